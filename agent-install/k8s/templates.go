@@ -0,0 +1,16 @@
+// Package k8s holds the templates of the kubernetes resources of the edge cluster agent. agent-install.sh fills them in
+// with sed, 'hzn util agent-manifest' fills them in from the templates built into hzn.
+package k8s
+
+import (
+	_ "embed"
+)
+
+//go:embed deployment-template.yml
+var DeploymentTemplate string
+
+//go:embed persistentClaim-template.yml
+var PersistentClaimTemplate string
+
+//go:embed auto-upgrade-cronjob-template.yml
+var AutoUpgradeCronjobTemplate string
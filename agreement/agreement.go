@@ -45,6 +45,7 @@ const (
 	EL_AG_UNABLE_WRITE_NODE_EXCH_PATTERN_TO_DB   = "Unable to save the new node exchange pattern %v to the local database. Error: %v"
 	EL_AG_TERM_UNABLE_SYNC_CONTAINERS            = "anax terminating, unable to sync up containers."
 	EL_AG_TERM_UNABLE_SYNC_AGS                   = "anax terminating, unable to complete agreement sync up. %v"
+	EL_AG_IGNORE_PROPOSAL_IN_MAINTENANCE         = "Node is in maintenance mode, ignoring proposal for agreement %v."
 )
 
 // This is does nothing useful at run time.
@@ -72,8 +73,13 @@ func MarkI18nMessages() {
 	msgPrinter.Sprintf(EL_AG_UNABLE_WRITE_NODE_EXCH_PATTERN_TO_DB)
 	msgPrinter.Sprintf(EL_AG_TERM_UNABLE_SYNC_CONTAINERS)
 	msgPrinter.Sprintf(EL_AG_TERM_UNABLE_SYNC_AGS)
+	msgPrinter.Sprintf(EL_AG_IGNORE_PROPOSAL_IN_MAINTENANCE)
 }
 
+// subworker names
+const NODE_DYNAMIC_PROPS = "NodeDynamicProperties"
+const NODE_PROPERTY_PROVIDER = "NodePropertyProvider-"
+
 // must be safely-constructed!!
 type AgreementWorker struct {
 	worker.BaseWorker        // embedded field
@@ -251,9 +257,9 @@ func (w *AgreementWorker) Initialize() bool {
 
 	glog.Info(logString(fmt.Sprintf("started")))
 
-	// Only check for container sync up when DockerEndpoint is set.
-	// If it is not set, the docker client could not be initialized.
-	if w.Config.Edge.DockerEndpoint != "" {
+	// Only check for container sync up when DockerEndpoint is set or the containers are run by containerd.
+	// Otherwise the docker client could not be initialized.
+	if w.Config.Edge.DockerEndpoint != "" || w.Config.IsContainerdRuntime() {
 		// Block for the container syncup message, to make sure the docker state matches our local DB.
 		for {
 			if w.containerSyncUpEvent == false {
@@ -310,6 +316,15 @@ func (w *AgreementWorker) Initialize() bool {
 		}
 	}
 
+	// Refresh the dynamic built-in properties of the node, e.g. the local hour, in the node policy.
+	w.DispatchSubworker(NODE_DYNAMIC_PROPS, w.checkNodeDynamicProperties, w.Config.Edge.NodePolicyCheckIntervalS, false)
+
+	// Refresh the properties of each node property provider on its own schedule.
+	for _, provider := range externalpolicy.GetNodePropertyProviders() {
+		p := provider
+		w.DispatchSubworker(NODE_PROPERTY_PROVIDER+p.GetName(), func() int { return w.refreshNodePropertyProvider(p) }, p.GetIntervalS(), false)
+	}
+
 	glog.Info(logString(fmt.Sprintf("waiting for commands.")))
 
 	return true
@@ -426,6 +441,13 @@ func (w *AgreementWorker) CommandHandler(command worker.Command) bool {
 			return true
 		} else if pDevice, err := persistence.FindExchangeDevice(w.db); err != nil {
 			glog.Errorf(logString(fmt.Sprintf("unable to get device from the local database. %v", err)))
+		} else if pDevice != nil && pDevice.Config.State == persistence.CONFIGSTATE_CONFIGURED && persistence.IsNodeInMaintenance(w.db) {
+			// The message is deleted, the agbot will make a new proposal after its agreement times out.
+			glog.Infof(logString(fmt.Sprintf("node is in maintenance mode, deleting proposal %v message %v", p, exchangeMsg.MsgId)))
+			eventlog.LogAgreementEvent2(w.db, persistence.SEVERITY_INFO,
+				persistence.NewMessageMeta(EL_AG_IGNORE_PROPOSAL_IN_MAINTENANCE, p.AgreementId()),
+				persistence.EC_IGNORE_PROPOSAL,
+				p.AgreementId(), persistence.WorkloadInfo{}, []persistence.ServiceSpec{}, "", msgProtocol)
 		} else if pDevice != nil && pDevice.Config.State == persistence.CONFIGSTATE_CONFIGURED {
 
 			deleteMessage, proposalAccepted = w.producerPH[msgProtocol].HandleProposalMessage(p, protocolMsg, exchangeMsg)
@@ -1028,6 +1050,10 @@ func deleteProducerAgreement(httpClient *http.Client, url string, deviceId strin
 }
 
 func (w *AgreementWorker) deleteMessage(msg *exchange.DeviceMessage) error {
+	if msg.MsgId == exchange.DIRECT_MSG_ID {
+		return nil
+	}
+
 	var resp interface{}
 	resp = new(exchange.PostDeviceResponse)
 	targetURL := w.GetExchangeURL() + "orgs/" + exchange.GetOrg(w.GetExchangeId()) + "/nodes/" + exchange.GetId(w.GetExchangeId()) + "/msgs/" + strconv.Itoa(msg.MsgId)
@@ -1047,6 +1073,11 @@ func (w *AgreementWorker) deleteMessage(msg *exchange.DeviceMessage) error {
 }
 
 func (w *AgreementWorker) messageInExchange(msgId int) (bool, error) {
+	// A message received on the stream to the agbot is not in the exchange.
+	if msgId == exchange.DIRECT_MSG_ID {
+		return true, nil
+	}
+
 	var resp interface{}
 	resp = new(exchange.GetDeviceMessageResponse)
 	targetURL := w.GetExchangeURL() + "orgs/" + exchange.GetOrg(w.GetExchangeId()) + "/nodes/" + exchange.GetId(w.GetExchangeId()) + "/msgs/" + strconv.Itoa(msgId)
@@ -14,6 +14,7 @@ import (
 	"github.com/open-horizon/anax/policy"
 	"sort"
 	"strings"
+	"time"
 )
 
 // Check node changes on the exchange and save it on local node
@@ -272,6 +273,41 @@ func (w *AgreementWorker) checkNodePolicyChanges() {
 	return
 }
 
+// Check the dynamic built-in properties of the node, e.g. the local hour and the uptime. When any of them changed,
+// the node policy is synced with the exchange, which saves the new values to the exchange node policy
+// and re-evaluates the agreements against it.
+func (w *AgreementWorker) checkNodeDynamicProperties() int {
+	if w.GetExchangeToken() == "" {
+		return 0
+	}
+
+	nodePolicy, err := persistence.FindNodePolicy(w.db)
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("Unable to read the node policy from the local database. %v", err)))
+		return 0
+	} else if nodePolicy == nil {
+		return 0
+	}
+
+	dynamicProps := externalpolicy.CreateNodeDynamicProperties(time.Now())
+	if externalpolicy.DynamicPropertiesChanged(nodePolicy.Properties, *dynamicProps) {
+		glog.V(3).Infof(logString(fmt.Sprintf("the dynamic built-in properties of the node changed to %v", dynamicProps)))
+		w.Commands <- NewNodePolicyChangeCommand()
+	}
+	return 0
+}
+
+// Get the properties of a node property provider again. When they changed, the node policy is updated right away
+// instead of at the next check of the dynamic properties.
+func (w *AgreementWorker) refreshNodePropertyProvider(p externalpolicy.NodePropertyProvider) int {
+	if changed, err := p.Refresh(); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to refresh the node properties. %v", err)))
+	} else if changed {
+		w.checkNodeDynamicProperties()
+	}
+	return 0
+}
+
 func (w *AgreementWorker) isOffline() {
 	msgPrinter := i18n.GetMessagePrinterWithLocale("en")
 	eventLogs, err := eventlog.GetEventLogs(w.db, false, nil, msgPrinter)
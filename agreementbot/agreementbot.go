@@ -23,10 +23,15 @@ import (
 	"time"
 )
 
+// The name of the agbot worker, the API reads the status of the worker by this name.
+const AGBOT_WORKER = "AgBot"
+
 // for identifying the subworkers used by this worker
 const DATABASE_HEARTBEAT = "AgbotDatabaseHeartBeat"
 const GOVERN_AGREEMENTS = "AgBotGovernAgreements"
 const GOVERN_ARCHIVED_AGREEMENTS = "AgBotGovernArchivedAgreements"
+const GOVERN_AGREEMENT_HISTORY = "AgBotGovernAgreementHistory"
+const FEDERATION_REFRESH = "AgBotFederationRefresh"
 const SECRETS_PROVIDER = "AgbotSecretsProvider"
 const SECRETS_UPDATE = "AgbotSecretsUpdate"
 const AGENT_FILE_VERSION_UPDATE = "AgbotUpdateAgentFileVersion"
@@ -36,6 +41,13 @@ const NMP_HA_GROUP_STATUS = "NMPHAGroupMonitor"
 const POLICY_WATCHER = "AgBotPolicyWatcher"
 const STALE_PARTITIONS = "AgbotStaleDatabasePartition"
 const MESSAGE_KEY_CHECK = "AgbotMessageKeyCheck"
+const NODE_SHARD = "AgbotNodeShard"
+const GOVERN_ROLLOUTS = "AgBotGovernRollouts"
+const GOVERN_SCHEDULES = "AgBotGovernSchedules"
+const GOVERN_FLEETS = "AgBotGovernFleets"
+const GOVERN_DEAD_LETTERS = "AgBotGovernDeadLetters"
+const GOVERN_RATE_LIMITS = "AgBotGovernRateLimits"
+const GOVERN_JOIN_TOKENS = "AgBotGovernJoinTokens"
 
 // Agreement governance timing state. Used in the GovernAgreements subworker.
 type DVState struct {
@@ -45,6 +57,13 @@ type DVState struct {
 // package level variable
 var patternManager *PatternManager
 var businessPolManager *BusinessPolicyManager
+var rolloutController *RolloutController
+var scheduleController *ScheduleController
+var fleetManager *FleetManager
+var agreementIndex *policy.AgreementIndex
+var antiAffinityTracker *AntiAffinityTracker
+var deadLetters *DeadLetterQueue
+var rateLimiter *RateLimiter
 
 // must be safely-constructed!!
 type AgreementBotWorker struct {
@@ -87,6 +106,7 @@ func NewAgreementBotWorker(name string, cfg *config.HorizonConfig, db persistenc
 	}
 
 	patternManager = NewPatternManager()
+	exchangeFederation = NewExchangeFederation(cfg)
 
 	glog.Info("Starting AgreementBot worker")
 	worker.Start(worker, int(cfg.AgreementBot.NewContractIntervalS))
@@ -103,7 +123,7 @@ func (w *AgreementBotWorker) Messages() chan events.Message {
 
 func (w *AgreementBotWorker) NewEvent(incoming events.Message) {
 
-	if w.Config.AgreementBot == (config.AGConfig{}) {
+	if !w.Config.IsAgbotConfigured() {
 		return
 	}
 
@@ -256,16 +276,16 @@ func (w *AgreementBotWorker) NewEvent(incoming events.Message) {
 			w.Commands <- NewServicePolicyChangeCommand(msg)
 		case events.CHANGE_NODE_POLICY_TYPE:
 			// A node policy has changed.
-			w.nodeSearch.SetRescanNeeded()
+			w.nodeSearch.AddChangedNodes(msg.GetChange())
 		case events.CHANGE_NODE_AGREEMENT_TYPE:
 			// A node agreement has changed.
-			w.nodeSearch.SetRescanNeeded()
+			w.nodeSearch.AddChangedNodes(msg.GetChange())
 		case events.CHANGE_NODE_CONFIGSTATE_TYPE:
 			// A service's config state has changed on a node.
-			w.nodeSearch.SetRescanNeeded()
+			w.nodeSearch.AddChangedNodes(msg.GetChange())
 		case events.CHANGE_NODE_TYPE:
 			// The node itself has changed.
-			w.nodeSearch.SetRescanNeeded()
+			w.nodeSearch.AddChangedNodes(msg.GetChange())
 		case events.CHANGE_HA_GROUP:
 			// A hagroup has changed
 			w.Commands <- NewHAGroupChangedCommand(msg)
@@ -311,7 +331,7 @@ func (w *AgreementBotWorker) Initialize() bool {
 	glog.Info("AgreementBot worker initializing")
 
 	// If there is no Agbot config, we will terminate. This is a normal condition when running on a node.
-	if w.Config.AgreementBot == (config.AGConfig{}) {
+	if !w.Config.IsAgbotConfigured() {
 		glog.Warningf("AgreementBotWorker terminating, no AgreementBot config.")
 		return false
 	} else if w.db == nil {
@@ -358,6 +378,16 @@ func (w *AgreementBotWorker) Initialize() bool {
 	// Give the policy manager a chance to read in all the policies. The agbot worker will not proceed past this point
 	// until it has some policies to work with.
 	businessPolManager = NewBusinessPolicyManager(w.Messages())
+	rolloutController = NewRolloutController(w.db, w.Messages())
+	scheduleController = NewScheduleController(w, w.Messages(), w.nodeSearch)
+	fleetManager = NewFleetManager(w.db, w, w.Messages(), w.nodeSearch)
+	agreementIndex = policy.NewAgreementIndex()
+	antiAffinityTracker = NewAntiAffinityTracker()
+	if err := fleetManager.Refresh(); err != nil {
+		glog.Errorf(AWlogString(fmt.Sprintf("unable to read the fleets, error: %v", err)))
+	}
+	deadLetters = NewDeadLetterQueue(w.db, w.Config.AgreementBot.MessageKeyPath)
+	rateLimiter = NewRateLimiter(w.Config.AgreementBot.OrgAgreementRateLimit, w.Config.AgreementBot.PolicyAgreementRateLimit, w.nodeSearch)
 	w.MMSObjectPM = NewMMSObjectPolicyManager(w.BaseWorker.Manager.Config)
 	for {
 
@@ -401,6 +431,11 @@ func (w *AgreementBotWorker) Initialize() bool {
 		return w.fail()
 	}
 
+	// Start serving the streams of the nodes that send their messages directly to this agbot.
+	if err := w.startDirectTransport(); err != nil {
+		glog.Errorf(AWlogString(fmt.Sprintf("unable to start the gRPC agreement transport, messages will be sent through the exchange, error: %v", err)))
+	}
+
 	// For each agreement protocol in the current list of configured policies, startup a processor
 	// to initiate the protocol.
 	for protocolName, _ := range w.pm.GetAllAgreementProtocols() {
@@ -432,12 +467,27 @@ func (w *AgreementBotWorker) Initialize() bool {
 	// Start the go thread that checks for stale partitions.
 	w.DispatchSubworker(STALE_PARTITIONS, w.stalePartitions, int(w.BaseWorker.Manager.Config.GetPartitionStale()), false)
 
+	// Start the go thread that rebalances the nodes when agbots join or leave the node shard.
+	if w.Config.GetAgbotShardNodes() {
+		w.DispatchSubworker(NODE_SHARD, w.rebalanceNodeShard, int(w.BaseWorker.Manager.Config.GetPartitionStale()/3), false)
+	}
+
 	// The agbot worker is now ready to handle incoming messages
 	w.ready = true
 
 	// Start the governance routines using the subworker APIs.
 	w.DispatchSubworker(GOVERN_AGREEMENTS, w.GovernAgreements, int(w.BaseWorker.Manager.Config.AgreementBot.ProcessGovernanceIntervalS), false)
 	w.DispatchSubworker(GOVERN_ARCHIVED_AGREEMENTS, w.GovernArchivedAgreements, 1800, false)
+	w.DispatchSubworker(GOVERN_AGREEMENT_HISTORY, w.GovernAgreementHistory, 3600, false)
+	if exchangeFederation != nil {
+		w.DispatchSubworker(FEDERATION_REFRESH, w.refreshFederation, int(w.Config.AgreementBot.FullRescanS), false)
+	}
+	w.DispatchSubworker(GOVERN_ROLLOUTS, w.GovernRollouts, 60, false)
+	w.DispatchSubworker(GOVERN_SCHEDULES, w.GovernSchedules, 60, false)
+	w.DispatchSubworker(GOVERN_FLEETS, w.GovernFleets, 60, false)
+	w.DispatchSubworker(GOVERN_DEAD_LETTERS, w.GovernDeadLetters, 10, false)
+	w.DispatchSubworker(GOVERN_RATE_LIMITS, w.GovernRateLimits, 10, false)
+	w.DispatchSubworker(GOVERN_JOIN_TOKENS, w.GovernJoinTokens, 3600, false)
 	//w.DispatchSubworker(GOVERN_BC_NEEDS, w.GovernBlockchainNeeds, 60, false)
 	w.DispatchSubworker(MESSAGE_KEY_CHECK, w.messageKeyCheck, w.BaseWorker.Manager.Config.AgreementBot.MessageKeyCheck, false)
 	w.DispatchSubworker(SECRETS_UPDATE, w.secretsUpdate, w.BaseWorker.Manager.Config.GetSecretsUpdateCheck(), false)
@@ -659,6 +709,12 @@ func (w *AgreementBotWorker) CommandHandler(command worker.Command) bool {
 			w.processProtocolMessage(w.calculateMessageLimit())
 		}
 
+	case *DirectMessageCommand:
+		// A message received on the stream of a node is not in the exchange, so it cannot be consumed twice.
+		cmd, _ := command.(*DirectMessageCommand)
+		glog.V(3).Infof(fmt.Sprintf("AgreementBotWorker reading message from %v on its stream", cmd.Msg.DeviceId))
+		w.handleProtocolMessage(&cmd.Msg)
+
 	case *PatternChangeCommand:
 		cmd, _ := command.(*PatternChangeCommand)
 		go w.generatePolicyFromPatterns(&cmd.Msg)
@@ -737,56 +793,79 @@ func (w *AgreementBotWorker) processProtocolMessage(limit int) {
 		return
 	}
 
-	if msgs, err := w.getMessages(limit); err != nil {
+	if msgs, err := w.getMessages(w, limit); err != nil {
 		glog.Errorf(fmt.Sprintf("AgreementBotWorker unable to retrieve exchange messages, error: %v", err))
 	} else {
 		// Loop through all the returned messages and process them.
 		for _, msg := range msgs {
 
 			glog.V(3).Infof(fmt.Sprintf("AgreementBotWorker reading message %v from the exchange", msg.MsgId))
-			// First get my own keys
-			_, myPrivKey, _ := exchange.GetKeys(w.Config.AgreementBot.MessageKeyPath)
-
-			// Deconstruct and decrypt the message. If there is a problem with the message, it will be deleted.
-			deleteMessage := true
-			if protocolMessage, receivedPubKey, err := exchange.DeconstructExchangeMessage(msg.Message, myPrivKey); err != nil {
-				glog.Errorf(fmt.Sprintf("AgreementBotWorker unable to deconstruct exchange message %v, error %v", msg, err))
-			} else if serializedPubKey, err := exchange.MarshalPublicKey(receivedPubKey); err != nil {
-				glog.Errorf(fmt.Sprintf("AgreementBotWorker unable to marshal the key from the encrypted message %v, error %v", receivedPubKey, err))
-			} else if bytes.Compare(msg.DevicePubKey, serializedPubKey) != 0 {
-				glog.Errorf(fmt.Sprintf("AgreementBotWorker sender public key from exchange %x is not the same as the sender public key in the encrypted message %x", msg.DevicePubKey, serializedPubKey))
-			} else if msgProtocol, err := abstractprotocol.ExtractProtocol(string(protocolMessage)); err != nil {
-				glog.Errorf(fmt.Sprintf("AgreementBotWorker unable to extract agreement protocol name from message %v", protocolMessage))
-			} else if !w.consumerPH.Has(msgProtocol) {
-				glog.Infof(fmt.Sprintf("AgreementBotWorker unable to direct exchange message %v to a protocol handler, deleting it.", protocolMessage))
-				deleteMessage = false
-				DeleteMessage(msg.MsgId, w.GetExchangeId(), w.GetExchangeToken(), w.GetExchangeURL(), w.httpClient)
-			} else {
-				// The message seems to be good, so don't delete it yet, the protocol worker that handles the message will delete it.
-				deleteMessage = false
-
-				// Send the message to a protocol worker.
-				cmd := NewNewProtocolMessageCommand(protocolMessage, msg.MsgId, msg.DeviceId, msg.DevicePubKey)
-				if !w.consumerPH.Get(msgProtocol).AcceptCommand(cmd) {
-					glog.Infof(fmt.Sprintf("AgreementBotWorker protocol handler for %v not accepting exchange messages, deleting msg.", msgProtocol))
-					DeleteMessage(msg.MsgId, w.GetExchangeId(), w.GetExchangeToken(), w.GetExchangeURL(), w.httpClient)
-				} else if err := w.consumerPH.Get(msgProtocol).DispatchProtocolMessage(cmd, w.consumerPH.Get(msgProtocol)); err != nil {
-					DeleteMessage(msg.MsgId, w.GetExchangeId(), w.GetExchangeToken(), w.GetExchangeURL(), w.httpClient)
-				}
+			w.handleProtocolMessage(&msg)
 
-			}
+		}
+		limit -= len(msgs)
+	}
 
-			// If anything went wrong trying to decrypt the message or verify its origin, etc, just delete it. These errors aren't
-			// expected to be retryable.
-			if deleteMessage {
-				DeleteMessage(msg.MsgId, w.GetExchangeId(), w.GetExchangeToken(), w.GetExchangeURL(), w.httpClient)
+	// The nodes of the federated exchanges send their messages to the agbot's mailbox in their own exchange.
+	for _, m := range exchangeFederation.Members() {
+		if limit <= 0 {
+			break
+		} else if msgs, err := w.getMessages(m, limit); err != nil {
+			glog.Errorf(fmt.Sprintf("AgreementBotWorker unable to retrieve messages from federated exchange %v, error: %v", m.Name, err))
+		} else {
+			for _, msg := range msgs {
+				glog.V(3).Infof(fmt.Sprintf("AgreementBotWorker reading message %v from federated exchange %v", msg.MsgId, m.Name))
+				msg.MsgId = exchangeFederation.trackMessage(m, msg.MsgId)
+				w.handleProtocolMessage(&msg)
 			}
-
+			limit -= len(msgs)
 		}
 	}
 	glog.V(3).Infof(fmt.Sprintf("AgreementBotWorker done processing messages"))
 }
 
+// Decrypt a message and put it on the work queue of its protocol.
+func (w *AgreementBotWorker) handleProtocolMessage(msg *exchange.AgbotMessage) {
+
+	// First get my own keys
+	_, myPrivKey, _ := exchange.GetKeys(w.Config.AgreementBot.MessageKeyPath)
+
+	// Deconstruct and decrypt the message. If there is a problem with the message, it will be deleted.
+	deleteMessage := true
+	if protocolMessage, receivedPubKey, err := exchange.DeconstructExchangeMessage(msg.Message, myPrivKey); err != nil {
+		glog.Errorf(fmt.Sprintf("AgreementBotWorker unable to deconstruct exchange message %v, error %v", msg, err))
+	} else if serializedPubKey, err := exchange.MarshalPublicKey(receivedPubKey); err != nil {
+		glog.Errorf(fmt.Sprintf("AgreementBotWorker unable to marshal the key from the encrypted message %v, error %v", receivedPubKey, err))
+	} else if bytes.Compare(msg.DevicePubKey, serializedPubKey) != 0 {
+		glog.Errorf(fmt.Sprintf("AgreementBotWorker sender public key from exchange %x is not the same as the sender public key in the encrypted message %x", msg.DevicePubKey, serializedPubKey))
+	} else if msgProtocol, err := abstractprotocol.ExtractProtocol(string(protocolMessage)); err != nil {
+		glog.Errorf(fmt.Sprintf("AgreementBotWorker unable to extract agreement protocol name from message %v", protocolMessage))
+	} else if !w.consumerPH.Has(msgProtocol) {
+		glog.Infof(fmt.Sprintf("AgreementBotWorker unable to direct exchange message %v to a protocol handler, deleting it.", protocolMessage))
+		deleteMessage = false
+		DeleteMessage(msg.MsgId, w.GetExchangeId(), w.GetExchangeToken(), w.GetExchangeURL(), w.httpClient)
+	} else {
+		// The message seems to be good, so don't delete it yet, the protocol worker that handles the message will delete it.
+		deleteMessage = false
+
+		// Send the message to a protocol worker.
+		cmd := NewNewProtocolMessageCommand(protocolMessage, msg.MsgId, msg.DeviceId, msg.DevicePubKey)
+		if !w.consumerPH.Get(msgProtocol).AcceptCommand(cmd) {
+			glog.Infof(fmt.Sprintf("AgreementBotWorker protocol handler for %v not accepting exchange messages, deleting msg.", msgProtocol))
+			DeleteMessage(msg.MsgId, w.GetExchangeId(), w.GetExchangeToken(), w.GetExchangeURL(), w.httpClient)
+		} else if err := w.consumerPH.Get(msgProtocol).DispatchProtocolMessage(cmd, w.consumerPH.Get(msgProtocol)); err != nil {
+			DeleteMessage(msg.MsgId, w.GetExchangeId(), w.GetExchangeToken(), w.GetExchangeURL(), w.httpClient)
+		}
+
+	}
+
+	// If anything went wrong trying to decrypt the message or verify its origin, etc, just delete it. These errors aren't
+	// expected to be retryable.
+	if deleteMessage {
+		DeleteMessage(msg.MsgId, w.GetExchangeId(), w.GetExchangeToken(), w.GetExchangeURL(), w.httpClient)
+	}
+}
+
 func (w *AgreementBotWorker) NoWorkHandler() {
 
 	w.noworkDispatch = time.Now().Unix()
@@ -949,12 +1028,12 @@ func (w *AgreementBotWorker) errorPolicy(org string, fileName string, err error)
 	glog.Errorf(fmt.Sprintf("AgreementBotWorker tried to read policy file %v/%v, encountered error: %v", org, fileName, err))
 }
 
-func (w *AgreementBotWorker) getMessages(limit int) ([]exchange.AgbotMessage, error) {
+func (w *AgreementBotWorker) getMessages(ec exchange.ExchangeContext, limit int) ([]exchange.AgbotMessage, error) {
 	var resp interface{}
 	resp = new(exchange.GetAgbotMessageResponse)
-	targetURL := w.GetExchangeURL() + "orgs/" + exchange.GetOrg(w.GetExchangeId()) + "/agbots/" + exchange.GetId(w.GetExchangeId()) + "/msgs?maxmsgs=" + strconv.Itoa(limit)
+	targetURL := ec.GetExchangeURL() + "orgs/" + exchange.GetOrg(ec.GetExchangeId()) + "/agbots/" + exchange.GetId(ec.GetExchangeId()) + "/msgs?maxmsgs=" + strconv.Itoa(limit)
 	for {
-		if err, tpErr := exchange.InvokeExchange(w.httpClient, "GET", targetURL, w.GetExchangeId(), w.GetExchangeToken(), nil, &resp); err != nil {
+		if err, tpErr := exchange.InvokeExchange(w.httpClient, "GET", targetURL, ec.GetExchangeId(), ec.GetExchangeToken(), nil, &resp); err != nil {
 			glog.Errorf(err.Error())
 			return nil, err
 		} else if tpErr != nil {
@@ -993,6 +1072,16 @@ func DeleteConsumerAgreement(httpClient *http.Client, url string, agbotId string
 }
 
 func DeleteMessage(msgId int, agbotId, agbotToken, exchangeURL string, httpClient *http.Client) error {
+	// A message received on the stream of a node is not in the exchange.
+	if msgId == exchange.DIRECT_MSG_ID {
+		return nil
+	} else if IsFederatedMessage(msgId) {
+		return exchangeFederation.deleteMessage(msgId, httpClient)
+	}
+	return deleteExchangeMessage(msgId, agbotId, agbotToken, exchangeURL, httpClient)
+}
+
+func deleteExchangeMessage(msgId int, agbotId, agbotToken, exchangeURL string, httpClient *http.Client) error {
 	var resp interface{}
 	resp = new(exchange.PostDeviceResponse)
 	targetURL := exchangeURL + "orgs/" + exchange.GetOrg(agbotId) + "/agbots/" + exchange.GetId(agbotId) + "/msgs/" + strconv.Itoa(msgId)
@@ -1218,14 +1307,28 @@ func listContains(list string, target string) bool {
 }
 
 func (w *AgreementBotWorker) registerPublicKey() error {
-	glog.V(5).Infof(AWlogString(fmt.Sprintf("registering agbot public key")))
+	if err := w.registerExchangePublicKey(w); err != nil {
+		return err
+	}
+
+	// The nodes of the federated exchanges read the key from the agbot's object in their own exchange.
+	for _, m := range exchangeFederation.Members() {
+		if err := w.registerExchangePublicKey(m); err != nil {
+			return fmt.Errorf("unable to register public key in federated exchange %v, error: %v", m.Name, err)
+		}
+	}
+	return nil
+}
+
+func (w *AgreementBotWorker) registerExchangePublicKey(ec exchange.ExchangeContext) error {
+	glog.V(5).Infof(AWlogString(fmt.Sprintf("registering agbot public key in %v", ec.GetExchangeURL())))
 
 	as := exchange.CreateAgbotPublicKeyPatch(w.Config.AgreementBot.MessageKeyPath)
 	var resp interface{}
 	resp = new(exchange.PostDeviceResponse)
-	targetURL := w.GetExchangeURL() + "orgs/" + exchange.GetOrg(w.GetExchangeId()) + "/agbots/" + exchange.GetId(w.GetExchangeId())
+	targetURL := ec.GetExchangeURL() + "orgs/" + exchange.GetOrg(ec.GetExchangeId()) + "/agbots/" + exchange.GetId(ec.GetExchangeId())
 	for {
-		if err, tpErr := exchange.InvokeExchange(w.httpClient, "PATCH", targetURL, w.GetExchangeId(), w.GetExchangeToken(), &as, &resp); err != nil {
+		if err, tpErr := exchange.InvokeExchange(w.httpClient, "PATCH", targetURL, ec.GetExchangeId(), ec.GetExchangeToken(), &as, &resp); err != nil {
 			glog.Errorf(err.Error())
 			return err
 		} else if tpErr != nil {
@@ -1248,9 +1351,18 @@ func (w *AgreementBotWorker) serviceResolver(wURL string, wOrg string, wVersion
 	return asl, err
 }
 
+// The changes of the federated exchanges are not reported by the agbot's own exchange, so the served patterns and
+// deployment policies, and the policies themselves, are read again from all the exchanges every full rescan interval.
+func (w *AgreementBotWorker) refreshFederation() int {
+	glog.V(5).Infof(AWlogString(fmt.Sprintf("refreshing the served patterns and deployment policies of the federated exchanges")))
+	w.Commands <- NewServedPatternCommand()
+	w.Commands <- NewServedPolicyCommand()
+	return 0
+}
+
 // Get the configured org/pattern/nodeorg triplet for this agbot.
 func (w *AgreementBotWorker) saveAgbotServedPatterns() {
-	servedPatterns, err := exchange.GetHTTPAgbotServedPattern(w)()
+	servedPatterns, err := exchangeFederation.ServedPatterns(w)
 	if err != nil {
 		glog.Errorf(AWlogString(fmt.Sprintf("unable to retrieve agbot served patterns, error %v", err)))
 	}
@@ -1263,7 +1375,7 @@ func (w *AgreementBotWorker) saveAgbotServedPatterns() {
 
 // Get the configured (policy org, business policy, node org) triplets for this agbot.
 func (w *AgreementBotWorker) saveAgbotServedPolicies() {
-	servedPolicies, err := exchange.GetHTTPAgbotServedDeploymentPolicy(w)()
+	servedPolicies, err := exchangeFederation.ServedPolicies(w)
 	if err != nil {
 		glog.Errorf(AWlogString(fmt.Sprintf("unable to retrieve agbot served deployment policies, error %v", err)))
 	}
@@ -1296,13 +1408,14 @@ func (w *AgreementBotWorker) generatePolicyFromPatterns(msg *events.ExchangeChan
 		var err error
 
 		// check if the org exists on the exchange or not
-		if _, err = exchange.GetOrganization(w.Config.Collaborators.HTTPClientFactory, org, w.GetExchangeURL(), w.GetExchangeId(), w.GetExchangeToken()); err != nil {
+		ec := exchangeFederation.ForOrg(w, org)
+		if _, err = exchange.GetOrganization(w.Config.Collaborators.HTTPClientFactory, org, ec.GetExchangeURL(), ec.GetExchangeId(), ec.GetExchangeToken()); err != nil {
 			// org does not exist is returned as an error
 			glog.V(5).Infof(AWlogString(fmt.Sprintf("unable to get organization %v: %v", org, err)))
 			exchangePatternMetadata = make(map[string]exchange.Pattern)
 		} else {
 			// Query exchange for all patterns in the org
-			if exchangePatternMetadata, err = exchange.GetPatterns(w.Config.Collaborators.HTTPClientFactory, org, "", w.GetExchangeURL(), w.GetExchangeId(), w.GetExchangeToken()); err != nil {
+			if exchangePatternMetadata, err = exchange.GetPatterns(w.Config.Collaborators.HTTPClientFactory, org, "", ec.GetExchangeURL(), ec.GetExchangeId(), ec.GetExchangeToken()); err != nil {
 				return errors.New(fmt.Sprintf("unable to get patterns for org %v, error %v", org, err))
 			}
 		}
@@ -1340,14 +1453,14 @@ func (w *AgreementBotWorker) generatePolicyFromBusinessPols(msg *events.Exchange
 		var err error
 
 		// check if the org exists on the exchange or not
-		getOrganization := exchange.GetHTTPExchangeOrgHandler(w)
+		getOrganization := exchange.GetHTTPExchangeOrgHandler(exchangeFederation.ForOrg(w, org))
 		if _, err = getOrganization(org); err != nil {
 			// org does not exist is returned as an error
 			glog.V(5).Infof(AWlogString(fmt.Sprintf("unable to get organization %v: %v", org, err)))
 			exchPolsMetadata = make(map[string]exchange.ExchangeBusinessPolicy)
 		} else {
 			// Query exchange for all business policies in the org
-			getBusinessPolicies := exchange.GetHTTPBusinessPoliciesHandler(w)
+			getBusinessPolicies := exchange.GetHTTPBusinessPoliciesHandler(exchangeFederation.ForOrg(w, org))
 			if exchPolsMetadata, err = getBusinessPolicies(org, ""); err != nil {
 				return errors.New(fmt.Sprintf("unable to get business polices for org %v, error %v", org, err))
 			}
@@ -1498,6 +1611,46 @@ func (w *AgreementBotWorker) stalePartitions() int {
 	return 0
 }
 
+// Move the nodes to the service version they should run while the service versions are rolled out. This function is
+// called by the rollout governance subworker.
+func (w *AgreementBotWorker) GovernRollouts() int {
+	rolloutController.Govern()
+	return 0
+}
+
+// Release the agreements and upgrades that were waiting for a maintenance window of their deployment policy to open. This
+// function is called by the schedule governance subworker.
+func (w *AgreementBotWorker) GovernSchedules() int {
+	scheduleController.Govern()
+	return 0
+}
+
+// Act on the changes to the fleets targeted by the deployment policies. This function is called by the fleet governance
+// subworker.
+func (w *AgreementBotWorker) GovernFleets() int {
+	fleetManager.Govern()
+	return 0
+}
+
+// Replay the dead letters requested through the API. This function is called by the dead letter governance subworker.
+func (w *AgreementBotWorker) GovernDeadLetters() int {
+	deadLetters.Govern(w.consumerPH)
+	return 0
+}
+
+// Search again for the nodes that were held by the agreement rate limits. This function is called by the rate limit
+// governance subworker.
+func (w *AgreementBotWorker) GovernRateLimits() int {
+	rateLimiter.Govern()
+	return 0
+}
+
+// Check for agbots joining or leaving the node shard. This function is called by the node shard subworker.
+func (w *AgreementBotWorker) rebalanceNodeShard() int {
+	w.nodeSearch.RebalanceShard()
+	return 0
+}
+
 // Ensure that the agbot's message key is still in its object in the exchange. If the agbot itself is missing,
 // we will panic (that should not happen). If the key is missing (i.e. the current key is a zero length byte array)
 // we will add our key back. If there is a key but it is just wrong, we will panic. This latter case could occur if
@@ -1599,7 +1752,8 @@ func (w *AgreementBotWorker) monitorHAGroupNMPUpdates() int {
 
 	for _, node := range upgradingNodes {
 		remove := false
-		if exNode, err := exchange.GetExchangeDevice(w.GetHTTPFactory(), fmt.Sprintf("%v/%v", node.OrgId, node.NodeId), w.GetExchangeId(), w.GetExchangeToken(), w.GetExchangeURL()); err != nil {
+		ec := exchangeFederation.ForOrg(w, node.OrgId)
+		if exNode, err := exchange.GetExchangeDevice(w.GetHTTPFactory(), fmt.Sprintf("%v/%v", node.OrgId, node.NodeId), ec.GetExchangeId(), ec.GetExchangeToken(), ec.GetExchangeURL()); err != nil {
 			glog.Errorf(AWlogString(fmt.Sprintf("error getting node %v/%v: %v", node.OrgId, node.NodeId, err)))
 			continue
 		} else if exNode == nil || exNode.HAGroup != node.GroupName {
@@ -1607,7 +1761,7 @@ func (w *AgreementBotWorker) monitorHAGroupNMPUpdates() int {
 		} else if nmpStatus, err := exchange.GetNodeManagementPolicyStatus(w, node.OrgId, node.NodeId, node.NMPName); err != nil {
 			glog.Errorf(AWlogString(fmt.Sprintf("error getting nmp status %v/%v/%v: %v", node.OrgId, node.NodeId, node.NMPName, err)))
 			continue
-		} else if !exchangecommon.IsActiveStatus(nmpStatus.Status()) {
+		} else if haAgentUpgradeDone(nmpStatus, exNode.LastHeartbeat) {
 			remove = true
 		}
 
@@ -1673,7 +1827,8 @@ func (w *AgreementBotWorker) updateWorkloadUsageForHAGroup(org string, groupName
 	glog.V(3).Info(AWlogString(fmt.Sprintf("AgreementBot start to update workload usages after HA group change for %v/%v", org, groupName)))
 
 	// get hs group node IDs
-	haGroup, err := GetHAGroup(org, groupName, w.GetHTTPFactory().NewHTTPClient(nil), w.GetExchangeURL(), w.GetExchangeId(), w.GetExchangeToken())
+	ec := exchangeFederation.ForOrg(w, org)
+	haGroup, err := GetHAGroup(org, groupName, w.GetHTTPFactory().NewHTTPClient(nil), ec.GetExchangeURL(), ec.GetExchangeId(), ec.GetExchangeToken())
 	if err != nil {
 		glog.Errorf(AWlogString(fmt.Sprintf("Failed to get HA group %v/%v from the exchange. %v", org, groupName, err)))
 		return
@@ -1729,7 +1884,8 @@ func (w *AgreementBotWorker) removeInvalidEntriesFrom_HA_WLU(haWorkloads []persi
 	for _, ha_wlu := range haWorkloads {
 		bDelete := false
 
-		exHAGroup, err := GetHAGroup(ha_wlu.OrgId, ha_wlu.GroupName, w.GetHTTPFactory().NewHTTPClient(nil), w.GetExchangeURL(), w.GetExchangeId(), w.GetExchangeToken())
+		ec := exchangeFederation.ForOrg(w, ha_wlu.OrgId)
+		exHAGroup, err := GetHAGroup(ha_wlu.OrgId, ha_wlu.GroupName, w.GetHTTPFactory().NewHTTPClient(nil), ec.GetExchangeURL(), ec.GetExchangeId(), ec.GetExchangeToken())
 		if err != nil {
 			return errors.New(fmt.Sprintf("unable to get HA group %v/%v from exchange , error %v", ha_wlu.OrgId, ha_wlu.GroupName, err))
 		}
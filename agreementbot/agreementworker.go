@@ -19,6 +19,7 @@ import (
 	"github.com/open-horizon/anax/exchangecommon"
 	"github.com/open-horizon/anax/externalpolicy"
 	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/metrics"
 	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/anax/worker"
 	"golang.org/x/text/message"
@@ -341,7 +342,7 @@ func (b *BaseAgreementWorker) AgreementLockManager() *AgreementLockManager {
 	return b.alm
 }
 
-func (b *BaseAgreementWorker) checkPolicyCompatibility(workerId string, wi *InitiateAgreement, nodePolicy *policy.Policy, businessPolicy *policy.Policy, mergedServicePolicy *externalpolicy.ExternalPolicy, nodeArch string, msgPrinter *message.Printer) (bool, *policy.Policy, error) {
+func (b *BaseAgreementWorker) checkPolicyCompatibility(workerId string, wi *InitiateAgreement, workload *policy.Workload, nodePolicy *policy.Policy, businessPolicy *policy.Policy, mergedServicePolicy *externalpolicy.ExternalPolicy, nodeArch string, msgPrinter *message.Printer) (bool, *policy.Policy, error) {
 
 	if compatible, reason, _, consumPol, err := compcheck.CheckPolicyCompatiblility(nodePolicy, businessPolicy, mergedServicePolicy, "", msgPrinter); err != nil {
 		glog.Warning(BAWlogstring(workerId, fmt.Sprintf("error checking policy compatibility. %v.", err.Error())))
@@ -354,6 +355,7 @@ func (b *BaseAgreementWorker) checkPolicyCompatibility(workerId string, wi *Init
 			return true, consumPol, nil
 		} else {
 			glog.Warningf(BAWlogstring(workerId, fmt.Sprintf("failed matching node policy %v and %v, error: %v", wi.ProducerPolicy, wi.ConsumerPolicy, reason)))
+			recordNegotiationFailure(wi, workload, NF_STAGE_POLICY, reason)
 			return false, nil, nil
 		}
 	}
@@ -363,14 +365,22 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 
 	msgPrinter := i18n.GetMessagePrinter()
 
+	// Keep the agreements of the org and the policy within their rate limits, the node is searched again when there is
+	// room for it.
+	if ok, next := rateLimiter.AllowsAgreement(wi.Org, cutil.FormOrgSpecUrl(wi.ConsumerPolicyName, wi.Org), wi.Device.Id); !ok {
+		recordNegotiationFailure(wi, nil, NF_STAGE_RATE_LIMIT, msgPrinter.Sprintf("The agreement rate limit of org %v or policy %v is exceeded, negotiating again at %v.", wi.Org, wi.ConsumerPolicyName, next.UTC().Format(time.RFC3339)))
+		return
+	}
+
 	// get node policy
-	nodePolicyHandler := exchange.GetHTTPNodePolicyHandler(b)
+	nodePolicyHandler := exchange.GetHTTPNodePolicyHandler(exchangeFederation.ForOrg(b, exchange.GetOrg(wi.Device.Id)))
 	_, nodePolicy, err := compcheck.GetNodePolicy(nodePolicyHandler, wi.Device.Id, msgPrinter)
 	if err != nil {
 		glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("%v", err)))
 		return
 	} else if nodePolicy == nil {
 		glog.Warning(BAWlogstring(workerId, fmt.Sprintf("Cannot find node policy for this node %v.", wi.Device.Id)))
+		recordNegotiationFailure(wi, nil, NF_STAGE_NODE_POLICY, msgPrinter.Sprintf("Cannot find node policy for this node %v.", wi.Device.Id))
 		return
 	} else {
 		if glog.V(5) {
@@ -378,10 +388,54 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 		}
 	}
 
+	// Only trust the node properties that must be signed when the node policy signature covers them, and do not make
+	// an agreement with a node whose signed properties or user input were changed.
+	if err := verifyNodePolicy(&b.config.AgreementBot, wi.Device.Id, nodePolicy, func() ([]policy.UserInput, error) {
+		if dev, err := GetFederatedDevice(cph, wi.Device.Id); err != nil {
+			return nil, err
+		} else {
+			return dev.UserInput, nil
+		}
+	}); err != nil {
+		glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("rejecting node %v, error: %v", wi.Device.Id, err)))
+		recordNegotiationFailure(wi, nil, NF_STAGE_SIGNATURE, msgPrinter.Sprintf("The node policy signature of node %v is not valid: %v", wi.Device.Id, err))
+		return
+	}
+
 	// If a deployment policy is being used, set wi.ProducerPolicy to the node policy
 	if wi.ConsumerPolicy.PatternId == "" {
 		// non pattern case
 
+		// Only deploy the service while a maintenance window of the deployment policy is open for the node, the node is
+		// searched again when the next window opens.
+		if open, next := scheduleController.AllowsAgreement(wi.Org, &wi.ConsumerPolicy, wi.Device.Id, nodePolicy.Properties); !open {
+			recordNegotiationFailure(wi, nil, NF_STAGE_SCHEDULE, msgPrinter.Sprintf("The deployment policy schedule is closed for this node until %v.", next.UTC().Format(time.RFC3339)))
+			return
+		}
+
+		// When the deployment policy targets fleets, only deploy the service to the nodes in those fleets.
+		if inFleet, reason := fleetManager.AllowsAgreement(wi.Org, &wi.ConsumerPolicy, wi.Device.Id, nodePolicy.Properties); !inFleet {
+			glog.V(5).Infof(BAWlogstring(workerId, reason))
+			recordNegotiationFailure(wi, nil, NF_STAGE_FLEET, reason)
+			return
+		}
+
+		// Do not place the service on a node whose current agreements or free memory do not satisfy the placement
+		// constraints of the deployment policy.
+		if ok, reason := placementAllowsAgreement(b.db, b, wi.Org, &wi.ConsumerPolicy, wi.Device.Id, nodePolicy.Properties); !ok {
+			glog.V(5).Infof(BAWlogstring(workerId, reason))
+			recordNegotiationFailure(wi, nil, NF_STAGE_PLACEMENT, reason)
+			return
+		}
+
+		// Spread the service across the sites of the nodes, the node is skipped when its site already has the instances
+		// allowed by the anti-affinity of the deployment policy.
+		if ok, reason := antiAffinityAllowsAgreement(b.db, b, wi.Org, &wi.ConsumerPolicy, wi.Device.Id, nodePolicy.Properties); !ok {
+			glog.V(5).Infof(BAWlogstring(workerId, reason))
+			recordNegotiationFailure(wi, nil, NF_STAGE_ANTI_AFFINITY, reason)
+			return
+		}
+
 		// If a deployment policy is being used and multiple service versions are possible, do an initial check of just the policy constraints of the deployment policy
 		// with the node properties to see if those match before we get too far invested in checking matches of all the different service versions.
 		// In the case were have thousands of deployment policies, this can avoid lots of calls to check and create workload_usages in the DB if there isn't a match at this level
@@ -395,7 +449,7 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 				Constraints: []string{},
 			}
 
-			compatible, _, _ := b.checkPolicyCompatibility(workerId, wi, nodePolicy, &wi.ConsumerPolicy, &EmptySvcPolicy, "", msgPrinter)
+			compatible, _, _ := b.checkPolicyCompatibility(workerId, wi, nil, nodePolicy, &wi.ConsumerPolicy, &EmptySvcPolicy, "", msgPrinter)
 			if !compatible {
 				// Not compatible with the constraints of the deployment policy so no need to continue checking with the service versions
 				return
@@ -432,7 +486,7 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 	// workload in the current consumer policy. If that's the case, query the exchange to get all the device
 	// policies so we can merge them.
 	var exchangeDev *exchange.Device
-	if theDev, err := GetDevice(b.config.Collaborators.HTTPClientFactory.NewHTTPClient(nil), wi.Device.Id, b.config.AgreementBot.ExchangeURL, cph.GetExchangeId(), cph.GetExchangeToken()); err != nil {
+	if theDev, err := GetFederatedDevice(cph, wi.Device.Id); err != nil {
 		glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("error getting device %v policies, error: %v", wi.Device.Id, err)))
 		return
 	} else {
@@ -471,6 +525,9 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 			workload = wi.ConsumerPolicy.NextHighestPriorityWorkload(wlUsage.Priority, wlUsage.RetryCount+1, wlUsage.FirstTryTime)
 		}
 
+		// Hold the node on the next priority version if the highest priority version has not been rolled out to it yet.
+		workload = rolloutController.SelectWorkload(wi.Org, &wi.ConsumerPolicy, wi.Device.Id, workload)
+
 		// If we chose the same workload 2 times in a row through this loop, then we need to exit out of here
 		// Added second comparison in case the workload pointer got changed by the policy merger
 		if (lastWorkload == workload) || (lastWorkload != nil && workload != nil && lastWorkload.IsSame(*workload)) {
@@ -489,6 +546,7 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 		// If the service is suspended, then do not make an agreement.
 		if found, suspended := exchange.ServiceSuspended(exchangeDev.RegisteredServices, workload.WorkloadURL, workload.Org, workload.Version); found && suspended {
 			glog.Infof(BAWlogstring(workerId, fmt.Sprintf("cannot make agreement with %v for policy %v because service %v version %v is suspended by the user.", wi.Device.Id, wi.ConsumerPolicy.Header.Name, cutil.FormOrgSpecUrl(workload.WorkloadURL, workload.Org), workload.Version)))
+			recordNegotiationFailure(wi, workload, NF_STAGE_SUSPENDED, msgPrinter.Sprintf("Service %v version %v is suspended on the node.", cutil.FormOrgSpecUrl(workload.WorkloadURL, workload.Org), workload.Version))
 			// When the service's config state is resumed, the agent will update the node resource and the agbot will be returned this node
 			// in a search result.
 			return
@@ -513,6 +571,7 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 
 							if arch1 != arch2 {
 								glog.Infof(BAWlogstring(workerId, fmt.Sprintf("workload arch %v does not match the device arch %v. Can not make agreement.", workload.Arch, prop.Value)))
+								recordNegotiationFailure(wi, workload, NF_STAGE_ARCH, msgPrinter.Sprintf("Service arch %v does not match the node arch %v.", workload.Arch, prop.Value))
 								return
 							}
 						}
@@ -532,7 +591,7 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 			workload.Arch = exchangeDev.Arch
 		}
 
-		asl, workloadDetails, sIds, err := exchange.GetHTTPServiceResolverHandler(cph)(workload.WorkloadURL, workload.Org, workload.Version, workload.Arch)
+		asl, workloadDetails, sIds, err := exchange.GetHTTPServiceResolverHandler(exchangeFederation.ForOrg(cph, workload.Org))(workload.WorkloadURL, workload.Org, workload.Version, workload.Arch)
 		if err != nil {
 			glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("error searching for service details %v, error: %v", workload, err)))
 			return
@@ -544,6 +603,7 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 		t_comp, t_reason := compcheck.CheckTypeCompatibility(nodeType, &topSvcDef, msgPrinter)
 		if !t_comp {
 			glog.Warningf(BAWlogstring(workerId, fmt.Sprintf("cannot make agreement with node %v for service %v/%v %v. %v", wi.Device.Id, workload.Org, workload.WorkloadURL, workload.Version, t_reason)))
+			recordNegotiationFailure(wi, workload, NF_STAGE_NODE_TYPE, t_reason)
 			return
 		}
 
@@ -553,6 +613,7 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 			t_comp, consumerNamespace, t_reason = compcheck.CheckClusterNamespaceCompatibility(nodeType, exchangeDev.ClusterNamespace, wi.ConsumerPolicy.ClusterNamespace, topSvcDef.GetClusterDeployment(), false, msgPrinter)
 			if !t_comp {
 				glog.Warningf(BAWlogstring(workerId, fmt.Sprintf("cannot make agreement with node %v for service %v/%v %v. %v", wi.Device.Id, workload.Org, workload.WorkloadURL, workload.Version, t_reason)))
+				recordNegotiationFailure(wi, workload, NF_STAGE_CLUSTER_NAMESPACE, t_reason)
 				return
 			} else {
 				wi.ConsumerPolicy.ClusterNamespace = consumerNamespace
@@ -586,7 +647,7 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 		}
 
 		// get dependent service definitions for later use
-		_, depServices, _, _, err := exchange.GetHTTPServiceDefResolverHandler(cph)(workload.WorkloadURL, workload.Org, workload.Version, workload.Arch)
+		_, depServices, _, _, err := exchange.GetHTTPServiceDefResolverHandler(exchangeFederation.ForOrg(cph, workload.Org))(workload.WorkloadURL, workload.Org, workload.Version, workload.Arch)
 		if err != nil {
 			glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("error searching for dependent service details for %v, error: %v", workload, err)))
 			return
@@ -601,8 +662,9 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 			} else if mergedProducer != nil {
 				wi.ProducerPolicy = *mergedProducer
 			}
-			svcDefResolverHandler := exchange.GetHTTPServiceDefResolverHandler(b)
-			patternHandler := exchange.GetHTTPExchangePatternHandler(b)
+			nodeEC := exchangeFederation.ForOrg(b, exchange.GetOrg(wi.Device.Id))
+			svcDefResolverHandler := exchange.GetHTTPServiceDefResolverHandler(nodeEC)
+			patternHandler := exchange.GetHTTPExchangePatternHandler(nodeEC)
 			cc := compcheck.CompCheck{NodeId: wi.Device.Id, PatternId: wi.ConsumerPolicy.PatternId, Service: []common.AbstractServiceFile{&topSvcDef}}
 			resourceCC := compcheck.CompCheckResource{DepServices: depServices, NodeArch: exchangeDev.Arch}
 			ccOutput, err := compcheck.EvaluatePatternPrivilegeCompatability(svcDefResolverHandler, patternHandler, nodePolicyHandler, &cc, &resourceCC, msgPrinter, false, false)
@@ -615,10 +677,12 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 			} else if ccOutput.Compatible {
 				if err := wi.ProducerPolicy.APISpecs.Supports(*asl); err != nil {
 					glog.Warningf(BAWlogstring(workerId, fmt.Sprintf("skipping workload %v because device %v can't support it: %v", workload, wi.Device.Id, err)))
+					recordNegotiationFailure(wi, workload, NF_STAGE_PATTERN, err.Error())
 				} else {
 					policy_match = true
 				}
 			} else {
+				recordNegotiationFailure(wi, workload, NF_STAGE_PATTERN, compCheckReason(ccOutput.Reason))
 				policy_match = false
 			}
 
@@ -629,7 +693,7 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 			servicePolTemp, foundTemp := wi.ServicePolicies[sIdTop]
 			if !foundTemp {
 				var errTemp error
-				serviceIdPolicyHandler := exchange.GetHTTPServicePolicyWithIdHandler(b)
+				serviceIdPolicyHandler := exchange.GetHTTPServicePolicyWithIdHandler(exchangeFederation.ForOrg(b, exchange.GetOrg(sIdTop)))
 				servicePol, errTemp = compcheck.GetServicePolicyWithId(serviceIdPolicyHandler, sIdTop, msgPrinter)
 				if errTemp != nil {
 					glog.Warning(BAWlogstring(workerId, fmt.Sprintf("error getting service policy for service %v. %v", sIdTop, errTemp)))
@@ -649,7 +713,7 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 				return
 			}
 
-			if compatible, consumPol, err := b.checkPolicyCompatibility(workerId, wi, nodePolicy, &wi.ConsumerPolicy, mergedServicePol, "", msgPrinter); err != nil {
+			if compatible, consumPol, err := b.checkPolicyCompatibility(workerId, wi, workload, nodePolicy, &wi.ConsumerPolicy, mergedServicePol, "", msgPrinter); err != nil {
 				return
 			} else {
 				if compatible {
@@ -669,6 +733,7 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 				userInput_match = false
 			} else if !compatible {
 				glog.Warningf(BAWlogstring(workerId, fmt.Sprintf("User input does not meet the requirement for service %v/%v %v %v: %v", workload.Org, workloadDetails.URL, workloadDetails.Version, workloadDetails.Arch, reason)))
+				recordNegotiationFailure(wi, workload, NF_STAGE_USER_INPUT, reason)
 				userInput_match = false
 			}
 		}
@@ -680,6 +745,7 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 			err := b.ValidateAndExtractSecrets(&wi.ConsumerPolicy, wi.Device.Id, &topSvcDef, depServices, workerId, msgPrinter)
 			if err != nil {
 				glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("Error processing secrets for policy %v, error: %v", wi.ConsumerPolicy.Header.Name, err)))
+				recordNegotiationFailure(wi, workload, NF_STAGE_SECRETS, err.Error())
 				secrets_match = false
 			}
 		}
@@ -762,7 +828,8 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 
 	// if the node max heartbeat interval is not set on the node, then get if from the org
 	if nodeMaxHBInterval == 0 {
-		exchOrg, err := exchange.GetOrganization(b.config.Collaborators.HTTPClientFactory, exchange.GetOrg(wi.Device.Id), b.config.AgreementBot.ExchangeURL, cph.GetExchangeId(), cph.GetExchangeToken())
+		ec := exchangeFederation.ForOrg(cph, exchange.GetOrg(wi.Device.Id))
+		exchOrg, err := exchange.GetOrganization(b.config.Collaborators.HTTPClientFactory, exchange.GetOrg(wi.Device.Id), ec.GetExchangeURL(), ec.GetExchangeId(), ec.GetExchangeToken())
 		if err != nil {
 			glog.Errorf(BAWlogstring(workerId, fmt.Errorf("Unable to get org %v from exchange: %v", exchange.GetOrg(wi.Device.Id), err)))
 		}
@@ -801,9 +868,13 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 
 		// TODO: Publish error on the message bus
 
+	} else {
+		metrics.Proposal(cph.Name())
+
 		// Update the agreement in the DB with the proposal and policy
-	} else if err := cph.PersistAgreement(wi, proposal, workerId); err != nil {
-		glog.Errorf(err.Error())
+		if err := cph.PersistAgreement(wi, proposal, workerId); err != nil {
+			glog.Errorf(err.Error())
+		}
 	}
 
 }
@@ -976,6 +1047,8 @@ func (b *BaseAgreementWorker) HandleAgreementReply(cph ConsumerProtocolHandler,
 	ackReplyAsValid := false
 	sendReply := true
 
+	metrics.ProposalReply(cph.Name(), reply.ProposalAccepted())
+
 	if reply.ProposalAccepted() {
 
 		// Find the saved agreement in the database. The returned agreement might be archived. If it's archived, then it is our agreement
@@ -1027,11 +1100,12 @@ func (b *BaseAgreementWorker) HandleAgreementReply(cph ConsumerProtocolHandler,
 				// is the case, then we need to reject the agreement and start over.
 
 				workload := consumerPolicy.NextHighestPriorityWorkload(0, 0, 0)
+				workload = rolloutController.SelectWorkload(agreement.Org, consumerPolicy, wi.SenderId, workload)
 				if !workload.Priority.IsSame(pol.Workloads[0].Priority) {
 					// Need a new workload usage record but not the same as the highest priority. That can't be right.
 					ackReplyAsValid = false
 				} else {
-					if theDev, err := GetDevice(b.config.Collaborators.HTTPClientFactory.NewHTTPClient(nil), wi.SenderId, b.config.AgreementBot.ExchangeURL, cph.GetExchangeId(), cph.GetExchangeToken()); err != nil {
+					if theDev, err := GetFederatedDevice(cph, wi.SenderId); err != nil {
 						glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("error getting device %v policies, error: %v", wi.SenderId, err)))
 					} else if !pol.Workloads[0].HasEmptyPriority() || theDev.HAGroup != "" {
 						// workload usage is used to track the priorities as well as the service upgrades for HA groups
@@ -1072,7 +1146,7 @@ func (b *BaseAgreementWorker) HandleAgreementReply(cph ConsumerProtocolHandler,
 				if b.GetCSSURL() != "" && agreement.Pattern == "" {
 
 					// Retrieve the node policy.
-					nodePolicyHandler := exchange.GetHTTPNodePolicyHandler(b)
+					nodePolicyHandler := exchange.GetHTTPNodePolicyHandler(exchangeFederation.ForOrg(b, exchange.GetOrg(agreement.DeviceId)))
 					msgPrinter := i18n.GetMessagePrinter()
 					_, nodePolicy, err := compcheck.GetNodePolicy(nodePolicyHandler, agreement.DeviceId, msgPrinter)
 					if err != nil {
@@ -1243,6 +1317,11 @@ func (b *BaseAgreementWorker) HandleWorkloadUpgrade(cph ConsumerProtocolHandler,
 	// (if specified) matches the device and policy name. Further, the caller has also validated that the device does
 	// (or did) have a workload running from the specified policy name.
 
+	// The upgrade is held until a maintenance window of the deployment policy opens for the device.
+	if !scheduleController.AllowsUpgrade(wi.Device, wi.PolicyName) {
+		return
+	}
+
 	// If there is no agreement id specified then find one for the current device and policy name. If we find one,
 	// grab the agreement id lock, cancel the agreement and delete the workload usage record.
 
@@ -1316,7 +1395,8 @@ func (b *BaseAgreementWorker) CancelAgreement(cph ConsumerProtocolHandler, agree
 		return false
 	}
 
-	// Update state in exchange
+	// Update state in exchange. The agreements of the agbot are recorded in its own exchange, also for the nodes of a
+	// federated org, see RecordConsumerAgreementState.
 	if err := DeleteConsumerAgreement(b.config.Collaborators.HTTPClientFactory.NewHTTPClient(nil), b.config.AgreementBot.ExchangeURL, cph.GetExchangeId(), cph.GetExchangeToken(), agreementId); err != nil {
 		glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("error deleting agreement %v in exchange: %v", agreementId, err)))
 	}
@@ -1327,7 +1407,7 @@ func (b *BaseAgreementWorker) CancelAgreement(cph ConsumerProtocolHandler, agree
 		glog.Warningf(BAWlogstring(workerId, fmt.Sprintf("warning updating agreement id in workload usage for %v for policy %v, error: %v", ag.DeviceId, ag.PolicyName, err)))
 
 	} else {
-		if wlUsage != nil && (wlUsage.ReqsNotMet || wlUsage.Priority == 0 || cph.IsTerminationReasonNodeShutdown(reason) || reason == basicprotocol.AB_CANCEL_POLICY_CHANGED || reason == basicprotocol.AB_CANCEL_FORCED_UPGRADE) {
+		if wlUsage != nil && (wlUsage.ReqsNotMet || wlUsage.Priority == 0 || cph.IsTerminationReasonNodeShutdown(reason) || reason == basicprotocol.AB_CANCEL_POLICY_CHANGED || reason == basicprotocol.AB_CANCEL_FORCED_UPGRADE || reason == basicprotocol.CANCEL_PREEMPTED) {
 			// If the workload usage record indicates that it is not at the highest priority workload because the device cant meet the
 			// requirements of the higher priority workload, then when an agreement gets cancelled, we will remove the record so that the
 			// agbot always tries the next agreement starting with the highest priority workload again.
@@ -1336,6 +1416,8 @@ func (b *BaseAgreementWorker) CancelAgreement(cph ConsumerProtocolHandler, agree
 			// workload priority in use at the time it was removed from the network.
 			// Or, we will remove the workload usage record when the policy changes or the workload was forced to get upgraded
 			// so that it will try with the highest priority with the new policy.
+			// Or, we will remove the workload usage record when the node preempted the agreement for a higher priority deployment
+			// policy, which is not a failure of the workload.
 			if err := b.db.DeleteWorkloadUsage(ag.DeviceId, ag.PolicyName); err != nil {
 				glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("error deleting workload usage record for device %v and policyName %v, error: %v", ag.DeviceId, ag.PolicyName, err)))
 			}
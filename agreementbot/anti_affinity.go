@@ -0,0 +1,189 @@
+package agreementbot
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/businesspolicy"
+	"github.com/open-horizon/anax/compcheck"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/policy"
+	"sync"
+	"time"
+)
+
+// The seconds a node that passed the anti-affinity check holds its place, until its agreement is in the database.
+const ANTI_AFFINITY_RESERVATION_S = 300
+
+// The seconds the value of the anti-affinity property of a node with an agreement is remembered.
+const ANTI_AFFINITY_VALUE_CACHE_S = 600
+
+// The AntiAffinityTracker counts the instances of the service of a deployment policy on the nodes that share a value of
+// the anti-affinity property of the policy. The agreements in the database are counted, together with the nodes that
+// passed the check and are still negotiating, so that two agreement workers do not place the service on two nodes of
+// the same site at the same time.
+type AntiAffinityTracker struct {
+	lock         sync.Mutex
+	values       map[string]antiAffinityValue                  // the property values of the nodes with agreements, by device id and property
+	reservations map[string]map[string]antiAffinityReservation // the nodes still negotiating, by policy name and device id
+}
+
+type antiAffinityValue struct {
+	value string
+	found bool  // false when the node does not have the property
+	time  int64 // when the value was read
+}
+
+type antiAffinityReservation struct {
+	value string
+	time  int64
+}
+
+func NewAntiAffinityTracker() *AntiAffinityTracker {
+	return &AntiAffinityTracker{
+		values:       make(map[string]antiAffinityValue),
+		reservations: make(map[string]map[string]antiAffinityReservation),
+	}
+}
+
+// Returns true if the service of the deployment policy can be placed on the node without exceeding the instances
+// allowed per value of the anti-affinity property. Otherwise the reason is returned. A node that does not have the
+// property is not limited.
+func antiAffinityAllowsAgreement(db persistence.AgbotDatabase, ec exchange.ExchangeContext, org string, pol *policy.Policy, deviceId string, props externalpolicy.PropertyList) (bool, string) {
+	if pol.PatternId != "" || businessPolManager == nil || antiAffinityTracker == nil {
+		return true, ""
+	}
+
+	_, polName := cutil.SplitOrgSpecUrl(pol.Header.Name)
+	aa := businessPolManager.GetAntiAffinity(org, polName)
+	if aa == nil {
+		return true, ""
+	}
+
+	value, found, err := getAntiAffinityValue(ec, aa.Property, deviceId, props)
+	if err != nil {
+		glog.Errorf(AWlogString(fmt.Sprintf("unable to get the %v of node %v, error %v", aa.Property, deviceId, err)))
+		return false, fmt.Sprintf("Unable to get the %v of node %v for the antiAffinity of the deployment policy.", aa.Property, deviceId)
+	} else if !found {
+		return true, ""
+	}
+
+	// the values of the nodes that have an agreement for the policy
+	agValues := make(map[string]string)
+	for _, agp := range policy.AllAgreementProtocols() {
+		ags, err := db.FindAgreements([]persistence.AFilter{persistence.UnarchivedAFilter(), persistence.PolicyAFilter(pol.Header.Name)}, agp)
+		if err != nil {
+			glog.Errorf(AWlogString(fmt.Sprintf("unable to find the agreements of policy %v, error %v", pol.Header.Name, err)))
+			return false, fmt.Sprintf("Unable to find the agreements of policy %v.", pol.Header.Name)
+		}
+		for _, ag := range ags {
+			if _, ok := agValues[ag.DeviceId]; ok || ag.DeviceId == deviceId {
+				continue
+			}
+			agValue, agFound, err := antiAffinityTracker.nodeValue(ag.DeviceId, aa.Property, func() (string, bool, error) {
+				return getAntiAffinityValue(ec, aa.Property, ag.DeviceId, nil)
+			})
+			if err != nil {
+				glog.Warningf(AWlogString(fmt.Sprintf("unable to get the %v of node %v, its agreement %v is not counted for the antiAffinity of policy %v, error %v", aa.Property, ag.DeviceId, ag.CurrentAgreementId, pol.Header.Name, err)))
+			} else if agFound {
+				agValues[ag.DeviceId] = agValue
+			}
+		}
+	}
+
+	if count, ok := antiAffinityTracker.admit(pol.Header.Name, deviceId, value, aa.GetMaxPerValue(), agValues, time.Now().Unix()); !ok {
+		return false, fmt.Sprintf("Policy %v already has %v instances on the nodes with %v %v, its antiAffinity allows %v.", pol.Header.Name, count, aa.Property, value, aa.GetMaxPerValue())
+	}
+	return true, ""
+}
+
+// Get the value of the anti-affinity property of a node. The node policy is read from the exchange when the properties
+// are not given, the HA group from the node.
+func getAntiAffinityValue(ec exchange.ExchangeContext, property string, deviceId string, props externalpolicy.PropertyList) (string, bool, error) {
+	nodeEC := exchangeFederation.ForOrg(ec, exchange.GetOrg(deviceId))
+
+	if property == businesspolicy.ANTI_AFFINITY_HA_GROUP {
+		if dev, err := exchange.GetExchangeDevice(ec.GetHTTPFactory(), deviceId, nodeEC.GetExchangeId(), nodeEC.GetExchangeToken(), nodeEC.GetExchangeURL()); err != nil {
+			return "", false, err
+		} else if dev == nil || dev.HAGroup == "" {
+			return "", false, nil
+		} else {
+			return dev.HAGroup, true, nil
+		}
+	}
+
+	if props == nil {
+		_, nodePol, err := compcheck.GetNodePolicy(exchange.GetHTTPNodePolicyHandler(nodeEC), deviceId, nil)
+		if err != nil {
+			return "", false, err
+		} else if nodePol == nil {
+			return "", false, nil
+		}
+		props = nodePol.Properties
+	}
+
+	if prop, err := props.GetProperty(property); err != nil {
+		return "", false, nil
+	} else {
+		return fmt.Sprintf("%v", prop.Value), true, nil
+	}
+}
+
+// Return the value of the property of a node with an agreement, from the cache or from the lookup function.
+func (t *AntiAffinityTracker) nodeValue(deviceId string, property string, lookup func() (string, bool, error)) (string, bool, error) {
+	key := deviceId + "|" + property
+	now := time.Now().Unix()
+
+	t.lock.Lock()
+	cached, ok := t.values[key]
+	t.lock.Unlock()
+	if ok && now-cached.time < ANTI_AFFINITY_VALUE_CACHE_S {
+		return cached.value, cached.found, nil
+	}
+
+	value, found, err := lookup()
+	if err != nil {
+		return "", false, err
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.values[key] = antiAffinityValue{value: value, found: found, time: now}
+	return value, found, nil
+}
+
+// Count the instances of the service of the policy on the nodes with the value, from the nodes with agreements and the
+// nodes still negotiating. When there is room for another instance the node is reserved, until its agreement is in the
+// database or the reservation expires. Returns the count and true if the node was admitted.
+func (t *AntiAffinityTracker) admit(polName string, deviceId string, value string, maxPerValue int, agValues map[string]string, now int64) (int, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	reserved, ok := t.reservations[polName]
+	if !ok {
+		reserved = make(map[string]antiAffinityReservation)
+		t.reservations[polName] = reserved
+	}
+
+	count := 0
+	for dev, agValue := range agValues {
+		if dev != deviceId && agValue == value {
+			count++
+		}
+	}
+	for dev, r := range reserved {
+		if _, hasAgreement := agValues[dev]; hasAgreement || now-r.time >= ANTI_AFFINITY_RESERVATION_S {
+			delete(reserved, dev)
+		} else if dev != deviceId && r.value == value {
+			count++
+		}
+	}
+
+	if count >= maxPerValue {
+		return count, false
+	}
+	reserved[deviceId] = antiAffinityReservation{value: value, time: now}
+	return count, true
+}
@@ -0,0 +1,68 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_AntiAffinityTracker_admit(t *testing.T) {
+	tracker := NewAntiAffinityTracker()
+	agValues := map[string]string{"myorg/node1": "berlin", "myorg/node2": "paris"}
+
+	if count, ok := tracker.admit("myorg/pol", "myorg/node3", "berlin", 1, agValues, 1000); ok || count != 1 {
+		t.Errorf("berlin already has an instance, got %v %v", count, ok)
+	} else if _, ok := tracker.admit("myorg/pol", "myorg/node3", "berlin", 2, agValues, 1000); !ok {
+		t.Errorf("berlin should allow 2 instances")
+	}
+
+	// node3 is still negotiating, it is counted for berlin
+	if count, ok := tracker.admit("myorg/pol", "myorg/node4", "berlin", 2, agValues, 1010); ok || count != 2 {
+		t.Errorf("berlin has an agreement and a node negotiating, got %v %v", count, ok)
+	}
+
+	// a node is not counted against itself
+	if _, ok := tracker.admit("myorg/pol", "myorg/node3", "berlin", 2, agValues, 1020); !ok {
+		t.Errorf("the reservation of node3 should not count against node3")
+	}
+
+	// the other policies and sites are not affected
+	if _, ok := tracker.admit("myorg/pol", "myorg/node5", "rome", 1, agValues, 1020); !ok {
+		t.Errorf("rome does not have an instance")
+	} else if _, ok := tracker.admit("myorg/other", "myorg/node4", "berlin", 1, map[string]string{}, 1020); !ok {
+		t.Errorf("the policy myorg/other does not have an instance in berlin")
+	}
+
+	// the reservation ends when it expires
+	if _, ok := tracker.admit("myorg/pol", "myorg/node4", "berlin", 2, agValues, 1020+ANTI_AFFINITY_RESERVATION_S); !ok {
+		t.Errorf("the reservation of node3 should have expired")
+	}
+}
+
+func Test_AntiAffinityTracker_nodeValue(t *testing.T) {
+	tracker := NewAntiAffinityTracker()
+
+	lookups := 0
+	lookup := func() (string, bool, error) {
+		lookups++
+		return "berlin", true, nil
+	}
+	for i := 0; i < 2; i++ {
+		if value, found, err := tracker.nodeValue("myorg/node1", "site", lookup); err != nil || !found || value != "berlin" {
+			t.Errorf("node1 should be in berlin, got %v %v %v", value, found, err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("the value should have been cached, but it was looked up %v times", lookups)
+	}
+
+	// errors are not cached
+	failed := func() (string, bool, error) { return "", false, errors.New("exchange unavailable") }
+	if _, _, err := tracker.nodeValue("myorg/node2", "site", failed); err == nil {
+		t.Errorf("the lookup error should be returned")
+	} else if value, _, err := tracker.nodeValue("myorg/node2", "site", lookup); err != nil || value != "berlin" {
+		t.Errorf("node2 should be in berlin, got %v %v", value, err)
+	}
+}
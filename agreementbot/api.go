@@ -12,11 +12,14 @@ import (
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/metrics"
 	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/anax/worker"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"sort"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -79,6 +82,13 @@ func (a *API) NewEvent(ev events.Message) {
 			apicommon.HandleStoppingBC(msg, a.bcState, &a.bcStateLock)
 			glog.V(3).Infof(APIlogString(fmt.Sprintf("API Worker processed BC stopping for %v", msg)))
 		}
+	case *events.NodeShutdownMessage:
+		msg, _ := ev.(*events.NodeShutdownMessage)
+		switch msg.Event().Id {
+		case events.START_AGBOT_QUIESCE:
+			// The agbot is no longer ready to make agreements once it starts to quiesce.
+			a.em.RecordEvent(msg, nil)
+		}
 	case *events.NodeShutdownCompleteMessage:
 		msg, _ := ev.(*events.NodeShutdownCompleteMessage)
 		// Now remove myself from the worker dispatch list. When the anax process terminates,
@@ -342,8 +352,15 @@ func (a *API) listen(apiListen string) {
 		router.HandleFunc("/policy/{org}/{name}", a.policy).Methods("GET", "OPTIONS")
 		router.HandleFunc("/policy/{name}/upgrade", a.policy).Methods("POST", "OPTIONS")
 		router.HandleFunc("/workloadusage", a.workloadusage).Methods("GET", "OPTIONS")
+		router.HandleFunc("/ratelimit", a.ratelimit).Methods("GET", "PUT", "OPTIONS")
+		router.HandleFunc("/federation", a.federation).Methods("GET", "OPTIONS")
+		router.HandleFunc("/agreementhistory", a.agreementhistory).Methods("GET", "OPTIONS")
+		router.HandleFunc("/agreementhistory/{month}", a.agreementhistory).Methods("GET", "DELETE", "OPTIONS")
 		router.HandleFunc("/status", a.status).Methods("GET", "OPTIONS")
 		router.HandleFunc("/health", a.health).Methods("GET", "OPTIONS")
+		router.HandleFunc("/healthz", a.healthz).Methods("GET", "OPTIONS")
+		router.HandleFunc("/readyz", a.readyz).Methods("GET", "OPTIONS")
+		router.HandleFunc("/metrics", a.agbotmetrics).Methods("GET", "OPTIONS")
 		router.HandleFunc("/status/workers", a.workerstatus).Methods("GET", "OPTIONS")
 		router.HandleFunc("/node", a.node).Methods("GET", "DELETE", "OPTIONS")
 		router.HandleFunc("/config", a.config).Methods("GET", "OPTIONS")
@@ -392,13 +409,20 @@ func (a *API) agreement(w http.ResponseWriter, r *http.Request) {
 			var archivedKey = "archived"
 			var activeKey = "active"
 
+			// The agreements can be searched by node, policy, state and the time of their last change of state.
+			filters, inputErr := agreementSearchFilters(r.URL.Query())
+			if inputErr != nil {
+				writeInputErr(w, http.StatusBadRequest, inputErr)
+				return
+			}
+
 			wrap := make(map[string]map[string][]persistence.Agreement, 0)
 			wrap[agreementsKey] = make(map[string][]persistence.Agreement, 0)
 			wrap[agreementsKey][archivedKey] = []persistence.Agreement{}
 			wrap[agreementsKey][activeKey] = []persistence.Agreement{}
 
 			for _, agp := range policy.AllAgreementProtocols() {
-				if ags, err := a.db.FindAgreements([]persistence.AFilter{}, agp); err != nil {
+				if ags, err := a.db.FindAgreements(filters, agp); err != nil {
 					glog.Error(APIlogString(fmt.Sprintf("error finding all agreements, error: %v", err)))
 					http.Error(w, "Internal server error", http.StatusInternalServerError)
 					return
@@ -411,7 +435,7 @@ func (a *API) agreement(w http.ResponseWriter, r *http.Request) {
 							glog.Error(APIlogString(fmt.Sprintf("failed to obscure secret details, error: %v", err)))
 						}
 						// The archived agreements and the agreements being terminated are returned as archived.
-						if agreement.Archived || agreement.AgreementTimedout != 0 {
+						if agreement.IsTerminated() {
 							wrap[agreementsKey][archivedKey] = append(wrap[agreementsKey][archivedKey], agreement)
 						} else {
 							wrap[agreementsKey][activeKey] = append(wrap[agreementsKey][activeKey], agreement)
@@ -465,6 +489,38 @@ func (a *API) agreement(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Returns the agreement filters for the node, policy, state and since query parameters of GET /agreement. The since
+// parameter is a time in seconds since the epoch.
+func agreementSearchFilters(query url.Values) ([]persistence.AFilter, *APIUserInputError) {
+	filters := []persistence.AFilter{}
+	if node := query.Get("node"); node != "" {
+		filters = append(filters, persistence.NodeAFilter(node))
+	}
+	if policyName := query.Get("policy"); policyName != "" {
+		filters = append(filters, persistence.PolicyAFilter(policyName))
+	}
+	if state := query.Get("state"); state != "" {
+		valid := false
+		for _, s := range persistence.AgreementStates() {
+			if state == s {
+				valid = true
+			}
+		}
+		if !valid {
+			return nil, &APIUserInputError{Input: "state", Error: fmt.Sprintf("state must be one of %v", persistence.AgreementStates())}
+		}
+		filters = append(filters, persistence.StateAFilter(state))
+	}
+	if since := query.Get("since"); since != "" {
+		sinceTime, err := strconv.ParseUint(since, 10, 64)
+		if err != nil {
+			return nil, &APIUserInputError{Input: "since", Error: "since must be a time in seconds since the epoch"}
+		}
+		filters = append(filters, persistence.SinceAFilter(sinceTime))
+	}
+	return filters, nil
+}
+
 func (a *API) policy(w http.ResponseWriter, r *http.Request) {
 
 	serviceResolver := func(wURL string, wOrg string, wVersion string, wArch string) (*policy.APISpecList, error) {
@@ -685,6 +741,112 @@ func (a *API) health(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// The output of the /healthz and /readyz probes. The checks are the reasons the probe failed.
+type ProbeOutput struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func writeProbe(w http.ResponseWriter, checks map[string]string) {
+	if len(checks) == 0 {
+		writeResponse(w, ProbeOutput{Status: "ok"}, http.StatusOK)
+	} else {
+		writeResponse(w, ProbeOutput{Status: "failed", Checks: checks}, http.StatusServiceUnavailable)
+	}
+}
+
+// The liveness probe. It fails when the agbot can not recover without a restart, i.e. the agbot worker failed to
+// initialize or has terminated, or the database heartbeat of the agbot is so old that its partition is considered stale
+// by the other agbots. A database that can not be reached is not a liveness failure, restarting the agbot would not fix it.
+func (a *API) healthz(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		checks := make(map[string]string)
+
+		if status := worker.GetWorkerStatusManager().GetWorkerStatus(AGBOT_WORKER); status == worker.STATUS_INIT_FAILED || status == worker.STATUS_TERMINATED {
+			checks["worker"] = fmt.Sprintf("the agbot worker is %v", status)
+		}
+
+		// The heartbeat is 0 when the database is not shared by several agbots.
+		now := uint64(time.Now().Unix())
+		if hb, err := a.db.GetHeartbeat(); err == nil && hb != 0 && now > hb+a.Config.GetPartitionStale() {
+			checks["database"] = fmt.Sprintf("the database heartbeat is %v seconds old", now-hb)
+		}
+
+		writeProbe(w, checks)
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// The readiness probe. It fails until the agbot worker has read the policies it serves, while the database can not be
+// reached and once the agbot starts to quiesce.
+func (a *API) readyz(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		checks := make(map[string]string)
+
+		if status := worker.GetWorkerStatusManager().GetWorkerStatus(AGBOT_WORKER); status != worker.STATUS_INITIALIZED {
+			checks["worker"] = fmt.Sprintf("the agbot worker is %v", status)
+		}
+
+		if _, err := a.db.GetHeartbeat(); err != nil {
+			checks["database"] = err.Error()
+		}
+
+		if a.em.ReceivedEvent(events.NewNodeShutdownMessage(events.START_AGBOT_QUIESCE, false, false, ""), nil) {
+			checks["quiesce"] = "the agbot is quiescing"
+		}
+
+		writeProbe(w, checks)
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) agbotmetrics(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		// the agreement counts are read from the database at each scrape
+		active := make(map[string]int64)
+		archived := make(map[string]int64)
+		if partitions, err := a.db.FindPartitions(); err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error finding all partitions, error: %v", err)))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		} else {
+			for _, p := range partitions {
+				if act, arch, err := a.db.GetAgreementCount(p); err != nil {
+					glog.Error(APIlogString(fmt.Sprintf("error finding agreement count in partition %v, error: %v", p, err)))
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+					return
+				} else {
+					active[p] = act
+					archived[p] = arch
+				}
+			}
+		}
+		metrics.SetAgbotAgreements(active, archived)
+
+		w.Header().Set("Content-Type", metrics.CONTENT_TYPE)
+		w.WriteHeader(http.StatusOK)
+		if err := metrics.GetRegistry().Write(w); err != nil {
+			glog.Errorf(APIlogString(fmt.Sprintf("Unable to write the metrics, error %v", err)))
+		}
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func (a *API) workerstatus(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
@@ -698,6 +860,113 @@ func (a *API) workerstatus(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Read or replace the agreement rate limits. The new limits take effect immediately and are kept until the agbot restarts.
+func (a *API) ratelimit(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case "GET":
+		if rateLimiter == nil {
+			http.Error(w, "Agbot is not ready", http.StatusServiceUnavailable)
+			return
+		}
+		writeResponse(w, rateLimiter.Limits(), http.StatusOK)
+
+	case "PUT":
+		if rateLimiter == nil {
+			http.Error(w, "Agbot is not ready", http.StatusServiceUnavailable)
+			return
+		}
+
+		var limits RateLimits
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &limits); err != nil {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "body", Error: fmt.Sprintf("user submitted data couldn't be deserialized to struct: %v. Error: %v", string(body), err)})
+		} else if err := rateLimiter.SetLimits(limits); err != nil {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "body", Error: err.Error()})
+		} else {
+			glog.V(3).Infof(APIlogString(fmt.Sprintf("agreement rate limits changed to %v", limits)))
+			writeResponse(w, rateLimiter.Limits(), http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, PUT, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Returns the federated exchanges and the orgs they host. The orgs that are not listed are in the agbot's own exchange.
+func (a *API) federation(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case "GET":
+		writeResponse(w, exchangeFederation.Output(), http.StatusOK)
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// The output of the GET /agreementhistory API.
+type AgreementHistoryOutput struct {
+	Months    []string                              `json:"months"`    // The months in the history, in the form YYYYMM.
+	Summaries []persistence.AgreementHistorySummary `json:"summaries"` // The summaries of the months that were dropped.
+}
+
+// List the months in the agreement history, export the archived agreements of a month, or drop a month keeping a summary
+// of its agreements. A month is exported before it is dropped to move it to other storage.
+func (a *API) agreementhistory(w http.ResponseWriter, r *http.Request) {
+
+	month := mux.Vars(r)["month"]
+	if month != "" {
+		if err := persistence.ValidateHistoryMonth(month); err != nil {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "month", Error: err.Error()})
+			return
+		}
+	}
+
+	switch r.Method {
+	case "GET":
+		if month == "" {
+			if months, err := a.db.FindAgreementHistoryMonths(); err != nil {
+				glog.Error(APIlogString(fmt.Sprintf("error finding agreement history months, error: %v", err)))
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			} else if summaries, err := a.db.FindAgreementHistorySummaries(); err != nil {
+				glog.Error(APIlogString(fmt.Sprintf("error finding agreement history summaries, error: %v", err)))
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			} else {
+				writeResponse(w, AgreementHistoryOutput{Months: months, Summaries: summaries}, http.StatusOK)
+			}
+		} else if agreements, err := a.db.FindAgreementHistory(month); err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error finding the agreement history of %v, error: %v", month, err)))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		} else {
+			writeResponse(w, agreements, http.StatusOK)
+		}
+
+	case "DELETE":
+		if summary, err := a.db.DeleteAgreementHistory(month); err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error dropping the agreement history of %v, error: %v", month, err)))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		} else if summary == nil {
+			writeInputErr(w, http.StatusNotFound, &APIUserInputError{Input: "month", Error: "month not found in the agreement history"})
+		} else {
+			glog.V(3).Infof(APIlogString(fmt.Sprintf("agreement history of %v dropped", month)))
+			writeResponse(w, summary, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, DELETE, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func (a *API) node(w http.ResponseWriter, r *http.Request) {
 
 	resource := "node"
@@ -739,7 +1008,7 @@ func (a *API) node(w http.ResponseWriter, r *http.Request) {
 		// b) make sure all this agbot's agreements are in a steady state, meaning archived or finalized
 
 		// Fire the NodeShutdown event to get the agbot to quiesce itself.
-		ns := events.NewNodeShutdownMessage(events.START_AGBOT_QUIESCE, blocking, false)
+		ns := events.NewNodeShutdownMessage(events.START_AGBOT_QUIESCE, blocking, false, "")
 		a.Messages() <- ns
 
 		// Wait (if allowed) for the ShutdownComplete event
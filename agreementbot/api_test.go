@@ -0,0 +1,81 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/basicprotocol"
+	"net/url"
+	"testing"
+)
+
+func Test_agreementSearchFilters(t *testing.T) {
+
+	agreements := []persistence.Agreement{
+		{CurrentAgreementId: "pending", DeviceId: "org/node1", PolicyName: "org/pol1", AgreementInceptionTime: 100},
+		{CurrentAgreementId: "agreed", DeviceId: "org/node1", PolicyName: "org/pol2", AgreementInceptionTime: 100, AgreementCreationTime: 110},
+		{CurrentAgreementId: "finalized", DeviceId: "org/node2", Pattern: "org/pat1", AgreementInceptionTime: 100, AgreementCreationTime: 110, AgreementFinalizedTime: 200},
+		{CurrentAgreementId: "failed", DeviceId: "org/node2", PolicyName: "org/pol1", AgreementInceptionTime: 100, AgreementTimedout: 300, TerminatedReason: basicprotocol.AB_CANCEL_NO_REPLY},
+		{CurrentAgreementId: "cancelled", DeviceId: "org/node3", PolicyName: "org/pol1", AgreementInceptionTime: 100, AgreementTimedout: 150, Archived: true, TerminatedReason: basicprotocol.AB_USER_REQUESTED},
+	}
+
+	search := func(query string) []string {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			t.Fatalf("invalid query %v: %v", query, err)
+		}
+		filters, inputErr := agreementSearchFilters(values)
+		if inputErr != nil {
+			t.Fatalf("unexpected error for query %v: %v", query, inputErr.Error)
+		}
+		ids := []string{}
+		for i := range agreements {
+			if persistence.RunFilters(&agreements[i], filters) != nil {
+				ids = append(ids, agreements[i].CurrentAgreementId)
+			}
+		}
+		return ids
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{"pending", "agreed", "finalized", "failed", "cancelled"}},
+		{"node=node1", []string{"pending", "agreed"}},
+		{"node=org/node2", []string{"finalized", "failed"}},
+		{"policy=pol1", []string{"pending", "failed", "cancelled"}},
+		{"policy=org/pat1", []string{"finalized"}},
+		{"state=active", []string{"pending", "agreed", "finalized"}},
+		{"state=pending", []string{"pending"}},
+		{"state=agreed", []string{"agreed"}},
+		{"state=finalized", []string{"finalized"}},
+		{"state=terminated", []string{"failed", "cancelled"}},
+		{"state=failed", []string{"failed"}},
+		{"since=200", []string{"finalized", "failed"}},
+		{"state=failed&since=301", []string{}},
+		{"node=node2&state=failed&policy=pol1", []string{"failed"}},
+	}
+
+	for _, test := range tests {
+		ids := search(test.query)
+		if len(ids) != len(test.expected) {
+			t.Errorf("query %v returned %v, expected %v", test.query, ids, test.expected)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != test.expected[i] {
+				t.Errorf("query %v returned %v, expected %v", test.query, ids, test.expected)
+				break
+			}
+		}
+	}
+
+	for _, query := range []string{"state=broken", "since=1h", "since=-5"} {
+		values, _ := url.ParseQuery(query)
+		if _, inputErr := agreementSearchFilters(values); inputErr == nil {
+			t.Errorf("query %v should have been rejected", query)
+		}
+	}
+}
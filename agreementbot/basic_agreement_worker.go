@@ -8,6 +8,7 @@ import (
 	"github.com/open-horizon/anax/basicprotocol"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/metrics"
 	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/anax/worker"
 	"github.com/satori/go.uuid"
@@ -244,6 +245,8 @@ func (a *BasicAgreementWorker) start(work *PrioritizedWorkQueue, random *rand.Ra
 				lock := a.alm.getAgreementLock(wi.Reply.AgreementId())
 				lock.Lock()
 
+				metrics.AgreementFinalized(a.protocolHandler.Name())
+
 				// Update state in the database
 				if ag, err := a.db.AgreementFinalized(wi.Reply.AgreementId(), a.protocolHandler.Name()); err != nil {
 					glog.Errorf(bwlogstring(a.workerID, fmt.Sprintf("error persisting agreement %v finalized: %v", wi.Reply.AgreementId(), err)))
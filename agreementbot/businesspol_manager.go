@@ -75,11 +75,23 @@ func NewServicePolicyEntry(p *externalpolicy.ExternalPolicy, svcId string) (*Ser
 }
 
 type BusinessPolicyEntry struct {
-	Policy          *policy.Policy                 `json:"policy,omitempty"`          // the metadata for this business policy from the exchange, it is the converted to the internal policy format
-	Updated         uint64                         `json:"updatedTime,omitempty"`     // the time in seconds when this entry was updated
-	UpdatedMSec     uint64                         `json:"updatedTimeMSec,omitempty"` // the time in milliseconds when this entry was updated
-	Hash            []byte                         `json:"hash,omitempty"`            // a hash of the business policy to compare for matadata changes in the exchange
-	ServicePolicies map[string]*ServicePolicyEntry `json:"servicePolicies,omitempty"` // map of the service id and service policies
+	Policy          *policy.Policy                     `json:"policy,omitempty"`          // the metadata for this business policy from the exchange, it is the converted to the internal policy format
+	Updated         uint64                             `json:"updatedTime,omitempty"`     // the time in seconds when this entry was updated
+	UpdatedMSec     uint64                             `json:"updatedTimeMSec,omitempty"` // the time in milliseconds when this entry was updated
+	Hash            []byte                             `json:"hash,omitempty"`            // a hash of the business policy to compare for matadata changes in the exchange
+	ServicePolicies map[string]*ServicePolicyEntry     `json:"servicePolicies,omitempty"` // map of the service id and service policies
+	Rollout         *businesspolicy.RolloutPolicy      `json:"rollout,omitempty"`         // the rollout policy of the highest priority service version
+	Schedule        *businesspolicy.DeploymentSchedule `json:"schedule,omitempty"`        // the maintenance windows in which the service can be deployed or changed
+	Fleets          []string                           `json:"fleets,omitempty"`          // the org qualified names of the fleets the service is deployed to
+
+	// the constraints on the dynamic state of the node, evaluated when the service is placed on a node
+	Placement externalpolicy.ConstraintExpression `json:"placement,omitempty"`
+
+	// limits the instances of the service on the nodes that share a value of a node property
+	AntiAffinity *businesspolicy.AntiAffinity `json:"antiAffinity,omitempty"`
+
+	// ranks the nodes that match the policy
+	Scoring []policy.ScoringRule `json:"scoring,omitempty"`
 }
 
 // return a pointer to a copy of BusinessPolicyEntry
@@ -112,7 +124,45 @@ func (p *BusinessPolicyEntry) DeepCopy() *BusinessPolicyEntry {
 		}
 	}
 
-	copyBusinessPolicyEntry := BusinessPolicyEntry{Policy: newPolicy, Updated: newUpdated, UpdatedMSec: newUpdatedMSec, Hash: newHash, ServicePolicies: newServePolicy}
+	var newRollout *businesspolicy.RolloutPolicy
+	if p.Rollout != nil {
+		r := *p.Rollout
+		newRollout = &r
+	}
+
+	var newSchedule *businesspolicy.DeploymentSchedule
+	if p.Schedule != nil {
+		s := *p.Schedule
+		s.Windows = make([]businesspolicy.ScheduleWindow, len(p.Schedule.Windows))
+		copy(s.Windows, p.Schedule.Windows)
+		newSchedule = &s
+	}
+
+	var newFleets []string
+	if p.Fleets != nil {
+		newFleets = make([]string, len(p.Fleets))
+		copy(newFleets, p.Fleets)
+	}
+
+	var newPlacement externalpolicy.ConstraintExpression
+	if p.Placement != nil {
+		newPlacement = make(externalpolicy.ConstraintExpression, len(p.Placement))
+		copy(newPlacement, p.Placement)
+	}
+
+	var newAntiAffinity *businesspolicy.AntiAffinity
+	if p.AntiAffinity != nil {
+		aa := *p.AntiAffinity
+		newAntiAffinity = &aa
+	}
+
+	var newScoring []policy.ScoringRule
+	if p.Scoring != nil {
+		newScoring = make([]policy.ScoringRule, len(p.Scoring))
+		copy(newScoring, p.Scoring)
+	}
+
+	copyBusinessPolicyEntry := BusinessPolicyEntry{Policy: newPolicy, Updated: newUpdated, UpdatedMSec: newUpdatedMSec, Hash: newHash, ServicePolicies: newServePolicy, Rollout: newRollout, Schedule: newSchedule, Fleets: newFleets, Placement: newPlacement, AntiAffinity: newAntiAffinity, Scoring: newScoring}
 	return &copyBusinessPolicyEntry
 
 }
@@ -138,6 +188,12 @@ func NewBusinessPolicyEntry(pol *businesspolicy.BusinessPolicy, polId string) (*
 	} else {
 		pBE.Policy = pPolicy
 	}
+	pBE.Rollout = pol.Service.Rollout
+	pBE.Schedule = pol.Schedule
+	pBE.Fleets = qualifyFleetNames(polId, pol.Fleets)
+	pBE.Placement = pol.Placement
+	pBE.AntiAffinity = pol.AntiAffinity
+	pBE.Scoring = pol.Scoring
 
 	return pBE, nil
 }
@@ -252,6 +308,12 @@ func (p *BusinessPolicyEntry) UpdateEntry(pol *businesspolicy.BusinessPolicy, po
 		return nil, fmt.Errorf("Failed to convert the business policy to internal policy format: %v. %v", *pol, err)
 	} else {
 		p.Policy = pPolicy
+		p.Rollout = pol.Service.Rollout
+		p.Schedule = pol.Schedule
+		p.Fleets = qualifyFleetNames(polId, pol.Fleets)
+		p.Placement = pol.Placement
+		p.AntiAffinity = pol.AntiAffinity
+		p.Scoring = pol.Scoring
 		return pPolicy, nil
 	}
 }
@@ -399,6 +461,93 @@ func (pm *BusinessPolicyManager) GetBusinessPolicyEntry(org string, pol *policy.
 	return nil
 }
 
+// Return the rollout policy of a business policy and the time the rollout started, which is when the policy changed.
+// Returns nil if the business policy does not roll out its service versions.
+func (pm *BusinessPolicyManager) GetRolloutPolicy(org string, polName string) (*businesspolicy.RolloutPolicy, uint64) {
+	pm.polMapLock.Lock()
+	defer pm.polMapLock.Unlock()
+
+	if orgMap, ok := pm.OrgPolicies[org]; ok {
+		if pBE, found := orgMap[polName]; found && pBE.Rollout != nil {
+			r := *pBE.Rollout
+			return &r, pBE.Updated
+		}
+	}
+	return nil, 0
+}
+
+func (pm *BusinessPolicyManager) GetSchedule(org string, polName string) *businesspolicy.DeploymentSchedule {
+	pm.polMapLock.Lock()
+	defer pm.polMapLock.Unlock()
+
+	if orgMap, ok := pm.OrgPolicies[org]; ok {
+		if pBE, found := orgMap[polName]; found && pBE.Schedule != nil {
+			s := *pBE.Schedule
+			return &s
+		}
+	}
+	return nil
+}
+
+// Return the org qualified names of the fleets a policy is deployed to, or nil when the policy does not target fleets.
+func (pm *BusinessPolicyManager) GetFleets(org string, polName string) []string {
+	pm.polMapLock.Lock()
+	defer pm.polMapLock.Unlock()
+
+	if orgMap, ok := pm.OrgPolicies[org]; ok {
+		if pBE, found := orgMap[polName]; found && len(pBE.Fleets) != 0 {
+			fleets := make([]string, len(pBE.Fleets))
+			copy(fleets, pBE.Fleets)
+			return fleets
+		}
+	}
+	return nil
+}
+
+// Return the placement constraints of a policy, or nil when the policy has none.
+func (pm *BusinessPolicyManager) GetPlacement(org string, polName string) externalpolicy.ConstraintExpression {
+	pm.polMapLock.Lock()
+	defer pm.polMapLock.Unlock()
+
+	if orgMap, ok := pm.OrgPolicies[org]; ok {
+		if pBE, found := orgMap[polName]; found && len(pBE.Placement) != 0 {
+			placement := make(externalpolicy.ConstraintExpression, len(pBE.Placement))
+			copy(placement, pBE.Placement)
+			return placement
+		}
+	}
+	return nil
+}
+
+// Return the anti-affinity of a policy, or nil when the policy has none.
+func (pm *BusinessPolicyManager) GetAntiAffinity(org string, polName string) *businesspolicy.AntiAffinity {
+	pm.polMapLock.Lock()
+	defer pm.polMapLock.Unlock()
+
+	if orgMap, ok := pm.OrgPolicies[org]; ok {
+		if pBE, found := orgMap[polName]; found && pBE.AntiAffinity != nil {
+			aa := *pBE.AntiAffinity
+			return &aa
+		}
+	}
+	return nil
+}
+
+// Return the scoring rules of a policy, or nil when the policy has none.
+func (pm *BusinessPolicyManager) GetScoring(org string, polName string) []policy.ScoringRule {
+	pm.polMapLock.Lock()
+	defer pm.polMapLock.Unlock()
+
+	if orgMap, ok := pm.OrgPolicies[org]; ok {
+		if pBE, found := orgMap[polName]; found && len(pBE.Scoring) != 0 {
+			scoring := make([]policy.ScoringRule, len(pBE.Scoring))
+			copy(scoring, pBE.Scoring)
+			return scoring
+		}
+	}
+	return nil
+}
+
 func (pm *BusinessPolicyManager) GetAllPolicyOrgs() []string {
 	pm.spMapLock.Lock()
 	defer pm.spMapLock.Unlock()
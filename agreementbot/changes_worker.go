@@ -133,6 +133,9 @@ func (w *ChangesWorker) findAndProcessChanges() {
 	// the agbot worker.
 	batchedEvents := make(map[events.EventId]bool)
 
+	// The nodes changed by the batched node changes, so that the agbot can negotiate with just the changed nodes.
+	changedNodes := make(map[events.EventId][]string)
+
 	agbotMessages := 0
 
 	// Loop through each change to identify resources that we are interested in, and then send out event messages
@@ -174,17 +177,21 @@ func (w *ChangesWorker) findAndProcessChanges() {
 
 		} else if change.IsNode("") {
 			batchedEvents[events.CHANGE_NODE_TYPE] = true
+			addChangedNode(changedNodes, events.CHANGE_NODE_TYPE, change)
 
 		} else if change.IsNodePolicy("") {
 			batchedEvents[events.CHANGE_NODE_POLICY_TYPE] = true
+			addChangedNode(changedNodes, events.CHANGE_NODE_POLICY_TYPE, change)
 			ev := events.NewNodePolicyChangedMessage(events.NODE_POLICY_CHANGED, change.OrgID, change.ID)
 			w.Messages() <- ev
 
 		} else if change.IsNodeAgreement("") {
 			batchedEvents[events.CHANGE_NODE_AGREEMENT_TYPE] = true
+			addChangedNode(changedNodes, events.CHANGE_NODE_AGREEMENT_TYPE, change)
 
 		} else if change.IsNodeServiceConfigState("") {
 			batchedEvents[events.CHANGE_NODE_CONFIGSTATE_TYPE] = true
+			addChangedNode(changedNodes, events.CHANGE_NODE_CONFIGSTATE_TYPE, change)
 
 		} else if change.IsHAGroup() {
 			ev := events.NewExchangeChangeMessage(events.CHANGE_HA_GROUP)
@@ -198,7 +205,7 @@ func (w *ChangesWorker) findAndProcessChanges() {
 	}
 
 	// Publish any batched events
-	w.emitChangeMessages(batchedEvents, agbotMessages)
+	w.emitChangeMessages(batchedEvents, agbotMessages, changedNodes)
 
 	// Record the most recent change id.
 	w.postProcessChanges(changes)
@@ -244,13 +251,25 @@ func (w *ChangesWorker) getChangeId() error {
 	return nil
 }
 
-// Send change message for each change type in the map that is set to true.
-func (w *ChangesWorker) emitChangeMessages(resChanges map[events.EventId]bool, agbotMessages int) {
+// Remember the org qualified id of the node of a node change. A deleted node has nothing to negotiate with.
+func addChangedNode(changedNodes map[events.EventId][]string, changeType events.EventId, change exchange.ExchangeChange) {
+	if change.Operation != exchange.CHANGE_OPERATION_DELETED {
+		changedNodes[changeType] = append(changedNodes[changeType], fmt.Sprintf("%v/%v", change.OrgID, change.ID))
+	}
+}
+
+// Send change message for each change type in the map that is set to true. The node change messages carry the ids of
+// the changed nodes.
+func (w *ChangesWorker) emitChangeMessages(resChanges map[events.EventId]bool, agbotMessages int, changedNodes map[events.EventId][]string) {
 	for changeType, _ := range resChanges {
 		if changeType == events.CHANGE_AGBOT_MESSAGE_TYPE {
 			ev := events.NewExchangeChangeMessage(changeType)
 			ev.SetChange(events.MessageCount{Count: agbotMessages})
 			w.Messages() <- ev
+		} else if nodes, ok := changedNodes[changeType]; ok {
+			ev := events.NewExchangeChangeMessage(changeType)
+			ev.SetChange(nodes)
+			w.Messages() <- ev
 		} else {
 			w.Messages() <- events.NewExchangeChangeMessage(changeType)
 		}
@@ -292,6 +292,21 @@ func NewMessageCommand(msg *events.ExchangeChangeMessage) *MessageCommand {
 	}
 }
 
+// ==============================================================================================================
+type DirectMessageCommand struct {
+	Msg exchange.AgbotMessage
+}
+
+func (e DirectMessageCommand) ShortString() string {
+	return fmt.Sprintf("DirectMessageCommand: NodeId %v", e.Msg.DeviceId)
+}
+
+func NewDirectMessageCommand(msg exchange.AgbotMessage) *DirectMessageCommand {
+	return &DirectMessageCommand{
+		Msg: msg,
+	}
+}
+
 // ==============================================================================================================
 type PatternChangeCommand struct {
 	Msg events.ExchangeChangeMessage
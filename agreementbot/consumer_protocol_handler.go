@@ -62,6 +62,7 @@ type ConsumerProtocolHandler interface {
 	VerifyAgreement(ag *persistence.Agreement, cph ConsumerProtocolHandler)
 	UpdateAgreement(ag *persistence.Agreement, updateType string, metadata interface{}, cph ConsumerProtocolHandler)
 	GetDeviceMessageEndpoint(deviceId string, workerId string) (string, []byte, error)
+	ReplayMessage(deviceId string, pay []byte) error
 	SetBlockchainClientAvailable(ev *events.BlockchainClientInitializedMessage)
 	SetBlockchainClientNotAvailable(ev *events.BlockchainClientStoppingMessage)
 	SetBlockchainWritable(ev *events.AccountFundedMessage)
@@ -101,7 +102,7 @@ type BaseConsumerProtocolHandler struct {
 }
 
 func (b *BaseConsumerProtocolHandler) GetSendMessage() func(mt interface{}, pay []byte) error {
-	return b.sendMessage
+	return b.deliverMessage
 }
 
 func (b *BaseConsumerProtocolHandler) Name() string {
@@ -136,6 +137,30 @@ func (b *BaseConsumerProtocolHandler) GetHTTPFactory() *config.HTTPClientFactory
 	return b.config.Collaborators.HTTPClientFactory
 }
 
+// Send a protocol message. The exchange calls already retry, so a message that cannot be sent is saved in the dead letter
+// queue, so that it can be replayed when the node is reachable.
+func (w *BaseConsumerProtocolHandler) deliverMessage(mt interface{}, pay []byte) error {
+	err := w.sendMessage(mt, pay)
+	if err != nil {
+		if messageTarget, ok := mt.(*exchange.ExchangeMessageTarget); ok {
+			deadLetters.Add(w.Name(), messageTarget.ReceiverExchangeId, pay, err, 1)
+		}
+	}
+	return err
+}
+
+// Send a dead letter to a node again. The message target is read from the exchange, so that the message is encrypted with
+// the current key of the node.
+func (b *BaseConsumerProtocolHandler) ReplayMessage(deviceId string, pay []byte) error {
+	if whisperTo, pubkeyTo, err := b.GetDeviceMessageEndpoint(deviceId, b.Name()); err != nil {
+		return err
+	} else if mt, err := exchange.CreateMessageTarget(deviceId, nil, pubkeyTo, whisperTo); err != nil {
+		return err
+	} else {
+		return b.sendMessage(mt, pay)
+	}
+}
+
 func (w *BaseConsumerProtocolHandler) sendMessage(mt interface{}, pay []byte) error {
 	// The mt parameter is an abstract message target object that is passed to this routine
 	// by the agreement protocol. It's an interface{} type so that we can avoid the protocol knowing
@@ -186,13 +211,16 @@ func (w *BaseConsumerProtocolHandler) sendMessage(mt interface{}, pay []byte) er
 		}
 	}
 
-	exchDev, err := exchange.GetExchangeDevice(w.GetHTTPFactory(), messageTarget.ReceiverExchangeId, w.agbotId, w.token, w.config.AgreementBot.ExchangeURL)
+	// The node may be in a federated exchange.
+	ec := exchangeFederation.ForOrg(w, exchange.GetOrg(messageTarget.ReceiverExchangeId))
+
+	exchDev, err := exchange.GetExchangeDevice(w.GetHTTPFactory(), messageTarget.ReceiverExchangeId, ec.GetExchangeId(), ec.GetExchangeToken(), ec.GetExchangeURL())
 	if err != nil {
 		return fmt.Errorf("Unable to get device from exchange: %v", err)
 	}
 	maxHb := exchDev.HeartbeatIntv.MaxInterval
 	if maxHb == 0 {
-		exchOrg, err := exchange.GetOrganization(w.GetHTTPFactory(), exchange.GetOrg(messageTarget.ReceiverExchangeId), w.config.AgreementBot.ExchangeURL, w.agbotId, w.token)
+		exchOrg, err := exchange.GetOrganization(w.GetHTTPFactory(), exchange.GetOrg(messageTarget.ReceiverExchangeId), ec.GetExchangeURL(), ec.GetExchangeId(), ec.GetExchangeToken())
 		if err != nil {
 			return fmt.Errorf("Unable to get org from exchange: %v", err)
 		}
@@ -206,14 +234,20 @@ func (w *BaseConsumerProtocolHandler) sendMessage(mt interface{}, pay []byte) er
 		// Marshal it into a byte array
 	} else if msgBody, err := json.Marshal(encryptedMsg); err != nil {
 		return errors.New(fmt.Sprintf("Unable to marshal exchange message, error %v for message %v", err, encryptedMsg))
+		// Send it on the stream of the device if it has one
+	} else if sendDirectMessage(messageTarget.ReceiverExchangeId, msgBody) {
+		if glog.V(5) {
+			glog.Infof(BCPHlogstring(w.Name(), fmt.Sprintf("sent message for %v on its stream.", messageTarget.ReceiverExchangeId)))
+		}
+		return nil
 		// Send it to the device's message queue
 	} else {
 		pm := exchange.CreatePostMessage(msgBody, exchangeMessageTTL)
 		var resp interface{}
 		resp = new(exchange.PostDeviceResponse)
-		targetURL := w.config.AgreementBot.ExchangeURL + "orgs/" + exchange.GetOrg(messageTarget.ReceiverExchangeId) + "/nodes/" + exchange.GetId(messageTarget.ReceiverExchangeId) + "/msgs"
+		targetURL := ec.GetExchangeURL() + "orgs/" + exchange.GetOrg(messageTarget.ReceiverExchangeId) + "/nodes/" + exchange.GetId(messageTarget.ReceiverExchangeId) + "/msgs"
 		for {
-			if err, tpErr := exchange.InvokeExchange(w.httpClient, "POST", targetURL, w.agbotId, w.token, pm, &resp); err != nil {
+			if err, tpErr := exchange.InvokeExchange(w.httpClient, "POST", targetURL, ec.GetExchangeId(), ec.GetExchangeToken(), pm, &resp); err != nil {
 				return err
 			} else if tpErr != nil {
 				glog.Warningf(tpErr.Error())
@@ -379,6 +413,9 @@ func (b *BaseConsumerProtocolHandler) HandlePolicyChangeForAgreement(ag persiste
 		glog.Infof(BCPHlogstring(b.Name(), fmt.Sprintf("attempting to update agreement %v due to change in policy", ag.CurrentAgreementId)))
 	}
 
+	// the agreement is indexed again only if it is still in policy
+	agreementIndex.Remove(ag.CurrentAgreementId)
+
 	svcAllPol := externalpolicy.ExternalPolicy{}
 
 	for _, svcId := range ag.ServiceId {
@@ -392,21 +429,22 @@ func (b *BaseConsumerProtocolHandler) HandlePolicyChangeForAgreement(ag persiste
 
 	msgPrinter := i18n.GetMessagePrinter()
 
-	busPolHandler := exchange.GetHTTPBusinessPoliciesHandler(b)
+	busPolHandler := exchange.GetHTTPBusinessPoliciesHandler(exchangeFederation.ForOrg(b, exchange.GetOrg(ag.PolicyName)))
 	_, busPol, err := compcheck.GetBusinessPolicy(busPolHandler, ag.PolicyName, true, msgPrinter)
 	if err != nil {
 		glog.Errorf(BCPHlogstring(b.Name(), fmt.Sprintf("failed to get business policy %v/%v from the exchange: %v", ag.Org, ag.PolicyName, err)))
 		return false, false
 	}
 
-	nodePolHandler := exchange.GetHTTPNodePolicyHandler(b)
+	nodeEC := exchangeFederation.ForOrg(b, exchange.GetOrg(ag.DeviceId))
+	nodePolHandler := exchange.GetHTTPNodePolicyHandler(nodeEC)
 	_, nodePol, err := compcheck.GetNodePolicy(nodePolHandler, ag.DeviceId, msgPrinter)
 	if err != nil {
 		glog.Errorf(BCPHlogstring(b.Name(), fmt.Sprintf("failed to get node policy for %v from the exchange.", ag.DeviceId)))
 		return false, false
 	}
 
-	dev, err := exchange.GetExchangeDevice(b.GetHTTPFactory(), ag.DeviceId, b.GetExchangeId(), b.GetExchangeToken(), b.GetExchangeURL())
+	dev, err := exchange.GetExchangeDevice(b.GetHTTPFactory(), ag.DeviceId, nodeEC.GetExchangeId(), nodeEC.GetExchangeToken(), nodeEC.GetExchangeURL())
 	if err != nil {
 		glog.Errorf(BCPHlogstring(b.Name(), fmt.Sprintf("failed to get node %v from the exchange.", ag.DeviceId)))
 		return false, false
@@ -415,6 +453,12 @@ func (b *BaseConsumerProtocolHandler) HandlePolicyChangeForAgreement(ag persiste
 		return false, false
 	}
 
+	// A node whose signed properties or user input were changed is no longer in policy.
+	if err := verifyNodePolicy(&b.config.AgreementBot, ag.DeviceId, nodePol, func() ([]policy.UserInput, error) { return dev.UserInput, nil }); err != nil {
+		glog.Errorf(BCPHlogstring(b.Name(), fmt.Sprintf("agreement %v is not longer in policy, error: %v", ag.CurrentAgreementId, err)))
+		return false, true
+	}
+
 	nodeArch := dev.Arch
 	if canArch := b.config.ArchSynonyms.GetCanonicalArch(dev.Arch); canArch != "" {
 		nodeArch = canArch
@@ -438,6 +482,18 @@ func (b *BaseConsumerProtocolHandler) HandlePolicyChangeForAgreement(ag persiste
 		return false, true
 	}
 
+	// The node properties can move the node out of the fleets targeted by the deployment policy.
+	if inFleet, reason := fleetManager.AllowsAgreement(ag.Org, busPol, ag.DeviceId, nodePol.Properties); !inFleet {
+		glog.V(5).Infof(BCPHlogstring(b.Name(), fmt.Sprintf("agreement %v is not longer in policy. Reason is: %v", ag.CurrentAgreementId, reason)))
+		return false, true
+	}
+
+	// Remember the node properties the policies depend on, so that a later node policy change that does not touch
+	// them skips this agreement.
+	if err := agreementIndex.Add(ag.CurrentAgreementId, ag.DeviceId, consumerPol, nodePol, fleetManager.TargetsFleets(ag.Org, busPol)); err != nil {
+		glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("unable to index agreement %v, error: %v", ag.CurrentAgreementId, err)))
+	}
+
 	// don't send an update if the agreement is not finalized yet
 	if ag.AgreementFinalizedTime == 0 {
 		return true, true
@@ -484,7 +540,7 @@ func (b *BaseConsumerProtocolHandler) HandlePolicyChangeForAgreement(ag persiste
 	}
 
 	// populate the workload with the deployment string
-	if svcDef, _, err := exchange.GetHTTPServiceHandler(b)(wl.WorkloadURL, wl.Org, wl.Version, wl.Arch); err != nil {
+	if svcDef, _, err := exchange.GetHTTPServiceHandler(exchangeFederation.ForOrg(b, wl.Org))(wl.WorkloadURL, wl.Org, wl.Version, wl.Arch); err != nil {
 		glog.Errorf(BCPHlogstring(b.Name(), fmt.Sprintf("error getting service '%v' from the exchange, error: %v", wl, err)))
 		return false, false
 	} else if svcDef == nil {
@@ -603,9 +659,34 @@ func (b *BaseConsumerProtocolHandler) HandleNodePolicyChanged(cmd *NodePolicyCha
 		return func(e persistence.Agreement) bool { return e.AgreementCreationTime != 0 && e.AgreementTimedout == 0 }
 	}
 
+	// Get the new node policy once, it is used to skip the agreements that the change cannot affect. If it cannot be
+	// read, all the agreements with the node are verified.
+	nodeId := cutil.FormOrgSpecUrl(cmd.Msg.NodeId, cmd.Msg.NodePolOrg)
+	nodeEC := exchangeFederation.ForOrg(b, cmd.Msg.NodePolOrg)
+	_, nodePol, err := compcheck.GetNodePolicy(exchange.GetHTTPNodePolicyHandler(nodeEC), nodeId, nil)
+	if err != nil {
+		glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("failed to get node policy for %v from the exchange, verifying all its agreements. %v", nodeId, err)))
+		nodePol = nil
+	} else if err := verifyNodePolicy(&b.config.AgreementBot, nodeId, nodePol, func() ([]policy.UserInput, error) {
+		if dev, err := exchange.GetExchangeDevice(b.GetHTTPFactory(), nodeId, nodeEC.GetExchangeId(), nodeEC.GetExchangeToken(), nodeEC.GetExchangeURL()); err != nil {
+			return nil, err
+		} else if dev == nil {
+			return nil, fmt.Errorf("node %v does not exist in the exchange", nodeId)
+		} else {
+			return dev.UserInput, nil
+		}
+	}); err != nil {
+		glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("the node policy signature of %v is not valid, verifying all its agreements. %v", nodeId, err)))
+		nodePol = nil
+	}
+
 	if agreements, err := b.db.FindAgreements([]persistence.AFilter{persistence.UnarchivedAFilter(), InProgress()}, cph.Name()); err == nil {
 		for _, ag := range agreements {
-			if ag.Pattern == "" && ag.DeviceId == cutil.FormOrgSpecUrl(cmd.Msg.NodeId, cmd.Msg.NodePolOrg) {
+			if ag.Pattern == "" && ag.DeviceId == nodeId {
+				if !agreementIndex.NodePolicyAffects(ag.CurrentAgreementId, nodePol) {
+					glog.V(5).Infof(BCPHlogstring(b.Name(), fmt.Sprintf("node policy change for %v does not affect agreement %v, skipping it.", nodeId, ag.CurrentAgreementId)))
+					continue
+				}
 				policyMatches, noNewPriority := b.HandlePolicyChangeForAgreement(ag, nil, cph)
 				agStillValid := policyMatches && noNewPriority
 				if !agStillValid {
@@ -668,12 +749,19 @@ func (b *BaseConsumerProtocolHandler) CancelAgreement(ag persistence.Agreement,
 	if glog.V(5) {
 		glog.Infof(BCPHlogstring(b.Name(), fmt.Sprintf("Canceling Agreement: %v, reason: %v", ag, reason)))
 	}
+	agreementIndex.Remove(ag.CurrentAgreementId)
+
+	// Changes to the deployment of a policy with a schedule are held until a maintenance window opens for the device,
+	// then the agreement is cancelled by a forced upgrade.
+	if !scheduleController.AllowsUpgrade(ag.DeviceId, ag.PolicyName) {
+		return
+	}
 	// Remove any workload usage records (non-HA) or mark for pending upgrade (HA). There might not be a workload usage record
 	// if the consumer policy does not specify the workload priority section.
 	if wlUsage, err := b.db.FindSingleWorkloadUsageByDeviceAndPolicyName(ag.DeviceId, ag.PolicyName); err != nil {
 		glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("error retreiving workload usage for %v using policy %v, error: %v", ag.DeviceId, ag.PolicyName, err)))
 	} else if wlUsage != nil && policyMatches {
-		theDev, err := GetDevice(b.config.Collaborators.HTTPClientFactory.NewHTTPClient(nil), ag.DeviceId, b.config.AgreementBot.ExchangeURL, cph.GetExchangeId(), cph.GetExchangeToken())
+		theDev, err := GetFederatedDevice(cph, ag.DeviceId)
 		if err != nil {
 			glog.Errorf(BCPHlogstring(b.Name(), fmt.Sprintf("error getting device %v, error: %v", ag.DeviceId, err)))
 			return
@@ -700,6 +788,15 @@ func (b *BaseConsumerProtocolHandler) CancelAgreement(ag persistence.Agreement,
 				return
 			}
 
+			// the members of the group are upgraded one at a time, whatever the policy or the kind of upgrade
+			if member, err := haGroupUpgradingMember(b.db, deviceAndGroupOrg, theDev.HAGroup, ag.DeviceId); err != nil {
+				glog.Errorf(BCPHlogstring(b.Name(), err.Error()))
+				return
+			} else if member != "" {
+				glog.Infof(BCPHlogstring(b.Name(), fmt.Sprintf("holding the upgrade of workload for hagroup %v, org: %v, policyName: %v deviceId: %v because node %v of the group is upgrading.", theDev.HAGroup, ag.Org, ag.PolicyName, ag.DeviceId, member)))
+				return
+			}
+
 			// put this workload in HA workload upgrading table
 			if glog.V(5) {
 				glog.Infof(BCPHlogstring(b.Name(), fmt.Sprintf("inserting HA upgrading workloads with hagroup %v, org: %v, policyName: %v deviceId: %v", theDev.HAGroup, ag.Org, ag.PolicyName, ag.DeviceId)))
@@ -913,9 +1010,10 @@ func (b *BaseConsumerProtocolHandler) getDevice(deviceId string, workerId string
 
 	var resp interface{}
 	resp = new(exchange.GetDevicesResponse)
-	targetURL := b.config.AgreementBot.ExchangeURL + "orgs/" + exchange.GetOrg(deviceId) + "/nodes/" + exchange.GetId(deviceId)
+	ec := exchangeFederation.ForOrg(b, exchange.GetOrg(deviceId))
+	targetURL := ec.GetExchangeURL() + "orgs/" + exchange.GetOrg(deviceId) + "/nodes/" + exchange.GetId(deviceId)
 	for {
-		if err, tpErr := exchange.InvokeExchange(b.config.Collaborators.HTTPClientFactory.NewHTTPClient(nil), "GET", targetURL, b.agbotId, b.token, nil, &resp); err != nil {
+		if err, tpErr := exchange.InvokeExchange(b.config.Collaborators.HTTPClientFactory.NewHTTPClient(nil), "GET", targetURL, ec.GetExchangeId(), ec.GetExchangeToken(), nil, &resp); err != nil {
 			glog.Errorf(BCPHlogstring2(workerId, fmt.Sprintf(err.Error())))
 			return nil, err
 		} else if tpErr != nil {
@@ -0,0 +1,202 @@
+package agreementbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/abstractprotocol"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/exchange"
+	"sync"
+	"time"
+)
+
+// The dead letters that have not failed for this long are deleted.
+const DEAD_LETTER_RETENTION_S = 7 * 24 * 60 * 60
+
+// The dead letter queue keeps the protocol messages that could not be delivered to a node, in the agbot database. The
+// messages can contain secrets, so they are encrypted with the message key pair of the agbot, which is not kept in the
+// database. The dead letters can be listed and replayed through the secure agbot API once the node is reachable again.
+// A replay is queued by the API and sent by the dead letter governance subworker, so that the API does not wait for the
+// exchange. A replayed message that is delivered is removed from the queue, one that fails again stays in it, and one
+// about an agreement that is no longer current is dropped.
+type DeadLetterQueue struct {
+	lock    sync.Mutex
+	db      persistence.AgbotDatabase
+	keyPath string   // The path of the message key pair of the agbot.
+	replays []uint64 // The ids of the dead letters to replay.
+}
+
+func NewDeadLetterQueue(db persistence.AgbotDatabase, keyPath string) *DeadLetterQueue {
+	return &DeadLetterQueue{
+		db:      db,
+		keyPath: keyPath,
+		replays: make([]uint64, 0, 10),
+	}
+}
+
+// Save a message that could not be delivered to a node.
+func (d *DeadLetterQueue) Add(protocol string, nodeId string, pay []byte, sendErr error, attempts int) {
+	if d == nil {
+		return
+	}
+
+	sealed, err := sealDeadLetter(pay, d.keyPath)
+	if err != nil {
+		glog.Errorf(DLlogString(fmt.Sprintf("unable to encrypt dead letter for node %v, the message is dropped, error: %v", nodeId, err)))
+		return
+	}
+
+	now := uint64(time.Now().Unix())
+	letter := persistence.DeadLetter{
+		Protocol:    protocol,
+		NodeId:      nodeId,
+		Payload:     sealed,
+		Error:       sendErr.Error(),
+		Attempts:    attempts,
+		FirstFailed: now,
+		LastFailed:  now,
+	}
+
+	// Every protocol message starts with the same header, so the type and agreement id can be read from any of them.
+	var header abstractprotocol.BaseProtocolMessage
+	if err := json.Unmarshal(pay, &header); err == nil {
+		letter.MsgType = header.Type()
+		letter.AgreementId = header.AgreementId()
+	}
+
+	if err := d.db.SaveDeadLetter(&letter); err != nil {
+		glog.Errorf(DLlogString(fmt.Sprintf("unable to save dead letter for node %v, the message is dropped, error: %v", nodeId, err)))
+	} else {
+		glog.Warningf(DLlogString(fmt.Sprintf("saved dead letter %v after %v attempts, error: %v", letter.ShortString(), attempts, sendErr)))
+	}
+}
+
+// Queue the dead letters to be replayed by the governance subworker.
+func (d *DeadLetterQueue) Replay(ids []uint64) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.replays = append(d.replays, ids...)
+}
+
+func (d *DeadLetterQueue) takeReplays() []uint64 {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	ids := d.replays
+	d.replays = make([]uint64, 0, 10)
+	return ids
+}
+
+// Replay the queued dead letters and delete the old ones. This function is called by the dead letter governance subworker.
+func (d *DeadLetterQueue) Govern(consumerPH *ConsumerPHMgr) {
+
+	for _, id := range d.takeReplays() {
+		letter, err := d.db.FindDeadLetter(id)
+		if err != nil {
+			glog.Errorf(DLlogString(fmt.Sprintf("unable to read dead letter %v, error: %v", id, err)))
+			continue
+		} else if letter == nil {
+			glog.V(3).Infof(DLlogString(fmt.Sprintf("dead letter %v was already replayed or deleted", id)))
+			continue
+		}
+
+		// A message about an agreement that was cancelled or replaced would confuse the node, so it is dropped.
+		if staleErr := d.checkCurrent(letter); staleErr != nil {
+			glog.Warningf(DLlogString(fmt.Sprintf("dropping dead letter %v, %v", letter.ShortString(), staleErr)))
+			if err := d.db.DeleteDeadLetter(id); err != nil {
+				glog.Errorf(DLlogString(fmt.Sprintf("unable to delete dead letter %v, error: %v", id, err)))
+			}
+			continue
+		}
+
+		var sendErr error
+		if !consumerPH.Has(letter.Protocol) {
+			sendErr = fmt.Errorf("agreement protocol %v is not supported by this agbot", letter.Protocol)
+		} else if pay, err := openDeadLetter(letter.Payload, d.keyPath); err != nil {
+			sendErr = fmt.Errorf("unable to decrypt the message, error: %v", err)
+		} else {
+			sendErr = consumerPH.Get(letter.Protocol).ReplayMessage(letter.NodeId, pay)
+		}
+
+		if sendErr == nil {
+			glog.V(3).Infof(DLlogString(fmt.Sprintf("replayed dead letter %v", letter.ShortString())))
+			if err := d.db.DeleteDeadLetter(id); err != nil {
+				glog.Errorf(DLlogString(fmt.Sprintf("unable to delete replayed dead letter %v, error: %v", id, err)))
+			}
+			continue
+		}
+
+		glog.Warningf(DLlogString(fmt.Sprintf("replay of dead letter %v failed, error: %v", letter.ShortString(), sendErr)))
+		letter.Attempts++
+		letter.Error = sendErr.Error()
+		letter.LastFailed = uint64(time.Now().Unix())
+		if err := d.db.SaveDeadLetter(letter); err != nil {
+			glog.Errorf(DLlogString(fmt.Sprintf("unable to save dead letter %v, error: %v", id, err)))
+		}
+	}
+
+	if n, err := d.db.DeleteDeadLettersBefore(uint64(time.Now().Unix() - DEAD_LETTER_RETENTION_S)); err != nil {
+		glog.Errorf(DLlogString(fmt.Sprintf("unable to delete old dead letters, error: %v", err)))
+	} else if n != 0 {
+		glog.V(3).Infof(DLlogString(fmt.Sprintf("deleted %v dead letters older than %v seconds", n, DEAD_LETTER_RETENTION_S)))
+	}
+}
+
+// Returns an error when the agreement of a dead letter is no longer current. A cancellation is always sent, so that the node
+// removes the agreement even when the agbot has archived it. The other messages are only sent for an active agreement
+// with the node, and a proposal only while the node has not replied.
+func (d *DeadLetterQueue) checkCurrent(letter *persistence.DeadLetter) error {
+	if letter.MsgType == abstractprotocol.MsgTypeCancel {
+		return nil
+	} else if letter.AgreementId == "" {
+		return fmt.Errorf("the message is not about an agreement")
+	}
+
+	ag, err := d.db.FindSingleAgreementByAgreementId(letter.AgreementId, letter.Protocol, []persistence.AFilter{})
+	if err != nil {
+		return fmt.Errorf("unable to read agreement %v, error: %v", letter.AgreementId, err)
+	}
+	return checkDeadLetterAgreement(letter, ag)
+}
+
+func checkDeadLetterAgreement(letter *persistence.DeadLetter, ag *persistence.Agreement) error {
+	if ag == nil || ag.Archived {
+		return fmt.Errorf("agreement %v no longer exists", letter.AgreementId)
+	} else if ag.DeviceId != letter.NodeId {
+		return fmt.Errorf("agreement %v is with node %v", letter.AgreementId, ag.DeviceId)
+	} else if ag.AgreementTimedout != 0 {
+		return fmt.Errorf("agreement %v is terminated", letter.AgreementId)
+	} else if letter.MsgType == abstractprotocol.MsgTypeProposal && ag.AgreementCreationTime != 0 {
+		return fmt.Errorf("the node already replied to the proposal of agreement %v", letter.AgreementId)
+	}
+	return nil
+}
+
+// Encrypt a message with the message key pair of the agbot, the same way as the messages sent to the nodes.
+func sealDeadLetter(pay []byte, keyPath string) (string, error) {
+	if pubKey, privKey, err := exchange.GetKeys(keyPath); err != nil {
+		return "", err
+	} else if msg, err := exchange.ConstructExchangeMessage(pay, pubKey, privKey, pubKey); err != nil {
+		return "", err
+	} else if sealed, err := json.Marshal(msg); err != nil {
+		return "", err
+	} else {
+		return string(sealed), nil
+	}
+}
+
+// Decrypt a message encrypted by sealDeadLetter. This fails when the message key pair of the agbot has changed since.
+func openDeadLetter(sealed string, keyPath string) ([]byte, error) {
+	if _, privKey, err := exchange.GetKeys(keyPath); err != nil {
+		return nil, err
+	} else if pay, _, err := exchange.DeconstructExchangeMessage([]byte(sealed), privKey); err != nil {
+		return nil, err
+	} else {
+		return pay, nil
+	}
+}
+
+// Logging function
+var DLlogString = func(v interface{}) string {
+	return fmt.Sprintf("Dead Letter Queue: %v", v)
+}
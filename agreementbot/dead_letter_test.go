@@ -0,0 +1,148 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"errors"
+	"github.com/open-horizon/anax/abstractprotocol"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"strings"
+	"testing"
+)
+
+// A database that only keeps dead letters and agreements.
+type deadLetterDB struct {
+	persistence.AgbotDatabase
+	letters    map[uint64]*persistence.DeadLetter
+	agreements map[string]*persistence.Agreement
+}
+
+func (db *deadLetterDB) SaveDeadLetter(letter *persistence.DeadLetter) error {
+	if letter.Id == 0 {
+		letter.Id = uint64(len(db.letters) + 1)
+	}
+	l := *letter
+	db.letters[letter.Id] = &l
+	return nil
+}
+
+func (db *deadLetterDB) FindDeadLetter(id uint64) (*persistence.DeadLetter, error) {
+	if l, ok := db.letters[id]; ok {
+		letter := *l
+		return &letter, nil
+	}
+	return nil, nil
+}
+
+func (db *deadLetterDB) DeleteDeadLetter(id uint64) error {
+	delete(db.letters, id)
+	return nil
+}
+
+func (db *deadLetterDB) DeleteDeadLettersBefore(failed uint64) (int, error) {
+	return 0, nil
+}
+
+func (db *deadLetterDB) FindSingleAgreementByAgreementId(agreementid string, protocol string, filters []persistence.AFilter) (*persistence.Agreement, error) {
+	return db.agreements[agreementid], nil
+}
+
+func Test_checkDeadLetterAgreement(t *testing.T) {
+
+	proposal := &persistence.DeadLetter{NodeId: "org/node1", MsgType: abstractprotocol.MsgTypeProposal, AgreementId: "ag1"}
+	replyAck := &persistence.DeadLetter{NodeId: "org/node1", MsgType: abstractprotocol.MsgTypeReplyAck, AgreementId: "ag1"}
+
+	tests := []struct {
+		name    string
+		letter  *persistence.DeadLetter
+		ag      *persistence.Agreement
+		current bool
+	}{
+		{"pending proposal", proposal, &persistence.Agreement{CurrentAgreementId: "ag1", DeviceId: "org/node1"}, true},
+		{"agreement deleted", proposal, nil, false},
+		{"agreement archived", proposal, &persistence.Agreement{CurrentAgreementId: "ag1", DeviceId: "org/node1", Archived: true}, false},
+		{"agreement terminated", replyAck, &persistence.Agreement{CurrentAgreementId: "ag1", DeviceId: "org/node1", AgreementTimedout: 100}, false},
+		{"other node", replyAck, &persistence.Agreement{CurrentAgreementId: "ag1", DeviceId: "org/node2"}, false},
+		{"proposal already replied", proposal, &persistence.Agreement{CurrentAgreementId: "ag1", DeviceId: "org/node1", AgreementCreationTime: 100}, false},
+		{"reply ack of agreed agreement", replyAck, &persistence.Agreement{CurrentAgreementId: "ag1", DeviceId: "org/node1", AgreementCreationTime: 100}, true},
+	}
+
+	for _, test := range tests {
+		if err := checkDeadLetterAgreement(test.letter, test.ag); (err == nil) != test.current {
+			t.Errorf("%v: expected current %v, but got error %v", test.name, test.current, err)
+		}
+	}
+}
+
+func Test_DeadLetterQueue_Govern(t *testing.T) {
+
+	db := &deadLetterDB{
+		letters: map[uint64]*persistence.DeadLetter{
+			1: {Id: 1, Protocol: "Basic", NodeId: "org/node1", MsgType: abstractprotocol.MsgTypeProposal, AgreementId: "gone"},
+			2: {Id: 2, Protocol: "Basic", NodeId: "org/node1", MsgType: abstractprotocol.MsgTypeProposal, AgreementId: "ag1", Attempts: 1},
+			3: {Id: 3, Protocol: "Basic", NodeId: "org/node1", MsgType: abstractprotocol.MsgTypeCancel, AgreementId: "gone", Attempts: 1},
+		},
+		agreements: map[string]*persistence.Agreement{
+			"ag1": {CurrentAgreementId: "ag1", DeviceId: "org/node1"},
+		},
+	}
+	d := NewDeadLetterQueue(db, "")
+	d.Replay([]uint64{1, 2, 3, 4})
+	d.Govern(NewConsumerPHMgr())
+
+	// the proposal of a deleted agreement is dropped, the others are kept because the protocol is not supported
+	if _, ok := db.letters[1]; ok {
+		t.Errorf("the dead letter of a deleted agreement should have been dropped")
+	}
+	for _, id := range []uint64{2, 3} {
+		if l, ok := db.letters[id]; !ok {
+			t.Errorf("dead letter %v should have been kept", id)
+		} else if l.Attempts != 2 || !strings.Contains(l.Error, "not supported") {
+			t.Errorf("dead letter %v should have failed again, got %v", id, l)
+		}
+	}
+	if len(d.takeReplays()) != 0 {
+		t.Errorf("the replays should have been taken")
+	}
+}
+
+func Test_sealDeadLetter(t *testing.T) {
+
+	t.Setenv("HZN_VAR_BASE", t.TempDir())
+
+	pay := []byte(`{"type":"proposal","agreementId":"ag1","secret":"s3cr3t"}`)
+	sealed, err := sealDeadLetter(pay, "")
+	if err != nil {
+		t.Fatalf("should not return error, but got %v", err)
+	} else if strings.Contains(sealed, "s3cr3t") {
+		t.Errorf("the sealed message should not contain the secret, got %v", sealed)
+	}
+
+	if opened, err := openDeadLetter(sealed, ""); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if string(opened) != string(pay) {
+		t.Errorf("expected %s, but got %s", pay, opened)
+	}
+
+	if _, err := openDeadLetter(`{"wrappedMessage":"eA==","symmetricValues":"eA=="}`, ""); err == nil {
+		t.Errorf("a message that was not sealed should have returned an error")
+	}
+}
+
+func Test_DeadLetterQueue_Add(t *testing.T) {
+
+	t.Setenv("HZN_VAR_BASE", t.TempDir())
+
+	db := &deadLetterDB{letters: map[uint64]*persistence.DeadLetter{}}
+	d := NewDeadLetterQueue(db, "")
+	d.Add("Basic", "org/node1", []byte(`{"type":"cancel","protocol":"Basic","version":1,"agreementId":"ag1","reason":1}`), errors.New("node unreachable"), 1)
+
+	if l, ok := db.letters[1]; !ok {
+		t.Fatalf("the dead letter should have been saved")
+	} else if l.MsgType != abstractprotocol.MsgTypeCancel || l.AgreementId != "ag1" || l.NodeId != "org/node1" || l.Error != "node unreachable" {
+		t.Errorf("wrong dead letter %v", l)
+	} else if strings.Contains(l.Payload, "agreementId") {
+		t.Errorf("the payload should be encrypted, got %v", l.Payload)
+	}
+}
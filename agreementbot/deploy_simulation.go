@@ -0,0 +1,204 @@
+package agreementbot
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/businesspolicy"
+	"github.com/open-horizon/anax/compcheck"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/policy"
+	"golang.org/x/text/message"
+	"sort"
+	"strings"
+)
+
+// The input of the POST /deploycheck/simulate API. A nil business policy simulates the deletion of the policy.
+type DeploySimulation struct {
+	BusinessPolId  string                         `json:"business_policy_id"`
+	BusinessPolicy *businesspolicy.BusinessPolicy `json:"business_policy,omitempty"`
+	ServicePolicy  *externalpolicy.ExternalPolicy `json:"service_policy,omitempty"`
+}
+
+// Validate the proposed deployment policy. Nothing needs to be validated when the deletion of the policy is simulated.
+func (d *DeploySimulation) Validate() error {
+	if d.BusinessPolicy == nil {
+		return nil
+	}
+	return d.BusinessPolicy.Validate()
+}
+
+// An agreement that would be cancelled by the simulated change.
+type SimulatedCancellation struct {
+	AgreementId string `json:"agreementId"`
+	Node        string `json:"node"`
+}
+
+// The output of the POST /deploycheck/simulate API.
+type DeploySimulationOutput struct {
+	Policy              string                  `json:"policy"`
+	NodesChecked        int                     `json:"nodesChecked"`        // The number of nodes the proposed policy was checked against.
+	Gaining             []string                `json:"gaining"`             // The nodes that would get the service, they have no agreement for the policy now.
+	Losing              []string                `json:"losing"`              // The nodes that have an agreement for the policy and would lose the service.
+	CancelledAgreements []SimulatedCancellation `json:"cancelledAgreements"` // The agreements that would be cancelled.
+	Reasons             map[string]string       `json:"reasons,omitempty"`   // Why the nodes losing the service are not compatible, keyed by node id.
+	Errors              map[string]string       `json:"errors,omitempty"`    // The nodes that could not be checked, keyed by node id.
+}
+
+// Work out the nodes gaining and losing the service from the nodes compatible with the proposed policy and the current
+// agreements of the policy. The nodes that could not be checked keep their agreements.
+func simulationDelta(out *DeploySimulationOutput, compatible map[string]bool, agreements []persistence.Agreement) {
+	hasAgreement := make(map[string]bool, len(agreements))
+	for _, ag := range agreements {
+		hasAgreement[ag.DeviceId] = true
+		if _, failed := out.Errors[ag.DeviceId]; failed {
+			continue
+		} else if !compatible[ag.DeviceId] {
+			out.CancelledAgreements = append(out.CancelledAgreements, SimulatedCancellation{AgreementId: ag.CurrentAgreementId, Node: ag.DeviceId})
+		}
+	}
+
+	losing := make(map[string]bool)
+	for _, c := range out.CancelledAgreements {
+		losing[c.Node] = true
+	}
+	for node := range losing {
+		out.Losing = append(out.Losing, node)
+	}
+	for node, ok := range compatible {
+		if ok && !hasAgreement[node] {
+			out.Gaining = append(out.Gaining, node)
+		}
+	}
+
+	// Only keep the reasons of the nodes losing the service.
+	for node := range out.Reasons {
+		if !losing[node] {
+			delete(out.Reasons, node)
+		}
+	}
+
+	sort.Strings(out.Gaining)
+	sort.Strings(out.Losing)
+	sort.Slice(out.CancelledAgreements, func(i, j int) bool {
+		return out.CancelledAgreements[i].AgreementId < out.CancelledAgreements[j].AgreementId
+	})
+}
+
+// Check a proposed deployment policy against the nodes it could be deployed to and compare the result with the current
+// agreements of the policy. The nodes are the nodes of the orgs the policy is served to, read with the credentials of
+// the user so that only the nodes the user can see are checked. Nothing is changed in the exchange or the database.
+func (a *SecureAPI) simulateDeployment(user_ec exchange.ExchangeContext, input *DeploySimulation, msgPrinter *message.Printer) (*DeploySimulationOutput, error) {
+
+	polOrg, polName := cutil.SplitOrgSpecUrl(input.BusinessPolId)
+
+	out := &DeploySimulationOutput{
+		Policy:              input.BusinessPolId,
+		Gaining:             []string{},
+		Losing:              []string{},
+		CancelledAgreements: []SimulatedCancellation{},
+		Reasons:             make(map[string]string),
+		Errors:              make(map[string]string),
+	}
+	compatible := make(map[string]bool)
+
+	// A deleted policy is compatible with no node, so the nodes do not need to be checked.
+	if input.BusinessPolicy != nil {
+		nodeOrgs := []string{polOrg}
+		if businessPolManager != nil {
+			if served := businessPolManager.GetServedNodeOrgs(polOrg, polName); len(served) != 0 {
+				nodeOrgs = served
+			}
+		}
+
+		fleets := qualifyFleetNames(input.BusinessPolId, input.BusinessPolicy.Fleets)
+		for _, org := range nodeOrgs {
+			// The nodes are checked a page at a time, so that the nodes of a large org are not read in one call.
+			for it := exchange.NewNodeIterator(user_ec, org, 0); it.HasNext(); {
+				nodes, err := it.Next()
+				if err != nil {
+					return nil, fmt.Errorf(msgPrinter.Sprintf("Unable to retrieve the nodes of org %v from the exchange, error: %v", org, err))
+				}
+				for id, node := range nodes {
+					// Pattern nodes do not make agreements for deployment policies, and unregistered nodes make none at all.
+					if node.Pattern != "" || node.PublicKey == "" {
+						continue
+					}
+					out.NodesChecked += 1
+
+					check := &compcheck.CompCheck{
+						NodeId:         id,
+						BusinessPolId:  input.BusinessPolId,
+						BusinessPolicy: input.BusinessPolicy,
+						ServicePolicy:  input.ServicePolicy,
+					}
+					if ccOutput, err := compcheck.DeployCompatible(user_ec, "", check, false, msgPrinter); err != nil {
+						out.Errors[id] = err.Error()
+					} else if !ccOutput.Compatible {
+						out.Reasons[id] = simulationReason(ccOutput.Reason)
+					} else if ok, reason := simulationInFleets(fleets, id, ccOutput); !ok {
+						out.Reasons[id] = reason
+					} else {
+						compatible[id] = true
+					}
+				}
+			}
+		}
+	}
+
+	PolicyAFilter := func() persistence.AFilter {
+		return func(a persistence.Agreement) bool { return a.PolicyName == input.BusinessPolId && a.Pattern == "" }
+	}
+
+	agreements := make([]persistence.Agreement, 0)
+	for _, agp := range policy.AllAgreementProtocols() {
+		if ags, err := a.db.FindAgreements([]persistence.AFilter{persistence.UnarchivedAFilter(), PolicyAFilter()}, agp); err != nil {
+			return nil, fmt.Errorf(msgPrinter.Sprintf("Unable to read the agreements of %v, error: %v", input.BusinessPolId, err))
+		} else {
+			agreements = append(agreements, ags...)
+		}
+	}
+
+	if input.BusinessPolicy == nil {
+		for _, ag := range agreements {
+			out.Reasons[ag.DeviceId] = msgPrinter.Sprintf("The deployment policy is deleted.")
+		}
+	}
+
+	simulationDelta(out, compatible, agreements)
+	glog.V(3).Infof(APIlogString(fmt.Sprintf("simulated change of %v: %v nodes checked, %v gaining, %v losing", input.BusinessPolId, out.NodesChecked, len(out.Gaining), len(out.Losing))))
+	return out, nil
+}
+
+// Returns true if the node is in the fleets of the proposed policy, or if the policy does not target fleets.
+func simulationInFleets(fleets []string, nodeId string, ccOutput *compcheck.CompCheckOutput) (bool, string) {
+	if len(fleets) == 0 || fleetManager == nil {
+		return true, ""
+	}
+
+	var props externalpolicy.PropertyList
+	if ccOutput.Input != nil && ccOutput.Input.NodePolicy != nil {
+		props = ccOutput.Input.NodePolicy.Properties
+	}
+	if inFleet, _ := fleetManager.hasNode(fleets, nodeId, props); !inFleet {
+		return false, fmt.Sprintf("Node %v is not in the fleets %v of the deployment policy.", nodeId, strings.Join(fleets, ", "))
+	}
+	return true, ""
+}
+
+// Combine the reasons of a compatibility check, one for each service, into one.
+func simulationReason(reasons map[string]string) string {
+	keys := make([]string, 0, len(reasons))
+	for k := range reasons {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	msgs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		msgs = append(msgs, fmt.Sprintf("%v: %v", k, reasons[k]))
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,56 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"reflect"
+	"testing"
+)
+
+func Test_simulationDelta(t *testing.T) {
+	out := &DeploySimulationOutput{
+		Reasons: map[string]string{"org1/n3": "not compatible", "org1/n5": "not compatible"},
+		Errors:  map[string]string{"org1/n4": "exchange error"},
+	}
+	compatible := map[string]bool{"org1/n1": true, "org1/n2": true}
+	agreements := []persistence.Agreement{
+		{CurrentAgreementId: "a2", DeviceId: "org1/n2"},
+		{CurrentAgreementId: "a3", DeviceId: "org1/n3"},
+		{CurrentAgreementId: "a4", DeviceId: "org1/n4"},
+	}
+
+	simulationDelta(out, compatible, agreements)
+
+	if !reflect.DeepEqual(out.Gaining, []string{"org1/n1"}) {
+		t.Errorf("expected org1/n1 to gain the service, got %v", out.Gaining)
+	}
+	if !reflect.DeepEqual(out.Losing, []string{"org1/n3"}) {
+		t.Errorf("expected org1/n3 to lose the service, got %v", out.Losing)
+	}
+	if !reflect.DeepEqual(out.CancelledAgreements, []SimulatedCancellation{{AgreementId: "a3", Node: "org1/n3"}}) {
+		t.Errorf("expected agreement a3 to be cancelled, got %v", out.CancelledAgreements)
+	}
+	if _, ok := out.Reasons["org1/n5"]; ok || len(out.Reasons) != 1 {
+		t.Errorf("only the reasons of the nodes losing the service should be kept, got %v", out.Reasons)
+	}
+}
+
+func Test_simulationDelta_deleted(t *testing.T) {
+	out := &DeploySimulationOutput{}
+	agreements := []persistence.Agreement{
+		{CurrentAgreementId: "a2", DeviceId: "org1/n2"},
+		{CurrentAgreementId: "a1", DeviceId: "org1/n1"},
+	}
+
+	simulationDelta(out, map[string]bool{}, agreements)
+
+	if len(out.Gaining) != 0 {
+		t.Errorf("no node should gain the service, got %v", out.Gaining)
+	} else if !reflect.DeepEqual(out.Losing, []string{"org1/n1", "org1/n2"}) {
+		t.Errorf("all the nodes should lose the service, got %v", out.Losing)
+	} else if len(out.CancelledAgreements) != 2 || out.CancelledAgreements[0].AgreementId != "a1" {
+		t.Errorf("all the agreements should be cancelled, got %v", out.CancelledAgreements)
+	}
+}
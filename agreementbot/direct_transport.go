@@ -0,0 +1,111 @@
+package agreementbot
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/grpctransport"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"net"
+	"sync"
+)
+
+// The server of the gRPC agreement transport, nil when it is not configured. The agreement protocol messages for a node
+// with an open stream are sent on the stream, the others are sent through the exchange.
+var directTransport *grpctransport.Server
+var directTransportLock sync.Mutex
+
+// Start serving the gRPC agreement transport on the configured address. The stream is protected by the secure API server
+// certificate, it is only served without TLS when AgreementGRPCInsecure is set.
+func (w *AgreementBotWorker) startDirectTransport() error {
+	listen := w.Config.AgreementBot.AgreementGRPCListen
+	if listen == "" {
+		return nil
+	}
+
+	opts := []grpc.ServerOption{}
+	certFile := w.Config.AgreementBot.SecureAPIServerCert
+	keyFile := w.Config.AgreementBot.SecureAPIServerKey
+	if (certFile == "" || keyFile == "") && !w.Config.AgreementBot.AgreementGRPCInsecure {
+		return fmt.Errorf("unable to serve on %v without TLS, configure the secure API server certificate and key or set AgreementGRPCInsecure", listen)
+	} else if certFile == "" || keyFile == "" {
+		glog.Warningf(DTlogString(fmt.Sprintf("the streams on %v are not protected by TLS, no secure API server certificate is configured", listen)))
+	} else if creds, err := credentials.NewServerTLSFromFile(certFile, keyFile); err != nil {
+		return fmt.Errorf("unable to read the secure API server certificate %v and key %v, error: %v", certFile, keyFile, err)
+	} else {
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	lis, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("unable to listen on %v, error: %v", listen, err)
+	}
+
+	server := grpctransport.NewServer(w.GetExchangeId(), w.authenticateNode, w.receiveDirectMessage, opts...)
+	directTransportLock.Lock()
+	directTransport = server
+	directTransportLock.Unlock()
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			glog.Errorf(DTlogString(fmt.Sprintf("stopped serving on %v, error: %v", listen, err)))
+		}
+	}()
+
+	glog.Infof(DTlogString(fmt.Sprintf("serving on %v", listen)))
+	return nil
+}
+
+// A node is authenticated by reading itself from the exchange with its own credentials.
+func (w *AgreementBotWorker) authenticateNode(nodeId string, token string) error {
+	if exchange.GetOrg(nodeId) == "" || exchange.GetId(nodeId) == "" {
+		return errors.New("the node id is not org qualified")
+	}
+	_, err := exchange.GetExchangeDevice(w.GetHTTPFactory(), nodeId, nodeId, token, exchangeFederation.ForOrg(w, exchange.GetOrg(nodeId)).GetExchangeURL())
+	return err
+}
+
+// Called for each message received on the stream of a node. The message is handled like a message from the exchange,
+// the key of the node is the one it registered in the exchange.
+func (w *AgreementBotWorker) receiveDirectMessage(nodeId string, message []byte) {
+	ec := exchangeFederation.ForOrg(w, exchange.GetOrg(nodeId))
+	if dev, err := exchange.GetExchangeDevice(w.GetHTTPFactory(), nodeId, ec.GetExchangeId(), ec.GetExchangeToken(), ec.GetExchangeURL()); err != nil {
+		glog.Errorf(DTlogString(fmt.Sprintf("unable to read node %v from the exchange, ignoring its message, error: %v", nodeId, err)))
+	} else if pubKey, err := base64.StdEncoding.DecodeString(dev.PublicKey); err != nil {
+		glog.Errorf(DTlogString(fmt.Sprintf("unable to decode the public key of node %v, ignoring its message, error: %v", nodeId, err)))
+	} else {
+		msg := exchange.AgbotMessage{
+			MsgId:        exchange.DIRECT_MSG_ID,
+			DeviceId:     nodeId,
+			DevicePubKey: pubKey,
+			Message:      message,
+			TimeSent:     cutil.FormattedTime(),
+		}
+		w.Commands <- NewDirectMessageCommand(msg)
+	}
+}
+
+// Send an encrypted message on the stream of a node. Returns false when the message has to be sent through the exchange.
+func sendDirectMessage(nodeId string, message []byte) bool {
+	directTransportLock.Lock()
+	server := directTransport
+	directTransportLock.Unlock()
+
+	if server == nil {
+		return false
+	} else if err := server.Send(nodeId, message); err != nil {
+		if err != grpctransport.ErrNotConnected {
+			glog.Warningf(DTlogString(fmt.Sprintf("sending the message for %v through the exchange, error: %v", nodeId, err)))
+		}
+		return false
+	}
+	return true
+}
+
+// Logging function
+var DTlogString = func(v interface{}) string {
+	return fmt.Sprintf("Direct Transport: %v", v)
+}
@@ -0,0 +1,31 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/worker"
+	"testing"
+)
+
+func Test_startDirectTransport_TLS(t *testing.T) {
+	w := &AgreementBotWorker{BaseWorker: worker.BaseWorker{Manager: worker.Manager{Config: &config.HorizonConfig{}}}}
+
+	// not configured
+	if err := w.startDirectTransport(); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	}
+
+	// no certificate and key
+	w.Config.AgreementBot.AgreementGRPCListen = "127.0.0.1:0"
+	if err := w.startDirectTransport(); err == nil {
+		t.Errorf("serving without TLS should have returned an error")
+	}
+
+	directTransportLock.Lock()
+	defer directTransportLock.Unlock()
+	if directTransport != nil {
+		t.Errorf("the transport should not be started without TLS")
+	}
+}
@@ -0,0 +1,240 @@
+package agreementbot
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/exchange"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// The exchange federation is the set of exchange instances, other than the agbot's own exchange, whose nodes are served
+// by the agbot. Each member exchange hosts its own orgs. Everything in those orgs (nodes, node policies, deployment
+// policies, patterns and services) is read from the member exchange with the agbot's credentials in that exchange, and
+// the messages to and from the nodes of those orgs go through the member exchange. The orgs of a member are never read
+// from any other exchange, so the org names must be unique across the federation. The agbot's own exchange is not a
+// member, it hosts all the other orgs.
+type ExchangeFederation struct {
+	members   []*FederationMember
+	orgs      map[string]*FederationMember
+	msgLock   sync.Mutex
+	msgs      map[int]federatedMessage // the member exchange messages being processed, keyed by local message id
+	msgIds    map[federatedMessage]int // the local message id of each member exchange message
+	nextMsgId int
+}
+
+// A member exchange of the federation.
+type FederationMember struct {
+	exchange.ExchangeContext
+	Name string
+	Orgs []string
+}
+
+func (m *FederationMember) String() string {
+	return fmt.Sprintf("Name: %v, URL: %v, Id: %v, Orgs: %v", m.Name, m.GetExchangeURL(), m.GetExchangeId(), m.Orgs)
+}
+
+// A message read from the mailbox of the agbot in a member exchange.
+type federatedMessage struct {
+	member string
+	msgId  int
+}
+
+// The federation of the agbot, nil when the agbot only serves the nodes of its own exchange.
+var exchangeFederation *ExchangeFederation
+
+// Returns nil when there are no federated exchanges configured.
+func NewExchangeFederation(cfg *config.HorizonConfig) *ExchangeFederation {
+	if len(cfg.AgreementBot.FederatedExchanges) == 0 {
+		return nil
+	}
+
+	f := &ExchangeFederation{
+		members:   make([]*FederationMember, 0, len(cfg.AgreementBot.FederatedExchanges)),
+		orgs:      make(map[string]*FederationMember),
+		msgs:      make(map[int]federatedMessage),
+		msgIds:    make(map[federatedMessage]int),
+		nextMsgId: exchange.DIRECT_MSG_ID - 1,
+	}
+	for _, fe := range cfg.AgreementBot.FederatedExchanges {
+		m := &FederationMember{
+			ExchangeContext: exchange.NewCustomExchangeContext(fe.ExchangeId, fe.ExchangeToken, fe.ExchangeURL, cfg.AgreementBot.CSSURL, cfg.Collaborators.HTTPClientFactory),
+			Name:            fe.Name,
+			Orgs:            fe.Orgs,
+		}
+		f.members = append(f.members, m)
+		for _, org := range fe.Orgs {
+			f.orgs[org] = m
+		}
+	}
+	return f
+}
+
+// Returns the members of the federation.
+func (f *ExchangeFederation) Members() []*FederationMember {
+	if f == nil {
+		return []*FederationMember{}
+	}
+	return f.members
+}
+
+// Returns the member exchange hosting the org, or nil when the org is in the agbot's own exchange.
+func (f *ExchangeFederation) Member(org string) *FederationMember {
+	if f == nil {
+		return nil
+	}
+	return f.orgs[org]
+}
+
+// Returns the exchange context to use for the given org, the context of the member exchange hosting the org or the
+// given context of the agbot's own exchange.
+func (f *ExchangeFederation) ForOrg(ec exchange.ExchangeContext, org string) exchange.ExchangeContext {
+	if m := f.Member(org); m != nil {
+		return m
+	}
+	return ec
+}
+
+// Returns the node from the exchange hosting the node's org, the given context of the agbot's own exchange or a member
+// exchange of the federation.
+func GetFederatedDevice(ec exchange.ExchangeContext, deviceId string) (*exchange.Device, error) {
+	nodeEC := exchangeFederation.ForOrg(ec, exchange.GetOrg(deviceId))
+	return GetDevice(nodeEC.GetHTTPFactory().NewHTTPClient(nil), deviceId, nodeEC.GetExchangeURL(), nodeEC.GetExchangeId(), nodeEC.GetExchangeToken())
+}
+
+// Returns the served deployment policies from the agbot's own exchange and from each member exchange. Only the served
+// policies whose policy org and node org are both hosted by the exchange they are read from are kept.
+func (f *ExchangeFederation) ServedPolicies(ec exchange.ExchangeContext) (map[string]exchange.ServedBusinessPolicy, error) {
+	pols, err := exchange.GetHTTPAgbotServedDeploymentPolicy(ec)()
+	if err != nil || f == nil {
+		return pols, err
+	}
+
+	served := make(map[string]exchange.ServedBusinessPolicy)
+	for key, sp := range pols {
+		if f.Member(sp.BusinessPolOrg) == nil && f.Member(sp.NodeOrg) == nil {
+			served[key] = sp
+		}
+	}
+	for _, m := range f.members {
+		if mPols, err := exchange.GetHTTPAgbotServedDeploymentPolicy(m)(); err != nil {
+			return nil, fmt.Errorf("unable to retrieve the served deployment policies from federated exchange %v, error %v", m.Name, err)
+		} else {
+			for key, sp := range mPols {
+				if f.Member(sp.BusinessPolOrg) == m && f.Member(sp.NodeOrg) == m {
+					served[key] = sp
+				} else {
+					glog.Warningf(AWlogString(fmt.Sprintf("ignoring served deployment policy %v of federated exchange %v, the orgs are not hosted by that exchange", sp, m.Name)))
+				}
+			}
+		}
+	}
+	return served, nil
+}
+
+// Returns the served patterns from the agbot's own exchange and from each member exchange. Only the served patterns
+// whose pattern org and node org are both hosted by the exchange they are read from are kept.
+func (f *ExchangeFederation) ServedPatterns(ec exchange.ExchangeContext) (map[string]exchange.ServedPattern, error) {
+	pats, err := exchange.GetHTTPAgbotServedPattern(ec)()
+	if err != nil || f == nil {
+		return pats, err
+	}
+
+	served := make(map[string]exchange.ServedPattern)
+	for key, sp := range pats {
+		if f.Member(sp.PatternOrg) == nil && f.Member(sp.NodeOrg) == nil {
+			served[key] = sp
+		}
+	}
+	for _, m := range f.members {
+		if mPats, err := exchange.GetHTTPAgbotServedPattern(m)(); err != nil {
+			return nil, fmt.Errorf("unable to retrieve the served patterns from federated exchange %v, error %v", m.Name, err)
+		} else {
+			for key, sp := range mPats {
+				if f.Member(sp.PatternOrg) == m && f.Member(sp.NodeOrg) == m {
+					served[key] = sp
+				} else {
+					glog.Warningf(AWlogString(fmt.Sprintf("ignoring served pattern %v of federated exchange %v, the orgs are not hosted by that exchange", sp, m.Name)))
+				}
+			}
+		}
+	}
+	return served, nil
+}
+
+// Messages read from a member exchange are given a local message id, below the id of the messages that are not in
+// any exchange, so that the protocol workers can delete them like the messages of the agbot's own exchange. A message
+// that is read again before it is deleted keeps its local id.
+func (f *ExchangeFederation) trackMessage(m *FederationMember, msgId int) int {
+	f.msgLock.Lock()
+	defer f.msgLock.Unlock()
+
+	fm := federatedMessage{member: m.Name, msgId: msgId}
+	if id, ok := f.msgIds[fm]; ok {
+		return id
+	}
+	id := f.nextMsgId
+	f.nextMsgId -= 1
+	f.msgs[id] = fm
+	f.msgIds[fm] = id
+	return id
+}
+
+// Returns true if the local message id is the id of a message read from a member exchange.
+func IsFederatedMessage(msgId int) bool {
+	return msgId < exchange.DIRECT_MSG_ID
+}
+
+// Delete a message, given its local message id, from the member exchange it was read from.
+func (f *ExchangeFederation) deleteMessage(msgId int, httpClient *http.Client) error {
+	if f == nil {
+		return nil
+	}
+
+	f.msgLock.Lock()
+	fm, ok := f.msgs[msgId]
+	f.msgLock.Unlock()
+	if !ok {
+		return nil
+	}
+
+	m := f.memberByName(fm.member)
+	if err := deleteExchangeMessage(fm.msgId, m.GetExchangeId(), m.GetExchangeToken(), m.GetExchangeURL(), httpClient); err != nil {
+		return err
+	}
+
+	f.msgLock.Lock()
+	delete(f.msgs, msgId)
+	delete(f.msgIds, fm)
+	f.msgLock.Unlock()
+	return nil
+}
+
+func (f *ExchangeFederation) memberByName(name string) *FederationMember {
+	for _, m := range f.members {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// The members of the federation and the orgs they host, returned by the agbot API.
+type FederationOutput struct {
+	Exchange string   `json:"exchange"`
+	URL      string   `json:"url"`
+	AgbotId  string   `json:"agbotId"`
+	Orgs     []string `json:"orgs"`
+}
+
+func (f *ExchangeFederation) Output() []FederationOutput {
+	out := make([]FederationOutput, 0, len(f.Members()))
+	for _, m := range f.Members() {
+		orgs := append([]string{}, m.Orgs...)
+		sort.Strings(orgs)
+		out = append(out, FederationOutput{Exchange: m.Name, URL: m.GetExchangeURL(), AgbotId: m.GetExchangeId(), Orgs: orgs})
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/exchange"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_ExchangeFederation_ForOrg(t *testing.T) {
+
+	cfg := &config.HorizonConfig{
+		AgreementBot: config.AGConfig{
+			FederatedExchanges: []config.FederatedExchange{
+				{Name: "eu", ExchangeURL: "https://eu/v1/", ExchangeId: "hub/agbot", ExchangeToken: "t", Orgs: []string{"orgA", "orgB"}},
+			},
+		},
+	}
+	home := exchange.NewCustomExchangeContext("hub/agbot", "t", "https://home/v1/", "", nil)
+
+	var none *ExchangeFederation
+	if ec := none.ForOrg(home, "orgA"); ec != home {
+		t.Errorf("an agbot without federation should use its own exchange, got %v", ec)
+	} else if len(none.Members()) != 0 {
+		t.Errorf("an agbot without federation should have no members")
+	} else if NewExchangeFederation(&config.HorizonConfig{}) != nil {
+		t.Errorf("the federation should be nil when there are no federated exchanges")
+	}
+
+	f := NewExchangeFederation(cfg)
+	if ec := f.ForOrg(home, "orgB"); ec.GetExchangeURL() != "https://eu/v1/" {
+		t.Errorf("orgB should be read from the eu exchange, got %v", ec.GetExchangeURL())
+	} else if ec := f.ForOrg(home, "orgC"); ec != home {
+		t.Errorf("orgC should be read from the agbot's own exchange, got %v", ec.GetExchangeURL())
+	} else if out := f.Output(); len(out) != 1 || out[0].Exchange != "eu" || len(out[0].Orgs) != 2 {
+		t.Errorf("wrong federation output %v", out)
+	}
+}
+
+func Test_ExchangeFederation_trackMessage(t *testing.T) {
+
+	cfg := &config.HorizonConfig{
+		AgreementBot: config.AGConfig{
+			FederatedExchanges: []config.FederatedExchange{
+				{Name: "eu", Orgs: []string{"orgA"}},
+				{Name: "us", Orgs: []string{"orgB"}},
+			},
+		},
+	}
+	f := NewExchangeFederation(cfg)
+	eu, us := f.Members()[0], f.Members()[1]
+
+	id1 := f.trackMessage(eu, 5)
+	id2 := f.trackMessage(us, 5)
+	if !IsFederatedMessage(id1) || !IsFederatedMessage(id2) || id1 == id2 {
+		t.Errorf("messages of different exchanges should have different local ids, got %v and %v", id1, id2)
+	} else if id := f.trackMessage(eu, 5); id != id1 {
+		t.Errorf("a message read again should keep its local id %v, got %v", id1, id)
+	} else if IsFederatedMessage(5) || IsFederatedMessage(exchange.DIRECT_MSG_ID) {
+		t.Errorf("the messages of the agbot's exchange are not federated")
+	}
+}
+
+func Test_GetFederatedDevice(t *testing.T) {
+
+	// only the eu exchange knows the nodes of orgA
+	eu := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/orgs/orgA/nodes/n1" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"nodes":{"orgA/n1":{"name":"n1","owner":"orgA/u"}}}`)
+	}))
+	defer eu.Close()
+
+	factory := &config.HTTPClientFactory{NewHTTPClient: func(*uint) *http.Client { return eu.Client() }, RetryCount: 1, RetryInterval: 1}
+	cfg := &config.HorizonConfig{
+		AgreementBot: config.AGConfig{
+			FederatedExchanges: []config.FederatedExchange{
+				{Name: "eu", ExchangeURL: eu.URL + "/v1/", ExchangeId: "hub/agbot", ExchangeToken: "t", Orgs: []string{"orgA"}},
+			},
+		},
+		Collaborators: config.Collaborators{HTTPClientFactory: factory},
+	}
+	home := exchange.NewCustomExchangeContext("hub/agbot", "t", "http://home.invalid/v1/", "", factory)
+
+	saved := exchangeFederation
+	exchangeFederation = NewExchangeFederation(cfg)
+	defer func() { exchangeFederation = saved }()
+
+	if dev, err := GetFederatedDevice(home, "orgA/n1"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if dev == nil || dev.Name != "n1" {
+		t.Errorf("the node of orgA should be read from the eu exchange, got %v", dev)
+	}
+}
@@ -0,0 +1,223 @@
+package agreementbot
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/compcheck"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/policy"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The fleet manager keeps the fleets of nodes that deployment policies target. A deployment policy that lists fleets only
+// makes agreements with the nodes in those fleets. The fleets are read from the agbot database, where they are written by
+// the secure API, and cached in memory.
+//
+// When a fleet or the fleets of a policy change, the nodes are searched again for the policy so that the nodes added to a
+// fleet get an agreement, and the agreements with the nodes that are no longer in the fleets are cancelled.
+type FleetManager struct {
+	lock       sync.Mutex
+	db         persistence.AgbotDatabase
+	ec         exchange.ExchangeContext
+	msgs       chan events.Message // Outgoing internal event messages are placed here.
+	nodeSearch *NodeSearch
+	fleets     map[string]persistence.Fleet // The fleets, keyed by org qualified fleet name.
+	policies   map[string]string            // The fleets of each policy when it was last checked, keyed by org qualified policy name.
+}
+
+func NewFleetManager(db persistence.AgbotDatabase, ec exchange.ExchangeContext, msgs chan events.Message, nodeSearch *NodeSearch) *FleetManager {
+	return &FleetManager{
+		db:         db,
+		ec:         ec,
+		msgs:       msgs,
+		nodeSearch: nodeSearch,
+		fleets:     make(map[string]persistence.Fleet),
+		policies:   make(map[string]string),
+	}
+}
+
+// Qualify the fleet names of a deployment policy with the org of the policy, when they are not already.
+func qualifyFleetNames(polId string, fleets []string) []string {
+	if len(fleets) == 0 {
+		return nil
+	}
+	qualified := make([]string, 0, len(fleets))
+	for _, f := range fleets {
+		if strings.Contains(f, "/") {
+			qualified = append(qualified, f)
+		} else {
+			qualified = append(qualified, fmt.Sprintf("%v/%v", exchange.GetOrg(polId), f))
+		}
+	}
+	return qualified
+}
+
+// Read the fleets from the database.
+func (fm *FleetManager) Refresh() error {
+	fleets, err := fm.db.FindFleets("")
+	if err != nil {
+		return err
+	}
+
+	fm.lock.Lock()
+	defer fm.lock.Unlock()
+	fm.fleets = make(map[string]persistence.Fleet, len(fleets))
+	for _, f := range fleets {
+		fm.fleets[fmt.Sprintf("%v/%v", f.Org, f.Name)] = f
+	}
+	return nil
+}
+
+// Returns true if the node is in one of the fleets. The second return value is true when the fleets select nodes by
+// their properties, so the node properties are needed to decide.
+func (fm *FleetManager) hasNode(fleets []string, nodeId string, props externalpolicy.PropertyList) (bool, bool) {
+	fm.lock.Lock()
+	defer fm.lock.Unlock()
+
+	needProps := false
+	for _, name := range fleets {
+		if f, ok := fm.fleets[name]; ok {
+			if f.HasNode(nodeId, props) {
+				return true, false
+			}
+			needProps = needProps || len(f.Constraints) != 0
+		}
+	}
+	return false, needProps
+}
+
+// Return the fleets of a policy and the time each was last changed, so that a change to the fleets can be detected.
+func (fm *FleetManager) fleetsState(fleets []string) string {
+	fm.lock.Lock()
+	defer fm.lock.Unlock()
+
+	state := make([]string, 0, len(fleets))
+	for _, name := range fleets {
+		if f, ok := fm.fleets[name]; ok {
+			state = append(state, fmt.Sprintf("%v@%v", name, f.LastUpdated))
+		} else {
+			state = append(state, name+"@missing")
+		}
+	}
+	sort.Strings(state)
+	return strings.Join(state, ",")
+}
+
+// Returns true if the node is in one of the fleets targeted by the deployment policy, or if the policy does not target
+// fleets. Otherwise the reason is returned.
+func (fm *FleetManager) AllowsAgreement(org string, pol *policy.Policy, deviceId string, props externalpolicy.PropertyList) (bool, string) {
+	if fm == nil || pol.PatternId != "" || businessPolManager == nil {
+		return true, ""
+	}
+
+	_, polName := cutil.SplitOrgSpecUrl(pol.Header.Name)
+	fleets := businessPolManager.GetFleets(org, polName)
+	if len(fleets) == 0 {
+		return true, ""
+	} else if inFleet, _ := fm.hasNode(fleets, deviceId, props); !inFleet {
+		return false, fmt.Sprintf("Node %v is not in the fleets %v of the deployment policy.", deviceId, strings.Join(fleets, ", "))
+	}
+	return true, ""
+}
+
+// Returns true if the deployment policy targets fleets. The fleet membership of a node depends on its properties, so
+// any change to the node properties can move it out of the fleets.
+func (fm *FleetManager) TargetsFleets(org string, pol *policy.Policy) bool {
+	if fm == nil || pol.PatternId != "" || businessPolManager == nil {
+		return false
+	}
+
+	_, polName := cutil.SplitOrgSpecUrl(pol.Header.Name)
+	return len(businessPolManager.GetFleets(org, polName)) != 0
+}
+
+// Check the fleets of the deployment policies for changes. When the fleets of a policy changed, the nodes are searched
+// again for the policy and the agreements with the nodes that left the fleets are cancelled. This function is called by
+// the fleet governance subworker.
+func (fm *FleetManager) Govern() {
+
+	if err := fm.Refresh(); err != nil {
+		glog.Errorf(FMlogString(fmt.Sprintf("unable to read the fleets, error: %v", err)))
+		return
+	}
+
+	served := make(map[string]bool)
+	for _, org := range businessPolManager.GetAllPolicyOrgs() {
+		for _, pol := range businessPolManager.GetAllPoliciesOrderedForOrg(org, false) {
+
+			_, polName := cutil.SplitOrgSpecUrl(pol.Header.Name)
+			fleets := businessPolManager.GetFleets(org, polName)
+			state := fm.fleetsState(fleets)
+			served[pol.Header.Name] = true
+
+			old, known := fm.policies[pol.Header.Name]
+			fm.policies[pol.Header.Name] = state
+			if known && old == state {
+				continue
+			}
+
+			// Search all the nodes again so that the nodes that joined the fleets are found. A policy seen for the first
+			// time is searched anyway.
+			if known {
+				glog.V(3).Infof(FMlogString(fmt.Sprintf("fleets of %v changed to %v", pol.Header.Name, state)))
+				fm.nodeSearch.AddRetry(pol.Header.Name, 0)
+			}
+			if len(fleets) != 0 {
+				fm.cancelOutsideFleets(pol.Header.Name, fleets)
+			}
+		}
+	}
+
+	// Forget the policies that are no longer served.
+	for name := range fm.policies {
+		if !served[name] {
+			delete(fm.policies, name)
+		}
+	}
+}
+
+// Cancel the agreements of a policy with the nodes that are not in its fleets. The agreements are cancelled through a
+// workload upgrade, which respects the schedule of the policy, and no new agreement is made while the node is outside
+// the fleets.
+func (fm *FleetManager) cancelOutsideFleets(policyName string, fleets []string) {
+
+	PolicyAFilter := func() persistence.AFilter {
+		return func(a persistence.Agreement) bool { return a.PolicyName == policyName && a.Pattern == "" }
+	}
+
+	for _, agp := range policy.AllAgreementProtocols() {
+		agreements, err := fm.db.FindAgreements([]persistence.AFilter{persistence.UnarchivedAFilter(), PolicyAFilter()}, agp)
+		if err != nil {
+			glog.Errorf(FMlogString(fmt.Sprintf("unable to find the agreements of %v, error: %v", policyName, err)))
+			continue
+		}
+
+		for _, ag := range agreements {
+			inFleet, needProps := fm.hasNode(fleets, ag.DeviceId, nil)
+			if !inFleet && needProps {
+				if _, nodePolicy, err := compcheck.GetNodePolicy(exchange.GetHTTPNodePolicyHandler(exchangeFederation.ForOrg(fm.ec, exchange.GetOrg(ag.DeviceId))), ag.DeviceId, nil); err != nil {
+					glog.Errorf(FMlogString(fmt.Sprintf("unable to get the node policy of %v, error: %v", ag.DeviceId, err)))
+					continue
+				} else if nodePolicy != nil {
+					inFleet, _ = fm.hasNode(fleets, ag.DeviceId, nodePolicy.Properties)
+				}
+			}
+
+			if !inFleet {
+				glog.V(3).Infof(FMlogString(fmt.Sprintf("node %v left the fleets of %v, cancelling agreement %v", ag.DeviceId, policyName, ag.CurrentAgreementId)))
+				fm.msgs <- events.NewABApiWorkloadUpgradeMessage(events.WORKLOAD_UPGRADE, ag.AgreementProtocol, ag.CurrentAgreementId, ag.DeviceId, ag.PolicyName)
+			}
+		}
+	}
+}
+
+// Logging function
+var FMlogString = func(v interface{}) string {
+	return fmt.Sprintf("Fleet Manager: %v", v)
+}
@@ -0,0 +1,108 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/externalpolicy"
+	_ "github.com/open-horizon/anax/externalpolicy/text_language"
+	"github.com/open-horizon/anax/policy"
+	"reflect"
+	"testing"
+)
+
+func fleetTestManager(t *testing.T, fleets ...persistence.Fleet) *FleetManager {
+	fm := NewFleetManager(nil, nil, make(chan events.Message, 10), nil)
+	for _, f := range fleets {
+		if err := f.Validate(); err != nil {
+			t.Fatalf("fleet %v should be valid, error: %v", f.Name, err)
+		}
+		fm.fleets[f.Org+"/"+f.Name] = f
+	}
+	return fm
+}
+
+func Test_qualifyFleetNames(t *testing.T) {
+	if q := qualifyFleetNames("myorg/mypolicy", nil); q != nil {
+		t.Errorf("expected no fleets, got %v", q)
+	}
+	if q := qualifyFleetNames("myorg/mypolicy", []string{"stores", "otherorg/stores"}); !reflect.DeepEqual(q, []string{"myorg/stores", "otherorg/stores"}) {
+		t.Errorf("wrong qualified fleets %v", q)
+	}
+}
+
+func Test_Fleet_Validate(t *testing.T) {
+	f := persistence.Fleet{Org: "myorg", Name: "stores", Nodes: []string{"node1", "otherorg/node2"}}
+	if err := f.Validate(); err != nil {
+		t.Errorf("fleet should be valid, error: %v", err)
+	} else if !reflect.DeepEqual(f.Nodes, []string{"myorg/node1", "otherorg/node2"}) {
+		t.Errorf("node ids not qualified: %v", f.Nodes)
+	}
+
+	bad := []persistence.Fleet{
+		{Org: "myorg", Nodes: []string{"node1"}},
+		{Org: "myorg", Name: "a/b", Nodes: []string{"node1"}},
+		{Org: "myorg", Name: "empty"},
+		{Org: "myorg", Name: "emptynode", Nodes: []string{""}},
+		{Org: "myorg", Name: "constraint", Constraints: externalpolicy.ConstraintExpression{"city =="}},
+	}
+	for _, f := range bad {
+		if err := f.Validate(); err == nil {
+			t.Errorf("fleet %v should not be valid", f)
+		}
+	}
+}
+
+func Test_FleetManager_hasNode(t *testing.T) {
+	fm := fleetTestManager(t,
+		persistence.Fleet{Org: "myorg", Name: "listed", Nodes: []string{"node1"}},
+		persistence.Fleet{Org: "myorg", Name: "berlin", Constraints: externalpolicy.ConstraintExpression{"city == Berlin"}})
+
+	berlin := externalpolicy.PropertyList{*externalpolicy.Property_Factory("city", "Berlin")}
+	paris := externalpolicy.PropertyList{*externalpolicy.Property_Factory("city", "Paris")}
+
+	if in, _ := fm.hasNode([]string{"myorg/listed"}, "myorg/node1", nil); !in {
+		t.Errorf("node1 should be in the listed fleet")
+	}
+	if in, need := fm.hasNode([]string{"myorg/listed"}, "myorg/node2", nil); in || need {
+		t.Errorf("node2 should not be in the listed fleet, got %v %v", in, need)
+	}
+	if in, need := fm.hasNode([]string{"myorg/listed", "myorg/berlin"}, "myorg/node2", nil); in || !need {
+		t.Errorf("node2 needs its properties for the berlin fleet, got %v %v", in, need)
+	}
+	if in, _ := fm.hasNode([]string{"myorg/berlin"}, "myorg/node2", berlin); !in {
+		t.Errorf("node2 should be in the berlin fleet")
+	}
+	if in, _ := fm.hasNode([]string{"myorg/berlin", "myorg/missing"}, "myorg/node2", paris); in {
+		t.Errorf("node2 should not be in the berlin fleet")
+	}
+}
+
+func Test_FleetManager_AllowsAgreement(t *testing.T) {
+	fm := fleetTestManager(t, persistence.Fleet{Org: "myorg", Name: "listed", Nodes: []string{"node1"}})
+
+	pol := policy.Policy_Factory("myorg/mypolicy")
+	businessPolManager = NewBusinessPolicyManager(make(chan events.Message, 10))
+	businessPolManager.OrgPolicies["myorg"] = map[string]*BusinessPolicyEntry{
+		"mypolicy": {Policy: pol},
+	}
+
+	if ok, _ := fm.AllowsAgreement("myorg", pol, "myorg/node2", nil); !ok {
+		t.Errorf("a policy without fleets should allow any node")
+	}
+
+	businessPolManager.OrgPolicies["myorg"]["mypolicy"].Fleets = []string{"myorg/listed"}
+	if ok, _ := fm.AllowsAgreement("myorg", pol, "myorg/node1", nil); !ok {
+		t.Errorf("node1 is in the fleet of the policy")
+	}
+	if ok, reason := fm.AllowsAgreement("myorg", pol, "myorg/node2", nil); ok || reason == "" {
+		t.Errorf("node2 is not in the fleet of the policy, got %v %v", ok, reason)
+	}
+
+	state := fm.fleetsState([]string{"myorg/listed", "myorg/missing"})
+	if state != "myorg/listed@0,myorg/missing@missing" {
+		t.Errorf("wrong fleets state %v", state)
+	}
+}
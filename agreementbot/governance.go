@@ -306,7 +306,7 @@ func (w *AgreementBotWorker) governHAPartners() {
 	//    - device != nil then get hagroup name of that device
 	//         - hagroup != "":
 	//               - check ha workload upgrade table with (org, hagroupName, workload.policyName)
-	//                     - doesn't have entry
+	//                     - doesn't have entry and no other member of the group is upgrading a workload or its agent
 	//                           => no one is upgrading, can update this workload: insert this workload in table, delete workload from db, cancel agreement if there is one
 	//         - hagroup == ""
 	//              - upgrade this workload: delete workload from db, cancel agreement if there is one
@@ -386,7 +386,16 @@ func (w *AgreementBotWorker) governHAPartners() {
 				if err != nil {
 					glog.Errorf(logString(fmt.Sprintf("error getting ha upgrading workload for %v/%v/%v, error: %v", org, haGroupName, wlu.PolicyName, err)))
 					return
-				} else if currentUpgradingWorkloadForGroup == nil {
+				} else if currentUpgradingWorkloadForGroup != nil {
+					continue
+				} else if member, err := haGroupUpgradingMember(w.db, org, haGroupName, wlu.DeviceId); err != nil {
+					glog.Errorf(logString(err.Error()))
+					return
+				} else if member != "" {
+					if glog.V(5) {
+						glog.Infof(logString(fmt.Sprintf("node %v of hagroup %v is upgrading, holding the upgrade of workload: %v.", member, haGroupName, wlu.String())))
+					}
+				} else {
 					if glog.V(5) {
 						glog.Infof(logString(fmt.Sprintf("no workload is upgrading for hagroup %v, now upgrade the workload: %v.", device.HAGroup, wlu.String())))
 					}
@@ -567,7 +576,8 @@ func (w *AgreementBotWorker) VerifyNodeHealth(ag *persistence.Agreement, cph Con
 	}
 
 	nodeHealthHandler := func(pattern string, org string, nodeOrgs []string, lastCallTime string) (*exchange.NodeHealthStatus, error) {
-		return exchange.GetNodeHealthStatus(w.Config.Collaborators.HTTPClientFactory, pattern, org, nodeOrgs, lastCallTime, w.GetExchangeURL(), w.GetExchangeId(), w.GetExchangeToken())
+		ec := exchangeFederation.ForOrg(w, org)
+		return exchange.GetNodeHealthStatus(w.Config.Collaborators.HTTPClientFactory, pattern, org, nodeOrgs, lastCallTime, ec.GetExchangeURL(), ec.GetExchangeId(), ec.GetExchangeToken())
 	}
 
 	if glog.V(5) {
@@ -614,6 +624,11 @@ func GetDevice(httpClient *http.Client, deviceId string, url string, agbotId str
 		return cachedDevice, nil
 	}
 
+	// The nodes of a federated exchange are read from that exchange.
+	if m := exchangeFederation.Member(exchange.GetOrg(deviceId)); m != nil {
+		url, agbotId, token = m.GetExchangeURL(), m.GetExchangeId(), m.GetExchangeToken()
+	}
+
 	var resp interface{}
 	resp = new(exchange.GetDevicesResponse)
 	targetURL := url + "orgs/" + exchange.GetOrg(deviceId) + "/nodes/" + exchange.GetId(deviceId)
@@ -641,7 +656,8 @@ func GetDevice(httpClient *http.Client, deviceId string, url string, agbotId str
 }
 
 // Govern the archived agreements, periodically deleting them from the database if they are old enough. The
-// age limit is defined by the agbot configuration, PurgeArchivedAgreementHours.
+// age limit is defined by the agbot configuration, PurgeArchivedAgreementHours. When the agreement history is turned
+// on, the agreements are saved in the history before they are deleted.
 func (w *AgreementBotWorker) GovernArchivedAgreements() int {
 
 	// Default to purging archived agreements an hour after they are terminated.
@@ -668,6 +684,12 @@ func (w *AgreementBotWorker) GovernArchivedAgreements() int {
 		now := time.Now().Unix()
 		if agreements, err := w.db.FindAgreements([]persistence.AFilter{persistence.ArchivedAFilter(), agedOutFilter(now, ageLimit)}, agp); err == nil {
 			for _, ag := range agreements {
+				if w.Config.AgreementBot.AgreementHistoryMonths != 0 {
+					if err := w.db.SaveAgreementHistory(&ag, agp); err != nil {
+						glog.Error(logString(fmt.Sprintf("error saving archived agreement %v to the history, error: %v", ag.CurrentAgreementId, err)))
+						continue
+					}
+				}
 				if err := w.db.DeleteAgreement(ag.CurrentAgreementId, agp); err != nil {
 					glog.Error(logString(fmt.Sprintf("error deleting archived agreement %v, error: %v", ag.CurrentAgreementId, err)))
 				} else {
@@ -682,6 +704,47 @@ func (w *AgreementBotWorker) GovernArchivedAgreements() int {
 	return 0
 }
 
+// Govern the agreement history, dropping the months that are older than the retention defined by the agbot configuration,
+// AgreementHistoryMonths. A summary of the agreements of each dropped month is kept.
+func (w *AgreementBotWorker) GovernAgreementHistory() int {
+
+	retention := w.Config.AgreementBot.AgreementHistoryMonths
+	if retention == 0 {
+		return 0
+	}
+
+	months, err := w.db.FindAgreementHistoryMonths()
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to read the agreement history months, error: %v", err)))
+		return 0
+	}
+
+	for _, month := range persistence.ExpiredHistoryMonths(months, retention, time.Now()) {
+		if summary, err := w.db.DeleteAgreementHistory(month); err != nil {
+			glog.Errorf(logString(fmt.Sprintf("unable to drop the agreement history of %v, error: %v", month, err)))
+		} else if summary != nil {
+			glog.V(3).Infof(logString(fmt.Sprintf("agreement history of %v dropped, summary: %v", month, summary)))
+		}
+	}
+	return 0
+}
+
+// The time in seconds that an expired join token is kept, so that the nodes that joined with it can still be listed.
+const JOIN_TOKEN_RETENTION_S = 86400
+
+// Govern the join tokens, deleting the ones that expired more than JOIN_TOKEN_RETENTION_S ago. The nodes that joined
+// with a deleted token are not changed.
+func (w *AgreementBotWorker) GovernJoinTokens() int {
+
+	before := uint64(time.Now().Unix()) - JOIN_TOKEN_RETENTION_S
+	if deleted, err := w.db.DeleteExpiredJoinTokens(before); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to delete the expired join tokens, error: %v", err)))
+	} else if deleted != 0 {
+		glog.V(3).Infof(logString(fmt.Sprintf("deleted %v join tokens that expired before %v", deleted, before)))
+	}
+	return 0
+}
+
 // Govern the active agreements, reporting which ones need a blockchain running so that the blockchain workers
 // can keep them running.
 func (w *AgreementBotWorker) GovernBlockchainNeeds() int {
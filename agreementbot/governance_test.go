@@ -2,7 +2,10 @@ package agreementbot
 
 import (
 	"flag"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func init() {
@@ -85,3 +88,46 @@ func Test_calc_skiptime4(t *testing.T) {
 	}
 
 }
+
+func Test_ExpiredHistoryMonths(t *testing.T) {
+	now := time.Date(2026, time.February, 15, 0, 0, 0, 0, time.UTC)
+	months := []string{"202602", "202512", "202601", "202511", "202410"}
+
+	if expired := persistence.ExpiredHistoryMonths(months, 3, now); !reflect.DeepEqual(expired, []string{"202410", "202511"}) {
+		t.Errorf("expected 202410 and 202511 to be expired, was %v", expired)
+	}
+	if expired := persistence.ExpiredHistoryMonths(months, 1, now); len(expired) != 4 {
+		t.Errorf("expected all but the current month to be expired, was %v", expired)
+	}
+}
+
+func Test_ValidateHistoryMonth(t *testing.T) {
+	if err := persistence.ValidateHistoryMonth("202610"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, month := range []string{"", "2026-10", "202613", "2026100", `2026"1`} {
+		if err := persistence.ValidateHistoryMonth(month); err == nil {
+			t.Errorf("expected an error for month %v", month)
+		}
+	}
+}
+
+func Test_AgreementHistorySummary(t *testing.T) {
+	summary := persistence.NewAgreementHistorySummary("202610")
+	summary.Add(&persistence.Agreement{Org: "org1", PolicyName: "org1/pol1", TerminatedReason: 200})
+	summary.Add(&persistence.Agreement{Org: "org1", PolicyName: "org1/pat1_org1_amd64", Pattern: "org1/pat1", TerminatedReason: 201})
+
+	previous := persistence.NewAgreementHistorySummary("202610")
+	previous.Add(&persistence.Agreement{Org: "org2", PolicyName: "org1/pol1", TerminatedReason: 200})
+	summary.Merge(previous)
+
+	if summary.Agreements != 3 {
+		t.Errorf("expected 3 agreements, was %v", summary.Agreements)
+	} else if !reflect.DeepEqual(summary.Orgs, map[string]int{"org1": 2, "org2": 1}) {
+		t.Errorf("unexpected org counts %v", summary.Orgs)
+	} else if !reflect.DeepEqual(summary.Policies, map[string]int{"org1/pol1": 2, "org1/pat1": 1}) {
+		t.Errorf("unexpected policy counts %v", summary.Policies)
+	} else if !reflect.DeepEqual(summary.Reasons, map[string]int{"200": 2, "201": 1}) {
+		t.Errorf("unexpected reason counts %v", summary.Reasons)
+	}
+}
@@ -0,0 +1,54 @@
+package agreementbot
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/exchangecommon"
+	"time"
+)
+
+// The members of an HA group are upgraded one at a time, whether it is a service upgrade made by the agbot or an agent
+// upgrade made by a node management policy, and whether the members are devices or clusters. A member starts an upgrade
+// only when no other member of the group is upgrading a service or its agent. A service upgrade is done when the new
+// agreement is finalized and the service is running on the node, an agent upgrade when the node heartbeats again after
+// the upgrade succeeded.
+
+// Returns the other member of the HA group that is upgrading a service or its agent, or an empty string when no other
+// member is upgrading. The node id is org qualified.
+func haGroupUpgradingMember(db persistence.AgbotDatabase, org string, groupName string, nodeId string) (string, error) {
+	if haWorkloads, err := db.ListHAUpgradingWorkloadsByGroupName(org, groupName); err != nil {
+		return "", fmt.Errorf("unable to read the upgrading workloads of HA group %v/%v, error %v", org, groupName, err)
+	} else {
+		for _, ha_wlu := range haWorkloads {
+			if ha_wlu.NodeId != nodeId {
+				return ha_wlu.NodeId, nil
+			}
+		}
+	}
+
+	if upgradingNode, err := persistence.GetUpgradingNodeInGroup(db, org, groupName); err != nil {
+		return "", fmt.Errorf("unable to read the upgrading node of HA group %v/%v, error %v", org, groupName, err)
+	} else if upgradingNode != nil && fmt.Sprintf("%v/%v", upgradingNode.OrgId, upgradingNode.NodeId) != nodeId {
+		return fmt.Sprintf("%v/%v", upgradingNode.OrgId, upgradingNode.NodeId), nil
+	}
+	return "", nil
+}
+
+// Returns true when the agent upgrade of an HA group member is over. A failed upgrade is over when it stops, so that
+// the other members are not held up by a node that could not upgrade. A successful upgrade is only over once the node
+// heartbeats after the upgrade completed, confirming that the upgraded agent is running.
+func haAgentUpgradeDone(status *exchangecommon.NodeManagementPolicyStatus, lastHeartbeat string) bool {
+	if status == nil || exchangecommon.IsActiveStatus(status.Status()) {
+		return false
+	} else if status.Status() != exchangecommon.STATUS_SUCCESSFUL {
+		return true
+	}
+
+	// without a completion time there is nothing to compare the heartbeat with
+	completed, err := time.Parse(time.RFC3339, status.AgentUpgrade.CompletionTime)
+	if err != nil {
+		return true
+	}
+	return lastHeartbeat != "" && cutil.TimeInSeconds(lastHeartbeat, cutil.ExchangeTimeFormat) > completed.Unix()
+}
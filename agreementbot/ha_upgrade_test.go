@@ -0,0 +1,39 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/exchangecommon"
+	"testing"
+	"time"
+)
+
+func Test_haAgentUpgradeDone(t *testing.T) {
+	completed := time.Now().Add(-10 * time.Minute)
+	status := func(s string, completion string) *exchangecommon.NodeManagementPolicyStatus {
+		return &exchangecommon.NodeManagementPolicyStatus{AgentUpgrade: &exchangecommon.AgentUpgradePolicyStatus{Status: s, CompletionTime: completion}}
+	}
+	before := completed.Add(-time.Minute).UTC().Format(cutil.ExchangeTimeFormat)
+	after := completed.Add(time.Minute).UTC().Format(cutil.ExchangeTimeFormat)
+
+	if haAgentUpgradeDone(nil, after) {
+		t.Errorf("an upgrade without status should not be done")
+	}
+	if haAgentUpgradeDone(status(exchangecommon.STATUS_INITIATED, ""), after) {
+		t.Errorf("an active upgrade should not be done")
+	}
+	if !haAgentUpgradeDone(status(exchangecommon.STATUS_FAILED_JOB, completed.Format(time.RFC3339)), before) {
+		t.Errorf("a failed upgrade should be done")
+	}
+	if haAgentUpgradeDone(status(exchangecommon.STATUS_SUCCESSFUL, completed.Format(time.RFC3339)), before) {
+		t.Errorf("a successful upgrade should not be done before the node heartbeats")
+	}
+	if haAgentUpgradeDone(status(exchangecommon.STATUS_SUCCESSFUL, completed.Format(time.RFC3339)), "") {
+		t.Errorf("a successful upgrade should not be done before the node heartbeats")
+	}
+	if !haAgentUpgradeDone(status(exchangecommon.STATUS_SUCCESSFUL, completed.Format(time.RFC3339)), after) {
+		t.Errorf("a successful upgrade should be done once the node heartbeats")
+	}
+}
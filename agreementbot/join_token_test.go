@@ -0,0 +1,97 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/externalpolicy"
+	"reflect"
+	"testing"
+)
+
+func joinTestToken() persistence.JoinToken {
+	return persistence.JoinToken{Org: "myorg", Id: "tok1", SecretHash: persistence.HashJoinTokenSecret("s3cret"), Expires: 1000}
+}
+
+func Test_JoinToken_Validate(t *testing.T) {
+	jt := joinTestToken()
+	if err := jt.Validate(); err != nil {
+		t.Errorf("join token should be valid, error: %v", err)
+	}
+
+	jt = joinTestToken()
+	jt.Expires = 0
+	if err := jt.Validate(); err == nil {
+		t.Errorf("join token without an expiry should not be valid")
+	}
+
+	jt = joinTestToken()
+	jt.MaxUses = -1
+	if err := jt.Validate(); err == nil {
+		t.Errorf("join token with a negative maxUses should not be valid")
+	}
+
+	jt = joinTestToken()
+	jt.Pattern = "myorg/mypattern"
+	jt.NodePolicy = &exchangecommon.NodePolicy{ExternalPolicy: externalpolicy.ExternalPolicy{Properties: externalpolicy.PropertyList{{Name: "location", Value: "store"}}}}
+	if err := jt.Validate(); err == nil {
+		t.Errorf("join token with a pattern and a node policy should not be valid")
+	}
+
+	jt = joinTestToken()
+	jt.NodeNameTemplate = "{{.Unknown}}"
+	if err := jt.Validate(); err == nil {
+		t.Errorf("join token with an invalid node name template should not be valid")
+	}
+}
+
+func Test_JoinToken_NodeName(t *testing.T) {
+	jt := joinTestToken()
+	if name, err := jt.NodeName("node1"); err != nil || name != "node1" {
+		t.Errorf("the node name should be the node id, got %v, error: %v", name, err)
+	}
+
+	jt.NodeNameTemplate = "{{.Org}}-store-{{.Index}}"
+	jt.Nodes = []string{"myorg/node0"}
+	if name, err := jt.NodeName("node1"); err != nil || name != "myorg-store-1" {
+		t.Errorf("wrong node name %v, error: %v", name, err)
+	}
+}
+
+func Test_JoinToken_Join(t *testing.T) {
+	jt := joinTestToken()
+	jt.MaxUses = 2
+
+	if err := jt.Join("wrong", "myorg/node1", 10); err == nil || err.Error() != "invalid join token" {
+		t.Errorf("join with a wrong secret should fail, error: %v", err)
+	}
+	if err := jt.Join("s3cret", "myorg/node1", 1001); err == nil {
+		t.Errorf("join with an expired token should fail")
+	}
+	if err := jt.Join("s3cret", "myorg/node1", 10); err != nil {
+		t.Errorf("join should succeed, error: %v", err)
+	}
+	if err := jt.Join("s3cret", "myorg/node1", 10); err == nil {
+		t.Errorf("a node should not join twice")
+	}
+	if err := jt.Join("s3cret", "myorg/node2", 10); err != nil {
+		t.Errorf("join should succeed, error: %v", err)
+	}
+	if err := jt.Join("s3cret", "myorg/node3", 10); err == nil {
+		t.Errorf("join should fail after maxUses nodes joined")
+	}
+	if !reflect.DeepEqual(jt.Nodes, []string{"myorg/node1", "myorg/node2"}) {
+		t.Errorf("wrong nodes %v", jt.Nodes)
+	}
+
+	// a node that could not be created leaves the token, so another node can join
+	jt.Leave("myorg/node1")
+	if !reflect.DeepEqual(jt.Nodes, []string{"myorg/node2"}) {
+		t.Errorf("wrong nodes after leave %v", jt.Nodes)
+	}
+	if err := jt.Join("s3cret", "myorg/node3", 10); err != nil {
+		t.Errorf("join should succeed after a node left, error: %v", err)
+	}
+}
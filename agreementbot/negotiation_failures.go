@@ -0,0 +1,156 @@
+package agreementbot
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/metrics"
+	"github.com/open-horizon/anax/policy"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The number of negotiation failures kept for each node.
+const NEGOTIATION_FAILURES_PER_NODE = 20
+
+// The failures of a node are forgotten when the node has not failed a negotiation for this many seconds.
+const NEGOTIATION_FAILURE_MAX_AGE = 24 * 60 * 60
+
+// The stages of the negotiation where the agbot can decide not to make an agreement with a node.
+const NF_STAGE_NODE_POLICY = "nodePolicy"             // The node policy was not found.
+const NF_STAGE_POLICY = "policy"                      // The node does not match the deployment policy or the service policy.
+const NF_STAGE_PATTERN = "pattern"                    // The node is not compatible with the pattern.
+const NF_STAGE_SUSPENDED = "suspended"                // The service is suspended on the node.
+const NF_STAGE_ARCH = "arch"                          // The service arch does not match the node arch.
+const NF_STAGE_NODE_TYPE = "nodeType"                 // The service has no deployment for the type of the node.
+const NF_STAGE_CLUSTER_NAMESPACE = "clusterNamespace" // The namespace of the cluster node does not fit the service.
+const NF_STAGE_USER_INPUT = "userInput"               // The required user input of the service is missing.
+const NF_STAGE_SECRETS = "secrets"                    // The secret bindings of the service are wrong.
+const NF_STAGE_SCHEDULE = "schedule"                  // The maintenance windows of the deployment policy are closed for the node.
+const NF_STAGE_FLEET = "fleet"                        // The node is not in the fleets targeted by the deployment policy.
+const NF_STAGE_RATE_LIMIT = "rateLimit"               // The agreement rate limit of the org or the policy was exceeded.
+const NF_STAGE_PLACEMENT = "placement"                // The dynamic state of the node does not satisfy the placement constraints of the deployment policy.
+const NF_STAGE_ANTI_AFFINITY = "antiAffinity"         // The site of the node already has the instances of the service allowed by the deployment policy.
+const NF_STAGE_SIGNATURE = "nodePolicySignature"      // The signature of the node policy is not valid.
+
+// A reason for not making an agreement with a node.
+type NegotiationFailure struct {
+	Time    uint64 `json:"time"`              // The time of the failure, in seconds since the epoch.
+	Policy  string `json:"policy"`            // The org qualified name of the deployment policy or pattern.
+	Service string `json:"service,omitempty"` // The org qualified url of the service, when the failure is for a service.
+	Version string `json:"version,omitempty"`
+	Arch    string `json:"arch,omitempty"`
+	Stage   string `json:"stage"`  // One of the NF_STAGE_* values.
+	Reason  string `json:"reason"` // The constraint, property or setting that failed.
+}
+
+func (f NegotiationFailure) String() string {
+	return fmt.Sprintf("Time: %v, Policy: %v, Service: %v, Version: %v, Arch: %v, Stage: %v, Reason: %v",
+		f.Time, f.Policy, f.Service, f.Version, f.Arch, f.Stage, f.Reason)
+}
+
+// The negotiation failures keeps the recent reasons the agbot did not make an agreement with each node, so that the
+// owner of a node can find out why no agreement was made without reading the agbot logs. The failures are kept in
+// memory, so each agbot only knows about the negotiations it attempted since it started.
+type NegotiationFailures struct {
+	lock      sync.Mutex
+	perNode   int
+	maxAge    uint64
+	nodes     map[string][]NegotiationFailure // The failures of each node, keyed by the org qualified node id, oldest first.
+	lastPrune uint64
+}
+
+func NewNegotiationFailures(perNode int, maxAge uint64) *NegotiationFailures {
+	return &NegotiationFailures{
+		perNode: perNode,
+		maxAge:  maxAge,
+		nodes:   make(map[string][]NegotiationFailure),
+	}
+}
+
+var negotiationFailures = NewNegotiationFailures(NEGOTIATION_FAILURES_PER_NODE, NEGOTIATION_FAILURE_MAX_AGE)
+
+// Remember a failure for a node. The oldest failure of the node is dropped when the node has too many.
+func (nf *NegotiationFailures) Record(deviceId string, failure NegotiationFailure) {
+	if failure.Time == 0 {
+		failure.Time = uint64(time.Now().Unix())
+	}
+
+	nf.lock.Lock()
+	defer nf.lock.Unlock()
+
+	failures := append(nf.nodes[deviceId], failure)
+	if len(failures) > nf.perNode {
+		failures = append([]NegotiationFailure{}, failures[len(failures)-nf.perNode:]...)
+	}
+	nf.nodes[deviceId] = failures
+
+	nf.prune(failure.Time)
+}
+
+// Forget the nodes that have not failed recently. Pruning is done at most once an hour. The caller holds the lock.
+func (nf *NegotiationFailures) prune(now uint64) {
+	if now < nf.lastPrune+3600 {
+		return
+	}
+	nf.lastPrune = now
+	for id, failures := range nf.nodes {
+		if failures[len(failures)-1].Time+nf.maxAge < now {
+			delete(nf.nodes, id)
+		}
+	}
+}
+
+// Return the recent failures of a node, newest first.
+func (nf *NegotiationFailures) Get(deviceId string) []NegotiationFailure {
+	nf.lock.Lock()
+	defer nf.lock.Unlock()
+
+	res := make([]NegotiationFailure, 0, len(nf.nodes[deviceId]))
+	for i := len(nf.nodes[deviceId]) - 1; i >= 0; i-- {
+		res = append(res, nf.nodes[deviceId][i])
+	}
+	return res
+}
+
+// Record the reason the agbot is not making an agreement with the node of the request, for the given workload. The
+// workload is nil when the failure is not specific to a service.
+func recordNegotiationFailure(wi *InitiateAgreement, workload *policy.Workload, stage string, reason string) {
+	failure := NegotiationFailure{
+		Policy: wi.ConsumerPolicy.Header.Name,
+		Stage:  stage,
+		Reason: reason,
+	}
+	if wi.ConsumerPolicy.PatternId != "" {
+		failure.Policy = wi.ConsumerPolicy.PatternId
+	}
+	if workload != nil {
+		failure.Service = cutil.FormOrgSpecUrl(workload.WorkloadURL, workload.Org)
+		failure.Version = workload.Version
+		failure.Arch = workload.Arch
+	}
+	glog.V(5).Infof(NFlogString(fmt.Sprintf("node %v: %v", wi.Device.Id, failure)))
+	negotiationFailures.Record(wi.Device.Id, failure)
+	metrics.NegotiationFailure(stage)
+}
+
+// Convert the reasons of a compatibility check, keyed by service, to a single reason.
+func compCheckReason(reasons map[string]string) string {
+	keys := make([]string, 0, len(reasons))
+	for k := range reasons {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	res := make([]string, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, fmt.Sprintf("%v: %v", k, reasons[k]))
+	}
+	return strings.Join(res, "; ")
+}
+
+// Logging function
+var NFlogString = func(v interface{}) string {
+	return fmt.Sprintf("Negotiation Failures: %v", v)
+}
@@ -0,0 +1,55 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_NegotiationFailures_Record(t *testing.T) {
+
+	nf := NewNegotiationFailures(3, 100)
+
+	for i := 1; i <= 5; i++ {
+		nf.Record("org/node1", NegotiationFailure{Time: uint64(i), Policy: "org/pol1", Stage: NF_STAGE_POLICY, Reason: fmt.Sprintf("reason%v", i)})
+	}
+	nf.Record("org/node2", NegotiationFailure{Time: 5, Policy: "org/pol1", Stage: NF_STAGE_USER_INPUT, Reason: "missing input"})
+
+	if failures := nf.Get("org/node1"); len(failures) != 3 {
+		t.Errorf("expected 3 failures, got %v", failures)
+	} else if failures[0].Reason != "reason5" || failures[2].Reason != "reason3" {
+		t.Errorf("expected the newest failures first, got %v", failures)
+	}
+
+	if failures := nf.Get("org/node2"); len(failures) != 1 || failures[0].Stage != NF_STAGE_USER_INPUT {
+		t.Errorf("expected 1 user input failure, got %v", failures)
+	}
+
+	if failures := nf.Get("org/node3"); failures == nil || len(failures) != 0 {
+		t.Errorf("expected no failures, got %v", failures)
+	}
+}
+
+func Test_NegotiationFailures_prune(t *testing.T) {
+
+	nf := NewNegotiationFailures(3, 2000)
+
+	nf.Record("org/node1", NegotiationFailure{Time: 5000, Policy: "org/pol1", Stage: NF_STAGE_POLICY})
+	nf.Record("org/node2", NegotiationFailure{Time: 8000, Policy: "org/pol1", Stage: NF_STAGE_POLICY})
+	nf.Record("org/node3", NegotiationFailure{Time: 9000, Policy: "org/pol1", Stage: NF_STAGE_POLICY})
+
+	if len(nf.Get("org/node1")) != 0 {
+		t.Errorf("expected the failures of node1 to be pruned")
+	} else if len(nf.Get("org/node2")) != 1 || len(nf.Get("org/node3")) != 1 {
+		t.Errorf("expected the failures of node2 and node3 to be kept")
+	}
+}
+
+func Test_compCheckReason(t *testing.T) {
+	r := compCheckReason(map[string]string{"org/svc2": "Incompatible", "org/svc1": "Compatible"})
+	if r != "org/svc1: Compatible; org/svc2: Incompatible" {
+		t.Errorf("unexpected reason %v", r)
+	}
+}
@@ -0,0 +1,70 @@
+package agreementbot
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/policy"
+	"os"
+	"path"
+)
+
+// Verify the signature of a node policy before it is matched against the policies. The properties in the agbot's
+// SignedNodeProperties are only trusted when the signature covers them, otherwise they are removed from the node policy
+// so that no constraint can match them. An error is returned when the node policy has a signature that is not valid,
+// the node claims properties or user input that were not set by an admin of its org and no agreement should be made.
+// The user input of the node is only read when it is signed.
+func verifyNodePolicy(agConfig *config.AGConfig, deviceId string, nodePol *policy.Policy, getUserInput func() ([]policy.UserInput, error)) error {
+	if nodePol == nil || (agConfig.NodePolicyKeyPath == "" && len(agConfig.SignedNodeProperties) == 0) {
+		return nil
+	}
+
+	trusted := []string{}
+	if policy.IsNodePolicySigned(nodePol.Properties) {
+		keyFiles, err := getNodePolicyKeyFiles(agConfig.NodePolicyKeyPath, exchange.GetOrg(deviceId))
+		if err != nil {
+			glog.Warningf(AWlogString(fmt.Sprintf("unable to read the node policy keys of org %v, the node policy signature of %v is not verified, error %v", exchange.GetOrg(deviceId), deviceId, err)))
+		} else if len(keyFiles) == 0 {
+			glog.V(3).Infof(AWlogString(fmt.Sprintf("there are no node policy keys for org %v, the node policy signature of %v is not verified", exchange.GetOrg(deviceId), deviceId)))
+		} else if trusted, err = policy.VerifyNodePolicySignature(keyFiles, deviceId, nodePol.Properties, getUserInput); err != nil {
+			return err
+		}
+	}
+
+	props := make(externalpolicy.PropertyList, 0, len(nodePol.Properties))
+	for _, prop := range nodePol.Properties {
+		if cutil.SliceContains(agConfig.SignedNodeProperties, prop.Name) && !cutil.SliceContains(trusted, prop.Name) {
+			glog.V(3).Infof(AWlogString(fmt.Sprintf("ignoring property %v of node %v, it is not signed by a key of the node's org", prop.Name, deviceId)))
+			continue
+		}
+		props = append(props, prop)
+	}
+	nodePol.Properties = props
+	return nil
+}
+
+// Returns the public key files of the org, in the org's sub-directory of the key path.
+func getNodePolicyKeyFiles(keyPath string, org string) ([]string, error) {
+	if keyPath == "" {
+		return []string{}, nil
+	}
+
+	orgPath := path.Join(keyPath, org)
+	entries, err := os.ReadDir(orgPath)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	keyFiles := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			keyFiles = append(keyFiles, path.Join(orgPath, entry.Name()))
+		}
+	}
+	return keyFiles, nil
+}
@@ -0,0 +1,100 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/policy"
+	"github.com/open-horizon/rsapss-tool/generatekeys"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_verifyNodePolicy(t *testing.T) {
+	keyPath, err := os.MkdirTemp("", "nodepolkeys")
+	if err != nil {
+		t.Fatalf("unable to create the key directory, error %v", err)
+	}
+	defer os.RemoveAll(keyPath)
+
+	// the private key is kept out of the public keys of the org
+	privDir := path.Join(keyPath, "private")
+	os.MkdirAll(privDir, 0700)
+	os.MkdirAll(path.Join(keyPath, "myorg"), 0700)
+	files, err := generatekeys.Write(privDir, 2048, "admin", "myorg", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unable to create the keys, error %v", err)
+	}
+	var privKey string
+	for _, f := range files {
+		if strings.HasSuffix(f, "private.key") {
+			privKey = f
+		} else if err := os.Rename(f, path.Join(keyPath, "myorg", path.Base(f))); err != nil {
+			t.Fatalf("unable to move the public key, error %v", err)
+		}
+	}
+
+	agConfig := &config.AGConfig{NodePolicyKeyPath: keyPath, SignedNodeProperties: []string{"certified", externalpolicy.PROP_NODE_HARDWAREID}}
+	noUserInput := func() ([]policy.UserInput, error) { return nil, nil }
+
+	extPol := exchangecommon.NodePolicy{
+		ExternalPolicy: externalpolicy.ExternalPolicy{
+			Properties: externalpolicy.PropertyList{*externalpolicy.Property_Factory("certified", true), *externalpolicy.Property_Factory(externalpolicy.PROP_NODE_HARDWAREID, "hw123"),
+				*externalpolicy.Property_Factory("purpose", "location")},
+		},
+	}
+	nodePolicy := func(extPol *exchangecommon.NodePolicy) *policy.Policy {
+		pol := policy.Policy_Factory("node")
+		pol.Properties = externalpolicy.CopyProperties(extPol.GetDeploymentPolicy().Properties)
+		return pol
+	}
+
+	// an unsigned policy loses the properties that must be signed
+	pol := nodePolicy(&extPol)
+	if err := verifyNodePolicy(agConfig, "myorg/node1", pol, noUserInput); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if pol.Properties.HasProperty("certified") || pol.Properties.HasProperty(externalpolicy.PROP_NODE_HARDWAREID) || !pol.Properties.HasProperty("purpose") {
+		t.Errorf("only the unsigned property purpose should be kept, but got %v", pol.Properties)
+	}
+
+	// the signed properties are kept, the one not covered by the signature is removed
+	signedPol := extPol.DeepCopy()
+	if err := policy.SignNodePolicy(privKey, "myorg/node1", signedPol, []string{"certified"}, nil, false); err != nil {
+		t.Fatalf("should not return error, but got %v", err)
+	}
+	pol = nodePolicy(signedPol)
+	if err := verifyNodePolicy(agConfig, "myorg/node1", pol, noUserInput); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if !pol.Properties.HasProperty("certified") || pol.Properties.HasProperty(externalpolicy.PROP_NODE_HARDWAREID) {
+		t.Errorf("only the signed property certified should be kept, but got %v", pol.Properties)
+	}
+
+	// a forged signed property rejects the node
+	forgedPol := signedPol.DeepCopy()
+	forgedPol.Properties.Add_Property(externalpolicy.Property_Factory("certified", false), true)
+	if err := verifyNodePolicy(agConfig, "myorg/node1", nodePolicy(forgedPol), noUserInput); err == nil {
+		t.Errorf("a forged signed property should have returned an error")
+	}
+
+	// the signature of a node in an org without keys cannot be verified, the properties are removed
+	pol = nodePolicy(signedPol)
+	if err := verifyNodePolicy(agConfig, "otherorg/node1", pol, noUserInput); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if pol.Properties.HasProperty("certified") {
+		t.Errorf("the property certified should be removed, but got %v", pol.Properties)
+	}
+
+	// nothing is verified when it is not configured
+	pol = nodePolicy(forgedPol)
+	if err := verifyNodePolicy(&config.AGConfig{}, "myorg/node1", pol, noUserInput); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(pol.Properties) != len(forgedPol.Properties) {
+		t.Errorf("the properties should not change, but got %v", pol.Properties)
+	}
+}
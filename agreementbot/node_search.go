@@ -33,12 +33,17 @@ type NodeSearch struct {
 	searchThread         chan bool
 	rescanLock           sync.Mutex      // The lock that protects the rescanNeeded flag. The rescanNeeded flag can be checked/changed on different threads.
 	rescanNeeded         bool            // A broad indicator that something policy or pattern related changed, and therefore the agbot needs to rescan all nodes.
+	changedNodes         map[string]bool // The nodes that changed in the exchange since the last scan, keyed by org qualified node id. Protected by the rescanLock.
+	deferredNodes        map[string]bool // The nodes whose deployment schedule window opened, searched like changed nodes. Protected by the rescanLock.
+	incrementalNodes     int             // The max number of changed nodes that are matched to the policies without searching the exchange.
 	batchSize            uint64          // The max number of nodes that this object will process in a deployment policy search result.
 	activeDeviceTimeoutS int             // The amount of time a device can go without heartbeating and still be considered active for the purposes of search.
 	retryLookBack        uint64          // The amount of time to look backward for node changes when node retries are happening.
 	policyOrder          bool            // When true, order policies most recently changed to least recently changed.
 	clearExchangeCache   bool            // When true, the exchange cache will be deleted after a seach is made with devices returned.
 	completedSearches    map[string]bool //Keeps track of the patterns/policies that have been searched to eliminate rescans until all are searched
+	shard                *NodeShard      // The agbots sharing the nodes when the nodes are sharded, nil when they are not.
+	partitionStale       uint64          // The number of seconds after which an agbot that has not heartbeated is no longer sharing the nodes.
 }
 
 func NewNodeSearch() *NodeSearch {
@@ -51,6 +56,8 @@ func NewNodeSearch() *NodeSearch {
 		rescanNeeded:        false,
 		clearExchangeCache:  false,
 		completedSearches:   make(map[string]bool),
+		changedNodes:        make(map[string]bool),
+		deferredNodes:       make(map[string]bool),
 	}
 	return ns
 }
@@ -69,6 +76,14 @@ func (n *NodeSearch) Init(db persistence.AgbotDatabase, pm *policy.PolicyManager
 	n.activeDeviceTimeoutS = cfg.AgreementBot.ActiveDeviceTimeoutS
 	n.retryLookBack = cfg.GetAgbotRetryLookBackWindow()
 	n.policyOrder = cfg.GetAgbotPolicyOrder()
+	n.incrementalNodes = cfg.GetAgbotIncrementalSearchNodes()
+
+	// When the nodes are sharded, each agbot searches for all the changed nodes and negotiates only with the ones in its shard.
+	if cfg.GetAgbotShardNodes() {
+		n.shard = NewNodeShard(db.PrimaryPartition())
+		n.partitionStale = cfg.GetPartitionStale()
+		n.refreshShardMembers()
+	}
 
 	// Set the time of the worker restart to 1 minute ago. This time is used to indicate that the node searches need to go backward in time
 	// because this agbot just restarted, and therefore could have lost search results that were in memory but the database was
@@ -111,6 +126,62 @@ func (n *NodeSearch) IsRescanNeeded() bool {
 	return n.rescanNeeded
 }
 
+// Remember the nodes that changed in the exchange, so that the next scan can negotiate with just those nodes. The input
+// is the list of org qualified node ids from a node change event, a rescan of all nodes is needed when the ids are not
+// known. This function is thread safe.
+func (n *NodeSearch) AddChangedNodes(change interface{}) {
+	nodes, ok := change.([]string)
+	if !ok || len(nodes) == 0 {
+		n.SetRescanNeeded()
+		return
+	}
+
+	n.rescanLock.Lock()
+	defer n.rescanLock.Unlock()
+	for _, id := range nodes {
+		n.changedNodes[id] = true
+	}
+}
+
+// Return the nodes that changed since the last call and forget them. This function is thread safe.
+func (n *NodeSearch) takeChangedNodes() []string {
+	n.rescanLock.Lock()
+	defer n.rescanLock.Unlock()
+
+	nodes := make([]string, 0, len(n.changedNodes))
+	for id := range n.changedNodes {
+		nodes = append(nodes, id)
+	}
+	n.changedNodes = make(map[string]bool)
+	return nodes
+}
+
+// Remember the nodes that were not given an agreement because the maintenance window of a deployment policy was closed,
+// and whose window has now opened. They have not changed in the exchange, so they are always matched to the policies
+// by the next scan, however many nodes changed. This function is thread safe.
+func (n *NodeSearch) AddDeferredNodes(nodes []string) {
+	n.rescanLock.Lock()
+	defer n.rescanLock.Unlock()
+	for _, id := range nodes {
+		n.deferredNodes[id] = true
+	}
+}
+
+// Return the deferred nodes and forget them. This function is thread safe.
+func (n *NodeSearch) takeDeferredNodes() []string {
+	n.rescanLock.Lock()
+	defer n.rescanLock.Unlock()
+
+	nodes := make([]string, 0, len(n.deferredNodes))
+	for id := range n.deferredNodes {
+		if !n.changedNodes[id] {
+			nodes = append(nodes, id)
+		}
+	}
+	n.deferredNodes = make(map[string]bool)
+	return nodes
+}
+
 // This is the main driving function in this object. It will initiate a node scan if needed, using an exiting search session or obtain a new one if needed.
 // The actual processing of a node scan for all policies and patterns is actually performed on a sub-thread. This function also also handles updating
 // itself if a previous scan has completed since the last time this method was called.
@@ -129,27 +200,48 @@ func (n *NodeSearch) Scan() {
 	// Now check to see if a new scan is needed. This function will periodically scan all nodes, to ensure that missed change events are eventually acted on.
 	// If there is no rescan needed but it's been a while since the last full scan, then do a full scan anyway.
 	// A full rescan uses its own changedSince time so that the full rescans overlap each other.
+	// The full rescan also clears the exchange cache, in case a change was missed.
 	if n.lastSearchComplete && !n.IsRescanNeeded() && (n.fullRescanIntervalS != 0 && (uint64(time.Now().Unix())-n.lastSearchTime) >= n.fullRescanIntervalS) {
 		n.lastSearchTime = uint64(time.Now().Unix())
 		glog.V(3).Infof(AWlogString("Polling Exchange (full rescan)"))
 		n.lastSearchComplete = false
-		go n.findAndMakeAgreements()
+		n.takeChangedNodes()
+		go n.findAndMakeAgreements(true)
 	}
 
-	// If changes in the system have occurred such that a rescan is needed, start a scan now.
+	// If only nodes have changed, and not too many of them, match the changed nodes to the patterns and deployment policies
+	// instead of searching the exchange for each pattern and deployment policy. When too many nodes have changed, the searches
+	// are cheaper.
+	if n.lastSearchComplete && !n.IsRescanNeeded() && ((uint64(time.Now().Unix()) - n.lastSearchTime) >= uint64(n.nextScanIntervalS)) {
+		deferred := n.takeDeferredNodes()
+		if nodes := n.takeChangedNodes(); len(nodes) > n.incrementalNodes {
+			glog.V(3).Infof(AWlogString(fmt.Sprintf("%v nodes changed, searching all policies", len(nodes))))
+			n.SetRescanNeeded()
+			n.AddDeferredNodes(deferred)
+		} else if nodes = append(nodes, deferred...); len(nodes) != 0 {
+			n.lastSearchTime = uint64(time.Now().Unix())
+			glog.V(3).Infof(AWlogString(fmt.Sprintf("Polling Exchange for %v changed nodes", len(nodes))))
+			n.lastSearchComplete = false
+			go n.searchChangedNodes(nodes)
+		}
+	}
+
+	// If changes in the system have occurred such that a rescan is needed, start a scan now. The changed nodes are found
+	// by the searches.
 	if n.lastSearchComplete && n.IsRescanNeeded() && ((uint64(time.Now().Unix()) - n.lastSearchTime) >= uint64(n.nextScanIntervalS)) {
 		n.lastSearchTime = uint64(time.Now().Unix())
 		glog.V(3).Infof(AWlogString("Polling Exchange"))
 		n.lastSearchComplete = false
 		n.UnsetRescanNeeded()
-		go n.findAndMakeAgreements()
+		n.takeChangedNodes()
+		go n.findAndMakeAgreements(false)
 	}
 
 }
 
 // Go through all the patterns and deployment polices and make agreements. This function runs on a sub-thread of the agbot
 // main thread so that the main thread can continue handling inflight agreements and changes.
-func (n *NodeSearch) findAndMakeAgreements() {
+func (n *NodeSearch) findAndMakeAgreements(fullRescan bool) {
 
 	if err := n.db.DumpSearchSessions(); err != nil {
 		glog.Errorf(AWlogString(fmt.Sprintf("unable to dump search session records, error: %v", err)))
@@ -159,9 +251,10 @@ func (n *NodeSearch) findAndMakeAgreements() {
 	// time and the same search session.
 	searchError := false
 
-	// allow clearing the cache for all the exchange resources, the searchNodesAndMakeAgreements
-	// function will clear the cache and set it false after it finds devices to make agreements.
-	n.clearExchangeCache = true
+	// On a full rescan, allow clearing the cache for all the exchange resources, the makeAgreements function will clear
+	// the cache and set it false after it finds devices to make agreements. Otherwise the cached nodes, node policies and
+	// services are kept, the changes worker removes them from the cache when they change in the exchange.
+	n.clearExchangeCache = fullRescan
 
 	// Get a list of all the orgs this agbot is serving.
 	allOrgs := n.pm.GetAllPolicyOrgs()
@@ -278,6 +371,17 @@ func (n *NodeSearch) searchNodesAndMakeAgreements(consumerPolicy *policy.Policy,
 
 	endOfResults := true
 
+	// The nodes of a pattern are searched a page at a time, the agreements are made with each page as it is returned.
+	if consumerPolicy.PatternId != "" {
+		err := n.searchPatternNodes(consumerPolicy, org, func(devices *[]exchange.SearchResultDevice) {
+			n.makeAgreements(consumerPolicy, org, polName, devices)
+		})
+		if err != nil {
+			glog.Errorf(AWlogString(fmt.Sprintf("received error searching for %v, error: %v", consumerPolicy, err)))
+		}
+		return endOfResults, err
+	}
+
 	if devices, err := n.searchExchange(consumerPolicy, org, polName, polLastUpdateTime); err != nil {
 		glog.Errorf(AWlogString(fmt.Sprintf("received error searching for %v, error: %v", consumerPolicy, err)))
 		return endOfResults, err
@@ -289,88 +393,188 @@ func (n *NodeSearch) searchNodesAndMakeAgreements(consumerPolicy *policy.Policy,
 			endOfResults = false
 		}
 
-		// Get all the agreements for this policy that are still active.
-		pendingAgreementFilter := func() persistence.AFilter {
-			return func(a persistence.Agreement) bool {
-				return a.PolicyName == consumerPolicy.Header.Name && a.AgreementTimedout == 0
+		n.makeAgreements(consumerPolicy, org, polName, devices)
+	}
+
+	return endOfResults, nil
+
+}
+
+// A pattern or deployment policy served by the agbot, with the node orgs it is served for and the changed nodes that
+// match it.
+type changedNodeMatch struct {
+	pol      policy.Policy
+	org      string
+	polName  string // The name of the deployment policy, empty for a pattern.
+	nodeOrgs []string
+	devices  []exchange.SearchResultDevice
+}
+
+// Return true if the node is one that the exchange search for the policy could return. The nodes using a pattern match
+// the policies generated from the pattern for their architecture, when they have heartbeated recently. The other nodes
+// match the deployment policies. The agreement worker checks the compatibility of the node with the policy.
+func (m *changedNodeMatch) matches(nodeOrg string, dev *exchange.Device, activeDeviceTimeoutS int, now int64) bool {
+	if !cutil.SliceContains(m.nodeOrgs, nodeOrg) || dev.PublicKey == "" {
+		return false
+	} else if m.pol.PatternId == "" {
+		return dev.Pattern == ""
+	} else if m.pol.PatternId != dev.Pattern {
+		return false
+	} else if arch := patternPolicyArch(&m.pol); arch != "" && arch != dev.Arch {
+		return false
+	} else if activeDeviceTimeoutS > 0 && dev.LastHeartbeat != "" && cutil.TimeInSeconds(dev.LastHeartbeat, cutil.ExchangeTimeFormat)+int64(activeDeviceTimeoutS) < now {
+		return false
+	}
+	return true
+}
+
+// Make agreements with the nodes that changed in the exchange since the last scan. Instead of searching the exchange for
+// each pattern and deployment policy, each changed node is read from the exchange, or from the exchange cache, and matched
+// to the patterns and deployment policies served by the agbot. The matching nodes are then handled like the nodes returned
+// by a search. Like findAndMakeAgreements, this function runs on a sub-thread of the agbot main thread.
+func (n *NodeSearch) searchChangedNodes(nodeIds []string) {
+
+	// Gather the patterns and deployment policies, with the node orgs they are served for.
+	matches := make([]*changedNodeMatch, 0)
+	for _, org := range n.pm.GetAllPolicyOrgs() {
+		for _, pol := range n.getOrderedPolicies(org) {
+			m := &changedNodeMatch{pol: pol, org: org}
+			if pol.PatternId != "" {
+				m.nodeOrgs = patternManager.GetServedNodeOrgs(org, exchange.GetId(pol.PatternId))
+			} else if pBE := businessPolManager.GetBusinessPolicyEntry(org, &pol); pBE != nil {
+				_, m.polName = cutil.SplitOrgSpecUrl(pol.Header.Name)
+				m.nodeOrgs = businessPolManager.GetServedNodeOrgs(org, m.polName)
+			}
+			if len(m.nodeOrgs) != 0 {
+				matches = append(matches, m)
 			}
 		}
+	}
 
-		ags := make(map[string][]persistence.Agreement)
+	now := time.Now().Unix()
+	for _, id := range nodeIds {
+		dev, err := exchange.GetHTTPDeviceHandler(exchangeFederation.ForOrg(n.ec, exchange.GetOrg(id)))(id, "")
+		if err != nil {
+			// The policy searches will find the node if it is still there.
+			glog.Errorf(AWlogString(fmt.Sprintf("unable to read changed node %v, searching all policies, error: %v", id, err)))
+			n.SetRescanNeeded()
+			continue
+		}
 
-		// The agreements with this policy could be part of any supported agreement protocol.
-		for _, agp := range policy.AllAgreementProtocols() {
-			// Find all agreements that are in progress. They might be waiting for a reply or not yet finalized.
-			// TODO: To support more than 1 agreement (maxagreements > 1) with this device for this policy, we need to adjust this logic.
-			if agreements, err := n.db.FindAgreements([]persistence.AFilter{persistence.UnarchivedAFilter(), pendingAgreementFilter()}, agp); err != nil {
-				glog.Errorf(AWlogString(fmt.Sprintf("received error trying to find pending agreements for protocol %v: %v", agp, err)))
-			} else {
-				ags[agp] = agreements
+		for _, m := range matches {
+			if m.matches(exchange.GetOrg(id), dev, n.activeDeviceTimeoutS, now) {
+				m.devices = append(m.devices, exchange.SearchResultDevice{Id: id, NodeType: dev.NodeType, PublicKey: dev.PublicKey})
 			}
 		}
+	}
 
-		// For each Scan(), clear the cache only once when there are devices returned from the search api.
-		if n.clearExchangeCache && len(*devices) != 0 {
-			glog.V(5).Infof("Clearing cache for all resources.")
-			exchange.ClearAllResourceCache()
-			n.clearExchangeCache = false
+	for _, m := range matches {
+		if len(m.devices) != 0 {
+			glog.V(3).Infof(AWlogString(fmt.Sprintf("found %v changed nodes for %v.", len(m.devices), m.pol.Header.Name)))
+			n.makeAgreements(&m.pol, m.org, m.polName, &m.devices)
 		}
+	}
 
-		for _, dev := range *devices {
-
-			glog.V(3).Infof(AWlogString(fmt.Sprintf("picked up %v for policy %v.", dev.ShortString(), consumerPolicy.Header.Name)))
-			glog.V(5).Infof(AWlogString(fmt.Sprintf("picked up %v", dev)))
+	n.searchThread <- true
+}
 
-			// Check for agreements already in progress with this device
-			if found := n.alreadyMakingAgreementWith(&dev, consumerPolicy, ags); found {
-				glog.V(5).Infof(AWlogString(fmt.Sprintf("skipping device id %v, agreement attempt already in progress with %v", dev.Id, consumerPolicy.Header.Name)))
-				continue
+// Queue an agreement attempt with each of the devices for the given policy, skipping the devices that are in the shard
+// of another agbot and the ones that already have an agreement in progress with the policy.
+func (n *NodeSearch) makeAgreements(consumerPolicy *policy.Policy, org string, polName string, devices *[]exchange.SearchResultDevice) {
+	// Only negotiate with the nodes in this agbot's shard, the other agbots negotiate with the rest.
+	if n.shard != nil {
+		owned := make([]exchange.SearchResultDevice, 0, len(*devices))
+		for _, dev := range *devices {
+			if n.shard.Owns(dev.Id) {
+				owned = append(owned, dev)
+			} else {
+				glog.V(5).Infof(AWlogString(fmt.Sprintf("skipping device id %v, it is in the shard of partition %v", dev.Id, n.shard.Owner(dev.Id))))
 			}
+		}
+		devices = &owned
+	}
 
-			// If the device is not ready to make agreements yet, then skip it.
-			if dev.PublicKey == "" {
-				glog.V(5).Infof(AWlogString(fmt.Sprintf("skipping device id %v, node is not ready to exchange messages", dev.Id)))
-				continue
-			}
+	// Get all the agreements for this policy that are still active.
+	pendingAgreementFilter := func() persistence.AFilter {
+		return func(a persistence.Agreement) bool {
+			return a.PolicyName == consumerPolicy.Header.Name && a.AgreementTimedout == 0
+		}
+	}
 
-			producerPolicy := policy.Policy_Factory(consumerPolicy.Header.Name)
-
-			// Get the cached service policies from the business policy manager. The returned value
-			// is a map keyed by the service id.
-			// There could be many service versions defined in a business policy.
-			// The policy manager only caches the ones that are used by an old agreement for this business policy.
-			// The cached ones may not be what the new agreement will use. If the new agreement chooses a
-			// new service version, then the new service policy will be put into the cache.
-			svcPolicies := make(map[string]externalpolicy.ExternalPolicy, 0)
-			if consumerPolicy.PatternId == "" {
-				svcPolicies = businessPolManager.GetServicePoliciesForPolicy(org, polName)
-			}
+	ags := make(map[string][]persistence.Agreement)
 
-			// Select a worker pool based on the agreement protocol that will be used. This is decided by the
-			// consumer policy.
-			protocol := policy.Select_Protocol(producerPolicy, consumerPolicy)
-			cmd := NewMakeAgreementCommand(*producerPolicy, *consumerPolicy, org, polName, dev, svcPolicies)
-
-			bcType, bcName, bcOrg := producerPolicy.RequiresKnownBC(protocol)
-
-			if !n.ph.Has(protocol) {
-				glog.Errorf(AWlogString(fmt.Sprintf("unable to find protocol handler for %v.", protocol)))
-			} else if bcType != "" && !n.ph.Get(protocol).IsBlockchainWritable(bcType, bcName, bcOrg) {
-				// Get that blockchain running if it isn't up.
-				glog.V(5).Infof(AWlogString(fmt.Sprintf("skipping device id %v, requires blockchain %v %v %v that isnt ready yet.", dev.Id, bcType, bcName, bcOrg)))
-				n.msgs <- events.NewNewBCContainerMessage(events.NEW_BC_CLIENT, bcType, bcName, bcOrg, n.ec.GetExchangeURL(), n.ec.GetExchangeId(), n.ec.GetExchangeToken())
-				continue
-			} else if !n.ph.Get(protocol).AcceptCommand(cmd) {
-				glog.Errorf(AWlogString(fmt.Sprintf("protocol handler for %v not accepting new agreement commands.", protocol)))
-			} else {
-				n.ph.Get(protocol).HandleMakeAgreement(cmd, n.ph.Get(protocol))
-				glog.V(5).Infof(AWlogString(fmt.Sprintf("queued agreement attempt for policy %v and node %v using protocol %v", consumerPolicy.Header.Name, dev.Id, protocol)))
-			}
+	// The agreements with this policy could be part of any supported agreement protocol.
+	for _, agp := range policy.AllAgreementProtocols() {
+		// Find all agreements that are in progress. They might be waiting for a reply or not yet finalized.
+		// TODO: To support more than 1 agreement (maxagreements > 1) with this device for this policy, we need to adjust this logic.
+		if agreements, err := n.db.FindAgreements([]persistence.AFilter{persistence.UnarchivedAFilter(), pendingAgreementFilter()}, agp); err != nil {
+			glog.Errorf(AWlogString(fmt.Sprintf("received error trying to find pending agreements for protocol %v: %v", agp, err)))
+		} else {
+			ags[agp] = agreements
 		}
+	}
 
+	// For each Scan(), clear the cache only once when there are devices returned from the search api.
+	if n.clearExchangeCache && len(*devices) != 0 {
+		glog.V(5).Infof("Clearing cache for all resources.")
+		exchange.ClearAllResourceCache()
+		n.clearExchangeCache = false
 	}
 
-	return endOfResults, nil
+	// Attempt the agreements with the highest scoring nodes first.
+	ranked := rankDevices(n.db, n.ec, org, consumerPolicy, polName, *devices)
+
+	for _, dev := range ranked {
+
+		glog.V(3).Infof(AWlogString(fmt.Sprintf("picked up %v for policy %v.", dev.ShortString(), consumerPolicy.Header.Name)))
+		glog.V(5).Infof(AWlogString(fmt.Sprintf("picked up %v", dev)))
+
+		// Check for agreements already in progress with this device
+		if found := n.alreadyMakingAgreementWith(&dev, consumerPolicy, ags); found {
+			glog.V(5).Infof(AWlogString(fmt.Sprintf("skipping device id %v, agreement attempt already in progress with %v", dev.Id, consumerPolicy.Header.Name)))
+			continue
+		}
+
+		// If the device is not ready to make agreements yet, then skip it.
+		if dev.PublicKey == "" {
+			glog.V(5).Infof(AWlogString(fmt.Sprintf("skipping device id %v, node is not ready to exchange messages", dev.Id)))
+			continue
+		}
+
+		producerPolicy := policy.Policy_Factory(consumerPolicy.Header.Name)
+
+		// Get the cached service policies from the business policy manager. The returned value
+		// is a map keyed by the service id.
+		// There could be many service versions defined in a business policy.
+		// The policy manager only caches the ones that are used by an old agreement for this business policy.
+		// The cached ones may not be what the new agreement will use. If the new agreement chooses a
+		// new service version, then the new service policy will be put into the cache.
+		svcPolicies := make(map[string]externalpolicy.ExternalPolicy, 0)
+		if consumerPolicy.PatternId == "" {
+			svcPolicies = businessPolManager.GetServicePoliciesForPolicy(org, polName)
+		}
+
+		// Select a worker pool based on the agreement protocol that will be used. This is decided by the
+		// consumer policy.
+		protocol := policy.Select_Protocol(producerPolicy, consumerPolicy)
+		cmd := NewMakeAgreementCommand(*producerPolicy, *consumerPolicy, org, polName, dev, svcPolicies)
+
+		bcType, bcName, bcOrg := producerPolicy.RequiresKnownBC(protocol)
+
+		if !n.ph.Has(protocol) {
+			glog.Errorf(AWlogString(fmt.Sprintf("unable to find protocol handler for %v.", protocol)))
+		} else if bcType != "" && !n.ph.Get(protocol).IsBlockchainWritable(bcType, bcName, bcOrg) {
+			// Get that blockchain running if it isn't up.
+			glog.V(5).Infof(AWlogString(fmt.Sprintf("skipping device id %v, requires blockchain %v %v %v that isnt ready yet.", dev.Id, bcType, bcName, bcOrg)))
+			n.msgs <- events.NewNewBCContainerMessage(events.NEW_BC_CLIENT, bcType, bcName, bcOrg, n.ec.GetExchangeURL(), n.ec.GetExchangeId(), n.ec.GetExchangeToken())
+			continue
+		} else if !n.ph.Get(protocol).AcceptCommand(cmd) {
+			glog.Errorf(AWlogString(fmt.Sprintf("protocol handler for %v not accepting new agreement commands.", protocol)))
+		} else {
+			n.ph.Get(protocol).HandleMakeAgreement(cmd, n.ph.Get(protocol))
+			glog.V(5).Infof(AWlogString(fmt.Sprintf("queued agreement attempt for policy %v and node %v using protocol %v", consumerPolicy.Header.Name, dev.Id, protocol)))
+		}
+	}
 
 }
 
@@ -397,158 +601,228 @@ func (n *NodeSearch) alreadyMakingAgreementWith(dev *exchange.SearchResultDevice
 
 }
 
-// Search the exchange for devices to make agreements with. The system should be operating such that devices are
-// not returned from the exchange (for any given set of search criteria) once an agreement which includes those
-// criteria has been reached. This prevents the agbot from continually sending proposals to devices that are
-// already in an agreement.
-//
-// There are 2 ways to search the exchange; (a) by pattern and service or workload URL, or (b) by business policy.
-// If the agbot is working with a policy file that was generated from a pattern, then it will do searches
-// by pattern. If the agbot is working with a business policy, then it will do searches by the business policy.
-func (n *NodeSearch) searchExchange(pol *policy.Policy, polOrg string, polName string, polLastUpdateTime uint64) (*[]exchange.SearchResultDevice, error) {
+// Search the exchange for the nodes of a pattern, calling handle with each page of nodes. The exchange returns all the
+// nodes that are eligible for the pattern, the pages keep a large result set from being read in a single call. Since the
+// nodes that make agreements drop out of the result set while it is paged, some nodes can be skipped, they are found by
+// the next search.
+func (n *NodeSearch) searchPatternNodes(pol *policy.Policy, polOrg string, handle func(devices *[]exchange.SearchResultDevice)) error {
+
+	// Get a list of node orgs that the agbot is serving for this pattern.
+	nodeOrgs := patternManager.GetServedNodeOrgs(polOrg, exchange.GetId(pol.PatternId))
+	if len(nodeOrgs) == 0 {
+		glog.V(3).Infof(AWlogString(fmt.Sprintf("Policy file for pattern %v exists but currently the agbot is not serving this policy for any organizations.", pol.PatternId)))
+		return nil
+	}
 
-	// If it is a pattern based policy, search by workload URL and pattern.
-	if pol.PatternId != "" {
-		// Get a list of node orgs that the agbot is serving for this pattern.
-		nodeOrgs := patternManager.GetServedNodeOrgs(polOrg, exchange.GetId(pol.PatternId))
-		if len(nodeOrgs) == 0 {
-			glog.V(3).Infof(AWlogString(fmt.Sprintf("Policy file for pattern %v exists but currently the agbot is not serving this policy for any organizations.", pol.PatternId)))
-			empty := make([]exchange.SearchResultDevice, 0, 0)
-			return &empty, nil
+	// Setup the search request body
+	ser := exchange.CreateSearchPatternRequest()
+	ser.SecondsStale = n.activeDeviceTimeoutS
+	ser.NodeOrgIds = nodeOrgs
+	ser.Arch = patternPolicyArch(pol)
+	ser.ServiceURL = cutil.FormOrgSpecUrl(pol.Workloads[0].WorkloadURL, pol.Workloads[0].Org)
+	ser.NumEntries = int(n.batchSize)
+
+	// An exchange that does not page the search returns all the nodes on every call, the search ends when a page has
+	// no node that was not seen before.
+	seen := make(map[string]bool)
+	for {
+		glog.V(3).Infof(AWlogString(fmt.Sprintf("searching %v with %v", pol.PatternId, ser)))
+
+		// Invoke the exchange
+		devs, err := exchange.GetHTTPAgbotPatternNodeSearchHandler(exchangeFederation.ForOrg(n.ec, polOrg))(ser, polOrg, pol.PatternId)
+		if err != nil {
+			return err
 		}
+		glog.V(3).Infof(AWlogString(fmt.Sprintf("found %v devices in exchange.", len(*devs))))
 
-		arch := ""
-		// Architectures supported by the horizon agents
-		supported_architectures := []string{"amd64", "arm", "arm64", "ppc64le"}
-		// pol.Header.Name should be something like Helloworld_ibm.helloworld_ieam-roks-scale_amd64 (or _arm or _arm64 or _ppc64le)
-		// Search list of supported architectures to find a match. If there isn't a match for some reason, the architecture passed in will be ""
-		// which the Exchange can handle by not filtering the nodes by architecture and the agbot will determine if they match
-		for _, pattern_arch := range supported_architectures {
-			if strings.HasSuffix(pol.Header.Name, pattern_arch) {
-				arch = pattern_arch
-				break
+		newDevs := make([]exchange.SearchResultDevice, 0, len(*devs))
+		for _, dev := range *devs {
+			if !seen[dev.Id] {
+				seen[dev.Id] = true
+				newDevs = append(newDevs, dev)
 			}
 		}
+		if len(newDevs) != 0 {
+			handle(&newDevs)
+		}
 
-		// Setup the search request body
-		ser := exchange.CreateSearchPatternRequest()
-		ser.SecondsStale = n.activeDeviceTimeoutS
-		ser.NodeOrgIds = nodeOrgs
-		ser.Arch = arch
-		ser.ServiceURL = cutil.FormOrgSpecUrl(pol.Workloads[0].WorkloadURL, pol.Workloads[0].Org)
-
-		glog.V(3).Infof(AWlogString(fmt.Sprintf("searching %v with %v", pol.PatternId, ser)))
-
-		// Invoke the exchange
-		devs, err := exchange.GetHTTPAgbotPatternNodeSearchHandler(n.ec)(ser, polOrg, pol.PatternId)
-		if err == nil {
-			glog.V(3).Infof(AWlogString(fmt.Sprintf("found %v devices in exchange.", len(*devs))))
+		if ser.NumEntries == 0 || len(*devs) < ser.NumEntries || len(newDevs) == 0 {
+			return nil
 		}
-		return devs, err
+		ser.StartIndex += len(*devs)
+	}
+}
 
-	} else {
+// Search the exchange for devices to make agreements with. The system should be operating such that devices are
+// not returned from the exchange (for any given set of search criteria) once an agreement which includes those
+// criteria has been reached. This prevents the agbot from continually sending proposals to devices that are
+// already in an agreement.
+//
+// This function searches the exchange by business policy, the policies generated from a pattern are searched by
+// pattern and service URL with searchPatternNodes.
+func (n *NodeSearch) searchExchange(pol *policy.Policy, polOrg string, polName string, polLastUpdateTime uint64) (*[]exchange.SearchResultDevice, error) {
 
-		// Current timestamp to be saved as the next agreement making cycle start time. This time is used to ensure that no changes are
-		// missed. This will cause the next search to look for changes nodes that overlap in time with the search that is about to be
-		// initiated. That's one way to ensure that changes aren't missed.
-		currentSearchStart := uint64(time.Now().Unix()) - 1
-
-		// Get the current changedSince time from the DB. The changedSince time is coordinated across all agbot instances.
-		// It indicates to the exchange that it should only return nodes that have changed since the given time. This time
-		// could be updated in the DB immediately after this point, which will result in the current searches using a
-		// changedSince value that has already been used. While this will result in extra work for the agbot, it should
-		// not cause errors in the system as a whole.
-
-		// Begin or continue a node search session. The exchange will return nodes in pages, i.e. a subset of all possible results to be
-		// processed by this Agbot. The Exchange uses the search session number as a key to know how much of the total result
-		// set has already been returned. This allows the exchange to return alternating pages of the result set to different
-		// Agbot instances.
-		searchSession, changedSince, err := n.db.ObtainSearchSession(pol.Header.Name)
-		if err != nil {
-			glog.Errorf(AWlogString(fmt.Sprintf("unable to start a new search session for %v, error: %v", pol.Header.Name, err)))
-			return nil, err
-		}
+	// Current timestamp to be saved as the next agreement making cycle start time. This time is used to ensure that no changes are
+	// missed. This will cause the next search to look for changes nodes that overlap in time with the search that is about to be
+	// initiated. That's one way to ensure that changes aren't missed.
+	currentSearchStart := uint64(time.Now().Unix()) - 1
+
+	// Get the current changedSince time from the DB. The changedSince time is coordinated across all agbot instances.
+	// It indicates to the exchange that it should only return nodes that have changed since the given time. This time
+	// could be updated in the DB immediately after this point, which will result in the current searches using a
+	// changedSince value that has already been used. While this will result in extra work for the agbot, it should
+	// not cause errors in the system as a whole.
+
+	// Begin or continue a node search session. The exchange will return nodes in pages, i.e. a subset of all possible results to be
+	// processed by this Agbot. The Exchange uses the search session number as a key to know how much of the total result
+	// set has already been returned. This allows the exchange to return alternating pages of the result set to different
+	// Agbot instances.
+	searchSession, changedSince, err := n.db.ObtainSearchSession(n.sessionKey(pol.Header.Name))
+	if err != nil {
+		glog.Errorf(AWlogString(fmt.Sprintf("unable to start a new search session for %v, error: %v", pol.Header.Name, err)))
+		return nil, err
+	}
 
-		// Get a list of node orgs that the agbot is serving for this business policy.
-		nodeOrgs := businessPolManager.GetServedNodeOrgs(polOrg, polName)
-		if len(nodeOrgs) == 0 {
-			glog.V(3).Infof(AWlogString(fmt.Sprintf("Business policy %v exists but currently the agbot is not serving this policy for any organizations.", pol.Header.Name)))
-			empty := make([]exchange.SearchResultDevice, 0, 0)
-			return &empty, nil
-		}
+	// Get a list of node orgs that the agbot is serving for this business policy.
+	nodeOrgs := businessPolManager.GetServedNodeOrgs(polOrg, polName)
+	if len(nodeOrgs) == 0 {
+		glog.V(3).Infof(AWlogString(fmt.Sprintf("Business policy %v exists but currently the agbot is not serving this policy for any organizations.", pol.Header.Name)))
+		empty := make([]exchange.SearchResultDevice, 0, 0)
+		return &empty, nil
+	}
 
-		// To make the search more efficient, the exchange only searches the nodes that have been changed since bp_check_time.
-		// If the business policy has changed since the last search cycle, then set changedSince to zero so that all nodes
-		// will be checked again. One or more of them might have become compatible when the policy changed.
-		bp_check_time := changedSince
-		if polLastUpdateTime > changedSince {
-			bp_check_time = 0
-		}
+	// To make the search more efficient, the exchange only searches the nodes that have been changed since bp_check_time.
+	// If the business policy has changed since the last search cycle, then set changedSince to zero so that all nodes
+	// will be checked again. One or more of them might have become compatible when the policy changed.
+	bp_check_time := changedSince
+	if polLastUpdateTime > changedSince {
+		bp_check_time = 0
+	}
 
-		// Setup the search request body
-		ser := exchange.SearchExchBusinessPolRequest{
-			NodeOrgIds:   nodeOrgs,
-			ChangedSince: bp_check_time,
-			Session:      searchSession,
-			NumEntries:   n.batchSize,
-		}
+	// Setup the search request body
+	ser := exchange.SearchExchBusinessPolRequest{
+		NodeOrgIds:   nodeOrgs,
+		ChangedSince: bp_check_time,
+		Session:      searchSession,
+		NumEntries:   n.batchSize,
+	}
 
-		// The search for nodes exploits pagination on the exchange, which means that the search API returns a "page" of results
-		// on each call, not the entire result set. To manage the page state, the agbot uses a coordinated session token
-		// to indicate that it wants the next page of results for a given session. If that session gets out of sync between
-		// the agbot and the exchange, the safest way to recover is for the agbot to use the session that the exchange
-		// is using and retry the search. This will happen until the exchange returns the last page of results, at which
-		// point the agbot can resume using the session that it wants to use. It is important to understand that the agbot
-		// keeps a single session token for all searches using a given policy and so does the exchange, so in theory
-		// it is possible that the exchange could have different sessions for different policies, but that should never get out
-		// of sync with the agbot. The error handling in this loop is intended to compensate if the agbot session ever gets out
-		// of sync with the exchange session.
-		devs := make([]exchange.SearchResultDevice, 0, 0)
-		for {
-
-			glog.V(3).Infof(AWlogString(fmt.Sprintf("searching %v with %v", pol.Header.Name, ser)))
-
-			// Invoke the exchange and return the device list or any hard errors that occur.
-			resp, err := exchange.GetHTTPAgbotPolicyNodeSearchHandler(n.ec)(&ser, polOrg, polName)
-			if err != nil {
-				return nil, err
-			} else if resp.Session != "" {
-				glog.Errorf(AWlogString(fmt.Sprintf("for %v search session is out of sync: %v", pol.Header.Name, resp)))
-				// To get the agbot back in sync, we will need to use the exchange session until it is exhausted.
-				ser.Session = resp.Session
-				searchSession = resp.Session
-				n.SetRescanNeeded()
-				continue
-			} else {
-				// The call was successful, update the DB if we got the last page.  If the exchange returns the number of nodes
-				// requested, then assume there are more nodes in the search result set that weren't returned.
-				if uint64(len(resp.Devices)) != n.batchSize {
-					// Update the DB with the new changedSince value, indicating that the scan is complete. This update also
-					// ends the current search session for this policy.
-					glog.V(3).Infof(AWlogString(fmt.Sprintf("for %v ending Session: %v", pol.Header.Name, searchSession)))
-					if sessionEnded, err := n.db.UpdateSearchSessionChangedSince(changedSince, currentSearchStart, pol.Header.Name); err != nil {
-						glog.Errorf(AWlogString(fmt.Sprintf("unable to update search session changed since, error: %v", err)))
-					} else {
-						if sessionEnded {
-							glog.V(3).Infof(AWlogString(fmt.Sprintf("for %v search Session: %v was already ended.", pol.Header.Name, searchSession)))
-						}
-					}
+	// The search for nodes exploits pagination on the exchange, which means that the search API returns a "page" of results
+	// on each call, not the entire result set. To manage the page state, the agbot uses a coordinated session token
+	// to indicate that it wants the next page of results for a given session. If that session gets out of sync between
+	// the agbot and the exchange, the safest way to recover is for the agbot to use the session that the exchange
+	// is using and retry the search. This will happen until the exchange returns the last page of results, at which
+	// point the agbot can resume using the session that it wants to use. It is important to understand that the agbot
+	// keeps a single session token for all searches using a given policy and so does the exchange, so in theory
+	// it is possible that the exchange could have different sessions for different policies, but that should never get out
+	// of sync with the agbot. The error handling in this loop is intended to compensate if the agbot session ever gets out
+	// of sync with the exchange session.
+	devs := make([]exchange.SearchResultDevice, 0, 0)
+	for {
+
+		glog.V(3).Infof(AWlogString(fmt.Sprintf("searching %v with %v", pol.Header.Name, ser)))
+
+		// Invoke the exchange and return the device list or any hard errors that occur.
+		resp, err := exchange.GetHTTPAgbotPolicyNodeSearchHandler(exchangeFederation.ForOrg(n.ec, polOrg))(&ser, polOrg, polName)
+		if err != nil {
+			return nil, err
+		} else if resp.Session != "" {
+			glog.Errorf(AWlogString(fmt.Sprintf("for %v search session is out of sync: %v", pol.Header.Name, resp)))
+			// To get the agbot back in sync, we will need to use the exchange session until it is exhausted.
+			ser.Session = resp.Session
+			searchSession = resp.Session
+			n.SetRescanNeeded()
+			continue
+		} else {
+			// The call was successful, update the DB if we got the last page.  If the exchange returns the number of nodes
+			// requested, then assume there are more nodes in the search result set that weren't returned.
+			if uint64(len(resp.Devices)) != n.batchSize {
+				// Update the DB with the new changedSince value, indicating that the scan is complete. This update also
+				// ends the current search session for this policy.
+				glog.V(3).Infof(AWlogString(fmt.Sprintf("for %v ending Session: %v", pol.Header.Name, searchSession)))
+				if sessionEnded, err := n.db.UpdateSearchSessionChangedSince(changedSince, currentSearchStart, n.sessionKey(pol.Header.Name)); err != nil {
+					glog.Errorf(AWlogString(fmt.Sprintf("unable to update search session changed since, error: %v", err)))
 				} else {
-					// There are more nodes to process, log it.
-					glog.V(3).Infof(AWlogString(fmt.Sprintf("for %v Session: %v scan not complete", pol.Header.Name, searchSession)))
-					n.SetRescanNeeded()
+					if sessionEnded {
+						glog.V(3).Infof(AWlogString(fmt.Sprintf("for %v search Session: %v was already ended.", pol.Header.Name, searchSession)))
+					}
 				}
-				devs = resp.Devices
+			} else {
+				// There are more nodes to process, log it.
+				glog.V(3).Infof(AWlogString(fmt.Sprintf("for %v Session: %v scan not complete", pol.Header.Name, searchSession)))
+				n.SetRescanNeeded()
 			}
-			glog.V(3).Infof(AWlogString(fmt.Sprintf("found %v devices in exchange.", len(devs))))
-			return &devs, nil
+			devs = resp.Devices
+		}
+		glog.V(3).Infof(AWlogString(fmt.Sprintf("found %v devices in exchange.", len(devs))))
+		return &devs, nil
+	}
+}
+
+// Return the architecture of the nodes that a policy generated from a pattern is for.
+func patternPolicyArch(pol *policy.Policy) string {
+	// Architectures supported by the horizon agents
+	supported_architectures := []string{"amd64", "arm", "arm64", "ppc64le"}
+	// pol.Header.Name should be something like Helloworld_ibm.helloworld_ieam-roks-scale_amd64 (or _arm or _arm64 or _ppc64le)
+	// Search list of supported architectures to find a match. If there isn't a match for some reason, the architecture returned will be ""
+	// which the Exchange can handle by not filtering the nodes by architecture and the agbot will determine if they match
+	for _, pattern_arch := range supported_architectures {
+		if strings.HasSuffix(pol.Header.Name, pattern_arch) {
+			return pattern_arch
 		}
 	}
+	return ""
 }
 
 func (n *NodeSearch) AddRetry(policyName string, changedSince uint64) {
 	n.SetRescanNeeded()
-	if err := n.db.ResetPolicyChangedSince(policyName, changedSince); err != nil {
+	if err := n.db.ResetPolicyChangedSince(n.sessionKey(policyName), changedSince); err != nil {
 		glog.Errorf(AWlogString(fmt.Sprintf("unable to update %v search session changed since, error: %v", policyName, err)))
 	}
 }
+
+// Return the key of the search session of a policy in the DB. The agbots share the search session of a policy so that
+// the exchange returns a different page of results to each agbot. When the nodes are sharded, each agbot has to see all
+// the changed nodes to find the ones in its shard, so each agbot has its own search session, keyed by its partition.
+func (n *NodeSearch) sessionKey(policyName string) string {
+	if n.shard == nil {
+		return policyName
+	}
+	return fmt.Sprintf("%v#%v", policyName, n.shard.self)
+}
+
+// Read the agbots that are sharing the nodes from the DB and update the shard. Returns true if they changed. This
+// function is thread safe.
+func (n *NodeSearch) refreshShardMembers() bool {
+	if n.shard == nil {
+		return false
+	}
+
+	partitions, err := n.db.FindLivePartitions(n.partitionStale)
+	if err != nil {
+		glog.Errorf(AWlogString(fmt.Sprintf("unable to find the agbots sharing the nodes, error: %v", err)))
+		return false
+	} else if n.shard.SetMembers(partitions) {
+		glog.V(3).Infof(AWlogString(fmt.Sprintf("node shard changed: %v", n.shard)))
+		return true
+	}
+	return false
+}
+
+// Rebalance the nodes when an agbot joins or leaves. The nodes that moved into this agbot's shard were skipped by the
+// previous searches, so the search sessions of this agbot start over from the beginning of time to find them again.
+// Nodes that already have an agreement keep it with the agbot that made it.
+func (n *NodeSearch) RebalanceShard() {
+	if !n.refreshShardMembers() {
+		return
+	}
+
+	for _, org := range n.pm.GetAllPolicyOrgs() {
+		for _, pol := range n.getOrderedPolicies(org) {
+			if pol.PatternId == "" {
+				n.AddRetry(pol.Header.Name, 1)
+			}
+		}
+	}
+	n.SetRescanNeeded()
+}
@@ -0,0 +1,79 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/policy"
+	"sort"
+	"testing"
+	"time"
+)
+
+func Test_NodeSearch_changedNodes(t *testing.T) {
+
+	n := NewNodeSearch()
+
+	n.AddChangedNodes([]string{"org/node1", "org/node2"})
+	n.AddChangedNodes([]string{"org/node2", "org/node3"})
+	if n.IsRescanNeeded() {
+		t.Errorf("a rescan should not be needed when the changed nodes are known")
+	}
+
+	nodes := n.takeChangedNodes()
+	sort.Strings(nodes)
+	if len(nodes) != 3 || nodes[0] != "org/node1" || nodes[2] != "org/node3" {
+		t.Errorf("expected 3 changed nodes, got %v", nodes)
+	} else if nodes := n.takeChangedNodes(); len(nodes) != 0 {
+		t.Errorf("the changed nodes should have been forgotten, got %v", nodes)
+	}
+
+	n.AddChangedNodes(nil)
+	if !n.IsRescanNeeded() {
+		t.Errorf("a rescan should be needed when the changed nodes are not known")
+	}
+
+	// The deferred nodes that also changed are searched as changed nodes.
+	n.AddDeferredNodes([]string{"org/node4", "org/node5"})
+	n.AddChangedNodes([]string{"org/node5"})
+	if nodes := n.takeDeferredNodes(); len(nodes) != 1 || nodes[0] != "org/node4" {
+		t.Errorf("expected 1 deferred node, got %v", nodes)
+	} else if nodes := n.takeDeferredNodes(); len(nodes) != 0 {
+		t.Errorf("the deferred nodes should have been forgotten, got %v", nodes)
+	}
+}
+
+func Test_changedNodeMatch_matches(t *testing.T) {
+
+	now := time.Now().Unix()
+	recent := time.Unix(now-10, 0).UTC().Format(cutil.ExchangeTimeFormat)
+	stale := time.Unix(now-1000, 0).UTC().Format(cutil.ExchangeTimeFormat)
+
+	bp := &changedNodeMatch{pol: policy.Policy{Header: policy.PolicyHeader{Name: "org/bp1"}}, org: "org", polName: "bp1", nodeOrgs: []string{"org"}}
+	pat := &changedNodeMatch{pol: policy.Policy{Header: policy.PolicyHeader{Name: "org/pat1_svc_amd64"}, PatternId: "org/pat1"}, org: "org", nodeOrgs: []string{"org", "org2"}}
+
+	polNode := &exchange.Device{PublicKey: "key", Arch: "amd64"}
+	patNode := &exchange.Device{PublicKey: "key", Arch: "amd64", Pattern: "org/pat1", LastHeartbeat: recent}
+
+	if !bp.matches("org", polNode, 120, now) {
+		t.Errorf("the deployment policy should match the node without a pattern")
+	} else if bp.matches("org", patNode, 120, now) {
+		t.Errorf("the deployment policy should not match the node with a pattern")
+	} else if bp.matches("org2", polNode, 120, now) {
+		t.Errorf("the deployment policy should not match a node in an org it is not served for")
+	} else if bp.matches("org", &exchange.Device{Arch: "amd64"}, 120, now) {
+		t.Errorf("the deployment policy should not match a node without a public key")
+	}
+
+	if !pat.matches("org2", patNode, 120, now) {
+		t.Errorf("the pattern should match the node with the pattern")
+	} else if pat.matches("org", polNode, 120, now) {
+		t.Errorf("the pattern should not match the node without a pattern")
+	} else if pat.matches("org", &exchange.Device{PublicKey: "key", Arch: "arm64", Pattern: "org/pat1", LastHeartbeat: recent}, 120, now) {
+		t.Errorf("the pattern should not match a node with another architecture")
+	} else if pat.matches("org", &exchange.Device{PublicKey: "key", Arch: "amd64", Pattern: "org/pat1", LastHeartbeat: stale}, 120, now) {
+		t.Errorf("the pattern should not match a node that has not heartbeated recently")
+	}
+}
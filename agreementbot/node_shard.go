@@ -0,0 +1,122 @@
+package agreementbot
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// The number of points each agbot has on the hash ring. More points spread the nodes more evenly across the agbots.
+const SHARD_POINTS_PER_AGBOT = 128
+
+// The node shard decides which agbot negotiates with a node when the nodes are sharded across the agbots that share a
+// database. Each agbot is identified by the database partition it owns and is placed on a consistent hash ring many
+// times. A node belongs to the agbot owning the first point on the ring at or after the hash of the node id, so when an
+// agbot joins or leaves, only the nodes next to its points move to another agbot.
+//
+// Each sharded agbot searches all the changed nodes with its own search session. The exchange keeps the paging state of
+// a search for each agbot identity, so the sharded agbots must each have their own ExchangeId, serving the same patterns
+// and deployment policies.
+type NodeShard struct {
+	lock    sync.RWMutex
+	self    string            // The partition of this agbot.
+	members []string          // The partitions of the running agbots, sorted.
+	points  []uint32          // The points of the ring, sorted.
+	owners  map[uint32]string // The partition owning each point.
+}
+
+func NewNodeShard(self string) *NodeShard {
+	s := &NodeShard{
+		self: self,
+	}
+	s.SetMembers([]string{self})
+	return s
+}
+
+func (s *NodeShard) String() string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return fmt.Sprintf("Self: %v, Members: %v", s.self, s.members)
+}
+
+func shardHash(key string) uint32 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint32(sum[:4])
+}
+
+// Place the given agbots on the ring. This agbot is always on the ring, even when its own heartbeat was not seen.
+// Returns true when the agbots on the ring changed.
+func (s *NodeShard) SetMembers(partitions []string) bool {
+
+	members := make([]string, 0, len(partitions)+1)
+	seen := map[string]bool{}
+	for _, p := range append(partitions, s.self) {
+		if !seen[p] {
+			seen[p] = true
+			members = append(members, p)
+		}
+	}
+	sort.Strings(members)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(members) == len(s.members) {
+		same := true
+		for i := range members {
+			if members[i] != s.members[i] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return false
+		}
+	}
+
+	points := make([]uint32, 0, len(members)*SHARD_POINTS_PER_AGBOT)
+	owners := make(map[uint32]string, len(members)*SHARD_POINTS_PER_AGBOT)
+	for _, m := range members {
+		for i := 0; i < SHARD_POINTS_PER_AGBOT; i++ {
+			point := shardHash(fmt.Sprintf("%v#%v", m, i))
+			// On the rare collision the point stays with the first agbot in sorted order, so every agbot builds the same ring.
+			if _, ok := owners[point]; !ok {
+				owners[point] = m
+				points = append(points, point)
+			}
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i] < points[j] })
+
+	s.members = members
+	s.points = points
+	s.owners = owners
+	return true
+}
+
+// Return the partitions of the agbots on the ring.
+func (s *NodeShard) Members() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return append([]string{}, s.members...)
+}
+
+// Return the partition of the agbot that negotiates with the given node.
+func (s *NodeShard) Owner(nodeId string) string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	h := shardHash(nodeId)
+	i := sort.Search(len(s.points), func(i int) bool { return s.points[i] >= h })
+	if i == len(s.points) {
+		i = 0
+	}
+	return s.owners[s.points[i]]
+}
+
+// Returns true if this agbot negotiates with the given node.
+func (s *NodeShard) Owns(nodeId string) bool {
+	return s.Owner(nodeId) == s.self
+}
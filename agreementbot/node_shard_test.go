@@ -0,0 +1,105 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_NodeShard_single(t *testing.T) {
+
+	s := NewNodeShard("1")
+
+	for i := 0; i < 100; i++ {
+		if !s.Owns(fmt.Sprintf("org/node%v", i)) {
+			t.Errorf("the only agbot should own node%v", i)
+		}
+	}
+
+	if s.SetMembers([]string{}) {
+		t.Errorf("the shard should not change, the agbot is always a member")
+	} else if s.SetMembers([]string{"1"}) {
+		t.Errorf("the shard should not change")
+	}
+}
+
+func Test_NodeShard_partition(t *testing.T) {
+
+	members := []string{"1", "2", "3"}
+	shards := make(map[string]*NodeShard)
+	for _, m := range members {
+		shards[m] = NewNodeShard(m)
+		if !shards[m].SetMembers([]string{"3", "1", "2"}) {
+			t.Errorf("the shard of %v should have changed", m)
+		}
+	}
+
+	const nodes = 3000
+	counts := make(map[string]int)
+	for i := 0; i < nodes; i++ {
+		id := fmt.Sprintf("org/node%v", i)
+		owners := 0
+		for _, m := range members {
+			if shards[m].Owns(id) {
+				owners++
+				counts[m]++
+			}
+		}
+		if owners != 1 {
+			t.Errorf("node %v should be owned by 1 agbot, it is owned by %v", id, owners)
+		}
+	}
+
+	for _, m := range members {
+		if counts[m] < nodes/3/2 || counts[m] > nodes/3*2 {
+			t.Errorf("agbot %v owns %v of %v nodes, the nodes are not spread across the agbots: %v", m, counts[m], nodes, counts)
+		}
+	}
+}
+
+func Test_NodeShard_rebalance(t *testing.T) {
+
+	s := NewNodeShard("1")
+	s.SetMembers([]string{"1", "2", "3"})
+
+	const nodes = 3000
+	before := make(map[string]string)
+	for i := 0; i < nodes; i++ {
+		id := fmt.Sprintf("org/node%v", i)
+		before[id] = s.Owner(id)
+	}
+
+	// Agbot 4 joins, the nodes that move can only move to it.
+	if !s.SetMembers([]string{"1", "2", "3", "4"}) {
+		t.Errorf("the shard should have changed")
+	}
+	moved := 0
+	for id, owner := range before {
+		if now := s.Owner(id); now != owner {
+			moved++
+			if now != "4" {
+				t.Errorf("node %v moved from %v to %v, it should only move to the new agbot", id, owner, now)
+			}
+		}
+	}
+	if moved == 0 || moved > nodes/2 {
+		t.Errorf("%v of %v nodes moved to the new agbot", moved, nodes)
+	}
+
+	// Agbot 2 leaves, only its nodes move.
+	s.SetMembers([]string{"1", "2", "3"})
+	s.SetMembers([]string{"1", "3"})
+	for id, owner := range before {
+		if now := s.Owner(id); owner != "2" && now != owner {
+			t.Errorf("node %v moved from %v to %v, only the nodes of the agbot that left should move", id, owner, now)
+		} else if now == "2" {
+			t.Errorf("node %v is owned by the agbot that left", id)
+		}
+	}
+
+	if members := s.Members(); len(members) != 2 || members[0] != "1" || members[1] != "3" {
+		t.Errorf("wrong members %v", members)
+	}
+}
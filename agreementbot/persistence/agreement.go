@@ -3,7 +3,9 @@ package persistence
 import (
 	"errors"
 	"fmt"
+	"github.com/open-horizon/anax/basicprotocol"
 	"github.com/open-horizon/anax/policy"
+	"strings"
 	"time"
 )
 
@@ -525,10 +527,98 @@ func IdAFilter(id string) AFilter {
 	return func(a Agreement) bool { return a.CurrentAgreementId == id }
 }
 
+func DevAFilter(deviceId string) AFilter {
+	return func(a Agreement) bool { return a.DeviceId == deviceId }
+}
+
 func DevPolAFilter(deviceId string, policyName string) AFilter {
 	return func(a Agreement) bool { return a.DeviceId == deviceId && a.PolicyName == policyName }
 }
 
+// The states of an agreement that the agreements can be searched by. An agreement is pending until the node replies to
+// the proposal, then agreed until it is finalized. An agreement being terminated or archived is terminated, and also
+// failed when it was terminated because something failed. The active state covers the states before termination.
+const (
+	AGREEMENT_STATE_ACTIVE     = "active"
+	AGREEMENT_STATE_PENDING    = "pending"
+	AGREEMENT_STATE_AGREED     = "agreed"
+	AGREEMENT_STATE_FINALIZED  = "finalized"
+	AGREEMENT_STATE_TERMINATED = "terminated"
+	AGREEMENT_STATE_FAILED     = "failed"
+)
+
+func AgreementStates() []string {
+	return []string{AGREEMENT_STATE_ACTIVE, AGREEMENT_STATE_PENDING, AGREEMENT_STATE_AGREED, AGREEMENT_STATE_FINALIZED, AGREEMENT_STATE_TERMINATED, AGREEMENT_STATE_FAILED}
+}
+
+// Returns true when the agreement is being terminated or is archived.
+func (a Agreement) IsTerminated() bool {
+	return a.Archived || a.AgreementTimedout != 0
+}
+
+// Returns the most specific state of the agreement, which is never the active state.
+func (a Agreement) State() string {
+	if a.IsTerminated() {
+		if basicprotocol.IsFailureReasonCode(uint64(a.TerminatedReason)) {
+			return AGREEMENT_STATE_FAILED
+		}
+		return AGREEMENT_STATE_TERMINATED
+	} else if a.AgreementFinalizedTime != 0 {
+		return AGREEMENT_STATE_FINALIZED
+	} else if a.AgreementCreationTime != 0 {
+		return AGREEMENT_STATE_AGREED
+	}
+	return AGREEMENT_STATE_PENDING
+}
+
+// Returns the time of the last change of state of the agreement.
+func (a Agreement) LastStateChangeTime() uint64 {
+	if a.IsTerminated() && a.AgreementTimedout != 0 {
+		return a.AgreementTimedout
+	} else if a.AgreementFinalizedTime != 0 {
+		return a.AgreementFinalizedTime
+	} else if a.AgreementCreationTime != 0 {
+		return a.AgreementCreationTime
+	}
+	return a.AgreementInceptionTime
+}
+
+// The node can be given with or without its org.
+func NodeAFilter(node string) AFilter {
+	return func(a Agreement) bool { return a.DeviceId == node || strings.HasSuffix(a.DeviceId, "/"+node) }
+}
+
+// The deployment policy or the pattern of the agreement, with or without its org.
+func PolicyAFilter(name string) AFilter {
+	matches := func(value string) bool { return value != "" && (value == name || strings.HasSuffix(value, "/"+name)) }
+	return func(a Agreement) bool { return matches(a.PolicyName) || matches(a.Pattern) }
+}
+
+func StateAFilter(state string) AFilter {
+	return func(a Agreement) bool {
+		switch state {
+		case AGREEMENT_STATE_ACTIVE:
+			return !a.IsTerminated()
+		case AGREEMENT_STATE_PENDING:
+			return !a.IsTerminated() && a.AgreementCreationTime == 0
+		case AGREEMENT_STATE_AGREED:
+			return !a.IsTerminated() && a.AgreementCreationTime != 0 && a.AgreementFinalizedTime == 0
+		case AGREEMENT_STATE_FINALIZED:
+			return !a.IsTerminated() && a.AgreementFinalizedTime != 0
+		case AGREEMENT_STATE_TERMINATED:
+			return a.IsTerminated()
+		case AGREEMENT_STATE_FAILED:
+			return a.IsTerminated() && basicprotocol.IsFailureReasonCode(uint64(a.TerminatedReason))
+		}
+		return false
+	}
+}
+
+// Keeps the agreements that changed state at or after the given time, in seconds since the epoch.
+func SinceAFilter(since uint64) AFilter {
+	return func(a Agreement) bool { return a.LastStateChangeTime() >= since }
+}
+
 func RunFilters(ag *Agreement, filters []AFilter) *Agreement {
 	for _, filterFn := range filters {
 		if !filterFn(*ag) {
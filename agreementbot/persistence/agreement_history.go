@@ -0,0 +1,102 @@
+package persistence
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// The agreement history holds the archived agreements after they are purged from the agreements, so that the agreements
+// an agbot reads all the time do not include years of terminated ones. The history is partitioned by the month the
+// agreements were archived in, in the form YYYYMM, so that a month can be exported and dropped at once. When a month is
+// dropped, a summary of its agreements is kept.
+const HISTORY_MONTH_FORMAT = "200601"
+
+var historyMonthRE = regexp.MustCompile(`^[0-9]{6}$`)
+
+// The summary of the agreements of a month of history, kept after the month is dropped.
+type AgreementHistorySummary struct {
+	Month      string         `json:"month"`
+	Agreements int            `json:"agreements"`        // the number of agreements archived in the month
+	Orgs       map[string]int `json:"orgs"`              // the number of agreements of each org
+	Policies   map[string]int `json:"policies"`          // the number of agreements of each deployment policy or pattern
+	Reasons    map[string]int `json:"reasons"`           // the number of agreements terminated for each reason code
+	Dropped    uint64         `json:"dropped,omitempty"` // the time in seconds when the month was last dropped
+}
+
+func (s AgreementHistorySummary) String() string {
+	return fmt.Sprintf("Month: %v, Agreements: %v, Orgs: %v, Policies: %v, Reasons: %v, Dropped: %v",
+		s.Month, s.Agreements, s.Orgs, s.Policies, s.Reasons, s.Dropped)
+}
+
+func NewAgreementHistorySummary(month string) *AgreementHistorySummary {
+	return &AgreementHistorySummary{
+		Month:    month,
+		Orgs:     make(map[string]int),
+		Policies: make(map[string]int),
+		Reasons:  make(map[string]int),
+	}
+}
+
+// Count an agreement in the summary.
+func (s *AgreementHistorySummary) Add(ag *Agreement) {
+	s.Agreements += 1
+	s.Orgs[ag.Org] += 1
+	if ag.Pattern != "" {
+		s.Policies[ag.Pattern] += 1
+	} else {
+		s.Policies[ag.PolicyName] += 1
+	}
+	s.Reasons[fmt.Sprintf("%v", ag.TerminatedReason)] += 1
+}
+
+// Add the counts of another summary of the same month, for the agreements archived in the month after it was dropped.
+func (s *AgreementHistorySummary) Merge(other *AgreementHistorySummary) {
+	s.Agreements += other.Agreements
+	for k, n := range other.Orgs {
+		s.Orgs[k] += n
+	}
+	for k, n := range other.Policies {
+		s.Policies[k] += n
+	}
+	for k, n := range other.Reasons {
+		s.Reasons[k] += n
+	}
+}
+
+// Returns the month of history of an agreement archived at the given time in seconds.
+func HistoryMonth(archived uint64) string {
+	return time.Unix(int64(archived), 0).UTC().Format(HISTORY_MONTH_FORMAT)
+}
+
+// Returns the month of history of an agreement. The time the agreement was archived is not recorded, the time it
+// timed out or was terminated is used.
+func AgreementHistoryMonth(ag *Agreement) string {
+	return HistoryMonth(ag.AgreementTimedout)
+}
+
+// Returns an error if the month is not in the form YYYYMM. The month is used in table and bucket names.
+func ValidateHistoryMonth(month string) error {
+	if !historyMonthRE.MatchString(month) {
+		return fmt.Errorf("month %v is not in the form YYYYMM", month)
+	} else if _, err := time.Parse(HISTORY_MONTH_FORMAT, month); err != nil {
+		return fmt.Errorf("month %v is not valid, error: %v", month, err)
+	}
+	return nil
+}
+
+// Returns the months that are older than the retention, in months counting the current one, sorted.
+func ExpiredHistoryMonths(months []string, retention int, now time.Time) []string {
+	now = now.UTC()
+	oldest := time.Date(now.Year(), now.Month()-time.Month(retention-1), 1, 0, 0, 0, 0, time.UTC).Format(HISTORY_MONTH_FORMAT)
+
+	expired := make([]string, 0)
+	for _, month := range months {
+		if month < oldest {
+			expired = append(expired, month)
+		}
+	}
+	sort.Strings(expired)
+	return expired
+}
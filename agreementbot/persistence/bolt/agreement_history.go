@@ -0,0 +1,148 @@
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Each month of history has its own bucket, so that the month can be dropped at once.
+const AGREEMENT_HISTORY_BUCKET_PREFIX = "agreement_history_"
+const AGREEMENT_HISTORY_SUMMARY_BUCKET = "agreement_history_summaries"
+
+func historyBucket(month string) []byte {
+	return []byte(AGREEMENT_HISTORY_BUCKET_PREFIX + month)
+}
+
+func (db *AgbotBoltDB) SaveAgreementHistory(ag *persistence.Agreement, protocol string) error {
+	month := persistence.AgreementHistoryMonth(ag)
+	return db.db.Update(func(tx *bolt.Tx) error {
+		if b, err := tx.CreateBucketIfNotExists(historyBucket(month)); err != nil {
+			return err
+		} else if serialized, err := json.Marshal(ag); err != nil {
+			return fmt.Errorf("Failed to serialize agreement history record: %v. Error: %v", ag, err)
+		} else if err := b.Put([]byte(ag.CurrentAgreementId), serialized); err != nil {
+			return fmt.Errorf("Failed to write agreement %v to the history of %v. Error: %v", ag.CurrentAgreementId, month, err)
+		} else {
+			glog.V(5).Infof("Succeeded saving agreement %v to the history of %v", ag.CurrentAgreementId, month)
+			return nil
+		}
+	})
+}
+
+func (db *AgbotBoltDB) FindAgreementHistoryMonths() ([]string, error) {
+	months := make([]string, 0)
+
+	readErr := db.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if n := string(name); n != AGREEMENT_HISTORY_SUMMARY_BUCKET && strings.HasPrefix(n, AGREEMENT_HISTORY_BUCKET_PREFIX) {
+				months = append(months, strings.TrimPrefix(n, AGREEMENT_HISTORY_BUCKET_PREFIX))
+			}
+			return nil
+		})
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	sort.Strings(months)
+	return months, nil
+}
+
+func (db *AgbotBoltDB) FindAgreementHistory(month string) ([]persistence.Agreement, error) {
+	ags := make([]persistence.Agreement, 0)
+
+	readErr := db.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(historyBucket(month)); b != nil {
+			return b.ForEach(func(k, v []byte) error {
+				var a persistence.Agreement
+				if err := json.Unmarshal(v, &a); err != nil {
+					return fmt.Errorf("Failed to deserialize agreement history record: %v. Error: %v", string(v), err)
+				}
+				ags = append(ags, a)
+				return nil
+			})
+		}
+		return nil
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return ags, nil
+}
+
+func (db *AgbotBoltDB) DeleteAgreementHistory(month string) (*persistence.AgreementHistorySummary, error) {
+	var summary *persistence.AgreementHistorySummary
+
+	updateErr := db.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(historyBucket(month))
+		if b == nil {
+			return nil
+		}
+
+		summary = persistence.NewAgreementHistorySummary(month)
+		if err := b.ForEach(func(k, v []byte) error {
+			var a persistence.Agreement
+			if err := json.Unmarshal(v, &a); err != nil {
+				return fmt.Errorf("Failed to deserialize agreement history record: %v. Error: %v", string(v), err)
+			}
+			summary.Add(&a)
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		sb, err := tx.CreateBucketIfNotExists([]byte(AGREEMENT_HISTORY_SUMMARY_BUCKET))
+		if err != nil {
+			return err
+		} else if v := sb.Get([]byte(month)); v != nil {
+			previous := persistence.NewAgreementHistorySummary(month)
+			if err := json.Unmarshal(v, previous); err != nil {
+				return fmt.Errorf("Failed to deserialize agreement history summary: %v. Error: %v", string(v), err)
+			}
+			summary.Merge(previous)
+		}
+		summary.Dropped = uint64(time.Now().Unix())
+
+		if serialized, err := json.Marshal(summary); err != nil {
+			return fmt.Errorf("Failed to serialize agreement history summary: %v. Error: %v", summary, err)
+		} else if err := sb.Put([]byte(month), serialized); err != nil {
+			return fmt.Errorf("Failed to write the agreement history summary of %v. Error: %v", month, err)
+		}
+		return tx.DeleteBucket(historyBucket(month))
+	})
+
+	if updateErr != nil {
+		return nil, updateErr
+	}
+	return summary, nil
+}
+
+func (db *AgbotBoltDB) FindAgreementHistorySummaries() ([]persistence.AgreementHistorySummary, error) {
+	summaries := make([]persistence.AgreementHistorySummary, 0)
+
+	readErr := db.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(AGREEMENT_HISTORY_SUMMARY_BUCKET)); b != nil {
+			return b.ForEach(func(k, v []byte) error {
+				var s persistence.AgreementHistorySummary
+				if err := json.Unmarshal(v, &s); err != nil {
+					return fmt.Errorf("Failed to deserialize agreement history summary: %v. Error: %v", string(v), err)
+				}
+				summaries = append(summaries, s)
+				return nil
+			})
+		}
+		return nil
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return summaries, nil
+}
@@ -0,0 +1,135 @@
+package bolt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+)
+
+const DEAD_LETTER_BUCKET = "dead_letters"
+
+func (db *AgbotBoltDB) SaveDeadLetter(letter *persistence.DeadLetter) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		if b, err := tx.CreateBucketIfNotExists([]byte(DEAD_LETTER_BUCKET)); err != nil {
+			return err
+		} else {
+			if letter.Id == 0 {
+				if id, err := b.NextSequence(); err != nil {
+					return fmt.Errorf("Failed to get the next dead letter id. Error: %v", err)
+				} else {
+					letter.Id = id
+				}
+			}
+			if serialized, err := json.Marshal(letter); err != nil {
+				return fmt.Errorf("Failed to serialize dead letter record: %v. Error: %v", letter, err)
+			} else if err := b.Put(deadLetterKey(letter.Id), serialized); err != nil {
+				return fmt.Errorf("Failed to write dead letter %v. Error: %v", letter.Id, err)
+			}
+			glog.V(5).Infof("Succeeded saving dead letter %v", letter.ShortString())
+			return nil
+		}
+	})
+}
+
+func (db *AgbotBoltDB) FindDeadLetter(id uint64) (*persistence.DeadLetter, error) {
+	var pl *persistence.DeadLetter
+
+	readErr := db.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(DEAD_LETTER_BUCKET)); b != nil {
+			v := b.Get(deadLetterKey(id))
+			if v == nil {
+				return nil
+			}
+
+			var l persistence.DeadLetter
+			if err := json.Unmarshal(v, &l); err != nil {
+				return fmt.Errorf("Failed to deserialize dead letter record: %v. Error: %v", string(v), err)
+			}
+			pl = &l
+		}
+		return nil
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return pl, nil
+}
+
+// Return the dead letters of a node, or of all nodes when the node id is empty, oldest first.
+func (db *AgbotBoltDB) FindDeadLetters(nodeId string) ([]persistence.DeadLetter, error) {
+	letters := make([]persistence.DeadLetter, 0)
+
+	readErr := db.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(DEAD_LETTER_BUCKET)); b != nil {
+			return b.ForEach(func(k, v []byte) error {
+				var l persistence.DeadLetter
+				if err := json.Unmarshal(v, &l); err != nil {
+					return fmt.Errorf("Failed to deserialize dead letter record: %v. Error: %v", string(v), err)
+				} else if nodeId == "" || l.NodeId == nodeId {
+					letters = append(letters, l)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return letters, nil
+}
+
+func (db *AgbotBoltDB) DeleteDeadLetter(id uint64) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(DEAD_LETTER_BUCKET)); b == nil {
+			return nil
+		} else {
+			return b.Delete(deadLetterKey(id))
+		}
+	})
+}
+
+// Delete the dead letters that last failed before the given time in seconds, returning how many were deleted.
+func (db *AgbotBoltDB) DeleteDeadLettersBefore(failed uint64) (int, error) {
+	deleted := 0
+	err := db.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(DEAD_LETTER_BUCKET))
+		if b == nil {
+			return nil
+		}
+
+		keys := make([][]byte, 0)
+		if err := b.ForEach(func(k, v []byte) error {
+			var l persistence.DeadLetter
+			if err := json.Unmarshal(v, &l); err != nil {
+				return fmt.Errorf("Failed to deserialize dead letter record: %v. Error: %v", string(v), err)
+			} else if l.LastFailed < failed {
+				keys = append(keys, k)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+			deleted++
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+// The ids are stored big endian so that the dead letters are iterated oldest first.
+func deadLetterKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
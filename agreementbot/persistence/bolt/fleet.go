@@ -0,0 +1,90 @@
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+)
+
+const FLEET_BUCKET = "fleets"
+
+func (db *AgbotBoltDB) SaveFleet(fleet *persistence.Fleet) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		if b, err := tx.CreateBucketIfNotExists([]byte(FLEET_BUCKET)); err != nil {
+			return err
+		} else if serialized, err := json.Marshal(fleet); err != nil {
+			return fmt.Errorf("Failed to serialize fleet record: %v. Error: %v", fleet, err)
+		} else if err := b.Put([]byte(fleetId(fleet.Org, fleet.Name)), serialized); err != nil {
+			return fmt.Errorf("Failed to write fleet %v/%v. Error: %v", fleet.Org, fleet.Name, err)
+		} else {
+			glog.V(5).Infof("Succeeded saving fleet %v", fleet.ShortString())
+			return nil
+		}
+	})
+}
+
+func (db *AgbotBoltDB) FindFleet(org string, name string) (*persistence.Fleet, error) {
+	var pf *persistence.Fleet
+
+	readErr := db.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(FLEET_BUCKET)); b != nil {
+			v := b.Get([]byte(fleetId(org, name)))
+			if v == nil {
+				return nil
+			}
+
+			var f persistence.Fleet
+			if err := json.Unmarshal(v, &f); err != nil {
+				return fmt.Errorf("Failed to deserialize fleet record: %v. Error: %v", string(v), err)
+			}
+			pf = &f
+		}
+		return nil
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return pf, nil
+}
+
+// Return the fleets of an org, or of all orgs when the org is empty.
+func (db *AgbotBoltDB) FindFleets(org string) ([]persistence.Fleet, error) {
+	fleets := make([]persistence.Fleet, 0)
+
+	readErr := db.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(FLEET_BUCKET)); b != nil {
+			return b.ForEach(func(k, v []byte) error {
+				var f persistence.Fleet
+				if err := json.Unmarshal(v, &f); err != nil {
+					return fmt.Errorf("Failed to deserialize fleet record: %v. Error: %v", string(v), err)
+				} else if org == "" || f.Org == org {
+					fleets = append(fleets, f)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return fleets, nil
+}
+
+func (db *AgbotBoltDB) DeleteFleet(org string, name string) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(FLEET_BUCKET)); b == nil {
+			return nil
+		} else {
+			return b.Delete([]byte(fleetId(org, name)))
+		}
+	})
+}
+
+func fleetId(org string, name string) string {
+	return fmt.Sprintf("%s/%s", org, name)
+}
@@ -0,0 +1,151 @@
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+)
+
+const JOIN_TOKEN_BUCKET = "join_tokens"
+
+func (db *AgbotBoltDB) SaveJoinToken(token *persistence.JoinToken) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return saveJoinToken(tx, token)
+	})
+}
+
+func saveJoinToken(tx *bolt.Tx, token *persistence.JoinToken) error {
+	if b, err := tx.CreateBucketIfNotExists([]byte(JOIN_TOKEN_BUCKET)); err != nil {
+		return err
+	} else if serialized, err := json.Marshal(token); err != nil {
+		return fmt.Errorf("Failed to serialize join token record: %v. Error: %v", token, err)
+	} else if err := b.Put([]byte(joinTokenId(token.Org, token.Id)), serialized); err != nil {
+		return fmt.Errorf("Failed to write join token %v/%v. Error: %v", token.Org, token.Id, err)
+	} else {
+		glog.V(5).Infof("Succeeded saving join token %v", token.ShortString())
+		return nil
+	}
+}
+
+func (db *AgbotBoltDB) FindJoinToken(org string, id string) (*persistence.JoinToken, error) {
+	var pt *persistence.JoinToken
+
+	readErr := db.db.View(func(tx *bolt.Tx) error {
+		var err error
+		pt, err = findJoinToken(tx, org, id)
+		return err
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return pt, nil
+}
+
+func findJoinToken(tx *bolt.Tx, org string, id string) (*persistence.JoinToken, error) {
+	b := tx.Bucket([]byte(JOIN_TOKEN_BUCKET))
+	if b == nil {
+		return nil, nil
+	}
+	v := b.Get([]byte(joinTokenId(org, id)))
+	if v == nil {
+		return nil, nil
+	}
+
+	var t persistence.JoinToken
+	if err := json.Unmarshal(v, &t); err != nil {
+		return nil, fmt.Errorf("Failed to deserialize join token record: %v. Error: %v", string(v), err)
+	}
+	return &t, nil
+}
+
+// Return the join tokens of an org, or of all orgs when the org is empty.
+func (db *AgbotBoltDB) FindJoinTokens(org string) ([]persistence.JoinToken, error) {
+	tokens := make([]persistence.JoinToken, 0)
+
+	readErr := db.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(JOIN_TOKEN_BUCKET)); b != nil {
+			return b.ForEach(func(k, v []byte) error {
+				var t persistence.JoinToken
+				if err := json.Unmarshal(v, &t); err != nil {
+					return fmt.Errorf("Failed to deserialize join token record: %v. Error: %v", string(v), err)
+				} else if org == "" || t.Org == org {
+					tokens = append(tokens, t)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return tokens, nil
+}
+
+func (db *AgbotBoltDB) UpdateJoinToken(org string, id string, fn func(*persistence.JoinToken) error) (*persistence.JoinToken, error) {
+	var pt *persistence.JoinToken
+
+	updateErr := db.db.Update(func(tx *bolt.Tx) error {
+		if t, err := findJoinToken(tx, org, id); err != nil || t == nil {
+			return err
+		} else if err := fn(t); err != nil {
+			return err
+		} else {
+			pt = t
+			return saveJoinToken(tx, t)
+		}
+	})
+
+	if updateErr != nil {
+		return nil, updateErr
+	}
+	return pt, nil
+}
+
+func (db *AgbotBoltDB) DeleteJoinToken(org string, id string) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(JOIN_TOKEN_BUCKET)); b == nil {
+			return nil
+		} else {
+			return b.Delete([]byte(joinTokenId(org, id)))
+		}
+	})
+}
+
+func (db *AgbotBoltDB) DeleteExpiredJoinTokens(before uint64) (int, error) {
+	deleted := 0
+	updateErr := db.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(JOIN_TOKEN_BUCKET))
+		if b == nil {
+			return nil
+		}
+		expired := [][]byte{}
+		if err := b.ForEach(func(k, v []byte) error {
+			var t persistence.JoinToken
+			if err := json.Unmarshal(v, &t); err != nil {
+				return fmt.Errorf("Failed to deserialize join token record: %v. Error: %v", string(v), err)
+			} else if t.Expires < before {
+				expired = append(expired, k)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return fmt.Errorf("Failed to delete join token %v. Error: %v", string(k), err)
+			}
+		}
+		deleted = len(expired)
+		return nil
+	})
+	return deleted, updateErr
+}
+
+func joinTokenId(org string, id string) string {
+	return fmt.Sprintf("%s/%s", org, id)
+}
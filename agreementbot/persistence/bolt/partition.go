@@ -32,3 +32,11 @@ func (db *AgbotBoltDB) GetPartitionOwner(id string) (string, error) {
 func (db *AgbotBoltDB) MovePartition(timeout uint64) (bool, error) {
 	return false, nil
 }
+
+func (db *AgbotBoltDB) PrimaryPartition() string {
+	return "global"
+}
+
+func (db *AgbotBoltDB) FindLivePartitions(timeout uint64) ([]string, error) {
+	return []string{"global"}, nil
+}
@@ -24,6 +24,8 @@ type AgbotDatabase interface {
 	QuiescePartition() error
 	GetPartitionOwner(id string) (string, error)
 	MovePartition(timeout uint64) (bool, error)
+	FindLivePartitions(timeout uint64) ([]string, error)
+	PrimaryPartition() string
 
 	// Persistent agreement related functions
 	FindAgreements(filters []AFilter, protocol string) ([]Agreement, error)
@@ -111,4 +113,37 @@ type AgbotDatabase interface {
 	GetHAUpgradingWorkload(org string, haGroupName string, policyName string) (*UpgradingHAGroupWorkload, error)
 	UpdateHAUpgradingWorkloadForGroupAndPolicy(org string, haGroupName string, policyName string, deviceId string) error
 	InsertHAUpgradingWorkloadForGroupAndPolicy(org string, haGroupName string, policyName string, deviceId string) (string, error)
+
+	// Functions related to persistence of the fleets of nodes that deployment policies target.
+	SaveFleet(fleet *Fleet) error
+	FindFleet(org string, name string) (*Fleet, error)
+	FindFleets(org string) ([]Fleet, error)
+	DeleteFleet(org string, name string) error
+
+	// Functions related to persistence of the join tokens that devices register with. UpdateJoinToken calls the function
+	// with the join token in a transaction and saves the token when the function returns no error, it returns nil when
+	// the token does not exist. DeleteExpiredJoinTokens deletes the tokens that expired before the given time in seconds
+	// and returns the number of tokens deleted.
+	SaveJoinToken(token *JoinToken) error
+	FindJoinToken(org string, id string) (*JoinToken, error)
+	FindJoinTokens(org string) ([]JoinToken, error)
+	UpdateJoinToken(org string, id string, fn func(*JoinToken) error) (*JoinToken, error)
+	DeleteJoinToken(org string, id string) error
+	DeleteExpiredJoinTokens(before uint64) (int, error)
+
+	// Functions related to persistence of the protocol messages that could not be delivered to nodes. SaveDeadLetter assigns
+	// the id of a new dead letter. An empty node id finds the dead letters of all nodes.
+	SaveDeadLetter(letter *DeadLetter) error
+	FindDeadLetter(id uint64) (*DeadLetter, error)
+	FindDeadLetters(nodeId string) ([]DeadLetter, error)
+	DeleteDeadLetter(id uint64) error
+	DeleteDeadLettersBefore(failed uint64) (int, error)
+
+	// Functions related to persistence of the history of the archived agreements, partitioned by the month the agreements
+	// were archived in. Deleting a month keeps a summary of its agreements, nil is returned when the month is not found.
+	SaveAgreementHistory(ag *Agreement, protocol string) error
+	FindAgreementHistoryMonths() ([]string, error)
+	FindAgreementHistory(month string) ([]Agreement, error)
+	DeleteAgreementHistory(month string) (*AgreementHistorySummary, error)
+	FindAgreementHistorySummaries() ([]AgreementHistorySummary, error)
 }
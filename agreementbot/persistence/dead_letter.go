@@ -0,0 +1,31 @@
+package persistence
+
+import (
+	"fmt"
+)
+
+// A dead letter is a protocol message that the agbot failed to deliver to a node, after retrying. It is kept in the agbot
+// database so that it can be inspected and replayed through the agbot API when the node is reachable again. The payload
+// is the message encrypted with the message key pair of the agbot, it is encrypted with the current key of the node when
+// it is replayed. The payload is not returned by the API.
+type DeadLetter struct {
+	Id          uint64 `json:"id"`
+	Protocol    string `json:"protocol"`    // the agreement protocol that sent the message
+	NodeId      string `json:"nodeId"`      // the org qualified id of the node the message is for
+	MsgType     string `json:"msgType"`     // the type of the protocol message, e.g. proposal or cancel
+	AgreementId string `json:"agreementId"` // the agreement the message is about
+	Payload     string `json:"payload,omitempty"`
+	Error       string `json:"error"`       // the error of the last delivery attempt
+	Attempts    int    `json:"attempts"`    // the number of delivery attempts, including the replays
+	FirstFailed uint64 `json:"firstFailed"` // the time in seconds when the message was dead lettered
+	LastFailed  uint64 `json:"lastFailed"`  // the time in seconds of the last failed attempt
+}
+
+func (d DeadLetter) String() string {
+	return fmt.Sprintf("Id: %v, Protocol: %v, NodeId: %v, MsgType: %v, AgreementId: %v, Error: %v, Attempts: %v, FirstFailed: %v, LastFailed: %v",
+		d.Id, d.Protocol, d.NodeId, d.MsgType, d.AgreementId, d.Error, d.Attempts, d.FirstFailed, d.LastFailed)
+}
+
+func (d DeadLetter) ShortString() string {
+	return fmt.Sprintf("Id: %v, NodeId: %v, MsgType: %v, AgreementId: %v", d.Id, d.NodeId, d.MsgType, d.AgreementId)
+}
@@ -0,0 +1,69 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"github.com/open-horizon/anax/externalpolicy"
+	"strings"
+)
+
+// A fleet is a named set of nodes that deployment policies can target directly. The members are the nodes listed in the
+// fleet and the nodes whose properties satisfy the constraints of the fleet. Fleets are kept in the agbot database so that
+// all the agbots sharing the database see the same fleets.
+type Fleet struct {
+	Org         string                              `json:"org"`
+	Name        string                              `json:"name"`
+	Description string                              `json:"description,omitempty"`
+	Owner       string                              `json:"owner,omitempty"`       // the user that last changed the fleet
+	Nodes       []string                            `json:"nodes,omitempty"`       // the org qualified ids of the nodes in the fleet
+	Constraints externalpolicy.ConstraintExpression `json:"constraints,omitempty"` // the nodes whose properties satisfy these constraints are in the fleet
+	LastUpdated uint64                              `json:"lastUpdated,omitempty"` // the time in seconds when the fleet was last changed
+}
+
+func (f Fleet) String() string {
+	return fmt.Sprintf("Org: %v, Name: %v, Description: %v, Owner: %v, Nodes: %v, Constraints: %v, LastUpdated: %v",
+		f.Org, f.Name, f.Description, f.Owner, f.Nodes, f.Constraints, f.LastUpdated)
+}
+
+func (f Fleet) ShortString() string {
+	return fmt.Sprintf("Org: %v, Name: %v, Nodes: %v, Constraints: %v", f.Org, f.Name, len(f.Nodes), f.Constraints)
+}
+
+// Validate the fleet and qualify the node ids that are not org qualified with the org of the fleet.
+func (f *Fleet) Validate() error {
+	if f.Org == "" || f.Name == "" {
+		return errors.New("the org and name of the fleet must not be empty")
+	} else if strings.Contains(f.Name, "/") {
+		return fmt.Errorf("the fleet name %v must not contain a /", f.Name)
+	} else if len(f.Nodes) == 0 && len(f.Constraints) == 0 {
+		return fmt.Errorf("fleet %v must have nodes, constraints or both", f.Name)
+	}
+
+	for ix, id := range f.Nodes {
+		if id == "" {
+			return fmt.Errorf("fleet %v has an empty node id", f.Name)
+		} else if !deviceIDContainsOrg(id) {
+			f.Nodes[ix] = fmt.Sprintf("%v/%v", f.Org, id)
+		}
+	}
+
+	if len(f.Constraints) != 0 {
+		if _, err := f.Constraints.Validate(); err != nil {
+			return fmt.Errorf("fleet %v has invalid constraints, %v", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// Returns true if the node with the given org qualified id and properties is in the fleet.
+func (f Fleet) HasNode(nodeId string, props externalpolicy.PropertyList) bool {
+	for _, id := range f.Nodes {
+		if id == nodeId {
+			return true
+		}
+	}
+	if len(f.Constraints) == 0 {
+		return false
+	}
+	return f.Constraints.IsSatisfiedBy(props) == nil
+}
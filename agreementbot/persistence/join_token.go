@@ -0,0 +1,127 @@
+package persistence
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/policy"
+	"strings"
+	"text/template"
+)
+
+// A join token lets a device register as a node of an org without user credentials. The admin creates the token with
+// the pattern or node policy, the user input and the node name template of the nodes, and the device presents the token
+// to the agbot, which creates the node in the exchange. The secret of the token is not kept, only its hash.
+type JoinToken struct {
+	Org              string                     `json:"org"`
+	Id               string                     `json:"id"`
+	SecretHash       string                     `json:"secretHash,omitempty"` // the hex encoded sha256 hash of the secret of the token
+	Description      string                     `json:"description,omitempty"`
+	Owner            string                     `json:"owner,omitempty"`            // the user that created the token
+	Pattern          string                     `json:"pattern,omitempty"`          // the pattern of the nodes, org qualified when it is in another org
+	NodePolicy       *exchangecommon.NodePolicy `json:"nodePolicy,omitempty"`       // the node policy of the nodes
+	UserInput        []policy.UserInput         `json:"userInput,omitempty"`        // the user input of the nodes
+	NodeNameTemplate string                     `json:"nodeNameTemplate,omitempty"` // a text/template for the node name, the node id when empty
+	MaxUses          int                        `json:"maxUses,omitempty"`          // the number of nodes that can join with the token, no limit when zero
+	Nodes            []string                   `json:"nodes,omitempty"`            // the org qualified ids of the nodes that joined with the token
+	Created          uint64                     `json:"created,omitempty"`          // the time in seconds when the token was created
+	Expires          uint64                     `json:"expires"`                    // the time in seconds after which the token cannot be used
+}
+
+func (t JoinToken) String() string {
+	return fmt.Sprintf("Org: %v, Id: %v, Description: %v, Owner: %v, Pattern: %v, NodePolicy: %v, UserInput: %v, NodeNameTemplate: %v, MaxUses: %v, Nodes: %v, Created: %v, Expires: %v",
+		t.Org, t.Id, t.Description, t.Owner, t.Pattern, t.NodePolicy, t.UserInput, t.NodeNameTemplate, t.MaxUses, t.Nodes, t.Created, t.Expires)
+}
+
+func (t JoinToken) ShortString() string {
+	return fmt.Sprintf("Org: %v, Id: %v, Pattern: %v, MaxUses: %v, Nodes: %v, Expires: %v", t.Org, t.Id, t.Pattern, t.MaxUses, len(t.Nodes), t.Expires)
+}
+
+// The values that a node name template can use.
+type JoinTokenNodeName struct {
+	Org    string
+	NodeId string
+	Index  int // the number of nodes that joined with the token before this one
+}
+
+// Returns the hash of a join token secret, as kept in the join token.
+func HashJoinTokenSecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
+
+// Validate the join token before it is saved.
+func (t *JoinToken) Validate() error {
+	if t.Org == "" || t.Id == "" {
+		return errors.New("the org and id of the join token must not be empty")
+	} else if strings.Contains(t.Id, "/") {
+		return fmt.Errorf("the join token id %v must not contain a /", t.Id)
+	} else if t.SecretHash == "" {
+		return fmt.Errorf("join token %v has no secret", t.Id)
+	} else if t.Expires == 0 {
+		return fmt.Errorf("join token %v must expire", t.Id)
+	} else if t.MaxUses < 0 {
+		return fmt.Errorf("join token %v must not have a negative maxUses", t.Id)
+	} else if t.Pattern != "" && t.NodePolicy != nil {
+		return fmt.Errorf("join token %v must not have both a pattern and a node policy", t.Id)
+	}
+
+	if t.NodePolicy != nil {
+		if err := t.NodePolicy.ValidateAndNormalize(); err != nil {
+			return fmt.Errorf("join token %v has an invalid node policy, %v", t.Id, err)
+		}
+	}
+	if _, err := t.NodeName("node"); err != nil {
+		return fmt.Errorf("join token %v has an invalid node name template, %v", t.Id, err)
+	}
+	return nil
+}
+
+// Returns the name of the node with the given id, from the node name template of the token.
+func (t JoinToken) NodeName(nodeId string) (string, error) {
+	if t.NodeNameTemplate == "" {
+		return nodeId, nil
+	}
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(t.NodeNameTemplate)
+	if err != nil {
+		return "", err
+	}
+	var name bytes.Buffer
+	if err := tmpl.Execute(&name, JoinTokenNodeName{Org: t.Org, NodeId: nodeId, Index: len(t.Nodes)}); err != nil {
+		return "", err
+	}
+	return name.String(), nil
+}
+
+// Check that a node can join with the token and record the node in the token. The error is the same for a wrong secret
+// and for a token that does not exist, so that the token ids cannot be discovered.
+func (t *JoinToken) Join(secret string, nodeId string, now uint64) error {
+	if subtle.ConstantTimeCompare([]byte(HashJoinTokenSecret(secret)), []byte(t.SecretHash)) != 1 {
+		return errors.New("invalid join token")
+	} else if now > t.Expires {
+		return fmt.Errorf("join token %v/%v expired", t.Org, t.Id)
+	} else if t.MaxUses != 0 && len(t.Nodes) >= t.MaxUses {
+		return fmt.Errorf("join token %v/%v was used by %v nodes already", t.Org, t.Id, len(t.Nodes))
+	}
+	for _, n := range t.Nodes {
+		if n == nodeId {
+			return fmt.Errorf("node %v already joined with join token %v/%v", nodeId, t.Org, t.Id)
+		}
+	}
+	t.Nodes = append(t.Nodes, nodeId)
+	return nil
+}
+
+// Remove a node from the nodes that joined with the token, when the node could not be created.
+func (t *JoinToken) Leave(nodeId string) {
+	for ix, n := range t.Nodes {
+		if n == nodeId {
+			t.Nodes = append(t.Nodes[:ix], t.Nodes[ix+1:]...)
+			return
+		}
+	}
+}
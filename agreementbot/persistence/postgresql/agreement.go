@@ -7,8 +7,10 @@ import (
 	"fmt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/metrics"
 	"github.com/open-horizon/anax/policy"
 	"strings"
+	"time"
 )
 
 // This function registers an uninitialized agbot DB instance with the DB plugin registry. The plugin's Initialize
@@ -254,6 +256,7 @@ func (db *AgbotPostgresqlDB) GetAgreementCount(partition string) (int64, int64,
 
 // Retrieve all agreements from the database and filter them out based on the input filters.
 func (db *AgbotPostgresqlDB) FindAgreements(filters []persistence.AFilter, protocol string) ([]persistence.Agreement, error) {
+	defer observe("find_agreements", time.Now())
 
 	ags := make([]persistence.Agreement, 0, 100)
 
@@ -337,6 +340,7 @@ func (db *AgbotPostgresqlDB) internalFindSingleAgreementByAgreementId(tx *sql.Tx
 }
 
 func (db *AgbotPostgresqlDB) FindSingleAgreementByAgreementId(agreementId string, protocol string, filters []persistence.AFilter) (*persistence.Agreement, error) {
+	defer observe("find_agreement", time.Now())
 	ag, _, err := db.internalFindSingleAgreementByAgreementId(nil, agreementId, protocol, filters)
 	return ag, err
 }
@@ -429,6 +433,7 @@ func (db *AgbotPostgresqlDB) AgreementPolicyUpdateAckTime(agreementid string, pr
 }
 
 func (db *AgbotPostgresqlDB) DeleteAgreement(agreementid string, protocol string) error {
+	defer observe("delete_agreement", time.Now())
 	tx, err := db.db.Begin()
 	if err != nil {
 		return err
@@ -450,6 +455,11 @@ func (db *AgbotPostgresqlDB) Close() {
 
 // Utility functions used by the public functions in this package.
 
+// Record the duration of a database operation in the agbot metrics. Deferred at the start of the operation.
+func observe(operation string, start time.Time) {
+	metrics.DatabaseOperation(operation, time.Since(start))
+}
+
 // This function is used by all functions that want to change something in the database. It first locates the agreement
 // to be updated (the query is done in it's own transaction), then calls the input function to update the agreement in
 // memory, and finally calls wrapTransaction to start a transaction that will actually perform the update.
@@ -466,6 +476,7 @@ func (db *AgbotPostgresqlDB) SingleAgreementUpdate(agreementid string, protocol
 
 // This function is used to wrap a database transaction around an update to an agreement object.
 func (db *AgbotPostgresqlDB) wrapTransaction(agreementid string, protocol string, updated *persistence.Agreement) error {
+	defer observe("update_agreement", time.Now())
 
 	if tx, err := db.db.Begin(); err != nil {
 		return err
@@ -496,6 +507,7 @@ func (db *AgbotPostgresqlDB) persistUpdatedAgreement(tx *sql.Tx, agreementid str
 }
 
 func (db *AgbotPostgresqlDB) insertAgreement(ag *persistence.Agreement, protocol string) error {
+	defer observe("insert_agreement", time.Now())
 
 	sql := strings.Replace(AGREEMENT_INSERT, AGREEMENT_TABLE_NAME_ROOT, db.GetAgreementPartitionTableName(db.PrimaryPartition()), 1)
 
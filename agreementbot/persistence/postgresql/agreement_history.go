@@ -0,0 +1,250 @@
+package postgresql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"strings"
+	"time"
+)
+
+// Constants for the SQL statements that are used to work with the agreement history. The history is partitioned by the
+// month the agreements were archived in, using table inheritance the same way as the agreements. The main table
+// (called agreement_history) never has any rows, each month has its own table (called agreement_history_<month>) that is
+// created when the first agreement of the month is saved. A month is dropped by dropping its table, so that the rows do
+// not have to be deleted one at a time. The history is not partitioned by agbot, any agbot sharing the database can
+// export or drop a month.
+
+// agreement_history schema:
+// agreement_id: The stringified agreement id for the agreement object in the record.
+// protocol:     The agreement protocol of the agreement.
+// month:        The month the agreement was archived in, in the form YYYYMM.
+// agreement:    The agreement object which is a JSON blob.
+// saved:        A timestamp to record when the agreement was moved to the history.
+//
+// agreement_history_summaries schema:
+// month:        The month that was dropped, in the form YYYYMM.
+// summary:      The summary of the agreements of the month, the JSON serialization of an AgreementHistorySummary.
+const AGREEMENT_HISTORY_CREATE_MAIN_TABLE = `CREATE TABLE IF NOT EXISTS agreement_history (
+	agreement_id text NOT NULL,
+	protocol text NOT NULL,
+	month text NOT NULL,
+	agreement jsonb NOT NULL,
+	saved timestamp with time zone DEFAULT current_timestamp
+);
+CREATE TABLE IF NOT EXISTS agreement_history_summaries (
+	month text PRIMARY KEY,
+	summary jsonb NOT NULL
+);`
+
+const AGREEMENT_HISTORY_TABLE_NAME_ROOT = `agreement_history_`
+const AGREEMENT_HISTORY_MONTH_FILLIN = `month_name`
+
+const AGREEMENT_HISTORY_CREATE_MONTH_TABLE = `CREATE TABLE IF NOT EXISTS "agreement_history_ (
+	CHECK ( month = 'month_name' )
+) INHERITS (agreement_history);
+CREATE UNIQUE INDEX IF NOT EXISTS "agreement_id_index_on_agreement_history_ ON "agreement_history_ (agreement_id);`
+
+// The agreement is not saved again if it is already in the history, in case it was saved but not deleted from the agreements.
+const AGREEMENT_HISTORY_INSERT = `INSERT INTO "agreement_history_ (agreement_id, protocol, month, agreement) VALUES ($1, $2, $3, $4) ON CONFLICT (agreement_id) DO NOTHING;`
+
+const AGREEMENT_HISTORY_QUERY = `SELECT agreement FROM "agreement_history_ ORDER BY saved;`
+
+const AGREEMENT_HISTORY_MONTHS = `SELECT c.relname FROM pg_inherits i
+	JOIN pg_class c ON c.oid = i.inhrelid
+	JOIN pg_class p ON p.oid = i.inhparent
+	WHERE p.relname = 'agreement_history' ORDER BY c.relname;`
+
+const AGREEMENT_HISTORY_MONTH_EXISTS = `SELECT to_regclass('agreement_history_');`
+
+const AGREEMENT_HISTORY_DROP_MONTH = `DROP TABLE IF EXISTS "agreement_history_;`
+
+const AGREEMENT_HISTORY_SUMMARY_QUERY = `SELECT summary FROM agreement_history_summaries WHERE month = $1 FOR UPDATE;`
+
+const AGREEMENT_HISTORY_SUMMARY_UPSERT = `INSERT INTO agreement_history_summaries (month, summary) VALUES ($1, $2)
+	ON CONFLICT (month) DO UPDATE SET summary = EXCLUDED.summary;`
+
+const AGREEMENT_HISTORY_SUMMARIES_QUERY = `SELECT summary FROM agreement_history_summaries ORDER BY month;`
+
+func (db *AgbotPostgresqlDB) GetAgreementHistoryTableName(month string) string {
+	return AGREEMENT_HISTORY_TABLE_NAME_ROOT + month + `"`
+}
+
+// Fill in the month table name, and the month, in one of the SQL templates above.
+func (db *AgbotPostgresqlDB) getAgreementHistorySQL(template string, month string) string {
+	sql := strings.Replace(template, AGREEMENT_HISTORY_TABLE_NAME_ROOT, db.GetAgreementHistoryTableName(month), -1)
+	return strings.Replace(sql, AGREEMENT_HISTORY_MONTH_FILLIN, month, -1)
+}
+
+// The SQL template used by this function uses single quotes around the table name, like the agreement partition check.
+func (db *AgbotPostgresqlDB) GetAgreementHistoryMonthExists(month string) string {
+	return strings.Replace(AGREEMENT_HISTORY_MONTH_EXISTS, AGREEMENT_HISTORY_TABLE_NAME_ROOT, AGREEMENT_HISTORY_TABLE_NAME_ROOT+month, 1)
+}
+
+func (db *AgbotPostgresqlDB) SaveAgreementHistory(ag *persistence.Agreement, protocol string) error {
+	defer observe("save_agreement_history", time.Now())
+
+	month := persistence.AgreementHistoryMonth(ag)
+	if err := persistence.ValidateHistoryMonth(month); err != nil {
+		return err
+	}
+
+	if _, err := db.db.Exec(db.getAgreementHistorySQL(AGREEMENT_HISTORY_CREATE_MONTH_TABLE, month)); err != nil {
+		return errors.New(fmt.Sprintf("unable to create the agreement history table of %v, error: %v", month, err))
+	} else if agm, err := json.Marshal(ag); err != nil {
+		return err
+	} else if _, err := db.db.Exec(db.getAgreementHistorySQL(AGREEMENT_HISTORY_INSERT, month), ag.CurrentAgreementId, protocol, month, agm); err != nil {
+		return errors.New(fmt.Sprintf("error saving agreement %v to the history of %v, error: %v", ag.CurrentAgreementId, month, err))
+	}
+	glog.V(5).Infof("Succeeded saving agreement %v to the history of %v", ag.CurrentAgreementId, month)
+	return nil
+}
+
+func (db *AgbotPostgresqlDB) FindAgreementHistoryMonths() ([]string, error) {
+	rows, err := db.db.Query(AGREEMENT_HISTORY_MONTHS)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error querying for agreement history months, error: %v", err))
+	}
+
+	// If the rows object doesnt get closed, memory and connections will grow and/or leak.
+	defer rows.Close()
+	months := make([]string, 0)
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, errors.New(fmt.Sprintf("error scanning row for agreement history months, error: %v", err))
+		}
+		months = append(months, strings.TrimPrefix(table, AGREEMENT_HISTORY_TABLE_NAME_ROOT))
+	}
+	return months, rows.Err()
+}
+
+// Returns true if the table of a month exists.
+func (db *AgbotPostgresqlDB) agreementHistoryMonthExists(tx *sql.Tx, month string) (bool, error) {
+	var table sql.NullString
+	if err := tx.QueryRow(db.GetAgreementHistoryMonthExists(month)).Scan(&table); err != nil {
+		return false, errors.New(fmt.Sprintf("error checking for the agreement history table of %v, error: %v", month, err))
+	}
+	return table.Valid, nil
+}
+
+func (db *AgbotPostgresqlDB) FindAgreementHistory(month string) ([]persistence.Agreement, error) {
+	defer observe("find_agreement_history", time.Now())
+
+	if err := persistence.ValidateHistoryMonth(month); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ags := make([]persistence.Agreement, 0)
+	if exists, err := db.agreementHistoryMonthExists(tx, month); err != nil || !exists {
+		return ags, err
+	}
+
+	err = db.scanAgreementHistory(tx, month, func(ag *persistence.Agreement) {
+		ags = append(ags, *ag)
+	})
+	return ags, err
+}
+
+// Read the agreements of a month, calling the function for each one.
+func (db *AgbotPostgresqlDB) scanAgreementHistory(tx *sql.Tx, month string, fn func(*persistence.Agreement)) error {
+	rows, err := tx.Query(db.getAgreementHistorySQL(AGREEMENT_HISTORY_QUERY, month))
+	if err != nil {
+		return errors.New(fmt.Sprintf("error querying for the agreement history of %v, error: %v", month, err))
+	}
+
+	// If the rows object doesnt get closed, memory and connections will grow and/or leak.
+	defer rows.Close()
+	for rows.Next() {
+		agBytes := make([]byte, 0, 2048)
+		ag := new(persistence.Agreement)
+		if err := rows.Scan(&agBytes); err != nil {
+			return errors.New(fmt.Sprintf("error scanning row for the agreement history of %v, error: %v", month, err))
+		} else if err := json.Unmarshal(agBytes, ag); err != nil {
+			return errors.New(fmt.Sprintf("error demarshalling agreement history row: %v, error: %v", string(agBytes), err))
+		}
+		fn(ag)
+	}
+	return rows.Err()
+}
+
+// Drop the table of a month, keeping the summary of its agreements. The summary is merged with the summary of the
+// agreements of the month dropped before, if any.
+func (db *AgbotPostgresqlDB) DeleteAgreementHistory(month string) (*persistence.AgreementHistorySummary, error) {
+	defer observe("delete_agreement_history", time.Now())
+
+	if err := persistence.ValidateHistoryMonth(month); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if exists, err := db.agreementHistoryMonthExists(tx, month); err != nil || !exists {
+		return nil, err
+	}
+
+	summary := persistence.NewAgreementHistorySummary(month)
+	if err := db.scanAgreementHistory(tx, month, summary.Add); err != nil {
+		return nil, err
+	}
+
+	var sBytes []byte
+	if err := tx.QueryRow(AGREEMENT_HISTORY_SUMMARY_QUERY, month).Scan(&sBytes); err != nil && err != sql.ErrNoRows {
+		return nil, errors.New(fmt.Sprintf("error scanning row for the agreement history summary of %v, error: %v", month, err))
+	} else if err == nil {
+		previous := persistence.NewAgreementHistorySummary(month)
+		if err := json.Unmarshal(sBytes, previous); err != nil {
+			return nil, errors.New(fmt.Sprintf("error demarshalling agreement history summary: %v, error: %v", string(sBytes), err))
+		}
+		summary.Merge(previous)
+	}
+	summary.Dropped = uint64(time.Now().Unix())
+
+	if sm, err := json.Marshal(summary); err != nil {
+		return nil, err
+	} else if _, err := tx.Exec(AGREEMENT_HISTORY_SUMMARY_UPSERT, month, sm); err != nil {
+		return nil, errors.New(fmt.Sprintf("error saving the agreement history summary of %v, error: %v", month, err))
+	} else if _, err := tx.Exec(db.getAgreementHistorySQL(AGREEMENT_HISTORY_DROP_MONTH, month)); err != nil {
+		return nil, errors.New(fmt.Sprintf("error dropping the agreement history table of %v, error: %v", month, err))
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	glog.V(3).Infof("Dropped the agreement history of %v, %v agreements", month, summary.Agreements)
+	return summary, nil
+}
+
+func (db *AgbotPostgresqlDB) FindAgreementHistorySummaries() ([]persistence.AgreementHistorySummary, error) {
+	rows, err := db.db.Query(AGREEMENT_HISTORY_SUMMARIES_QUERY)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error querying for agreement history summaries, error: %v", err))
+	}
+
+	// If the rows object doesnt get closed, memory and connections will grow and/or leak.
+	defer rows.Close()
+	summaries := make([]persistence.AgreementHistorySummary, 0)
+	for rows.Next() {
+		var sBytes []byte
+		var s persistence.AgreementHistorySummary
+		if err := rows.Scan(&sBytes); err != nil {
+			return nil, errors.New(fmt.Sprintf("error scanning row for agreement history summaries, error: %v", err))
+		} else if err := json.Unmarshal(sBytes, &s); err != nil {
+			return nil, errors.New(fmt.Sprintf("error demarshalling agreement history summary: %v, error: %v", string(sBytes), err))
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries, rows.Err()
+}
@@ -0,0 +1,116 @@
+package postgresql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+)
+
+// Constants for the SQL statements that are used to manage the dead letters.
+
+// Create the dead letter table. This table will not be partitioned, so that any agbot sharing the database can replay a
+// dead letter.
+const DEAD_LETTER_CREATE_MAIN_TABLE = `CREATE TABLE IF NOT EXISTS dead_letters (
+	id bigserial PRIMARY KEY,
+	node_id text NOT NULL,
+	letter jsonb NOT NULL,
+	last_failed bigint NOT NULL
+);
+CREATE INDEX IF NOT EXISTS node_id_index_on_dead_letters ON dead_letters(node_id);`
+
+const DEAD_LETTER_INSERT = `INSERT INTO dead_letters (node_id, letter, last_failed) VALUES ($1, $2, $3) RETURNING id;`
+
+const DEAD_LETTER_UPDATE = `UPDATE dead_letters SET letter = $2, last_failed = $3 WHERE id = $1;`
+
+const DEAD_LETTER_QUERY = `SELECT letter FROM dead_letters WHERE id = $1;`
+
+const DEAD_LETTER_QUERY_NODE = `SELECT letter FROM dead_letters WHERE node_id = $1 ORDER BY id;`
+
+const DEAD_LETTER_QUERY_ALL = `SELECT letter FROM dead_letters ORDER BY id;`
+
+const DEAD_LETTER_DELETE = `DELETE FROM dead_letters WHERE id = $1;`
+
+const DEAD_LETTER_DELETE_BEFORE = `DELETE FROM dead_letters WHERE last_failed < $1;`
+
+func (db *AgbotPostgresqlDB) SaveDeadLetter(letter *persistence.DeadLetter) error {
+	if letter.Id == 0 {
+		if lm, err := json.Marshal(letter); err != nil {
+			return errors.New(fmt.Sprintf("error marshalling dead letter %v, error: %v", letter, err))
+		} else if err := db.db.QueryRow(DEAD_LETTER_INSERT, letter.NodeId, lm, letter.LastFailed).Scan(&letter.Id); err != nil {
+			return errors.New(fmt.Sprintf("error inserting dead letter %v, error: %v", letter.ShortString(), err))
+		}
+	}
+
+	// The id is only known after the insert, so the letter is written again with its id.
+	if lm, err := json.Marshal(letter); err != nil {
+		return errors.New(fmt.Sprintf("error marshalling dead letter %v, error: %v", letter, err))
+	} else if _, err := db.db.Exec(DEAD_LETTER_UPDATE, letter.Id, lm, letter.LastFailed); err != nil {
+		return errors.New(fmt.Sprintf("error saving dead letter %v, error: %v", letter.ShortString(), err))
+	}
+	glog.V(5).Infof("Succeeded saving dead letter %v", letter.ShortString())
+	return nil
+}
+
+func (db *AgbotPostgresqlDB) FindDeadLetter(id uint64) (*persistence.DeadLetter, error) {
+	var lBytes []byte
+	if err := db.db.QueryRow(DEAD_LETTER_QUERY, id).Scan(&lBytes); err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.New(fmt.Sprintf("error scanning row for dead letter %v, error: %v", id, err))
+	}
+
+	letter := new(persistence.DeadLetter)
+	if err := json.Unmarshal(lBytes, letter); err != nil {
+		return nil, errors.New(fmt.Sprintf("error demarshalling dead letter %v, error: %v", id, err))
+	}
+	return letter, nil
+}
+
+// Return the dead letters of a node, or of all nodes when the node id is empty, oldest first.
+func (db *AgbotPostgresqlDB) FindDeadLetters(nodeId string) ([]persistence.DeadLetter, error) {
+	var rows *sql.Rows
+	var err error
+	if nodeId == "" {
+		rows, err = db.db.Query(DEAD_LETTER_QUERY_ALL)
+	} else {
+		rows, err = db.db.Query(DEAD_LETTER_QUERY_NODE, nodeId)
+	}
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error querying for dead letters of node %v, error: %v", nodeId, err))
+	}
+
+	defer rows.Close()
+	letters := make([]persistence.DeadLetter, 0)
+	for rows.Next() {
+		var lBytes []byte
+		var letter persistence.DeadLetter
+		if err := rows.Scan(&lBytes); err != nil {
+			return nil, errors.New(fmt.Sprintf("error scanning row for dead letters of node %v, error: %v", nodeId, err))
+		} else if err := json.Unmarshal(lBytes, &letter); err != nil {
+			return nil, errors.New(fmt.Sprintf("error demarshalling dead letter row %v, error: %v", string(lBytes), err))
+		}
+		letters = append(letters, letter)
+	}
+	return letters, rows.Err()
+}
+
+func (db *AgbotPostgresqlDB) DeleteDeadLetter(id uint64) error {
+	if _, err := db.db.Exec(DEAD_LETTER_DELETE, id); err != nil {
+		return errors.New(fmt.Sprintf("error deleting dead letter %v, error: %v", id, err))
+	}
+	return nil
+}
+
+// Delete the dead letters that last failed before the given time in seconds, returning how many were deleted.
+func (db *AgbotPostgresqlDB) DeleteDeadLettersBefore(failed uint64) (int, error) {
+	if res, err := db.db.Exec(DEAD_LETTER_DELETE_BEFORE, failed); err != nil {
+		return 0, errors.New(fmt.Sprintf("error deleting dead letters that failed before %v, error: %v", failed, err))
+	} else if n, err := res.RowsAffected(); err != nil {
+		return 0, errors.New(fmt.Sprintf("error counting the deleted dead letters, error: %v", err))
+	} else {
+		return int(n), nil
+	}
+}
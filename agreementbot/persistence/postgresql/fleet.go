@@ -0,0 +1,91 @@
+package postgresql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+)
+
+// Constants for the SQL statements that are used to manage fleets.
+
+// Create the fleets table. This table will not be partitioned as it is shared between agbots.
+const FLEET_CREATE_MAIN_TABLE = `CREATE TABLE IF NOT EXISTS fleets (
+	org text NOT NULL,
+	name text NOT NULL,
+	fleet jsonb NOT NULL,
+	updated timestamp with time zone DEFAULT current_timestamp,
+	PRIMARY KEY (org, name)
+);`
+
+const FLEET_UPSERT = `INSERT INTO fleets (org, name, fleet) VALUES ($1, $2, $3) ON CONFLICT (org, name) DO UPDATE SET fleet = EXCLUDED.fleet, updated = current_timestamp;`
+
+const FLEET_QUERY = `SELECT fleet FROM fleets WHERE org = $1 AND name = $2;`
+
+const FLEET_QUERY_ORG = `SELECT fleet FROM fleets WHERE org = $1;`
+
+const FLEET_QUERY_ALL = `SELECT fleet FROM fleets;`
+
+const FLEET_DELETE = `DELETE FROM fleets WHERE org = $1 AND name = $2;`
+
+func (db *AgbotPostgresqlDB) SaveFleet(fleet *persistence.Fleet) error {
+	if fm, err := json.Marshal(fleet); err != nil {
+		return errors.New(fmt.Sprintf("error marshalling fleet %v, error: %v", fleet, err))
+	} else if _, err := db.db.Exec(FLEET_UPSERT, fleet.Org, fleet.Name, fm); err != nil {
+		return errors.New(fmt.Sprintf("error saving fleet %v/%v, error: %v", fleet.Org, fleet.Name, err))
+	}
+	glog.V(5).Infof("Succeeded saving fleet %v", fleet.ShortString())
+	return nil
+}
+
+func (db *AgbotPostgresqlDB) FindFleet(org string, name string) (*persistence.Fleet, error) {
+	var fBytes []byte
+	if err := db.db.QueryRow(FLEET_QUERY, org, name).Scan(&fBytes); err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.New(fmt.Sprintf("error scanning row for fleet %v/%v, error: %v", org, name, err))
+	}
+
+	fleet := new(persistence.Fleet)
+	if err := json.Unmarshal(fBytes, fleet); err != nil {
+		return nil, errors.New(fmt.Sprintf("error demarshalling fleet %v/%v, error: %v", org, name, err))
+	}
+	return fleet, nil
+}
+
+// Return the fleets of an org, or of all orgs when the org is empty.
+func (db *AgbotPostgresqlDB) FindFleets(org string) ([]persistence.Fleet, error) {
+	var rows *sql.Rows
+	var err error
+	if org == "" {
+		rows, err = db.db.Query(FLEET_QUERY_ALL)
+	} else {
+		rows, err = db.db.Query(FLEET_QUERY_ORG, org)
+	}
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error querying for fleets in org %v, error: %v", org, err))
+	}
+
+	defer rows.Close()
+	fleets := make([]persistence.Fleet, 0)
+	for rows.Next() {
+		var fBytes []byte
+		var fleet persistence.Fleet
+		if err := rows.Scan(&fBytes); err != nil {
+			return nil, errors.New(fmt.Sprintf("error scanning row for fleets in org %v, error: %v", org, err))
+		} else if err := json.Unmarshal(fBytes, &fleet); err != nil {
+			return nil, errors.New(fmt.Sprintf("error demarshalling fleet row %v, error: %v", string(fBytes), err))
+		}
+		fleets = append(fleets, fleet)
+	}
+	return fleets, rows.Err()
+}
+
+func (db *AgbotPostgresqlDB) DeleteFleet(org string, name string) error {
+	if _, err := db.db.Exec(FLEET_DELETE, org, name); err != nil {
+		return errors.New(fmt.Sprintf("error deleting fleet %v/%v, error: %v", org, name, err))
+	}
+	return nil
+}
@@ -116,6 +116,27 @@ func (db *AgbotPostgresqlDB) Initialize(cfg *config.HorizonConfig) error {
 			return fmt.Errorf("unable to create ha workload add if not present function, error: %v", err)
 		}
 
+		// Create the fleets table. Do not partition it.
+		if _, err := db.db.Exec(FLEET_CREATE_MAIN_TABLE); err != nil {
+			return fmt.Errorf("unable to create fleets table, error: %v", err)
+		}
+
+		// Create the join tokens table. Do not partition it.
+		if _, err := db.db.Exec(JOIN_TOKEN_CREATE_MAIN_TABLE); err != nil {
+			return fmt.Errorf("unable to create join tokens table, error: %v", err)
+		}
+
+		// Create the dead letter table. Do not partition it.
+		if _, err := db.db.Exec(DEAD_LETTER_CREATE_MAIN_TABLE); err != nil {
+			return fmt.Errorf("unable to create dead letter table, error: %v", err)
+		}
+
+		// Create the agreement history tables. The history is partitioned by month, the table of a month is created when
+		// the first agreement of the month is saved.
+		if _, err := db.db.Exec(AGREEMENT_HISTORY_CREATE_MAIN_TABLE); err != nil {
+			return fmt.Errorf("unable to create agreement history tables, error: %v", err)
+		}
+
 		glog.V(3).Infof("Postgresql primary partition database tables exist.")
 
 		// Migrate the database tables if necessary. Extract the current schema version from the version table,
@@ -0,0 +1,135 @@
+package postgresql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+)
+
+// Constants for the SQL statements that are used to manage join tokens.
+
+// Create the join tokens table. This table will not be partitioned as it is shared between agbots.
+const JOIN_TOKEN_CREATE_MAIN_TABLE = `CREATE TABLE IF NOT EXISTS join_tokens (
+	org text NOT NULL,
+	id text NOT NULL,
+	token jsonb NOT NULL,
+	updated timestamp with time zone DEFAULT current_timestamp,
+	PRIMARY KEY (org, id)
+);`
+
+const JOIN_TOKEN_UPSERT = `INSERT INTO join_tokens (org, id, token) VALUES ($1, $2, $3) ON CONFLICT (org, id) DO UPDATE SET token = EXCLUDED.token, updated = current_timestamp;`
+
+const JOIN_TOKEN_QUERY = `SELECT token FROM join_tokens WHERE org = $1 AND id = $2;`
+
+const JOIN_TOKEN_QUERY_FOR_UPDATE = `SELECT token FROM join_tokens WHERE org = $1 AND id = $2 FOR UPDATE;`
+
+const JOIN_TOKEN_QUERY_ORG = `SELECT token FROM join_tokens WHERE org = $1;`
+
+const JOIN_TOKEN_QUERY_ALL = `SELECT token FROM join_tokens;`
+
+const JOIN_TOKEN_DELETE = `DELETE FROM join_tokens WHERE org = $1 AND id = $2;`
+
+const JOIN_TOKEN_DELETE_EXPIRED = `DELETE FROM join_tokens WHERE (token->>'expires')::bigint < $1;`
+
+func (db *AgbotPostgresqlDB) SaveJoinToken(token *persistence.JoinToken) error {
+	if tm, err := json.Marshal(token); err != nil {
+		return errors.New(fmt.Sprintf("error marshalling join token %v, error: %v", token, err))
+	} else if _, err := db.db.Exec(JOIN_TOKEN_UPSERT, token.Org, token.Id, tm); err != nil {
+		return errors.New(fmt.Sprintf("error saving join token %v/%v, error: %v", token.Org, token.Id, err))
+	}
+	glog.V(5).Infof("Succeeded saving join token %v", token.ShortString())
+	return nil
+}
+
+func (db *AgbotPostgresqlDB) FindJoinToken(org string, id string) (*persistence.JoinToken, error) {
+	return scanJoinToken(db.db.QueryRow(JOIN_TOKEN_QUERY, org, id), org, id)
+}
+
+func scanJoinToken(row *sql.Row, org string, id string) (*persistence.JoinToken, error) {
+	var tBytes []byte
+	if err := row.Scan(&tBytes); err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.New(fmt.Sprintf("error scanning row for join token %v/%v, error: %v", org, id, err))
+	}
+
+	token := new(persistence.JoinToken)
+	if err := json.Unmarshal(tBytes, token); err != nil {
+		return nil, errors.New(fmt.Sprintf("error demarshalling join token %v/%v, error: %v", org, id, err))
+	}
+	return token, nil
+}
+
+// Return the join tokens of an org, or of all orgs when the org is empty.
+func (db *AgbotPostgresqlDB) FindJoinTokens(org string) ([]persistence.JoinToken, error) {
+	var rows *sql.Rows
+	var err error
+	if org == "" {
+		rows, err = db.db.Query(JOIN_TOKEN_QUERY_ALL)
+	} else {
+		rows, err = db.db.Query(JOIN_TOKEN_QUERY_ORG, org)
+	}
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error querying for join tokens in org %v, error: %v", org, err))
+	}
+
+	defer rows.Close()
+	tokens := make([]persistence.JoinToken, 0)
+	for rows.Next() {
+		var tBytes []byte
+		var token persistence.JoinToken
+		if err := rows.Scan(&tBytes); err != nil {
+			return nil, errors.New(fmt.Sprintf("error scanning row for join tokens in org %v, error: %v", org, err))
+		} else if err := json.Unmarshal(tBytes, &token); err != nil {
+			return nil, errors.New(fmt.Sprintf("error demarshalling join token row %v, error: %v", string(tBytes), err))
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// The row of the join token is locked until the transaction ends, so that the agbots sharing the database do not let
+// more nodes join than the token allows.
+func (db *AgbotPostgresqlDB) UpdateJoinToken(org string, id string, fn func(*persistence.JoinToken) error) (*persistence.JoinToken, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	token, err := scanJoinToken(tx.QueryRow(JOIN_TOKEN_QUERY_FOR_UPDATE, org, id), org, id)
+	if err != nil || token == nil {
+		return nil, err
+	} else if err := fn(token); err != nil {
+		return nil, err
+	}
+
+	if tm, err := json.Marshal(token); err != nil {
+		return nil, errors.New(fmt.Sprintf("error marshalling join token %v, error: %v", token, err))
+	} else if _, err := tx.Exec(JOIN_TOKEN_UPSERT, token.Org, token.Id, tm); err != nil {
+		return nil, errors.New(fmt.Sprintf("error saving join token %v/%v, error: %v", token.Org, token.Id, err))
+	}
+	return token, tx.Commit()
+}
+
+func (db *AgbotPostgresqlDB) DeleteJoinToken(org string, id string) error {
+	if _, err := db.db.Exec(JOIN_TOKEN_DELETE, org, id); err != nil {
+		return errors.New(fmt.Sprintf("error deleting join token %v/%v, error: %v", org, id, err))
+	}
+	return nil
+}
+
+func (db *AgbotPostgresqlDB) DeleteExpiredJoinTokens(before uint64) (int, error) {
+	res, err := db.db.Exec(JOIN_TOKEN_DELETE_EXPIRED, before)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error deleting join tokens expired before %v, error: %v", before, err))
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error getting the number of join tokens expired before %v, error: %v", before, err))
+	}
+	return int(deleted), nil
+}
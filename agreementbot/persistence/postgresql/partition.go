@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/golang/glog"
+	"time"
 )
 
 // Constants for the SQL statements that are used to work with partitions. Each agbot owns a single partition. Each agbot has
@@ -48,6 +49,11 @@ const PARTITION_QUIESCE = `UPDATE partitions SET owner = NULL, heartbeat = NULL
 
 const PARTITION_DELETE = `DELETE FROM partitions WHERE id = $1;`
 
+const PARTITION_LIVE = `SELECT id FROM partitions
+	WHERE owner IS NOT NULL AND heartbeat IS NOT NULL
+		AND (SELECT EXTRACT ('epoch' FROM (SELECT AGE(current_timestamp, heartbeat)))) <= $1
+	ORDER BY id;`
+
 // The complexity of the WHERE clause should not be underestimated. Each row is scanned whlie the table is locked
 // so we are sure that no other agbot can even read this table until this query is complete. This query runs in a
 // transaction that is controlled by the functions in this package.
@@ -200,6 +206,7 @@ func (db *AgbotPostgresqlDB) VerifyPartitions(partitions []string) ([]string, er
 
 // Update the hearbeat for our partition.
 func (db *AgbotPostgresqlDB) HeartbeatPartition() error {
+	defer observe("heartbeat_partition", time.Now())
 
 	if res, err := db.db.Exec(PARTITION_HEARTBEAT, db.PrimaryPartition(), db.identity); err != nil {
 		return errors.New(fmt.Sprintf("AgreementBot %v unable to heartbeat, error: %v", db.identity, err))
@@ -219,6 +226,7 @@ func (db *AgbotPostgresqlDB) HeartbeatPartition() error {
 
 // Retrieve the heartbeat timestamp for a given partition.
 func (db *AgbotPostgresqlDB) GetHeartbeat() (uint64, error) {
+	defer observe("get_heartbeat", time.Now())
 
 	var hb float64
 	if err := db.db.QueryRow(PARTITION_GET_HEARTBEAT, db.PrimaryPartition()).Scan(&hb); err != nil {
@@ -284,3 +292,27 @@ func (db *AgbotPostgresqlDB) MovePartition(timeout uint64) (bool, error) {
 	// We found a partition and moved all the records.
 	return true, nil
 }
+
+// Locate the partitions that are owned by a running agbot, i.e. an agbot that has heartbeated within the timeout. These
+// are the agbots that share the nodes when the nodes are sharded across the agbots.
+func (db *AgbotPostgresqlDB) FindLivePartitions(timeout uint64) ([]string, error) {
+
+	rows, err := db.db.Query(PARTITION_LIVE, timeout)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error querying for live partitions, error: %v", err))
+	}
+	defer rows.Close()
+
+	partitions := make([]string, 0, 5)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.New(fmt.Sprintf("error scanning live partition, error: %v", err))
+		}
+		partitions = append(partitions, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error iterating live partitions, error: %v", err))
+	}
+	return partitions, nil
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/agreementbot/persistence"
 	"strings"
+	"time"
 )
 
 // Constants for the SQL statements that are used to work with workload usages. These records are used to track what workload
@@ -180,11 +181,13 @@ func (db *AgbotPostgresqlDB) internalFindSingleWorkloadUsageByDeviceAndPolicyNam
 }
 
 func (db *AgbotPostgresqlDB) FindSingleWorkloadUsageByDeviceAndPolicyName(deviceid string, policyName string) (*persistence.WorkloadUsage, error) {
+	defer observe("find_workload_usage", time.Now())
 	wu, _, err := db.internalFindSingleWorkloadUsageByDeviceAndPolicyName(nil, deviceid, policyName)
 	return wu, err
 }
 
 func (db *AgbotPostgresqlDB) FindWorkloadUsages(filters []persistence.WUFilter) ([]persistence.WorkloadUsage, error) {
+	defer observe("find_workload_usages", time.Now())
 	wus := make([]persistence.WorkloadUsage, 0, 100)
 
 	for _, currentPartition := range db.AllPartitions() {
@@ -231,6 +234,7 @@ func (db *AgbotPostgresqlDB) FindWorkloadUsages(filters []persistence.WUFilter)
 }
 
 func (db *AgbotPostgresqlDB) NewWorkloadUsage(deviceId string, policy string, policyName string, priority int, retryDurationS int, verifiedDurationS int, reqsNotMet bool, agid string) error {
+	defer observe("insert_workload_usage", time.Now())
 	if wlUsage, err := persistence.NewWorkloadUsage(deviceId, policy, policyName, priority, retryDurationS, verifiedDurationS, reqsNotMet, agid); err != nil {
 		return err
 	} else if existing, partition, err := db.internalFindSingleWorkloadUsageByDeviceAndPolicyName(nil, deviceId, policyName); err != nil {
@@ -306,6 +310,7 @@ func (db *AgbotPostgresqlDB) DisableRollbackChecking(deviceid string, policyName
 }
 
 func (db *AgbotPostgresqlDB) DeleteWorkloadUsage(deviceid string, policyName string) error {
+	defer observe("delete_workload_usage", time.Now())
 	tx, err := db.db.Begin()
 	if err != nil {
 		return err
@@ -331,6 +336,7 @@ func (db *AgbotPostgresqlDB) SingleWorkloadUsageUpdate(deviceid string, policyNa
 }
 
 func (db *AgbotPostgresqlDB) wrapWUTransaction(deviceid string, policyName string, updated *persistence.WorkloadUsage) error {
+	defer observe("update_workload_usage", time.Now())
 
 	if tx, err := db.db.Begin(); err != nil {
 		return err
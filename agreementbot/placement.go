@@ -0,0 +1,76 @@
+package agreementbot
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/policy"
+	"strings"
+)
+
+// The placement constraints of a deployment policy refer to the dynamic state of the node, so that the agbot does not
+// place the service on nodes that are already saturated. They are never sent to the node, the agbot evaluates them when
+// it is about to make a new agreement, against the node properties and these properties:
+//
+//	openhorizon.agreementCount - the number of agreements the agbot currently has with the node
+//	openhorizon.freeMemory     - the free memory in MB, as last published by the node with its status
+//
+// The free memory is only known for the nodes configured to publish it. A constraint on the free memory of a node that
+// does not publish it is not satisfied. The existing agreements are not cancelled when the state of a node changes.
+func placementAllowsAgreement(db persistence.AgbotDatabase, ec exchange.ExchangeContext, org string, pol *policy.Policy, deviceId string, props externalpolicy.PropertyList) (bool, string) {
+	if pol.PatternId != "" || businessPolManager == nil {
+		return true, ""
+	}
+
+	_, polName := cutil.SplitOrgSpecUrl(pol.Header.Name)
+	placement := businessPolManager.GetPlacement(org, polName)
+	if len(placement) == 0 {
+		return true, ""
+	}
+
+	agCount, err := countNodeAgreements(db, deviceId)
+	if err != nil {
+		glog.Errorf(AWlogString(fmt.Sprintf("unable to count the agreements of node %v, error %v", deviceId, err)))
+		return false, fmt.Sprintf("Unable to count the agreements of node %v.", deviceId)
+	}
+
+	var freeMemory *int64
+	if status, err := exchange.GetHTTPNodeStatusHandler(exchangeFederation.ForOrg(ec, exchange.GetOrg(deviceId)))(deviceId); err != nil {
+		glog.Warningf(AWlogString(fmt.Sprintf("unable to read the status of node %v, the free memory is unknown, error %v", deviceId, err)))
+	} else if status != nil {
+		freeMemory = status.FreeMemory
+	}
+
+	if err := checkPlacement(placement, props, agCount, freeMemory); err != nil {
+		return false, fmt.Sprintf("Node %v does not satisfy the placement constraints [%v] of the deployment policy: %v", deviceId, strings.Join(placement, ", "), err)
+	}
+	return true, ""
+}
+
+// Returns the number of agreements the agbot currently has with the node, for all the agreement protocols.
+func countNodeAgreements(db persistence.AgbotDatabase, deviceId string) (int, error) {
+	count := 0
+	for _, agp := range policy.AllAgreementProtocols() {
+		if ags, err := db.FindAgreements([]persistence.AFilter{persistence.UnarchivedAFilter(), persistence.DevAFilter(deviceId)}, agp); err != nil {
+			return 0, err
+		} else {
+			count += len(ags)
+		}
+	}
+	return count, nil
+}
+
+// Check the placement constraints against the node properties and the dynamic state of the node. The free memory is nil
+// when the node does not publish it.
+func checkPlacement(placement externalpolicy.ConstraintExpression, props externalpolicy.PropertyList, agCount int, freeMemory *int64) error {
+	allProps := make(externalpolicy.PropertyList, 0, len(props)+2)
+	allProps = append(allProps, props...)
+	allProps = append(allProps, *externalpolicy.Property_Factory(externalpolicy.PROP_NODE_AGREEMENT_COUNT, float64(agCount)))
+	if freeMemory != nil {
+		allProps = append(allProps, *externalpolicy.Property_Factory(externalpolicy.PROP_NODE_FREE_MEMORY, float64(*freeMemory)))
+	}
+	return placement.IsSatisfiedBy(allProps)
+}
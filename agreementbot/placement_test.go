@@ -0,0 +1,36 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"github.com/open-horizon/anax/externalpolicy"
+	_ "github.com/open-horizon/anax/externalpolicy/text_language"
+	"testing"
+)
+
+func Test_checkPlacement(t *testing.T) {
+	props := externalpolicy.PropertyList{*externalpolicy.Property_Factory("zone", "east")}
+	free := int64(1024)
+	low := int64(256)
+
+	placement := externalpolicy.ConstraintExpression{"openhorizon.agreementCount < 3 && openhorizon.freeMemory >= 512"}
+	if err := checkPlacement(placement, props, 2, &free); err != nil {
+		t.Errorf("placement should be satisfied, error: %v", err)
+	}
+	if err := checkPlacement(placement, props, 3, &free); err == nil {
+		t.Errorf("placement should not be satisfied with too many agreements")
+	}
+	if err := checkPlacement(placement, props, 0, &low); err == nil {
+		t.Errorf("placement should not be satisfied with too little free memory")
+	}
+	if err := checkPlacement(placement, props, 0, nil); err == nil {
+		t.Errorf("placement should not be satisfied when the node does not publish its free memory")
+	}
+
+	// the node properties can be used together with the dynamic state of the node
+	placement = externalpolicy.ConstraintExpression{"zone == east && openhorizon.agreementCount == 0"}
+	if err := checkPlacement(placement, props, 0, nil); err != nil {
+		t.Errorf("placement should be satisfied, error: %v", err)
+	}
+}
@@ -367,7 +367,7 @@ func (w *BaseAgreementWorker) HandleMMSObjectPolicy(cph ConsumerProtocolHandler,
 		// if the agreement is for a service that is compatible (including arch and version range) with a service in the new policy
 		if w.findCompatibleServices(&agreement, &newPolicy, workerId, w.config.ArchSynonyms) {
 
-			_, nodePolicy, err := compcheck.GetNodePolicy(exchange.GetHTTPNodePolicyHandler(w), agreement.DeviceId, nil)
+			_, nodePolicy, err := compcheck.GetNodePolicy(exchange.GetHTTPNodePolicyHandler(exchangeFederation.ForOrg(w, exchange.GetOrg(agreement.DeviceId))), agreement.DeviceId, nil)
 
 			if err != nil {
 				glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("Object Policy error %v", err)))
@@ -405,7 +405,7 @@ func (w *BaseAgreementWorker) HandleMMSObjectPolicy(cph ConsumerProtocolHandler,
 					if cutil.SliceContains(destNodes, exchange.GetId(agreement.DeviceId)) {
 
 						// find ALL the services running on the node (even the services for which this agbot doesnt have an agreement)
-						ns, err := exchange.GetHTTPNodeStatusHandler(w)(agreement.DeviceId)
+						ns, err := exchange.GetHTTPNodeStatusHandler(exchangeFederation.ForOrg(w, exchange.GetOrg(agreement.DeviceId)))(agreement.DeviceId)
 						if err != nil {
 							glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("Object Policy unable to get node status, error %v", err)))
 							continue
@@ -0,0 +1,271 @@
+package agreementbot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The agreement rate limits of the agbot, in agreements initiated per minute. A limit of zero means no limit.
+type RateLimits struct {
+	OrgDefault    int            `json:"orgDefault"`         // The limit of each org that has no limit of its own.
+	PolicyDefault int            `json:"policyDefault"`      // The limit of each deployment policy or pattern that has no limit of its own.
+	Orgs          map[string]int `json:"orgs,omitempty"`     // The limits of orgs, keyed by org.
+	Policies      map[string]int `json:"policies,omitempty"` // The limits of deployment policies and patterns, keyed by org qualified name.
+}
+
+func (r RateLimits) String() string {
+	return fmt.Sprintf("OrgDefault: %v, PolicyDefault: %v, Orgs: %v, Policies: %v", r.OrgDefault, r.PolicyDefault, r.Orgs, r.Policies)
+}
+
+func (r *RateLimits) Validate() error {
+	if r.OrgDefault < 0 || r.PolicyDefault < 0 {
+		return errors.New("the default limits cannot be negative")
+	}
+	for org, limit := range r.Orgs {
+		if org == "" || strings.Contains(org, "/") {
+			return fmt.Errorf("%v is not an org", org)
+		} else if limit < 0 {
+			return fmt.Errorf("the limit of org %v cannot be negative", org)
+		}
+	}
+	for name, limit := range r.Policies {
+		if parts := strings.Split(name, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("policy %v is not qualified by its org", name)
+		} else if limit < 0 {
+			return fmt.Errorf("the limit of policy %v cannot be negative", name)
+		}
+	}
+	return nil
+}
+
+func (r *RateLimits) orgLimit(org string) int {
+	if limit, ok := r.Orgs[org]; ok {
+		return limit
+	}
+	return r.OrgDefault
+}
+
+func (r *RateLimits) policyLimit(policyName string) int {
+	if limit, ok := r.Policies[policyName]; ok {
+		return limit
+	}
+	return r.PolicyDefault
+}
+
+// A token bucket holding up to a minute of agreements. It is refilled continuously at the rate of the limit.
+type tokenBucket struct {
+	perMinute int
+	tokens    float64
+	last      time.Time
+}
+
+func newTokenBucket(perMinute int, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		perMinute: perMinute,
+		tokens:    float64(perMinute),
+		last:      now,
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(b.perMinute), b.tokens+elapsed*float64(b.perMinute)/60)
+		b.last = now
+	}
+}
+
+// Change the limit of the bucket, keeping the tokens it has up to the new size.
+func (b *tokenBucket) setLimit(perMinute int, now time.Time) {
+	b.refill(now)
+	b.perMinute = perMinute
+	b.tokens = math.Min(float64(perMinute), b.tokens)
+}
+
+// Returns how long until the bucket has a token.
+func (b *tokenBucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) * 60 / float64(b.perMinute) * float64(time.Second))
+}
+
+func (b *tokenBucket) full() bool {
+	return b.tokens >= float64(b.perMinute)
+}
+
+// The rate limiter caps the number of agreements initiated per minute for each org and for each deployment policy or
+// pattern, so that a large rollout in one org cannot keep the agreement workers of the agbot from the other orgs. Each
+// org and policy with a limit has a token bucket, an agreement takes a token from both. A node that is not negotiated
+// because a bucket is empty is searched again when the bucket has a token.
+//
+// The limits start from the agbot configuration and can be changed through the agbot API. The changes are kept in memory,
+// the configured limits are used again when the agbot restarts.
+type RateLimiter struct {
+	lock       sync.Mutex
+	nodeSearch *NodeSearch
+	limits     RateLimits
+	orgs       map[string]*tokenBucket // The buckets of the orgs, keyed by org.
+	policies   map[string]*tokenBucket // The buckets of the policies, keyed by org qualified name.
+	nodes      map[string]int64        // The nodes waiting for a token, keyed by org qualified node id, with the time it is available.
+}
+
+func NewRateLimiter(orgLimit int, policyLimit int, nodeSearch *NodeSearch) *RateLimiter {
+	return &RateLimiter{
+		nodeSearch: nodeSearch,
+		limits:     RateLimits{OrgDefault: orgLimit, PolicyDefault: policyLimit},
+		orgs:       make(map[string]*tokenBucket),
+		policies:   make(map[string]*tokenBucket),
+		nodes:      make(map[string]int64),
+	}
+}
+
+// Returns true if an agreement for a policy of an org can be initiated with a node now, and takes a token for it.
+// Otherwise the node is searched again when there is a token, and the time that happens is returned.
+func (rl *RateLimiter) AllowsAgreement(org string, policyName string, deviceId string) (bool, time.Time) {
+	if rl == nil {
+		return true, time.Time{}
+	}
+	return rl.allowsAgreement(org, policyName, deviceId, time.Now())
+}
+
+func (rl *RateLimiter) allowsAgreement(org string, policyName string, deviceId string, now time.Time) (bool, time.Time) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	buckets := make([]*tokenBucket, 0, 2)
+	if b := rl.bucket(rl.orgs, org, rl.limits.orgLimit(org), now); b != nil {
+		buckets = append(buckets, b)
+	}
+	if b := rl.bucket(rl.policies, policyName, rl.limits.policyLimit(policyName), now); b != nil {
+		buckets = append(buckets, b)
+	}
+
+	// Only take the tokens when all the buckets have one.
+	var wait time.Duration
+	for _, b := range buckets {
+		if w := b.wait(); w > wait {
+			wait = w
+		}
+	}
+	if wait == 0 {
+		for _, b := range buckets {
+			b.tokens--
+		}
+		return true, now
+	}
+
+	next := now.Add(wait)
+	if at, ok := rl.nodes[deviceId]; !ok || next.Unix() < at {
+		rl.nodes[deviceId] = next.Unix()
+	}
+	glog.V(3).Infof(RLlogString(fmt.Sprintf("agreement rate of org %v or policy %v exceeded, negotiating with node %v at %v", org, policyName, deviceId, next)))
+	return false, next
+}
+
+// Returns the refilled bucket of a key, creating it if needed, or nil when the key has no limit.
+func (rl *RateLimiter) bucket(buckets map[string]*tokenBucket, key string, limit int, now time.Time) *tokenBucket {
+	if limit == 0 {
+		return nil
+	}
+	b, ok := buckets[key]
+	if !ok {
+		b = newTokenBucket(limit, now)
+		buckets[key] = b
+	} else if b.perMinute != limit {
+		b.setLimit(limit, now)
+	} else {
+		b.refill(now)
+	}
+	return b
+}
+
+func (rl *RateLimiter) Limits() RateLimits {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	limits := RateLimits{OrgDefault: rl.limits.OrgDefault, PolicyDefault: rl.limits.PolicyDefault}
+	if len(rl.limits.Orgs) != 0 {
+		limits.Orgs = make(map[string]int)
+		for org, limit := range rl.limits.Orgs {
+			limits.Orgs[org] = limit
+		}
+	}
+	if len(rl.limits.Policies) != 0 {
+		limits.Policies = make(map[string]int)
+		for name, limit := range rl.limits.Policies {
+			limits.Policies[name] = limit
+		}
+	}
+	return limits
+}
+
+// Replace the limits. The buckets keep the tokens they have, up to their new size.
+func (rl *RateLimiter) SetLimits(limits RateLimits) error {
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	rl.limits = limits
+	now := time.Now()
+	for org, b := range rl.orgs {
+		if limit := limits.orgLimit(org); limit == 0 {
+			delete(rl.orgs, org)
+		} else {
+			b.setLimit(limit, now)
+		}
+	}
+	for name, b := range rl.policies {
+		if limit := limits.policyLimit(name); limit == 0 {
+			delete(rl.policies, name)
+		} else {
+			b.setLimit(limit, now)
+		}
+	}
+	glog.V(3).Infof(RLlogString(fmt.Sprintf("agreement rate limits changed to %v", limits)))
+	return nil
+}
+
+// Give the nodes that have a token again to the node search, and forget the buckets that are full since they are the
+// same as new ones. This function is called by the rate limit governance subworker.
+func (rl *RateLimiter) Govern() {
+	nodes := rl.release(time.Now())
+	if len(nodes) != 0 {
+		glog.V(3).Infof(RLlogString(fmt.Sprintf("tokens available for nodes %v", nodes)))
+		rl.nodeSearch.AddDeferredNodes(nodes)
+	}
+}
+
+func (rl *RateLimiter) release(now time.Time) []string {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	nodes := make([]string, 0)
+	for id, at := range rl.nodes {
+		if at <= now.Unix() {
+			nodes = append(nodes, id)
+			delete(rl.nodes, id)
+		}
+	}
+
+	for _, buckets := range []map[string]*tokenBucket{rl.orgs, rl.policies} {
+		for key, b := range buckets {
+			if b.refill(now); b.full() {
+				delete(buckets, key)
+			}
+		}
+	}
+	return nodes
+}
+
+// Logging function
+var RLlogString = func(v interface{}) string {
+	return fmt.Sprintf("Rate Limiter: %v", v)
+}
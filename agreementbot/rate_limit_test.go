@@ -0,0 +1,121 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_tokenBucket(t *testing.T) {
+	now := time.Unix(1000, 0)
+	b := newTokenBucket(6, now)
+
+	for i := 0; i < 6; i++ {
+		if b.wait() != 0 {
+			t.Fatalf("token %v should be available", i)
+		}
+		b.tokens--
+	}
+	if w := b.wait(); w != 10*time.Second {
+		t.Errorf("expected to wait 10 seconds for a token, got %v", w)
+	}
+
+	b.refill(now.Add(5 * time.Second))
+	if w := b.wait(); w != 5*time.Second {
+		t.Errorf("expected to wait 5 seconds for a token, got %v", w)
+	}
+
+	b.refill(now.Add(10 * time.Minute))
+	if !b.full() || b.tokens != 6 {
+		t.Errorf("the bucket should hold one minute of tokens, has %v", b.tokens)
+	}
+
+	b.setLimit(3, now.Add(10*time.Minute))
+	if b.tokens != 3 {
+		t.Errorf("the bucket should keep the tokens up to its new size, has %v", b.tokens)
+	}
+}
+
+func Test_RateLimiter_allowsAgreement(t *testing.T) {
+	now := time.Unix(1000, 0)
+	rl := NewRateLimiter(3, 0, nil)
+	if err := rl.SetLimits(RateLimits{OrgDefault: 3, Policies: map[string]int{"org1/pol2": 1}}); err != nil {
+		t.Fatalf("unexpected error setting the limits: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := rl.allowsAgreement("org1", "org1/pol1", "org1/node", now); !ok {
+			t.Fatalf("agreement %v should be allowed", i)
+		}
+	}
+	if ok, next := rl.allowsAgreement("org1", "org1/pol1", "org1/node4", now); ok {
+		t.Errorf("the org limit should be exceeded")
+	} else if next != now.Add(20*time.Second) {
+		t.Errorf("expected the next token at %v, got %v", now.Add(20*time.Second), next)
+	}
+
+	// Another org has its own bucket.
+	if ok, _ := rl.allowsAgreement("org2", "org2/pol1", "org2/node", now); !ok {
+		t.Errorf("the agreement of another org should be allowed")
+	}
+
+	// An empty policy bucket does not take a token from the org.
+	if ok, _ := rl.allowsAgreement("org2", "org2/pol2", "org2/node", now); !ok {
+		t.Errorf("the agreement of policy org2/pol2 should be allowed")
+	}
+	if ok, _ := rl.allowsAgreement("org3", "org1/pol2", "org3/node", now); !ok {
+		t.Errorf("the first agreement of policy org1/pol2 should be allowed")
+	}
+	if ok, _ := rl.allowsAgreement("org3", "org1/pol2", "org3/node", now); ok {
+		t.Errorf("the limit of policy org1/pol2 should be exceeded")
+	}
+	if rl.orgs["org3"].tokens != 2 {
+		t.Errorf("the org should have kept its token, has %v", rl.orgs["org3"].tokens)
+	}
+
+	// The held nodes are released when there is a token.
+	if nodes := rl.release(now.Add(10 * time.Second)); len(nodes) != 0 {
+		t.Errorf("no node should be released yet, got %v", nodes)
+	} else if nodes := rl.release(now.Add(time.Minute)); len(nodes) != 2 {
+		t.Errorf("expected 2 nodes to be released, got %v", nodes)
+	} else if len(rl.orgs) != 0 || len(rl.policies) != 0 {
+		t.Errorf("the full buckets should be forgotten, have %v and %v", rl.orgs, rl.policies)
+	}
+}
+
+func Test_RateLimiter_SetLimits(t *testing.T) {
+	now := time.Now()
+	rl := NewRateLimiter(10, 0, nil)
+	rl.allowsAgreement("org1", "org1/pol1", "org1/node", now)
+
+	for _, limits := range []RateLimits{
+		{OrgDefault: -1},
+		{Orgs: map[string]int{"org1/x": 1}},
+		{Policies: map[string]int{"pol1": 1}},
+		{Policies: map[string]int{"org1/pol1": -2}},
+	} {
+		if err := rl.SetLimits(limits); err == nil {
+			t.Errorf("expected an error for %v", limits)
+		}
+	}
+	if l := rl.Limits(); l.OrgDefault != 10 {
+		t.Errorf("the limits should not have changed, got %v", l)
+	}
+
+	if err := rl.SetLimits(RateLimits{OrgDefault: 10, Orgs: map[string]int{"org1": 0}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if _, ok := rl.orgs["org1"]; ok {
+		t.Errorf("the bucket of an org without a limit should be removed")
+	} else if ok, _ := rl.allowsAgreement("org1", "org1/pol1", "org1/node", now); !ok {
+		t.Errorf("the agreements of an org without a limit should be allowed")
+	}
+}
+
+func Test_RateLimiter_nil(t *testing.T) {
+	var rl *RateLimiter
+	if ok, _ := rl.AllowsAgreement("org1", "org1/pol1", "org1/node"); !ok {
+		t.Errorf("a nil rate limiter should allow all agreements")
+	}
+}
@@ -0,0 +1,197 @@
+package agreementbot
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/businesspolicy"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/policy"
+	"sync"
+	"time"
+)
+
+// The state of the rollout of the new service version of a business policy.
+type rolloutState struct {
+	started  uint64          // The time the rollout started, which is when the business policy changed.
+	upgraded map[string]bool // The nodes that were given the new service version.
+	halted   bool            // The rollout was halted because too many of the upgraded nodes failed to run the new version.
+}
+
+// The rollout controller rolls the highest priority service version of a business policy out to the nodes in waves, as
+// configured in the rollout section of the policy. The nodes outside of the current wave are given the next priority
+// version. A node that cannot run the new version falls back to the next priority version through the workload usage
+// retries, that is counted as a failed upgrade. When the failed upgrades exceed the maximum failure rate, the rollout is
+// halted and all the nodes are rolled back to the next priority version until the policy changes again.
+//
+// The state of the rollouts is kept in memory. Each agbot controls the rollout for the nodes in its own partition.
+type RolloutController struct {
+	lock   sync.Mutex
+	db     persistence.AgbotDatabase
+	msgs   chan events.Message      // Outgoing internal event messages are placed here.
+	states map[string]*rolloutState // The rollouts in progress, keyed by the org qualified business policy name.
+}
+
+func NewRolloutController(db persistence.AgbotDatabase, msgs chan events.Message) *RolloutController {
+	return &RolloutController{
+		db:     db,
+		msgs:   msgs,
+		states: make(map[string]*rolloutState),
+	}
+}
+
+// Return the bucket of a node for a policy, between 0 and 99. The node gets the new version when its bucket is below the
+// percentage of the current wave. The policy name is part of the hash so that the same nodes are not always the first
+// ones to get a new version.
+func rolloutBucket(deviceId string, policyName string) int {
+	return int(shardHash(policyName+"#"+deviceId) % 100)
+}
+
+// Return the workload with the new version, which is the highest priority workload, and the workload with the next priority
+// version that the nodes keep until the new version is rolled out to them.
+func rolloutWorkloads(pol *policy.Policy) (*policy.Workload, *policy.Workload) {
+	var newWl, stableWl *policy.Workload
+	for ix, wl := range pol.Workloads {
+		if wl.Priority.PriorityValue == 0 {
+			continue
+		}
+		if newWl == nil || wl.Priority.PriorityValue < newWl.Priority.PriorityValue {
+			stableWl = newWl
+			newWl = &pol.Workloads[ix]
+		} else if stableWl == nil || wl.Priority.PriorityValue < stableWl.Priority.PriorityValue {
+			stableWl = &pol.Workloads[ix]
+		}
+	}
+	if newWl == nil || stableWl == nil {
+		return nil, nil
+	}
+	return newWl, stableWl
+}
+
+// Get the state of the rollout of a policy, starting a new rollout if the policy changed. The caller holds the lock.
+func (rc *RolloutController) getState(policyName string, started uint64) *rolloutState {
+	if rs, ok := rc.states[policyName]; ok && rs.started == started {
+		return rs
+	}
+	rs := &rolloutState{
+		started:  started,
+		upgraded: make(map[string]bool),
+	}
+	rc.states[policyName] = rs
+	glog.V(3).Infof(RClogString(fmt.Sprintf("started rollout of %v at %v", policyName, started)))
+	return rs
+}
+
+// Returns true if the node is in the waves of the rollout that have started.
+func (rs *rolloutState) allows(rollout *businesspolicy.RolloutPolicy, deviceId string, policyName string, now uint64) bool {
+	return !rs.halted && rolloutBucket(deviceId, policyName) < rollout.WavePercentage(rs.started, now)
+}
+
+// Return the workload to propose to a node. When the chosen workload is the new version of a policy being rolled out and
+// the node is not in the started waves, the next priority version is returned instead. Otherwise the chosen workload is
+// returned.
+func (rc *RolloutController) SelectWorkload(org string, pol *policy.Policy, deviceId string, workload *policy.Workload) *policy.Workload {
+	if rc == nil || workload == nil || pol.PatternId != "" {
+		return workload
+	}
+
+	_, polName := cutil.SplitOrgSpecUrl(pol.Header.Name)
+	rollout, started := businessPolManager.GetRolloutPolicy(org, polName)
+	if rollout == nil {
+		return workload
+	}
+
+	newWl, stableWl := rolloutWorkloads(pol)
+	if newWl == nil || workload.Priority.PriorityValue != newWl.Priority.PriorityValue {
+		return workload
+	}
+
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	rs := rc.getState(pol.Header.Name, started)
+	if rs.allows(rollout, deviceId, pol.Header.Name, uint64(time.Now().Unix())) {
+		rs.upgraded[deviceId] = true
+		return workload
+	}
+
+	glog.V(5).Infof(RClogString(fmt.Sprintf("node %v gets version %v of %v, the rollout of version %v has not reached it", deviceId, stableWl.Version, pol.Header.Name, newWl.Version)))
+	return stableWl
+}
+
+// Check the failed upgrades of the rollouts in progress and move the nodes to the version they should run. The nodes
+// that entered a started wave are upgraded and, when a rollout is halted, the upgraded nodes are rolled back. The nodes
+// are moved by cancelling their agreement, the new agreement is made with the version returned by SelectWorkload.
+func (rc *RolloutController) Govern() {
+
+	now := uint64(time.Now().Unix())
+	inProgress := make(map[string]bool)
+
+	for _, org := range businessPolManager.GetAllPolicyOrgs() {
+		for _, pol := range businessPolManager.GetAllPoliciesOrderedForOrg(org, false) {
+
+			_, polName := cutil.SplitOrgSpecUrl(pol.Header.Name)
+			rollout, started := businessPolManager.GetRolloutPolicy(org, polName)
+			if rollout == nil {
+				continue
+			}
+			newWl, stableWl := rolloutWorkloads(&pol)
+			if newWl == nil {
+				continue
+			}
+			inProgress[pol.Header.Name] = true
+
+			wlus, err := rc.db.FindWorkloadUsages([]persistence.WUFilter{persistence.PWUFilter(pol.Header.Name)})
+			if err != nil {
+				glog.Errorf(RClogString(fmt.Sprintf("unable to find the workload usages of %v, error: %v", pol.Header.Name, err)))
+				continue
+			}
+
+			rc.lock.Lock()
+			rs := rc.getState(pol.Header.Name, started)
+
+			// A node that was given the new version and is now using another one failed to run the new version.
+			failed := 0
+			for _, wlu := range wlus {
+				if rs.upgraded[wlu.DeviceId] && wlu.Priority != newWl.Priority.PriorityValue {
+					failed++
+				}
+			}
+			if !rs.halted && failed != 0 && float64(failed)*100/float64(len(rs.upgraded)) > rollout.MaxFailureRate {
+				rs.halted = true
+				glog.Warningf(RClogString(fmt.Sprintf("halted rollout of version %v of %v, %v of %v upgraded nodes failed, rolling the nodes back to version %v", newWl.Version, pol.Header.Name, failed, len(rs.upgraded), stableWl.Version)))
+			}
+
+			moves := make([]persistence.WorkloadUsage, 0)
+			for _, wlu := range wlus {
+				allowed := rs.allows(rollout, wlu.DeviceId, pol.Header.Name, now)
+				if wlu.Priority == newWl.Priority.PriorityValue && !allowed {
+					moves = append(moves, wlu)
+				} else if wlu.Priority == stableWl.Priority.PriorityValue && allowed && !rs.upgraded[wlu.DeviceId] {
+					moves = append(moves, wlu)
+				}
+			}
+			rc.lock.Unlock()
+
+			for _, wlu := range moves {
+				glog.V(3).Infof(RClogString(fmt.Sprintf("moving node %v of %v off priority %v", wlu.DeviceId, pol.Header.Name, wlu.Priority)))
+				rc.msgs <- events.NewABApiWorkloadUpgradeMessage(events.WORKLOAD_UPGRADE, policy.BasicProtocol, "", wlu.DeviceId, pol.Header.Name)
+			}
+		}
+	}
+
+	// Forget the rollouts of the policies that were removed or no longer have a rollout.
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	for name := range rc.states {
+		if !inProgress[name] {
+			delete(rc.states, name)
+		}
+	}
+}
+
+// Logging function
+var RClogString = func(v interface{}) string {
+	return fmt.Sprintf("Rollout Controller: %v", v)
+}
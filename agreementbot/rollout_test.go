@@ -0,0 +1,93 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/businesspolicy"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/policy"
+	"testing"
+	"time"
+)
+
+func rolloutTestPolicy(rollout *businesspolicy.RolloutPolicy, started uint64) *policy.Policy {
+	pol := policy.Policy_Factory("myorg/mypolicy")
+	for ix, v := range []string{"1.0.0", "1.0.1"} {
+		wl := policy.Workload_Factory("cpu", "myorg", v, "amd64")
+		wl.Priority = *policy.Workload_Priority_Factory(2-ix, 1, 600, 0)
+		pol.Add_Workload(wl)
+	}
+
+	businessPolManager = NewBusinessPolicyManager(make(chan events.Message, 10))
+	businessPolManager.OrgPolicies["myorg"] = map[string]*BusinessPolicyEntry{
+		"mypolicy": {Policy: pol, Updated: started, Rollout: rollout},
+	}
+	return pol
+}
+
+func Test_rolloutWorkloads(t *testing.T) {
+
+	pol := rolloutTestPolicy(nil, 0)
+	if newWl, stableWl := rolloutWorkloads(pol); newWl == nil || newWl.Version != "1.0.1" || stableWl.Version != "1.0.0" {
+		t.Errorf("wrong rollout workloads %v %v", newWl, stableWl)
+	}
+
+	pol.Workloads = pol.Workloads[:1]
+	if newWl, stableWl := rolloutWorkloads(pol); newWl != nil || stableWl != nil {
+		t.Errorf("a single workload cannot be rolled out, got %v %v", newWl, stableWl)
+	}
+}
+
+func Test_RolloutController_SelectWorkload(t *testing.T) {
+
+	// First wave, 1% of the nodes get the new version.
+	now := uint64(time.Now().Unix())
+	pol := rolloutTestPolicy(&businesspolicy.RolloutPolicy{SoakTimeS: 3600}, now)
+	rc := NewRolloutController(nil, nil)
+
+	highest := pol.NextHighestPriorityWorkload(0, 0, 0)
+	upgraded := 0
+	for i := 0; i < 1000; i++ {
+		dev := fmt.Sprintf("myorg/node%v", i)
+		wl := rc.SelectWorkload("myorg", pol, dev, highest)
+		if wl.Version == "1.0.1" {
+			upgraded++
+		} else if wl.Version != "1.0.0" {
+			t.Errorf("wrong version %v for %v", wl.Version, dev)
+		}
+	}
+	if upgraded == 0 || upgraded > 50 {
+		t.Errorf("%v of 1000 nodes got the new version in the first wave", upgraded)
+	} else if len(rc.states["myorg/mypolicy"].upgraded) != upgraded {
+		t.Errorf("the upgraded nodes should be remembered")
+	}
+
+	// A workload that is not the new version is kept.
+	if wl := rc.SelectWorkload("myorg", pol, "myorg/node1", &pol.Workloads[0]); wl.Version != "1.0.0" {
+		t.Errorf("the next priority version should be kept, got %v", wl.Version)
+	}
+
+	// The last wave, all the nodes get the new version.
+	rolloutTestPolicy(&businesspolicy.RolloutPolicy{SoakTimeS: 60}, now-3600)
+	for i := 0; i < 100; i++ {
+		if wl := rc.SelectWorkload("myorg", pol, fmt.Sprintf("myorg/node%v", i), highest); wl.Version != "1.0.1" {
+			t.Errorf("all the nodes should get the new version, got %v", wl.Version)
+		}
+	}
+
+	// A halted rollout gives the next priority version to all the nodes.
+	rc.states["myorg/mypolicy"].halted = true
+	for i := 0; i < 100; i++ {
+		if wl := rc.SelectWorkload("myorg", pol, fmt.Sprintf("myorg/node%v", i), highest); wl.Version != "1.0.0" {
+			t.Errorf("the nodes should be rolled back, got %v", wl.Version)
+		}
+	}
+
+	// Without a rollout, the chosen workload is used.
+	rolloutTestPolicy(nil, now)
+	if wl := rc.SelectWorkload("myorg", pol, "myorg/node1", highest); wl.Version != "1.0.1" {
+		t.Errorf("the highest priority version should be used without a rollout, got %v", wl.Version)
+	}
+}
@@ -0,0 +1,161 @@
+package agreementbot
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/compcheck"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/policy"
+	"sync"
+	"time"
+)
+
+// How long to wait before checking the schedule of a node again when none of the windows of its deployment policy opens
+// within the lookahead.
+const SCHEDULE_RECHECK_S = 24 * 60 * 60
+
+// An upgrade of a node that is waiting for a maintenance window of the deployment policy to open.
+type deferredUpgrade struct {
+	deviceId   string
+	policyName string
+	at         int64 // The time the window opens.
+}
+
+// The schedule controller holds the work of deployment policies with a schedule until a maintenance window opens in the
+// time zone of the node. New agreements are not made outside of the windows, the nodes are searched again when the window
+// opens. Upgrades, which are forced through the API, started by a rollout or caused by a policy change, are held and
+// then forced when the window opens.
+//
+// The held work is kept in memory. When the agbot restarts, the nodes are found again by the full rescan, but the held
+// upgrades are lost and have to be forced again through the API.
+type ScheduleController struct {
+	lock       sync.Mutex
+	ec         exchange.ExchangeContext
+	msgs       chan events.Message // Outgoing internal event messages are placed here.
+	nodeSearch *NodeSearch
+	nodes      map[string]int64           // The nodes waiting to negotiate, keyed by org qualified node id, with the time a window opens.
+	upgrades   map[string]deferredUpgrade // The upgrades waiting for a window, keyed by node id and policy name.
+}
+
+func NewScheduleController(ec exchange.ExchangeContext, msgs chan events.Message, nodeSearch *NodeSearch) *ScheduleController {
+	return &ScheduleController{
+		ec:         ec,
+		msgs:       msgs,
+		nodeSearch: nodeSearch,
+		nodes:      make(map[string]int64),
+		upgrades:   make(map[string]deferredUpgrade),
+	}
+}
+
+// Check the schedule of a deployment policy for a node with the given properties. Returns true if the service can be
+// deployed now, otherwise the time the next window opens.
+func scheduleOpen(org string, polName string, props externalpolicy.PropertyList, now time.Time) (bool, time.Time) {
+	if businessPolManager == nil {
+		return true, now
+	}
+	schedule := businessPolManager.GetSchedule(org, polName)
+	if schedule == nil {
+		return true, now
+	}
+
+	next := schedule.NextOpen(now.In(schedule.Location(props)))
+	if next.IsZero() {
+		return false, now.Add(SCHEDULE_RECHECK_S * time.Second)
+	}
+	return !next.After(now), next
+}
+
+// Returns true if an agreement can be made with a node for a policy now. Otherwise the node is searched again when the
+// next window opens, and the time it opens is returned.
+func (sc *ScheduleController) AllowsAgreement(org string, pol *policy.Policy, deviceId string, props externalpolicy.PropertyList) (bool, time.Time) {
+	if sc == nil || pol.PatternId != "" {
+		return true, time.Time{}
+	}
+
+	_, polName := cutil.SplitOrgSpecUrl(pol.Header.Name)
+	open, next := scheduleOpen(org, polName, props, time.Now())
+	if open {
+		return true, next
+	}
+
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	if at, ok := sc.nodes[deviceId]; !ok || next.Unix() < at {
+		sc.nodes[deviceId] = next.Unix()
+	}
+	glog.V(3).Infof(SClogString(fmt.Sprintf("node %v is outside the schedule of %v, negotiating when the window opens at %v", deviceId, pol.Header.Name, next)))
+	return false, next
+}
+
+// Returns true if the service of a policy can be upgraded on a node now. Otherwise the upgrade is forced when the next
+// window opens. The node policy is read from the exchange to find the time zone of the node.
+func (sc *ScheduleController) AllowsUpgrade(deviceId string, policyName string) bool {
+	if sc == nil {
+		return true
+	}
+
+	org, polName := cutil.SplitOrgSpecUrl(policyName)
+	if businessPolManager == nil || businessPolManager.GetSchedule(org, polName) == nil {
+		return true
+	}
+
+	var props externalpolicy.PropertyList
+	if _, nodePolicy, err := compcheck.GetNodePolicy(exchange.GetHTTPNodePolicyHandler(exchangeFederation.ForOrg(sc.ec, exchange.GetOrg(deviceId))), deviceId, nil); err != nil {
+		glog.Warningf(SClogString(fmt.Sprintf("unable to get the node policy of %v, using the time zone of the schedule, error: %v", deviceId, err)))
+	} else if nodePolicy != nil {
+		props = nodePolicy.Properties
+	}
+
+	open, next := scheduleOpen(org, polName, props, time.Now())
+	if open {
+		return true
+	}
+
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	sc.upgrades[deviceId+"|"+policyName] = deferredUpgrade{deviceId: deviceId, policyName: policyName, at: next.Unix()}
+	glog.V(3).Infof(SClogString(fmt.Sprintf("upgrade of node %v for %v is outside the schedule, upgrading when the window opens at %v", deviceId, policyName, next)))
+	return false
+}
+
+// Release the work whose window has opened. The nodes are given to the node search and the upgrades are forced. This
+// function is called by the schedule governance subworker.
+func (sc *ScheduleController) Govern() {
+
+	now := time.Now().Unix()
+	nodes := make([]string, 0)
+	upgrades := make([]deferredUpgrade, 0)
+
+	sc.lock.Lock()
+	for id, at := range sc.nodes {
+		if at <= now {
+			nodes = append(nodes, id)
+			delete(sc.nodes, id)
+		}
+	}
+	for key, u := range sc.upgrades {
+		if u.at <= now {
+			upgrades = append(upgrades, u)
+			delete(sc.upgrades, key)
+		}
+	}
+	sc.lock.Unlock()
+
+	if len(nodes) != 0 {
+		glog.V(3).Infof(SClogString(fmt.Sprintf("window opened for nodes %v", nodes)))
+		sc.nodeSearch.AddDeferredNodes(nodes)
+	}
+
+	for _, u := range upgrades {
+		glog.V(3).Infof(SClogString(fmt.Sprintf("window opened, upgrading node %v for %v", u.deviceId, u.policyName)))
+		sc.msgs <- events.NewABApiWorkloadUpgradeMessage(events.WORKLOAD_UPGRADE, policy.BasicProtocol, "", u.deviceId, u.policyName)
+	}
+}
+
+// Logging function
+var SClogString = func(v interface{}) string {
+	return fmt.Sprintf("Schedule Controller: %v", v)
+}
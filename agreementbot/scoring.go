@@ -0,0 +1,79 @@
+package agreementbot
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/compcheck"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/policy"
+)
+
+// Order the nodes found by a search for a deployment policy by the scoring rules of the policy, so that agreements are
+// attempted with the highest scoring nodes first. When the antiAffinity or placement of the policy do not allow the
+// service on all the nodes, the highest scoring nodes get it instead of the nodes the exchange happened to return first.
+// The nodes are scored against their node properties and the placement properties of their dynamic state. The order is
+// returned unchanged when the policy has no scoring rules.
+func rankDevices(db persistence.AgbotDatabase, ec exchange.ExchangeContext, org string, pol *policy.Policy, polName string, devices []exchange.SearchResultDevice) []exchange.SearchResultDevice {
+	if pol.PatternId != "" || businessPolManager == nil || len(devices) < 2 {
+		return devices
+	}
+
+	rules := businessPolManager.GetScoring(org, polName)
+	if len(rules) == 0 {
+		return devices
+	}
+
+	needAgCount, needFreeMemory := false, false
+	for _, rule := range rules {
+		needAgCount = needAgCount || rule.Property == externalpolicy.PROP_NODE_AGREEMENT_COUNT
+		needFreeMemory = needFreeMemory || rule.Property == externalpolicy.PROP_NODE_FREE_MEMORY
+	}
+
+	candidates := make([]policy.ScoringCandidate, len(devices))
+	byId := make(map[string]exchange.SearchResultDevice, len(devices))
+	for i, dev := range devices {
+		byId[dev.Id] = dev
+		candidates[i] = policy.ScoringCandidate{Id: dev.Id, Properties: getScoringProperties(db, ec, dev.Id, needAgCount, needFreeMemory)}
+	}
+
+	ranked := policy.RankCandidates(rules, candidates)
+	rankedDevices := make([]exchange.SearchResultDevice, len(ranked))
+	for i, c := range ranked {
+		rankedDevices[i] = byId[c.Id]
+	}
+
+	glog.V(5).Infof(AWlogString(fmt.Sprintf("ranked %v nodes for policy %v by its scoring rules %v", len(rankedDevices), pol.Header.Name, rules)))
+	return rankedDevices
+}
+
+// Returns the properties of a node that are scored, the node properties and the placement properties the rules refer to.
+// A property that cannot be read is left out, the node is ranked as if it had the least preferred value.
+func getScoringProperties(db persistence.AgbotDatabase, ec exchange.ExchangeContext, deviceId string, needAgCount bool, needFreeMemory bool) externalpolicy.PropertyList {
+	nodeEC := exchangeFederation.ForOrg(ec, exchange.GetOrg(deviceId))
+
+	props := externalpolicy.PropertyList{}
+	if _, nodePol, err := compcheck.GetNodePolicy(exchange.GetHTTPNodePolicyHandler(nodeEC), deviceId, nil); err != nil {
+		glog.Warningf(AWlogString(fmt.Sprintf("unable to read the node policy of %v for scoring, error %v", deviceId, err)))
+	} else if nodePol != nil {
+		props = append(props, nodePol.Properties...)
+	}
+
+	if needAgCount {
+		if agCount, err := countNodeAgreements(db, deviceId); err != nil {
+			glog.Warningf(AWlogString(fmt.Sprintf("unable to count the agreements of node %v for scoring, error %v", deviceId, err)))
+		} else {
+			props = append(props, *externalpolicy.Property_Factory(externalpolicy.PROP_NODE_AGREEMENT_COUNT, float64(agCount)))
+		}
+	}
+
+	if needFreeMemory {
+		if status, err := exchange.GetHTTPNodeStatusHandler(nodeEC)(deviceId); err != nil {
+			glog.Warningf(AWlogString(fmt.Sprintf("unable to read the status of node %v for scoring, error %v", deviceId, err)))
+		} else if status != nil && status.FreeMemory != nil {
+			props = append(props, *externalpolicy.Property_Factory(externalpolicy.PROP_NODE_FREE_MEMORY, float64(*status.FreeMemory)))
+		}
+	}
+	return props
+}
@@ -0,0 +1,113 @@
+package agreementbot
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/compcheck"
+	"github.com/open-horizon/anax/policy"
+	"sort"
+)
+
+// The use of a secret manager secret by a service of an agreement. The secret bindings of an agreement are the ones in the
+// consumer policy that the agreement was made with, which come from its deployment policy or pattern. When the secret is
+// updated, the agbot sends the new value to the node of each agreement that uses it, and the agent restarts the service
+// so that it gets the new value.
+type SecretUsage struct {
+	AgreementId             string `json:"agreement_id"`
+	Node                    string `json:"node"`
+	Policy                  string `json:"policy,omitempty"`
+	Pattern                 string `json:"pattern,omitempty"`
+	State                   string `json:"state"`
+	ServiceOrg              string `json:"service_org"`
+	ServiceUrl              string `json:"service_url"`
+	ServiceArch             string `json:"service_arch,omitempty"`
+	ServiceVersionRange     string `json:"service_version_range,omitempty"`
+	ServiceSecretName       string `json:"service_secret_name"`
+	LastSecretUpdateTime    uint64 `json:"last_secret_update_time"`
+	LastSecretUpdateTimeAck uint64 `json:"last_secret_update_time_ack"`
+}
+
+func (s SecretUsage) String() string {
+	return fmt.Sprintf("AgreementId: %v, Node: %v, Policy: %v, Pattern: %v, State: %v, Service: %v/%v %v %v, ServiceSecretName: %v",
+		s.AgreementId, s.Node, s.Policy, s.Pattern, s.State, s.ServiceOrg, s.ServiceUrl, s.ServiceArch, s.ServiceVersionRange, s.ServiceSecretName)
+}
+
+// Returns the uses of a secret by the services of an agreement. The secret is given by its org and its name in the
+// secret manager, '<secretname>' for an org secret or 'user/<username>/<secretname>' for a user secret. The secrets of an
+// agreement are in the org of its deployment policy or pattern.
+func agreementSecretUsage(ag *persistence.Agreement, secretOrg string, secretName string) ([]SecretUsage, error) {
+	usage := make([]SecretUsage, 0)
+	if ag.Org != secretOrg || ag.Policy == "" {
+		return usage, nil
+	}
+
+	secretUser, secretShortName, err := compcheck.ParseVaultSecretName(secretName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	pol, err := policy.DemarshalPolicy(ag.Policy)
+	if err != nil {
+		return nil, fmt.Errorf("unable to demarshal consumer policy for agreement %v, error: %v", ag.CurrentAgreementId, err)
+	}
+
+	for _, binding := range pol.SecretBinding {
+		for _, bs := range binding.Secrets {
+			serviceSecretName, smSecretName := bs.GetBinding()
+
+			// The bound name can be written in several ways, compare the parsed names.
+			if user, name, err := compcheck.ParseVaultSecretName(smSecretName, nil); err != nil || user != secretUser || name != secretShortName {
+				continue
+			}
+
+			u := SecretUsage{
+				AgreementId:             ag.CurrentAgreementId,
+				Node:                    ag.DeviceId,
+				Pattern:                 ag.Pattern,
+				State:                   ag.State(),
+				ServiceOrg:              binding.ServiceOrgid,
+				ServiceUrl:              binding.ServiceUrl,
+				ServiceArch:             binding.ServiceArch,
+				ServiceVersionRange:     binding.ServiceVersionRange,
+				ServiceSecretName:       serviceSecretName,
+				LastSecretUpdateTime:    ag.LastSecretUpdateTime,
+				LastSecretUpdateTimeAck: ag.LastSecretUpdateTimeAck,
+			}
+			if ag.Pattern == "" {
+				u.Policy = ag.PolicyName
+			}
+			usage = append(usage, u)
+		}
+	}
+	return usage, nil
+}
+
+// Returns the uses of a secret by the services of the unarchived agreements of the agbot, sorted by node and agreement.
+// An agreement whose policy cannot be read is skipped.
+func findSecretUsage(db persistence.AgbotDatabase, secretOrg string, secretName string) ([]SecretUsage, error) {
+	usage := make([]SecretUsage, 0)
+	for _, agp := range policy.AllAgreementProtocols() {
+		agreements, err := db.FindAgreements([]persistence.AFilter{persistence.UnarchivedAFilter()}, agp)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the agreements, error: %v", err)
+		}
+
+		for _, ag := range agreements {
+			agUsage, err := agreementSecretUsage(&ag, secretOrg, secretName)
+			if err != nil {
+				glog.Errorf(APIlogString(fmt.Sprintf("unable to find the use of secret %v/%v by agreement %v, error: %v", secretOrg, secretName, ag.CurrentAgreementId, err)))
+				continue
+			}
+			usage = append(usage, agUsage...)
+		}
+	}
+
+	sort.SliceStable(usage, func(i, j int) bool {
+		if usage[i].Node != usage[j].Node {
+			return usage[i].Node < usage[j].Node
+		}
+		return usage[i].AgreementId < usage[j].AgreementId
+	})
+	return usage, nil
+}
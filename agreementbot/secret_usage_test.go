@@ -0,0 +1,62 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/policy"
+	"testing"
+)
+
+func secretUsageTestAgreement(t *testing.T, id string, bindings []exchangecommon.SecretBinding) *persistence.Agreement {
+	pol := policy.Policy_Factory("myorg/mypolicy")
+	pol.SecretBinding = bindings
+	polString, err := policy.MarshalPolicy(pol)
+	if err != nil {
+		t.Fatalf("unable to marshal policy, error: %v", err)
+	}
+	return &persistence.Agreement{CurrentAgreementId: id, Org: "myorg", DeviceId: "myorg/node1", PolicyName: "myorg/mypolicy", Policy: polString, AgreementCreationTime: 10}
+}
+
+func Test_agreementSecretUsage(t *testing.T) {
+	bindings := []exchangecommon.SecretBinding{
+		{ServiceOrgid: "myorg", ServiceUrl: "svc1", Secrets: []exchangecommon.BoundSecret{{"db_pw": "dbsecret"}, {"api_key": "user/alice/apikey"}}},
+		{ServiceOrgid: "myorg", ServiceUrl: "svc2", ServiceArch: "amd64", Secrets: []exchangecommon.BoundSecret{{"password": "/dbsecret"}}},
+	}
+	ag := secretUsageTestAgreement(t, "ag1", bindings)
+
+	if usage, err := agreementSecretUsage(ag, "myorg", "dbsecret"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if len(usage) != 2 {
+		t.Errorf("expected 2 uses of dbsecret, got %v", usage)
+	} else if usage[0].ServiceUrl != "svc1" || usage[0].ServiceSecretName != "db_pw" || usage[1].ServiceUrl != "svc2" || usage[1].ServiceSecretName != "password" {
+		t.Errorf("wrong uses of dbsecret %v", usage)
+	} else if usage[0].Policy != "myorg/mypolicy" || usage[0].Node != "myorg/node1" || usage[0].State != persistence.AGREEMENT_STATE_AGREED {
+		t.Errorf("wrong agreement in use %v", usage[0])
+	}
+
+	if usage, err := agreementSecretUsage(ag, "myorg", "user/alice/apikey"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if len(usage) != 1 || usage[0].ServiceSecretName != "api_key" {
+		t.Errorf("expected 1 use of the user secret, got %v", usage)
+	}
+
+	// the same name in another org or for another user is a different secret
+	if usage, err := agreementSecretUsage(ag, "otherorg", "dbsecret"); err != nil || len(usage) != 0 {
+		t.Errorf("expected no use of the secret of another org, got %v, error: %v", usage, err)
+	}
+	if usage, err := agreementSecretUsage(ag, "myorg", "user/bob/apikey"); err != nil || len(usage) != 0 {
+		t.Errorf("expected no use of the secret of another user, got %v, error: %v", usage, err)
+	}
+
+	if _, err := agreementSecretUsage(ag, "myorg", "user/alice"); err == nil {
+		t.Errorf("expected an error for an invalid secret name")
+	}
+
+	ag.Policy = "{"
+	if _, err := agreementSecretUsage(ag, "myorg", "dbsecret"); err == nil {
+		t.Errorf("expected an error for an invalid agreement policy")
+	}
+}
@@ -18,6 +18,7 @@ package agreementbot
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/golang/glog"
 	"github.com/gorilla/mux"
@@ -31,11 +32,14 @@ import (
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/exchangecommon"
 	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/anax/worker"
+	uuid "github.com/satori/go.uuid"
 	"golang.org/x/text/message"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -188,11 +192,23 @@ func (a *SecureAPI) listen() {
 		router.HandleFunc("/deploycheck/userinputcompatible", a.userinput_compatible).Methods("GET", "OPTIONS")
 		router.HandleFunc("/deploycheck/deploycompatible", a.deploy_compatible).Methods("GET", "OPTIONS")
 		router.HandleFunc("/deploycheck/secretbindingcompatible", a.secretbinding_compatible).Methods("GET", "OPTIONS")
+		router.HandleFunc("/deploycheck/simulate", a.simulate).Methods("POST", "OPTIONS")
 		router.HandleFunc("/org/{org}/secrets/user/{user}", a.userSecrets).Methods("LIST", "OPTIONS")
 		router.HandleFunc(`/org/{org}/secrets/user/{user}/{secret:[\w\/\-]+}`, a.userSecret).Methods("GET", "LIST", "PUT", "POST", "DELETE", "OPTIONS")
 		router.HandleFunc("/org/{org}/secrets", a.orgSecrets).Methods("LIST", "OPTIONS")
 		router.HandleFunc(`/org/{org}/secrets/{secret:[\w\/\-]+}`, a.orgSecret).Methods("GET", "LIST", "PUT", "POST", "DELETE", "OPTIONS")
+		router.HandleFunc(`/org/{org}/secretusage/{secret:[\w\/\-]+}`, a.secretUsage).Methods("GET", "OPTIONS")
 		router.HandleFunc("/org/{org}/hagroup/{group}/nodemanagement/{node}/{nmpid}", a.haNodeNMPUpdateRequest).Methods("POST", "OPTIONS")
+		router.HandleFunc("/node/{org}/{id}/negotiation", a.nodeNegotiation).Methods("GET", "OPTIONS")
+		router.HandleFunc("/node/{org}/{id}/deadletters", a.nodeDeadLetters).Methods("GET", "OPTIONS")
+		router.HandleFunc("/node/{org}/{id}/deadletters/replay", a.nodeDeadLetterReplay).Methods("POST", "OPTIONS")
+		router.HandleFunc("/node/{org}/{id}/deadletters/{letter:[0-9]+}", a.nodeDeadLetters).Methods("GET", "DELETE", "OPTIONS")
+		router.HandleFunc("/node/{org}/{id}/deadletters/{letter:[0-9]+}/replay", a.nodeDeadLetterReplay).Methods("POST", "OPTIONS")
+		router.HandleFunc("/org/{org}/fleets", a.fleets).Methods("GET", "OPTIONS")
+		router.HandleFunc("/org/{org}/fleets/{fleet}", a.fleet).Methods("GET", "PUT", "POST", "DELETE", "OPTIONS")
+		router.HandleFunc("/org/{org}/jointokens", a.joinTokens).Methods("GET", "POST", "OPTIONS")
+		router.HandleFunc("/org/{org}/jointokens/{id}", a.joinToken).Methods("GET", "DELETE", "OPTIONS")
+		router.HandleFunc("/org/{org}/jointokens/{id}/join", a.joinWithToken).Methods("POST", "OPTIONS")
 
 		apiListen := fmt.Sprintf("%v:%v", apiListenHost, apiListenPort)
 
@@ -233,6 +249,17 @@ func (a *SecureAPI) haNodeNMPUpdateRequest(w http.ResponseWriter, r *http.Reques
 				return
 			}
 
+			// the agent is not upgraded while another member of the group is upgrading a service
+			if member, err := haGroupUpgradingMember(a.db, org, groupName, fmt.Sprintf("%v/%v", org, node)); err != nil {
+				glog.Errorf("Error handling ha node upgrade request from node %v/%v: %v", org, node, err)
+				writeResponse(w, exchange.PutPostDeleteStandardResponse{Code: fmt.Sprintf("%v", http.StatusInternalServerError), Msg: msgPrinter.Sprintf("Error handling node upgrade request: %v", err.Error())}, http.StatusInternalServerError)
+				return
+			} else if member != "" {
+				glog.V(3).Infof("Node %v/%v cannot begin upgrade for nmp %v. Node %v also in group %v is currently upgrading.", org, node, nmpId, member, groupName)
+				writeResponse(w, exchange.PutPostDeleteStandardResponse{Code: fmt.Sprintf("%v", http.StatusConflict), Msg: msgPrinter.Sprintf("Node %v/%v can not start executing nmp %v.", org, node, nmpId)}, http.StatusConflict)
+				return
+			}
+
 			reqNode := persistence.UpgradingHAGroupNode{GroupName: groupName, OrgId: org, NodeId: node, NMPName: nmpId}
 			upgradingNode, err := persistence.NodeManagementUpgradeQuery(a.db, reqNode)
 			if err != nil {
@@ -256,6 +283,580 @@ func (a *SecureAPI) haNodeNMPUpdateRequest(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+// The output of the GET /node/{org}/{id}/negotiation API.
+type NodeNegotiationOutput struct {
+	Node     string               `json:"node"`
+	Failures []NegotiationFailure `json:"failures"` // The recent negotiation failures of the node, newest first.
+}
+
+// This function returns the reasons why this agbot recently did not make an agreement with a node. The user must be able
+// to read the node in the exchange.
+func (a *SecureAPI) nodeNegotiation(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		pathVars := mux.Vars(r)
+		org := pathVars["org"]
+		id := pathVars["id"]
+		nodeId := fmt.Sprintf("%v/%v", org, id)
+
+		glog.V(5).Infof(APIlogString(fmt.Sprintf("%v /node/%v/negotiation called.", r.Method, nodeId)))
+
+		if user_ec, exUser, msgPrinter, ok := a.processExchangeCred("/node/{org}/{id}/negotiation", UserTypeCred, w, r); ok && a.userCanAccessNode(user_ec, exUser, nodeId, w, msgPrinter) {
+			writeResponse(w, NodeNegotiationOutput{Node: nodeId, Failures: negotiationFailures.Get(nodeId)}, http.StatusOK)
+		}
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// List, read and delete the protocol messages that could not be delivered to a node. The user must be able to read the
+// node in the exchange. The encrypted messages are not returned.
+func (a *SecureAPI) nodeDeadLetters(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET", "DELETE":
+		pathVars := mux.Vars(r)
+		nodeId := fmt.Sprintf("%v/%v", pathVars["org"], pathVars["id"])
+
+		glog.V(5).Infof(APIlogString(fmt.Sprintf("%v /node/%v/deadletters/%v called.", r.Method, nodeId, pathVars["letter"])))
+
+		if user_ec, exUser, msgPrinter, ok := a.processExchangeCred("/node/{org}/{id}/deadletters", UserTypeCred, w, r); ok && a.userCanAccessNode(user_ec, exUser, nodeId, w, msgPrinter) {
+			if pathVars["letter"] == "" {
+				if letters, err := a.db.FindDeadLetters(nodeId); err != nil {
+					glog.Errorf(APIlogString(err.Error()))
+					writeResponse(w, msgPrinter.Sprintf("Unable to read the dead letters of node %v, error: %v", nodeId, err), http.StatusInternalServerError)
+				} else {
+					for i := range letters {
+						letters[i].Payload = ""
+					}
+					writeResponse(w, letters, http.StatusOK)
+				}
+			} else if letter, ok := a.findNodeDeadLetter(nodeId, pathVars["letter"], w, msgPrinter); !ok {
+				return
+			} else if r.Method == "GET" {
+				letter.Payload = ""
+				writeResponse(w, letter, http.StatusOK)
+			} else if err := a.db.DeleteDeadLetter(letter.Id); err != nil {
+				glog.Errorf(APIlogString(err.Error()))
+				writeResponse(w, msgPrinter.Sprintf("Unable to delete dead letter %v, error: %v", letter.Id, err), http.StatusInternalServerError)
+			} else {
+				glog.V(3).Infof(APIlogString(fmt.Sprintf("dead letter %v deleted by %v", letter.ShortString(), exUser)))
+				w.WriteHeader(http.StatusNoContent)
+			}
+		}
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, DELETE, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// The output of the dead letter replay API.
+type DeadLetterReplayOutput struct {
+	Replaying []uint64 `json:"replaying"` // The ids of the dead letters queued for replay.
+}
+
+// Queue the dead letters of a node to be sent again, either one dead letter or all of them. The messages are sent in the
+// background, the ones that are delivered are removed. The user must be able to read the node in the exchange.
+func (a *SecureAPI) nodeDeadLetterReplay(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		pathVars := mux.Vars(r)
+		nodeId := fmt.Sprintf("%v/%v", pathVars["org"], pathVars["id"])
+
+		glog.V(5).Infof(APIlogString(fmt.Sprintf("%v /node/%v/deadletters/%v/replay called.", r.Method, nodeId, pathVars["letter"])))
+
+		if user_ec, exUser, msgPrinter, ok := a.processExchangeCred("/node/{org}/{id}/deadletters/replay", UserTypeCred, w, r); ok && a.userCanAccessNode(user_ec, exUser, nodeId, w, msgPrinter) {
+			if deadLetters == nil {
+				writeResponse(w, msgPrinter.Sprintf("Agbot is not ready."), http.StatusServiceUnavailable)
+				return
+			}
+
+			ids := make([]uint64, 0)
+			if pathVars["letter"] != "" {
+				if letter, ok := a.findNodeDeadLetter(nodeId, pathVars["letter"], w, msgPrinter); !ok {
+					return
+				} else {
+					ids = append(ids, letter.Id)
+				}
+			} else if letters, err := a.db.FindDeadLetters(nodeId); err != nil {
+				glog.Errorf(APIlogString(err.Error()))
+				writeResponse(w, msgPrinter.Sprintf("Unable to read the dead letters of node %v, error: %v", nodeId, err), http.StatusInternalServerError)
+				return
+			} else {
+				for _, letter := range letters {
+					ids = append(ids, letter.Id)
+				}
+			}
+
+			glog.V(3).Infof(APIlogString(fmt.Sprintf("replaying dead letters %v of node %v for %v", ids, nodeId, exUser)))
+			deadLetters.Replay(ids)
+			writeResponse(w, DeadLetterReplayOutput{Replaying: ids}, http.StatusAccepted)
+		}
+	case "OPTIONS":
+		w.Header().Set("Allow", "POST, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Returns the dead letter of the node. Otherwise the response is written.
+func (a *SecureAPI) findNodeDeadLetter(nodeId string, letterId string, w http.ResponseWriter, msgPrinter *message.Printer) (*persistence.DeadLetter, bool) {
+	id, _ := strconv.ParseUint(letterId, 10, 64)
+	if letter, err := a.db.FindDeadLetter(id); err != nil {
+		glog.Errorf(APIlogString(err.Error()))
+		writeResponse(w, msgPrinter.Sprintf("Unable to read dead letter %v, error: %v", id, err), http.StatusInternalServerError)
+		return nil, false
+	} else if letter == nil || letter.NodeId != nodeId {
+		writeResponse(w, msgPrinter.Sprintf("Dead letter %v of node %v not found.", id, nodeId), http.StatusNotFound)
+		return nil, false
+	} else {
+		return letter, true
+	}
+}
+
+// Returns true if the user can read the node in the exchange. Otherwise the response is written.
+func (a *SecureAPI) userCanAccessNode(user_ec exchange.ExchangeContext, exUser string, nodeId string, w http.ResponseWriter, msgPrinter *message.Printer) bool {
+	if found, err := a.userCanReadNode(user_ec, nodeId, msgPrinter); err != nil {
+		glog.Errorf(APIlogString(err.Error()))
+		writeResponse(w, err.Error(), http.StatusServiceUnavailable)
+		return false
+	} else if !found {
+		glog.Errorf(APIlogString(fmt.Sprintf("Node %v not found for user %v.", nodeId, exUser)))
+		writeResponse(w, msgPrinter.Sprintf("Node %v not found for user %v.", nodeId, exUser), http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// Returns true if the user can read the node in the exchange. The node cache is not used because the exchange decides
+// which nodes the user is allowed to see.
+func (a *SecureAPI) userCanReadNode(user_ec exchange.ExchangeContext, nodeId string, msgPrinter *message.Printer) (bool, error) {
+	var resp interface{}
+	resp = new(exchange.GetDevicesResponse)
+	targetURL := fmt.Sprintf("%vorgs/%v/nodes/%v", user_ec.GetExchangeURL(), exchange.GetOrg(nodeId), exchange.GetId(nodeId))
+
+	if err, tpErr := exchange.InvokeExchange(a.httpClient, "GET", targetURL, user_ec.GetExchangeId(), user_ec.GetExchangeToken(), nil, &resp); err != nil {
+		if strings.Contains(err.Error(), "status: 401") || strings.Contains(err.Error(), "status: 403") {
+			return false, nil
+		}
+		return false, fmt.Errorf(msgPrinter.Sprintf("Unable to retrieve node %v from the exchange, error: %v", nodeId, err))
+	} else if tpErr != nil {
+		return false, fmt.Errorf(msgPrinter.Sprintf("Unable to retrieve node %v from the exchange, error: %v", nodeId, tpErr))
+	} else {
+		_, found := resp.(*exchange.GetDevicesResponse).Devices[nodeId]
+		return found, nil
+	}
+}
+
+// Returns true if the user is in the org of the fleets. Otherwise the response is written.
+func (a *SecureAPI) userInFleetOrg(user_ec exchange.ExchangeContext, org string, w http.ResponseWriter, msgPrinter *message.Printer) bool {
+	if userOrg := exchange.GetOrg(user_ec.GetExchangeId()); userOrg != org {
+		glog.Errorf(APIlogString(fmt.Sprintf("User %v cannot access the fleets of org %v.", user_ec.GetExchangeId(), org)))
+		writeResponse(w, msgPrinter.Sprintf("User %v cannot access the fleets of org %v.", user_ec.GetExchangeId(), org), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// This function returns the fleets of an org, keyed by org qualified fleet name. The user must be in the org.
+func (a *SecureAPI) fleets(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		org := mux.Vars(r)["org"]
+
+		glog.V(5).Infof(APIlogString(fmt.Sprintf("%v /org/%v/fleets called.", r.Method, org)))
+
+		if user_ec, _, msgPrinter, ok := a.processExchangeCred("/org/{org}/fleets", UserTypeCred, w, r); ok && a.userInFleetOrg(user_ec, org, w, msgPrinter) {
+			if fleets, err := a.db.FindFleets(org); err != nil {
+				glog.Errorf(APIlogString(err.Error()))
+				writeResponse(w, msgPrinter.Sprintf("Unable to read the fleets of org %v, error: %v", org, err), http.StatusInternalServerError)
+			} else {
+				out := make(map[string]persistence.Fleet, len(fleets))
+				for _, f := range fleets {
+					out[fmt.Sprintf("%v/%v", f.Org, f.Name)] = f
+				}
+				writeResponse(w, out, http.StatusOK)
+			}
+		}
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// This function returns the services of the agreements of this agbot that use a secret, and so are restarted on the nodes
+// when the secret is updated. The secret name is '<secretname>' or 'user/<username>/<secretname>'. The user must be in
+// the org of the secret.
+func (a *SecureAPI) secretUsage(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		pathVars := mux.Vars(r)
+		org := pathVars["org"]
+		name := pathVars["secret"]
+
+		glog.V(5).Infof(APIlogString(fmt.Sprintf("%v /org/%v/secretusage/%v called.", r.Method, org, name)))
+
+		if user_ec, _, msgPrinter, ok := a.processExchangeCred("/org/{org}/secretusage/{secret}", UserTypeCred, w, r); ok {
+			if userOrg := exchange.GetOrg(user_ec.GetExchangeId()); userOrg != org {
+				glog.Errorf(APIlogString(fmt.Sprintf("User %v cannot access the secrets of org %v.", user_ec.GetExchangeId(), org)))
+				writeResponse(w, msgPrinter.Sprintf("User %v cannot access the secrets of org %v.", user_ec.GetExchangeId(), org), http.StatusForbidden)
+			} else if _, _, err := compcheck.ParseVaultSecretName(name, msgPrinter); err != nil {
+				writeResponse(w, err.Error(), http.StatusBadRequest)
+			} else if usage, err := findSecretUsage(a.db, org, name); err != nil {
+				glog.Errorf(APIlogString(err.Error()))
+				writeResponse(w, msgPrinter.Sprintf("Unable to find the use of secret %v/%v, error: %v", org, name, err), http.StatusInternalServerError)
+			} else {
+				writeResponse(w, usage, http.StatusOK)
+			}
+		}
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// This function reads, creates, replaces and deletes a fleet. The user must be in the org of the fleet. The fleets are
+// shared by all the agbots using the same database.
+func (a *SecureAPI) fleet(w http.ResponseWriter, r *http.Request) {
+	pathVars := mux.Vars(r)
+	org := pathVars["org"]
+	name := pathVars["fleet"]
+	resource := "/org/{org}/fleets/{fleet}"
+
+	switch r.Method {
+	case "GET":
+		glog.V(5).Infof(APIlogString(fmt.Sprintf("%v /org/%v/fleets/%v called.", r.Method, org, name)))
+
+		if user_ec, _, msgPrinter, ok := a.processExchangeCred(resource, UserTypeCred, w, r); ok && a.userInFleetOrg(user_ec, org, w, msgPrinter) {
+			if fleet, err := a.db.FindFleet(org, name); err != nil {
+				glog.Errorf(APIlogString(err.Error()))
+				writeResponse(w, msgPrinter.Sprintf("Unable to read fleet %v/%v, error: %v", org, name, err), http.StatusInternalServerError)
+			} else if fleet == nil {
+				writeResponse(w, msgPrinter.Sprintf("Fleet %v/%v not found.", org, name), http.StatusNotFound)
+			} else {
+				writeResponse(w, fleet, http.StatusOK)
+			}
+		}
+	case "PUT", "POST":
+		glog.V(5).Infof(APIlogString(fmt.Sprintf("%v /org/%v/fleets/%v called.", r.Method, org, name)))
+
+		if user_ec, _, msgPrinter, ok := a.processExchangeCred(resource, UserTypeCred, w, r); ok && a.userInFleetOrg(user_ec, org, w, msgPrinter) {
+			body, _ := ioutil.ReadAll(r.Body)
+			var fleet persistence.Fleet
+			if err := json.Unmarshal(body, &fleet); err != nil {
+				writeResponse(w, msgPrinter.Sprintf("Input body couldn't be deserialized to fleet object: %v, error: %v", string(body), err), http.StatusBadRequest)
+				return
+			}
+
+			fleet.Org = org
+			fleet.Name = name
+			fleet.Owner = user_ec.GetExchangeId()
+			fleet.LastUpdated = uint64(time.Now().Unix())
+			if err := fleet.Validate(); err != nil {
+				writeResponse(w, msgPrinter.Sprintf("Invalid fleet %v/%v, error: %v", org, name, err), http.StatusBadRequest)
+			} else if err := a.db.SaveFleet(&fleet); err != nil {
+				glog.Errorf(APIlogString(err.Error()))
+				writeResponse(w, msgPrinter.Sprintf("Unable to save fleet %v/%v, error: %v", org, name, err), http.StatusInternalServerError)
+			} else {
+				glog.V(3).Infof(APIlogString(fmt.Sprintf("fleet %v saved by %v", fleet.ShortString(), fleet.Owner)))
+				writeResponse(w, fleet, http.StatusCreated)
+			}
+		}
+	case "DELETE":
+		glog.V(5).Infof(APIlogString(fmt.Sprintf("%v /org/%v/fleets/%v called.", r.Method, org, name)))
+
+		if user_ec, _, msgPrinter, ok := a.processExchangeCred(resource, UserTypeCred, w, r); ok && a.userInFleetOrg(user_ec, org, w, msgPrinter) {
+			if fleet, err := a.db.FindFleet(org, name); err != nil {
+				glog.Errorf(APIlogString(err.Error()))
+				writeResponse(w, msgPrinter.Sprintf("Unable to read fleet %v/%v, error: %v", org, name, err), http.StatusInternalServerError)
+			} else if fleet == nil {
+				writeResponse(w, msgPrinter.Sprintf("Fleet %v/%v not found.", org, name), http.StatusNotFound)
+			} else if err := a.db.DeleteFleet(org, name); err != nil {
+				glog.Errorf(APIlogString(err.Error()))
+				writeResponse(w, msgPrinter.Sprintf("Unable to delete fleet %v/%v, error: %v", org, name, err), http.StatusInternalServerError)
+			} else {
+				glog.V(3).Infof(APIlogString(fmt.Sprintf("fleet %v/%v deleted by %v", org, name, user_ec.GetExchangeId())))
+				w.WriteHeader(http.StatusNoContent)
+			}
+		}
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, PUT, POST, DELETE, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// The default and the maximum time in seconds that a join token can be used.
+const JOIN_TOKEN_DEFAULT_TTL = 24 * 60 * 60
+const JOIN_TOKEN_MAX_TTL = 30 * 24 * 60 * 60
+
+// The input of the join token create API.
+type JoinTokenRequest struct {
+	Description      string                     `json:"description,omitempty"`
+	Pattern          string                     `json:"pattern,omitempty"`
+	NodePolicy       *exchangecommon.NodePolicy `json:"nodePolicy,omitempty"`
+	UserInput        []policy.UserInput         `json:"userInput,omitempty"`
+	NodeNameTemplate string                     `json:"nodeNameTemplate,omitempty"`
+	MaxUses          int                        `json:"maxUses,omitempty"`
+	TTL              int                        `json:"ttl,omitempty"` // seconds
+}
+
+// The output of the join token create API. The secret is only returned here.
+type JoinTokenResponse struct {
+	persistence.JoinToken
+	Secret string `json:"secret"`
+}
+
+// The input of the join API, sent by the device.
+type JoinRequest struct {
+	Secret    string `json:"secret"`
+	NodeId    string `json:"nodeId"`
+	NodeToken string `json:"nodeToken"`
+	NodeType  string `json:"nodeType,omitempty"`
+	Arch      string `json:"arch,omitempty"`
+}
+
+// The output of the join API, what the device needs to finish its registration.
+type JoinResponse struct {
+	Org        string                     `json:"org"`
+	NodeId     string                     `json:"nodeId"`
+	NodeName   string                     `json:"nodeName"`
+	Pattern    string                     `json:"pattern,omitempty"`
+	NodePolicy *exchangecommon.NodePolicy `json:"nodePolicy,omitempty"`
+}
+
+// This function lists and creates the join tokens of an org. The user must be in the org. The id and the secret of a
+// new token are generated here, only the hash of the secret is saved.
+func (a *SecureAPI) joinTokens(w http.ResponseWriter, r *http.Request) {
+	org := mux.Vars(r)["org"]
+	resource := "/org/{org}/jointokens"
+
+	switch r.Method {
+	case "GET":
+		glog.V(5).Infof(APIlogString(fmt.Sprintf("%v /org/%v/jointokens called.", r.Method, org)))
+
+		if user_ec, _, msgPrinter, ok := a.processExchangeCred(resource, UserTypeCred, w, r); ok && a.userInFleetOrg(user_ec, org, w, msgPrinter) {
+			if tokens, err := a.db.FindJoinTokens(org); err != nil {
+				glog.Errorf(APIlogString(err.Error()))
+				writeResponse(w, msgPrinter.Sprintf("Unable to read the join tokens of org %v, error: %v", org, err), http.StatusInternalServerError)
+			} else {
+				out := make(map[string]persistence.JoinToken, len(tokens))
+				for _, t := range tokens {
+					t.SecretHash = ""
+					out[fmt.Sprintf("%v/%v", t.Org, t.Id)] = t
+				}
+				writeResponse(w, out, http.StatusOK)
+			}
+		}
+	case "POST":
+		glog.V(5).Infof(APIlogString(fmt.Sprintf("%v /org/%v/jointokens called.", r.Method, org)))
+
+		if user_ec, _, msgPrinter, ok := a.processExchangeCred(resource, UserTypeCred, w, r); ok && a.userInFleetOrg(user_ec, org, w, msgPrinter) {
+			body, _ := ioutil.ReadAll(r.Body)
+			var input JoinTokenRequest
+			if err := json.Unmarshal(body, &input); err != nil {
+				writeResponse(w, msgPrinter.Sprintf("Input body couldn't be deserialized to join token object: %v, error: %v", string(body), err), http.StatusBadRequest)
+				return
+			}
+
+			ttl := input.TTL
+			if ttl == 0 {
+				ttl = JOIN_TOKEN_DEFAULT_TTL
+			} else if ttl < 0 || ttl > JOIN_TOKEN_MAX_TTL {
+				writeResponse(w, msgPrinter.Sprintf("The ttl of a join token must be between 1 and %v seconds.", JOIN_TOKEN_MAX_TTL), http.StatusBadRequest)
+				return
+			}
+
+			pattern := input.Pattern
+			if pattern != "" && !strings.Contains(pattern, "/") {
+				pattern = fmt.Sprintf("%v/%v", org, pattern)
+			}
+
+			secret, err := cutil.SecureRandomString()
+			if err != nil {
+				glog.Errorf(APIlogString(err.Error()))
+				writeResponse(w, msgPrinter.Sprintf("Unable to generate the secret of the join token, error: %v", err), http.StatusInternalServerError)
+				return
+			}
+
+			now := uint64(time.Now().Unix())
+			token := persistence.JoinToken{
+				Org:              org,
+				Id:               uuid.NewV4().String(),
+				SecretHash:       persistence.HashJoinTokenSecret(secret),
+				Description:      input.Description,
+				Owner:            user_ec.GetExchangeId(),
+				Pattern:          pattern,
+				NodePolicy:       input.NodePolicy,
+				UserInput:        input.UserInput,
+				NodeNameTemplate: input.NodeNameTemplate,
+				MaxUses:          input.MaxUses,
+				Created:          now,
+				Expires:          now + uint64(ttl),
+			}
+			if err := token.Validate(); err != nil {
+				writeResponse(w, msgPrinter.Sprintf("Invalid join token, error: %v", err), http.StatusBadRequest)
+			} else if err := a.db.SaveJoinToken(&token); err != nil {
+				glog.Errorf(APIlogString(err.Error()))
+				writeResponse(w, msgPrinter.Sprintf("Unable to save join token %v/%v, error: %v", org, token.Id, err), http.StatusInternalServerError)
+			} else {
+				glog.V(3).Infof(APIlogString(fmt.Sprintf("join token %v created by %v", token.ShortString(), token.Owner)))
+				token.SecretHash = ""
+				writeResponse(w, JoinTokenResponse{JoinToken: token, Secret: secret}, http.StatusCreated)
+			}
+		}
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, POST, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// This function reads and deletes a join token. The user must be in the org of the token. Deleting a token does not
+// remove the nodes that joined with it.
+func (a *SecureAPI) joinToken(w http.ResponseWriter, r *http.Request) {
+	pathVars := mux.Vars(r)
+	org := pathVars["org"]
+	id := pathVars["id"]
+	resource := "/org/{org}/jointokens/{id}"
+
+	switch r.Method {
+	case "GET", "DELETE":
+		glog.V(5).Infof(APIlogString(fmt.Sprintf("%v /org/%v/jointokens/%v called.", r.Method, org, id)))
+
+		if user_ec, _, msgPrinter, ok := a.processExchangeCred(resource, UserTypeCred, w, r); ok && a.userInFleetOrg(user_ec, org, w, msgPrinter) {
+			if token, err := a.db.FindJoinToken(org, id); err != nil {
+				glog.Errorf(APIlogString(err.Error()))
+				writeResponse(w, msgPrinter.Sprintf("Unable to read join token %v/%v, error: %v", org, id, err), http.StatusInternalServerError)
+			} else if token == nil {
+				writeResponse(w, msgPrinter.Sprintf("Join token %v/%v not found.", org, id), http.StatusNotFound)
+			} else if r.Method == "GET" {
+				token.SecretHash = ""
+				writeResponse(w, token, http.StatusOK)
+			} else if err := a.db.DeleteJoinToken(org, id); err != nil {
+				glog.Errorf(APIlogString(err.Error()))
+				writeResponse(w, msgPrinter.Sprintf("Unable to delete join token %v/%v, error: %v", org, id, err), http.StatusInternalServerError)
+			} else {
+				glog.V(3).Infof(APIlogString(fmt.Sprintf("join token %v/%v deleted by %v", org, id, user_ec.GetExchangeId())))
+				w.WriteHeader(http.StatusNoContent)
+			}
+		}
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, DELETE, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// This function lets a device join an org with a join token. The device has no exchange credentials yet, the secret of
+// the token is the credential. The node is created in the exchange by the agbot, with the token and the pattern and
+// user input of the join token, so the agbot must be allowed to create nodes in the org. The join fails with a conflict
+// when the node already exists.
+func (a *SecureAPI) joinWithToken(w http.ResponseWriter, r *http.Request) {
+	pathVars := mux.Vars(r)
+	org := pathVars["org"]
+	id := pathVars["id"]
+
+	switch r.Method {
+	case "POST":
+		glog.V(5).Infof(APIlogString(fmt.Sprintf("%v /org/%v/jointokens/%v/join called.", r.Method, org, id)))
+
+		// there is no user cred to check, get the message printer with the language passed in from the header
+		lan := r.Header.Get("Accept-Language")
+		if lan == "" {
+			lan = i18n.DEFAULT_LANGUAGE
+		}
+		msgPrinter := i18n.GetMessagePrinterWithLocale(lan)
+
+		body, _ := ioutil.ReadAll(r.Body)
+		var input JoinRequest
+		if err := json.Unmarshal(body, &input); err != nil {
+			writeResponse(w, msgPrinter.Sprintf("Input body couldn't be deserialized to join request object, error: %v", err), http.StatusBadRequest)
+			return
+		} else if input.NodeId == "" || strings.Contains(input.NodeId, "/") || input.NodeToken == "" {
+			writeResponse(w, msgPrinter.Sprintf("The join request must have a node id without a / and a node token."), http.StatusBadRequest)
+			return
+		}
+		nodeId := fmt.Sprintf("%v/%v", org, input.NodeId)
+
+		var nodeName string
+		token, err := a.db.UpdateJoinToken(org, id, func(t *persistence.JoinToken) error {
+			if name, err := t.NodeName(input.NodeId); err != nil {
+				return err
+			} else if err := t.Join(input.Secret, nodeId, uint64(time.Now().Unix())); err != nil {
+				return err
+			} else {
+				nodeName = name
+			}
+			return nil
+		})
+		if err == nil && token == nil {
+			err = errors.New("invalid join token")
+		}
+		if err != nil {
+			glog.Errorf(APIlogString(fmt.Sprintf("node %v cannot join with join token %v/%v, error: %v", nodeId, org, id, err)))
+			writeResponse(w, msgPrinter.Sprintf("Unable to join with join token %v/%v, error: %v", org, id, err), http.StatusForbidden)
+			return
+		}
+
+		nodeType := input.NodeType
+		if nodeType == "" {
+			nodeType = persistence.DEVICE_TYPE_DEVICE
+		}
+		pdr := exchange.PutDeviceRequest{
+			Token:              input.NodeToken,
+			Name:               nodeName,
+			NodeType:           nodeType,
+			Pattern:            token.Pattern,
+			RegisteredServices: []exchange.Microservice{},
+			MsgEndPoint:        "",
+			SoftwareVersions:   exchange.SoftwareVersion{},
+			PublicKey:          []byte(""),
+			Arch:               input.Arch,
+			UserInput:          token.UserInput,
+		}
+
+		agbot_ec := exchange.NewCustomExchangeContext(a.Config.AgreementBot.ExchangeId, a.Config.AgreementBot.ExchangeToken, a.Config.AgreementBot.ExchangeURL, a.Config.GetAgbotCSSURL(), newHTTPClientFactory())
+		if err := exchange.CreateExchangeDevice(exchangeFederation.ForOrg(agbot_ec, org), nodeId, &pdr); err != nil {
+			glog.Errorf(APIlogString(fmt.Sprintf("unable to create node %v with join token %v/%v, error: %v", nodeId, org, id, err)))
+			if _, lerr := a.db.UpdateJoinToken(org, id, func(t *persistence.JoinToken) error {
+				if t != nil {
+					t.Leave(nodeId)
+				}
+				return nil
+			}); lerr != nil {
+				glog.Errorf(APIlogString(fmt.Sprintf("unable to remove node %v from join token %v/%v, error: %v", nodeId, org, id, lerr)))
+			}
+			if exchange.IsNodeExistsError(err) {
+				writeResponse(w, msgPrinter.Sprintf("Unable to create node %v, error: %v", nodeId, err), http.StatusConflict)
+			} else {
+				writeResponse(w, msgPrinter.Sprintf("Unable to create node %v, error: %v", nodeId, err), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		glog.V(3).Infof(APIlogString(fmt.Sprintf("node %v joined with join token %v", nodeId, token.ShortString())))
+		writeResponse(w, JoinResponse{Org: org, NodeId: input.NodeId, NodeName: nodeName, Pattern: token.Pattern, NodePolicy: token.NodePolicy}, http.StatusCreated)
+	case "OPTIONS":
+		w.Header().Set("Allow", "POST, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 // This function does policy compatibility check.
 func (a *SecureAPI) policy_compatible(w http.ResponseWriter, r *http.Request) {
 
@@ -282,6 +883,11 @@ func (a *SecureAPI) policy_compatible(w http.ResponseWriter, r *http.Request) {
 	//    type: bool
 	//    required: false
 	//    description: "Show the input which was used to come up with the result."
+	//  - name: explain
+	//    in: query
+	//    type: bool
+	//    required: false
+	//    description: "Show the evaluation of each constraint clause of the policy check, with the property value it was evaluated against and whether it matched."
 	//  - name: node_id
 	//    in: body
 	//    type: string
@@ -367,6 +973,11 @@ func (a *SecureAPI) policy_compatible(w http.ResponseWriter, r *http.Request) {
 					output.Input = nil
 				}
 
+				// nil out the evaluation tree of the policy check if 'explain' is not set in the request
+				if r.URL.Query().Get("explain") == "" && output != nil {
+					output.Explanation = nil
+				}
+
 				// write the output
 				a.writeCompCheckResponse(w, output, err, msgPrinter)
 			}
@@ -678,6 +1289,11 @@ func (a *SecureAPI) deploy_compatible(w http.ResponseWriter, r *http.Request) {
 	//    type: bool
 	//    required: false
 	//    description: "Show the input which was used to come up with the result."
+	//  - name: explain
+	//    in: query
+	//    type: bool
+	//    required: false
+	//    description: "Show the evaluation of each constraint clause of the policy check, with the property value it was evaluated against and whether it matched."
 	//  - name: node_id
 	//    in: body
 	//    type: string
@@ -798,6 +1414,11 @@ func (a *SecureAPI) deploy_compatible(w http.ResponseWriter, r *http.Request) {
 					if long == "" {
 						output.Input = nil
 					}
+
+					// nil out the evaluation tree of the policy check if 'explain' is not set in the request
+					if r.URL.Query().Get("explain") == "" {
+						output.Explanation = nil
+					}
 				}
 
 				// write the output
@@ -813,6 +1434,94 @@ func (a *SecureAPI) deploy_compatible(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// This function simulates a change of a deployment policy without publishing it.
+func (a *SecureAPI) simulate(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	// swagger:operation POST /deploycheck/simulate deployCheckSimulate
+	//
+	// Simulate a deployment policy change
+	//
+	// This API checks a proposed deployment policy against the nodes it could be deployed to and returns the nodes that would gain the service, the nodes that would lose it and the agreements that would be cancelled. The policy is not published. Omit the business_policy to simulate the deletion of the policy. The user must be in the organization of the deployment policy.
+	//
+	// ---
+	// consumes:
+	//  - application/json
+	// produces:
+	//  - application/json
+	// parameters:
+	//  - name: business_policy_id
+	//    in: body
+	//    type: string
+	//    required: true
+	//    description: "The organization qualified id of the deployment policy that is changed."
+	//  - name: business_policy
+	//    in: body
+	//    required: false
+	//    description: "The proposed deployment policy. If omitted, the deletion of the policy is simulated."
+	//    schema:
+	//     "$ref": "#/definitions/BusinessPolicy"
+	//  - name: service_policy
+	//    in: body
+	//    required: false
+	//    description: "The service policy. If omitted, the service policy will be retrieved from the exchange."
+	//    schema:
+	//     "$ref": "#/definitions/ExternalPolicy"
+	// responses:
+	//  '200':
+	//    description: "Success"
+	//    schema:
+	//     type: DeploySimulationOutput
+	//  '400':
+	//    description: "Failure - Invalid input"
+	//    schema:
+	//     type: string
+	//  '401':
+	//    description: "Failure - Failed to authenticate"
+	//    schema:
+	//     type: string
+	//  '403':
+	//    description: "Failure - The user is not in the organization of the deployment policy"
+	//    schema:
+	//     type: string
+	//  '500':
+	//    description: "Failure - Error"
+	//    schema:
+	//      type: string
+	case "POST":
+		glog.V(5).Infof(APIlogString(fmt.Sprintf("/deploycheck/simulate called.")))
+
+		if user_ec, _, msgPrinter, ok := a.processExchangeCred("/deploycheck/simulate", UserTypeCred, w, r); ok {
+			body, _ := ioutil.ReadAll(r.Body)
+			var input DeploySimulation
+			if len(body) == 0 {
+				glog.Errorf(APIlogString(fmt.Sprintf("No input found.")))
+				writeResponse(w, msgPrinter.Sprintf("No input found."), http.StatusBadRequest)
+			} else if err := json.Unmarshal(body, &input); err != nil {
+				writeResponse(w, msgPrinter.Sprintf("Input body couldn't be deserialized to DeploySimulation object: %v, error: %v", string(body), err), http.StatusBadRequest)
+			} else if polOrg, polName := cutil.SplitOrgSpecUrl(input.BusinessPolId); polOrg == "" || polName == "" {
+				writeResponse(w, msgPrinter.Sprintf("The business_policy_id %v must be qualified by its organization.", input.BusinessPolId), http.StatusBadRequest)
+			} else if userOrg := exchange.GetOrg(user_ec.GetExchangeId()); userOrg != polOrg {
+				glog.Errorf(APIlogString(fmt.Sprintf("User %v cannot simulate a change of deployment policy %v.", user_ec.GetExchangeId(), input.BusinessPolId)))
+				writeResponse(w, msgPrinter.Sprintf("User %v cannot simulate a change of deployment policy %v.", user_ec.GetExchangeId(), input.BusinessPolId), http.StatusForbidden)
+			} else if err := input.Validate(); err != nil {
+				writeResponse(w, msgPrinter.Sprintf("Validation failure for deployment policy %v. %v", input.BusinessPolId, err), http.StatusBadRequest)
+			} else if output, err := a.simulateDeployment(user_ec, &input, msgPrinter); err != nil {
+				glog.Errorf(APIlogString(err.Error()))
+				writeResponse(w, err.Error(), http.StatusInternalServerError)
+			} else {
+				writeResponse(w, output, http.StatusOK)
+			}
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "POST, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 // This function checks user cred and writes corrsponding response. It also creates a message printer with given language from the http request.
 func (a *SecureAPI) processExchangeCred(resource string, authType string, w http.ResponseWriter, r *http.Request) (exchange.ExchangeContext, string, *message.Printer, bool) {
 	// get message printer with the language passed in from the header
@@ -26,6 +26,8 @@ type API struct {
 	bcStateLock    sync.Mutex
 	shutdownError  string
 	EC             *worker.BaseExchangeContext
+	stream         *EventStream // pushes the agent's events to the clients of /events/stream
+	configFile     string       // the config file that is read again by /config/reload
 }
 
 type BlockchainState struct {
@@ -35,7 +37,7 @@ type BlockchainState struct {
 	servicePort string // the network port of the container
 }
 
-func NewAPIListener(name string, cfg *config.HorizonConfig, db *bolt.DB, pm *policy.PolicyManager) *API {
+func NewAPIListener(name string, cfg *config.HorizonConfig, db *bolt.DB, pm *policy.PolicyManager, configFile string) *API {
 	messages := make(chan events.Message)
 
 	listener := &API{
@@ -51,8 +53,13 @@ func NewAPIListener(name string, cfg *config.HorizonConfig, db *bolt.DB, pm *pol
 		bcState:     make(map[string]map[string]apicommon.BlockchainState),
 		bcStateLock: sync.Mutex{},
 		EC:          nil,
+		stream:      NewEventStream(),
+		configFile:  configFile,
 	}
 
+	// the event logs are pushed to the clients of the event stream as they are saved
+	persistence.AddEventLogListener(listener.stream.PublishEventLog)
+
 	// setup the exchange context if the device is set
 	pDevice, err := persistence.FindExchangeDevice(db)
 	if err != nil {
@@ -75,17 +82,34 @@ func (a *API) router(includeStaticRedirects bool) *mux.Router {
 	// For working with existing or archived agreements
 	router.HandleFunc("/agreement", a.agreement).Methods("GET", "OPTIONS")
 	router.HandleFunc("/agreement/{id}", a.agreement).Methods("GET", "DELETE", "OPTIONS")
+	router.HandleFunc("/agreement/{id}/history", a.agreementHistory).Methods("GET", "OPTIONS")
 
 	// For obtaining microservice info or configuring a microservice (sensor) userInput variables
 	router.HandleFunc("/service", a.service).Methods("GET", "OPTIONS")
 	router.HandleFunc("/service/config", a.serviceconfig).Methods("GET", "POST", "OPTIONS")
 	router.HandleFunc("/service/configstate", a.service_configstate).Methods("GET", "POST", "OPTIONS")
+	router.HandleFunc("/service/pause", a.service_pause).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/service/resume", a.service_resume).Methods("PUT", "OPTIONS")
 	router.HandleFunc("/service/policy", a.servicepolicy).Methods("GET", "OPTIONS")
+	router.HandleFunc("/service/stats", a.servicestats).Methods("GET", "OPTIONS")
+	router.HandleFunc("/service/log", a.servicelog).Methods("GET", "OPTIONS")
 
 	// Connectivity and blockchain status info
 	router.HandleFunc("/status", a.status).Methods("GET", "OPTIONS")
 	router.HandleFunc("/status/workers", a.workerstatus).Methods("GET", "OPTIONS")
 
+	// For troubleshooting the connectivity, the clock and the certificates of the node
+	router.HandleFunc("/diagnostics", a.diagnostics).Methods("GET", "OPTIONS")
+
+	// For applying the changes of the config file without restarting the agent
+	router.HandleFunc("/config/reload", a.configreload).Methods("PUT", "OPTIONS")
+
+	// The OpenAPI document of this API
+	router.HandleFunc("/api-docs", a.apidocs).Methods("GET", "OPTIONS")
+
+	// Agent metrics in the Prometheus text format
+	router.HandleFunc("/metrics", a.agentmetrics).Methods("GET", "OPTIONS")
+
 	// Used by the Registration UI to obtain a random token string
 	router.HandleFunc("/token/random", tokenRandom).Methods("GET", "OPTIONS")
 
@@ -94,6 +118,9 @@ func (a *API) router(includeStaticRedirects bool) *mux.Router {
 	router.HandleFunc("/node/configstate", a.nodeconfigstate).Methods("GET", "HEAD", "PUT", "OPTIONS")
 	router.HandleFunc("/node/policy", a.nodepolicy).Methods("GET", "HEAD", "PUT", "POST", "PATCH", "DELETE", "OPTIONS")
 	router.HandleFunc("/node/userinput", a.nodeuserinput).Methods("GET", "HEAD", "PUT", "POST", "PATCH", "DELETE", "OPTIONS")
+	router.HandleFunc("/node/maintenance", a.nodemaintenance).Methods("GET", "PUT", "OPTIONS")
+	router.HandleFunc("/node/resources", a.noderesources).Methods("GET", "OPTIONS")
+	router.HandleFunc("/node/validate", a.nodevalidate).Methods("POST", "OPTIONS")
 
 	// Used to get the event logs on this node.
 	// get the eventlogs for current registration.
@@ -103,6 +130,9 @@ func (a *API) router(includeStaticRedirects bool) *mux.Router {
 	//get the active surface errors for this node
 	router.HandleFunc("/eventlog/surface", a.surface).Methods("GET", "OPTIONS")
 
+	// Used to push the agreement, service and event log changes to the client as they occur.
+	router.HandleFunc("/events/stream", a.eventstream).Methods("GET", "OPTIONS")
+
 	router.HandleFunc("/nodemanagement/nextjob", a.nextUpgradeJob).Methods("GET", "OPTIONS")
 	router.HandleFunc("/nodemanagement/status", a.managementStatus).Methods("GET", "OPTIONS")
 	router.HandleFunc("/nodemanagement/status/{org}/{nmpname}", a.managementStatus).Methods("GET", "PUT", "OPTIONS")
@@ -134,9 +164,6 @@ func (a *API) listen(cfg *config.HorizonConfig) {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Add("Cache-Control", "no-cache, no-store, must-revalidate")
 			w.Header().Add("Pragma", "no-cache, no-store")
-			w.Header().Add("Access-Control-Allow-Origin", "*")
-			w.Header().Add("Access-Control-Allow-Headers", "X-Requested-With, content-type, Authorization")
-			w.Header().Add("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, PATCH, OPTIONS")
 			h.ServeHTTP(w, r)
 		})
 	}
@@ -144,11 +171,19 @@ func (a *API) listen(cfg *config.HorizonConfig) {
 	// This routine does not need to be a subworker because there is no way to terminate it. It will terminate when
 	// the main anax process goes away.
 	go func() {
-		if err := http.ListenAndServe(cfg.Edge.APIListen, nocache(a.router(true))); err != nil {
+		if err := serveAPI(cfg, nocache(AllowCORS(cfg.Edge.GetAPICORSOrigins(), a.router(true)))); err != nil {
 			glog.Fatalf(apiLogString(fmt.Sprintf("Failed to start listener on %v, error %v", cfg.Edge.APIListen, err)))
 		}
 	}()
 
+	if cfg.Edge.APIObserverSocketPath != "" {
+		go func() {
+			if err := serveObserverAPI(cfg, nocache(a.router(false))); err != nil {
+				glog.Fatalf(apiLogString(fmt.Sprintf("Failed to start listener on %v, error %v", cfg.Edge.APIObserverSocketPath, err)))
+			}
+		}()
+	}
+
 }
 
 // Worker framework functions
@@ -158,6 +193,12 @@ func (a *API) Messages() chan events.Message {
 
 func (a *API) NewEvent(incoming events.Message) {
 
+	if a.stream != nil {
+		for _, event := range NewStreamEventsFromMessage(incoming) {
+			a.stream.Publish(event)
+		}
+	}
+
 	switch incoming.(type) {
 	case *events.BlockchainClientInitializedMessage:
 		msg, _ := incoming.(*events.BlockchainClientInitializedMessage)
@@ -261,7 +302,7 @@ func serializeResponse(w http.ResponseWriter, payload interface{}) ([]byte, bool
 	serial, err := json.Marshal(payload)
 	if err != nil {
 		glog.Error(apiLogString(err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		writeAPIError(w, NewAPIError(http.StatusInternalServerError, API_ERR_INTERNAL, "Internal server error", "", ""))
 		return nil, true
 	}
 
@@ -292,10 +333,10 @@ func (a *API) existingDeviceOrError(w http.ResponseWriter) (*persistence.Exchang
 
 	if err != nil {
 		glog.Errorf(apiLogString(fmt.Sprintf("Failed fetching existing exchange device, error: %v", err)))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		writeAPIError(w, NewAPIError(http.StatusInternalServerError, API_ERR_INTERNAL, "Internal server error", "", ""))
 		statusWritten = true
 	} else if existingDevice == nil {
-		writeInputErr(w, http.StatusFailedDependency, NewAPIUserInputError("Exchange registration not recorded. Complete account and node registration with an exchange and then record node registration using this API's /node path.", "node"))
+		writeAPIError(w, NewAPIError(http.StatusFailedDependency, API_ERR_NODE_NOT_CONFIGURED, "Exchange registration not recorded. Complete account and node registration with an exchange and then record node registration using this API's /node path.", "node", ""))
 		statusWritten = true
 	}
 
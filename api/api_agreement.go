@@ -25,11 +25,19 @@ func (a *API) agreement(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		opts, selections, err := NewListOptions(r.URL.Query())
+		if err != nil {
+			errorhandler(NewAPIUserInputError(err.Error(), "query parameters"))
+			return
+		}
+
 		// Gather all the agreements from the local database and format them for output.
 		if out, err := FindAgreementsForOutput(a.db); err != nil {
 			errorhandler(NewSystemError(fmt.Sprintf("Error getting %v for output, error %v", resource, err)))
-		} else {
+		} else if opts.IsEmpty() && len(selections) == 0 {
 			writeResponse(w, out, http.StatusOK)
+		} else if errHandled, selected := SelectAgreementsForOutput(out, opts, selections, errorhandler); !errHandled {
+			writeResponse(w, selected, http.StatusOK)
 		}
 
 	case "DELETE":
@@ -63,3 +71,26 @@ func (a *API) agreement(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
+
+func (a *API) agreementHistory(w http.ResponseWriter, r *http.Request) {
+
+	resource := "agreement history"
+	errorhandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "GET":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+		pathVars := mux.Vars(r)
+		id := pathVars["id"]
+
+		if errHandled, out := FindAgreementHistoryForOutput(errorhandler, id, a.db); !errHandled {
+			writeResponse(w, out, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Serve the API with the authentication set in the config. It only returns when the listener fails.
+func serveAPI(cfg *config.HorizonConfig, handler http.Handler) error {
+
+	switch cfg.Edge.GetAPIAuth() {
+	case config.APIAuth_PEERCRED:
+		allowed, err := GetAllowedUids(cfg.Edge.APIAllowedUsers)
+		if err != nil {
+			return err
+		}
+		observers, err := GetObserverUids(cfg.Edge.APIObserverUsers)
+		if err != nil {
+			return err
+		}
+		socket := cfg.Edge.GetAPISocketPath()
+		listener, err := ListenAPISocket(socket)
+		if err != nil {
+			return err
+		}
+		glog.Infof(apiLogString(fmt.Sprintf("Serving the API on %v to the users %v, and to the %v users %v", socket, allowed, API_ROLE_OBSERVER, observers)))
+		server := &http.Server{Handler: RequirePeerCred(allowed, observers, handler), ConnContext: peerCredContext}
+		return server.Serve(listener)
+
+	case config.APIAuth_TOKEN:
+		token, err := LoadOrCreateAPIToken(cfg.Edge.GetAPITokenFile())
+		if err != nil {
+			return err
+		}
+		observerToken := ""
+		if cfg.Edge.APIObserverTokenFile != "" {
+			if observerToken, err = LoadOrCreateAPIToken(cfg.Edge.APIObserverTokenFile); err != nil {
+				return err
+			} else if observerToken == token {
+				return errors.New(fmt.Sprintf("the API token file %v and the %v token file %v hold the same token", cfg.Edge.GetAPITokenFile(), API_ROLE_OBSERVER, cfg.Edge.APIObserverTokenFile))
+			}
+			glog.Infof(apiLogString(fmt.Sprintf("The clients holding the token in %v have the %v role", cfg.Edge.APIObserverTokenFile, API_ROLE_OBSERVER)))
+		}
+		glog.Infof(apiLogString(fmt.Sprintf("Serving the API on %v to the clients holding the token in %v", cfg.Edge.APIListen, cfg.Edge.GetAPITokenFile())))
+		return http.ListenAndServe(cfg.Edge.APIListen, RequireAPIToken(token, observerToken, handler))
+
+	case config.APIAuth_MTLS:
+		tlsConfig, err := NewAPITLSConfig(cfg.Edge.APIClientCACert)
+		if err != nil {
+			return err
+		}
+		glog.Infof(apiLogString(fmt.Sprintf("Serving the API on %v to the clients with a certificate signed by %v", cfg.Edge.APIListen, cfg.Edge.APIClientCACert)))
+		server := &http.Server{Addr: cfg.Edge.APIListen, Handler: handler, TLSConfig: tlsConfig}
+		return server.ListenAndServeTLS(cfg.Edge.APIServerCert, cfg.Edge.APIServerKey)
+	}
+
+	return http.ListenAndServe(cfg.Edge.APIListen, handler)
+}
+
+// The bearer token of the API is read from the token file. The file is created with a random token when it does not
+// exist, readable only by the user running the agent, so that only that user (and root) can pass the token to the API.
+func LoadOrCreateAPIToken(tokenFile string) (string, error) {
+	if b, err := os.ReadFile(filepath.Clean(tokenFile)); err == nil {
+		if token := strings.TrimSpace(string(b)); token != "" {
+			return token, nil
+		}
+		return "", errors.New(fmt.Sprintf("the API token file %v is empty", tokenFile))
+	} else if !os.IsNotExist(err) {
+		return "", errors.New(fmt.Sprintf("unable to read the API token file %v, error %v", tokenFile, err))
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.New(fmt.Sprintf("unable to generate the API token, error %v", err))
+	}
+	token := hex.EncodeToString(b)
+
+	if err := os.MkdirAll(filepath.Dir(tokenFile), 0755); err != nil {
+		return "", errors.New(fmt.Sprintf("unable to create the directory of the API token file %v, error %v", tokenFile, err))
+	} else if err := os.WriteFile(tokenFile, []byte(token+"\n"), 0600); err != nil {
+		return "", errors.New(fmt.Sprintf("unable to write the API token file %v, error %v", tokenFile, err))
+	}
+	glog.Infof(apiLogString(fmt.Sprintf("Created the API token file %v", tokenFile)))
+	return token, nil
+}
+
+// Serve the API to any local user on the observer socket, with the observer role. It only returns when the listener
+// fails.
+func serveObserverAPI(cfg *config.HorizonConfig, handler http.Handler) error {
+	socket := cfg.Edge.APIObserverSocketPath
+	listener, err := ListenAPISocket(socket)
+	if err != nil {
+		return err
+	}
+	glog.Infof(apiLogString(fmt.Sprintf("Serving the API on %v to any local user with the %v role", socket, API_ROLE_OBSERVER)))
+	server := &http.Server{Handler: RequireObserverRole(handler)}
+	return server.Serve(listener)
+}
+
+// Reject the requests that do not carry the bearer token. The requests carrying the observer token, when there is
+// one, have the observer role. The CORS preflight requests never carry credentials, they are let through.
+func RequireAPIToken(token string, observerToken string, h http.Handler) http.Handler {
+	observer := RequireObserverRole(h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodOptions {
+			auth := r.Header.Get("Authorization")
+			given := strings.TrimPrefix(auth, "Bearer ")
+			if !strings.HasPrefix(auth, "Bearer ") {
+				given = ""
+			}
+			if given != "" && observerToken != "" && subtle.ConstantTimeCompare([]byte(given), []byte(observerToken)) == 1 {
+				observer.ServeHTTP(w, r)
+				return
+			} else if given == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				glog.Warningf(apiLogString(fmt.Sprintf("Rejected %v %v from %v, the API token is missing or wrong", r.Method, r.URL.Path, r.RemoteAddr)))
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeAPIError(w, NewAPIError(http.StatusUnauthorized, API_ERR_UNAUTHORIZED, "The API token is missing or wrong.", "", ""))
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// The clients must present a certificate signed by one of the CA certificates in the given file.
+func NewAPITLSConfig(clientCACert string) (*tls.Config, error) {
+	b, err := os.ReadFile(filepath.Clean(clientCACert))
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read the API client CA certificate %v, error %v", clientCACert, err))
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.New(fmt.Sprintf("no PEM encoded certificates found in the API client CA certificate %v", clientCACert))
+	}
+
+	return &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  pool,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// Root and the user running the agent are always allowed, in addition to the given users.
+func GetAllowedUids(users []string) (map[uint32]bool, error) {
+	allowed := map[uint32]bool{0: true, uint32(os.Geteuid()): true}
+	return allowed, addUids(allowed, users)
+}
+
+// The given users have the observer role, unless they are allowed to use the whole API.
+func GetObserverUids(users []string) (map[uint32]bool, error) {
+	observers := make(map[uint32]bool)
+	return observers, addUids(observers, users)
+}
+
+func addUids(uids map[uint32]bool, users []string) error {
+	for _, name := range users {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return errors.New(fmt.Sprintf("unable to find the API user %v, error %v", name, err))
+		}
+		uid, err := strconv.ParseUint(u.Uid, 10, 32)
+		if err != nil {
+			return errors.New(fmt.Sprintf("the uid %v of the API user %v is not a number", u.Uid, name))
+		}
+		uids[uint32(uid)] = true
+	}
+	return nil
+}
+
+// Listen on the unix socket of the API. A socket left over by a previous run of the agent is replaced. Anyone can
+// connect to the socket, the peer credentials of the connection decide what is allowed.
+func ListenAPISocket(socket string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(socket), 0755); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to create the directory of the API socket %v, error %v", socket, err))
+	} else if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		return nil, errors.New(fmt.Sprintf("unable to remove the old API socket %v, error %v", socket, err))
+	}
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to listen on the API socket %v, error %v", socket, err))
+	} else if err := os.Chmod(socket, 0666); err != nil {
+		listener.Close()
+		return nil, errors.New(fmt.Sprintf("unable to set the mode of the API socket %v, error %v", socket, err))
+	}
+	return listener, nil
+}
+
+type peerCredKey struct{}
+
+// The uid of the process at the other end of the connection, or the error getting it.
+type peerCred struct {
+	uid uint32
+	err error
+}
+
+// Save the peer credentials of the connection in the context of its requests.
+func peerCredContext(ctx context.Context, c net.Conn) context.Context {
+	uid, err := getPeerUid(c)
+	return context.WithValue(ctx, peerCredKey{}, peerCred{uid: uid, err: err})
+}
+
+// Reject the requests from the processes run by users that are not allowed to use the API. The requests of the observer
+// users have the observer role.
+func RequirePeerCred(allowed map[uint32]bool, observers map[uint32]bool, h http.Handler) http.Handler {
+	observer := RequireObserverRole(h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cred, ok := r.Context().Value(peerCredKey{}).(peerCred); !ok || cred.err != nil {
+			glog.Errorf(apiLogString(fmt.Sprintf("Rejected %v %v, unable to get the peer credentials of the connection, error %v", r.Method, r.URL.Path, cred.err)))
+			writeAPIError(w, NewAPIError(http.StatusForbidden, API_ERR_FORBIDDEN, "Unable to get the peer credentials of the connection.", "", ""))
+			return
+		} else if !allowed[cred.uid] && observers[cred.uid] {
+			observer.ServeHTTP(w, r)
+			return
+		} else if !allowed[cred.uid] {
+			glog.Warningf(apiLogString(fmt.Sprintf("Rejected %v %v from uid %v, the user is not allowed to use the API", r.Method, r.URL.Path, cred.uid)))
+			writeAPIError(w, NewAPIError(http.StatusForbidden, API_ERR_FORBIDDEN, fmt.Sprintf("The user %v is not allowed to use the API.", cred.uid), "", ""))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
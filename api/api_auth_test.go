@@ -0,0 +1,182 @@
+//go:build unit
+// +build unit
+
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"runtime"
+	"testing"
+)
+
+// Verify that the token file is created with a token only its owner can read, and that the same token is used afterwards.
+func Test_LoadOrCreateAPIToken(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "apitoken-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tokenFile := path.Join(dir, "horizon", "api.token")
+	token, err := LoadOrCreateAPIToken(tokenFile)
+	if err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(token) != 64 {
+		t.Errorf("wrong token %v", token)
+	} else if info, err := os.Stat(tokenFile); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("the token file should only be readable by its owner, but got %v %v", info, err)
+	}
+
+	if token2, err := LoadOrCreateAPIToken(tokenFile); err != nil || token2 != token {
+		t.Errorf("the token should not change, but got %v, error %v", token2, err)
+	}
+
+	os.WriteFile(tokenFile, []byte("\n"), 0600)
+	if _, err := LoadOrCreateAPIToken(tokenFile); err == nil {
+		t.Errorf("should have returned an error for an empty token file")
+	}
+}
+
+func Test_RequireAPIToken(t *testing.T) {
+
+	h := RequireAPIToken("mytoken", "observertoken", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		method string
+		path   string
+		auth   string
+		code   int
+	}{
+		{"GET", "/node", "", http.StatusUnauthorized},
+		{"GET", "/node", "Bearer wrong", http.StatusUnauthorized},
+		{"GET", "/node", "mytoken", http.StatusUnauthorized},
+		{"GET", "/node", "Basic mytoken", http.StatusUnauthorized},
+		{"GET", "/status", "observertoken", http.StatusUnauthorized},
+		{"DELETE", "/node", "Bearer mytoken", http.StatusOK},
+		{"OPTIONS", "/node", "", http.StatusOK},
+		{"GET", "/status", "Bearer observertoken", http.StatusOK},
+		{"DELETE", "/node", "Bearer observertoken", http.StatusForbidden},
+		{"GET", "/node/userinput", "Bearer observertoken", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != test.code {
+			t.Errorf("%v %v with %v should return %v, but got %v", test.method, test.path, test.auth, test.code, w.Code)
+		}
+	}
+}
+
+func Test_RequirePeerCred(t *testing.T) {
+
+	h := RequirePeerCred(map[uint32]bool{0: true}, map[uint32]bool{1001: true}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		cred   interface{}
+		method string
+		path   string
+		code   int
+	}{
+		{nil, "GET", "/node", http.StatusForbidden},
+		{peerCred{uid: 1000}, "GET", "/node", http.StatusForbidden},
+		{peerCred{uid: 0}, "GET", "/node", http.StatusOK},
+		{peerCred{uid: 1001}, "GET", "/agreement", http.StatusOK},
+		{peerCred{uid: 1001}, "GET", "/node", http.StatusForbidden},
+		{peerCred{uid: 1001}, "PUT", "/service/pause", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		if test.cred != nil {
+			req = req.WithContext(context.WithValue(req.Context(), peerCredKey{}, test.cred))
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != test.code {
+			t.Errorf("%v %v from %v should return %v, but got %v", test.method, test.path, test.cred, test.code, w.Code)
+		}
+	}
+}
+
+// Verify that the API served on the unix socket sees the uid of the client.
+func Test_ListenAPISocket(t *testing.T) {
+
+	if runtime.GOOS != "linux" {
+		t.Skip("the peer credentials are only available on linux")
+	}
+
+	dir, err := os.MkdirTemp("", "apisocket-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := path.Join(dir, "run", "anax.sock")
+	listener, err := ListenAPISocket(socket)
+	if err != nil {
+		t.Fatalf("should not return error, but got %v", err)
+	}
+
+	allowed, err := GetAllowedUids(nil)
+	if err != nil {
+		t.Fatalf("should not return error, but got %v", err)
+	}
+	server := &http.Server{Handler: RequirePeerCred(allowed, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})), ConnContext: peerCredContext}
+	go server.Serve(listener)
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	if resp, err := client.Get("http://localhost/status"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if resp.StatusCode != http.StatusOK {
+		t.Errorf("the user running the agent should be allowed, but got %v", resp.StatusCode)
+	}
+
+	// a socket left behind is replaced
+	server.Close()
+	if l, err := ListenAPISocket(socket); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else {
+		l.Close()
+	}
+}
+
+func Test_NewAPITLSConfig(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "apitls-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := NewAPITLSConfig(path.Join(dir, "nofile.pem")); err == nil {
+		t.Errorf("should have returned an error for a missing CA certificate")
+	}
+
+	notPem := path.Join(dir, "ca.pem")
+	os.WriteFile(notPem, []byte("not a certificate"), 0600)
+	if _, err := NewAPITLSConfig(notPem); err == nil {
+		t.Errorf("should have returned an error for a file without certificates")
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	corsAllowHeaders = "X-Requested-With, content-type, Authorization"
+	corsAllowMethods = "GET, HEAD, POST, PUT, DELETE, PATCH, OPTIONS"
+	corsMaxAge       = "600"
+)
+
+// Add the CORS headers to the responses to the web pages served from the allowed origins, so that a dashboard in the
+// browser can call the API. The preflight requests of those pages are answered here, before the API authentication,
+// because the browser never sends credentials with them. The requests from other origins get no CORS headers and the
+// browser does not let the page read the response.
+func AllowCORS(origins []string, h http.Handler) http.Handler {
+	anyOrigin := false
+	allowed := make(map[string]bool)
+	for _, origin := range origins {
+		if origin == "*" {
+			anyOrigin = true
+		} else {
+			allowed[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if anyOrigin {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else if allowed[strings.ToLower(origin)] {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		} else {
+			h.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+		w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,62 @@
+//go:build unit
+// +build unit
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Verify that only the allowed origins get the CORS headers and that their preflight requests are answered.
+func Test_AllowCORS(t *testing.T) {
+
+	served := false
+	h := AllowCORS([]string{"https://gateway.local:8443/"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		method    string
+		origin    string
+		preflight bool
+		code      int
+		allow     string
+		served    bool
+	}{
+		{"GET", "", false, http.StatusOK, "", true},
+		{"GET", "https://gateway.local:8443", false, http.StatusOK, "https://gateway.local:8443", true},
+		{"GET", "https://other.local", false, http.StatusOK, "", true},
+		{"OPTIONS", "https://gateway.local:8443", true, http.StatusNoContent, "https://gateway.local:8443", false},
+		{"OPTIONS", "https://other.local", true, http.StatusOK, "", true},
+	}
+
+	for _, test := range tests {
+		served = false
+		req := httptest.NewRequest(test.method, "/node", nil)
+		if test.origin != "" {
+			req.Header.Set("Origin", test.origin)
+		}
+		if test.preflight {
+			req.Header.Set("Access-Control-Request-Method", "PUT")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != test.code || served != test.served {
+			t.Errorf("%v from %v should return %v and be served %v, but got %v %v", test.method, test.origin, test.code, test.served, w.Code, served)
+		} else if allow := w.Header().Get("Access-Control-Allow-Origin"); allow != test.allow {
+			t.Errorf("%v from %v should allow origin %v, but got %v", test.method, test.origin, test.allow, allow)
+		}
+	}
+
+	h = AllowCORS([]string{"*"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest("GET", "/node", nil)
+	req.Header.Set("Origin", "https://other.local")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if allow := w.Header().Get("Access-Control-Allow-Origin"); allow != "*" {
+		t.Errorf("any origin should be allowed, but got %v", allow)
+	}
+}
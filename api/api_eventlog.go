@@ -34,12 +34,22 @@ func (a *API) eventlog(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v with selection %v. Language: %v", r.Method, resource, r.Form, lan)))
+		opts, selections, err := NewListOptions(r.Form)
+		if err != nil {
+			errorHandler(NewAPIUserInputError(err.Error(), "query parameters"))
+			return
+		}
+
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v with selection %v and list options %v. Language: %v", r.Method, resource, selections, opts, lan)))
 
-		if out, err := FindEventLogsForOutput(a.db, all_loags, r.Form, msgPrinter); err != nil {
+		if out, err := FindEventLogsForOutput(a.db, all_loags, selections, msgPrinter); err != nil {
 			errorHandler(NewSystemError(msgPrinter.Sprintf("Error getting %v for output, error %v", resource, err)))
-		} else {
+		} else if opts.IsEmpty() {
 			writeResponse(w, out, http.StatusOK)
+		} else if selected, err := opts.Apply(out, "timestamp", nil); err != nil {
+			errorHandler(NewSystemError(msgPrinter.Sprintf("Error selecting %v for output, error %v", resource, err)))
+		} else {
+			writeResponse(w, selected, http.StatusOK)
 		}
 
 	case "OPTIONS":
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/i18n"
+	"net/http"
+	"time"
+)
+
+// Push the agreement, service and event log changes to the client as server-sent events, until the client goes away.
+func (a *API) eventstream(w http.ResponseWriter, r *http.Request) {
+
+	resource := "events/stream"
+
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "GET":
+		lan := r.Header.Get("Accept-Language")
+		if lan == "" {
+			lan = i18n.DEFAULT_LANGUAGE
+		}
+		msgPrinter := i18n.GetMessagePrinterWithLocale(lan)
+
+		types, err := ParseStreamEventTypes(r.URL.Query().Get("type"))
+		if err != nil {
+			errorHandler(NewAPIUserInputError(err.Error(), "type"))
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			errorHandler(NewSystemError(msgPrinter.Sprintf("Streaming is not supported on this connection.")))
+			return
+		}
+
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v for event types %v. Language: %v", r.Method, resource, types, lan)))
+
+		ch := a.stream.Subscribe()
+		defer a.stream.Unsubscribe(ch)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepalive := time.NewTicker(EVENT_STREAM_KEEPALIVE_S * time.Second)
+		defer keepalive.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				glog.V(5).Infof(apiLogString(fmt.Sprintf("Client of %v went away.", resource)))
+				return
+			case event := <-ch:
+				if !types[event.Type] {
+					continue
+				} else if err := WriteStreamEvent(w, event, msgPrinter); err != nil {
+					glog.Errorf(apiLogString(fmt.Sprintf("Unable to write to the client of %v, error %v", resource, err)))
+					return
+				}
+				flusher.Flush()
+			case <-keepalive.C:
+				if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strconv"
 
 	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/exchange"
@@ -142,9 +143,10 @@ func (a *API) node(w http.ResponseWriter, r *http.Request) {
 		removeNode := r.URL.Query().Get("removeNode")
 		deepClean := r.URL.Query().Get("deepClean")
 		block := r.URL.Query().Get("block")
+		exportPath := r.URL.Query().Get("exportPath")
 
 		// Validate the DELETE request and delete the object from the database.
-		errHandled := DeleteHorizonDevice(removeNode, deepClean, block, a.em, a.Messages(), errorHandler, a.db)
+		errHandled := DeleteHorizonDevice(removeNode, deepClean, block, exportPath, a.em, a.Messages(), errorHandler, a.db)
 		if errHandled {
 			return
 		}
@@ -567,3 +569,136 @@ func (a *API) nodeuserinput(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
+
+// For turning the maintenance mode of the node on and off, for a planned servicing window.
+func (a *API) nodemaintenance(w http.ResponseWriter, r *http.Request) {
+
+	resource := "node/maintenance"
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "GET":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		if out, err := FindNodeMaintenanceForOutput(a.db); err != nil {
+			errorHandler(NewSystemError(fmt.Sprintf("Error getting %v for output, error %v", resource, err)))
+		} else {
+			writeResponse(w, out, http.StatusOK)
+		}
+
+	case "PUT":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		pDevice, errWritten := a.existingDeviceOrError(w)
+		if errWritten {
+			return
+		}
+
+		var maintenance NodeMaintenance
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &maintenance); err != nil {
+			errorHandler(NewAPIUserInputError(fmt.Sprintf("Input body couldn't be deserialized to %v object: %v, error: %v", resource, string(body), err), "maintenance"))
+			return
+		}
+
+		errHandled, out, msgs := UpdateNodeMaintenance(&maintenance, errorHandler, a.db)
+		if errHandled {
+			return
+		}
+
+		if !*maintenance.Enabled {
+			LogDeviceEvent(a.db, persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_API_NODE_MAINTENANCE_OFF), persistence.EC_NODE_MAINTENANCE_OFF, pDevice)
+		} else if len(msgs) != 0 {
+			LogDeviceEvent(a.db, persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_API_NODE_MAINTENANCE_ON_DRAIN, len(msgs)), persistence.EC_NODE_MAINTENANCE_ON, pDevice)
+		} else {
+			LogDeviceEvent(a.db, persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_API_NODE_MAINTENANCE_ON), persistence.EC_NODE_MAINTENANCE_ON, pDevice)
+		}
+
+		// Send the agreement cancellations to the governance worker, it stops the services gracefully.
+		for _, msg := range msgs {
+			a.Messages() <- msg
+		}
+
+		writeResponse(w, out, http.StatusOK)
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, PUT, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// For external schedulers and admission checks that need to know the headroom of the node.
+func (a *API) noderesources(w http.ResponseWriter, r *http.Request) {
+
+	resource := "node/resources"
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "GET":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		if out, err := FindNodeResourcesForOutput(a.db, a.Config.Edge.ServiceStorage); err != nil {
+			errorHandler(NewSystemError(fmt.Sprintf("Error getting %v for output, error %v", resource, err)))
+		} else {
+			writeResponse(w, out, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) nodevalidate(w http.ResponseWriter, r *http.Request) {
+
+	resource := "node/validate"
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "POST":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		var nv NodeValidation
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &nv); err != nil {
+			errorHandler(NewAPIUserInputError(fmt.Sprintf("Input body could not be deserialized to %v object: %v, error: %v", resource, string(body), err), "body"))
+			return
+		}
+
+		// use the credentials of the node being validated, or of the registered node. The exchange is tried once so
+		// that the local service cache is used soon when it cannot be reached.
+		id, token := a.GetExchangeId(), a.GetExchangeToken()
+		if nv.Node != nil && nv.Node.Id != nil && nv.Node.Org != nil && nv.Node.Token != nil {
+			id, token = fmt.Sprintf("%v/%v", *nv.Node.Org, *nv.Node.Id), *nv.Node.Token
+		}
+		httpFactory := &config.HTTPClientFactory{
+			NewHTTPClient: a.GetHTTPFactory().NewHTTPClient,
+			RetryCount:    1,
+			RetryInterval: 5,
+		}
+		vex := &validationExchange{
+			ec: exchange.NewCustomExchangeContext(id, token, a.GetExchangeURL(), a.GetCSSURL(), httpFactory),
+			db: a.db,
+		}
+
+		errHandled, out := ValidateNode(&nv, errorHandler, exchange.GetHTTPExchangePatternHandler(vex.ec), exchange.GetHTTPBusinessPoliciesHandler(vex.ec), vex.deployCompatible, a.db)
+		if errHandled {
+			return
+		}
+		out.Offline = vex.offline
+
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handled %v on resource %v", r.Method, resource)))
+
+		writeResponse(w, out, http.StatusOK)
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "POST, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"net/http"
+	"strings"
+)
+
+// The roles of the clients of the API. The admin role can use the whole API, the observer role can only read the
+// state of the node, so that an on-device dashboard can display it without being able to change the node.
+const (
+	API_ROLE_ADMIN    = "admin"
+	API_ROLE_OBSERVER = "observer"
+)
+
+// The resources the observer role can read, with everything below them. The node, its policy and its user input are
+// not included, they can hold secrets.
+var observerResources = []string{
+	"/status",
+	"/agreement",
+	"/eventlog",
+	"/events/stream",
+	"/service/configstate",
+	"/service/stats",
+	"/metrics",
+	"/api-docs",
+}
+
+// The resources the observer role can read, without what is below them.
+var observerExactResources = []string{
+	"/service",
+}
+
+// Returns true if the observer role can make the request.
+func ObserverAllowed(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions {
+		return false
+	}
+
+	p := strings.TrimSuffix(r.URL.Path, "/")
+	for _, resource := range observerExactResources {
+		if p == resource {
+			return true
+		}
+	}
+	for _, resource := range observerResources {
+		if p == resource || strings.HasPrefix(p, resource+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Reject the requests the observer role cannot make.
+func RequireObserverRole(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ObserverAllowed(r) {
+			glog.Warningf(apiLogString(fmt.Sprintf("Rejected %v %v from %v, the %v role can only read the state of the node", r.Method, r.URL.Path, r.RemoteAddr, API_ROLE_OBSERVER)))
+			writeAPIError(w, NewAPIError(http.StatusForbidden, API_ERR_FORBIDDEN, fmt.Sprintf("The %v role can only read the state of the node.", API_ROLE_OBSERVER), "", ""))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,42 @@
+//go:build unit
+// +build unit
+
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_ObserverAllowed(t *testing.T) {
+
+	tests := []struct {
+		method  string
+		path    string
+		allowed bool
+	}{
+		{"GET", "/status", true},
+		{"GET", "/status/workers", true},
+		{"GET", "/agreement", true},
+		{"GET", "/agreement/ag1/history", true},
+		{"HEAD", "/eventlog/all", true},
+		{"GET", "/service", true},
+		{"GET", "/service/", true},
+		{"GET", "/service/configstate", true},
+		{"OPTIONS", "/agreement/ag1", true},
+		{"DELETE", "/agreement/ag1", false},
+		{"POST", "/service/configstate", false},
+		{"GET", "/service/config", false},
+		{"GET", "/node", false},
+		{"GET", "/node/userinput", false},
+		{"GET", "/statusx", false},
+		{"GET", "/agreements", false},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		if allowed := ObserverAllowed(req); allowed != test.allowed {
+			t.Errorf("%v %v should be allowed %v, but got %v", test.method, test.path, test.allowed, allowed)
+		}
+	}
+}
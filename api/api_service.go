@@ -9,6 +9,7 @@ import (
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/kube_operator"
 	"github.com/open-horizon/anax/persistence"
 	"io/ioutil"
 	"net/http"
@@ -37,11 +38,19 @@ func (a *API) service(w http.ResponseWriter, r *http.Request) {
 
 		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
 
+		opts, selections, err := NewListOptions(r.URL.Query())
+		if err != nil {
+			errorhandler(NewAPIUserInputError(err.Error(), "query parameters"))
+			return
+		}
+
 		// Gather all the service info from the database and format for output.
 		if out, err := FindServicesForOutput(a.pm, a.db, a.Config); err != nil {
 			errorhandler(NewSystemError(fmt.Sprintf("Error getting %v for output, error %v", resource, err)))
-		} else {
+		} else if opts.IsEmpty() && len(selections) == 0 {
 			writeResponse(w, *out, http.StatusOK)
+		} else if errHandled, selected := SelectServicesForOutput(out, opts, selections, errorhandler); !errHandled {
+			writeResponse(w, selected, http.StatusOK)
 		}
 
 	case "OPTIONS":
@@ -208,6 +217,62 @@ func (a *API) service_configstate(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// For pausing a service on the node, its containers are stopped without cancelling its agreements.
+func (a *API) service_pause(w http.ResponseWriter, r *http.Request) {
+	a.changeServicePause(w, r, "service/pause", true)
+}
+
+// For resuming a paused service on the node.
+func (a *API) service_resume(w http.ResponseWriter, r *http.Request) {
+	a.changeServicePause(w, r, "service/resume", false)
+}
+
+func (a *API) changeServicePause(w http.ResponseWriter, r *http.Request, resource string, pause bool) {
+
+	errorhandler := GetHTTPErrorHandler(w)
+
+	pDevice, errWritten := a.existingDeviceOrError(w)
+	if errWritten {
+		return
+	}
+
+	switch r.Method {
+	case "PUT":
+
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		var service ServicePause
+		body, _ := ioutil.ReadAll(r.Body)
+
+		if err := json.Unmarshal(body, &service); err != nil {
+			errorhandler(NewAPIUserInputError(fmt.Sprintf("Input body couldn't be deserialized to %v object: %v, error: %v", resource, string(body), err), "service"))
+			return
+		}
+
+		errorHandled, msg := ChangeServicePause(&service, pause, pDevice.GetNodeType(), errorhandler, a.db)
+		if errorHandled {
+			return
+		} else if msg != nil {
+			a.Messages() <- msg
+
+			elMessage := EL_API_SVC_PAUSED
+			elCode := persistence.EC_SERVICE_PAUSED
+			if !pause {
+				elMessage = EL_API_SVC_RESUMED
+				elCode = persistence.EC_SERVICE_RESUMED
+			}
+			LogServiceEvent(a.db, persistence.SEVERITY_INFO, persistence.NewMessageMeta(elMessage, cutil.FormOrgSpecUrl(service.Url, service.Org)), elCode, NewService(service.Url, service.Org, "", cutil.ArchString(), ""))
+		}
+		w.WriteHeader(http.StatusOK)
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "PUT, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 // For working with a node's policy files.
 func (a *API) servicepolicy(w http.ResponseWriter, r *http.Request) {
 
@@ -239,3 +304,105 @@ func (a *API) servicepolicy(w http.ResponseWriter, r *http.Request) {
 	}
 
 }
+
+// For getting the resource usage of the containers of the services running on the node.
+func (a *API) servicestats(w http.ResponseWriter, r *http.Request) {
+
+	resource := "service/stats"
+	errorhandler := GetHTTPErrorHandler(w)
+
+	_, errWritten := a.existingDeviceOrError(w)
+	if errWritten {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		// The stats are collected periodically by the governance worker, return the last ones.
+		if out, err := persistence.FindServiceStats(a.db); err != nil {
+			errorhandler(NewSystemError(fmt.Sprintf("Error getting %v for output, error %v", resource, err)))
+		} else {
+			writeResponse(w, out, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+
+}
+
+// Streams the log records of the containers of a service on a cluster, the CLI reads the logs of the services on a device
+// from the container engine or the syslog directly.
+func (a *API) servicelog(w http.ResponseWriter, r *http.Request) {
+
+	resource := "service/log"
+	errorhandler := GetHTTPErrorHandler(w)
+
+	pDevice, errWritten := a.existingDeviceOrError(w)
+	if errWritten {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v with query %v", r.Method, resource, r.URL.RawQuery)))
+
+		if pDevice.GetNodeType() != persistence.DEVICE_TYPE_CLUSTER {
+			errorhandler(NewAPIUserInputError("The service logs are only served on a cluster node.", "service/log"))
+			return
+		}
+		opts, err := ParseServiceLogOptions(r.URL.Query())
+		if err != nil {
+			errorhandler(err)
+			return
+		}
+		instanceId := r.URL.Query().Get("instance")
+		kd, reqNamespace, err := FindServiceLogDeployment(a.db, instanceId)
+		if err != nil {
+			errorhandler(err)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			errorhandler(NewSystemError("Streaming is not supported on this connection."))
+			return
+		}
+		kc, err := kube_operator.NewKubeClient()
+		if err != nil {
+			errorhandler(NewSystemError(fmt.Sprintf("Unable to get a kube client, error %v", err)))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		// The response has started, so an error reading the logs can only be logged. The logs are read until the
+		// client goes away when they are followed.
+		err = kc.ServiceLogs(r.Context(), kd.OperatorYamlArchive, kd.Metadata, instanceId, reqNamespace, *opts, func(name string, line string) error {
+			if _, err := fmt.Fprintf(w, "[%v] %v\n", name, line); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		})
+		if err != nil && r.Context().Err() == nil {
+			glog.Errorf(apiLogString(fmt.Sprintf("Unable to stream the logs of service instance %v, error %v", instanceId, err)))
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+
+}
@@ -1,8 +1,16 @@
 package api
 
 import (
+	"fmt"
+	dockerclient "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
 	"github.com/open-horizon/anax/apicommon"
+	"github.com/open-horizon/anax/container"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/metrics"
 	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/version"
 	"github.com/open-horizon/anax/worker"
 	"net/http"
 )
@@ -46,3 +54,142 @@ func (a *API) workerstatus(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
+
+// For scraping the agent metrics into Prometheus.
+func (a *API) apidocs(w http.ResponseWriter, r *http.Request) {
+
+	resource := "api-docs"
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "GET":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		if doc, err := GenerateOpenAPIDocument(a.router(false), version.HORIZON_VERSION, a.Config.Edge.GetAPIAuth()); err != nil {
+			errorHandler(NewSystemError(fmt.Sprintf("Unable to generate the OpenAPI document, error %v", err)))
+		} else {
+			writeResponse(w, doc, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) agentmetrics(w http.ResponseWriter, r *http.Request) {
+
+	resource := "metrics"
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "GET":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		// the agreement counts are read from the local db at each scrape
+		if counts, err := CountAgreementsByState(a.db); err != nil {
+			errorHandler(NewSystemError(fmt.Sprintf("Unable to read agreement objects, error %v", err)))
+			return
+		} else {
+			metrics.SetAgreements(counts)
+		}
+
+		w.Header().Set("Content-Type", metrics.CONTENT_TYPE)
+		w.WriteHeader(http.StatusOK)
+		if err := metrics.GetRegistry().Write(w); err != nil {
+			glog.Errorf(apiLogString(fmt.Sprintf("Unable to write the metrics, error %v", err)))
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// For applying the changes of the config file that do not require a restart of the agent.
+func (a *API) configreload(w http.ResponseWriter, r *http.Request) {
+
+	resource := "config/reload"
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "PUT":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		pDevice, _ := persistence.FindExchangeDevice(a.db)
+
+		errHandled, result := ReloadConfig(a.configFile, a.Config, errorHandler)
+		if errHandled {
+			LogDeviceEvent(a.db, persistence.SEVERITY_ERROR, persistence.NewMessageMeta(EL_API_ERR_CONFIG_RELOADED, a.configFile), persistence.EC_ERROR_CONFIG_RELOADED, pDevice)
+			return
+		}
+
+		LogDeviceEvent(a.db, persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_API_CONFIG_RELOADED, a.configFile, result.Applied, result.RestartRequired), persistence.EC_CONFIG_RELOADED, pDevice)
+
+		// Tell the workers that cache config values about the applied changes.
+		if len(result.Applied) != 0 {
+			a.Messages() <- events.NewConfigReloadedMessage(events.CONFIG_RELOADED, result.Applied)
+		}
+
+		writeResponse(w, result, http.StatusOK)
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "PUT, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// For troubleshooting the node, the checks show what prevents the agent from working.
+func (a *API) diagnostics(w http.ResponseWriter, r *http.Request) {
+
+	resource := "diagnostics"
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "GET":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		nodeType := persistence.DEVICE_TYPE_DEVICE
+		if pDevice, err := persistence.FindExchangeDevice(a.db); err != nil {
+			errorHandler(NewSystemError(fmt.Sprintf("Unable to read node object, error %v", err)))
+			return
+		} else if pDevice != nil {
+			nodeType = pDevice.GetNodeType()
+		}
+
+		listContainers := func() ([]dockerclient.APIContainers, error) {
+			if a.Config.IsContainerdRuntime() {
+				return container.ListContainerdContainers(a.Config, false)
+			} else if client, err := dockerclient.NewClient(a.Config.Edge.DockerEndpoint); err != nil {
+				return nil, err
+			} else {
+				return client.ListContainers(dockerclient.ListContainersOptions{})
+			}
+		}
+		kubeVersion := func() (string, error) {
+			if client, err := cutil.NewKubeClient(); err != nil {
+				return "", err
+			} else if version, err := client.Discovery().ServerVersion(); err != nil {
+				return "", err
+			} else {
+				return version.GitVersion, nil
+			}
+		}
+
+		timeout := uint(10)
+		out := RunDiagnostics(a.Config, nodeType, a.GetExchangeURL(), a.GetCSSURL(), a.GetHTTPFactory().NewHTTPClient(&timeout), listContainers, kubeVersion)
+		writeResponse(w, out, http.StatusOK)
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -13,7 +13,7 @@ func tokenRandom(w http.ResponseWriter, r *http.Request) {
 	case "GET":
 		str, err := cutil.SecureRandomString()
 		if err != nil {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			writeAPIError(w, NewAPIError(http.StatusInternalServerError, API_ERR_INTERNAL, "Internal server error", "", ""))
 			return
 		}
 
@@ -24,14 +24,14 @@ func tokenRandom(w http.ResponseWriter, r *http.Request) {
 		serial, err := json.Marshal(out)
 		if err != nil {
 			glog.Error(apiLogString(err))
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			writeAPIError(w, NewAPIError(http.StatusInternalServerError, API_ERR_INTERNAL, "Internal server error", "", ""))
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
 
 		if _, err := w.Write(serial); err != nil {
 			glog.Error(apiLogString(err))
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			writeAPIError(w, NewAPIError(http.StatusInternalServerError, API_ERR_INTERNAL, "Internal server error", "", ""))
 			return
 		}
 
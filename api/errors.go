@@ -15,6 +15,69 @@ type ErrorHandler func(err error) bool
 // This type is used for the node related the functions
 type DeviceErrorHandler func(device interface{}, err error) bool
 
+// The codes of the errors returned by the API. Unlike the messages, the codes never change, so clients can rely on them
+// to decide how to handle an error.
+const (
+	API_ERR_INVALID_INPUT       = "INVALID_INPUT"
+	API_ERR_BAD_REQUEST         = "BAD_REQUEST"
+	API_ERR_NOT_FOUND           = "NOT_FOUND"
+	API_ERR_CONFLICT            = "CONFLICT"
+	API_ERR_TYPE_MISMATCH       = "TYPE_MISMATCH"
+	API_ERR_MISSING_USER_INPUT  = "MISSING_USER_INPUT"
+	API_ERR_DUPLICATE_SERVICE   = "DUPLICATE_SERVICE"
+	API_ERR_NODE_NOT_CONFIGURED = "NODE_NOT_CONFIGURED"
+	API_ERR_INVALID_NODE_STATE  = "INVALID_NODE_STATE"
+	API_ERR_UNAUTHORIZED        = "UNAUTHORIZED"
+	API_ERR_FORBIDDEN           = "FORBIDDEN"
+	API_ERR_SERVICE_UNAVAILABLE = "SERVICE_UNAVAILABLE"
+	API_ERR_INTERNAL            = "INTERNAL_ERROR"
+)
+
+// What the user can do about an error, when the error itself does not say.
+var apiErrorRemediations = map[string]string{
+	API_ERR_INVALID_INPUT:       "Correct the input named in the detail and retry the request.",
+	API_ERR_BAD_REQUEST:         "Correct the request and retry it.",
+	API_ERR_NOT_FOUND:           "Check that the resource named in the detail exists on the node.",
+	API_ERR_CONFLICT:            "The node is not in a state that allows the request. Check the node with 'hzn node list' and retry the request when it is.",
+	API_ERR_TYPE_MISMATCH:       "Use a service of the same type as the node, a device service on a device node or a cluster service on a cluster node.",
+	API_ERR_MISSING_USER_INPUT:  "Set the user input named in the detail with 'hzn userinput add' or in the user input file given to 'hzn register'.",
+	API_ERR_DUPLICATE_SERVICE:   "The service is already configured on the node, change its configuration with 'hzn userinput update' instead.",
+	API_ERR_NODE_NOT_CONFIGURED: "Register the node with 'hzn register' and retry the request.",
+	API_ERR_INVALID_NODE_STATE:  "Check the state of the node with 'hzn node list'. Use 'hzn unregister -D' to reset a node left in a bad state.",
+	API_ERR_UNAUTHORIZED:        "Send the token in the APITokenFile of the agent as a bearer token. The hzn command sends it when it can read the file.",
+	API_ERR_FORBIDDEN:           "Run the request as root, as the user running the agent or as one of the APIAllowedUsers of the agent.",
+	API_ERR_SERVICE_UNAVAILABLE: "Check the agent with 'hzn eventlog list' and retry the request later.",
+	API_ERR_INTERNAL:            "Check the agent with 'hzn eventlog list' and the agent log for the cause of the error.",
+}
+
+// APIError is the body of every error returned by the API. The Detail is usually the name of the input in error. The
+// Error and Input fields repeat the Message and Detail for the clients that read the errors before the code was added.
+type APIError struct {
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+	Detail      string `json:"detail,omitempty"`
+	Remediation string `json:"remediation,omitempty"`
+	Err         string `json:"error"`
+	Input       string `json:"input,omitempty"`
+	status      int    // the http status of the response
+}
+
+func (e APIError) Error() string {
+	return fmt.Sprintf("Code: %v, Message: %v, Detail: %v", e.Code, e.Message, e.Detail)
+}
+
+func NewAPIError(status int, code string, message string, detail string, remediation string) *APIError {
+	return &APIError{
+		Code:        code,
+		Message:     message,
+		Detail:      detail,
+		Remediation: remediation,
+		Err:         message,
+		Input:       detail,
+		status:      status,
+	}
+}
+
 // APIUserInputError is for problems found with input path variables or input bodies. The Input field is flexible;
 // could be a field name or other. Note: the info in this field is intended to be consumed by humans, either API
 // consumers or developers of the UI. Add enum codes if these are to be evaluated in frontend code.
@@ -180,56 +243,44 @@ func GetHTTPErrorHandler(w http.ResponseWriter) ErrorHandler {
 	return func(err error) bool {
 		if err != nil {
 			switch err.(type) {
+			case *APIError:
+				writeAPIError(w, err.(*APIError))
+
 			case *APIUserInputError:
-				apiErr := err.(*APIUserInputError)
-				writeInputErr(w, http.StatusBadRequest, apiErr)
+				inputErr := err.(*APIUserInputError)
+				writeAPIError(w, NewAPIError(http.StatusBadRequest, API_ERR_INVALID_INPUT, inputErr.Err, inputErr.Input, ""))
 
 			case *TypeMismatchError:
 				tmmErr := err.(*TypeMismatchError)
-				apiErr := NewAPIUserInputError(tmmErr.Err, tmmErr.Input)
-				writeInputErr(w, http.StatusBadRequest, apiErr)
+				writeAPIError(w, NewAPIError(http.StatusBadRequest, API_ERR_TYPE_MISMATCH, tmmErr.Err, tmmErr.Input, ""))
 
 			case *MSMissingVariableConfigError:
-				// convert to an API Input Error
 				msErr := err.(*MSMissingVariableConfigError)
-				apiErr := NewAPIUserInputError(msErr.Err, msErr.Input)
-				writeInputErr(w, http.StatusBadRequest, apiErr)
+				writeAPIError(w, NewAPIError(http.StatusBadRequest, API_ERR_MISSING_USER_INPUT, msErr.Err, msErr.Input, ""))
 
 			case *DuplicateServiceError:
-				// convert to an API Input Error
 				dupErr := err.(*DuplicateServiceError)
-				apiErr := NewAPIUserInputError(dupErr.Err, dupErr.Input)
-				writeInputErr(w, http.StatusBadRequest, apiErr)
+				writeAPIError(w, NewAPIError(http.StatusBadRequest, API_ERR_DUPLICATE_SERVICE, dupErr.Err, dupErr.Input, ""))
 
 			case *SystemError:
-				sysErr := err.(*SystemError)
-				glog.Errorf(apiLogString(sysErr.Error()))
-				http.Error(w, sysErr.Error(), http.StatusInternalServerError)
+				writeAPIError(w, NewAPIError(http.StatusInternalServerError, API_ERR_INTERNAL, err.Error(), "", ""))
 
 			case *ConflictError:
-				conErr := err.(*ConflictError)
-				glog.Errorf(apiLogString(conErr.Error()))
-				http.Error(w, conErr.Error(), http.StatusConflict)
+				writeAPIError(w, NewAPIError(http.StatusConflict, API_ERR_CONFLICT, err.Error(), "", ""))
 
 			case *BadRequestError:
-				badErr := err.(*BadRequestError)
-				glog.Errorf(apiLogString(badErr.Error()))
-				http.Error(w, badErr.Error(), http.StatusBadRequest)
+				writeAPIError(w, NewAPIError(http.StatusBadRequest, API_ERR_BAD_REQUEST, err.Error(), "", ""))
 
 			case *NotFoundError:
-				// convert to an API Input Error
 				notErr := err.(*NotFoundError)
-				apiErr := NewAPIUserInputError(notErr.Err, notErr.Input)
-				writeInputErr(w, http.StatusNotFound, apiErr)
+				writeAPIError(w, NewAPIError(http.StatusNotFound, API_ERR_NOT_FOUND, notErr.Err, notErr.Input, ""))
 
 			case *ServiceUnavailableError:
-				suErr := err.(*ServiceUnavailableError)
-				glog.Errorf(apiLogString(suErr.Error()))
-				http.Error(w, suErr.Error(), http.StatusServiceUnavailable)
+				writeAPIError(w, NewAPIError(http.StatusServiceUnavailable, API_ERR_SERVICE_UNAVAILABLE, err.Error(), "", ""))
 
 			default:
 				glog.Errorf(apiLogString(fmt.Sprintf("unknown error (%T) %v", err, err.Error())))
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				writeAPIError(w, NewAPIError(http.StatusInternalServerError, API_ERR_INTERNAL, "Internal server error", "", ""))
 
 			}
 			// tell the caller they should not continue processing
@@ -241,19 +292,23 @@ func GetHTTPErrorHandler(w http.ResponseWriter) ErrorHandler {
 	}
 }
 
-// use this function to properly write a User Input Error to the http response.
-func writeInputErr(writer http.ResponseWriter, status int, inputErr *APIUserInputError) {
-	if serial, err := json.Marshal(inputErr); err != nil {
-		glog.Errorf(apiLogString(fmt.Sprintf("Error serializing input error: %v, error %v", inputErr, err)))
+// Use this function to properly write an error to the http response. The remediation of the error code is used when the
+// error does not have one.
+func writeAPIError(writer http.ResponseWriter, apiErr *APIError) {
+	if apiErr.Remediation == "" {
+		apiErr.Remediation = apiErrorRemediations[apiErr.Code]
+	}
+
+	if serial, err := json.Marshal(apiErr); err != nil {
+		glog.Errorf(apiLogString(fmt.Sprintf("Error serializing error: %v, error %v", apiErr, err)))
 		http.Error(writer, "Internal server error", http.StatusInternalServerError)
 	} else {
-		writer.WriteHeader(status)
 		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(apiErr.status)
 		if _, err := writer.Write(serial); err != nil {
 			glog.Errorf(apiLogString(fmt.Sprintf("Error writing response: %v, error %v", serial, err)))
-			http.Error(writer, "Internal server error", http.StatusInternalServerError)
 		} else {
-			glog.Errorf(apiLogString(fmt.Sprintf("Returning status %v for error %v", status, string(serial))))
+			glog.Errorf(apiLogString(fmt.Sprintf("Returning status %v for error %v", apiErr.status, string(serial))))
 		}
 	}
 }
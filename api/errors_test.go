@@ -4,6 +4,9 @@
 package api
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 )
@@ -62,3 +65,45 @@ func Test_PassthruHandler(t *testing.T) {
 	}
 
 }
+
+// Verify that every error is written as the same envelope, with the code and status of the error.
+func Test_HTTPErrorHandler(t *testing.T) {
+
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{NewAPIUserInputError("bad value", "node.name"), http.StatusBadRequest, API_ERR_INVALID_INPUT},
+		{NewMSMissingVariableConfigError("variable missing", "var1"), http.StatusBadRequest, API_ERR_MISSING_USER_INPUT},
+		{NewNotFoundError("not found", "agreement"), http.StatusNotFound, API_ERR_NOT_FOUND},
+		{NewConflictError("conflict"), http.StatusConflict, API_ERR_CONFLICT},
+		{NewSystemError("db error"), http.StatusInternalServerError, API_ERR_INTERNAL},
+		{NewServiceUnavailableError("shutting down"), http.StatusServiceUnavailable, API_ERR_SERVICE_UNAVAILABLE},
+		{NewAPIError(http.StatusBadRequest, API_ERR_INVALID_NODE_STATE, "wrong state", "node", "do this"), http.StatusBadRequest, API_ERR_INVALID_NODE_STATE},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		if !GetHTTPErrorHandler(w)(test.err) {
+			t.Errorf("the error %v should have been handled", test.err)
+		}
+
+		var apiErr APIError
+		if w.Code != test.status {
+			t.Errorf("%v should return %v, but got %v", test.err, test.status, w.Code)
+		} else if w.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%v should be returned as json, but got %v", test.err, w.Header().Get("Content-Type"))
+		} else if err := json.Unmarshal(w.Body.Bytes(), &apiErr); err != nil {
+			t.Errorf("unable to unmarshal %v, error %v", w.Body.String(), err)
+		} else if apiErr.Code != test.code || apiErr.Message == "" || apiErr.Message != apiErr.Err || apiErr.Remediation == "" {
+			t.Errorf("wrong error %v for %v", apiErr, test.err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	GetHTTPErrorHandler(w)(NewAPIError(http.StatusBadRequest, API_ERR_INVALID_NODE_STATE, "wrong state", "node", "do this"))
+	if !strings.Contains(w.Body.String(), `"remediation":"do this"`) {
+		t.Errorf("the remediation of the error should have been kept, but got %v", w.Body.String())
+	}
+}
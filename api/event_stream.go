@@ -0,0 +1,212 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/persistence"
+	"golang.org/x/text/message"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The types of the events pushed to the clients of the /events/stream resource.
+const (
+	STREAM_EVENT_AGREEMENT = "agreement"
+	STREAM_EVENT_SERVICE   = "service"
+	STREAM_EVENT_EVENTLOG  = "eventlog"
+)
+
+// The number of events buffered for each client of the event stream. The events are dropped for a client that does not
+// read them fast enough, the agent is never held up by a slow client.
+const EVENT_STREAM_BUFFER_SIZE = 100
+
+// The seconds between the comments written to an idle event stream, so that the connection is not closed by a proxy.
+const EVENT_STREAM_KEEPALIVE_S = 30
+
+// An event pushed to the clients of the event stream. The data is an AgreementStreamEvent, a ServiceStreamEvent or a
+// persistence.EventLog, depending on the type.
+type StreamEvent struct {
+	Type      string      `json:"type"`
+	Timestamp uint64      `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+func (e StreamEvent) String() string {
+	return fmt.Sprintf("Type: %v, Timestamp: %v, Data: %v", e.Type, e.Timestamp, e.Data)
+}
+
+func NewStreamEvent(eventType string, data interface{}) *StreamEvent {
+	return &StreamEvent{
+		Type:      eventType,
+		Timestamp: uint64(time.Now().Unix()),
+		Data:      data,
+	}
+}
+
+// A change in the lifecycle of an agreement.
+type AgreementStreamEvent struct {
+	Event       string `json:"event"`
+	AgreementId string `json:"agreement_id"`
+	Protocol    string `json:"protocol,omitempty"`
+	Cause       string `json:"cause,omitempty"`
+}
+
+// A change in the status of a service, or of one of its instances.
+type ServiceStreamEvent struct {
+	Event       string `json:"event"`
+	InstanceKey string `json:"instance_key,omitempty"`
+	Url         string `json:"url,omitempty"`
+	Org         string `json:"org,omitempty"`
+	Version     string `json:"version,omitempty"`
+	ConfigState string `json:"config_state,omitempty"`
+}
+
+// Fans out the events of the agent to the clients of the /events/stream resource.
+type EventStream struct {
+	lock        sync.Mutex
+	subscribers map[chan *StreamEvent]bool
+}
+
+func NewEventStream() *EventStream {
+	return &EventStream{
+		subscribers: make(map[chan *StreamEvent]bool),
+	}
+}
+
+// Returns the channel on which the events are delivered to a new client.
+func (s *EventStream) Subscribe() chan *StreamEvent {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ch := make(chan *StreamEvent, EVENT_STREAM_BUFFER_SIZE)
+	s.subscribers[ch] = true
+	return ch
+}
+
+func (s *EventStream) Unsubscribe(ch chan *StreamEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.subscribers, ch)
+}
+
+func (s *EventStream) NumSubscribers() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.subscribers)
+}
+
+// Delivers the event to all the clients without blocking.
+func (s *EventStream) Publish(event *StreamEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+			glog.Warningf(apiLogString(fmt.Sprintf("Event stream client is not keeping up, dropping event %v", event)))
+		}
+	}
+}
+
+func (s *EventStream) PublishEventLog(el persistence.EventLog) {
+	s.Publish(NewStreamEvent(STREAM_EVENT_EVENTLOG, el))
+}
+
+// Convert an internal message into the events pushed to the clients of the event stream. Most messages are not of
+// interest to the clients, nil is returned for them.
+func NewStreamEventsFromMessage(msg events.Message) []*StreamEvent {
+
+	switch msg.(type) {
+	case *events.AgreementReachedMessage:
+		m, _ := msg.(*events.AgreementReachedMessage)
+		if lc := m.LaunchContext(); lc != nil {
+			return []*StreamEvent{NewStreamEvent(STREAM_EVENT_AGREEMENT, AgreementStreamEvent{Event: string(m.Event().Id), AgreementId: lc.AgreementId, Protocol: lc.AgreementProtocol})}
+		}
+
+	case *events.WorkloadMessage:
+		m, _ := msg.(*events.WorkloadMessage)
+		switch m.Event().Id {
+		case events.EXECUTION_BEGUN, events.EXECUTION_FAILED, events.WORKLOAD_DESTROYED:
+			return []*StreamEvent{NewStreamEvent(STREAM_EVENT_AGREEMENT, AgreementStreamEvent{Event: string(m.Event().Id), AgreementId: m.AgreementId, Protocol: m.AgreementProtocol})}
+		}
+
+	case *events.GovernanceWorkloadCancelationMessage:
+		m, _ := msg.(*events.GovernanceWorkloadCancelationMessage)
+		if m.Event().Id == events.AGREEMENT_ENDED {
+			return []*StreamEvent{NewStreamEvent(STREAM_EVENT_AGREEMENT, AgreementStreamEvent{Event: string(m.Event().Id), AgreementId: m.AgreementId, Protocol: m.AgreementProtocol, Cause: string(m.Cause)})}
+		}
+
+	case *events.ContainerMessage:
+		m, _ := msg.(*events.ContainerMessage)
+		switch m.Event().Id {
+		case events.EXECUTION_BEGUN, events.EXECUTION_FAILED:
+			se := ServiceStreamEvent{Event: string(m.Event().Id), InstanceKey: m.LaunchContext.Name}
+			if len(m.LaunchContext.ServicePath) > 0 {
+				svc := m.LaunchContext.ServicePath[len(m.LaunchContext.ServicePath)-1]
+				se.Url, se.Org, se.Version = svc.URL, svc.Org, svc.Version
+			}
+			return []*StreamEvent{NewStreamEvent(STREAM_EVENT_SERVICE, se)}
+		}
+
+	case *events.MicroserviceContainersDestroyedMessage:
+		m, _ := msg.(*events.MicroserviceContainersDestroyedMessage)
+		return []*StreamEvent{NewStreamEvent(STREAM_EVENT_SERVICE, ServiceStreamEvent{Event: string(m.Event().Id), InstanceKey: m.MsInstKey})}
+
+	case *events.ServicePauseMessage:
+		m, _ := msg.(*events.ServicePauseMessage)
+		return []*StreamEvent{NewStreamEvent(STREAM_EVENT_SERVICE, ServiceStreamEvent{Event: string(m.Event().Id), Url: m.Url, Org: m.Org})}
+
+	case *events.ServiceConfigStateChangeMessage:
+		m, _ := msg.(*events.ServiceConfigStateChangeMessage)
+		out := make([]*StreamEvent, 0, len(m.ServiceConfigState))
+		for _, scs := range m.ServiceConfigState {
+			out = append(out, NewStreamEvent(STREAM_EVENT_SERVICE, ServiceStreamEvent{Event: string(m.Event().Id), Url: scs.Url, Org: scs.Org, Version: scs.Version, ConfigState: scs.ConfigState}))
+		}
+		return out
+	}
+
+	return nil
+}
+
+// Parse the comma separated list of event types the client wants to receive. All the types are streamed when the
+// list is empty.
+func ParseStreamEventTypes(types string) (map[string]bool, error) {
+	out := map[string]bool{}
+	if types == "" {
+		types = strings.Join([]string{STREAM_EVENT_AGREEMENT, STREAM_EVENT_SERVICE, STREAM_EVENT_EVENTLOG}, ",")
+	}
+
+	for _, t := range strings.Split(types, ",") {
+		switch t = strings.TrimSpace(t); t {
+		case STREAM_EVENT_AGREEMENT, STREAM_EVENT_SERVICE, STREAM_EVENT_EVENTLOG:
+			out[t] = true
+		default:
+			return nil, errors.New(fmt.Sprintf("unsupported event type %v, the supported types are %v, %v and %v", t, STREAM_EVENT_AGREEMENT, STREAM_EVENT_SERVICE, STREAM_EVENT_EVENTLOG))
+		}
+	}
+	return out, nil
+}
+
+// Write the event in the server-sent events format. The message of an event log is translated with the message printer
+// of the client.
+func WriteStreamEvent(w io.Writer, event *StreamEvent, msgPrinter *message.Printer) error {
+	out := *event
+	if el, ok := event.Data.(persistence.EventLog); ok && el.MessageMeta != nil && el.MessageMeta.MessageKey != "" {
+		el.Message = msgPrinter.Sprintf(el.MessageMeta.MessageKey, el.MessageMeta.MessageArgs...)
+		el.MessageMeta = nil
+		out.Data = el
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to marshal event %v, error %v", event, err))
+	}
+	_, err = fmt.Fprintf(w, "event: %v\ndata: %s\n\n", out.Type, data)
+	return err
+}
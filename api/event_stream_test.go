@@ -0,0 +1,167 @@
+//go:build unit
+// +build unit
+
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/persistence"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Verify that the agreement and service messages are converted into stream events, and that the other messages are not.
+func Test_NewStreamEventsFromMessage(t *testing.T) {
+
+	lc := &events.AgreementLaunchContext{AgreementProtocol: "Basic", AgreementId: "ag1"}
+	if evs := NewStreamEventsFromMessage(events.NewAgreementMessage(events.AGREEMENT_REACHED, lc)); len(evs) != 1 || evs[0].Type != STREAM_EVENT_AGREEMENT {
+		t.Errorf("wrong events for agreement reached %v", evs)
+	} else if data := evs[0].Data.(AgreementStreamEvent); data.AgreementId != "ag1" || data.Event != string(events.AGREEMENT_REACHED) {
+		t.Errorf("wrong agreement event %v", data)
+	}
+
+	if evs := NewStreamEventsFromMessage(events.NewGovernanceWorkloadCancelationMessage(events.AGREEMENT_ENDED, events.AG_TERMINATED, "Basic", "ag1", "", nil)); len(evs) != 1 {
+		t.Errorf("wrong events for agreement ended %v", evs)
+	} else if data := evs[0].Data.(AgreementStreamEvent); data.Cause != string(events.AG_TERMINATED) {
+		t.Errorf("wrong agreement event %v", data)
+	}
+
+	clc := events.ContainerLaunchContext{Name: "svc-key", ServicePath: []persistence.ServiceInstancePathElement{{URL: "gps", Org: "myorg", Version: "1.0.0"}}}
+	if evs := NewStreamEventsFromMessage(events.NewContainerMessage(events.EXECUTION_BEGUN, clc, "", "")); len(evs) != 1 || evs[0].Type != STREAM_EVENT_SERVICE {
+		t.Errorf("wrong events for service started %v", evs)
+	} else if data := evs[0].Data.(ServiceStreamEvent); data.InstanceKey != "svc-key" || data.Url != "gps" || data.Org != "myorg" {
+		t.Errorf("wrong service event %v", data)
+	}
+
+	scs := []events.ServiceConfigState{{Url: "gps", Org: "myorg", ConfigState: "suspended"}, {Url: "netspeed", Org: "myorg", ConfigState: "suspended"}}
+	if evs := NewStreamEventsFromMessage(events.NewServiceConfigStateChangeMessage(events.SERVICE_CONFIG_STATE_CHANGED, scs)); len(evs) != 2 {
+		t.Errorf("there should be an event for each service, but got %v", evs)
+	}
+
+	if evs := NewStreamEventsFromMessage(events.NewProposalAcceptedMessage(events.PROPOSAL_ACCEPTED)); evs != nil {
+		t.Errorf("there should be no events, but got %v", evs)
+	}
+}
+
+// Verify that the events are delivered to all the clients, and are dropped for a client that does not read them.
+func Test_EventStream_Publish(t *testing.T) {
+
+	stream := NewEventStream()
+	ch1 := stream.Subscribe()
+	ch2 := stream.Subscribe()
+
+	for i := 0; i < EVENT_STREAM_BUFFER_SIZE+10; i++ {
+		stream.Publish(NewStreamEvent(STREAM_EVENT_SERVICE, ServiceStreamEvent{Event: "test"}))
+		<-ch1
+	}
+
+	if len(ch1) != 0 {
+		t.Errorf("the events should have been read, but %v are left", len(ch1))
+	} else if len(ch2) != EVENT_STREAM_BUFFER_SIZE {
+		t.Errorf("the events should have been dropped after %v, but %v are buffered", EVENT_STREAM_BUFFER_SIZE, len(ch2))
+	}
+
+	stream.Unsubscribe(ch1)
+	stream.Unsubscribe(ch2)
+	if stream.NumSubscribers() != 0 {
+		t.Errorf("there should be no clients, but got %v", stream.NumSubscribers())
+	}
+}
+
+func Test_ParseStreamEventTypes(t *testing.T) {
+
+	if types, err := ParseStreamEventTypes(""); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(types) != 3 {
+		t.Errorf("all the types should be streamed, but got %v", types)
+	}
+
+	if types, err := ParseStreamEventTypes("agreement, eventlog"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if !types[STREAM_EVENT_AGREEMENT] || !types[STREAM_EVENT_EVENTLOG] || types[STREAM_EVENT_SERVICE] {
+		t.Errorf("wrong types %v", types)
+	}
+
+	if _, err := ParseStreamEventTypes("agreement,node"); err == nil {
+		t.Errorf("should have returned an error for an unknown type")
+	}
+}
+
+// Verify that the message of an event log is translated when it is written to the stream.
+func Test_WriteStreamEvent(t *testing.T) {
+
+	meta := persistence.NewMessageMeta("Start agreement %v", "ag1")
+	el := persistence.NewEventLog(persistence.SEVERITY_INFO, meta, persistence.EC_AGREEMENT_REACHED, persistence.SRC_TYPE_AG, persistence.AgreementEventSource{AgreementId: "ag1"})
+
+	var buf bytes.Buffer
+	if err := WriteStreamEvent(&buf, NewStreamEvent(STREAM_EVENT_EVENTLOG, *el), i18n.GetMessagePrinter()); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if !strings.HasPrefix(buf.String(), "event: eventlog\ndata: ") || !strings.HasSuffix(buf.String(), "\n\n") {
+		t.Errorf("wrong format of the event %v", buf.String())
+	}
+
+	var out struct {
+		Type string `json:"type"`
+		Data struct {
+			Message     string                   `json:"message"`
+			MessageMeta *persistence.MessageMeta `json:"message_meta"`
+		} `json:"data"`
+	}
+	line := strings.TrimSuffix(strings.TrimPrefix(buf.String(), "event: eventlog\ndata: "), "\n\n")
+	if err := json.Unmarshal([]byte(line), &out); err != nil {
+		t.Errorf("unable to unmarshal the event %v, error %v", line, err)
+	} else if out.Data.Message != "Start agreement ag1" || out.Data.MessageMeta != nil {
+		t.Errorf("the message should have been translated, but got %v", out)
+	}
+}
+
+// Verify that the events are pushed to a client of the /events/stream resource as they occur.
+func Test_EventStream_Handler(t *testing.T) {
+
+	a := &API{stream: NewEventStream()}
+	server := httptest.NewServer(http.HandlerFunc(a.eventstream))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"?type=service", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unable to connect to the event stream, error %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("wrong response %v", resp)
+	}
+
+	// the client is subscribed once the headers are sent
+	a.NewEvent(events.NewAgreementMessage(events.AGREEMENT_REACHED, &events.AgreementLaunchContext{AgreementId: "ag1"}))
+	a.NewEvent(events.NewServicePauseMessage(events.SERVICE_PAUSED, "gps", "myorg"))
+
+	reader := bufio.NewReader(resp.Body)
+	if line, err := reader.ReadString('\n'); err != nil {
+		t.Errorf("unable to read the event stream, error %v", err)
+	} else if line != "event: service\n" {
+		t.Errorf("only the service event should be streamed, but got %v", line)
+	} else if line, err := reader.ReadString('\n'); err != nil || !strings.Contains(line, `"event":"SERVICE_PAUSED"`) {
+		t.Errorf("wrong service event %v, error %v", line, err)
+	}
+
+	cancel()
+	for i := 0; i < 50 && a.stream.NumSubscribers() != 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if a.stream.NumSubscribers() != 0 {
+		t.Errorf("the client should have been unsubscribed")
+	}
+}
@@ -23,6 +23,27 @@ func (c *Configstate) String() string {
 	}
 }
 
+// The maintenance mode of the node. StartTime is only used in the output.
+type NodeMaintenance struct {
+	Enabled   *bool   `json:"enabled"`
+	Drain     *bool   `json:"drain,omitempty"`      // cancel the existing agreements when maintenance mode is turned on
+	StartTime *uint64 `json:"start_time,omitempty"` // the time maintenance mode was turned on
+}
+
+func (m NodeMaintenance) String() string {
+	enabled, drain, startTime := "not set", "not set", "not set"
+	if m.Enabled != nil {
+		enabled = strconv.FormatBool(*m.Enabled)
+	}
+	if m.Drain != nil {
+		drain = strconv.FormatBool(*m.Drain)
+	}
+	if m.StartTime != nil {
+		startTime = strconv.FormatUint(*m.StartTime, 10)
+	}
+	return fmt.Sprintf("Enabled: %v, Drain: %v, StartTime: %v", enabled, drain, startTime)
+}
+
 type HorizonDevice struct {
 	Id                 *string      `json:"id"`
 	Org                *string      `json:"organization"`
@@ -212,6 +233,16 @@ func (s *Service) String() string {
 	return fmt.Sprintf("Url: %v, Org: %v, Name: %v, Arch: %v, VersionRange: %v, AutoUpgrade: %v, ActiveUpgrade: %v, Attributes: %v", sURL, sOrg, sName, sArch, sVersion, auto_upgrade, active_upgrade, s.Attributes)
 }
 
+// The service to pause or resume on the node.
+type ServicePause struct {
+	Url string `json:"url"` // The URL of the service definition.
+	Org string `json:"org"` // The org that holds the service definition.
+}
+
+func (s ServicePause) String() string {
+	return fmt.Sprintf("Url: %v, Org: %v", s.Url, s.Org)
+}
+
 // Constructor used to create service objects for programmatic creation of services.
 func NewService(url string, org string, name string, arch string, v string) *Service {
 	autoUpgrade := microservice.MS_DEFAULT_AUTOUPGRADE
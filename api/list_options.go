@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/open-horizon/anax/persistence"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The query parameters that page, limit to a time range, and select the fields of the items returned by the list
+// resources (/agreement, /eventlog and /service). The other query parameters are selections on the fields of the items,
+// in the same format as the /eventlog selections.
+const (
+	LIST_LIMIT  = "limit"
+	LIST_OFFSET = "offset"
+	LIST_SINCE  = "since"
+	LIST_UNTIL  = "until"
+	LIST_FIELDS = "fields"
+)
+
+type ListOptions struct {
+	Limit  int      // the maximum number of items to return, 0 means no limit
+	Offset int      // the number of matching items to skip
+	Since  uint64   // only return the items from this time on (unix seconds), 0 means no lower bound
+	Until  uint64   // only return the items up to this time (unix seconds), 0 means no upper bound
+	Fields []string // the json fields of the items to return, all the fields when empty
+}
+
+func (o ListOptions) String() string {
+	return fmt.Sprintf("Limit: %v, Offset: %v, Since: %v, Until: %v, Fields: %v", o.Limit, o.Offset, o.Since, o.Until, o.Fields)
+}
+
+// Returns true when the items are returned as they are.
+func (o ListOptions) IsEmpty() bool {
+	return o.Limit == 0 && o.Offset == 0 && o.Since == 0 && o.Until == 0 && len(o.Fields) == 0
+}
+
+// Parse the list options out of the query parameters. The query parameters that are not list options are returned as
+// the selections.
+func NewListOptions(form url.Values) (*ListOptions, map[string][]string, error) {
+	opts := &ListOptions{}
+	selections := make(map[string][]string)
+
+	for key, vals := range form {
+		if len(vals) == 0 {
+			continue
+		}
+		val := vals[len(vals)-1]
+
+		var err error
+		switch key {
+		case LIST_LIMIT:
+			opts.Limit, err = parseListInt(key, val)
+		case LIST_OFFSET:
+			opts.Offset, err = parseListInt(key, val)
+		case LIST_SINCE:
+			opts.Since, err = strconv.ParseUint(val, 10, 64)
+		case LIST_UNTIL:
+			opts.Until, err = strconv.ParseUint(val, 10, 64)
+		case LIST_FIELDS:
+			for _, f := range strings.Split(val, ",") {
+				if f = strings.TrimSpace(f); f != "" {
+					opts.Fields = append(opts.Fields, f)
+				}
+			}
+		default:
+			selections[key] = vals
+		}
+
+		if err != nil {
+			return nil, nil, errors.New(fmt.Sprintf("the %v query parameter %v is not valid, it must be a non-negative integer", key, val))
+		}
+	}
+
+	if opts.Until != 0 && opts.Until < opts.Since {
+		return nil, nil, errors.New(fmt.Sprintf("the %v query parameter %v is before the %v query parameter %v", LIST_UNTIL, opts.Until, LIST_SINCE, opts.Since))
+	}
+	return opts, selections, nil
+}
+
+func parseListInt(key string, val string) (int, error) {
+	n, err := strconv.Atoi(val)
+	if err == nil && n < 0 {
+		err = errors.New(fmt.Sprintf("%v is negative", key))
+	}
+	return n, err
+}
+
+// Filter the given slice of items by the time range and the selectors, and return the requested page of the items with
+// the requested fields. The items and the selectors are matched using the json form of the items, a dotted name such as
+// workload_to_run.url selects a field of an embedded object. The time range is applied to the given time field, it is
+// not applied when the time field is empty. The items are returned as they are when no fields are requested.
+func (o ListOptions) Apply(items interface{}, timeField string, selectors map[string][]persistence.Selector) ([]interface{}, error) {
+
+	v := reflect.ValueOf(items)
+	if v.Kind() != reflect.Slice {
+		return nil, errors.New(fmt.Sprintf("unable to apply list options to %v, it is not a list", v.Kind()))
+	}
+
+	out := make([]interface{}, 0)
+	skipped := 0
+	for i := 0; i < v.Len(); i++ {
+		if o.Limit != 0 && len(out) == o.Limit {
+			break
+		}
+
+		item := v.Index(i).Interface()
+		fields, err := toJsonFields(item)
+		if err != nil {
+			return nil, err
+		}
+
+		if !o.inTimeRange(fields, timeField) || !matchJsonFields(fields, selectors) {
+			continue
+		} else if skipped < o.Offset {
+			skipped++
+			continue
+		}
+
+		if len(o.Fields) == 0 {
+			out = append(out, item)
+		} else {
+			selected := make(map[string]interface{})
+			for _, f := range o.Fields {
+				if val, ok := getJsonField(fields, f); ok {
+					selected[f] = val
+				}
+			}
+			out = append(out, selected)
+		}
+	}
+	return out, nil
+}
+
+// Apply the list options to each of the lists in the given map, such as the active and archived agreements.
+func (o ListOptions) ApplyToEach(lists interface{}, timeField string, selectors map[string][]persistence.Selector) (map[string][]interface{}, error) {
+
+	v := reflect.ValueOf(lists)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil, errors.New(fmt.Sprintf("unable to apply list options to %v, it is not a map of lists", v.Kind()))
+	}
+
+	out := make(map[string][]interface{})
+	iter := v.MapRange()
+	for iter.Next() {
+		if items, err := o.Apply(iter.Value().Interface(), timeField, selectors); err != nil {
+			return nil, err
+		} else {
+			out[iter.Key().String()] = items
+		}
+	}
+	return out, nil
+}
+
+func (o ListOptions) inTimeRange(fields map[string]interface{}, timeField string) bool {
+	if timeField == "" || (o.Since == 0 && o.Until == 0) {
+		return true
+	}
+
+	val, _ := getJsonField(fields, timeField)
+	t, ok := val.(float64)
+	if !ok {
+		return false
+	}
+	return uint64(t) >= o.Since && (o.Until == 0 || uint64(t) <= o.Until)
+}
+
+// The json form of the item as a map, so that the fields can be selected by their json names.
+func toJsonFields(item interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if b, err := json.Marshal(item); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to marshal %v, error %v", item, err))
+	} else if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to unmarshal %v, error %v", string(b), err))
+	}
+	return fields, nil
+}
+
+func getJsonField(fields map[string]interface{}, name string) (interface{}, bool) {
+	var val interface{} = fields
+	for _, part := range strings.Split(name, ".") {
+		m, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, false
+		} else if val, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return val, val != nil
+}
+
+// An item matches when all the selectors match. A selector on a field the item does not have does not match.
+func matchJsonFields(fields map[string]interface{}, selectors map[string][]persistence.Selector) bool {
+	for name, s := range selectors {
+		if val, ok := getJsonField(fields, name); !ok {
+			return false
+		} else if m, _, err := persistence.MatchAttributeValue(val, s); err != nil || !m {
+			return false
+		}
+	}
+	return true
+}
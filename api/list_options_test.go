@@ -0,0 +1,114 @@
+//go:build unit
+// +build unit
+
+package api
+
+import (
+	"github.com/open-horizon/anax/persistence"
+	"net/url"
+	"testing"
+)
+
+func Test_NewListOptions(t *testing.T) {
+
+	form := url.Values{"limit": {"10"}, "offset": {"20"}, "since": {"100"}, "until": {"200"}, "fields": {"a, b.c"}, "severity": {"error"}}
+	if opts, selections, err := NewListOptions(form); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if opts.Limit != 10 || opts.Offset != 20 || opts.Since != 100 || opts.Until != 200 || len(opts.Fields) != 2 || opts.Fields[1] != "b.c" {
+		t.Errorf("wrong list options %v", opts)
+	} else if len(selections) != 1 || selections["severity"][0] != "error" {
+		t.Errorf("wrong selections %v", selections)
+	}
+
+	if opts, selections, err := NewListOptions(url.Values{}); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if !opts.IsEmpty() || len(selections) != 0 {
+		t.Errorf("there should be no list options, but got %v %v", opts, selections)
+	}
+
+	for _, form := range []url.Values{{"limit": {"-1"}}, {"offset": {"a"}}, {"since": {"-5"}}, {"since": {"200"}, "until": {"100"}}} {
+		if _, _, err := NewListOptions(form); err == nil {
+			t.Errorf("should have returned an error for %v", form)
+		}
+	}
+}
+
+// Verify that the items are filtered by time and selection before they are paged, and that only the requested fields
+// are returned.
+func Test_ListOptions_Apply(t *testing.T) {
+
+	ags := []persistence.EstablishedAgreement{}
+	for i := 1; i <= 10; i++ {
+		url := "netspeed"
+		if i%2 == 0 {
+			url = "gps"
+		}
+		ags = append(ags, persistence.EstablishedAgreement{
+			CurrentAgreementId:    string(rune('a' + i)),
+			AgreementCreationTime: uint64(i * 100),
+			RunningWorkload:       persistence.WorkloadInfo{URL: url, Org: "myorg"},
+		})
+	}
+
+	// no options
+	if out, err := (ListOptions{}).Apply(ags, "agreement_creation_time", nil); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(out) != 10 {
+		t.Errorf("all the agreements should be returned, but got %v", len(out))
+	}
+
+	// paging
+	if out, err := (ListOptions{Limit: 3, Offset: 8}).Apply(ags, "agreement_creation_time", nil); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(out) != 2 || out[0].(persistence.EstablishedAgreement).AgreementCreationTime != 900 {
+		t.Errorf("wrong page %v", out)
+	}
+
+	// time range and selection on a field of an embedded object
+	selectors, _ := persistence.ConvertToSelectors(map[string][]string{"workload_to_run.url": {"gps"}})
+	if out, err := (ListOptions{Since: 300, Until: 800, Offset: 1}).Apply(ags, "agreement_creation_time", selectors); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(out) != 2 || out[0].(persistence.EstablishedAgreement).AgreementCreationTime != 600 || out[1].(persistence.EstablishedAgreement).AgreementCreationTime != 800 {
+		t.Errorf("wrong agreements %v", out)
+	}
+
+	// a selection on a field the items do not have matches nothing
+	selectors, _ = persistence.ConvertToSelectors(map[string][]string{"nofield": {"x"}})
+	if out, err := (ListOptions{}).Apply(ags, "", selectors); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(out) != 0 {
+		t.Errorf("no agreements should be returned, but got %v", len(out))
+	}
+
+	// field selection
+	if out, err := (ListOptions{Limit: 1, Fields: []string{"current_agreement_id", "workload_to_run.url", "nofield"}}).Apply(ags, "", nil); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if fields, ok := out[0].(map[string]interface{}); !ok || len(fields) != 2 || fields["current_agreement_id"] != "b" || fields["workload_to_run.url"] != "netspeed" {
+		t.Errorf("wrong fields %v", out[0])
+	}
+
+	if _, err := (ListOptions{}).Apply("notalist", "", nil); err == nil {
+		t.Errorf("should have returned an error")
+	}
+}
+
+func Test_SelectAgreementsForOutput(t *testing.T) {
+
+	agreements := map[string]map[string][]persistence.EstablishedAgreement{
+		"agreements": {
+			"active":   {{CurrentAgreementId: "ag1", AgreementCreationTime: 100}, {CurrentAgreementId: "ag2", AgreementCreationTime: 200}},
+			"archived": {{CurrentAgreementId: "ag3", AgreementCreationTime: 50}},
+		},
+	}
+
+	var myError error
+	errorhandler := GetPassThroughErrorHandler(&myError)
+
+	if errHandled, out := SelectAgreementsForOutput(agreements, &ListOptions{Limit: 1, Fields: []string{"current_agreement_id"}}, map[string][]string{}, errorhandler); errHandled {
+		t.Errorf("should not return error, but got %v", myError)
+	} else if len(out["agreements"]["active"]) != 1 || len(out["agreements"]["archived"]) != 1 {
+		t.Errorf("each list should be limited separately, but got %v", out)
+	} else if out["agreements"]["active"][0].(map[string]interface{})["current_agreement_id"] != "ag1" {
+		t.Errorf("wrong agreement %v", out["agreements"]["active"][0])
+	}
+}
@@ -38,6 +38,7 @@ const (
 	EL_API_ERR_NODE_UNREG_WRONG_VALUE_FOR_RN    = "Input error for node unregistration. %v is an incorrect value for removeNode"
 	EL_API_ERR_NODE_UNREG_WRONG_VALUE_FOR_DC    = "Input error for node unregistration. %v is an incorrect value for deepClean"
 	EL_API_ERR_NODE_UNREG_WRONG_VALUE_FOR_BLOCK = "Input error for node unregistration. %v is an incorrect value for block"
+	EL_API_ERR_NODE_UNREG_WRONG_EXPORT_PATH     = "Input error for node unregistration. %v is an incorrect value for exportPath: %v"
 
 	EL_API_ERR_READ_NODE_FROM_DB    = "Unable to read node object from database, error %v"
 	EL_API_ERR_SAVE_NODE_CONF_TO_DB = "Error saving new node config state (unconfiguring) in the database: %v"
@@ -61,8 +62,18 @@ const (
 	// from path_node_userinput.go
 	EL_API_NEW_NODE_UI         = "New node user input: %v"
 	EL_API_NO_NODE_UI_TO_DEL   = "No node user input to detele"
+	EL_API_NODE_UI_UNCHANGED   = "The node user input is not changed by the patch: %v"
 	EL_API_DELETED_ALL_NODE_UI = "Deleted all node user input"
 
+	// from path_config.go
+	EL_API_CONFIG_RELOADED     = "Agent configuration reloaded from %v. Applied: %v. Restart required: %v."
+	EL_API_ERR_CONFIG_RELOADED = "Error reloading the agent configuration from %v, the running configuration is unchanged."
+
+	// from path_node_maintenance.go
+	EL_API_NODE_MAINTENANCE_ON       = "Node maintenance mode turned on, new agreements are not accepted."
+	EL_API_NODE_MAINTENANCE_ON_DRAIN = "Node maintenance mode turned on, new agreements are not accepted and %v existing agreements are being cancelled."
+	EL_API_NODE_MAINTENANCE_OFF      = "Node maintenance mode turned off, new agreements are accepted."
+
 	// from path_service_config.go
 	EL_API_START_SVC_CONFIG           = "Start service configuration with user input for %v/%v."
 	EL_API_START_SVC_AUTO_CONFIG      = "Start service auto configuration for %v/%v."
@@ -75,6 +86,8 @@ const (
 	EL_API_ERR_CHANGE_SVC_CONFIGSTATE      = "Error changing service configstate %v, error %v"
 	EL_API_START_CHANGE_SVC_CONFIGSTATE    = "Start changing service configuration state to %v for %v for the node."
 	EL_API_COMPLETE_CHANGE_SVC_CONFIGSTATE = "Complete changing service configuration state to %v for %v for the node."
+	EL_API_SVC_PAUSED                      = "Service %v paused for the node."
+	EL_API_SVC_RESUMED                     = "Service %v resumed for the node."
 
 	// from path_management_status.go
 	EL_API_NMP_STATUS_CHANGE = "Node management status for %v/%v changed to %v."
@@ -120,6 +133,7 @@ func MarkI18nMessages() {
 	msgPrinter.Sprintf(EL_API_ERR_NODE_UNREG_WRONG_VALUE_FOR_RN)
 	msgPrinter.Sprintf(EL_API_ERR_NODE_UNREG_WRONG_VALUE_FOR_DC)
 	msgPrinter.Sprintf(EL_API_ERR_NODE_UNREG_WRONG_VALUE_FOR_BLOCK)
+	msgPrinter.Sprintf(EL_API_ERR_NODE_UNREG_WRONG_EXPORT_PATH)
 
 	msgPrinter.Sprintf(EL_API_ERR_READ_NODE_FROM_DB)
 	msgPrinter.Sprintf(EL_API_ERR_SAVE_NODE_CONF_TO_DB)
@@ -143,8 +157,18 @@ func MarkI18nMessages() {
 	// from path_node_userinput.go
 	msgPrinter.Sprintf(EL_API_NEW_NODE_UI)
 	msgPrinter.Sprintf(EL_API_NO_NODE_UI_TO_DEL)
+	msgPrinter.Sprintf(EL_API_NODE_UI_UNCHANGED)
 	msgPrinter.Sprintf(EL_API_DELETED_ALL_NODE_UI)
 
+	// from path_config.go
+	msgPrinter.Sprintf(EL_API_CONFIG_RELOADED)
+	msgPrinter.Sprintf(EL_API_ERR_CONFIG_RELOADED)
+
+	// from path_node_maintenance.go
+	msgPrinter.Sprintf(EL_API_NODE_MAINTENANCE_ON)
+	msgPrinter.Sprintf(EL_API_NODE_MAINTENANCE_ON_DRAIN)
+	msgPrinter.Sprintf(EL_API_NODE_MAINTENANCE_OFF)
+
 	// from path_service_config.go
 	msgPrinter.Sprintf(EL_API_START_SVC_CONFIG)
 	msgPrinter.Sprintf(EL_API_START_SVC_AUTO_CONFIG)
@@ -157,6 +181,8 @@ func MarkI18nMessages() {
 	msgPrinter.Sprintf(EL_API_ERR_CHANGE_SVC_CONFIGSTATE)
 	msgPrinter.Sprintf(EL_API_START_CHANGE_SVC_CONFIGSTATE)
 	msgPrinter.Sprintf(EL_API_COMPLETE_CHANGE_SVC_CONFIGSTATE)
+	msgPrinter.Sprintf(EL_API_SVC_PAUSED)
+	msgPrinter.Sprintf(EL_API_SVC_RESUMED)
 
 	// from path_management_status.go
 	msgPrinter.Sprintf(EL_API_NMP_STATUS_CHANGE)
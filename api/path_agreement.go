@@ -48,6 +48,24 @@ func FindAgreementsForOutput(db *bolt.DB) (map[string]map[string][]persistence.E
 	return wrap, nil
 }
 
+// Page, filter and select the fields of the active and archived agreements. The list options are applied to each list
+// separately, the time range is matched against the agreement creation time.
+func SelectAgreementsForOutput(agreements map[string]map[string][]persistence.EstablishedAgreement, opts *ListOptions, selections map[string][]string, errorhandler ErrorHandler) (bool, map[string]map[string][]interface{}) {
+
+	selectors, err := persistence.ConvertToSelectors(selections)
+	if err != nil {
+		return errorhandler(NewAPIUserInputError(fmt.Sprintf("Error converting the selections into Selectors: %v", err), "selection")), nil
+	}
+
+	out := make(map[string]map[string][]interface{})
+	for key, lists := range agreements {
+		if out[key], err = opts.ApplyToEach(lists, "agreement_creation_time", selectors); err != nil {
+			return errorhandler(NewSystemError(fmt.Sprintf("Error selecting the agreements for output, error %v", err))), nil
+		}
+	}
+	return false, out
+}
+
 func DeleteAgreement(errorhandler ErrorHandler, agreementId string, db *bolt.DB) (bool, *events.ApiAgreementCancelationMessage) {
 
 	glog.V(3).Infof(apiLogString(fmt.Sprintf("Handling DELETE of agreement: %v", agreementId)))
@@ -71,3 +89,49 @@ func DeleteAgreement(errorhandler ErrorHandler, agreementId string, db *bolt.DB)
 
 	return false, msg
 }
+
+// Return the negotiation history of the agreement. The agreements made before the history was recorded get a history
+// built from the times in the agreement record.
+func FindAgreementHistoryForOutput(errorhandler ErrorHandler, agreementId string, db *bolt.DB) (bool, *persistence.AgreementHistory) {
+
+	history, err := persistence.FindAgreementHistory(db, agreementId)
+	if err != nil {
+		return errorhandler(NewSystemError(fmt.Sprintf("unable to read the history of agreement %v, error %v", agreementId, err))), nil
+	} else if history != nil {
+		return false, history
+	}
+
+	agreements, err := persistence.FindEstablishedAgreementsAllProtocols(db, policy.AllAgreementProtocols(), []persistence.EAFilter{persistence.IdEAFilter(agreementId)})
+	if err != nil {
+		return errorhandler(NewSystemError(fmt.Sprintf("unable to read agreement objects, error %v", err))), nil
+	} else if len(agreements) == 0 {
+		return errorhandler(NewNotFoundError(fmt.Sprintf("agreement %v not found", agreementId), "id")), nil
+	}
+
+	ag := agreements[0]
+	history = &persistence.AgreementHistory{AgreementId: agreementId, Protocol: ag.AgreementProtocol, Entries: []persistence.AgreementHistoryEntry{}}
+	steps := []struct {
+		time  uint64
+		event string
+	}{
+		{ag.AgreementCreationTime, persistence.AG_HISTORY_AGREEMENT_CREATED},
+		{ag.AgreementAcceptedTime, persistence.AG_HISTORY_AGREEMENT_REACHED},
+		{ag.AgreementFinalizedTime, persistence.AG_HISTORY_FINALIZED},
+		{ag.AgreementExecutionStartTime, persistence.AG_HISTORY_EXECUTION_STARTED},
+		{ag.AgreementTerminatedTime, persistence.AG_HISTORY_TERMINATED},
+	}
+	for _, step := range steps {
+		if step.time == 0 {
+			continue
+		}
+		entry := persistence.AgreementHistoryEntry{Time: step.time, Event: step.event}
+		if step.event == persistence.AG_HISTORY_AGREEMENT_CREATED {
+			entry.Actor = ag.ConsumerId
+		} else if step.event == persistence.AG_HISTORY_TERMINATED {
+			entry.ReasonCode = ag.TerminatedReason
+			entry.Reason = ag.TerminatedDescription
+		}
+		history.Entries = append(history.Entries, entry)
+	}
+	return false, history
+}
@@ -224,3 +224,48 @@ func Test_DeleteAgreement3(t *testing.T) {
 	}
 
 }
+
+// Verify that the recorded history of an agreement is returned, and that a history is built from the agreement record
+// when none was recorded.
+func Test_FindAgreementHistoryForOutput(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	var myError error
+	errorhandler := GetPassThroughErrorHandler(&myError)
+
+	if errHandled, _ := FindAgreementHistoryForOutput(errorhandler, "agreementId1", db); !errHandled {
+		t.Errorf("expected an error for an unknown agreement")
+	} else if _, ok := myError.(*NotFoundError); !ok {
+		t.Errorf("expected a NotFoundError, got %v", myError)
+	}
+
+	rejected := persistence.NewAgreementHistoryEntry(persistence.AG_HISTORY_PROPOSAL_REJECTED, "myorg/node1", "Node type matching failed, ignoring proposal")
+	if err := persistence.AddAgreementHistoryEntry(db, "agreementId1", "Basic", rejected); err != nil {
+		t.Errorf("error adding history: %v", err)
+	} else if errHandled, out := FindAgreementHistoryForOutput(errorhandler, "agreementId1", db); errHandled {
+		t.Errorf("unexpected error %v", myError)
+	} else if len(out.Entries) != 1 || out.Entries[0].Event != persistence.AG_HISTORY_PROPOSAL_REJECTED || out.Entries[0].Reason != rejected.Reason {
+		t.Errorf("wrong history %v", out)
+	}
+
+	// an agreement made before the history was recorded
+	wi, _ := persistence.NewWorkloadInfo("url", "org", "version", "")
+	if _, err := persistence.NewEstablishedAgreement(db, "name1", "agreementId2", "consumerId", "{}", "Basic", 1, []persistence.ServiceSpec{}, "", "", "", "", "", wi, 180); err != nil {
+		t.Errorf("error writing agreement2: %v", err)
+	} else if _, err := persistence.AgreementStateTerminated(db, "agreementId2", 203, "cancelled", "Basic"); err != nil {
+		t.Errorf("error terminating agreement2: %v", err)
+	} else if err := persistence.DeleteAgreementHistories(db); err != nil {
+		t.Errorf("error deleting histories: %v", err)
+	} else if errHandled, out := FindAgreementHistoryForOutput(errorhandler, "agreementId2", db); errHandled {
+		t.Errorf("unexpected error %v", myError)
+	} else if len(out.Entries) != 2 || out.Entries[0].Event != persistence.AG_HISTORY_AGREEMENT_CREATED || out.Entries[0].Actor != "consumerId" {
+		t.Errorf("wrong history %v", out)
+	} else if out.Entries[1].Event != persistence.AG_HISTORY_TERMINATED || out.Entries[1].ReasonCode != 203 || out.Entries[1].Reason != "cancelled" {
+		t.Errorf("wrong termination %v", out.Entries[1])
+	}
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/open-horizon/anax/config"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The OpenAPI 3 description of the agent API served at /api-docs. It is generated from the routes of the API, so it
+// lists every path, method and path parameter. The request and response bodies are described in docs/api.md.
+type OpenAPIDocument struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       OpenAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                      `json:"components"`
+	Security   []map[string][]string                  `json:"security,omitempty"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type OpenAPIOperation struct {
+	Summary     string                     `json:"summary"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Name     string                 `json:"name"`
+	In       string                 `json:"in"`
+	Required bool                   `json:"required"`
+	Schema   map[string]interface{} `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema map[string]interface{} `json:"schema"`
+}
+
+type OpenAPIComponents struct {
+	Schemas         map[string]interface{} `json:"schemas"`
+	SecuritySchemes map[string]interface{} `json:"securitySchemes,omitempty"`
+}
+
+const OPENAPI_VERSION = "3.0.3"
+
+// A path variable with a list of alternatives, e.g. {p:(?:publickey|trust)}, is served as one path per alternative.
+var alternativesPathVar = regexp.MustCompile(`\{(\w+):\(\?:([\w|]+)\)\}`)
+
+// Any other path variable with a pattern, e.g. {p:[\w\/]+}.
+var patternPathVar = regexp.MustCompile(`\{(\w+):[^}]*\}`)
+
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// Generate the OpenAPI document of the routes of the given router. The version is the version of the agent and the
+// API authentication mode adds the bearer token security scheme when the API requires a token.
+func GenerateOpenAPIDocument(router *mux.Router, version string, apiAuth string) (*OpenAPIDocument, error) {
+	doc := &OpenAPIDocument{
+		OpenAPI: OPENAPI_VERSION,
+		Info: OpenAPIInfo{
+			Title:       "Horizon Agent API",
+			Description: "The local API of the Horizon agent. The request and response bodies are described in https://github.com/open-horizon/anax/blob/master/docs/api.md.",
+			Version:     version,
+		},
+		Paths: make(map[string]map[string]OpenAPIOperation),
+		Components: OpenAPIComponents{
+			Schemas: map[string]interface{}{
+				"Error": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"code":        map[string]string{"type": "string"},
+						"message":     map[string]string{"type": "string"},
+						"detail":      map[string]string{"type": "string"},
+						"remediation": map[string]string{"type": "string"},
+						"error":       map[string]string{"type": "string"},
+						"input":       map[string]string{"type": "string"},
+					},
+				},
+			},
+		},
+	}
+
+	if apiAuth == config.APIAuth_TOKEN {
+		doc.Components.SecuritySchemes = map[string]interface{}{
+			"bearerAuth": map[string]string{"type": "http", "scheme": "bearer"},
+		}
+		doc.Security = []map[string][]string{{"bearerAuth": []string{}}}
+	}
+
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// the routes without methods are the redirects to the static web content
+			return nil
+		}
+
+		for _, path := range expandPathTemplate(template) {
+			if _, ok := doc.Paths[path]; !ok {
+				doc.Paths[path] = make(map[string]OpenAPIOperation)
+			}
+			for _, method := range methods {
+				if method == http.MethodOptions {
+					continue
+				}
+				doc.Paths[path][strings.ToLower(method)] = newOpenAPIOperation(method, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Turn a route template into the OpenAPI paths it serves.
+func expandPathTemplate(template string) []string {
+	paths := []string{template}
+	for {
+		match := alternativesPathVar.FindStringSubmatchIndex(paths[0])
+		if match == nil {
+			break
+		}
+		expanded := []string{}
+		for _, path := range paths {
+			m := alternativesPathVar.FindStringSubmatchIndex(path)
+			for _, alt := range strings.Split(path[m[4]:m[5]], "|") {
+				expanded = append(expanded, path[:m[0]]+alt+path[m[1]:])
+			}
+		}
+		paths = expanded
+	}
+
+	for i, path := range paths {
+		paths[i] = patternPathVar.ReplaceAllString(path, "{$1}")
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func newOpenAPIOperation(method string, path string) OpenAPIOperation {
+	op := OpenAPIOperation{
+		Summary: fmt.Sprintf("%v %v", method, path),
+		Responses: map[string]OpenAPIResponse{
+			"200": {Description: "success"},
+			"default": {
+				Description: "error",
+				Content: map[string]OpenAPIMediaType{
+					"application/json": {Schema: map[string]interface{}{"$ref": "#/components/schemas/Error"}},
+				},
+			},
+		},
+	}
+
+	if segments := strings.Split(strings.Trim(path, "/"), "/"); segments[0] != "" {
+		op.Tags = []string{segments[0]}
+	}
+
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		op.Parameters = append(op.Parameters, OpenAPIParameter{Name: m[1], In: "path", Required: true, Schema: map[string]interface{}{"type": "string"}})
+	}
+
+	if method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch {
+		op.RequestBody = &OpenAPIRequestBody{
+			Content: map[string]OpenAPIMediaType{
+				"application/json": {Schema: map[string]interface{}{"type": "object"}},
+			},
+		}
+	}
+	return op
+}
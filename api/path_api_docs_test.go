@@ -0,0 +1,67 @@
+//go:build unit
+// +build unit
+
+package api
+
+import (
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/worker"
+	"reflect"
+	"testing"
+)
+
+// Verify that the OpenAPI document lists the paths, methods and path parameters of the API routes.
+func Test_GenerateOpenAPIDocument(t *testing.T) {
+
+	a := &API{Manager: worker.Manager{Config: getBasicConfig()}}
+
+	doc, err := GenerateOpenAPIDocument(a.router(false), "2.31.0", "")
+	if err != nil {
+		t.Fatalf("should not return error, but got %v", err)
+	} else if doc.OpenAPI != OPENAPI_VERSION || doc.Info.Version != "2.31.0" || doc.Security != nil {
+		t.Errorf("wrong document %v %v %v", doc.OpenAPI, doc.Info, doc.Security)
+	}
+
+	if ops, ok := doc.Paths["/node"]; !ok {
+		t.Errorf("/node is missing")
+	} else if _, ok := ops["options"]; ok {
+		t.Errorf("OPTIONS should not be listed")
+	} else if op, ok := ops["post"]; !ok || op.RequestBody == nil || op.Tags[0] != "node" {
+		t.Errorf("wrong POST /node %v", op)
+	} else if op, ok := ops["get"]; !ok || op.RequestBody != nil {
+		t.Errorf("wrong GET /node %v", op)
+	}
+
+	if op, ok := doc.Paths["/agreement/{id}/history"]["get"]; !ok {
+		t.Errorf("GET /agreement/{id}/history is missing")
+	} else if len(op.Parameters) != 1 || op.Parameters[0].Name != "id" || op.Parameters[0].In != "path" || !op.Parameters[0].Required {
+		t.Errorf("wrong parameters %v", op.Parameters)
+	}
+
+	for _, path := range []string{"/trust", "/publickey/{filename}", "/api-docs"} {
+		if _, ok := doc.Paths[path]; !ok {
+			t.Errorf("%v is missing", path)
+		}
+	}
+
+	doc, err = GenerateOpenAPIDocument(a.router(false), "2.31.0", config.APIAuth_TOKEN)
+	if err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if !reflect.DeepEqual(doc.Security, []map[string][]string{{"bearerAuth": []string{}}}) {
+		t.Errorf("wrong security %v", doc.Security)
+	}
+}
+
+func Test_expandPathTemplate(t *testing.T) {
+
+	tests := map[string][]string{
+		"/node":                               {"/node"},
+		"/{p:(?:publickey|trust)}/{filename}": {"/publickey/{filename}", "/trust/{filename}"},
+		`/{p:[\w\/]+}`:                        {"/{p}"},
+	}
+	for template, expected := range tests {
+		if paths := expandPathTemplate(template); !reflect.DeepEqual(paths, expected) {
+			t.Errorf("%v should be %v, but got %v", template, expected, paths)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+)
+
+// Read the config file again and apply the changes that do not require a restart of the agent to the running config.
+// When the config file cannot be read or is not valid, the running config is not changed.
+func ReloadConfig(configFile string, cfg *config.HorizonConfig, errorhandler ErrorHandler) (bool, *config.ReloadResult) {
+
+	newCfg, err := config.Read(configFile)
+	if err != nil {
+		return errorhandler(NewBadRequestError(fmt.Sprintf("Unable to reload the config file %v, the running config is unchanged. %v", configFile, err))), nil
+	}
+
+	result := cfg.Reload(newCfg)
+	glog.V(3).Infof(apiLogString(fmt.Sprintf("Reloaded config file %v. %v", configFile, result)))
+
+	if result.IsApplied("Edge.LogVerbosity") {
+		if err := cfg.Edge.SetLogVerbosity(); err != nil {
+			return errorhandler(NewSystemError(fmt.Sprintf("Unable to set the log verbosity, error %v", err))), nil
+		}
+	}
+
+	return false, result
+}
@@ -0,0 +1,84 @@
+//go:build unit
+// +build unit
+
+package api
+
+import (
+	"github.com/open-horizon/anax/config"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+// The heartbeat change is applied, the exchange URL change requires a restart.
+func Test_ReloadConfig(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "utconfig-")
+	if err != nil {
+		t.Error(err)
+	}
+	defer os.RemoveAll(dir)
+
+	configFile := path.Join(dir, "anax.json")
+	if err := ioutil.WriteFile(configFile, []byte(`{"Edge": {"ExchangeURL": "https://exchange/v1", "ExchangeHeartbeat": 60}}`), 0600); err != nil {
+		t.Error(err)
+	}
+
+	cfg, err := config.Read(configFile)
+	if err != nil {
+		t.Errorf("unable to read config file, error %v", err)
+	}
+
+	if err := ioutil.WriteFile(configFile, []byte(`{"Edge": {"ExchangeURL": "https://other-exchange/v1", "ExchangeHeartbeat": 30}}`), 0600); err != nil {
+		t.Error(err)
+	}
+
+	var myError error
+	errorhandler := GetPassThroughErrorHandler(&myError)
+	if errHandled, result := ReloadConfig(configFile, cfg, errorhandler); errHandled {
+		t.Errorf("unexpected error %v", myError)
+	} else if !result.IsApplied("Edge.ExchangeHeartbeat") || len(result.Applied) != 1 {
+		t.Errorf("wrong applied fields %v", result.Applied)
+	} else if len(result.RestartRequired) != 1 || result.RestartRequired[0] != "Edge.ExchangeURL" {
+		t.Errorf("wrong restart required fields %v", result.RestartRequired)
+	} else if cfg.Edge.ExchangeHeartbeat != 30 || cfg.Edge.ExchangeURL != "https://exchange/v1/" {
+		t.Errorf("wrong running config %v %v", cfg.Edge.ExchangeHeartbeat, cfg.Edge.ExchangeURL)
+	}
+}
+
+// A config file that is not valid does not change the running config.
+func Test_ReloadConfig_invalid(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "utconfig-")
+	if err != nil {
+		t.Error(err)
+	}
+	defer os.RemoveAll(dir)
+
+	configFile := path.Join(dir, "anax.json")
+	if err := ioutil.WriteFile(configFile, []byte(`{"Edge": {"ExchangeHeartbeat": 60}}`), 0600); err != nil {
+		t.Error(err)
+	}
+
+	cfg, err := config.Read(configFile)
+	if err != nil {
+		t.Errorf("unable to read config file, error %v", err)
+	}
+
+	if err := ioutil.WriteFile(configFile, []byte(`{"Edge": {"ExchangeHeartbeat": 30,}}`), 0600); err != nil {
+		t.Error(err)
+	}
+
+	var myError error
+	errorhandler := GetPassThroughErrorHandler(&myError)
+	if errHandled, result := ReloadConfig(configFile, cfg, errorhandler); !errHandled {
+		t.Errorf("expected to receive error")
+	} else if _, ok := myError.(*BadRequestError); !ok {
+		t.Errorf("expected error of type BadRequestError, but is %T, %v", myError, myError)
+	} else if result != nil {
+		t.Errorf("should not have returned output, %v", result)
+	} else if cfg.Edge.ExchangeHeartbeat != 60 {
+		t.Errorf("the running config should not be changed, heartbeat is %v", cfg.Edge.ExchangeHeartbeat)
+	}
+}
@@ -0,0 +1,285 @@
+package api
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	dockerclient "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The results of a diagnostic check.
+const (
+	DIAG_PASSED  = "passed"
+	DIAG_WARNING = "warning"
+	DIAG_FAILED  = "failed"
+	DIAG_SKIPPED = "skipped"
+)
+
+// The kinds of diagnostic checks.
+const (
+	DIAG_CONNECTIVITY = "connectivity"
+	DIAG_CLOCK        = "clock"
+	DIAG_CERTIFICATE  = "certificate"
+)
+
+// The clock of the node is compared with the clock of the exchange. Tokens and certificates are rejected when the
+// clocks are too far apart.
+const (
+	DIAG_CLOCK_SKEW_WARNING_S = 30
+	DIAG_CLOCK_SKEW_MAX_S     = 300
+)
+
+// A certificate that expires within this time is reported as a warning.
+const DIAG_CERT_EXPIRY_WARNING = 30 * 24 * time.Hour
+
+// The registry that is checked when the node does not run any container yet.
+const DIAG_DEFAULT_REGISTRY = "registry-1.docker.io"
+
+type DiagnosticCheck struct {
+	Name        string `json:"name"`
+	Category    string `json:"category"`
+	Status      string `json:"status"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+func (c DiagnosticCheck) String() string {
+	return fmt.Sprintf("Name: %v, Category: %v, Status: %v, Message: %v, Remediation: %v", c.Name, c.Category, c.Status, c.Message, c.Remediation)
+}
+
+// The output of GET /diagnostics. The node is healthy when none of the checks failed.
+type Diagnostics struct {
+	Time    uint64            `json:"time"`
+	Healthy bool              `json:"healthy"`
+	Checks  []DiagnosticCheck `json:"checks"`
+}
+
+func (d *Diagnostics) add(check DiagnosticCheck) {
+	d.Checks = append(d.Checks, check)
+	if check.Status == DIAG_FAILED {
+		d.Healthy = false
+	}
+}
+
+// A handler that lists the containers of the container runtime of a device.
+type ContainerListHandler func() ([]dockerclient.APIContainers, error)
+
+// A handler that returns the version of the kubernetes API server of a cluster.
+type KubeVersionHandler func() (string, error)
+
+// Run the connectivity, clock skew and certificate checks of the node. The checks do not stop at the first failure,
+// so that the report shows all the problems at once.
+func RunDiagnostics(cfg *config.HorizonConfig,
+	nodeType string,
+	exchangeURL string,
+	cssURL string,
+	httpClient *http.Client,
+	listContainers ContainerListHandler,
+	kubeVersion KubeVersionHandler) *Diagnostics {
+
+	now := time.Now()
+	out := &Diagnostics{Time: uint64(now.Unix()), Healthy: true, Checks: []DiagnosticCheck{}}
+
+	// the exchange response is also used to check the clock and the certificate of the exchange
+	var exchResp *http.Response
+	if exchangeURL == "" {
+		out.add(DiagnosticCheck{Name: "exchange", Category: DIAG_CONNECTIVITY, Status: DIAG_SKIPPED, Message: "The exchange URL is not configured."})
+	} else {
+		var check DiagnosticCheck
+		exchResp, check = checkEndpoint(httpClient, "exchange", strings.TrimRight(exchangeURL, "/")+"/admin/version",
+			"Check the network connection of the node and that HZN_EXCHANGE_URL in /etc/default/horizon is correct.")
+		out.add(check)
+	}
+
+	if exchResp == nil {
+		out.add(DiagnosticCheck{Name: "exchange", Category: DIAG_CLOCK, Status: DIAG_SKIPPED, Message: "The exchange could not be reached."})
+		out.add(DiagnosticCheck{Name: "exchange", Category: DIAG_CERTIFICATE, Status: DIAG_SKIPPED, Message: "The exchange could not be reached."})
+	} else {
+		out.add(checkClockSkew("exchange", exchResp.Header.Get("Date"), now))
+		if exchResp.TLS == nil || len(exchResp.TLS.PeerCertificates) == 0 {
+			out.add(DiagnosticCheck{Name: "exchange", Category: DIAG_CERTIFICATE, Status: DIAG_SKIPPED, Message: "The exchange is not using TLS."})
+		} else {
+			out.add(checkCertificates("exchange", exchResp.TLS.PeerCertificates[:1], now))
+		}
+	}
+
+	if cssURL == "" {
+		out.add(DiagnosticCheck{Name: "css", Category: DIAG_CONNECTIVITY, Status: DIAG_SKIPPED, Message: "The CSS URL is not configured."})
+	} else {
+		_, check := checkEndpoint(httpClient, "css", cssURL+"/",
+			"Check the network connection of the node and that HZN_FSS_CSSURL in /etc/default/horizon is correct.")
+		out.add(check)
+	}
+
+	// the container runtime, and the registries of the images the node runs
+	registries := []string{}
+	if nodeType == persistence.DEVICE_TYPE_CLUSTER {
+		if version, err := kubeVersion(); err != nil {
+			out.add(DiagnosticCheck{Name: "kubernetes", Category: DIAG_CONNECTIVITY, Status: DIAG_FAILED,
+				Message:     fmt.Sprintf("Unable to reach the kubernetes API server, error %v", err),
+				Remediation: "Check that the agent service account is allowed to reach the kubernetes API server."})
+		} else {
+			out.add(DiagnosticCheck{Name: "kubernetes", Category: DIAG_CONNECTIVITY, Status: DIAG_PASSED, Message: fmt.Sprintf("The kubernetes API server version is %v.", version)})
+		}
+	} else if containers, err := listContainers(); err != nil {
+		out.add(DiagnosticCheck{Name: "container runtime", Category: DIAG_CONNECTIVITY, Status: DIAG_FAILED,
+			Message:     fmt.Sprintf("Unable to list the containers, error %v", err),
+			Remediation: "Check that the container runtime is running and that DockerEndpoint or ContainerdAddress in the agent configuration is correct."})
+	} else {
+		out.add(DiagnosticCheck{Name: "container runtime", Category: DIAG_CONNECTIVITY, Status: DIAG_PASSED, Message: fmt.Sprintf("The container runtime is running %v containers.", len(containers))})
+		registries = imageRegistries(containers)
+	}
+
+	if len(registries) == 0 {
+		registries = []string{DIAG_DEFAULT_REGISTRY}
+	}
+	for _, registry := range registries {
+		_, check := checkEndpoint(httpClient, fmt.Sprintf("registry %v", registry), fmt.Sprintf("https://%v/v2/", registry),
+			"Check the network connection of the node and that the proxy settings of the container runtime allow the registry.")
+		out.add(check)
+	}
+
+	// the certificates the agent is configured to trust
+	certFiles := map[string]string{"ca certs": cfg.Edge.CACertsPath, "css cert": cfg.GetCSSSSLCert()}
+	names := make([]string, 0, len(certFiles))
+	for name := range certFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		out.add(checkCertificateFile(name, certFiles[name], now))
+	}
+
+	return out
+}
+
+// Check that an HTTP endpoint can be reached. Any HTTP response means that the endpoint is reachable, e.g. a registry
+// answers 401 to a client that is not logged in.
+func checkEndpoint(httpClient *http.Client, name string, url string, remediation string) (*http.Response, DiagnosticCheck) {
+	start := time.Now()
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, DiagnosticCheck{Name: name, Category: DIAG_CONNECTIVITY, Status: DIAG_FAILED,
+			Message: fmt.Sprintf("Unable to reach %v, error %v", url, err), Remediation: remediation}
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+
+	return resp, DiagnosticCheck{Name: name, Category: DIAG_CONNECTIVITY, Status: DIAG_PASSED,
+		Message: fmt.Sprintf("Reached %v in %v, HTTP status %v.", url, time.Since(start).Round(time.Millisecond), resp.StatusCode)}
+}
+
+// Compare the clock of the node with the Date header of a server response.
+func checkClockSkew(name string, date string, now time.Time) DiagnosticCheck {
+	serverTime, err := http.ParseTime(date)
+	if err != nil {
+		return DiagnosticCheck{Name: name, Category: DIAG_CLOCK, Status: DIAG_SKIPPED, Message: fmt.Sprintf("The response of the %v has no valid Date header.", name)}
+	}
+
+	skew := now.Sub(serverTime).Round(time.Second)
+	if skew < 0 {
+		skew = -skew
+	}
+	remediation := "Synchronize the clock of the node, e.g. with NTP."
+	if skew > DIAG_CLOCK_SKEW_MAX_S*time.Second {
+		return DiagnosticCheck{Name: name, Category: DIAG_CLOCK, Status: DIAG_FAILED,
+			Message: fmt.Sprintf("The clock of the node is %v apart from the clock of the %v.", skew, name), Remediation: remediation}
+	} else if skew > DIAG_CLOCK_SKEW_WARNING_S*time.Second {
+		return DiagnosticCheck{Name: name, Category: DIAG_CLOCK, Status: DIAG_WARNING,
+			Message: fmt.Sprintf("The clock of the node is %v apart from the clock of the %v.", skew, name), Remediation: remediation}
+	}
+	return DiagnosticCheck{Name: name, Category: DIAG_CLOCK, Status: DIAG_PASSED, Message: fmt.Sprintf("The clock of the node is within %v of the clock of the %v.", skew, name)}
+}
+
+// Check the certificates in a PEM file.
+func checkCertificateFile(name string, file string, now time.Time) DiagnosticCheck {
+	if file == "" {
+		return DiagnosticCheck{Name: name, Category: DIAG_CERTIFICATE, Status: DIAG_SKIPPED, Message: fmt.Sprintf("The %v file is not configured.", name)}
+	}
+
+	remediation := fmt.Sprintf("Replace %v with the certificate of the management hub.", file)
+	pemBytes, err := ioutil.ReadFile(file)
+	if err != nil {
+		return DiagnosticCheck{Name: name, Category: DIAG_CERTIFICATE, Status: DIAG_FAILED, Message: fmt.Sprintf("Unable to read %v, error %v", file, err), Remediation: remediation}
+	}
+
+	certs := []*x509.Certificate{}
+	for block, rest := pem.Decode(pemBytes); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		} else if cert, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return DiagnosticCheck{Name: name, Category: DIAG_CERTIFICATE, Status: DIAG_FAILED, Message: fmt.Sprintf("Unable to parse a certificate in %v, error %v", file, err), Remediation: remediation}
+		} else {
+			certs = append(certs, cert)
+		}
+	}
+	if len(certs) == 0 {
+		return DiagnosticCheck{Name: name, Category: DIAG_CERTIFICATE, Status: DIAG_FAILED, Message: fmt.Sprintf("There is no certificate in %v.", file), Remediation: remediation}
+	}
+
+	check := checkCertificates(name, certs, now)
+	if check.Status != DIAG_PASSED {
+		check.Remediation = remediation
+	}
+	return check
+}
+
+// Check that the certificates are valid now and are not about to expire.
+func checkCertificates(name string, certs []*x509.Certificate, now time.Time) DiagnosticCheck {
+	check := DiagnosticCheck{Name: name, Category: DIAG_CERTIFICATE, Status: DIAG_PASSED}
+
+	var firstExpiry time.Time
+	for _, cert := range certs {
+		subject := cert.Subject.CommonName
+		if now.After(cert.NotAfter) {
+			check.Status = DIAG_FAILED
+			check.Message = fmt.Sprintf("The certificate %v of the %v expired on %v.", subject, name, cert.NotAfter.UTC().Format(time.RFC3339))
+			return check
+		} else if now.Before(cert.NotBefore) {
+			check.Status = DIAG_FAILED
+			check.Message = fmt.Sprintf("The certificate %v of the %v is not valid until %v, check the clock of the node.", subject, name, cert.NotBefore.UTC().Format(time.RFC3339))
+			return check
+		} else if cert.NotAfter.Sub(now) < DIAG_CERT_EXPIRY_WARNING {
+			check.Status = DIAG_WARNING
+			check.Message = fmt.Sprintf("The certificate %v of the %v expires on %v.", subject, name, cert.NotAfter.UTC().Format(time.RFC3339))
+		}
+		if firstExpiry.IsZero() || cert.NotAfter.Before(firstExpiry) {
+			firstExpiry = cert.NotAfter
+		}
+	}
+
+	if check.Status == DIAG_PASSED {
+		check.Message = fmt.Sprintf("The certificates of the %v are valid until %v.", name, firstExpiry.UTC().Format(time.RFC3339))
+	}
+	return check
+}
+
+// Return the registry hosts of the images of the containers, sorted. An image without a registry host is from Docker Hub.
+func imageRegistries(containers []dockerclient.APIContainers) []string {
+	hosts := make(map[string]bool)
+	for _, c := range containers {
+		hosts[imageRegistry(c.Image)] = true
+	}
+
+	registries := make([]string, 0, len(hosts))
+	for host := range hosts {
+		registries = append(registries, host)
+	}
+	sort.Strings(registries)
+	return registries
+}
+
+func imageRegistry(image string) string {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[0]
+	}
+	return DIAG_DEFAULT_REGISTRY
+}
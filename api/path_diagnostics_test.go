@@ -0,0 +1,141 @@
+//go:build unit
+// +build unit
+
+package api
+
+import (
+	"crypto/x509"
+	"errors"
+	dockerclient "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/persistence"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// All the checks pass when the exchange, the CSS and the registry can be reached.
+func Test_RunDiagnostics(t *testing.T) {
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	host := strings.TrimPrefix(srv.URL, "https://")
+	listContainers := func() ([]dockerclient.APIContainers, error) {
+		return []dockerclient.APIContainers{{Image: host + "/myorg/app:1.0"}}, nil
+	}
+	kubeVersion := func() (string, error) {
+		return "", errors.New("not a cluster")
+	}
+
+	out := RunDiagnostics(getBasicConfig(), persistence.DEVICE_TYPE_DEVICE, srv.URL+"/v1/", srv.URL, srv.Client(), listContainers, kubeVersion)
+	if !out.Healthy {
+		t.Errorf("the node should be healthy, %v", out.Checks)
+	}
+
+	statuses := make(map[string]string)
+	for _, c := range out.Checks {
+		statuses[c.Category+"/"+c.Name] = c.Status
+	}
+	expected := map[string]string{
+		"connectivity/exchange":          DIAG_PASSED,
+		"clock/exchange":                 DIAG_PASSED,
+		"certificate/exchange":           DIAG_PASSED,
+		"connectivity/css":               DIAG_PASSED,
+		"connectivity/container runtime": DIAG_PASSED,
+		"connectivity/registry " + host:  DIAG_PASSED,
+		"certificate/ca certs":           DIAG_SKIPPED,
+		"certificate/css cert":           DIAG_SKIPPED,
+	}
+	for name, status := range expected {
+		if statuses[name] != status {
+			t.Errorf("check %v should be %v, but is %v", name, status, statuses[name])
+		}
+	}
+}
+
+// The node is not healthy when the exchange cannot be reached, the dependent checks are skipped.
+func Test_RunDiagnostics_unreachable(t *testing.T) {
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	client := srv.Client()
+	client.Timeout = 2 * time.Second
+	srv.Close()
+
+	listContainers := func() ([]dockerclient.APIContainers, error) {
+		return nil, errors.New("not a device")
+	}
+	kubeVersion := func() (string, error) {
+		return "v1.27.1", nil
+	}
+
+	out := RunDiagnostics(getBasicConfig(), persistence.DEVICE_TYPE_CLUSTER, url+"/v1/", "", client, listContainers, kubeVersion)
+	if out.Healthy {
+		t.Errorf("the node should not be healthy, %v", out.Checks)
+	}
+	for _, c := range out.Checks {
+		if c.Name == "exchange" && c.Category == DIAG_CONNECTIVITY && (c.Status != DIAG_FAILED || c.Remediation == "") {
+			t.Errorf("wrong exchange check %v", c)
+		} else if c.Name == "exchange" && c.Category != DIAG_CONNECTIVITY && c.Status != DIAG_SKIPPED {
+			t.Errorf("the %v check of the exchange should be skipped, %v", c.Category, c)
+		} else if c.Name == "css" && c.Status != DIAG_SKIPPED {
+			t.Errorf("the css check should be skipped, %v", c)
+		} else if c.Name == "kubernetes" && c.Status != DIAG_PASSED {
+			t.Errorf("wrong kubernetes check %v", c)
+		} else if c.Name == "container runtime" {
+			t.Errorf("the container runtime should not be checked on a cluster, %v", c)
+		}
+	}
+}
+
+func Test_checkClockSkew(t *testing.T) {
+
+	now := time.Now()
+	if c := checkClockSkew("exchange", now.Add(-5*time.Second).UTC().Format(http.TimeFormat), now); c.Status != DIAG_PASSED {
+		t.Errorf("wrong check %v", c)
+	} else if c := checkClockSkew("exchange", now.Add(time.Minute).UTC().Format(http.TimeFormat), now); c.Status != DIAG_WARNING {
+		t.Errorf("wrong check %v", c)
+	} else if c := checkClockSkew("exchange", now.Add(-time.Hour).UTC().Format(http.TimeFormat), now); c.Status != DIAG_FAILED || c.Remediation == "" {
+		t.Errorf("wrong check %v", c)
+	} else if c := checkClockSkew("exchange", "", now); c.Status != DIAG_SKIPPED {
+		t.Errorf("wrong check %v", c)
+	}
+}
+
+func Test_checkCertificates(t *testing.T) {
+
+	cert := &x509.Certificate{NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(365 * 24 * time.Hour)}
+	if c := checkCertificates("exchange", []*x509.Certificate{cert}, time.Now()); c.Status != DIAG_PASSED {
+		t.Errorf("wrong check %v", c)
+	} else if c := checkCertificates("exchange", []*x509.Certificate{cert}, cert.NotAfter.Add(-24*time.Hour)); c.Status != DIAG_WARNING {
+		t.Errorf("wrong check %v", c)
+	} else if c := checkCertificates("exchange", []*x509.Certificate{cert}, cert.NotAfter.Add(time.Hour)); c.Status != DIAG_FAILED {
+		t.Errorf("wrong check %v", c)
+	} else if c := checkCertificates("exchange", []*x509.Certificate{cert}, cert.NotBefore.Add(-time.Hour)); c.Status != DIAG_FAILED {
+		t.Errorf("wrong check %v", c)
+	}
+
+	if c := checkCertificateFile("ca certs", "/tmp/no-such-cert-file.pem", time.Now()); c.Status != DIAG_FAILED || c.Remediation == "" {
+		t.Errorf("wrong check %v", c)
+	}
+}
+
+func Test_imageRegistry(t *testing.T) {
+
+	images := map[string]string{
+		"nginx":                             DIAG_DEFAULT_REGISTRY,
+		"openhorizon/amd64_agbot:latest":    DIAG_DEFAULT_REGISTRY,
+		"quay.io/myorg/app:1.0":             "quay.io",
+		"localhost/app":                     "localhost",
+		"myregistry:5000/app@sha256:abcdef": "myregistry:5000",
+	}
+	for image, registry := range images {
+		if r := imageRegistry(image); r != registry {
+			t.Errorf("the registry of %v should be %v, but is %v", image, registry, r)
+		}
+	}
+}
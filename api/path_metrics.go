@@ -0,0 +1,48 @@
+package api
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+)
+
+// The states of the agreements in the anax_agreements metric.
+const (
+	AGREEMENT_STATE_ACCEPTED    = "accepted"
+	AGREEMENT_STATE_FINALIZED   = "finalized"
+	AGREEMENT_STATE_EXECUTING   = "executing"
+	AGREEMENT_STATE_TERMINATING = "terminating"
+	AGREEMENT_STATE_ARCHIVED    = "archived"
+)
+
+// Count the agreements in the local database by state. Every state is returned, also when there are no agreements
+// in it, so that the metric goes back to zero.
+func CountAgreementsByState(db *bolt.DB) (map[string]int, error) {
+	counts := map[string]int{
+		AGREEMENT_STATE_ACCEPTED:    0,
+		AGREEMENT_STATE_FINALIZED:   0,
+		AGREEMENT_STATE_EXECUTING:   0,
+		AGREEMENT_STATE_TERMINATING: 0,
+		AGREEMENT_STATE_ARCHIVED:    0,
+	}
+
+	agreements, err := persistence.FindEstablishedAgreementsAllProtocols(db, policy.AllAgreementProtocols(), []persistence.EAFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ag := range agreements {
+		if ag.Archived {
+			counts[AGREEMENT_STATE_ARCHIVED]++
+		} else if ag.AgreementTerminatedTime != 0 {
+			counts[AGREEMENT_STATE_TERMINATING]++
+		} else if ag.AgreementExecutionStartTime != 0 {
+			counts[AGREEMENT_STATE_EXECUTING]++
+		} else if ag.AgreementFinalizedTime != 0 {
+			counts[AGREEMENT_STATE_FINALIZED]++
+		} else {
+			counts[AGREEMENT_STATE_ACCEPTED]++
+		}
+	}
+	return counts, nil
+}
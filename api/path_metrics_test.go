@@ -0,0 +1,54 @@
+//go:build unit
+// +build unit
+
+package api
+
+import (
+	"github.com/open-horizon/anax/persistence"
+	"testing"
+)
+
+func Test_CountAgreementsByState(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	if counts, err := CountAgreementsByState(db); err != nil {
+		t.Errorf("error counting agreements: %v", err)
+	} else if len(counts) != 5 || counts[AGREEMENT_STATE_EXECUTING] != 0 {
+		t.Errorf("expecting all the states with no agreements, have %v", counts)
+	}
+
+	// Agreement 1 is accepted, agreement2 is finalized, agreement3 is executing, agreement4 is terminating and agreement5 is archived.
+	sp := persistence.ServiceSpec{Url: "http://sensor.org", Org: "myorg"}
+	sps := []persistence.ServiceSpec{sp}
+
+	wi, _ := persistence.NewWorkloadInfo("url", "org", "version", "")
+	for _, id := range []string{"agreementId1", "agreementId2", "agreementId3", "agreementId4", "agreementId5"} {
+		if _, err := persistence.NewEstablishedAgreement(db, "name1", id, "consumerId", "{}", "Basic", 1, sps, "signature", "address", "bcType", "bcName", "bcOrg", wi, 180); err != nil {
+			t.Errorf("error writing %v: %v", id, err)
+		}
+	}
+	if _, err := persistence.AgreementStateFinalized(db, "agreementId2", "Basic"); err != nil {
+		t.Errorf("error finalizing agreement2: %v", err)
+	} else if _, err := persistence.AgreementStateExecutionStarted(db, "agreementId3", "Basic"); err != nil {
+		t.Errorf("error starting agreement3: %v", err)
+	} else if _, err := persistence.AgreementStateTerminated(db, "agreementId4", 100, "unit test termination", "Basic"); err != nil {
+		t.Errorf("error terminating agreement4: %v", err)
+	} else if _, err := persistence.ArchiveEstablishedAgreement(db, "agreementId5", "Basic"); err != nil {
+		t.Errorf("error archiving agreement5: %v", err)
+	}
+
+	if counts, err := CountAgreementsByState(db); err != nil {
+		t.Errorf("error counting agreements: %v", err)
+	} else {
+		for _, state := range []string{AGREEMENT_STATE_ACCEPTED, AGREEMENT_STATE_FINALIZED, AGREEMENT_STATE_EXECUTING, AGREEMENT_STATE_TERMINATING, AGREEMENT_STATE_ARCHIVED} {
+			if counts[state] != 1 {
+				t.Errorf("expecting 1 agreement in state %v, have %v", state, counts)
+			}
+		}
+	}
+}
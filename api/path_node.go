@@ -3,6 +3,9 @@ package api
 import (
 	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/boltdb/bolt"
@@ -159,6 +162,7 @@ func CreateHorizonDevice(device *HorizonDevice,
 
 	// make sure current exchange version meet the requirement
 	deviceId := fmt.Sprintf("%v/%v", *device.Org, *device.Id)
+	exchange.UseNodeAuthProvider(deviceId)
 	if exchangeVersion, err := getExchangeVersion(deviceId, *device.Token); err != nil {
 		return errorhandler(NewSystemError(fmt.Sprintf("Error getting exchange version. error: %v", err))), nil, nil
 	} else {
@@ -319,10 +323,12 @@ func UpdateHorizonDevice(device *HorizonDevice,
 
 }
 
-// Handles the DELETE verb on this resource.
+// Handles the DELETE verb on this resource. When an export path is given, the data volumes of the services are exported
+// to it before the service containers are removed.
 func DeleteHorizonDevice(removeNode string,
 	deepClean string,
 	block string,
+	exportPath string,
 	em *events.EventStateManager,
 	msgQueue chan events.Message,
 	errorhandler ErrorHandler,
@@ -341,7 +347,7 @@ func DeleteHorizonDevice(removeNode string,
 		return errorhandler(NewNotFoundError("The node is not registered.", "node"))
 	} else if !pDevice.IsState(persistence.CONFIGSTATE_CONFIGURED) && !pDevice.IsState(persistence.CONFIGSTATE_CONFIGURING) {
 		LogDeviceEvent(db, persistence.SEVERITY_ERROR, persistence.NewMessageMeta(EL_API_ERR_NODE_UNREG_NOT_IN_STATE), persistence.EC_ERROR_NODE_UNREG, pDevice)
-		return errorhandler(NewBadRequestError(fmt.Sprintf("INVALID_NODE_STATE. The node must be in configured or configuring state in order to unconfigure it.")))
+		return errorhandler(NewAPIError(http.StatusBadRequest, API_ERR_INVALID_NODE_STATE, "The node must be in configured or configuring state in order to unconfigure it.", "node", ""))
 	}
 
 	// Verify optional input
@@ -357,6 +363,13 @@ func DeleteHorizonDevice(removeNode string,
 		LogDeviceEvent(db, persistence.SEVERITY_ERROR, persistence.NewMessageMeta(EL_API_ERR_NODE_UNREG_WRONG_VALUE_FOR_BLOCK, block), persistence.EC_API_USER_INPUT_ERROR, pDevice)
 		return errorhandler(NewAPIUserInputError("%v is an incorrect value for block", "url.block"))
 	}
+	if exportPath != "" {
+		if err := validateExportPath(exportPath, pDevice.GetNodeType()); err != nil {
+			LogDeviceEvent(db, persistence.SEVERITY_ERROR, persistence.NewMessageMeta(EL_API_ERR_NODE_UNREG_WRONG_EXPORT_PATH, exportPath, err.Error()), persistence.EC_API_USER_INPUT_ERROR, pDevice)
+			return errorhandler(NewAPIUserInputError(err.Error(), "url.exportPath"))
+		}
+		exportPath = filepath.Clean(exportPath)
+	}
 
 	// Establish defaults for optional inputs
 	rNode := false
@@ -385,7 +398,7 @@ func DeleteHorizonDevice(removeNode string,
 	Unconfiguring = true
 
 	// Fire the NodeShutdown event to get the node to quiesce itself.
-	ns := events.NewNodeShutdownMessage(events.START_UNCONFIGURE, blocking, rNode)
+	ns := events.NewNodeShutdownMessage(events.START_UNCONFIGURE, blocking, rNode, exportPath)
 	msgQueue <- ns
 
 	// Wait (if allowed) for the ShutdownComplete event
@@ -415,3 +428,16 @@ func DeleteHorizonDevice(removeNode string,
 	return false
 
 }
+
+// The directory the service data volumes are exported to must be an absolute path the agent can write to. The services
+// of a cluster node are removed by the operator, their volumes cannot be exported.
+func validateExportPath(exportPath string, nodeType string) error {
+	if nodeType == persistence.DEVICE_TYPE_CLUSTER {
+		return fmt.Errorf("the data volumes of the services cannot be exported on a %v node", nodeType)
+	} else if !filepath.IsAbs(exportPath) {
+		return fmt.Errorf("%v is not an absolute path", exportPath)
+	} else if err := os.MkdirAll(exportPath, 0700); err != nil {
+		return fmt.Errorf("unable to create directory %v, error %v", exportPath, err)
+	}
+	return nil
+}
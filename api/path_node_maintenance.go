@@ -0,0 +1,85 @@
+package api
+
+import (
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+)
+
+// Return the maintenance mode of the node.
+func FindNodeMaintenanceForOutput(db *bolt.DB) (*NodeMaintenance, error) {
+	enabled := false
+	out := &NodeMaintenance{Enabled: &enabled}
+
+	if maintenance, err := persistence.FindNodeMaintenance(db); err != nil {
+		return nil, err
+	} else if maintenance != nil {
+		enabled = true
+		out.Drain = &maintenance.Drain
+		out.StartTime = &maintenance.StartTime
+	}
+	return out, nil
+}
+
+// Turn the maintenance mode of the node on or off. While the node is in maintenance mode, the agent does not accept
+// new agreements. When the maintenance mode is turned on with drain, the existing agreements are cancelled so that their
+// services are stopped gracefully. It returns the events that cancel the agreements.
+func UpdateNodeMaintenance(maintenance *NodeMaintenance, errorhandler ErrorHandler, db *bolt.DB) (bool, *NodeMaintenance, []*events.ApiAgreementCancelationMessage) {
+
+	if maintenance.Enabled == nil {
+		return errorhandler(NewAPIUserInputError("Please specify if the maintenance mode is enabled.", "enabled")), nil, nil
+	}
+
+	if !*maintenance.Enabled {
+		if err := persistence.DeleteNodeMaintenance(db); err != nil {
+			return errorhandler(NewSystemError(fmt.Sprintf("Unable to delete the node maintenance mode from the database, error %v", err))), nil, nil
+		}
+		glog.V(3).Infof(apiLogString("Node maintenance mode turned off."))
+		out, err := FindNodeMaintenanceForOutput(db)
+		if err != nil {
+			return errorhandler(NewSystemError(fmt.Sprintf("Unable to read the node maintenance mode from the database, error %v", err))), nil, nil
+		}
+		return false, out, nil
+	}
+
+	drain := maintenance.Drain != nil && *maintenance.Drain
+
+	// keep the start time when the node is already in maintenance mode
+	current, err := persistence.FindNodeMaintenance(db)
+	if err != nil {
+		return errorhandler(NewSystemError(fmt.Sprintf("Unable to read the node maintenance mode from the database, error %v", err))), nil, nil
+	}
+	newMaintenance := persistence.NewNodeMaintenance(drain)
+	if current != nil {
+		newMaintenance.StartTime = current.StartTime
+		newMaintenance.Drain = drain || current.Drain
+	}
+	if err := persistence.SaveNodeMaintenance(db, newMaintenance); err != nil {
+		return errorhandler(NewSystemError(fmt.Sprintf("Unable to save the node maintenance mode in the database, error %v", err))), nil, nil
+	}
+
+	// cancel the agreements that are not already terminating
+	msgs := make([]*events.ApiAgreementCancelationMessage, 0)
+	if drain {
+		agreements, err := persistence.FindEstablishedAgreementsAllProtocols(db, policy.AllAgreementProtocols(), []persistence.EAFilter{persistence.UnarchivedEAFilter()})
+		if err != nil {
+			return errorhandler(NewSystemError(fmt.Sprintf("Unable to read agreement objects, error %v", err))), nil, nil
+		}
+		for _, ag := range agreements {
+			if ag.AgreementTerminatedTime == 0 {
+				msgs = append(msgs, events.NewApiAgreementCancelationMessage(events.AGREEMENT_ENDED, events.AG_TERMINATED, ag.AgreementProtocol, ag.CurrentAgreementId, ag.GetDeploymentConfig()))
+			}
+		}
+	}
+
+	glog.V(3).Infof(apiLogString(fmt.Sprintf("Node maintenance mode turned on: %v, cancelling %v agreements.", newMaintenance, len(msgs))))
+
+	out, err := FindNodeMaintenanceForOutput(db)
+	if err != nil {
+		return errorhandler(NewSystemError(fmt.Sprintf("Unable to read the node maintenance mode from the database, error %v", err))), nil, nil
+	}
+	return false, out, msgs
+}
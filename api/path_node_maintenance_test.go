@@ -0,0 +1,89 @@
+//go:build unit
+// +build unit
+
+package api
+
+import (
+	"github.com/open-horizon/anax/persistence"
+	"testing"
+)
+
+// The enabled flag is required.
+func Test_UpdateNodeMaintenance_noinput(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	var myError error
+	errorhandler := GetPassThroughErrorHandler(&myError)
+	if errHandled, out, msgs := UpdateNodeMaintenance(&NodeMaintenance{}, errorhandler, db); !errHandled {
+		t.Errorf("expected to receive error")
+	} else if _, ok := myError.(*APIUserInputError); !ok {
+		t.Errorf("expected error of type APIUserInputError, but is %T, %v", myError, myError)
+	} else if out != nil || msgs != nil {
+		t.Errorf("should not have returned output, %v %v", out, msgs)
+	}
+}
+
+// Turn maintenance mode on, then on with drain, then off. Only the active agreements are cancelled by the drain.
+func Test_UpdateNodeMaintenance(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	// Agreement 1 is active, agreement2 is archived, agreement3 is active but terminating.
+	sp := persistence.ServiceSpec{Url: "http://sensor.org", Org: "myorg"}
+	sps := []persistence.ServiceSpec{sp}
+
+	wi, _ := persistence.NewWorkloadInfo("url", "org", "version", "")
+	if _, err := persistence.NewEstablishedAgreement(db, "name1", "agreementId1", "consumerId", "{}", "Basic", 1, sps, "signature", "address", "bcType", "bcName", "bcOrg", wi, 180); err != nil {
+		t.Errorf("error writing agreement1: %v", err)
+	} else if _, err := persistence.NewEstablishedAgreement(db, "name1", "agreementId2", "consumerId", "{}", "Basic", 1, sps, "signature", "address", "bcType", "bcName", "bcOrg", wi, 180); err != nil {
+		t.Errorf("error writing agreement2: %v", err)
+	} else if _, err := persistence.NewEstablishedAgreement(db, "name1", "agreementId3", "consumerId", "{}", "Basic", 1, sps, "signature", "address", "bcType", "bcName", "bcOrg", wi, 180); err != nil {
+		t.Errorf("error writing agreement3: %v", err)
+	} else if _, err := persistence.ArchiveEstablishedAgreement(db, "agreementId2", "Basic"); err != nil {
+		t.Errorf("error archiving agreement2: %v", err)
+	} else if _, err := persistence.AgreementStateTerminated(db, "agreementId3", 100, "unit test termination", "Basic"); err != nil {
+		t.Errorf("error terminating agreement3: %v", err)
+	}
+
+	var myError error
+	errorhandler := GetPassThroughErrorHandler(&myError)
+	enabled, drain := true, true
+
+	if errHandled, out, msgs := UpdateNodeMaintenance(&NodeMaintenance{Enabled: &enabled}, errorhandler, db); errHandled {
+		t.Errorf("unexpected error %v", myError)
+	} else if out == nil || out.Enabled == nil || !*out.Enabled || out.Drain == nil || *out.Drain || out.StartTime == nil {
+		t.Errorf("wrong output %v", out)
+	} else if len(msgs) != 0 {
+		t.Errorf("no agreement should be cancelled, but got %v", msgs)
+	} else if !persistence.IsNodeInMaintenance(db) {
+		t.Errorf("node should be in maintenance mode")
+	}
+
+	if errHandled, out, msgs := UpdateNodeMaintenance(&NodeMaintenance{Enabled: &enabled, Drain: &drain}, errorhandler, db); errHandled {
+		t.Errorf("unexpected error %v", myError)
+	} else if out == nil || out.Drain == nil || !*out.Drain {
+		t.Errorf("wrong output %v", out)
+	} else if len(msgs) != 1 || msgs[0].AgreementId != "agreementId1" {
+		t.Errorf("only agreement1 should be cancelled, but got %v", msgs)
+	}
+
+	enabled = false
+	if errHandled, out, msgs := UpdateNodeMaintenance(&NodeMaintenance{Enabled: &enabled}, errorhandler, db); errHandled {
+		t.Errorf("unexpected error %v", myError)
+	} else if out == nil || out.Enabled == nil || *out.Enabled || out.StartTime != nil {
+		t.Errorf("wrong output %v", out)
+	} else if msgs != nil {
+		t.Errorf("no agreement should be cancelled, but got %v", msgs)
+	} else if persistence.IsNodeInMaintenance(db) {
+		t.Errorf("node should not be in maintenance mode")
+	}
+}
@@ -12,7 +12,7 @@ import (
 	"testing"
 )
 
-const NUM_BUILT_INS = 7
+const NUM_BUILT_INS = 9
 
 func init() {
 	flag.Set("alsologtostderr", "true")
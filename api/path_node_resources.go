@@ -0,0 +1,148 @@
+package api
+
+import (
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/kube_operator"
+	"github.com/open-horizon/anax/persistence"
+)
+
+// The capacity of one kind of resource of the node and how much of it is in use.
+type NodeResource struct {
+	Capacity    float64  `json:"capacity"`
+	Available   *float64 `json:"available,omitempty"`   // the amount that is free now, e.g. the memory not used by any process
+	Reserved    *float64 `json:"reserved,omitempty"`    // the amount reserved by the active agreements
+	Allocatable *float64 `json:"allocatable,omitempty"` // the amount the cluster can schedule to pods
+}
+
+func (r NodeResource) String() string {
+	return fmt.Sprintf("Capacity: %v, Available: %v, Reserved: %v, Allocatable: %v", r.Capacity, floatString(r.Available), floatString(r.Reserved), floatString(r.Allocatable))
+}
+
+func floatString(f *float64) string {
+	if f == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%v", *f)
+}
+
+// The output of GET /node/resources. A resource that cannot be read on this node is omitted.
+type NodeResources struct {
+	NodeType string        `json:"nodeType"`
+	CPU      *NodeResource `json:"cpu,omitempty"`    // in CPUs
+	Memory   *NodeResource `json:"memory,omitempty"` // in MB
+	Disk     *NodeResource `json:"disk,omitempty"`   // in MB, the file system that holds the service storage
+	GPU      *NodeResource `json:"gpu,omitempty"`    // in GPUs
+}
+
+func (n NodeResources) String() string {
+	return fmt.Sprintf("NodeType: %v, CPU: %v, Memory: %v, Disk: %v, GPU: %v", n.NodeType, n.CPU, n.Memory, n.Disk, n.GPU)
+}
+
+// Return the current capacity of the node and the resources reserved by the active agreements. On a device, the
+// reservations are the limits of the service containers. On a cluster, they are the requests of the running pods.
+func FindNodeResourcesForOutput(db *bolt.DB, storagePath string) (*NodeResources, error) {
+
+	pDevice, err := persistence.FindExchangeDevice(db)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read node object, error %v", err)
+	}
+
+	if pDevice != nil && pDevice.GetNodeType() == persistence.DEVICE_TYPE_CLUSTER {
+		return findClusterResources()
+	}
+
+	reservations, err := persistence.FindResourceReservations(db)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the resource reservations, error %v", err)
+	}
+
+	out := &NodeResources{NodeType: persistence.DEVICE_TYPE_DEVICE}
+
+	if cpus, err := cutil.GetCPUCount(""); err != nil {
+		glog.V(3).Infof(apiLogString(fmt.Sprintf("Unable to get the cpu count of the node, error %v", err)))
+	} else {
+		out.CPU = &NodeResource{Capacity: float64(cpus)}
+	}
+
+	if totalMem, availMem, err := cutil.GetMemInfo(""); err != nil {
+		glog.V(3).Infof(apiLogString(fmt.Sprintf("Unable to get the memory of the node, error %v", err)))
+	} else {
+		available := float64(availMem)
+		out.Memory = &NodeResource{Capacity: float64(totalMem), Available: &available}
+	}
+
+	if storagePath == "" {
+		storagePath = "/"
+	}
+	if totalDisk, availDisk, err := cutil.GetDiskInfo(storagePath); err != nil {
+		glog.V(3).Infof(apiLogString(fmt.Sprintf("Unable to get the disk size of %v, error %v", storagePath, err)))
+	} else {
+		available := float64(availDisk)
+		out.Disk = &NodeResource{Capacity: float64(totalDisk), Available: &available}
+	}
+
+	if gpus, err := cutil.GetGPUCount(""); err != nil {
+		glog.V(3).Infof(apiLogString(fmt.Sprintf("Unable to get the gpu count of the node, error %v", err)))
+	} else {
+		out.GPU = &NodeResource{Capacity: float64(gpus)}
+	}
+
+	addResourceReservations(out, reservations)
+	return out, nil
+}
+
+// Add the resources reserved by the containers of the agreements to the device resources. A container that can use all
+// the GPUs reserves all of them.
+func addResourceReservations(out *NodeResources, reservations []persistence.ResourceReservation) {
+	memoryMb, cpus, gpus := float64(0), float64(0), float64(0)
+	allGPUs := false
+	for _, r := range reservations {
+		memoryMb += float64(r.MemoryMb)
+		cpus += r.CPUs
+		if r.GPUs < 0 {
+			allGPUs = true
+		} else {
+			gpus += float64(r.GPUs)
+		}
+	}
+
+	if out.CPU != nil {
+		out.CPU.Reserved = &cpus
+	}
+	if out.Memory != nil {
+		out.Memory.Reserved = &memoryMb
+	}
+	if out.GPU != nil {
+		if allGPUs || gpus > out.GPU.Capacity {
+			gpus = out.GPU.Capacity
+		}
+		out.GPU.Reserved = &gpus
+	}
+}
+
+// Return the cpu and memory of the schedulable nodes of the cluster.
+func findClusterResources() (*NodeResources, error) {
+	client, err := kube_operator.NewKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get a kube client, error %v", err)
+	}
+
+	capacity, allocatable, requested, err := client.GetClusterResources()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the cluster resources, error %v", err)
+	}
+
+	cpuAllocatable := float64(allocatable.CPU.MilliValue()) / 1000
+	cpuReserved := float64(requested.CPU.MilliValue()) / 1000
+	memAllocatable := float64(allocatable.Memory.Value() >> 20)
+	memReserved := float64(requested.Memory.Value() >> 20)
+
+	return &NodeResources{
+		NodeType: persistence.DEVICE_TYPE_CLUSTER,
+		CPU:      &NodeResource{Capacity: float64(capacity.CPU.MilliValue()) / 1000, Reserved: &cpuReserved, Allocatable: &cpuAllocatable},
+		Memory:   &NodeResource{Capacity: float64(capacity.Memory.Value() >> 20), Reserved: &memReserved, Allocatable: &memAllocatable},
+	}, nil
+}
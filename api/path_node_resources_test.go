@@ -0,0 +1,54 @@
+//go:build unit
+// +build unit
+
+package api
+
+import (
+	"github.com/open-horizon/anax/persistence"
+	"testing"
+)
+
+// The capacity of the device is reported with the resources reserved by the agreements.
+func Test_FindNodeResourcesForOutput(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	for _, r := range []*persistence.ResourceReservation{persistence.NewResourceReservation("ag1", 256, 0.5, 0), persistence.NewResourceReservation("ag2", 512, 1, 0)} {
+		if err := persistence.SaveResourceReservation(db, r); err != nil {
+			t.Errorf("failed to save resource reservation, error %v", err)
+		}
+	}
+
+	if out, err := FindNodeResourcesForOutput(db, dir); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if out.NodeType != persistence.DEVICE_TYPE_DEVICE {
+		t.Errorf("wrong node type %v", out.NodeType)
+	} else if out.CPU == nil || out.CPU.Reserved == nil || *out.CPU.Reserved != 1.5 {
+		t.Errorf("wrong cpu resources %v", out.CPU)
+	} else if out.Memory == nil || out.Memory.Reserved == nil || *out.Memory.Reserved != 768 || out.Memory.Available == nil {
+		t.Errorf("wrong memory resources %v", out.Memory)
+	} else if out.Disk == nil || out.Disk.Available == nil || *out.Disk.Available > out.Disk.Capacity {
+		t.Errorf("wrong disk resources %v", out.Disk)
+	}
+}
+
+// A container that can use all the GPUs reserves all of them.
+func Test_addResourceReservations(t *testing.T) {
+
+	out := &NodeResources{GPU: &NodeResource{Capacity: 4}}
+	addResourceReservations(out, []persistence.ResourceReservation{{Key: "ag1", GPUs: 1}, {Key: "ag2", GPUs: 2}})
+	if out.GPU.Reserved == nil || *out.GPU.Reserved != 3 {
+		t.Errorf("wrong gpu resources %v", out.GPU)
+	} else if out.CPU != nil || out.Memory != nil {
+		t.Errorf("only the gpu should be set, %v", out)
+	}
+
+	addResourceReservations(out, []persistence.ResourceReservation{{Key: "ag1", GPUs: 1}, {Key: "ag2", GPUs: -1}})
+	if out.GPU.Reserved == nil || *out.GPU.Reserved != 4 {
+		t.Errorf("all the gpus should be reserved, %v", out.GPU)
+	}
+}
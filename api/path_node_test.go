@@ -11,6 +11,7 @@ import (
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/persistence"
 	"os"
+	"path"
 	"strings"
 	"testing"
 )
@@ -725,7 +726,7 @@ func Test_DeleteHorizonDevice_success(t *testing.T) {
 	blocking := "false"
 	deepClean := "false"
 	msgQueue := make(chan events.Message, 10)
-	errHandled := DeleteHorizonDevice(removeNode, deepClean, blocking, events.NewEventStateManager(), msgQueue, errorhandler, db)
+	errHandled := DeleteHorizonDevice(removeNode, deepClean, blocking, "", events.NewEventStateManager(), msgQueue, errorhandler, db)
 
 	if errHandled {
 		t.Errorf("unexpected error %v", myError)
@@ -764,12 +765,14 @@ func Test_DeleteHorizonDevice_fail1(t *testing.T) {
 	blocking := "false"
 	deepClean := "false"
 	msgQueue := make(chan events.Message, 10)
-	errHandled := DeleteHorizonDevice(removeNode, deepClean, blocking, events.NewEventStateManager(), msgQueue, errorhandler, db)
+	errHandled := DeleteHorizonDevice(removeNode, deepClean, blocking, "", events.NewEventStateManager(), msgQueue, errorhandler, db)
 
 	if !errHandled {
 		t.Errorf("expected error")
-	} else if _, ok := myError.(*BadRequestError); !ok {
+	} else if apiErr, ok := myError.(*APIError); !ok {
 		t.Errorf("myError has the wrong type (%T)", myError)
+	} else if apiErr.Code != API_ERR_INVALID_NODE_STATE {
+		t.Errorf("wrong error code %v", apiErr.Code)
 	} else if len(msgQueue) != 0 {
 		t.Errorf("there should not be a message on the queue")
 	} else if dev, err := FindHorizonDeviceForOutput(db); err != nil {
@@ -780,6 +783,82 @@ func Test_DeleteHorizonDevice_fail1(t *testing.T) {
 
 }
 
+// Delete of horizondevice with the export of the service volumes
+func Test_DeleteHorizonDevice_exportPath(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	myOrg := "testOrg"
+	myPattern := "testPattern"
+	device := getBasicDevice(myOrg, myPattern)
+
+	_, err = persistence.SaveNewExchangeDevice(db, *device.Id, *device.Token, *device.Name, "device", *device.Org, *device.Pattern, persistence.CONFIGSTATE_CONFIGURED, persistence.SoftwareVersion{persistence.AGENT_VERSION: "1.0.0"})
+	if err != nil {
+		t.Errorf("unexpected error creating device %v", err)
+	}
+
+	var myError error
+	errorhandler := GetPassThroughErrorHandler(&myError)
+	msgQueue := make(chan events.Message, 10)
+
+	errHandled := DeleteHorizonDevice("false", "false", "false", "export", events.NewEventStateManager(), msgQueue, errorhandler, db)
+	if !errHandled {
+		t.Errorf("expected an error for a relative export path")
+	} else if _, ok := myError.(*APIUserInputError); !ok {
+		t.Errorf("myError has the wrong type (%T)", myError)
+	} else if len(msgQueue) != 0 {
+		t.Errorf("there should not be a message on the queue")
+	}
+
+	exportPath := path.Join(dir, "export")
+	errHandled = DeleteHorizonDevice("false", "false", "false", exportPath+"/", events.NewEventStateManager(), msgQueue, errorhandler, db)
+	if errHandled {
+		t.Errorf("unexpected error %v", myError)
+	} else if len(msgQueue) != 1 {
+		t.Errorf("there should be a message on the queue")
+	} else if ns, ok := (<-msgQueue).(*events.NodeShutdownMessage); !ok {
+		t.Errorf("wrong message type")
+	} else if ns.ExportPath() != exportPath {
+		t.Errorf("wrong export path %v", ns.ExportPath())
+	} else if _, err := os.Stat(exportPath); err != nil {
+		t.Errorf("the export path should be created, error %v", err)
+	}
+}
+
+// Delete of horizondevice fails because the volumes of a cluster node cannot be exported
+func Test_DeleteHorizonDevice_exportPath_cluster(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	device := getBasicDevice("testOrg", "testPattern")
+
+	_, err = persistence.SaveNewExchangeDevice(db, *device.Id, *device.Token, *device.Name, "cluster", *device.Org, *device.Pattern, persistence.CONFIGSTATE_CONFIGURED, persistence.SoftwareVersion{persistence.AGENT_VERSION: "1.0.0"})
+	if err != nil {
+		t.Errorf("unexpected error creating device %v", err)
+	}
+
+	var myError error
+	errorhandler := GetPassThroughErrorHandler(&myError)
+	msgQueue := make(chan events.Message, 10)
+
+	errHandled := DeleteHorizonDevice("false", "false", "false", path.Join(dir, "export"), events.NewEventStateManager(), msgQueue, errorhandler, db)
+	if !errHandled {
+		t.Errorf("expected an error")
+	} else if _, ok := myError.(*APIUserInputError); !ok {
+		t.Errorf("myError has the wrong type (%T)", myError)
+	} else if len(msgQueue) != 0 {
+		t.Errorf("there should not be a message on the queue")
+	}
+}
+
 // Patch of horizondevice fails because its in the wrong state
 func Test_PatchHorizonDevice_fail1(t *testing.T) {
 
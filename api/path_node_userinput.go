@@ -66,7 +66,9 @@ func UpdateNodeUserInput(userInput []policy.UserInput,
 	}
 }
 
-// Update a single field of the UserInput object in the local node db and in the exchange
+// Merge the variables of the patch into the UserInput object in the local node db and in the exchange. The values are
+// validated against the userInputs of the service definitions. Only the services whose user input is changed by the
+// patch are restarted.
 func PatchNodeUserInput(patchObject []policy.UserInput,
 	errorhandler DeviceErrorHandler,
 	getDevice exchange.DeviceHandler,
@@ -92,17 +94,15 @@ func PatchNodeUserInput(patchObject []policy.UserInput,
 		}
 	}
 
-	if err := exchangesync.PatchNodeUserInput(pDevice, db, patchObject, getDevice, patchDevice); err != nil {
+	if changedSvcs, err := exchangesync.PatchNodeUserInput(pDevice, db, patchObject, getDevice, patchDevice); err != nil {
 		return errorhandler(pDevice, NewSystemError(fmt.Sprintf("Unable patch the user input. %v", err))), nil, nil
+	} else if len(changedSvcs) == 0 {
+		LogDeviceEvent(db, persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_API_NODE_UI_UNCHANGED, patchObject), persistence.EC_NODE_USERINPUT_UPDATED, pDevice)
+		return false, patchObject, []*events.NodeUserInputMessage{}
 	} else {
 		LogDeviceEvent(db, persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_API_NEW_NODE_UI, patchObject), persistence.EC_NODE_USERINPUT_UPDATED, pDevice)
 
-		chnagedSvcSpecs := new(persistence.ServiceSpecs)
-		for _, ui := range patchObject {
-			chnagedSvcSpecs.AppendServiceSpec(persistence.ServiceSpec{Url: ui.ServiceUrl, Org: ui.ServiceOrgid})
-
-		}
-		nodeUserInputUpdated := events.NewNodeUserInputMessage(events.UPDATE_NODE_USERINPUT, *chnagedSvcSpecs)
+		nodeUserInputUpdated := events.NewNodeUserInputMessage(events.UPDATE_NODE_USERINPUT, changedSvcs)
 		return false, patchObject, []*events.NodeUserInputMessage{nodeUserInputUpdated}
 	}
 }
@@ -0,0 +1,77 @@
+//go:build unit
+// +build unit
+
+package api
+
+import (
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"testing"
+)
+
+// The patch is merged variable by variable and only the services whose user input changed are restarted.
+func Test_PatchNodeUserInput(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	var myError error
+	errorhandler := GetPassThroughErrorHandler(&myError)
+	userinput_error_handler := func(device interface{}, err error) bool {
+		return errorhandler(err)
+	}
+
+	_, err = persistence.SaveNewExchangeDevice(db, "testid", "testtoken", "testname", persistence.DEVICE_TYPE_DEVICE, "myorg", "", persistence.CONFIGSTATE_CONFIGURED, persistence.SoftwareVersion{persistence.AGENT_VERSION: "1.0.0"})
+	if err != nil {
+		t.Errorf("failed to create persisted device, error %v", err)
+	}
+
+	// the node user input in the exchange
+	exchUserInput := []policy.UserInput{
+		{ServiceOrgid: "myorg", ServiceUrl: "svc1", Inputs: []policy.Input{{Name: "var1", Value: float64(1)}, {Name: "var2", Value: float64(2)}}},
+		{ServiceOrgid: "myorg", ServiceUrl: "svc2", Inputs: []policy.Input{{Name: "var1", Value: float64(3)}}},
+	}
+	getDevice := func(id string, token string) (*exchange.Device, error) {
+		return &exchange.Device{UserInput: exchUserInput}, nil
+	}
+	patchDevice := func(deviceId string, deviceToken string, pdr *exchange.PatchDeviceRequest) error {
+		exchUserInput = *pdr.UserInput
+		return nil
+	}
+	getService := getVariableServiceHandler(exchangecommon.UserInput{Name: "var1", Type: "int"})
+
+	patch := []policy.UserInput{{ServiceOrgid: "myorg", ServiceUrl: "svc1", Inputs: []policy.Input{{Name: "var1", Value: float64(5)}}}}
+	if errHandled, _, msgs := PatchNodeUserInput(patch, userinput_error_handler, getDevice, patchDevice, getService, db); errHandled {
+		t.Errorf("unexpected error %v", myError)
+	} else if len(msgs) != 1 || len(msgs[0].ServiceSpecs) != 1 || msgs[0].ServiceSpecs[0].Url != "svc1" {
+		t.Errorf("only svc1 should be restarted, but the messages are %v", msgs)
+	} else if ui, err := persistence.FindNodeUserInput(db); err != nil {
+		t.Errorf("failed to read the node user input, error %v", err)
+	} else if len(ui) != 2 || len(ui[0].Inputs) != 2 || ui[0].Inputs[0].Value != float64(5) || ui[0].Inputs[1].Value != float64(2) || ui[1].Inputs[0].Value != float64(3) {
+		t.Errorf("wrong node user input after the patch %v", ui)
+	}
+
+	// the same patch again does not restart any service
+	if errHandled, _, msgs := PatchNodeUserInput(patch, userinput_error_handler, getDevice, patchDevice, getService, db); errHandled {
+		t.Errorf("unexpected error %v", myError)
+	} else if len(msgs) != 0 {
+		t.Errorf("no service should be restarted, but the messages are %v", msgs)
+	}
+
+	// the value does not have the type declared in the service definition
+	patch = []policy.UserInput{{ServiceOrgid: "myorg", ServiceUrl: "svc2", Inputs: []policy.Input{{Name: "var1", Value: "abc"}}}}
+	if errHandled, _, msgs := PatchNodeUserInput(patch, userinput_error_handler, getDevice, patchDevice, getService, db); !errHandled {
+		t.Errorf("expected to receive error")
+	} else if _, ok := myError.(*APIUserInputError); !ok {
+		t.Errorf("expected error of type APIUserInputError, but is %T, %v", myError, myError)
+	} else if msgs != nil {
+		t.Errorf("should not have returned messages, %v", msgs)
+	} else if exchUserInput[1].Inputs[0].Value != float64(3) {
+		t.Errorf("the exchange user input should not be changed, %v", exchUserInput)
+	}
+}
@@ -0,0 +1,269 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/open-horizon/anax/businesspolicy"
+	"github.com/open-horizon/anax/compcheck"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"sort"
+)
+
+// The input of POST /node/validate. It describes the node as it is going to be registered. The node is not registered
+// and nothing is saved, the services that would be deployed on the node are returned.
+type NodeValidation struct {
+	Node               *HorizonDevice                           `json:"node,omitempty"`               // the id, token, organization, pattern and nodeType of the node, the registered node when omitted
+	Policy             *exchangecommon.NodePolicy               `json:"policy,omitempty"`             // the node policy, the policy of the registered node when omitted
+	UserInput          []policy.UserInput                       `json:"userInput,omitempty"`          // the user input, the user input of the registered node when omitted
+	PatternDefinition  *exchange.Pattern                        `json:"patternDefinition,omitempty"`  // the definition of the node pattern, it is taken from the exchange when omitted
+	DeploymentPolicies map[string]businesspolicy.BusinessPolicy `json:"deploymentPolicies,omitempty"` // the deployment policies keyed by org/name, all the deployment policies of the node org in the exchange when omitted
+}
+
+func (n NodeValidation) String() string {
+	return fmt.Sprintf("Node: %v, Policy: %v, UserInput: %v, PatternDefinition: %v, DeploymentPolicies: %v", n.Node, n.Policy, n.UserInput, n.PatternDefinition, n.DeploymentPolicies)
+}
+
+// The services that the pattern or a deployment policy would deploy on the node.
+type NodeValidationResult struct {
+	Pattern          string            `json:"pattern,omitempty"`
+	DeploymentPolicy string            `json:"deploymentPolicy,omitempty"`
+	Services         []string          `json:"services"`               // the services that would be deployed
+	Incompatible     map[string]string `json:"incompatible,omitempty"` // why each of the other services would not be deployed
+	Error            string            `json:"error,omitempty"`        // the compatibility could not be checked
+}
+
+type NodeValidationOutput struct {
+	Compatible bool                   `json:"compatible"` // at least one service would be deployed
+	Results    []NodeValidationResult `json:"results"`
+	Offline    bool                   `json:"offline"` // the exchange could not be reached, the service definitions came from the local service cache
+}
+
+// A handler that checks the compatibility of the node with a pattern or a deployment policy.
+type DeployCompatibleHandler func(ccInput *compcheck.CompCheck) (*compcheck.CompCheckOutput, error)
+
+// Check which services would be deployed on the node if it was registered as described, using the same checks as
+// hzn deploycheck all. The current registration of the node, if any, fills in what is not in the input.
+func ValidateNode(nv *NodeValidation,
+	errorhandler ErrorHandler,
+	getPatterns exchange.PatternHandler,
+	getBusinessPolicies exchange.BusinessPoliciesHandler,
+	deployCompatible DeployCompatibleHandler,
+	db *bolt.DB) (bool, *NodeValidationOutput) {
+
+	pDevice, err := persistence.FindExchangeDevice(db)
+	if err != nil {
+		return errorhandler(NewSystemError(fmt.Sprintf("Unable to read node object, error %v", err))), nil
+	}
+
+	// the node as it is going to be registered
+	var org, pattern, nodeType, clusterNS string
+	if nv.Node != nil {
+		if nv.Node.Org != nil {
+			org = *nv.Node.Org
+		} else if pDevice != nil {
+			org = pDevice.Org
+		}
+		if nv.Node.Pattern != nil {
+			pattern = *nv.Node.Pattern
+		}
+		if nv.Node.NodeType != nil {
+			nodeType = *nv.Node.NodeType
+		}
+		if nv.Node.ClusterNamespace != nil {
+			clusterNS = *nv.Node.ClusterNamespace
+		}
+	} else if pDevice != nil {
+		org = pDevice.Org
+		pattern = pDevice.Pattern
+		nodeType = pDevice.NodeType
+	}
+
+	if org == "" {
+		return errorhandler(NewAPIUserInputError("The organization of the node is required when the node is not registered.", "node.organization")), nil
+	} else if nodeType == "" {
+		nodeType = persistence.DEVICE_TYPE_DEVICE
+	} else if nodeType != persistence.DEVICE_TYPE_DEVICE && nodeType != persistence.DEVICE_TYPE_CLUSTER {
+		return errorhandler(NewAPIUserInputError(fmt.Sprintf("The node type %v is not valid, it must be %v or %v.", nodeType, persistence.DEVICE_TYPE_DEVICE, persistence.DEVICE_TYPE_CLUSTER), "node.nodeType")), nil
+	}
+
+	// the node policy with the built-in properties of this node
+	nodePolicy := nv.Policy
+	if nodePolicy == nil {
+		if nodePolicy, err = persistence.FindNodePolicy(db); err != nil {
+			return errorhandler(NewSystemError(fmt.Sprintf("Unable to read node policy, error %v", err))), nil
+		} else if nodePolicy == nil {
+			nodePolicy = &exchangecommon.NodePolicy{}
+		}
+	}
+	builtinPol, builtinPolReadWrite := externalpolicy.CreateNodeBuiltInPolicy(false, false, &nodePolicy.ExternalPolicy, nodeType == persistence.DEVICE_TYPE_CLUSTER)
+	if builtinPol != nil {
+		(&nodePolicy.ExternalPolicy).MergeWith(builtinPol, true)
+	}
+	if builtinPolReadWrite != nil {
+		(&nodePolicy.ExternalPolicy).MergeWith(builtinPolReadWrite, false)
+	}
+	if err := nodePolicy.ValidateAndNormalize(); err != nil {
+		return errorhandler(NewAPIUserInputError(fmt.Sprintf("Node policy does not validate, error %v", err), "policy")), nil
+	}
+
+	userInput := nv.UserInput
+	if userInput == nil {
+		if userInput, err = persistence.FindNodeUserInput(db); err != nil {
+			return errorhandler(NewSystemError(fmt.Sprintf("Unable to read node user input, error %v", err))), nil
+		}
+	}
+
+	ccInput := compcheck.CompCheck{
+		NodeArch:      cutil.ArchString(),
+		NodeType:      nodeType,
+		NodeClusterNS: clusterNS,
+		NodeOrg:       org,
+		NodePolicy:    nodePolicy,
+		NodeUserInput: userInput,
+	}
+
+	out := &NodeValidationOutput{Results: []NodeValidationResult{}}
+
+	if pattern != "" {
+		patOrg, patName, patId := persistence.GetFormatedPatternString(pattern, org)
+
+		patDef := nv.PatternDefinition
+		if patDef == nil {
+			if patterns, err := getPatterns(patOrg, patName); exchange.IsRetriesExceededError(err) {
+				return errorhandler(NewServiceUnavailableError(fmt.Sprintf("Unable to get pattern %v, the exchange cannot be reached. Give the pattern in patternDefinition to validate the node offline. %v", patId, err))), nil
+			} else if err != nil {
+				return errorhandler(NewSystemError(fmt.Sprintf("Unable to get pattern %v from the exchange, error %v", patId, err))), nil
+			} else if p, ok := patterns[patId]; !ok {
+				return errorhandler(NewNotFoundError(fmt.Sprintf("Pattern %v not found in the exchange.", patId), "node.pattern")), nil
+			} else {
+				patDef = &p
+			}
+		}
+
+		cc := ccInput
+		cc.PatternId = patId
+		cc.Pattern = &compcheck.Pattern{Org: patOrg, Pattern: *patDef}
+		out.Results = append(out.Results, newNodeValidationResult(patId, "", deployCompatible, &cc))
+
+	} else {
+		bPols := nv.DeploymentPolicies
+		if bPols == nil {
+			exPols, err := getBusinessPolicies(org, "")
+			if exchange.IsRetriesExceededError(err) {
+				return errorhandler(NewServiceUnavailableError(fmt.Sprintf("Unable to get the deployment policies of %v, the exchange cannot be reached. Give the deployment policies in deploymentPolicies to validate the node offline. %v", org, err))), nil
+			} else if err != nil {
+				return errorhandler(NewSystemError(fmt.Sprintf("Unable to get the deployment policies of %v from the exchange, error %v", org, err))), nil
+			}
+			bPols = make(map[string]businesspolicy.BusinessPolicy)
+			for id, exPol := range exPols {
+				bPols[id] = exPol.GetBusinessPolicy()
+			}
+		}
+
+		ids := make([]string, 0, len(bPols))
+		for id := range bPols {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			bPol := bPols[id]
+			cc := ccInput
+			cc.BusinessPolId = id
+			cc.BusinessPolicy = &bPol
+			out.Results = append(out.Results, newNodeValidationResult("", id, deployCompatible, &cc))
+		}
+	}
+
+	for _, r := range out.Results {
+		if len(r.Services) != 0 {
+			out.Compatible = true
+		}
+	}
+	return false, out
+}
+
+// Split the services of a compatibility check into the ones that would be deployed and the others.
+func newNodeValidationResult(pattern string, bPolId string, deployCompatible DeployCompatibleHandler, ccInput *compcheck.CompCheck) NodeValidationResult {
+	result := NodeValidationResult{Pattern: pattern, DeploymentPolicy: bPolId, Services: []string{}}
+
+	ccOutput, err := deployCompatible(ccInput)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	msgCompatible := i18n.GetMessagePrinter().Sprintf("Compatible")
+	for sId, reason := range ccOutput.Reason {
+		if reason == msgCompatible {
+			result.Services = append(result.Services, sId)
+		} else {
+			if result.Incompatible == nil {
+				result.Incompatible = make(map[string]string)
+			}
+			result.Incompatible[sId] = reason
+		}
+	}
+	sort.Strings(result.Services)
+	return result
+}
+
+// The exchange access of the node validation. Once the exchange cannot be reached, the service definitions are taken
+// from the local service cache.
+type validationExchange struct {
+	ec      exchange.ExchangeContext
+	db      *bolt.DB
+	offline bool
+}
+
+func (v *validationExchange) getService(url string, org string, version string, arch string) (*exchange.ServiceDefinition, string, error) {
+	if !v.offline {
+		sDef, sId, err := exchange.GetService(v.ec, url, org, version, arch)
+		if !exchange.IsRetriesExceededError(err) {
+			return sDef, sId, err
+		}
+		v.offline = true
+	}
+
+	if sDef, sId, err := exchange.GetServiceFromLocalCache(v.db, url, org, version, arch); err != nil {
+		return nil, "", err
+	} else if sDef == nil {
+		return nil, "", errors.New(fmt.Sprintf("service %v/%v version %v arch %v is not in the local service cache and the exchange cannot be reached", org, url, version, arch))
+	} else {
+		return sDef, sId, nil
+	}
+}
+
+func (v *validationExchange) getSelectedServices(url string, org string, version string, arch string) (map[string]exchange.ServiceDefinition, error) {
+	if !v.offline {
+		sDefs, err := exchange.GetSelectedServices(v.ec, url, org, version, arch)
+		if !exchange.IsRetriesExceededError(err) {
+			return sDefs, err
+		}
+		v.offline = true
+	}
+
+	sDef, sId, err := v.getService(url, org, version, arch)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]exchange.ServiceDefinition{sId: *sDef}, nil
+}
+
+func (v *validationExchange) getServiceDefResolver(url string, org string, version string, arch string) (*policy.APISpecList, map[string]exchange.ServiceDefinition, *exchange.ServiceDefinition, string, error) {
+	return exchange.ServiceDefResolver(url, org, version, arch, v.getService)
+}
+
+func (v *validationExchange) deployCompatible(ccInput *compcheck.CompCheck) (*compcheck.CompCheckOutput, error) {
+	return compcheck.DeployCompatibleWithHandlers(exchange.GetHTTPDeviceHandler(v.ec), exchange.GetHTTPNodePolicyHandler(v.ec),
+		exchange.GetHTTPBusinessPoliciesHandler(v.ec), exchange.GetHTTPExchangePatternHandler(v.ec), exchange.GetHTTPServicePolicyHandler(v.ec),
+		v.getService, v.getServiceDefResolver, v.getSelectedServices, exchange.GetHTTPVaultSecretExistsHandler(v.ec), "",
+		ccInput, true, i18n.GetMessagePrinter())
+}
@@ -0,0 +1,159 @@
+//go:build unit
+// +build unit
+
+package api
+
+import (
+	"errors"
+	"github.com/open-horizon/anax/businesspolicy"
+	"github.com/open-horizon/anax/compcheck"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/i18n"
+	"testing"
+)
+
+// The deploy compatibility handler returns the given reasons for every check.
+func getDummyDeployCompatible(reasons map[string]string, checked *[]*compcheck.CompCheck) DeployCompatibleHandler {
+	return func(ccInput *compcheck.CompCheck) (*compcheck.CompCheckOutput, error) {
+		*checked = append(*checked, ccInput)
+		return &compcheck.CompCheckOutput{Compatible: true, Reason: reasons}, nil
+	}
+}
+
+func getFailingPatternHandler(err error) exchange.PatternHandler {
+	return func(org string, pattern string) (map[string]exchange.Pattern, error) {
+		return nil, err
+	}
+}
+
+func getFailingBusinessPoliciesHandler(err error) exchange.BusinessPoliciesHandler {
+	return func(org string, policy_id string) (map[string]exchange.ExchangeBusinessPolicy, error) {
+		return nil, err
+	}
+}
+
+// Validate a node that is not registered with the pattern definition in the input.
+func Test_ValidateNode_pattern(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	org := "myorg"
+	pattern := "mypattern"
+	nv := &NodeValidation{
+		Node:              &HorizonDevice{Org: &org, Pattern: &pattern},
+		PatternDefinition: &exchange.Pattern{Services: []exchange.ServiceReference{{ServiceURL: "svc1", ServiceOrg: org}}},
+	}
+
+	reasons := map[string]string{
+		"myorg/svc1_1.0.0_amd64": i18n.GetMessagePrinter().Sprintf("Compatible"),
+		"myorg/svc1_1.0.0_arm":   "Architecture does not match.",
+	}
+	checked := []*compcheck.CompCheck{}
+
+	var myError error
+	errorHandler := GetPassThroughErrorHandler(&myError)
+	errHandled, out := ValidateNode(nv, errorHandler, getFailingPatternHandler(errors.New("should not be called")), getFailingBusinessPoliciesHandler(errors.New("should not be called")), getDummyDeployCompatible(reasons, &checked), db)
+
+	if errHandled {
+		t.Errorf("unexpected error %v", myError)
+	} else if out == nil || !out.Compatible || len(out.Results) != 1 {
+		t.Errorf("wrong output %v", out)
+	} else if out.Results[0].Pattern != "myorg/mypattern" || len(out.Results[0].Services) != 1 || out.Results[0].Services[0] != "myorg/svc1_1.0.0_amd64" {
+		t.Errorf("wrong result %v", out.Results[0])
+	} else if len(out.Results[0].Incompatible) != 1 || out.Results[0].Incompatible["myorg/svc1_1.0.0_arm"] == "" {
+		t.Errorf("wrong incompatible services %v", out.Results[0].Incompatible)
+	} else if len(checked) != 1 || checked[0].NodeOrg != org || checked[0].Pattern == nil || checked[0].NodePolicy == nil {
+		t.Errorf("wrong compatibility check input %v", checked)
+	}
+}
+
+// Validate a node that is not registered against the deployment policies in the input.
+func Test_ValidateNode_policy(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	org := "myorg"
+	nv := &NodeValidation{
+		Node: &HorizonDevice{Org: &org},
+		DeploymentPolicies: map[string]businesspolicy.BusinessPolicy{
+			"myorg/pol2": businesspolicy.BusinessPolicy{Service: businesspolicy.ServiceRef{Name: "svc2", Org: org}},
+			"myorg/pol1": businesspolicy.BusinessPolicy{Service: businesspolicy.ServiceRef{Name: "svc1", Org: org}},
+		},
+	}
+
+	reasons := map[string]string{"myorg/svc1_1.0.0_amd64": "Policy does not match."}
+	checked := []*compcheck.CompCheck{}
+
+	var myError error
+	errorHandler := GetPassThroughErrorHandler(&myError)
+	errHandled, out := ValidateNode(nv, errorHandler, getFailingPatternHandler(errors.New("should not be called")), getFailingBusinessPoliciesHandler(errors.New("should not be called")), getDummyDeployCompatible(reasons, &checked), db)
+
+	if errHandled {
+		t.Errorf("unexpected error %v", myError)
+	} else if out == nil || out.Compatible || len(out.Results) != 2 {
+		t.Errorf("wrong output %v", out)
+	} else if out.Results[0].DeploymentPolicy != "myorg/pol1" || out.Results[1].DeploymentPolicy != "myorg/pol2" {
+		t.Errorf("results are not sorted %v", out.Results)
+	} else if len(out.Results[0].Services) != 0 || len(out.Results[0].Incompatible) != 1 {
+		t.Errorf("wrong result %v", out.Results[0])
+	} else if len(checked) != 2 || checked[0].BusinessPolicy == nil {
+		t.Errorf("wrong compatibility check input %v", checked)
+	}
+}
+
+// The organization is required when the node is not registered.
+func Test_ValidateNode_noorg(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	checked := []*compcheck.CompCheck{}
+
+	var myError error
+	errorHandler := GetPassThroughErrorHandler(&myError)
+	errHandled, out := ValidateNode(&NodeValidation{}, errorHandler, getFailingPatternHandler(nil), getFailingBusinessPoliciesHandler(nil), getDummyDeployCompatible(nil, &checked), db)
+
+	if !errHandled {
+		t.Errorf("expected an error, got %v", out)
+	} else if _, ok := myError.(*APIUserInputError); !ok {
+		t.Errorf("wrong error type %T: %v", myError, myError)
+	} else if len(checked) != 0 {
+		t.Errorf("no compatibility check expected, got %v", checked)
+	}
+}
+
+// The pattern cannot be fetched when the exchange cannot be reached.
+func Test_ValidateNode_offline(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	org := "myorg"
+	pattern := "mypattern"
+	nv := &NodeValidation{Node: &HorizonDevice{Org: &org, Pattern: &pattern}}
+	checked := []*compcheck.CompCheck{}
+
+	var myError error
+	errorHandler := GetPassThroughErrorHandler(&myError)
+	errHandled, out := ValidateNode(nv, errorHandler, getFailingPatternHandler(exchange.NewRetriesExceededError(1, errors.New("exchange is down"))), getFailingBusinessPoliciesHandler(nil), getDummyDeployCompatible(nil, &checked), db)
+
+	if !errHandled {
+		t.Errorf("expected an error, got %v", out)
+	} else if _, ok := myError.(*ServiceUnavailableError); !ok {
+		t.Errorf("wrong error type %T: %v", myError, myError)
+	}
+}
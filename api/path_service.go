@@ -57,7 +57,7 @@ func FindServicesForOutput(pm *policy.PolicyManager,
 		if msinst.IsArchived() {
 			wrap.Instances[archivedKey] = append(wrap.Instances[archivedKey], NewMicroserviceInstanceOutput(*mi, nil))
 		} else {
-			containers, err := GetMicroserviceContainers(config.Edge.DockerEndpoint, mi)
+			containers, err := GetMicroserviceContainers(config, mi)
 			if err != nil {
 				return nil, errors.New(fmt.Sprintf("unable to get docker container info, error %v", err))
 			}
@@ -91,9 +91,41 @@ func FindServicesForOutput(pm *policy.PolicyManager,
 	return wrap, nil
 }
 
+// Page, filter and select the fields of the service configurations, instances and definitions. The list options are
+// applied to each list separately, the time range is matched against the creation time of the service instances.
+func SelectServicesForOutput(services *AllServices, opts *ListOptions, selections map[string][]string, errorhandler ErrorHandler) (bool, map[string]interface{}) {
+
+	selectors, err := persistence.ConvertToSelectors(selections)
+	if err != nil {
+		return errorhandler(NewAPIUserInputError(fmt.Sprintf("Error converting the selections into Selectors: %v", err), "selection")), nil
+	}
+
+	config, err := opts.Apply(services.Config, "", selectors)
+	if err != nil {
+		return errorhandler(NewSystemError(fmt.Sprintf("Error selecting the service configurations for output, error %v", err))), nil
+	}
+	instances, err := opts.ApplyToEach(services.Instances, "instance_creation_time", selectors)
+	if err != nil {
+		return errorhandler(NewSystemError(fmt.Sprintf("Error selecting the service instances for output, error %v", err))), nil
+	}
+	definitions, err := opts.ApplyToEach(services.Definitions, "", selectors)
+	if err != nil {
+		return errorhandler(NewSystemError(fmt.Sprintf("Error selecting the service definitions for output, error %v", err))), nil
+	}
+
+	return false, map[string]interface{}{"config": config, "instances": instances, "definitions": definitions}
+}
+
 // Get docker container metadata from the docker API for microservice containers
-func GetMicroserviceContainers(dockerEndpoint string, msinst *persistence.MicroserviceInstance) ([]dockerclient.APIContainers, error) {
-	if client, err := dockerclient.NewClient(dockerEndpoint); err != nil {
+func GetMicroserviceContainers(cfg *config.HorizonConfig, msinst *persistence.MicroserviceInstance) ([]dockerclient.APIContainers, error) {
+	dockerEndpoint := cfg.Edge.DockerEndpoint
+	if cfg.IsContainerdRuntime() {
+		if containers, err := container.ListContainerdContainers(cfg, true); err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to list containerd containers from %v, error %v", cfg.GetContainerdAddress(), err))
+		} else {
+			return filterMicroserviceContainers(containers, msinst), nil
+		}
+	} else if client, err := dockerclient.NewClient(dockerEndpoint); err != nil {
 		return nil, errors.New(fmt.Sprintf("unable to create docker client from %v, error %v", dockerEndpoint, err))
 	} else {
 		opts := dockerclient.ListContainersOptions{
@@ -103,18 +135,21 @@ func GetMicroserviceContainers(dockerEndpoint string, msinst *persistence.Micros
 		if containers, err := client.ListContainers(opts); err != nil {
 			return nil, errors.New(fmt.Sprintf("unable to list docker containers from %v, error %v", dockerEndpoint, err))
 		} else {
-			ret := make([]dockerclient.APIContainers, 0, 10)
-
-			// Iterate through containers looking for lable 'openhorizon.anax.agreement_id' that
-			// matches the microservice instance key.
-			for _, c := range containers {
-				if agid, exists := c.Labels[container.LABEL_PREFIX+".agreement_id"]; exists {
-					if agid == msinst.GetKey() {
-						ret = append(ret, c)
-					}
-				}
+			return filterMicroserviceContainers(containers, msinst), nil
+		}
+	}
+}
+
+// Iterate through containers looking for lable 'openhorizon.anax.agreement_id' that
+// matches the microservice instance key.
+func filterMicroserviceContainers(containers []dockerclient.APIContainers, msinst *persistence.MicroserviceInstance) []dockerclient.APIContainers {
+	ret := make([]dockerclient.APIContainers, 0, 10)
+	for _, c := range containers {
+		if agid, exists := c.Labels[container.LABEL_PREFIX+".agreement_id"]; exists {
+			if agid == msinst.GetKey() {
+				ret = append(ret, c)
 			}
-			return ret, nil
 		}
 	}
+	return ret
 }
@@ -438,7 +438,7 @@ func CreateService(service *Service,
 	}
 
 	if from_user && len(userInput) > 0 {
-		if err := exchangesync.PatchNodeUserInput(pDevice, db, userInput, getDevice, patchDevice); err != nil {
+		if _, err := exchangesync.PatchNodeUserInput(pDevice, db, userInput, getDevice, patchDevice); err != nil {
 			return errorhandler(NewSystemError(fmt.Sprintf("Failed to add the user input %v to node. %v", userInput, err))), nil, nil
 		}
 	}
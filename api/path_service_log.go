@@ -0,0 +1,85 @@
+package api
+
+import (
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/open-horizon/anax/abstractprotocol"
+	"github.com/open-horizon/anax/kube_operator"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Returns the log options of the service/log query parameters. The logs of all the containers are returned when the
+// container is not given, all the records when neither since nor tail is given.
+func ParseServiceLogOptions(query url.Values) (*kube_operator.LogOptions, error) {
+	opts := &kube_operator.LogOptions{Container: query.Get("container"), Tail: -1}
+
+	if follow := query.Get("follow"); follow != "" {
+		if b, err := strconv.ParseBool(follow); err != nil {
+			return nil, NewAPIUserInputError(fmt.Sprintf("The follow parameter must be true or false, error %v", err), "follow")
+		} else {
+			opts.Follow = b
+		}
+	}
+	if since := query.Get("since"); since != "" {
+		if t, err := time.Parse(time.RFC3339, since); err != nil {
+			return nil, NewAPIUserInputError(fmt.Sprintf("The since parameter must be an RFC 3339 timestamp, error %v", err), "since")
+		} else {
+			opts.Since = t
+		}
+	}
+	if tail := query.Get("tail"); tail != "" {
+		if n, err := strconv.Atoi(tail); err != nil || n < 0 {
+			return nil, NewAPIUserInputError(fmt.Sprintf("The tail parameter must be a number of lines, got %v", tail), "tail")
+		} else {
+			opts.Tail = n
+		}
+	}
+	return opts, nil
+}
+
+// Returns the operator deployment and the requested namespace of a top level service instance on a cluster, the
+// instance id is the agreement id of the service.
+func FindServiceLogDeployment(db *bolt.DB, instanceId string) (*persistence.KubeDeploymentConfig, string, error) {
+	if instanceId == "" {
+		return nil, "", NewAPIUserInputError("Please specify the service instance.", "instance")
+	}
+
+	msi, err := persistence.FindMicroserviceInstanceWithKey(db, instanceId)
+	if err != nil {
+		return nil, "", NewSystemError(fmt.Sprintf("Unable to read the service instance %v from the database, error %v", instanceId, err))
+	} else if msi == nil || msi.IsArchived() || !msi.IsTopLevelService() {
+		return nil, "", NewNotFoundError(fmt.Sprintf("The service instance %v is not running on the node.", instanceId), "instance")
+	}
+
+	msdef, err := persistence.FindMicroserviceDefWithKey(db, msi.MicroserviceDefId)
+	if err != nil {
+		return nil, "", NewSystemError(fmt.Sprintf("Unable to read the service definition %v from the database, error %v", msi.MicroserviceDefId, err))
+	} else if msdef == nil {
+		return nil, "", NewNotFoundError(fmt.Sprintf("The service definition of instance %v is not on the node.", instanceId), "instance")
+	}
+	kd, err := persistence.GetKubeDeployment(msdef.ClusterDeployment)
+	if err != nil {
+		return nil, "", NewAPIUserInputError(fmt.Sprintf("The service instance %v is not deployed as a kubernetes operator.", instanceId), "instance")
+	}
+
+	ags, err := persistence.FindEstablishedAgreementsAllProtocols(db, policy.AllAgreementProtocols(), []persistence.EAFilter{persistence.UnarchivedEAFilter(), persistence.IdEAFilter(instanceId)})
+	if err != nil {
+		return nil, "", NewSystemError(fmt.Sprintf("Unable to read the agreement %v from the database, error %v", instanceId, err))
+	} else if len(ags) != 1 {
+		return nil, "", NewNotFoundError(fmt.Sprintf("The agreement of service instance %v is not on the node.", instanceId), "instance")
+	}
+
+	proposal, err := abstractprotocol.DemarshalProposal(ags[0].Proposal)
+	if err != nil {
+		return nil, "", NewSystemError(fmt.Sprintf("Unable to demarshal the proposal of agreement %v, error %v", instanceId, err))
+	}
+	tcPolicy, err := policy.DemarshalPolicy(proposal.TsAndCs())
+	if err != nil {
+		return nil, "", NewSystemError(fmt.Sprintf("Unable to demarshal the TsAndCs of agreement %v, error %v", instanceId, err))
+	}
+	return kd, tcPolicy.ClusterNamespace, nil
+}
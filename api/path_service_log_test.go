@@ -0,0 +1,33 @@
+//go:build unit
+// +build unit
+
+package api
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_ParseServiceLogOptions(t *testing.T) {
+	if opts, err := ParseServiceLogOptions(url.Values{}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if opts.Follow || !opts.Since.IsZero() || opts.Tail != -1 || opts.Container != "" {
+		t.Errorf("unexpected default options %v", opts)
+	}
+
+	query := url.Values{"container": {"c1"}, "follow": {"true"}, "since": {"2024-01-02T09:30:00Z"}, "tail": {"20"}}
+	if opts, err := ParseServiceLogOptions(query); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if !opts.Follow || !opts.Since.Equal(time.Date(2024, time.January, 2, 9, 30, 0, 0, time.UTC)) || opts.Tail != 20 || opts.Container != "c1" {
+		t.Errorf("unexpected options %v", opts)
+	}
+
+	for _, query := range []url.Values{{"follow": {"sure"}}, {"since": {"10m"}}, {"tail": {"-1"}}, {"tail": {"many"}}} {
+		if _, err := ParseServiceLogOptions(query); err == nil {
+			t.Errorf("expected an error for query %v", query)
+		} else if _, ok := err.(*APIUserInputError); !ok {
+			t.Errorf("expected an input error for query %v, got %v", query, err)
+		}
+	}
+}
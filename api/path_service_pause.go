@@ -0,0 +1,89 @@
+package api
+
+import (
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/persistence"
+)
+
+// Pause or resume the given service on the node. A paused service keeps its agreements, but its containers are stopped
+// (or its operator deployment is scaled to zero on a cluster) until it is resumed. It returns the event that tells the
+// governance worker to stop or start the running instances of the service, nil if there is nothing to change.
+func ChangeServicePause(service *ServicePause, pause bool, nodeType string, errorhandler ErrorHandler, db *bolt.DB) (bool, *events.ServicePauseMessage) {
+
+	// input error checking
+	if service.Url == "" {
+		return errorhandler(NewAPIUserInputError("Please specify the service url.", "url")), nil
+	} else if service.Org == "" {
+		return errorhandler(NewAPIUserInputError("Please specify the service organization.", "org")), nil
+	}
+	sId := cutil.FormOrgSpecUrl(service.Url, service.Org)
+
+	paused, err := persistence.FindPausedService(db, service.Org, service.Url)
+	if err != nil {
+		return errorhandler(NewSystemError(fmt.Sprintf("Unable to read the paused service %v from the database, error %v", sId, err))), nil
+	}
+
+	if !pause {
+		if paused == nil {
+			return errorhandler(NewAPIUserInputError(fmt.Sprintf("The service %v is not paused.", sId), "url, org")), nil
+		} else if err := persistence.DeletePausedService(db, service.Org, service.Url); err != nil {
+			return errorhandler(NewSystemError(fmt.Sprintf("Unable to delete the paused service %v from the database, error %v", sId, err))), nil
+		}
+		glog.V(3).Infof(apiLogString(fmt.Sprintf("Resumed service %v.", sId)))
+		return false, events.NewServicePauseMessage(events.SERVICE_RESUMED, service.Url, service.Org)
+	} else if paused != nil {
+		glog.V(3).Infof(apiLogString(fmt.Sprintf("Service %v is already paused.", sId)))
+		return false, nil
+	}
+
+	// only the services that are running on the node can be paused
+	msInsts, err := persistence.FindMicroserviceInstances(db, []persistence.MIFilter{persistence.UnarchivedMIFilter(), persistence.NotCleanedUpMIFilter()})
+	if err != nil {
+		return errorhandler(NewSystemError(fmt.Sprintf("Unable to read the service instances from the database, error %v", err))), nil
+	}
+
+	found := false
+	for _, msi := range msInsts {
+		if msi.SpecRef != service.Url || msi.Org != service.Org {
+			continue
+		}
+		found = true
+
+		if msdef, err := persistence.FindMicroserviceDefWithKey(db, msi.MicroserviceDefId); err != nil {
+			return errorhandler(NewSystemError(fmt.Sprintf("Unable to read the service definition %v from the database, error %v", msi.MicroserviceDefId, err))), nil
+		} else if msdef != nil && !isPausableDeployment(msdef, nodeType) {
+			return errorhandler(NewAPIUserInputError(fmt.Sprintf("The service %v cannot be paused, only services deployed as containers or as a kubernetes operator can be paused.", sId), "url, org")), nil
+		}
+	}
+
+	if !found {
+		return errorhandler(NewAPIUserInputError(fmt.Sprintf("The service %v is not running on the node.", sId), "url, org")), nil
+	} else if err := persistence.SavePausedService(db, persistence.NewPausedService(service.Org, service.Url)); err != nil {
+		return errorhandler(NewSystemError(fmt.Sprintf("Unable to save the paused service %v in the database, error %v", sId, err))), nil
+	}
+
+	glog.V(3).Infof(apiLogString(fmt.Sprintf("Paused service %v.", sId)))
+	return false, events.NewServicePauseMessage(events.SERVICE_PAUSED, service.Url, service.Org)
+}
+
+// The services deployed as WebAssembly modules, host processes or helm charts are not stopped by the pause.
+func isPausableDeployment(msdef *persistence.MicroserviceDefinition, nodeType string) bool {
+	if nodeType == persistence.DEVICE_TYPE_CLUSTER {
+		_, err := persistence.GetKubeDeployment(msdef.ClusterDeployment)
+		return err == nil
+	}
+
+	deployment, _ := msdef.GetDeployment()
+	if _, err := persistence.GetWasmDeployment(deployment); err == nil {
+		return false
+	} else if _, err := persistence.GetHostProcessDeployment(deployment); err == nil {
+		return false
+	} else if _, err := persistence.GetHelmDeployment(deployment); err == nil {
+		return false
+	}
+	return true
+}
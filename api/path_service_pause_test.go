@@ -0,0 +1,84 @@
+//go:build unit
+// +build unit
+
+package api
+
+import (
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/persistence"
+	"testing"
+)
+
+// Verify that only the services that run on the node as containers can be paused, and that only paused services can be resumed.
+func Test_ChangeServicePause(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	services := map[string]string{
+		"netspeed": `{"services":{"netspeed":{"image":"netspeed:1.0"}}}`,
+		"wasmsvc":  `{"wasm_module":"AGFzbQ=="}`,
+	}
+	for url, deployment := range services {
+		msdef := &persistence.MicroserviceDefinition{Id: url + "-def", SpecRef: url, Org: "myorg", Version: "1.0.0", Arch: "amd64", Deployment: deployment}
+		if err := persistence.SaveOrUpdateMicroserviceDef(db, msdef); err != nil {
+			t.Fatalf("failed to save service definition, error %v", err)
+		} else if _, err := persistence.NewMicroserviceInstance(db, url, "myorg", "1.0.0", msdef.Id, []persistence.ServiceInstancePathElement{}, false); err != nil {
+			t.Fatalf("failed to save service instance, error %v", err)
+		}
+	}
+
+	var myError error
+	errorhandler := GetPassThroughErrorHandler(&myError)
+
+	// the service must be given
+	if errHandled, msg := ChangeServicePause(&ServicePause{Url: "netspeed"}, true, persistence.DEVICE_TYPE_DEVICE, errorhandler, db); !errHandled || msg != nil {
+		t.Errorf("the org should be required, but got %v", msg)
+	} else if _, ok := myError.(*APIUserInputError); !ok {
+		t.Errorf("should have returned an input error, but got %v", myError)
+	}
+
+	// the service must run on the node
+	myError = nil
+	if errHandled, msg := ChangeServicePause(&ServicePause{Url: "gps", Org: "myorg"}, true, persistence.DEVICE_TYPE_DEVICE, errorhandler, db); !errHandled || msg != nil {
+		t.Errorf("a service that is not running should not be paused, but got %v", msg)
+	}
+
+	// a WebAssembly module cannot be paused
+	myError = nil
+	if errHandled, msg := ChangeServicePause(&ServicePause{Url: "wasmsvc", Org: "myorg"}, true, persistence.DEVICE_TYPE_DEVICE, errorhandler, db); !errHandled || msg != nil {
+		t.Errorf("a wasm service should not be paused, but got %v", msg)
+	} else if persistence.IsServicePaused(db, "myorg", "wasmsvc") {
+		t.Errorf("the wasm service should not be recorded as paused")
+	}
+
+	// a service that is not paused cannot be resumed
+	myError = nil
+	if errHandled, msg := ChangeServicePause(&ServicePause{Url: "netspeed", Org: "myorg"}, false, persistence.DEVICE_TYPE_DEVICE, errorhandler, db); !errHandled || msg != nil {
+		t.Errorf("a service that is not paused should not be resumed, but got %v", msg)
+	}
+
+	// pause the service, then pause it again
+	myError = nil
+	if errHandled, msg := ChangeServicePause(&ServicePause{Url: "netspeed", Org: "myorg"}, true, persistence.DEVICE_TYPE_DEVICE, errorhandler, db); errHandled {
+		t.Errorf("should not return error, but got %v", myError)
+	} else if msg == nil || msg.Event().Id != events.SERVICE_PAUSED || msg.Url != "netspeed" || msg.Org != "myorg" {
+		t.Errorf("wrong pause message %v", msg)
+	} else if !persistence.IsServicePaused(db, "myorg", "netspeed") {
+		t.Errorf("the service should be recorded as paused")
+	} else if errHandled, msg := ChangeServicePause(&ServicePause{Url: "netspeed", Org: "myorg"}, true, persistence.DEVICE_TYPE_DEVICE, errorhandler, db); errHandled || msg != nil {
+		t.Errorf("pausing a paused service should do nothing, but got %v %v", msg, myError)
+	}
+
+	// resume the service
+	if errHandled, msg := ChangeServicePause(&ServicePause{Url: "netspeed", Org: "myorg"}, false, persistence.DEVICE_TYPE_DEVICE, errorhandler, db); errHandled {
+		t.Errorf("should not return error, but got %v", myError)
+	} else if msg == nil || msg.Event().Id != events.SERVICE_RESUMED {
+		t.Errorf("wrong resume message %v", msg)
+	} else if persistence.IsServicePaused(db, "myorg", "netspeed") {
+		t.Errorf("the service should not be recorded as paused")
+	}
+}
@@ -0,0 +1,35 @@
+//go:build linux
+// +build linux
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"net"
+)
+
+// Returns the uid of the process at the other end of a unix socket connection.
+func getPeerUid(c net.Conn) (uint32, error) {
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return 0, errors.New(fmt.Sprintf("the connection from %v is not a unix socket connection", c.RemoteAddr()))
+	}
+
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var cred *unix.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return 0, err
+	} else if credErr != nil {
+		return 0, credErr
+	}
+	return cred.Uid, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package api
+
+import (
+	"errors"
+	"net"
+)
+
+func getPeerUid(c net.Conn) (uint32, error) {
+	return 0, errors.New("the peer credentials of the API clients are only available on linux")
+}
@@ -540,6 +540,7 @@ const CANCEL_SERVICE_SUSPENDED = 119
 const CANCEL_NODE_USERINPUT_CHANGED = 120
 const CANCEL_NODE_PATTERN_CHANGED = 121
 const CANCEL_FAILED_AGREEMENT_VERIFY = 122
+const CANCEL_PREEMPTED = 123
 
 // These constants represent consumer cancellation reason codes
 // const AB_CANCEL_NOT_FINALIZED_TIMEOUT = 200  // xc8
@@ -579,6 +580,7 @@ func DecodeReasonCode(code uint64) string {
 		CANCEL_SERVICE_SUSPENDED:        "service suspended",
 		CANCEL_NODE_USERINPUT_CHANGED:   "node user input changed",
 		CANCEL_NODE_PATTERN_CHANGED:     "node pattern changed",
+		CANCEL_PREEMPTED:                "preempted by a higher priority deployment policy",
 		// AB_CANCEL_NOT_FINALIZED_TIMEOUT: "agreement bot never detected agreement on the blockchain",
 		AB_CANCEL_NO_REPLY:         "agreement bot never received reply to proposal",
 		AB_CANCEL_NEGATIVE_REPLY:   "agreement bot received negative reply",
@@ -598,3 +600,17 @@ func DecodeReasonCode(code uint64) string {
 		return reasonString
 	}
 }
+
+// IsFailureReasonCode returns true when the agreement was cancelled because something failed on the node or in the agreement
+// protocol, as opposed to a change requested by a user or made to a policy.
+func IsFailureReasonCode(code uint64) bool {
+	switch code {
+	case CANCEL_CONTAINER_FAILURE, CANCEL_NOT_EXECUTED_TIMEOUT, CANCEL_NO_REPLY_ACK, CANCEL_MICROSERVICE_FAILURE,
+		CANCEL_WL_IMAGE_LOAD_FAILURE, CANCEL_MS_IMAGE_LOAD_FAILURE, CANCEL_MS_IMAGE_FETCH_FAILURE, CANCEL_MS_DOWNGRADE_REQUIRED,
+		CANCEL_IMAGE_DATA_ERROR, CANCEL_IMAGE_FETCH_FAILURE, CANCEL_IMAGE_FETCH_AUTH_FAILURE, CANCEL_IMAGE_SIG_VERIF_FAILURE,
+		CANCEL_FAILED_AGREEMENT_VERIFY, AB_CANCEL_NO_REPLY, AB_CANCEL_NEGATIVE_REPLY, AB_CANCEL_NO_DATA_RECEIVED,
+		AB_CANCEL_DISCOVERED, AB_CANCEL_NODE_HEARTBEAT, AB_CANCEL_AG_MISSING, AB_CANCEL_UPDATE_REJECTED:
+		return true
+	}
+	return false
+}
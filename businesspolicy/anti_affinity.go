@@ -0,0 +1,39 @@
+package businesspolicy
+
+import (
+	"fmt"
+)
+
+// The anti-affinity property that spreads the service across the HA groups of the nodes instead of a node property.
+const ANTI_AFFINITY_HA_GROUP = "openhorizon.haGroup"
+
+// The anti-affinity of a deployment policy spreads the instances of the service across the nodes that share a value of
+// a node property, e.g. the site or the gateway of the node, so that redundant deployments do not all land on the first
+// matching nodes of the same site. It is evaluated by the agbot when it places the service on a node.
+type AntiAffinity struct {
+	Property    string `json:"property"`              // the node property whose value identifies the site, or openhorizon.haGroup for the HA group of the node
+	MaxPerValue int    `json:"maxPerValue,omitempty"` // the maximum number of instances of the service per value of the property, 1 if not set
+}
+
+func (a AntiAffinity) String() string {
+	return fmt.Sprintf("Property: %v, MaxPerValue: %v",
+		a.Property,
+		a.MaxPerValue)
+}
+
+func (a AntiAffinity) Validate() error {
+	if a.Property == "" {
+		return fmt.Errorf("antiAffinity must have a property")
+	} else if a.MaxPerValue < 0 {
+		return fmt.Errorf("antiAffinity maxPerValue must not be negative, it is %v", a.MaxPerValue)
+	}
+	return nil
+}
+
+// Returns the maximum number of instances of the service per value of the property.
+func (a AntiAffinity) GetMaxPerValue() int {
+	if a.MaxPerValue == 0 {
+		return 1
+	}
+	return a.MaxPerValue
+}
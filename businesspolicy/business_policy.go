@@ -23,10 +23,16 @@ type BusinessPolicy struct {
 	Constraints   externalpolicy.ConstraintExpression `json:"constraints,omitempty"`
 	UserInput     []policy.UserInput                  `json:"userInput,omitempty"`
 	SecretBinding []exchangecommon.SecretBinding      `json:"secretBinding,omitempty"` // The secret binding from service secret names to secret manager secret names.
+	Priority      int                                 `json:"priority,omitempty"`      // The agreements of policies with a lower priority are preempted when a node lacks capacity for this one. Higher values are more important.
+	Schedule      *DeploymentSchedule                 `json:"schedule,omitempty"`      // The maintenance windows in which the service can be deployed or changed on a node.
+	Fleets        []string                            `json:"fleets,omitempty"`        // The fleets of nodes the service is deployed to, by name in the org of the policy or org/name. Any node matching the constraints when empty.
+	Placement     externalpolicy.ConstraintExpression `json:"placement,omitempty"`     // Constraints on the dynamic state of the node, evaluated by the agbot when it places the service on a node.
+	AntiAffinity  *AntiAffinity                       `json:"antiAffinity,omitempty"`  // Limits the instances of the service on the nodes that share a value of a node property, e.g. their site.
+	Scoring       []policy.ScoringRule                `json:"scoring,omitempty"`       // Ranks the matching nodes, the agbot tries to place the service on the nodes with the highest score first.
 }
 
 func (w BusinessPolicy) String() string {
-	return fmt.Sprintf("Owner: %v, Label: %v, Description: %v, Service: %v, Properties: %v, Constraints: %v, UserInput: %v, SecretBinding: %v",
+	return fmt.Sprintf("Owner: %v, Label: %v, Description: %v, Service: %v, Properties: %v, Constraints: %v, UserInput: %v, SecretBinding: %v, Priority: %v, Schedule: %v, Fleets: %v, Placement: %v, AntiAffinity: %v, Scoring: %v",
 		w.Owner,
 		w.Label,
 		w.Description,
@@ -34,7 +40,13 @@ func (w BusinessPolicy) String() string {
 		w.Properties,
 		w.Constraints,
 		w.UserInput,
-		w.SecretBinding)
+		w.SecretBinding,
+		w.Priority,
+		w.Schedule,
+		w.Fleets,
+		w.Placement,
+		w.AntiAffinity,
+		w.Scoring)
 }
 
 type ServiceRef struct {
@@ -44,16 +56,18 @@ type ServiceRef struct {
 	ClusterNamespace string           `json:"clusterNamespace,omitempty"` // the namespace ths service will be deployed to.
 	ServiceVersions  []WorkloadChoice `json:"serviceVersions,omitempty"`  // a list of service version for rollback
 	NodeH            NodeHealth       `json:"nodeHealth"`                 // policy for determining when a node's health is violating its agreements
+	Rollout          *RolloutPolicy   `json:"rollout,omitempty"`          // policy for rolling out the highest priority service version in waves
 }
 
 func (w ServiceRef) String() string {
-	return fmt.Sprintf("Name: %v, Org: %v, Arch: %v, ClusterNamespace: %v, ServiceVersions: %v, NodeH: %v",
+	return fmt.Sprintf("Name: %v, Org: %v, Arch: %v, ClusterNamespace: %v, ServiceVersions: %v, NodeH: %v, Rollout: %v",
 		w.Name,
 		w.Org,
 		w.Arch,
 		w.ClusterNamespace,
 		w.ServiceVersions,
-		w.NodeH)
+		w.NodeH,
+		w.Rollout)
 }
 
 func (w ServiceRef) Validate() error {
@@ -70,6 +84,10 @@ func (w ServiceRef) Validate() error {
 			}
 		}
 	}
+
+	if w.Rollout != nil {
+		return w.Rollout.Validate(w.ServiceVersions)
+	}
 	return nil
 
 }
@@ -113,6 +131,79 @@ func (w WorkloadChoice) String() string {
 		w.Upgrade)
 }
 
+// The default waves of a rollout, the new service version goes to 1% of the nodes, then 10%, then all of them.
+var DEFAULT_ROLLOUT_WAVES = []int{1, 10, 100}
+
+// The rollout policy of the highest priority service version. The new version is deployed to a growing percentage of the
+// nodes that match the policy, one wave at a time, while the other nodes keep the next priority version. The rollout
+// starts when the policy changes and is halted, rolling the nodes back, when too many of the upgraded nodes failed to run
+// the new version and fell back to the next priority version.
+type RolloutPolicy struct {
+	Waves          []int   `json:"waves,omitempty"`          // the increasing percentages of the nodes that get the new version in each wave, the last one is 100
+	SoakTimeS      int     `json:"soakTime,omitempty"`       // the number of seconds a wave runs before the next wave starts
+	MaxFailureRate float64 `json:"maxFailureRate,omitempty"` // the percentage of the upgraded nodes that can fail before the rollout is halted
+}
+
+func (w RolloutPolicy) String() string {
+	return fmt.Sprintf("Waves: %v, SoakTimeS: %v, MaxFailureRate: %v",
+		w.Waves,
+		w.SoakTimeS,
+		w.MaxFailureRate)
+}
+
+// Return the waves of the rollout, the default waves if none are set.
+func (w RolloutPolicy) GetWaves() []int {
+	if len(w.Waves) == 0 {
+		return DEFAULT_ROLLOUT_WAVES
+	}
+	return w.Waves
+}
+
+// Return the percentage of the nodes that get the new version at the given time, for a rollout started at the given time.
+func (w RolloutPolicy) WavePercentage(started uint64, now uint64) int {
+	waves := w.GetWaves()
+	wave := 0
+	if now > started && w.SoakTimeS > 0 {
+		wave = int((now - started) / uint64(w.SoakTimeS))
+	} else if w.SoakTimeS == 0 {
+		wave = len(waves) - 1
+	}
+	if wave >= len(waves) {
+		wave = len(waves) - 1
+	}
+	return waves[wave]
+}
+
+// The rollout needs a new version and a version to keep the nodes on, so the service versions must have priorities.
+func (w RolloutPolicy) Validate(versions []WorkloadChoice) error {
+	if len(versions) < 2 {
+		return fmt.Errorf("rollout needs at least 2 service versions, the highest priority version is rolled out and the next one is kept on the other nodes")
+	}
+	for _, wc := range versions {
+		if wc.Priority.PriorityValue == 0 {
+			return fmt.Errorf("rollout needs a priority_value for every service version, version %v has none", wc.Version)
+		}
+	}
+
+	last := 0
+	for _, p := range w.Waves {
+		if p <= last || p > 100 {
+			return fmt.Errorf("rollout waves %v must be increasing percentages between 1 and 100", w.Waves)
+		}
+		last = p
+	}
+	if len(w.Waves) != 0 && last != 100 {
+		return fmt.Errorf("the last rollout wave must be 100, the waves are %v", w.Waves)
+	}
+
+	if w.SoakTimeS < 0 {
+		return fmt.Errorf("rollout soakTime %v cannot be negative", w.SoakTimeS)
+	} else if w.MaxFailureRate < 0 || w.MaxFailureRate > 100 {
+		return fmt.Errorf("rollout maxFailureRate %v must be a percentage between 0 and 100", w.MaxFailureRate)
+	}
+	return nil
+}
+
 type NodeHealth struct {
 	MissingHBInterval    int `json:"missing_heartbeat_interval,omitempty"` // How long a heartbeat can be missing until it is considered missing (in seconds)
 	CheckAgreementStatus int `json:"check_agreement_status,omitempty"`     // How often to check that the node agreement entry still exists in the exchange (in seconds)
@@ -158,6 +249,42 @@ func (b *BusinessPolicy) Validate() error {
 		}
 	}
 
+	if b.Priority < 0 {
+		return fmt.Errorf(msgPrinter.Sprintf("priority must not be negative, it is %v.", b.Priority))
+	}
+
+	if b.Schedule != nil {
+		if err := b.Schedule.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, fleet := range b.Fleets {
+		if parts := strings.Split(fleet, "/"); len(parts) > 2 || parts[len(parts)-1] == "" || parts[0] == "" {
+			return fmt.Errorf(msgPrinter.Sprintf("fleet %v must be a fleet name or org/name.", fleet))
+		}
+	}
+
+	// The placement constraints are not sent to the node, they are evaluated by the agbot against the properties of the
+	// node and the properties filled in from its dynamic state.
+	if len(b.Placement) != 0 {
+		if _, err := b.Placement.Validate(); err != nil {
+			return fmt.Errorf(msgPrinter.Sprintf("placement contains an invalid constraint: %v", err))
+		}
+	}
+
+	if b.AntiAffinity != nil {
+		if err := b.AntiAffinity.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, rule := range b.Scoring {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+
 	// Validate the Constraints expression by invoking the plugins.
 	if b != nil && len(b.Constraints) != 0 {
 		_, err := b.Constraints.Validate()
@@ -216,6 +343,8 @@ func (b *BusinessPolicy) GenPolicyFromBusinessPolicy(policyName string) (*policy
 
 	pol.MaxAgreements = DEFAULT_MAX_AGREEMENT
 
+	pol.Priority = b.Priority
+
 	// add default agreement protocol
 	newAGP := policy.AgreementProtocol_Factory(policy.BasicProtocol)
 	newAGP.Initialize()
@@ -209,6 +209,107 @@ func Test_Validate_Succeeded4(t *testing.T) {
 	}
 }
 
+func Test_Validate_Fleets(t *testing.T) {
+
+	bPolicy := BusinessPolicy{
+		Service: ServiceRef{
+			Name:            "cpu",
+			Org:             "mycomp",
+			Arch:            "amd64",
+			ServiceVersions: []WorkloadChoice{{Version: "1.0.0"}},
+		},
+		Fleets: []string{"stores", "otherorg/stores"},
+	}
+	if err := bPolicy.Validate(); err != nil {
+		t.Errorf("Validate should not have returned error: %v", err)
+	}
+
+	for _, fleet := range []string{"", "/stores", "otherorg/", "a/b/c"} {
+		bPolicy.Fleets = []string{fleet}
+		if err := bPolicy.Validate(); err == nil || !strings.Contains(err.Error(), "fleet") {
+			t.Errorf("Validate should have returned a fleet error for %v, got: %v", fleet, err)
+		}
+	}
+}
+
+func Test_Validate_Placement(t *testing.T) {
+
+	bPolicy := BusinessPolicy{
+		Service: ServiceRef{
+			Name:            "cpu",
+			Org:             "mycomp",
+			Arch:            "amd64",
+			ServiceVersions: []WorkloadChoice{{Version: "1.0.0"}},
+		},
+		Placement: externalpolicy.ConstraintExpression{"openhorizon.agreementCount < 5 && openhorizon.freeMemory >= 512"},
+	}
+	if err := bPolicy.Validate(); err != nil {
+		t.Errorf("Validate should not have returned error: %v", err)
+	}
+
+	bPolicy.Placement = externalpolicy.ConstraintExpression{"openhorizon.agreementCount < "}
+	if err := bPolicy.Validate(); err == nil || !strings.Contains(err.Error(), "placement") {
+		t.Errorf("Validate should have returned a placement error, got: %v", err)
+	}
+
+	// the placement constraints are only evaluated by the agbot, they are not part of the policy sent to the node
+	bPolicy.Placement = externalpolicy.ConstraintExpression{"openhorizon.agreementCount < 5"}
+	if pol, err := bPolicy.GenPolicyFromBusinessPolicy("mycomp/cpu"); err != nil {
+		t.Errorf("GenPolicyFromBusinessPolicy should not have returned error: %v", err)
+	} else if len(pol.Constraints) != 0 {
+		t.Errorf("the placement constraints should not be in the policy, got: %v", pol.Constraints)
+	}
+}
+
+func Test_Validate_AntiAffinity(t *testing.T) {
+
+	bPolicy := BusinessPolicy{
+		Service: ServiceRef{
+			Name:            "cpu",
+			Org:             "mycomp",
+			Arch:            "amd64",
+			ServiceVersions: []WorkloadChoice{{Version: "1.0.0"}},
+		},
+		AntiAffinity: &AntiAffinity{Property: "site"},
+	}
+	if err := bPolicy.Validate(); err != nil {
+		t.Errorf("Validate should not have returned error: %v", err)
+	} else if bPolicy.AntiAffinity.GetMaxPerValue() != 1 {
+		t.Errorf("the default maxPerValue should be 1, got %v", bPolicy.AntiAffinity.GetMaxPerValue())
+	}
+
+	bPolicy.AntiAffinity = &AntiAffinity{Property: ""}
+	if err := bPolicy.Validate(); err == nil || !strings.Contains(err.Error(), "antiAffinity") {
+		t.Errorf("Validate should have returned an antiAffinity error, got: %v", err)
+	}
+
+	bPolicy.AntiAffinity = &AntiAffinity{Property: ANTI_AFFINITY_HA_GROUP, MaxPerValue: -1}
+	if err := bPolicy.Validate(); err == nil || !strings.Contains(err.Error(), "maxPerValue") {
+		t.Errorf("Validate should have returned a maxPerValue error, got: %v", err)
+	}
+}
+
+func Test_Validate_Scoring(t *testing.T) {
+
+	bPolicy := BusinessPolicy{
+		Service: ServiceRef{
+			Name:            "cpu",
+			Org:             "mycomp",
+			Arch:            "amd64",
+			ServiceVersions: []WorkloadChoice{{Version: "1.0.0"}},
+		},
+		Scoring: []policy.ScoringRule{{Property: "openhorizon.freeMemory", Weight: 2}, {Property: "gpu", Weight: 1, Value: true}},
+	}
+	if err := bPolicy.Validate(); err != nil {
+		t.Errorf("Validate should not have returned error: %v", err)
+	}
+
+	bPolicy.Scoring = append(bPolicy.Scoring, policy.ScoringRule{Property: "model"})
+	if err := bPolicy.Validate(); err == nil || !strings.Contains(err.Error(), "weight") {
+		t.Errorf("Validate should have returned a weight error, got: %v", err)
+	}
+}
+
 func Test_GenPolicyFromBusinessPolicy_Simple(t *testing.T) {
 
 	wlc := WorkloadChoice{
@@ -321,3 +422,98 @@ func Test_GenPolicyFromBusinessPolicy_Complicated(t *testing.T) {
 		t.Errorf("Second user input variable value for service cpu should be val2 but got %v.", pPolicy.UserInput[0].Inputs[1].Value)
 	}
 }
+
+func Test_RolloutPolicy_Validate(t *testing.T) {
+
+	versions := []WorkloadChoice{
+		{Version: "1.0.1", Priority: WorkloadPriority{PriorityValue: 1, Retries: 1, RetryDurationS: 600}},
+		{Version: "1.0.0", Priority: WorkloadPriority{PriorityValue: 2, Retries: 1, RetryDurationS: 600}},
+	}
+
+	service := ServiceRef{
+		Name:            "cpu",
+		Org:             "mycomp",
+		Arch:            "amd64",
+		ServiceVersions: versions,
+		Rollout:         &RolloutPolicy{SoakTimeS: 3600, MaxFailureRate: 5},
+	}
+	if err := service.Validate(); err != nil {
+		t.Errorf("Validate should not have returned error: %v", err)
+	}
+
+	service.Rollout.Waves = []int{5, 50, 100}
+	if err := service.Validate(); err != nil {
+		t.Errorf("Validate should not have returned error: %v", err)
+	}
+
+	bad := map[string]RolloutPolicy{
+		"increasing":     {Waves: []int{10, 5, 100}},
+		"between 1":      {Waves: []int{0, 100}},
+		"must be 100":    {Waves: []int{1, 10, 50}},
+		"negative":       {SoakTimeS: -1},
+		"maxFailureRate": {MaxFailureRate: 101},
+	}
+	for msg, r := range bad {
+		service.Rollout = &r
+		if err := service.Validate(); err == nil {
+			t.Errorf("Validate should have returned error for %v", r)
+		} else if !strings.Contains(err.Error(), msg) {
+			t.Errorf("Wrong error string: %v", err)
+		}
+	}
+
+	service.Rollout = &RolloutPolicy{}
+	service.ServiceVersions = versions[:1]
+	if err := service.Validate(); err == nil || !strings.Contains(err.Error(), "at least 2 service versions") {
+		t.Errorf("Wrong error: %v", err)
+	}
+
+	service.ServiceVersions = []WorkloadChoice{{Version: "1.0.1"}, {Version: "1.0.0"}}
+	if err := service.Validate(); err == nil || !strings.Contains(err.Error(), "priority_value") {
+		t.Errorf("Wrong error: %v", err)
+	}
+}
+
+func Test_RolloutPolicy_WavePercentage(t *testing.T) {
+
+	r := RolloutPolicy{SoakTimeS: 100}
+	for now, expected := range map[uint64]int{1000: 1, 1099: 1, 1100: 10, 1250: 100, 5000: 100} {
+		if p := r.WavePercentage(1000, now); p != expected {
+			t.Errorf("wave percentage at %v should be %v, is %v", now, expected, p)
+		}
+	}
+
+	r.Waves = []int{25, 100}
+	if p := r.WavePercentage(1000, 1050); p != 25 {
+		t.Errorf("wave percentage should be 25, is %v", p)
+	}
+
+	r.SoakTimeS = 0
+	if p := r.WavePercentage(1000, 1000); p != 100 {
+		t.Errorf("wave percentage without soak time should be 100, is %v", p)
+	}
+}
+
+func Test_BusinessPolicy_Priority(t *testing.T) {
+
+	service := ServiceRef{
+		Name:            "cpu",
+		Org:             "mycomp",
+		Arch:            "amd64",
+		ServiceVersions: []WorkloadChoice{{Version: "1.0.0"}},
+	}
+
+	bPolicy := BusinessPolicy{Service: service, Priority: -1}
+	if err := bPolicy.Validate(); err == nil {
+		t.Errorf("Validate should have returned error for a negative priority")
+	}
+
+	bPolicy.Priority = 100
+	if pPolicy, err := bPolicy.GenPolicyFromBusinessPolicy("mypolicy"); err != nil {
+		t.Errorf("GenPolicyFromBusinessPolicy should have not have returned error but got: %v", err)
+	} else if pPolicy.Priority != 100 {
+		t.Errorf("the priority of the policy should be 100 but is %v", pPolicy.Priority)
+	} else if pPolicy.DeepCopy().Priority != 100 {
+		t.Errorf("the priority of the copied policy should be 100")
+	}
+}
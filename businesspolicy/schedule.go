@@ -0,0 +1,227 @@
+package businesspolicy
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/externalpolicy"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The longest a maintenance window can stay open, one week.
+const MAX_SCHEDULE_WINDOW_S = 7 * 24 * 60 * 60
+
+// How far ahead to look for the next opening of a maintenance window.
+const SCHEDULE_LOOKAHEAD = 366 * 24 * time.Hour
+
+// The deployment schedule of a deployment policy. Agreements are only made, and the service is only upgraded or moved by
+// a policy change, while one of the maintenance windows is open. The windows are evaluated in the time zone of the node,
+// which is read from a node property, so that one policy can serve nodes in many time zones.
+type DeploymentSchedule struct {
+	Windows          []ScheduleWindow `json:"windows"`                    // the maintenance windows, the service can be deployed while any of them is open
+	Timezone         string           `json:"timezone,omitempty"`         // the IANA time zone of the windows when the node does not have one, UTC if not set
+	TimezoneProperty string           `json:"timezoneProperty,omitempty"` // the name of the node property holding the IANA time zone of the node
+}
+
+func (s DeploymentSchedule) String() string {
+	return fmt.Sprintf("Windows: %v, Timezone: %v, TimezoneProperty: %v",
+		s.Windows,
+		s.Timezone,
+		s.TimezoneProperty)
+}
+
+// A maintenance window opens at each time matched by a cron expression and stays open for the duration.
+type ScheduleWindow struct {
+	Start     string `json:"start"`    // cron expression of the opening times: minute hour day-of-month month day-of-week
+	DurationS int    `json:"duration"` // the number of seconds the window stays open
+}
+
+func (w ScheduleWindow) String() string {
+	return fmt.Sprintf("Start: %v, DurationS: %v",
+		w.Start,
+		w.DurationS)
+}
+
+func (s DeploymentSchedule) Validate() error {
+	if len(s.Windows) == 0 {
+		return fmt.Errorf("schedule must have at least one window")
+	}
+	for _, w := range s.Windows {
+		if _, err := parseCron(w.Start); err != nil {
+			return fmt.Errorf("schedule window start %v is not valid, %v", w.Start, err)
+		} else if w.DurationS < 60 || w.DurationS > MAX_SCHEDULE_WINDOW_S {
+			return fmt.Errorf("schedule window duration %v must be between 60 and %v seconds", w.DurationS, MAX_SCHEDULE_WINDOW_S)
+		}
+	}
+	if s.Timezone != "" {
+		if _, err := time.LoadLocation(s.Timezone); err != nil {
+			return fmt.Errorf("schedule timezone %v is not valid, %v", s.Timezone, err)
+		}
+	}
+	return nil
+}
+
+// Return the time zone of a node with the given properties. The time zone in the node property is used when it is set
+// and valid, otherwise the time zone of the schedule.
+func (s DeploymentSchedule) Location(props externalpolicy.PropertyList) *time.Location {
+	if s.TimezoneProperty != "" && props.HasProperty(s.TimezoneProperty) {
+		prop, _ := props.GetProperty(s.TimezoneProperty)
+		if tz, ok := prop.Value.(string); ok {
+			if loc, err := time.LoadLocation(tz); err == nil {
+				return loc
+			}
+		}
+	}
+	if s.Timezone != "" {
+		if loc, err := time.LoadLocation(s.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// Returns true if one of the windows is open at the given time. The time should be in the location of the node.
+func (s DeploymentSchedule) IsOpen(t time.Time) bool {
+	for _, w := range s.Windows {
+		c, err := parseCron(w.Start)
+		if err != nil {
+			continue
+		}
+		// The window is open if it opened less than its duration ago.
+		for m := t.Truncate(time.Minute); t.Sub(m) < time.Duration(w.DurationS)*time.Second; m = m.Add(-time.Minute) {
+			if c.matches(m) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Return the time the next window opens, which is the given time when a window is already open. The zero time is returned
+// when no window opens within a year.
+func (s DeploymentSchedule) NextOpen(t time.Time) time.Time {
+	if s.IsOpen(t) {
+		return t
+	}
+	var next time.Time
+	for _, w := range s.Windows {
+		c, err := parseCron(w.Start)
+		if err != nil {
+			continue
+		}
+		if n := c.next(t, t.Add(SCHEDULE_LOOKAHEAD)); !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	return next
+}
+
+// The parsed fields of a cron expression. Each field is the set of values it matches.
+type cronExpr struct {
+	minutes  []bool
+	hours    []bool
+	days     []bool
+	months   []bool
+	weekdays []bool
+	anyDay   bool // the day of month field is *
+	anyWeek  bool // the day of week field is *
+}
+
+// Parse a cron expression with the fields: minute hour day-of-month month day-of-week. Each field is *, a value, a range
+// a-b, or a list of them separated by commas, optionally with a /step. Sunday is day 0 or 7 of the week.
+func parseCron(expr string) (*cronExpr, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, minute hour day-of-month month day-of-week, found %v", len(fields))
+	}
+
+	c := new(cronExpr)
+	var err error
+	if c.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("minute %v", err)
+	} else if c.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("hour %v", err)
+	} else if c.days, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("day-of-month %v", err)
+	} else if c.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("month %v", err)
+	} else if c.weekdays, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("day-of-week %v", err)
+	}
+	c.weekdays[0] = c.weekdays[0] || c.weekdays[7]
+	c.anyDay = fields[2] == "*"
+	c.anyWeek = fields[4] == "*"
+	return c, nil
+}
+
+func parseCronField(field string, min int, max int) ([]bool, error) {
+	set := make([]bool, max+1)
+	for _, item := range strings.Split(field, ",") {
+		step := 1
+		if ix := strings.Index(item, "/"); ix != -1 {
+			s, err := strconv.Atoi(item[ix+1:])
+			if err != nil || s < 1 {
+				return nil, fmt.Errorf("step in %v is not a positive number", item)
+			}
+			step = s
+			item = item[:ix]
+		}
+
+		low, high := min, max
+		if item != "*" {
+			bounds := strings.SplitN(item, "-", 2)
+			var err error
+			if low, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("%v is not a number", bounds[0])
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("%v is not a number", bounds[1])
+				}
+			}
+		}
+		if low < min || high > max || low > high {
+			return nil, fmt.Errorf("%v is not within %v-%v", item, min, max)
+		}
+
+		for v := low; v <= high; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// Returns true if the day of the given time matches. As in cron, when both the day of month and the day of week are
+// restricted, a day matching either one matches.
+func (c *cronExpr) matchesDay(t time.Time) bool {
+	if !c.months[int(t.Month())] {
+		return false
+	}
+	day, weekday := c.days[t.Day()], c.weekdays[int(t.Weekday())]
+	if c.anyDay || c.anyWeek {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+func (c *cronExpr) matches(t time.Time) bool {
+	return c.matchesDay(t) && c.hours[t.Hour()] && c.minutes[t.Minute()]
+}
+
+// Return the first time after the given time and before the limit that matches, or the zero time. Days and hours that
+// do not match are skipped as a whole.
+func (c *cronExpr) next(t time.Time, limit time.Time) time.Time {
+	for m := t.Truncate(time.Minute).Add(time.Minute); m.Before(limit); {
+		if !c.matchesDay(m) {
+			m = time.Date(m.Year(), m.Month(), m.Day()+1, 0, 0, 0, 0, m.Location())
+		} else if !c.hours[m.Hour()] {
+			m = time.Date(m.Year(), m.Month(), m.Day(), m.Hour()+1, 0, 0, 0, m.Location())
+		} else if !c.minutes[m.Minute()] {
+			m = m.Add(time.Minute)
+		} else {
+			return m
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,112 @@
+//go:build unit
+// +build unit
+
+package businesspolicy
+
+import (
+	"github.com/open-horizon/anax/externalpolicy"
+	"testing"
+	"time"
+)
+
+func Test_DeploymentSchedule_Validate(t *testing.T) {
+
+	good := []DeploymentSchedule{
+		{Windows: []ScheduleWindow{{Start: "0 22 * * *", DurationS: 7200}}},
+		{Windows: []ScheduleWindow{{Start: "*/15 1-3,23 1 1-6/2 0,7", DurationS: 60}}, Timezone: "Europe/Berlin"},
+		{Windows: []ScheduleWindow{{Start: "30 2 * * 6", DurationS: MAX_SCHEDULE_WINDOW_S}}, TimezoneProperty: "timezone"},
+	}
+	for _, s := range good {
+		if err := s.Validate(); err != nil {
+			t.Errorf("schedule %v should be valid, error: %v", s, err)
+		}
+	}
+
+	bad := []DeploymentSchedule{
+		{},
+		{Windows: []ScheduleWindow{{Start: "0 22 * *", DurationS: 7200}}},
+		{Windows: []ScheduleWindow{{Start: "60 22 * * *", DurationS: 7200}}},
+		{Windows: []ScheduleWindow{{Start: "0 5-2 * * *", DurationS: 7200}}},
+		{Windows: []ScheduleWindow{{Start: "*/0 * * * *", DurationS: 7200}}},
+		{Windows: []ScheduleWindow{{Start: "0 22 * * mon", DurationS: 7200}}},
+		{Windows: []ScheduleWindow{{Start: "0 22 * * *", DurationS: 30}}},
+		{Windows: []ScheduleWindow{{Start: "0 22 * * *", DurationS: MAX_SCHEDULE_WINDOW_S + 1}}},
+		{Windows: []ScheduleWindow{{Start: "0 22 * * *", DurationS: 7200}}, Timezone: "Not/AZone"},
+	}
+	for _, s := range bad {
+		if err := s.Validate(); err == nil {
+			t.Errorf("schedule %v should not be valid", s)
+		}
+	}
+}
+
+func Test_DeploymentSchedule_IsOpen(t *testing.T) {
+
+	// Open from 22:00 to 02:00 on weekdays.
+	s := DeploymentSchedule{Windows: []ScheduleWindow{{Start: "0 22 * * 1-5", DurationS: 4 * 60 * 60}}}
+
+	// 2024-03-04 is a Monday.
+	tests := []struct {
+		t    time.Time
+		open bool
+	}{
+		{time.Date(2024, 3, 4, 21, 59, 0, 0, time.UTC), false},
+		{time.Date(2024, 3, 4, 22, 0, 0, 0, time.UTC), true},
+		{time.Date(2024, 3, 5, 1, 59, 59, 0, time.UTC), true},
+		{time.Date(2024, 3, 5, 2, 0, 0, 0, time.UTC), false},
+		{time.Date(2024, 3, 9, 23, 0, 0, 0, time.UTC), false}, // Saturday
+		{time.Date(2024, 3, 9, 1, 0, 0, 0, time.UTC), true},   // Friday night window
+	}
+	for _, test := range tests {
+		if open := s.IsOpen(test.t); open != test.open {
+			t.Errorf("at %v open should be %v, is %v", test.t, test.open, open)
+		}
+	}
+}
+
+func Test_DeploymentSchedule_NextOpen(t *testing.T) {
+
+	s := DeploymentSchedule{Windows: []ScheduleWindow{
+		{Start: "0 22 * * 1-5", DurationS: 3600},
+		{Start: "30 6 1 * *", DurationS: 3600},
+	}}
+
+	now := time.Date(2024, 3, 4, 22, 30, 0, 0, time.UTC)
+	if next := s.NextOpen(now); !next.Equal(now) {
+		t.Errorf("next open should be now %v, is %v", now, next)
+	}
+
+	now = time.Date(2024, 3, 8, 23, 30, 0, 0, time.UTC)
+	if next, expected := s.NextOpen(now), time.Date(2024, 3, 11, 22, 0, 0, 0, time.UTC); !next.Equal(expected) {
+		t.Errorf("next open should be %v, is %v", expected, next)
+	}
+
+	now = time.Date(2024, 3, 29, 23, 30, 0, 0, time.UTC)
+	if next, expected := s.NextOpen(now), time.Date(2024, 4, 1, 6, 30, 0, 0, time.UTC); !next.Equal(expected) {
+		t.Errorf("next open should be %v, is %v", expected, next)
+	}
+
+	never := DeploymentSchedule{Windows: []ScheduleWindow{{Start: "0 0 30 2 *", DurationS: 3600}}}
+	if next := never.NextOpen(now); !next.IsZero() {
+		t.Errorf("next open should be zero, is %v", next)
+	}
+}
+
+func Test_DeploymentSchedule_Location(t *testing.T) {
+
+	s := DeploymentSchedule{Windows: []ScheduleWindow{{Start: "0 22 * * *", DurationS: 3600}}, Timezone: "America/New_York", TimezoneProperty: "timezone"}
+
+	props := externalpolicy.PropertyList{*externalpolicy.Property_Factory("timezone", "Asia/Tokyo")}
+	if loc := s.Location(props); loc.String() != "Asia/Tokyo" {
+		t.Errorf("location should be the node time zone, is %v", loc)
+	}
+
+	props = externalpolicy.PropertyList{*externalpolicy.Property_Factory("timezone", "Not/AZone")}
+	if loc := s.Location(props); loc.String() != "America/New_York" {
+		t.Errorf("location should be the schedule time zone, is %v", loc)
+	}
+
+	if loc := (DeploymentSchedule{}).Location(nil); loc != time.UTC {
+		t.Errorf("location should be UTC, is %v", loc)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/metrics"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/anax/version"
@@ -180,6 +181,15 @@ func (w *ChangesWorker) NewEvent(incoming events.Message) {
 			w.Commands <- worker.NewTerminateCommand("shutdown")
 		}
 
+	case *events.ConfigReloadedMessage:
+		msg, _ := incoming.(*events.ConfigReloadedMessage)
+		for _, field := range msg.Applied {
+			if field == "Edge.ExchangeMessagePollInterval" || field == "Edge.ExchangeMessagePollMaxInterval" || field == "Edge.ExchangeMessagePollIncrement" {
+				w.Commands <- NewReloadPollIntervalsCommand()
+				break
+			}
+		}
+
 	default: //nothing
 
 	}
@@ -207,6 +217,9 @@ func (w *ChangesWorker) CommandHandler(command worker.Command) bool {
 	case *AgreementCommand:
 		w.agreementReached = true
 
+	case *ReloadPollIntervalsCommand:
+		w.reloadPollIntervals()
+
 	case *DeviceRegisteredCommand:
 		cmd, _ := command.(*DeviceRegisteredCommand)
 		w.handleDeviceRegistration(cmd)
@@ -362,12 +375,14 @@ func (w *ChangesWorker) postProcessChanges(changes *exchange.ExchangeChanges, in
 // Process any error from the /changes API and update the heartbeat state appropriately. Return true if the
 // caller should not proceed to process the response.
 func (w *ChangesWorker) handleHeartbeatStateAndError(changes *exchange.ExchangeChanges, err error) bool {
+	metrics.Heartbeat(err == nil)
+
 	if err != nil {
 		glog.Errorf(chglog(fmt.Sprintf("heartbeat and change retrieval failed, error %v", err)))
 
 		if strings.Contains(err.Error(), "status: 401") {
 			// If the heartbeat fails because the node entry is gone then initiate a full node quiesce.
-			w.Messages() <- events.NewNodeShutdownMessage(events.START_UNCONFIGURE, false, false)
+			w.Messages() <- events.NewNodeShutdownMessage(events.START_UNCONFIGURE, false, false, "")
 		} else {
 			// The exchange context is configured for minimal retries and a small interval. This will cause retries
 			// to end quickly and to be handled like errors here. When there are errors, the "no work interval" is kept
@@ -621,6 +636,27 @@ func (w *ChangesWorker) getHeartbeatIntervals() bool {
 	return updated
 }
 
+// The poll intervals in the config file were reloaded. The node's and node org's heartbeat configuration still take
+// precedence over the config file. The polling restarts from the min interval.
+func (w *ChangesWorker) reloadPollIntervals() {
+	w.pollMinInterval = w.Config.Edge.ExchangeMessagePollInterval
+	w.pollMaxInterval = w.Config.Edge.ExchangeMessagePollMaxInterval
+	w.pollAdjustment = w.Config.Edge.ExchangeMessagePollIncrement
+
+	if w.GetExchangeToken() != "" {
+		w.getHeartbeatIntervals()
+	}
+	if w.pollMaxInterval < w.pollMinInterval {
+		w.pollMaxInterval = w.pollMinInterval
+	}
+
+	glog.V(3).Infof(chglog(fmt.Sprintf("Poll intervals reloaded from the config file. min: %v, max: %v, increment: %v", w.pollMinInterval, w.pollMaxInterval, w.pollAdjustment)))
+
+	w.pollInterval = w.pollMinInterval
+	w.SetNoWorkInterval(w.pollInterval)
+	w.noMsgCount = 0
+}
+
 // Utility logging function
 var chglog = func(v interface{}) string {
 	return fmt.Sprintf("Exchange Changes Worker: %v", v)
@@ -43,3 +43,14 @@ func (c UpdateIntervalCommand) ShortString() string {
 func NewUpdateIntervalCommand(updateType string) *UpdateIntervalCommand {
 	return &UpdateIntervalCommand{UpdateType: updateType}
 }
+
+type ReloadPollIntervalsCommand struct {
+}
+
+func (c ReloadPollIntervalsCommand) ShortString() string {
+	return fmt.Sprintf("ReloadPollIntervalsCommand")
+}
+
+func NewReloadPollIntervalsCommand() *ReloadPollIntervalsCommand {
+	return &ReloadPollIntervalsCommand{}
+}
@@ -1,7 +1,6 @@
 package agreement
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/open-horizon/anax/cli/cliutils"
 	"github.com/open-horizon/anax/i18n"
@@ -104,7 +103,7 @@ func List(archivedAgreements bool, agreementId string) {
 		for i := range apiAgreements {
 			if agreementId == apiAgreements[i].CurrentAgreementId {
 				// Found it
-				jsonBytes, err := json.MarshalIndent(apiAgreements[i], "", cliutils.JSON_INDENT)
+				jsonBytes, err := cliutils.MarshalOutput(apiAgreements[i])
 				if err != nil {
 					cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal agreement with index %d: %v", i, err))
 				}
@@ -121,7 +120,7 @@ func List(archivedAgreements bool, agreementId string) {
 			for i := range apiAgreements {
 				agreements[i].CopyAgreementInto(apiAgreements[i])
 			}
-			jsonBytes, err := json.MarshalIndent(agreements, "", cliutils.JSON_INDENT)
+			jsonBytes, err := cliutils.MarshalOutput(agreements)
 			if err != nil {
 				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn agreement list' output: %v", err))
 			}
@@ -132,7 +131,7 @@ func List(archivedAgreements bool, agreementId string) {
 			for i := range apiAgreements {
 				agreements[i].CopyAgreementInto(apiAgreements[i])
 			}
-			jsonBytes, err := json.MarshalIndent(agreements, "", cliutils.JSON_INDENT)
+			jsonBytes, err := cliutils.MarshalOutput(agreements)
 			if err != nil {
 				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn agreement list' output: %v", err))
 			}
@@ -170,3 +169,41 @@ func Cancel(agreementId string, allAgreements bool) {
 		cliutils.HorizonDelete("agreement/"+id, []int{200, 204}, []int{}, false)
 	}
 }
+
+// One step of the agreement history, with a readable time.
+type AgreementHistoryEntry struct {
+	Time       string   `json:"time"`
+	Event      string   `json:"event"`
+	Actor      string   `json:"actor,omitempty"`
+	Policies   []string `json:"policies,omitempty"`
+	Services   []string `json:"services,omitempty"`
+	ReasonCode uint64   `json:"reason_code,omitempty"`
+	Reason     string   `json:"reason,omitempty"`
+}
+
+func History(agreementId string) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	var apiOutput persistence.AgreementHistory
+	cliutils.HorizonGet("agreement/"+agreementId+"/history", []int{200}, &apiOutput, false)
+
+	entries := make([]AgreementHistoryEntry, len(apiOutput.Entries))
+	for i, e := range apiOutput.Entries {
+		entries[i] = AgreementHistoryEntry{
+			Time:       cliutils.ConvertTime(e.Time),
+			Event:      e.Event,
+			Actor:      e.Actor,
+			Policies:   e.Policies,
+			Services:   e.Services,
+			ReasonCode: e.ReasonCode,
+			Reason:     e.Reason,
+		}
+	}
+
+	jsonBytes, err := cliutils.MarshalOutput(entries)
+	if err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn agreement history' output: %v", err))
+	}
+	fmt.Printf("%s\n", jsonBytes)
+}
@@ -1,12 +1,14 @@
 package agreementbot
 
 import (
-	"encoding/json"
 	"fmt"
 	agbot "github.com/open-horizon/anax/agreementbot/persistence"
 	"github.com/open-horizon/anax/cli/cliutils"
 	"github.com/open-horizon/anax/i18n"
+	"net/url"
 	"os"
+	"strconv"
+	"time"
 )
 
 type ActiveAgreement struct {
@@ -64,7 +66,12 @@ func NewArchivedAgreement(agreement agbot.Agreement) *ArchivedAgreement {
 	return &a
 }
 
-func getAgreements(archivedAgreements bool) (apiAgreements []agbot.Agreement) {
+// The states that the agreements can be listed by.
+func GetAgreementStates() []string {
+	return agbot.AgreementStates()
+}
+
+func getAgreements(archivedAgreements bool, query url.Values) (apiAgreements []agbot.Agreement) {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
 
@@ -75,7 +82,11 @@ func getAgreements(archivedAgreements bool) (apiAgreements []agbot.Agreement) {
 
 	// Get horizon api agreement output and drill down to the category we want
 	apiOutput := make(map[string]map[string][]agbot.Agreement, 0)
-	cliutils.HorizonGet("agreement", []int{200}, &apiOutput, false)
+	urlSuffix := "agreement"
+	if len(query) != 0 {
+		urlSuffix += "?" + query.Encode()
+	}
+	cliutils.HorizonGet(urlSuffix, []int{200}, &apiOutput, false)
 
 	var ok bool
 	if _, ok = apiOutput["agreements"]; !ok {
@@ -91,18 +102,44 @@ func getAgreements(archivedAgreements bool) (apiAgreements []agbot.Agreement) {
 	return
 }
 
-func AgreementList(archivedAgreements bool, agreement string) {
+// AgreementList lists the active or archived agreements, or shows one agreement. The agreements can be searched by node,
+// policy, state and the time of their last change of state given to since. The terminated and failed states are
+// archived agreements.
+func AgreementList(archivedAgreements bool, agreement string, node string, policyName string, state string, since string) {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
 
-	apiAgreements := getAgreements(archivedAgreements)
+	query := url.Values{}
+	if node != "" {
+		query.Set("node", node)
+	}
+	if policyName != "" {
+		query.Set("policy", policyName)
+	}
+	if state != "" {
+		if state == agbot.AGREEMENT_STATE_TERMINATED || state == agbot.AGREEMENT_STATE_FAILED {
+			archivedAgreements = true
+		} else if archivedAgreements {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("the agreements in state %v are not archived, --state %v cannot be used with -r.", state, state))
+		}
+		query.Set("state", state)
+	}
+	if since != "" {
+		sinceTime, err := cliutils.ParseSince(since, time.Now())
+		if err != nil {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("invalid value %v for --since, it must be a duration such as 10m or 2h, or an RFC 3339 timestamp: %v", since, err))
+		}
+		query.Set("since", strconv.FormatInt(sinceTime.Unix(), 10))
+	}
+
+	apiAgreements := getAgreements(archivedAgreements, query)
 
 	if agreement != "" {
 		// Look for our agreement id. This works for either active or archived
 		for i := range apiAgreements {
 			if agreement == apiAgreements[i].CurrentAgreementId {
 				// Found it
-				jsonBytes, err := json.MarshalIndent(apiAgreements[i], "", cliutils.JSON_INDENT)
+				jsonBytes, err := cliutils.MarshalOutput(apiAgreements[i])
 				if err != nil {
 					cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal agreement with index %d: %v", i, err))
 				}
@@ -119,7 +156,7 @@ func AgreementList(archivedAgreements bool, agreement string) {
 			for i := range apiAgreements {
 				agreements[i] = *NewActiveAgreement(apiAgreements[i])
 			}
-			jsonBytes, err := json.MarshalIndent(agreements, "", cliutils.JSON_INDENT)
+			jsonBytes, err := cliutils.MarshalOutput(agreements)
 			if err != nil {
 				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'agreement list' output: %v", err))
 			}
@@ -129,7 +166,7 @@ func AgreementList(archivedAgreements bool, agreement string) {
 			for i := range apiAgreements {
 				agreements[i] = *NewArchivedAgreement(apiAgreements[i])
 			}
-			jsonBytes, err := json.MarshalIndent(agreements, "", cliutils.JSON_INDENT)
+			jsonBytes, err := cliutils.MarshalOutput(agreements)
 			if err != nil {
 				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'agreement list' output: %v", err))
 			}
@@ -145,7 +182,7 @@ func AgreementCancel(agreementId string, allAgreements bool) {
 	// Put the agreement ids in a slice
 	var agrIds []string
 	if allAgreements {
-		apiAgreements := getAgreements(false)
+		apiAgreements := getAgreements(false, nil)
 		for _, a := range apiAgreements {
 			agrIds = append(agrIds, a.CurrentAgreementId)
 		}
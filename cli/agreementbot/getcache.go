@@ -1,7 +1,6 @@
 package agreementbot
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/open-horizon/anax/agreementbot"
 	"github.com/open-horizon/anax/cli/cliutils"
@@ -22,7 +21,7 @@ func GetServedOrgs() {
 	cliutils.HorizonGet("cache/servedorg", []int{200}, &servedOrgsInfo, false)
 
 	// Output the combined info
-	jsonBytes, err := json.MarshalIndent(servedOrgsInfo, "", cliutils.JSON_INDENT)
+	jsonBytes, err := cliutils.MarshalOutput(servedOrgsInfo)
 	if err != nil {
 		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn node list' output: %v", err))
 	}
@@ -65,7 +64,7 @@ func GetPatterns(org string, name string, long bool) {
 			cliutils.HorizonGet(patUrl, []int{200}, &patInfo, false)
 
 			// Output the combined info
-			jsonBytes, err := json.MarshalIndent(patInfo, "", cliutils.JSON_INDENT)
+			jsonBytes, err := cliutils.MarshalOutput(patInfo)
 			if err != nil {
 				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal output: %v", err))
 			}
@@ -80,7 +79,7 @@ func GetPatterns(org string, name string, long bool) {
 				msgPrinter.Println()
 			} else {
 				// Output the combined info
-				jsonBytes, err := json.MarshalIndent(patInfo, "", cliutils.JSON_INDENT)
+				jsonBytes, err := cliutils.MarshalOutput(patInfo)
 				if err != nil {
 					cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal output: %v", err))
 				}
@@ -97,7 +96,7 @@ func GetPatterns(org string, name string, long bool) {
 				msgPrinter.Println()
 			} else {
 				// Output the combined info
-				jsonBytes, err := json.MarshalIndent(patInfo, "", cliutils.JSON_INDENT)
+				jsonBytes, err := cliutils.MarshalOutput(patInfo)
 				if err != nil {
 					cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal output: %v", err))
 				}
@@ -111,7 +110,7 @@ func GetPatterns(org string, name string, long bool) {
 				msgPrinter.Println()
 			} else {
 				// Output the combined info
-				jsonBytes, err := json.MarshalIndent(patInfo, "", cliutils.JSON_INDENT)
+				jsonBytes, err := cliutils.MarshalOutput(patInfo)
 				if err != nil {
 					cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal output: %v", err))
 				}
@@ -124,7 +123,7 @@ func GetPatterns(org string, name string, long bool) {
 		cliutils.HorizonGet(patUrl, []int{200}, &patInfo, false)
 
 		// Output the combined info
-		jsonBytes, err := json.MarshalIndent(patInfo, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(patInfo)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal output: %v", err))
 		}
@@ -168,7 +167,7 @@ func GetPolicies(org string, name string, long bool) {
 			cliutils.HorizonGet(polUrl, []int{200}, &polInfo, false)
 
 			// Output the combined info
-			jsonBytes, err := json.MarshalIndent(polInfo, "", cliutils.JSON_INDENT)
+			jsonBytes, err := cliutils.MarshalOutput(polInfo)
 			if err != nil {
 				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal output: %v", err))
 			}
@@ -183,7 +182,7 @@ func GetPolicies(org string, name string, long bool) {
 				msgPrinter.Println()
 			} else {
 				// Output the combined info
-				jsonBytes, err := json.MarshalIndent(polInfo, "", cliutils.JSON_INDENT)
+				jsonBytes, err := cliutils.MarshalOutput(polInfo)
 				if err != nil {
 					cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal output: %v", err))
 				}
@@ -200,7 +199,7 @@ func GetPolicies(org string, name string, long bool) {
 				msgPrinter.Println()
 			} else {
 				// Output the combined info
-				jsonBytes, err := json.MarshalIndent(polInfo, "", cliutils.JSON_INDENT)
+				jsonBytes, err := cliutils.MarshalOutput(polInfo)
 				if err != nil {
 					cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal output: %v", err))
 				}
@@ -213,7 +212,7 @@ func GetPolicies(org string, name string, long bool) {
 				msgPrinter.Println()
 			} else {
 				// Output the combined info
-				jsonBytes, err := json.MarshalIndent(polInfo, "", cliutils.JSON_INDENT)
+				jsonBytes, err := cliutils.MarshalOutput(polInfo)
 				if err != nil {
 					cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal output: %v", err))
 				}
@@ -226,7 +225,7 @@ func GetPolicies(org string, name string, long bool) {
 		cliutils.HorizonGet(polUrl, []int{200}, &polInfo, false)
 
 		// Output the combined info
-		jsonBytes, err := json.MarshalIndent(polInfo, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(polInfo)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal output: %v", err))
 		}
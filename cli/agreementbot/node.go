@@ -1,7 +1,6 @@
 package agreementbot
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/open-horizon/anax/agreementbot"
 	"github.com/open-horizon/anax/apicommon"
@@ -51,7 +50,7 @@ func List() {
 	nodeInfo.CopyStatusInto(&status)
 
 	// Output the combined info
-	jsonBytes, err := json.MarshalIndent(nodeInfo, "", cliutils.JSON_INDENT)
+	jsonBytes, err := cliutils.MarshalOutput(nodeInfo)
 	if err != nil {
 		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal 'hzn node list' output: %v", err))
 	}
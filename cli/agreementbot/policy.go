@@ -1,7 +1,6 @@
 package agreementbot
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/open-horizon/anax/cli/cliutils"
 	"github.com/open-horizon/anax/i18n"
@@ -52,7 +51,7 @@ func PolicyList(org string, name string) {
 	if name == "" {
 		policies, httpCode := getPolicyNames(org)
 		if httpCode == 200 {
-			jsonBytes, err := json.MarshalIndent(policies, "", cliutils.JSON_INDENT)
+			jsonBytes, err := cliutils.MarshalOutput(policies)
 			if err != nil {
 				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'policy list' output: %v", err))
 			}
@@ -64,7 +63,7 @@ func PolicyList(org string, name string) {
 	} else {
 		pol, httpCode := getPolicy(org, name)
 		if httpCode == 200 {
-			jsonBytes, err := json.MarshalIndent(pol, "", cliutils.JSON_INDENT)
+			jsonBytes, err := cliutils.MarshalOutput(pol)
 			if err != nil {
 				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'policy list' output: %v", err))
 			}
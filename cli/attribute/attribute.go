@@ -1,7 +1,6 @@
 package attribute
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/open-horizon/anax/api"
 	"github.com/open-horizon/anax/cli/cliutils"
@@ -43,7 +42,7 @@ func List() {
 	}
 
 	// Convert to json and output
-	jsonBytes, err := json.MarshalIndent(attrs, "", cliutils.JSON_INDENT)
+	jsonBytes, err := cliutils.MarshalOutput(attrs)
 	if err != nil {
 		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal 'hzn attribute list' output: %v", err))
 	}
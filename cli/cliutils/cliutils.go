@@ -40,6 +40,7 @@ import (
 const (
 	HZN_API             = "http://localhost:" + config.AnaxAPIPortDefault
 	HZN_API_MAC         = "http://localhost:8081"
+	HZN_API_SOCKET      = "http://localhost" // the base url used when hzn connects to the agent API through its unix socket
 	JSON_INDENT         = "  "
 	MUST_REGISTER_FIRST = "this command can not be run before running 'hzn register'"
 
@@ -53,6 +54,7 @@ const (
 	NOT_FOUND         = 8
 	SIGNATURE_INVALID = 9
 	EXEC_CMD_ERROR    = 10
+	QUOTA_ERROR       = 11 // the exchange org has reached its limit of nodes or services
 	INTERNAL_ERROR    = 99
 
 	// Anax API HTTP Codes
@@ -63,6 +65,12 @@ const (
 	ANAX_OVERWRITE_FILE = "/etc/default/horizon"
 	ANAX_CONFIG_FILE    = "/etc/horizon/anax.json"
 
+	// the credentials hzn uses for the agent API when the agent requires authentication
+	HZN_AGENT_API_TOKEN_FILE  = "HZN_AGENT_API_TOKEN_FILE"
+	HZN_AGENT_API_CLIENT_CERT = "HZN_AGENT_API_CLIENT_CERT"
+	HZN_AGENT_API_CLIENT_KEY  = "HZN_AGENT_API_CLIENT_KEY"
+	HZN_AGENT_API_CA_CERT     = "HZN_AGENT_API_CA_CERT"
+
 	// default keys will be prepended with $HOME
 	DEFAULT_PRIVATE_KEY_FILE = ".hzn/keys/service.private.key"
 	DEFAULT_PUBLIC_KEY_FILE  = ".hzn/keys/service.public.pem"
@@ -81,6 +89,7 @@ const (
 type GlobalOptions struct {
 	Verbose     *bool
 	IsDryRun    *bool
+	Output      *string
 	UsingApiKey bool // should go away soon
 }
 
@@ -175,8 +184,18 @@ func Unmarshal(data []byte, v interface{}, errMsg string) {
 	}
 }
 
-// MarshalIndent calls json.MarshalIndent and handles any errors
+// MarshalIndent returns v in the output format given with --output-format, indented json by default.
 func MarshalIndent(v interface{}, errMsg string) string {
+	jsonBytes, err := MarshalOutput(v)
+	if err != nil {
+		Fatal(JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal data type from %s: %v", errMsg, err))
+	}
+	return string(jsonBytes)
+}
+
+// MarshalIndentJSON returns v as indented json whatever the output format, for the output that is read back by other
+// commands, like a signed node policy.
+func MarshalIndentJSON(v interface{}, errMsg string) string {
 	jsonBytes, err := json.MarshalIndent(v, "", JSON_INDENT)
 	if err != nil {
 		Fatal(JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal data type from %s: %v", errMsg, err))
@@ -561,6 +580,31 @@ func RequiredWithDefaultEnvVar(flag *string, envVarName, errMsg string) *string
 
 // GetHorizonUrlBase returns the base part of the horizon api url (which can be overridden by env var HORIZON_URL)
 func GetHorizonUrlBase() string {
+	if GetHorizonSocket() != "" {
+		return HZN_API_SOCKET
+	}
+	return horizonUrlBase()
+}
+
+// GetHorizonSocket returns the path of the unix socket of the agent API when hzn connects to the API through the socket.
+// The socket is set with HORIZON_URL=unix:///path/to/socket. When HORIZON_URL is not set, the default socket is used
+// if the agent serves its API on it.
+func GetHorizonSocket() string {
+	if envVar := os.Getenv("HORIZON_URL"); envVar != "" {
+		if strings.HasPrefix(envVar, "unix://") {
+			return strings.TrimPrefix(envVar, "unix://")
+		}
+		return ""
+	}
+	if runtime.GOOS == "linux" {
+		if info, err := os.Stat(config.APISocketPath_DEFAULT); err == nil && info.Mode()&os.ModeSocket != 0 {
+			return config.APISocketPath_DEFAULT
+		}
+	}
+	return ""
+}
+
+func horizonUrlBase() string {
 	envVar := os.Getenv("HORIZON_URL")
 	if envVar != "" {
 		return envVar
@@ -596,7 +640,8 @@ func GetHorizonContainerIndex() (int, error) {
 	return portInt - 8080, nil
 }
 
-// Returns the agbot native url. If HZN_AGBOT_API not set, use HORIZON_URL
+// Returns the agbot native url. If HZN_AGBOT_API not set, use HORIZON_URL. A HORIZON_URL that is the unix socket of the
+// agent API is not used, the agbot does not serve its API on it.
 func GetAgbotUrlBase() string {
 	envVar := os.Getenv("HZN_AGBOT_API")
 	if envVar != "" {
@@ -604,7 +649,13 @@ func GetAgbotUrlBase() string {
 		return envVar
 	}
 
-	return GetHorizonUrlBase()
+	if strings.HasPrefix(os.Getenv("HORIZON_URL"), "unix://") {
+		if runtime.GOOS == "darwin" {
+			return HZN_API_MAC
+		}
+		return HZN_API
+	}
+	return horizonUrlBase()
 }
 
 // Returns the url for the agbot secure API.
@@ -645,6 +696,33 @@ func isGoodCode(actualHttpCode int, goodHttpCodes []int) bool {
 	return false
 }
 
+// The body of the errors returned by the agent API.
+type horizonAPIError struct {
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+	Detail      string `json:"detail"`
+	Remediation string `json:"remediation"`
+}
+
+// FormatHorizonError returns the error in a response body of the agent API with its code and remediation. The body is
+// returned as it is when it is not an agent API error.
+func FormatHorizonError(respBody string) string {
+	var apiErr horizonAPIError
+	if err := json.Unmarshal([]byte(respBody), &apiErr); err != nil || apiErr.Code == "" || apiErr.Message == "" {
+		return respBody
+	}
+
+	msgPrinter := i18n.GetMessagePrinter()
+	msg := msgPrinter.Sprintf("%v (%v)", apiErr.Message, apiErr.Code)
+	if apiErr.Detail != "" {
+		msg += msgPrinter.Sprintf(", detail: %v", apiErr.Detail)
+	}
+	if apiErr.Remediation != "" {
+		msg += "\n" + msgPrinter.Sprintf("Remediation: %v", apiErr.Remediation)
+	}
+	return msg
+}
+
 func printHorizonRestError(apiMethod string, err error) {
 	msg := ""
 	if os.Getenv("HORIZON_URL") == "" {
@@ -671,7 +749,7 @@ func HorizonGet(urlSuffix string, goodHttpCodes []int, structure interface{}, qu
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
 
-	httpClient := GetHTTPClient(0)
+	httpClient := GetHorizonHTTPClient(0)
 
 	url := GetHorizonUrlBase() + "/" + urlSuffix
 	apiMsg := http.MethodGet + " " + url
@@ -682,6 +760,7 @@ func HorizonGet(urlSuffix string, goodHttpCodes []int, structure interface{}, qu
 		Fatal(HTTP_ERROR, msgPrinter.Sprintf("%s new request failed: %v", apiMsg, err))
 	}
 	req.Close = true
+	addHorizonAuth(req)
 	req.Header.Add("Accept", "application/json")
 
 	// add the language request to the http header
@@ -719,6 +798,8 @@ func HorizonGet(urlSuffix string, goodHttpCodes []int, structure interface{}, qu
 		if quiet {
 			retError = fmt.Errorf(msgPrinter.Sprintf("Bad HTTP code from %s: %d", apiMsg, httpCode))
 			return
+		} else if errMsg := FormatHorizonError(GetRespBodyAsString(resp.Body)); errMsg != "" {
+			Fatal(HTTP_ERROR, msgPrinter.Sprintf("bad HTTP code %d from %s: %s", httpCode, apiMsg, errMsg))
 		} else {
 			Fatal(HTTP_ERROR, msgPrinter.Sprintf("bad HTTP code from %s: %d", apiMsg, httpCode))
 		}
@@ -753,6 +834,51 @@ func HorizonGet(urlSuffix string, goodHttpCodes []int, structure interface{}, qu
 	return
 }
 
+// HorizonStream runs a GET on the anax api and copies the response body to the writer as it is received, until the agent
+// ends the response. It exits with an error if the http code is not 200.
+func HorizonStream(urlSuffix string, w io.Writer) {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	// The response lasts as long as the agent sends it, so the request has no timeout.
+	httpClient := GetHorizonHTTPClient(0)
+
+	url := GetHorizonUrlBase() + "/" + urlSuffix
+	apiMsg := http.MethodGet + " " + url
+	Verbose(apiMsg)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		Fatal(HTTP_ERROR, msgPrinter.Sprintf("%s new request failed: %v", apiMsg, err))
+	}
+	req.Close = true
+	addHorizonAuth(req)
+
+	// add the language request to the http header
+	localeTag, err := i18n.GetLocale()
+	if err != nil {
+		localeTag = language.English
+	}
+	req.Header.Add("Accept-Language", localeTag.String())
+
+	resp, err := httpClient.Do(req)
+	if resp != nil && resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		printHorizonRestError(apiMsg, err)
+	}
+	Verbose(msgPrinter.Sprintf("HTTP code: %d", resp.StatusCode))
+	if resp.StatusCode != http.StatusOK {
+		if errMsg := FormatHorizonError(GetRespBodyAsString(resp.Body)); errMsg != "" {
+			Fatal(HTTP_ERROR, msgPrinter.Sprintf("bad HTTP code %d from %s: %s", resp.StatusCode, apiMsg, errMsg))
+		} else {
+			Fatal(HTTP_ERROR, msgPrinter.Sprintf("bad HTTP code from %s: %d", apiMsg, resp.StatusCode))
+		}
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		Fatal(HTTP_ERROR, msgPrinter.Sprintf("failed to read body response from %s: %v", apiMsg, err))
+	}
+}
+
 // HorizonDelete runs a DELETE on the anax api.
 // If the list of goodHttpCodes is not empty and none match the actual http code, it will exit with an error. Otherwise the actual code is returned.
 func HorizonDelete(urlSuffix string, goodHttpCodes []int, expectedHttpErrorCodes []int, quiet bool) (httpCode int, retError error) {
@@ -766,7 +892,7 @@ func HorizonDelete(urlSuffix string, goodHttpCodes []int, expectedHttpErrorCodes
 	if IsDryRun() {
 		return 204, nil
 	}
-	httpClient := GetHTTPClient(0)
+	httpClient := GetHorizonHTTPClient(0)
 	req, err := http.NewRequest(http.MethodDelete, url, nil)
 	if err != nil {
 		if quiet {
@@ -777,6 +903,7 @@ func HorizonDelete(urlSuffix string, goodHttpCodes []int, expectedHttpErrorCodes
 		}
 	}
 	req.Close = true
+	addHorizonAuth(req)
 
 	resp, err := httpClient.Do(req)
 	if resp != nil && resp.Body != nil {
@@ -805,11 +932,11 @@ func HorizonDelete(urlSuffix string, goodHttpCodes []int, expectedHttpErrorCodes
 	if isGoodCode(httpCode, goodHttpCodes) {
 		return
 	} else if isGoodCode(httpCode, expectedHttpErrorCodes) {
-		err_msg := GetRespBodyAsString(resp.Body)
+		err_msg := FormatHorizonError(GetRespBodyAsString(resp.Body))
 		retError = fmt.Errorf(err_msg)
 		return
 	} else {
-		err_msg := msgPrinter.Sprintf("bad HTTP code %d from %s: %s", httpCode, apiMsg, GetRespBodyAsString(resp.Body))
+		err_msg := msgPrinter.Sprintf("bad HTTP code %d from %s: %s", httpCode, apiMsg, FormatHorizonError(GetRespBodyAsString(resp.Body)))
 		if quiet {
 			retError = fmt.Errorf(err_msg)
 			return
@@ -829,7 +956,7 @@ func HorizonPutPost(method string, urlSuffix string, goodHttpCodes []int, body i
 	if IsDryRun() {
 		return 201, "", nil
 	}
-	httpClient := GetHTTPClient(0)
+	httpClient := GetHorizonHTTPClient(0)
 
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
@@ -865,6 +992,7 @@ func HorizonPutPost(method string, urlSuffix string, goodHttpCodes []int, body i
 		return 0, "", err
 	}
 	req.Close = true
+	addHorizonAuth(req)
 	req.Header.Add("Accept", "application/json")
 	if bodyIsBytes {
 		req.Header.Add("Content-Length", strconv.Itoa(len(jsonBytes)))
@@ -888,9 +1016,9 @@ func HorizonPutPost(method string, urlSuffix string, goodHttpCodes []int, body i
 	resp_body = GetRespBodyAsString(resp.Body)
 	if !isGoodCode(httpCode, goodHttpCodes) {
 		if exitOnErr {
-			Fatal(HTTP_ERROR, msgPrinter.Sprintf("bad HTTP code %d from %s: %s", httpCode, apiMsg, resp_body))
+			Fatal(HTTP_ERROR, msgPrinter.Sprintf("bad HTTP code %d from %s: %s", httpCode, apiMsg, FormatHorizonError(resp_body)))
 		} else {
-			return 0, "", fmt.Errorf(msgPrinter.Sprintf("bad HTTP code %d from %s: %s", httpCode, apiMsg, resp_body))
+			return 0, "", fmt.Errorf(msgPrinter.Sprintf("bad HTTP code %d from %s: %s", httpCode, apiMsg, FormatHorizonError(resp_body)))
 		}
 	}
 	return
@@ -1389,6 +1517,7 @@ func InvokeRestApi(httpClient *http.Client, method string, urlPath string, crede
 	}
 
 	retryCount := 0
+	reauthorized := false
 	for {
 		retryCount++
 
@@ -1445,7 +1574,18 @@ func InvokeRestApi(httpClient *http.Client, method string, urlPath string, crede
 		}
 		req.Header.Add("Accept-Language", localeTag.String())
 
-		if credentials != "" {
+		// the exchange may be authenticated with the access tokens of an identity provider instead of the password
+		var authProvider exchange.AuthProvider
+		if credentials != "" && service == "Exchange" {
+			authProvider = GetExchangeAuthProvider()
+		}
+		if authProvider != nil {
+			if authorization, err := authProvider.Authorization(httpClient); err != nil {
+				Fatal(HTTP_ERROR, msgPrinter.Sprintf("Unable to get an access token for %v REST API %v: %v", service, apiMsg, err))
+			} else {
+				req.Header.Add("Authorization", authorization)
+			}
+		} else if credentials != "" {
 			req.Header.Add("Authorization", fmt.Sprintf("Basic %v", base64.StdEncoding.EncodeToString([]byte(credentials))))
 		} // else it is an anonymous call
 
@@ -1468,6 +1608,15 @@ func InvokeRestApi(httpClient *http.Client, method string, urlPath string, crede
 			}
 		} else if err != nil {
 			printHorizonServiceRestError(service, apiMsg, err)
+		} else if resp.StatusCode == http.StatusUnauthorized && authProvider != nil && !reauthorized {
+			// the access token may have been revoked, retry with a new one
+			Verbose(msgPrinter.Sprintf("The access token was rejected calling %v REST API %v. Will retry with a new one.", service, apiMsg))
+			if resp.Body != nil {
+				resp.Body.Close()
+			}
+			authProvider.Invalidate()
+			reauthorized = true
+			continue
 		} else {
 			return resp
 		}
@@ -1608,6 +1757,11 @@ func ExchangePutPost(service string, method string, urlBase string, urlSuffix st
 		if err != nil {
 			Fatal(HTTP_ERROR, msgPrinter.Sprintf("failed to read exchange body response from %s: %v", apiMsg, err))
 		}
+		if httpCode == http.StatusForbidden && service == "Exchange" {
+			if quotaOrg, resource := orgQuotaResource(method, urlSuffix); resource != "" {
+				CheckOrgQuota(urlBase, quotaOrg, resource, credentials)
+			}
+		}
 		respMsg := exchange.PostDeviceResponse{}
 		err = json.Unmarshal(bodyBytes, &respMsg)
 		if err != nil {
@@ -2025,6 +2179,62 @@ func GetHTTPClient(timeout int) *http.Client {
 
 }
 
+// Get an HTTP client for the agent API. The client connects through the unix socket of the agent API when it is used,
+// and presents the client certificate in HZN_AGENT_API_CLIENT_CERT and HZN_AGENT_API_CLIENT_KEY when they are set.
+func GetHorizonHTTPClient(timeout int) *http.Client {
+	httpClient := GetHTTPClient(timeout)
+	transport := httpClient.Transport.(*http.Transport)
+
+	if socket := GetHorizonSocket(); socket != "" {
+		Verbose(i18n.GetMessagePrinter().Sprintf("Connecting to the agent API through %v", socket))
+		dial := transport.Dial
+		transport.Dial = func(_, _ string) (net.Conn, error) {
+			return dial("unix", socket)
+		}
+	}
+
+	certFile := os.Getenv(HZN_AGENT_API_CLIENT_CERT)
+	keyFile := os.Getenv(HZN_AGENT_API_CLIENT_KEY)
+	if certFile != "" && keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			Fatal(CLI_GENERAL_ERROR, i18n.GetMessagePrinter().Sprintf("Unable to load the agent API client certificate %v and key %v: %v", certFile, keyFile, err))
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if caFile := os.Getenv(HZN_AGENT_API_CA_CERT); caFile != "" {
+		caBytes, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			Fatal(CLI_GENERAL_ERROR, i18n.GetMessagePrinter().Sprintf("Unable to read the agent API CA certificate %v: %v", caFile, err))
+		}
+		certPool := x509.NewCertPool()
+		certPool.AppendCertsFromPEM(caBytes)
+		transport.TLSClientConfig.RootCAs = certPool
+	}
+
+	return httpClient
+}
+
+// Add the agent API token to a request to the local agent, when the token file can be read. The token file is only
+// readable by the user running the agent and root, the other users are authenticated in other ways or are rejected
+// by the agent.
+func addHorizonAuth(req *http.Request) {
+	if host := req.URL.Hostname(); host != "localhost" && host != "127.0.0.1" && host != "::1" {
+		return
+	}
+
+	tokenFile := os.Getenv(HZN_AGENT_API_TOKEN_FILE)
+	if tokenFile == "" {
+		tokenFile = config.APITokenFile_DEFAULT
+	}
+	if b, err := ioutil.ReadFile(tokenFile); err == nil {
+		if token := strings.TrimSpace(string(b)); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+}
+
 // create the exchange context with the given user credentail
 func GetUserExchangeContext(userOrg string, credToUse string) exchange.ExchangeContext {
 	var ec exchange.ExchangeContext
@@ -2034,6 +2244,9 @@ func GetUserExchangeContext(userOrg string, credToUse string) exchange.ExchangeC
 			cred = AddOrg(userOrg, cred)
 		}
 		ec = CreateUserExchangeContext(cred, token)
+
+		// registers the auth provider of the exchange calls made with the context
+		GetExchangeAuthProvider()
 	} else {
 		ec = CreateUserExchangeContext("", "")
 	}
@@ -2245,21 +2458,82 @@ func (pr *progressReader) Read(p []byte) (n int, err error) {
 
 // Create progressReader for given io.Reader body. Prints progress appended to given message. Progress is determined by given size.
 func DisplayProgress(body io.Reader, size int, message string) *progressReader {
-	msgPrinter := i18n.GetMessagePrinter()
-	totalSent := 0
+	return DisplayProgressFrom(body, 0, int64(size), message)
+}
+
+// Create progressReader for given io.Reader body that continues a transfer of which offset bytes of size were already done,
+// for example a download that is resumed.
+func DisplayProgressFrom(body io.Reader, offset int64, size int64, message string) *progressReader {
+	done := offset
 	progReader := &progressReader{body, func(r int) {
 		if r > 0 {
-			totalSent += r
-			msgPrinter.Printf("\r"+message+": %.2f %s", float32(totalSent)/float32(size)*100, "%")
+			done += int64(r)
+			PrintProgress(message, done, size)
 		} else {
-			// Clear the progress info when the file has been fully uploaded
-			msgPrinter.Printf("\r")
+			// Clear the progress info when the body has been fully read
+			ClearProgress()
 		}
 
 	}}
 	return progReader
 }
 
+// The width of the progress bar, in characters.
+const PROGRESS_BAR_WIDTH = 30
+
+// FormatProgress returns a progress bar for done out of total bytes, for example
+// Uploading: [===============>              ]  52.30% 1.2 GiB/2.3 GiB
+func FormatProgress(message string, done int64, total int64) string {
+	if total <= 0 {
+		return fmt.Sprintf("%v: %v", message, FormatByteSize(done))
+	}
+	if done > total {
+		done = total
+	}
+	percent := float64(done) / float64(total) * 100
+	filled := int(done * PROGRESS_BAR_WIDTH / total)
+	bar := strings.Repeat("=", filled)
+	if filled < PROGRESS_BAR_WIDTH {
+		bar += ">" + strings.Repeat(" ", PROGRESS_BAR_WIDTH-filled-1)
+	}
+	return fmt.Sprintf("%v: [%v] %6.2f%% %v/%v", message, bar, percent, FormatByteSize(done), FormatByteSize(total))
+}
+
+// PrintProgress prints the progress bar over the previous one on the same line.
+func PrintProgress(message string, done int64, total int64) {
+	fmt.Print("\r" + FormatProgress(message, done, total))
+}
+
+// ClearProgress clears the line of the progress bar.
+func ClearProgress() {
+	fmt.Print("\r" + strings.Repeat(" ", PROGRESS_BAR_WIDTH+60) + "\r")
+}
+
+// ParseSince returns the time given to a --since flag, either a duration before now such as 2h, or an RFC 3339 timestamp.
+func ParseSince(since string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(since); err == nil {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("negative duration %v", since)
+		}
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, since)
+}
+
+// FormatByteSize returns the size in bytes in a human readable form, for example 1.5 MiB.
+func FormatByteSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
 // Returns HZN_DEVICE_ID or HZN_NODE_ID env variables depending on which is defined
 func GetDeviceId() string {
 	deviceId := ""
@@ -5,7 +5,11 @@ package cliutils
 
 import (
 	"fmt"
+	"net/http"
+	"os"
+	"path"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -38,3 +42,90 @@ func Test_ValidateOrg(t *testing.T) {
 	assert.Equal(t, false, invalidFlag, fmt.Sprintf("%s should be a valid org name.", org_name))
 
 }
+
+func Test_HorizonSocketAndAuth(t *testing.T) {
+
+	t.Setenv("HZN_AGBOT_API", "")
+	t.Setenv("HORIZON_URL", "unix:///tmp/anax.sock")
+	assert.Equal(t, "/tmp/anax.sock", GetHorizonSocket(), "the socket should be taken from HORIZON_URL")
+	assert.Equal(t, HZN_API_SOCKET, GetHorizonUrlBase(), "the base url should not be the socket")
+	assert.Equal(t, HZN_API, GetAgbotUrlBase(), "the agbot url should not be the socket")
+
+	t.Setenv("HORIZON_URL", "http://localhost:8510")
+	assert.Equal(t, "", GetHorizonSocket(), "there should be no socket")
+	assert.Equal(t, "http://localhost:8510", GetHorizonUrlBase(), "the base url should be HORIZON_URL")
+	assert.Equal(t, "http://localhost:8510", GetAgbotUrlBase(), "the agbot url should be HORIZON_URL")
+
+	tokenFile := path.Join(t.TempDir(), "api.token")
+	os.WriteFile(tokenFile, []byte("mytoken\n"), 0600)
+	t.Setenv(HZN_AGENT_API_TOKEN_FILE, tokenFile)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost:8510/node", nil)
+	addHorizonAuth(req)
+	assert.Equal(t, "Bearer mytoken", req.Header.Get("Authorization"), "the token should be sent to the local agent")
+
+	req, _ = http.NewRequest(http.MethodGet, "https://agbot.example.com/node", nil)
+	addHorizonAuth(req)
+	assert.Equal(t, "", req.Header.Get("Authorization"), "the token should not be sent to a remote host")
+}
+
+func Test_FormatHorizonError(t *testing.T) {
+
+	body := `{"code":"INVALID_NODE_STATE","message":"The node must be configured.","detail":"node","remediation":"Run hzn node list.","error":"The node must be configured.","input":"node"}`
+	assert.Equal(t, "The node must be configured. (INVALID_NODE_STATE), detail: node\nRemediation: Run hzn node list.", FormatHorizonError(body), "the error should be formatted")
+
+	for _, body := range []string{"Internal server error", `{"error":"old error","input":"node"}`, ""} {
+		assert.Equal(t, body, FormatHorizonError(body), "a body that is not an agent API error should not change")
+	}
+}
+
+func Test_syslogRecordTime(t *testing.T) {
+	now := time.Date(2024, time.January, 2, 10, 0, 0, 0, time.UTC)
+
+	logged, ok := syslogRecordTime("Jan  2 09:30:00 host workload-abc_c1[123]: hello\n", now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2024, time.January, 2, 9, 30, 0, 0, time.UTC), logged)
+
+	// A record from december was logged the year before.
+	logged, ok = syslogRecordTime("Dec 31 23:59:59 host workload-abc_c1[123]: hello\n", now)
+	assert.True(t, ok)
+	assert.Equal(t, 2023, logged.Year())
+
+	logged, ok = syslogRecordTime("2024-01-02T09:45:00.123456+00:00 host workload-abc_c1[123]: hello\n", now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2024, time.January, 2, 9, 45, 0, 123456000, time.UTC), logged.UTC())
+
+	_, ok = syslogRecordTime("garbage\n", now)
+	assert.False(t, ok)
+
+	assert.True(t, logRecordSince("Jan  2 09:30:00 host workload-abc_c1[123]: hello\n", now.Add(-time.Hour), now))
+	assert.False(t, logRecordSince("Jan  2 08:30:00 host workload-abc_c1[123]: hello\n", now.Add(-time.Hour), now))
+	assert.True(t, logRecordSince("garbage\n", now.Add(-time.Hour), now))
+}
+
+func Test_tailLogRecords(t *testing.T) {
+	records := []logRecord{{0, "a1"}, {1, "b1"}, {0, "a2"}, {0, "a3"}, {1, "b2"}, {1, "b3"}, {0, "a4"}}
+
+	assert.Equal(t, []logRecord{{0, "a3"}, {1, "b2"}, {1, "b3"}, {0, "a4"}}, tailLogRecords(records, 2))
+	assert.Equal(t, records, tailLogRecords(records, 10))
+	assert.Equal(t, []logRecord{}, tailLogRecords(records, 0))
+}
+
+func Test_matchLogSource(t *testing.T) {
+	sources := []LogSource{{Match: "abc_c1", Prefix: "c1"}, {Match: "abc_c2", Prefix: "c2"}}
+
+	assert.Equal(t, 1, matchLogSource(sources, "Jan  2 09:30:00 host workload-abc_c2[123]: hello\n"))
+	assert.Equal(t, -1, matchLogSource(sources, "Jan  2 09:30:00 host other abc_c2: hello\n"))
+	assert.Equal(t, -1, matchLogSource(sources, "Jan  2 09:30:00 host workload-xyz_c1[123]: hello\n"))
+}
+
+func Test_FormatProgress(t *testing.T) {
+	assert.Equal(t, "512 B", FormatByteSize(512))
+	assert.Equal(t, "1.5 KiB", FormatByteSize(1536))
+	assert.Equal(t, "2.0 GiB", FormatByteSize(2*1024*1024*1024))
+
+	assert.Equal(t, "Uploading: [>                             ]   0.00% 0 B/1.0 KiB", FormatProgress("Uploading", 0, 1024))
+	assert.Equal(t, "Uploading: [===============>              ]  50.00% 512 B/1.0 KiB", FormatProgress("Uploading", 512, 1024))
+	assert.Equal(t, "Uploading: [==============================] 100.00% 1.0 KiB/1.0 KiB", FormatProgress("Uploading", 2048, 1024))
+	assert.Equal(t, "Downloading: 512 B", FormatProgress("Downloading", 512, 0))
+}
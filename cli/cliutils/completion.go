@@ -0,0 +1,98 @@
+package cliutils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// The hint actions that complete the names of exchange resources in the shell completion. They are called while the
+// user is typing, so they never print errors or exit, they return no names when the exchange cannot be queried. The
+// exchange url, the org and the credentials come from HZN_EXCHANGE_URL, HZN_ORG_ID and HZN_EXCHANGE_USER_AUTH, which
+// can be set in the hzn configuration files.
+
+// The timeout of the completion queries, in seconds. It is short so that the shell does not hang.
+const COMPLETION_TIMEOUT_S = 5
+
+func CompleteOrgs() []string {
+	orgs := completionGet("orgs", "orgs")
+	if org := os.Getenv("HZN_ORG_ID"); org != "" && len(orgs) == 0 {
+		orgs = []string{org}
+	}
+	return orgs
+}
+
+func CompleteServices() []string {
+	return completionGet("orgs/"+os.Getenv("HZN_ORG_ID")+"/services", "services")
+}
+
+func CompletePatterns() []string {
+	return completionGet("orgs/"+os.Getenv("HZN_ORG_ID")+"/patterns", "patterns")
+}
+
+func CompleteDeploymentPolicies() []string {
+	return completionGet("orgs/"+os.Getenv("HZN_ORG_ID")+"/business/policies", "businessPolicy")
+}
+
+func CompleteNodes() []string {
+	return completionGet("orgs/"+os.Getenv("HZN_ORG_ID")+"/nodes", "nodes")
+}
+
+// Returns the sorted ids of the resources in the listKey map of the exchange response, without the org of the user.
+func completionGet(urlSuffix string, listKey string) []string {
+	exchUrl := strings.TrimSuffix(os.Getenv("HZN_EXCHANGE_URL"), "/")
+	org := os.Getenv("HZN_ORG_ID")
+	creds := os.Getenv("HZN_EXCHANGE_USER_AUTH")
+	if exchUrl == "" || org == "" || creds == "" {
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, exchUrl+"/"+urlSuffix, nil)
+	if err != nil {
+		return nil
+	}
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Authorization", fmt.Sprintf("Basic %v", base64.StdEncoding.EncodeToString([]byte(OrgAndCreds(org, creds)))))
+
+	httpClient := GetHTTPClient(COMPLETION_TIMEOUT_S)
+	if err := TrustIcpCert(httpClient); err != nil {
+		return nil
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil
+	}
+	return completionIds(bodyBytes, listKey, org)
+}
+
+func completionIds(bodyBytes []byte, listKey string, org string) []string {
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(bodyBytes, &body); err != nil {
+		return nil
+	}
+	resources := map[string]json.RawMessage{}
+	if list, ok := body[listKey]; ok {
+		if err := json.Unmarshal(list, &resources); err != nil {
+			return nil
+		}
+	}
+	ids := []string{}
+	for id := range resources {
+		ids = append(ids, strings.TrimPrefix(id, org+"/"))
+	}
+	sort.Strings(ids)
+	return ids
+}
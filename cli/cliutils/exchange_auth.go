@@ -0,0 +1,62 @@
+package cliutils
+
+import (
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/i18n"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+// The env vars that make hzn authenticate to the exchange with the access tokens of an OIDC identity provider
+const (
+	HZN_EXCHANGE_OIDC_TOKEN_URL          = "HZN_EXCHANGE_OIDC_TOKEN_URL"
+	HZN_EXCHANGE_OIDC_CLIENT_ID          = "HZN_EXCHANGE_OIDC_CLIENT_ID"
+	HZN_EXCHANGE_OIDC_CLIENT_SECRET      = "HZN_EXCHANGE_OIDC_CLIENT_SECRET"
+	HZN_EXCHANGE_OIDC_SCOPE              = "HZN_EXCHANGE_OIDC_SCOPE"
+	HZN_EXCHANGE_OIDC_REFRESH_TOKEN_FILE = "HZN_EXCHANGE_OIDC_REFRESH_TOKEN_FILE"
+)
+
+var exchangeAuthProvider exchange.AuthProvider
+var exchangeAuthProviderOnce sync.Once
+
+// Returns the auth provider of the exchange calls when HZN_EXCHANGE_OIDC_TOKEN_URL is set, nil otherwise. The calls are
+// then authenticated with the access tokens of the identity provider, the exchange credentials only name the user or
+// node. The refresh token is read from HZN_EXCHANGE_OIDC_REFRESH_TOKEN_FILE, and written back to it when the identity
+// provider rotates it. The client credentials are used when there is no refresh token, or when the identity provider
+// rejects it.
+func GetExchangeAuthProvider() exchange.AuthProvider {
+	exchangeAuthProviderOnce.Do(func() {
+		tokenURL := os.Getenv(HZN_EXCHANGE_OIDC_TOKEN_URL)
+		if tokenURL == "" {
+			return
+		}
+
+		refreshToken := ""
+		var storeRefreshToken func(string) error
+		if refreshTokenFile := os.Getenv(HZN_EXCHANGE_OIDC_REFRESH_TOKEN_FILE); refreshTokenFile != "" {
+			b, err := ioutil.ReadFile(refreshTokenFile)
+			if err != nil {
+				Fatal(CLI_INPUT_ERROR, i18n.GetMessagePrinter().Sprintf("Unable to read the refresh token from %v: %v", refreshTokenFile, err))
+			}
+			refreshToken = strings.TrimSpace(string(b))
+			storeRefreshToken = func(refreshToken string) error {
+				return ioutil.WriteFile(refreshTokenFile, []byte(refreshToken+"\n"), 0600)
+			}
+		}
+
+		exchangeAuthProvider = exchange.NewOIDCAuthProvider(tokenURL, os.Getenv(HZN_EXCHANGE_OIDC_CLIENT_ID), os.Getenv(HZN_EXCHANGE_OIDC_CLIENT_SECRET),
+			os.Getenv(HZN_EXCHANGE_OIDC_SCOPE), refreshToken, 0, storeRefreshToken)
+
+		// the exchange calls made through the exchange package use it too, but not the calls to the CSS
+		exchUrl := os.Getenv("HZN_EXCHANGE_URL")
+		if exchUrl == "" {
+			exchUrl = GetExchangeUrlFromAnax()
+		}
+		if exchUrl != "" {
+			exchange.RegisterAuthProvider("", exchUrl, exchangeAuthProvider)
+		}
+	})
+	return exchangeAuthProvider
+}
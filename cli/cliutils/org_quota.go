@@ -0,0 +1,74 @@
+package cliutils
+
+import (
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/i18n"
+	"net/http"
+	"regexp"
+)
+
+// The exchange URLs that create a node or a service, which the exchange refuses with a 403 when the org quota is reached.
+var orgQuotaUrlRegex = regexp.MustCompile(`^orgs/([^/?]+)/(nodes|services)(/[^/?]+)?(\?.*)?$`)
+
+// Returns the org and the quota resource of the exchange call, empty when the call does not create a resource that the
+// org quota limits. A node is created with a PUT of its URL, a service with a POST to the services URL.
+func orgQuotaResource(method string, urlSuffix string) (string, string) {
+	if m := orgQuotaUrlRegex.FindStringSubmatch(urlSuffix); m == nil {
+		return "", ""
+	} else if m[2] == exchange.ORG_QUOTA_NODES && method == http.MethodPut && m[3] != "" {
+		return m[1], exchange.ORG_QUOTA_NODES
+	} else if m[2] == exchange.ORG_QUOTA_SERVICES && method == http.MethodPost && m[3] == "" {
+		return m[1], exchange.ORG_QUOTA_SERVICES
+	}
+	return "", ""
+}
+
+// Get the usage of the org of the resources, nodes or services, and the limit of the org. It returns nil when they can
+// not be read with the credentials, only the org admins can read the org status.
+func GetOrgQuotaUsage(exchUrl string, org string, resource string, credentials string) *exchange.OrgQuotaUsage {
+	var orgs exchange.GetOrganizationResponse
+	if httpCode := ExchangeGet("Exchange", exchUrl, "orgs/"+org, credentials, []int{200, 401, 403, 404}, &orgs); httpCode != 200 {
+		return nil
+	}
+	theOrg, ok := orgs.Orgs[org]
+	if !ok {
+		return nil
+	}
+
+	var status exchange.OrgStatus
+	if httpCode := ExchangeGet("Exchange", exchUrl, "orgs/"+org+"/status", credentials, []int{200, 401, 403, 404}, &status); httpCode != 200 {
+		return nil
+	}
+
+	usage := &exchange.OrgQuotaUsage{Max: theOrg.Limits.GetMax(resource)}
+	if resource == exchange.ORG_QUOTA_NODES {
+		usage.Used = status.NumberOfNodes
+	} else if status.NumberOfServices != nil {
+		usage.Used = *status.NumberOfServices
+	} else {
+		// the older exchanges do not count the services in the org status
+		var services exchange.GetServicesResponse
+		if httpCode := ExchangeGet("Exchange", exchUrl, "orgs/"+org+"/services", credentials, []int{200, 401, 403, 404}, &services); httpCode == 200 {
+			usage.Used = len(services.Services)
+		} else if httpCode != 404 {
+			return nil
+		}
+	}
+	return usage
+}
+
+// Exit with a quota error when the org has reached its quota of the resource. It is called when the exchange refused to
+// create a node or a service with a 403, which is otherwise reported as an access error.
+func CheckOrgQuota(exchUrl string, org string, resource string, credentials string) {
+	usage := GetOrgQuotaUsage(exchUrl, org, resource, credentials)
+	if usage == nil || !usage.Reached() {
+		return
+	}
+
+	msgPrinter := i18n.GetMessagePrinter()
+	if resource == exchange.ORG_QUOTA_NODES {
+		Fatal(QUOTA_ERROR, msgPrinter.Sprintf("organization %v has reached its limit of %v nodes, it has %v nodes. Remove the unused nodes, or ask an organization admin to raise the limit with 'hzn exchange org update --max-nodes'.", org, usage.Max, usage.Used))
+	} else {
+		Fatal(QUOTA_ERROR, msgPrinter.Sprintf("organization %v has reached its limit of %v services, it has %v services. Remove the unused services, or ask an organization admin to raise the limit with 'hzn exchange org update --max-services'.", org, usage.Max, usage.Used))
+	}
+}
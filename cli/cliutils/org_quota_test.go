@@ -0,0 +1,79 @@
+//go:build unit
+// +build unit
+
+package cliutils
+
+import (
+	"github.com/open-horizon/anax/exchange"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_orgQuotaResource(t *testing.T) {
+	tests := []struct {
+		method    string
+		urlSuffix string
+		org       string
+		resource  string
+	}{
+		{http.MethodPut, "orgs/myorg/nodes/node1?noheartbeat=true", "myorg", exchange.ORG_QUOTA_NODES},
+		{http.MethodPut, "orgs/myorg/nodes/node1", "myorg", exchange.ORG_QUOTA_NODES},
+		{http.MethodPost, "orgs/myorg/services", "myorg", exchange.ORG_QUOTA_SERVICES},
+		{http.MethodPatch, "orgs/myorg/nodes/node1", "", ""},
+		{http.MethodPut, "orgs/myorg/nodes/node1/policy", "", ""},
+		{http.MethodPut, "orgs/myorg/services/svc1", "", ""},
+		{http.MethodPost, "orgs/myorg/services/svc1/dockauths", "", ""},
+	}
+
+	for _, test := range tests {
+		if org, resource := orgQuotaResource(test.method, test.urlSuffix); org != test.org || resource != test.resource {
+			t.Errorf("%v %v: expected %v %v, got %v %v", test.method, test.urlSuffix, test.org, test.resource, org, resource)
+		}
+	}
+}
+
+// The services are counted from the org status, or listed when the exchange does not count them.
+func Test_GetOrgQuotaUsage(t *testing.T) {
+	servicesCounted := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/orgs/myorg":
+			w.Write([]byte(`{"orgs":{"myorg":{"label":"myorg","limits":{"maxNodes":10,"maxServices":2}}}}`))
+		case "/orgs/myorg/status":
+			if servicesCounted {
+				w.Write([]byte(`{"numberOfUsers":1,"numberOfNodes":4,"numberOfServices":2}`))
+			} else {
+				w.Write([]byte(`{"numberOfUsers":1,"numberOfNodes":4}`))
+			}
+		case "/orgs/myorg/services":
+			w.Write([]byte(`{"services":{"myorg/svc1":{"url":"svc1"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	if usage := GetOrgQuotaUsage(server.URL, "myorg", exchange.ORG_QUOTA_NODES, "myorg/user:pw"); usage == nil {
+		t.Errorf("the node usage is not returned")
+	} else if usage.Used != 4 || usage.Max != 10 || usage.Reached() {
+		t.Errorf("wrong node usage %v", usage)
+	}
+
+	if usage := GetOrgQuotaUsage(server.URL, "myorg", exchange.ORG_QUOTA_SERVICES, "myorg/user:pw"); usage == nil {
+		t.Errorf("the service usage is not returned")
+	} else if usage.Used != 2 || usage.Max != 2 || !usage.Reached() {
+		t.Errorf("wrong service usage %v", usage)
+	}
+
+	servicesCounted = false
+	if usage := GetOrgQuotaUsage(server.URL, "myorg", exchange.ORG_QUOTA_SERVICES, "myorg/user:pw"); usage == nil {
+		t.Errorf("the listed service usage is not returned")
+	} else if usage.Used != 1 || usage.Reached() {
+		t.Errorf("wrong listed service usage %v", usage)
+	}
+
+	if usage := GetOrgQuotaUsage(server.URL, "otherorg", exchange.ORG_QUOTA_NODES, "myorg/user:pw"); usage != nil {
+		t.Errorf("unexpected usage %v of an unknown org", usage)
+	}
+}
@@ -0,0 +1,222 @@
+package cliutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/i18n"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// The output formats of the commands that display resources, selected with the global --output-format flag.
+const (
+	OUTPUT_JSON  = "json"
+	OUTPUT_YAML  = "yaml"
+	OUTPUT_TABLE = "table"
+)
+
+func GetOutputFormats() []string {
+	return []string{OUTPUT_JSON, OUTPUT_YAML, OUTPUT_TABLE}
+}
+
+// OutputFormat returns the output format given with --output-format, json when it is not set.
+func OutputFormat() string {
+	if Opts.Output == nil || *Opts.Output == "" {
+		return OUTPUT_JSON
+	}
+	return *Opts.Output
+}
+
+// MarshalOutput marshals v in the output format. The json format is the same indented json the commands always displayed,
+// so that scripts that parse it keep working. Like json.MarshalIndent, there is no trailing newline.
+func MarshalOutput(v interface{}) ([]byte, error) {
+	switch OutputFormat() {
+	case OUTPUT_YAML:
+		// go through json so that the json tags of the structs are used for the yaml keys
+		jsonBytes, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		yamlBytes, err := yaml.JSONToYAML(jsonBytes)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.TrimRight(yamlBytes, "\n"), nil
+	case OUTPUT_TABLE:
+		return marshalTable(v)
+	default:
+		return json.MarshalIndent(v, "", JSON_INDENT)
+	}
+}
+
+// FormatOutput returns data in the output format. It replaces DisplayAsJson for the output of the commands, the json
+// format is still displayed without escaping <, > and &.
+func FormatOutput(data interface{}) (string, error) {
+	if OutputFormat() == OUTPUT_JSON {
+		return DisplayAsJson(data)
+	}
+	output, err := MarshalOutput(data)
+	if err != nil {
+		return "", err
+	}
+	return string(output) + "\n", nil
+}
+
+// A table has a row for each object in a list, or for each object in a map with the key in the first column, and a
+// column for each attribute of the objects that is a scalar or a list of scalars. Any other value is displayed as a
+// list of attributes and values.
+func marshalTable(v interface{}) ([]byte, error) {
+	jsonBytes, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	decoder.UseNumber()
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	header := []string{}
+	rows := [][]string{}
+
+	switch g := generic.(type) {
+	case []interface{}:
+		if objs, ok := toObjects(g); ok && len(objs) != 0 {
+			header = tableColumns(objs)
+			for _, obj := range objs {
+				rows = append(rows, tableRow(obj, header))
+			}
+		} else {
+			header = []string{"VALUE"}
+			for _, val := range g {
+				rows = append(rows, []string{tableCell(val)})
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(g))
+		values := make([]interface{}, 0, len(g))
+		for k := range g {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			values = append(values, g[k])
+		}
+
+		if objs, ok := toObjects(values); ok && len(objs) != 0 {
+			columns := tableColumns(objs)
+			header = append([]string{"ID"}, columns...)
+			for ix, obj := range objs {
+				rows = append(rows, append([]string{keys[ix]}, tableRow(obj, columns)...))
+			}
+		} else {
+			header = []string{"ATTRIBUTE", "VALUE"}
+			for ix, k := range keys {
+				rows = append(rows, []string{k, tableCell(values[ix])})
+			}
+		}
+	default:
+		return []byte(tableCell(generic)), nil
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 3, ' ', 0)
+	for ix, col := range header {
+		header[ix] = strings.ToUpper(col)
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// Returns the objects in the list, false if one of the elements is not an object.
+func toObjects(list []interface{}) ([]map[string]interface{}, bool) {
+	objs := make([]map[string]interface{}, 0, len(list))
+	for _, elem := range list {
+		obj, ok := elem.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		objs = append(objs, obj)
+	}
+	return objs, true
+}
+
+// The sorted attributes of the objects that can be displayed in a column.
+func tableColumns(objs []map[string]interface{}) []string {
+	columns := []string{}
+	for _, obj := range objs {
+		for k, val := range obj {
+			if val != nil && isTableCell(val) && !cutil.SliceContains(columns, k) {
+				columns = append(columns, k)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+func tableRow(obj map[string]interface{}, columns []string) []string {
+	row := make([]string, 0, len(columns))
+	for _, col := range columns {
+		if val, ok := obj[col]; ok && isTableCell(val) {
+			row = append(row, tableCell(val))
+		} else {
+			row = append(row, "")
+		}
+	}
+	return row
+}
+
+func isTableCell(val interface{}) bool {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		return false
+	case []interface{}:
+		for _, elem := range v {
+			if !isTableCell(elem) {
+				return false
+			}
+			if _, ok := elem.([]interface{}); ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func tableCell(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []interface{}:
+		if isTableCell(v) {
+			cells := make([]string, 0, len(v))
+			for _, elem := range v {
+				cells = append(cells, tableCell(elem))
+			}
+			return strings.Join(cells, ",")
+		}
+	case map[string]interface{}:
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+	// the nested objects are displayed as compact json
+	if jsonBytes, err := json.Marshal(val); err != nil {
+		return i18n.GetMessagePrinter().Sprintf("<invalid value: %v>", err)
+	} else {
+		return string(jsonBytes)
+	}
+}
@@ -0,0 +1,85 @@
+//go:build unit
+// +build unit
+
+package cliutils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type outputTestNode struct {
+	Name     string            `json:"name"`
+	Pattern  string            `json:"pattern"`
+	Services []string          `json:"services"`
+	Props    map[string]string `json:"props"`
+}
+
+func setOutputFormat(format string) func() {
+	saved := Opts.Output
+	Opts.Output = &format
+	return func() { Opts.Output = saved }
+}
+
+func Test_MarshalOutput(t *testing.T) {
+	nodes := map[string]outputTestNode{
+		"myorg/node2": {Name: "node2", Services: []string{"s1", "s2"}},
+		"myorg/node1": {Name: "node1", Pattern: "myorg/pat", Props: map[string]string{"a": "b"}},
+	}
+
+	// json is the default and stays the same
+	defer setOutputFormat("")()
+	out, err := MarshalOutput(map[string]string{"a": "<b>"})
+	assert.Nil(t, err)
+	assert.Equal(t, "{\n  \"a\": \"\\u003cb\\u003e\"\n}", string(out))
+
+	setOutputFormat(OUTPUT_YAML)
+	out, err = MarshalOutput(nodes["myorg/node1"])
+	assert.Nil(t, err)
+	assert.Equal(t, "name: node1\npattern: myorg/pat\nprops:\n  a: b\nservices: null", string(out))
+
+	// a map of objects has a row for each object, the nested objects are not in the table
+	setOutputFormat(OUTPUT_TABLE)
+	out, err = MarshalOutput(nodes)
+	assert.Nil(t, err)
+	assert.Equal(t, "ID            NAME    PATTERN     SERVICES\n"+
+		"myorg/node1   node1   myorg/pat   \n"+
+		"myorg/node2   node2               s1,s2", string(out))
+
+	// a single object is a list of attributes
+	out, err = MarshalOutput(nodes["myorg/node1"])
+	assert.Nil(t, err)
+	assert.Equal(t, "ATTRIBUTE   VALUE\n"+
+		"name        node1\n"+
+		"pattern     myorg/pat\n"+
+		"props       {\"a\":\"b\"}\n"+
+		"services    ", string(out))
+
+	out, err = MarshalOutput([]string{"node1", "node2"})
+	assert.Nil(t, err)
+	assert.Equal(t, "VALUE\nnode1\nnode2", string(out))
+
+	// FormatOutput keeps the trailing newline of DisplayAsJson
+	setOutputFormat(OUTPUT_JSON)
+	output, err := FormatOutput(map[string]string{"a": "<b>"})
+	assert.Nil(t, err)
+	assert.Equal(t, "{\n  \"a\": \"<b>\"\n}\n", output)
+}
+
+func Test_completionIds(t *testing.T) {
+	body := []byte(`{"services": {"myorg/svc2_1.0.0_arm": {}, "myorg/svc1_1.0.0_amd64": {"url": "svc1"}, "other/svc3": {}}, "lastIndex": 0}`)
+	assert.Equal(t, []string{"other/svc3", "svc1_1.0.0_amd64", "svc2_1.0.0_arm"}, completionIds(body, "services", "myorg"))
+	assert.Equal(t, []string{}, completionIds(body, "patterns", "myorg"))
+	assert.Nil(t, completionIds([]byte("not json"), "services", "myorg"))
+}
+
+func Test_MarshalIndentJSON(t *testing.T) {
+
+	// the output that other commands read is json in any output format
+	for _, format := range GetOutputFormats() {
+		restore := setOutputFormat(format)
+		assert.Equal(t, "{\n  \"name\": \"node1\",\n  \"pattern\": \"\",\n  \"services\": null,\n  \"props\": null\n}", MarshalIndentJSON(outputTestNode{Name: "node1"}, "test"), format)
+		restore()
+	}
+}
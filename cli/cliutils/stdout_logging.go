@@ -7,49 +7,112 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/i18n"
 )
 
+// The options of the log records displayed for a service.
+type LogOptions struct {
+	Follow bool      // Keep displaying the new records until the command is interrupted.
+	Since  time.Time // Only display the records logged at or after this time, all the records when zero.
+	Tail   int       // Only display the last records of each container, all the records when negative.
+}
+
+// A container whose log records are displayed. When the records of several containers are displayed together, each
+// record is prefixed with the name of its container.
+type LogSource struct {
+	Match  string // The name of the container for the container engine, or the tag of its records in the syslog.
+	Prefix string // Displayed in front of each record, nothing when empty.
+}
+
+// Display the logs of a service container run by docker.
 func LogMac(instanceId string, tailing bool) {
+	LogContainers("docker", []LogSource{{Match: instanceId}}, LogOptions{Follow: tailing, Tail: -1})
+}
+
+// Display the logs of a service container run by podman. Podman logs to journald by default, not to syslog, so the
+// logs are retrieved from podman.
+func LogPodman(instanceId string, tailing bool) {
+	LogContainers("podman", []LogSource{{Match: instanceId}}, LogOptions{Follow: tailing, Tail: -1})
+}
+
+// Display the logs of a service container from the syslog.
+func LogLinux(instanceId string, tailing bool) {
+	LogSyslog([]LogSource{{Match: instanceId}}, LogOptions{Follow: tailing, Tail: -1})
+}
+
+// Returns true if the container engine that the CLI talks to is podman.
+func IsPodmanEndpoint() bool {
+	return strings.Contains(cutil.GetDockerEndpoint(), "podman")
+}
+
+// Display the logs of the containers from the container engine. The logs of all the containers are read at the same
+// time, so that the records of all of them are displayed as they are logged when following the logs.
+func LogContainers(engine string, sources []LogSource, opts LogOptions) {
 	msgPrinter := i18n.GetMessagePrinter()
-	dockerCommand := "docker logs $(docker ps -q --filter name=" + instanceId + ")"
-	if tailing {
-		dockerCommand = "docker logs -f $(docker ps -q --filter name=" + instanceId + ")"
-	}
-	fmt.Print(dockerCommand)
-	fmt.Print("\n")
-	cmd := exec.Command("/bin/sh", "-c", dockerCommand)
-	cmdReader, err := cmd.StdoutPipe()
-	if err != nil {
-		Fatal(EXEC_CMD_ERROR, msgPrinter.Sprintf("Error creating StdoutPipe for command: %v", err))
-	}
-	// Assign a single pipe to Command.Stdout and Command.Stderr
-	cmd.Stderr = cmd.Stdout
-	// Combine stdout and stderr to single reader to be able to print messages in correct order.
-	scanner := bufio.NewScanner(cmdReader)
-	// Goroutine to print Stdout and Stderr while Docker logs command is running
-	go func() {
-		// scanner.Scan() == scanner.EOF go func() will end.
-		for scanner.Scan() {
-			msg := scanner.Text()
-			fmt.Println(msg)
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	for _, src := range sources {
+		dockerCommand := engine + " logs" + engineLogFlags(opts) + " $(" + engine + " ps -q --filter name=" + src.Match + ")"
+		fmt.Print(dockerCommand)
+		fmt.Print("\n")
+		cmd := exec.Command("/bin/sh", "-c", dockerCommand)
+		cmdReader, err := cmd.StdoutPipe()
+		if err != nil {
+			Fatal(EXEC_CMD_ERROR, msgPrinter.Sprintf("Error creating StdoutPipe for command: %v", err))
 		}
-	}()
-	err = cmd.Start()
-	if err != nil {
-		Fatal(EXEC_CMD_ERROR, msgPrinter.Sprintf("Error starting command: %v", err))
+		// Assign a single pipe to Command.Stdout and Command.Stderr
+		cmd.Stderr = cmd.Stdout
+		if err := cmd.Start(); err != nil {
+			Fatal(EXEC_CMD_ERROR, msgPrinter.Sprintf("Error starting command: %v", err))
+		}
+
+		wg.Add(1)
+		go func(cmd *exec.Cmd, reader io.Reader, prefix string) {
+			defer wg.Done()
+			// Combine stdout and stderr to single reader to be able to print messages in correct order.
+			scanner := bufio.NewScanner(reader)
+			for scanner.Scan() {
+				lock.Lock()
+				printLogRecord(prefix, scanner.Text()+"\n")
+				lock.Unlock()
+			}
+			// The command is waited for once all of its output is read.
+			if err := cmd.Wait(); err != nil {
+				Fatal(EXEC_CMD_ERROR, msgPrinter.Sprintf("Error waiting for command: %v", err))
+			}
+		}(cmd, cmdReader, src.Prefix)
 	}
-	err = cmd.Wait()
-	if err != nil {
-		Fatal(EXEC_CMD_ERROR, msgPrinter.Sprintf("Error waiting for command: %v", err))
+	wg.Wait()
+}
+
+// Returns the flags of the logs command of the container engine for the options.
+func engineLogFlags(opts LogOptions) string {
+	flags := ""
+	if !opts.Since.IsZero() {
+		flags += " --since " + opts.Since.Format(time.RFC3339)
 	}
-	return
+	if opts.Tail >= 0 {
+		flags += fmt.Sprintf(" --tail %v", opts.Tail)
+	}
+	if opts.Follow {
+		flags += " -f"
+	}
+	return flags
 }
 
-func LogLinux(instanceId string, tailing bool) {
+// A record of the syslog for one of the containers.
+type logRecord struct {
+	source int
+	line   string
+}
+
+// Display the logs of the containers from the syslog.
+func LogSyslog(sources []LogSource, opts LogOptions) {
 	msgPrinter := i18n.GetMessagePrinter()
 	// Determine the system log file based on linux distribution
 	sysLogPath := "/var/log/messages"
@@ -68,13 +131,20 @@ func LogLinux(instanceId string, tailing bool) {
 	defer cutil.CloseFileLogError(file)
 	// Check file stats and capture the current size of the file if we will be tailing it.
 	var file_size int64
-	if tailing {
+	if opts.Follow {
 		fi, err := file.Stat()
 		if err != nil {
 			Fatal(NOT_FOUND, msgPrinter.Sprintf("%v could not get stats: %v", sysLogPath, err))
 		}
 		file_size = fi.Size()
 	}
+
+	// The records already in the syslog are held until the end of the file is reached when only the last ones are
+	// displayed, the records logged after that are displayed as they come.
+	var held []logRecord
+	holding := opts.Tail >= 0
+	now := time.Now()
+
 	// Setup a file reader
 	reader := bufio.NewReader(file)
 	// Start reading records. The syslog could be rotated while we're tailing it. Log rotation occurs when
@@ -84,8 +154,15 @@ func LogLinux(instanceId string, tailing bool) {
 	for {
 		// Get a record (delimited by EOL) from syslog.
 		if line, err := reader.ReadString('\n'); err != nil {
+			if holding {
+				for _, r := range tailLogRecords(held, opts.Tail) {
+					printLogRecord(sources[r.source].Prefix, r.line)
+				}
+				held = nil
+				holding = false
+			}
 			// Any error we get back, even EOF, is treated the same if we are not tailing. Just return to the caller.
-			if !tailing {
+			if !opts.Follow {
 				return
 			}
 			// When we're tailing and we hit EOF, briefly sleep to allow more records to appear in syslog.
@@ -96,13 +173,17 @@ func LogLinux(instanceId string, tailing bool) {
 				// ignore the error and keep trying.
 				Verbose(msgPrinter.Sprintf("Error reading from %v: %v", sysLogPath, err))
 			}
-		} else if strings.Contains(line, "workload-") && strings.Contains(line, instanceId) {
+		} else if source := matchLogSource(sources, line); source >= 0 && logRecordSince(line, opts.Since, now) {
 			// If the requested service id is in the current syslog record, display it.
-			fmt.Print(string(line))
+			if holding {
+				held = append(held, logRecord{source: source, line: line})
+			} else {
+				printLogRecord(sources[source].Prefix, line)
+			}
 		}
 		// Re-check syslog file size via stats in case syslog was logrotated.
 		// If were tailing and there was a non-EOF error, we will always come here.
-		if tailing {
+		if opts.Follow {
 			fi_new, err := os.Stat(sysLogPath)
 			if err != nil {
 				Verbose(msgPrinter.Sprintf("Unable to state %v: %v", sysLogPath, err))
@@ -131,3 +212,82 @@ func LogLinux(instanceId string, tailing bool) {
 		}
 	}
 }
+
+// Returns the index of the container whose syslog tag is in the record, or -1 when the record is not from a service
+// container.
+func matchLogSource(sources []LogSource, line string) int {
+	if !strings.Contains(line, "workload-") {
+		return -1
+	}
+	for ix, src := range sources {
+		if strings.Contains(line, src.Match) {
+			return ix
+		}
+	}
+	return -1
+}
+
+// Returns the last n records of each container, in the order they were logged.
+func tailLogRecords(records []logRecord, n int) []logRecord {
+	counts := make(map[int]int)
+	keep := make([]bool, len(records))
+	kept := 0
+	for ix := len(records) - 1; ix >= 0; ix-- {
+		if counts[records[ix].source] < n {
+			counts[records[ix].source] += 1
+			keep[ix] = true
+			kept += 1
+		}
+	}
+
+	tail := make([]logRecord, 0, kept)
+	for ix, r := range records {
+		if keep[ix] {
+			tail = append(tail, r)
+		}
+	}
+	return tail
+}
+
+// Returns true if the syslog record was logged at or after the given time. The records whose time cannot be read are
+// always displayed.
+func logRecordSince(line string, since time.Time, now time.Time) bool {
+	if since.IsZero() {
+		return true
+	} else if logged, ok := syslogRecordTime(line, now); ok {
+		return !logged.Before(since)
+	}
+	return true
+}
+
+// Returns the time at the start of a syslog record. The record starts with an RFC 3339 timestamp when the syslog uses
+// the high precision format, otherwise with the traditional timestamp which has no year and is in local time.
+func syslogRecordTime(line string, now time.Time) (time.Time, bool) {
+	if ix := strings.Index(line, " "); ix > 0 {
+		if t, err := time.Parse(time.RFC3339Nano, line[:ix]); err == nil {
+			return t, true
+		}
+	}
+
+	if len(line) < len(time.Stamp) {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(time.Stamp, line[:len(time.Stamp)], now.Location())
+	if err != nil {
+		return time.Time{}, false
+	}
+	// A record from december read in january was logged the year before.
+	t = t.AddDate(now.Year(), 0, 0)
+	if t.After(now.AddDate(0, 0, 1)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t, true
+}
+
+func printLogRecord(prefix string, line string) {
+	if prefix != "" {
+		fmt.Print("[" + prefix + "] " + line)
+	} else {
+		fmt.Print(line)
+	}
+}
@@ -0,0 +1,172 @@
+package compose_deployment
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/cli/dev"
+	"github.com/open-horizon/anax/cli/kube_deployment"
+	"github.com/open-horizon/anax/cli/plugin_registry"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/rsapss-tool/sign"
+	"path/filepath"
+	"sort"
+)
+
+const COMPOSE_DEPLOYMENT_CONFIG_TYPE = "compose"
+
+func init() {
+	plugin_registry.Register(COMPOSE_DEPLOYMENT_CONFIG_TYPE, NewComposeDeploymentConfigPlugin())
+}
+
+// The compose deployment config plugin owns the deployment configs that refer to a docker compose file:
+//
+//	"deployment": {
+//	  "compose": "docker-compose.yaml"
+//	}
+//
+// The file might be relative to the service definition file. When the service is published, the file is replaced by
+// its base64 encoded contents, which the agent translates into the native deployment.
+type ComposeDeploymentConfigPlugin struct {
+}
+
+func NewComposeDeploymentConfigPlugin() plugin_registry.DeploymentConfigPlugin {
+	return new(ComposeDeploymentConfigPlugin)
+}
+
+func (p *ComposeDeploymentConfigPlugin) Sign(dep map[string]interface{}, privKey *rsa.PrivateKey, ctx plugin_registry.PluginContext) (bool, string, string, error) {
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if owned, err := p.Validate(dep, nil); !owned || err != nil {
+		return owned, "", "", err
+	}
+
+	// Grab the compose file from the deployment config. The file might be relative to the service definition file.
+	composeFilePath := filepath.Clean(dep[containermessage.COMPOSE_DEPLOYMENT_KEY].(string))
+	if currentDir, ok := (ctx.Get("currentDir")).(string); !ok {
+		return true, "", "", errors.New(msgPrinter.Sprintf("plugin context must include 'currentDir' as the current directory of the service definition file"))
+	} else if !filepath.IsAbs(composeFilePath) {
+		composeFilePath = filepath.Join(currentDir, composeFilePath)
+	}
+
+	b64, err := kube_deployment.ConvertFileToB64String(composeFilePath)
+	if err != nil {
+		return true, "", "", errors.New(msgPrinter.Sprintf("unable to read compose file %v, error %v", dep[containermessage.COMPOSE_DEPLOYMENT_KEY], err))
+	}
+
+	// Make sure the agent will be able to translate the compose file before signing it.
+	if _, err := containermessage.ConvertComposeDeployment(b64); err != nil {
+		return true, "", "", errors.New(msgPrinter.Sprintf("compose file %v is not supported: %v", composeFilePath, err))
+	}
+	dep[containermessage.COMPOSE_DEPLOYMENT_KEY] = b64
+
+	// Stringify and sign the deployment string.
+	deployment, err := json.Marshal(dep)
+	if err != nil {
+		return true, "", "", errors.New(msgPrinter.Sprintf("failed to marshal %v deployment string %v, error %v", COMPOSE_DEPLOYMENT_CONFIG_TYPE, dep, err))
+	}
+	depStr := string(deployment)
+
+	hasher := sha256.New()
+	_, err = hasher.Write(deployment)
+	if err != nil {
+		return true, "", "", err
+	}
+	sig, err := sign.Sha256HashOfInput(privKey, hasher)
+
+	if err != nil {
+		return true, "", "", errors.New(msgPrinter.Sprintf("problem signing %v deployment string: %v", COMPOSE_DEPLOYMENT_CONFIG_TYPE, err))
+	}
+
+	return true, depStr, sig, nil
+}
+
+// The images can only be returned once the deployment has been signed, which is when the compose file has been read in.
+// An error is returned when the compose file cannot be converted.
+func (p *ComposeDeploymentConfigPlugin) GetContainerImages(dep interface{}) (bool, []string, error) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	imageList := []string{}
+	if owned, err := p.Validate(dep, nil); !owned || err != nil {
+		return owned, imageList, err
+	}
+
+	dd, err := containermessage.ConvertComposeDeployment(dep.(map[string]interface{})[containermessage.COMPOSE_DEPLOYMENT_KEY].(string))
+	if err != nil {
+		cliutils.Verbose(msgPrinter.Sprintf("unable to convert the %v deployment to get its images: %v", COMPOSE_DEPLOYMENT_CONFIG_TYPE, err))
+		return true, imageList, errors.New(msgPrinter.Sprintf("unable to convert the %v deployment, error %v", COMPOSE_DEPLOYMENT_CONFIG_TYPE, err))
+	}
+	for _, svc := range dd.Services {
+		imageList = append(imageList, svc.Image)
+	}
+	sort.Strings(imageList)
+	return true, imageList, nil
+}
+
+// Return the default config object, which is nil in this case.
+func (p *ComposeDeploymentConfigPlugin) DefaultConfig(imageInfo interface{}) interface{} {
+	return nil
+}
+
+// Return the default cluster config object, which is nil in this case.
+func (p *ComposeDeploymentConfigPlugin) DefaultClusterConfig() interface{} {
+	return nil
+}
+
+func (p *ComposeDeploymentConfigPlugin) Validate(dep interface{}, cdep interface{}) (bool, error) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if dc, ok := dep.(map[string]interface{}); !ok {
+		return false, nil
+	} else if c, ok := dc[containermessage.COMPOSE_DEPLOYMENT_KEY]; !ok {
+		return false, nil
+	} else if cs, ok := c.(string); !ok {
+		return true, errors.New(msgPrinter.Sprintf("%v must have a string type value, has %T", containermessage.COMPOSE_DEPLOYMENT_KEY, c))
+	} else if len(cs) == 0 {
+		return true, errors.New(msgPrinter.Sprintf("%v must be a non-empty string", containermessage.COMPOSE_DEPLOYMENT_KEY))
+	} else if _, ok := dc["services"]; ok {
+		return true, errors.New(msgPrinter.Sprintf("the 'deployment' field cannot have both 'services' and '%v'", containermessage.COMPOSE_DEPLOYMENT_KEY))
+	} else {
+		return true, nil
+	}
+}
+
+func (p *ComposeDeploymentConfigPlugin) StartTest(homeDirectory string, userInputFile string, configFiles []string, configType string, noFSS bool, userCreds string, secretsFiles map[string]string) bool {
+	return p.unsupportedTest(homeDirectory, dev.SERVICE_START_COMMAND)
+}
+
+func (p *ComposeDeploymentConfigPlugin) StopTest(homeDirectory string) bool {
+	return p.unsupportedTest(homeDirectory, dev.SERVICE_STOP_COMMAND)
+}
+
+// The services in a compose file are tested with docker compose, so the test commands are not supported.
+func (p *ComposeDeploymentConfigPlugin) unsupportedTest(homeDirectory string, command string) bool {
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	// Perform the common execution setup.
+	dir, _, _ := dev.CommonExecutionSetup(homeDirectory, "", dev.SERVICE_COMMAND, command)
+
+	// Get the service definition, so that we can check if we own the deployment config object.
+	serviceDef, sderr := dev.GetServiceDefinition(dir, dev.SERVICE_DEFINITION_FILE)
+	if sderr != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, fmt.Sprintf("'%v %v' %v", dev.SERVICE_COMMAND, command, sderr))
+	}
+
+	if owned, _ := p.Validate(serviceDef.Deployment, nil); !owned {
+		return false
+	}
+
+	cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' not supported for services using a %v deployment configuration, use 'docker compose up' to test the services", dev.SERVICE_COMMAND, command, COMPOSE_DEPLOYMENT_CONFIG_TYPE))
+	// For the compiler
+	return true
+}
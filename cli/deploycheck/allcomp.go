@@ -24,7 +24,7 @@ import (
 func AllCompatible(org string, userPw string, nodeIds []string, haGroupName string, nodeArch string, nodeType string, nodeNamespace string, nodeOrg string,
 	nodePolFile string, nodeUIFile string, businessPolId string, businessPolFile string,
 	patternId string, patternFile string, servicePolFile string, svcDefFiles []string,
-	checkAllSvcs bool, showDetail bool) {
+	checkAllSvcs bool, showDetail bool, showExplanation bool) {
 
 	msgPrinter := i18n.GetMessagePrinter()
 
@@ -146,6 +146,9 @@ func AllCompatible(org string, userPw string, nodeIds []string, haGroupName stri
 			if !showDetail {
 				compOutput.Input = nil
 			}
+			if !showExplanation {
+				compOutput.Explanation = nil
+			}
 			totalOutput[nId] = compOutput
 		}
 	}
@@ -155,11 +158,11 @@ func AllCompatible(org string, userPw string, nodeIds []string, haGroupName stri
 	var err error
 	if haGroupName == "" && len(nIds) == 1 {
 		for _, o := range totalOutput {
-			output, err = cliutils.DisplayAsJson(o)
+			output, err = cliutils.FormatOutput(o)
 			break
 		}
 	} else {
-		output, err = cliutils.DisplayAsJson(totalOutput)
+		output, err = cliutils.FormatOutput(totalOutput)
 	}
 
 	if err != nil {
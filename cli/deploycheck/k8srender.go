@@ -0,0 +1,111 @@
+package deploycheck
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/cli/kube_deployment"
+	"github.com/open-horizon/anax/common"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/kube_operator"
+	"github.com/open-horizon/anax/persistence"
+	"os"
+	"path/filepath"
+)
+
+// The agreement id used for the names of the rendered objects when the user does not provide one
+const RENDER_DEFAULT_AGREEMENT_ID = "agreementid"
+
+// K8sRender resolves the clusterDeployment of the given service definition file the same way the agent does when it
+// installs the operator, and displays the resulting kubernetes objects. Nothing is sent to the exchange or to a cluster.
+func K8sRender(svcDefFile string, nodeUIFile string, namespace string, agId string) {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	// do not show the kube operator logs on the console
+	flag.Set("stderrthreshold", "3")
+
+	if agId == "" {
+		agId = RENDER_DEFAULT_AGREEMENT_ID
+	}
+
+	var svcFile common.ServiceFile
+	cliutils.Unmarshal(cliutils.ReadJsonFile(svcDefFile), &svcFile, svcDefFile)
+
+	kd, err := getRenderKubeDeployment(svcFile.ClusterDeployment, filepath.Dir(svcDefFile))
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Error getting the clusterDeployment from %v: %v", svcDefFile, err))
+	}
+
+	// the env vars come from the default values in the service definition, overridden by the node user input
+	envVars := map[string]string{}
+	for _, ui := range svcFile.UserInputs {
+		if ui.DefaultValue != "" {
+			envVars[ui.Name] = ui.DefaultValue
+		}
+	}
+	if nodeUIFile != "" {
+		uif, err := common.NewUserInputFileFromJsonBytes(cliutils.ReadJsonFile(nodeUIFile))
+		if err != nil {
+			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("Error parsing the node user input file %v: %v", nodeUIFile, err))
+		}
+		for _, ui := range uif.GetServiceUserInput() {
+			if ui.GetServiceUrl() != svcFile.URL || (svcFile.Org != "" && ui.GetServiceOrgid() != "" && ui.GetServiceOrgid() != svcFile.Org) {
+				continue
+			}
+			for name, value := range ui.GetInputMap() {
+				if err := cutil.NativeToEnvVariableMap(envVars, name, value); err != nil {
+					cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Error converting user input %v: %v", name, err))
+				}
+			}
+		}
+	}
+
+	objs, _, err := kube_operator.RenderDeployment(kd.OperatorYamlArchive, kd.Metadata, envVars, agId, namespace, kube_operator.ImagePolicy{})
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("Error rendering the clusterDeployment: %v", err))
+	}
+
+	if output, err := cliutils.FormatOutput(objs); err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal the rendered objects: %v", err))
+	} else {
+		fmt.Fprintf(os.Stdout, "%v", output)
+	}
+}
+
+// get the kube deployment from the clusterDeployment attribute of the service definition. The operatorYamlArchive
+// can be a file path, relative to the directory of the service definition file, or the base64 encoded archive.
+func getRenderKubeDeployment(clusterDeployment interface{}, svcDefDir string) (*persistence.KubeDeploymentConfig, error) {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	var kd *persistence.KubeDeploymentConfig
+	var err error
+	switch cd := clusterDeployment.(type) {
+	case string:
+		kd, err = persistence.GetKubeDeployment(cd)
+	case map[string]interface{}:
+		kd = new(persistence.KubeDeploymentConfig)
+		err = kd.FromPersistentForm(cd)
+	default:
+		return nil, fmt.Errorf(msgPrinter.Sprintf("the service definition does not have a clusterDeployment"))
+	}
+	if err != nil {
+		return nil, err
+	} else if kd.OperatorYamlArchive == "" {
+		return nil, fmt.Errorf(msgPrinter.Sprintf("operatorYamlArchive is missing in the clusterDeployment"))
+	}
+
+	archivePath := filepath.Clean(kd.OperatorYamlArchive)
+	if !filepath.IsAbs(archivePath) {
+		archivePath = filepath.Join(svcDefDir, archivePath)
+	}
+	if _, err := os.Stat(archivePath); err == nil {
+		if kd.OperatorYamlArchive, err = kube_deployment.ConvertFileToB64String(archivePath); err != nil {
+			return nil, fmt.Errorf(msgPrinter.Sprintf("unable to read kube operator %v, error %v", archivePath, err))
+		}
+	} else if _, err := base64.StdEncoding.DecodeString(kd.OperatorYamlArchive); err != nil {
+		return nil, fmt.Errorf(msgPrinter.Sprintf("operatorYamlArchive %v is neither a file nor a base64 encoded archive", kd.OperatorYamlArchive))
+	}
+	return kd, nil
+}
@@ -31,7 +31,7 @@ func readServicePolicyFile(filePath string, inputFileStruct *exchangecommon.Serv
 }
 
 // check if the policies are compatible
-func PolicyCompatible(org string, userPw string, nodeIds []string, haGroupName string, nodeArch string, nodeType string, nodeNamespace string, nodePolFile string, businessPolId string, businessPolFile string, servicePolFile string, svcDefFiles []string, checkAllSvcs bool, showDetail bool) {
+func PolicyCompatible(org string, userPw string, nodeIds []string, haGroupName string, nodeArch string, nodeType string, nodeNamespace string, nodePolFile string, businessPolId string, businessPolFile string, servicePolFile string, svcDefFiles []string, checkAllSvcs bool, showDetail bool, showExplanation bool) {
 
 	msgPrinter := i18n.GetMessagePrinter()
 
@@ -126,6 +126,9 @@ func PolicyCompatible(org string, userPw string, nodeIds []string, haGroupName s
 			if !showDetail {
 				compOutput.Input = nil
 			}
+			if !showExplanation {
+				compOutput.Explanation = nil
+			}
 			totalOutput[nId] = compOutput
 		}
 	}
@@ -135,11 +138,11 @@ func PolicyCompatible(org string, userPw string, nodeIds []string, haGroupName s
 	var err error
 	if haGroupName == "" && len(nIds) == 1 {
 		for _, o := range totalOutput {
-			output, err = cliutils.DisplayAsJson(o)
+			output, err = cliutils.FormatOutput(o)
 			break
 		}
 	} else {
-		output, err = cliutils.DisplayAsJson(totalOutput)
+		output, err = cliutils.FormatOutput(totalOutput)
 	}
 
 	if err != nil {
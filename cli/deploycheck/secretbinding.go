@@ -73,7 +73,7 @@ func SecretBindingCompatible(org string, userPw string, nodeId string, nodeArch
 		}
 
 		// display the output
-		output, err := cliutils.DisplayAsJson(compOutput)
+		output, err := cliutils.FormatOutput(compOutput)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn deploycheck secretbinding' output: %v", err))
 		}
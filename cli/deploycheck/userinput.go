@@ -111,7 +111,7 @@ func UserInputCompatible(org string, userPw string, nodeId string, nodeArch stri
 		}
 
 		// display the output
-		output, err := cliutils.DisplayAsJson(compOutput)
+		output, err := cliutils.FormatOutput(compOutput)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn deploycheck userinput' output: %v", err))
 		}
@@ -0,0 +1,155 @@
+package dev
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/open-horizon/anax/common"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/kube_operator"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// The kubeconfig context of the local development cluster that the cluster services are started in. When it is not
+// set, the current context of the kubeconfig is used.
+const DEVTOOL_HZN_KUBE_CONTEXT = "HZN_DEV_KUBE_CONTEXT"
+
+// The file in the project that records the cluster services started by 'hzn dev service start', so that they can be
+// stopped.
+const CLUSTER_TEST_FILE = ".hzn_dev_cluster.json"
+
+// The cluster services are only started in the clusters that kind and k3d create on the developer's machine, their
+// kubeconfig contexts have these prefixes.
+var devKubeContextPrefixes = []string{"kind-", "k3d-"}
+
+// A service started as a kubernetes operator in the development cluster.
+type ClusterTestService struct {
+	URL         string `json:"url"`
+	Org         string `json:"org"`
+	Version     string `json:"version"`
+	AgreementId string `json:"agreementId"` // the mock agreement id, it names the env var config map of the operator
+}
+
+// The cluster services started by 'hzn dev service start', in the order they were started. The dependencies are started
+// before the services that require them.
+type ClusterTest struct {
+	Context   string               `json:"context"`
+	Namespace string               `json:"namespace"`
+	Services  []ClusterTestService `json:"services"`
+}
+
+// Returns true if the kubeconfig context is the context of a kind or k3d cluster.
+func IsDevKubeContext(name string) bool {
+	for _, prefix := range devKubeContextPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a client of the local development cluster and the name of its kubeconfig context. The kubeconfig is found
+// the same way kubectl finds it.
+func NewDevKubeClient() (*kube_operator.KubeClient, string, error) {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: os.Getenv(DEVTOOL_HZN_KUBE_CONTEXT)}
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+
+	rawConfig, err := clientConfig.RawConfig()
+	if err != nil {
+		return nil, "", errors.New(msgPrinter.Sprintf("unable to read the kubeconfig, error: %v", err))
+	}
+	kubeContext := rawConfig.CurrentContext
+	if overrides.CurrentContext != "" {
+		kubeContext = overrides.CurrentContext
+	}
+	if !IsDevKubeContext(kubeContext) {
+		return nil, "", errors.New(msgPrinter.Sprintf("kubeconfig context %v is not a kind or k3d cluster, set %v to the context of a local development cluster", kubeContext, DEVTOOL_HZN_KUBE_CONTEXT))
+	}
+
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", errors.New(msgPrinter.Sprintf("unable to get the config of kubeconfig context %v, error: %v", kubeContext, err))
+	}
+	kc, err := kube_operator.NewKubeClientForConfig(restConfig)
+	if err != nil {
+		return nil, "", errors.New(msgPrinter.Sprintf("unable to create a client of kubeconfig context %v, error: %v", kubeContext, err))
+	}
+	return kc, kubeContext, nil
+}
+
+// Creates the namespace in the development cluster if it does not exist. The agent's namespace always exists in a
+// real cluster, the operators installed in other namespaces create their own.
+func EnsureDevNamespace(kc *kube_operator.KubeClient, namespace string) error {
+	if _, err := kc.Client.CoreV1().Namespaces().Get(context.Background(), namespace, metav1.GetOptions{}); err == nil {
+		return nil
+	} else if !k8serrors.IsNotFound(err) {
+		return err
+	}
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	if _, err := kc.Client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{}); err != nil && !k8serrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// Returns the cluster services started in the project, nil if there are none.
+func GetClusterTest(directory string) (*ClusterTest, error) {
+	if found, err := FileExists(directory, CLUSTER_TEST_FILE); err != nil || !found {
+		return nil, err
+	}
+	ct := new(ClusterTest)
+	if err := GetFile(directory, CLUSTER_TEST_FILE, ct); err != nil {
+		return nil, err
+	}
+	return ct, nil
+}
+
+// Records the cluster services started in the project, or removes the record when there are none left.
+func SaveClusterTest(directory string, ct *ClusterTest) error {
+	if ct == nil || len(ct.Services) == 0 {
+		if err := os.Remove(path.Join(directory, CLUSTER_TEST_FILE)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return CreateFile(directory, CLUSTER_TEST_FILE, ct)
+}
+
+// Returns true if the service is already started in the cluster.
+func (ct *ClusterTest) Started(url string, org string, version string) bool {
+	for _, s := range ct.Services {
+		if s.URL == url && s.Org == org && s.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// The operator archive of a cluster service is a file relative to its project. When the service is hardened as a
+// dependency of another project, the path is made absolute so that the operator can be started from that project.
+func AbsoluteOperatorArchive(sf *common.ServiceFile, project string) error {
+	cdep, ok := sf.ClusterDeployment.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	archive, ok := cdep["operatorYamlArchive"].(string)
+	if !ok || archive == "" || filepath.IsAbs(archive) {
+		return nil
+	}
+	absProject, err := filepath.Abs(project)
+	if err != nil {
+		return err
+	}
+	cdep["operatorYamlArchive"] = filepath.Join(absProject, archive)
+	return nil
+}
@@ -0,0 +1,76 @@
+//go:build unit
+// +build unit
+
+package dev
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/open-horizon/anax/common"
+)
+
+func Test_IsDevKubeContext(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"kind-kind":      true,
+		"k3d-dev":        true,
+		"minikube":       false,
+		"prod-cluster":   false,
+		"":               false,
+		"my-kind-kind":   false,
+		"default/k3d-ab": false,
+	} {
+		if IsDevKubeContext(name) != expected {
+			t.Errorf("IsDevKubeContext(%v) should be %v", name, expected)
+		}
+	}
+}
+
+func Test_AbsoluteOperatorArchive(t *testing.T) {
+	sf := &common.ServiceFile{ClusterDeployment: map[string]interface{}{"operatorYamlArchive": "operator.tar.gz"}}
+	if err := AbsoluteOperatorArchive(sf, "/home/dev/project"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if archive := sf.ClusterDeployment.(map[string]interface{})["operatorYamlArchive"]; archive != filepath.Join("/home/dev/project", "operator.tar.gz") {
+		t.Errorf("wrong archive path %v", archive)
+	}
+
+	// An absolute path is kept.
+	sf = &common.ServiceFile{ClusterDeployment: map[string]interface{}{"operatorYamlArchive": "/tmp/operator.tar.gz"}}
+	if err := AbsoluteOperatorArchive(sf, "/home/dev/project"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if archive := sf.ClusterDeployment.(map[string]interface{})["operatorYamlArchive"]; archive != "/tmp/operator.tar.gz" {
+		t.Errorf("wrong archive path %v", archive)
+	}
+
+	// A service without a cluster deployment is ignored.
+	sf = &common.ServiceFile{}
+	if err := AbsoluteOperatorArchive(sf, "/home/dev/project"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func Test_ClusterTest_save(t *testing.T) {
+	dir := t.TempDir()
+	if ct, err := GetClusterTest(dir); err != nil || ct != nil {
+		t.Errorf("expected no cluster test, got %v %v", ct, err)
+	}
+
+	ct := &ClusterTest{Context: "kind-kind", Namespace: "openhorizon-agent", Services: []ClusterTestService{{URL: "svc", Org: "org", Version: "1.0.0", AgreementId: "ag1"}}}
+	if err := SaveClusterTest(dir, ct); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if read, err := GetClusterTest(dir); err != nil || read == nil {
+		t.Errorf("expected a cluster test, got %v %v", read, err)
+	} else if !read.Started("svc", "org", "1.0.0") || read.Started("svc", "org", "2.0.0") {
+		t.Errorf("wrong started services %v", read.Services)
+	}
+
+	// The record is removed once all the services are stopped.
+	ct.Services = nil
+	if err := SaveClusterTest(dir, ct); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if read, err := GetClusterTest(dir); err != nil || read != nil {
+		t.Errorf("expected no cluster test, got %v %v", read, err)
+	}
+}
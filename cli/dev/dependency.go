@@ -486,6 +486,13 @@ func fetchLocalProjectDependency(homeDirectory string, project string, userInput
 
 	cliutils.Verbose(msgPrinter.Sprintf("Found dependency %v, Org: %v", sDef.GetURL(), sDef.GetOrg()))
 
+	// A cluster service dependency keeps pointing to the operator archive in its own project.
+	if sf, ok := sDef.(*common.ServiceFile); ok {
+		if err := AbsoluteOperatorArchive(sf, project); err != nil {
+			return err
+		}
+	}
+
 	// restore the env vars
 	if proj_config_file != "" {
 		err = cliconfig.RestoreEnvVars(orig_env_vars, hzn_vars, metadata_vars)
@@ -846,6 +853,15 @@ func getServiceDefinition(homeDirectory, surl string, org string, version string
 	sDef_cliex.Sharable = serviceDef.Sharable
 	sDef_cliex.UserInputs = serviceDef.UserInputs
 	sDef_cliex.Deployment = dc
+
+	// The cluster deployment of a published service holds the operator archive itself, base64 encoded.
+	if serviceDef.ClusterDeployment != "" {
+		cdc := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(serviceDef.ClusterDeployment), &cdc); err != nil {
+			return nil, errors.New(msgPrinter.Sprintf("failed to unmarshal cluster deployment of %v/%v: %v", org, surl, err))
+		}
+		sDef_cliex.ClusterDeployment = cdc
+	}
 	sDef_cliex.MatchHardware = serviceDef.MatchHardware
 	sDef_cliex.RequiredServices = serviceDef.RequiredServices
 
@@ -0,0 +1,248 @@
+package dev
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/open-horizon/anax/cli/cliutils"
+	cliexchange "github.com/open-horizon/anax/cli/exchange"
+	"github.com/open-horizon/anax/common"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/i18n"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The architectures a service is published for when none are specified, the same as the build-all-arches target of the
+// Makefile of a new service project.
+var DEFAULT_PUBLISH_ARCHES = []string{"amd64", "arm", "arm64"}
+
+// The key of the digest of the pushed image in the metadata file written by docker buildx.
+const BUILDX_METADATA_DIGEST = "containerimage.digest"
+
+// Returns the docker platform that an image is built for to run on an architecture.
+func dockerPlatform(arch string) string {
+	switch arch {
+	case "arm":
+		// the base image of a new service project for arm is arm32v6, which also runs on armv7
+		return "linux/arm/v6"
+	default:
+		return "linux/" + arch
+	}
+}
+
+// Returns the images of the services of a native deployment, keyed by service name. The images that are already
+// referenced by digest are not returned, they are published as they are.
+func deploymentBuildImages(deployment interface{}) map[string]string {
+	images := make(map[string]string)
+	dep, ok := deployment.(map[string]interface{})
+	if !ok {
+		return images
+	}
+	services, ok := dep["services"].(map[string]interface{})
+	if !ok {
+		return images
+	}
+	for name, svc := range services {
+		if service, ok := svc.(map[string]interface{}); ok {
+			if image, ok := service["image"].(string); ok && image != "" {
+				if _, path, _, digest := cutil.ParseDockerImagePath(image); path != "" && digest == "" {
+					images[name] = image
+				}
+			}
+		}
+	}
+	return images
+}
+
+// Sets the images of the services of a native deployment, keyed by service name.
+func setDeploymentImages(deployment interface{}, images map[string]string) {
+	services := deployment.(map[string]interface{})["services"].(map[string]interface{})
+	for name, image := range images {
+		services[name].(map[string]interface{})["image"] = image
+	}
+}
+
+// Returns the Dockerfile that the image for an architecture is built from. When none is specified, Dockerfile.<arch> is
+// used if it exists in the build context, as in a new service project, otherwise Dockerfile.
+func archDockerfile(dockerfile string, buildContext string, arch string) string {
+	if dockerfile != "" {
+		return dockerfile
+	}
+	archFile := filepath.Join(buildContext, "Dockerfile."+arch)
+	if _, err := os.Stat(archFile); err == nil {
+		return archFile
+	}
+	return filepath.Join(buildContext, "Dockerfile")
+}
+
+// Builds an image for the platforms with docker buildx and pushes it. An image built for several platforms is pushed as a
+// manifest list. Returns the image referenced by the digest of what was pushed.
+func buildxImage(image string, platforms []string, dockerfile string, buildContext string) string {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	metadataFile, err := ioutil.TempFile("", "hzn-buildx-*.json")
+	if err != nil {
+		cliutils.Fatal(cliutils.FILE_IO_ERROR, msgPrinter.Sprintf("unable to create a temporary file: %v", err))
+	}
+	metadataFile.Close()
+	defer os.Remove(metadataFile.Name())
+
+	args := []string{"buildx", "build", "--platform", strings.Join(platforms, ","), "-f", dockerfile, "-t", image, "--push", "--metadata-file", metadataFile.Name(), buildContext}
+	msgPrinter.Printf("Building and pushing image %v for %v...", image, strings.Join(platforms, ", "))
+	msgPrinter.Println()
+	if cliutils.IsDryRun() {
+		msgPrinter.Printf("Skipping the build, would run: docker %v", strings.Join(args, " "))
+		msgPrinter.Println()
+		return image
+	}
+
+	cliutils.Verbose(msgPrinter.Sprintf("running: docker %v", strings.Join(args, " ")))
+	cmd := exec.Command("docker", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		cliutils.Fatal(cliutils.EXEC_CMD_ERROR, msgPrinter.Sprintf("docker buildx failed to build image %v: %v. docker buildx must be installed, with a builder that can build for %v, see 'docker buildx ls'.", image, err, strings.Join(platforms, ", ")))
+	}
+
+	var metadata map[string]interface{}
+	if metadataBytes, err := ioutil.ReadFile(metadataFile.Name()); err != nil {
+		cliutils.Fatal(cliutils.FILE_IO_ERROR, msgPrinter.Sprintf("unable to read the docker buildx metadata of image %v: %v", image, err))
+	} else if err := json.Unmarshal(metadataBytes, &metadata); err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("unable to parse the docker buildx metadata of image %v: %v", image, err))
+	}
+	digest, _ := metadata[BUILDX_METADATA_DIGEST].(string)
+	if digest == "" {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("could not find the digest of image %v in the docker buildx metadata.", image))
+	}
+
+	domain, path, _, _ := cutil.ParseDockerImagePath(image)
+	if domain != "" {
+		path = domain + "/" + path
+	}
+	return path + "@" + digest
+}
+
+// ServicePublish builds the images of a service project for each architecture with docker buildx, pushes them and
+// publishes the service definition of each architecture in the Exchange, with its images referenced by digest. The
+// service definition file of the project is read once for each architecture, with ARCH set to the architecture, so it
+// must use $ARCH for the arch of the service. With multiArch, each image is built once for all the architectures and
+// pushed as a manifest list, and the service definitions of all the architectures reference the same manifest list, so
+// the image names must not depend on $ARCH. The Exchange holds a service definition for each architecture in both cases.
+func ServicePublish(homeDirectory string, userCreds string, arches []string, multiArch bool, dockerfile string, buildContext string, keyFilePath string, pubKeyFilePath string, registryTokens []string, overwrite bool, servicePolicyFilePath string) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	// Get the setup info and context for running the command.
+	dir, err := setup(homeDirectory, true, true, userCreds)
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "'%v %v' %v", SERVICE_COMMAND, SERVICE_PUBLISH_COMMAND, err)
+	}
+	if userCreds == "" {
+		userCreds = os.Getenv(DEVTOOL_HZN_USER)
+	}
+	if len(arches) == 0 {
+		arches = DEFAULT_PUBLISH_ARCHES
+	}
+	if buildContext == "" {
+		buildContext = "."
+	}
+
+	// Read the service definition for each architecture.
+	defs := make([]*common.ServiceFile, 0, len(arches))
+	for _, arch := range arches {
+		if err := os.Setenv("ARCH", arch); err != nil {
+			cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, err.Error())
+		}
+		sf, err := GetServiceDefinition(dir, SERVICE_DEFINITION_FILE)
+		if err != nil {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "'%v %v' %v", SERVICE_COMMAND, SERVICE_PUBLISH_COMMAND, err)
+		} else if sf.Arch != arch {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("the arch of the service in %v is %v with ARCH set to %v, set it to $ARCH to publish the service for several architectures.", SERVICE_DEFINITION_FILE, sf.Arch, arch))
+		}
+		defs = append(defs, sf)
+	}
+
+	org := defs[0].Org
+	if org == "" {
+		org = os.Getenv(DEVTOOL_HZN_ORG)
+	}
+
+	// Build and push the images, then reference them by digest in the service definitions.
+	if multiArch {
+		images := deploymentBuildImages(defs[0].Deployment)
+		for i, sf := range defs[1:] {
+			if archImages := deploymentBuildImages(sf.Deployment); !imagesEqual(images, archImages) {
+				cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("the images of the service are %v for %v and %v for %v. The images must have the same names for all the architectures to be built as manifest lists, remove $ARCH from the image names.", images, arches[0], archImages, arches[i+1]))
+			}
+		}
+
+		platforms := make([]string, 0, len(arches))
+		for _, arch := range arches {
+			platforms = append(platforms, dockerPlatform(arch))
+		}
+		if dockerfile == "" {
+			dockerfile = filepath.Join(buildContext, "Dockerfile")
+		}
+
+		for _, name := range sortedImageServices(images) {
+			images[name] = buildxImage(images[name], platforms, dockerfile, buildContext)
+		}
+		for _, sf := range defs {
+			setDeploymentImages(sf.Deployment, images)
+		}
+	} else {
+		for i, sf := range defs {
+			images := deploymentBuildImages(sf.Deployment)
+			for _, name := range sortedImageServices(images) {
+				images[name] = buildxImage(images[name], []string{dockerPlatform(arches[i])}, archDockerfile(dockerfile, buildContext, arches[i]), buildContext)
+			}
+			setDeploymentImages(sf.Deployment, images)
+		}
+	}
+
+	// Publish the service definition of each architecture.
+	ec := cliutils.GetUserExchangeContext(org, userCreds)
+	for _, sf := range defs {
+		msgPrinter.Printf("Publishing service %v/%v version %v for %v...", org, sf.URL, sf.Version, sf.Arch)
+		msgPrinter.Println()
+		if err := common.ValidateService(exchange.GetHTTPServiceDefResolverHandler(ec), sf, msgPrinter); err != nil {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Error validating the service for %v: %v", sf.Arch, err))
+		}
+
+		// the images were pushed by docker buildx, they are not in the local image store
+		cliexchange.SignAndPublish(sf, org, userCreds, filepath.Join(dir, SERVICE_DEFINITION_FILE), keyFilePath, pubKeyFilePath, true, false, registryTokens, !overwrite)
+
+		if servicePolicyFilePath != "" {
+			cliexchange.ServiceAddPolicy(org, userCreds, fmt.Sprintf("%s/%s", org, cutil.FormExchangeIdForService(sf.URL, sf.Version, sf.Arch)), servicePolicyFilePath)
+		}
+	}
+}
+
+// Returns true if the images of two deployments are the same.
+func imagesEqual(images1 map[string]string, images2 map[string]string) bool {
+	if len(images1) != len(images2) {
+		return false
+	}
+	for name, image := range images1 {
+		if images2[name] != image {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the service names of the images in order, so that the images are built in the same order each time.
+func sortedImageServices(images map[string]string) []string {
+	names := make([]string, 0, len(images))
+	for name := range images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
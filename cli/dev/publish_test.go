@@ -0,0 +1,60 @@
+//go:build unit
+// +build unit
+
+package dev
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_deploymentBuildImages(t *testing.T) {
+	var dep interface{}
+	if err := json.Unmarshal([]byte(`{"services":{"svc1":{"image":"myreg/svc1_amd64:1.0.0"},"svc2":{"image":"myreg/svc2@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},"svc3":{"image":"svc3"}}}`), &dep); err != nil {
+		t.Fatalf("unable to unmarshal deployment, error: %v", err)
+	}
+
+	// the image referenced by digest is not built
+	images := deploymentBuildImages(dep)
+	expected := map[string]string{"svc1": "myreg/svc1_amd64:1.0.0", "svc3": "svc3"}
+	if !reflect.DeepEqual(images, expected) {
+		t.Errorf("wrong images %v, expected %v", images, expected)
+	}
+
+	images["svc1"] = "myreg/svc1_amd64@sha256:abc"
+	setDeploymentImages(dep, images)
+	if image := dep.(map[string]interface{})["services"].(map[string]interface{})["svc1"].(map[string]interface{})["image"]; image != "myreg/svc1_amd64@sha256:abc" {
+		t.Errorf("wrong image %v after setting the images", image)
+	}
+
+	if images := deploymentBuildImages(nil); len(images) != 0 {
+		t.Errorf("a service without deployment should have no images, got %v", images)
+	}
+}
+
+func Test_archDockerfile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile.arm64"), []byte("FROM alpine"), 0644); err != nil {
+		t.Fatalf("unable to write Dockerfile, error: %v", err)
+	}
+
+	if f := archDockerfile("", dir, "arm64"); f != filepath.Join(dir, "Dockerfile.arm64") {
+		t.Errorf("wrong Dockerfile %v for arm64", f)
+	}
+	if f := archDockerfile("", dir, "amd64"); f != filepath.Join(dir, "Dockerfile") {
+		t.Errorf("wrong Dockerfile %v for amd64", f)
+	}
+	if f := archDockerfile("build/Dockerfile", dir, "arm64"); f != "build/Dockerfile" {
+		t.Errorf("the specified Dockerfile should be used, got %v", f)
+	}
+
+	if p := dockerPlatform("arm"); p != "linux/arm/v6" {
+		t.Errorf("wrong platform %v for arm", p)
+	}
+	if p := dockerPlatform("ppc64le"); p != "linux/ppc64le" {
+		t.Errorf("wrong platform %v for ppc64le", p)
+	}
+}
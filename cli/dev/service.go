@@ -23,6 +23,7 @@ const SERVICE_START_COMMAND = "start"
 const SERVICE_STOP_COMMAND = "stop"
 const SERVICE_VERIFY_COMMAND = "verify"
 const SERVICE_LOG_COMMAND = "log"
+const SERVICE_PUBLISH_COMMAND = "publish"
 
 const SERVICE_NEW_DEFAULT_VERSION = "0.0.1"
 
@@ -390,7 +391,9 @@ func ServiceLog(homeDirectory string, serviceName string, containerName string,
 				msgPrinter.Println()
 			}
 
-			if runtime.GOOS == "darwin" || nonDefaultLogDriverUsed {
+			if cliutils.IsPodmanEndpoint() {
+				cliutils.LogPodman(msId+"-"+containerName, tailing)
+			} else if runtime.GOOS == "darwin" || nonDefaultLogDriverUsed {
 				cliutils.LogMac(msId+"-"+containerName, tailing)
 			} else {
 				cliutils.LogLinux(strings.ToLower(msId)+"_"+containerName, tailing)
@@ -417,6 +417,14 @@ func createContainerWorker() (*container.ContainerWorker, error) {
 		Collaborators: config.Collaborators{},
 	}
 
+	// Use the container runtime the agent is configured with. A containerd agent is not reached through the docker
+	// endpoint, the engine behind the endpoint is detected instead.
+	if anaxConfig, err := cliutils.GetAnaxConfig(cliutils.ANAX_CONFIG_FILE); err != nil {
+		cliutils.Verbose(i18n.GetMessagePrinter().Sprintf("Error getting ContainerRuntime from %v. %v", cliutils.ANAX_CONFIG_FILE, err))
+	} else if anaxConfig != nil && !anaxConfig.IsContainerdRuntime() {
+		config.Edge.ContainerRuntime = anaxConfig.Edge.ContainerRuntime
+	}
+
 	// Create the folder for SSL certificates (under authentication path)
 	if err := os.MkdirAll(config.GetESSSSLClientCertPath(), 0755); err != nil {
 		return nil, err
@@ -722,6 +730,20 @@ func StartContainers(deployment *containermessage.DeploymentDescription,
 	return getContainerNetworks(dc, id, cw)
 }
 
+// Returns the environment variables of a service started as a kubernetes operator, the same variables a service container
+// gets. They are put in the env var config map of the operator.
+func ClusterEnvVarMap(agreementId string,
+	specRef string,
+	globals []common.GlobalSet, // API attributes
+	defUserInputs []exchangecommon.UserInput, // indicates variable defaults
+	configUserInputs []policy.AbstractUserInput, // indicates configured variables
+	org string,
+	cw *container.ContainerWorker) (map[string]string, error) {
+
+	configVars := getConfiguredVariables(configUserInputs, specRef)
+	return createEnvVarMap(agreementId, globals, specRef, configVars, defUserInputs, org, cw, persistence.AttributesToEnvvarMap)
+}
+
 func ProcessStopDependencies(dir string, deps []*common.ServiceFile, cw *container.ContainerWorker) error {
 
 	// Log the stopping of dependencies if there are any.
@@ -854,11 +876,13 @@ func getContainerImages(containerConfig *events.ContainerConfig, currentUIs *com
 	return nil
 }
 
-func CreateNetwork(client *docker.Client, name string) (*docker.Network, error) {
+// Create a bridge network with the container runtime of the container worker, which is the configured runtime or the one
+// detected when the container worker was created.
+func CreateNetwork(client *docker.Client, rt container.ContainerRuntime, name string) (*docker.Network, error) {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
 
-	bridge, err := container.MakeBridge(client, name, true, false, true)
+	bridge, err := container.MakeBridge(client, rt, name, true, false, true, "")
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,127 @@
+package diagnose
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/open-horizon/anax/api"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/i18n"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The agent APIs that are collected into the support bundle, by the name of the file in the bundle.
+var bundleFiles = []struct {
+	name string
+	url  string
+}{
+	{"status.json", "status"},
+	{"workers.json", "status/workers"},
+	{"node.json", "node"},
+	{"agreements.json", "agreement"},
+	{"services.json", "service"},
+	{"eventlog.json", "eventlog"},
+}
+
+// Run the diagnostics of the agent and display the report. Unless noBundle is set, the report is saved with the recent
+// event logs and the worker status into a support bundle, a gzipped tar file.
+func Diagnose(outputFile string, noBundle bool) {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	var report api.Diagnostics
+	cliutils.HorizonGet("diagnostics", []int{200}, &report, false)
+
+	for _, c := range report.Checks {
+		msgPrinter.Printf("%-8s %-13s %-20s %s\n", strings.ToUpper(c.Status), c.Category, c.Name, c.Message)
+		if c.Remediation != "" && c.Status != api.DIAG_PASSED {
+			msgPrinter.Printf("%-43s %s\n", "", c.Remediation)
+		}
+	}
+	if report.Healthy {
+		msgPrinter.Printf("The node passed the diagnostics.\n")
+	} else {
+		msgPrinter.Printf("The node failed the diagnostics.\n")
+	}
+
+	if noBundle {
+		return
+	}
+
+	if outputFile == "" {
+		outputFile = fmt.Sprintf("hzn-diagnostics-%v.tar.gz", time.Now().Format("20060102-150405"))
+	}
+
+	files := map[string][]byte{}
+	if reportBytes, err := json.MarshalIndent(report, "", cliutils.JSON_INDENT); err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal the diagnostics report: %v", err))
+	} else {
+		files["diagnostics.json"] = reportBytes
+	}
+
+	// the bundle is still written when some of the APIs fail, the errors are saved in the bundle
+	errs := []string{}
+	for _, f := range bundleFiles {
+		var output string
+		if _, err := cliutils.HorizonGet(f.url, []int{200}, &output, true); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		var obj interface{}
+		if err := json.Unmarshal([]byte(output), &obj); err != nil {
+			files[f.name] = []byte(output)
+		} else if pretty, err := json.MarshalIndent(obj, "", cliutils.JSON_INDENT); err != nil {
+			files[f.name] = []byte(output)
+		} else {
+			files[f.name] = pretty
+		}
+	}
+	if len(errs) != 0 {
+		files["errors.txt"] = []byte(strings.Join(errs, "\n") + "\n")
+	}
+
+	if err := writeBundle(outputFile, files); err != nil {
+		cliutils.Fatal(cliutils.FILE_IO_ERROR, msgPrinter.Sprintf("failed to write the support bundle %v: %v", outputFile, err))
+	}
+	msgPrinter.Printf("The support bundle is saved in %v.\n", outputFile)
+}
+
+// Write the files into a gzipped tar file.
+func writeBundle(outputFile string, files map[string][]byte) error {
+	f, err := os.OpenFile(outputFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// the files are in a directory named after the bundle
+	dir := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(outputFile), ".gz"), ".tar")
+	now := time.Now()
+	for _, name := range names {
+		content := files[name]
+		hdr := &tar.Header{Name: dir + "/" + name, Mode: 0600, Size: int64(len(content)), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		} else if _, err := tw.Write(content); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
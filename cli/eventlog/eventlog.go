@@ -171,7 +171,7 @@ func ListSurfaced(long bool) {
 			long_output[i].SourceType = fullV.SourceType
 			long_output[i].Source = fullV.Source
 		}
-		jsonBytes, err := cliutils.DisplayAsJson(long_output)
+		jsonBytes, err := cliutils.FormatOutput(long_output)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal 'hzn eventlog surface' output: %v", err))
 		}
@@ -180,7 +180,7 @@ func ListSurfaced(long bool) {
 		if len(apiOutput) == 0 {
 			apiOutput = []persistence.SurfaceError{}
 		}
-		jsonBytes, err := cliutils.DisplayAsJson(apiOutput)
+		jsonBytes, err := cliutils.FormatOutput(apiOutput)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal 'hzn eventlog surface' output: %v", err))
 		}
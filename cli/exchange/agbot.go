@@ -1,7 +1,6 @@
 package exchange
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/open-horizon/anax/cli/cliutils"
 	"github.com/open-horizon/anax/exchange"
@@ -31,7 +30,7 @@ func AgbotList(org string, userPw string, agbot string, namesOnly bool) {
 		for a := range resp.Agbots {
 			agbots = append(agbots, a)
 		}
-		jsonBytes, err := json.MarshalIndent(agbots, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(agbots)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal 'exchange agbot list' output: %v", err))
 		}
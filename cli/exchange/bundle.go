@@ -0,0 +1,427 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/open-horizon/anax/businesspolicy"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/pmezard/go-difflib/difflib"
+	"sigs.k8s.io/yaml"
+)
+
+// The resources of an org, as written by 'hzn exchange export' and read by 'hzn exchange apply'. The services are keyed
+// by their exchange id without the org, the patterns and deployment policies by their name.
+type Bundle struct {
+	Org                string                                   `json:"org"`
+	Services           map[string]BundleService                 `json:"services,omitempty"`
+	Patterns           map[string]PatternInput                  `json:"patterns,omitempty"`
+	DeploymentPolicies map[string]businesspolicy.BusinessPolicy `json:"deploymentPolicies,omitempty"`
+}
+
+// A service of a bundle, with its service policy and the public keys that verify its deployment signatures. The policy
+// and the keys are left as they are in the exchange when they are not in the bundle.
+type BundleService struct {
+	exchange.ServiceDefinition
+	Policy *exchangecommon.ServicePolicy `json:"policy,omitempty"`
+	Keys   map[string]string             `json:"keys,omitempty"`
+}
+
+// A resource that 'hzn exchange apply' creates or updates. The current and desired resources are the yaml that is
+// compared, current is empty when the resource is not in the exchange.
+type bundleChange struct {
+	kind    string
+	name    string
+	current string
+	desired string
+	apply   func()
+}
+
+// Write the services, service policies, patterns and deployment policies of the org to stdout as a yaml bundle.
+func BundleExport(org, userPw string) {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	cliutils.SetWhetherUsingApiKey(userPw)
+	exchUrl := cliutils.GetExchangeUrl()
+	creds := cliutils.OrgAndCreds(org, userPw)
+
+	bundle := Bundle{Org: org, Services: map[string]BundleService{}, Patterns: map[string]PatternInput{}, DeploymentPolicies: map[string]businesspolicy.BusinessPolicy{}}
+
+	var services exchange.GetServicesResponse
+	cliutils.ExchangeGet("Exchange", exchUrl, "orgs/"+org+"/services", creds, []int{200, 404}, &services)
+	for id, s := range services.Services {
+		svcId := strings.TrimPrefix(id, org+"/")
+		bundle.Services[svcId] = getBundleService(exchUrl, org, svcId, creds, s, nil)
+	}
+
+	var patterns exchange.GetPatternResponse
+	cliutils.ExchangeGet("Exchange", exchUrl, "orgs/"+org+"/patterns", creds, []int{200, 404}, &patterns)
+	for id, p := range patterns.Patterns {
+		bundle.Patterns[strings.TrimPrefix(id, org+"/")] = toPatternInput(p)
+	}
+
+	var policies exchange.GetBusinessPolicyResponse
+	cliutils.ExchangeGet("Exchange", exchUrl, "orgs/"+org+"/business/policies", creds, []int{200, 404}, &policies)
+	for id, p := range policies.BusinessPolicy {
+		pol := p.BusinessPolicy
+		pol.Owner = ""
+		bundle.DeploymentPolicies[strings.TrimPrefix(id, org+"/")] = pol
+	}
+
+	output, err := bundleYaml(bundle)
+	if err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn exchange export' output: %v", err))
+	}
+	fmt.Print(output)
+}
+
+// Create or update the resources of a bundle in the exchange. The differences between the exchange and the bundle are
+// displayed before they are applied. Resources that are in the exchange but not in the bundle are not removed, so
+// applying the same bundle again changes nothing.
+func BundleApply(org, userPw, filePath string, force bool) {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	cliutils.SetWhetherUsingApiKey(userPw)
+	exchUrl := cliutils.GetExchangeUrl()
+	creds := cliutils.OrgAndCreds(org, userPw)
+
+	bundle, err := readBundle(filePath)
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("unable to read bundle %v: %v", filePath, err))
+	}
+	bundleOrg := org
+	if bundle.Org != "" {
+		bundleOrg = bundle.Org
+	}
+
+	changes := []bundleChange{}
+	unchanged := 0
+	addChange := func(c bundleChange) {
+		if c.current == c.desired {
+			unchanged += 1
+		} else {
+			changes = append(changes, c)
+		}
+	}
+
+	// The services are applied before the patterns and deployment policies that reference them, and the required services
+	// before the services that require them.
+	for _, svcId := range orderBundleServices(bundleOrg, bundle.Services) {
+		addChange(planBundleService(exchUrl, bundleOrg, svcId, creds, bundle.Services[svcId]))
+	}
+	for _, name := range sortedKeys(bundle.Patterns) {
+		addChange(planBundlePattern(exchUrl, bundleOrg, name, creds, bundle.Patterns[name]))
+	}
+	for _, name := range sortedKeys(bundle.DeploymentPolicies) {
+		addChange(planBundlePolicy(exchUrl, bundleOrg, name, creds, bundle.DeploymentPolicies[name]))
+	}
+
+	if len(changes) == 0 {
+		msgPrinter.Printf("The Exchange is up to date with the bundle, %v resources unchanged.", unchanged)
+		msgPrinter.Println()
+		return
+	}
+
+	creates := 0
+	for _, c := range changes {
+		if c.current == "" {
+			creates += 1
+		}
+		diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(c.current),
+			B:        difflib.SplitLines(c.desired),
+			FromFile: "exchange/" + c.kind + "/" + c.name,
+			ToFile:   "bundle/" + c.kind + "/" + c.name,
+			Context:  3,
+		})
+		fmt.Print(diff)
+	}
+	msgPrinter.Printf("%v resources to create, %v to update, %v unchanged.", creates, len(changes)-creates, unchanged)
+	msgPrinter.Println()
+
+	if cliutils.IsDryRun() {
+		return
+	} else if !force {
+		cliutils.ConfirmRemove(msgPrinter.Sprintf("Are you sure you want to apply these changes to org %v in the Horizon Exchange?", bundleOrg))
+	}
+	for _, c := range changes {
+		c.apply()
+	}
+	msgPrinter.Printf("Bundle applied.")
+	msgPrinter.Println()
+}
+
+// Returns the bundle in a yaml or json file.
+func readBundle(filePath string) (*Bundle, error) {
+	var content []byte
+	var err error
+	if filePath == "-" {
+		content, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		content, err = ioutil.ReadFile(filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	bundle := new(Bundle)
+	if err := yaml.UnmarshalStrict(content, bundle); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// Returns the service with its policy and keys. When the desired bundle service is given, only the policy and the keys
+// that it manages are returned.
+func getBundleService(exchUrl, org, svcId, creds string, s exchange.ServiceDefinition, desired *BundleService) BundleService {
+	s.Owner = ""
+	s.LastUpdated = ""
+	bs := BundleService{ServiceDefinition: s}
+
+	if desired == nil || desired.Policy != nil {
+		var policy exchange.ExchangeServicePolicy
+		if httpCode := cliutils.ExchangeGet("Exchange", exchUrl, "orgs/"+org+"/services/"+svcId+"/policy", creds, []int{200, 404}, &policy); httpCode == 200 {
+			bs.Policy = &policy.ServicePolicy
+		}
+	}
+
+	var keyNames []string
+	cliutils.ExchangeGet("Exchange", exchUrl, "orgs/"+org+"/services/"+svcId+"/keys", creds, []int{200, 404}, &keyNames)
+	for _, name := range keyNames {
+		if desired != nil {
+			if _, ok := desired.Keys[name]; !ok {
+				continue
+			}
+		}
+		var key []byte
+		if httpCode := cliutils.ExchangeGet("Exchange", exchUrl, "orgs/"+org+"/services/"+svcId+"/keys/"+name, creds, []int{200, 404}, &key); httpCode == 200 {
+			if bs.Keys == nil {
+				bs.Keys = map[string]string{}
+			}
+			bs.Keys[name] = string(key)
+		}
+	}
+	return bs
+}
+
+func planBundleService(exchUrl, org, svcId, creds string, desired BundleService) bundleChange {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if id := cutil.FormExchangeIdForService(desired.URL, desired.Version, desired.Arch); id != svcId {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("service %v in the bundle must be named %v after its url, version and arch", svcId, id))
+	}
+	desired.Owner = ""
+	desired.LastUpdated = ""
+
+	c := bundleChange{kind: "services", name: svcId, desired: mustBundleYaml(desired)}
+
+	var services exchange.GetServicesResponse
+	exists := cliutils.ExchangeGet("Exchange", exchUrl, "orgs/"+org+"/services/"+svcId, creds, []int{200, 404}, &services) == 200
+	var current BundleService
+	if exists {
+		current = getBundleService(exchUrl, org, svcId, creds, services.Services[org+"/"+svcId], &desired)
+		c.current = mustBundleYaml(current)
+	}
+
+	c.apply = func() {
+		if exists {
+			msgPrinter.Printf("Updating service %v/%v in the Exchange...", org, svcId)
+			msgPrinter.Println()
+			cliutils.ExchangePutPost("Exchange", http.MethodPut, exchUrl, "orgs/"+org+"/services/"+svcId, creds, []int{201}, desired.ServiceDefinition, nil)
+			recordServiceHistory(exchUrl, org, svcId, creds, HISTORY_UPDATE)
+		} else {
+			msgPrinter.Printf("Creating service %v/%v in the Exchange...", org, svcId)
+			msgPrinter.Println()
+			cliutils.ExchangePutPost("Exchange", http.MethodPost, exchUrl, "orgs/"+org+"/services", creds, []int{201}, desired.ServiceDefinition, nil)
+			recordServiceHistory(exchUrl, org, svcId, creds, HISTORY_CREATE)
+		}
+		if desired.Policy != nil && (current.Policy == nil || mustBundleYaml(current.Policy) != mustBundleYaml(desired.Policy)) {
+			cliutils.ExchangePutPost("Exchange", http.MethodPut, exchUrl, "orgs/"+org+"/services/"+svcId+"/policy", creds, []int{201}, desired.Policy, nil)
+			recordServicePolicyHistory(exchUrl, org, svcId, creds, HISTORY_UPDATE)
+		}
+		for _, name := range sortedKeys(desired.Keys) {
+			if current.Keys[name] != desired.Keys[name] {
+				cliutils.ExchangePutPost("Exchange", http.MethodPut, exchUrl, "orgs/"+org+"/services/"+svcId+"/keys/"+name, creds, []int{201}, []byte(desired.Keys[name]), nil)
+			}
+		}
+	}
+	return c
+}
+
+func planBundlePattern(exchUrl, org, name, creds string, desired PatternInput) bundleChange {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	c := bundleChange{kind: "patterns", name: name, desired: mustBundleYaml(desired)}
+
+	var patterns exchange.GetPatternResponse
+	exists := cliutils.ExchangeGet("Exchange", exchUrl, "orgs/"+org+"/patterns/"+name, creds, []int{200, 404}, &patterns) == 200
+	if exists {
+		c.current = mustBundleYaml(toPatternInput(patterns.Patterns[org+"/"+name]))
+	}
+
+	c.apply = func() {
+		if exists {
+			msgPrinter.Printf("Updating pattern %v/%v in the Exchange...", org, name)
+			msgPrinter.Println()
+			cliutils.ExchangePutPost("Exchange", http.MethodPut, exchUrl, "orgs/"+org+"/patterns/"+name, creds, []int{201}, desired, nil)
+		} else {
+			msgPrinter.Printf("Creating pattern %v/%v in the Exchange...", org, name)
+			msgPrinter.Println()
+			cliutils.ExchangePutPost("Exchange", http.MethodPost, exchUrl, "orgs/"+org+"/patterns/"+name, creds, []int{201}, desired, nil)
+		}
+	}
+	return c
+}
+
+func planBundlePolicy(exchUrl, org, name, creds string, desired businesspolicy.BusinessPolicy) bundleChange {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	desired.Owner = ""
+	c := bundleChange{kind: "deploymentPolicies", name: name, desired: mustBundleYaml(desired)}
+
+	var policies exchange.GetBusinessPolicyResponse
+	exists := cliutils.ExchangeGet("Exchange", exchUrl, "orgs/"+org+"/business/policies/"+name, creds, []int{200, 404}, &policies) == 200
+	if exists {
+		current := policies.BusinessPolicy[org+"/"+name].BusinessPolicy
+		current.Owner = ""
+		c.current = mustBundleYaml(current)
+	}
+
+	c.apply = func() {
+		if exists {
+			msgPrinter.Printf("Updating deployment policy %v/%v in the Exchange...", org, name)
+			msgPrinter.Println()
+			cliutils.ExchangePutPost("Exchange", http.MethodPut, exchUrl, "orgs/"+org+"/business/policies/"+name, creds, []int{201}, desired, nil)
+			recordDeploymentPolicyHistory(exchUrl, org, name, creds, HISTORY_UPDATE)
+		} else {
+			msgPrinter.Printf("Creating deployment policy %v/%v in the Exchange...", org, name)
+			msgPrinter.Println()
+			cliutils.ExchangePutPost("Exchange", http.MethodPost, exchUrl, "orgs/"+org+"/business/policies/"+name, creds, []int{201}, desired, nil)
+			recordDeploymentPolicyHistory(exchUrl, org, name, creds, HISTORY_CREATE)
+		}
+	}
+	return c
+}
+
+// Returns the ids of the services in the order they can be created, the services required by another service of the
+// bundle come before it. Services in a dependency cycle are returned in the order of their ids.
+func orderBundleServices(org string, services map[string]BundleService) []string {
+	ordered := []string{}
+	done := map[string]bool{}
+	remaining := sortedKeys(services)
+
+	requiresPending := func(s BundleService) bool {
+		for _, rs := range s.RequiredServices {
+			for _, id := range remaining {
+				other := services[id]
+				if !done[id] && rs.Org == org && rs.URL == other.URL && rs.Arch == other.Arch {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for len(remaining) > 0 {
+		next := []string{}
+		for _, id := range remaining {
+			if requiresPending(services[id]) {
+				next = append(next, id)
+			} else {
+				ordered = append(ordered, id)
+				done[id] = true
+			}
+		}
+		if len(next) == len(remaining) {
+			return append(ordered, next...)
+		}
+		remaining = next
+	}
+	return ordered
+}
+
+func toPatternInput(p exchange.Pattern) PatternInput {
+	var pi PatternInput
+	if b, err := json.Marshal(p); err == nil {
+		json.Unmarshal(b, &pi)
+	}
+	return pi
+}
+
+// Returns the yaml of a resource, without the empty attributes so that a resource read from the exchange compares equal
+// to the same resource written by hand.
+func bundleYaml(obj interface{}) (string, error) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(b, &generic); err != nil {
+		return "", err
+	}
+	if b, err = json.Marshal(pruneEmpty(generic)); err != nil {
+		return "", err
+	}
+	y, err := yaml.JSONToYAML(b)
+	return string(y), err
+}
+
+func mustBundleYaml(obj interface{}) string {
+	y, err := bundleYaml(obj)
+	if err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal %v: %v", obj, err))
+	}
+	return y
+}
+
+// Removes the null, empty string, empty list and empty object values from a json document.
+func pruneEmpty(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if e = pruneEmpty(e); isEmptyJson(e) {
+				delete(t, k)
+			} else {
+				t[k] = e
+			}
+		}
+	case []interface{}:
+		for ix, e := range t {
+			t[ix] = pruneEmpty(e)
+		}
+	}
+	return v
+}
+
+func isEmptyJson(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case string:
+		return t == ""
+	case map[string]interface{}:
+		return len(t) == 0
+	case []interface{}:
+		return len(t) == 0
+	}
+	return false
+}
+
+// Returns the keys of a map of resources in order, so that they are displayed and applied in the same order each time.
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
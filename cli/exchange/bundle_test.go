@@ -0,0 +1,88 @@
+package exchange
+
+import (
+	"github.com/open-horizon/anax/businesspolicy"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/exchangecommon"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_orderBundleServices(t *testing.T) {
+	services := map[string]BundleService{
+		"a_1.0.0_amd64": {ServiceDefinition: exchange.ServiceDefinition{URL: "a", Version: "1.0.0", Arch: "amd64", RequiredServices: []exchangecommon.ServiceDependency{{URL: "c", Org: "myorg", VersionRange: "1.0.0", Arch: "amd64"}}}},
+		"b_1.0.0_amd64": {ServiceDefinition: exchange.ServiceDefinition{URL: "b", Version: "1.0.0", Arch: "amd64"}},
+		"c_1.0.0_amd64": {ServiceDefinition: exchange.ServiceDefinition{URL: "c", Version: "1.0.0", Arch: "amd64", RequiredServices: []exchangecommon.ServiceDependency{{URL: "d", Org: "myorg", VersionRange: "1.0.0", Arch: "amd64"}}}},
+		"d_1.0.0_amd64": {ServiceDefinition: exchange.ServiceDefinition{URL: "d", Version: "1.0.0", Arch: "amd64"}},
+		// a service of another org is not in the bundle
+		"e_1.0.0_amd64": {ServiceDefinition: exchange.ServiceDefinition{URL: "e", Version: "1.0.0", Arch: "amd64", RequiredServices: []exchangecommon.ServiceDependency{{URL: "a", Org: "otherorg", VersionRange: "1.0.0", Arch: "amd64"}}}},
+	}
+
+	expected := []string{"b_1.0.0_amd64", "d_1.0.0_amd64", "e_1.0.0_amd64", "c_1.0.0_amd64", "a_1.0.0_amd64"}
+	if ordered := orderBundleServices("myorg", services); !reflect.DeepEqual(ordered, expected) {
+		t.Errorf("wrong order %v, expected %v", ordered, expected)
+	}
+
+	// a cycle does not lose services
+	services = map[string]BundleService{
+		"a_1.0.0_amd64": {ServiceDefinition: exchange.ServiceDefinition{URL: "a", Version: "1.0.0", Arch: "amd64", RequiredServices: []exchangecommon.ServiceDependency{{URL: "b", Org: "myorg", Arch: "amd64"}}}},
+		"b_1.0.0_amd64": {ServiceDefinition: exchange.ServiceDefinition{URL: "b", Version: "1.0.0", Arch: "amd64", RequiredServices: []exchangecommon.ServiceDependency{{URL: "a", Org: "myorg", Arch: "amd64"}}}},
+	}
+	if ordered := orderBundleServices("myorg", services); len(ordered) != 2 {
+		t.Errorf("wrong order %v", ordered)
+	}
+}
+
+func Test_bundleYaml_empty(t *testing.T) {
+	// a service read from the exchange has empty lists where a service written by hand has none
+	fromExchange := BundleService{ServiceDefinition: exchange.ServiceDefinition{URL: "a", Version: "1.0.0", Arch: "amd64", RequiredServices: []exchangecommon.ServiceDependency{}, UserInputs: []exchangecommon.UserInput{}}}
+	fromFile := BundleService{ServiceDefinition: exchange.ServiceDefinition{URL: "a", Version: "1.0.0", Arch: "amd64"}}
+
+	if a, b := mustBundleYaml(fromExchange), mustBundleYaml(fromFile); a != b {
+		t.Errorf("yaml should be the same, got\n%v\nand\n%v", a, b)
+	}
+
+	fromFile.Label = "changed"
+	if a, b := mustBundleYaml(fromExchange), mustBundleYaml(fromFile); a == b {
+		t.Errorf("yaml should be different, got %v", a)
+	}
+}
+
+func Test_readBundle(t *testing.T) {
+	bundle := Bundle{
+		Org: "myorg",
+		Services: map[string]BundleService{
+			"a_1.0.0_amd64": {
+				ServiceDefinition: exchange.ServiceDefinition{URL: "a", Version: "1.0.0", Arch: "amd64", Deployment: `{"services":{"a":{"image":"a:1.0.0"}}}`},
+				Policy:            &exchangecommon.ServicePolicy{Label: "policy of a"},
+				Keys:              map[string]string{"key.pem": "-----BEGIN PUBLIC KEY-----\nabc\n-----END PUBLIC KEY-----\n"},
+			},
+		},
+		Patterns:           map[string]PatternInput{"pat": {Label: "pat", Services: []ServiceReference{{ServiceURL: "a", ServiceOrg: "myorg", ServiceArch: "amd64"}}}},
+		DeploymentPolicies: map[string]businesspolicy.BusinessPolicy{"pol": {Label: "pol", Service: businesspolicy.ServiceRef{Name: "a", Org: "myorg", Arch: "amd64"}}},
+	}
+
+	filePath := filepath.Join(t.TempDir(), "bundle.yaml")
+	if err := ioutil.WriteFile(filePath, []byte(mustBundleYaml(bundle)), 0600); err != nil {
+		t.Fatalf("unable to write bundle: %v", err)
+	}
+
+	read, err := readBundle(filePath)
+	if err != nil {
+		t.Fatalf("unable to read bundle: %v", err)
+	} else if mustBundleYaml(read) != mustBundleYaml(bundle) {
+		t.Errorf("wrong bundle read\n%v\nexpected\n%v", mustBundleYaml(read), mustBundleYaml(bundle))
+	} else if read.Services["a_1.0.0_amd64"].Policy == nil || read.Services["a_1.0.0_amd64"].Keys["key.pem"] == "" {
+		t.Errorf("policy or keys of the service not read: %v", read.Services)
+	}
+
+	// an unknown attribute is an error
+	if err := ioutil.WriteFile(filePath, []byte("org: myorg\nservice: {}\n"), 0600); err != nil {
+		t.Fatalf("unable to write bundle: %v", err)
+	}
+	if _, err := readBundle(filePath); err == nil {
+		t.Errorf("expected an error for an unknown attribute")
+	}
+}
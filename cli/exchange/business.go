@@ -46,7 +46,7 @@ func BusinessListPolicy(org string, credToUse string, policy string, namesOnly b
 		for bPolicy := range policyList.BusinessPolicy {
 			policyNameList = append(policyNameList, bPolicy)
 		}
-		jsonBytes, err := json.MarshalIndent(policyNameList, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(policyNameList)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn exchange deployment listpolicy' output: %v", err))
 		}
@@ -112,12 +112,14 @@ func BusinessAddPolicy(org string, credToUse string, policy string, jsonFilePath
 		//try to update the existing policy
 		httpCode = cliutils.ExchangePutPost("Exchange", http.MethodPut, exchUrl, "orgs/"+polOrg+"/business/policies"+cliutils.AddSlash(policy), cliutils.OrgAndCreds(org, credToUse), []int{201, 404}, policyFile, nil)
 		if httpCode == 201 {
+			recordDeploymentPolicyHistory(exchUrl, polOrg, policy, cliutils.OrgAndCreds(org, credToUse), HISTORY_UPDATE)
 			msgPrinter.Printf("Deployment policy: %v/%v updated in the Horizon Exchange", polOrg, policy)
 			msgPrinter.Println()
 		} else if httpCode == 404 {
 			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Cannot create deployment policy %v/%v: %v", polOrg, policy, resp.Msg))
 		}
 	} else {
+		recordDeploymentPolicyHistory(exchUrl, polOrg, policy, cliutils.OrgAndCreds(org, credToUse), HISTORY_CREATE)
 		msgPrinter.Printf("Deployment policy: %v/%v added in the Horizon Exchange", polOrg, policy)
 		msgPrinter.Println()
 	}
@@ -224,6 +226,7 @@ func BusinessUpdatePolicy(org string, credToUse string, policyName string, fileP
 	msgPrinter.Printf("Updating Policy %v/%v in the Horizon Exchange and re-evaluating all agreements based on this deployment policy. Existing agreements might be cancelled and re-negotiated.", polOrg, policyName)
 	msgPrinter.Println()
 	cliutils.ExchangePutPost("Exchange", http.MethodPatch, exchUrl, "orgs/"+polOrg+"/business/policies"+cliutils.AddSlash(policyName), cliutils.OrgAndCreds(org, credToUse), []int{201}, patch, nil)
+	recordDeploymentPolicyHistory(exchUrl, polOrg, policyName, cliutils.OrgAndCreds(org, credToUse), HISTORY_UPDATE)
 	msgPrinter.Printf("Policy %v/%v updated in the Horizon Exchange", polOrg, policyName)
 	msgPrinter.Println()
 }
@@ -247,6 +250,7 @@ func BusinessRemovePolicy(org string, credToUse string, policy string, force boo
 		msgPrinter.Printf("Policy %v/%v not found in the Horizon Exchange", polOrg, policy)
 		msgPrinter.Println()
 	} else {
+		recordDeploymentPolicyHistory(cliutils.GetExchangeUrl(), polOrg, policy, cliutils.OrgAndCreds(org, credToUse), HISTORY_REMOVE)
 		msgPrinter.Printf("Removing deployment policy %v/%v and re-evaluating all agreements. Existing agreements might be cancelled and re-negotiated", polOrg, policy)
 		msgPrinter.Println()
 		msgPrinter.Printf("Deployment policy %v/%v removed", polOrg, policy)
@@ -1,7 +1,6 @@
 package exchange
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/open-horizon/anax/cli/cliutils"
 	"github.com/open-horizon/anax/exchange"
@@ -34,7 +33,7 @@ func CatalogServiceList(credOrg string, userPw string, displayShort bool, displa
 	cliutils.ExchangeGet("Exchange", cliutils.GetExchangeUrl(), "catalog/services?orgtype="+orgType, cliutils.OrgAndCreds(credOrg, userPw), []int{200}, &resp)
 
 	if displayLong {
-		jsonBytes, err := json.MarshalIndent(resp.Services, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(resp.Services)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn exchange catalog servicelist -l' output: %v", err))
 		}
@@ -44,7 +43,7 @@ func CatalogServiceList(credOrg string, userPw string, displayShort bool, displa
 		for k := range resp.Services {
 			serviceNames = append(serviceNames, k)
 		}
-		jsonBytes, err := json.MarshalIndent(serviceNames, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(serviceNames)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn exchange catalog servicelist -s' output: %v", err))
 		}
@@ -59,7 +58,7 @@ func CatalogServiceList(credOrg string, userPw string, displayShort bool, displa
 			}
 			servicesMedium[k] = catalogServiceMedium
 		}
-		jsonBytes, err := json.MarshalIndent(servicesMedium, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(servicesMedium)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn exchange catalog servicelist' output: %v", err))
 		}
@@ -83,7 +82,7 @@ func CatalogPatternList(credOrg string, userPw string, displayShort bool, displa
 	cliutils.ExchangeGet("Exchange", cliutils.GetExchangeUrl(), "catalog/patterns?orgtype="+orgType, cliutils.OrgAndCreds(credOrg, userPw), []int{200}, &resp)
 
 	if displayLong {
-		jsonBytes, err := json.MarshalIndent(resp.Patterns, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(resp.Patterns)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn exchange catalog patternlist -l' output: %v", err))
 		}
@@ -93,7 +92,7 @@ func CatalogPatternList(credOrg string, userPw string, displayShort bool, displa
 		for k := range resp.Patterns {
 			patternNames = append(patternNames, k)
 		}
-		jsonBytes, err := json.MarshalIndent(patternNames, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(patternNames)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn exchange catalog patternlist -s' output: %v", err))
 		}
@@ -107,7 +106,7 @@ func CatalogPatternList(credOrg string, userPw string, displayShort bool, displa
 			}
 			patternsMedium[k] = catalogPatternMedium
 		}
-		jsonBytes, err := json.MarshalIndent(patternsMedium, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(patternsMedium)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn exchange catalog patternlist' output: %v", err))
 		}
@@ -0,0 +1,130 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/open-horizon/anax/cli/cliconfig"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/i18n"
+	"net/http"
+	"sort"
+
+	agbot "github.com/open-horizon/anax/agreementbot/persistence"
+)
+
+// Fleets are kept by the agbots, so these commands use the agbot secure API instead of the exchange.
+func FleetList(org, credToUse, fleetName string, namesOnly bool) {
+
+	cliutils.SetWhetherUsingApiKey(credToUse)
+
+	var fleetOrg string
+	fleetOrg, fleetName = cliutils.TrimOrg(org, fleetName)
+
+	if fleetName == "*" {
+		fleetName = ""
+	}
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if fleetName != "" {
+		var fleet agbot.Fleet
+		httpCode := cliutils.AgbotGet("org"+cliutils.AddSlash(fleetOrg)+"/fleets"+cliutils.AddSlash(fleetName), cliutils.OrgAndCreds(org, credToUse), []int{200, 404}, &fleet)
+		if httpCode == 404 {
+			cliutils.Fatal(cliutils.NOT_FOUND, msgPrinter.Sprintf("Fleet %s not found in org %s", fleetName, fleetOrg))
+		}
+		output := cliutils.MarshalIndent(map[string]agbot.Fleet{fmt.Sprintf("%v/%v", fleetOrg, fleetName): fleet}, "exchange fleet list")
+		fmt.Println(output)
+		return
+	}
+
+	fleets := make(map[string]agbot.Fleet)
+	cliutils.AgbotGet("org"+cliutils.AddSlash(fleetOrg)+"/fleets", cliutils.OrgAndCreds(org, credToUse), []int{200}, &fleets)
+	if namesOnly {
+		nameList := []string{}
+		for name := range fleets {
+			nameList = append(nameList, name)
+		}
+		sort.Strings(nameList)
+		jsonBytes, err := cliutils.MarshalOutput(nameList)
+		if err != nil {
+			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn exchange fleet list' output: %v", err))
+		}
+		fmt.Println(string(jsonBytes))
+	} else {
+		output := cliutils.MarshalIndent(fleets, "exchange fleet list")
+		fmt.Println(output)
+	}
+}
+
+func FleetNew() {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	var fleet_template = []string{
+		`{`,
+		`  "description": "",      /* ` + msgPrinter.Sprintf("A description of the fleet.") + ` */`,
+		`  "nodes": [              /* ` + msgPrinter.Sprintf("Optional. A list of node names that are in the fleet.") + ` */`,
+		`    "node1",`,
+		`    "node2"`,
+		`  ],`,
+		`  "constraints": [        /* ` + msgPrinter.Sprintf("Optional. The nodes whose properties satisfy these constraints are also in the fleet.") + ` */`,
+		`    "location == store"`,
+		`  ]`,
+		`}`,
+	}
+
+	for _, s := range fleet_template {
+		fmt.Println(s)
+	}
+}
+
+func FleetAdd(org, credToUse, fleetName, jsonFilePath string) {
+	cliutils.SetWhetherUsingApiKey(credToUse)
+
+	var fleetOrg string
+	fleetOrg, fleetName = cliutils.TrimOrg(org, fleetName)
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	// read in the new fleet from file
+	newBytes := cliconfig.ReadJsonFileWithLocalConfig(jsonFilePath)
+	var fleet agbot.Fleet
+	err := json.Unmarshal(newBytes, &fleet)
+	if err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to unmarshal json input file %s: %v", jsonFilePath, err))
+	}
+
+	fleet.Org = fleetOrg
+	fleet.Name = fleetName
+	if err := fleet.Validate(); err != nil {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Incorrect fleet format in file %s: %v", jsonFilePath, err))
+	}
+
+	cliutils.AgbotPutPost(http.MethodPut, "org"+cliutils.AddSlash(fleetOrg)+"/fleets"+cliutils.AddSlash(fleetName), cliutils.OrgAndCreds(org, credToUse), []int{201}, fleet, nil)
+	msgPrinter.Printf("Fleet %v/%v added or updated in the agbot", fleetOrg, fleetName)
+	msgPrinter.Println()
+}
+
+func FleetRemove(org, credToUse, fleetName string, force bool) {
+	cliutils.SetWhetherUsingApiKey(credToUse)
+
+	var fleetOrg string
+	fleetOrg, fleetName = cliutils.TrimOrg(org, fleetName)
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if !force {
+		cliutils.ConfirmRemove(msgPrinter.Sprintf("Are you sure you want to remove fleet %v for org %v? The agreements with the nodes of the fleet made for the deployment policies targeting it will be cancelled.", fleetName, fleetOrg))
+	}
+
+	httpCode := cliutils.AgbotDelete("org"+cliutils.AddSlash(fleetOrg)+"/fleets"+cliutils.AddSlash(fleetName), cliutils.OrgAndCreds(org, credToUse), []int{204, 404})
+	if httpCode == 404 {
+		cliutils.Fatal(cliutils.NOT_FOUND, msgPrinter.Sprintf("Fleet %s is not found in org %s", fleetName, fleetOrg))
+	} else if httpCode == 204 {
+		msgPrinter.Printf("Fleet %v/%v removed from the agbot.", fleetOrg, fleetName)
+		msgPrinter.Println()
+	}
+}
@@ -0,0 +1,326 @@
+package exchange
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/pmezard/go-difflib/difflib"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The directory under the home directory where hzn records the changes it makes to the services and deployment policies
+// in the exchange. Set HZN_HISTORY_DIR to record them in another directory, for example one that is shared by the admins
+// of an organization.
+const HISTORY_DIR = ".hzn/history"
+
+// The kinds of resources that changes are recorded for. The changes to a service and to its service policy are recorded
+// in the history of the service.
+const (
+	HISTORY_SERVICE           = "service"
+	HISTORY_SERVICE_POLICY    = "servicePolicy"
+	HISTORY_DEPLOYMENT_POLICY = "deploymentPolicy"
+)
+
+// The changes recorded in a history.
+const (
+	HISTORY_CREATE = "create"
+	HISTORY_UPDATE = "update"
+	HISTORY_REMOVE = "remove"
+)
+
+// A change made with hzn to a resource in the exchange. The content is the resource in the exchange after the change,
+// without the owner and the time of the last update, which are in the entry.
+type HistoryEntry struct {
+	Time        string      `json:"time"`
+	User        string      `json:"user"`
+	Host        string      `json:"host,omitempty"`
+	Kind        string      `json:"kind"`
+	Action      string      `json:"action"`
+	LastUpdated string      `json:"lastUpdated,omitempty"`
+	Content     interface{} `json:"content,omitempty"`
+}
+
+// Returns the file that the history of a resource is recorded in. The history of each resource has its own file, under
+// the org and the category of the resource, with an entry on each line.
+func historyFile(org string, category string, name string) (string, error) {
+	dir := os.Getenv("HZN_HISTORY_DIR")
+	if dir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(homeDir, HISTORY_DIR)
+	}
+	return filepath.Join(dir, org, category, name+".jsonl"), nil
+}
+
+// Records a change to a resource. A failure is not fatal, since the change was made in the exchange.
+func recordHistory(org string, category string, name string, creds string, kind string, action string, content interface{}, lastUpdated string) {
+	if cliutils.IsDryRun() {
+		return
+	}
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	user, _ := cliutils.SplitIdToken(cliutils.OrgAndCreds(org, creds))
+	host, _ := os.Hostname()
+	entry := HistoryEntry{Time: time.Now().UTC().Format(time.RFC3339), User: user, Host: host, Kind: kind, Action: action, LastUpdated: lastUpdated, Content: content}
+
+	file, err := historyFile(org, category, name)
+	var entryBytes []byte
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(file), 0700)
+	}
+	if err == nil {
+		entryBytes, err = json.Marshal(entry)
+	}
+	if err == nil {
+		var f *os.File
+		if f, err = os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600); err == nil {
+			_, err = f.Write(append(entryBytes, '\n'))
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
+	if err != nil {
+		cliutils.Warning(msgPrinter.Sprintf("unable to record the change to %v %v/%v in the history: %v", kind, org, name, err))
+	}
+}
+
+// Records a change to a service, with the service as it is in the exchange after the change.
+func recordServiceHistory(exchUrl string, org string, svcId string, creds string, action string) {
+	var content interface{}
+	lastUpdated := ""
+	if action != HISTORY_REMOVE {
+		var services exchange.GetServicesResponse
+		if httpCode := cliutils.ExchangeGet("Exchange", exchUrl, "orgs/"+org+"/services/"+svcId, creds, []int{200, 404}, &services); httpCode == 200 {
+			svc := services.Services[org+"/"+svcId]
+			lastUpdated = svc.LastUpdated
+			svc.Owner = ""
+			svc.LastUpdated = ""
+			content = svc
+		}
+	}
+	recordHistory(org, "services", svcId, creds, HISTORY_SERVICE, action, content, lastUpdated)
+}
+
+// Records a change to the policy of a service, with the policy as it is in the exchange after the change.
+func recordServicePolicyHistory(exchUrl string, org string, svcId string, creds string, action string) {
+	var content interface{}
+	lastUpdated := ""
+	if action != HISTORY_REMOVE {
+		var policy exchange.ExchangeServicePolicy
+		if httpCode := cliutils.ExchangeGet("Exchange", exchUrl, "orgs/"+org+"/services/"+svcId+"/policy", creds, []int{200, 404}, &policy); httpCode == 200 {
+			lastUpdated = policy.LastUpdated
+			content = policy.ServicePolicy
+		}
+	}
+	recordHistory(org, "services", svcId, creds, HISTORY_SERVICE_POLICY, action, content, lastUpdated)
+}
+
+// Records a change to a deployment policy, with the policy as it is in the exchange after the change.
+func recordDeploymentPolicyHistory(exchUrl string, org string, name string, creds string, action string) {
+	var content interface{}
+	lastUpdated := ""
+	if action != HISTORY_REMOVE {
+		var policies exchange.GetBusinessPolicyResponse
+		if httpCode := cliutils.ExchangeGet("Exchange", exchUrl, "orgs/"+org+"/business/policies/"+name, creds, []int{200, 404}, &policies); httpCode == 200 {
+			pol := policies.BusinessPolicy[org+"/"+name]
+			lastUpdated = pol.LastUpdated
+			pol.Owner = ""
+			content = pol.BusinessPolicy
+		}
+	}
+	recordHistory(org, "deployment", name, creds, HISTORY_DEPLOYMENT_POLICY, action, content, lastUpdated)
+}
+
+// Returns the recorded history of a resource, oldest first. A resource without history has no entries.
+func readHistory(org string, category string, name string) ([]HistoryEntry, string, error) {
+	entries := []HistoryEntry{}
+	file, err := historyFile(org, category, name)
+	if err != nil {
+		return nil, "", err
+	}
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return entries, file, nil
+	} else if err != nil {
+		return nil, file, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, file, fmt.Errorf("line %v: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, file, scanner.Err()
+}
+
+// Returns the content of a history entry as yaml for the diffs. The deployment of a service is a json string, it is
+// displayed as yaml too so that the diff shows what changed in it.
+func historyYaml(content interface{}) string {
+	if content == nil {
+		return ""
+	}
+	contentBytes, err := json.Marshal(content)
+	if err != nil {
+		return fmt.Sprintf("%v\n", content)
+	}
+	var generic interface{}
+	if err := json.Unmarshal(contentBytes, &generic); err != nil {
+		return fmt.Sprintf("%v\n", content)
+	}
+	if m, ok := generic.(map[string]interface{}); ok {
+		if dep, ok := m["deployment"].(string); ok && dep != "" {
+			var depObj interface{}
+			if err := json.Unmarshal([]byte(dep), &depObj); err == nil {
+				m["deployment"] = depObj
+			}
+		}
+	}
+	y, err := bundleYaml(generic)
+	if err != nil {
+		return fmt.Sprintf("%v\n", content)
+	}
+	return y
+}
+
+// Returns the unified diff between two versions of a resource, empty if they are the same.
+func historyDiff(from string, fromName string, to string, toName string) string {
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from),
+		B:        difflib.SplitLines(to),
+		FromFile: fromName,
+		ToFile:   toName,
+		Context:  3,
+	})
+	return diff
+}
+
+// The current content of a resource in the exchange, to compare with its recorded history.
+type historyCurrent struct {
+	exists      bool
+	content     interface{}
+	owner       string
+	lastUpdated string
+}
+
+// Displays the recorded changes to a resource, with the diff of each change, then the changes that were made to the
+// resource in the exchange without being recorded, if there are any.
+func displayHistory(org string, category string, name string, kinds []string, current map[string]historyCurrent) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	entries, file, err := readHistory(org, category, name)
+	if err != nil {
+		cliutils.Fatal(cliutils.FILE_IO_ERROR, msgPrinter.Sprintf("unable to read the history in %v: %v", file, err))
+	}
+	if len(entries) == 0 {
+		msgPrinter.Printf("No changes to %v/%v are recorded in %v.", org, name, file)
+		msgPrinter.Println()
+	}
+
+	last := map[string]HistoryEntry{}
+	for _, entry := range entries {
+		msgPrinter.Printf("%v %v %v by %v on %v", entry.Time, entry.Action, entry.Kind, entry.User, entry.Host)
+		msgPrinter.Println()
+		prev, ok := last[entry.Kind]
+		prevName := entry.Kind
+		if ok {
+			prevName = entry.Kind + "@" + prev.Time
+		}
+		fmt.Print(historyDiff(historyYaml(prev.Content), prevName, historyYaml(entry.Content), entry.Kind+"@"+entry.Time))
+		last[entry.Kind] = entry
+	}
+
+	// the changes made in the exchange without hzn, or by an admin who records the history in another directory
+	for _, kind := range kinds {
+		cur := current[kind]
+		recorded, hasHistory := last[kind]
+		recordedYaml := ""
+		if hasHistory {
+			recordedYaml = historyYaml(recorded.Content)
+		}
+		currentYaml := ""
+		if cur.exists {
+			currentYaml = historyYaml(cur.content)
+		}
+		if recordedYaml == currentYaml {
+			continue
+		}
+		if cur.exists {
+			msgPrinter.Printf("The %v in the Exchange was changed without being recorded in the history, last updated %v by %v:", kind, cur.lastUpdated, cur.owner)
+		} else {
+			msgPrinter.Printf("The %v was removed from the Exchange without being recorded in the history:", kind)
+		}
+		msgPrinter.Println()
+		fromName := kind
+		if hasHistory {
+			fromName = kind + "@" + recorded.Time
+		}
+		fmt.Print(historyDiff(recordedYaml, fromName, currentYaml, "exchange/"+kind))
+	}
+}
+
+// ServiceHistory displays the changes to a service and its service policy that were made with hzn, with the diff of
+// each change, and the changes that were made in the exchange without being recorded.
+func ServiceHistory(org string, credToUse string, service string) {
+	cliutils.SetWhetherUsingApiKey(credToUse)
+	var svcOrg string
+	svcOrg, service = cliutils.TrimOrg(org, service)
+	exchUrl := cliutils.GetExchangeUrl()
+	creds := cliutils.OrgAndCreds(org, credToUse)
+
+	current := map[string]historyCurrent{}
+	var services exchange.GetServicesResponse
+	if httpCode := cliutils.ExchangeGet("Exchange", exchUrl, "orgs/"+svcOrg+"/services/"+service, creds, []int{200, 404}, &services); httpCode == 200 {
+		svc := services.Services[svcOrg+"/"+service]
+		cur := historyCurrent{exists: true, owner: svc.Owner, lastUpdated: svc.LastUpdated}
+		svc.Owner = ""
+		svc.LastUpdated = ""
+		cur.content = svc
+		current[HISTORY_SERVICE] = cur
+
+		var policy exchange.ExchangeServicePolicy
+		if httpCode := cliutils.ExchangeGet("Exchange", exchUrl, "orgs/"+svcOrg+"/services/"+service+"/policy", creds, []int{200, 404}, &policy); httpCode == 200 {
+			current[HISTORY_SERVICE_POLICY] = historyCurrent{exists: true, content: policy.ServicePolicy, owner: cur.owner, lastUpdated: policy.LastUpdated}
+		}
+	}
+
+	displayHistory(svcOrg, "services", service, []string{HISTORY_SERVICE, HISTORY_SERVICE_POLICY}, current)
+}
+
+// DeploymentPolicyHistory displays the changes to a deployment policy that were made with hzn, with the diff of each
+// change, and the changes that were made in the exchange without being recorded.
+func DeploymentPolicyHistory(org string, credToUse string, policy string) {
+	cliutils.SetWhetherUsingApiKey(credToUse)
+	var polOrg string
+	polOrg, policy = cliutils.TrimOrg(org, policy)
+
+	current := map[string]historyCurrent{}
+	var policies exchange.GetBusinessPolicyResponse
+	if httpCode := cliutils.ExchangeGet("Exchange", cliutils.GetExchangeUrl(), "orgs/"+polOrg+"/business/policies/"+policy, cliutils.OrgAndCreds(org, credToUse), []int{200, 404}, &policies); httpCode == 200 {
+		pol := policies.BusinessPolicy[polOrg+"/"+policy]
+		cur := historyCurrent{exists: true, owner: pol.Owner, lastUpdated: pol.LastUpdated}
+		pol.Owner = ""
+		cur.content = pol.BusinessPolicy
+		current[HISTORY_DEPLOYMENT_POLICY] = cur
+	}
+
+	displayHistory(polOrg, "deployment", policy, []string{HISTORY_DEPLOYMENT_POLICY}, current)
+}
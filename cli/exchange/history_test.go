@@ -0,0 +1,64 @@
+package exchange
+
+import (
+	"github.com/open-horizon/anax/cli/cliutils"
+	"strings"
+	"testing"
+)
+
+func Test_recordHistory(t *testing.T) {
+	t.Setenv("HZN_HISTORY_DIR", t.TempDir())
+	dryRun := false
+	cliutils.Opts.IsDryRun = &dryRun
+	defer func() { cliutils.Opts.IsDryRun = nil }()
+
+	if entries, _, err := readHistory("myorg", "deployment", "pol1"); err != nil {
+		t.Errorf("unable to read history, error: %v", err)
+	} else if len(entries) != 0 {
+		t.Errorf("a resource without history should have no entries, got %v", entries)
+	}
+
+	recordHistory("myorg", "deployment", "pol1", "alice:pw", HISTORY_DEPLOYMENT_POLICY, HISTORY_CREATE, map[string]interface{}{"label": "v1"}, "t1")
+	recordHistory("myorg", "deployment", "pol1", "myorg/alice:pw", HISTORY_DEPLOYMENT_POLICY, HISTORY_UPDATE, map[string]interface{}{"label": "v2"}, "t2")
+	recordHistory("myorg", "deployment", "pol1", "alice:pw", HISTORY_DEPLOYMENT_POLICY, HISTORY_REMOVE, nil, "")
+
+	// nothing is recorded in dry run mode
+	dryRun = true
+	recordHistory("myorg", "deployment", "pol1", "alice:pw", HISTORY_DEPLOYMENT_POLICY, HISTORY_CREATE, map[string]interface{}{"label": "v3"}, "t3")
+
+	entries, _, err := readHistory("myorg", "deployment", "pol1")
+	if err != nil {
+		t.Fatalf("unable to read history, error: %v", err)
+	} else if len(entries) != 3 {
+		t.Fatalf("wrong number of entries %v, expected 3", len(entries))
+	}
+	for i, action := range []string{HISTORY_CREATE, HISTORY_UPDATE, HISTORY_REMOVE} {
+		if entries[i].Action != action || entries[i].User != "myorg/alice" || entries[i].Kind != HISTORY_DEPLOYMENT_POLICY {
+			t.Errorf("wrong entry %v, expected action %v by myorg/alice", entries[i], action)
+		}
+	}
+	if entries[2].Content != nil {
+		t.Errorf("a removed resource should have no content, got %v", entries[2].Content)
+	}
+
+	diff := historyDiff(historyYaml(entries[0].Content), "v1", historyYaml(entries[1].Content), "v2")
+	if !strings.Contains(diff, "-label: v1") || !strings.Contains(diff, "+label: v2") {
+		t.Errorf("wrong diff %v", diff)
+	}
+	if diff := historyDiff(historyYaml(entries[1].Content), "v2", historyYaml(entries[1].Content), "v2"); diff != "" {
+		t.Errorf("the same content should have no diff, got %v", diff)
+	}
+}
+
+func Test_historyYaml(t *testing.T) {
+	if y := historyYaml(nil); y != "" {
+		t.Errorf("no content should be empty, got %v", y)
+	}
+
+	// the deployment of a service is displayed as yaml
+	svc := map[string]interface{}{"url": "svc1", "deployment": `{"services":{"svc1":{"image":"img1:1.0"}}}`}
+	y := historyYaml(svc)
+	if !strings.Contains(y, "image: img1:1.0") || !strings.Contains(y, "url: svc1") {
+		t.Errorf("wrong yaml %v", y)
+	}
+}
@@ -0,0 +1,161 @@
+package exchange
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/open-horizon/anax/agreementbot"
+	"github.com/open-horizon/anax/cli/cliconfig"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/i18n"
+	"net/http"
+	"sort"
+	"strings"
+
+	agbot "github.com/open-horizon/anax/agreementbot/persistence"
+)
+
+// The join token given to the device. It has everything the device needs to join the org, so that a single string can
+// be passed to 'hzn register --token'.
+type JoinTokenString struct {
+	AgbotURL string `json:"url"`
+	Org      string `json:"org"`
+	Id       string `json:"id"`
+	Secret   string `json:"secret"`
+}
+
+func (t JoinTokenString) Encode() string {
+	b, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeJoinTokenString(s string) (*JoinTokenString, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, errors.New("the join token is not base64 encoded")
+	}
+	var t JoinTokenString
+	if err := json.Unmarshal(b, &t); err != nil {
+		return nil, fmt.Errorf("the join token is not valid, %v", err)
+	} else if t.AgbotURL == "" || t.Org == "" || t.Id == "" || t.Secret == "" {
+		return nil, errors.New("the join token must have an agbot url, an org, an id and a secret")
+	}
+	return &t, nil
+}
+
+// Join tokens are kept by the agbots, so these commands use the agbot secure API instead of the exchange.
+func JoinTokenList(org, credToUse, tokenId string, idsOnly bool) {
+	cliutils.SetWhetherUsingApiKey(credToUse)
+
+	var tokenOrg string
+	tokenOrg, tokenId = cliutils.TrimOrg(org, tokenId)
+
+	if tokenId == "*" {
+		tokenId = ""
+	}
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if tokenId != "" {
+		var token agbot.JoinToken
+		httpCode := cliutils.AgbotGet("org"+cliutils.AddSlash(tokenOrg)+"/jointokens"+cliutils.AddSlash(tokenId), cliutils.OrgAndCreds(org, credToUse), []int{200, 404}, &token)
+		if httpCode == 404 {
+			cliutils.Fatal(cliutils.NOT_FOUND, msgPrinter.Sprintf("Join token %s not found in org %s", tokenId, tokenOrg))
+		}
+		output := cliutils.MarshalIndent(map[string]agbot.JoinToken{fmt.Sprintf("%v/%v", tokenOrg, tokenId): token}, "exchange jointoken list")
+		fmt.Println(output)
+		return
+	}
+
+	tokens := make(map[string]agbot.JoinToken)
+	cliutils.AgbotGet("org"+cliutils.AddSlash(tokenOrg)+"/jointokens", cliutils.OrgAndCreds(org, credToUse), []int{200}, &tokens)
+	if idsOnly {
+		idList := []string{}
+		for id := range tokens {
+			idList = append(idList, id)
+		}
+		sort.Strings(idList)
+		jsonBytes, err := cliutils.MarshalOutput(idList)
+		if err != nil {
+			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn exchange jointoken list' output: %v", err))
+		}
+		fmt.Println(string(jsonBytes))
+	} else {
+		output := cliutils.MarshalIndent(tokens, "exchange jointoken list")
+		fmt.Println(output)
+	}
+}
+
+func JoinTokenNew() {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	var token_template = []string{
+		`{`,
+		`  "description": "",            /* ` + msgPrinter.Sprintf("A description of the join token.") + ` */`,
+		`  "pattern": "",                /* ` + msgPrinter.Sprintf("Optional. The pattern of the nodes. Do not set it with a node policy.") + ` */`,
+		`  "nodePolicy": {               /* ` + msgPrinter.Sprintf("Optional. The node policy of the nodes.") + ` */`,
+		`    "properties": [],`,
+		`    "constraints": []`,
+		`  },`,
+		`  "userInput": [],              /* ` + msgPrinter.Sprintf("Optional. The user input of the nodes.") + ` */`,
+		`  "nodeNameTemplate": "{{.NodeId}}",  /* ` + msgPrinter.Sprintf("Optional. A template for the node names, with .Org, .NodeId and .Index.") + ` */`,
+		`  "maxUses": 0,                 /* ` + msgPrinter.Sprintf("Optional. The number of nodes that can join with the token, no limit when 0.") + ` */`,
+		`  "ttl": 86400                  /* ` + msgPrinter.Sprintf("Optional. The number of seconds the token can be used, 1 day by default.") + ` */`,
+		`}`,
+	}
+
+	for _, s := range token_template {
+		fmt.Println(s)
+	}
+}
+
+func JoinTokenCreate(org, credToUse, jsonFilePath string) {
+	cliutils.SetWhetherUsingApiKey(credToUse)
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	var input agreementbot.JoinTokenRequest
+	if jsonFilePath != "" {
+		newBytes := cliconfig.ReadJsonFileWithLocalConfig(jsonFilePath)
+		if err := json.Unmarshal(newBytes, &input); err != nil {
+			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to unmarshal json input file %s: %v", jsonFilePath, err))
+		}
+	}
+
+	var resp agreementbot.JoinTokenResponse
+	cliutils.AgbotPutPost(http.MethodPost, "org"+cliutils.AddSlash(org)+"/jointokens", cliutils.OrgAndCreds(org, credToUse), []int{201}, input, &resp)
+	if cliutils.IsDryRun() {
+		return
+	}
+
+	token := JoinTokenString{AgbotURL: cliutils.GetAgbotSecureAPIUrlBase(), Org: resp.Org, Id: resp.Id, Secret: resp.Secret}
+	msgPrinter.Printf("Join token %v/%v created, it expires at %v. Register the nodes with 'hzn register --token <token>' using this token, it is not shown again:", resp.Org, resp.Id, cliutils.ConvertTime(resp.Expires))
+	msgPrinter.Println()
+	fmt.Println(token.Encode())
+}
+
+func JoinTokenRemove(org, credToUse, tokenId string, force bool) {
+	cliutils.SetWhetherUsingApiKey(credToUse)
+
+	var tokenOrg string
+	tokenOrg, tokenId = cliutils.TrimOrg(org, tokenId)
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if !force {
+		cliutils.ConfirmRemove(msgPrinter.Sprintf("Are you sure you want to remove join token %v for org %v? The nodes that joined with it are not removed.", tokenId, tokenOrg))
+	}
+
+	httpCode := cliutils.AgbotDelete("org"+cliutils.AddSlash(tokenOrg)+"/jointokens"+cliutils.AddSlash(tokenId), cliutils.OrgAndCreds(org, credToUse), []int{204, 404})
+	if httpCode == 404 {
+		cliutils.Fatal(cliutils.NOT_FOUND, msgPrinter.Sprintf("Join token %s is not found in org %s", tokenId, tokenOrg))
+	} else if httpCode == 204 {
+		msgPrinter.Printf("Join token %v/%v removed from the agbot.", tokenOrg, tokenId)
+		msgPrinter.Println()
+	}
+}
@@ -0,0 +1,21 @@
+package exchange
+
+import (
+	"testing"
+)
+
+func Test_JoinTokenString(t *testing.T) {
+	token := JoinTokenString{AgbotURL: "https://agbot:3111", Org: "myorg", Id: "tok1", Secret: "s3cret"}
+	if decoded, err := DecodeJoinTokenString(token.Encode() + "\n"); err != nil {
+		t.Errorf("unable to decode join token, error: %v", err)
+	} else if *decoded != token {
+		t.Errorf("wrong decoded join token %v, expected %v", *decoded, token)
+	}
+
+	if _, err := DecodeJoinTokenString("not a token"); err == nil {
+		t.Errorf("an invalid join token should not decode")
+	}
+	if _, err := DecodeJoinTokenString(JoinTokenString{AgbotURL: "https://agbot:3111", Org: "myorg", Id: "tok1"}.Encode()); err == nil {
+		t.Errorf("a join token without a secret should not decode")
+	}
+}
@@ -48,7 +48,7 @@ func NMPList(org, credToUse, nmpName string, namesOnly, listNodes bool) {
 		for nmp := range nmpList.Policies {
 			nmpNameList = append(nmpNameList, nmp)
 		}
-		jsonBytes, err := json.MarshalIndent(nmpNameList, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(nmpNameList)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn exchange nmp list' output: %v", err))
 		}
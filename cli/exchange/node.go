@@ -26,10 +26,11 @@ type ExchangeNodes struct {
 }
 
 type ContainerStat struct {
-	Name    string `json:"name"`
-	Image   string `json:"image"`
-	Created int    `json:"created"`
-	State   string `json:"state"`
+	Name        string `json:"name"`
+	Image       string `json:"image"`
+	ImageDigest string `json:"imageDigest,omitempty"`
+	Created     int    `json:"created"`
+	State       string `json:"state"`
 }
 
 type ExNodeStatusService struct {
@@ -65,7 +66,7 @@ func NodeList(org string, credToUse string, node string, namesOnly bool) {
 		for n := range resp.Nodes {
 			nodes = append(nodes, n)
 		}
-		jsonBytes, err := json.MarshalIndent(nodes, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(nodes)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal 'exchange node list' output: %v", err))
 		}
@@ -177,6 +178,7 @@ func NodeCreate(org, nodeIdTok, node, token, userPw, arch string, nodeName strin
 			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("can not update existing node %s because it is owned by another user (%s)", nodeId, ourNode.Owner))
 		} else if httpCode == 404 {
 			// Node doesn't exist. MaxNodes reached or 403 means real access denied, display the message from the exchange
+			cliutils.CheckOrgQuota(exchUrlBase, org, exchange.ORG_QUOTA_NODES, cliutils.OrgAndCreds(org, userPw))
 			if resp.Msg != "" {
 				fmt.Println(resp.Msg)
 			}
@@ -401,7 +403,7 @@ func NodeListPolicy(org string, credToUse string, node string) {
 	cliutils.ExchangeGet("Exchange", cliutils.GetExchangeUrl(), "orgs/"+nodeOrg+"/nodes"+cliutils.AddSlash(node)+"/policy", cliutils.OrgAndCreds(org, credToUse), []int{200, 404}, &policy)
 
 	// display
-	output, err := cliutils.DisplayAsJson(policy)
+	output, err := cliutils.FormatOutput(policy)
 	if err != nil {
 		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal node policy output: %v", err))
 	}
@@ -607,7 +609,7 @@ func NodeListErrors(org string, credToUse string, node string, long bool) {
 	}
 
 	if !long {
-		jsonBytes, err := json.MarshalIndent(errorList, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(errorList)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn exchange node listerrors' output: %v", err))
 		}
@@ -629,7 +631,7 @@ func NodeListErrors(org string, credToUse string, node string, long bool) {
 			long_output[i].SourceType = fullV.SourceType
 			long_output[i].Source = fullV.Source
 		}
-		jsonBytes, err := json.MarshalIndent(long_output, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(long_output)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal 'hzn exchange node listerrors' output: %v", err))
 		}
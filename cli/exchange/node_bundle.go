@@ -0,0 +1,276 @@
+package exchange
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/open-horizon/anax/cli/cliconfig"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/common"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/persistence"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+)
+
+// The files in a node bundle.
+const (
+	NODE_BUNDLE_NODE_FILE      = "node.json"
+	NODE_BUNDLE_POLICY_FILE    = "policy.json"
+	NODE_BUNDLE_USERINPUT_FILE = "userinput.json"
+)
+
+const NODE_BUNDLE_VERSION = 1
+
+// A node bundle has what a device needs to register with 'hzn register --from-bundle', without user credentials and
+// without input files: the identity of the node, created in the exchange by an admin, and its pattern, node policy and
+// user input. It also lists the images of the services of the pattern, so that they can be pulled on the device before
+// it is deployed to a site with no access to the image registries.
+type NodeBundle struct {
+	Version     int      `json:"version"`
+	Org         string   `json:"org"`
+	Id          string   `json:"id"`
+	Token       string   `json:"token"`
+	Name        string   `json:"name"`
+	NodeType    string   `json:"nodeType"`
+	Arch        string   `json:"arch,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	ExchangeUrl string   `json:"exchangeUrl"`
+	Images      []string `json:"images,omitempty"`
+	Created     string   `json:"created"`
+
+	// The node policy and user input files, they are kept in their own files in the bundle.
+	NodePolicy []byte `json:"-"`
+	UserInput  []byte `json:"-"`
+}
+
+// Writes the bundle as a gzipped tar file.
+func (b *NodeBundle) Write(w io.Writer) error {
+	nodeBytes, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	files := []struct {
+		name string
+		data []byte
+	}{{NODE_BUNDLE_NODE_FILE, nodeBytes}, {NODE_BUNDLE_POLICY_FILE, b.NodePolicy}, {NODE_BUNDLE_USERINPUT_FILE, b.UserInput}}
+	for _, f := range files {
+		if f.data == nil {
+			continue
+		}
+		// the token is in the bundle, only the owner can read the files when they are extracted
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0600, Size: int64(len(f.data)), ModTime: time.Now()}); err != nil {
+			return err
+		} else if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Reads a bundle written by Write.
+func ReadNodeBundle(r io.Reader) (*NodeBundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("the bundle is not a gzipped tar file, %v", err)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to read the bundle, %v", err)
+		}
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, tr); err != nil {
+			return nil, fmt.Errorf("unable to read %v from the bundle, %v", hdr.Name, err)
+		}
+		files[hdr.Name] = buf.Bytes()
+	}
+
+	nodeBytes, ok := files[NODE_BUNDLE_NODE_FILE]
+	if !ok {
+		return nil, fmt.Errorf("the bundle has no %v", NODE_BUNDLE_NODE_FILE)
+	}
+	var b NodeBundle
+	if err := json.Unmarshal(nodeBytes, &b); err != nil {
+		return nil, fmt.Errorf("unable to parse %v, %v", NODE_BUNDLE_NODE_FILE, err)
+	} else if b.Version > NODE_BUNDLE_VERSION {
+		return nil, fmt.Errorf("the bundle version %v is newer than the version %v this hzn supports", b.Version, NODE_BUNDLE_VERSION)
+	} else if b.Org == "" || b.Id == "" || b.Token == "" {
+		return nil, errors.New("the bundle must have the org, the id and the token of the node")
+	}
+	b.NodePolicy = files[NODE_BUNDLE_POLICY_FILE]
+	b.UserInput = files[NODE_BUNDLE_USERINPUT_FILE]
+	return &b, nil
+}
+
+// Creates a node in the exchange and writes a bundle that a device can register with, with 'hzn register --from-bundle',
+// without user credentials. A random token is generated when none is given. The images of the services of the pattern
+// for the architecture of the node are listed in the bundle, with the images given with --image.
+func NodeBundleCreate(org, userPw, node, token, arch, nodeName, nodeType, pattern, policyFile, userInputFile string, images []string, bundleFile string) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if nodeType == "" {
+		nodeType = persistence.DEVICE_TYPE_DEVICE
+	}
+	if nodeName == "" {
+		nodeName = node
+	}
+	if token == "" {
+		var err error
+		if token, err = cutil.SecureRandomString(); err != nil {
+			cliutils.Fatal(cliutils.INTERNAL_ERROR, msgPrinter.Sprintf("could not create a random token"))
+		}
+	}
+
+	bundle := NodeBundle{
+		Version:     NODE_BUNDLE_VERSION,
+		Org:         org,
+		Id:          node,
+		Token:       token,
+		Name:        nodeName,
+		NodeType:    nodeType,
+		Arch:        arch,
+		ExchangeUrl: cliutils.GetExchangeUrl(),
+		Created:     time.Now().UTC().Format(time.RFC3339),
+	}
+
+	// check the input files before the node is created
+	if policyFile != "" {
+		bundle.NodePolicy = cliconfig.ReadJsonFileWithLocalConfig(policyFile)
+		var nodePol exchangecommon.NodePolicy
+		if err := json.Unmarshal(bundle.NodePolicy, &nodePol); err != nil {
+			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to unmarshal json input file %s: %v", policyFile, err))
+		} else if err := nodePol.ValidateAndNormalize(); err != nil {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Incorrect node policy format in file %s: %v", policyFile, err))
+		}
+	}
+	if userInputFile != "" {
+		bundle.UserInput = cliconfig.ReadJsonFileWithLocalConfig(userInputFile)
+		if _, err := common.NewUserInputFileFromJsonBytes(bundle.UserInput); err != nil {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Unable to create UserInputFile object from file %s. %v", userInputFile, err))
+		}
+	}
+
+	imageSet := make(map[string]bool)
+	for _, image := range images {
+		imageSet[image] = true
+	}
+	if pattern != "" {
+		bundle.Pattern = cliutils.AddOrg(org, pattern)
+		for _, image := range patternImages(org, userPw, bundle.Pattern, arch) {
+			imageSet[image] = true
+		}
+	}
+	for image := range imageSet {
+		bundle.Images = append(bundle.Images, image)
+	}
+	sort.Strings(bundle.Images)
+
+	NodeCreate(org, "", node, token, userPw, arch, nodeName, nodeType, true)
+
+	file, err := os.OpenFile(bundleFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		cliutils.Fatal(cliutils.FILE_IO_ERROR, msgPrinter.Sprintf("unable to create file %v: %v", bundleFile, err))
+	}
+	defer file.Close()
+	if err := bundle.Write(file); err != nil {
+		cliutils.Fatal(cliutils.FILE_IO_ERROR, msgPrinter.Sprintf("unable to write the bundle to %v: %v", bundleFile, err))
+	}
+
+	msgPrinter.Printf("Node bundle %v written for node %v/%v, with %d image(s). It holds the node token, keep it safe.", bundleFile, org, node, len(bundle.Images))
+	msgPrinter.Println()
+}
+
+// Returns the images of the services of a pattern, and of their dependencies, for an architecture. The images are
+// only found for the services deployed with docker.
+func patternImages(org, userPw, pattern, arch string) []string {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	patOrg, patName := cliutils.TrimOrg(org, pattern)
+	var output exchange.GetPatternResponse
+	httpCode := cliutils.ExchangeGet("Exchange", cliutils.GetExchangeUrl(), "orgs/"+patOrg+"/patterns"+cliutils.AddSlash(patName), cliutils.OrgAndCreds(org, userPw), []int{200, 404}, &output)
+	pat, ok := output.Patterns[pattern]
+	if httpCode == 404 || !ok {
+		cliutils.Fatal(cliutils.NOT_FOUND, msgPrinter.Sprintf("pattern '%s' not found from the Exchange.", pattern))
+	}
+
+	if arch == "" {
+		msgPrinter.Printf("The images of pattern %v are not added to the bundle because the node architecture is not specified with --arch.", pattern)
+		msgPrinter.Println()
+		return nil
+	}
+
+	resolveService := exchange.GetHTTPServiceDefResolverHandler(cliutils.GetUserExchangeContext(org, userPw))
+	images := []string{}
+	for _, sref := range pat.Services {
+		if sref.ServiceArch != "" && sref.ServiceArch != "*" && sref.ServiceArch != arch {
+			continue
+		}
+		for _, sv := range sref.ServiceVersions {
+			_, deps, top, _, err := resolveService(sref.ServiceURL, sref.ServiceOrg, sv.Version, arch)
+			if err != nil {
+				cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("Error retrieving service %v/%v version %v from the Exchange. %v", sref.ServiceOrg, sref.ServiceURL, sv.Version, err))
+			}
+			sdefs := []exchange.ServiceDefinition{*top}
+			for _, dep := range deps {
+				sdefs = append(sdefs, dep)
+			}
+			for _, sdef := range sdefs {
+				images = append(images, deploymentImages(sdef.GetDeploymentString())...)
+			}
+		}
+	}
+	return images
+}
+
+// Returns the images of a docker deployment, nothing for the other kinds of deployment.
+func deploymentImages(deployment string) []string {
+	images := []string{}
+	if depConfig, err := common.ConvertToDeploymentConfig(deployment, nil); err == nil && depConfig != nil {
+		for _, svc := range depConfig.Services {
+			if svc != nil && svc.Image != "" {
+				images = append(images, svc.Image)
+			}
+		}
+	}
+	return images
+}
+
+// Returns the bundle in a file.
+func ReadNodeBundleFile(bundleFile string) *NodeBundle {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	bundleBytes, err := ioutil.ReadFile(bundleFile)
+	if err != nil {
+		cliutils.Fatal(cliutils.FILE_IO_ERROR, msgPrinter.Sprintf("reading %s failed: %v", bundleFile, err))
+	}
+	bundle, err := ReadNodeBundle(bytes.NewReader(bundleBytes))
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Invalid node bundle %v: %v", bundleFile, err))
+	}
+	return bundle
+}
@@ -0,0 +1,69 @@
+package exchange
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func Test_NodeBundle(t *testing.T) {
+	bundle := NodeBundle{
+		Version:     NODE_BUNDLE_VERSION,
+		Org:         "myorg",
+		Id:          "node1",
+		Token:       "s3cret",
+		Name:        "node one",
+		NodeType:    "device",
+		Arch:        "arm64",
+		Pattern:     "myorg/pat1",
+		ExchangeUrl: "https://exchange/v1",
+		Images:      []string{"registry/img1@sha256:0123", "registry/img2:1.0"},
+		Created:     "2026-10-15T00:00:00Z",
+		NodePolicy:  []byte(`{"properties":[{"name":"site","value":"offshore"}]}`),
+		UserInput:   []byte(`[]`),
+	}
+
+	var buf bytes.Buffer
+	if err := bundle.Write(&buf); err != nil {
+		t.Fatalf("unable to write bundle, error: %v", err)
+	}
+	if read, err := ReadNodeBundle(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Errorf("unable to read bundle, error: %v", err)
+	} else if !reflect.DeepEqual(*read, bundle) {
+		t.Errorf("wrong bundle %v, expected %v", *read, bundle)
+	}
+
+	// the node policy and user input are optional
+	bundle.NodePolicy = nil
+	bundle.UserInput = nil
+	buf.Reset()
+	if err := bundle.Write(&buf); err != nil {
+		t.Fatalf("unable to write bundle, error: %v", err)
+	}
+	if read, err := ReadNodeBundle(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Errorf("unable to read bundle, error: %v", err)
+	} else if read.NodePolicy != nil || read.UserInput != nil {
+		t.Errorf("the bundle should have no node policy and no user input, got %v", *read)
+	}
+}
+
+func Test_ReadNodeBundle_invalid(t *testing.T) {
+	if _, err := ReadNodeBundle(bytes.NewReader([]byte("not a bundle"))); err == nil {
+		t.Errorf("a file that is not a gzipped tar file should not be read")
+	}
+
+	invalid := []NodeBundle{
+		{Version: NODE_BUNDLE_VERSION, Org: "myorg", Id: "node1"},
+		{Version: NODE_BUNDLE_VERSION, Org: "myorg", Token: "s3cret"},
+		{Version: NODE_BUNDLE_VERSION + 1, Org: "myorg", Id: "node1", Token: "s3cret"},
+	}
+	for _, bundle := range invalid {
+		var buf bytes.Buffer
+		if err := bundle.Write(&buf); err != nil {
+			t.Fatalf("unable to write bundle, error: %v", err)
+		}
+		if _, err := ReadNodeBundle(bytes.NewReader(buf.Bytes())); err == nil {
+			t.Errorf("bundle %v should not be read", bundle)
+		}
+	}
+}
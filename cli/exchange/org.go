@@ -52,7 +52,7 @@ func OrgList(org, userPwCreds, theOrg string, long bool) {
 			organizations = append(organizations, o)
 		}
 
-		jsonBytes, err := json.MarshalIndent(organizations, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(organizations)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'exchange org list' output: %v", err))
 		}
@@ -63,7 +63,7 @@ func OrgList(org, userPwCreds, theOrg string, long bool) {
 	}
 }
 
-func OrgCreate(org, userPwCreds, theOrg string, label string, desc string, tags []string, min int, max int, adjust int, maxNodes int, agbot string) {
+func OrgCreate(org, userPwCreds, theOrg string, label string, desc string, tags []string, min int, max int, adjust int, maxNodes int, maxServices int, agbot string) {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
 
@@ -115,9 +115,14 @@ func OrgCreate(org, userPwCreds, theOrg string, label string, desc string, tags
 		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Invalid input for --max-nodes. Negative integer is not allowed."))
 	}
 
+	// validate maxServices
+	if maxServices < 0 {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Invalid input for --max-services. Negative integer is not allowed."))
+	}
+
 	// add org to exchange
 	orgHb := exchange.HeartbeatIntervals{MinInterval: min, MaxInterval: max, IntervalAdjustment: adjust}
-	limits := exchange.OrgLimits{MaxNodes: maxNodes}
+	limits := exchange.OrgLimits{MaxNodes: maxNodes, MaxServices: maxServices}
 	postOrgReq := exchange.Organization{Label: label, Description: desc, HeartbeatIntv: &orgHb, Tags: orgTags, Limits: &limits}
 	cliutils.ExchangePutPost("Exchange", http.MethodPost, cliutils.GetExchangeUrl(), "orgs/"+theOrg, cliutils.OrgAndCreds(org, userPwCreds), []int{201}, postOrgReq, nil)
 
@@ -148,7 +153,7 @@ func OrgCreate(org, userPwCreds, theOrg string, label string, desc string, tags
 	msgPrinter.Println()
 }
 
-func OrgUpdate(org, userPwCreds, theOrg string, label string, desc string, tags []string, min int, max int, adjust int, maxNodes int) {
+func OrgUpdate(org, userPwCreds, theOrg string, label string, desc string, tags []string, min int, max int, adjust int, maxNodes int, maxServices int) {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
 
@@ -186,11 +191,23 @@ func OrgUpdate(org, userPwCreds, theOrg string, label string, desc string, tags
 		cliutils.ExchangePutPost("Exchange", http.MethodPatch, cliutils.GetExchangeUrl(), "orgs/"+theOrg, cliutils.OrgAndCreds(org, userPwCreds), []int{201}, newOrgHeartbeaat, nil)
 	}
 
-	// do nothing if maxNodes is -1
+	// do nothing if maxNodes and maxServices are -1
 	if maxNodes < -1 {
 		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Invalid input for --max-nodes. Only -1, 0 and positive integers are allowed."))
-	} else if maxNodes > -1 {
-		limits := exchange.OrgLimits{MaxNodes: maxNodes}
+	} else if maxServices < -1 {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Invalid input for --max-services. Only -1, 0 and positive integers are allowed."))
+	} else if maxNodes > -1 || maxServices > -1 {
+		// the limits are replaced as a whole, so keep the one that is not changed
+		limits := exchange.OrgLimits{}
+		if current := orgs.Orgs[theOrg].Limits; current != nil {
+			limits = *current
+		}
+		if maxNodes > -1 {
+			limits.MaxNodes = maxNodes
+		}
+		if maxServices > -1 {
+			limits.MaxServices = maxServices
+		}
 		newOrgLimits := exchange.Organization{Limits: &limits}
 		cliutils.ExchangePutPost("Exchange", http.MethodPatch, cliutils.GetExchangeUrl(), "orgs/"+theOrg, cliutils.OrgAndCreds(org, userPwCreds), []int{201}, newOrgLimits, nil)
 	}
@@ -199,6 +216,59 @@ func OrgUpdate(org, userPwCreds, theOrg string, label string, desc string, tags
 	msgPrinter.Println()
 }
 
+// The usage of an org, with the limits of its nodes and services.
+type OrgStatusOutput struct {
+	Org             string                 `json:"org"`
+	Users           int                    `json:"users"`
+	Nodes           exchange.OrgQuotaUsage `json:"nodes"`
+	RegisteredNodes int                    `json:"registeredNodes"`
+	NodeAgreements  int                    `json:"nodeAgreements"`
+	Services        exchange.OrgQuotaUsage `json:"services"`
+}
+
+// Display the usage of the org, and how much of its quota of nodes and services it uses.
+func OrgStatus(org, userPwCreds, theOrg string) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+	exchUrlBase := cliutils.GetExchangeUrl()
+
+	if theOrg == "" {
+		theOrg = org
+	}
+	if theOrg == "" {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Please specify the organization with the org argument, the -o flag or HZN_ORG_ID."))
+	}
+
+	var orgs exchange.GetOrganizationResponse
+	httpCode := cliutils.ExchangeGet("Exchange", exchUrlBase, "orgs/"+theOrg, cliutils.OrgAndCreds(org, userPwCreds), []int{200, 404}, &orgs)
+	if httpCode == 404 {
+		cliutils.Fatal(cliutils.NOT_FOUND, msgPrinter.Sprintf("org '%s' not found.", theOrg))
+	}
+
+	var status exchange.OrgStatus
+	cliutils.ExchangeGet("Exchange", exchUrlBase, "orgs/"+theOrg+"/status", cliutils.OrgAndCreds(org, userPwCreds), []int{200}, &status)
+
+	limits := orgs.Orgs[theOrg].Limits
+	output := OrgStatusOutput{
+		Org:             theOrg,
+		Users:           status.NumberOfUsers,
+		Nodes:           exchange.OrgQuotaUsage{Used: status.NumberOfNodes, Max: limits.GetMax(exchange.ORG_QUOTA_NODES)},
+		RegisteredNodes: status.NumberOfRegisteredNodes,
+		NodeAgreements:  status.NumberOfNodeAgreements,
+		Services:        exchange.OrgQuotaUsage{Max: limits.GetMax(exchange.ORG_QUOTA_SERVICES)},
+	}
+	if status.NumberOfServices != nil {
+		output.Services.Used = *status.NumberOfServices
+	} else {
+		// the older exchanges do not count the services in the org status
+		var services exchange.GetServicesResponse
+		cliutils.ExchangeGet("Exchange", exchUrlBase, "orgs/"+theOrg+"/services", cliutils.OrgAndCreds(org, userPwCreds), []int{200, 404}, &services)
+		output.Services.Used = len(services.Services)
+	}
+
+	fmt.Println(cliutils.MarshalIndent(output, "exchange org status"))
+}
+
 func OrgDel(org, userPwCreds, theOrg, agbot string, force bool) {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
@@ -247,8 +317,11 @@ func OrgDel(org, userPwCreds, theOrg, agbot string, force bool) {
 		for patternId, p := range patternsResp.Patterns {
 			// Convert pattern's value into JSON and unmarshal it
 			var servedPattern ServedPattern
-			patternJson := cliutils.MarshalIndent(p, "Cannot convert pattern to JSON")
-			cliutils.Unmarshal([]byte(patternJson), &servedPattern, msgPrinter.Sprintf("Cannot unmarshal served pattern"))
+			patternJson, err := json.Marshal(p)
+			if err != nil {
+				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("Cannot convert pattern to JSON: %v", err))
+			}
+			cliutils.Unmarshal(patternJson, &servedPattern, msgPrinter.Sprintf("Cannot unmarshal served pattern"))
 
 			if servedPattern.PatternOrg == theOrg || servedPattern.NodeOrg == theOrg {
 				cliutils.Verbose(msgPrinter.Sprintf("Removing pattern %s from agbot %s", patternId, agbot))
@@ -85,7 +85,7 @@ func PatternList(org string, userPw string, pattern string, namesOnly bool) {
 		for p := range resp.Patterns {
 			patterns = append(patterns, p)
 		}
-		jsonBytes, err := json.MarshalIndent(patterns, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(patterns)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'exchange pattern list' output: %v", err))
 		}
@@ -97,7 +97,7 @@ func PatternList(org string, userPw string, pattern string, namesOnly bool) {
 		if httpCode == 404 && pattern != "" {
 			cliutils.Fatal(cliutils.NOT_FOUND, msgPrinter.Sprintf("pattern '%s' not found in org %s", pattern, patOrg))
 		}
-		jsonBytes, err := json.MarshalIndent(patterns.Patterns, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(patterns.Patterns)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn exchange pattern list' output: %v", err))
 		}
@@ -62,7 +62,7 @@ func ServiceList(credOrg, userPw, service string, namesOnly bool, filePath strin
 		for k := range resp.Services {
 			services = append(services, k)
 		}
-		jsonBytes, err := json.MarshalIndent(services, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(services)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn exchange service list' output: %v", err))
 		}
@@ -98,7 +98,7 @@ func ServiceList(credOrg, userPw, service string, namesOnly bool, filePath strin
 				exchServices[sId] = s_copy
 			}
 		}
-		jsonBytes, err := json.MarshalIndent(exchServices, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(exchServices)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn exchange service list' output: %v", err))
 		}
@@ -207,11 +207,13 @@ func SignAndPublish(sf *common.ServiceFile, org, userPw, jsonFilePath, keyFilePa
 		msgPrinter.Printf("Updating %s in the Exchange...", exchId)
 		msgPrinter.Println()
 		cliutils.ExchangePutPost("Exchange", http.MethodPut, exchUrl, "orgs/"+org+"/services/"+exchId, cliutils.OrgAndCreds(org, userPw), []int{201}, svcInput, nil)
+		recordServiceHistory(exchUrl, org, exchId, cliutils.OrgAndCreds(org, userPw), HISTORY_UPDATE)
 	} else {
 		// Service not there, create it
 		msgPrinter.Printf("Creating %s in the Exchange...", exchId)
 		msgPrinter.Println()
 		cliutils.ExchangePutPost("Exchange", http.MethodPost, exchUrl, "orgs/"+org+"/services", cliutils.OrgAndCreds(org, userPw), []int{201}, svcInput, nil)
+		recordServiceHistory(exchUrl, org, exchId, cliutils.OrgAndCreds(org, userPw), HISTORY_CREATE)
 	}
 
 	// Store the public key in the exchange
@@ -421,6 +423,7 @@ func ServiceRemove(org, userPw, service string, force bool) {
 	if httpCode == 404 {
 		cliutils.Fatal(cliutils.NOT_FOUND, msgPrinter.Sprintf("service '%s' not found in org %s", service, svcorg))
 	}
+	recordServiceHistory(cliutils.GetExchangeUrl(), svcorg, service, cliutils.OrgAndCreds(org, userPw), HISTORY_REMOVE)
 }
 
 // List the public keys for a service that can be used to verify the deployment signature for the service
@@ -597,6 +600,7 @@ func ServiceAddPolicy(org string, credToUse string, service string, jsonFilePath
 	msgPrinter.Printf("Updating Service policy and re-evaluating all agreements based on this Service policy. Existing agreements might be cancelled and re-negotiated.")
 	msgPrinter.Println()
 	cliutils.ExchangePutPost("Exchange", http.MethodPut, exchUrl, "orgs/"+svcorg+"/services/"+service+"/policy", cliutils.OrgAndCreds(org, credToUse), []int{201}, policyFile, nil)
+	recordServicePolicyHistory(exchUrl, svcorg, service, cliutils.OrgAndCreds(org, credToUse), HISTORY_UPDATE)
 
 	msgPrinter.Printf("Service policy updated.")
 	msgPrinter.Println()
@@ -626,14 +630,16 @@ func ServiceRemovePolicy(org string, credToUse string, service string, force boo
 	//remove service policy
 	msgPrinter.Printf("Removing Service policy and re-evaluating all agreements. Existing agreements might be cancelled and re-negotiated.")
 	msgPrinter.Println()
-	cliutils.ExchangeDelete("Exchange", cliutils.GetExchangeUrl(), "orgs/"+svcorg+"/services/"+service+"/policy", cliutils.OrgAndCreds(org, credToUse), []int{204, 404})
+	if httpCode := cliutils.ExchangeDelete("Exchange", cliutils.GetExchangeUrl(), "orgs/"+svcorg+"/services/"+service+"/policy", cliutils.OrgAndCreds(org, credToUse), []int{204, 404}); httpCode == 204 {
+		recordServicePolicyHistory(cliutils.GetExchangeUrl(), svcorg, service, cliutils.OrgAndCreds(org, credToUse), HISTORY_REMOVE)
+	}
 	msgPrinter.Printf("Service policy removed.")
 	msgPrinter.Println()
 }
 
 // Display an empty service policy template as an object.
 func ServiceNewPolicy() {
-	policy.New()
+	policy.New(false)
 }
 
 // Get the Kubernetes operator yaml archive from the cluster deployment string and save it to a file
@@ -738,7 +744,7 @@ func ListServiceNodes(org, userPw, svcId, nodeOrg string) {
 		if nodes, ok := listNodes["nodes"]; !ok {
 			fmt.Println("[]")
 		} else {
-			jsonBytes, err := json.MarshalIndent(nodes, "", cliutils.JSON_INDENT)
+			jsonBytes, err := cliutils.MarshalOutput(nodes)
 			if err != nil {
 				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn exchange service listnode' output: %v", err))
 			}
@@ -1,7 +1,6 @@
 package exchange
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/open-horizon/anax/cli/cliutils"
 	"github.com/open-horizon/anax/i18n"
@@ -54,7 +53,7 @@ func UserList(org, userPwCreds, theUser string, allUsers, namesOnly bool) {
 		for u := range users.Users {
 			usernames = append(usernames, u)
 		}
-		jsonBytes, err := json.MarshalIndent(usernames, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(usernames)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'exchange user list' output: %v", err))
 		}
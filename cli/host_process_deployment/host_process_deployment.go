@@ -0,0 +1,164 @@
+package host_process_deployment
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/cli/dev"
+	"github.com/open-horizon/anax/cli/plugin_registry"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/rsapss-tool/sign"
+	"os"
+	"path/filepath"
+)
+
+const HOST_PROCESS_DEPLOYMENT_CONFIG_TYPE = "host_process"
+const BINARY_KEY = "binary"
+
+func init() {
+	plugin_registry.Register(HOST_PROCESS_DEPLOYMENT_CONFIG_TYPE, NewHostProcessDeploymentConfigPlugin())
+}
+
+// The host process deployment config plugin owns the deployment configs that refer to a binary run directly on the host:
+//
+//	"deployment": {
+//	  "binary": "bin/myservice",
+//	  "args": ["--verbose"],
+//	  "user": "myservice"
+//	}
+//
+// The binary file might be relative to the service definition file. When the service is published, the file is
+// replaced by its base64 encoded contents, so that the binary is covered by the deployment signature.
+type HostProcessDeploymentConfigPlugin struct {
+}
+
+func NewHostProcessDeploymentConfigPlugin() plugin_registry.DeploymentConfigPlugin {
+	return new(HostProcessDeploymentConfigPlugin)
+}
+
+func (p *HostProcessDeploymentConfigPlugin) Sign(dep map[string]interface{}, privKey *rsa.PrivateKey, ctx plugin_registry.PluginContext) (bool, string, string, error) {
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if owned, err := p.Validate(dep, nil); !owned || err != nil {
+		return owned, "", "", err
+	}
+
+	// Grab the binary file from the deployment config. The file might be relative to the service definition file.
+	binaryFilePath := filepath.Clean(dep[BINARY_KEY].(string))
+	if currentDir, ok := (ctx.Get("currentDir")).(string); !ok {
+		return true, "", "", errors.New(msgPrinter.Sprintf("plugin context must include 'currentDir' as the current directory of the service definition file"))
+	} else if !filepath.IsAbs(binaryFilePath) {
+		binaryFilePath = filepath.Join(currentDir, binaryFilePath)
+	}
+
+	binary, err := os.ReadFile(binaryFilePath)
+	if err != nil {
+		return true, "", "", errors.New(msgPrinter.Sprintf("unable to read binary %v, error %v", dep[BINARY_KEY], err))
+	}
+	dep[BINARY_KEY] = base64.StdEncoding.EncodeToString(binary)
+
+	// Stringify and sign the deployment string.
+	deployment, err := json.Marshal(dep)
+	if err != nil {
+		return true, "", "", errors.New(msgPrinter.Sprintf("failed to marshal %v deployment string %v, error %v", HOST_PROCESS_DEPLOYMENT_CONFIG_TYPE, dep, err))
+	}
+	depStr := string(deployment)
+
+	hasher := sha256.New()
+	_, err = hasher.Write(deployment)
+	if err != nil {
+		return true, "", "", err
+	}
+	sig, err := sign.Sha256HashOfInput(privKey, hasher)
+
+	if err != nil {
+		return true, "", "", errors.New(msgPrinter.Sprintf("problem signing %v deployment string: %v", HOST_PROCESS_DEPLOYMENT_CONFIG_TYPE, err))
+	}
+
+	return true, depStr, sig, nil
+}
+
+// A host process is not run from a container image.
+func (p *HostProcessDeploymentConfigPlugin) GetContainerImages(dep interface{}) (bool, []string, error) {
+	owned, err := p.Validate(dep, nil)
+	return owned, []string{}, err
+}
+
+// Return the default config object, which is nil in this case.
+func (p *HostProcessDeploymentConfigPlugin) DefaultConfig(imageInfo interface{}) interface{} {
+	return nil
+}
+
+// Return the default cluster config object, which is nil in this case.
+func (p *HostProcessDeploymentConfigPlugin) DefaultClusterConfig() interface{} {
+	return nil
+}
+
+func (p *HostProcessDeploymentConfigPlugin) Validate(dep interface{}, cdep interface{}) (bool, error) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if dc, ok := dep.(map[string]interface{}); !ok {
+		return false, nil
+	} else if m, ok := dc[BINARY_KEY]; !ok {
+		return false, nil
+	} else if ms, ok := m.(string); !ok {
+		return true, errors.New(msgPrinter.Sprintf("%v must have a string type value, has %T", BINARY_KEY, m))
+	} else if len(ms) == 0 {
+		return true, errors.New(msgPrinter.Sprintf("%v must be a non-empty string", BINARY_KEY))
+	} else if _, ok := dc["services"]; ok {
+		return true, errors.New(msgPrinter.Sprintf("the 'deployment' field cannot have both 'services' and '%v'", BINARY_KEY))
+	} else if depBytes, err := json.Marshal(dc); err != nil {
+		return true, errors.New(msgPrinter.Sprintf("failed to marshal %v deployment %v, error %v", HOST_PROCESS_DEPLOYMENT_CONFIG_TYPE, dc, err))
+	} else {
+		// The binary is read in when the service is signed, the rest of the deployment is checked the way the agent does.
+		hd := new(persistence.HostProcessDeploymentConfig)
+		if err := json.Unmarshal(depBytes, hd); err != nil {
+			return true, errors.New(msgPrinter.Sprintf("invalid %v deployment: %v", HOST_PROCESS_DEPLOYMENT_CONFIG_TYPE, err))
+		}
+		hd.Binary = ""
+		if err := hd.Validate(); err != nil {
+			return true, errors.New(msgPrinter.Sprintf("invalid %v deployment: %v", HOST_PROCESS_DEPLOYMENT_CONFIG_TYPE, err))
+		}
+		return true, nil
+	}
+}
+
+func (p *HostProcessDeploymentConfigPlugin) StartTest(homeDirectory string, userInputFile string, configFiles []string, configType string, noFSS bool, userCreds string, secretsFiles map[string]string) bool {
+	return p.unsupportedTest(homeDirectory, dev.SERVICE_START_COMMAND)
+}
+
+func (p *HostProcessDeploymentConfigPlugin) StopTest(homeDirectory string) bool {
+	return p.unsupportedTest(homeDirectory, dev.SERVICE_STOP_COMMAND)
+}
+
+// The test commands run the service containers, so they are not supported for host processes.
+func (p *HostProcessDeploymentConfigPlugin) unsupportedTest(homeDirectory string, command string) bool {
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	// Perform the common execution setup.
+	dir, _, _ := dev.CommonExecutionSetup(homeDirectory, "", dev.SERVICE_COMMAND, command)
+
+	// Get the service definition, so that we can check if we own the deployment config object.
+	serviceDef, sderr := dev.GetServiceDefinition(dir, dev.SERVICE_DEFINITION_FILE)
+	if sderr != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, fmt.Sprintf("'%v %v' %v", dev.SERVICE_COMMAND, command, sderr))
+	}
+
+	if owned, _ := p.Validate(serviceDef.Deployment, nil); !owned {
+		return false
+	}
+
+	cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' not supported for services using a %v deployment configuration, run the binary directly to test it", dev.SERVICE_COMMAND, command, HOST_PROCESS_DEPLOYMENT_CONFIG_TYPE))
+	// For the compiler
+	return true
+}
@@ -8,11 +8,14 @@ import (
 	"github.com/open-horizon/anax/cli/attribute"
 	"github.com/open-horizon/anax/cli/cliconfig"
 	"github.com/open-horizon/anax/cli/cliutils"
+	_ "github.com/open-horizon/anax/cli/compose_deployment"
 	"github.com/open-horizon/anax/cli/deploycheck"
 	"github.com/open-horizon/anax/cli/dev"
+	"github.com/open-horizon/anax/cli/diagnose"
 	"github.com/open-horizon/anax/cli/eventlog"
 	"github.com/open-horizon/anax/cli/exchange"
 	"github.com/open-horizon/anax/cli/fdo"
+	_ "github.com/open-horizon/anax/cli/host_process_deployment"
 	_ "github.com/open-horizon/anax/cli/i18n_messages"
 	"github.com/open-horizon/anax/cli/key"
 	"github.com/open-horizon/anax/cli/kube_deployment"
@@ -31,6 +34,7 @@ import (
 	"github.com/open-horizon/anax/cli/unregister"
 	"github.com/open-horizon/anax/cli/userinput"
 	"github.com/open-horizon/anax/cli/utilcmds"
+	_ "github.com/open-horizon/anax/cli/wasm_deployment"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/version"
@@ -122,6 +126,10 @@ Environment Variables:
   HZN_FDO_SVC_URL:  Override the URL that the 'hzn fdo' sub-commands use
 	  to communicate with FDO owner services. (By default hzn will ask the
 		Horizon Agent for the URL.)
+  HZN_OUTPUT_FORMAT:  Default value for the '--output-format' flag, the format of the
+      resources the commands display: json, yaml or table. The output that is
+      read back by other commands, like the signed node policy of
+      'hzn util signnodepolicy', is always json.
 
   All these environment variables and ones mentioned in the command help can be
   specified in user's configuration file: ~/.hzn/hzn.json with JSON format.
@@ -135,6 +143,7 @@ Environment Variables:
 	app.UsageTemplate(kingpin.CompactUsageTemplate)
 	cliutils.Opts.Verbose = app.Flag("verbose", msgPrinter.Sprintf("Verbose output.")).Short('v').Bool()
 	cliutils.Opts.IsDryRun = app.Flag("dry-run", msgPrinter.Sprintf("When calling the Horizon or Exchange API, do GETs, but don't do PUTs, POSTs, or DELETEs.")).Bool()
+	cliutils.Opts.Output = app.Flag("output-format", msgPrinter.Sprintf("The format of the resources the commands display: json, yaml or table. The default is json, or HZN_OUTPUT_FORMAT when it is set. The output that is read back by other commands, like the signed node policy of 'hzn util signnodepolicy', is always json.")).Envar("HZN_OUTPUT_FORMAT").Default(cliutils.OUTPUT_JSON).Enum(cliutils.GetOutputFormats()...)
 
 	agbotCmd := app.Command("agbot", msgPrinter.Sprintf("List and manage Horizon agreement bot resources."))
 
@@ -145,6 +154,10 @@ Environment Variables:
 	agbotAgreementListCmd := agbotAgreementCmd.Command("list | ls", msgPrinter.Sprintf("List the active or archived agreements this Horizon agreement bot has with edge nodes.")).Alias("ls").Alias("list")
 	agbotlistArchivedAgreements := agbotAgreementListCmd.Flag("archived", msgPrinter.Sprintf("List archived agreements instead of the active agreements.")).Short('r').Bool()
 	agbotAgreement := agbotAgreementListCmd.Arg("agreement-id", msgPrinter.Sprintf("Show the details of this active or archived agreement.")).String()
+	agbotAgreementListNode := agbotAgreementListCmd.Flag("node", msgPrinter.Sprintf("List only the agreements with this node, given as org/node or node.")).Short('n').String()
+	agbotAgreementListPolicy := agbotAgreementListCmd.Flag("policy", msgPrinter.Sprintf("List only the agreements made with this deployment policy or pattern, given as org/name or name.")).Short('p').String()
+	agbotAgreementListState := agbotAgreementListCmd.Flag("state", msgPrinter.Sprintf("List only the agreements in this state. An agreement is pending until the node replies to the proposal, agreed until it is finalized, and then finalized. The active state covers these 3 states. The terminated state covers the agreements being terminated or archived, and the failed state the ones terminated because something failed, for example the node did not reply or the service failed to start. The terminated and failed agreements are listed without -r.")).HintOptions(agreementbot.GetAgreementStates()...).Enum(agreementbot.GetAgreementStates()...)
+	agbotAgreementListSince := agbotAgreementListCmd.Flag("since", msgPrinter.Sprintf("List only the agreements that changed state since this time, given as a duration such as 10m or 2h, or as an RFC 3339 timestamp.")).String()
 
 	agbotCacheCmd := agbotCmd.Command("cache", msgPrinter.Sprintf("Manage cached agbot-serving organizations, patterns, and deployment policies."))
 	agbotCacheDeployPol := agbotCacheCmd.Command("deploymentpol | dep", msgPrinter.Sprintf("List served deployment policies cached in the agbot.")).Alias("dep").Alias("deploymentpol")
@@ -175,6 +188,8 @@ Environment Variables:
 	agreementCancelCmd := agreementCmd.Command("cancel | can", msgPrinter.Sprintf("Cancel 1 or all of the active agreements this edge node has made with a Horizon agreement bot. Usually an agbot will immediately negotiated a new agreement. If you want to cancel all agreements and not have this edge accept new agreements, run 'hzn unregister'.")).Alias("can").Alias("cancel")
 	cancelAllAgreements := agreementCancelCmd.Flag("all", msgPrinter.Sprintf("Cancel all of the current agreements.")).Short('a').Bool()
 	cancelAgreementId := agreementCancelCmd.Arg("agreement-id", msgPrinter.Sprintf("The active agreement to cancel.")).String()
+	agreementHistoryCmd := agreementCmd.Command("history", msgPrinter.Sprintf("Show how an active or archived agreement was negotiated and why it was cancelled, or why its proposal was rejected."))
+	historyAgreementId := agreementHistoryCmd.Arg("agreement-id", msgPrinter.Sprintf("The agreement to show the history of.")).Required().String()
 
 	archCmd := app.Command("architecture", msgPrinter.Sprintf("Show the architecture of this machine (as defined by Horizon and golang)."))
 
@@ -186,6 +201,7 @@ Environment Variables:
 	deploycheckUserPw := deploycheckCmd.Flag("user-pw", msgPrinter.Sprintf("Horizon exchange user credential to query exchange resources. If not specified, HZN_EXCHANGE_USER_AUTH or HZN_EXCHANGE_NODE_AUTH will be used as a default. If you don't prepend it with the organization id, it will automatically be prepended with the -o value.")).Short('u').PlaceHolder("USER:PW").String()
 	deploycheckCheckAll := deploycheckCmd.Flag("check-all", msgPrinter.Sprintf("Show the compatibility status of all the service versions referenced in the deployment policy.")).Short('c').Bool()
 	deploycheckLong := deploycheckCmd.Flag("long", msgPrinter.Sprintf("Show policies and userinput used for the compatibility checking.")).Short('l').Bool()
+	deploycheckExplain := deploycheckCmd.Flag("explain", msgPrinter.Sprintf("Show how each constraint clause of the policy check was evaluated, with the property value it was evaluated against and whether it matched. It applies to the 'policy' and 'all' subcommands.")).Bool()
 	allCompCmd := deploycheckCmd.Command("all", msgPrinter.Sprintf("Check all compatibilities for a deployment."))
 	allCompNodeArch := allCompCmd.Flag("arch", msgPrinter.Sprintf("The architecture of the node. It is required when -n is not specified. If omitted, the service of all the architectures referenced in the deployment policy or pattern will be checked for compatibility.")).Short('a').String()
 	allCompNodeType := allCompCmd.Flag("node-type", msgPrinter.Sprintf("The node type. The valid values are 'device' and 'cluster'. The default value is the type of the node provided by -n or current registered device, if omitted.")).Short('t').String()
@@ -193,7 +209,7 @@ Environment Variables:
 	allCompNodeOrg := allCompCmd.Flag("node-org", msgPrinter.Sprintf("The organization of the node. The default value is the organization of the node provided by -n or current registered device, if omitted.")).Short('O').String()
 	allCompNodeId := allCompCmd.Flag("node-id", msgPrinter.Sprintf("The Horizon exchange node ID. Mutually exclusive with --ha-group, --node-pol and --node-ui. If omitted, the node ID that the current device is registered with will be used. This flag can be repeated to specify more than one nodes. If you don't prepend a node id with the organization id, it will automatically be prepended with the -o value.")).Short('n').Strings()
 	allCompHAGroup := allCompCmd.Flag("ha-group", msgPrinter.Sprintf("The name of an HA group. The deployment check will be performed on all the nodes within the given HA group. Mutually exclusive with -n, --node-pol and --node-ui.")).String()
-	allCompNodePolFile := allCompCmd.Flag("node-pol", msgPrinter.Sprintf("The JSON input file name containing the node policy. Mutually exclusive with -n, --ha-group, -p and -P.")).String()
+	allCompNodePolFile := allCompCmd.Flag("node-pol", msgPrinter.Sprintf("The JSON input file name containing the node policy. Mutually exclusive with -n, --ha-group, -p and -P. For a cluster node, the openhorizon.kubernetesVersion and openhorizon.kubernetesNamespaceScoped properties are checked against the requirements of the cluster services.")).String()
 	allCompNodeUIFile := allCompCmd.Flag("node-ui", msgPrinter.Sprintf("The JSON input file name containing the node user input. Mutually exclusive with -n, --ha-group.")).String()
 	allCompBPolId := allCompCmd.Flag("business-pol-id", "").Hidden().String()
 	allCompDepPolId := allCompCmd.Flag("deployment-pol-id", msgPrinter.Sprintf("The Horizon exchange deployment policy ID. Mutually exclusive with -B, -p and -P. If you don't prepend it with the organization id, it will automatically be prepended with the node's organization id.")).Short('b').String()
@@ -203,13 +219,18 @@ Environment Variables:
 	allCompSvcFile := allCompCmd.Flag("service", msgPrinter.Sprintf("(optional) The JSON input file name containing the service definition. If omitted, the service defined in the deployment policy or pattern will be retrieved from the Exchange. This flag can be repeated to specify different versions of the service.")).Strings()
 	allCompPatternId := allCompCmd.Flag("pattern-id", msgPrinter.Sprintf("The Horizon exchange pattern ID. Mutually exclusive with -P, -b, -B --node-pol and --service-pol. If you don't prepend it with the organization id, it will automatically be prepended with the node's organization id.")).Short('p').String()
 	allCompPatternFile := allCompCmd.Flag("pattern", msgPrinter.Sprintf("The JSON input file name containing the pattern. Mutually exclusive with -p, -b and -B, --node-pol and --service-pol.")).Short('P').String()
+	k8sRenderCmd := deploycheckCmd.Command("k8s-render | kr", msgPrinter.Sprintf("Render the kubernetes objects of a cluster service locally, the same way the agent resolves them before installing the operator. The exchange is not contacted.")).Alias("kr").Alias("k8s-render")
+	k8sRenderSvcFile := k8sRenderCmd.Flag("service", msgPrinter.Sprintf("The JSON input file name containing the service definition. The operatorYamlArchive in the clusterDeployment can be a file path relative to the service definition file.")).Short('f').Required().String()
+	k8sRenderNodeUIFile := k8sRenderCmd.Flag("node-ui", msgPrinter.Sprintf("The JSON input file name containing the node user input. The values override the default values of the service user input variables in the env var config map.")).String()
+	k8sRenderNamespace := k8sRenderCmd.Flag("namespace", msgPrinter.Sprintf("The namespace requested for the service by the deployment policy or pattern. If omitted, the namespace in the operator package or the agent namespace will be used.")).Short('n').String()
+	k8sRenderAgId := k8sRenderCmd.Flag("agreement-id", msgPrinter.Sprintf("The agreement id used in the names of the objects created by the agent. If omitted, '%v' will be used.", deploycheck.RENDER_DEFAULT_AGREEMENT_ID)).String()
 	policyCompCmd := deploycheckCmd.Command("policy | pol", msgPrinter.Sprintf("Check policy compatibility.")).Alias("pol").Alias("policy")
 	policyCompNodeArch := policyCompCmd.Flag("arch", msgPrinter.Sprintf("The architecture of the node. It is required when -n is not specified. If omitted, the service of all the architectures referenced in the deployment policy will be checked for compatibility.")).Short('a').String()
 	policyCompNodeType := policyCompCmd.Flag("node-type", msgPrinter.Sprintf("The node type. The valid values are 'device' and 'cluster'. The default value is the type of the node provided by -n or current registered device, if omitted.")).Short('t').String()
 	policyCompNodeNs := policyCompCmd.Flag("cluster-namespace", msgPrinter.Sprintf("The Kubernetes cluster namespace for the node if the node type is 'cluster'. The default value is 'openhorizon-agent', if omitted. The value is ignored when the node type is 'device'")).Short('s').String()
 	policyCompNodeId := policyCompCmd.Flag("node-id", msgPrinter.Sprintf("The Horizon exchange node ID. Mutually exclusive with --ha-group and --node-pol. If omitted, the node ID that the current device is registered with will be used. This flag can be repeated to specify more than one nodes. If you don't prepend a node id with the organization id, it will automatically be prepended with the -o value.")).Short('n').Strings()
 	policyCompHAGroup := policyCompCmd.Flag("ha-group", msgPrinter.Sprintf("The name of an HA group. The deployment check will be performed on all the nodes within the given HA group. Mutually exclusive with -n and --node-pol.")).String()
-	policyCompNodePolFile := policyCompCmd.Flag("node-pol", msgPrinter.Sprintf("The JSON input file name containing the node policy. Mutually exclusive with -n, --ha-group.")).String()
+	policyCompNodePolFile := policyCompCmd.Flag("node-pol", msgPrinter.Sprintf("The JSON input file name containing the node policy. Mutually exclusive with -n, --ha-group. For a cluster node, the openhorizon.kubernetesVersion and openhorizon.kubernetesNamespaceScoped properties are checked against the requirements of the cluster services.")).String()
 	policyCompBPolId := policyCompCmd.Flag("business-pol-id", "").Hidden().String()
 	policyCompDepPolId := policyCompCmd.Flag("deployment-pol-id", msgPrinter.Sprintf("The Horizon exchange deployment policy ID. Mutually exclusive with -B. If you don't prepend it with the organization id, it will automatically be prepended with the node's organization id.")).Short('b').String()
 	policyCompBPolFile := policyCompCmd.Flag("business-pol", "").Hidden().String()
@@ -270,18 +291,33 @@ Environment Variables:
 	devServiceNewCmdNoPattern := devServiceNewCmd.Flag("noPattern", msgPrinter.Sprintf("Indicates no pattern definition file will be created.")).Bool()
 	devServiceNewCmdNoPolicy := devServiceNewCmd.Flag("noPolicy", msgPrinter.Sprintf("Indicate no policy file will be created.")).Bool()
 	devServiceNewCmdCfg := devServiceNewCmd.Flag("dconfig", msgPrinter.Sprintf("Indicates the type of deployment configuration that will be used, native (the default), or %v. This flag can be specified more than once to create a service with more than 1 kind of deployment configuration.", kube_deployment.KUBE_DEPLOYMENT_CONFIG_TYPE)).Short('c').Default("native").Strings()
-	devServiceStartTestCmd := devServiceCmd.Command("start", msgPrinter.Sprintf("Run a service in a mocked Horizon Agent environment. This command is not supported for services using the %v deployment configuration.", kube_deployment.KUBE_DEPLOYMENT_CONFIG_TYPE))
+	devServicePublishCmd := devServiceCmd.Command("publish | pub", msgPrinter.Sprintf("Build the images of the service project for each architecture with docker buildx, push them, and publish the service definition of each architecture in the Horizon Exchange with the images referenced by digest. The service definition is read once for each architecture with ARCH set to it, so it must use $ARCH for the arch of the service.")).Alias("pub").Alias("publish")
+	devServicePublishArch := devServicePublishCmd.Flag("arch", msgPrinter.Sprintf("An architecture to build and publish the service for. This flag can be repeated. The default is amd64, arm and arm64.")).Short('a').Strings()
+	devServicePublishMultiArch := devServicePublishCmd.Flag("multi-arch", msgPrinter.Sprintf("Build each image once for all the architectures and push it as a manifest list, which the service definitions of all the architectures reference. The image names must not depend on $ARCH. Without this flag, an image is built and pushed for each architecture.")).Bool()
+	devServicePublishDockerfile := devServicePublishCmd.Flag("dockerfile", msgPrinter.Sprintf("The Dockerfile the images are built from. If omitted, Dockerfile.<arch> in the build context is used when it exists and --multi-arch is not specified, otherwise Dockerfile in the build context.")).String()
+	devServicePublishContext := devServicePublishCmd.Flag("context", msgPrinter.Sprintf("The docker build context directory. If omitted, the current directory is used.")).Default(".").String()
+	devServicePublishPrivKeyFile := devServicePublishCmd.Flag("private-key-file", msgPrinter.Sprintf("The path of a private key file to be used to sign the service. If not specified, the environment variable HZN_PRIVATE_KEY_FILE will be used. If HZN_PRIVATE_KEY_FILE not specified, ~/.hzn/keys/service.private.key will be used. If none are specified, a random key pair will be generated and the public key will be stored with the service.")).Short('k').ExistingFile()
+	devServicePublishPubKeyFile := devServicePublishCmd.Flag("public-key-file", msgPrinter.Sprintf("The path of public key file (that corresponds to the private key) that should be stored with the service, to be used by the Horizon Agent to verify the signature. If this flag is not specified, the public key will be calculated from the private key.")).Short('K').ExistingFile()
+	devServicePublishRegistryTokens := devServicePublishCmd.Flag("registry-token", msgPrinter.Sprintf("Docker registry domain and auth that should be stored with the service, to enable the Horizon edge node to access the service's docker images. This flag can be repeated, and each flag should be in the format: registry:user:token")).Short('r').Strings()
+	devServicePublishOverwrite := devServicePublishCmd.Flag("overwrite", msgPrinter.Sprintf("Overwrite the existing version if the service exists in the Exchange. It will skip the 'do you want to overwrite' prompt.")).Short('O').Bool()
+	devServicePublishPolicyFile := devServicePublishCmd.Flag("service-policy-file", msgPrinter.Sprintf("The path of the service policy JSON file to be used for the service of each architecture. This flag is optional")).Short('p').String()
+	devServicePublishUserPw := devServicePublishCmd.Flag("user-pw", msgPrinter.Sprintf("Horizon Exchange user credentials to publish the service. The default is HZN_EXCHANGE_USER_AUTH environment variable. If you don't prepend it with the user's org, it will automatically be prepended with the value of the HZN_ORG_ID environment variable.")).Short('u').PlaceHolder("USER:PW").String()
+	devServiceStartTestCmd := devServiceCmd.Command("start", msgPrinter.Sprintf("Run a service in a mocked Horizon Agent environment. A service using the %v deployment configuration is run in a local kind or k3d cluster, the current kubeconfig context is used unless %v is set.", kube_deployment.KUBE_DEPLOYMENT_CONFIG_TYPE, dev.DEVTOOL_HZN_KUBE_CONTEXT))
 	devServiceUserInputFile := devServiceStartTestCmd.Flag("userInputFile", msgPrinter.Sprintf("File containing user input values for running a test. If omitted, the userinput file for the project will be used.")).Short('f').String()
 	devServiceConfigFile := devServiceStartTestCmd.Flag("configFile", msgPrinter.Sprintf("File to be made available through the sync service APIs. This flag can be repeated to populate multiple files.")).Short('m').Strings()
 	devServiceConfigType := devServiceStartTestCmd.Flag("type", msgPrinter.Sprintf("The type of file to be made available through the sync service APIs. All config files are presumed to be of the same type. This flag is required if any configFiles are specified.")).Short('t').String()
 	devServiceNoFSS := devServiceStartTestCmd.Flag("noFSS", msgPrinter.Sprintf("Do not bring up file sync service (FSS) containers. They are brought up by default.")).Short('S').Bool()
 	devServiceStartCmdUserPw := devServiceStartTestCmd.Flag("user-pw", msgPrinter.Sprintf("Horizon Exchange user credentials to query exchange resources. Specify it when you want to automatically fetch the missing dependent services from the Exchange. The default is HZN_EXCHANGE_USER_AUTH environment variable. If you don't prepend it with the user's org, it will automatically be prepended with the value of the HZN_ORG_ID environment variable.")).Short('u').PlaceHolder("USER:PW").String()
 	devServiceStartSecretsFiles := devServiceStartTestCmd.Flag("secret", msgPrinter.Sprintf("Filepath of a file containing a secret that is required by the service or one of its dependent services. The filename must match a secret name in the service definition. The file is encoded in JSON as an object containing two keys both typed as a string; \"key\" is used to indicate the kind of secret, and \"value\" is the string form of the secret. This flag can be repeated.")).Strings()
-	devServiceStopTestCmd := devServiceCmd.Command("stop", msgPrinter.Sprintf("Stop a service that is running in a mocked Horizon Agent environment. This command is not supported for services using the %v deployment configuration.", kube_deployment.KUBE_DEPLOYMENT_CONFIG_TYPE))
+	devServiceStopTestCmd := devServiceCmd.Command("stop", msgPrinter.Sprintf("Stop a service that is running in a mocked Horizon Agent environment."))
 	devServiceValidateCmd := devServiceCmd.Command("verify | vf", msgPrinter.Sprintf("Validate the project for completeness and schema compliance.")).Alias("vf").Alias("verify")
 	devServiceVerifyUserInputFile := devServiceValidateCmd.Flag("userInputFile", msgPrinter.Sprintf("File containing user input values for verification of a project. If omitted, the userinput file for the project will be used.")).Short('f').String()
 	devServiceValidateCmdUserPw := devServiceValidateCmd.Flag("user-pw", msgPrinter.Sprintf("Horizon Exchange user credentials to query exchange resources. Specify it when you want to automatically fetch the missing dependent services from the Exchange. The default is HZN_EXCHANGE_USER_AUTH environment variable. If you don't prepend it with the user's org, it will automatically be prepended with the value of the HZN_ORG_ID environment variable.")).Short('u').PlaceHolder("USER:PW").String()
 
+	diagnoseCmd := app.Command("diagnose", msgPrinter.Sprintf("Check the connectivity, the clock and the certificates of this Horizon edge node, and save the results with the recent event logs and the agent status into a support bundle."))
+	diagnoseOutput := diagnoseCmd.Flag("output", msgPrinter.Sprintf("The file the support bundle is saved in. The default is hzn-diagnostics-<timestamp>.tar.gz in the current directory.")).Short('o').String()
+	diagnoseNoBundle := diagnoseCmd.Flag("no-bundle", msgPrinter.Sprintf("Only display the results of the checks, do not save the support bundle.")).Short('n').Bool()
+
 	envCmd := app.Command("env", msgPrinter.Sprintf("Show the Horizon Environment Variables."))
 
 	eventlogCmd := app.Command("eventlog | ev", msgPrinter.Sprintf("List the event logs for the current or all registrations.")).Alias("ev").Alias("eventlog")
@@ -314,7 +350,7 @@ Environment Variables:
 	exAgbotListPatsCmd := exAgbotCmd.Command("listpattern | lspa", msgPrinter.Sprintf("Display the patterns that this agbot is serving.")).Alias("lspa").Alias("listpattern")
 	exAgbotLP := exAgbotListPatsCmd.Arg("agbot", msgPrinter.Sprintf("The agbot to list the patterns for.")).Required().String()
 	exAgbotLPPatOrg := exAgbotListPatsCmd.Arg("patternorg", msgPrinter.Sprintf("The organization of the 1 pattern to list.")).String()
-	exAgbotLPPat := exAgbotListPatsCmd.Arg("pattern", msgPrinter.Sprintf("The name of the 1 pattern to list.")).String()
+	exAgbotLPPat := exAgbotListPatsCmd.Arg("pattern", msgPrinter.Sprintf("The name of the 1 pattern to list.")).HintAction(cliutils.CompletePatterns).String()
 	exAgbotLPNodeOrg := exAgbotListPatsCmd.Arg("nodeorg", msgPrinter.Sprintf("The organization of the nodes that should be searched. Defaults to patternorg.")).String()
 	exAgbotDelPolCmd := exAgbotCmd.Command("removedeploymentpol | rmpo", msgPrinter.Sprintf("Remove this deployment policy from the list of policies this agbot is serving. Currently only support removing all the deployment policies from an organization.")).Alias("removebusinesspol").Alias("rmpo").Alias("removedeploymentpol")
 	exAgbotDPolAg := exAgbotDelPolCmd.Arg("agbot", msgPrinter.Sprintf("The agbot to remove the deployment policy from.")).Required().String()
@@ -322,7 +358,7 @@ Environment Variables:
 	exAgbotDelPatCmd := exAgbotCmd.Command("removepattern | rmpa", msgPrinter.Sprintf("Remove this pattern from the list of patterns this agbot is serving.")).Alias("rmpa").Alias("removepattern")
 	exAgbotDP := exAgbotDelPatCmd.Arg("agbot", msgPrinter.Sprintf("The agbot to remove the pattern from.")).Required().String()
 	exAgbotDPPatOrg := exAgbotDelPatCmd.Arg("patternorg", msgPrinter.Sprintf("The organization of the pattern to remove.")).Required().String()
-	exAgbotDPPat := exAgbotDelPatCmd.Arg("pattern", msgPrinter.Sprintf("The name of the pattern to remove.")).Required().String()
+	exAgbotDPPat := exAgbotDelPatCmd.Arg("pattern", msgPrinter.Sprintf("The name of the pattern to remove.")).HintAction(cliutils.CompletePatterns).Required().String()
 	exAgbotDPNodeOrg := exAgbotDelPatCmd.Arg("nodeorg", msgPrinter.Sprintf("The organization of the nodes that should be searched. Defaults to patternorg.")).String()
 
 	exCatalogCmd := exchangeCmd.Command("catalog | cat", msgPrinter.Sprintf("List all public services/patterns in all orgs that have orgType: IBM.")).Alias("cat").Alias("catalog")
@@ -339,18 +375,21 @@ Environment Variables:
 	exBusinessAddPolicyPolicy := exBusinessAddPolicyCmd.Arg("policy", msgPrinter.Sprintf("The name of the deployment policy to add or overwrite.")).Required().String()
 	exBusinessAddPolicyJsonFile := exBusinessAddPolicyCmd.Flag("json-file", msgPrinter.Sprintf("The path of a JSON file containing the metadata necessary to create/update the service policy in the Horizon Exchange. Specify -f- to read from stdin.")).Short('f').Required().String()
 	exBusinessAddPolNoConstraint := exBusinessAddPolicyCmd.Flag("no-constraints", msgPrinter.Sprintf("Allow this deployment policy to be published even though it does not have any constraints.")).Bool()
+	exBusinessHistoryCmd := exBusinessCmd.Command("history", msgPrinter.Sprintf("Display the changes to a deployment policy that were made with this command, who made them and when, with the diff of each change. The history is kept in ~/.hzn/history, or in the directory set by HZN_HISTORY_DIR."))
+	exBusinessHistoryIdTok := exBusinessHistoryCmd.Flag("id-token", msgPrinter.Sprintf("The Horizon ID and password of the user.")).Short('n').PlaceHolder("ID:TOK").String()
+	exBusinessHistoryPolicy := exBusinessHistoryCmd.Arg("policy", msgPrinter.Sprintf("The deployment policy. Use <org>/<policy> to specify a public policy in another org.")).HintAction(cliutils.CompleteDeploymentPolicies).Required().String()
 	exBusinessListPolicyCmd := exBusinessCmd.Command("listpolicy | ls", msgPrinter.Sprintf("Display the deployment policies from the Horizon Exchange.")).Alias("ls").Alias("listpolicy")
 	exBusinessListPolicyIdTok := exBusinessListPolicyCmd.Flag("id-token", msgPrinter.Sprintf("The Horizon ID and password of the user.")).Short('n').PlaceHolder("ID:TOK").String()
 	exBusinessListPolicyLong := exBusinessListPolicyCmd.Flag("long", msgPrinter.Sprintf("Display detailed output about the deployment policies.")).Short('l').Bool()
-	exBusinessListPolicyPolicy := exBusinessListPolicyCmd.Arg("policy", msgPrinter.Sprintf("List just this one deployment policy. Use <org>/<policy> to specify a public policy in another org, or <org>/ to list all of the public policies in another org.")).String()
+	exBusinessListPolicyPolicy := exBusinessListPolicyCmd.Arg("policy", msgPrinter.Sprintf("List just this one deployment policy. Use <org>/<policy> to specify a public policy in another org, or <org>/ to list all of the public policies in another org.")).HintAction(cliutils.CompleteDeploymentPolicies).String()
 	exBusinessNewPolicyCmd := exBusinessCmd.Command("new", msgPrinter.Sprintf("Display an empty deployment policy template that can be filled in."))
 	exBusinessRemovePolicyCmd := exBusinessCmd.Command("removepolicy | rmp", msgPrinter.Sprintf("Remove the deployment policy in the Horizon Exchange.")).Alias("rmp").Alias("removepolicy")
 	exBusinessRemovePolicyIdTok := exBusinessRemovePolicyCmd.Flag("id-token", msgPrinter.Sprintf("The Horizon ID and password of the user.")).Short('n').PlaceHolder("ID:TOK").String()
 	exBusinessRemovePolicyForce := exBusinessRemovePolicyCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('f').Bool()
-	exBusinessRemovePolicyPolicy := exBusinessRemovePolicyCmd.Arg("policy", msgPrinter.Sprintf("The name of the deployment policy to be removed.")).Required().String()
+	exBusinessRemovePolicyPolicy := exBusinessRemovePolicyCmd.Arg("policy", msgPrinter.Sprintf("The name of the deployment policy to be removed.")).HintAction(cliutils.CompleteDeploymentPolicies).Required().String()
 	exBusinessUpdatePolicyCmd := exBusinessCmd.Command("updatepolicy | upp", msgPrinter.Sprintf("Update one attribute of an existing deployment policy in the Horizon Exchange. The supported attributes are the top level attributes in the policy definition as shown by the command 'hzn exchange deployment new'.")).Alias("upp").Alias("updatepolicy")
 	exBusinessUpdatePolicyIdTok := exBusinessUpdatePolicyCmd.Flag("id-token", msgPrinter.Sprintf("The Horizon ID and password of the user.")).Short('n').PlaceHolder("ID:TOK").String()
-	exBusinessUpdatePolicyPolicy := exBusinessUpdatePolicyCmd.Arg("policy", msgPrinter.Sprintf("The name of the policy to be updated in the Horizon Exchange.")).Required().String()
+	exBusinessUpdatePolicyPolicy := exBusinessUpdatePolicyCmd.Arg("policy", msgPrinter.Sprintf("The name of the policy to be updated in the Horizon Exchange.")).HintAction(cliutils.CompleteDeploymentPolicies).Required().String()
 	exBusinessUpdatePolicyJsonFile := exBusinessUpdatePolicyCmd.Flag("json-file", msgPrinter.Sprintf("The path to the json file containing the updated deployment policy attribute to be changed in the Horizon Exchange. Specify -f- to read from stdin.")).Short('f').Required().String()
 
 	exNMPCmd := exchangeCmd.Command("nmp", msgPrinter.Sprintf("List and manage node management policies in the Horizon Exchange."))
@@ -383,8 +422,19 @@ Environment Variables:
 	exNodeCmd := exchangeCmd.Command("node", msgPrinter.Sprintf("List and manage nodes in the Horizon Exchange"))
 	exNodeAddPolicyCmd := exNodeCmd.Command("addpolicy | addp", msgPrinter.Sprintf("Add or replace the node policy in the Horizon Exchange.")).Alias("addp").Alias("addpolicy")
 	exNodeAddPolicyIdTok := exNodeAddPolicyCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modify the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
-	exNodeAddPolicyNode := exNodeAddPolicyCmd.Arg("node", msgPrinter.Sprintf("Add or replace policy for this node.")).Required().String()
+	exNodeAddPolicyNode := exNodeAddPolicyCmd.Arg("node", msgPrinter.Sprintf("Add or replace policy for this node.")).HintAction(cliutils.CompleteNodes).Required().String()
 	exNodeAddPolicyJsonFile := exNodeAddPolicyCmd.Flag("json-file", msgPrinter.Sprintf("The path of a JSON file containing the metadata necessary to create/update the node policy in the Horizon exchange. Specify -f- to read from stdin. A node policy contains the 'deployment' and 'management' attributes. Please use 'hzn policy new' to see the node policy format.")).Short('f').Required().String()
+	exNodeBundleCmd := exNodeCmd.Command("bundle", msgPrinter.Sprintf("Create the node resource in the Horizon Exchange and write a node bundle that a device can register with, using 'hzn register --from-bundle', without user credentials. The bundle holds the node id and token, and the pattern, node policy and user input of the node. It also lists the images of the services of the pattern, which are pulled on the device when it registers, so that they are on the device when it is deployed to a site with no access to the image registries."))
+	exNodeBundleNode := exNodeBundleCmd.Arg("node", msgPrinter.Sprintf("The node to be created.")).Required().String()
+	exNodeBundleToken := exNodeBundleCmd.Arg("token", msgPrinter.Sprintf("The token the new node should have. If not specified, a random token is generated.")).String()
+	exNodeBundleFile := exNodeBundleCmd.Flag("file", msgPrinter.Sprintf("The file the bundle is written to.")).Short('f').Required().String()
+	exNodeBundleArch := exNodeBundleCmd.Flag("arch", msgPrinter.Sprintf("The node architecture. The images of the services of the pattern are only added to the bundle when it is specified.")).Short('a').String()
+	exNodeBundleName := exNodeBundleCmd.Flag("name", msgPrinter.Sprintf("The name of the node. If not specified, it will be the same as the node id.")).Short('m').String()
+	exNodeBundleType := exNodeBundleCmd.Flag("node-type", msgPrinter.Sprintf("The type of the node. The valid values are: device, cluster.")).Short('T').Default("device").String()
+	exNodeBundlePattern := exNodeBundleCmd.Flag("pattern", msgPrinter.Sprintf("The pattern the node registers with. If the pattern is from a different organization than the node, use the 'other_org/pattern' format.")).Short('p').HintAction(cliutils.CompletePatterns).String()
+	exNodeBundlePolicy := exNodeBundleCmd.Flag("policy", msgPrinter.Sprintf("A JSON file with the node policy the node registers with.")).String()
+	exNodeBundleUserInput := exNodeBundleCmd.Flag("input-file", msgPrinter.Sprintf("A JSON file with the user input the node registers with.")).Short('i').String()
+	exNodeBundleImages := exNodeBundleCmd.Flag("image", msgPrinter.Sprintf("An image to pull on the device when it registers, in addition to the images of the services of the pattern. This flag can be repeated.")).Strings()
 	exNodeCreateCmd := exNodeCmd.Command("create | cr", msgPrinter.Sprintf("Create the node resource in the Horizon Exchange.")).Alias("cr").Alias("create")
 	exNodeCreateNodeIdTok := exNodeCreateCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be created. The node ID must be unique within the organization.")).Short('n').PlaceHolder("ID:TOK").String()
 	exNodeCreateNodeArch := exNodeCreateCmd.Flag("arch", msgPrinter.Sprintf("Your node architecture. If not specified, architecture will be left blank.")).Short('a').String()
@@ -394,55 +444,55 @@ Environment Variables:
 	exNodeCreateToken := exNodeCreateCmd.Arg("token", msgPrinter.Sprintf("The token the new node should have.")).String()
 	exNodeConfirmCmd := exNodeCmd.Command("confirm | con", msgPrinter.Sprintf("Check to see if the specified node and token are valid in the Horizon Exchange.")).Alias("con").Alias("confirm")
 	exNodeConfirmNodeIdTok := exNodeConfirmCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon exchange node ID and token to be checked. If not specified, HZN_EXCHANGE_NODE_AUTH will be used as a default. Mutually exclusive with <node> and <token> arguments.")).Short('n').PlaceHolder("ID:TOK").String()
-	exNodeConfirmNode := exNodeConfirmCmd.Arg("node", msgPrinter.Sprintf("The node id to be checked. Mutually exclusive with -n flag.")).String()
+	exNodeConfirmNode := exNodeConfirmCmd.Arg("node", msgPrinter.Sprintf("The node id to be checked. Mutually exclusive with -n flag.")).HintAction(cliutils.CompleteNodes).String()
 	exNodeConfirmToken := exNodeConfirmCmd.Arg("token", msgPrinter.Sprintf("The token for the node. Mutually exclusive with -n flag.")).String()
 	exNodeListCmd := exNodeCmd.Command("list | ls", msgPrinter.Sprintf("Display the node resources from the Horizon Exchange.")).Alias("ls").Alias("list")
-	exNode := exNodeListCmd.Arg("node", msgPrinter.Sprintf("List just this one node.")).String()
+	exNode := exNodeListCmd.Arg("node", msgPrinter.Sprintf("List just this one node.")).HintAction(cliutils.CompleteNodes).String()
 	exNodeListNodeIdTok := exNodeListCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modify the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
 	exNodeLong := exNodeListCmd.Flag("long", msgPrinter.Sprintf("When listing all of the nodes, show the entire resource of each node, instead of just the name.")).Short('l').Bool()
 	exNodeErrorsList := exNodeCmd.Command("listerrors | lse", msgPrinter.Sprintf("List the node errors currently surfaced to the Exchange.")).Alias("lse").Alias("listerrors")
 	exNodeErrorsListIdTok := exNodeErrorsList.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modify the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
-	exNodeErrorsListNode := exNodeErrorsList.Arg("node", msgPrinter.Sprintf("List surfaced errors for this node.")).Required().String()
+	exNodeErrorsListNode := exNodeErrorsList.Arg("node", msgPrinter.Sprintf("List surfaced errors for this node.")).HintAction(cliutils.CompleteNodes).Required().String()
 	exNodeErrorsListLong := exNodeErrorsList.Flag("long", msgPrinter.Sprintf("Show the full eventlog object of the errors currently surfaced to the Exchange.")).Short('l').Bool()
 	exNodeListPolicyCmd := exNodeCmd.Command("listpolicy | lsp", msgPrinter.Sprintf("Display the node policy from the Horizon Exchange.")).Alias("lsp").Alias("listpolicy")
 	exNodeListPolicyIdTok := exNodeListPolicyCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modify the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
-	exNodeListPolicyNode := exNodeListPolicyCmd.Arg("node", msgPrinter.Sprintf("List policy for this node.")).Required().String()
+	exNodeListPolicyNode := exNodeListPolicyCmd.Arg("node", msgPrinter.Sprintf("List policy for this node.")).HintAction(cliutils.CompleteNodes).Required().String()
 
 	exNodeManagementCmd := exNodeCmd.Command("management | mgmt", msgPrinter.Sprintf("List and manage node management resources in the Horizon Exchange")).Alias("mgmt").Alias("management")
 	exNodeManagementListCmd := exNodeManagementCmd.Command("list | ls", msgPrinter.Sprintf("List the compatible node management policies for the node. Only policies that are enabled will be displayed unless the -a flag is specified.")).Alias("ls").Alias("list")
-	exNodeManagementListName := exNodeManagementListCmd.Arg("node", msgPrinter.Sprintf("List node management policies for this node")).Required().String()
+	exNodeManagementListName := exNodeManagementListCmd.Arg("node", msgPrinter.Sprintf("List node management policies for this node")).HintAction(cliutils.CompleteNodes).Required().String()
 	exNodeManagementListNodeIdTok := exNodeManagementListCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modfy the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
 	exNodeManagementListAll := exNodeManagementListCmd.Flag("all", msgPrinter.Sprintf("Include disabled NMP's.")).Short('a').Bool()
 	exNodeManagementStatusCmd := exNodeManagementCmd.Command("status", msgPrinter.Sprintf("List the node management policy statuses for this node."))
-	exNodeManagementStatusName := exNodeManagementStatusCmd.Arg("node", msgPrinter.Sprintf("List node management policy statuses for this node.")).Required().String()
+	exNodeManagementStatusName := exNodeManagementStatusCmd.Arg("node", msgPrinter.Sprintf("List node management policy statuses for this node.")).HintAction(cliutils.CompleteNodes).Required().String()
 	exNodeManagementStatusNodeIdTok := exNodeManagementStatusCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modfy the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
 	exNodeManagementStatusPol := exNodeManagementStatusCmd.Flag("policy", msgPrinter.Sprintf("Filter output to include just this one node managment policy. Use with --long flag to display entire content of a single node management policy status object.")).Short('p').String()
 	exNodeManagementStatusLong := exNodeManagementStatusCmd.Flag("long", msgPrinter.Sprintf("Show the entire contents of each node management policy status object.")).Short('l').Bool()
 	exNodeManagementResetCmd := exNodeManagementCmd.Command("reset", msgPrinter.Sprintf("Re-evaluate the node management policy (nmp) for this node. Run this command to retry a nmp when the upgrade failed and the problem is fixed. Do not run this command when the node is still in the middle of an upgrade."))
-	exNodeManagementResetName := exNodeManagementResetCmd.Arg("node", msgPrinter.Sprintf("Re-evaluate node management policy for this node.")).Required().String()
+	exNodeManagementResetName := exNodeManagementResetCmd.Arg("node", msgPrinter.Sprintf("Re-evaluate node management policy for this node.")).HintAction(cliutils.CompleteNodes).Required().String()
 	exNodeManagementResetNodeIdTok := exNodeManagementResetCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modfy the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
 	exNodeManagementResetPol := exNodeManagementResetCmd.Flag("policy", msgPrinter.Sprintf("The name of the node managment policy to be re-evaluated. If omitted, all of the node management policies will be re-evaluated for this node.")).Short('p').String()
 	exNodeStatusList := exNodeCmd.Command("liststatus | lst", msgPrinter.Sprintf("List the run-time status of the node.")).Alias("lst").Alias("liststatus")
 	exNodeStatusIdTok := exNodeStatusList.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modify the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
-	exNodeStatusListNode := exNodeStatusList.Arg("node", msgPrinter.Sprintf("List status for this node")).Required().String()
+	exNodeStatusListNode := exNodeStatusList.Arg("node", msgPrinter.Sprintf("List status for this node")).HintAction(cliutils.CompleteNodes).Required().String()
 	exNodeDelCmd := exNodeCmd.Command("remove | rm", msgPrinter.Sprintf("Remove a node resource from the Horizon Exchange. Do NOT do this when an edge node is registered with this node id.")).Alias("rm").Alias("remove")
 	exNodeRemoveNodeIdTok := exNodeDelCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modfy the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
-	exDelNode := exNodeDelCmd.Arg("node", msgPrinter.Sprintf("The node to remove.")).Required().String()
+	exDelNode := exNodeDelCmd.Arg("node", msgPrinter.Sprintf("The node to remove.")).HintAction(cliutils.CompleteNodes).Required().String()
 	exNodeDelForce := exNodeDelCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('f').Bool()
 	exNodeRemovePolicyCmd := exNodeCmd.Command("removepolicy | rmp", msgPrinter.Sprintf("Remove the node policy in the Horizon Exchange.")).Alias("rmp").Alias("removepolicy")
 	exNodeRemovePolicyIdTok := exNodeRemovePolicyCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modify the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
-	exNodeRemovePolicyNode := exNodeRemovePolicyCmd.Arg("node", msgPrinter.Sprintf("Remove policy for this node.")).Required().String()
+	exNodeRemovePolicyNode := exNodeRemovePolicyCmd.Arg("node", msgPrinter.Sprintf("Remove policy for this node.")).HintAction(cliutils.CompleteNodes).Required().String()
 	exNodeRemovePolicyForce := exNodeRemovePolicyCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('f').Bool()
 	exNodeSetTokCmd := exNodeCmd.Command("settoken", msgPrinter.Sprintf("Change the token of a node resource in the Horizon Exchange."))
-	exNodeSetTokNode := exNodeSetTokCmd.Arg("node", msgPrinter.Sprintf("The node to be changed.")).Required().String()
+	exNodeSetTokNode := exNodeSetTokCmd.Arg("node", msgPrinter.Sprintf("The node to be changed.")).HintAction(cliutils.CompleteNodes).Required().String()
 	exNodeSetTokToken := exNodeSetTokCmd.Arg("token", msgPrinter.Sprintf("The new token for the node.")).Required().String()
 	exNodeSetTokNodeIdTok := exNodeSetTokCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modify the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
 	exNodeUpdateCmd := exNodeCmd.Command("update | up", msgPrinter.Sprintf("Update an attribute of the node in the Horizon Exchange.")).Alias("up").Alias("update")
-	exNodeUpdateNode := exNodeUpdateCmd.Arg("node", msgPrinter.Sprintf("The node to be updated.")).Required().String()
+	exNodeUpdateNode := exNodeUpdateCmd.Arg("node", msgPrinter.Sprintf("The node to be updated.")).HintAction(cliutils.CompleteNodes).Required().String()
 	exNodeUpdateIdTok := exNodeUpdateCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modify the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
 	exNodeUpdateJsonFile := exNodeUpdateCmd.Flag("json-file", msgPrinter.Sprintf("The path to a json file containing the changed attribute to be updated in the Horizon Exchange. Specify -f- to read from stdin.")).Short('f').Required().String()
 	exNodeUpdatePolicyCmd := exNodeCmd.Command("updatepolicy | upp", msgPrinter.Sprintf("(DEPRECATED) This command is deprecated. Please use 'hzn exchange node addpolicy' to update the node policy. This command is used to update either the node policy properties or the constraints, but not both.")).Alias("upp").Alias("updatepolicy")
-	exNodeUpdatePolicyNode := exNodeUpdatePolicyCmd.Arg("node", msgPrinter.Sprintf("Update the policy for this node.")).Required().String()
+	exNodeUpdatePolicyNode := exNodeUpdatePolicyCmd.Arg("node", msgPrinter.Sprintf("Update the policy for this node.")).HintAction(cliutils.CompleteNodes).Required().String()
 	exNodeUpdatePolicyIdTok := exNodeUpdatePolicyCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modify the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
 	exNodeUpdatePolicyJsonFile := exNodeUpdatePolicyCmd.Flag("json-file", msgPrinter.Sprintf("The path of a JSON file containing the new constraints or properties (not both) for the node policy in the Horizon Exchange. Specify -f- to read from stdin.")).Short('f').Required().String()
 
@@ -456,16 +506,17 @@ Environment Variables:
 	exOrgCreateHBMax := exOrgCreateCmd.Flag("heartbeatmax", msgPrinter.Sprintf("The maximum number of seconds between agent heartbeats to the Exchange. During periods of inactivity, the agent will increase the interval between heartbeats by increments of --heartbeatadjust.")).Int()
 	exOrgCreateHBAdjust := exOrgCreateCmd.Flag("heartbeatadjust", msgPrinter.Sprintf("The number of seconds to increment the agent's heartbeat interval.")).Int()
 	exOrgCreateMaxNodes := exOrgCreateCmd.Flag("max-nodes", msgPrinter.Sprintf("The maximum number of nodes this organization is allowed to have. The value cannot exceed the Exchange global limit. The default is 0 which means no organization limit.")).Int()
+	exOrgCreateMaxServices := exOrgCreateCmd.Flag("max-services", msgPrinter.Sprintf("The maximum number of services this organization is allowed to have. The default is 0 which means no organization limit.")).Int()
 	exOrgCreateAddToAgbot := exOrgCreateCmd.Flag("agbot", msgPrinter.Sprintf("Add the organization to this agbot so that it will be responsible for deploying services in this org. The agbot will deploy services to nodes in this org, using the patterns and deployment policies in this org. If omitted, the first agbot found in the exchange will become responsible for this org. The format is 'agbot_org/agbot_id'.")).Short('a').String()
 	exOrgListCmd := exOrgCmd.Command("list | ls", msgPrinter.Sprintf("Display the organization resource from the Horizon Exchange. (Normally you can only display your own organiztion. If the org does not exist, you will get an invalid credentials error.)")).Alias("ls").Alias("list")
-	exOrgListOrg := exOrgListCmd.Arg("org", msgPrinter.Sprintf("List this one organization.")).String()
+	exOrgListOrg := exOrgListCmd.Arg("org", msgPrinter.Sprintf("List this one organization.")).HintAction(cliutils.CompleteOrgs).String()
 	exOrgListLong := exOrgListCmd.Flag("long", msgPrinter.Sprintf("Display detailed info of orgs")).Short('l').Bool()
 	exOrgDelCmd := exOrgCmd.Command("remove | rm", msgPrinter.Sprintf("Remove an organization resource from the Horizon Exchange.")).Alias("rm").Alias("remove")
-	exOrgDelOrg := exOrgDelCmd.Arg("org", msgPrinter.Sprintf("Remove this organization.")).Required().String()
+	exOrgDelOrg := exOrgDelCmd.Arg("org", msgPrinter.Sprintf("Remove this organization.")).HintAction(cliutils.CompleteOrgs).Required().String()
 	exOrgDelFromAgbot := exOrgDelCmd.Flag("agbot", msgPrinter.Sprintf("The agbot to remove the deployment policy from. If omitted, the first agbot found in the exchange will be used. The format is 'agbot_org/agbot_id'.")).Short('a').String()
 	exOrgDelForce := exOrgDelCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('f').Bool()
 	exOrgUpdateCmd := exOrgCmd.Command("update | up", msgPrinter.Sprintf("Update the organization resource in the Horizon Exchange.")).Alias("up").Alias("update")
-	exOrgUpdateOrg := exOrgUpdateCmd.Arg("org", msgPrinter.Sprintf("Update this organization.")).Required().String()
+	exOrgUpdateOrg := exOrgUpdateCmd.Arg("org", msgPrinter.Sprintf("Update this organization.")).HintAction(cliutils.CompleteOrgs).Required().String()
 	exOrgUpdateLabel := exOrgUpdateCmd.Flag("label", msgPrinter.Sprintf("New label for organization.")).Short('l').String()
 	exOrgUpdateDesc := exOrgUpdateCmd.Flag("description", msgPrinter.Sprintf("New description for organization.")).Short('d').String()
 	exOrgUpdateTags := exOrgUpdateCmd.Flag("tag", msgPrinter.Sprintf("New tag for organization. The format is mytag1=myvalue1. This flag can be repeated to specify multiple tags. Use '-t \"\"' once to remove all the tags.")).Short('t').Strings()
@@ -473,15 +524,18 @@ Environment Variables:
 	exOrgUpdateHBMax := exOrgUpdateCmd.Flag("heartbeatmax", msgPrinter.Sprintf("New maximum number of seconds between agent heartbeats to the Exchange. The default negative integer -1 means no change to this attribute.")).Default("-1").Int()
 	exOrgUpdateHBAdjust := exOrgUpdateCmd.Flag("heartbeatadjust", msgPrinter.Sprintf("New value for the number of seconds to increment the agent's heartbeat interval. The default negative integer -1 means no change to this attribute.")).Default("-1").Int()
 	exOrgUpdateMaxNodes := exOrgUpdateCmd.Flag("max-nodes", msgPrinter.Sprintf("The new maximum number of nodes this organization is allowed to have. The value cannot exceed the Exchange global limit. The default negative integer -1 means no change.")).Default("-1").Int()
+	exOrgUpdateMaxServices := exOrgUpdateCmd.Flag("max-services", msgPrinter.Sprintf("The new maximum number of services this organization is allowed to have. 0 means no organization limit. The default negative integer -1 means no change.")).Default("-1").Int()
+	exOrgStatusCmd := exOrgCmd.Command("status", msgPrinter.Sprintf("Display the usage of the organization, and how much of its limits of nodes and services it uses. (Only the organization admins can display it.)"))
+	exOrgStatusOrg := exOrgStatusCmd.Arg("org", msgPrinter.Sprintf("Display the status of this organization. If not specified, the organization of the -o flag or HZN_ORG_ID is used.")).HintAction(cliutils.CompleteOrgs).String()
 
 	exPatternCmd := exchangeCmd.Command("pattern | pat", msgPrinter.Sprintf("List and manage patterns in the Horizon Exchange")).Alias("pat").Alias("pattern")
 	exPatternListCmd := exPatternCmd.Command("list | ls", msgPrinter.Sprintf("Display the pattern resources from the Horizon Exchange.")).Alias("ls").Alias("list")
 	exPatternListNodeIdTok := exPatternListCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modify the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
-	exPattern := exPatternListCmd.Arg("pattern", msgPrinter.Sprintf("List just this one pattern. Use <org>/<pat> to specify a public pattern in another org, or <org>/ to list all of the public patterns in another org.")).String()
+	exPattern := exPatternListCmd.Arg("pattern", msgPrinter.Sprintf("List just this one pattern. Use <org>/<pat> to specify a public pattern in another org, or <org>/ to list all of the public patterns in another org.")).HintAction(cliutils.CompletePatterns).String()
 	exPatternLong := exPatternListCmd.Flag("long", msgPrinter.Sprintf("When listing all of the patterns, show the entire resource of each pattern, instead of just the name.")).Short('l').Bool()
 	exPatternListKeyCmd := exPatternCmd.Command("listkey | lsk", msgPrinter.Sprintf("List the signing public keys/certs for this pattern resource in the Horizon Exchange.")).Alias("lsk").Alias("listkey")
 	exPatternListKeyNodeIdTok := exPatternListKeyCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modify the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
-	exPatListKeyPat := exPatternListKeyCmd.Arg("pattern", msgPrinter.Sprintf("The existing pattern to list the keys for.")).Required().String()
+	exPatListKeyPat := exPatternListKeyCmd.Arg("pattern", msgPrinter.Sprintf("The existing pattern to list the keys for.")).HintAction(cliutils.CompletePatterns).Required().String()
 	exPatListKeyKey := exPatternListKeyCmd.Arg("key-name", msgPrinter.Sprintf("The existing key name to see the contents of.")).String()
 	exPatternPublishCmd := exPatternCmd.Command("publish | pub", msgPrinter.Sprintf("Sign and create/update the pattern resource in the Horizon Exchange.")).Alias("pub").Alias("publish")
 	exPatJsonFile := exPatternPublishCmd.Flag("json-file", msgPrinter.Sprintf("The path of a JSON file containing the metadata necessary to create/update the pattern in the Horizon exchange. See %v/pattern.json. Specify -f- to read from stdin.", sample_dir)).Short('f').Required().String()
@@ -489,45 +543,48 @@ Environment Variables:
 	exPatPubPubKeyFile := exPatternPublishCmd.Flag("public-key-file", msgPrinter.Sprintf("(DEPRECATED) The path of public key file (that corresponds to the private key) that should be stored with the pattern, to be used by the Horizon Agent to verify the signature. If this flag is not specified, the public key will be calculated from the private key.")).Short('K').ExistingFile()
 	exPatName := exPatternPublishCmd.Flag("pattern-name", msgPrinter.Sprintf("The name to use for this pattern in the Horizon exchange. If not specified, will default to the base name of the file path specified in -f.")).Short('p').String()
 	exPatDelCmd := exPatternCmd.Command("remove | rm", msgPrinter.Sprintf("Remove a pattern resource from the Horizon Exchange.")).Alias("rm").Alias("remove")
-	exDelPat := exPatDelCmd.Arg("pattern", msgPrinter.Sprintf("The pattern to remove.")).Required().String()
+	exDelPat := exPatDelCmd.Arg("pattern", msgPrinter.Sprintf("The pattern to remove.")).HintAction(cliutils.CompletePatterns).Required().String()
 	exPatDelForce := exPatDelCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('f').Bool()
 	exPatternRemKeyCmd := exPatternCmd.Command("removekey | rmk", msgPrinter.Sprintf("Remove a signing public key/cert for this pattern resource in the Horizon Exchange.")).Alias("rmk").Alias("removekey")
-	exPatRemKeyPat := exPatternRemKeyCmd.Arg("pattern", msgPrinter.Sprintf("The existing pattern to remove the key from.")).Required().String()
+	exPatRemKeyPat := exPatternRemKeyCmd.Arg("pattern", msgPrinter.Sprintf("The existing pattern to remove the key from.")).HintAction(cliutils.CompletePatterns).Required().String()
 	exPatRemKeyKey := exPatternRemKeyCmd.Arg("key-name", msgPrinter.Sprintf("The existing key name to remove.")).Required().String()
 	exPatUpdateCmd := exPatternCmd.Command("update | up", msgPrinter.Sprintf("Update an attribute of the pattern in the Horizon Exchange.")).Alias("up").Alias("update")
 	exPatUpdateNodeIdTok := exPatUpdateCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modify the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
-	exPatUpdatePattern := exPatUpdateCmd.Arg("pattern", msgPrinter.Sprintf("The name of the pattern in the Horizon Exchange to publish.")).Required().String()
+	exPatUpdatePattern := exPatUpdateCmd.Arg("pattern", msgPrinter.Sprintf("The name of the pattern in the Horizon Exchange to publish.")).HintAction(cliutils.CompletePatterns).Required().String()
 	exPatUpdateJsonFile := exPatUpdateCmd.Flag("json-file", msgPrinter.Sprintf("The path to a json file containing the updated attribute of the pattern to be put in the Horizon Exchange. Specify -f- to read from stdin.")).Short('f').Required().String()
 	exPatternVerifyCmd := exPatternCmd.Command("verify | vf", msgPrinter.Sprintf("Verify the signatures of a pattern resource in the Horizon Exchange.")).Alias("vf").Alias("verify")
-	exVerPattern := exPatternVerifyCmd.Arg("pattern", msgPrinter.Sprintf("The pattern to verify.")).Required().String()
+	exVerPattern := exPatternVerifyCmd.Arg("pattern", msgPrinter.Sprintf("The pattern to verify.")).HintAction(cliutils.CompletePatterns).Required().String()
 	exPatternVerifyNodeIdTok := exPatternVerifyCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modify the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
 	exPatPubKeyFile := exPatternVerifyCmd.Flag("public-key-file", msgPrinter.Sprintf("The path of a pem public key file to be used to verify the pattern. If not specified, the environment variable HZN_PUBLIC_KEY_FILE will be used. If none of them are set, ~/.hzn/keys/service.public.pem is the default.")).Short('k').String()
 
 	exServiceCmd := exchangeCmd.Command("service | serv", msgPrinter.Sprintf("List and manage services in the Horizon Exchange")).Alias("serv").Alias("service")
 	exServiceAddPolicyCmd := exServiceCmd.Command("addpolicy | addp", msgPrinter.Sprintf("Add or replace the service policy in the Horizon Exchange.")).Alias("addp").Alias("addpolicy")
 	exServiceAddPolicyIdTok := exServiceAddPolicyCmd.Flag("service-id-tok", msgPrinter.Sprintf("The Horizon Exchange ID and password of the user")).Short('n').PlaceHolder("ID:TOK").String()
-	exServiceAddPolicyService := exServiceAddPolicyCmd.Arg("service", msgPrinter.Sprintf("Add or replace policy for this service.")).Required().String()
+	exServiceAddPolicyService := exServiceAddPolicyCmd.Arg("service", msgPrinter.Sprintf("Add or replace policy for this service.")).HintAction(cliutils.CompleteServices).Required().String()
 	exServiceAddPolicyJsonFile := exServiceAddPolicyCmd.Flag("json-file", msgPrinter.Sprintf("The path of a JSON file containing the metadata necessary to create/update the service policy in the Horizon Exchange. Specify -f- to read from stdin.")).Short('f').Required().String()
 	exServiceListCmd := exServiceCmd.Command("list | ls", msgPrinter.Sprintf("Display the service resources from the Horizon Exchange.")).Alias("ls").Alias("list")
-	exService := exServiceListCmd.Arg("service", msgPrinter.Sprintf("List just this one service. Use <org>/<svc> to specify a public service in another org, or <org>/ to list all of the public services in another org.")).String()
+	exService := exServiceListCmd.Arg("service", msgPrinter.Sprintf("List just this one service. Use <org>/<svc> to specify a public service in another org, or <org>/ to list all of the public services in another org.")).HintAction(cliutils.CompleteServices).String()
 	exServiceListNodeIdTok := exServiceListCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modify the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
 	exServiceLong := exServiceListCmd.Flag("long", msgPrinter.Sprintf("When listing all of the services, show the entire service definition, instead of just the name. When listing a specific service, show more details.")).Short('l').Bool()
 	exSvcOpYamlFilePath := exServiceListCmd.Flag("op-yaml-file", msgPrinter.Sprintf("The name of the file where the cluster deployment operator yaml archive will be saved. This flag is only used when listing a specific service. This flag is ignored when the service does not have a clusterDeployment attribute.")).Short('f').String()
 	exSvcOpYamlForce := exServiceListCmd.Flag("force", msgPrinter.Sprintf("Skip the 'do you want to overwrite?' prompt when -f is specified and the file exists.")).Short('F').Bool()
 	exServiceListAuthCmd := exServiceCmd.Command("listauth | lsau", msgPrinter.Sprintf("List the docker auth tokens for this service resource in the Horizon Exchange.")).Alias("lsau").Alias("listauth")
-	exSvcListAuthSvc := exServiceListAuthCmd.Arg("service", msgPrinter.Sprintf("The existing service to list the docker auths for.")).Required().String()
+	exSvcListAuthSvc := exServiceListAuthCmd.Arg("service", msgPrinter.Sprintf("The existing service to list the docker auths for.")).HintAction(cliutils.CompleteServices).Required().String()
 	exSvcListAuthId := exServiceListAuthCmd.Arg("auth-name", msgPrinter.Sprintf("The existing docker auth id to see the contents of.")).Uint()
 	exServiceListAuthNodeIdTok := exServiceListAuthCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modify the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
 	exServiceListKeyCmd := exServiceCmd.Command("listkey | lsk", msgPrinter.Sprintf("List the signing public keys/certs for this service resource in the Horizon Exchange.")).Alias("lsk").Alias("listkey")
-	exSvcListKeySvc := exServiceListKeyCmd.Arg("service", msgPrinter.Sprintf("The existing service to list the keys for.")).Required().String()
+	exSvcListKeySvc := exServiceListKeyCmd.Arg("service", msgPrinter.Sprintf("The existing service to list the keys for.")).HintAction(cliutils.CompleteServices).Required().String()
 	exSvcListKeyKey := exServiceListKeyCmd.Arg("key-name", msgPrinter.Sprintf("The existing key name to see the contents of.")).String()
 	exServiceListKeyNodeIdTok := exServiceListKeyCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modify the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
+	exServiceHistoryCmd := exServiceCmd.Command("history", msgPrinter.Sprintf("Display the changes to a service and its service policy that were made with this command, who made them and when, with the diff of each change. The history is kept in ~/.hzn/history, or in the directory set by HZN_HISTORY_DIR."))
+	exServiceHistoryIdTok := exServiceHistoryCmd.Flag("service-id-tok", msgPrinter.Sprintf("The Horizon Exchange id and password of the user")).Short('n').PlaceHolder("ID:TOK").String()
+	exServiceHistoryService := exServiceHistoryCmd.Arg("service", msgPrinter.Sprintf("The service id. Use <org>/<svc> to specify a service from a different org.")).HintAction(cliutils.CompleteServices).Required().String()
 	exServiceListnode := exServiceCmd.Command("listnode | lsn", msgPrinter.Sprintf("Display the nodes that the service is running on.")).Alias("lsn").Alias("listnode")
-	exServiceListnodeService := exServiceListnode.Arg("service", msgPrinter.Sprintf("The service id. Use <org>/<svc> to specify a service from a different org.")).Required().String()
+	exServiceListnodeService := exServiceListnode.Arg("service", msgPrinter.Sprintf("The service id. Use <org>/<svc> to specify a service from a different org.")).HintAction(cliutils.CompleteServices).Required().String()
 	exServiceListnodeNodeOrg := exServiceListnode.Flag("node-org", msgPrinter.Sprintf("The node's organization. If omitted, it will be same as the org specified by -o or HZN_ORG_ID.")).Short('O').String()
 	exServiceListPolicyCmd := exServiceCmd.Command("listpolicy | lsp", msgPrinter.Sprintf("Display the service policy from the Horizon Exchange.")).Alias("lsp").Alias("listpolicy")
 	exServiceListPolicyIdTok := exServiceListPolicyCmd.Flag("service-id-tok", msgPrinter.Sprintf("The Horizon Exchange id and password of the user")).Short('n').PlaceHolder("ID:TOK").String()
-	exServiceListPolicyService := exServiceListPolicyCmd.Arg("service", msgPrinter.Sprintf("List policy for this service.")).Required().String()
+	exServiceListPolicyService := exServiceListPolicyCmd.Arg("service", msgPrinter.Sprintf("List policy for this service.")).HintAction(cliutils.CompleteServices).Required().String()
 	exServiceNewPolicyCmd := exServiceCmd.Command("newpolicy | newp", msgPrinter.Sprintf("Display an empty service policy template that can be filled in.")).Alias("newp").Alias("newpolicy")
 	exServicePublishCmd := exServiceCmd.Command("publish | pub", msgPrinter.Sprintf("Sign and create/update the service resource in the Horizon Exchange.")).Alias("pub").Alias("publish")
 	exSvcJsonFile := exServicePublishCmd.Flag("json-file", msgPrinter.Sprintf("The path of a JSON file containing the metadata necessary to create/update the service in the Horizon exchange. See %v/service.json and %v/service_cluster.json. Specify -f- to read from stdin.", sample_dir, sample_dir)).Short('f').Required().String()
@@ -540,20 +597,20 @@ Environment Variables:
 	exSvcPolicyFile := exServicePublishCmd.Flag("service-policy-file", msgPrinter.Sprintf("The path of the service policy JSON file to be used for the service to be published. This flag is optional")).Short('p').String()
 	exSvcPublic := exServicePublishCmd.Flag("public", msgPrinter.Sprintf("Whether the service is visible to users outside of the organization. This flag is optional. If left unset, the service will default to whatever the metadata has set. If the service definition has also not set the public field, then the service will by default not be public.")).String()
 	exSvcDelCmd := exServiceCmd.Command("remove | rm", msgPrinter.Sprintf("Remove a service resource from the Horizon Exchange.")).Alias("rm").Alias("remove")
-	exDelSvc := exSvcDelCmd.Arg("service", msgPrinter.Sprintf("The service to remove.")).Required().String()
+	exDelSvc := exSvcDelCmd.Arg("service", msgPrinter.Sprintf("The service to remove.")).HintAction(cliutils.CompleteServices).Required().String()
 	exSvcDelForce := exSvcDelCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('f').Bool()
 	exServiceRemAuthCmd := exServiceCmd.Command("removeauth | rmau", msgPrinter.Sprintf("Remove a docker auth token for this service resource in the Horizon Exchange.")).Alias("rmau").Alias("removeauth")
-	exSvcRemAuthSvc := exServiceRemAuthCmd.Arg("service", msgPrinter.Sprintf("The existing service to remove the docker auth from.")).Required().String()
+	exSvcRemAuthSvc := exServiceRemAuthCmd.Arg("service", msgPrinter.Sprintf("The existing service to remove the docker auth from.")).HintAction(cliutils.CompleteServices).Required().String()
 	exSvcRemAuthId := exServiceRemAuthCmd.Arg("auth-name", msgPrinter.Sprintf("The existing docker auth id to remove.")).Required().Uint()
 	exServiceRemKeyCmd := exServiceCmd.Command("removekey | rmk", msgPrinter.Sprintf("Remove a signing public key/cert for this service resource in the Horizon Exchange.")).Alias("rmk").Alias("removekey")
-	exSvcRemKeySvc := exServiceRemKeyCmd.Arg("service", msgPrinter.Sprintf("The existing service to remove the key from.")).Required().String()
+	exSvcRemKeySvc := exServiceRemKeyCmd.Arg("service", msgPrinter.Sprintf("The existing service to remove the key from.")).HintAction(cliutils.CompleteServices).Required().String()
 	exSvcRemKeyKey := exServiceRemKeyCmd.Arg("key-name", msgPrinter.Sprintf("The existing key name to remove.")).Required().String()
 	exServiceRemovePolicyCmd := exServiceCmd.Command("removepolicy | rmp", msgPrinter.Sprintf("Remove the service policy in the Horizon Exchange.")).Alias("rmp").Alias("removepolicy")
 	exServiceRemovePolicyIdTok := exServiceRemovePolicyCmd.Flag("service-id-tok", msgPrinter.Sprintf("The Horizon Exchange ID and password of the user")).Short('n').PlaceHolder("ID:TOK").String()
-	exServiceRemovePolicyService := exServiceRemovePolicyCmd.Arg("service", msgPrinter.Sprintf("Remove policy for this service.")).Required().String()
+	exServiceRemovePolicyService := exServiceRemovePolicyCmd.Arg("service", msgPrinter.Sprintf("Remove policy for this service.")).HintAction(cliutils.CompleteServices).Required().String()
 	exServiceRemovePolicyForce := exServiceRemovePolicyCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('f').Bool()
 	exServiceVerifyCmd := exServiceCmd.Command("verify | vf", msgPrinter.Sprintf("Verify the signatures of a service resource in the Horizon Exchange.")).Alias("vf").Alias("verify")
-	exVerService := exServiceVerifyCmd.Arg("service", msgPrinter.Sprintf("The service to verify.")).Required().String()
+	exVerService := exServiceVerifyCmd.Arg("service", msgPrinter.Sprintf("The service to verify.")).HintAction(cliutils.CompleteServices).Required().String()
 	exServiceVerifyNodeIdTok := exServiceVerifyCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon Exchange node ID and token to be used as credentials to query and modify the node resources if -u flag is not specified. HZN_EXCHANGE_NODE_AUTH will be used as a default for -n. If you don't prepend it with the node's org, it will automatically be prepended with the -o value.")).Short('n').PlaceHolder("ID:TOK").String()
 	exSvcPubKeyFile := exServiceVerifyCmd.Flag("public-key-file", msgPrinter.Sprintf("The path of a pem public key file to be used to verify the service. If not specified, the environment variable HZN_PUBLIC_KEY_FILE will be used. If none of them are set, ~/.hzn/keys/service.public.pem is the default.")).Short('k').String()
 
@@ -578,6 +635,34 @@ Environment Variables:
 	exHAGroupMemberRemoveNodes := exHAGroupMemberRemoveCmd.Flag("node", msgPrinter.Sprintf("Node to be removed from the HA group. This flag can be repeated to specify different nodes.")).Short('m').Required().Strings()
 	exHAGroupMemberRemoveForce := exHAGroupMemberRemoveCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('f').Bool()
 
+	exFleetCmd := exchangeCmd.Command("fleet | fl", msgPrinter.Sprintf("List and manage the fleets of nodes that deployment policies can target. Fleets are kept by the agbots, HZN_AGBOT_URL must be set.")).Alias("fleet").Alias("fl")
+	exFleetListCmd := exFleetCmd.Command("list | ls", msgPrinter.Sprintf("Display the fleets from the agbot.")).Alias("ls").Alias("list")
+	exFleetListName := exFleetListCmd.Arg("fleet-name", msgPrinter.Sprintf("List just this one fleet.")).String()
+	exFleetListLong := exFleetListCmd.Flag("long", msgPrinter.Sprintf("When listing all of the fleets, show the entire resource of each fleet, instead of just the name.")).Short('l').Bool()
+	exFleetNewCmd := exFleetCmd.Command("new", msgPrinter.Sprintf("Display an empty fleet template that can be filled in."))
+	exFleetAddCmd := exFleetCmd.Command("add", msgPrinter.Sprintf("Add or replace a fleet in the agbot. Use 'hzn exchange fleet new' for an empty fleet template."))
+	exFleetAddName := exFleetAddCmd.Arg("fleet-name", msgPrinter.Sprintf("The name of the fleet to add or overwrite.")).Required().String()
+	exFleetAddJsonFile := exFleetAddCmd.Flag("json-file", msgPrinter.Sprintf("The path of a JSON file containing the nodes and constraints of the fleet. Specify -f- to read from stdin.")).Short('f').Required().String()
+	exFleetRemoveCmd := exFleetCmd.Command("remove | rm", msgPrinter.Sprintf("Remove the fleet from the agbot.")).Alias("rm").Alias("remove")
+	exFleetRemoveName := exFleetRemoveCmd.Arg("fleet-name", msgPrinter.Sprintf("The name of the fleet to be removed.")).Required().String()
+	exFleetRemoveForce := exFleetRemoveCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('f').Bool()
+
+	exJoinTokenCmd := exchangeCmd.Command("jointoken | jt", msgPrinter.Sprintf("List and manage the join tokens that devices can register with, using 'hzn register --token', without user credentials. Join tokens are kept by the agbots, HZN_AGBOT_URL must be set.")).Alias("jointoken").Alias("jt")
+	exJoinTokenListCmd := exJoinTokenCmd.Command("list | ls", msgPrinter.Sprintf("Display the join tokens from the agbot. The secrets of the tokens are not displayed.")).Alias("ls").Alias("list")
+	exJoinTokenListId := exJoinTokenListCmd.Arg("token-id", msgPrinter.Sprintf("List just this one join token.")).String()
+	exJoinTokenListLong := exJoinTokenListCmd.Flag("long", msgPrinter.Sprintf("When listing all of the join tokens, show the entire resource of each token, instead of just the id.")).Short('l').Bool()
+	exJoinTokenNewCmd := exJoinTokenCmd.Command("new", msgPrinter.Sprintf("Display an empty join token template that can be filled in."))
+	exJoinTokenCreateCmd := exJoinTokenCmd.Command("create", msgPrinter.Sprintf("Create a join token in the agbot and display the token to give to the devices. Use 'hzn exchange jointoken new' for an empty join token template."))
+	exJoinTokenCreateJsonFile := exJoinTokenCreateCmd.Flag("json-file", msgPrinter.Sprintf("The path of a JSON file containing the pattern or node policy, the user input, the node name template, the maximum number of uses and the ttl of the token. Specify -f- to read from stdin.")).Short('f').String()
+	exJoinTokenRemoveCmd := exJoinTokenCmd.Command("remove | rm", msgPrinter.Sprintf("Remove the join token from the agbot. The nodes that joined with it are not removed.")).Alias("rm").Alias("remove")
+	exJoinTokenRemoveId := exJoinTokenRemoveCmd.Arg("token-id", msgPrinter.Sprintf("The id of the join token to be removed.")).Required().String()
+	exJoinTokenRemoveForce := exJoinTokenRemoveCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('f').Bool()
+
+	exExportCmd := exchangeCmd.Command("export", msgPrinter.Sprintf("Display the services, service policies, patterns and deployment policies of the org as a yaml bundle that can be applied with 'hzn exchange apply'."))
+	exApplyCmd := exchangeCmd.Command("apply", msgPrinter.Sprintf("Create or update the services, service policies, patterns and deployment policies of a bundle in the Horizon Exchange. The differences with the Exchange are displayed before they are applied, use --dry-run to only display them. Resources that are not in the bundle are not removed."))
+	exApplyFile := exApplyCmd.Flag("file", msgPrinter.Sprintf("The path of the yaml or json bundle, as written by 'hzn exchange export'. Specify -f- to read from stdin.")).Short('f').Required().String()
+	exApplyForce := exApplyCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('F').Bool()
+
 	exStatusCmd := exchangeCmd.Command("status", msgPrinter.Sprintf("Display the status of the Horizon Exchange."))
 
 	exUserCmd := exchangeCmd.Command("user", msgPrinter.Sprintf("List and manage users in the Horizon Exchange."))
@@ -637,6 +722,8 @@ Environment Variables:
 	mmsObjectDownloadFile := mmsObjectDownloadCmd.Flag("file", msgPrinter.Sprintf("The file that the data of downloaded object is written to. This flag must be used with -f. If omit, will use default file name in format of objectType_objectID and save in current directory")).Short('f').String()
 	mmsObjectDownloadOverwrite := mmsObjectDownloadCmd.Flag("overwrite", msgPrinter.Sprintf("Overwrite the existing file if it exists in the file system.")).Short('O').Bool()
 	mmsObjectDownloadSkipIntegrityCheck := mmsObjectDownloadCmd.Flag("noIntegrity", msgPrinter.Sprintf("The download command will not perform a data integrity check on the downloaded object data")).Bool()
+	mmsObjectDownloadChunkSize := mmsObjectDownloadCmd.Flag("chunkSize", msgPrinter.Sprintf("The size of the data chunks that the object data is downloaded in. A chunk that fails because of a network error is retried from the last byte received.")).Default("52428800").Int()
+	mmsObjectDownloadResume := mmsObjectDownloadCmd.Flag("resume", msgPrinter.Sprintf("Resume an interrupted download of the object to the same file. The data received so far is kept in a file with the .part suffix until the download is complete.")).Bool()
 
 	mmsObjectListCmd := mmsObjectCmd.Command("list | ls", msgPrinter.Sprintf("List objects in the Horizon Model Management Service.")).Alias("ls").Alias("list")
 	mmsObjectListType := mmsObjectListCmd.Flag("type", msgPrinter.Sprintf("The type of the object to list.")).Short('t').String()
@@ -664,6 +751,7 @@ Environment Variables:
 	mmsObjectPublishObj := mmsObjectPublishCmd.Flag("object", msgPrinter.Sprintf("The object (in the form of a file) to publish. This flag is optional so that you can update only the object's definition.")).Short('f').String()
 	mmsObjectPublishNoChunkUpload := mmsObjectPublishCmd.Flag("disableChunkUpload", msgPrinter.Sprintf("The publish command will disable chunk upload. Data will stream to CSS.")).Bool()
 	mmsObjectPublishChunkUploadDataSize := mmsObjectPublishCmd.Flag("chunkSize", msgPrinter.Sprintf("The size of data chunk that will be published with. Ignored if --disableChunkUpload is specified.")).Default("52428800").Int()
+	mmsObjectPublishResume := mmsObjectPublishCmd.Flag("resume", msgPrinter.Sprintf("Resume an interrupted chunk upload of the same object file with the same chunk size from the last chunk that was received, without publishing the object definition again. If the file changed or the upload cannot be resumed, the object is published from the beginning. It is mutually exclusive with --disableChunkUpload.")).Bool()
 	mmsObjectPublishSkipIntegrityCheck := mmsObjectPublishCmd.Flag("noIntegrity", msgPrinter.Sprintf("The publish command will not perform a data integrity check on the uploaded object data. It is mutually exclusive with --hashAlgo and --hash")).Bool()
 	mmsObjectPublishDSHashAlgo := mmsObjectPublishCmd.Flag("hashAlgo", msgPrinter.Sprintf("The hash algorithm used to hash the object data before signing it, ensuring data integrity during upload and download. Supported hash algorithms are SHA1 or SHA256, the default is SHA1. It is mutually exclusive with the --noIntegrity flag")).Short('a').String()
 	mmsObjectPublishDSHash := mmsObjectPublishCmd.Flag("hash", msgPrinter.Sprintf("The hash of the object data being uploaded or downloaded. Use this flag if you want to provide the hash instead of allowing the command to automatically calculate the hash. The hash must be generated using either the SHA1 or SHA256 algorithm. The -a flag must be specified if the hash was generated using SHA256. This flag is mutually exclusive with --noIntegrity.")).String()
@@ -673,6 +761,10 @@ Environment Variables:
 
 	nodeCmd := app.Command("node", msgPrinter.Sprintf("List and manage general information about this Horizon edge node."))
 	nodeListCmd := nodeCmd.Command("list | ls", msgPrinter.Sprintf("Display general information about this Horizon edge node.")).Alias("list").Alias("ls")
+	nodeMaintenanceCmd := nodeCmd.Command("maintenance", msgPrinter.Sprintf("Turn the maintenance mode of this Horizon edge node on or off, for a planned servicing window."))
+	nodeMaintenanceOnCmd := nodeMaintenanceCmd.Command("on", msgPrinter.Sprintf("Stop accepting new agreements on this Horizon edge node. The services that are running are kept unless --drain is specified."))
+	nodeMaintenanceOnDrain := nodeMaintenanceOnCmd.Flag("drain", msgPrinter.Sprintf("Also cancel the existing agreements, so that the services running on the node are stopped gracefully.")).Short('d').Bool()
+	nodeMaintenanceOffCmd := nodeMaintenanceCmd.Command("off", msgPrinter.Sprintf("Start accepting new agreements on this Horizon edge node again."))
 
 	nodeManagementCmd := app.Command("nodemanagement | nm", msgPrinter.Sprintf("List and manage manifests and agent files for node management.")).Alias("nm").Alias("nodemanagement")
 	nmOrg := nodeManagementCmd.Flag("org", msgPrinter.Sprintf("The Horizon organization ID. If not specified, HZN_ORG_ID will be used as a default.")).Short('o').String()
@@ -707,18 +799,22 @@ Environment Variables:
 	policyCmd := app.Command("policy | pol", msgPrinter.Sprintf("List and manage policy for this Horizon edge node.")).Alias("pol").Alias("policy")
 	policyListCmd := policyCmd.Command("list | ls", msgPrinter.Sprintf("Display this edge node's policy.")).Alias("ls").Alias("list")
 	policyNewCmd := policyCmd.Command("new", msgPrinter.Sprintf("Display an empty policy template that can be filled in."))
+	policyNewInteractive := policyNewCmd.Flag("interactive", msgPrinter.Sprintf("Build the policy by entering its properties and constraints at prompts. Each constraint is checked as it is entered and the constraints can be evaluated against sample properties. The prompts are written to stderr and the policy to stdout.")).Short('i').Bool()
 	policyPatchCmd := policyCmd.Command("patch", msgPrinter.Sprintf("(DEPRECATED) This command is deprecated. Please use 'hzn policy update' to update the node policy. This command is used to update either the node policy properties or the constraints, but not both."))
 	policyPatchInput := policyPatchCmd.Arg("patch", msgPrinter.Sprintf("The new constraints or properties in the format '%s' or '%s'.", "{\"constraints\":[<constraint list>]}", "{\"properties\":[<property list>]}")).Required().String()
 	policyRemoveCmd := policyCmd.Command("remove | rm", msgPrinter.Sprintf("Remove the node's policy.")).Alias("rm").Alias("remove")
 	policyRemoveForce := policyRemoveCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('f').Bool()
+	policyValidateCmd := policyCmd.Command("validate | vf", msgPrinter.Sprintf("Check the syntax of the properties and constraints of a policy file locally, showing the position of each error in the constraints. The constraints can also be evaluated against sample properties.")).Alias("vf").Alias("validate")
+	policyValidateInputFile := policyValidateCmd.Flag("input-file", msgPrinter.Sprintf("The JSON input file name containing the policy. It can be a node, deployment, or service policy. Specify -f- to read from stdin.")).Short('f').Required().String()
+	policyValidatePropsFile := policyValidateCmd.Flag("props", msgPrinter.Sprintf("A JSON file with sample properties to evaluate the constraints against. It contains either a list of properties or a policy, whose properties are used.")).Short('p').String()
 	policyUpdateCmd := policyCmd.Command("update | up", msgPrinter.Sprintf("Create or replace the node's policy. The node's built-in properties cannot be modified or deleted by this command, with the exception of openhorizon.allowPrivileged.")).Alias("up").Alias("update")
 	policyUpdateInputFile := policyUpdateCmd.Flag("input-file", msgPrinter.Sprintf("The JSON input file name containing the node policy. Specify -f- to read from stdin. A node policy contains the 'deployment' and 'management' attributes. Please use 'hzn policy new' to see the node policy format.")).Short('f').Required().String()
 
 	regInputCmd := app.Command("reginput", msgPrinter.Sprintf("Create an input file template for this pattern that can be used for the 'hzn register' command (once filled in). This examines the services that the specified pattern uses, and determines the node owner input that is required for them."))
 	regInputNodeIdTok := regInputCmd.Flag("node-id-tok", msgPrinter.Sprintf("The Horizon exchange node ID and token (it must already exist).")).Short('n').PlaceHolder("ID:TOK").Required().String()
 	regInputInputFile := regInputCmd.Flag("input-file", msgPrinter.Sprintf("The JSON input template file name that should be created. This file will contain placeholders for you to fill in user input values.")).Short('f').Required().String()
-	regInputOrg := regInputCmd.Arg("nodeorg", msgPrinter.Sprintf("The Horizon exchange organization ID that the node will be registered in.")).Required().String()
-	regInputPattern := regInputCmd.Arg("pattern", msgPrinter.Sprintf("The Horizon exchange pattern that describes what workloads that should be deployed to this node. If the pattern is from a different organization than the node, use the 'other_org/pattern' format.")).Required().String()
+	regInputOrg := regInputCmd.Arg("nodeorg", msgPrinter.Sprintf("The Horizon exchange organization ID that the node will be registered in.")).HintAction(cliutils.CompleteOrgs).Required().String()
+	regInputPattern := regInputCmd.Arg("pattern", msgPrinter.Sprintf("The Horizon exchange pattern that describes what workloads that should be deployed to this node. If the pattern is from a different organization than the node, use the 'other_org/pattern' format.")).HintAction(cliutils.CompletePatterns).Required().String()
 	regInputArch := regInputCmd.Arg("arch", msgPrinter.Sprintf("The architecture to write the template file for. (Horizon ignores services in patterns whose architecture is different from the target system.) The architecture must be what is returned by 'hzn node list' on the target system.")).Default(cutil.ArchString()).String()
 
 	registerCmd := app.Command("register | reg", msgPrinter.Sprintf("Register this edge node with Horizon.")).Alias("reg").Alias("register")
@@ -726,14 +822,16 @@ Environment Variables:
 	nodeName := registerCmd.Flag("name", msgPrinter.Sprintf("The name of the node. If not specified, it will be the same as the node id.")).Short('m').String()
 	userPw := registerCmd.Flag("user-pw", msgPrinter.Sprintf("User credentials to create the node resource in the Horizon exchange if it does not already exist. If not specified, HZN_EXCHANGE_USER_AUTH will be used as a default.")).Short('u').PlaceHolder("USER:PW").String()
 	inputFile := registerCmd.Flag("input-file", msgPrinter.Sprintf("A JSON file that sets or overrides user input variables needed by the services that will be deployed to this node. See %v/user_input.json. Specify -f- to read from stdin.", sample_dir)).Short('f').String() // not using ExistingFile() because it can be - for stdin
-	nodeOrgFlag := registerCmd.Flag("nodeorg", msgPrinter.Sprintf("The Horizon exchange organization ID that the node should be registered in. The default is the HZN_ORG_ID environment variable. Mutually exclusive with <nodeorg> and <pattern> arguments.")).Short('o').String()
-	patternFlag := registerCmd.Flag("pattern", msgPrinter.Sprintf("The Horizon exchange pattern that describes what workloads that should be deployed to this node. If the pattern is from a different organization than the node, use the 'other_org/pattern' format. Mutually exclusive with <nodeorg> and <pattern> arguments.")).Short('p').String()
+	nodeOrgFlag := registerCmd.Flag("nodeorg", msgPrinter.Sprintf("The Horizon exchange organization ID that the node should be registered in. The default is the HZN_ORG_ID environment variable. Mutually exclusive with <nodeorg> and <pattern> arguments.")).Short('o').HintAction(cliutils.CompleteOrgs).String()
+	patternFlag := registerCmd.Flag("pattern", msgPrinter.Sprintf("The Horizon exchange pattern that describes what workloads that should be deployed to this node. If the pattern is from a different organization than the node, use the 'other_org/pattern' format. Mutually exclusive with <nodeorg> and <pattern> arguments.")).Short('p').HintAction(cliutils.CompletePatterns).String()
 	nodepolicyFlag := registerCmd.Flag("policy", msgPrinter.Sprintf("A JSON file that sets or overrides the node policy for this node. A node policy contains the 'deployment' and 'management' attributes. Please use 'hzn policy new' to see the node policy format.")).String()
-	org := registerCmd.Arg("nodeorg", msgPrinter.Sprintf("The Horizon exchange organization ID that the node should be registered in. Mutually exclusive with -o and -p.")).String()
-	pattern := registerCmd.Arg("pattern", msgPrinter.Sprintf("The Horizon exchange pattern that describes what workloads that should be deployed to this node. If the pattern is from a different organization than the node, use the 'other_org/pattern' format. Mutually exclusive with -o and -p.")).String()
+	org := registerCmd.Arg("nodeorg", msgPrinter.Sprintf("The Horizon exchange organization ID that the node should be registered in. Mutually exclusive with -o and -p.")).HintAction(cliutils.CompleteOrgs).String()
+	pattern := registerCmd.Arg("pattern", msgPrinter.Sprintf("The Horizon exchange pattern that describes what workloads that should be deployed to this node. If the pattern is from a different organization than the node, use the 'other_org/pattern' format. Mutually exclusive with -o and -p.")).HintAction(cliutils.CompletePatterns).String()
+	joinTokenFlag := registerCmd.Flag("token", msgPrinter.Sprintf("A join token created with 'hzn exchange jointoken create'. The node is created in the Exchange by the agbot with the pattern and user input of the token, so the -u flag is not needed. The org of the node is the org of the token.")).String()
 	haGroupName := registerCmd.Flag("ha-group", msgPrinter.Sprintf("The name of the HA group that this node will be added to.")).String()
 	waitServiceFlag := registerCmd.Flag("service", msgPrinter.Sprintf("Wait for the named service to start executing on this node. When registering with a pattern, use '*' to watch all the services in the pattern. When registering with a policy, '*' is not a valid value for -s. This flag is not supported for edge cluster nodes.")).Short('s').String()
 	waitServiceOrgFlag := registerCmd.Flag("serviceorg", msgPrinter.Sprintf("The org of the service to wait for on this node. If '-s *' is specified, then --serviceorg must be omitted.")).String()
+	bundleFlag := registerCmd.Flag("from-bundle", msgPrinter.Sprintf("A node bundle created with 'hzn exchange node bundle'. The node is registered with the node id, token, pattern, node policy and user input of the bundle, so the -u flag is not needed, and the images listed in the bundle are pulled. The -f and --policy flags override the user input and node policy of the bundle. If the Exchange cannot be reached, the registration waits for it. Mutually exclusive with -n, -o, -p, --token and the <nodeorg> and <pattern> arguments.")).String()
 	waitTimeoutFlag := registerCmd.Flag("timeout", msgPrinter.Sprintf("The number of seconds for the --service to start. The default is 60 seconds, beginning when registration is successful. Ignored if --service is not specified.")).Short('t').Default("60").Int()
 
 	serviceCmd := app.Command("service | serv", msgPrinter.Sprintf("List or manage the services that are currently registered on this Horizon edge node.")).Alias("serv").Alias("service")
@@ -750,11 +848,19 @@ Environment Variables:
 	suspendServiceName := serviceConfigStateSuspendCmd.Arg("service", msgPrinter.Sprintf("The name of the service that should be suspended. If omitted, all the services for the organization will be suspended.")).String()
 	suspendServiceVersion := serviceConfigStateSuspendCmd.Arg("version", msgPrinter.Sprintf("The version of the service that should be suspended. If omitted, all the versions for this service will be suspended.")).String()
 	forceSuspendService := serviceConfigStateSuspendCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('f').Bool()
+	servicePauseCmd := serviceCmd.Command("pause", msgPrinter.Sprintf("Stop the containers of a service running on this Horizon edge node without cancelling its agreements, for troubleshooting or a maintenance window. On an edge cluster, the operator deployment of the service is scaled to zero."))
+	pauseServiceOrg := servicePauseCmd.Arg("serviceorg", msgPrinter.Sprintf("The organization of the service that should be paused.")).Required().String()
+	pauseServiceName := servicePauseCmd.Arg("service", msgPrinter.Sprintf("The name of the service that should be paused.")).Required().String()
+	serviceResumeCmd := serviceCmd.Command("resume", msgPrinter.Sprintf("Start the containers of a paused service on this Horizon edge node again."))
+	resumePausedServiceOrg := serviceResumeCmd.Arg("serviceorg", msgPrinter.Sprintf("The organization of the service that should be resumed.")).Required().String()
+	resumePausedServiceName := serviceResumeCmd.Arg("service", msgPrinter.Sprintf("The name of the service that should be resumed.")).Required().String()
 	serviceLogCmd := serviceCmd.Command("log", msgPrinter.Sprintf("Show the container logs for a service."))
-	logServiceName := serviceLogCmd.Arg("service", msgPrinter.Sprintf("The name of the service whose log records should be displayed. The service name is the same as the url field of a service definition. Displays log records similar to tail behavior and returns .")).Required().String()
+	logServiceName := serviceLogCmd.Arg("service", msgPrinter.Sprintf("The name of the service whose log records should be displayed. The service name is the same as the url field of a service definition. When the service has several containers and no container is given, the log records of all of them are displayed, prefixed with the name of their container.")).Required().String()
 	logServiceVersion := serviceLogCmd.Flag("version", msgPrinter.Sprintf("The version of the service.")).Short('V').String()
 	logServiceContainerName := serviceLogCmd.Flag("container", msgPrinter.Sprintf("The name of the container within the service whose log records should be displayed.")).Short('c').String()
-	logTail := serviceLogCmd.Flag("tail", msgPrinter.Sprintf("Continuously polls the service's logs to display the most recent records, similar to tail -F behavior.")).Short('f').Bool()
+	logFollow := serviceLogCmd.Flag("follow", msgPrinter.Sprintf("Keep displaying the new log records of the service as they are logged, similar to tail -F behavior.")).Short('f').Bool()
+	logSince := serviceLogCmd.Flag("since", msgPrinter.Sprintf("Only display the log records logged since this time, given as a duration such as 10m or 2h, or as an RFC 3339 timestamp.")).String()
+	logTail := serviceLogCmd.Flag("tail", msgPrinter.Sprintf("Only display this number of the last log records of each container.")).Default("-1").Int()
 	serviceListCmd := serviceCmd.Command("list | ls", msgPrinter.Sprintf("List the services variable configuration that has been done on this Horizon edge node.")).Alias("ls").Alias("list")
 	serviceRegisteredCmd := serviceCmd.Command("registered | reg", msgPrinter.Sprintf("List the services that are currently registered on this Horizon edge node.")).Alias("reg").Alias("registered")
 
@@ -767,6 +873,9 @@ Environment Variables:
 	deepCleanUnregister := unregisterCmd.Flag("deep-clean", msgPrinter.Sprintf("Also remove all the previous registration information. Use it only after the 'hzn unregister' command failed. The eventlog is automatically saved after running this command.")).Short('D').Bool()
 	timeoutUnregister := unregisterCmd.Flag("timeout", msgPrinter.Sprintf("The number of minutes to wait for unregistration to complete. The default is zero which will wait forever.")).Short('t').Default("0").Int()
 	containerUnregister := unregisterCmd.Flag("container", msgPrinter.Sprintf("Perform a deep clean on a node running in a container. This flag  must be used with -D and only if the agent was installed as anax-in-container.")).Short('C').Bool()
+	exportPathUnregister := unregisterCmd.Flag("export-path", msgPrinter.Sprintf("Run the pre_stop command of each service container, then export the named volumes of the services to this absolute directory as tar files before the services are removed. The directory is on the file system of the agent.")).String()
+	exportObjectTypeUnregister := unregisterCmd.Flag("export-object-type", msgPrinter.Sprintf("Also publish the exported volumes as objects of this type in the Model Management Service. This flag must be used with --export-path.")).String()
+	userPwUnregister := unregisterCmd.Flag("user-pw", msgPrinter.Sprintf("Horizon user credentials to publish the exported volumes in the Model Management Service. If not specified, HZN_EXCHANGE_USER_AUTH will be used as a default.")).Short('u').PlaceHolder("USER:PW").String()
 
 	userinputCmd := app.Command("userinput | u", msgPrinter.Sprintf("List or manage the service user inputs that are currently registered on this Horizon edge node.")).Alias("u").Alias("userinput")
 	userinputAddCmd := userinputCmd.Command("add", msgPrinter.Sprintf("Add a new user input object or overwrite the current user input object for this Horizon edge node."))
@@ -786,10 +895,41 @@ Environment Variables:
 	nmstatusResetName := nmstatusResetCmd.Arg("name", msgPrinter.Sprintf("The name of the node management policy. If omitted all management policies for this node will be re-evaluated.")).String()
 
 	utilCmd := app.Command("util", msgPrinter.Sprintf("Utility commands."))
+	utilAgentManifestCmd := utilCmd.Command("agent-manifest", msgPrinter.Sprintf("Display the manifest that installs the agent with custom settings, from the same templates as agent-install.sh. The k8s manifest holds the kubernetes resources of the edge cluster agent, apply it with 'kubectl apply -f -'. The device manifest holds /etc/default/horizon and a systemd drop-in for the horizon service of the horizon package."))
+	utilAgentManifestType := utilAgentManifestCmd.Flag("type", msgPrinter.Sprintf("The type of the manifest: k8s or device.")).Short('t').Required().HintOptions(utilcmds.GetAgentManifestTypes()...).Enum(utilcmds.GetAgentManifestTypes()...)
+	utilAgentManifestOrg := utilAgentManifestCmd.Flag("org", msgPrinter.Sprintf("The Horizon organization ID of the node. If not specified, HZN_ORG_ID will be used as a default.")).Short('o').Envar("HZN_ORG_ID").String()
+	utilAgentManifestExchangeUrl := utilAgentManifestCmd.Flag("exchange-url", msgPrinter.Sprintf("The URL of the Horizon Exchange. If not specified, HZN_EXCHANGE_URL will be used as a default.")).Envar("HZN_EXCHANGE_URL").String()
+	utilAgentManifestCssUrl := utilAgentManifestCmd.Flag("css-url", msgPrinter.Sprintf("The URL of the Model Management Service. If not specified, HZN_FSS_CSSURL will be used as a default.")).Envar("HZN_FSS_CSSURL").String()
+	utilAgentManifestAgbotUrl := utilAgentManifestCmd.Flag("agbot-url", msgPrinter.Sprintf("The URL of the agbot secure API. If not specified, HZN_AGBOT_URL will be used as a default.")).Envar("HZN_AGBOT_URL").String()
+	utilAgentManifestNodeId := utilAgentManifestCmd.Flag("node-id", msgPrinter.Sprintf("The ID of the node.")).String()
+	utilAgentManifestCertFile := utilAgentManifestCmd.Flag("cert-file", msgPrinter.Sprintf("The path of the certificate file of the management hub.")).Short('c').ExistingFile()
+	utilAgentManifestNamespace := utilAgentManifestCmd.Flag("namespace", msgPrinter.Sprintf("The namespace of the edge cluster agent. The default is %v.", utilcmds.DEFAULT_AGENT_NAMESPACE)).String()
+	utilAgentManifestNamespaceScoped := utilAgentManifestCmd.Flag("namespace-scoped", msgPrinter.Sprintf("The edge cluster agent only deploys services in its own namespace.")).Bool()
+	utilAgentManifestOpenShift := utilAgentManifestCmd.Flag("openshift", msgPrinter.Sprintf("The edge cluster is an OpenShift cluster, the permissions of the agent volume are set by its security context constraints.")).Bool()
+	utilAgentManifestImage := utilAgentManifestCmd.Flag("image", msgPrinter.Sprintf("The image of the edge cluster agent.")).String()
+	utilAgentManifestAutoUpgradeImage := utilAgentManifestCmd.Flag("auto-upgrade-image", msgPrinter.Sprintf("The image of the auto-upgrade cronjob of the edge cluster agent. The cronjob is not in the manifest when it is not specified.")).String()
+	utilAgentManifestRegistryMirror := utilAgentManifestCmd.Flag("registry-mirror", msgPrinter.Sprintf("The registry mirror (host[:port][/path]) that replaces the registry of the edge cluster agent images.")).String()
+	utilAgentManifestStorageClass := utilAgentManifestCmd.Flag("storage-class", msgPrinter.Sprintf("The storage class of the persistent volume claim of the edge cluster agent. The default is %v.", utilcmds.DEFAULT_AGENT_STORAGE_CLASS)).String()
+	utilAgentManifestPVCAccessMode := utilAgentManifestCmd.Flag("pvc-access-mode", msgPrinter.Sprintf("The access mode of the persistent volume claim of the edge cluster agent. The default is %v.", utilcmds.DEFAULT_AGENT_PVC_ACCESS_MODE)).String()
+	utilAgentManifestCPURequest := utilAgentManifestCmd.Flag("cpu-request", msgPrinter.Sprintf("The CPU request of the edge cluster agent, for example 100m.")).String()
+	utilAgentManifestCPULimit := utilAgentManifestCmd.Flag("cpu-limit", msgPrinter.Sprintf("The CPU limit of the agent, for example 500m or 2. It is the CPUQuota of the horizon service on a device.")).String()
+	utilAgentManifestMemoryRequest := utilAgentManifestCmd.Flag("memory-request", msgPrinter.Sprintf("The memory request of the agent, for example 256Mi. It is the MemoryLow of the horizon service on a device.")).String()
+	utilAgentManifestMemoryLimit := utilAgentManifestCmd.Flag("memory-limit", msgPrinter.Sprintf("The memory limit of the agent, for example 1Gi. It is the MemoryMax of the horizon service on a device.")).String()
+	utilAgentManifestHTTPProxy := utilAgentManifestCmd.Flag("http-proxy", msgPrinter.Sprintf("The HTTP_PROXY of the agent.")).String()
+	utilAgentManifestHTTPSProxy := utilAgentManifestCmd.Flag("https-proxy", msgPrinter.Sprintf("The HTTPS_PROXY of the agent.")).String()
+	utilAgentManifestNoProxy := utilAgentManifestCmd.Flag("no-proxy", msgPrinter.Sprintf("The NO_PROXY of the agent.")).String()
+	utilAgentManifestOutputDir := utilAgentManifestCmd.Flag("output-dir", msgPrinter.Sprintf("Write the files of the device manifest under this directory instead of displaying them. Use -d / on the node to install them.")).Short('d').String()
 	utilConfigConvCmd := utilCmd.Command("configconv | cfg", msgPrinter.Sprintf("Convert the configuration file from JSON format to a shell script.")).Alias("cfg").Alias("configconv")
 	utilConfigConvFile := utilConfigConvCmd.Flag("config-file", msgPrinter.Sprintf("The path of a configuration file to be converted. ")).Short('f').Required().ExistingFile()
 	utilSignCmd := utilCmd.Command("sign", msgPrinter.Sprintf("Sign the text in stdin. The signature is sent to stdout."))
 	utilSignPrivKeyFile := utilSignCmd.Flag("private-key-file", msgPrinter.Sprintf("The path of a private key file to be used to sign the stdin. ")).Short('k').Required().ExistingFile()
+	utilSignNodePolicyCmd := utilCmd.Command("signnodepolicy", msgPrinter.Sprintf("Sign the properties of the node policy in stdin with a private key of the node's organization, so that the agbots trust them. The signed node policy is sent to stdout, add it to the node with 'hzn exchange node addpolicy -f-'. Changing a signed property or the signed user input invalidates the signature."))
+	utilSignNodePolicyPrivKeyFile := utilSignNodePolicyCmd.Flag("private-key-file", msgPrinter.Sprintf("The path of a private key file of the node's organization to sign the node policy.")).Short('k').Required().ExistingFile()
+	utilSignNodePolicyNode := utilSignNodePolicyCmd.Flag("node", msgPrinter.Sprintf("The org qualified id of the node the policy is for, the signature is only valid for this node.")).Short('n').Required().String()
+	utilSignNodePolicyProps := utilSignNodePolicyCmd.Flag("property", msgPrinter.Sprintf("The name of a node property to sign. This flag can be repeated to sign more than one property.")).Short('p').Strings()
+	utilSignNodePolicyUIFile := utilSignNodePolicyCmd.Flag("userinput-file", msgPrinter.Sprintf("The path of a JSON file containing the user input of the node to sign with the properties. It must be the same as the user input set on the node.")).ExistingFile()
+	utilCompletionCmd := utilCmd.Command("completion", msgPrinter.Sprintf("Display the completion script of the shell. The script completes the commands, the flags and the names of the organizations, services, patterns, deployment policies and nodes in the exchange, using HZN_EXCHANGE_URL, HZN_ORG_ID and HZN_EXCHANGE_USER_AUTH. Load it with 'source <(hzn util completion bash)' in bash and zsh, or 'hzn util completion fish | source' in fish."))
+	utilCompletionShell := utilCompletionCmd.Arg("shell", msgPrinter.Sprintf("The shell: bash, zsh or fish.")).Required().HintOptions(utilcmds.GetCompletionShells()...).Enum(utilcmds.GetCompletionShells()...)
 	utilVerifyCmd := utilCmd.Command("verify | vf", msgPrinter.Sprintf("Verify that the signature specified via -s is a valid signature for the text in stdin.")).Alias("vf").Alias("verify")
 	utilVerifyPubKeyFile := utilVerifyCmd.Flag("public-key-file", msgPrinter.Sprintf("The path of public key file (that corresponds to the private key that was used to sign) to verify the signature of stdin.")).Short('K').Required().ExistingFile()
 	utilVerifySig := utilVerifyCmd.Flag("signature", msgPrinter.Sprintf("The supposed signature of stdin.")).Short('s').Required().String()
@@ -811,6 +951,14 @@ Environment Variables:
 	smSecretRemoveName := smSecretRemoveCmd.Arg("secretName", msgPrinter.Sprintf("The name of the secret to be removed from the secrets manager.")).Required().String()
 	smSecretReadCmd := smSecretCmd.Command("read", msgPrinter.Sprintf("Read the details of a secret stored in the secrets manager. This consists of the key and value pair provided on secret creation."))
 	smSecretReadName := smSecretReadCmd.Arg("secretName", msgPrinter.Sprintf("The name of the secret to read in the secrets manager.")).Required().String()
+	smSecretRotateCmd := smSecretCmd.Command("rotate", msgPrinter.Sprintf("Replace the details of an existing secret in the secrets manager. The changes to the key and value are displayed, without the values, with the services and agreements that use the secret. The agbot sends the new details to the nodes of those agreements, which restart the services. Use --dry-run to only display the changes and the services."))
+	smSecretRotateName := smSecretRotateCmd.Arg("secretName", msgPrinter.Sprintf("The name of the secret to rotate in the secrets manager.")).Required().String()
+	smSecretRotateFile := smSecretRotateCmd.Flag("secretFile", msgPrinter.Sprintf("Filepath to a file containing the new secret details. Mutually exclusive with --secretDetail. Specify -f- to read from stdin, which requires --force.")).Short('f').String()
+	smSecretRotateKey := smSecretRotateCmd.Flag("secretKey", msgPrinter.Sprintf("A new key for the secret. If not specified, the current key is kept.")).String()
+	smSecretRotateDetail := smSecretRotateCmd.Flag("secretDetail", msgPrinter.Sprintf("The new secret details as a string. Mutually exclusive with --secretFile.")).Short('d').String()
+	smSecretRotateForce := smSecretRotateCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('F').Bool()
+	smSecretUsageCmd := smSecretCmd.Command("usage", msgPrinter.Sprintf("Display the services of the agreements that use a secret. These services are restarted with the new details when the secret is updated."))
+	smSecretUsageName := smSecretUsageCmd.Arg("secretName", msgPrinter.Sprintf("The name of the secret in the secrets manager.")).Required().String()
 
 	versionCmd := app.Command("version", msgPrinter.Sprintf("Show the Horizon version.")) // using a cmd for this instead of --version flag, because kingpin takes over the latter and can't get version only when it is needed
 
@@ -990,6 +1138,8 @@ Environment Variables:
 			credToUse = cliutils.GetExchangeAuth(*exUserPw, *exPatternListKeyNodeIdTok, false)
 		case "service | serv listpolicy | lsp":
 			credToUse = cliutils.GetExchangeAuth(*exUserPw, *exServiceListPolicyIdTok, false)
+		case "service | serv history":
+			credToUse = cliutils.GetExchangeAuth(*exUserPw, *exServiceHistoryIdTok, false)
 		case "service | serv addpolicy | addp":
 			credToUse = cliutils.GetExchangeAuth(*exUserPw, *exServiceAddPolicyIdTok, false)
 		case "service | serv removepolicy | rmp":
@@ -1008,8 +1158,26 @@ Environment Variables:
 			credToUse = cliutils.GetExchangeAuth(*exUserPw, "", false)
 		case "hagroup | hagr member | mb remove | rm":
 			credToUse = cliutils.GetExchangeAuth(*exUserPw, "", false)
+		case "fleet | fl list | ls":
+			credToUse = cliutils.GetExchangeAuth(*exUserPw, "", false)
+		case "fleet | fl add":
+			credToUse = cliutils.GetExchangeAuth(*exUserPw, "", false)
+		case "fleet | fl remove | rm":
+			credToUse = cliutils.GetExchangeAuth(*exUserPw, "", false)
+		case "fleet | fl new":
+			// does not require exchange credentials
+		case "jointoken | jt list | ls":
+			credToUse = cliutils.GetExchangeAuth(*exUserPw, "", false)
+		case "jointoken | jt create":
+			credToUse = cliutils.GetExchangeAuth(*exUserPw, "", false)
+		case "jointoken | jt remove | rm":
+			credToUse = cliutils.GetExchangeAuth(*exUserPw, "", false)
+		case "jointoken | jt new":
+			// does not require exchange credentials
 		case "deployment | dep listpolicy | ls":
 			credToUse = cliutils.GetExchangeAuth(*exUserPw, *exBusinessListPolicyIdTok, false)
+		case "deployment | dep history":
+			credToUse = cliutils.GetExchangeAuth(*exUserPw, *exBusinessHistoryIdTok, false)
 		case "deployment | dep updatepolicy | upp":
 			credToUse = cliutils.GetExchangeAuth(*exUserPw, *exBusinessUpdatePolicyIdTok, false)
 		case "deployment | dep addpolicy | addp":
@@ -1042,9 +1210,12 @@ Environment Variables:
 		// use HZN_ORG_ID or org provided by -o for version check
 		verCheckOrg := cliutils.WithDefaultEnvVar(org, "HZN_ORG_ID")
 
-		if exVersion := exchange.LoadExchangeVersion(false, *verCheckOrg, *userPw, *nodeIdTok); exVersion != "" {
-			if err := version.VerifyExchangeVersion1(exVersion, false); err != nil {
-				cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, err.Error())
+		// a node registering from a bundle may not be able to reach the exchange yet, the version is checked once it can
+		if *bundleFlag == "" {
+			if exVersion := exchange.LoadExchangeVersion(false, *verCheckOrg, *userPw, *nodeIdTok); exVersion != "" {
+				if err := version.VerifyExchangeVersion1(exVersion, false); err != nil {
+					cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, err.Error())
+				}
 			}
 		}
 	}
@@ -1071,9 +1242,12 @@ Environment Variables:
 			allCompBPolFile = allCompDepPolFile
 		}
 
-		if exVersion := exchange.LoadExchangeVersion(false, *deploycheckOrg, *deploycheckUserPw); exVersion != "" {
-			if err := version.VerifyExchangeVersion1(exVersion, false); err != nil {
-				cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, err.Error())
+		// rendering the cluster deployment is done locally, so the exchange does not have to be available
+		if fullCmd != k8sRenderCmd.FullCommand() {
+			if exVersion := exchange.LoadExchangeVersion(false, *deploycheckOrg, *deploycheckUserPw); exVersion != "" {
+				if err := version.VerifyExchangeVersion1(exVersion, false); err != nil {
+					cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, err.Error())
+				}
 			}
 		}
 	}
@@ -1173,17 +1347,24 @@ Environment Variables:
 		node.Architecture()
 	case exVersionCmd.FullCommand():
 		exchange.Version(*exOrg, credToUse)
+	case exExportCmd.FullCommand():
+		exchange.BundleExport(*exOrg, *exUserPw)
+	case exApplyCmd.FullCommand():
+		exchange.BundleApply(*exOrg, *exUserPw, *exApplyFile, *exApplyForce)
+
 	case exStatusCmd.FullCommand():
 		exchange.Status(*exOrg, *exUserPw)
 
 	case exOrgListCmd.FullCommand():
 		exchange.OrgList(*exOrg, *exUserPw, *exOrgListOrg, *exOrgListLong)
 	case exOrgCreateCmd.FullCommand():
-		exchange.OrgCreate(*exOrg, *exUserPw, *exOrgCreateOrg, *exOrgCreateLabel, *exOrgCreateDesc, *exOrgCreateTags, *exOrgCreateHBMin, *exOrgCreateHBMax, *exOrgCreateHBAdjust, *exOrgCreateMaxNodes, *exOrgCreateAddToAgbot)
+		exchange.OrgCreate(*exOrg, *exUserPw, *exOrgCreateOrg, *exOrgCreateLabel, *exOrgCreateDesc, *exOrgCreateTags, *exOrgCreateHBMin, *exOrgCreateHBMax, *exOrgCreateHBAdjust, *exOrgCreateMaxNodes, *exOrgCreateMaxServices, *exOrgCreateAddToAgbot)
 	case exOrgUpdateCmd.FullCommand():
-		exchange.OrgUpdate(*exOrg, *exUserPw, *exOrgUpdateOrg, *exOrgUpdateLabel, *exOrgUpdateDesc, *exOrgUpdateTags, *exOrgUpdateHBMin, *exOrgUpdateHBMax, *exOrgUpdateHBAdjust, *exOrgUpdateMaxNodes)
+		exchange.OrgUpdate(*exOrg, *exUserPw, *exOrgUpdateOrg, *exOrgUpdateLabel, *exOrgUpdateDesc, *exOrgUpdateTags, *exOrgUpdateHBMin, *exOrgUpdateHBMax, *exOrgUpdateHBAdjust, *exOrgUpdateMaxNodes, *exOrgUpdateMaxServices)
 	case exOrgDelCmd.FullCommand():
 		exchange.OrgDel(*exOrg, *exUserPw, *exOrgDelOrg, *exOrgDelFromAgbot, *exOrgDelForce)
+	case exOrgStatusCmd.FullCommand():
+		exchange.OrgStatus(*exOrg, *exUserPw, *exOrgStatusOrg)
 
 	case exUserListCmd.FullCommand():
 		exchange.UserList(*exOrg, *exUserPw, *exUserListUser, *exUserListAll, *exUserListNamesOnly)
@@ -1221,11 +1402,29 @@ Environment Variables:
 		exchange.HAGroupMemberAdd(*exOrg, credToUse, *exHAGroupMemberAddName, *exHAGroupMemberAddNodes)
 	case exHAGroupMemberRemoveCmd.FullCommand():
 		exchange.HAGroupMemberRemove(*exOrg, credToUse, *exHAGroupMemberRemoveName, *exHAGroupMemberRemoveNodes, *exHAGroupMemberRemoveForce)
+	case exFleetNewCmd.FullCommand():
+		exchange.FleetNew()
+	case exFleetListCmd.FullCommand():
+		exchange.FleetList(*exOrg, credToUse, *exFleetListName, !*exFleetListLong)
+	case exFleetAddCmd.FullCommand():
+		exchange.FleetAdd(*exOrg, credToUse, *exFleetAddName, *exFleetAddJsonFile)
+	case exFleetRemoveCmd.FullCommand():
+		exchange.FleetRemove(*exOrg, credToUse, *exFleetRemoveName, *exFleetRemoveForce)
+	case exJoinTokenNewCmd.FullCommand():
+		exchange.JoinTokenNew()
+	case exJoinTokenListCmd.FullCommand():
+		exchange.JoinTokenList(*exOrg, credToUse, *exJoinTokenListId, !*exJoinTokenListLong)
+	case exJoinTokenCreateCmd.FullCommand():
+		exchange.JoinTokenCreate(*exOrg, credToUse, *exJoinTokenCreateJsonFile)
+	case exJoinTokenRemoveCmd.FullCommand():
+		exchange.JoinTokenRemove(*exOrg, credToUse, *exJoinTokenRemoveId, *exJoinTokenRemoveForce)
 
 	case exNodeListCmd.FullCommand():
 		exchange.NodeList(*exOrg, credToUse, *exNode, !*exNodeLong)
 	case exNodeUpdateCmd.FullCommand():
 		exchange.NodeUpdate(*exOrg, credToUse, *exNodeUpdateNode, *exNodeUpdateJsonFile)
+	case exNodeBundleCmd.FullCommand():
+		exchange.NodeBundleCreate(*exOrg, *exUserPw, *exNodeBundleNode, *exNodeBundleToken, *exNodeBundleArch, *exNodeBundleName, *exNodeBundleType, *exNodeBundlePattern, *exNodeBundlePolicy, *exNodeBundleUserInput, *exNodeBundleImages, *exNodeBundleFile)
 	case exNodeCreateCmd.FullCommand():
 		exchange.NodeCreate(*exOrg, *exNodeCreateNodeIdTok, *exNodeCreateNode, *exNodeCreateToken, *exUserPw, *exNodeCreateNodeArch, *exNodeCreateNodeName, *exNodeCreateNodeType, true)
 	case exNodeSetTokCmd.FullCommand():
@@ -1312,8 +1511,12 @@ Environment Variables:
 		exchange.ServiceAddPolicy(*exOrg, credToUse, *exServiceAddPolicyService, *exServiceAddPolicyJsonFile)
 	case exServiceRemovePolicyCmd.FullCommand():
 		exchange.ServiceRemovePolicy(*exOrg, credToUse, *exServiceRemovePolicyService, *exServiceRemovePolicyForce)
+	case exServiceHistoryCmd.FullCommand():
+		exchange.ServiceHistory(*exOrg, credToUse, *exServiceHistoryService)
 	case exServiceListnode.FullCommand():
 		exchange.ListServiceNodes(*exOrg, *exUserPw, *exServiceListnodeService, *exServiceListnodeNodeOrg)
+	case exBusinessHistoryCmd.FullCommand():
+		exchange.DeploymentPolicyHistory(*exOrg, credToUse, *exBusinessHistoryPolicy)
 	case exBusinessListPolicyCmd.FullCommand():
 		exchange.BusinessListPolicy(*exOrg, credToUse, *exBusinessListPolicyPolicy, !*exBusinessListPolicyLong)
 	case exBusinessNewPolicyCmd.FullCommand():
@@ -1331,7 +1534,7 @@ Environment Variables:
 	case regInputCmd.FullCommand():
 		register.CreateInputFile(*regInputOrg, *regInputPattern, *regInputArch, *regInputNodeIdTok, *regInputInputFile)
 	case registerCmd.FullCommand():
-		register.DoIt(*org, *pattern, *nodeIdTok, *userPw, *inputFile, *nodeOrgFlag, *patternFlag, *nodeName, *haGroupName, *nodepolicyFlag, *waitServiceFlag, *waitServiceOrgFlag, *waitTimeoutFlag)
+		register.DoIt(*org, *pattern, *nodeIdTok, *userPw, *inputFile, *nodeOrgFlag, *patternFlag, *nodeName, *haGroupName, *nodepolicyFlag, *joinTokenFlag, *bundleFlag, *waitServiceFlag, *waitServiceOrgFlag, *waitTimeoutFlag)
 	case keyListCmd.FullCommand():
 		key.List(*keyName, *keyListAll)
 	case keyCreateCmd.FullCommand():
@@ -1342,10 +1545,16 @@ Environment Variables:
 		key.Remove(*keyDelName)
 	case nodeListCmd.FullCommand():
 		node.List()
+	case nodeMaintenanceOnCmd.FullCommand():
+		node.MaintenanceOn(*nodeMaintenanceOnDrain)
+	case nodeMaintenanceOffCmd.FullCommand():
+		node.MaintenanceOff()
 	case policyListCmd.FullCommand():
 		policy.List()
 	case policyNewCmd.FullCommand():
-		policy.New()
+		policy.New(*policyNewInteractive)
+	case policyValidateCmd.FullCommand():
+		policy.Validate(*policyValidateInputFile, *policyValidatePropsFile)
 	case policyUpdateCmd.FullCommand():
 		policy.Update(*policyUpdateInputFile)
 	case policyPatchCmd.FullCommand():
@@ -1353,17 +1562,21 @@ Environment Variables:
 	case policyRemoveCmd.FullCommand():
 		policy.Remove(*policyRemoveForce)
 	case policyCompCmd.FullCommand():
-		deploycheck.PolicyCompatible(*deploycheckOrg, *deploycheckUserPw, *policyCompNodeId, *policyCompHAGroup, *policyCompNodeArch, *policyCompNodeType, *policyCompNodeNs, *policyCompNodePolFile, *policyCompBPolId, *policyCompBPolFile, *policyCompSPolFile, *policyCompSvcFile, *deploycheckCheckAll, *deploycheckLong)
+		deploycheck.PolicyCompatible(*deploycheckOrg, *deploycheckUserPw, *policyCompNodeId, *policyCompHAGroup, *policyCompNodeArch, *policyCompNodeType, *policyCompNodeNs, *policyCompNodePolFile, *policyCompBPolId, *policyCompBPolFile, *policyCompSPolFile, *policyCompSvcFile, *deploycheckCheckAll, *deploycheckLong, *deploycheckExplain)
 	case userinputCompCmd.FullCommand():
 		deploycheck.UserInputCompatible(*deploycheckOrg, *deploycheckUserPw, *userinputCompNodeId, *userinputCompNodeArch, *userinputCompNodeType, *userinputCompNodeUIFile, *userinputCompBPolId, *userinputCompBPolFile, *userinputCompPatternId, *userinputCompPatternFile, *userinputCompSvcFile, *deploycheckCheckAll, *deploycheckLong)
+	case k8sRenderCmd.FullCommand():
+		deploycheck.K8sRender(*k8sRenderSvcFile, *k8sRenderNodeUIFile, *k8sRenderNamespace, *k8sRenderAgId)
 	case secretCompCmd.FullCommand():
 		deploycheck.SecretBindingCompatible(*deploycheckOrg, *deploycheckUserPw, *secretCompNodeId, *secretCompNodeArch, *secretCompNodeType, *secretCompNodeOrg, *secretCompDepPolId, *secretCompDepPolFile, *secretCompPatternId, *secretCompPatternFile, *secretCompSvcFile, *deploycheckCheckAll, *deploycheckLong)
 	case allCompCmd.FullCommand():
-		deploycheck.AllCompatible(*deploycheckOrg, *deploycheckUserPw, *allCompNodeId, *allCompHAGroup, *allCompNodeArch, *allCompNodeType, *allCompNodeNs, *allCompNodeOrg, *allCompNodePolFile, *allCompNodeUIFile, *allCompBPolId, *allCompBPolFile, *allCompPatternId, *allCompPatternFile, *allCompSPolFile, *allCompSvcFile, *deploycheckCheckAll, *deploycheckLong)
+		deploycheck.AllCompatible(*deploycheckOrg, *deploycheckUserPw, *allCompNodeId, *allCompHAGroup, *allCompNodeArch, *allCompNodeType, *allCompNodeNs, *allCompNodeOrg, *allCompNodePolFile, *allCompNodeUIFile, *allCompBPolId, *allCompBPolFile, *allCompPatternId, *allCompPatternFile, *allCompSPolFile, *allCompSvcFile, *deploycheckCheckAll, *deploycheckLong, *deploycheckExplain)
 	case agreementListCmd.FullCommand():
 		agreement.List(*listArchivedAgreements, *listAgreementId)
 	case agreementCancelCmd.FullCommand():
 		agreement.Cancel(*cancelAgreementId, *cancelAllAgreements)
+	case agreementHistoryCmd.FullCommand():
+		agreement.History(*historyAgreementId)
 	case meteringListCmd.FullCommand():
 		metering.List(*listArchivedMetering)
 	case attributeListCmd.FullCommand():
@@ -1381,7 +1594,7 @@ Environment Variables:
 	case serviceListCmd.FullCommand():
 		service.List()
 	case serviceLogCmd.FullCommand():
-		service.Log(*logServiceName, *logServiceVersion, *logServiceContainerName, *logTail)
+		service.Log(*logServiceName, *logServiceVersion, *logServiceContainerName, *logFollow, *logSince, *logTail)
 	case serviceRegisteredCmd.FullCommand():
 		service.Registered()
 	case serviceConfigStateListCmd.FullCommand():
@@ -1390,8 +1603,14 @@ Environment Variables:
 		service.Suspend(*forceSuspendService, *suspendAllServices, *suspendServiceOrg, *suspendServiceName, *suspendServiceVersion)
 	case serviceConfigStateActiveCmd.FullCommand():
 		service.Resume(*resumeAllServices, *resumeServiceOrg, *resumeServiceName, *resumeServiceVersion)
+	case servicePauseCmd.FullCommand():
+		service.Pause(*pauseServiceOrg, *pauseServiceName)
+	case serviceResumeCmd.FullCommand():
+		service.ResumePaused(*resumePausedServiceOrg, *resumePausedServiceName)
 	case unregisterCmd.FullCommand():
-		unregister.DoIt(*forceUnregister, *removeNodeUnregister, *deepCleanUnregister, *timeoutUnregister, *containerUnregister)
+		unregister.DoIt(*forceUnregister, *removeNodeUnregister, *deepCleanUnregister, *timeoutUnregister, *containerUnregister, *exportPathUnregister, *exportObjectTypeUnregister, *userPwUnregister)
+	case diagnoseCmd.FullCommand():
+		diagnose.Diagnose(*diagnoseOutput, *diagnoseNoBundle)
 	case statusCmd.FullCommand():
 		status.DisplayStatus(*statusLong, false)
 	case eventlogListCmd.FullCommand():
@@ -1404,6 +1623,8 @@ Environment Variables:
 		dev.ServiceStartTest(*devHomeDirectory, *devServiceUserInputFile, *devServiceConfigFile, *devServiceConfigType, *devServiceNoFSS, *devServiceStartCmdUserPw, *devServiceStartSecretsFiles)
 	case devServiceStopTestCmd.FullCommand():
 		dev.ServiceStopTest(*devHomeDirectory)
+	case devServicePublishCmd.FullCommand():
+		dev.ServicePublish(*devHomeDirectory, *devServicePublishUserPw, *devServicePublishArch, *devServicePublishMultiArch, *devServicePublishDockerfile, *devServicePublishContext, *devServicePublishPrivKeyFile, *devServicePublishPubKeyFile, *devServicePublishRegistryTokens, *devServicePublishOverwrite, *devServicePublishPolicyFile)
 	case devServiceValidateCmd.FullCommand():
 		dev.ServiceValidate(*devHomeDirectory, *devServiceVerifyUserInputFile, []string{}, "", *devServiceValidateCmdUserPw)
 	case devServiceLogCmd.FullCommand():
@@ -1415,7 +1636,7 @@ Environment Variables:
 	case devDependencyRemoveCmd.FullCommand():
 		dev.DependencyRemove(*devHomeDirectory, *devDependencyCmdSpecRef, *devDependencyCmdURL, *devDependencyCmdVersion, *devDependencyCmdArch, *devDependencyCmdOrg)
 	case agbotAgreementListCmd.FullCommand():
-		agreementbot.AgreementList(*agbotlistArchivedAgreements, *agbotAgreement)
+		agreementbot.AgreementList(*agbotlistArchivedAgreements, *agbotAgreement, *agbotAgreementListNode, *agbotAgreementListPolicy, *agbotAgreementListState, *agbotAgreementListSince)
 	case agbotAgreementCancelCmd.FullCommand():
 		agreementbot.AgreementCancel(*agbotCancelAgreementId, *agbotCancelAllAgreements)
 	case agbotListCmd.FullCommand():
@@ -1424,10 +1645,39 @@ Environment Variables:
 		agreementbot.PolicyList(*agbotPolicyOrg, *agbotPolicyName)
 	case utilSignCmd.FullCommand():
 		utilcmds.Sign(*utilSignPrivKeyFile)
+	case utilSignNodePolicyCmd.FullCommand():
+		utilcmds.SignNodePolicy(*utilSignNodePolicyPrivKeyFile, *utilSignNodePolicyNode, *utilSignNodePolicyProps, *utilSignNodePolicyUIFile)
 	case utilVerifyCmd.FullCommand():
 		utilcmds.Verify(*utilVerifyPubKeyFile, *utilVerifySig)
 	case agbotStatusCmd.FullCommand():
 		status.DisplayStatus(*agbotStatusLong, true)
+	case utilAgentManifestCmd.FullCommand():
+		utilcmds.AgentManifest(utilcmds.AgentManifestSettings{
+			Type:             *utilAgentManifestType,
+			Org:              *utilAgentManifestOrg,
+			ExchangeUrl:      *utilAgentManifestExchangeUrl,
+			CssUrl:           *utilAgentManifestCssUrl,
+			AgbotUrl:         *utilAgentManifestAgbotUrl,
+			NodeId:           *utilAgentManifestNodeId,
+			CertFile:         *utilAgentManifestCertFile,
+			Namespace:        *utilAgentManifestNamespace,
+			NamespaceScoped:  *utilAgentManifestNamespaceScoped,
+			OpenShift:        *utilAgentManifestOpenShift,
+			Image:            *utilAgentManifestImage,
+			AutoUpgradeImage: *utilAgentManifestAutoUpgradeImage,
+			RegistryMirror:   *utilAgentManifestRegistryMirror,
+			StorageClass:     *utilAgentManifestStorageClass,
+			PVCAccessMode:    *utilAgentManifestPVCAccessMode,
+			CPURequest:       *utilAgentManifestCPURequest,
+			CPULimit:         *utilAgentManifestCPULimit,
+			MemoryRequest:    *utilAgentManifestMemoryRequest,
+			MemoryLimit:      *utilAgentManifestMemoryLimit,
+			HTTPProxy:        *utilAgentManifestHTTPProxy,
+			HTTPSProxy:       *utilAgentManifestHTTPSProxy,
+			NoProxy:          *utilAgentManifestNoProxy,
+		}, *utilAgentManifestOutputDir)
+	case utilCompletionCmd.FullCommand():
+		utilcmds.Completion(*utilCompletionShell)
 	case utilConfigConvCmd.FullCommand():
 		utilcmds.ConvertConfig(*utilConfigConvFile)
 	case mmsStatusCmd.FullCommand():
@@ -1437,11 +1687,11 @@ Environment Variables:
 	case mmsObjectNewCmd.FullCommand():
 		sync_service.ObjectNew(*mmsOrg)
 	case mmsObjectPublishCmd.FullCommand():
-		sync_service.ObjectPublish(*mmsOrg, *mmsUserPw, *mmsObjectPublishType, *mmsObjectPublishId, *mmsObjectPublishPat, *mmsObjectPublishDef, *mmsObjectPublishObj, *mmsObjectPublishNoChunkUpload, *mmsObjectPublishChunkUploadDataSize, *mmsObjectPublishSkipIntegrityCheck, *mmsObjectPublishDSHashAlgo, *mmsObjectPublishDSHash, *mmsObjectPublishPrivKeyFile)
+		sync_service.ObjectPublish(*mmsOrg, *mmsUserPw, *mmsObjectPublishType, *mmsObjectPublishId, *mmsObjectPublishPat, *mmsObjectPublishDef, *mmsObjectPublishObj, *mmsObjectPublishNoChunkUpload, *mmsObjectPublishChunkUploadDataSize, *mmsObjectPublishSkipIntegrityCheck, *mmsObjectPublishDSHashAlgo, *mmsObjectPublishDSHash, *mmsObjectPublishPrivKeyFile, *mmsObjectPublishResume)
 	case mmsObjectDeleteCmd.FullCommand():
 		sync_service.ObjectDelete(*mmsOrg, *mmsUserPw, *mmsObjectDeleteType, *mmsObjectDeleteId)
 	case mmsObjectDownloadCmd.FullCommand():
-		sync_service.ObjectDownLoad(*mmsOrg, *mmsUserPw, *mmsObjectDownloadType, *mmsObjectDownloadId, *mmsObjectDownloadFile, *mmsObjectDownloadOverwrite, *mmsObjectDownloadSkipIntegrityCheck)
+		sync_service.ObjectDownLoad(*mmsOrg, *mmsUserPw, *mmsObjectDownloadType, *mmsObjectDownloadId, *mmsObjectDownloadFile, *mmsObjectDownloadOverwrite, *mmsObjectDownloadSkipIntegrityCheck, *mmsObjectDownloadChunkSize, *mmsObjectDownloadResume)
 	case mmsObjectTypesCmd.FullCommand():
 		sync_service.ObjectTypes(*mmsOrg, *mmsUserPw)
 
@@ -1509,5 +1759,9 @@ Environment Variables:
 		secret_manager.SecretRemove(*smOrg, *smUserPw, *smSecretRemoveName, *smSecretRemoveForce)
 	case smSecretReadCmd.FullCommand():
 		secret_manager.SecretRead(*smOrg, *smUserPw, *smSecretReadName)
+	case smSecretRotateCmd.FullCommand():
+		secret_manager.SecretRotate(*smOrg, *smUserPw, *smSecretRotateName, *smSecretRotateFile, *smSecretRotateKey, *smSecretRotateDetail, *smSecretRotateForce)
+	case smSecretUsageCmd.FullCommand():
+		secret_manager.SecretUsageList(*smOrg, *smUserPw, *smSecretUsageName)
 	}
 }
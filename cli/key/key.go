@@ -1,7 +1,6 @@
 package key
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/open-horizon/anax/api"
 	"github.com/open-horizon/anax/cli/cliutils"
@@ -34,7 +33,7 @@ func List(keyName string, listAll bool) {
 	if keyName == "" && listAll {
 		var apiOutput KeyList
 		cliutils.HorizonGet("trust", []int{200}, &apiOutput, false)
-		jsonBytes, err := json.MarshalIndent(apiOutput.Pem, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(apiOutput.Pem)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'key list' output: %v", err))
 		}
@@ -64,7 +63,7 @@ func List(keyName string, listAll bool) {
 			})
 		}
 
-		jsonBytes, err := json.MarshalIndent(certsSimpleOutput, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(certsSimpleOutput)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'key list' output: %v", err))
 		}
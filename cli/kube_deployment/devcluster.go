@@ -0,0 +1,171 @@
+package kube_deployment
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/cli/dev"
+	"github.com/open-horizon/anax/common"
+	"github.com/open-horizon/anax/container"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/kube_operator"
+)
+
+// Returns the base64 encoded operator archive and the metadata of a cluster deployment config. The operator archive
+// of a project is a file relative to the project, the archive of a service fetched from the exchange is already
+// encoded.
+func getDevClusterDeployment(dir string, cdep interface{}) (string, map[string]interface{}, error) {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	dc, ok := cdep.(map[string]interface{})
+	if s, isString := cdep.(string); isString {
+		ok = json.Unmarshal([]byte(s), &dc) == nil
+	}
+	if !ok {
+		return "", nil, errors.New(msgPrinter.Sprintf("'clusterDeployment' has wrong format."))
+	}
+
+	archive, _ := dc["operatorYamlArchive"].(string)
+	archivePath := archive
+	if !filepath.IsAbs(archivePath) {
+		archivePath = filepath.Join(dir, archivePath)
+	}
+
+	var tar string
+	if _, err := os.Stat(archivePath); err == nil {
+		if tar, err = ConvertFileToB64String(archivePath); err != nil {
+			return "", nil, errors.New(msgPrinter.Sprintf("unable to read kube operator %v, error %v", archive, err))
+		}
+	} else if _, err := base64.StdEncoding.DecodeString(archive); err == nil && archive != "" {
+		tar = archive
+	} else {
+		return "", nil, errors.New(msgPrinter.Sprintf("kube operator %v does not exist", archivePath))
+	}
+
+	metadata, _ := dc["metadata"].(map[string]interface{})
+	return tar, metadata, nil
+}
+
+// Returns the secrets given to 'hzn dev service start', keyed by secret name, the way the agent mounts them into the
+// operator.
+func readDevSecrets(secretsFiles map[string]string) (map[string][]byte, error) {
+	secrets := make(map[string][]byte, len(secretsFiles))
+	for name, secretPath := range secretsFiles {
+		if content, err := ioutil.ReadFile(secretPath); err != nil {
+			return nil, err
+		} else {
+			secrets[name] = content
+		}
+	}
+	return secrets, nil
+}
+
+// Starts the operator of a cluster service in the development cluster, after the operators of its dependencies. All the
+// operators are installed in the same namespace, so that a service reaches the kubernetes services of its dependencies
+// by name. Each operator gets a mock agreement id and the env var config map that the agent would create for it. A
+// dependency required by several services is started once.
+func startDevClusterService(dir string, serviceDef *common.ServiceFile, userInputs *common.UserInputFile, cw *container.ContainerWorker,
+	kc *kube_operator.KubeClient, ct *dev.ClusterTest, secrets map[string][]byte) error {
+
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if ct.Started(serviceDef.URL, serviceDef.Org, serviceDef.Version) {
+		return nil
+	}
+
+	if serviceDef.HasDependencies() {
+		deps, err := dev.GetServiceDependencies(dir, serviceDef.RequiredServices)
+		if err != nil {
+			return errors.New(msgPrinter.Sprintf("unable to retrieve dependency metadata: %v", err))
+		}
+		for _, dep := range deps {
+			if owned, err := new(KubeDeploymentConfigPlugin).Validate(nil, dep.ClusterDeployment); !owned || err != nil {
+				return errors.New(msgPrinter.Sprintf("dependency %v/%v has no %v deployment configuration, only cluster services can be required by a cluster service", dep.Org, dep.URL, KUBE_DEPLOYMENT_CONFIG_TYPE))
+			} else if err := startDevClusterService(dir, dep, userInputs, cw, kc, ct, secrets); err != nil {
+				return err
+			}
+		}
+	}
+
+	tar, metadata, err := getDevClusterDeployment(dir, serviceDef.ClusterDeployment)
+	if err != nil {
+		return err
+	}
+
+	agreementId, err := cutil.GenerateAgreementId()
+	if err != nil {
+		return errors.New(msgPrinter.Sprintf("unable to generate test agreementId, %v", err))
+	}
+
+	envVars, err := dev.ClusterEnvVarMap(agreementId, serviceDef.URL, userInputs.Global, serviceDef.UserInputs, userInputs.Services, serviceDef.Org, cw)
+	if err != nil {
+		return err
+	}
+	cliutils.Verbose(msgPrinter.Sprintf("Passing environment variables: %v", envVars))
+
+	msgPrinter.Printf("Start service %v/%v in namespace %v of cluster %v with agreement id %v", serviceDef.Org, serviceDef.URL, ct.Namespace, ct.Context, agreementId)
+	msgPrinter.Println()
+
+	if err := kc.Install(tar, metadata, envVars, secrets, agreementId, ct.Namespace, kube_operator.NewInstallTimeouts(cw.Config), kube_operator.ImagePolicy{}); err != nil {
+		// Remove whatever part of the operator was installed.
+		kc.Uninstall(tar, metadata, agreementId, ct.Namespace)
+		return errors.New(msgPrinter.Sprintf("unable to install the operator of service %v/%v, error: %v", serviceDef.Org, serviceDef.URL, err))
+	}
+	ct.Services = append(ct.Services, dev.ClusterTestService{URL: serviceDef.URL, Org: serviceDef.Org, Version: serviceDef.Version, AgreementId: agreementId})
+	return nil
+}
+
+// Stops the operators of the cluster services started in the project, the services that require a dependency are
+// stopped before it.
+func stopDevClusterServices(dir string, serviceDef *common.ServiceFile, kc *kube_operator.KubeClient, ct *dev.ClusterTest) error {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	for ix := len(ct.Services) - 1; ix >= 0; ix-- {
+		s := ct.Services[ix]
+
+		sf, err := findDevClusterService(dir, serviceDef, s)
+		if err != nil {
+			return err
+		} else if sf == nil {
+			return errors.New(msgPrinter.Sprintf("service %v/%v is no longer in the project, unable to stop its operator", s.Org, s.URL))
+		}
+		tar, metadata, err := getDevClusterDeployment(dir, sf.ClusterDeployment)
+		if err != nil {
+			return err
+		}
+
+		msgPrinter.Printf("Stop service %v/%v with agreement id %v", s.Org, s.URL, s.AgreementId)
+		msgPrinter.Println()
+		if err := kc.Uninstall(tar, metadata, s.AgreementId, ct.Namespace); err != nil {
+			return errors.New(msgPrinter.Sprintf("unable to uninstall the operator of service %v/%v, error: %v", s.Org, s.URL, err))
+		}
+		ct.Services = ct.Services[:ix]
+	}
+	return nil
+}
+
+// Returns the definition of a started service, the project's service or one of its dependencies at any depth.
+func findDevClusterService(dir string, serviceDef *common.ServiceFile, s dev.ClusterTestService) (*common.ServiceFile, error) {
+	if serviceDef.URL == s.URL && serviceDef.Org == s.Org && serviceDef.Version == s.Version {
+		return serviceDef, nil
+	} else if !serviceDef.HasDependencies() {
+		return nil, nil
+	}
+
+	deps, err := dev.GetServiceDependencies(dir, serviceDef.RequiredServices)
+	if err != nil {
+		return nil, err
+	}
+	for _, dep := range deps {
+		if sf, err := findDevClusterService(dir, dep, s); err != nil || sf != nil {
+			return sf, err
+		}
+	}
+	return nil, nil
+}
@@ -11,7 +11,9 @@ import (
 	"github.com/open-horizon/anax/cli/dev"
 	"github.com/open-horizon/anax/cli/plugin_registry"
 	"github.com/open-horizon/anax/common"
+	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/kube_operator"
 	"github.com/open-horizon/rsapss-tool/sign"
 	"io/ioutil"
 	"os"
@@ -60,11 +62,29 @@ func (p *KubeDeploymentConfigPlugin) Sign(dep map[string]interface{}, privKey *r
 	}
 	dep["operatorYamlArchive"] = b64
 
-	if _, ok := dep["metadata"]; ok {
-		return true, "", "", errors.New(msgPrinter.Sprintf("'metadata' in 'clusterDeployment' should not be set. Remove 'metadata' inside 'clusterDeployment' before publishing service"))
+	// The only user settable attributes in the metadata are the kubernetes versions of the operator, the replica policy
+	// for the operator deployment and the labels and annotations of the operator namespace.
+	md := make(map[string]interface{}, 0)
+	if mdInterf, ok := dep["metadata"]; ok {
+		if userMd, ok := mdInterf.(map[string]interface{}); !ok {
+			return true, "", "", errors.New(msgPrinter.Sprintf("'metadata' in 'clusterDeployment' has wrong format."))
+		} else {
+			for key, value := range userMd {
+				if key != kube_operator.KUBE_VERSION_METADATA_KEY && key != kube_operator.REPLICA_POLICY_KEY && key != kube_operator.NAMESPACE_METADATA_KEY {
+					return true, "", "", errors.New(msgPrinter.Sprintf("'%v' in 'metadata' in 'clusterDeployment' should not be set. Only '%v', '%v' and '%v' are allowed inside 'metadata' when publishing service", key, kube_operator.KUBE_VERSION_METADATA_KEY, kube_operator.REPLICA_POLICY_KEY, kube_operator.NAMESPACE_METADATA_KEY))
+				}
+				md[key] = value
+			}
+			if _, err := kube_operator.GetKubeVersionRange(md); err != nil {
+				return true, "", "", err
+			} else if _, err := kube_operator.GetReplicaPolicy(md); err != nil {
+				return true, "", "", err
+			} else if _, err := kube_operator.GetNamespaceMetadata(md); err != nil {
+				return true, "", "", err
+			}
+		}
 	}
 
-	md := make(map[string]interface{}, 0)
 	namespaceInOperator, err := common.GetKubeOperatorNamespace(b64)
 	if err != nil {
 		return true, "", "", errors.New(msgPrinter.Sprintf("failed to get namespace from kube operator %v, error %v", operatorFilePath, err))
@@ -134,6 +154,8 @@ func (p *KubeDeploymentConfigPlugin) Validate(dep interface{}, cdep interface{})
 	}
 }
 
+// Start the cluster deployment config in test mode. The operator of the service, and the operators of its dependencies,
+// are installed in a local kind or k3d cluster.
 func (p *KubeDeploymentConfigPlugin) StartTest(homeDirectory string, userInputFile string, configFiles []string, configType string, noFSS bool, userCreds string, secretsFiles map[string]string) bool {
 
 	// get message printer
@@ -143,7 +165,7 @@ func (p *KubeDeploymentConfigPlugin) StartTest(homeDirectory string, userInputFi
 	dev.ServiceValidate(homeDirectory, userInputFile, configFiles, configType, userCreds)
 
 	// Perform the common execution setup.
-	dir, _, _ := dev.CommonExecutionSetup(homeDirectory, userInputFile, dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND)
+	dir, userInputs, cw := dev.CommonExecutionSetup(homeDirectory, userInputFile, dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND)
 
 	// Get the service definition, so that we can look at the user input variable definitions.
 	serviceDef, sderr := dev.GetServiceDefinition(dir, dev.SERVICE_DEFINITION_FILE)
@@ -154,30 +176,77 @@ func (p *KubeDeploymentConfigPlugin) StartTest(homeDirectory string, userInputFi
 	// Now that we have the service def, we can check if we own the deployment config object.
 	// If there is a deployment config that we dont own, then return false, we dont own this service def.
 	// This allows another plugin to claim ownership of the service def and start a test.
-	// Otherwise, if the cluster config is ours, then we own the service but since this plugin doesnt
-	// support start and stop, terminate with a fatal error.
 	if serviceDef.Deployment != nil {
 		return false
-	} else {
-		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' not supported for services using a %v deployment configuration", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, KUBE_DEPLOYMENT_CONFIG_TYPE))
+	} else if owned, err := p.Validate(serviceDef.Deployment, serviceDef.ClusterDeployment); !owned || err != nil {
+		return false
+	}
+
+	if ct, err := dev.GetClusterTest(dir); err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "'%v %v' %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, err)
+	} else if ct != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' the service is already started in cluster %v, run 'hzn dev service stop' first", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, ct.Context))
+	}
+	if len(configFiles) != 0 {
+		msgPrinter.Printf("Warning: the file sync service is not started for a %v deployment configuration, the config files are ignored.", KUBE_DEPLOYMENT_CONFIG_TYPE)
+		msgPrinter.Println()
 	}
 
-	// For the compiler
+	kc, kubeContext, err := dev.NewDevKubeClient()
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "'%v %v' %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, err)
+	}
+	secrets, err := readDevSecrets(secretsFiles)
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' unable to read the secrets, %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, err))
+	}
+
+	// The service and its dependencies are installed in the namespace of the service operator, or in the agent's
+	// namespace like on a real cluster.
+	tar, metadata, err := getDevClusterDeployment(dir, serviceDef.ClusterDeployment)
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "'%v %v' %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, err)
+	}
+	_, namespace, err := kube_operator.ProcessDeployment(tar, metadata, map[string]string{}, "", 0)
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "'%v %v' %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, err)
+	} else if namespace == "" {
+		namespace = cutil.GetClusterNamespace()
+	}
+	if err := dev.EnsureDevNamespace(kc, namespace); err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' unable to create namespace %v, %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, namespace, err))
+	}
+
+	ct := &dev.ClusterTest{Context: kubeContext, Namespace: namespace}
+	startErr := startDevClusterService(dir, serviceDef, userInputs, cw, kc, ct, secrets)
+	if startErr != nil {
+		// Stop the dependencies that are already started.
+		if err := stopDevClusterServices(dir, serviceDef, kc, ct); err != nil {
+			msgPrinter.Printf("Warning: %v", err)
+			msgPrinter.Println()
+		}
+	}
+	if err := dev.SaveClusterTest(dir, ct); err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "'%v %v' %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, err)
+	} else if startErr != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "'%v %v' %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, startErr)
+	}
+
+	msgPrinter.Printf("Running service.")
+	msgPrinter.Println()
 	return true
 }
 
+// Stop the cluster deployment config in test mode, the operators started by 'hzn dev service start' are uninstalled.
 func (p *KubeDeploymentConfigPlugin) StopTest(homeDirectory string) bool {
 
-	// get message printer
-	msgPrinter := i18n.GetMessagePrinter()
-
 	// Perform the common execution setup.
-	dir, _, _ := dev.CommonExecutionSetup(homeDirectory, "", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND)
+	dir, _, _ := dev.CommonExecutionSetup(homeDirectory, "", dev.SERVICE_COMMAND, dev.SERVICE_STOP_COMMAND)
 
 	// Get the service definition, so that we can look at the user input variable definitions.
 	serviceDef, sderr := dev.GetServiceDefinition(dir, dev.SERVICE_DEFINITION_FILE)
 	if sderr != nil {
-		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, fmt.Sprintf("'%v %v' %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, sderr))
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, fmt.Sprintf("'%v %v' %v", dev.SERVICE_COMMAND, dev.SERVICE_STOP_COMMAND, sderr))
 	}
 
 	// Now that we have the service def, we can check if we own the deployment config object.
@@ -187,8 +256,30 @@ func (p *KubeDeploymentConfigPlugin) StopTest(homeDirectory string) bool {
 		return false
 	}
 
-	cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' not supported for services using a %v deployment configuration", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, KUBE_DEPLOYMENT_CONFIG_TYPE))
-	// For the compiler
+	ct, err := dev.GetClusterTest(dir)
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "'%v %v' %v", dev.SERVICE_COMMAND, dev.SERVICE_STOP_COMMAND, err)
+	} else if ct == nil {
+		i18n.GetMessagePrinter().Printf("The service is not started.")
+		i18n.GetMessagePrinter().Println()
+		return true
+	}
+
+	// The operators are uninstalled from the cluster they were started in, even if the current context has changed.
+	if os.Getenv(dev.DEVTOOL_HZN_KUBE_CONTEXT) == "" {
+		os.Setenv(dev.DEVTOOL_HZN_KUBE_CONTEXT, ct.Context)
+	}
+	kc, _, err := dev.NewDevKubeClient()
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "'%v %v' %v", dev.SERVICE_COMMAND, dev.SERVICE_STOP_COMMAND, err)
+	}
+
+	stopErr := stopDevClusterServices(dir, serviceDef, kc, ct)
+	if err := dev.SaveClusterTest(dir, ct); err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "'%v %v' %v", dev.SERVICE_COMMAND, dev.SERVICE_STOP_COMMAND, err)
+	} else if stopErr != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "'%v %v' %v", dev.SERVICE_COMMAND, dev.SERVICE_STOP_COMMAND, stopErr)
+	}
 	return true
 }
 
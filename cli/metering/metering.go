@@ -1,7 +1,6 @@
 package metering
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/open-horizon/anax/cli/cliutils"
 	"github.com/open-horizon/anax/i18n"
@@ -114,7 +113,7 @@ func List(archivedMetering bool) {
 		for i := range apiAgreements {
 			metering[i].CopyAgreementInto(apiAgreements[i])
 		}
-		jsonBytes, err := json.MarshalIndent(metering, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(metering)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn metering list' output: %v", err))
 		}
@@ -124,7 +123,7 @@ func List(archivedMetering bool) {
 		for i := range apiAgreements {
 			metering[i].CopyAgreementInto(apiAgreements[i])
 		}
-		jsonBytes, err := json.MarshalIndent(metering, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(metering)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn metering list' output: %v", err))
 		}
@@ -174,7 +174,7 @@ func (p *NativeDeploymentConfigPlugin) Validate(dep interface{}, cdep interface{
 }
 
 // This can't be a const because a map literal isn't a const in go
-var VALID_DEPLOYMENT_FIELDS = map[string]int8{"image": 1, "privileged": 1, "cap_add": 1, "environment": 1, "devices": 1, "binds": 1, "specific_ports": 1, "command": 1, "ports": 1, "ephemeral_ports": 1, "tmpfs": 1, "network": 1, "entrypoint": 1, "max_memory_mb": 1, "max_cpus": 1, "log_driver": 1, "secrets": 1, "pid": 1, "user": 1, "sysctls": 1}
+var VALID_DEPLOYMENT_FIELDS = map[string]int8{"image": 1, "privileged": 1, "cap_add": 1, "environment": 1, "devices": 1, "binds": 1, "specific_ports": 1, "command": 1, "ports": 1, "ephemeral_ports": 1, "tmpfs": 1, "network": 1, "entrypoint": 1, "max_memory_mb": 1, "max_cpus": 1, "log_driver": 1, "secrets": 1, "pid": 1, "user": 1, "sysctls": 1, "depends_on": 1, "gpus": 1, "log_options": 1, "restart_policy": 1, "volumes": 1, "security_opts": 1, "max_egress_kbps": 1, "checkpoint": 1}
 
 // CheckDeploymentService verifies it has the required 'image' key, and checks for keys we don't recognize.
 // For now it only prints a warning for unrecognized keys, in case we recently added a key to anax and haven't updated hzn yet.
@@ -211,6 +211,83 @@ func CheckDeploymentService(svcName string, depSvc map[string]interface{}) error
 				}
 			}
 		}
+
+		// Check the log rotation options, the log driver is the default one when it is not set.
+		if k == "log_options" {
+			var logOptions map[string]string
+			logDriver, _ := depSvc["log_driver"].(string)
+			if bytes, err := json.Marshal(depSvc[k]); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has a malformed log_options value %v, error %v", svcName, depSvc[k], err))
+			} else if err := json.Unmarshal(bytes, &logOptions); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has a malformed log_options value %v, error %v", svcName, string(bytes), err))
+			} else if err := containermessage.ValidateLogOptions(logDriver, logOptions); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has an invalid log_options value, error %v", svcName, err))
+			}
+		}
+
+		// Check that the gpus can be requested from the container engine.
+		if k == "gpus" {
+			var gpus containermessage.GPUs
+			if bytes, err := json.Marshal(depSvc[k]); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has a malformed gpus value %v, error %v", svcName, depSvc[k], err))
+			} else if err := json.Unmarshal(bytes, &gpus); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has a malformed gpus value %v, error %v", svcName, string(bytes), err))
+			} else if _, err := gpus.DeviceRequest(); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has an invalid gpus value, error %v", svcName, err))
+			}
+		}
+
+		// Check that the restart policy is supported by the container engine.
+		if k == "restart_policy" {
+			var restartPolicy containermessage.RestartPolicy
+			if bytes, err := json.Marshal(depSvc[k]); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has a malformed restart_policy value %v, error %v", svcName, depSvc[k], err))
+			} else if err := json.Unmarshal(bytes, &restartPolicy); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has a malformed restart_policy value %v, error %v", svcName, string(bytes), err))
+			} else if err := restartPolicy.Validate(); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has an invalid restart_policy value, error %v", svcName, err))
+			}
+		}
+
+		// Check the names, targets and retention policies of the named volumes.
+		if k == "volumes" {
+			var volumes []containermessage.Volume
+			if bytes, err := json.Marshal(depSvc[k]); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has a malformed volumes value %v, error %v", svcName, depSvc[k], err))
+			} else if err := json.Unmarshal(bytes, &volumes); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has a malformed volumes value %v, error %v", svcName, string(bytes), err))
+			} else {
+				for _, v := range volumes {
+					if err := v.Validate(); err != nil {
+						return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has an invalid volumes value, error %v", svcName, err))
+					}
+				}
+			}
+		}
+
+		// Check the stop timeout and the command run before the container is stopped.
+		if k == "stop_timeout" || k == "pre_stop" {
+			var stop containermessage.Service
+			if bytes, err := json.Marshal(map[string]interface{}{k: depSvc[k]}); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has a malformed %v value %v, error %v", svcName, k, depSvc[k], err))
+			} else if err := json.Unmarshal(bytes, &stop); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has a malformed %v value %v, error %v", svcName, k, string(bytes), err))
+			} else if err := stop.ValidateStop(); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has an invalid %v value, error %v", svcName, k, err))
+			}
+		}
+
+		// Check the profiles and capabilities of the security options.
+		if k == "security_opts" {
+			var securityOpts containermessage.SecurityOpts
+			if bytes, err := json.Marshal(depSvc[k]); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has a malformed security_opts value %v, error %v", svcName, depSvc[k], err))
+			} else if err := json.Unmarshal(bytes, &securityOpts); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has a malformed security_opts value %v, error %v", svcName, string(bytes), err))
+			} else if err := securityOpts.Validate(); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has an invalid security_opts value, error %v", svcName, err))
+			}
+		}
 	}
 	return nil
 }
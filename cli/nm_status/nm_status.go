@@ -36,13 +36,13 @@ func List(nmpName string, long bool) {
 	var output string
 	var err error
 	if long {
-		output, err = cliutils.DisplayAsJson(managementStatuses)
+		output, err = cliutils.FormatOutput(managementStatuses)
 	} else {
 		nmpStatusNames := make(map[string]string, 0)
 		for nmpStatusName, nmpStatus := range *managementStatuses {
 			nmpStatusNames[nmpStatusName] = nmpStatus.Status()
 		}
-		output, err = cliutils.DisplayAsJson(nmpStatusNames)
+		output, err = cliutils.FormatOutput(nmpStatusNames)
 	}
 
 	if err != nil {
@@ -1,7 +1,6 @@
 package node
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/open-horizon/anax/api"
 	"github.com/open-horizon/anax/apicommon"
@@ -9,6 +8,7 @@ import (
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/version"
+	"net/http"
 	"strings"
 )
 
@@ -82,7 +82,7 @@ func List() {
 	nodeInfo.CopyStatusInto(&status)
 
 	// Output the combined info
-	jsonBytes, err := json.MarshalIndent(nodeInfo, "", cliutils.JSON_INDENT)
+	jsonBytes, err := cliutils.MarshalOutput(nodeInfo)
 	if err != nil {
 		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn node list' output: %v", err))
 	}
@@ -139,3 +139,31 @@ func Env(org, userPw, exchUrl, cssUrl, agbotUrl string) {
 	msgPrinter.Printf("HZN_AGBOT_URL: %s", agbotUrl)
 	msgPrinter.Println()
 }
+
+func MaintenanceOn(drain bool) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	enabled := true
+	apiInput := api.NodeMaintenance{Enabled: &enabled, Drain: &drain}
+	cliutils.HorizonPutPost(http.MethodPut, "node/maintenance", []int{200}, apiInput, false)
+
+	if drain {
+		msgPrinter.Printf("The node is in maintenance mode, new agreements are not accepted and the existing agreements are being cancelled. Please use 'hzn agreement list' to make sure all the agreements are cancelled. It may take a couple of minutes.")
+	} else {
+		msgPrinter.Printf("The node is in maintenance mode, new agreements are not accepted. The services that are running are kept.")
+	}
+	msgPrinter.Println()
+}
+
+func MaintenanceOff() {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	enabled := false
+	apiInput := api.NodeMaintenance{Enabled: &enabled}
+	cliutils.HorizonPutPost(http.MethodPut, "node/maintenance", []int{200}, apiInput, false)
+
+	msgPrinter.Printf("The node is out of maintenance mode, new agreements are accepted.")
+	msgPrinter.Println()
+}
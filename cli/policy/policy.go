@@ -9,6 +9,7 @@ import (
 	"github.com/open-horizon/anax/externalpolicy"
 	"github.com/open-horizon/anax/i18n"
 	"net/http"
+	"os"
 )
 
 func List() {
@@ -17,7 +18,7 @@ func List() {
 	cliutils.HorizonGet("node/policy", []int{200}, &nodePolicy, false)
 
 	// Output the combined info
-	output, err := cliutils.DisplayAsJson(nodePolicy)
+	output, err := cliutils.FormatOutput(nodePolicy)
 	if err != nil {
 		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal 'hzn policy list' output: %v", err))
 	}
@@ -91,11 +92,22 @@ func Remove(force bool) {
 	msgPrinter.Println()
 }
 
-// Display an empty policy template as an object.
-func New() {
+// Display an empty policy template as an object, or build the policy with prompts when interactive is true.
+func New(interactive bool) {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
 
+	if interactive {
+		// the prompts go to stderr so that the policy can be redirected to a file
+		pol := BuildInteractive(os.Stdin, os.Stderr)
+		output, err := cliutils.FormatOutput(pol)
+		if err != nil {
+			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn policy new' output: %v", err))
+		}
+		fmt.Println(output)
+		return
+	}
+
 	var policy_template = []string{
 		`{`,
 		`  "properties": [      /* ` + msgPrinter.Sprintf("A list of policy properties that describe the object.") + ` */`,
@@ -0,0 +1,193 @@
+package policy
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/open-horizon/anax/cli/cliconfig"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/i18n"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Validate checks the syntax of the properties and constraints in a policy file, and when a sample properties file
+// is given, evaluates the constraints against those properties without contacting the exchange.
+func Validate(policyFile string, propsFile string) {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	pol := new(exchangecommon.NodePolicy)
+	readInputFile(policyFile, pol)
+
+	sections := []struct {
+		name string
+		pol  externalpolicy.ExternalPolicy
+	}{{"", pol.ExternalPolicy}, {"deployment", pol.Deployment}, {"management", pol.Management}}
+
+	valid := true
+	for _, section := range sections {
+		prefix := ""
+		if section.name != "" {
+			prefix = section.name + "."
+		}
+		if err := section.pol.Properties.Validate(); err != nil {
+			msgPrinter.Printf("%vproperties: %v", prefix, err)
+			msgPrinter.Println()
+			valid = false
+		}
+		for _, constraint := range section.pol.Constraints {
+			ce := externalpolicy.ConstraintExpression{constraint}
+			if err := ce.CheckSyntax(); err != nil {
+				msgPrinter.Printf("%vconstraints:", prefix)
+				msgPrinter.Println()
+				fmt.Println(FormatSyntaxError(err))
+				valid = false
+			}
+		}
+	}
+	if !valid {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("the policy in %v is not valid.", policyFile))
+	}
+	msgPrinter.Printf("The policy in %v is valid.", policyFile)
+	msgPrinter.Println()
+
+	if propsFile == "" {
+		return
+	}
+	props := readSampleProperties(propsFile)
+	if pol.IsDeploymentEmpty() && pol.IsManagementEmpty() {
+		EvaluateConstraints(pol.Constraints, props, os.Stdout)
+	} else {
+		msgPrinter.Printf("deployment:")
+		msgPrinter.Println()
+		EvaluateConstraints(pol.GetDeploymentPolicy().Constraints, props, os.Stdout)
+		msgPrinter.Printf("management:")
+		msgPrinter.Println()
+		EvaluateConstraints(pol.GetManagementPolicy().Constraints, props, os.Stdout)
+	}
+}
+
+// The sample properties are either a list of properties or a policy, in which case its deployment properties are used.
+func readSampleProperties(filePath string) externalpolicy.PropertyList {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	newBytes := cliconfig.ReadJsonFileWithLocalConfig(filePath)
+	props := externalpolicy.PropertyList{}
+	if err := json.Unmarshal(newBytes, &props); err != nil {
+		pol := exchangecommon.NodePolicy{}
+		if err := json.Unmarshal(newBytes, &pol); err != nil {
+			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to unmarshal json input file %s: %v", filePath, err))
+		}
+		props = pol.GetDeploymentPolicy().Properties
+	}
+	if err := props.Validate(); err != nil {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("the sample properties in %v are not valid: %v", filePath, err))
+	}
+	return props
+}
+
+// EvaluateConstraints writes whether each constraint is satisfied by the properties, and why not.
+func EvaluateConstraints(constraints externalpolicy.ConstraintExpression, props externalpolicy.PropertyList, out io.Writer) {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if len(constraints) == 0 {
+		fmt.Fprintln(out, msgPrinter.Sprintf("  There are no constraints, any properties satisfy them."))
+		return
+	}
+	for _, constraint := range constraints {
+		ce := externalpolicy.ConstraintExpression{constraint}
+		if err := ce.IsSatisfiedBy(props); err != nil {
+			fmt.Fprintln(out, msgPrinter.Sprintf("  not satisfied: %v (%v)", constraint, err))
+		} else {
+			fmt.Fprintln(out, msgPrinter.Sprintf("  satisfied: %v", constraint))
+		}
+	}
+}
+
+// FormatSyntaxError shows the constraint with a marker under the position of a syntax error.
+func FormatSyntaxError(err error) string {
+	serr, ok := err.(*externalpolicy.ConstraintSyntaxError)
+	if !ok {
+		return "  " + err.Error()
+	}
+	return fmt.Sprintf("  %v\n  %v^\n  %v", serr.Constraint, strings.Repeat(" ", serr.Position-1), i18n.GetMessagePrinter().Sprintf("position %v: %v", serr.Position, serr.Err))
+}
+
+// ParseProperty parses a property given as name=value. The value is a boolean, a number, or a string that can be quoted.
+func ParseProperty(input string) (*externalpolicy.Property, error) {
+	parts := strings.SplitN(input, "=", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return nil, fmt.Errorf(i18n.GetMessagePrinter().Sprintf("the property %v must be in the form <name>=<value>", input))
+	}
+
+	name := strings.TrimSpace(parts[0])
+	value := strings.TrimSpace(parts[1])
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return externalpolicy.Property_Factory(name, unquoted), nil
+	} else if value == "true" || value == "false" {
+		return externalpolicy.Property_Factory(name, value == "true"), nil
+	} else if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return externalpolicy.Property_Factory(name, f), nil
+	}
+	return externalpolicy.Property_Factory(name, value), nil
+}
+
+// BuildInteractive prompts for the properties and the constraints of a policy on out and reads them from in. Each
+// constraint is checked as it is entered so that a syntax error can be fixed right away. The constraints can then be
+// tried against sample properties before the policy is returned.
+func BuildInteractive(in io.Reader, out io.Writer) externalpolicy.ExternalPolicy {
+	msgPrinter := i18n.GetMessagePrinter()
+	scanner := bufio.NewScanner(in)
+
+	pol := externalpolicy.ExternalPolicy{Constraints: externalpolicy.ConstraintExpression{}}
+
+	fmt.Fprintln(out, msgPrinter.Sprintf("Enter the properties, one per line in the form <name>=<value>. Enter an empty line when done."))
+	pol.Properties = readProperties(scanner, out)
+
+	fmt.Fprintln(out, msgPrinter.Sprintf("Enter the constraints, one per line, for example: purpose == location && version in [1.0.0,2.0.0). Enter an empty line when done."))
+	for {
+		fmt.Fprint(out, "constraint> ")
+		if !scanner.Scan() || strings.TrimSpace(scanner.Text()) == "" {
+			break
+		}
+		ce := externalpolicy.ConstraintExpression{strings.TrimSpace(scanner.Text())}
+		if err := ce.CheckSyntax(); err != nil {
+			fmt.Fprintln(out, FormatSyntaxError(err))
+			fmt.Fprintln(out, msgPrinter.Sprintf("The constraint is not added, enter it again."))
+			continue
+		}
+		pol.Constraints = append(pol.Constraints, ce...)
+	}
+
+	if len(pol.Constraints) != 0 {
+		fmt.Fprintln(out, msgPrinter.Sprintf("Enter sample properties to evaluate the constraints against, in the form <name>=<value>. Enter an empty line to skip."))
+		if props := readProperties(scanner, out); len(props) != 0 {
+			EvaluateConstraints(pol.Constraints, props, out)
+		}
+	}
+	return pol
+}
+
+// Reads properties until an empty line, asking again for the ones that are not valid.
+func readProperties(scanner *bufio.Scanner, out io.Writer) externalpolicy.PropertyList {
+	props := externalpolicy.PropertyList{}
+	for {
+		fmt.Fprint(out, "property> ")
+		if !scanner.Scan() || strings.TrimSpace(scanner.Text()) == "" {
+			return props
+		}
+		prop, err := ParseProperty(scanner.Text())
+		if err == nil {
+			err = (&externalpolicy.PropertyList{*prop}).Validate()
+		}
+		if err != nil {
+			fmt.Fprintln(out, "  "+err.Error())
+			continue
+		}
+		props.Add_Property(prop, true)
+	}
+}
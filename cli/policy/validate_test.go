@@ -0,0 +1,62 @@
+//go:build unit
+// +build unit
+
+package policy
+
+import (
+	"bytes"
+	_ "github.com/open-horizon/anax/externalpolicy/text_language"
+	"strings"
+	"testing"
+)
+
+func Test_ParseProperty(t *testing.T) {
+	tests := map[string]interface{}{
+		"purpose=location":    "location",
+		" ready = true ":      true,
+		"size=3.5":            3.5,
+		"label=\"true\"":      "true",
+		"version=1.2.3":       "1.2.3",
+		"name=\"a b == c\"":   "a b == c",
+		"expr=a==b":           "a==b",
+		"empty=\"\"":          "",
+		"quoted=\"no closing": "\"no closing",
+	}
+	for input, value := range tests {
+		if prop, err := ParseProperty(input); err != nil {
+			t.Errorf("unable to parse property %v, error: %v", input, err)
+		} else if prop.Value != value {
+			t.Errorf("wrong value %v (%T) for property %v, expected %v", prop.Value, prop.Value, input, value)
+		}
+	}
+
+	if _, err := ParseProperty("noequal"); err == nil {
+		t.Errorf("a property without a value should not parse")
+	}
+	if _, err := ParseProperty("=value"); err == nil {
+		t.Errorf("a property without a name should not parse")
+	}
+}
+
+func Test_BuildInteractive(t *testing.T) {
+	input := strings.Join([]string{
+		"purpose=location", "bad", "size=4", "",
+		"size > 3 && purpose ==", "size > 3 && purpose == location", "",
+		"size=5", "purpose=camera", "",
+	}, "\n")
+	var out bytes.Buffer
+	pol := BuildInteractive(strings.NewReader(input), &out)
+
+	if len(pol.Properties) != 2 || !pol.Properties.HasProperty("purpose") || !pol.Properties.HasProperty("size") {
+		t.Errorf("wrong properties %v", pol.Properties)
+	}
+	if len(pol.Constraints) != 1 || pol.Constraints[0] != "size > 3 && purpose == location" {
+		t.Errorf("wrong constraints %v", pol.Constraints)
+	}
+	if !strings.Contains(out.String(), "size > 3 && purpose ==\n              ^\n") {
+		t.Errorf("the syntax error should be marked at its position, output:\n%v", out.String())
+	}
+	if !strings.Contains(out.String(), "not satisfied: size > 3 && purpose == location") {
+		t.Errorf("the constraint should be evaluated against the sample properties, output:\n%v", out.String())
+	}
+}
@@ -0,0 +1,175 @@
+package register
+
+import (
+	"encoding/json"
+	dockerclient "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/cli/cliutils"
+	cliexchange "github.com/open-horizon/anax/cli/exchange"
+	"github.com/open-horizon/anax/common"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/version"
+	"strings"
+	"time"
+)
+
+// How long to wait between the checks of the exchange when a node registers from a bundle before it can reach it.
+const BUNDLE_EXCHANGE_WAIT_INTERVAL_S = 30
+
+// Returns the node id and token of the bundle. A node id that is also given with -n or HZN_EXCHANGE_NODE_AUTH must be
+// the node id of the bundle.
+func bundleNodeIdTok(bundle *cliexchange.NodeBundle, nodeIdTok string) string {
+	if nodeIdTok != "" {
+		id, _ := cliutils.SplitIdToken(nodeIdTok)
+		if _, id = cliutils.TrimOrg(bundle.Org, id); id != "" && id != bundle.Id {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, i18n.GetMessagePrinter().Sprintf("The node id %v from -n or HZN_EXCHANGE_NODE_AUTH is not the node id %v of the bundle.", id, bundle.Id))
+		}
+	}
+	return bundle.Id + ":" + bundle.Token
+}
+
+// Returns the user input of the bundle, nil if it has none.
+func bundleUserInput(bundle *cliexchange.NodeBundle, bundleFile string) *common.UserInputFile {
+	if bundle.UserInput == nil {
+		return nil
+	}
+	uif, err := common.NewUserInputFileFromJsonBytes(bundle.UserInput)
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, i18n.GetMessagePrinter().Sprintf("Unable to create UserInputFile object from the user input of bundle %s. %v", bundleFile, err))
+	}
+	return uif
+}
+
+// Reads the node policy of the bundle into nodePol, returns false if the bundle has none.
+func bundleNodePolicy(bundle *cliexchange.NodeBundle, bundleFile string, nodePol *exchangecommon.NodePolicy) bool {
+	if bundle.NodePolicy == nil {
+		return false
+	}
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if err := json.Unmarshal(bundle.NodePolicy, nodePol); err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to unmarshal the node policy of bundle %s: %v", bundleFile, err))
+	} else if err := nodePol.ValidateAndNormalize(); err != nil {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Incorrect node policy format in bundle %s: %v", bundleFile, err))
+	}
+	return true
+}
+
+// Pulls the images of the bundle that are not already on the node. A failed pull is not fatal, the agent pulls the image
+// again when the service is started.
+func pullBundleImages(images []string) {
+	if len(images) == 0 {
+		return
+	}
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	client := cliutils.NewDockerClient()
+	for _, image := range images {
+		if _, err := client.InspectImage(image); err == nil {
+			msgPrinter.Printf("Image %v is already on the node.", image)
+			msgPrinter.Println()
+			continue
+		}
+
+		domain, path, tag, digest := cutil.ParseDockerImagePath(image)
+		if path == "" {
+			cliutils.Warning(msgPrinter.Sprintf("Invalid image name %v in the bundle, it is not pulled.", image))
+			continue
+		}
+		opts := dockerclient.PullImageOptions{Repository: image}
+		if digest == "" {
+			opts.Repository = path
+			if domain != "" {
+				opts.Repository = domain + "/" + path
+			}
+			if tag == "" {
+				tag = "latest"
+			}
+			opts.Tag = tag
+		}
+
+		auth, err := cliutils.GetDockerAuth(domain)
+		if err != nil {
+			cliutils.Verbose(msgPrinter.Sprintf("unable to get docker auth for docker.io or %s domain: %v", domain, err))
+		}
+
+		msgPrinter.Printf("Pulling image %v...", image)
+		msgPrinter.Println()
+		if err := client.PullImage(opts, auth); err != nil {
+			cliutils.Warning(msgPrinter.Sprintf("unable to pull image %v, it will be pulled when the service is started: %v", image, err))
+		}
+	}
+}
+
+// Waits until the exchange can be reached, so that a node with a bundle can be registered before it has a network
+// connection. Any http response means that the exchange can be reached, the version of the exchange is then checked.
+func waitForExchange(exchUrlBase string, org string, nodeIdTok string) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	httpClient := cliutils.GetHTTPClient(config.HTTPRequestTimeoutS)
+	if err := cliutils.TrustIcpCert(httpClient); err != nil {
+		cliutils.Fatal(cliutils.FILE_IO_ERROR, err.Error())
+	}
+
+	url := strings.TrimSuffix(exchUrlBase, "/") + "/admin/version"
+	waiting := false
+	for {
+		resp, err := httpClient.Get(url)
+		if resp != nil && resp.Body != nil {
+			resp.Body.Close()
+		}
+		if err == nil {
+			break
+		}
+		if !waiting {
+			msgPrinter.Printf("The Horizon Exchange cannot be reached, the registration will continue when it can. Press Ctrl-C to stop waiting.")
+			msgPrinter.Println()
+			waiting = true
+		}
+		cliutils.Verbose(msgPrinter.Sprintf("Unable to reach the Horizon Exchange at %v: %v", url, err))
+		time.Sleep(BUNDLE_EXCHANGE_WAIT_INTERVAL_S * time.Second)
+	}
+	if waiting {
+		msgPrinter.Printf("The Horizon Exchange can be reached, continuing the registration.")
+		msgPrinter.Println()
+	}
+
+	if exVersion := cliexchange.LoadExchangeVersion(false, org, nodeIdTok); exVersion != "" {
+		if err := version.VerifyExchangeVersion1(exVersion, false); err != nil {
+			cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, err.Error())
+		}
+	}
+}
+
+// Prepares the node for the registration with the bundle: the images of the bundle are pulled, then the registration
+// waits for the exchange.
+func prepareBundleRegistration(bundle *cliexchange.NodeBundle, bundleFile string, exchUrlBase string, anaxArch string, nodeType string, org string, nodeIdTok string) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if bundle.ExchangeUrl != "" && strings.TrimSuffix(bundle.ExchangeUrl, "/") != strings.TrimSuffix(exchUrlBase, "/") {
+		cliutils.Warning(msgPrinter.Sprintf("bundle %v was created with the Horizon Exchange %v, the node is registered with %v.", bundleFile, bundle.ExchangeUrl, exchUrlBase))
+	}
+	if bundle.Arch != "" && bundle.Arch != anaxArch {
+		cliutils.Warning(msgPrinter.Sprintf("bundle %v was created for architecture %v, the architecture of the node is %v.", bundleFile, bundle.Arch, anaxArch))
+	}
+	if bundle.NodeType != "" && bundle.NodeType != nodeType {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Node type mismatch. The node type '%v' does not match the node type '%v' of bundle %v.", nodeType, bundle.NodeType, bundleFile))
+	}
+
+	// the images of a cluster are pulled by the cluster, not by the agent
+	if nodeType != persistence.DEVICE_TYPE_CLUSTER {
+		pullBundleImages(bundle.Images)
+	}
+
+	waitForExchange(exchUrlBase, org, nodeIdTok)
+	cliutils.Verbose(msgPrinter.Sprintf("Registering node %v/%v from bundle %v created %v", bundle.Org, bundle.Id, bundleFile, bundle.Created))
+}
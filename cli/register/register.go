@@ -3,6 +3,7 @@ package register
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/open-horizon/anax/agreementbot"
 	"github.com/open-horizon/anax/api"
 	"github.com/open-horizon/anax/apicommon"
 	"github.com/open-horizon/anax/cli/cliconfig"
@@ -68,12 +69,42 @@ func ReadAndVerifyPolicFile(jsonFilePath string, nodePol *exchangecommon.NodePol
 }
 
 // DoIt registers this node to Horizon with a pattern
-func DoIt(org, pattern, nodeIdTok, userPw, inputFile string, nodeOrgFromFlag string, patternFromFlag string, nodeName string, haGroupName string, nodepolicyFlag string, waitService string, waitOrg string, waitTimeout int) {
+func DoIt(org, pattern, nodeIdTok, userPw, inputFile string, nodeOrgFromFlag string, patternFromFlag string, nodeName string, haGroupName string, nodepolicyFlag string, joinToken string, bundleFile string, waitService string, waitOrg string, waitTimeout int) {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
 
+	// the join token has the org of the node
+	var joinTok *cliexchange.JoinTokenString
+	if joinToken != "" {
+		var err error
+		if joinTok, err = cliexchange.DecodeJoinTokenString(joinToken); err != nil {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Invalid --token: %v", err))
+		}
+		if org == "" && nodeOrgFromFlag == "" {
+			nodeOrgFromFlag = joinTok.Org
+		}
+	}
+
+	// the bundle has the identity and the pattern of the node
+	var bundle *cliexchange.NodeBundle
+	if bundleFile != "" {
+		if joinToken != "" || org != "" || pattern != "" || nodeOrgFromFlag != "" || patternFromFlag != "" {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("--from-bundle is mutually exclusive with -o, -p, --token and the <nodeorg> and <pattern> arguments."))
+		}
+		bundle = cliexchange.ReadNodeBundleFile(bundleFile)
+		nodeIdTok = bundleNodeIdTok(bundle, nodeIdTok)
+		nodeOrgFromFlag = bundle.Org
+		patternFromFlag = bundle.Pattern
+		if nodeName == "" {
+			nodeName = bundle.Name
+		}
+	}
+
 	// check the input
 	org, pattern, waitService, waitOrg, haGroupName = verifyRegisterParamters(org, pattern, nodeOrgFromFlag, patternFromFlag, waitService, waitOrg, nodeIdTok, haGroupName)
+	if joinTok != nil && joinTok.Org != org {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("The join token is for org %v, the node cannot be registered in org %v with it.", joinTok.Org, org))
+	}
 
 	cliutils.SetWhetherUsingApiKey(nodeIdTok) // if we have to use userPw later in NodeCreate(), it will set this appropriately for userPw
 	var userInputFileObj *common.UserInputFile
@@ -82,11 +113,14 @@ func DoIt(org, pattern, nodeIdTok, userPw, inputFile string, nodeOrgFromFlag str
 		msgPrinter.Println()
 		userInputFileObj = ReadUserInputFile(inputFile)
 		cliutils.Verbose(msgPrinter.Sprintf("Retrieved user input object from file %v: %v", inputFile, userInputFileObj))
+	} else if bundle != nil {
+		userInputFileObj = bundleUserInput(bundle, bundleFile)
 	}
 
 	// read and verify the node policy if it specified
 	var nodePol exchangecommon.NodePolicy
-	if nodepolicyFlag != "" {
+	hasNodePolicy := nodepolicyFlag != ""
+	if hasNodePolicy {
 		ReadAndVerifyPolicFile(nodepolicyFlag, &nodePol)
 
 		// let the user aware of the new node policy format.
@@ -94,6 +128,8 @@ func DoIt(org, pattern, nodeIdTok, userPw, inputFile string, nodeOrgFromFlag str
 			msgPrinter.Printf("Note: No properties and constraints are specified under 'deployment' attribute in the node policy file %v. The top level properties and constraints will be used.", nodepolicyFlag)
 			msgPrinter.Println()
 		}
+	} else if bundle != nil {
+		hasNodePolicy = bundleNodePolicy(bundle, bundleFile, &nodePol)
 	}
 
 	// get the arch from anax
@@ -184,10 +220,6 @@ func DoIt(org, pattern, nodeIdTok, userPw, inputFile string, nodeOrgFromFlag str
 	}
 	nodeIdTok = nodeId + ":" + nodeToken
 
-	if nodeName == "" {
-		nodeName = nodeId
-	}
-
 	// validate the node type
 	nodeType := persistence.DEVICE_TYPE_DEVICE
 	_, err1 := rest.InClusterConfig()
@@ -195,9 +227,26 @@ func DoIt(org, pattern, nodeIdTok, userPw, inputFile string, nodeOrgFromFlag str
 		nodeType = persistence.DEVICE_TYPE_CLUSTER
 	}
 
-	// make sure the node type is not "cluster" if --ha-group is specified
-	if haGroupName != "" && nodeType == persistence.DEVICE_TYPE_CLUSTER {
-		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("--ha-group flag does not support 'cluster' type agent or node."))
+	// pull the images of the bundle and wait for the exchange, the node may not have a network connection yet
+	if bundle != nil {
+		prepareBundleRegistration(bundle, bundleFile, exchUrlBase, anaxArch, nodeType, org, nodeIdTok)
+	}
+
+	// The agbot creates the node in the exchange with the pattern and user input of the join token. The name and the
+	// node policy of the join token are used when they are not given.
+	if joinTok != nil {
+		joinResp := JoinWithToken(joinTok, nodeId, nodeToken, nodeType, anaxArch)
+		if nodeName == "" {
+			nodeName = joinResp.NodeName
+		}
+		if !hasNodePolicy && joinResp.NodePolicy != nil {
+			nodePol = *joinResp.NodePolicy
+			hasNodePolicy = true
+		}
+	}
+
+	if nodeName == "" {
+		nodeName = nodeId
 	}
 
 	// See if the node exists in the exchange, and create if it doesn't
@@ -235,12 +284,6 @@ func DoIt(org, pattern, nodeIdTok, userPw, inputFile string, nodeOrgFromFlag str
 				}
 				existingNodeName = n.Name
 				existingHagrName = n.HAGroup
-
-				// make sure that the node is not in any HA group for cluster agent.
-				// This should not happen, but check just in case.
-				if n.HAGroup != "" && nodeType == persistence.DEVICE_TYPE_CLUSTER {
-					cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("The node %v is in the HA group %v but the agent is 'cluster' type. HA group does not support 'cluster' type members. Please remove the node from the HA group or choose a different node id with -n flag.", nId, n.HAGroup))
-				}
 				break
 			}
 		}
@@ -256,12 +299,6 @@ func DoIt(org, pattern, nodeIdTok, userPw, inputFile string, nodeOrgFromFlag str
 			}
 			existingNodeName = n.Name
 			existingHagrName = n.HAGroup
-
-			// make sure that the node is not in any HA group for cluster agent.
-			// This should not happen, but check just in case.
-			if n.HAGroup != "" && nodeType == persistence.DEVICE_TYPE_CLUSTER {
-				cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("The node %v is in the HA group %v but the agent is 'cluster' type. HA group does not support 'cluster' type members. Please remove the node from the HA group or choose a different node id with -n flag.", nId, n.HAGroup))
-			}
 			break
 		}
 	}
@@ -277,7 +314,7 @@ func DoIt(org, pattern, nodeIdTok, userPw, inputFile string, nodeOrgFromFlag str
 	// Use the exchange node pattern if any
 	if pattern == "" {
 		if exchangePattern == "" {
-			if nodepolicyFlag == "" {
+			if !hasNodePolicy {
 				msgPrinter.Printf("No pattern or node policy is specified. Will proceed with the existing node policy.")
 				msgPrinter.Println()
 			} else {
@@ -335,7 +372,7 @@ func DoIt(org, pattern, nodeIdTok, userPw, inputFile string, nodeOrgFromFlag str
 	}
 
 	// Update node policy if specified
-	if nodepolicyFlag != "" {
+	if hasNodePolicy {
 		msgPrinter.Printf("Updating the node policy...")
 		msgPrinter.Println()
 		exchNodePol := exchange.ExchangeNodePolicy{NodePolicy: nodePol, NodePolicyVersion: exchangecommon.NODEPOLICY_VERSION_VERSION_2}
@@ -395,7 +432,7 @@ func DoIt(org, pattern, nodeIdTok, userPw, inputFile string, nodeOrgFromFlag str
 		}
 	}
 
-	if inputFile == "" {
+	if inputFile == "" && userInputFileObj == nil {
 		// Technically an input file is not required, but it is not the common case, so warn them
 		msgPrinter.Printf("Note: no input file was specified. This is only valid if none of the services need variables set.")
 		msgPrinter.Println()
@@ -652,6 +689,23 @@ func SetConfigState(timeout int, inputFile string) error {
 	}
 }
 
+// Create the node in the exchange through the agbot that the join token is from.
+func JoinWithToken(joinTok *cliexchange.JoinTokenString, nodeId, nodeToken, nodeType, arch string) *agreementbot.JoinResponse {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	msgPrinter.Printf("Joining org %v with join token %v...", joinTok.Org, joinTok.Id)
+	msgPrinter.Println()
+
+	req := agreementbot.JoinRequest{Secret: joinTok.Secret, NodeId: nodeId, NodeToken: nodeToken, NodeType: nodeType, Arch: arch}
+	var resp agreementbot.JoinResponse
+	cliutils.ExchangePutPost("Agbot", http.MethodPost, strings.TrimSuffix(joinTok.AgbotURL, "/"), "org/"+joinTok.Org+"/jointokens/"+joinTok.Id+"/join", "", []int{201}, req, &resp)
+
+	msgPrinter.Printf("Node %v/%v created in the Exchange by the agbot.", joinTok.Org, nodeId)
+	msgPrinter.Println()
+	return &resp
+}
+
 func verifyRegisterParamters(org, pattern, nodeOrgFromFlag, patternFromFlag, waitService, waitOrg, nodeIdTok string, haGroupName string) (string, string, string, string, string) {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
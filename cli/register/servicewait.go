@@ -327,7 +327,7 @@ func WaitForService(org string, waitService string, waitTimeout int, pattern str
 				msgPrinter.Printf("Command output:")
 				msgPrinter.Println()
 				deploycheck.AllCompatible(userOrg, userPw, []string{}, "", nodeArch, nodeType, "", nodeOrg, "", "",
-					"", "", pattern, "", "", []string{}, false, false)
+					"", "", pattern, "", "", []string{}, false, false, false)
 			} else {
 				msgPrinter.Printf("Using the 'hzn deploycheck userinput -p' command to verify that node, service configuration and pattern are compatible.")
 				msgPrinter.Println()
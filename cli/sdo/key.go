@@ -100,7 +100,7 @@ func KeyList(org, userCreds, keyName string) {
 		if !found {
 			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("SDO key name %s not found", keyName))
 		} else {
-			jsonBytes, err = json.MarshalIndent(foundKey, "", cliutils.JSON_INDENT)
+			jsonBytes, err = cliutils.MarshalOutput(foundKey)
 			if err != nil {
 				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn sdo keys list' output: %v", err))
 			}
@@ -108,7 +108,7 @@ func KeyList(org, userCreds, keyName string) {
 
 		// use all the keys in SDO owner services
 	} else {
-		jsonBytes, err = json.MarshalIndent(output, "", cliutils.JSON_INDENT)
+		jsonBytes, err = cliutils.MarshalOutput(output)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn sdo keys list' output: %v", err))
 		}
@@ -221,7 +221,7 @@ func listFullVoucher(respBodyBytes []byte, apiMsg string) []byte {
 		cliutils.Fatal(cliutils.HTTP_ERROR, msgPrinter.Sprintf("json unmarshalling HTTP response '%s' from %s: %v", string(respBodyBytes), apiMsg, err))
 	}
 
-	jsonBytes, err := json.MarshalIndent(output, "", cliutils.JSON_INDENT)
+	jsonBytes, err := cliutils.MarshalOutput(output)
 	if err != nil {
 		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn exchange service list' output: %v", err))
 	}
@@ -247,7 +247,7 @@ func VoucherList(org, userCreds, voucher string, namesOnly bool) {
 			cliutils.Fatal(cliutils.HTTP_ERROR, msgPrinter.Sprintf("json unmarshalling HTTP response '%s' from %s: %v", string(respBodyBytes), apiMsg, err))
 		}
 
-		jsonBytes, err := json.MarshalIndent(output, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(output)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn sdo voucher list' output: %v", err))
 		}
@@ -276,7 +276,7 @@ func VoucherList(org, userCreds, voucher string, namesOnly bool) {
 				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("parsing the json from %s: %v", voucher, err))
 			}
 
-			jsonBytes, err := json.MarshalIndent(vouch, "", cliutils.JSON_INDENT)
+			jsonBytes, err := cliutils.MarshalOutput(vouch)
 			if err != nil {
 				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn sdo voucher list' output: %v", err))
 			}
@@ -35,7 +35,7 @@ func printResponse(resp []byte, structure interface{}) {
 	}
 
 	// print the parsed structure
-	jsonBytes, jerr := json.MarshalIndent(structure, "", cliutils.JSON_INDENT)
+	jsonBytes, jerr := cliutils.MarshalOutput(structure)
 	if jerr != nil {
 		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'agbot API' output: %v", jerr))
 	}
@@ -121,7 +121,7 @@ func SecretList(org, credToUse, secretName string) {
 		} else if retCode == 404 {
 			// secret doesn't exist, output exists: false for consistency
 			secretDNE := SecretResponse{false}
-			jsonBytes, jerr := json.MarshalIndent(secretDNE, "", cliutils.JSON_INDENT)
+			jsonBytes, jerr := cliutils.MarshalOutput(secretDNE)
 			if jerr != nil {
 				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'agbot API' output: %v", jerr))
 			}
@@ -175,27 +175,7 @@ func SecretAdd(org, credToUse, secretName, secretFile, secretKey, secretDetail s
 	}
 
 	// parse the key and details
-	var newSecret struct {
-		Key   string `json:"key"`
-		Value string `json:"value"`
-	}
-	newSecret.Key = secretKey
-	if secretDetail != "" {
-		newSecret.Value = secretDetail
-	} else {
-		// parse in a file as bytes for the secret details
-		var secretBytes []byte
-		var err error
-		if secretFile == "-" {
-			secretBytes, err = ioutil.ReadAll(os.Stdin)
-		} else {
-			secretBytes, err = ioutil.ReadFile(secretFile)
-		}
-		if err != nil {
-			cliutils.Fatal(cliutils.FILE_IO_ERROR, msgPrinter.Sprintf("reading %s failed: %v", secretFile, err))
-		}
-		newSecret.Value = string(secretBytes)
-	}
+	newSecret := readSecretDetails(secretFile, secretKey, secretDetail)
 
 	// prompt for overwrite if the secret already exists
 	if secretExists && !secretOverwrite {
@@ -221,6 +201,28 @@ func SecretAdd(org, credToUse, secretName, secretFile, secretKey, secretDetail s
 
 }
 
+// Returns the secret details given on the command line, reading the value from the secret file when there is no secret detail.
+func readSecretDetails(secretFile, secretKey, secretDetail string) secrets.SecretDetails {
+	newSecret := secrets.SecretDetails{Key: secretKey}
+	if secretDetail != "" {
+		newSecret.Value = secretDetail
+	} else {
+		// parse in a file as bytes for the secret details
+		var secretBytes []byte
+		var err error
+		if secretFile == "-" {
+			secretBytes, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			secretBytes, err = ioutil.ReadFile(secretFile)
+		}
+		if err != nil {
+			cliutils.Fatal(cliutils.FILE_IO_ERROR, i18n.GetMessagePrinter().Sprintf("reading %s failed: %v", secretFile, err))
+		}
+		newSecret.Value = string(secretBytes)
+	}
+	return newSecret
+}
+
 // Removes a secret in the secrets manager. If the secret does not exist, an error (fatal) is raised
 func SecretRemove(org, credToUse, secretName string, forceRemoval bool) {
 	// get message printer
@@ -287,3 +289,195 @@ func SecretRead(org, credToUse, secretName string) {
 	}
 
 }
+
+// The use of a secret by a service of an agreement, as returned by the agbot secure API. The node of the agreement
+// restarts the service when the secret is updated.
+type SecretUsage struct {
+	AgreementId             string `json:"agreement_id"`
+	Node                    string `json:"node"`
+	Policy                  string `json:"policy,omitempty"`
+	Pattern                 string `json:"pattern,omitempty"`
+	State                   string `json:"state"`
+	ServiceOrg              string `json:"service_org"`
+	ServiceUrl              string `json:"service_url"`
+	ServiceArch             string `json:"service_arch,omitempty"`
+	ServiceVersionRange     string `json:"service_version_range,omitempty"`
+	ServiceSecretName       string `json:"service_secret_name"`
+	LastSecretUpdateTime    uint64 `json:"last_secret_update_time"`
+	LastSecretUpdateTimeAck uint64 `json:"last_secret_update_time_ack"`
+}
+
+// Returns the raw response of the agbot with the uses of the secret by the services of the agreements.
+func getSecretUsage(org, credToUse, secretName string) []byte {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	var resp []byte
+	usageQuery := func() int {
+		return cliutils.AgbotGet("org"+cliutils.AddSlash(org)+"/secretusage"+cliutils.AddSlash(secretName), cliutils.OrgAndCreds(org, credToUse),
+			[]int{200, 400, 401, 403, 404, 503}, &resp)
+	}
+	retCode := queryWithRetry(usageQuery, 3, 1)
+
+	if retCode == 404 {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("The agbot does not support finding the use of secrets, it must be upgraded."))
+	} else if retCode != 200 {
+		respString, _ := strconv.Unquote(string(resp))
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, respString)
+	}
+	return resp
+}
+
+// Displays the services of the agreements that use a secret, which are restarted with the new value when the secret is updated.
+func SecretUsageList(org, credToUse, secretName string) {
+	// get rid of trailing / from secret name
+	secretName = strings.TrimSuffix(secretName, "/")
+
+	usage := make([]SecretUsage, 0)
+	printResponse(getSecretUsage(org, credToUse, secretName), &usage)
+}
+
+// Replaces the details of an existing secret in the secrets manager. Before the secret is updated, the changes to the key
+// and value are displayed, without the values, along with the services and agreements that use the secret. The agbot sends
+// the new value to the nodes of those agreements, which restart the services. The key is kept when no key is given. With
+// --dry-run, only the changes and the services are displayed.
+func SecretRotate(org, credToUse, secretName, secretFile, secretKey, secretDetail string, force bool) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	// get rid of trailing / from secret name
+	secretName = strings.TrimSuffix(secretName, "/")
+
+	// check the input
+	if secretFile != "" && secretDetail != "" {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("-f is mutually exclusive with --secretDetail."))
+	}
+	if secretFile == "" && secretDetail == "" {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Must specify either -f or --secretDetail."))
+	}
+	if secretFile == "-" && !force && !cliutils.IsDryRun() {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("--force must be specified when the secret details are read from stdin."))
+	}
+
+	// read the current details, only an existing secret can be rotated
+	var resp []byte
+	readQuery := func() int {
+		return cliutils.AgbotGet("org"+cliutils.AddSlash(org)+"/secrets"+cliutils.AddSlash(secretName), cliutils.OrgAndCreds(org, credToUse),
+			[]int{200, 400, 401, 403, 404, 503}, &resp)
+	}
+	retCode := queryWithRetry(readQuery, 3, 1)
+	if retCode == 404 {
+		cliutils.Fatal(cliutils.NOT_FOUND, msgPrinter.Sprintf("Secret \"%s\" not found in the secrets manager, use 'hzn secretsmanager secret add' to add it.", secretName))
+	} else if retCode != 200 {
+		respString, _ := strconv.Unquote(string(resp))
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, respString)
+	}
+	var oldSecret secrets.SecretDetails
+	if err := json.Unmarshal(resp, &oldSecret); err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to unmarshal REST API response: %v", err))
+	}
+
+	if secretKey == "" {
+		secretKey = oldSecret.Key
+	}
+	newSecret := readSecretDetails(secretFile, secretKey, secretDetail)
+
+	// display the changes, the values are never displayed
+	msgPrinter.Printf("Changes to secret \"%s\":", secretName)
+	msgPrinter.Println()
+	if oldSecret.Key != newSecret.Key {
+		msgPrinter.Printf("  key: %s -> %s", oldSecret.Key, newSecret.Key)
+	} else {
+		msgPrinter.Printf("  key: %s (unchanged)", newSecret.Key)
+	}
+	msgPrinter.Println()
+	if oldSecret.Value != newSecret.Value {
+		msgPrinter.Printf("  value: changed (%d -> %d bytes)", len(oldSecret.Value), len(newSecret.Value))
+	} else {
+		msgPrinter.Printf("  value: unchanged")
+	}
+	msgPrinter.Println()
+	if oldSecret == newSecret {
+		msgPrinter.Printf("Secret \"%s\" is unchanged, nothing to rotate.", secretName)
+		msgPrinter.Println()
+		return
+	}
+
+	// display the services that will be restarted with the new value
+	usage := make([]SecretUsage, 0)
+	if err := json.Unmarshal(getSecretUsage(org, credToUse, secretName), &usage); err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to unmarshal REST API response: %v", err))
+	}
+	displaySecretUsage(secretName, usage)
+
+	if cliutils.IsDryRun() {
+		return
+	}
+	if !force {
+		cliutils.ConfirmRemove(msgPrinter.Sprintf("Are you sure you want to rotate secret %s?", secretName))
+	}
+
+	// replace the secret in the secrets manager
+	var resp2 []byte
+	updateQuery := func() int {
+		return cliutils.AgbotPutPost(http.MethodPut, "org"+cliutils.AddSlash(org)+"/secrets"+cliutils.AddSlash(secretName),
+			cliutils.OrgAndCreds(org, credToUse), []int{201, 400, 401, 403, 503}, newSecret, &resp2)
+	}
+	retCode = queryWithRetry(updateQuery, 3, 1)
+
+	if retCode == 201 {
+		msgPrinter.Printf("Secret \"%s\" successfully rotated in the secrets manager.", secretName)
+		msgPrinter.Println()
+		if len(usage) != 0 {
+			msgPrinter.Printf("The agbot will send the new value to the nodes of the agreements, use 'hzn secretsmanager secret usage %s' to see when each node received it.", secretName)
+			msgPrinter.Println()
+		}
+	} else {
+		respString, _ := strconv.Unquote(string(resp2))
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, respString)
+	}
+}
+
+// Displays the services that use a secret, with the agreements of each service.
+func displaySecretUsage(secretName string, usage []SecretUsage) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if len(usage) == 0 {
+		msgPrinter.Printf("No agreement uses secret \"%s\", no service will be restarted.", secretName)
+		msgPrinter.Println()
+		return
+	}
+
+	// group the agreements by service, in the order the agbot returned them
+	services := make([]string, 0)
+	agreements := make(map[string][]SecretUsage)
+	for _, u := range usage {
+		service := fmt.Sprintf("%s/%s", u.ServiceOrg, u.ServiceUrl)
+		if u.ServiceArch != "" {
+			service += " " + u.ServiceArch
+		}
+		if u.ServiceVersionRange != "" {
+			service += " " + u.ServiceVersionRange
+		}
+		if _, ok := agreements[service]; !ok {
+			services = append(services, service)
+		}
+		agreements[service] = append(agreements[service], u)
+	}
+
+	msgPrinter.Printf("These services use secret \"%s\" and will be restarted with the new value:", secretName)
+	msgPrinter.Println()
+	for _, service := range services {
+		msgPrinter.Printf("  %s, %d agreement(s):", service, len(agreements[service]))
+		msgPrinter.Println()
+		for _, u := range agreements[service] {
+			deployment := u.Policy
+			if u.Pattern != "" {
+				deployment = u.Pattern
+			}
+			msgPrinter.Printf("    node %s, agreement %s (%s), %s, service secret %s", u.Node, u.AgreementId, u.State, deployment, u.ServiceSecretName)
+			msgPrinter.Println()
+		}
+	}
+}
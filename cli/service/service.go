@@ -9,11 +9,17 @@ import (
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/anax/semanticversion"
 	"net/http"
+	"net/url"
+	"os"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type OurService struct {
@@ -43,16 +49,25 @@ func List() {
 	}
 
 	// Convert to json and output
-	jsonBytes, err := json.MarshalIndent(services, "", cliutils.JSON_INDENT)
+	jsonBytes, err := cliutils.MarshalOutput(services)
 	if err != nil {
 		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn service list' output: %v", err))
 	}
 	fmt.Printf("%s\n", jsonBytes)
 }
 
-func Log(serviceName string, serviceVersion, containerName string, tailing bool) {
+func Log(serviceName string, serviceVersion, containerName string, follow bool, since string, tail int) {
 	msgPrinter := i18n.GetMessagePrinter()
 
+	opts := cliutils.LogOptions{Follow: follow, Tail: tail}
+	if since != "" {
+		if t, err := cliutils.ParseSince(since, time.Now()); err != nil {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Invalid --since value %v, it must be a duration such as 10m or an RFC 3339 timestamp.", since))
+		} else {
+			opts.Since = t
+		}
+	}
+
 	// if node is not registered
 	horDevice := api.HorizonDevice{}
 	cliutils.HorizonGet("node", []int{200}, &horDevice, false)
@@ -99,67 +114,118 @@ func Log(serviceName string, serviceVersion, containerName string, tailing bool)
 		}
 	}
 
+	// The containers of a service on a cluster run in pods, their logs are read by the agent.
+	if horDevice.NodeType != nil && *horDevice.NodeType == persistence.DEVICE_TYPE_CLUSTER {
+		clusterLog(instanceId, containerName, name, opts)
+		return
+	}
+
 	// Check service's log-driver to read logs from correct place
-	containerFound := false
+	var containerNames []string
 	var nonDefaultLogDriverUsed bool
 	for _, def := range runningServices.Definitions["active"] {
 		if def.Id == msdefId {
-			if def.Deployment != "" {
+			if _, err := persistence.GetHostProcessDeployment(def.Deployment); err == nil {
+				// A host process logs under the same syslog tag as a container, with a fixed name in the place of the container name.
+				if containerName != "" && containerName != persistence.HOST_PROCESS_LOG_NAME {
+					cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Service %v runs as a host process, it has no containers.", serviceName))
+				}
+				containerNames = []string{persistence.HOST_PROCESS_LOG_NAME}
+				instanceId = strings.ToLower(instanceId)
+			} else if def.Deployment != "" {
 				deployment := &containermessage.DeploymentDescription{}
 				if err := json.Unmarshal([]byte(def.Deployment), deployment); err != nil {
 					cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Deployment unmarshalling error: %v", err))
 				}
 
-				if len(deployment.Services) > 1 && containerName == "" {
-					var cNames []string
-					for name, _ := range deployment.Services {
-						cNames = append(cNames, name)
-					}
-					cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Service definition %v consists of more than one container: %v. Please specify the service name by -c flag", serviceName, strings.Join(cNames, ", ")))
-				}
+				// Without a container name, the logs of all the containers of the service are displayed.
 				for deployedContainerName, service := range deployment.Services {
-					if containerName == deployedContainerName || (containerName == "" && len(deployment.Services) == 1) {
-						var err error
-						if nonDefaultLogDriverUsed, err = cliutils.ChekServiceLogPossibility(service.LogDriver); err != nil {
+					if containerName == deployedContainerName || containerName == "" {
+						if nonDefault, err := cliutils.ChekServiceLogPossibility(service.LogDriver); err != nil {
 							cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("Service logs are unavailable: %v", err))
+						} else if nonDefault {
+							nonDefaultLogDriverUsed = true
 						}
-						containerName = deployedContainerName
-						containerFound = true
-						break
+						containerNames = append(containerNames, deployedContainerName)
 					}
 				}
+				sort.Strings(containerNames)
 			}
 			break
 		}
 	}
-	if !containerFound && containerName != "" {
+	if len(containerNames) == 0 && containerName != "" {
 		if serviceVersion == "" {
 			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Container %v is not running as part of service %v.", containerName, serviceName))
 		} else {
 			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Container %v is not running as part of service %v version.", containerName, serviceName, serviceVersion))
 		}
-	} else if !containerFound {
+	} else if len(containerNames) == 0 {
 		if serviceVersion == "" {
 			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Could not find service %v running on the node.", serviceName))
 		} else {
 			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Could not find service %v version %v running on the node.", serviceName, serviceVersion))
 		}
 	} else {
-		msgPrinter.Printf("Displaying log messages of container %v for service %v with service id %v.", containerName, name, instanceId)
+		msgPrinter.Printf("Displaying log messages of container %v for service %v with service id %v.", strings.Join(containerNames, ", "), name, instanceId)
 		msgPrinter.Println()
-		if tailing {
+		if follow {
 			msgPrinter.Printf("Use ctrl-C to terminate this command.")
 			msgPrinter.Println()
 		}
 	}
 
-	if runtime.GOOS == "darwin" || nonDefaultLogDriverUsed {
-		cliutils.LogMac(instanceId+"-"+containerName, tailing)
+	// The records are prefixed with the name of their container when the logs of several containers are displayed.
+	useEngine := cliutils.IsPodmanEndpoint() || runtime.GOOS == "darwin" || nonDefaultLogDriverUsed
+	sources := make([]cliutils.LogSource, 0, len(containerNames))
+	for _, cName := range containerNames {
+		src := cliutils.LogSource{Match: instanceId + "_" + cName}
+		if useEngine {
+			src.Match = instanceId + "-" + cName
+		}
+		if len(containerNames) > 1 {
+			src.Prefix = cName
+		}
+		sources = append(sources, src)
+	}
+
+	if cliutils.IsPodmanEndpoint() {
+		cliutils.LogContainers("podman", sources, opts)
+	} else if useEngine {
+		cliutils.LogContainers("docker", sources, opts)
 	} else {
-		cliutils.LogLinux(instanceId+"_"+containerName, tailing)
+		cliutils.LogSyslog(sources, opts)
 	}
 }
 
+// Display the logs of a service on a cluster, streamed by the agent.
+func clusterLog(instanceId string, containerName string, name string, opts cliutils.LogOptions) {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	query := url.Values{}
+	query.Set("instance", instanceId)
+	if containerName != "" {
+		query.Set("container", containerName)
+	}
+	if opts.Follow {
+		query.Set("follow", "true")
+	}
+	if !opts.Since.IsZero() {
+		query.Set("since", opts.Since.Format(time.RFC3339))
+	}
+	if opts.Tail >= 0 {
+		query.Set("tail", strconv.Itoa(opts.Tail))
+	}
+
+	msgPrinter.Printf("Displaying log messages of the pods of service %v with service id %v.", name, instanceId)
+	msgPrinter.Println()
+	if opts.Follow {
+		msgPrinter.Printf("Use ctrl-C to terminate this command.")
+		msgPrinter.Println()
+	}
+	cliutils.HorizonStream("service/log?"+query.Encode(), os.Stdout)
+}
+
 func Registered() {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
@@ -172,7 +238,7 @@ func Registered() {
 	}
 
 	// Convert to json and output
-	jsonBytes, err := json.MarshalIndent(apiOutput, "", cliutils.JSON_INDENT)
+	jsonBytes, err := cliutils.MarshalOutput(apiOutput)
 	if err != nil {
 		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn service registered' output: %v", err))
 	}
@@ -190,7 +256,7 @@ func ListConfigState() {
 	}
 
 	// Convert to json and output
-	jsonBytes, err := json.MarshalIndent(apiOutput, "", cliutils.JSON_INDENT)
+	jsonBytes, err := cliutils.MarshalOutput(apiOutput)
 	if err != nil {
 		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn service configstate' output: %v", err))
 	}
@@ -311,3 +377,49 @@ func Resume(applyAll bool, serviceOrg string, serviceUrl string, serviceVer stri
 	}
 	msgPrinter.Println()
 }
+
+func Pause(serviceOrg string, serviceUrl string) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	msgPrinter.Printf("Pausing service %v/%v, stopping its containers ...", serviceOrg, serviceUrl)
+	msgPrinter.Println()
+
+	apiInput := api.ServicePause{
+		Url: serviceUrl,
+		Org: serviceOrg,
+	}
+
+	httpCode, respBody, err := cliutils.HorizonPutPost(http.MethodPut, "service/pause", []int{201, 200, 400}, apiInput, false)
+	if httpCode == 200 || httpCode == 201 {
+		msgPrinter.Printf("Service pausing request successfully sent, the agreements of the service are kept. Please use 'hzn service resume %v %v' to start the service containers again.", serviceOrg, serviceUrl)
+	} else if httpCode == 400 {
+		msgPrinter.Printf("Error returned pausing the service: %v", respBody)
+	} else {
+		msgPrinter.Printf("Error: %v", err)
+	}
+	msgPrinter.Println()
+}
+
+func ResumePaused(serviceOrg string, serviceUrl string) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	msgPrinter.Printf("Resuming paused service %v/%v ...", serviceOrg, serviceUrl)
+	msgPrinter.Println()
+
+	apiInput := api.ServicePause{
+		Url: serviceUrl,
+		Org: serviceOrg,
+	}
+
+	httpCode, respBody, err := cliutils.HorizonPutPost(http.MethodPut, "service/resume", []int{201, 200, 400}, apiInput, false)
+	if httpCode == 200 || httpCode == 201 {
+		msgPrinter.Printf("Service resuming request successfully sent, please use 'hzn node status' to make sure the service containers are started. It may take a couple of minutes.")
+	} else if httpCode == 400 {
+		msgPrinter.Printf("Error returned resuming the service: %v", respBody)
+	} else {
+		msgPrinter.Printf("Error: %v", err)
+	}
+	msgPrinter.Println()
+}
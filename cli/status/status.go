@@ -1,7 +1,6 @@
 package status
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/open-horizon/anax/cli/cliutils"
 	"github.com/open-horizon/anax/i18n"
@@ -33,7 +32,7 @@ func DisplayStatus(details bool, agbot bool) {
 	status := getStatus(agbot)
 
 	if details {
-		jsonBytes, err := json.MarshalIndent(status, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(status)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn status -l' output: %v", err))
 		}
@@ -42,7 +41,7 @@ func DisplayStatus(details bool, agbot bool) {
 		workers := make(map[string]map[string]*worker.WorkerStatus)
 		workers["workers"] = status.Workers
 
-		jsonBytes, err := json.MarshalIndent(workers, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(workers)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn status' output: %v", err))
 		}
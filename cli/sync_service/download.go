@@ -7,62 +7,29 @@ import (
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/edge-sync-service/common"
-	"io"
 	"os"
 	"path"
 	"strings"
 )
 
-// ObjectDownLoad is to download data to a file named ${objectType}_${objectId}
-func ObjectDownLoad(org string, userPw string, objType string, objId string, filePath string, overwrite bool, skipDigitalSigVerify bool) {
+// ObjectDownLoad is to download data to a file named ${objectType}_${objectId}. The data is downloaded in chunks to a
+// ${fileName}.part file that is renamed when the data is complete and verified, so that an interrupted download can be
+// resumed with the resume flag.
+func ObjectDownLoad(org string, userPw string, objType string, objId string, filePath string, overwrite bool, skipDigitalSigVerify bool, chunkSize int, resume bool) {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
 
 	if userPw == "" {
 		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("must specify exchange credentials to access the model management service"))
 	}
+	if chunkSize <= 0 {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("--chunkSize must be greater than 0"))
+	}
 
 	// For this command, object type and id are required parameters, No null checking is needed.
 	// Set the API key env var if that's what we're using.
 	cliutils.SetWhetherUsingApiKey(userPw)
 
-	// Establish the HTTP request override because the download could take some time.
-	setHTTPOverride := false
-	if os.Getenv(config.HTTPRequestTimeoutOverride) == "" {
-		setHTTPOverride = true
-		os.Setenv(config.HTTPRequestTimeoutOverride, "0")
-	}
-
-	// Call the MMS service over HTTP to get object metadata and determine the file size.
-	var objectMeta common.MetaData
-	metaUrlPath := path.Join("api/v1/objects/", org, objType, objId)
-	httpCode := cliutils.ExchangeGet("Model Management Service", cliutils.GetMMSUrl(), metaUrlPath, cliutils.OrgAndCreds(org, userPw), []int{200, 404}, &objectMeta)
-	if httpCode == 404 {
-		cliutils.Fatal(cliutils.NOT_FOUND, msgPrinter.Sprintf("object '%s' of type '%s' not found in org %s", objId, objType, org))
-	}
-	size := objectMeta.ObjectSize
-
-	// Call the MMS service over HTTP to download the object data.
-	var data io.Reader
-	urlPath := path.Join("api/v1/objects/", org, objType, objId, "/data")
-	resp := cliutils.ExchangeGetResponse("Model Management Service", cliutils.GetMMSUrl(), urlPath, cliutils.OrgAndCreds(org, userPw))
-	if resp.Body != nil {
-		defer resp.Body.Close()
-	}
-	if resp.StatusCode == 404 {
-		cliutils.Fatal(cliutils.NOT_FOUND, msgPrinter.Sprintf("object '%s' of type '%s' not found in org %s", objId, objType, org))
-	}
-
-	// Display download progress on console
-	if size > 0 {
-		data = cliutils.DisplayProgress(resp.Body, int(size), "Downloading")
-	}
-
-	// Restore HTTP request override if necessary.
-	if setHTTPOverride {
-		os.Setenv(config.HTTPRequestTimeoutOverride, "")
-	}
-
 	var fileName string
 	// if no fileName and filePath specified, data will be saved in current dir, with name {objectType}_{objectId}
 	if filePath == "" {
@@ -100,15 +67,66 @@ func ObjectDownLoad(org string, userPw string, objType string, objId string, fil
 		}
 	}
 
+	// Establish the HTTP request override because the download could take some time.
+	setHTTPOverride := false
+	if os.Getenv(config.HTTPRequestTimeoutOverride) == "" {
+		setHTTPOverride = true
+		os.Setenv(config.HTTPRequestTimeoutOverride, "0")
+	}
+
+	// Call the MMS service over HTTP to get object metadata and determine the file size.
+	var objectMeta common.MetaData
+	metaUrlPath := path.Join("api/v1/objects/", org, objType, objId)
+	httpCode := cliutils.ExchangeGet("Model Management Service", cliutils.GetMMSUrl(), metaUrlPath, cliutils.OrgAndCreds(org, userPw), []int{200, 404}, &objectMeta)
+	if httpCode == 404 {
+		cliutils.Fatal(cliutils.NOT_FOUND, msgPrinter.Sprintf("object '%s' of type '%s' not found in org %s", objId, objType, org))
+	}
+	size := objectMeta.ObjectSize
+
+	// Call the MMS service over HTTP to download the object data, into the part file first.
+	urlPath := path.Join("api/v1/objects/", org, objType, objId, "/data")
+	partFileName := fileName + DOWNLOAD_PART_SUFFIX
+	if size > 0 {
+		downloadDataByChunk(cliutils.GetMMSUrl()+"/"+urlPath, cliutils.OrgAndCreds(org, userPw), int64(chunkSize), size, partFileName, resume)
+	} else {
+		// the size of the data is not known, it can only be downloaded in one request
+		resp := cliutils.ExchangeGetResponse("Model Management Service", cliutils.GetMMSUrl(), urlPath, cliutils.OrgAndCreds(org, userPw))
+		if resp.Body != nil {
+			defer resp.Body.Close()
+		}
+		if resp.StatusCode == 404 {
+			cliutils.Fatal(cliutils.NOT_FOUND, msgPrinter.Sprintf("object '%s' of type '%s' not found in org %s", objId, objType, org))
+		}
+		os.Remove(partFileName)
+		if err := cutil.WriteDateStreamToFile(resp.Body, partFileName); err != nil {
+			cliutils.Fatal(cliutils.INTERNAL_ERROR, msgPrinter.Sprintf("Failed to save data for object '%s' of type '%s' to file %s, err: %v", objId, objType, partFileName, err))
+		}
+	}
+
+	// Restore HTTP request override if necessary.
+	if setHTTPOverride {
+		os.Setenv(config.HTTPRequestTimeoutOverride, "")
+	}
+
+	if size > 0 {
+		if fi, err := os.Stat(partFileName); err != nil || fi.Size() != size {
+			os.Remove(partFileName)
+			cliutils.Fatal(cliutils.INTERNAL_ERROR, msgPrinter.Sprintf("the size of the downloaded data of object '%s' of type '%s' is not the size of the object %v, download it again", objId, objType, size))
+		}
+	}
+
 	var verified bool
 	var err error
 	if !skipDigitalSigVerify {
 		if objectMeta.HashAlgorithm != "" && objectMeta.PublicKey != "" && objectMeta.Signature != "" {
-			// verify data
-			//dataReader := bytes.NewReader(data)
+			// verify the data, the verification saves it to the file
 			msgPrinter.Printf("Verifying data with digital signature....")
 			msgPrinter.Println()
-			if verified, err = cutil.VerifyDataSig(data, objectMeta.PublicKey, objectMeta.Signature, objectMeta.HashAlgorithm, fileName); !verified {
+			os.Remove(fileName)
+			if verified, err = cutil.VerifyDataSigInFile(partFileName, objectMeta.PublicKey, objectMeta.Signature, objectMeta.HashAlgorithm, fileName); !verified {
+				// the data is corrupted, it has to be downloaded again from the beginning
+				os.Remove(partFileName)
+				os.Remove(fileName)
 				cliutils.Fatal(cliutils.INTERNAL_ERROR, msgPrinter.Sprintf("Failed to verify data: %s", err.Error()))
 			}
 			msgPrinter.Printf("Verifying digital signature is done.")
@@ -117,12 +135,12 @@ func ObjectDownLoad(org string, userPw string, objType string, objId string, fil
 
 	}
 	if !verified {
-		// verify process will save the data to file, if verify process not execute, then stream data directly to a file
+		// verify process will save the data to file, if verify process not execute, then rename the part file
 		// Reach here if:
 		// 1) use --noIntegrity flag,
 		// or
 		// 2) object metadata doesn't have HashAlgorithm, or publicKey or signature field
-		if err := cutil.WriteDateStreamToFile(data, fileName); err != nil {
+		if err := os.Rename(partFileName, fileName); err != nil {
 			cliutils.Fatal(cliutils.INTERNAL_ERROR, msgPrinter.Sprintf("Failed to save data for object '%s' of type '%s' to file %s, err: %v", objId, objType, fileName, err))
 		}
 	}
@@ -38,6 +38,9 @@ const BatchSize = 50
 
 const MaxTry = 150
 
+// The size of the data chunks of an object upload, in bytes.
+const DefaultChunkSize = 52428800
+
 type MMSObjectInfo struct {
 	ObjectID     string                      `json:"objectID,omitempty"`
 	ObjectType   string                      `json:"objectType,omitempty"`
@@ -224,7 +227,7 @@ func ObjectList(org string, userPw string, objType string, objId string, destPol
 			output = cliutils.MarshalIndent(mmsObjects, "mms object list")
 		} else {
 			var err1 error
-			output, err1 = cliutils.DisplayAsJson(objectsMeta)
+			output, err1 = cliutils.FormatOutput(objectsMeta)
 			if err1 != nil {
 				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal 'hzn mms object list' output: %v", err1))
 			}
@@ -298,7 +301,9 @@ func ObjectNew(org string) {
 
 // Upload an object to the MMS. The user can provide a copy of the object's metadata in a file, or they can simply provide
 // object id and type.
-func ObjectPublish(org string, userPw string, objType string, objId string, objPattern string, objMetadataFile string, objFile string, noChunkUpload bool, chunkSize int, skipDigitalSig bool, dsHashAlgo string, dsHash string, privKeyFilePath string) {
+// When resume is true and a chunked upload of the same file was interrupted, only the chunks that the MMS did not receive
+// are uploaded.
+func ObjectPublish(org string, userPw string, objType string, objId string, objPattern string, objMetadataFile string, objFile string, noChunkUpload bool, chunkSize int, skipDigitalSig bool, dsHashAlgo string, dsHash string, privKeyFilePath string, resume bool) {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
 
@@ -323,6 +328,12 @@ func ObjectPublish(org string, userPw string, objType string, objId string, objP
 		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("cannot specify --skipDigitalSig with --hashAlgo"))
 	} else if dsHashAlgo != "" && dsHashAlgo != common.Sha1 && dsHashAlgo != common.Sha256 {
 		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("invalid value for --hashAlgo, please use SHA1 or SHA256"))
+	} else if resume && objFile == "" {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("must specify --object with --resume"))
+	} else if resume && noChunkUpload {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("cannot specify --resume with --disableChunkUpload"))
+	} else if !noChunkUpload && chunkSize <= 0 {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("--chunkSize must be greater than 0"))
 	}
 
 	// If we were given a full metadata file, read it in and use it to create the object. Otherwise, construct a minimal
@@ -340,11 +351,20 @@ func ObjectPublish(org string, userPw string, objType string, objId string, objP
 		objectMeta.DestType = objPattern
 	}
 
+	// The state of a chunked upload is saved after each chunk. When resuming the upload of the same file, the metadata with
+	// the signature of the data is already in the MMS, so only the rest of the data has to be uploaded.
+	var uploadState *UploadState
+	resumeUpload := false
+	if objFile != "" && !noChunkUpload {
+		uploadState = getUploadState(org, userPw, objectMeta, objFile, chunkSize, resume)
+		resumeUpload = uploadState.Offset > 0
+	}
+
 	// If there is no data to upload, set the metaonly flag to indicate that we are only updating the object's metadata. This ensures
 	// that the MMS (CSS) correctly interpets the PUT.
 	if objFile == "" {
 		objectMeta.MetaOnly = true
-	} else if !skipDigitalSig {
+	} else if !skipDigitalSig && !resumeUpload {
 
 		hashAlgorithm := common.Sha1
 		if dsHashAlgo == common.Sha256 {
@@ -388,7 +408,9 @@ func ObjectPublish(org string, userPw string, objType string, objId string, objP
 		os.Setenv(config.HTTPRequestTimeoutOverride, "120")
 	}
 
-	cliutils.ExchangePutPost("Model Management Service", http.MethodPut, cliutils.GetMMSUrl(), urlPath, cliutils.OrgAndCreds(org, userPw), []int{204}, wrapper, nil)
+	if !resumeUpload {
+		cliutils.ExchangePutPost("Model Management Service", http.MethodPut, cliutils.GetMMSUrl(), urlPath, cliutils.OrgAndCreds(org, userPw), []int{204}, wrapper, nil)
+	}
 
 	if metaDataSetHTTPOverride == true {
 		os.Setenv(config.HTTPRequestTimeoutOverride, "")
@@ -416,7 +438,7 @@ func ObjectPublish(org string, userPw string, objType string, objId string, objP
 		} else {
 			cliutils.Verbose(msgPrinter.Sprintf("Upload object in chunk, chunk size is: %d", chunkSize))
 			mmsUrl := cliutils.GetMMSUrl() + "/" + urlPath
-			uploadDataByChunk(mmsUrl, cliutils.OrgAndCreds(org, userPw), chunkSize, file)
+			uploadDataByChunk(mmsUrl, cliutils.OrgAndCreds(org, userPw), file, uploadState)
 		}
 
 		// Restore HTTP request override if necessary.
@@ -446,8 +468,10 @@ func ObjectPublish(org string, userPw string, objType string, objId string, objP
 			cliutils.ExchangeGet("Model Management Service", cliutils.GetMMSUrl(), urlPath, cliutils.OrgAndCreds(org, userPw), []int{200}, &resp)
 			cliutils.Verbose(msgPrinter.Sprintf("Object status: %v", string(resp)))
 
-			if string(resp) == common.ReadyToSend || string(resp) == common.VerificationFailed {
+			if string(resp) == common.ReadyToSend {
 				break
+			} else if string(resp) == common.VerificationFailed {
+				cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("the Model Management Service failed to verify the data of object %v with its digital signature, the data may have been corrupted during the upload. Publish the object again.", objectMeta.ObjectID))
 			}
 
 			time.Sleep(5 * time.Second)
@@ -518,7 +542,7 @@ func ObjectTypes(org, userPw string) {
 	if httpCode == 404 {
 		fmt.Println("[]")
 	} else {
-		jsonBytes, err := json.MarshalIndent(types, "", cliutils.JSON_INDENT)
+		jsonBytes, err := cliutils.MarshalOutput(types)
 		if err != nil {
 			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn mms object types' output: %v", err))
 		}
@@ -639,7 +663,45 @@ func GetCryptoHashType(hashAlgo string) (crypto.Hash, error) {
 	}
 }
 
-func uploadDataByChunk(mmsUrl string, creds string, chunkSize int, file *os.File) {
+// Returns the state of the chunked upload of the object file. When resume is true, it is the saved state of an interrupted
+// upload of the same file if the object is still in the MMS, otherwise the upload starts from the beginning.
+func getUploadState(org string, userPw string, objectMeta common.MetaData, objFile string, chunkSize int, resume bool) *UploadState {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	file, err := os.Open(objFile)
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("unable to open object file %v: %v", objFile, err))
+	}
+	defer cutil.CloseFileLogError(file)
+
+	state, err := NewUploadState(org, objectMeta.ObjectType, objectMeta.ObjectID, file, chunkSize)
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("unable to get file info of object file %v: %v", objFile, err))
+	}
+	if !resume {
+		return state
+	}
+
+	saved := LoadUploadState(org, objectMeta.ObjectType, objectMeta.ObjectID)
+	if saved != nil && saved.Matches(state) {
+		var existing common.MetaData
+		urlPath := path.Join("api/v1/objects/", org, objectMeta.ObjectType, objectMeta.ObjectID)
+		if httpCode := cliutils.ExchangeGet("Model Management Service", cliutils.GetMMSUrl(), urlPath, cliutils.OrgAndCreds(org, userPw), []int{200, 404}, &existing); httpCode == 200 {
+			state.Offset = saved.Offset
+			msgPrinter.Printf("Resuming the upload at %v of %v.", cliutils.FormatByteSize(state.Offset), cliutils.FormatByteSize(state.FileSize))
+			msgPrinter.Println()
+			return state
+		}
+	}
+	msgPrinter.Printf("There is no interrupted upload of %v to resume for object %v, it is uploaded from the beginning.", objFile, objectMeta.ObjectID)
+	msgPrinter.Println()
+	return state
+}
+
+// Uploads the object data in chunks from the offset of the upload state, saving the state after each chunk that the MMS
+// received so that an interrupted upload can be resumed.
+func uploadDataByChunk(mmsUrl string, creds string, file *os.File, state *UploadState) {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
 
@@ -662,10 +724,16 @@ func uploadDataByChunk(mmsUrl string, creds string, chunkSize int, file *os.File
 	cliutils.Verbose(apiMsg)
 
 	closeRequest := false
-	startOffset := int64(0)
-	totalSent := int64(0)
+	chunkSize := state.ChunkSize
+	startOffset := state.Offset
+	totalSent := state.Offset
 	headers := make(map[string]string)
 
+	// the message that ends the upload when it fails, with the way to continue it
+	resumeMsg := msgPrinter.Sprintf("%v of %v were uploaded, run the same publish command with --resume to continue the upload.", cliutils.FormatByteSize(state.Offset), cliutils.FormatByteSize(fileInfo.Size()))
+	uploadMsg := msgPrinter.Sprintf("Uploading")
+	cliutils.PrintProgress(uploadMsg, totalSent, fileInfo.Size())
+
 	var endOffset int64
 	var dataLength int64
 	var mmsOwnerID string
@@ -691,12 +759,6 @@ func uploadDataByChunk(mmsUrl string, creds string, chunkSize int, file *os.File
 			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("read unexpected length of data (read data length: %d, expected data length: %d) from object file %v from offset %d: %v", n, dataLength, fileInfo.Name(), startOffset, err))
 		}
 
-		msgPrinter.Printf("\rUploading: %.2f %s", float32(totalSent+dataLength)/float32(fileInfo.Size())*100, "%")
-		if closeRequest {
-			// Clear the progress info when the file has been fully uploaded
-			msgPrinter.Printf("\r")
-		}
-
 		makeHeaderMap(headers, startOffset, endOffset, fileInfo.Size(), dataLength, mmsOwnerID, creds)
 		resp, err := makeChunkUploadRequest(httpClient, mmsUrl, headers, chunkData, closeRequest)
 
@@ -718,20 +780,33 @@ func uploadDataByChunk(mmsUrl string, creds string, chunkSize int, file *os.File
 				time.Sleep(time.Duration(retryInterval) * time.Second)
 				continue
 			} else {
-				cliutils.Fatal(cliutils.HTTP_ERROR, msgPrinter.Sprintf("Encountered HTTP error: %v calling MMS REST API %v. HTTP status: %v.", err, apiMsg, http_status))
+				cliutils.ClearProgress()
+				cliutils.Fatal(cliutils.HTTP_ERROR, msgPrinter.Sprintf("Encountered HTTP error: %v calling MMS REST API %v. HTTP status: %v. %v", err, apiMsg, http_status, resumeMsg))
 			}
 		} else if err != nil {
-			cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("Error during calling MMS REST API %v: %s", apiMsg, err.Error()))
+			cliutils.ClearProgress()
+			cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("Error during calling MMS REST API %v: %s. %v", apiMsg, err.Error(), resumeMsg))
 		}
 
 		if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusTemporaryRedirect {
-			cliutils.Fatal(cliutils.HTTP_ERROR, msgPrinter.Sprintf("bad HTTP code %d from %s", resp.StatusCode, apiMsg))
+			cliutils.ClearProgress()
+			cliutils.Fatal(cliutils.HTTP_ERROR, msgPrinter.Sprintf("bad HTTP code %d from %s. %v", resp.StatusCode, apiMsg, resumeMsg))
 		} else if resp.StatusCode == http.StatusNoContent {
 			totalSent += dataLength
 			startOffset += dataLength
+			// the retries are counted for each chunk, so that a long upload over a flaky link is not stopped by errors spread over its chunks
+			retryCount = 0
+
+			state.Offset = startOffset
+			SaveUploadState(state)
+			resumeMsg = msgPrinter.Sprintf("%v of %v were uploaded, run the same publish command with --resume to continue the upload.", cliutils.FormatByteSize(state.Offset), cliutils.FormatByteSize(fileInfo.Size()))
+			cliutils.PrintProgress(uploadMsg, totalSent, fileInfo.Size())
 		}
 	}
 
+	// Clear the progress info when the file has been fully uploaded
+	cliutils.ClearProgress()
+	RemoveUploadState(state.Org, state.ObjectType, state.ObjectID)
 }
 
 func makeChunkUploadRequest(httpClient *http.Client, mmsUrl string, headers map[string]string, chunkData []byte, closeRequest bool) (*http.Response, error) {
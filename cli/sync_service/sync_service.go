@@ -37,7 +37,7 @@ func Start(cw *container.ContainerWorker, org string, configFiles []string, conf
 	dc := cw.GetClient()
 
 	// Create a network for all the sync service containers.
-	network, err := dev.CreateNetwork(dc, NETWORK_NAME)
+	network, err := dev.CreateNetwork(dc, cw.GetRuntime(), NETWORK_NAME)
 	if err != nil {
 		return errors.New(msgPrinter.Sprintf("unable to create network %v for file sync service, error %v", NETWORK_NAME, err))
 	}
@@ -0,0 +1,235 @@
+package sync_service
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/i18n"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The directory under the home directory where the state of the interrupted chunked uploads is saved.
+const UPLOAD_STATE_DIR = ".hzn/mms/uploads"
+
+// The suffix of the file that a download is written to until all the data has been received.
+const DOWNLOAD_PART_SUFFIX = ".part"
+
+// The state of a chunked upload, saved after each chunk that the MMS received so that the upload can be resumed with
+// 'hzn mms object publish --resume' from the next chunk. The size and modification time of the file are used to detect
+// that the file changed since the upload started, in which case it has to be uploaded from the beginning.
+type UploadState struct {
+	Org        string `json:"org"`
+	ObjectType string `json:"objectType"`
+	ObjectID   string `json:"objectId"`
+	FileName   string `json:"fileName"`
+	FileSize   int64  `json:"fileSize"`
+	ModTime    int64  `json:"modTime"`
+	ChunkSize  int    `json:"chunkSize"`
+	Offset     int64  `json:"offset"`
+}
+
+// Returns a new upload state for the object file, with nothing uploaded yet.
+func NewUploadState(org string, objType string, objId string, file *os.File, chunkSize int) (*UploadState, error) {
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	fileName, err := filepath.Abs(file.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &UploadState{
+		Org:        org,
+		ObjectType: objType,
+		ObjectID:   objId,
+		FileName:   fileName,
+		FileSize:   fileInfo.Size(),
+		ModTime:    fileInfo.ModTime().UnixNano(),
+		ChunkSize:  chunkSize,
+	}, nil
+}
+
+// Matches returns true when the saved state is for the same upload as other, so that it can continue from the saved offset.
+func (s *UploadState) Matches(other *UploadState) bool {
+	return s.Org == other.Org && s.ObjectType == other.ObjectType && s.ObjectID == other.ObjectID && s.FileName == other.FileName &&
+		s.FileSize == other.FileSize && s.ModTime == other.ModTime && s.ChunkSize == other.ChunkSize && s.Offset < s.FileSize
+}
+
+// The state of each object is saved in its own file, named after a hash of the object so that any object id can be used.
+func uploadStateFile(org string, objType string, objId string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(org + "/" + objType + "/" + objId))
+	return filepath.Join(homeDir, UPLOAD_STATE_DIR, hex.EncodeToString(sum[:])+".json"), nil
+}
+
+// Returns the saved state of the upload of the object, nil if there is none.
+func LoadUploadState(org string, objType string, objId string) *UploadState {
+	stateFile, err := uploadStateFile(org, objType, objId)
+	if err != nil {
+		return nil
+	}
+	stateBytes, err := ioutil.ReadFile(stateFile)
+	if err != nil {
+		return nil
+	}
+	state := new(UploadState)
+	if err := json.Unmarshal(stateBytes, state); err != nil {
+		cliutils.Verbose(i18n.GetMessagePrinter().Sprintf("Ignoring the upload state in %v: %v", stateFile, err))
+		return nil
+	}
+	return state
+}
+
+// Saves the state of the upload. A failure is not fatal, it only prevents the upload from being resumed.
+func SaveUploadState(state *UploadState) {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	stateFile, err := uploadStateFile(state.Org, state.ObjectType, state.ObjectID)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(stateFile), 0700)
+	}
+	var stateBytes []byte
+	if err == nil {
+		stateBytes, err = json.Marshal(state)
+	}
+	if err == nil {
+		err = ioutil.WriteFile(stateFile, stateBytes, 0600)
+	}
+	if err != nil {
+		cliutils.Verbose(msgPrinter.Sprintf("Unable to save the state of the upload, it cannot be resumed: %v", err))
+	}
+}
+
+// Removes the saved state of the upload of the object once it is complete.
+func RemoveUploadState(org string, objType string, objId string) {
+	if stateFile, err := uploadStateFile(org, objType, objId); err == nil {
+		if err := os.Remove(stateFile); err != nil && !os.IsNotExist(err) {
+			cliutils.Verbose(i18n.GetMessagePrinter().Sprintf("Unable to remove the upload state %v: %v", stateFile, err))
+		}
+	}
+}
+
+// downloadDataByChunk downloads the size bytes of the object data to partFile in chunks, using http range requests. When
+// resume is true and partFile already holds the beginning of the data from an interrupted download, only the rest of the
+// data is downloaded. A chunk that fails because of a network error is retried from the last byte received.
+func downloadDataByChunk(mmsUrl string, creds string, chunkSize int64, size int64, partFile string, resume bool) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	offset := int64(0)
+	if resume {
+		if fi, err := os.Stat(partFile); err == nil && fi.Size() <= size {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+			offset = fi.Size()
+			msgPrinter.Printf("Resuming the download at %v of %v.", cliutils.FormatByteSize(offset), cliutils.FormatByteSize(size))
+			msgPrinter.Println()
+		}
+	}
+
+	file, err := os.OpenFile(partFile, flags, 0600)
+	if err != nil {
+		cliutils.Fatal(cliutils.FILE_IO_ERROR, msgPrinter.Sprintf("unable to open file %v: %v", partFile, err))
+	}
+	defer file.Close()
+
+	// get retry count and retry interval from env
+	maxRetries, retryInterval, err := cliutils.GetHttpRetryParameters(5, 2)
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, err.Error())
+	}
+
+	httpClient := cliutils.GetHTTPClient(config.HTTPRequestTimeoutS)
+	if err := cliutils.TrustIcpCert(httpClient); err != nil {
+		cliutils.Fatal(cliutils.FILE_IO_ERROR, err.Error())
+	}
+
+	apiMsg := http.MethodGet + " " + mmsUrl
+	cliutils.Verbose(apiMsg)
+
+	retryCount := 0
+	for offset < size {
+		endOffset := offset + chunkSize - 1
+		if endOffset >= size {
+			endOffset = size - 1
+		}
+
+		received, err := downloadChunk(httpClient, mmsUrl, creds, offset, endOffset, size, file)
+		offset += received
+
+		if err != nil {
+			if received > 0 {
+				// the link works again, start counting the retries from zero
+				retryCount = 0
+			}
+			if retryCount < maxRetries {
+				retryCount++
+				cliutils.ClearProgress()
+				cliutils.Verbose(msgPrinter.Sprintf("Encountered error: %v calling MMS REST API %v. Will retry from offset %d.", err, apiMsg, offset))
+				time.Sleep(time.Duration(retryInterval) * time.Second)
+				continue
+			}
+			cliutils.ClearProgress()
+			cliutils.Fatal(cliutils.HTTP_ERROR, msgPrinter.Sprintf("Encountered error: %v calling MMS REST API %v. %v of %v were saved in %v, run the same download command with --resume to continue the download.", err, apiMsg, cliutils.FormatByteSize(offset), cliutils.FormatByteSize(size), partFile))
+		}
+		retryCount = 0
+	}
+	cliutils.ClearProgress()
+}
+
+// Downloads the bytes from offset to endOffset of the object data and appends them to file. Returns the number of
+// bytes appended, even when the transfer fails part way, so that a retry can continue from there.
+func downloadChunk(httpClient *http.Client, mmsUrl string, creds string, offset int64, endOffset int64, size int64, file *os.File) (int64, error) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	req, err := http.NewRequest(http.MethodGet, mmsUrl, nil)
+	if err != nil {
+		cliutils.Fatal(cliutils.HTTP_ERROR, msgPrinter.Sprintf("%s new request failed: %v", http.MethodGet+" "+mmsUrl, err))
+	}
+	req.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", offset, endOffset))
+	req.Header.Add("Authorization", fmt.Sprintf("Basic %v", base64.StdEncoding.EncodeToString([]byte(creds))))
+
+	resp, err := httpClient.Do(req)
+	if resp != nil && resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	if exchange.IsTransportError(resp, err) {
+		return 0, err
+	} else if err != nil {
+		cliutils.Fatal(cliutils.HTTP_ERROR, msgPrinter.Sprintf("Error during calling MMS REST API %v: %v", http.MethodGet+" "+mmsUrl, err))
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// the whole data is returned when the range covers it, which can only be used from the beginning
+		if offset != 0 {
+			cliutils.Fatal(cliutils.HTTP_ERROR, msgPrinter.Sprintf("the Model Management Service does not support downloading part of the object data, run the download command without --resume"))
+		}
+	case http.StatusNotFound:
+		cliutils.Fatal(cliutils.NOT_FOUND, msgPrinter.Sprintf("the data of the object is not found in the Model Management Service"))
+	default:
+		cliutils.Fatal(cliutils.HTTP_ERROR, msgPrinter.Sprintf("bad HTTP code %d from %s", resp.StatusCode, http.MethodGet+" "+mmsUrl))
+	}
+
+	body := cliutils.DisplayProgressFrom(resp.Body, offset, size, msgPrinter.Sprintf("Downloading"))
+	received, err := io.Copy(file, io.LimitReader(body, endOffset-offset+1))
+	if err == nil && received != endOffset-offset+1 {
+		err = io.ErrUnexpectedEOF
+	}
+	return received, err
+}
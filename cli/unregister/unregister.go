@@ -8,12 +8,15 @@ import (
 	"github.com/open-horizon/anax/api"
 	"github.com/open-horizon/anax/cli/agreement"
 	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/cli/sync_service"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/persistence"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
@@ -27,14 +30,25 @@ type ApiAttributes struct {
 	Attributes []ApiAttribute `json:"attributes"`
 }
 
-// DoIt unregisters this Horizon edge node and resets it so it can be registered again
-func DoIt(forceUnregister, removeNodeUnregister bool, deepClean bool, timeout int, container bool) {
+// DoIt unregisters this Horizon edge node and resets it so it can be registered again. When an export path is given, the
+// data volumes of the services are exported to it before the services are removed, and published as objects of the
+// export object type in the model management service if one is given.
+func DoIt(forceUnregister, removeNodeUnregister bool, deepClean bool, timeout int, container bool, exportPath string, exportObjectType string, userPw string) {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
 
 	if !deepClean && container {
 		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Cannot use -C flag if not performing a deep clean. Must specify -D flag to use -C flag."))
 	}
+	if exportObjectType != "" && exportPath == "" {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Cannot use --export-object-type without --export-path."))
+	}
+	if exportPath != "" && !path.IsAbs(exportPath) {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("The export path %v must be an absolute path.", exportPath))
+	}
+	if exportObjectType != "" {
+		userPw = *cliutils.RequiredWithDefaultEnvVar(&userPw, "HZN_EXCHANGE_USER_AUTH", msgPrinter.Sprintf("exchange user authentication must be specified with either the -u flag or HZN_EXCHANGE_USER_AUTH to publish the exported volumes"))
+	}
 
 	if !forceUnregister {
 		cliutils.ConfirmRemove(msgPrinter.Sprintf("Are you sure you want to unregister this Horizon node?"))
@@ -75,7 +89,7 @@ func DoIt(forceUnregister, removeNodeUnregister bool, deepClean bool, timeout in
 		msgPrinter.Println()
 
 		// call horizon DELETE /node api, default timeout is to wait forever.
-		unregErr := DeleteHorizonNode(removeNodeUnregister, deepClean, timeout)
+		unregErr := DeleteHorizonNodeWithExport(removeNodeUnregister, deepClean, timeout, exportPath)
 
 		// deep clean if anax failed to do it
 		if unregErr != nil {
@@ -110,12 +124,25 @@ func DoIt(forceUnregister, removeNodeUnregister bool, deepClean bool, timeout in
 				msgPrinter.Printf("Horizon node unregistered. You may now run 'hzn register ...' again, if desired.")
 				msgPrinter.Println()
 			}
+
+			if exportPath != "" {
+				msgPrinter.Printf("The data volumes of the services were exported to %v.", exportPath)
+				msgPrinter.Println()
+				if exportObjectType != "" {
+					publishExportedVolumes(*horDevice.Org, *horDevice.Id, userPw, exportPath, exportObjectType)
+				}
+			}
 		}
 	}
 }
 
 // call horizon DELETE /node api, timeout in 3 minutes.
 func DeleteHorizonNode(removeNodeUnregister bool, deepClean bool, timeout int) error {
+	return DeleteHorizonNodeWithExport(removeNodeUnregister, deepClean, timeout, "")
+}
+
+// call horizon DELETE /node api, exporting the data volumes of the services to the export path if it is not empty.
+func DeleteHorizonNodeWithExport(removeNodeUnregister bool, deepClean bool, timeout int, exportPath string) error {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
 
@@ -127,10 +154,14 @@ func DeleteHorizonNode(removeNodeUnregister bool, deepClean bool, timeout int) e
 	if deepClean {
 		deepCleanOption = "&deepClean=true"
 	}
+	exportOption := ""
+	if exportPath != "" {
+		exportOption = "&exportPath=" + url.QueryEscape(exportPath)
+	}
 
 	c := make(chan string, 1)
 	go func() {
-		httpCode, err := cliutils.HorizonDelete("node?block=true"+removeNodeOption+deepCleanOption, []int{200, 204}, []int{503}, true)
+		httpCode, err := cliutils.HorizonDelete("node?block=true"+removeNodeOption+deepCleanOption+exportOption, []int{200, 204}, []int{503}, true)
 		if httpCode == http.StatusServiceUnavailable {
 			msgPrinter.Printf("WARNING: The node is unregistered, but an error occurred during unregistration.")
 			msgPrinter.Println()
@@ -391,3 +422,27 @@ func backupEventLogs() error {
 
 	return nil
 }
+
+// Publish the volumes exported by the agent as objects in the model management service, so that they can be downloaded
+// once the node is decommissioned. The agent writes a tar file per service volume in a directory per agreement, the
+// object id is made of the node id, the agreement id and the file name.
+func publishExportedVolumes(org string, nodeId string, userPw string, exportPath string, objType string) {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	files, err := filepath.Glob(path.Join(exportPath, "*", "*.tar"))
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("Unable to list the exported volumes in %v: %v", exportPath, err))
+	} else if len(files) == 0 {
+		msgPrinter.Printf("No volumes were exported to %v, there is nothing to publish.", exportPath)
+		msgPrinter.Println()
+		return
+	}
+
+	for _, file := range files {
+		agreementId := path.Base(path.Dir(file))
+		objId := fmt.Sprintf("%v-%v-%v", nodeId, agreementId, strings.TrimSuffix(path.Base(file), ".tar"))
+		sync_service.ObjectPublish(org, userPw, objType, objId, "", "", file, false, sync_service.DefaultChunkSize, true, "", "", "", false)
+		msgPrinter.Printf("Published %v as object %v of type %v.", file, objId, objType)
+		msgPrinter.Println()
+	}
+}
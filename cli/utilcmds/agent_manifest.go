@@ -0,0 +1,407 @@
+package utilcmds
+
+import (
+	"encoding/base64"
+	"fmt"
+	agentk8s "github.com/open-horizon/anax/agent-install/k8s"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/kube_operator"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"strconv"
+	"strings"
+)
+
+// The types of agent install manifests.
+const (
+	AGENT_MANIFEST_K8S    = "k8s"
+	AGENT_MANIFEST_DEVICE = "device"
+)
+
+// The names and locations of the agent resources, the same as the ones agent-install.sh creates.
+const (
+	DEFAULT_AGENT_NAMESPACE         = "openhorizon-agent"
+	DEFAULT_AGENT_STORAGE_CLASS     = "gp2"
+	DEFAULT_AGENT_PVC_ACCESS_MODE   = "ReadWriteOnce"
+	AGENT_SERVICE_ACCOUNT_NAME      = "agent-service-account"
+	AGENT_CLUSTER_ROLE_BINDING_NAME = "openhorizon-agent-cluster-rule"
+	AGENT_SECRET_NAME               = "openhorizon-agent-secrets"
+	AGENT_CONFIGMAP_NAME            = "openhorizon-agent-config"
+	AGENT_CERT_FILE_NAME            = "agent-install.crt"
+	AGENT_CERT_DIR                  = "/etc/default/cert"
+	AGENT_DEFAULTS_FILE             = "/etc/default/horizon"
+	AGENT_SYSTEMD_DROPIN_FILE       = "/etc/systemd/system/horizon.service.d/agent-manifest.conf"
+	AGENT_PORT                      = 8510
+	AGENT_K8S_CRONJOB_API           = "batch/v1"
+)
+
+func GetAgentManifestTypes() []string {
+	return []string{AGENT_MANIFEST_K8S, AGENT_MANIFEST_DEVICE}
+}
+
+// The settings of an agent install manifest. The ones that are empty are not set in the manifest, or get the same
+// default value as with agent-install.sh.
+type AgentManifestSettings struct {
+	Type             string
+	Org              string
+	ExchangeUrl      string
+	CssUrl           string
+	AgbotUrl         string
+	NodeId           string
+	CertFile         string
+	Namespace        string
+	NamespaceScoped  bool
+	OpenShift        bool
+	Image            string
+	AutoUpgradeImage string
+	RegistryMirror   string
+	StorageClass     string
+	PVCAccessMode    string
+	CPURequest       string
+	CPULimit         string
+	MemoryRequest    string
+	MemoryLimit      string
+	HTTPProxy        string
+	HTTPSProxy       string
+	NoProxy          string
+}
+
+// A file of a device manifest, with its absolute path on the node.
+type manifestFile struct {
+	Path    string
+	Content string
+	Mode    os.FileMode
+}
+
+// AgentManifest displays the manifest that installs the agent with the settings. The k8s manifest holds the kubernetes
+// resources of the edge cluster agent, from the same templates as agent-install.sh, to apply with kubectl. The device
+// manifest holds /etc/default/horizon and a systemd drop-in for the horizon service of the horizon package, and is
+// written under outputDir when it is set.
+func AgentManifest(settings AgentManifestSettings, outputDir string) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if settings.ExchangeUrl == "" {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("the Horizon Exchange URL must be specified with --exchange-url or HZN_EXCHANGE_URL."))
+	} else if settings.CssUrl == "" {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("the Model Management Service URL must be specified with --css-url or HZN_FSS_CSSURL."))
+	}
+
+	var certBytes []byte
+	if settings.CertFile != "" {
+		var err error
+		if certBytes, err = ioutil.ReadFile(settings.CertFile); err != nil {
+			cliutils.Fatal(cliutils.FILE_IO_ERROR, msgPrinter.Sprintf("unable to read certificate file %v: %v", settings.CertFile, err))
+		}
+	}
+
+	switch settings.Type {
+	case AGENT_MANIFEST_K8S:
+		if outputDir != "" {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("--output-dir is only used with --type %v, the %v manifest is displayed to apply with 'kubectl apply -f -'.", AGENT_MANIFEST_DEVICE, AGENT_MANIFEST_K8S))
+		}
+		manifest, err := k8sAgentManifest(settings, certBytes)
+		if err != nil {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, err.Error())
+		}
+		fmt.Print(manifest)
+	case AGENT_MANIFEST_DEVICE:
+		files, err := deviceAgentManifest(settings, certBytes)
+		if err != nil {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, err.Error())
+		}
+		if outputDir == "" {
+			for _, file := range files {
+				fmt.Printf("# %v\n%v\n", file.Path, file.Content)
+			}
+			return
+		}
+		for _, file := range files {
+			path := filepath.Join(outputDir, file.Path)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				cliutils.Fatal(cliutils.FILE_IO_ERROR, msgPrinter.Sprintf("unable to create directory %v: %v", filepath.Dir(path), err))
+			} else if err := ioutil.WriteFile(path, []byte(file.Content), file.Mode); err != nil {
+				cliutils.Fatal(cliutils.FILE_IO_ERROR, msgPrinter.Sprintf("unable to write file %v: %v", path, err))
+			}
+			msgPrinter.Printf("Wrote %v", path)
+			msgPrinter.Println()
+		}
+		msgPrinter.Printf("Run 'systemctl daemon-reload' and 'systemctl restart horizon' on the node for the settings to be used.")
+		msgPrinter.Println()
+	}
+}
+
+// Returns the content of /etc/default/horizon for the settings, the same variables as agent-install.sh sets.
+func agentDefaults(settings AgentManifestSettings, certPath string) string {
+	defaults := "HZN_EXCHANGE_URL=" + settings.ExchangeUrl + "\n"
+	defaults += "HZN_FSS_CSSURL=" + settings.CssUrl + "\n"
+	if settings.AgbotUrl != "" {
+		defaults += "HZN_AGBOT_URL=" + settings.AgbotUrl + "\n"
+	}
+	if settings.NodeId != "" {
+		defaults += "HZN_DEVICE_ID=" + settings.NodeId + "\n"
+		defaults += "HZN_NODE_ID=" + settings.NodeId + "\n"
+	}
+	if certPath != "" {
+		defaults += "HZN_MGMT_HUB_CERT_PATH=" + certPath + "\n"
+	}
+	defaults += "HZN_AGENT_PORT=" + strconv.Itoa(AGENT_PORT) + "\n"
+	return defaults
+}
+
+// Returns the proxy environment variables of the settings, in the order they are set.
+func agentProxyEnv(settings AgentManifestSettings) [][2]string {
+	env := [][2]string{}
+	for _, v := range [][2]string{{"HTTP_PROXY", settings.HTTPProxy}, {"HTTPS_PROXY", settings.HTTPSProxy}, {"NO_PROXY", settings.NoProxy}} {
+		if v[1] != "" {
+			env = append(env, v)
+		}
+	}
+	return env
+}
+
+// Returns the parsed resource quantity, nil if it is not set.
+func agentQuantity(flag string, value string) (*resource.Quantity, error) {
+	if value == "" {
+		return nil, nil
+	}
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return nil, fmt.Errorf(i18n.GetMessagePrinter().Sprintf("invalid %v %v: %v", flag, value, err))
+	}
+	return &q, nil
+}
+
+// Returns the files of the device manifest: /etc/default/horizon, the certificate and the systemd drop-in that sets the
+// proxy and the resource limits of the horizon service.
+func deviceAgentManifest(settings AgentManifestSettings, certBytes []byte) ([]manifestFile, error) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	for _, f := range [][2]string{{"--namespace", settings.Namespace}, {"--image", settings.Image}, {"--auto-upgrade-image", settings.AutoUpgradeImage}, {"--registry-mirror", settings.RegistryMirror}, {"--storage-class", settings.StorageClass}, {"--pvc-access-mode", settings.PVCAccessMode}, {"--cpu-request", settings.CPURequest}} {
+		if f[1] != "" {
+			return nil, fmt.Errorf(msgPrinter.Sprintf("%v is only used with --type %v.", f[0], AGENT_MANIFEST_K8S))
+		}
+	}
+	if settings.NamespaceScoped || settings.OpenShift {
+		return nil, fmt.Errorf(msgPrinter.Sprintf("--namespace-scoped and --openshift are only used with --type %v.", AGENT_MANIFEST_K8S))
+	}
+
+	files := []manifestFile{}
+	certPath := ""
+	if certBytes != nil {
+		certPath = AGENT_CERT_DIR + "/" + AGENT_CERT_FILE_NAME
+		files = append(files, manifestFile{Path: certPath, Content: string(certBytes), Mode: 0644})
+	}
+	files = append(files, manifestFile{Path: AGENT_DEFAULTS_FILE, Content: agentDefaults(settings, certPath), Mode: 0644})
+
+	dropin := ""
+	for _, v := range agentProxyEnv(settings) {
+		dropin += fmt.Sprintf("Environment=\"%v=%v\"\n", v[0], v[1])
+	}
+	if cpu, err := agentQuantity("--cpu-limit", settings.CPULimit); err != nil {
+		return nil, err
+	} else if cpu != nil {
+		// a kubernetes cpu is 100% of a cpu for systemd
+		dropin += fmt.Sprintf("CPUQuota=%v%%\n", (cpu.MilliValue()+9)/10)
+	}
+	if mem, err := agentQuantity("--memory-request", settings.MemoryRequest); err != nil {
+		return nil, err
+	} else if mem != nil {
+		dropin += fmt.Sprintf("MemoryLow=%v\n", mem.Value())
+	}
+	if mem, err := agentQuantity("--memory-limit", settings.MemoryLimit); err != nil {
+		return nil, err
+	} else if mem != nil {
+		dropin += fmt.Sprintf("MemoryMax=%v\n", mem.Value())
+	}
+	if dropin != "" {
+		files = append(files, manifestFile{Path: AGENT_SYSTEMD_DROPIN_FILE, Content: "[Service]\n" + dropin, Mode: 0644})
+	}
+	return files, nil
+}
+
+// Fills in an agent template the same way as agent-install.sh: the sections between the '# START_<section>' and
+// '# END_<section>' lines are removed, then the '__<name>__' placeholders are replaced with the values.
+func fillAgentTemplate(template string, removeSections []string, values map[string]string) string {
+	lines := []string{}
+	removing := ""
+	for _, line := range strings.Split(template, "\n") {
+		if removing != "" {
+			if strings.Contains(line, "END_"+removing) {
+				removing = ""
+			}
+			continue
+		}
+		for _, section := range removeSections {
+			if strings.Contains(line, "START_"+section) {
+				removing = section
+			}
+		}
+		if removing == "" {
+			lines = append(lines, line)
+		}
+	}
+
+	replacements := []string{}
+	for name, value := range values {
+		replacements = append(replacements, "__"+name+"__", value)
+	}
+	return strings.NewReplacer(replacements...).Replace(strings.Join(lines, "\n"))
+}
+
+// Returns the kubernetes object of a filled in agent template.
+func agentTemplateObject(name string, template string) (map[string]interface{}, error) {
+	obj := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(template), &obj); err != nil {
+		return nil, fmt.Errorf(i18n.GetMessagePrinter().Sprintf("unable to parse the %v template: %v", name, err))
+	}
+	return obj, nil
+}
+
+// Returns the first container of the pod template of a deployment or a cronjob.
+func agentContainer(obj map[string]interface{}, path ...string) map[string]interface{} {
+	m := obj
+	for _, key := range path {
+		m, _ = m[key].(map[string]interface{})
+	}
+	if containers, ok := m["containers"].([]interface{}); ok && len(containers) > 0 {
+		container, _ := containers[0].(map[string]interface{})
+		return container
+	}
+	return nil
+}
+
+// Returns the multi-document yaml of the kubernetes resources of the edge cluster agent.
+func k8sAgentManifest(settings AgentManifestSettings, certBytes []byte) (string, error) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if settings.Org == "" {
+		return "", fmt.Errorf(msgPrinter.Sprintf("the organization must be specified with --org or HZN_ORG_ID."))
+	} else if settings.Image == "" {
+		return "", fmt.Errorf(msgPrinter.Sprintf("the image of the edge cluster agent must be specified with --image."))
+	}
+	if settings.Namespace == "" {
+		settings.Namespace = DEFAULT_AGENT_NAMESPACE
+	}
+	if settings.StorageClass == "" {
+		settings.StorageClass = DEFAULT_AGENT_STORAGE_CLASS
+	}
+	if settings.PVCAccessMode == "" {
+		settings.PVCAccessMode = DEFAULT_AGENT_PVC_ACCESS_MODE
+	}
+
+	resources := map[string]interface{}{}
+	for _, r := range []struct{ flag, value, kind, name string }{
+		{"--cpu-request", settings.CPURequest, "requests", "cpu"},
+		{"--memory-request", settings.MemoryRequest, "requests", "memory"},
+		{"--cpu-limit", settings.CPULimit, "limits", "cpu"},
+		{"--memory-limit", settings.MemoryLimit, "limits", "memory"},
+	} {
+		if q, err := agentQuantity(r.flag, r.value); err != nil {
+			return "", err
+		} else if q != nil {
+			if resources[r.kind] == nil {
+				resources[r.kind] = map[string]interface{}{}
+			}
+			resources[r.kind].(map[string]interface{})[r.name] = q.String()
+		}
+	}
+
+	image := kube_operator.RewriteImageRegistry(settings.Image, settings.RegistryMirror)
+	registryHost, _, _, _ := cutil.ParseDockerImagePath(image)
+
+	objects := []map[string]interface{}{}
+
+	namespace := map[string]interface{}{"apiVersion": "v1", "kind": "Namespace", "metadata": map[string]interface{}{"name": settings.Namespace}}
+	if settings.OpenShift && settings.Namespace != DEFAULT_AGENT_NAMESPACE {
+		namespace["metadata"].(map[string]interface{})["annotations"] = map[string]interface{}{"openshift.io/sa.scc.uid-range": "1000/1000", "openshift.io/sa.scc.supplemental-groups": "1000/1000"}
+	}
+	objects = append(objects, namespace)
+	objects = append(objects, map[string]interface{}{"apiVersion": "v1", "kind": "ServiceAccount", "metadata": map[string]interface{}{"name": AGENT_SERVICE_ACCOUNT_NAME, "namespace": settings.Namespace}})
+	objects = append(objects, map[string]interface{}{
+		"apiVersion": "rbac.authorization.k8s.io/v1",
+		"kind":       "ClusterRoleBinding",
+		"metadata":   map[string]interface{}{"name": settings.Namespace + "-" + AGENT_CLUSTER_ROLE_BINDING_NAME},
+		"roleRef":    map[string]interface{}{"apiGroup": "rbac.authorization.k8s.io", "kind": "ClusterRole", "name": "cluster-admin"},
+		"subjects":   []interface{}{map[string]interface{}{"kind": "ServiceAccount", "name": AGENT_SERVICE_ACCOUNT_NAME, "namespace": settings.Namespace}},
+	})
+
+	certPath := ""
+	if certBytes != nil {
+		certPath = AGENT_CERT_DIR + "/" + AGENT_CERT_FILE_NAME
+		objects = append(objects, map[string]interface{}{"apiVersion": "v1", "kind": "Secret", "metadata": map[string]interface{}{"name": AGENT_SECRET_NAME, "namespace": settings.Namespace}, "data": map[string]interface{}{AGENT_CERT_FILE_NAME: base64.StdEncoding.EncodeToString(certBytes)}})
+	}
+	objects = append(objects, map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": AGENT_CONFIGMAP_NAME, "namespace": settings.Namespace}, "data": map[string]interface{}{"horizon": agentDefaults(settings, certPath)}})
+
+	pvc, err := agentTemplateObject("persistentClaim", fillAgentTemplate(agentk8s.PersistentClaimTemplate, nil, map[string]string{
+		"AgentNameSpace": strconv.Quote(settings.Namespace),
+		"StorageClass":   strconv.Quote(settings.StorageClass),
+		"PVCAccessMode":  settings.PVCAccessMode,
+	}))
+	if err != nil {
+		return "", err
+	}
+	objects = append(objects, pvc)
+
+	removeSections := []string{}
+	if settings.OpenShift {
+		// the permissions of the volume are set by the security context constraints of openshift
+		removeSections = append(removeSections, "NOT_FOR_OCP")
+	}
+	if certBytes == nil {
+		removeSections = append(removeSections, "CERT_VOL")
+	}
+	deployment, err := agentTemplateObject("deployment", fillAgentTemplate(agentk8s.DeploymentTemplate, removeSections, map[string]string{
+		"AgentNameSpace":    strconv.Quote(settings.Namespace),
+		"NamespaceScoped":   strconv.Quote(strconv.FormatBool(settings.NamespaceScoped)),
+		"OrgId":             strconv.Quote(settings.Org),
+		"ImagePath":         strconv.Quote(image),
+		"ImageRegistryHost": strconv.Quote(registryHost),
+	}))
+	if err != nil {
+		return "", err
+	}
+	if container := agentContainer(deployment, "spec", "template", "spec"); container != nil {
+		env, _ := container["env"].([]interface{})
+		for _, v := range agentProxyEnv(settings) {
+			env = append(env, map[string]interface{}{"name": v[0], "value": v[1]})
+		}
+		container["env"] = env
+		if len(resources) != 0 {
+			container["resources"] = resources
+		}
+	}
+	objects = append(objects, deployment)
+
+	if settings.AutoUpgradeImage != "" {
+		cronjob, err := agentTemplateObject("auto-upgrade-cronjob", fillAgentTemplate(agentk8s.AutoUpgradeCronjobTemplate, nil, map[string]string{
+			"KubernetesApi":  AGENT_K8S_CRONJOB_API,
+			"ServiceAccount": strconv.Quote(AGENT_SERVICE_ACCOUNT_NAME),
+			"AgentNameSpace": strconv.Quote(settings.Namespace),
+			"ImagePath":      kube_operator.RewriteImageRegistry(settings.AutoUpgradeImage, settings.RegistryMirror),
+		}))
+		if err != nil {
+			return "", err
+		}
+		// agent-install.sh applies the cronjob in the agent namespace
+		cronjob["metadata"].(map[string]interface{})["namespace"] = settings.Namespace
+		objects = append(objects, cronjob)
+	}
+
+	docs := []string{}
+	for _, obj := range objects {
+		doc, err := yaml.Marshal(obj)
+		if err != nil {
+			return "", fmt.Errorf(msgPrinter.Sprintf("unable to marshal %v: %v", obj["kind"], err))
+		}
+		docs = append(docs, string(doc))
+	}
+	return strings.Join(docs, "---\n"), nil
+}
@@ -0,0 +1,107 @@
+//go:build unit
+// +build unit
+
+package utilcmds
+
+import (
+	"sigs.k8s.io/yaml"
+	"strings"
+	"testing"
+)
+
+func Test_fillAgentTemplate(t *testing.T) {
+	template := "a: __Value__\n# START_CERT_VOL\nb: cert\n# END_CERT_VOL\nc: __Value__\n"
+	if filled := fillAgentTemplate(template, []string{"CERT_VOL"}, map[string]string{"Value": "v"}); filled != "a: v\nc: v\n" {
+		t.Errorf("wrong filled template %q", filled)
+	}
+	if filled := fillAgentTemplate(template, nil, map[string]string{"Value": "v"}); !strings.Contains(filled, "b: cert") {
+		t.Errorf("the section should be kept, got %q", filled)
+	}
+}
+
+func Test_k8sAgentManifest(t *testing.T) {
+	settings := AgentManifestSettings{
+		Type:           AGENT_MANIFEST_K8S,
+		Org:            "myorg",
+		ExchangeUrl:    "https://exchange/v1",
+		CssUrl:         "https://css/",
+		Image:          "registry.example.com/openhorizon/amd64_anax_k8s:2.31.0",
+		RegistryMirror: "mirror.local:5000",
+		CPULimit:       "500m",
+		MemoryRequest:  "256Mi",
+		HTTPSProxy:     "http://proxy:3128",
+	}
+	manifest, err := k8sAgentManifest(settings, nil)
+	if err != nil {
+		t.Fatalf("unable to create manifest, error: %v", err)
+	}
+
+	kinds := []string{}
+	var deployment map[string]interface{}
+	for _, doc := range strings.Split(manifest, "---\n") {
+		obj := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			t.Fatalf("unable to parse %v, error: %v", doc, err)
+		}
+		kinds = append(kinds, obj["kind"].(string))
+		if obj["kind"] == "Deployment" {
+			deployment = obj
+		}
+		if ns := obj["metadata"].(map[string]interface{})["namespace"]; obj["kind"] != "Namespace" && obj["kind"] != "ClusterRoleBinding" && ns != DEFAULT_AGENT_NAMESPACE {
+			t.Errorf("wrong namespace %v of %v", ns, obj["kind"])
+		}
+	}
+	if strings.Join(kinds, ",") != "Namespace,ServiceAccount,ClusterRoleBinding,ConfigMap,PersistentVolumeClaim,Deployment" {
+		t.Errorf("wrong resources %v", kinds)
+	}
+
+	container := agentContainer(deployment, "spec", "template", "spec")
+	if image := container["image"]; image != "mirror.local:5000/openhorizon/amd64_anax_k8s:2.31.0" {
+		t.Errorf("wrong image %v", image)
+	}
+	if resources := container["resources"].(map[string]interface{}); resources["limits"].(map[string]interface{})["cpu"] != "500m" || resources["requests"].(map[string]interface{})["memory"] != "256Mi" {
+		t.Errorf("wrong resources %v", resources)
+	}
+	env := map[string]interface{}{}
+	for _, e := range container["env"].([]interface{}) {
+		env[e.(map[string]interface{})["name"].(string)] = e.(map[string]interface{})["value"]
+	}
+	if env["HZN_ORG_ID"] != "myorg" || env["HTTPS_PROXY"] != "http://proxy:3128" || env["AGENT_CLUSTER_IMAGE_REGISTRY_HOST"] != "mirror.local:5000" || env["HZN_MGMT_HUB_CERT_PATH"] != nil {
+		t.Errorf("wrong env %v", env)
+	}
+
+	// the image is required
+	settings.Image = ""
+	if _, err := k8sAgentManifest(settings, nil); err == nil {
+		t.Errorf("a manifest without image should not be created")
+	}
+}
+
+func Test_deviceAgentManifest(t *testing.T) {
+	settings := AgentManifestSettings{
+		Type:        AGENT_MANIFEST_DEVICE,
+		ExchangeUrl: "https://exchange/v1",
+		CssUrl:      "https://css/",
+		CPULimit:    "1500m",
+		MemoryLimit: "1Gi",
+		HTTPProxy:   "http://proxy:3128",
+	}
+	files, err := deviceAgentManifest(settings, []byte("cert"))
+	if err != nil {
+		t.Fatalf("unable to create manifest, error: %v", err)
+	} else if len(files) != 3 {
+		t.Fatalf("wrong number of files %v, expected 3", len(files))
+	}
+	if files[1].Path != AGENT_DEFAULTS_FILE || !strings.Contains(files[1].Content, "HZN_MGMT_HUB_CERT_PATH="+AGENT_CERT_DIR+"/"+AGENT_CERT_FILE_NAME) {
+		t.Errorf("wrong defaults file %v", files[1])
+	}
+	if dropin := files[2].Content; !strings.Contains(dropin, "CPUQuota=150%") || !strings.Contains(dropin, "MemoryMax=1073741824") || !strings.Contains(dropin, `Environment="HTTP_PROXY=http://proxy:3128"`) {
+		t.Errorf("wrong systemd drop-in %v", dropin)
+	}
+
+	// the kubernetes settings are not used on a device
+	settings.RegistryMirror = "mirror.local:5000"
+	if _, err := deviceAgentManifest(settings, nil); err == nil {
+		t.Errorf("a device manifest with a registry mirror should not be created")
+	}
+}
@@ -0,0 +1,53 @@
+package utilcmds
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/i18n"
+)
+
+// The completion scripts call 'hzn --completion-bash' with the words on the command line, which returns the possible
+// sub commands, flags and values, including the names of the exchange resources the arguments refer to.
+const bashCompletionScript = `_hzn_bash_autocomplete() {
+    local cur opts
+    COMPREPLY=()
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    opts=$( ${COMP_WORDS[0]} --completion-bash ${COMP_WORDS[@]:1:$COMP_CWORD} 2>/dev/null )
+    COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
+    return 0
+}
+complete -F _hzn_bash_autocomplete hzn`
+
+const zshCompletionScript = `#compdef hzn
+_hzn() {
+    local -a opts
+    opts=(${(f)"$(${words[1]} --completion-bash ${words[2,CURRENT]} 2>/dev/null)"})
+    compadd -- $opts
+}
+compdef _hzn hzn`
+
+const fishCompletionScript = `function __hzn_complete
+    set -l args (commandline -opc)
+    set -e args[1]
+    hzn --completion-bash $args (commandline -ct) 2>/dev/null
+end
+complete -c hzn -f -a '(__hzn_complete)'`
+
+func GetCompletionShells() []string {
+	return []string{"bash", "zsh", "fish"}
+}
+
+// Completion displays the completion script for the shell. It is loaded with 'source <(hzn util completion bash)' in
+// bash and zsh, and 'hzn util completion fish | source' in fish.
+func Completion(shell string) {
+	switch shell {
+	case "bash":
+		fmt.Println(bashCompletionScript)
+	case "zsh":
+		fmt.Println(zshCompletionScript)
+	case "fish":
+		fmt.Println(fishCompletionScript)
+	default:
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, i18n.GetMessagePrinter().Sprintf("unsupported shell %v, the supported shells are %v.", shell, GetCompletionShells()))
+	}
+}
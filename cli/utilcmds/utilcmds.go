@@ -1,13 +1,17 @@
 package utilcmds
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/open-horizon/anax/cli/cliconfig"
 	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/exchangecommon"
 	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/rsapss-tool/sign"
 	"github.com/open-horizon/rsapss-tool/verify"
 	"os"
+	"strings"
 )
 
 func Sign(privKeyFilePath string) {
@@ -37,6 +41,35 @@ func Verify(pubKeyFilePath, signature string) {
 	}
 }
 
+// Sign the properties of the node policy in stdin, and the node user input in the file when it is given, with the
+// private key of the node's org. The signed node policy is sent to stdout.
+func SignNodePolicy(privKeyFilePath string, nodeId string, properties []string, userInputFile string) {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if parts := strings.Split(nodeId, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("The node id %v must be org qualified, in the form org/id.", nodeId))
+	}
+
+	var nodePol exchangecommon.NodePolicy
+	if err := json.Unmarshal(cliutils.ReadJsonFile("-"), &nodePol); err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to unmarshal the node policy in stdin: %v", err))
+	} else if err := nodePol.ValidateAndNormalize(); err != nil {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Incorrect node policy format: %v", err))
+	}
+
+	var userInput []policy.UserInput
+	if userInputFile != "" {
+		if err := json.Unmarshal(cliutils.ReadJsonFile(userInputFile), &userInput); err != nil {
+			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to unmarshal the user input in %s: %v", userInputFile, err))
+		}
+	}
+
+	if err := policy.SignNodePolicy(privKeyFilePath, nodeId, &nodePol, properties, userInput, userInputFile != ""); err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("problem signing the node policy: %v", err))
+	}
+	fmt.Println(cliutils.MarshalIndentJSON(nodePol, "util signnodepolicy"))
+}
+
 // convert the given json file to shell export commands and output it to stdout
 func ConvertConfig(configFile string) {
 	if configFile == "" {
@@ -0,0 +1,169 @@
+package wasm_deployment
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/cli/dev"
+	"github.com/open-horizon/anax/cli/plugin_registry"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/rsapss-tool/sign"
+	"os"
+	"path/filepath"
+)
+
+const WASM_DEPLOYMENT_CONFIG_TYPE = "wasm"
+const WASM_MODULE_KEY = "wasm_module"
+
+// The magic number at the start of the binary format of a WebAssembly module.
+var wasmMagic = []byte{0x00, 'a', 's', 'm'}
+
+func init() {
+	plugin_registry.Register(WASM_DEPLOYMENT_CONFIG_TYPE, NewWasmDeploymentConfigPlugin())
+}
+
+// The wasm deployment config plugin owns the deployment configs that refer to a WebAssembly module:
+//
+//	"deployment": {
+//	  "wasm_module": "mymodule.wasm",
+//	  "args": ["--verbose"]
+//	}
+//
+// The module file might be relative to the service definition file. When the service is published, the file is
+// replaced by its base64 encoded contents, which the agent runs with wasmtime.
+type WasmDeploymentConfigPlugin struct {
+}
+
+func NewWasmDeploymentConfigPlugin() plugin_registry.DeploymentConfigPlugin {
+	return new(WasmDeploymentConfigPlugin)
+}
+
+func (p *WasmDeploymentConfigPlugin) Sign(dep map[string]interface{}, privKey *rsa.PrivateKey, ctx plugin_registry.PluginContext) (bool, string, string, error) {
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if owned, err := p.Validate(dep, nil); !owned || err != nil {
+		return owned, "", "", err
+	}
+
+	// Grab the module file from the deployment config. The file might be relative to the service definition file.
+	moduleFilePath := filepath.Clean(dep[WASM_MODULE_KEY].(string))
+	if currentDir, ok := (ctx.Get("currentDir")).(string); !ok {
+		return true, "", "", errors.New(msgPrinter.Sprintf("plugin context must include 'currentDir' as the current directory of the service definition file"))
+	} else if !filepath.IsAbs(moduleFilePath) {
+		moduleFilePath = filepath.Join(currentDir, moduleFilePath)
+	}
+
+	module, err := os.ReadFile(moduleFilePath)
+	if err != nil {
+		return true, "", "", errors.New(msgPrinter.Sprintf("unable to read wasm module %v, error %v", dep[WASM_MODULE_KEY], err))
+	} else if !bytes.HasPrefix(module, wasmMagic) {
+		return true, "", "", errors.New(msgPrinter.Sprintf("%v is not a WebAssembly module in the binary format", moduleFilePath))
+	}
+	dep[WASM_MODULE_KEY] = base64.StdEncoding.EncodeToString(module)
+
+	// Stringify and sign the deployment string.
+	deployment, err := json.Marshal(dep)
+	if err != nil {
+		return true, "", "", errors.New(msgPrinter.Sprintf("failed to marshal %v deployment string %v, error %v", WASM_DEPLOYMENT_CONFIG_TYPE, dep, err))
+	}
+	depStr := string(deployment)
+
+	hasher := sha256.New()
+	_, err = hasher.Write(deployment)
+	if err != nil {
+		return true, "", "", err
+	}
+	sig, err := sign.Sha256HashOfInput(privKey, hasher)
+
+	if err != nil {
+		return true, "", "", errors.New(msgPrinter.Sprintf("problem signing %v deployment string: %v", WASM_DEPLOYMENT_CONFIG_TYPE, err))
+	}
+
+	return true, depStr, sig, nil
+}
+
+// A WebAssembly module is not run from a container image.
+func (p *WasmDeploymentConfigPlugin) GetContainerImages(dep interface{}) (bool, []string, error) {
+	owned, err := p.Validate(dep, nil)
+	return owned, []string{}, err
+}
+
+// Return the default config object, which is nil in this case.
+func (p *WasmDeploymentConfigPlugin) DefaultConfig(imageInfo interface{}) interface{} {
+	return nil
+}
+
+// Return the default cluster config object, which is nil in this case.
+func (p *WasmDeploymentConfigPlugin) DefaultClusterConfig() interface{} {
+	return nil
+}
+
+func (p *WasmDeploymentConfigPlugin) Validate(dep interface{}, cdep interface{}) (bool, error) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if dc, ok := dep.(map[string]interface{}); !ok {
+		return false, nil
+	} else if m, ok := dc[WASM_MODULE_KEY]; !ok {
+		return false, nil
+	} else if ms, ok := m.(string); !ok {
+		return true, errors.New(msgPrinter.Sprintf("%v must have a string type value, has %T", WASM_MODULE_KEY, m))
+	} else if len(ms) == 0 {
+		return true, errors.New(msgPrinter.Sprintf("%v must be a non-empty string", WASM_MODULE_KEY))
+	} else if _, ok := dc["services"]; ok {
+		return true, errors.New(msgPrinter.Sprintf("the 'deployment' field cannot have both 'services' and '%v'", WASM_MODULE_KEY))
+	} else if depBytes, err := json.Marshal(dc); err != nil {
+		return true, errors.New(msgPrinter.Sprintf("failed to marshal %v deployment %v, error %v", WASM_DEPLOYMENT_CONFIG_TYPE, dc, err))
+	} else {
+		// The module is checked when it is read in, the rest of the deployment is checked the way the agent does.
+		wd := new(persistence.WasmDeploymentConfig)
+		if err := json.Unmarshal(depBytes, wd); err != nil {
+			return true, errors.New(msgPrinter.Sprintf("invalid %v deployment: %v", WASM_DEPLOYMENT_CONFIG_TYPE, err))
+		}
+		wd.WasmModule = ""
+		if err := wd.Validate(); err != nil {
+			return true, errors.New(msgPrinter.Sprintf("invalid %v deployment: %v", WASM_DEPLOYMENT_CONFIG_TYPE, err))
+		}
+		return true, nil
+	}
+}
+
+func (p *WasmDeploymentConfigPlugin) StartTest(homeDirectory string, userInputFile string, configFiles []string, configType string, noFSS bool, userCreds string, secretsFiles map[string]string) bool {
+	return p.unsupportedTest(homeDirectory, dev.SERVICE_START_COMMAND)
+}
+
+func (p *WasmDeploymentConfigPlugin) StopTest(homeDirectory string) bool {
+	return p.unsupportedTest(homeDirectory, dev.SERVICE_STOP_COMMAND)
+}
+
+// The test commands run the service containers, so they are not supported for WebAssembly modules.
+func (p *WasmDeploymentConfigPlugin) unsupportedTest(homeDirectory string, command string) bool {
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	// Perform the common execution setup.
+	dir, _, _ := dev.CommonExecutionSetup(homeDirectory, "", dev.SERVICE_COMMAND, command)
+
+	// Get the service definition, so that we can check if we own the deployment config object.
+	serviceDef, sderr := dev.GetServiceDefinition(dir, dev.SERVICE_DEFINITION_FILE)
+	if sderr != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, fmt.Sprintf("'%v %v' %v", dev.SERVICE_COMMAND, command, sderr))
+	}
+
+	if owned, _ := p.Validate(serviceDef.Deployment, nil); !owned {
+		return false
+	}
+
+	cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' not supported for services using a %v deployment configuration, use 'wasmtime run' to test the module", dev.SERVICE_COMMAND, command, WASM_DEPLOYMENT_CONFIG_TYPE))
+	// For the compiler
+	return true
+}
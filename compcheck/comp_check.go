@@ -11,10 +11,12 @@ import (
 	"github.com/open-horizon/anax/exchangecommon"
 	"github.com/open-horizon/anax/externalpolicy"
 	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/kube_operator"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/anax/semanticversion"
 	"golang.org/x/text/message"
+	"sort"
 	"strings"
 )
 
@@ -141,9 +143,10 @@ func (p *CompCheck) UnmarshalJSON(b []byte) error {
 // CompCheckOutput The output format for the compatibility check
 // swagger:model
 type CompCheckOutput struct {
-	Compatible bool               `json:"compatible"`
-	Reason     map[string]string  `json:"reason"` // set when not compatible
-	Input      *CompCheckResource `json:"input,omitempty"`
+	Compatible  bool                          `json:"compatible"`
+	Reason      map[string]string             `json:"reason"`                // set when not compatible
+	Explanation map[string]*PolicyExplanation `json:"explanation,omitempty"` // the evaluation tree of the policy check of each service
+	Input       *CompCheckResource            `json:"input,omitempty"`
 }
 
 func (p *CompCheckOutput) String() string {
@@ -302,6 +305,22 @@ func DeployCompatible(ec exchange.ExchangeContext, agbotUrl string, ccInput *Com
 	return deployCompatible(getDeviceHandler, nodePolicyHandler, getBusinessPolicies, getPatterns, servicePolicyHandler, getServiceHandler, serviceDefResolverHandler, getSelectedServices, vaultSecretExists, agbotUrl, ccInput, checkAllSvcs, msgPrinter)
 }
 
+// Same as DeployCompatible, but the resources are obtained with the given handlers. The agent uses it to get the
+// service definitions from its local service cache when the exchange cannot be reached.
+func DeployCompatibleWithHandlers(getDeviceHandler exchange.DeviceHandler,
+	nodePolicyHandler exchange.NodePolicyHandler,
+	getBusinessPolicies exchange.BusinessPoliciesHandler,
+	getPatterns exchange.PatternHandler,
+	servicePolicyHandler exchange.ServicePolicyHandler,
+	getServiceHandler exchange.ServiceHandler,
+	serviceDefResolverHandler exchange.ServiceDefResolverHandler,
+	getSelectedServices exchange.SelectedServicesHandler,
+	vaultSecretExists exchange.VaultSecretExistsHandler, agbotUrl string,
+	ccInput *CompCheck, checkAllSvcs bool, msgPrinter *message.Printer) (*CompCheckOutput, error) {
+
+	return deployCompatible(getDeviceHandler, nodePolicyHandler, getBusinessPolicies, getPatterns, servicePolicyHandler, getServiceHandler, serviceDefResolverHandler, getSelectedServices, vaultSecretExists, agbotUrl, ccInput, checkAllSvcs, msgPrinter)
+}
+
 // Internal function for PolicyCompatible
 func deployCompatible(getDeviceHandler exchange.DeviceHandler,
 	nodePolicyHandler exchange.NodePolicyHandler,
@@ -526,6 +545,9 @@ func createCompCheckOutput(pcOutput *CompCheckOutput, privOutput *CompCheckOutpu
 	}
 
 	ccOutput.Reason = reason
+	if pcOutput != nil {
+		ccOutput.Explanation = pcOutput.Explanation
+	}
 
 	// combine the input part
 	ccInput := CompCheckResource{}
@@ -732,7 +754,7 @@ func ServicesRequirePrivilege(topSvc common.AbstractServiceFile, topSvcId string
 	return reqPriv, nil, privSvcs
 }
 
-// Check if the deployment string given uses the privileged flag or network=host
+// Check if the deployment string given uses the privileged flag, network=host or an unconfined seccomp or AppArmor profile
 func DeploymentRequiresPrivilege(deploymentString string, msgPrinter *message.Printer) (bool, error) {
 	if deploymentString == "" {
 		return false, nil
@@ -747,7 +769,7 @@ func DeploymentRequiresPrivilege(deploymentString string, msgPrinter *message.Pr
 	}
 	for _, topSvc := range deploymentStruct.Services {
 		if topSvc != nil {
-			if topSvc.Privileged || topSvc.Network == "host" {
+			if topSvc.Privileged || topSvc.Network == "host" || topSvc.SecurityOpts.IsUnconfined() {
 				return true, nil
 			}
 		}
@@ -863,6 +885,82 @@ func CheckClusterNamespaceCompatibility(nodeType string, nodeNamespace string, d
 	}
 }
 
+// Check the requirements of a cluster service against the node for cluster case.
+// The nodeProps are the properties of the node policy, which include the built-in properties published by the agent.
+// Compatibility check:
+// The kubernetes version of the node must be in the kubernetesVersion range of the clusterDeployment metadata.
+// A namespace scoped agent cannot install the cluster scoped kinds or the OLM kinds of the operator.
+// A requirement is not checked when the node does not have the property it needs.
+func CheckClusterRequirementsCompatibility(nodeType string, nodeProps externalpolicy.PropertyList, clusterDeployment interface{}, msgPrinter *message.Printer) (bool, string) {
+	if msgPrinter == nil {
+		msgPrinter = i18n.GetMessagePrinter()
+	}
+
+	if nodeType != persistence.DEVICE_TYPE_CLUSTER {
+		return true, ""
+	}
+
+	kd := new(persistence.KubeDeploymentConfig)
+	switch d := clusterDeployment.(type) {
+	case nil:
+		return true, ""
+	case string:
+		if d == "" {
+			return true, ""
+		} else if kd1, err := persistence.GetKubeDeployment(d); err != nil {
+			return false, msgPrinter.Sprintf("Failed to get cluster deployment from the service. %v", err)
+		} else {
+			kd = kd1
+		}
+	case map[string]interface{}:
+		if err := kd.FromPersistentForm(d); err != nil {
+			return false, msgPrinter.Sprintf("Failed to get cluster deployment from the service. %v", err)
+		}
+	default:
+		return false, msgPrinter.Sprintf("Invalid data presented in the cluster deployment field: %v", clusterDeployment)
+	}
+
+	// the kubernetes version of the node
+	if vExp, err := kube_operator.GetKubeVersionRange(kd.Metadata); err != nil {
+		return false, msgPrinter.Sprintf("Failed to get the kubernetes version range from the cluster deployment. %v", err)
+	} else if vExp != nil {
+		if prop, err := nodeProps.GetProperty(externalpolicy.PROP_NODE_K8S_VERSION); err == nil {
+			nodeVersion := kube_operator.NormalizeKubeVersion(fmt.Sprintf("%v", prop.Value))
+			if inRange, err := vExp.Is_within_range(nodeVersion); err != nil || !inRange {
+				return false, msgPrinter.Sprintf("The kubernetes version '%v' of the node is not in the version range '%v' required by the service.", prop.Value, vExp.Get_expression())
+			}
+		}
+	}
+
+	// the kinds a namespace scoped agent cannot install
+	namespaceScoped := false
+	if prop, err := nodeProps.GetProperty(externalpolicy.PROP_NODE_K8S_NAMESPACE_SCOPED); err == nil {
+		namespaceScoped, _ = prop.Value.(bool)
+	}
+	if namespaceScoped && kd.OperatorYamlArchive != "" {
+		kinds, err := kube_operator.GetOperatorKinds(kd.OperatorYamlArchive)
+		if err != nil {
+			return false, msgPrinter.Sprintf("Failed to read the kinds of the operator. %v", err)
+		}
+		clusterKinds := []string{}
+		olmKinds := []string{}
+		for _, kind := range kinds {
+			if kube_operator.IsClusterScopedKind(kind) {
+				clusterKinds = append(clusterKinds, kind)
+			} else if kube_operator.IsOLMKind(kind) {
+				olmKinds = append(olmKinds, kind)
+			}
+		}
+		if len(clusterKinds) != 0 {
+			return false, msgPrinter.Sprintf("The service requires the cluster scoped kinds %v, which the namespace scoped agent of the node cannot create.", strings.Join(clusterKinds, ", "))
+		} else if len(olmKinds) != 0 {
+			return false, msgPrinter.Sprintf("The service requires the OLM kinds %v, which need a cluster scoped agent.", strings.Join(olmKinds, ", "))
+		}
+	}
+
+	return true, ""
+}
+
 // Get the dependent services for the given service.
 // It goes to the dependentServices to find a dependent first. If not found
 // it will go to the exchange to get the dependents.
@@ -1007,6 +1105,33 @@ func GetServiceAndDeps(svcUrl, svcOrg, svcVersion, svcArch string,
 	return topSvc, topId, depSvcs, nil
 }
 
+// Check that the node can read the dependent services with its credentials. The node can only read the services of its
+// own org and the public services of the other orgs, so a private dependent service of another org can be resolved
+// with the user's credentials but not by the agent. The node org can be empty when it is not known, then nothing is checked.
+func CheckCrossOrgServiceVisibility(nodeOrg string, depServices map[string]exchange.ServiceDefinition, msgPrinter *message.Printer) (bool, string) {
+	// get default message printer if nil
+	if msgPrinter == nil {
+		msgPrinter = i18n.GetMessagePrinter()
+	}
+
+	if nodeOrg == "" {
+		return true, ""
+	}
+
+	notVisible := []string{}
+	for sId, sDef := range depServices {
+		if svcOrg := exchange.GetOrg(sId); svcOrg != nodeOrg && !sDef.Public {
+			notVisible = append(notVisible, sId)
+		}
+	}
+	if len(notVisible) == 0 {
+		return true, ""
+	}
+
+	sort.Strings(notVisible)
+	return false, msgPrinter.Sprintf("The dependent services %v are not public in their organizations, they are not visible to the credentials of the node in organization %v. Make them public or publish them in organization %v.", strings.Join(notVisible, ", "), nodeOrg, nodeOrg)
+}
+
 func FormatReasonMessage(reason string, type_error bool, msg_prefix string, type_prefix string) string {
 	if type_error {
 		return fmt.Sprintf("%v: %v", type_prefix, reason)
@@ -0,0 +1,113 @@
+//go:build unit
+// +build unit
+
+package compcheck
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/persistence"
+	"strings"
+	"testing"
+)
+
+// Returns a base64 encoded operator yaml archive with one file for each yaml.
+func createOperatorArchive(t *testing.T, yamls ...string) string {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for ix, y := range yamls {
+		if err := tw.WriteHeader(&tar.Header{Name: strings.Repeat("f", ix+1) + ".yaml", Mode: 0600, Size: int64(len(y))}); err != nil {
+			t.Fatalf("unable to write the tar header, error: %v", err)
+		} else if _, err := tw.Write([]byte(y)); err != nil {
+			t.Fatalf("unable to write the tar file, error: %v", err)
+		}
+	}
+	tw.Close()
+	gw.Close()
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func Test_CheckClusterRequirementsCompatibility(t *testing.T) {
+	deployment := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: op\n"
+	crd := "apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nmetadata:\n  name: crs.example.com\n---\napiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: op\n"
+	subscription := "apiVersion: operators.coreos.com/v1alpha1\nkind: Subscription\nmetadata:\n  name: op\n"
+
+	clusterScoped := externalpolicy.PropertyList{{Name: externalpolicy.PROP_NODE_K8S_VERSION, Value: "v1.27.3+k3s1"}, {Name: externalpolicy.PROP_NODE_K8S_NAMESPACE_SCOPED, Value: false}}
+	namespaceScoped := externalpolicy.PropertyList{{Name: externalpolicy.PROP_NODE_K8S_VERSION, Value: "v1.27.3+k3s1"}, {Name: externalpolicy.PROP_NODE_K8S_NAMESPACE_SCOPED, Value: true}}
+
+	// devices and services without a cluster deployment are not checked
+	if compatible, reason := CheckClusterRequirementsCompatibility(persistence.DEVICE_TYPE_DEVICE, namespaceScoped, "not a deployment", nil); !compatible {
+		t.Errorf("a device should be compatible, reason: %v", reason)
+	}
+	if compatible, reason := CheckClusterRequirementsCompatibility(persistence.DEVICE_TYPE_CLUSTER, namespaceScoped, nil, nil); !compatible {
+		t.Errorf("a service without a cluster deployment should be compatible, reason: %v", reason)
+	}
+
+	// the kubernetes version range
+	cd := map[string]interface{}{"operatorYamlArchive": createOperatorArchive(t, deployment), "metadata": map[string]interface{}{"kubernetesVersion": "[1.24.0,1.28.0)"}}
+	if compatible, reason := CheckClusterRequirementsCompatibility(persistence.DEVICE_TYPE_CLUSTER, clusterScoped, cd, nil); !compatible {
+		t.Errorf("kubernetes 1.27.3 should be in the range, reason: %v", reason)
+	}
+	cd["metadata"] = map[string]interface{}{"kubernetesVersion": "[1.28.0,INFINITY)"}
+	if compatible, reason := CheckClusterRequirementsCompatibility(persistence.DEVICE_TYPE_CLUSTER, clusterScoped, cd, nil); compatible {
+		t.Errorf("kubernetes 1.27.3 should not be in the range")
+	} else if !strings.Contains(reason, "v1.27.3+k3s1") || !strings.Contains(reason, "[1.28.0,INFINITY)") {
+		t.Errorf("wrong reason: %v", reason)
+	}
+	if compatible, reason := CheckClusterRequirementsCompatibility(persistence.DEVICE_TYPE_CLUSTER, nil, cd, nil); !compatible {
+		t.Errorf("the version should not be checked without the node property, reason: %v", reason)
+	}
+	cd["metadata"] = map[string]interface{}{"kubernetesVersion": "not a range"}
+	if compatible, _ := CheckClusterRequirementsCompatibility(persistence.DEVICE_TYPE_CLUSTER, clusterScoped, cd, nil); compatible {
+		t.Errorf("an invalid version range should not be compatible")
+	}
+
+	// the cluster scoped kinds
+	cd = map[string]interface{}{"operatorYamlArchive": createOperatorArchive(t, deployment, crd)}
+	if compatible, reason := CheckClusterRequirementsCompatibility(persistence.DEVICE_TYPE_CLUSTER, clusterScoped, cd, nil); !compatible {
+		t.Errorf("a cluster scoped agent should install the cluster scoped kinds, reason: %v", reason)
+	}
+	if compatible, reason := CheckClusterRequirementsCompatibility(persistence.DEVICE_TYPE_CLUSTER, namespaceScoped, cd, nil); compatible {
+		t.Errorf("a namespace scoped agent should not install the cluster scoped kinds")
+	} else if !strings.Contains(reason, "ClusterRole, CustomResourceDefinition") {
+		t.Errorf("wrong reason: %v", reason)
+	}
+
+	// the OLM kinds
+	cd = map[string]interface{}{"operatorYamlArchive": createOperatorArchive(t, deployment, subscription)}
+	if compatible, reason := CheckClusterRequirementsCompatibility(persistence.DEVICE_TYPE_CLUSTER, namespaceScoped, cd, nil); compatible {
+		t.Errorf("a namespace scoped agent should not install the OLM kinds")
+	} else if !strings.Contains(reason, "Subscription") {
+		t.Errorf("wrong reason: %v", reason)
+	}
+	cd = map[string]interface{}{"operatorYamlArchive": createOperatorArchive(t, deployment)}
+	if compatible, reason := CheckClusterRequirementsCompatibility(persistence.DEVICE_TYPE_CLUSTER, namespaceScoped, cd, nil); !compatible {
+		t.Errorf("a namespace scoped agent should install a deployment, reason: %v", reason)
+	}
+}
+
+func Test_CheckCrossOrgServiceVisibility(t *testing.T) {
+	depServices := map[string]exchange.ServiceDefinition{
+		"myorg/svc1_1.0.0_amd64": exchange.ServiceDefinition{URL: "svc1"},
+		"IBM/svc2_1.0.0_amd64":   exchange.ServiceDefinition{URL: "svc2", Public: true},
+	}
+	if compatible, reason := CheckCrossOrgServiceVisibility("myorg", depServices, nil); !compatible {
+		t.Errorf("the services of the node org and the public services should be visible, reason: %v", reason)
+	}
+
+	depServices["otherorg/svc3_1.0.0_amd64"] = exchange.ServiceDefinition{URL: "svc3"}
+	if compatible, reason := CheckCrossOrgServiceVisibility("myorg", depServices, nil); compatible {
+		t.Errorf("the private service of another org should not be visible")
+	} else if !strings.Contains(reason, "otherorg/svc3_1.0.0_amd64") || strings.Contains(reason, "svc2") {
+		t.Errorf("wrong reason: %v", reason)
+	}
+
+	if compatible, reason := CheckCrossOrgServiceVisibility("", depServices, nil); !compatible {
+		t.Errorf("nothing should be checked without the node org, reason: %v", reason)
+	}
+}
@@ -180,6 +180,7 @@ func policyCompatible(getDeviceHandler exchange.DeviceHandler,
 
 	// go through all the workloads and check if compatible or not
 	messages := map[string]string{}
+	explanations := map[string]*PolicyExplanation{}
 	overall_compatible := false
 	for _, workload := range bPolicy.Workloads {
 
@@ -236,6 +237,9 @@ func policyCompatible(getDeviceHandler exchange.DeviceHandler,
 						// check namespace compatibility
 						if resources.NodeType == persistence.DEVICE_TYPE_CLUSTER {
 							compatible, _, reason = CheckClusterNamespaceCompatibility(resources.NodeType, resources.NodeClusterNS, bPolicy.ClusterNamespace, topSvcDef.GetClusterDeployment(), true, msgPrinter)
+							if compatible {
+								compatible, reason = CheckClusterRequirementsCompatibility(resources.NodeType, nPolicy.Properties, topSvcDef.GetClusterDeployment(), msgPrinter)
+							}
 						}
 						if compatible {
 							// policy compatibility check
@@ -243,6 +247,7 @@ func policyCompatible(getDeviceHandler exchange.DeviceHandler,
 							if err1 != nil {
 								return nil, err1
 							}
+							explanations[sId] = explainPolicyCompatibility(nPolicy, bPolicy, mergedServicePol, msgPrinter)
 						}
 					}
 					if compatible {
@@ -250,7 +255,7 @@ func policyCompatible(getDeviceHandler exchange.DeviceHandler,
 						if checkAllSvcs {
 							messages[sId] = msg_compatible
 						} else {
-							return NewCompCheckOutput(true, map[string]string{sId: msg_compatible}, resources), nil
+							return newPolicyCheckOutput(true, map[string]string{sId: msg_compatible}, explanations, resources), nil
 						}
 					} else {
 						messages[sId] = fmt.Sprintf("%v: %v", msg_incompatible, reason)
@@ -287,6 +292,9 @@ func policyCompatible(getDeviceHandler exchange.DeviceHandler,
 								// check namespace compatibility
 								if resources.NodeType == persistence.DEVICE_TYPE_CLUSTER {
 									compatible, _, reason = CheckClusterNamespaceCompatibility(resources.NodeType, resources.NodeClusterNS, bPolicy.ClusterNamespace, topSvcDef.GetClusterDeployment(), true, msgPrinter)
+									if compatible {
+										compatible, reason = CheckClusterRequirementsCompatibility(resources.NodeType, nPolicy.Properties, topSvcDef.GetClusterDeployment(), msgPrinter)
+									}
 								}
 								if compatible {
 									// policy compatibility check
@@ -294,6 +302,7 @@ func policyCompatible(getDeviceHandler exchange.DeviceHandler,
 									if err != nil {
 										return nil, err
 									}
+									explanations[sId] = explainPolicyCompatibility(nPolicy, bPolicy, mergedServicePol, msgPrinter)
 								}
 							}
 							if compatible {
@@ -301,7 +310,7 @@ func policyCompatible(getDeviceHandler exchange.DeviceHandler,
 								if checkAllSvcs {
 									messages[sId] = msg_compatible
 								} else {
-									return NewCompCheckOutput(true, map[string]string{sId: msg_compatible}, resources), nil
+									return newPolicyCheckOutput(true, map[string]string{sId: msg_compatible}, explanations, resources), nil
 								}
 							} else {
 								messages[sId] = fmt.Sprintf("%v: %v", msg_incompatible, reason)
@@ -345,6 +354,9 @@ func policyCompatible(getDeviceHandler exchange.DeviceHandler,
 					// check namespace compatibility
 					if resources.NodeType == persistence.DEVICE_TYPE_CLUSTER {
 						compatible, _, reason = CheckClusterNamespaceCompatibility(resources.NodeType, resources.NodeClusterNS, bPolicy.ClusterNamespace, topSvcDef.GetClusterDeployment(), true, msgPrinter)
+						if compatible {
+							compatible, reason = CheckClusterRequirementsCompatibility(resources.NodeType, nPolicy.Properties, topSvcDef.GetClusterDeployment(), msgPrinter)
+						}
 					}
 					if compatible {
 						// policy compatibility check
@@ -352,6 +364,7 @@ func policyCompatible(getDeviceHandler exchange.DeviceHandler,
 						if err1 != nil {
 							return nil, err1
 						}
+						explanations[sId] = explainPolicyCompatibility(nPolicy, bPolicy, mergedServicePol, msgPrinter)
 					}
 				}
 			}
@@ -360,7 +373,7 @@ func policyCompatible(getDeviceHandler exchange.DeviceHandler,
 				if checkAllSvcs {
 					messages[sId] = msg_compatible
 				} else {
-					return NewCompCheckOutput(true, map[string]string{sId: msg_compatible}, resources), nil
+					return newPolicyCheckOutput(true, map[string]string{sId: msg_compatible}, explanations, resources), nil
 				}
 			} else {
 				messages[sId] = fmt.Sprintf("%v: %v", msg_incompatible, reason)
@@ -373,7 +386,7 @@ func policyCompatible(getDeviceHandler exchange.DeviceHandler,
 	resources.Service = top_services
 
 	if messages != nil && len(messages) != 0 {
-		return NewCompCheckOutput(overall_compatible, messages, resources), nil
+		return newPolicyCheckOutput(overall_compatible, messages, explanations, resources), nil
 	} else {
 		// If we get here, it means that no workload is found in the bp that matches the required node arch.
 		if resources.NodeArch != "" {
@@ -386,6 +399,25 @@ func policyCompatible(getDeviceHandler exchange.DeviceHandler,
 	}
 }
 
+// Returns the explanation of the policy compatibility check, or nil if it cannot be explained. The policies were already
+// checked by CheckPolicyCompatiblility, which reports the errors.
+func explainPolicyCompatibility(nodePolicy *policy.Policy, businessPolicy *policy.Policy, mergedServicePolicy *externalpolicy.ExternalPolicy, msgPrinter *message.Printer) *PolicyExplanation {
+	explanation, _ := ExplainPolicyCompatibility(nodePolicy, businessPolicy, mergedServicePolicy, msgPrinter)
+	return explanation
+}
+
+// Create the output of the policy compatibility check, with the explanations of the services in the reason.
+func newPolicyCheckOutput(compatible bool, reason map[string]string, explanations map[string]*PolicyExplanation, input *CompCheckResource) *CompCheckOutput {
+	output := NewCompCheckOutput(compatible, reason, input)
+	output.Explanation = map[string]*PolicyExplanation{}
+	for sId := range reason {
+		if explanation, ok := explanations[sId]; ok && explanation != nil {
+			output.Explanation[sId] = explanation
+		}
+	}
+	return output
+}
+
 // It does the policy compatibility check. node arch can be empty. It is called by agbot and PolicyCompatible function.
 // The node arch is supposed to be already compared against the service arch before calling this function.
 func CheckPolicyCompatiblility(nodePolicy *policy.Policy, businessPolicy *policy.Policy, mergedServicePolicy *externalpolicy.ExternalPolicy, nodeArch string, msgPrinter *message.Printer) (bool, string, *policy.Policy, *policy.Policy, error) {
@@ -410,6 +442,11 @@ func CheckPolicyCompatiblility(nodePolicy *policy.Policy, businessPolicy *policy
 		return false, "", nil, nil, NewCompCheckError(fmt.Errorf(msgPrinter.Sprintf("Merged service policy cannot be null.")), COMPCHECK_INPUT_ERROR)
 	}
 
+	// tell plainly when the service cannot run because it needs privileges the node does not give
+	if privilegeProperty(mergedServicePolicy.Properties, externalpolicy.PROP_SVC_PRIVILEGED) && !privilegeProperty(nodePolicy.Properties, externalpolicy.PROP_NODE_PRIVILEGED) {
+		return false, msgPrinter.Sprintf("The service requires privileged mode, network=host or an unconfined security profile, but the node policy does not allow it. Set the %v property to true in the node policy to run the service on this node.", externalpolicy.PROP_NODE_PRIVILEGED), nil, nil, nil
+	}
+
 	// merge the service policy, the default service properties
 	mergedConsumerPol, err := MergeFullServicePolicyToBusinessPolicy(businessPolicy, mergedServicePolicy, msgPrinter)
 	if err != nil {
@@ -431,6 +468,66 @@ func CheckPolicyCompatiblility(nodePolicy *policy.Policy, businessPolicy *policy
 	}
 }
 
+// The evaluation tree of a policy compatibility check, with each clause of the constraints on both sides and the value
+// of the property it was evaluated against.
+type PolicyExplanation struct {
+	Compatible            bool                                  `json:"compatible"`
+	PrivilegeAllowed      bool                                  `json:"privilegeAllowed"`                // false when the service needs privileges that the node does not allow
+	DeploymentConstraints *externalpolicy.ConstraintExplanation `json:"deploymentConstraints,omitempty"` // the constraints of the deployment and service policies, evaluated against the node properties
+	NodeConstraints       *externalpolicy.ConstraintExplanation `json:"nodeConstraints,omitempty"`       // the constraints of the node, evaluated against the deployment and service properties
+}
+
+func (p PolicyExplanation) String() string {
+	return fmt.Sprintf("Compatible: %v, PrivilegeAllowed: %v, DeploymentConstraints: %v, NodeConstraints: %v",
+		p.Compatible, p.PrivilegeAllowed, p.DeploymentConstraints, p.NodeConstraints)
+}
+
+// Explain the policy compatibility check of CheckPolicyCompatiblility clause by clause. The policies are merged and the
+// privileges and constraints are evaluated the same way as in CheckPolicyCompatiblility.
+func ExplainPolicyCompatibility(nodePolicy *policy.Policy, businessPolicy *policy.Policy, mergedServicePolicy *externalpolicy.ExternalPolicy, msgPrinter *message.Printer) (*PolicyExplanation, error) {
+
+	// get default message printer if nil
+	if msgPrinter == nil {
+		msgPrinter = i18n.GetMessagePrinter()
+	}
+
+	if nodePolicy == nil || businessPolicy == nil || mergedServicePolicy == nil {
+		return nil, NewCompCheckError(fmt.Errorf(msgPrinter.Sprintf("Node policy, deployment policy and merged service policy cannot be null.")), COMPCHECK_INPUT_ERROR)
+	}
+
+	mergedConsumerPol, err := MergeFullServicePolicyToBusinessPolicy(businessPolicy, mergedServicePolicy, msgPrinter)
+	if err != nil {
+		return nil, err
+	}
+
+	explanation := &PolicyExplanation{
+		PrivilegeAllowed: !privilegeProperty(mergedServicePolicy.Properties, externalpolicy.PROP_SVC_PRIVILEGED) || privilegeProperty(nodePolicy.Properties, externalpolicy.PROP_NODE_PRIVILEGED),
+	}
+	if explanation.DeploymentConstraints, err = mergedConsumerPol.Constraints.Explain(nodePolicy.Properties); err != nil {
+		return nil, NewCompCheckError(fmt.Errorf(msgPrinter.Sprintf("Error evaluating the deployment constraints. %v", err)), COMPCHECK_VALIDATION_ERROR)
+	}
+	if explanation.NodeConstraints, err = nodePolicy.Constraints.Explain(mergedConsumerPol.Properties); err != nil {
+		return nil, NewCompCheckError(fmt.Errorf(msgPrinter.Sprintf("Error evaluating the node constraints. %v", err)), COMPCHECK_VALIDATION_ERROR)
+	}
+
+	explanation.Compatible = explanation.PrivilegeAllowed &&
+		(explanation.DeploymentConstraints == nil || explanation.DeploymentConstraints.Match) &&
+		(explanation.NodeConstraints == nil || explanation.NodeConstraints.Match)
+	return explanation, nil
+}
+
+// Returns true if the given privilege property is in the properties and is true.
+func privilegeProperty(props externalpolicy.PropertyList, name string) bool {
+	if props.HasProperty(name) {
+		if prop, err := props.GetProperty(name); err == nil {
+			if priv, ok := prop.Value.(bool); ok {
+				return priv
+			}
+		}
+	}
+	return false
+}
+
 // add node arch property to the node policy. node arch can be empty
 func addNodeArchToPolicy(nodePolicy *policy.Policy, nodeArch string, msgPrinter *message.Printer) (*policy.Policy, error) {
 	// get default message printer if nil
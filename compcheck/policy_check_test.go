@@ -872,6 +872,18 @@ func Test_CheckPolicyCompatiblility(t *testing.T) {
 		t.Errorf("The producerPolicy should not have 3 properties but got %v", len(producerPolicy.Properties))
 	}
 
+	// not compatible, the service requires privileged mode and the node policy does not allow it
+	privSPol := mergedSPol.DeepCopy()
+	privSPol.Properties.Add_Property(externalpolicy.Property_Factory(externalpolicy.PROP_SVC_PRIVILEGED, true), true)
+	privSPol.Constraints.Add_Constraint(fmt.Sprintf("%s = %t", externalpolicy.PROP_SVC_PRIVILEGED, true))
+	if compatible, reason, _, _, err := CheckPolicyCompatiblility(intNPol, intBPol, privSPol, "amd64", msgPrinter); err != nil {
+		t.Errorf("CheckPolicyCompatiblility should have returned nil error but got: %v", err)
+	} else if compatible {
+		t.Errorf("CheckPolicyCompatiblility should have returned not compatible")
+	} else if !strings.Contains(reason, "requires privileged mode") {
+		t.Errorf("CheckPolicyCompatiblility should have returned the privileged mode reason but got: %v", reason)
+	}
+
 	// error cases
 	if _, _, _, _, err := CheckPolicyCompatiblility(nil, intBPol, mergedSPol, "arm64", msgPrinter); err == nil {
 		t.Errorf("CheckPolicyCompatiblility should not have returned nil error")
@@ -890,6 +902,93 @@ func Test_CheckPolicyCompatiblility(t *testing.T) {
 	}
 }
 
+func Test_ExplainPolicyCompatibility(t *testing.T) {
+
+	msgPrinter := i18n.GetMessagePrinter()
+
+	svcUrl := "weather"
+	svcOrg := "myorg"
+	svcVersion := "1.0.1"
+	svcArch := "amd64"
+	service := businesspolicy.ServiceRef{
+		Name:            svcUrl,
+		Org:             svcOrg,
+		Arch:            svcArch,
+		ServiceVersions: []businesspolicy.WorkloadChoice{businesspolicy.WorkloadChoice{Version: svcVersion}},
+	}
+
+	extPol := createExternalPolicy(map[string]string{"prop3": "val3"}, []string{"a==b"})
+	extPol_Deploy := createExternalPolicy(map[string]string{"prop4": "some value"}, []string{"prop1 == val1", "prop5 == val5"})
+	extPol_Manage := createExternalPolicy(map[string]string{"prop5": "some value5"}, []string{"c==d"})
+
+	_, intBPol, err := GetBusinessPolicy(getBusinessPolicyHandler(service, map[string]string{"prop1": "val1", "prop2": "val2"}, []string{"prop3 == val3", "prop4 == \"some value\""}), "myorg/mybp", true, msgPrinter)
+	if err != nil {
+		t.Errorf("GetBusinessPolicy should have returned nil error but got: %v", err)
+	}
+
+	_, intNPol, err := GetNodePolicy(getNodePolicyHandler(*extPol, *extPol_Deploy, *extPol_Manage), "myorg/mynode", msgPrinter)
+	if err != nil {
+		t.Errorf("GetNodePolicy should have returned nil error but got: %v", err)
+	}
+
+	mergedSPol, _, _, _, _, err := GetServicePolicyWithDefaultProperties(getServicePolicyHandler(map[string]string{"prop5": "val5", "prop6": "val6"}, []string{"prop4 == \"some value\""}), getServiceDefResolverHandler(), svcUrl, svcOrg, svcVersion, svcArch, msgPrinter)
+	if err != nil {
+		t.Errorf("GetServicePolicyWithDefaultProperties should have returned nil error but got: %v", err)
+	}
+
+	// compatible, every clause matches
+	if explanation, err := ExplainPolicyCompatibility(intNPol, intBPol, mergedSPol, msgPrinter); err != nil {
+		t.Errorf("ExplainPolicyCompatibility should have returned nil error but got: %v", err)
+	} else if !explanation.Compatible || !explanation.PrivilegeAllowed {
+		t.Errorf("ExplainPolicyCompatibility should have returned compatible but got: %v", explanation)
+	} else if explanation.DeploymentConstraints == nil || explanation.DeploymentConstraints.Op != externalpolicy.OP_AND || len(explanation.DeploymentConstraints.Clauses) != 2 {
+		t.Errorf("The deployment constraints should be an 'and' of 2 clauses but got: %v", explanation.DeploymentConstraints)
+	} else if explanation.NodeConstraints == nil || !explanation.NodeConstraints.Match {
+		t.Errorf("The node constraints should match but got: %v", explanation.NodeConstraints)
+	}
+
+	// not compatible, the clause on prop4 does not match the node value
+	extPol_Deploy_Other := createExternalPolicy(map[string]string{"prop4": "some other value"}, []string{"prop1 == val1", "prop5 == val5"})
+	_, intNPol1, err := GetNodePolicy(getNodePolicyHandler(*extPol, *extPol_Deploy_Other, *extPol_Manage), "myorg/mynode", msgPrinter)
+	if err != nil {
+		t.Errorf("GetNodePolicy should have returned nil error but got: %v", err)
+	}
+	if explanation, err := ExplainPolicyCompatibility(intNPol1, intBPol, mergedSPol, msgPrinter); err != nil {
+		t.Errorf("ExplainPolicyCompatibility should have returned nil error but got: %v", err)
+	} else if explanation.Compatible || explanation.DeploymentConstraints.Match {
+		t.Errorf("ExplainPolicyCompatibility should have returned not compatible but got: %v", explanation)
+	} else {
+		found := false
+		for _, clause := range explanation.DeploymentConstraints.Clauses {
+			if clause.Property == "prop4" {
+				found = true
+				if clause.Match || clause.PropertyValue != "some other value" {
+					t.Errorf("The prop4 clause should not match the node value but got: %v", clause)
+				}
+			} else if !clause.Match {
+				t.Errorf("The %v clause should match but got: %v", clause.Property, clause)
+			}
+		}
+		if !found {
+			t.Errorf("The deployment constraints should have a prop4 clause but got: %v", explanation.DeploymentConstraints)
+		}
+	}
+
+	// not compatible, the service requires privileged mode and the node policy does not allow it
+	privSPol := mergedSPol.DeepCopy()
+	privSPol.Properties.Add_Property(externalpolicy.Property_Factory(externalpolicy.PROP_SVC_PRIVILEGED, true), true)
+	if explanation, err := ExplainPolicyCompatibility(intNPol, intBPol, privSPol, msgPrinter); err != nil {
+		t.Errorf("ExplainPolicyCompatibility should have returned nil error but got: %v", err)
+	} else if explanation.Compatible || explanation.PrivilegeAllowed {
+		t.Errorf("ExplainPolicyCompatibility should have returned privilege not allowed but got: %v", explanation)
+	}
+
+	// error cases
+	if _, err := ExplainPolicyCompatibility(nil, intBPol, mergedSPol, msgPrinter); err == nil {
+		t.Errorf("ExplainPolicyCompatibility should not have returned nil error")
+	}
+}
+
 func Test_addNodeArchToPolicy(t *testing.T) {
 
 	msgPrinter := i18n.GetMessagePrinter()
@@ -1278,3 +1377,50 @@ func getServiceDefResolverHandler() exchange.ServiceDefResolverHandler {
 		return nil, map[string]exchange.ServiceDefinition{}, service, sId, nil
 	}
 }
+
+// The deployment policy constraints can use the regex, version comparison and list operators.
+func Test_CheckPolicyCompatiblility_ConstraintOperators(t *testing.T) {
+
+	msgPrinter := i18n.GetMessagePrinter()
+
+	service := businesspolicy.ServiceRef{
+		Name:            "weather",
+		Org:             "myorg",
+		Arch:            "amd64",
+		ServiceVersions: []businesspolicy.WorkloadChoice{businesspolicy.WorkloadChoice{Version: "1.0.1"}},
+	}
+
+	_, intBPol, err := GetBusinessPolicy(getBusinessPolicyHandler(service, map[string]string{"prop1": "val1"}, []string{"hostname =~ \"^edge-[0-9]+$\" && tier in [gold, silver]"}), "myorg/mybp", true, msgPrinter)
+	if err != nil {
+		t.Errorf("GetBusinessPolicy should have returned nil error but got: %v", err)
+	}
+
+	mergedSPol, _, _, _, _, err := GetServicePolicyWithDefaultProperties(getServicePolicyHandler(map[string]string{"prop5": "val5"}, []string{}), getServiceDefResolverHandler(), "weather", "myorg", "1.0.1", "amd64", msgPrinter)
+	if err != nil {
+		t.Errorf("GetServicePolicyWithDefaultProperties should have returned nil error but got: %v", err)
+	}
+
+	extPol := createExternalPolicy(map[string]string{"hostname": "edge-7", "tier": "silver"}, []string{"prop1 == val1"})
+	_, intNPol, err := GetNodePolicy(getNodePolicyHandler(*extPol, *extPol, *extPol), "myorg/mynode", msgPrinter)
+	if err != nil {
+		t.Errorf("GetNodePolicy should have returned nil error but got: %v", err)
+	}
+	if compatible, reason, _, _, err := CheckPolicyCompatiblility(intNPol, intBPol, mergedSPol, "amd64", msgPrinter); err != nil {
+		t.Errorf("CheckPolicyCompatiblility should have returned nil error but got: %v", err)
+	} else if !compatible {
+		t.Errorf("CheckPolicyCompatiblility should have returned compatible but got: %v", reason)
+	}
+
+	extPol = createExternalPolicy(map[string]string{"hostname": "cloud-7", "tier": "silver"}, []string{"prop1 == val1"})
+	_, intNPol, err = GetNodePolicy(getNodePolicyHandler(*extPol, *extPol, *extPol), "myorg/mynode", msgPrinter)
+	if err != nil {
+		t.Errorf("GetNodePolicy should have returned nil error but got: %v", err)
+	}
+	if compatible, reason, _, _, err := CheckPolicyCompatiblility(intNPol, intBPol, mergedSPol, "amd64", msgPrinter); err != nil {
+		t.Errorf("CheckPolicyCompatiblility should have returned nil error but got: %v", err)
+	} else if compatible {
+		t.Errorf("CheckPolicyCompatiblility should have returned not compatible but not")
+	} else if !strings.Contains(reason, "hostname=~") {
+		t.Errorf("The reason should tell the regex constraint but got: %v", reason)
+	}
+}
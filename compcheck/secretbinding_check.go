@@ -9,7 +9,6 @@ import (
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/exchangecommon"
 	"github.com/open-horizon/anax/i18n"
-	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/semanticversion"
 	"golang.org/x/text/message"
 	"strings"
@@ -151,8 +150,6 @@ func secretBindingCompatible(getDeviceHandler exchange.DeviceHandler,
 	// node id or from the input.
 	if nodeType, err := VerifyNodeType(input.NodeType, resources.NodeType, nodeId, msgPrinter); err != nil {
 		return nil, err
-	} else if nodeType == persistence.DEVICE_TYPE_CLUSTER {
-		return nil, NewCompCheckError(fmt.Errorf(msgPrinter.Sprintf("Node type '%v' does not support secret binding check.", nodeType)), COMPCHECK_INPUT_ERROR)
 	} else {
 		resources.NodeType = nodeType
 	}
@@ -583,13 +580,20 @@ func ValidateSecretBindingForSingleService(secretBinding []exchangecommon.Secret
 		return index, nil, err
 	}
 
-	// cluster type does not have secrets
+	// cluster services do not declare their secrets, all the bound secrets are mounted into the operator
 	if sdef.GetServiceType() == exchangecommon.SERVICE_TYPE_CLUSTER {
 		if index == -1 {
 			return index, nil, nil
-		} else {
-			return index, nil, fmt.Errorf(msgPrinter.Sprintf("Secret binding for a cluster service is not supported."))
 		}
+		used_sb := []string{}
+		for _, vbind := range secretBinding[index].Secrets {
+			key, vs := vbind.GetBinding()
+			if _, _, err := ParseVaultSecretName(vs, msgPrinter); err != nil {
+				return index, nil, err
+			}
+			used_sb = append(used_sb, key)
+		}
+		return index, used_sb, nil
 	}
 
 	// convert the deployment string into object
@@ -7,6 +7,7 @@ import (
 	"github.com/open-horizon/anax/common"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/externalpolicy"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
@@ -202,6 +203,17 @@ func userInputCompatible(getDeviceHandler exchange.DeviceHandler,
 		serviceRefs = getWorkloadsFromPattern(pattern, resources.NodeArch)
 		consumerNamespace = pattern.GetClusterNamespace()
 	}
+
+	// the built-in properties of a cluster node, for the cluster requirements of the services. The deployment policy
+	// case has checked them with the node policy already.
+	var nodeProps externalpolicy.PropertyList
+	if !useBPol && resources.NodeType == persistence.DEVICE_TYPE_CLUSTER && nodeId != "" {
+		if _, nPolicy, err := GetNodePolicy(nodePolicyHandler, nodeId, msgPrinter); err != nil {
+			return nil, err
+		} else if nPolicy != nil {
+			nodeProps = nPolicy.Properties
+		}
+	}
 	if serviceRefs == nil || len(serviceRefs) == 0 {
 		if resources.NodeArch != "" {
 			return nil, NewCompCheckError(fmt.Errorf(msgPrinter.Sprintf("No service versions with architecture %v specified in the deployment policy or pattern.", resources.NodeArch)), COMPCHECK_VALIDATION_ERROR)
@@ -261,12 +273,23 @@ func userInputCompatible(getDeviceHandler exchange.DeviceHandler,
 							svc_other_mismatch[sId] = true
 						} else if resources.NodeType == persistence.DEVICE_TYPE_CLUSTER {
 							compatible_n, _, reason_n := CheckClusterNamespaceCompatibility(resources.NodeType, resources.NodeClusterNS, consumerNamespace, topSvcDef.GetClusterDeployment(), true, msgPrinter)
+							if compatible_n {
+								compatible_n, reason_n = CheckClusterRequirementsCompatibility(resources.NodeType, nodeProps, topSvcDef.GetClusterDeployment(), msgPrinter)
+							}
 							if !compatible_n {
 								reason = reason_n
 								svc_other_mismatch[sId] = true
 							}
 						}
 
+						// the dependent services must be visible to the node
+						if compatible && compatible_t {
+							if visible, reason_v := CheckCrossOrgServiceVisibility(resources.NodeOrg, depSvcDefs, msgPrinter); !visible {
+								compatible = false
+								reason = reason_v
+							}
+						}
+
 						if compatible && compatible_t {
 							service_compatible = true
 							service_comp[sId] = topSvcDef
@@ -306,12 +329,23 @@ func userInputCompatible(getDeviceHandler exchange.DeviceHandler,
 									svc_other_mismatch[sId] = true
 								} else if resources.NodeType == persistence.DEVICE_TYPE_CLUSTER {
 									compatible_n, _, reason_n := CheckClusterNamespaceCompatibility(resources.NodeType, resources.NodeClusterNS, consumerNamespace, svc.GetClusterDeployment(), true, msgPrinter)
+									if compatible_n {
+										compatible_n, reason_n = CheckClusterRequirementsCompatibility(resources.NodeType, nodeProps, svc.GetClusterDeployment(), msgPrinter)
+									}
 									if !compatible_n {
 										reason = reason_n
 										svc_other_mismatch[sId] = true
 									}
 								}
 
+								// the dependent services must be visible to the node
+								if compatible && compatible_t {
+									if visible, reason_v := CheckCrossOrgServiceVisibility(resources.NodeOrg, depSvcDefs, msgPrinter); !visible {
+										compatible = false
+										reason = reason_v
+									}
+								}
+
 								if compatible && compatible_t {
 									service_compatible = true
 									service_comp[sId] = &svc
@@ -362,12 +396,23 @@ func userInputCompatible(getDeviceHandler exchange.DeviceHandler,
 							svc_other_mismatch[sId] = true
 						} else if resources.NodeType == persistence.DEVICE_TYPE_CLUSTER {
 							compatible_n, _, reason_n := CheckClusterNamespaceCompatibility(resources.NodeType, resources.NodeClusterNS, consumerNamespace, useSDef.GetClusterDeployment(), true, msgPrinter)
+							if compatible_n {
+								compatible_n, reason_n = CheckClusterRequirementsCompatibility(resources.NodeType, nodeProps, useSDef.GetClusterDeployment(), msgPrinter)
+							}
 							if !compatible_n {
 								reason = reason_n
 								svc_other_mismatch[sId] = true
 							}
 						}
 
+						// the dependent services must be visible to the node
+						if compatible && compatible_t {
+							if visible, reason_v := CheckCrossOrgServiceVisibility(resources.NodeOrg, depSvcDefs, msgPrinter); !visible {
+								compatible = false
+								reason = reason_v
+							}
+						}
+
 						if compatible && compatible_t {
 							service_compatible = true
 							service_comp[sId] = useSDef
@@ -195,8 +195,13 @@ func newHTTPClientFactory(hConfig HorizonConfig) (*HTTPClientFactory, error) {
 		MaxConnsPerHost:       maxConnsPerHost,
 		IdleConnTimeout:       idleTimeout,
 		TLSClientConfig:       &tlsConf,
+		// the custom Dial and TLSClientConfig turn HTTP/2 off unless it is forced
+		ForceAttemptHTTP2: hConfig.Edge.HTTP2,
 	}
 
+	// The responses are decompressed by the client, so that the deflate ones are too
+	compression := &compressionTransport{transport: transport, compressRequests: hConfig.Edge.HTTPCompressRequests}
+
 	clientFunc := func(overrideTimeoutS *uint) *http.Client {
 		var timeoutS uint
 
@@ -211,7 +216,7 @@ func newHTTPClientFactory(hConfig HorizonConfig) (*HTTPClientFactory, error) {
 			// body reading. This means that you must set the timeout according
 			// to the total payload size you expect
 			Timeout:   time.Second * time.Duration(timeoutS),
-			Transport: transport,
+			Transport: compression,
 		}
 	}
 
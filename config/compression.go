@@ -0,0 +1,145 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// The request bodies smaller than this are not compressed, the compression would not pay off.
+const HTTPCompressMinSize = 1024
+
+// The request bodies larger than this are not compressed, they are files that are usually compressed already.
+const HTTPCompressMaxSize = 16 * 1024 * 1024
+
+// A transport that asks the servers for gzip or deflate compressed responses and decompresses them, so that the
+// callers always read the plain response. When compressRequests is set, it also compresses the json request bodies
+// with gzip.
+type compressionTransport struct {
+	transport        *http.Transport
+	compressRequests bool
+}
+
+// Returns the transport of a client made by the HTTPClientFactory, or of another client, nil when it is not an
+// http.Transport, e.g. to read its TLS config.
+func GetHTTPTransport(client *http.Client) *http.Transport {
+	switch t := client.Transport.(type) {
+	case *http.Transport:
+		return t
+	case *compressionTransport:
+		return t.transport
+	}
+	return nil
+}
+
+func (c *compressionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	compressed := req.Header.Get("Accept-Encoding") == ""
+	if compressed || c.shouldCompress(req) {
+		req = req.Clone(req.Context())
+	}
+	if compressed {
+		req.Header.Set("Accept-Encoding", "gzip, deflate")
+	}
+	if c.shouldCompress(req) {
+		if err := compressRequestBody(req); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := c.transport.RoundTrip(req)
+	if err != nil || !compressed || req.Method == http.MethodHead {
+		return resp, err
+	}
+	return decompressResponse(resp)
+}
+
+func (c *compressionTransport) shouldCompress(req *http.Request) bool {
+	return c.compressRequests && req.Body != nil && req.Body != http.NoBody && req.Header.Get("Content-Encoding") == "" &&
+		req.ContentLength >= HTTPCompressMinSize && req.ContentLength <= HTTPCompressMaxSize &&
+		strings.HasPrefix(req.Header.Get("Content-Type"), "application/json")
+}
+
+// Replace the body of the request with its gzip compression.
+func compressRequestBody(req *http.Request) error {
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return err
+	} else if err := zw.Close(); err != nil {
+		return err
+	}
+
+	compressedBody := buf.Bytes()
+	req.Body = ioutil.NopCloser(bytes.NewReader(compressedBody))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(compressedBody)), nil
+	}
+	req.ContentLength = int64(len(compressedBody))
+	req.Header.Set("Content-Encoding", "gzip")
+	return nil
+}
+
+// Replace the body of a gzip or deflate compressed response with its decompression.
+func decompressResponse(resp *http.Response) (*http.Response, error) {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if (encoding != "gzip" && encoding != "deflate") || resp.Body == nil || resp.Body == http.NoBody {
+		return resp, nil
+	}
+
+	var reader io.ReadCloser
+	if encoding == "gzip" {
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		reader = zr
+	} else {
+		// deflate is meant to be zlib wrapped, but some servers send the raw deflate stream
+		br := bufio.NewReader(resp.Body)
+		if header, err := br.Peek(2); err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+			reader = zr
+		} else {
+			reader = flate.NewReader(br)
+		}
+	}
+
+	resp.Body = &decompressedBody{reader: reader, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// The decompressed body of a response, closing it closes the response body.
+type decompressedBody struct {
+	reader io.ReadCloser
+	body   io.ReadCloser
+}
+
+func (d *decompressedBody) Read(p []byte) (int, error) {
+	return d.reader.Read(p)
+}
+
+func (d *decompressedBody) Close() error {
+	d.reader.Close()
+	return d.body.Close()
+}
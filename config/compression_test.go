@@ -0,0 +1,129 @@
+//go:build unit
+// +build unit
+
+package config
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// The gzip and deflate responses are decompressed, whichever way the deflate stream is wrapped.
+func Test_CompressionTransport_Response(t *testing.T) {
+
+	body := strings.Repeat(`{"pattern":"netspeed"}`, 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip, deflate" {
+			t.Errorf("unexpected Accept-Encoding %v", r.Header.Get("Accept-Encoding"))
+		}
+		var zw io.WriteCloser
+		switch r.URL.Path {
+		case "/gzip":
+			zw = gzip.NewWriter(w)
+		case "/zlib":
+			zw = zlib.NewWriter(w)
+		case "/flate":
+			zw, _ = flate.NewWriter(w, flate.DefaultCompression)
+		default:
+			w.Write([]byte(body))
+			return
+		}
+		if r.URL.Path == "/gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+		} else {
+			w.Header().Set("Content-Encoding", "deflate")
+		}
+		zw.Write([]byte(body))
+		zw.Close()
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &compressionTransport{transport: &http.Transport{}}}
+	for _, path := range []string{"/gzip", "/zlib", "/flate", "/plain"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", path, err)
+		}
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Errorf("%v: unexpected error reading the body %v", path, err)
+		} else if string(b) != body {
+			t.Errorf("%v: unexpected body %v", path, string(b))
+		} else if resp.Header.Get("Content-Encoding") != "" {
+			t.Errorf("%v: the Content-Encoding %v is not removed", path, resp.Header.Get("Content-Encoding"))
+		}
+	}
+}
+
+// Only the large json request bodies are compressed, and only when it is configured.
+func Test_CompressionTransport_Request(t *testing.T) {
+
+	large := strings.Repeat(`{"service":"netspeed"}`, 100)
+
+	var encoding string
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		var reader io.Reader = r.Body
+		if encoding == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("unexpected error %v", err)
+				return
+			}
+			reader = zr
+		}
+		b, _ := ioutil.ReadAll(reader)
+		received = string(b)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		compressRequests bool
+		contentType      string
+		body             string
+		compressed       bool
+	}{
+		{true, "application/json", large, true},
+		{true, "application/json", `{"service":"netspeed"}`, false},
+		{true, "application/octet-stream", large, false},
+		{false, "application/json", large, false},
+	}
+
+	for i, test := range tests {
+		client := &http.Client{Transport: &compressionTransport{transport: &http.Transport{}, compressRequests: test.compressRequests}}
+		resp, err := client.Post(server.URL, test.contentType, bytes.NewReader([]byte(test.body)))
+		if err != nil {
+			t.Fatalf("test %v: unexpected error %v", i, err)
+		}
+		resp.Body.Close()
+
+		if (encoding == "gzip") != test.compressed {
+			t.Errorf("test %v: unexpected Content-Encoding %v", i, encoding)
+		} else if received != test.body {
+			t.Errorf("test %v: unexpected body %v", i, received)
+		}
+	}
+}
+
+func Test_GetHTTPTransport(t *testing.T) {
+
+	transport := &http.Transport{}
+	if GetHTTPTransport(&http.Client{Transport: transport}) != transport {
+		t.Errorf("the transport is not returned")
+	} else if GetHTTPTransport(&http.Client{Transport: &compressionTransport{transport: transport}}) != transport {
+		t.Errorf("the wrapped transport is not returned")
+	} else if GetHTTPTransport(&http.Client{}) != nil {
+		t.Errorf("a transport is returned for the default client")
+	}
+}
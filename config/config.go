@@ -3,9 +3,12 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"strings"
 )
 
@@ -31,19 +34,59 @@ type HorizonConfig struct {
 type Config struct {
 	ServiceStorage                   string // The base storage directory where the service can write or get the data.
 	APIListen                        string
+	APIAuth                          string   // How the clients of the API are authenticated. "peercred" serves the API on the APISocketPath unix socket to the users allowed by their peer credentials, "token" requires the bearer token held in the APITokenFile and "mtls" requires a client certificate signed by the APIClientCACert. Empty leaves the API on APIListen unauthenticated
+	APISocketPath                    string   // The unix socket of the API in the "peercred" mode. The default is /var/run/horizon/anax.sock
+	APIAllowedUsers                  []string // The local users, besides root and the user running the agent, allowed to use the API in the "peercred" mode
+	APITokenFile                     string   // The file holding the bearer token of the API in the "token" mode. It is created with a random token, readable only by the user running the agent, if it does not exist. The default is /var/horizon/api.token
+	APIServerCert                    string   // The certificate the API serves in the "mtls" mode
+	APIServerKey                     string   // The key of the APIServerCert
+	APIClientCACert                  string   // The CA certificates that sign the client certificates accepted in the "mtls" mode
+	APICORSOrigins                   []string // The origins, e.g. https://gateway.local:8443, of the web pages allowed to call the API from the browser. "*" allows any origin, which is the default
+	APIObserverTokenFile             string   // The file holding the bearer token of the read-only observer role in the "token" mode. It is created with a random token if it does not exist. No observer token is accepted when empty
+	APIObserverUsers                 []string // The local users given the read-only observer role in the "peercred" mode
+	APIObserverSocketPath            string   // A unix socket on which any local user gets the read-only observer role, e.g. an on-device dashboard. It is not served when empty
 	DBPath                           string
 	DockerEndpoint                   string
+	ContainerRuntime                 string // The container engine, "docker" or "podman" behind the DockerEndpoint, or "containerd". Empty means the agent detects it from the engine's version info. The containerd containers of all the agreements share one CNI network, they are not isolated from each other
+	ContainerdAddress                string // The containerd socket used when the ContainerRuntime is "containerd"
+	ContainerdNamespace              string // The containerd namespace holding the images and containers of the agent
+	ContainerdStateDir               string // The directory holding the logs, hosts files and volumes of the containerd containers
+	CNIConfDir                       string // The directory searched for the "horizon" CNI network that containerd containers are attached to. A default bridge network is used if it is not there
+	ContainerdSnapshotter            string // The containerd snapshotter that unpacks the images of the containerd containers, the containerd default when empty. A lazy pulling snapshotter such as "stargz" only fetches the parts of eStargz and zstd:chunked layers that the services read
+	CNIPluginDir                     string // The directory holding the CNI plugins
+	ServiceNetworkIPv6               bool   // Create the docker networks of the services with IPv6 enabled, in addition to IPv4. Each network gets its own /64 subnet from the ServiceNetworkIPv6Prefix
+	ServiceNetworkIPv6Prefix         string // The IPv6 unique local address (ULA) prefix from which the /64 subnets of the service networks are assigned, at most a /60. The default is fd00:4f48::/48
 	DockerCredFilePath               string
+	ImageDigestPolicy                string // "pin" resolves the tags of the service images to their digests when the images are pulled, and creates the containers from the digests. "require" refuses the images that are not referenced by digest. Empty pulls and runs the images as referenced
 	DefaultCPUSet                    string
+	ServiceLogMaxSize                string // The max-size of the json-file and local logs of a service container when the service does not set it, e.g. "10m". "-1" means unlimited
+	ServiceLogMaxFile                int    // The max-file of the json-file and local logs of a service container when the service does not set it
+	VolumeRetentionS                 int    // How long a named volume retained across service upgrades is kept after the last agreement of the service ends. The default is 7 days
+	PreemptionGraceS                 int    // How long a lower priority agreement keeps running after it is chosen to be preempted by a higher priority deployment policy. The default is 30 seconds, a negative value preempts immediately
 	DefaultServiceRegistrationRAM    int64
 	StaticWebContent                 string
 	PublicKeyPath                    string
 	TrustSystemCACerts               bool   // If equal to true, the HTTP client factory will set up clients that trust CA certs provided by a Linux distribution (see https://golang.org/pkg/crypto/x509/#SystemCertPool and https://golang.org/src/crypto/x509/root_linux.go)
 	CACertsPath                      string // Path to a file containing PEM-encoded x509 certs HTTP clients in Anax will trust (additive to the configuration option "TrustSystemCACerts")
 	ExchangeURL                      string
+	ExchangeURLs                     []string // More base URLs of the same exchange, e.g. in other regions. The calls to the ExchangeURL fail over to them, in order, while the circuit breaker of the ExchangeURL is open
+	ExchangeCircuitBreakerFailures   int      // The number of consecutive transport errors after which the circuit breaker of an exchange URL opens. The circuit breakers are used when ExchangeURLs or this is set, the default is 5
+	ExchangeCircuitBreakerOpenS      int      // How many seconds the circuit breaker of an exchange URL stays open before the URL is health checked and used again. The default is 30
+	ExchangeAuth                     string   // How the agent authenticates to the exchange. "oidc" authenticates with the short-lived access tokens of the OIDC identity provider at ExchangeOIDCTokenURL instead of the node token. Empty uses the node token
+	ExchangeOIDCTokenURL             string   // The token endpoint of the OIDC identity provider in the "oidc" mode
+	ExchangeOIDCClientID             string   // The client id of the agent in the OIDC identity provider
+	ExchangeOIDCClientSecretFile     string   // The file holding the client secret of the agent, if the client has one
+	ExchangeOIDCScope                string   // The scope of the access tokens, the default scope of the identity provider when empty
+	ExchangeOIDCRefreshTokenFile     string   // The file holding the first refresh token. It is only read when the agent db has no refresh token, the tokens that the identity provider rotates it to are kept in the db encrypted with the key in the ExchangeAuthKeyFile. The client credentials are used when there is no refresh token, or when the identity provider rejects it
+	ExchangeAuthKeyFile              string   // The file holding the key that encrypts the exchange refresh token in the agent db, created if it does not exist. It must not be in the DBPath, so that a copy of the db directory does not reveal the token. The default is /etc/horizon/exchange_auth.key
+	ExchangeOIDCClockSkewS           int      // How many seconds before an access token expires it is refreshed. The default is 30
 	AgbotURL                         string
+	AgbotGRPCAddress                 string // The host:port of the agbot gRPC agreement transport. When set, agreement protocol messages are sent directly to the agbot on a stream, instead of through the exchange. Empty turns it off
+	AgbotGRPCInsecure                bool   // If equal to true, the stream to the AgbotGRPCAddress is not protected by TLS. For testing only
 	DefaultHTTPClientTimeoutS        uint
 	HTTPIdleConnectionTimeout        uint // Will be seconds for agbot and milliseconds for agent
+	HTTPCompressRequests             bool // If equal to true, the json request bodies of 1KB or more sent to the exchange, CSS and agbot are compressed with gzip. The servers must accept compressed requests. The responses are always requested compressed
+	HTTP2                            bool // If equal to true, the calls to the exchange, CSS and agbot use HTTP/2 when the server supports it, so that the calls share one connection
 	PolicyPath                       string
 	ExchangeHeartbeat                int       // Seconds between heartbeats
 	ExchangeVersionCheckIntervalM    int64     // Exchange version check interval in minutes. The default is 720. This is now deprecated with the usage of /changes API which returns exchange version on every call.
@@ -60,13 +103,18 @@ type Config struct {
 	ReportDeviceStatus               bool      // whether to report the device status to the exchange or not.
 	TrustCertUpdatesFromOrg          bool      // whether to trust the certs provided by the organization on the exchange or not.
 	TrustDockerAuthFromOrg           bool      // whether to turst the docker auths provided by the organization on the exchange or not.
+	CrossOrgServiceOrgs              []string  // The orgs, other than the org of a service, whose services it can require, e.g. the public IBM org. "*" allows all the orgs. Empty means all the orgs are allowed
+	ImageAuthRefreshIntervalS        int       // Seconds between the refreshes of the docker auths of the running services from the exchange. The default is 1800, a negative value turns the refresh off
 	ServiceUpgradeCheckIntervalS     int64     // service upgrade check interval in seconds. The default is 300 seconds.
 	MultipleAnaxInstances            bool      // multiple anax instances running on the same machine
 	DefaultServiceRetryCount         int       // the default service retry count if retries are not specified by the policy file. The default value is 2.
 	DefaultServiceRetryDuration      uint64    // the default retry duration in seconds. The next retry cycle occurs after the duration. The default value is 600
+	ServiceRetryBackoffS             int       // the delay in seconds before the first restart of a failed service, the delay doubles with each retry. The default value is 10
+	ServiceRetryBackoffMaxS          int       // the maximum delay in seconds between the restarts of a failed service when its restart_policy does not set one. The default value is 300
 	DefaultNodePolicyFile            string    // the default node policy file name.
 	NodeCheckIntervalS               int       // the node check interval. The default is 15 seconds.
 	NodePolicyCheckIntervalS         int       // the node policy check interval. The default is 15 seconds.
+	GeoLocationFile                  string    // The json file, e.g. kept up to date by a GPS daemon, that the openhorizon.geo.lat and openhorizon.geo.long node properties are read from. Empty omits them
 	FileSyncService                  FSSConfig // The config for the embedded ESS sync service.
 	SurfaceErrorTimeoutS             int       // How long surfaced errors will remain active after they're created. Default is no timeout
 	SurfaceErrorCheckIntervalS       int       // Deprecated. Used to be how often the node will check for errors that are no longer active and update the exchange. Default is 15 seconds
@@ -74,14 +122,81 @@ type Config struct {
 	InitialPollingBuffer             int       // the number of seconds to wait before increasing the polling interval while there is no agreement on the node.
 	MaxAgreementPrelaunchTimeM       int64     // The maximum numbers of minutes to wait for workload to start in an agreement
 	K8sCRInstallTimeoutS             int64     // The number of seconds to wait for the custom resouce to install successfully before it is considered a failure
+	K8sDeploymentRolloutTimeoutS     int64     // The number of seconds to wait for the operator deployment to roll out before it is considered a failure. Zero means the agent does not wait for the rollout
+	K8sCRDEstablishedTimeoutS        int64     // The number of seconds to wait for a custom resource definition to be established before it is considered a failure
+	K8sNamespaceActiveTimeoutS       int64     // The number of seconds to wait for a namespace to become active before it is considered a failure
+	K8sImageRegistryMirror           string    // The local registry mirror (host[:port][/path]) that replaces the registry of the operator images, used by air-gapped clusters
+	K8sImageAllowList                []string  // The image prefixes that the operator and custom resource images must match, up to a "/", ":" or "@" of the image. Empty means all images are allowed
+	K8sMaxConcurrentInstalls         int       // The number of namespaces in which operators are installed, uninstalled and maintained at the same time. Work in the same namespace is always done in order
 	SecretsManagerFilePath           string    // The filepath for the secrets manager to store secrets in the agent filesystem
+	ServiceMTLS                      bool      // Issue a certificate signed by the node's service CA to each service so that the services can authenticate each other with mutual TLS
+	ServiceMTLSPath                  string    // The filepath where the node's service CA and the service certificates are stored in the agent filesystem
+	ServiceDeviceRebind              bool      // Watch the device events of the host and recreate the service containers whose devices are plugged back in under a new device node
+	ServiceDNS                       bool      // Connect the service containers of all the agreements to a shared network on which they resolve each other as <service name>.horizon
+	ServiceCheckpointPath            string    // The filepath where the container engine writes the checkpoints of the service containers stopped by a service upgrade, it must be the same path on the host
+	ServiceStatsIntervalS            int       // Seconds between the collections of the CPU, memory, network and block IO usage of the service containers. The default is 60, a negative value turns the collection off
+	ServiceStatsReport               bool      // Publish the resource usage of each service, summed over its containers, with the node status in the exchange
+	ReportFreeMemory                 bool      // Publish the free memory of the node with the node status in the exchange, for the placement constraints of the deployment policies
 	NodeMgmtWorkDirectory            string    // The filepath for the node management policy updates to use
+	WasmRuntimePath                  string    // The wasmtime executable that runs the services deployed as WebAssembly modules. The default is the wasmtime found in the PATH
+	WasmStateDir                     string    // The directory holding the modules, logs and pid files of the services deployed as WebAssembly modules
+	HostProcessDir                   string    // The directory holding the binaries and environment files of the services run as host processes
+	HostProcessEnabled               bool      // Whether the services deployed as binaries can run as host processes on this node. The default is false
+	HostProcessUsers                 []string  // The host users the host processes can run as, other than root. By default the processes run as dynamic users and cannot choose a user
+	LogVerbosity                     *int      // The glog verbosity level of the agent, it overrides the -v flag. It can be changed without restarting the agent with PUT /config/reload
+
+	// External programs that contribute properties, e.g. the model of an attached PLC, to the node policy.
+	NodePropertyProviders []NodePropertyProvider
 
 	// these Ids could be provided in config or discovered after startup by the system
 	BlockchainAccountId        string
 	BlockchainDirectoryAddress string
 }
 
+// An external program that contributes node properties. The agent runs it every IntervalS seconds and merges the
+// properties it writes to its stdout, a json array of {"name": ..., "value": ...}, into the node policy.
+type NodePropertyProvider struct {
+	Name       string            // The name of the provider, used in the logs.
+	Command    string            // The executable of the provider.
+	Args       []string          // The arguments of the executable.
+	IntervalS  int               // Seconds between the runs of the provider. The default is 300.
+	TimeoutS   int               // Seconds after which a run of the provider is killed. The default is 30.
+	Properties map[string]string // The schema of the properties the provider can contribute, the type (string, int, float, boolean, version or list of strings) of each property name.
+}
+
+func (p NodePropertyProvider) String() string {
+	return fmt.Sprintf("Name: %v, Command: %v, Args: %v, IntervalS: %v, TimeoutS: %v, Properties: %v", p.Name, p.Command, p.Args, p.IntervalS, p.TimeoutS, p.Properties)
+}
+
+// Validate the node property providers of the agent. A property can only be contributed by one provider and the
+// built-in openhorizon properties cannot be contributed by any.
+func ValidateNodePropertyProviders(providers []NodePropertyProvider) error {
+	names := make(map[string]bool)
+	props := make(map[string]string)
+	for _, p := range providers {
+		if p.Name == "" {
+			return fmt.Errorf("a node property provider must have a Name")
+		} else if names[p.Name] {
+			return fmt.Errorf("node property provider %v is configured more than once", p.Name)
+		} else if p.Command == "" {
+			return fmt.Errorf("node property provider %v must have a Command", p.Name)
+		} else if len(p.Properties) == 0 {
+			return fmt.Errorf("node property provider %v must declare at least one property", p.Name)
+		}
+		names[p.Name] = true
+
+		for prop := range p.Properties {
+			if strings.HasPrefix(prop, "openhorizon.") {
+				return fmt.Errorf("node property provider %v cannot contribute the built-in property %v", p.Name, prop)
+			} else if other, ok := props[prop]; ok {
+				return fmt.Errorf("property %v of node property provider %v is already contributed by %v", prop, p.Name, other)
+			}
+			props[prop] = p.Name
+		}
+	}
+	return nil
+}
+
 // This is the configuration options for Agreement bot flavor of Anax
 type AGConfig struct {
 	TxLostDelayTolerationSeconds  int
@@ -117,7 +232,10 @@ type AGConfig struct {
 	SecureAPIListenPort           string           // The port for the secure API to listen on
 	SecureAPIServerCert           string           // The path to the certificate file for the secure api
 	SecureAPIServerKey            string           // The path to the server key file for the secure api
+	AgreementGRPCListen           string           // Host and port for the gRPC agreement transport to listen on, the stream is protected by the SecureAPIServerCert and SecureAPIServerKey. Empty turns it off.
+	AgreementGRPCInsecure         bool             // If equal to true, the gRPC agreement transport is served without TLS when there is no SecureAPIServerCert and SecureAPIServerKey. For testing only
 	PurgeArchivedAgreementHours   int              // Number of hours to leave an archived agreement in the database before automatically deleting it
+	AgreementHistoryMonths        int              // Number of months, counting the current one, to keep the purged archived agreements in the agreement history. Zero turns off the history, purged agreements are deleted.
 	CheckUpdatedPolicyS           int              // The number of seconds to wait between checks for an updated policy file. Zero means auto checking is turned off.
 	CSSURL                        string           // The URL used to access the CSS.
 	CSSSSLCert                    string           // The path to the client side SSL certificate for the CSS.
@@ -130,9 +248,61 @@ type AGConfig struct {
 	MaxExchangeChanges            int              // The maximum number of exchange changes to request on a given call the exchange /changes API.
 	RetryLookBackWindow           uint64           // The time window (in seconds) used by the agbot to look backward in time for node changes when node agreements are retried.
 	PolicySearchOrder             bool             // When true, search policies from most recently changed to least recently changed.
+	ShardNodes                    bool             // When true, the agbots sharing the Postgresql database partition the nodes by a hash of the node id, each node is negotiated by one agbot.
+	IncrementalSearchNodes        int              // The max number of changed nodes that the agbot matches to its patterns and deployment policies itself, instead of searching the exchange for each of them. Zero turns it off.
+	OrgAgreementRateLimit         int              // The max number of agreements initiated per minute for the deployment policies and patterns of each org. Zero means no limit.
+	PolicyAgreementRateLimit      int              // The max number of agreements initiated per minute for each deployment policy or pattern. Zero means no limit.
+	NodePolicyKeyPath             string           // The directory of the public keys that sign node policies, with a sub-directory of keys for each org.
+	SignedNodeProperties          []string         // The node properties that are only matched against the policies when they are signed by a key of the node's org.
 	Vault                         VaultConfig      // The hashicorp vault config to connect to and fetch secrets from.
 	SecretsUpdateCheck            int              // The number of seconds between checks for updated secrets.
 	CSSDestinationBatchSize       int              // The max number of destination updates to send to CSS in a single update.
+
+	// Other exchange instances whose nodes are served by the agbot, each one hosting its own orgs.
+	FederatedExchanges []FederatedExchange
+}
+
+// An exchange instance, other than the one in ExchangeURL, whose nodes are served by the agbot. The orgs of a
+// federated exchange are only read from that exchange, with the agbot's credentials in that exchange.
+type FederatedExchange struct {
+	Name          string   // The name of the exchange, used in the logs and the agbot API.
+	ExchangeURL   string   // The URL of the exchange.
+	ExchangeId    string   // The org qualified id of the agbot in the exchange.
+	ExchangeToken string   // The agbot's authentication token in the exchange.
+	Orgs          []string // The orgs hosted by the exchange. An org can only be hosted by one exchange.
+}
+
+func (f FederatedExchange) String() string {
+	return fmt.Sprintf("Name: %v, ExchangeURL: %v, ExchangeId: %v, ExchangeToken: ******, Orgs: %v", f.Name, f.ExchangeURL, f.ExchangeId, f.Orgs)
+}
+
+// Validate the federated exchanges of the agbot. The orgs of the federation must be unique, including the org of the
+// agbot which is always hosted by the agbot's own exchange.
+func ValidateFederatedExchanges(exchanges []FederatedExchange, agbotId string) error {
+	names := make(map[string]bool)
+	orgs := map[string]string{strings.Split(agbotId, "/")[0]: "the agbot's exchange"}
+	for _, fe := range exchanges {
+		if fe.Name == "" {
+			return fmt.Errorf("a federated exchange must have a Name")
+		} else if names[fe.Name] {
+			return fmt.Errorf("federated exchange %v is configured more than once", fe.Name)
+		} else if fe.ExchangeURL == "" || fe.ExchangeToken == "" {
+			return fmt.Errorf("federated exchange %v must have an ExchangeURL and an ExchangeToken", fe.Name)
+		} else if parts := strings.Split(fe.ExchangeId, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("federated exchange %v ExchangeId %v must be org qualified", fe.Name, fe.ExchangeId)
+		} else if len(fe.Orgs) == 0 {
+			return fmt.Errorf("federated exchange %v must host at least one org", fe.Name)
+		}
+		names[fe.Name] = true
+
+		for _, org := range fe.Orgs {
+			if other, ok := orgs[org]; ok {
+				return fmt.Errorf("org %v of federated exchange %v is already hosted by %v", org, fe.Name, other)
+			}
+			orgs[org] = fe.Name
+		}
+	}
+	return nil
 }
 
 // Contains the hashicorp vault configuration used within AGConfig.
@@ -157,6 +327,11 @@ func (c *HorizonConfig) UserPublicKeyPath() string {
 	return c.Edge.UserPublicKeyPath
 }
 
+// Returns true if any of the agbot config is set. The agbot config is not comparable because of the federated exchanges.
+func (c *HorizonConfig) IsAgbotConfigured() bool {
+	return !reflect.DeepEqual(c.AgreementBot, AGConfig{})
+}
+
 func (c *HorizonConfig) IsBoltDBConfigured() bool {
 	return len(c.AgreementBot.DBPath) != 0
 }
@@ -186,6 +361,20 @@ func (c *HorizonConfig) GetSecretsManagerFilePath() string {
 	return secPath
 }
 
+func (c *HorizonConfig) GetServiceMTLSPath() string {
+	if c.Edge.ServiceMTLSPath == "" {
+		return path.Join(getDefaultBase(), HZN_MTLS_PATH)
+	}
+	return c.Edge.ServiceMTLSPath
+}
+
+func (c *HorizonConfig) GetServiceCheckpointPath() string {
+	if c.Edge.ServiceCheckpointPath == "" {
+		return path.Join(getDefaultBase(), HZN_CHECKPOINT_PATH)
+	}
+	return c.Edge.ServiceCheckpointPath
+}
+
 func (c *HorizonConfig) GetSecretsUpdateCheck() int {
 	return c.AgreementBot.SecretsUpdateCheck
 }
@@ -234,6 +423,14 @@ func (c *HorizonConfig) GetAgbotPolicyOrder() bool {
 	return c.AgreementBot.PolicySearchOrder
 }
 
+func (c *HorizonConfig) GetAgbotShardNodes() bool {
+	return c.AgreementBot.ShardNodes
+}
+
+func (c *HorizonConfig) GetAgbotIncrementalSearchNodes() int {
+	return c.AgreementBot.IncrementalSearchNodes
+}
+
 func (c *HorizonConfig) GetK8sCRInstallTimeouts() int64 {
 	if c.Edge.K8sCRInstallTimeoutS > 0 {
 		return c.Edge.K8sCRInstallTimeoutS
@@ -241,6 +438,193 @@ func (c *HorizonConfig) GetK8sCRInstallTimeouts() int64 {
 	return K8sCRInstallTimeoutS_DEFAULT
 }
 
+func (c *HorizonConfig) GetK8sDeploymentRolloutTimeoutS() int64 {
+	if c.Edge.K8sDeploymentRolloutTimeoutS > 0 {
+		return c.Edge.K8sDeploymentRolloutTimeoutS
+	}
+	return 0
+}
+
+func (c *HorizonConfig) GetK8sCRDEstablishedTimeoutS() int64 {
+	if c.Edge.K8sCRDEstablishedTimeoutS > 0 {
+		return c.Edge.K8sCRDEstablishedTimeoutS
+	}
+	return K8sCRDEstablishedTimeoutS_DEFAULT
+}
+
+func (c *HorizonConfig) GetK8sNamespaceActiveTimeoutS() int64 {
+	if c.Edge.K8sNamespaceActiveTimeoutS > 0 {
+		return c.Edge.K8sNamespaceActiveTimeoutS
+	}
+	return K8sNamespaceActiveTimeoutS_DEFAULT
+}
+
+func (c *HorizonConfig) GetK8sMaxConcurrentInstalls() int {
+	if c.Edge.K8sMaxConcurrentInstalls > 0 {
+		return c.Edge.K8sMaxConcurrentInstalls
+	}
+	return K8sMaxConcurrentInstalls_DEFAULT
+}
+
+func (c *HorizonConfig) GetServiceLogMaxSize() string {
+	if c.Edge.ServiceLogMaxSize != "" {
+		return c.Edge.ServiceLogMaxSize
+	}
+	return ServiceLogMaxSize_DEFAULT
+}
+
+func (c *HorizonConfig) GetServiceLogMaxFile() int {
+	if c.Edge.ServiceLogMaxFile > 0 {
+		return c.Edge.ServiceLogMaxFile
+	}
+	return ServiceLogMaxFile_DEFAULT
+}
+
+func (c *HorizonConfig) GetServiceRetryBackoff() int {
+	if c.Edge.ServiceRetryBackoffS > 0 {
+		return c.Edge.ServiceRetryBackoffS
+	}
+	return ServiceRetryBackoffS_DEFAULT
+}
+
+func (c *HorizonConfig) GetServiceRetryBackoffMax() int {
+	if c.Edge.ServiceRetryBackoffMaxS > 0 {
+		return c.Edge.ServiceRetryBackoffMaxS
+	}
+	return ServiceRetryBackoffMaxS_DEFAULT
+}
+
+func (c *HorizonConfig) GetPreemptionGrace() int {
+	if c.Edge.PreemptionGraceS > 0 {
+		return c.Edge.PreemptionGraceS
+	} else if c.Edge.PreemptionGraceS < 0 {
+		return 0
+	}
+	return PreemptionGraceS_DEFAULT
+}
+
+func (c *HorizonConfig) GetVolumeRetention() int {
+	if c.Edge.VolumeRetentionS > 0 {
+		return c.Edge.VolumeRetentionS
+	}
+	return VolumeRetentionS_DEFAULT
+}
+
+// Returns the number of seconds between the collections of the service container stats, 0 when they are not collected.
+func (c *HorizonConfig) GetServiceStatsInterval() int {
+	if c.Edge.ServiceStatsIntervalS < 0 {
+		return 0
+	} else if c.Edge.ServiceStatsIntervalS == 0 {
+		return ServiceStatsIntervalS_DEFAULT
+	}
+	return c.Edge.ServiceStatsIntervalS
+}
+
+// Returns the number of seconds between the refreshes of the docker auths of the running services, 0 when they are not
+// refreshed.
+func (c *HorizonConfig) GetImageAuthRefreshInterval() int {
+	if c.Edge.ImageAuthRefreshIntervalS < 0 {
+		return 0
+	} else if c.Edge.ImageAuthRefreshIntervalS == 0 {
+		return ImageAuthRefreshIntervalS_DEFAULT
+	}
+	return c.Edge.ImageAuthRefreshIntervalS
+}
+
+func (c *HorizonConfig) GetContainerRuntime() string {
+	return strings.ToLower(strings.TrimSpace(c.Edge.ContainerRuntime))
+}
+
+// Returns true if the agent drives containerd directly instead of a docker API endpoint.
+func (c *HorizonConfig) IsContainerdRuntime() bool {
+	return c.GetContainerRuntime() == ContainerRuntime_CONTAINERD
+}
+
+func (c *HorizonConfig) GetContainerdAddress() string {
+	if c.Edge.ContainerdAddress != "" {
+		return c.Edge.ContainerdAddress
+	}
+	return ContainerdAddress_DEFAULT
+}
+
+func (c *HorizonConfig) GetContainerdNamespace() string {
+	if c.Edge.ContainerdNamespace != "" {
+		return c.Edge.ContainerdNamespace
+	}
+	return ContainerdNamespace_DEFAULT
+}
+
+func (c *HorizonConfig) GetContainerdStateDir() string {
+	if c.Edge.ContainerdStateDir != "" {
+		return c.Edge.ContainerdStateDir
+	}
+	return path.Join(getDefaultBase(), "containerd")
+}
+
+func (c *HorizonConfig) GetWasmRuntimePath() string {
+	if c.Edge.WasmRuntimePath != "" {
+		return c.Edge.WasmRuntimePath
+	}
+	return WasmRuntimePath_DEFAULT
+}
+
+func (c *HorizonConfig) GetWasmStateDir() string {
+	if c.Edge.WasmStateDir != "" {
+		return c.Edge.WasmStateDir
+	}
+	return path.Join(getDefaultBase(), "wasm")
+}
+
+func (c *HorizonConfig) GetHostProcessDir() string {
+	if c.Edge.HostProcessDir != "" {
+		return c.Edge.HostProcessDir
+	}
+	return path.Join(getDefaultBase(), "hostprocess")
+}
+
+func (c *HorizonConfig) GetCNIConfDir() string {
+	if c.Edge.CNIConfDir != "" {
+		return c.Edge.CNIConfDir
+	}
+	return CNIConfDir_DEFAULT
+}
+
+// Returns the IPv6 prefix of the service networks, or an empty string when the service networks are IPv4 only.
+func (c *HorizonConfig) GetServiceNetworkIPv6Prefix() string {
+	if !c.Edge.ServiceNetworkIPv6 {
+		return ""
+	} else if c.Edge.ServiceNetworkIPv6Prefix != "" {
+		return c.Edge.ServiceNetworkIPv6Prefix
+	}
+	return ServiceNetworkIPv6Prefix_DEFAULT
+}
+
+// The IPv6 prefix of the service networks must be a unique local address (fc00::/7) prefix, large enough to hold
+// several /64 subnets.
+func ValidateIPv6Prefix(prefix string) error {
+	ip, ipNet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return err
+	} else if ip.To4() != nil {
+		return fmt.Errorf("%v is not an IPv6 prefix", prefix)
+	}
+
+	_, ula, _ := net.ParseCIDR("fc00::/7")
+	if !ula.Contains(ip) {
+		return fmt.Errorf("%v is not a unique local address prefix", prefix)
+	} else if ones, _ := ipNet.Mask.Size(); ones > 60 {
+		return fmt.Errorf("%v is smaller than a /60", prefix)
+	}
+	return nil
+}
+
+func (c *HorizonConfig) GetCNIPluginDir() string {
+	if c.Edge.CNIPluginDir != "" {
+		return c.Edge.CNIPluginDir
+	}
+	return CNIPluginDir_DEFAULT
+}
+
 func (a *AGConfig) GetProtocolTimeout(maxHeartbeatInterval int) uint64 {
 	if a.ProtocolTimeoutS != 0 {
 		return a.ProtocolTimeoutS
@@ -301,6 +685,67 @@ func (a *AGConfig) GetExchangeMessageTTL(maxHeartbeatInterval int) int {
 	return int(float64(hbInterval) * scaleFactor)
 }
 
+// Returns how the clients of the API are authenticated, APIAuth_PEERCRED, APIAuth_TOKEN, APIAuth_MTLS or empty.
+func (c *Config) GetAPIAuth() string {
+	return strings.ToLower(strings.TrimSpace(c.APIAuth))
+}
+
+// Returns the origins allowed to call the API from the browser, any origin when none is configured.
+func (c *Config) GetAPICORSOrigins() []string {
+	if len(c.APICORSOrigins) != 0 {
+		return c.APICORSOrigins
+	}
+	return []string{"*"}
+}
+
+// An origin is "*" or the scheme, host and optional port of a web page, e.g. https://gateway.local:8443.
+func ValidateCORSOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("the scheme must be http or https")
+	} else if u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return fmt.Errorf("an origin is only a scheme, a host and an optional port")
+	}
+	return nil
+}
+
+// Returns how the agent authenticates to the exchange, ExchangeAuth_OIDC or empty.
+func (c *Config) GetExchangeAuth() string {
+	return strings.ToLower(strings.TrimSpace(c.ExchangeAuth))
+}
+
+// The file holding the key that encrypts the exchange refresh token in the agent db.
+func (c *Config) GetExchangeAuthKeyFile() string {
+	if c.ExchangeAuthKeyFile != "" {
+		return c.ExchangeAuthKeyFile
+	}
+	return ExchangeAuthKeyFile_DEFAULT
+}
+
+func (c *Config) GetAPISocketPath() string {
+	if c.APISocketPath != "" {
+		return c.APISocketPath
+	}
+	return APISocketPath_DEFAULT
+}
+
+func (c *Config) GetAPITokenFile() string {
+	if c.APITokenFile != "" {
+		return c.APITokenFile
+	}
+	return APITokenFile_DEFAULT
+}
+
+// Returns how the service images are tied to their digests, ImageDigestPolicy_PIN, ImageDigestPolicy_REQUIRE or empty.
+func (c *Config) GetImageDigestPolicy() string {
+	return strings.ToLower(strings.TrimSpace(c.ImageDigestPolicy))
+}
+
 func (c *Config) GetNodeMgmtDirectory() string {
 	if c.NodeMgmtWorkDirectory == "" {
 		return fmt.Sprintf("%v/nmp", getDefaultBase())
@@ -390,6 +835,9 @@ func Read(file string) (*HorizonConfig, error) {
 				ExchangeMessagePollIncrement:   ExchangeMessagePollIncrement_DEFAULT,
 				MaxAgreementPrelaunchTimeM:     EdgeMaxAgreementPrelaunchTimeM_DEFAULT,
 				K8sCRInstallTimeoutS:           K8sCRInstallTimeoutS_DEFAULT,
+				K8sCRDEstablishedTimeoutS:      K8sCRDEstablishedTimeoutS_DEFAULT,
+				K8sNamespaceActiveTimeoutS:     K8sNamespaceActiveTimeoutS_DEFAULT,
+				K8sMaxConcurrentInstalls:       K8sMaxConcurrentInstalls_DEFAULT,
 			},
 			AgreementBot: AGConfig{
 				MessageKeyCheck:         AgbotMessageKeyCheck_DEFAULT,
@@ -401,6 +849,7 @@ func Read(file string) (*HorizonConfig, error) {
 				MaxExchangeChanges:      AgbotMaxChanges_DEFAULT,
 				RetryLookBackWindow:     AgbotRetryLookBackWindow_DEFAULT,
 				PolicySearchOrder:       AgbotPolicySearchOrder_DEFAULT,
+				IncrementalSearchNodes:  AgbotIncrementalSearchNodes_DEFAULT,
 				SecretsUpdateCheck:      SecretsUpdateCheck_DEFAULT,
 				CSSDestinationBatchSize: AgbotCSSDestinationBatchSize_DEFAULT,
 			},
@@ -430,6 +879,15 @@ func Read(file string) (*HorizonConfig, error) {
 			config.Edge.NodePolicyCheckIntervalS = 15
 		}
 
+		for i, p := range config.Edge.NodePropertyProviders {
+			if p.IntervalS == 0 {
+				config.Edge.NodePropertyProviders[i].IntervalS = 300
+			}
+			if p.TimeoutS == 0 {
+				config.Edge.NodePropertyProviders[i].TimeoutS = 30
+			}
+		}
+
 		if config.Edge.SurfaceErrorCheckIntervalS == 0 {
 			config.Edge.SurfaceErrorCheckIntervalS = 15
 		}
@@ -460,6 +918,9 @@ func Read(file string) (*HorizonConfig, error) {
 		if config.AgreementBot.ExchangeURL != "" {
 			config.AgreementBot.ExchangeURL = strings.TrimRight(config.AgreementBot.ExchangeURL, "/") + "/"
 		}
+		for i, fe := range config.AgreementBot.FederatedExchanges {
+			config.AgreementBot.FederatedExchanges[i].ExchangeURL = strings.TrimRight(fe.ExchangeURL, "/") + "/"
+		}
 
 		// add a slash at the back of the PolicyPath
 		if config.Edge.PolicyPath != "" {
@@ -486,6 +947,76 @@ func Read(file string) (*HorizonConfig, error) {
 			config.AgreementBot.MMSGarbageCollectionInterval = 300
 		}
 
+		if prefix := config.GetServiceNetworkIPv6Prefix(); prefix != "" {
+			if err := ValidateIPv6Prefix(prefix); err != nil {
+				return nil, fmt.Errorf("Invalid ServiceNetworkIPv6Prefix %v in config file: %v", prefix, err)
+			}
+		}
+
+		if config.Edge.LogVerbosity != nil && *config.Edge.LogVerbosity < 0 {
+			return nil, fmt.Errorf("Invalid LogVerbosity %v in config file, it must not be negative", *config.Edge.LogVerbosity)
+		}
+
+		if policy := config.Edge.GetImageDigestPolicy(); policy != "" && policy != ImageDigestPolicy_PIN && policy != ImageDigestPolicy_REQUIRE {
+			return nil, fmt.Errorf("Invalid ImageDigestPolicy %v in config file, it must be %v or %v", config.Edge.ImageDigestPolicy, ImageDigestPolicy_PIN, ImageDigestPolicy_REQUIRE)
+		}
+
+		for _, origin := range config.Edge.APICORSOrigins {
+			if err := ValidateCORSOrigin(origin); err != nil {
+				return nil, fmt.Errorf("Invalid APICORSOrigins %v in config file: %v", origin, err)
+			}
+		}
+
+		if config.Edge.APIObserverTokenFile != "" && config.Edge.GetAPIAuth() != APIAuth_TOKEN {
+			return nil, fmt.Errorf("APIObserverTokenFile can only be set in config file when APIAuth is %v", APIAuth_TOKEN)
+		} else if len(config.Edge.APIObserverUsers) != 0 && config.Edge.GetAPIAuth() != APIAuth_PEERCRED {
+			return nil, fmt.Errorf("APIObserverUsers can only be set in config file when APIAuth is %v", APIAuth_PEERCRED)
+		} else if config.Edge.APIObserverSocketPath != "" && !filepath.IsAbs(config.Edge.APIObserverSocketPath) {
+			return nil, fmt.Errorf("Invalid APIObserverSocketPath %v in config file, it must be an absolute path", config.Edge.APIObserverSocketPath)
+		} else if config.Edge.APIObserverSocketPath != "" && config.Edge.GetAPIAuth() == APIAuth_PEERCRED && filepath.Clean(config.Edge.APIObserverSocketPath) == filepath.Clean(config.Edge.GetAPISocketPath()) {
+			return nil, fmt.Errorf("Invalid APIObserverSocketPath %v in config file, it must not be the APISocketPath", config.Edge.APIObserverSocketPath)
+		}
+
+		if config.AgreementBot.ShardNodes && !config.IsPostgresqlConfigured() {
+			return nil, fmt.Errorf("AgreementBot ShardNodes can only be set in config file when the agbot uses a Postgresql database")
+		}
+
+		if err := ValidateFederatedExchanges(config.AgreementBot.FederatedExchanges, config.AgreementBot.ExchangeId); err != nil {
+			return nil, fmt.Errorf("Invalid AgreementBot FederatedExchanges in config file: %v", err)
+		}
+
+		if err := ValidateNodePropertyProviders(config.Edge.NodePropertyProviders); err != nil {
+			return nil, fmt.Errorf("Invalid NodePropertyProviders in config file: %v", err)
+		}
+
+		for _, exchURL := range config.Edge.ExchangeURLs {
+			if u, err := url.Parse(exchURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("Invalid ExchangeURLs %v in config file, it must be an http or https URL", exchURL)
+			}
+		}
+
+		switch config.Edge.GetExchangeAuth() {
+		case "":
+		case ExchangeAuth_OIDC:
+			if config.Edge.ExchangeOIDCTokenURL == "" || config.Edge.ExchangeOIDCClientID == "" {
+				return nil, fmt.Errorf("ExchangeOIDCTokenURL and ExchangeOIDCClientID must be set in config file when ExchangeAuth is %v", ExchangeAuth_OIDC)
+			} else if rel, err := filepath.Rel(filepath.Clean(config.Edge.DBPath), filepath.Clean(config.Edge.GetExchangeAuthKeyFile())); config.Edge.DBPath != "" && err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+				return nil, fmt.Errorf("Invalid ExchangeAuthKeyFile %v in config file, it must not be in the DBPath %v", config.Edge.GetExchangeAuthKeyFile(), config.Edge.DBPath)
+			}
+		default:
+			return nil, fmt.Errorf("Invalid ExchangeAuth %v in config file, it must be %v", config.Edge.ExchangeAuth, ExchangeAuth_OIDC)
+		}
+
+		switch config.Edge.GetAPIAuth() {
+		case "", APIAuth_PEERCRED, APIAuth_TOKEN:
+		case APIAuth_MTLS:
+			if config.Edge.APIServerCert == "" || config.Edge.APIServerKey == "" || config.Edge.APIClientCACert == "" {
+				return nil, fmt.Errorf("APIServerCert, APIServerKey and APIClientCACert must be set in config file when APIAuth is %v", APIAuth_MTLS)
+			}
+		default:
+			return nil, fmt.Errorf("Invalid APIAuth %v in config file, it must be %v, %v or %v", config.Edge.APIAuth, APIAuth_PEERCRED, APIAuth_TOKEN, APIAuth_MTLS)
+		}
+
 		// success at last!
 		return &config, nil
 	}
@@ -498,19 +1029,43 @@ func (c *HorizonConfig) String() string {
 func (con *Config) String() string {
 	return fmt.Sprintf("ServiceStorage %v"+
 		", APIListen %v"+
+		", APIAuth %v"+
+		", APICORSOrigins %v"+
+		", APIObserverTokenFile %v"+
+		", APIObserverUsers %v"+
+		", APIObserverSocketPath %v"+
 		", DBPath %v"+
 		", DockerEndpoint %v"+
+		", ContainerRuntime %v"+
+		", ServiceNetworkIPv6 %v"+
+		", ServiceNetworkIPv6Prefix %v"+
 		", DockerCredFilePath %v"+
+		", ImageDigestPolicy %v"+
 		", DefaultCPUSet %v"+
+		", ServiceLogMaxSize %v"+
+		", ServiceLogMaxFile %v"+
+		", VolumeRetentionS %v"+
+		", PreemptionGraceS %v"+
 		", DefaultServiceRegistrationRAM: %v"+
 		", StaticWebContent: %v"+
 		", PublicKeyPath: %v"+
 		", TrustSystemCACerts: %v"+
 		", CACertsPath: %v"+
 		", ExchangeURL: %v"+
+		", ExchangeURLs: %v"+
+		", ExchangeCircuitBreakerFailures: %v"+
+		", ExchangeCircuitBreakerOpenS: %v"+
+		", ExchangeAuth: %v"+
+		", ExchangeOIDCTokenURL: %v"+
+		", ExchangeOIDCClientID: %v"+
+		", ExchangeAuthKeyFile: %v"+
 		", AgbotURL: %v"+
+		", AgbotGRPCAddress: %v"+
+		", AgbotGRPCInsecure: %v"+
 		", DefaultHTTPClientTimeoutS: %v"+
 		", HTTPIdleConnectionTimeout: %v"+
+		", HTTPCompressRequests: %v"+
+		", HTTP2: %v"+
 		", PolicyPath: %v"+
 		", ExchangeHeartbeat: %v"+
 		", AgreementTimeoutS: %v"+
@@ -525,22 +1080,45 @@ func (con *Config) String() string {
 		", ReportDeviceStatus: %v"+
 		", TrustCertUpdatesFromOrg: %v"+
 		", TrustDockerAuthFromOrg: %v"+
+		", CrossOrgServiceOrgs: %v"+
+		", ImageAuthRefreshIntervalS: %v"+
 		", ServiceUpgradeCheckIntervalS: %v"+
 		", MultipleAnaxInstances: %v"+
 		", DefaultServiceRetryCount: %v"+
 		", DefaultServiceRetryDuration: %v"+
+		", ServiceRetryBackoffS: %v"+
+		", ServiceRetryBackoffMaxS: %v"+
 		", NodeCheckIntervalS: %v"+
+		", GeoLocationFile: %v"+
+		", ServiceMTLS: %v"+
+		", ServiceMTLSPath: %v"+
+		", ServiceDeviceRebind: %v"+
+		", ServiceDNS: %v"+
+		", ServiceCheckpointPath: %v"+
+		", ServiceStatsIntervalS: %v"+
+		", ServiceStatsReport: %v"+
+		", ReportFreeMemory: %v"+
+		", WasmRuntimePath: %v"+
+		", WasmStateDir: %v"+
+		", HostProcessDir: %v"+
+		", HostProcessEnabled: %v"+
+		", HostProcessUsers: %v"+
+		", NodePropertyProviders: %v"+
 		", FileSyncService: {%v}"+
 		", InitialPollingBuffer: {%v}"+
 		", BlockchainAccountId: %v"+
 		", BlockchainDirectoryAddress %v",
-		con.ServiceStorage, con.APIListen, con.DBPath, con.DockerEndpoint, con.DockerCredFilePath, con.DefaultCPUSet,
-		con.DefaultServiceRegistrationRAM, con.StaticWebContent, con.PublicKeyPath, con.TrustSystemCACerts, con.CACertsPath, con.ExchangeURL, con.AgbotURL,
-		con.DefaultHTTPClientTimeoutS, con.HTTPIdleConnectionTimeout, con.PolicyPath, con.ExchangeHeartbeat, con.AgreementTimeoutS,
+		con.ServiceStorage, con.APIListen, con.APIAuth, con.APICORSOrigins, con.APIObserverTokenFile, con.APIObserverUsers, con.APIObserverSocketPath, con.DBPath, con.DockerEndpoint, con.ContainerRuntime, con.ServiceNetworkIPv6, con.ServiceNetworkIPv6Prefix,
+		con.DockerCredFilePath, con.ImageDigestPolicy, con.DefaultCPUSet,
+		con.ServiceLogMaxSize, con.ServiceLogMaxFile, con.VolumeRetentionS, con.PreemptionGraceS,
+		con.DefaultServiceRegistrationRAM, con.StaticWebContent, con.PublicKeyPath, con.TrustSystemCACerts, con.CACertsPath, con.ExchangeURL, con.ExchangeURLs, con.ExchangeCircuitBreakerFailures, con.ExchangeCircuitBreakerOpenS, con.ExchangeAuth, con.ExchangeOIDCTokenURL, con.ExchangeOIDCClientID, con.ExchangeAuthKeyFile, con.AgbotURL, con.AgbotGRPCAddress, con.AgbotGRPCInsecure,
+		con.DefaultHTTPClientTimeoutS, con.HTTPIdleConnectionTimeout, con.HTTPCompressRequests, con.HTTP2, con.PolicyPath, con.ExchangeHeartbeat, con.AgreementTimeoutS,
 		con.DVPrefix, con.RegistrationDelayS, con.ExchangeMessageTTL, con.ExchangeMessageDynamicPoll, con.ExchangeMessagePollInterval,
 		con.ExchangeMessagePollMaxInterval, con.ExchangeMessagePollIncrement, con.UserPublicKeyPath, con.ReportDeviceStatus,
-		con.TrustCertUpdatesFromOrg, con.TrustDockerAuthFromOrg, con.ServiceUpgradeCheckIntervalS, con.MultipleAnaxInstances,
-		con.DefaultServiceRetryCount, con.DefaultServiceRetryDuration, con.NodeCheckIntervalS, con.FileSyncService.String(),
+		con.TrustCertUpdatesFromOrg, con.TrustDockerAuthFromOrg, con.CrossOrgServiceOrgs, con.ImageAuthRefreshIntervalS, con.ServiceUpgradeCheckIntervalS, con.MultipleAnaxInstances,
+		con.DefaultServiceRetryCount, con.DefaultServiceRetryDuration, con.ServiceRetryBackoffS, con.ServiceRetryBackoffMaxS, con.NodeCheckIntervalS, con.GeoLocationFile, con.ServiceMTLS, con.ServiceMTLSPath, con.ServiceDeviceRebind, con.ServiceDNS, con.ServiceCheckpointPath, con.ServiceStatsIntervalS, con.ServiceStatsReport, con.ReportFreeMemory,
+		con.WasmRuntimePath, con.WasmStateDir, con.HostProcessDir, con.HostProcessEnabled, con.HostProcessUsers, con.NodePropertyProviders,
+		con.FileSyncService.String(),
 		con.InitialPollingBuffer, con.BlockchainAccountId, con.BlockchainDirectoryAddress)
 }
 
@@ -575,7 +1153,10 @@ func (agc *AGConfig) String() string {
 		", SecureAPIListenPort: %v"+
 		", SecureAPIServerCert: %v"+
 		", SecureAPIServerkey: %v"+
+		", AgreementGRPCListen: %v"+
+		", AgreementGRPCInsecure: %v"+
 		", PurgeArchivedAgreementHours: %v"+
+		", AgreementHistoryMonths: %v"+
 		", CheckUpdatedPolicyS: %v"+
 		", CSSURL: %v"+
 		", CSSSSLCert: %v"+
@@ -588,16 +1169,23 @@ func (agc *AGConfig) String() string {
 		", MaxExchangeChanges: %v"+
 		", RetryLookBackWindow: %v"+
 		", PolicySearchOrder: %v"+
-		", Vault: {%v}",
+		", ShardNodes: %v"+
+		", IncrementalSearchNodes: %v"+
+		", OrgAgreementRateLimit: %v"+
+		", PolicyAgreementRateLimit: %v"+
+		", NodePolicyKeyPath: %v"+
+		", SignedNodeProperties: %v"+
+		", Vault: {%v}"+
+		", FederatedExchanges: %v",
 		agc.TxLostDelayTolerationSeconds, agc.AgreementWorkers, agc.DBPath, agc.Postgresql.String(),
 		agc.PartitionStale, agc.ProtocolTimeoutS, agc.AgreementTimeoutS, agc.NoDataIntervalS, agc.ActiveAgreementsURL,
 		agc.ActiveAgreementsUser, mask, agc.PolicyPath, agc.NewContractIntervalS, agc.ProcessGovernanceIntervalS,
 		agc.IgnoreContractWithAttribs, agc.ExchangeURL, agc.ExchangeHeartbeat, agc.ExchangeId,
 		mask, agc.DVPrefix, agc.ActiveDeviceTimeoutS, agc.ExchangeMessageTTL, agc.MessageKeyPath, mask, agc.APIListen,
-		agc.SecureAPIListenHost, agc.SecureAPIListenPort, agc.SecureAPIServerCert, agc.SecureAPIServerKey,
-		agc.PurgeArchivedAgreementHours, agc.CheckUpdatedPolicyS, agc.CSSURL, agc.CSSSSLCert, agc.CSSDestinationBatchSize, agc.AgreementBatchSize,
+		agc.SecureAPIListenHost, agc.SecureAPIListenPort, agc.SecureAPIServerCert, agc.SecureAPIServerKey, agc.AgreementGRPCListen, agc.AgreementGRPCInsecure,
+		agc.PurgeArchivedAgreementHours, agc.AgreementHistoryMonths, agc.CheckUpdatedPolicyS, agc.CSSURL, agc.CSSSSLCert, agc.CSSDestinationBatchSize, agc.AgreementBatchSize,
 		agc.AgreementQueueSize, agc.MessageQueueScale, agc.QueueHistorySize, agc.FullRescanS, agc.MaxExchangeChanges,
-		agc.RetryLookBackWindow, agc.PolicySearchOrder, agc.Vault)
+		agc.RetryLookBackWindow, agc.PolicySearchOrder, agc.ShardNodes, agc.IncrementalSearchNodes, agc.OrgAgreementRateLimit, agc.PolicyAgreementRateLimit, agc.NodePolicyKeyPath, agc.SignedNodeProperties, agc.Vault, agc.FederatedExchanges)
 }
 
 func (c *VaultConfig) String() string {
@@ -72,3 +72,101 @@ func Test_enrichFromEnvvars_success(t *testing.T) {
 	}
 
 }
+
+func Test_ValidateIPv6Prefix(t *testing.T) {
+
+	for _, prefix := range []string{ServiceNetworkIPv6Prefix_DEFAULT, "fd12:3456:789a::/48", "fc00::/7", "fd00:1::/60"} {
+		if err := ValidateIPv6Prefix(prefix); err != nil {
+			t.Errorf("should not return error for %v, but got %v", prefix, err)
+		}
+	}
+
+	for _, prefix := range []string{"", "fd00::", "10.0.0.0/8", "2001:db8::/48", "fd00:1::/64"} {
+		if err := ValidateIPv6Prefix(prefix); err == nil {
+			t.Errorf("should have returned an error for %v", prefix)
+		}
+	}
+
+	cfg := HorizonConfig{Edge: Config{ServiceNetworkIPv6Prefix: "fd12:3456:789a::/48"}}
+	if p := cfg.GetServiceNetworkIPv6Prefix(); p != "" {
+		t.Errorf("the prefix should be empty when IPv6 is disabled, got %v", p)
+	}
+	cfg.Edge.ServiceNetworkIPv6 = true
+	if p := cfg.GetServiceNetworkIPv6Prefix(); p != "fd12:3456:789a::/48" {
+		t.Errorf("wrong prefix %v", p)
+	}
+}
+
+func Test_ValidateCORSOrigin(t *testing.T) {
+
+	for _, origin := range []string{"*", "https://gateway.local:8443", "http://192.168.1.10", "http://localhost:3000/"} {
+		if err := ValidateCORSOrigin(origin); err != nil {
+			t.Errorf("should not return error for %v, but got %v", origin, err)
+		}
+	}
+
+	for _, origin := range []string{"", "gateway.local", "ftp://gateway.local", "https://gateway.local/dashboard", "https://user@gateway.local", "https://"} {
+		if err := ValidateCORSOrigin(origin); err == nil {
+			t.Errorf("should have returned an error for %v", origin)
+		}
+	}
+
+	cfg := Config{}
+	if o := cfg.GetAPICORSOrigins(); len(o) != 1 || o[0] != "*" {
+		t.Errorf("any origin should be allowed by default, got %v", o)
+	}
+}
+
+func Test_ValidateFederatedExchanges(t *testing.T) {
+
+	eu := FederatedExchange{Name: "eu", ExchangeURL: "https://eu/v1/", ExchangeId: "hub/agbot", ExchangeToken: "t", Orgs: []string{"orgA", "orgB"}}
+	us := FederatedExchange{Name: "us", ExchangeURL: "https://us/v1/", ExchangeId: "hub/agbot", ExchangeToken: "t", Orgs: []string{"orgC"}}
+
+	if err := ValidateFederatedExchanges(nil, "hub/agbot"); err != nil {
+		t.Errorf("no federated exchanges should be valid, got %v", err)
+	} else if err := ValidateFederatedExchanges([]FederatedExchange{eu, us}, "hub/agbot"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	}
+
+	noId := us
+	noId.ExchangeId = "agbot"
+	noOrgs := us
+	noOrgs.Orgs = nil
+	sameOrg := us
+	sameOrg.Orgs = []string{"orgB"}
+	agbotOrg := us
+	agbotOrg.Orgs = []string{"hub"}
+
+	for _, bad := range [][]FederatedExchange{{eu, eu}, {eu, noId}, {eu, noOrgs}, {eu, sameOrg}, {agbotOrg}, {{Name: "x"}}} {
+		if err := ValidateFederatedExchanges(bad, "hub/agbot"); err == nil {
+			t.Errorf("should have returned an error for %v", bad)
+		}
+	}
+}
+
+func Test_ValidateNodePropertyProviders(t *testing.T) {
+
+	plc := NodePropertyProvider{Name: "plc", Command: "/usr/bin/plc-props", Properties: map[string]string{"plc.model": "string"}}
+	tpm := NodePropertyProvider{Name: "tpm", Command: "/usr/bin/tpm-props", Properties: map[string]string{"tpm.attested": "boolean"}}
+
+	if err := ValidateNodePropertyProviders(nil); err != nil {
+		t.Errorf("no node property providers should be valid, got %v", err)
+	} else if err := ValidateNodePropertyProviders([]NodePropertyProvider{plc, tpm}); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	}
+
+	noCommand := tpm
+	noCommand.Command = ""
+	noProps := tpm
+	noProps.Properties = nil
+	builtIn := tpm
+	builtIn.Properties = map[string]string{"openhorizon.cpu": "int"}
+	sameProp := tpm
+	sameProp.Properties = map[string]string{"plc.model": "string"}
+
+	for _, bad := range [][]NodePropertyProvider{{plc, plc}, {noCommand}, {noProps}, {builtIn}, {plc, sameProp}, {{Command: "x"}}} {
+		if err := ValidateNodePropertyProviders(bad); err == nil {
+			t.Errorf("should have returned an error for %v", bad)
+		}
+	}
+}
@@ -81,6 +81,20 @@ const HZN_FSS_MAX_CHUNK_SIZE = 5242880
 // The name of the folder where secrets from the agreement protocol will be stored within a workload container
 const HZN_SECRETS_MOUNT = "/open-horizon-secrets"
 
+// The name of the folder where the service CA and the service certificates are stored in the host filesystem.
+const HZN_MTLS_PATH = "service-mtls"
+
+// The name of the folder where the checkpoints of the service containers are stored in the host filesystem.
+const HZN_CHECKPOINT_PATH = "service-checkpoints"
+
+// The name of the folder where the service certificate, its key and the service CA certificate are mounted within a workload container
+const HZN_MTLS_MOUNT = "/open-horizon-mtls"
+
+// The file names of the service CA certificate, the service certificate and its key.
+const HZN_MTLS_CA_FILE = "ca.pem"
+const HZN_MTLS_CERT_FILE = "cert.pem"
+const HZN_MTLS_KEY_FILE = "key.pem"
+
 // The Default starting exchange message polling interval.
 const ExchangeMessagePollInterval_DEFAULT = 20
 
@@ -117,6 +131,9 @@ const AgbotFullRescan_DEFAULT = 600
 // The maximum number of changes to retrieve at once from the exchange
 const AgbotMaxChanges_DEFAULT = 1000
 
+// The default max number of changed nodes that the agbot matches to its policies without searching the exchange
+const AgbotIncrementalSearchNodes_DEFAULT = 100
+
 // Retry lookback window
 const AgbotRetryLookBackWindow_DEFAULT = 3600
 
@@ -132,6 +149,74 @@ const AgbotProtocolTimeoutScaleFactor_DEFAULT = 2
 // Time to allow a kube agent to attempt to install a custom resource before timing out
 const K8sCRInstallTimeoutS_DEFAULT = 180
 
+// Time to allow a kube agent to wait for a custom resource definition to be established before timing out
+const K8sCRDEstablishedTimeoutS_DEFAULT = 60
+
+// Time to allow a kube agent to wait for a namespace to become active before timing out
+const K8sNamespaceActiveTimeoutS_DEFAULT = 30
+
+// The number of namespaces in which a kube agent installs, uninstalls and maintains operators at the same time
+const K8sMaxConcurrentInstalls_DEFAULT = 4
+
+// The rotation of the json-file and local logs of the service containers, when the service does not set it
+const ServiceLogMaxSize_DEFAULT = "10m"
+const ServiceLogMaxFile_DEFAULT = 3
+
+// The delay before the first restart of a failed service and the maximum delay between its restarts, in seconds
+const ServiceRetryBackoffS_DEFAULT = 10
+const ServiceRetryBackoffMaxS_DEFAULT = 300
+
+// The default number of seconds a named volume retained across service upgrades is kept once the service is gone
+const VolumeRetentionS_DEFAULT = 604800
+
+// The default number of seconds a lower priority agreement keeps running once it is chosen to be preempted
+const PreemptionGraceS_DEFAULT = 30
+
+// The default number of seconds between the collections of the resource usage of the service containers
+const ServiceStatsIntervalS_DEFAULT = 60
+
+// The default number of seconds between the refreshes of the docker auths of the running services from the exchange,
+// shorter than the lifetime of the usual registry tokens
+const ImageAuthRefreshIntervalS_DEFAULT = 1800
+
+// The image digest policies. Pin resolves the image tags to digests when the images are pulled, require refuses the
+// images that are not referenced by digest.
+const ImageDigestPolicy_PIN = "pin"
+const ImageDigestPolicy_REQUIRE = "require"
+
+// The ways the clients of the agent API are authenticated, see the APIAuth field of the agent config
+const APIAuth_PEERCRED = "peercred"
+const APIAuth_TOKEN = "token"
+const APIAuth_MTLS = "mtls"
+
+// The agent authenticates to the exchange with OIDC access tokens, see the ExchangeAuth field of the agent config
+const ExchangeAuth_OIDC = "oidc"
+
+// The file holding the key that encrypts the exchange refresh token in the agent db, outside of the DBPath
+const ExchangeAuthKeyFile_DEFAULT = "/etc/horizon/exchange_auth.key"
+
+// The unix socket of the agent API and the file holding its bearer token. The hzn command looks for them in the same
+// places.
+const APISocketPath_DEFAULT = "/var/run/horizon/anax.sock"
+const APITokenFile_DEFAULT = "/var/horizon/api.token"
+
+// The container runtime that makes the agent drive containerd directly instead of a docker API endpoint
+const ContainerRuntime_CONTAINERD = "containerd"
+
+// The containerd socket and namespace used when the agent drives containerd directly
+const ContainerdAddress_DEFAULT = "/run/containerd/containerd.sock"
+const ContainerdNamespace_DEFAULT = "horizon"
+
+// The wasmtime executable that runs the services deployed as WebAssembly modules, looked up in the PATH
+const WasmRuntimePath_DEFAULT = "wasmtime"
+
+// The CNI configuration and plugin directories used to network the containerd containers
+const CNIConfDir_DEFAULT = "/etc/cni/net.d"
+const CNIPluginDir_DEFAULT = "/opt/cni/bin"
+
+// The IPv6 unique local address prefix from which the subnets of the service networks are assigned
+const ServiceNetworkIPv6Prefix_DEFAULT = "fd00:4f48::/48"
+
 // Time between secret update checks
 const SecretsUpdateCheck_DEFAULT = 60
 
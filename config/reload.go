@@ -0,0 +1,105 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// The fields of the agent configuration that are applied without restarting the agent, because the workers read them
+// each time they are used or are told about the change. A change to any other field requires a restart.
+var reloadableEdgeFields = map[string]bool{
+	"LogVerbosity":                     true,
+	"ExchangeHeartbeat":                true,
+	"ExchangeMessageDynamicPoll":       true,
+	"ExchangeMessagePollInterval":      true,
+	"ExchangeMessagePollMaxInterval":   true,
+	"ExchangeMessagePollIncrement":     true,
+	"InitialPollingBuffer":             true,
+	"SurfaceErrorTimeoutS":             true,
+	"SurfaceErrorAgreementPersistentS": true,
+	"DefaultServiceRetryCount":         true,
+	"DefaultServiceRetryDuration":      true,
+	"ServiceRetryBackoffS":             true,
+	"ServiceRetryBackoffMaxS":          true,
+	"ReportDeviceStatus":               true,
+	"ReportFreeMemory":                 true,
+	"TrustCertUpdatesFromOrg":          true,
+	"TrustDockerAuthFromOrg":           true,
+	"MaxAgreementPrelaunchTimeM":       true,
+	"VolumeRetentionS":                 true,
+	"PreemptionGraceS":                 true,
+	"ServiceLogMaxFile":                true,
+}
+
+// The fields that changed in the config file when it was reloaded, by their path in the config, e.g. Edge.ExchangeHeartbeat.
+type ReloadResult struct {
+	Applied         []string `json:"applied"`         // the changes that are in effect
+	RestartRequired []string `json:"restartRequired"` // the changes that are ignored until the agent is restarted
+}
+
+func (r ReloadResult) String() string {
+	return fmt.Sprintf("Applied: %v, RestartRequired: %v", r.Applied, r.RestartRequired)
+}
+
+// Returns true if the given field, e.g. Edge.ExchangeHeartbeat, was applied.
+func (r *ReloadResult) IsApplied(field string) bool {
+	for _, f := range r.Applied {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+var reloadLock sync.Mutex
+
+// Apply the changes from the new config, read from the config file, that can be applied without restarting the agent.
+// The other changes are only reported, the running config keeps the values the agent was started with.
+func (c *HorizonConfig) Reload(newConfig *HorizonConfig) *ReloadResult {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	result := &ReloadResult{Applied: []string{}, RestartRequired: []string{}}
+
+	current := reflect.ValueOf(&c.Edge).Elem()
+	updated := reflect.ValueOf(&newConfig.Edge).Elem()
+	for i := 0; i < current.NumField(); i++ {
+		name := current.Type().Field(i).Name
+		if reflect.DeepEqual(current.Field(i).Interface(), updated.Field(i).Interface()) {
+			continue
+		} else if reloadableEdgeFields[name] {
+			current.Field(i).Set(updated.Field(i))
+			result.Applied = append(result.Applied, "Edge."+name)
+		} else {
+			result.RestartRequired = append(result.RestartRequired, "Edge."+name)
+		}
+	}
+
+	current = reflect.ValueOf(&c.AgreementBot).Elem()
+	updated = reflect.ValueOf(&newConfig.AgreementBot).Elem()
+	for i := 0; i < current.NumField(); i++ {
+		if !reflect.DeepEqual(current.Field(i).Interface(), updated.Field(i).Interface()) {
+			result.RestartRequired = append(result.RestartRequired, "AgreementBot."+current.Type().Field(i).Name)
+		}
+	}
+
+	if !reflect.DeepEqual(c.ArchSynonyms, newConfig.ArchSynonyms) {
+		result.RestartRequired = append(result.RestartRequired, "ArchSynonyms")
+	}
+
+	sort.Strings(result.Applied)
+	sort.Strings(result.RestartRequired)
+	return result
+}
+
+// Set the glog verbosity level from the config, if it is set.
+func (c *Config) SetLogVerbosity() error {
+	if c.LogVerbosity == nil {
+		return nil
+	}
+	return flag.Set("v", strconv.Itoa(*c.LogVerbosity))
+}
@@ -0,0 +1,57 @@
+//go:build unit
+// +build unit
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Only the reloadable fields are applied, the other changes are reported as requiring a restart.
+func Test_Reload(t *testing.T) {
+
+	current := &HorizonConfig{
+		Edge: Config{
+			ExchangeURL:       "https://exchange/v1/",
+			ExchangeHeartbeat: 60,
+			ServiceLogMaxFile: 2,
+		},
+		AgreementBot: AGConfig{
+			ExchangeURL: "https://exchange/v1/",
+		},
+		ArchSynonyms: NewArchSynonyms(),
+	}
+
+	verbosity := 5
+	newConfig := &HorizonConfig{
+		Edge: Config{
+			ExchangeURL:       "https://other-exchange/v1/",
+			ExchangeHeartbeat: 30,
+			ServiceLogMaxFile: 2,
+			LogVerbosity:      &verbosity,
+		},
+		AgreementBot: AGConfig{
+			ExchangeURL: "https://other-exchange/v1/",
+		},
+		ArchSynonyms: NewArchSynonyms(),
+	}
+
+	result := current.Reload(newConfig)
+	if !reflect.DeepEqual(result.Applied, []string{"Edge.ExchangeHeartbeat", "Edge.LogVerbosity"}) {
+		t.Errorf("wrong applied fields %v", result.Applied)
+	} else if !reflect.DeepEqual(result.RestartRequired, []string{"AgreementBot.ExchangeURL", "Edge.ExchangeURL"}) {
+		t.Errorf("wrong restart required fields %v", result.RestartRequired)
+	} else if current.Edge.ExchangeHeartbeat != 30 || current.Edge.LogVerbosity == nil || *current.Edge.LogVerbosity != 5 {
+		t.Errorf("the reloadable fields are not applied %v", current.Edge)
+	} else if current.Edge.ExchangeURL != "https://exchange/v1/" {
+		t.Errorf("the exchange url should not be changed, but is %v", current.Edge.ExchangeURL)
+	} else if !result.IsApplied("Edge.ExchangeHeartbeat") || result.IsApplied("Edge.ExchangeURL") {
+		t.Errorf("wrong IsApplied result for %v", result)
+	}
+
+	// nothing changes when the same config is reloaded again
+	if result := current.Reload(newConfig); len(result.Applied) != 0 || len(result.RestartRequired) != 2 {
+		t.Errorf("wrong result of the second reload %v", result)
+	}
+}
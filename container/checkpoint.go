@@ -0,0 +1,219 @@
+package container
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/eventlog"
+	"github.com/open-horizon/anax/persistence"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// The label of the service containers that are checkpointed with CRIU when a service upgrade stops them, and restored
+// from the checkpoint instead of cold started when the new agreement starts them again.
+const CHECKPOINT_LABEL = LABEL_PREFIX + ".checkpoint"
+
+// The checkpoints that are not restored within this time are removed.
+const CHECKPOINT_MAX_AGE = time.Hour
+
+// Returns the directory of the checkpoint of a service container. The checkpoint is found by the container that
+// replaces it from the deployment hash and the name of its service, so that it is only restored into a container of the
+// same service deployed the same way.
+func checkpointDir(root string, labels map[string]string) string {
+	name := labels[LABEL_PREFIX+".deployment_description_hash"] + "-" + labels[LABEL_PREFIX+".service_name"]
+	if variation := labels[LABEL_PREFIX+".variation"]; variation != "" {
+		name += "-" + variation
+	}
+	return path.Join(root, name)
+}
+
+// Returns the name of the checkpoint of a container, the id of its image, so that a checkpoint is not restored into a
+// container of another image.
+func checkpointName(imageId string) string {
+	return strings.TrimPrefix(imageId, "sha256:")
+}
+
+// Sends a POST request to the docker API for the endpoints the docker client does not implement.
+func dockerPost(client *docker.Client, apiPath string, query url.Values, body interface{}) error {
+	endpoint, err := url.Parse(client.Endpoint())
+	if err != nil {
+		return err
+	}
+
+	u := url.URL{Scheme: "http", Host: endpoint.Host, Path: apiPath, RawQuery: query.Encode()}
+	if endpoint.Scheme == "unix" {
+		// The host is not used to reach the unix socket.
+		u.Host = "unix.sock"
+	} else if endpoint.Scheme == "https" {
+		u.Scheme = "https"
+	}
+
+	var reader io.Reader
+	if body != nil {
+		if b, err := json.Marshal(body); err != nil {
+			return err
+		} else {
+			reader = bytes.NewReader(b)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		msg := struct {
+			Message string `json:"message"`
+		}{}
+		if b, err := ioutil.ReadAll(resp.Body); err == nil && json.Unmarshal(b, &msg) != nil {
+			msg.Message = string(b)
+		}
+		return fmt.Errorf("%v returned %v: %v", apiPath, resp.StatusCode, msg.Message)
+	}
+	return nil
+}
+
+// Checkpoints the running containers of the agreements that are to be restored when they are started again, and stops
+// them. Only docker can checkpoint containers, and only when its experimental features are enabled and CRIU is
+// installed. The containers that cannot be checkpointed are cold started.
+func (b *ContainerWorker) checkpointContainers(agreements []string) {
+	if b.GetRuntime().Name() != API_SERVER_TYPE_DOCKER {
+		glog.V(3).Infof("ContainerWorker cannot checkpoint the containers of %v with %v", agreements, b.GetRuntime().Name())
+		return
+	}
+
+	root := b.Config.GetServiceCheckpointPath()
+	b.pruneCheckpoints()
+
+	// The shared containers are not stopped with the agreement.
+	b.ContainersMatchingAgreement(agreements, false, func(container *docker.APIContainers, agreementId string) error {
+		if container.Labels[CHECKPOINT_LABEL] != "true" || container.State != "running" {
+			return nil
+		}
+
+		dir := checkpointDir(root, container.Labels)
+		var name string
+		err := os.RemoveAll(dir)
+		if err == nil {
+			err = os.MkdirAll(dir, 0700)
+		}
+		if err == nil {
+			var c *docker.Container
+			if c, err = b.client.InspectContainer(container.ID); err == nil {
+				name = checkpointName(c.Image)
+			}
+		}
+		if err == nil {
+			err = dockerPost(b.client, "/containers/"+container.ID+"/checkpoints", nil, map[string]interface{}{
+				"CheckpointID":  name,
+				"CheckpointDir": dir,
+				"Exit":          true,
+			})
+		}
+
+		if err != nil {
+			os.RemoveAll(dir)
+			glog.Errorf("ContainerWorker unable to checkpoint container %v, error: %v", container.Names, err)
+			eventlog.LogServiceEvent2(b.db, persistence.SEVERITY_WARN,
+				persistence.NewMessageMeta(EL_CONT_CHECKPOINT_ERROR, container.Labels[LABEL_PREFIX+".service_name"], agreementId, err.Error()),
+				persistence.EC_ERROR_CHECKPOINT_CONTAINER,
+				"", "", "", "", "", []string{agreementId})
+		} else {
+			glog.V(3).Infof("ContainerWorker checkpointed container %v to %v", container.Names, path.Join(dir, name))
+			eventlog.LogServiceEvent2(b.db, persistence.SEVERITY_INFO,
+				persistence.NewMessageMeta(EL_CONT_CHECKPOINTED, container.Labels[LABEL_PREFIX+".service_name"], agreementId),
+				persistence.EC_CONTAINER_CHECKPOINTED,
+				"", "", "", "", "", []string{agreementId})
+		}
+		return nil
+	})
+}
+
+// Starts a new service container, from the checkpoint of the container it replaces if there is one. The checkpoint is
+// removed once it is used, and the container is cold started if it cannot be restored.
+func (b *ContainerWorker) startContainer(agreementId string, serviceURL string, version string, container *docker.Container) error {
+	if dir, name, serviceName := b.findCheckpoint(container.ID); dir != "" {
+		err := dockerPost(b.client, "/containers/"+container.ID+"/start", url.Values{"checkpoint": {name}, "checkpoint-dir": {dir}}, nil)
+		os.RemoveAll(dir)
+
+		if err == nil {
+			glog.V(3).Infof("ContainerWorker restored container %v from %v", container.Name, path.Join(dir, name))
+			eventlog.LogServiceEvent2(b.db, persistence.SEVERITY_INFO,
+				persistence.NewMessageMeta(EL_CONT_RESTORED, serviceName, agreementId),
+				persistence.EC_CONTAINER_RESTORED,
+				"", serviceURL, "", version, "", []string{agreementId})
+			return nil
+		}
+
+		glog.Errorf("ContainerWorker unable to restore container %v from %v, cold starting it. Error: %v", container.Name, path.Join(dir, name), err)
+		eventlog.LogServiceEvent2(b.db, persistence.SEVERITY_WARN,
+			persistence.NewMessageMeta(EL_CONT_RESTORE_ERROR, serviceName, agreementId, err.Error()),
+			persistence.EC_ERROR_RESTORE_CONTAINER,
+			"", serviceURL, "", version, "", []string{agreementId})
+	}
+
+	return b.client.StartContainer(container.ID, nil)
+}
+
+// Returns the directory and the name of the checkpoint a new container is restored from, and the name of its service.
+// The directory is empty when there is no checkpoint for the container. A checkpoint of another image is removed,
+// the container of the new image replaces it.
+func (b *ContainerWorker) findCheckpoint(id string) (string, string, string) {
+	root := b.Config.GetServiceCheckpointPath()
+	if entries, err := ioutil.ReadDir(root); err != nil || len(entries) == 0 {
+		return "", "", ""
+	}
+
+	container, err := b.client.InspectContainer(id)
+	if err != nil {
+		glog.Errorf("ContainerWorker unable to inspect container %v, error: %v", id, err)
+		return "", "", ""
+	} else if container.Config == nil || container.Config.Labels[CHECKPOINT_LABEL] != "true" {
+		return "", "", ""
+	}
+
+	dir := checkpointDir(root, container.Config.Labels)
+	name := checkpointName(container.Image)
+	if _, err := os.Stat(path.Join(dir, name)); err != nil {
+		os.RemoveAll(dir)
+		return "", "", ""
+	}
+	return dir, name, container.Config.Labels[LABEL_PREFIX+".service_name"]
+}
+
+// Removes the checkpoints that were not restored in time, e.g. because the upgraded service no longer has the
+// container, or the new agreement was not made.
+func (b *ContainerWorker) pruneCheckpoints() {
+	root := b.Config.GetServiceCheckpointPath()
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if time.Since(entry.ModTime()) > CHECKPOINT_MAX_AGE {
+			glog.V(3).Infof("ContainerWorker removing expired checkpoint %v", entry.Name())
+			if err := os.RemoveAll(path.Join(root, entry.Name())); err != nil {
+				glog.Errorf("ContainerWorker unable to remove checkpoint %v, error: %v", entry.Name(), err)
+			}
+		}
+	}
+}
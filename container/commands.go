@@ -85,12 +85,40 @@ func (b *ContainerWorker) NewContainerMaintenanceCommand(protocol string, agreem
 	}
 }
 
+// ==============================================================================================================
+type WorkloadPauseCommand struct {
+	Key        string                       // the agreement id or the service instance key of the containers
+	Deployment persistence.DeploymentConfig // the deployment of the agreement, nil for a service instance
+	Pause      bool
+}
+
+func (c WorkloadPauseCommand) String() string {
+	deployment_string := ""
+	if c.Deployment != nil {
+		deployment_string = c.Deployment.ToString()
+	}
+	return fmt.Sprintf("Key: %v, Deployment: %v, Pause: %v", c.Key, deployment_string, c.Pause)
+}
+
+func (c WorkloadPauseCommand) ShortString() string {
+	return c.String()
+}
+
+func (b *ContainerWorker) NewWorkloadPauseCommand(key string, deployment persistence.DeploymentConfig, pause bool) *WorkloadPauseCommand {
+	return &WorkloadPauseCommand{
+		Key:        key,
+		Deployment: deployment,
+		Pause:      pause,
+	}
+}
+
 // ==============================================================================================================
 type WorkloadShutdownCommand struct {
 	AgreementProtocol  string
 	CurrentAgreementId string
 	Deployment         persistence.DeploymentConfig
 	Agreements         []string
+	Checkpoint         bool // checkpoint the containers that can be restored when they are started again
 }
 
 func (c WorkloadShutdownCommand) String() string {
@@ -98,7 +126,7 @@ func (c WorkloadShutdownCommand) String() string {
 	if c.Deployment != nil {
 		depStr = c.Deployment.ToString()
 	}
-	return fmt.Sprintf("AgreementProtocol: %v, CurrentAgreementId: %v, Deployment: %v, Agreements (sample): %v", c.AgreementProtocol, c.CurrentAgreementId, depStr, cutil.FirstN(10, c.Agreements))
+	return fmt.Sprintf("AgreementProtocol: %v, CurrentAgreementId: %v, Deployment: %v, Agreements (sample): %v, Checkpoint: %v", c.AgreementProtocol, c.CurrentAgreementId, depStr, cutil.FirstN(10, c.Agreements), c.Checkpoint)
 }
 
 func (c WorkloadShutdownCommand) ShortString() string {
@@ -197,3 +225,39 @@ func NewNodeUnconfigCommand(msg *events.NodeShutdownCompleteMessage) *NodeUnconf
 		msg: msg,
 	}
 }
+
+// ==============================================================================================================
+// This worker command is used to tell the worker that the node is being unregistered and that the named volumes of
+// the services are to be exported before the service containers are removed.
+type VolumeExportCommand struct {
+	ExportPath string
+}
+
+func (c VolumeExportCommand) ShortString() string {
+	return fmt.Sprintf("VolumeExportCommand: ExportPath %v", c.ExportPath)
+}
+
+func NewVolumeExportCommand(exportPath string) *VolumeExportCommand {
+	return &VolumeExportCommand{
+		ExportPath: exportPath,
+	}
+}
+
+// ==============================================================================================================
+// This worker command is used to tell the worker that a device node was added on the host, so that the service
+// containers bound to the same device under another device node can be bound to the new one.
+type DeviceChangedCommand struct {
+	DevName string // the name of the device node, relative to /dev
+	DevPath string // the sysfs path of the device
+}
+
+func (c DeviceChangedCommand) ShortString() string {
+	return fmt.Sprintf("DeviceChangedCommand: DevName %v, DevPath %v", c.DevName, c.DevPath)
+}
+
+func (b *ContainerWorker) NewDeviceChangedCommand(devName string, devPath string) *DeviceChangedCommand {
+	return &DeviceChangedCommand{
+		DevName: devName,
+		DevPath: devPath,
+	}
+}
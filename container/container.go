@@ -4,17 +4,21 @@ import (
 	"bytes"
 	"crypto/sha1"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"io/ioutil"
 	"math/big"
+	"net"
 	"os"
 	"os/user"
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/coreos/go-iptables/iptables"
@@ -38,29 +42,37 @@ const LABEL_PREFIX = "openhorizon.anax"
 const IPT_COLONUS_ISOLATED_CHAIN = "OPENHORIZON-ANAX-ISOLATION"
 
 const (
-	API_SERVER_TYPE_DOCKER = "docker"
-	API_SERVER_TYPE_PODMAN = "podman"
-	LOG_DRIVER_SYSLOG      = "syslog"
-	LOG_DRIVER_JOURNALD    = "journald"
+	API_SERVER_TYPE_DOCKER     = "docker"
+	API_SERVER_TYPE_PODMAN     = "podman"
+	API_SERVER_TYPE_CONTAINERD = config.ContainerRuntime_CONTAINERD
+	LOG_DRIVER_SYSLOG          = "syslog"
+	LOG_DRIVER_JOURNALD        = "journald"
 )
 
 // messages for event logs
 const (
-	EL_CONT_DEPLOYCONF_UNSUPPORT_CAP_FOR_WL   = "Deployment config %v contains unsupported capability for a workload"
-	EL_CONT_DEPLOYCONF_UNSUPPORT_CAP_FOR_CONT = "Deployment config %v contains unsupported capability for infrastructure container."
-	EL_CONT_DEPLOYCONF_UNSUPPORT_BIND         = "Deployment config %v contains unsupported bind for a workload, %v"
-	EL_CONT_DEPLOYCONF_UNSUPPORT_BIND_FOR     = "Deployment config %v contains unsupported bind for %v, %v"
-	EL_CONT_ERROR_UNMARSHAL_DEPLOY            = "Error Unmarshalling deployment string %v, error: %v"
-	EL_CONT_ERROR_UNMARSHAL_DEPLOY_OVERRIDE   = "Error Unmarshalling deployment override string %v for agreement %v, error: %v"
-	EL_CONT_START_CONTAINER_ERROR             = "Error starting containers: %v"
-	EL_CONT_START_CONTAINER_ERROR_FOR_AG      = "Error starting containers for agreement %v: %v"
-	EL_CONT_RESTART_CONTAINER_ERROR_FOR_AG    = "Error restarting containers for agreements %v: %v"
-	EL_CONT_CLEAN_OLD_CONTAINER_ERROR         = "Error cleaning up old containers before starting up new containers for %v. Error: %v"
-	EL_CONT_FAIL_GET_PAENT_CONT_FOR_SVC       = "Failed to get a list of parent containers for service retry for %v. %v"
-	EL_CONT_FAIL_RESTORE_NW_WITH_PARENT       = "Failed to restoring the network connection with the parents for service %v. %v"
-	EL_CONT_TERM_UNABLE_ACCESS_STORAGE_DIR    = "anax terminating. Unable to access service storage direcotry specified in config: %v. %v"
-	EL_CONT_TERM_UNABLE_INIT_IPTABLE_CLIENT   = "anax terminating. Failed to instantiate iptables client. %v"
-	EL_CONT_TERM_UNABLE_INIT_DOCKER_CLIENT    = "anax terminating. Failed to instantiate docker client. %v"
+	EL_CONT_DEPLOYCONF_UNSUPPORT_CAP_FOR_WL    = "Deployment config %v contains unsupported capability for a workload"
+	EL_CONT_DEPLOYCONF_UNSUPPORT_CAP_FOR_CONT  = "Deployment config %v contains unsupported capability for infrastructure container."
+	EL_CONT_DEPLOYCONF_UNSUPPORT_BIND          = "Deployment config %v contains unsupported bind for a workload, %v"
+	EL_CONT_DEPLOYCONF_UNSUPPORT_BIND_FOR      = "Deployment config %v contains unsupported bind for %v, %v"
+	EL_CONT_ERROR_UNMARSHAL_DEPLOY             = "Error Unmarshalling deployment string %v, error: %v"
+	EL_CONT_ERROR_UNMARSHAL_DEPLOY_OVERRIDE    = "Error Unmarshalling deployment override string %v for agreement %v, error: %v"
+	EL_CONT_START_CONTAINER_ERROR              = "Error starting containers: %v"
+	EL_CONT_START_CONTAINER_ERROR_FOR_AG       = "Error starting containers for agreement %v: %v"
+	EL_CONT_RESTART_CONTAINER_ERROR_FOR_AG     = "Error restarting containers for agreements %v: %v"
+	EL_CONT_CLEAN_OLD_CONTAINER_ERROR          = "Error cleaning up old containers before starting up new containers for %v. Error: %v"
+	EL_CONT_FAIL_GET_PAENT_CONT_FOR_SVC        = "Failed to get a list of parent containers for service retry for %v. %v"
+	EL_CONT_FAIL_RESTORE_NW_WITH_PARENT        = "Failed to restoring the network connection with the parents for service %v. %v"
+	EL_CONT_TERM_UNABLE_ACCESS_STORAGE_DIR     = "anax terminating. Unable to access service storage direcotry specified in config: %v. %v"
+	EL_CONT_TERM_UNABLE_INIT_IPTABLE_CLIENT    = "anax terminating. Failed to instantiate iptables client. %v"
+	EL_CONT_TERM_UNABLE_INIT_DOCKER_CLIENT     = "anax terminating. Failed to instantiate docker client. %v"
+	EL_CONT_TERM_UNABLE_INIT_CONTAINERD_CLIENT = "anax terminating. Failed to instantiate containerd client. %v"
+	EL_CONT_CHECKPOINTED                       = "Checkpointed container %v for agreement %v."
+	EL_CONT_CHECKPOINT_ERROR                   = "Failed to checkpoint container %v for agreement %v, it will be cold started. Error: %v"
+	EL_CONT_RESTORED                           = "Restored container %v for agreement %v from its checkpoint."
+	EL_CONT_RESTORE_ERROR                      = "Failed to restore container %v for agreement %v from its checkpoint, it is cold started. Error: %v"
+	EL_CONT_VOLUME_EXPORTED                    = "Exported volume %v of container %v for agreement %v to %v."
+	EL_CONT_VOLUME_EXPORT_ERROR                = "Failed to export volume %v of container %v for agreement %v. Error: %v"
 )
 
 // This is does nothing useful at run time.
@@ -86,6 +98,13 @@ func MarkI18nMessages() {
 	msgPrinter.Sprintf(EL_CONT_TERM_UNABLE_ACCESS_STORAGE_DIR)
 	msgPrinter.Sprintf(EL_CONT_TERM_UNABLE_INIT_IPTABLE_CLIENT)
 	msgPrinter.Sprintf(EL_CONT_TERM_UNABLE_INIT_DOCKER_CLIENT)
+	msgPrinter.Sprintf(EL_CONT_TERM_UNABLE_INIT_CONTAINERD_CLIENT)
+	msgPrinter.Sprintf(EL_CONT_CHECKPOINTED)
+	msgPrinter.Sprintf(EL_CONT_CHECKPOINT_ERROR)
+	msgPrinter.Sprintf(EL_CONT_RESTORED)
+	msgPrinter.Sprintf(EL_CONT_RESTORE_ERROR)
+	msgPrinter.Sprintf(EL_CONT_VOLUME_EXPORTED)
+	msgPrinter.Sprintf(EL_CONT_VOLUME_EXPORT_ERROR)
 }
 
 /*
@@ -104,10 +123,26 @@ func MarkI18nMessages() {
  *       "devices": [
  *         "/dev/bus/usb/001/001:/dev/bus/usb/001/001"
  *       ],
+ *       "gpus": {
+ *         "count": 1,
+ *         "capabilities": ["compute", "utility"]
+ *       },
+ *       "restart_policy": {
+ *         "name": "on-failure",
+ *         "max_retries": 5,
+ *         "backoff_max_s": 300
+ *       },
  *       "binds": [
  *         "/tmp/testdata:/tmp/mydata:ro",
  *         "myvolume1:/tmp/mydata2"
  *       ],
+ *       "volumes": [
+ *         {
+ *           "name": "models",
+ *           "target": "/var/lib/models",
+ *           "retain": "upgrade"
+ *         }
+ *       ],
  *       "ports": [
  *         {
  *           "HostPort":"5200:6414/tcp",
@@ -117,6 +152,12 @@ func MarkI18nMessages() {
  *     },
  *     "service_b": {
  *       "image": "...",
+ *       "security_opts": {
+ *         "read_only_root_filesystem": true,
+ *         "seccomp_profile": "default",
+ *         "cap_drop": ["ALL"],
+ *         "cap_add": ["NET_BIND_SERVICE"]
+ *       },
  *       "network_isolation": {
  *         "outbound_permit_only_ignore": "ETH_ACCT_SPECIFIED",
  *         "outbound_permit_only": [
@@ -179,6 +220,31 @@ type servicePair struct {
 	serviceConfig *persistence.ServiceConfig // the internal type
 }
 
+// Returns the docker security options for the seccomp and AppArmor profiles of a service. Docker takes the content of a
+// seccomp profile rather than its path, so the profile is read from the node.
+func dockerSecurityOpt(opts *containermessage.SecurityOpts) ([]string, error) {
+	securityOpt := []string{}
+	switch opts.SeccompProfile {
+	case "", containermessage.SECURITY_PROFILE_DEFAULT:
+	case containermessage.SECURITY_PROFILE_UNCONFINED:
+		securityOpt = append(securityOpt, "seccomp="+containermessage.SECURITY_PROFILE_UNCONFINED)
+	default:
+		profile, err := ioutil.ReadFile(opts.SeccompProfile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read seccomp profile %v, error: %v", opts.SeccompProfile, err)
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, profile); err != nil {
+			return nil, fmt.Errorf("seccomp profile %v is not valid JSON, error: %v", opts.SeccompProfile, err)
+		}
+		securityOpt = append(securityOpt, "seccomp="+compact.String())
+	}
+	if opts.ApparmorProfile != "" {
+		securityOpt = append(securityOpt, "apparmor="+opts.ApparmorProfile)
+	}
+	return securityOpt, nil
+}
+
 func hashService(service *containermessage.Service) (string, error) {
 	if service == nil {
 		return "", errors.New("required service ref not provided")
@@ -199,6 +265,17 @@ func hashService(service *containermessage.Service) (string, error) {
 	return base64.URLEncoding.EncodeToString(h.Sum(nil)), nil
 }
 
+// Returns the comma separated host names of the dependency containers, as known on the dependency networks.
+func dependencyServiceNames(containers []docker.APIContainers) string {
+	names := make([]string, 0)
+	for _, container := range containers {
+		if name, ok := container.Labels[LABEL_PREFIX+".service_name"]; ok && name != "" && !cutil.SliceContains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
 // This function will remove an env var that is already in the array. This function
 // modifies the input array.
 func removeDuplicateVariable(existingArray *[]string, newVar string) {
@@ -214,7 +291,7 @@ func removeDuplicateVariable(existingArray *[]string, newVar string) {
 
 }
 
-func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *containermessage.DeploymentDescription, environmentAdditions map[string]string, workloadRWStorageDir string, cpuSet string, uds string) (map[string]servicePair, error) {
+func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *containermessage.DeploymentDescription, environmentAdditions map[string]string, workloadRWStorageDir string, cpuSet string, uds string, serviceURL string) (map[string]servicePair, error) {
 
 	// final structure
 	services := make(map[string]servicePair, 0)
@@ -236,6 +313,22 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 		return nil, fmt.Errorf("No services specified in pattern: %v", deployment)
 	}
 
+	// The named volumes are checked for the whole deployment because the services can share them.
+	if _, err := deployment.Volumes(); err != nil {
+		return nil, fmt.Errorf("Illegal volumes specified in deployment description: %v", err)
+	}
+
+	// The services that join the network of another service are checked for the whole deployment too.
+	if err := deployment.ValidatePods(); err != nil {
+		return nil, fmt.Errorf("Illegal network specified in deployment description: %v", err)
+	}
+
+	// The node policy can limit the egress of every service container.
+	nodeEgressKbps, err := w.nodeEgressLimit()
+	if err != nil {
+		return nil, fmt.Errorf("Illegal egress limit in the node policy: %v", err)
+	}
+
 	for serviceName, service := range deployment.Services {
 		deploymentHash, err := hashService(service)
 		if err != nil {
@@ -243,18 +336,11 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 		}
 
 		// Determine if docker or podman as there are some differences
-		if w.apiServerType == "" {
-			if svType, err := GetServerEnginType(w.client); err != nil {
-				return nil, err
-			} else {
-				w.apiServerType = svType
-			}
-		}
-
-		// Needed in case SELinux is enabled with podman. Podman won't allow the use of the unix domain socket without it
-		if w.apiServerType == API_SERVER_TYPE_PODMAN {
-			service.SecurityOpt = append(service.SecurityOpt, "label=disable")
+		rt, err := w.resolveRuntime()
+		if err != nil {
+			return nil, err
 		}
+		service.SecurityOpt = append(service.SecurityOpt, rt.SecurityOpts()...)
 
 		// If the FSS is using a unix domain socket listener, add a filesystem binding for it.
 		if uds != "" {
@@ -273,6 +359,39 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 			service.Binds = append(service.Binds, fmt.Sprintf("%v:%v:ro", w.GetSecretsManager().GetSecretsPath(agreementId), config.HZN_SECRETS_MOUNT))
 		}
 
+		// Add a filesystem binding for the mTLS certificate and tell the service where to find it and which peers it can
+		// authenticate, the services it depends on and the other services in the same deployment.
+		var mtlsEnv map[string]string
+		if w.GetMTLSManager() != nil {
+			service.Binds = append(service.Binds, fmt.Sprintf("%v:%v:ro", w.GetMTLSManager().GetCertificatePath(agreementId), config.HZN_MTLS_MOUNT))
+
+			peers := make([]string, 0)
+			if deps, ok := environmentAdditions[config.ENVVAR_PREFIX+"MTLS_PEERS"]; ok && deps != "" {
+				peers = append(peers, strings.Split(deps, ",")...)
+			}
+			for _, name := range deployment.ServiceNames() {
+				if name != serviceName {
+					peers = append(peers, name)
+				}
+			}
+
+			mtlsEnv = map[string]string{
+				config.ENVVAR_PREFIX + "MTLS_CA":    path.Join(config.HZN_MTLS_MOUNT, config.HZN_MTLS_CA_FILE),
+				config.ENVVAR_PREFIX + "MTLS_CERT":  path.Join(config.HZN_MTLS_MOUNT, config.HZN_MTLS_CERT_FILE),
+				config.ENVVAR_PREFIX + "MTLS_KEY":   path.Join(config.HZN_MTLS_MOUNT, config.HZN_MTLS_KEY_FILE),
+				config.ENVVAR_PREFIX + "MTLS_PEERS": strings.Join(peers, ","),
+			}
+		}
+
+		// Add a filesystem binding for each named volume of the service.
+		for _, v := range service.Volumes {
+			bind := fmt.Sprintf("%v:%v", namedVolumeName(v, agreementId, serviceURL), v.Target)
+			if v.ReadOnly {
+				bind += ":ro"
+			}
+			service.Binds = append(service.Binds, bind)
+		}
+
 		// Get the group id that owns the service ess auth folder/file. Add this group id in the GroupAdd fields in docker.HostConfig. So that service account in service container can read ess auth folder/file (750)
 		groupAdds := make([]string, 0)
 		if !w.IsDevInstance() {
@@ -306,13 +425,9 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 		var logConfig docker.LogConfig
 
 		// Use -log-driver defined in the deployment string of the service.
-		// If -log-driver is not defined
-		// Use journald log driver by default if podman is running
-		// Otherwise use syslog log driver by default.
-		logDriver := LOG_DRIVER_SYSLOG
-		if w.apiServerType == API_SERVER_TYPE_PODMAN {
-			logDriver = LOG_DRIVER_JOURNALD
-		}
+		// If -log-driver is not defined, use the default log driver of the runtime,
+		// journald for podman and syslog for docker.
+		logDriver := rt.DefaultLogDriver()
 		if service.LogDriver != "" {
 			logDriver = service.LogDriver
 		}
@@ -344,6 +459,23 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 			delete(logConfig.Config, "tag")
 		}
 
+		// Add the log options of the service. The logs written to files on the device are rotated even if the
+		// service does not ask for it, so that they do not fill the disk.
+		if err := containermessage.ValidateLogOptions(logDriver, service.LogOptions); err != nil {
+			return nil, fmt.Errorf("Illegal log_options specified in deployment description for service %v: %v", serviceName, err)
+		}
+		for k, v := range service.LogOptions {
+			logConfig.Config[k] = v
+		}
+		if containermessage.IsRotatingLogDriver(logDriver) {
+			if _, ok := logConfig.Config["max-size"]; !ok && w.Config.GetServiceLogMaxSize() != "-1" {
+				logConfig.Config["max-size"] = w.Config.GetServiceLogMaxSize()
+				if _, ok := logConfig.Config["max-file"]; !ok {
+					logConfig.Config["max-file"] = strconv.Itoa(w.Config.GetServiceLogMaxFile())
+				}
+			}
+		}
+
 		serviceConfig := &persistence.ServiceConfig{
 			Config: docker.Config{
 				Image:        service.Image,
@@ -376,14 +508,46 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 			},
 		}
 
-		// Set CPU and memory limits if they are defined in the service config
+		// Set CPU and memory limits if they are defined in the service config. The memory limit is a hard limit, the
+		// container cannot use swap on top of it.
 		if service.MaxMemoryMb != 0 {
 			serviceConfig.HostConfig.Memory = service.MaxMemoryMb * 1024 * 1024
+			serviceConfig.HostConfig.MemorySwap = serviceConfig.HostConfig.Memory
 		}
 		if service.MaxCPUs != 0 {
 			serviceConfig.HostConfig.NanoCPUs = int64(service.MaxCPUs * 1000000000)
 		}
 
+		// The containers of a pod join the network namespace of the container of the service they name.
+		if pod := service.PodService(); pod != "" {
+			serviceConfig.HostConfig.NetworkMode = podNetworkMode(agreementId, pod)
+			serviceConfig.Config.Labels[POD_LABEL] = pod
+		}
+
+		// The egress of the container is shaped once it is started, the egress of the host network is not.
+		if service.MaxEgressKbps < 0 {
+			return nil, fmt.Errorf("Illegal max_egress_kbps specified in deployment description for service %v: %v", serviceName, service.MaxEgressKbps)
+		} else if service.Network == "host" && service.MaxEgressKbps != 0 {
+			return nil, fmt.Errorf("Illegal max_egress_kbps specified in deployment description for service %v: the egress of the host network cannot be limited", serviceName)
+		} else if kbps := egressLimit(service.MaxEgressKbps, nodeEgressKbps); kbps != 0 && service.Network != "host" && service.PodService() == "" {
+			serviceConfig.Config.Labels[EGRESS_LABEL] = strconv.FormatInt(kbps, 10)
+		}
+
+		// The container is checkpointed when a service upgrade stops it.
+		if service.Checkpoint {
+			serviceConfig.Config.Labels[CHECKPOINT_LABEL] = "true"
+		}
+
+		// The container is given time to flush its state when it is stopped, instead of being killed.
+		if err := service.ValidateStop(); err != nil {
+			return nil, fmt.Errorf("Illegal stop settings specified in deployment description for service %v: %v", serviceName, err)
+		} else if service.GracefulStop() {
+			if err := setStopLabels(serviceConfig.Config.Labels, service); err != nil {
+				return nil, fmt.Errorf("Unable to set the stop settings of service %v: %v", serviceName, err)
+			}
+			serviceConfig.Config.StopTimeout = service.StopTimeoutS()
+		}
+
 		// Mark each container as infrastructure if the deployment description indicates infrastructure
 		if deployment.Infrastructure {
 			serviceConfig.Config.Labels[LABEL_PREFIX+".infrastructure"] = ""
@@ -394,6 +558,12 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 			serviceConfig.Config.Env = append(serviceConfig.Config.Env, fmt.Sprintf("%s=%v", k, v))
 		}
 
+		// the mTLS variables are specific to each service in the deployment
+		for k, v := range mtlsEnv {
+			removeDuplicateVariable(&serviceConfig.Config.Env, fmt.Sprintf("%s=%v", k, v))
+			serviceConfig.Config.Env = append(serviceConfig.Config.Env, fmt.Sprintf("%s=%v", k, v))
+		}
+
 		// add the environment variables from the deployment definition
 		for _, v := range service.Environment {
 			// skip this one b/c it's dangerous
@@ -509,6 +679,47 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 			})
 		}
 
+		// Record the devices so that the container can be bound to them again when they are plugged back in
+		if w.Config.Edge.ServiceDeviceRebind && len(serviceConfig.HostConfig.Devices) != 0 {
+			if bindings := deviceBindings(serviceConfig.HostConfig.Devices); len(bindings) != 0 {
+				if label, err := json.Marshal(bindings); err == nil {
+					serviceConfig.Config.Labels[DEVICES_LABEL] = string(label)
+				}
+			}
+		}
+
+		// The GPUs are requested from the nvidia runtime of the container engine rather than as raw devices
+		if service.GPUs != nil {
+			if req, err := service.GPUs.DeviceRequest(); err != nil {
+				return nil, fmt.Errorf("Illegal gpus specified in deployment description for service %v: %v", serviceName, err)
+			} else {
+				serviceConfig.HostConfig.DeviceRequests = append(serviceConfig.HostConfig.DeviceRequests, *req)
+			}
+		}
+
+		// The container is always restarted unless the service asks otherwise
+		if service.RestartPolicy != nil {
+			if rp, err := service.RestartPolicy.DockerRestartPolicy(); err != nil {
+				return nil, fmt.Errorf("Illegal restart_policy specified in deployment description for service %v: %v", serviceName, err)
+			} else {
+				serviceConfig.HostConfig.RestartPolicy = rp
+			}
+		}
+
+		// The security options harden the container beyond the defaults of the container engine
+		if service.SecurityOpts != nil {
+			if err := service.SecurityOpts.Validate(); err != nil {
+				return nil, fmt.Errorf("Illegal security_opts specified in deployment description for service %v: %v", serviceName, err)
+			} else if securityOpt, err := dockerSecurityOpt(service.SecurityOpts); err != nil {
+				return nil, fmt.Errorf("Illegal security_opts specified in deployment description for service %v: %v", serviceName, err)
+			} else {
+				serviceConfig.HostConfig.ReadonlyRootfs = service.SecurityOpts.ReadOnlyRootFilesystem
+				serviceConfig.HostConfig.CapDrop = service.SecurityOpts.CapDrop
+				serviceConfig.HostConfig.CapAdd = append(append([]string{}, service.CapAdd...), service.SecurityOpts.CapAdd...)
+				serviceConfig.HostConfig.SecurityOpt = append(append([]string{}, serviceConfig.HostConfig.SecurityOpt...), securityOpt...)
+			}
+		}
+
 		services[serviceName] = servicePair{
 			serviceConfig: serviceConfig,
 			service:       service,
@@ -559,9 +770,12 @@ type ContainerWorker struct {
 	iptables          *iptables.IPTables
 	authMgr           *resource.AuthenticationManager
 	secretMgr         *resource.SecretsManager
+	mtlsMgr           *resource.MTLSManager
 	pattern           string
 	isDevInstance     bool
-	apiServerType     string
+	runtime           ContainerRuntime
+	ctrd              *ContainerdBackend
+	exportPath        string // the directory the named volumes are exported to before their containers are removed, set by a node unregistration
 }
 
 func (cw *ContainerWorker) GetClient() *docker.Client {
@@ -580,6 +794,11 @@ func (cw *ContainerWorker) GetSecretsManager() *resource.SecretsManager {
 	return cw.secretMgr
 }
 
+// Returns nil when service mTLS is not enabled.
+func (cw *ContainerWorker) GetMTLSManager() *resource.MTLSManager {
+	return cw.mtlsMgr
+}
+
 func CreateCLIContainerWorker(config *config.HorizonConfig) (*ContainerWorker, error) {
 	dockerEP := cutil.GetDockerEndpoint()
 	client, derr := docker.NewClient(dockerEP)
@@ -587,7 +806,7 @@ func CreateCLIContainerWorker(config *config.HorizonConfig) (*ContainerWorker, e
 		return nil, derr
 	}
 
-	svType, err := GetServerEnginType(client)
+	rt, err := NewContainerRuntime(client, config.GetContainerRuntime())
 	if err != nil {
 		return nil, err
 	}
@@ -601,7 +820,7 @@ func CreateCLIContainerWorker(config *config.HorizonConfig) (*ContainerWorker, e
 		secretMgr:     resource.NewSecretsManager(config.GetSecretsManagerFilePath(), nil),
 		pattern:       "",
 		isDevInstance: true,
-		apiServerType: svType,
+		runtime:       rt,
 	}, nil
 }
 
@@ -653,23 +872,54 @@ func NewContainerWorker(name string, config *config.HorizonConfig, db *bolt.DB,
 		}
 	}
 
+	var ctrd *ContainerdBackend
+	if config.IsContainerdRuntime() {
+		ctrd, err = NewContainerdBackend(config)
+		if err != nil {
+			glog.Errorf("Failed to instantiate containerd Client: %v", err)
+			eventlog.LogNodeEvent(db, persistence.SEVERITY_FATAL,
+				persistence.NewMessageMeta(EL_CONT_TERM_UNABLE_INIT_CONTAINERD_CLIENT, err.Error()),
+				persistence.EC_ERROR_CREATE_DOCKER_CLIENT,
+				"", "", "", "")
+			panic(fmt.Sprintf("Terminating, unable to instantiate containerd Client. %v", err))
+		}
+	}
+
 	pattern := ""
 	if dev != nil {
 		pattern = dev.Pattern
 	}
 
+	var mm *resource.MTLSManager
+	if config.Edge.ServiceMTLS {
+		mm = resource.NewMTLSManager(config.GetServiceMTLSPath())
+	}
+
 	worker := &ContainerWorker{
-		BaseWorker:    worker.NewBaseWorker(name, config, nil),
-		db:            db,
-		client:        client,
-		iptables:      ipt,
-		authMgr:       am,
-		secretMgr:     sm,
-		pattern:       pattern,
-		apiServerType: "",
+		BaseWorker: worker.NewBaseWorker(name, config, nil),
+		db:         db,
+		client:     client,
+		iptables:   ipt,
+		authMgr:    am,
+		secretMgr:  sm,
+		mtlsMgr:    mm,
+		pattern:    pattern,
+		ctrd:       ctrd,
 	}
 	worker.SetDeferredDelay(15)
 
+	if config.Edge.ServiceDeviceRebind && ctrd != nil {
+		glog.Warningf("ContainerWorker unable to watch device events, rebinding devices is not supported with containerd")
+	} else if config.Edge.ServiceDeviceRebind {
+		if err := worker.watchDevices(); err != nil {
+			glog.Warningf("ContainerWorker unable to watch device events, service containers will not be rebound to their devices, error: %v", err)
+		}
+	}
+
+	if config.Edge.ServiceDNS && ctrd != nil {
+		glog.Warningf("ContainerWorker unable to create the shared service network, it is not supported with containerd")
+	}
+
 	worker.Start(worker, 0)
 	return worker
 }
@@ -715,6 +965,8 @@ func (w *ContainerWorker) NewEvent(incoming events.Message) {
 		case events.CONTAINER_MAINTAIN:
 			containerCmd := w.NewContainerMaintenanceCommand(msg.AgreementProtocol, msg.AgreementId, msg.Deployment)
 			w.Commands <- containerCmd
+		case events.WORKLOAD_PAUSE, events.WORKLOAD_RESUME:
+			w.Commands <- w.NewWorkloadPauseCommand(msg.AgreementId, msg.Deployment, msg.Event().Id == events.WORKLOAD_PAUSE)
 		}
 
 	case *events.GovernanceWorkloadCancelationMessage:
@@ -723,6 +975,7 @@ func (w *ContainerWorker) NewEvent(incoming events.Message) {
 		switch msg.Event().Id {
 		case events.AGREEMENT_ENDED:
 			containerCmd := w.NewWorkloadShutdownCommand(msg.AgreementProtocol, msg.AgreementId, msg.Deployment, []string{})
+			containerCmd.Checkpoint = msg.Cause == events.AG_UPGRADED
 			w.Commands <- containerCmd
 		}
 
@@ -742,6 +995,8 @@ func (w *ContainerWorker) NewEvent(incoming events.Message) {
 		case events.CONTAINER_MAINTAIN:
 			containerCmd := w.NewMaintainMicroserviceCommand(msg.MsInstKey)
 			w.Commands <- containerCmd
+		case events.WORKLOAD_PAUSE, events.WORKLOAD_RESUME:
+			w.Commands <- w.NewWorkloadPauseCommand(msg.MsInstKey, nil, msg.Event().Id == events.WORKLOAD_PAUSE)
 		}
 
 	case *events.MicroserviceCancellationMessage:
@@ -756,6 +1011,15 @@ func (w *ContainerWorker) NewEvent(incoming events.Message) {
 			w.Commands <- containerCmd
 		}
 
+	case *events.NodeShutdownMessage:
+		msg, _ := incoming.(*events.NodeShutdownMessage)
+		switch msg.Event().Id {
+		case events.START_UNCONFIGURE:
+			if msg.ExportPath() != "" {
+				w.Commands <- NewVolumeExportCommand(msg.ExportPath())
+			}
+		}
+
 	case *events.NodeShutdownCompleteMessage:
 		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
 		switch msg.Event().Id {
@@ -770,7 +1034,9 @@ func (w *ContainerWorker) NewEvent(incoming events.Message) {
 	return
 }
 
-func MakeBridge(client *docker.Client, name string, infrastructure, sharedPattern, isDev bool) (*docker.Network, error) {
+// Create a bridge network for the containers of a service. When an IPv6 prefix is given, the network is dual-stack and
+// gets its own /64 subnet from the prefix, the IPv4 subnet is still assigned by the container engine.
+func MakeBridge(client *docker.Client, rt ContainerRuntime, name string, infrastructure, sharedPattern, isDev bool, ipv6Prefix string) (*docker.Network, error) {
 
 	// Labels on the docker network indicate attributes about the network.
 	labels := make(map[string]string)
@@ -797,12 +1063,31 @@ func MakeBridge(client *docker.Client, name string, infrastructure, sharedPatter
 			Driver: "default",
 			Config: []docker.IPAMConfig{},
 		},
-		Options: map[string]interface{}{
-			"com.docker.network.bridge.enable_icc":           "true",
-			"com.docker.network.bridge.enable_ip_masquerade": "true",
-			"com.docker.network.bridge.default_bridge":       "false",
-		},
-		Labels: labels,
+		Options: rt.BridgeOptions(),
+		Labels:  labels,
+	}
+
+	if ipv6Prefix != "" {
+		networks, err := client.ListNetworks()
+		if err != nil {
+			return nil, fmt.Errorf("unable to list networks, error %v", err)
+		}
+		usedSubnets := make([]string, 0)
+		for _, nw := range networks {
+			for _, ipamConfig := range nw.IPAM.Config {
+				usedSubnets = append(usedSubnets, ipamConfig.Subnet)
+			}
+		}
+
+		subnet, err := freeIPv6Subnet(ipv6Prefix, name, usedSubnets)
+		if err != nil {
+			return nil, err
+		}
+		glog.V(3).Infof("Assigning IPv6 subnet %v to network %v", subnet, name)
+
+		bridgeOpts.EnableIPv6 = true
+		bridgeOpts.IPAM.Config = append(bridgeOpts.IPAM.Config, docker.IPAMConfig{Subnet: subnet})
+		labels[LABEL_PREFIX+".ipv6_subnet"] = subnet
 	}
 
 	bridge, err := client.CreateNetwork(bridgeOpts)
@@ -812,6 +1097,53 @@ func MakeBridge(client *docker.Client, name string, infrastructure, sharedPatter
 	return bridge, nil
 }
 
+// Returns a /64 subnet of the IPv6 prefix that does not overlap any of the used subnets. The subnets are searched starting
+// at a position derived from the network name, so that a network that is re-created usually gets the same subnet.
+func freeIPv6Subnet(prefix string, name string, usedSubnets []string) (string, error) {
+	if err := config.ValidateIPv6Prefix(prefix); err != nil {
+		return "", err
+	}
+	_, prefixNet, _ := net.ParseCIDR(prefix)
+
+	used := make([]*net.IPNet, 0)
+	for _, subnet := range usedSubnets {
+		if _, usedNet, err := net.ParseCIDR(subnet); err == nil {
+			used = append(used, usedNet)
+		}
+	}
+
+	// At most the first 65536 subnets of the prefix are used.
+	ones, _ := prefixNet.Mask.Size()
+	subnetBits := 64 - ones
+	if subnetBits > 16 {
+		subnetBits = 16
+	}
+	count := uint64(1) << uint(subnetBits)
+
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	start := h.Sum64() % count
+
+	for i := uint64(0); i < count; i++ {
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, prefixNet.IP.To16())
+		binary.BigEndian.PutUint64(ip[:8], binary.BigEndian.Uint64(ip[:8])|((start+i)%count))
+		candidate := &net.IPNet{IP: ip, Mask: net.CIDRMask(64, 128)}
+
+		overlaps := false
+		for _, usedNet := range used {
+			if usedNet.Contains(candidate.IP) || candidate.Contains(usedNet.IP) {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			return candidate.String(), nil
+		}
+	}
+	return "", fmt.Errorf("no free IPv6 subnet left in %v", prefix)
+}
+
 func serviceStart(client *docker.Client,
 	agreementId string,
 	serviceName string,
@@ -821,6 +1153,7 @@ func serviceStart(client *docker.Client,
 	sharedEndpoints map[string]*docker.EndpointConfig,
 	postCreateContainers *[]interface{},
 	fail func(container *docker.Container, name string, err error) error,
+	start func(container *docker.Container) error,
 	isFirstTry bool) error {
 
 	var namePrefix string
@@ -855,9 +1188,8 @@ func serviceStart(client *docker.Client,
 		}
 	}
 
-	// second arg just a backwards compat feature, will go away someday
 	logDriverName := serviceConfig.HostConfig.LogConfig.Type
-	err := client.StartContainer(container.ID, nil)
+	err := start(container)
 	if err != nil {
 		if strings.Contains(err.Error(), "logging driver") && (strings.Contains(err.Error(), LOG_DRIVER_SYSLOG) || strings.Contains(err.Error(), LOG_DRIVER_JOURNALD)) {
 			// prevent infinit loop, just in case
@@ -872,13 +1204,13 @@ func serviceStart(client *docker.Client,
 				return fail(container, serviceName, err_r)
 			} else {
 				return serviceStart(client, agreementId, serviceName, shareLabel, serviceConfig, endpointsConfig,
-					sharedEndpoints, postCreateContainers, fail, false)
+					sharedEndpoints, postCreateContainers, fail, start, false)
 			}
 		} else {
 			return fail(container, serviceName, err)
 		}
 	}
-	if serviceConfig.HostConfig.NetworkMode != "host" {
+	if serviceConfig.HostConfig.NetworkMode != "host" && podContainer(serviceConfig.HostConfig.NetworkMode) == "" {
 		for _, cfg := range sharedEndpoints {
 			glog.V(5).Infof("Connecting network: %v to container id: %v as endpoint: %v", cfg.NetworkID, container.ID, cfg.Aliases)
 			err := client.ConnectNetwork(cfg.NetworkID, docker.NetworkConnectionOptions{
@@ -898,9 +1230,14 @@ func serviceStart(client *docker.Client,
 	return nil
 }
 
-func serviceDestroy(client *docker.Client, agreementId string, containerId string) (bool, error) {
+func serviceDestroy(client *docker.Client, agreementId string, containerId string, labels map[string]string) (bool, error) {
 	glog.V(3).Infof("Attempting to stop container %v from agreement: %v.", containerId, agreementId)
-	err := client.KillContainer(docker.KillContainerOptions{ID: containerId})
+	var err error
+	if stopTimeout, preStop := getStopLabels(labels); stopTimeout != 0 {
+		err = gracefulStop(client, agreementId, containerId, stopTimeout, preStop)
+	} else {
+		err = client.KillContainer(docker.KillContainerOptions{ID: containerId})
+	}
 
 	if err != nil {
 		if _, ok := err.(*docker.NoSuchContainer); ok {
@@ -1240,6 +1577,11 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 		return err
 	}
 
+	// a container stopped by a service upgrade is restored from its checkpoint
+	start := func(container *docker.Container) error {
+		return b.startContainer(agreementId, serviceURL, sVer, container)
+	}
+
 	mkEndpoints := func(bridge *docker.Network, containerName string) map[string]*docker.EndpointConfig {
 
 		return map[string]*docker.EndpointConfig{
@@ -1306,6 +1648,14 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 		glog.Errorf("Failed to create MMS Authentication credential file for %v, error %v", agreementId, err)
 	}
 
+	// Issue the mTLS certificate of the service, valid for the host names of the containers in the deployment. It uses
+	// the group created with the authentication credential.
+	if b.GetMTLSManager() != nil {
+		if err := b.GetMTLSManager().CreateCertificate(agreementId, serviceURL, deployment.ServiceNames(), !b.isDevInstance); err != nil {
+			return nil, fmt.Errorf("Failed to create mTLS certificate for %v, error %v", agreementId, err)
+		}
+	}
+
 	if !b.IsDevInstance() {
 		msInstKey := agreementId
 		// Make sure miroservice instance exsits
@@ -1325,11 +1675,34 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 		return nil, fmt.Errorf("Error writing service secrets for agreement %v to file: %v", agreementId, err)
 	}
 
-	servicePairs, err := b.finalizeDeployment(agreementId, deployment, environmentAdditions, workloadRWStorageDir, b.Config.Edge.DefaultCPUSet, b.Config.GetFileSyncServiceAPIUnixDomainSocketPath())
+	servicePairs, err := b.finalizeDeployment(agreementId, deployment, environmentAdditions, workloadRWStorageDir, b.Config.Edge.DefaultCPUSet, b.Config.GetFileSyncServiceAPIUnixDomainSocketPath(), serviceURL)
+	if err != nil {
+		return nil, err
+	}
+
+	startOrder, err := deployment.StartOrder()
 	if err != nil {
 		return nil, err
 	}
 
+	// Reserve the resources the containers are limited to, so that the node declines agreements that do not fit.
+	if !b.IsDevInstance() {
+		memoryMb, cpus := deployment.ResourceLimits()
+		if err := persistence.SaveResourceReservation(b.db, persistence.NewResourceReservation(agreementId, memoryMb, cpus, deployment.GPULimits())); err != nil {
+			return nil, fail(nil, agreementId, fmt.Errorf("Unable to save the resource reservation for %v, error %v", agreementId, err))
+		}
+	}
+
+	// Remember which agreement uses the named volumes, so that they are released when the agreement ends.
+	if err := b.recordNamedVolumes(agreementId, serviceURL, deployment); err != nil {
+		return nil, fail(nil, agreementId, err)
+	}
+
+	// When the containers are run by containerd, the ms_networks are the names and IP addresses of the dependency services.
+	if b.ctrd != nil {
+		return b.containerdResourcesCreate(agreementId, agreementProtocol, deployment, servicePairs, startOrder, ms_networks, fail)
+	}
+
 	// process services that are "shared" first, then others
 	shared := make(map[string]servicePair, 0)
 	private := make(map[string]servicePair, 0)
@@ -1421,7 +1794,7 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 		}
 
 		if existingNetwork == nil {
-			existingNetwork, err = MakeBridge(b.client, bridgeName, deployment.Infrastructure, true, b.isDevInstance)
+			existingNetwork, err = MakeBridge(b.client, b.GetRuntime(), bridgeName, deployment.Infrastructure, true, b.isDevInstance, b.Config.GetServiceNetworkIPv6Prefix())
 			glog.V(2).Infof("Created new network for shared container: %v. Network: %v", containerName, existingNetwork)
 			if err != nil {
 				return nil, fail(nil, containerName, fmt.Errorf("Unable to create bridge for shared container. Original error: %v", err))
@@ -1437,7 +1810,7 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 		if existingContainer == nil {
 			// only create container if there wasn't one
 			servicePair.serviceConfig.HostConfig.NetworkMode = bridgeName
-			if err := serviceStart(b.client, agreementId, containerName, shareLabel, servicePair.serviceConfig, eps, ms_sharedendpoints, &postCreateContainers, fail, true); err != nil {
+			if err := serviceStart(b.client, agreementId, containerName, shareLabel, servicePair.serviceConfig, eps, ms_sharedendpoints, &postCreateContainers, fail, start, true); err != nil {
 				return nil, err
 			}
 		} else {
@@ -1464,7 +1837,7 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 			}
 			if agBridge == nil {
 				glog.V(5).Infof("Making network %v", agreementId)
-				newBridge, err := MakeBridge(b.client, agreementId, deployment.Infrastructure, false, b.isDevInstance)
+				newBridge, err := MakeBridge(b.client, b.GetRuntime(), agreementId, deployment.Infrastructure, false, b.isDevInstance, b.Config.GetServiceNetworkIPv6Prefix())
 				if err != nil {
 					return nil, err
 				}
@@ -1478,22 +1851,40 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 		recordEndpoints(sharedEndpoints, ms_sharedendpoints)
 	}
 
-	// every one of these gets wired to both the agBridge and every shared bridge from this agreement
-	for serviceName, servicePair := range private {
+	// every one of these gets wired to both the agBridge and every shared bridge from this agreement, the services are
+	// started after the services they depend on
+	for _, serviceName := range startOrder {
+		servicePair, ok := private[serviceName]
+		if !ok {
+			continue
+		}
 		if servicePair.serviceConfig.HostConfig.NetworkMode == "" {
 			servicePair.serviceConfig.HostConfig.NetworkMode = agreementId // custom bridge has agreementId as name, same as endpoint key
 		}
 		var endpoints map[string]*docker.EndpointConfig
-		if servicePair.serviceConfig.HostConfig.NetworkMode != "host" {
+		if servicePair.serviceConfig.HostConfig.NetworkMode != "host" && podContainer(servicePair.serviceConfig.HostConfig.NetworkMode) == "" {
 			endpoints = mkEndpoints(agBridge, serviceName)
+
+			// the other containers of the pod are reached at the address of this one
+			endpoints[agBridge.Name].Aliases = append(endpoints[agBridge.Name].Aliases, deployment.PodMembers(serviceName)...)
 		}
-		if err := serviceStart(b.client, agreementId, serviceName, "", servicePair.serviceConfig, endpoints, sharedEndpoints, &postCreateContainers, fail, true); err != nil {
+		if err := serviceStart(b.client, agreementId, serviceName, "", servicePair.serviceConfig, endpoints, sharedEndpoints, &postCreateContainers, fail, start, true); err != nil {
 			if err != docker.ErrContainerAlreadyExists {
 				return nil, err
 			}
 		}
 	}
 
+	// Connect the containers to the shared network, so that the services of the other agreements can reach them by name.
+	if b.Config.Edge.ServiceDNS {
+		if err := b.connectServiceDNS(postCreateContainers); err != nil {
+			return nil, fail(nil, agreementId, err)
+		}
+	}
+
+	// Limit the egress of the containers now that they have their network namespaces.
+	b.shapeAgreementEgress(agreementId)
+
 	// check environmentAdditions for MTN_ETHEREUM_ACCOUNT
 	_, hasSpecifiedEthAccount := environmentAdditions[config.ENVVAR_PREFIX+"ETHEREUM_ACCOUNT"]
 
@@ -1542,6 +1933,11 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 			// network ids to be added to all the workload containers.
 			ms_children_networks := b.GatherAndCreateDependencyNetworks(ms_containers, agreementId)
 
+			// The dependencies are mTLS peers of the workload containers.
+			if b.GetMTLSManager() != nil {
+				(*cmd.AgreementLaunchContext.EnvironmentAdditions)[config.ENVVAR_PREFIX+"MTLS_PEERS"] = dependencyServiceNames(ms_containers)
+			}
+
 			// We support capabilities in the deployment string that not all container deployments should be able
 			// to exploit, e.g. file system mapping from host to container. This check ensures that workloads dont try
 			// to do something dangerous.
@@ -1671,6 +2067,11 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 				// Now that we have a list of containers on which this service is dependent, we need to get a list of network ids
 				// for the dependencies so that all of this service's containers can be added to the dependency networks.
 				ms_children_networks = b.GatherAndCreateDependencyNetworks(ms_containers, lc.Name)
+
+				// The dependencies are mTLS peers of this service's containers.
+				if b.GetMTLSManager() != nil {
+					(*lc.EnvironmentAdditions)[config.ENVVAR_PREFIX+"MTLS_PEERS"] = dependencyServiceNames(ms_containers)
+				}
 			}
 		}
 
@@ -1700,6 +2101,11 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 
 		for serviceName, service := range deploymentDesc.Services {
 
+			// the image may have been pinned to its digest when it was pulled
+			if fetched, ok := cmd.DeploymentDescription.Services[serviceName]; ok && fetched.Image != "" {
+				service.Image = fetched.Image
+			}
+
 			if !service.Privileged {
 				glog.V(5).Infof("Checking bind permissions for service %v", serviceName)
 				if err := hasValidBindPermissions(service.Binds); err != nil {
@@ -1825,6 +2231,9 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 			}
 
 			b.ContainersMatchingAgreement([]string{cmd.AgreementId}, true, report)
+			b.restartStalePodMembers(cMatches)
+			b.shapeContainersEgress(cMatches)
+			b.pruneCheckpoints()
 
 			if len(serviceNames) == len(cMatches) {
 				glog.V(3).Infof("Found expected count of running containers for agreement %v: %v", cmd.AgreementId, len(cMatches))
@@ -1836,6 +2245,20 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 			}
 		}
 
+	case *WorkloadPauseCommand:
+		cmd := command.(*WorkloadPauseCommand)
+
+		// The container worker might not be the right handler for this event, if the deployment is handled by some other worker.
+		if cmd.Deployment != nil && !cmd.Deployment.IsNative() {
+			glog.V(5).Infof("ContainerWorker ignoring pause command for %v: %v", cmd.Key, cmd)
+			return true
+		}
+
+		glog.V(3).Infof("ContainerWorker received pause command: %v", cmd.ShortString())
+		if err := b.pauseContainers(cmd.Key, cmd.Pause); err != nil {
+			glog.Errorf("Error changing the paused state of the containers of %v: %v", cmd.Key, err)
+		}
+
 	case *WorkloadShutdownCommand:
 		cmd := command.(*WorkloadShutdownCommand)
 
@@ -1853,6 +2276,10 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 			agreements = append(agreements, cmd.CurrentAgreementId)
 		}
 
+		if cmd.Checkpoint {
+			b.checkpointContainers(agreements)
+		}
+
 		if err := b.ResourcesRemove(agreements); err != nil {
 			glog.Errorf("Error removing resources: %v", err)
 		}
@@ -1901,6 +2328,8 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 			}
 
 			b.ContainersMatchingAgreement([]string{cmd.MsInstKey}, true, report)
+			b.restartStalePodMembers(cMatches)
+			b.shapeContainersEgress(cMatches)
 
 			if len(serviceNames) == len(cMatches) {
 				glog.V(3).Infof("Found expected count of running containers for service instance %v: %v", cmd.MsInstKey, len(cMatches))
@@ -1936,6 +2365,11 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 			glog.Infof("ContainerWorker received cancel network command for service %v. Cancelling extra networks.", cmd.MsInstKey)
 		}
 
+		// The containerd containers share a single network, there are no extra networks.
+		if b.ctrd != nil {
+			return true
+		}
+
 		// Get a list of all the networks that currently exist.
 		networks, err := b.client.ListNetworks()
 		if err != nil {
@@ -2002,10 +2436,28 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 			}
 		}
 
+	case *DeviceChangedCommand:
+		cmd := command.(*DeviceChangedCommand)
+		b.rebindDevice(cmd)
+
+	case *VolumeExportCommand:
+		cmd := command.(*VolumeExportCommand)
+		if b.ctrd != nil {
+			glog.Warningf("ContainerWorker cannot export the volumes of the services with %v, they are removed with the services.", b.GetRuntime().Name())
+		} else {
+			glog.V(3).Infof("ContainerWorker will export the named volumes of the services to %v before removing them", cmd.ExportPath)
+			b.exportPath = cmd.ExportPath
+		}
+
 	case *NodeUnconfigCommand:
 		if err := b.GetAuthenticationManager().RemoveAll(!b.isDevInstance); err != nil {
 			glog.Errorf("Error handling node unconfig command: %v", err)
 		}
+		if b.GetMTLSManager() != nil {
+			if err := b.GetMTLSManager().RemoveAll(); err != nil {
+				glog.Errorf("Error handling node unconfig command: %v", err)
+			}
+		}
 		b.Commands <- worker.NewTerminateCommand("shutdown")
 
 	default:
@@ -2025,7 +2477,7 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 // function which periodically checks to ensure that all containers are running.
 func (b *ContainerWorker) syncupResources() {
 
-	if b.Config.Edge.DockerEndpoint == "" {
+	if b.Config.Edge.DockerEndpoint == "" && b.ctrd == nil {
 		glog.V(3).Infof("ContainerWorker: skip syncupResources. Docker client could be be initialized because DockerEndpoint is not set in the configuration.")
 		return
 	}
@@ -2082,9 +2534,12 @@ func (b *ContainerWorker) syncupResources() {
 
 		glog.V(5).Infof("Container worker found active agreements: %v", agMap)
 
+		// Release the resources reserved for agreements and service instances that are gone.
+		b.releaseLeftoverReservations(agMap)
+
 		// Second, run through each container (active or inactive) looking for containers that are leftover from old agreements. Be aware that there
 		// could be other non-Horizon containers on this host, so we have to be careful to NOT terminate them.
-		if containers, err := b.client.ListContainers(docker.ListContainersOptions{All: true}); err != nil {
+		if containers, err := b.listContainers(true); err != nil {
 			fail(fmt.Sprintf("ContainerWorker unable to get list of containers: %v", err))
 		} else {
 
@@ -2112,8 +2567,11 @@ func (b *ContainerWorker) syncupResources() {
 		}
 
 		// Third, run through each network looking for networks that are leftover from old agreements. Be aware that there
-		// could be other non-Horizon networks on this host, so we have to be careful to NOT terminate them.
-		if networks, err := b.client.ListNetworks(); err != nil {
+		// could be other non-Horizon networks on this host, so we have to be careful to NOT terminate them. The containerd
+		// containers share a single network, so there are no agreement networks.
+		if b.ctrd != nil {
+			glog.V(5).Infof("ContainerWorker skipping network sync up for containerd.")
+		} else if networks, err := b.client.ListNetworks(); err != nil {
 			fail(fmt.Sprintf("ContainerWorker unable to get list of networks: %v", err))
 		} else {
 			for _, net := range networks {
@@ -2134,6 +2592,8 @@ func (b *ContainerWorker) syncupResources() {
 					} else {
 						glog.Infof("Succeeded removing unused shared network: %v", net)
 					}
+				} else if net.Name == SERVICE_DNS_NETWORK {
+					b.releaseServiceDNSNetwork([]docker.Network{net})
 				} else if !IsAgreementId(net.Name) {
 					continue
 				} else if _, there := agMap[net.Name]; !there {
@@ -2172,6 +2632,10 @@ func (b *ContainerWorker) syncupResources() {
 			}
 		}
 
+		// Release the named volumes of the agreements and service instances that are gone, and remove the retained
+		// volumes that have not been used for the retention period.
+		b.releaseLeftoverVolumes(agMap)
+
 	}
 	// remove the leftover docker volumes created by anax
 	if b.GetExchangeToken() == "" {
@@ -2185,6 +2649,138 @@ func (b *ContainerWorker) syncupResources() {
 	b.Messages() <- events.NewDeviceContainersSyncedMessage(events.DEVICE_CONTAINERS_SYNCED, outcome)
 }
 
+// Delete the resource reservations of the agreements and service instances that are no longer active, e.g. because
+// they were cleaned up while the agent was down.
+func (b *ContainerWorker) releaseLeftoverReservations(agMap map[string]bool) {
+	if reservations, err := persistence.FindResourceReservations(b.db); err != nil {
+		glog.Errorf("ContainerWorker unable to retrieve resource reservations from database, error %v", err)
+	} else {
+		for _, r := range reservations {
+			if agMap[r.Key] {
+				continue
+			} else if msi, err := persistence.FindMicroserviceInstanceWithKey(b.db, r.Key); err != nil {
+				glog.Errorf("ContainerWorker unable to retrieve service instance %v from database, error %v", r.Key, err)
+			} else if msi != nil && !msi.Archived {
+				continue
+			} else {
+				glog.V(3).Infof("ContainerWorker releasing leftover resource reservation %v", r)
+				if err := persistence.DeleteResourceReservation(b.db, r.Key); err != nil {
+					glog.Errorf("ContainerWorker unable to delete resource reservation %v, error %v", r.Key, err)
+				}
+			}
+		}
+	}
+}
+
+// Returns the name of a named volume in the container engine. A volume retained across upgrades is named after the
+// service so that the next version of the service finds it, the other volumes are named after the agreement.
+func namedVolumeName(v containermessage.Volume, agreementId string, serviceURL string) string {
+	if v.IsRetained() {
+		return fmt.Sprintf("hzn-%v-%v", cutil.GetHashFromString(serviceURL), v.Name)
+	}
+	return fmt.Sprintf("%v-%v", agreementId, v.Name)
+}
+
+// Save the named volumes of the deployment in the local db with the agreement that uses them.
+func (b *ContainerWorker) recordNamedVolumes(agreementId string, serviceURL string, deployment *containermessage.DeploymentDescription) error {
+	if b.db == nil {
+		return nil
+	}
+
+	volumes, err := deployment.Volumes()
+	if err != nil {
+		return err
+	}
+	for _, v := range volumes {
+		retain := v.Retain
+		if retain == "" {
+			retain = containermessage.VOLUME_RETAIN_AGREEMENT
+		}
+		if err := persistence.SaveNamedContainerVolume(b.db, namedVolumeName(v, agreementId, serviceURL), agreementId, serviceURL, retain); err != nil {
+			return fmt.Errorf("Failed to save the volume %v for %v into the local db. %v", v.Name, agreementId, err)
+		}
+	}
+	return nil
+}
+
+// Release the named volumes of the agreements that ended. The volumes retained across upgrades are kept for the
+// retention period, the others are removed. Then remove the retained volumes whose retention period is over.
+func (b *ContainerWorker) releaseNamedVolumes(agreements []string) {
+	if b.db == nil {
+		return
+	}
+
+	for _, agreementId := range agreements {
+		cvs, err := persistence.FindContainerVolumes(b.db, []persistence.ContainerVolumeFilter{persistence.UnarchivedCVFilter(), persistence.AgreementCVFilter(agreementId)})
+		if err != nil {
+			glog.Errorf("Unable to retrieve the volumes of %v from the local db, error %v", agreementId, err)
+			continue
+		}
+		for ix, cv := range cvs {
+			if cv.Retain != containermessage.VOLUME_RETAIN_UPGRADE {
+				b.removeNamedVolume(&cvs[ix])
+			} else if cv.ReleaseTime == 0 {
+				glog.V(3).Infof("Keeping volume %v of service %v for %v seconds", cv.Name, cv.ServiceURL, b.Config.GetVolumeRetention())
+				if err := persistence.ReleaseContainerVolume(b.db, &cvs[ix]); err != nil {
+					glog.Errorf("%v", err)
+				}
+			}
+		}
+	}
+
+	cvs, err := persistence.FindContainerVolumes(b.db, []persistence.ContainerVolumeFilter{persistence.UnarchivedCVFilter(), persistence.NamedCVFilter()})
+	if err != nil {
+		glog.Errorf("Unable to retrieve the named volumes from the local db, error %v", err)
+		return
+	}
+	now := uint64(time.Now().Unix())
+	for ix, cv := range cvs {
+		if cv.ReleaseTime != 0 && now-cv.ReleaseTime >= uint64(b.Config.GetVolumeRetention()) {
+			b.removeNamedVolume(&cvs[ix])
+		}
+	}
+}
+
+// Release the named volumes of the agreements and service instances that are no longer active, e.g. because they were
+// cleaned up while the agent was down.
+func (b *ContainerWorker) releaseLeftoverVolumes(agMap map[string]bool) {
+	leftovers := make([]string, 0)
+	if cvs, err := persistence.FindContainerVolumes(b.db, []persistence.ContainerVolumeFilter{persistence.UnarchivedCVFilter(), persistence.NamedCVFilter()}); err != nil {
+		glog.Errorf("ContainerWorker unable to retrieve the named volumes from database, error %v", err)
+	} else {
+		for _, cv := range cvs {
+			if cv.ReleaseTime != 0 || agMap[cv.AgreementId] || cutil.SliceContains(leftovers, cv.AgreementId) {
+				continue
+			} else if msi, err := persistence.FindMicroserviceInstanceWithKey(b.db, cv.AgreementId); err != nil {
+				glog.Errorf("ContainerWorker unable to retrieve service instance %v from database, error %v", cv.AgreementId, err)
+			} else if msi == nil || msi.Archived {
+				leftovers = append(leftovers, cv.AgreementId)
+			}
+		}
+	}
+	b.releaseNamedVolumes(leftovers)
+}
+
+func (b *ContainerWorker) removeNamedVolume(cv *persistence.ContainerVolume) {
+	if err := b.removeVolume(cv.Name); err != nil {
+		glog.Errorf("Failed to remove volume %v. Error: %v", cv.Name, err)
+	} else if err := persistence.ArchiveContainerVolumes(b.db, cv); err != nil {
+		glog.Errorf("%v", err)
+	} else {
+		glog.V(3).Infof("Volume %v removed.", cv.Name)
+	}
+}
+
+// Remove a volume from the container engine the worker drives.
+func (b *ContainerWorker) removeVolume(name string) error {
+	if b.ctrd != nil {
+		return b.ctrd.RemoveVolume(name)
+	} else if err := b.client.RemoveVolume(name); err != nil && err != docker.ErrNoSuchVolume {
+		return err
+	}
+	return nil
+}
+
 // Given a list of containers on which a parent service is dependent, we need to get a list of dependency service network ids
 // so that they can be added to all of this (parent) service's containers. The dependency containers can be in more than 1 network so
 // we have to carefully choose the networks that the parent container should connect to. Only choose the network
@@ -2201,6 +2797,11 @@ func (b *ContainerWorker) GatherAndCreateDependencyNetworks(dependencyContainers
 		return ms_children_networks
 	}
 
+	// There are no dependency networks with containerd, the parent reaches its dependencies through its hosts file.
+	if b.ctrd != nil {
+		return containerdDependencyHosts(dependencyContainers)
+	}
+
 	var dependencyBaseNetworkName string
 	var nw docker.ContainerNetwork
 	var ok bool
@@ -2210,6 +2811,9 @@ func (b *ContainerWorker) GatherAndCreateDependencyNetworks(dependencyContainers
 		dependencyBaseNetworkName, ok = msc.Labels[LABEL_PREFIX+".agreement_id"]
 		if !ok {
 			continue
+		} else if _, ok = msc.Labels[POD_LABEL]; ok {
+			// the container is in the network namespace of another container of the dependency
+			continue
 		}
 
 		// Search for the network to which the parent service container should be connected. Parent containers are connected
@@ -2236,7 +2840,7 @@ func (b *ContainerWorker) GatherAndCreateDependencyNetworks(dependencyContainers
 			glog.Errorf("failure listing network %v, error %v", nwForParentSvc, err)
 			continue
 		} else if len(nws) == 0 {
-			if newNetwork, err := MakeBridge(b.client, nwForParentSvc, true, false, b.isDevInstance); err != nil {
+			if newNetwork, err := MakeBridge(b.client, b.GetRuntime(), nwForParentSvc, true, false, b.isDevInstance, b.Config.GetServiceNetworkIPv6Prefix()); err != nil {
 				glog.Errorf("Could not create parent specific network %v for service: %v", nwForParentSvc, err)
 				continue
 			} else {
@@ -2303,6 +2907,10 @@ func (b *ContainerWorker) GatherAndCreateDependencyNetworks(dependencyContainers
 // all the containers have to be re-connected.
 func (b *ContainerWorker) restoreDependencyServiceNetworks(networkName string, parentContainers *[]docker.APIContainers) error {
 
+	if b.ctrd != nil {
+		return b.containerdRestoreDependencyHosts(networkName, parentContainers)
+	}
+
 	// Get the list of containers in the service's default network, so that we can move them to parent specific networks.
 	var originalNetwork *docker.Network
 	var serviceContainers []docker.APIContainers
@@ -2343,7 +2951,7 @@ func (b *ContainerWorker) restoreDependencyServiceNetworks(networkName string, p
 		if nws, err := b.client.FilteredListNetworks(docker.NetworkFilterOpts{"name": {nwForParentSvc: true}}); err != nil {
 			return fmt.Errorf("failure listing network %v, error %v", nwForParentSvc, err)
 		} else if len(nws) == 0 {
-			if newNetwork, err := MakeBridge(b.client, nwForParentSvc, true, false, b.isDevInstance); err != nil {
+			if newNetwork, err := MakeBridge(b.client, b.GetRuntime(), nwForParentSvc, true, false, b.isDevInstance, b.Config.GetServiceNetworkIPv6Prefix()); err != nil {
 				return fmt.Errorf("Could not create parent specific network %v for service: %v", nwForParentSvc, err)
 			} else {
 				parentSpecificNetwork = newNetwork
@@ -2392,6 +3000,26 @@ func (b *ContainerWorker) restoreDependencyServiceNetworks(networkName string, p
 func (b *ContainerWorker) ResourcesRemove(agreements []string) error {
 	glog.V(5).Infof("Killing and removing resources in agreements: %v", agreements)
 
+	if !b.IsDevInstance() {
+		for _, agreementId := range agreements {
+			if err := persistence.DeleteResourceReservation(b.db, agreementId); err != nil {
+				glog.Errorf("Unable to delete the resource reservation for %v, error %v", agreementId, err)
+			}
+		}
+	}
+
+	if b.GetMTLSManager() != nil {
+		for _, agreementId := range agreements {
+			if err := b.GetMTLSManager().RemoveCertificate(agreementId); err != nil {
+				glog.Errorf("Failed to remove mTLS certificate for %v, error %v", agreementId, err)
+			}
+		}
+	}
+
+	if b.ctrd != nil {
+		return b.containerdResourcesRemove(agreements)
+	}
+
 	// Remove networks
 	networks, err := b.client.ListNetworks()
 	if err != nil {
@@ -2473,8 +3101,12 @@ func (b *ContainerWorker) ResourcesRemove(agreements []string) error {
 		}
 
 		serviceName := container.Labels[LABEL_PREFIX+".service_name"]
+		if b.exportPath != "" {
+			b.exportContainerVolumes(container, agreementId)
+		}
+
 		// if we made it this far, we're hosing the container
-		if destroyed, err := serviceDestroy(b.client, agreementId, container.ID); err != nil {
+		if destroyed, err := serviceDestroy(b.client, agreementId, container.ID, container.Labels); err != nil {
 			glog.Errorf("Service %v in agreement %v could not be removed. Error: %v", serviceName, agreementId, err)
 		} else if destroyed {
 			glog.V(1).Infof("Service %v in agreement %v stopped and removed", serviceName, agreementId)
@@ -2526,6 +3158,9 @@ func (b *ContainerWorker) ResourcesRemove(agreements []string) error {
 
 	}
 
+	// Remove the named volumes of the agreements, or keep them for the next version of the service.
+	b.releaseNamedVolumes(agreements)
+
 	// gather agreement networks to free
 	for _, net := range networks {
 		for _, agreementId := range agreements {
@@ -2566,6 +3201,9 @@ func (b *ContainerWorker) ResourcesRemove(agreements []string) error {
 		}
 	}
 
+	// The shared network goes away with the last service container.
+	b.releaseServiceDNSNetwork(networks)
+
 	// the primary rule
 	if b.iptables != nil {
 		if exists, err := b.iptables.Exists("filter", IPT_COLONUS_ISOLATED_CHAIN, "-j", "RETURN"); err != nil {
@@ -2599,11 +3237,19 @@ func (b *ContainerWorker) ResourcesRemove(agreements []string) error {
 	return nil
 }
 
+// Returns the containers of the container engine the worker drives. Only the running containers are returned unless all is true.
+func (b *ContainerWorker) listContainers(all bool) ([]docker.APIContainers, error) {
+	if b.ctrd != nil {
+		return b.ctrd.ListContainers(all)
+	}
+	return b.client.ListContainers(docker.ListContainersOptions{All: all})
+}
+
 func (b *ContainerWorker) ContainersMatchingAgreement(agreements []string, includeShared bool, fn func(*docker.APIContainers, string) error) error {
 	var processingErr error
 
 	// get all containers including the inactive ones.
-	containers, err := b.client.ListContainers(docker.ListContainersOptions{All: true})
+	containers, err := b.listContainers(true)
 	if err != nil {
 		glog.Errorf("Unable to get list of running containers: %v", err)
 	} else {
@@ -2668,7 +3314,7 @@ func (b *ContainerWorker) findMicroserviceDefContainerNames(api_spec string, org
 // It only includes the containers with "running" state.
 func (b *ContainerWorker) findDependencyContainersForService(parent *persistence.ServiceInstancePathElement, agreementIds []string, microservices []events.MicroserviceSpec) ([]docker.APIContainers, error) {
 	ms_containers := make([]docker.APIContainers, 0)
-	if containers, err := b.client.ListContainers(docker.ListContainersOptions{}); err != nil {
+	if containers, err := b.listContainers(false); err != nil {
 		return nil, fmt.Errorf("Unable to get list of running containers: %v", err)
 	} else {
 		for _, api_spec := range microservices {
@@ -2777,7 +3423,7 @@ func (b *ContainerWorker) findParentContainersForService(msinst_key string) ([]d
 	glog.V(5).Infof("ContainerWorker Top level services are converted to microservices: %v", top_level_msinsts)
 
 	// Get all the containers on the system and examine them for matches.
-	containers, err := b.client.ListContainers(docker.ListContainersOptions{})
+	containers, err := b.listContainers(false)
 	if err != nil {
 		return nil, fmt.Errorf("Unable to get list of running containers: %v", err)
 	}
@@ -3007,6 +3653,10 @@ func (b *ContainerWorker) ConnectContainerToNetwork(network *docker.Network, con
 func DeleteLeftoverDockerVolumes(db *bolt.DB, config *config.HorizonConfig) error {
 	glog.V(3).Infof("Cleaning up leftover docker volumes created by anax.")
 
+	if config.IsContainerdRuntime() {
+		return deleteLeftoverContainerdVolumes(db, config)
+	}
+
 	if config.Edge.DockerEndpoint == "" {
 		return fmt.Errorf("Docker client cannot be initialized. Please make sure DockerEndpoint is set in the configuration file.")
 	}
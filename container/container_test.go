@@ -7,7 +7,17 @@ import (
 	"encoding/json"
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/persistence"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func Test_UnmarshalNetworkIsolation(t *testing.T) {
@@ -272,3 +282,459 @@ func Test_CheckPermissions_0(t *testing.T) {
 	}
 
 }
+
+func Test_NewContainerRuntime(t *testing.T) {
+
+	if rt, err := NewContainerRuntime(nil, API_SERVER_TYPE_PODMAN); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if rt.Name() != API_SERVER_TYPE_PODMAN || rt.DefaultLogDriver() != LOG_DRIVER_JOURNALD || len(rt.BridgeOptions()) != 0 || len(rt.SecurityOpts()) != 1 {
+		t.Errorf("wrong podman runtime %v", rt)
+	}
+
+	if rt, err := NewContainerRuntime(nil, API_SERVER_TYPE_DOCKER); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if rt.Name() != API_SERVER_TYPE_DOCKER || rt.DefaultLogDriver() != LOG_DRIVER_SYSLOG || len(rt.BridgeOptions()) != 3 || len(rt.SecurityOpts()) != 0 {
+		t.Errorf("wrong docker runtime %v", rt)
+	}
+
+	if rt, err := NewContainerRuntime(nil, API_SERVER_TYPE_CONTAINERD); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if rt.Name() != API_SERVER_TYPE_CONTAINERD || rt.DefaultLogDriver() != "" || len(rt.BridgeOptions()) != 0 || len(rt.SecurityOpts()) != 0 {
+		t.Errorf("wrong containerd runtime %v", rt)
+	}
+
+	if _, err := NewContainerRuntime(nil, "rkt"); err == nil {
+		t.Errorf("should have returned an error for an unsupported runtime")
+	}
+
+	// the runtime cannot be detected without a client
+	if _, err := NewContainerRuntime(nil, ""); err == nil {
+		t.Errorf("should have returned an error without a client")
+	}
+}
+
+func Test_ContainerdHostsFile(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "hosts")
+	if err := writeHostsFile(file, map[string]string{"svc1": "10.89.0.2", "svc2": "10.89.0.3", "svc3": ""}); err != nil {
+		t.Fatalf("should not return error, but got %v", err)
+	}
+
+	if hosts, err := readHostsFile(file); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(hosts) != 2 || hosts["svc1"] != "10.89.0.2" || hosts["svc2"] != "10.89.0.3" {
+		t.Errorf("wrong hosts %v", hosts)
+	}
+}
+
+func Test_ContainerdPortMappings(t *testing.T) {
+
+	bindings := map[docker.Port][]docker.PortBinding{
+		"8080/tcp": []docker.PortBinding{{HostIP: "127.0.0.1", HostPort: "9080"}},
+		"53/udp":   []docker.PortBinding{{HostPort: "5353"}},
+	}
+
+	if mappings, err := portMappings(bindings); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(mappings) != 2 {
+		t.Errorf("wrong number of port mappings %v", mappings)
+	} else {
+		for _, m := range mappings {
+			if m.ContainerPort == 8080 && (m.HostPort != 9080 || m.Protocol != "tcp" || m.HostIP != "127.0.0.1") {
+				t.Errorf("wrong tcp port mapping %v", m)
+			} else if m.ContainerPort == 53 && (m.HostPort != 5353 || m.Protocol != "udp") {
+				t.Errorf("wrong udp port mapping %v", m)
+			}
+		}
+	}
+
+	// a free host port is picked when none is given
+	if mappings, err := portMappings(map[docker.Port][]docker.PortBinding{"80/tcp": []docker.PortBinding{{}}}); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(mappings) != 1 || mappings[0].HostPort == 0 {
+		t.Errorf("wrong port mapping %v", mappings)
+	}
+
+	if _, err := portMappings(map[docker.Port][]docker.PortBinding{"80/tcp": []docker.PortBinding{{HostPort: "abc"}}}); err == nil {
+		t.Errorf("should have returned an error for an invalid host port")
+	}
+}
+
+func Test_imageRegistryHosts(t *testing.T) {
+
+	if hosts := imageRegistryHosts("docker.io/library/nginx:1.25"); len(hosts) != 2 || !hosts["docker.io"] || !hosts["registry-1.docker.io"] {
+		t.Errorf("wrong docker hub registry hosts %v", hosts)
+	} else if hosts := imageRegistryHosts("myregistry.example.com:5000/myorg/gps:1.0"); len(hosts) != 1 || !hosts["myregistry.example.com:5000"] {
+		t.Errorf("wrong registry hosts %v", hosts)
+	} else if hosts := imageRegistryHosts("Not A Ref"); len(hosts) != 0 {
+		t.Errorf("an invalid reference should have no registry hosts, but got %v", hosts)
+	}
+}
+
+func Test_dependencyServiceNames(t *testing.T) {
+
+	containers := []docker.APIContainers{
+		{Labels: map[string]string{LABEL_PREFIX + ".service_name": "gps"}},
+		{Labels: map[string]string{LABEL_PREFIX + ".service_name": "cpu"}},
+		{Labels: map[string]string{LABEL_PREFIX + ".service_name": "gps"}},
+		{Labels: map[string]string{}},
+	}
+
+	if names := dependencyServiceNames(containers); names != "gps,cpu" {
+		t.Errorf("wrong dependency service names %v", names)
+	} else if names := dependencyServiceNames(nil); names != "" {
+		t.Errorf("wrong dependency service names %v", names)
+	}
+}
+
+func Test_freeIPv6Subnet(t *testing.T) {
+
+	subnet, err := freeIPv6Subnet("fd00:4f48::/48", "agreement1", []string{"172.17.0.0/16"})
+	if err != nil {
+		t.Fatalf("should not return error, but got %v", err)
+	} else if !strings.HasPrefix(subnet, "fd00:4f48:") || !strings.HasSuffix(subnet, "::/64") {
+		t.Errorf("wrong subnet %v", subnet)
+	}
+
+	// the same name gets the same subnet, unless it is in use
+	if again, err := freeIPv6Subnet("fd00:4f48::/48", "agreement1", nil); err != nil || again != subnet {
+		t.Errorf("expected subnet %v, got %v %v", subnet, again, err)
+	} else if other, err := freeIPv6Subnet("fd00:4f48::/48", "agreement1", []string{subnet}); err != nil || other == subnet {
+		t.Errorf("expected a subnet other than %v, got %v %v", subnet, other, err)
+	}
+
+	// a /60 holds 16 subnets
+	used := make([]string, 0)
+	for i := 0; i < 16; i++ {
+		if s, err := freeIPv6Subnet("fd00:4f48::/60", "agreement1", used); err != nil {
+			t.Fatalf("should not return error, but got %v", err)
+		} else {
+			used = append(used, s)
+		}
+	}
+	if _, err := freeIPv6Subnet("fd00:4f48::/60", "agreement1", used); err == nil {
+		t.Errorf("should have returned an error when the prefix is full")
+	} else if _, err := freeIPv6Subnet("fd00:4f48::/48", "agreement1", []string{"fd00:4f48::/48"}); err == nil {
+		t.Errorf("should have returned an error when the prefix overlaps a used subnet")
+	} else if _, err := freeIPv6Subnet("2001:db8::/48", "agreement1", nil); err == nil {
+		t.Errorf("should have returned an error for a global prefix")
+	}
+}
+
+func Test_namedVolumeName(t *testing.T) {
+
+	v := containermessage.Volume{Name: "data", Target: "/data"}
+	if name := namedVolumeName(v, "ag1", "myorg/mysvc"); name != "ag1-data" {
+		t.Errorf("wrong volume name %v", name)
+	}
+
+	// a retained volume has the same name for all the agreements of the service
+	v.Retain = containermessage.VOLUME_RETAIN_UPGRADE
+	if name := namedVolumeName(v, "ag1", "myorg/mysvc"); name != namedVolumeName(v, "ag2", "myorg/mysvc") || !strings.HasPrefix(name, "hzn-") || !strings.HasSuffix(name, "-data") {
+		t.Errorf("wrong volume name %v", name)
+	} else if name == namedVolumeName(v, "ag1", "myorg/othersvc") {
+		t.Errorf("the volumes of different services should not have the same name %v", name)
+	}
+}
+
+func Test_deviceIdentity(t *testing.T) {
+
+	event := parseUevent([]byte("add@/devices/pci0000:00/0000:00:14.0/usb1/1-1/1-1:1.0/ttyUSB1/tty/ttyUSB1\x00ACTION=add\x00DEVPATH=/devices/pci0000:00/0000:00:14.0/usb1/1-1/1-1:1.0/ttyUSB1/tty/ttyUSB1\x00SUBSYSTEM=tty\x00MAJOR=188\x00MINOR=1\x00DEVNAME=ttyUSB1\x00SEQNUM=4021\x00"))
+	if event["ACTION"] != "add" || event["DEVNAME"] != "ttyUSB1" || event["MINOR"] != "1" {
+		t.Errorf("wrong event %v", event)
+	}
+
+	// without udev, a device plugged back into the same port has the same identity
+	if id := deviceIdentity(nil, event["DEVPATH"]); id != "sysfs:/devices/pci0000:00/0000:00:14.0/usb1/1-1/1-1:1.0" {
+		t.Errorf("wrong identity %v", id)
+	} else if other := deviceIdentity(nil, "/devices/pci0000:00/0000:00:14.0/usb1/1-1/1-1:1.0/ttyUSB0/tty/ttyUSB0"); other != id {
+		t.Errorf("expected identity %v, got %v", id, other)
+	} else if id := deviceIdentity(nil, "/devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0/video4linux/video0"); id != "sysfs:/devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0/video4linux" {
+		t.Errorf("wrong identity %v", id)
+	}
+
+	// udev identifies the device by its serial number wherever it is plugged in
+	props := parseUdevData([]byte("S:serial/by-id/usb-FTDI_FT232R_A1B2C3-if00-port0\nI:12345\nE:ID_SERIAL=FTDI_FT232R_A1B2C3\nE:ID_USB_INTERFACE_NUM=00\nE:ID_PATH=pci-0000:00:14.0-usb-0:1:1.0\nG:systemd\n"))
+	if id := deviceIdentity(props, event["DEVPATH"]); id != "serial:FTDI_FT232R_A1B2C3:00" {
+		t.Errorf("wrong identity %v", id)
+	}
+	delete(props, "ID_SERIAL")
+	if id := deviceIdentity(props, event["DEVPATH"]); id != "path:pci-0000:00:14.0-usb-0:1:1.0" {
+		t.Errorf("wrong identity %v", id)
+	} else if id := deviceIdentity(nil, ""); id != "" {
+		t.Errorf("expected no identity, got %v", id)
+	}
+}
+
+func Test_reboundDevices(t *testing.T) {
+
+	bindings := []deviceBinding{
+		{Path: "/dev/ttyUSB0", Target: "/dev/ttyUSB0", Node: "/dev/ttyUSB0", Dev: "188:0", ID: "serial:A1B2C3:00"},
+		{Path: "/dev/video0", Target: "/dev/video0", Node: "/dev/video0", Dev: "81:0", ID: "serial:CAM1:"},
+	}
+
+	// the same device under the same node is not rebound, nor is another device
+	if _, changed := reboundDevices(bindings, "serial:A1B2C3:00", "/dev/ttyUSB0", "188:0"); changed {
+		t.Errorf("should not have rebound the device")
+	} else if _, changed := reboundDevices(bindings, "serial:OTHER:00", "/dev/ttyUSB1", "188:1"); changed {
+		t.Errorf("should not have rebound another device")
+	}
+
+	bindings, changed := reboundDevices(bindings, "serial:A1B2C3:00", "/dev/ttyUSB1", "188:1")
+	if !changed {
+		t.Errorf("should have rebound the device")
+	} else if bindings[0].Node != "/dev/ttyUSB1" || bindings[0].Dev != "188:1" || bindings[0].Target != "/dev/ttyUSB0" {
+		t.Errorf("wrong binding %v", bindings[0])
+	} else if bindings[1].Node != "/dev/video0" {
+		t.Errorf("wrong binding %v", bindings[1])
+	} else if path := reboundHostPath(bindings[0]); path != "/dev/ttyUSB1" {
+		t.Errorf("expected the new device node, got %v", path)
+	}
+}
+
+func Test_dockerSecurityOpt(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "seccomp")
+	if err != nil {
+		t.Fatalf("unable to create temp dir, error %v", err)
+	}
+	defer os.RemoveAll(dir)
+	profile := path.Join(dir, "profile.json")
+	if err := ioutil.WriteFile(profile, []byte("{\n  \"defaultAction\": \"SCMP_ACT_ERRNO\",\n  \"syscalls\": []\n}\n"), 0644); err != nil {
+		t.Fatalf("unable to write profile, error %v", err)
+	}
+
+	if opts, err := dockerSecurityOpt(&containermessage.SecurityOpts{SeccompProfile: containermessage.SECURITY_PROFILE_DEFAULT}); err != nil || len(opts) != 0 {
+		t.Errorf("expected no security options, got %v %v", opts, err)
+	} else if opts, err := dockerSecurityOpt(&containermessage.SecurityOpts{SeccompProfile: containermessage.SECURITY_PROFILE_UNCONFINED, ApparmorProfile: "my-profile"}); err != nil || !reflect.DeepEqual(opts, []string{"seccomp=unconfined", "apparmor=my-profile"}) {
+		t.Errorf("wrong security options %v %v", opts, err)
+	} else if opts, err := dockerSecurityOpt(&containermessage.SecurityOpts{SeccompProfile: profile}); err != nil || !reflect.DeepEqual(opts, []string{`seccomp={"defaultAction":"SCMP_ACT_ERRNO","syscalls":[]}`}) {
+		t.Errorf("wrong security options %v %v", opts, err)
+	} else if _, err := dockerSecurityOpt(&containermessage.SecurityOpts{SeccompProfile: path.Join(dir, "missing.json")}); err == nil {
+		t.Errorf("should have returned an error for a missing profile")
+	}
+}
+
+func Test_serviceDNSAliases(t *testing.T) {
+
+	if aliases := serviceDNSAliases(map[string]string{LABEL_PREFIX + ".agreement_id": "ag1"}); len(aliases) != 0 {
+		t.Errorf("expected no aliases, got %v", aliases)
+	} else if aliases := serviceDNSAliases(map[string]string{LABEL_PREFIX + ".service_name": "gps", LABEL_PREFIX + ".variation": ""}); !reflect.DeepEqual(aliases, []string{"gps.horizon"}) {
+		t.Errorf("wrong aliases %v", aliases)
+	} else if aliases := serviceDNSAliases(map[string]string{LABEL_PREFIX + ".service_name": "gps", LABEL_PREFIX + ".variation": "v2"}); !reflect.DeepEqual(aliases, []string{"gps.horizon", "gps-v2.horizon"}) {
+		t.Errorf("wrong aliases %v", aliases)
+	}
+}
+
+func Test_pods(t *testing.T) {
+
+	mode := podNetworkMode("ag1", "app")
+	if mode != "container:ag1-app" {
+		t.Errorf("wrong network mode %v", mode)
+	} else if pod := podContainer(mode); pod != "ag1-app" {
+		t.Errorf("wrong pod container %v", pod)
+	} else if pod := podContainer("ag1"); pod != "" {
+		t.Errorf("wrong pod container %v", pod)
+	}
+
+	started := time.Now()
+	pod := &docker.Container{State: docker.State{Running: true, StartedAt: started}}
+	member := &docker.Container{State: docker.State{Running: true, StartedAt: started.Add(time.Second)}}
+	if isStalePodMember(pod, member) {
+		t.Errorf("container started after the pod should not be stale")
+	}
+	pod.State.StartedAt = started.Add(time.Minute)
+	if !isStalePodMember(pod, member) {
+		t.Errorf("container started before the pod should be stale")
+	}
+	pod.State.Running = false
+	if isStalePodMember(pod, member) {
+		t.Errorf("container should not be restarted while the pod is not running")
+	}
+}
+
+func Test_egressLimit(t *testing.T) {
+
+	if kbps := egressLimit(0, 0); kbps != 0 {
+		t.Errorf("expected no limit, got %v", kbps)
+	} else if kbps := egressLimit(512, 0); kbps != 512 {
+		t.Errorf("expected the limit of the service, got %v", kbps)
+	} else if kbps := egressLimit(0, 1024); kbps != 1024 {
+		t.Errorf("expected the limit of the node, got %v", kbps)
+	} else if kbps := egressLimit(2048, 1024); kbps != 1024 {
+		t.Errorf("expected the limit of the node, got %v", kbps)
+	} else if kbps := egressLimit(512, 1024); kbps != 512 {
+		t.Errorf("expected the limit of the service, got %v", kbps)
+	}
+
+	expected := [][]string{
+		{"qdisc", "replace", "dev", "eth0", "root", "handle", "1:", "htb", "default", "10"},
+		{"class", "replace", "dev", "eth0", "parent", "1:", "classid", "1:10", "htb", "rate", "512kbit", "ceil", "512kbit"},
+	}
+	if cmds := htbCommands("eth0", 512); !reflect.DeepEqual(cmds, expected) {
+		t.Errorf("wrong tc commands %v", cmds)
+	}
+}
+
+func Test_checkpoint(t *testing.T) {
+
+	labels := map[string]string{
+		LABEL_PREFIX + ".deployment_description_hash": "abc123",
+		LABEL_PREFIX + ".service_name":                "db",
+	}
+	if dir := checkpointDir("/var/horizon/service-checkpoints", labels); dir != "/var/horizon/service-checkpoints/abc123-db" {
+		t.Errorf("wrong checkpoint directory %v", dir)
+	}
+	labels[LABEL_PREFIX+".variation"] = "east"
+	if dir := checkpointDir("/var/horizon/service-checkpoints", labels); dir != "/var/horizon/service-checkpoints/abc123-db-east" {
+		t.Errorf("wrong checkpoint directory %v", dir)
+	}
+	if name := checkpointName("sha256:0123456789abcdef"); name != "0123456789abcdef" {
+		t.Errorf("wrong checkpoint name %v", name)
+	}
+
+	var reqPath, reqQuery, reqBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqPath, reqQuery = r.URL.Path, r.URL.RawQuery
+		b, _ := ioutil.ReadAll(r.Body)
+		reqBody = string(b)
+		if strings.HasSuffix(r.URL.Path, "/start") {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"message": "checkpoint is only supported in experimental mode"}`))
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+
+	client, err := docker.NewClient(strings.Replace(server.URL, "http://", "tcp://", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dockerPost(client, "/containers/c1/checkpoints", nil, map[string]interface{}{"CheckpointID": "cp", "Exit": true}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if reqPath != "/containers/c1/checkpoints" || reqBody != `{"CheckpointID":"cp","Exit":true}` {
+		t.Errorf("wrong request %v %v", reqPath, reqBody)
+	}
+	if err := dockerPost(client, "/containers/c1/start", url.Values{"checkpoint": {"cp"}, "checkpoint-dir": {"/tmp/cp"}}, nil); err == nil || !strings.Contains(err.Error(), "experimental mode") {
+		t.Errorf("expected the error of the docker API, got %v", err)
+	} else if reqQuery != "checkpoint=cp&checkpoint-dir=%2Ftmp%2Fcp" {
+		t.Errorf("wrong query %v", reqQuery)
+	}
+}
+
+func Test_exportVolume(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "export-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var reqPath, reqQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqPath, reqQuery = r.URL.Path, r.URL.RawQuery
+		if r.URL.Query().Get("path") == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Could not find the file /missing in container c1"}`))
+		} else {
+			w.Write([]byte("tar content"))
+		}
+	}))
+	defer server.Close()
+
+	client, err := docker.NewClient(strings.Replace(server.URL, "http://", "tcp://", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &ContainerWorker{client: client}
+
+	file := volumeExportFile(dir, "ag1", "myorg/mysvc", "data")
+	if file != path.Join(dir, "ag1", "myorg_mysvc-data.tar") {
+		t.Errorf("wrong export file %v", file)
+	}
+
+	if err := b.exportVolume("c1", "/var/data", file); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if reqPath != "/containers/c1/archive" || reqQuery != "path=%2Fvar%2Fdata" {
+		t.Errorf("wrong request %v %v", reqPath, reqQuery)
+	} else if content, err := ioutil.ReadFile(file); err != nil {
+		t.Errorf("unable to read %v, error %v", file, err)
+	} else if string(content) != "tar content" {
+		t.Errorf("wrong content %v", string(content))
+	}
+
+	missing := volumeExportFile(dir, "ag1", "mysvc", "missing")
+	if err := b.exportVolume("c1", "/missing", missing); err == nil {
+		t.Errorf("expected an error")
+	} else if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Errorf("the partial file %v should be removed, error %v", missing, err)
+	}
+}
+
+func Test_stopLabels(t *testing.T) {
+
+	labels := map[string]string{}
+	if err := setStopLabels(labels, &containermessage.Service{PreStop: []string{"sh", "-c", "redis-cli save"}}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	} else if timeout, preStop := getStopLabels(labels); timeout != containermessage.DEFAULT_STOP_TIMEOUT_S || !reflect.DeepEqual(preStop, []string{"sh", "-c", "redis-cli save"}) {
+		t.Errorf("wrong stop settings %v %v from labels %v", timeout, preStop, labels)
+	}
+
+	// A container without the labels is killed.
+	if timeout, preStop := getStopLabels(map[string]string{}); timeout != 0 || preStop != nil {
+		t.Errorf("wrong stop settings %v %v for a container without labels", timeout, preStop)
+	}
+
+	// The container is sent a SIGTERM and given the stop timeout to exit.
+	var reqPath, reqQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqPath, reqQuery = r.URL.Path, r.URL.RawQuery
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client, err := docker.NewClient(strings.Replace(server.URL, "http://", "tcp://", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gracefulStop(client, "ag1", "c1", 30, nil); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if !strings.HasSuffix(reqPath, "/containers/c1/stop") || (reqQuery != "t=29" && reqQuery != "t=30") {
+		t.Errorf("wrong request %v %v", reqPath, reqQuery)
+	}
+}
+
+func Test_convertDockerStats(t *testing.T) {
+	s := &docker.Stats{}
+	s.CPUStats.CPUUsage.TotalUsage = 3000
+	s.CPUStats.SystemCPUUsage = 20000
+	s.CPUStats.OnlineCPUs = 2
+	s.PreCPUStats.CPUUsage.TotalUsage = 1000
+	s.PreCPUStats.SystemCPUUsage = 10000
+	s.MemoryStats.Usage = 5000
+	s.MemoryStats.Limit = 10000
+	s.MemoryStats.Stats.InactiveFile = 1000
+	s.Networks = map[string]docker.NetworkStats{"eth0": {RxBytes: 10, TxBytes: 20}, "eth1": {RxBytes: 1, TxBytes: 2}}
+	s.BlkioStats.IOServiceBytesRecursive = []docker.BlkioStatsEntry{{Op: "Read", Value: 100}, {Op: "write", Value: 200}, {Op: "Total", Value: 300}}
+
+	stats := convertDockerStats("/agid-svc", s)
+	expected := persistence.ContainerStats{Name: "/agid-svc", CPUPercent: 40, MemoryUsage: 4000, MemoryLimit: 10000, NetworkRxBytes: 11, NetworkTxBytes: 22, BlockReadBytes: 100, BlockWriteBytes: 200}
+	if stats != expected {
+		t.Errorf("expected %v, got %v", expected, stats)
+	}
+
+	// the first sample has no previous CPU usage
+	s.PreCPUStats.SystemCPUUsage = 0
+	s.PreCPUStats.CPUUsage.TotalUsage = 0
+	s.CPUStats.CPUUsage.TotalUsage = 0
+	if stats := convertDockerStats("/agid-svc", s); stats.CPUPercent != 0 {
+		t.Errorf("expected no CPU usage, got %v", stats.CPUPercent)
+	}
+}
@@ -0,0 +1,1384 @@
+package container
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/contrib/nvidia"
+	"github.com/containerd/containerd/contrib/seccomp"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/containerd/oci"
+	"github.com/containerd/containerd/pkg/snapshotters"
+	refdocker "github.com/containerd/containerd/reference/docker"
+	ctrdremotes "github.com/containerd/containerd/remotes/docker"
+	gocni "github.com/containerd/go-cni"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/persistence"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+const (
+	// The name of the CNI network the containerd containers are attached to. If the CNI configuration directory does
+	// not have a <name>.conflist file, the default network below is used.
+	CONTAINERD_NETWORK_NAME = "horizon"
+
+	// The number of seconds a container is given to stop before it is killed
+	CONTAINERD_STOP_TIMEOUT_S = 10
+
+	// The labels on the containerd containers holding the IP address of the container and, for shared containers, the
+	// agreements using the container
+	containerdIPLabel          = LABEL_PREFIX + ".ip"
+	containerdSharedUsersLabel = LABEL_PREFIX + ".shared_users"
+)
+
+// The bridge network used when no horizon network is configured in the CNI configuration directory.
+const defaultCNIConfList = `{
+  "cniVersion": "0.4.0",
+  "name": "horizon",
+  "plugins": [
+    {
+      "type": "bridge",
+      "bridge": "hzn0",
+      "isGateway": true,
+      "ipMasq": true,
+      "hairpinMode": true,
+      "ipam": {
+        "type": "host-local",
+        "ranges": [[{"subnet": "10.89.0.0/16"}]],
+        "routes": [{"dst": "0.0.0.0/0"}]
+      }
+    },
+    {
+      "type": "portmap",
+      "capabilities": {"portMappings": true}
+    }
+  ]
+}`
+
+// ContainerdBackend runs the service containers through containerd directly, for devices on which running dockerd is not
+// desirable. The images and containers live in their own containerd namespace. The containers are attached to a single
+// CNI network and the services reach each other by name through the hosts file of each container, instead of through the
+// per agreement docker networks.
+type ContainerdBackend struct {
+	client      *containerd.Client
+	namespace   string
+	stateDir    string
+	snapshotter string
+	network     gocni.CNI
+}
+
+func NewContainerdBackend(cfg *config.HorizonConfig) (*ContainerdBackend, error) {
+	client, err := containerd.New(cfg.GetContainerdAddress(), containerd.WithDefaultNamespace(cfg.GetContainerdNamespace()))
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to containerd at %v, error %v", cfg.GetContainerdAddress(), err)
+	}
+
+	b := &ContainerdBackend{
+		client:      client,
+		namespace:   cfg.GetContainerdNamespace(),
+		stateDir:    cfg.GetContainerdStateDir(),
+		snapshotter: cfg.Edge.ContainerdSnapshotter,
+	}
+	for _, dir := range []string{b.logDir(), b.hostsDir(), b.volumeDir()} {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			return nil, fmt.Errorf("unable to create containerd state directory %v, error %v", dir, err)
+		}
+	}
+
+	if b.network, err = gocni.New(gocni.WithMinNetworkCount(2), gocni.WithPluginConfDir(cfg.GetCNIConfDir()), gocni.WithPluginDir([]string{cfg.GetCNIPluginDir()})); err != nil {
+		return nil, fmt.Errorf("unable to initialize CNI, error %v", err)
+	}
+
+	// The horizon network is loaded first so that it is the eth0 interface of the containers.
+	netConf := gocni.WithConfListBytes([]byte(defaultCNIConfList))
+	confFile := path.Join(cfg.GetCNIConfDir(), CONTAINERD_NETWORK_NAME+".conflist")
+	if _, err := os.Stat(confFile); err == nil {
+		netConf = gocni.WithConfListFile(confFile)
+	}
+	if err := b.network.Load(netConf, gocni.WithLoNetwork); err != nil {
+		return nil, fmt.Errorf("unable to load the %v CNI network, error %v", CONTAINERD_NETWORK_NAME, err)
+	}
+
+	glog.V(3).Infof("Using containerd at %v with namespace %v.", cfg.GetContainerdAddress(), b.namespace)
+	return b, nil
+}
+
+func (b *ContainerdBackend) ctx() context.Context {
+	return namespaces.WithNamespace(context.Background(), b.namespace)
+}
+
+func (b *ContainerdBackend) logDir() string {
+	return path.Join(b.stateDir, "logs")
+}
+
+func (b *ContainerdBackend) hostsDir() string {
+	return path.Join(b.stateDir, "hosts")
+}
+
+func (b *ContainerdBackend) volumeDir() string {
+	return containerdVolumeDir(b.stateDir)
+}
+
+// The named volumes of the services are directories in the volumes directory of the containerd state directory.
+func containerdVolumeDir(stateDir string) string {
+	return path.Join(stateDir, "volumes")
+}
+
+// The log file of a container. The output of the container is appended to it.
+func (b *ContainerdBackend) LogPath(id string) string {
+	return path.Join(b.logDir(), id+".log")
+}
+
+func (b *ContainerdBackend) hostsPath(id string) string {
+	return path.Join(b.hostsDir(), id)
+}
+
+// Returns the fully qualified reference of the image, which is how containerd names images.
+func normalizeImageRef(image string) (string, error) {
+	named, err := refdocker.ParseDockerRef(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image name %v, error %v", image, err)
+	}
+	return named.String(), nil
+}
+
+// Returns the hosts of the registry of a normalized image reference. The images of docker hub are pulled from its
+// registry host.
+func imageRegistryHosts(ref string) map[string]bool {
+	hosts := map[string]bool{}
+	if named, err := refdocker.ParseDockerRef(ref); err == nil {
+		domain := refdocker.Domain(named)
+		hosts[domain] = true
+		if domain == "docker.io" {
+			hosts["registry-1.docker.io"] = true
+		}
+	}
+	return hosts
+}
+
+// PullImage pulls and unpacks the image with the given credentials. An empty auth pulls the image anonymously. Only the
+// layers that are not already on the node are downloaded. With a lazy pulling snapshotter, such as stargz, the eStargz
+// and zstd:chunked layers are not downloaded either, the snapshotter fetches the files the service reads with range
+// requests to the registry.
+func (b *ContainerdBackend) PullImage(image string, auth docker.AuthConfiguration) (*events.ImagePullStats, error) {
+	ref, err := normalizeImageRef(image)
+	if err != nil {
+		return nil, err
+	}
+
+	// The credentials are only given to the registry of the image, not to any other host the pull is redirected to.
+	registryHosts := imageRegistryHosts(ref)
+	authorizer := ctrdremotes.NewDockerAuthorizer(ctrdremotes.WithAuthCreds(func(host string) (string, string, error) {
+		if !registryHosts[host] {
+			return "", "", nil
+		}
+		return auth.Username, auth.Password, nil
+	}))
+	resolver := ctrdremotes.NewResolver(ctrdremotes.ResolverOptions{
+		Hosts: ctrdremotes.ConfigureDefaultRegistries(ctrdremotes.WithAuthorizer(authorizer)),
+	})
+
+	ctx := b.ctx()
+	layers := newPullLayers(b.client.ContentStore())
+	wrapper := layers.handlerWrapper
+	opts := []containerd.RemoteOpt{containerd.WithPullUnpack, containerd.WithResolver(resolver)}
+	if b.snapshotter != "" {
+		// The snapshotter is given the image and layer digests, which a remote snapshotter needs to find the layers in the registry.
+		appendInfo := snapshotters.AppendInfoHandlerWrapper(ref)
+		wrapper = func(h images.Handler) images.Handler {
+			return layers.handlerWrapper(appendInfo(h))
+		}
+		opts = append(opts, containerd.WithPullSnapshotter(b.snapshotter))
+	}
+	opts = append(opts, containerd.WithImageHandlerWrapper(wrapper))
+
+	if _, err := b.client.Pull(ctx, ref, opts...); err != nil {
+		return nil, fmt.Errorf("unable to pull image %v, error %v", ref, err)
+	}
+	return layers.stats(ctx), nil
+}
+
+// The layers of an image pull. The manifest tells all the layers of the image, the layers that need their content are
+// passed to the handler, unless the snapshotter already has them unpacked or fetches them lazily.
+type pullLayers struct {
+	store    content.Store
+	lock     sync.Mutex
+	manifest map[digest.Digest]int64 // the size of each layer of the image
+	fetched  map[digest.Digest]bool  // the layers passed to the handler, and whether their content was already there
+}
+
+func newPullLayers(store content.Store) *pullLayers {
+	return &pullLayers{
+		store:    store,
+		manifest: make(map[digest.Digest]int64),
+		fetched:  make(map[digest.Digest]bool),
+	}
+}
+
+func (p *pullLayers) handlerWrapper(h images.Handler) images.Handler {
+	return images.HandlerFunc(func(ctx context.Context, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+		if images.IsLayerType(desc.MediaType) {
+			_, err := p.store.Info(ctx, desc.Digest)
+			p.lock.Lock()
+			p.fetched[desc.Digest] = err == nil
+			p.lock.Unlock()
+		}
+
+		children, err := h.Handle(ctx, desc)
+		if err == nil && images.IsManifestType(desc.MediaType) {
+			p.lock.Lock()
+			for _, child := range children {
+				if images.IsLayerType(child.MediaType) {
+					p.manifest[child.Digest] = child.Size
+				}
+			}
+			p.lock.Unlock()
+		}
+		return children, err
+	})
+}
+
+// Returns the stats of the pull once it is done. A layer that was not passed to the handler was either already on the
+// node, when its content is in the content store, or is fetched lazily.
+func (p *pullLayers) stats(ctx context.Context) *events.ImagePullStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	stats := &events.ImagePullStats{Layers: len(p.manifest)}
+	for dgst, size := range p.manifest {
+		if existed, ok := p.fetched[dgst]; ok && !existed {
+			stats.DownloadedBytes += size
+			continue
+		} else if ok {
+			stats.ReusedLayers++
+		} else if _, err := p.store.Info(ctx, dgst); err == nil {
+			stats.ReusedLayers++
+		} else {
+			stats.LazyLayers++
+		}
+		stats.SavedBytes += size
+	}
+	return stats
+}
+
+// HasImage returns an error if the image is not in the containerd namespace of the agent.
+func (b *ContainerdBackend) HasImage(image string) error {
+	ref, err := normalizeImageRef(image)
+	if err != nil {
+		return err
+	}
+	_, err = b.client.GetImage(b.ctx(), ref)
+	return err
+}
+
+// PinImage returns the reference of a pulled image by the digest of its manifest, so that the container is created from
+// the image that was pulled even if its tag is moved to another image later. The image is also named by the digest in
+// the containerd namespace of the agent.
+func (b *ContainerdBackend) PinImage(image string) (string, error) {
+	named, err := refdocker.ParseDockerRef(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image name %v, error %v", image, err)
+	}
+
+	ctx := b.ctx()
+	img, err := b.client.ImageService().Get(ctx, named.String())
+	if err != nil {
+		return "", err
+	}
+
+	pinned := refdocker.TrimNamed(named).String() + "@" + img.Target.Digest.String()
+	if pinned == img.Name {
+		return pinned, nil
+	} else if _, err := b.client.ImageService().Get(ctx, pinned); err == nil {
+		return pinned, nil
+	} else if !errdefs.IsNotFound(err) {
+		return "", err
+	}
+
+	if _, err := b.client.ImageService().Create(ctx, images.Image{Name: pinned, Target: img.Target}); err != nil && !errdefs.IsAlreadyExists(err) {
+		return "", err
+	}
+	return pinned, nil
+}
+
+// ListContainers returns the containers of the agent in the docker API form, so that the container worker can match
+// them the same way as the docker containers. Only the running containers are returned unless all is true.
+func (b *ContainerdBackend) ListContainers(all bool) ([]docker.APIContainers, error) {
+	ctx := b.ctx()
+	cs, err := b.client.Containers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]docker.APIContainers, 0, len(cs))
+	for _, c := range cs {
+		info, err := c.Info(ctx, containerd.WithoutRefreshedMetadata)
+		if err != nil {
+			if errdefs.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		state := "created"
+		if task, err := c.Task(ctx, nil); err == nil {
+			if status, err := task.Status(ctx); err == nil {
+				state = string(status.Status)
+			}
+		}
+		if !all && state != string(containerd.Running) {
+			continue
+		}
+
+		apiContainer := docker.APIContainers{
+			ID:       c.ID(),
+			Image:    info.Image,
+			Names:    []string{"/" + c.ID()},
+			Labels:   info.Labels,
+			State:    state,
+			Created:  info.CreatedAt.Unix(),
+			Networks: docker.NetworkList{Networks: map[string]docker.ContainerNetwork{}},
+		}
+		if ip := info.Labels[containerdIPLabel]; ip != "" {
+			apiContainer.Networks.Networks[CONTAINERD_NETWORK_NAME] = docker.ContainerNetwork{IPAddress: ip}
+		}
+		ret = append(ret, apiContainer)
+	}
+	return ret, nil
+}
+
+// ListContainerdContainers returns the containers containerd runs for the agent, for the callers that do not hold a
+// container worker.
+func ListContainerdContainers(cfg *config.HorizonConfig, all bool) ([]docker.APIContainers, error) {
+	client, err := containerd.New(cfg.GetContainerdAddress(), containerd.WithDefaultNamespace(cfg.GetContainerdNamespace()))
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to containerd at %v, error %v", cfg.GetContainerdAddress(), err)
+	}
+	defer client.Close()
+
+	b := &ContainerdBackend{client: client, namespace: cfg.GetContainerdNamespace()}
+	return b.ListContainers(all)
+}
+
+// StartService creates and starts a container from the given service config. The hosts are the names, and their IP
+// address, that the service can reach. It returns the IP address of the container, which is empty for a container in
+// the host network.
+func (b *ContainerdBackend) StartService(id string, serviceName string, serviceConfig *persistence.ServiceConfig, hosts map[string]string) (string, error) {
+	ctx := b.ctx()
+
+	ref, err := normalizeImageRef(serviceConfig.Config.Image)
+	if err != nil {
+		return "", err
+	}
+	image, err := b.client.GetImage(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("unable to find image %v, error %v", ref, err)
+	}
+
+	hostNetwork := serviceConfig.HostConfig.NetworkMode == "host"
+	pod := podContainer(serviceConfig.HostConfig.NetworkMode)
+	if !hostNetwork && pod == "" {
+		// The file is mounted before the IP address of the container is known, it is filled in before the container starts.
+		if err := os.WriteFile(b.hostsPath(id), []byte{}, 0644); err != nil {
+			return "", err
+		}
+	}
+
+	specOpts, err := b.specOpts(ctx, image, id, serviceName, serviceConfig, hostNetwork)
+	if err != nil {
+		return "", err
+	}
+
+	glog.V(5).Infof("Creating containerd container %v with config: %v, host config: %v", id, serviceConfig.Config, serviceConfig.HostConfig)
+	containerOpts := []containerd.NewContainerOpts{containerd.WithImage(image)}
+	if b.snapshotter != "" {
+		containerOpts = append(containerOpts, containerd.WithSnapshotter(b.snapshotter))
+	}
+	containerOpts = append(containerOpts,
+		containerd.WithNewSnapshot(id+"-snapshot", image),
+		containerd.WithNewSpec(specOpts...),
+		containerd.WithContainerLabels(serviceConfig.Config.Labels))
+	c, err := b.client.NewContainer(ctx, id, containerOpts...)
+	if err != nil {
+		if errdefs.IsAlreadyExists(err) {
+			return "", docker.ErrContainerAlreadyExists
+		}
+		return "", fmt.Errorf("unable to create container %v, error %v", id, err)
+	}
+
+	ip, err := b.startTask(ctx, c, serviceName, serviceConfig, hosts, !hostNetwork && pod == "")
+	if err == nil && pod != "" {
+		// the container has the address of the container of the pod
+		_, ip, err = b.podNetwork(ctx, pod)
+	}
+	if err != nil {
+		if rErr := b.RemoveContainer(id); rErr != nil {
+			glog.Errorf("Unable to remove container %v after it failed to start, error %v", id, rErr)
+		}
+		return "", err
+	}
+
+	glog.V(3).Infof("Successfully started containerd container %v with IP address %v", id, ip)
+	return ip, nil
+}
+
+// Create the task of the container, attach it to the network and start it. The container is not attached when it uses
+// the host network or the network of another container.
+func (b *ContainerdBackend) startTask(ctx context.Context, c containerd.Container, serviceName string, serviceConfig *persistence.ServiceConfig, hosts map[string]string, attach bool) (string, error) {
+	task, err := c.NewTask(ctx, cio.LogFile(b.LogPath(c.ID())))
+	if err != nil {
+		return "", fmt.Errorf("unable to create task for container %v, error %v", c.ID(), err)
+	}
+
+	ip := ""
+	if attach {
+		ports, err := portMappings(serviceConfig.HostConfig.PortBindings)
+		if err != nil {
+			return "", err
+		}
+
+		result, err := b.network.Setup(ctx, c.ID(), netnsPath(task.Pid()), gocni.WithCapabilityPortMap(ports))
+		if err != nil {
+			return "", fmt.Errorf("unable to attach container %v to the %v network, error %v", c.ID(), CONTAINERD_NETWORK_NAME, err)
+		}
+		if iface, ok := result.Interfaces["eth0"]; ok && len(iface.IPConfigs) != 0 {
+			ip = iface.IPConfigs[0].IP.String()
+		}
+		if _, err := c.SetLabels(ctx, map[string]string{containerdIPLabel: ip}); err != nil {
+			return "", err
+		}
+
+		containerHosts := map[string]string{c.ID(): ip, serviceName: ip}
+		for name, hostIP := range hosts {
+			containerHosts[name] = hostIP
+		}
+		if err := writeHostsFile(b.hostsPath(c.ID()), containerHosts); err != nil {
+			return "", err
+		}
+	}
+
+	if err := task.Start(ctx); err != nil {
+		return "", fmt.Errorf("unable to start container %v, error %v", c.ID(), err)
+	}
+	return ip, nil
+}
+
+// StartSharedService starts a container that is shared by the agreements using the service, or adds the agreement to the
+// users of the container if it is already running. It returns the IP address of the container.
+func (b *ContainerdBackend) StartSharedService(id string, agreementId string, serviceName string, serviceConfig *persistence.ServiceConfig, hosts map[string]string) (string, error) {
+	ctx := b.ctx()
+
+	if c, err := b.client.LoadContainer(ctx, id); err == nil {
+		if isRunning(ctx, c) {
+			info, err := c.Info(ctx)
+			if err != nil {
+				return "", err
+			}
+			users := sharedUsers(info.Labels)
+			if !stringSliceContains(users, agreementId) {
+				users = append(users, agreementId)
+			}
+			if _, err := c.SetLabels(ctx, map[string]string{containerdSharedUsersLabel: strings.Join(users, ",")}); err != nil {
+				return "", err
+			}
+			glog.V(3).Infof("Using existing shared container %v for agreement %v", id, agreementId)
+			return info.Labels[containerdIPLabel], nil
+		}
+
+		// the shared container is not running, create it again
+		if err := b.RemoveContainer(id); err != nil {
+			return "", err
+		}
+	} else if !errdefs.IsNotFound(err) {
+		return "", err
+	}
+
+	serviceConfig.Config.Labels[containerdSharedUsersLabel] = agreementId
+	return b.StartService(id, serviceName, serviceConfig, hosts)
+}
+
+// RemoveContainer stops the container, detaches it from the network and deletes it with its snapshot.
+func (b *ContainerdBackend) RemoveContainer(id string) error {
+	ctx := b.ctx()
+
+	c, err := b.client.LoadContainer(ctx, id)
+	if errdefs.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	info, err := c.Info(ctx)
+	if err != nil {
+		return err
+	}
+
+	netns := ""
+	if task, err := c.Task(ctx, nil); err == nil {
+		if isRunning(ctx, c) {
+			netns = netnsPath(task.Pid())
+		}
+		if info.Labels[containerdIPLabel] != "" {
+			if err := b.network.Remove(ctx, id, netns); err != nil {
+				glog.Errorf("Unable to detach container %v from the %v network, error %v", id, CONTAINERD_NETWORK_NAME, err)
+			}
+		}
+		if err := stopTask(ctx, task); err != nil {
+			glog.Warningf("Unable to stop container %v, error %v. Will try to forcefully remove it.", id, err)
+		}
+		if _, err := task.Delete(ctx, containerd.WithProcessKill); err != nil && !errdefs.IsNotFound(err) {
+			return fmt.Errorf("unable to delete task of container %v, error %v", id, err)
+		}
+	} else if !errdefs.IsNotFound(err) {
+		return err
+	} else if info.Labels[containerdIPLabel] != "" {
+		// release the IP address of a container whose task is gone
+		if err := b.network.Remove(ctx, id, ""); err != nil {
+			glog.Errorf("Unable to detach container %v from the %v network, error %v", id, CONTAINERD_NETWORK_NAME, err)
+		}
+	}
+
+	if err := c.Delete(ctx, containerd.WithSnapshotCleanup); err != nil && !errdefs.IsNotFound(err) {
+		return fmt.Errorf("unable to delete container %v, error %v", id, err)
+	}
+
+	for _, file := range []string{b.hostsPath(id), b.LogPath(id)} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			glog.Warningf("Unable to remove %v, error %v", file, err)
+		}
+	}
+
+	glog.V(3).Infof("Removed containerd container %v", id)
+	return nil
+}
+
+// RemoveAgreements removes the containers of the given agreements or service instances. A shared container is removed
+// when the last agreement using it is removed.
+func (b *ContainerdBackend) RemoveAgreements(agreements []string) error {
+	ctx := b.ctx()
+
+	cs, err := b.ListContainers(true)
+	if err != nil {
+		return fmt.Errorf("unable to list containers, error %v", err)
+	}
+
+	var lastErr error
+	for _, c := range cs {
+		if agId, ok := c.Labels[LABEL_PREFIX+".agreement_id"]; ok {
+			if !stringSliceContains(agreements, agId) {
+				continue
+			}
+		} else if c.Labels[LABEL_PREFIX+".service_pattern.shared"] == "singleton" {
+			users := []string{}
+			for _, user := range sharedUsers(c.Labels) {
+				if !stringSliceContains(agreements, user) {
+					users = append(users, user)
+				}
+			}
+			if len(users) != 0 {
+				if sc, err := b.client.LoadContainer(ctx, c.ID); err != nil {
+					lastErr = err
+				} else if _, err := sc.SetLabels(ctx, map[string]string{containerdSharedUsersLabel: strings.Join(users, ",")}); err != nil {
+					lastErr = err
+				}
+				continue
+			}
+		} else {
+			continue
+		}
+
+		if err := b.RemoveContainer(c.ID); err != nil {
+			glog.Errorf("Unable to remove container %v, error %v", c.ID, err)
+			lastErr = err
+		}
+	}
+
+	// Remove the volumes that hold the storage of the agreements
+	for _, agreementId := range agreements {
+		if err := os.RemoveAll(path.Join(b.volumeDir(), agreementId)); err != nil {
+			glog.Errorf("Unable to remove volume %v, error %v", agreementId, err)
+		}
+	}
+
+	return lastErr
+}
+
+// CreateVolume creates the directory of a named volume. It returns false if the volume already exists.
+func (b *ContainerdBackend) CreateVolume(name string) (bool, error) {
+	volume := path.Join(b.volumeDir(), name)
+	if _, err := os.Stat(volume); err == nil {
+		return false, nil
+	}
+	if err := os.MkdirAll(volume, 0755); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RemoveVolume removes the directory of a named volume.
+func (b *ContainerdBackend) RemoveVolume(name string) error {
+	return os.RemoveAll(path.Join(b.volumeDir(), name))
+}
+
+// UpdateHosts adds or replaces the given names in the hosts file of the container.
+func (b *ContainerdBackend) UpdateHosts(id string, hosts map[string]string) error {
+	current, err := readHostsFile(b.hostsPath(id))
+	if err != nil {
+		return err
+	}
+	for name, ip := range hosts {
+		current[name] = ip
+	}
+	return writeHostsFile(b.hostsPath(id), current)
+}
+
+// Build the OCI spec of the container from the service config the container worker builds for docker.
+func (b *ContainerdBackend) specOpts(ctx context.Context, image containerd.Image, id string, serviceName string, serviceConfig *persistence.ServiceConfig, hostNetwork bool) ([]oci.SpecOpts, error) {
+	cfg := serviceConfig.Config
+	hostCfg := serviceConfig.HostConfig
+
+	opts := []oci.SpecOpts{oci.WithImageConfig(image)}
+
+	// Docker semantics, the entrypoint of the service replaces the entrypoint of the image and its command.
+	if len(cfg.Entrypoint) != 0 || len(cfg.Cmd) != 0 {
+		imageConfig, err := imageRuntimeConfig(ctx, image)
+		if err != nil {
+			return nil, err
+		}
+		entrypoint := imageConfig.Entrypoint
+		cmd := imageConfig.Cmd
+		if len(cfg.Entrypoint) != 0 {
+			entrypoint = cfg.Entrypoint
+			cmd = nil
+		}
+		if len(cfg.Cmd) != 0 {
+			cmd = cfg.Cmd
+		}
+		opts = append(opts, oci.WithProcessArgs(append(append([]string{}, entrypoint...), cmd...)...))
+	}
+
+	opts = append(opts, oci.WithEnv(cfg.Env), oci.WithHostname(serviceName))
+	if cfg.User != "" {
+		opts = append(opts, oci.WithUser(cfg.User))
+	}
+	if len(hostCfg.GroupAdd) != 0 {
+		opts = append(opts, withAdditionalGIDs(hostCfg.GroupAdd))
+	}
+
+	mounts, err := b.mounts(hostCfg.Binds, hostCfg.Tmpfs)
+	if err != nil {
+		return nil, err
+	}
+
+	if hostNetwork {
+		opts = append(opts, oci.WithHostNamespace(specs.NetworkNamespace), oci.WithHostHostsFile, oci.WithHostResolvconf)
+	} else if pod := podContainer(hostCfg.NetworkMode); pod != "" {
+		// join the network namespace of the container of the pod, with the same hosts
+		netns, _, err := b.podNetwork(ctx, pod)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, oci.WithLinuxNamespace(specs.LinuxNamespace{Type: specs.NetworkNamespace, Path: netns}), oci.WithHostResolvconf)
+		mounts = append(mounts, specs.Mount{Destination: "/etc/hosts", Type: "bind", Source: b.hostsPath(pod), Options: []string{"rbind", "ro"}})
+	} else {
+		opts = append(opts, oci.WithHostResolvconf)
+		mounts = append(mounts, specs.Mount{Destination: "/etc/hosts", Type: "bind", Source: b.hostsPath(id), Options: []string{"rbind", "ro"}})
+	}
+	opts = append(opts, oci.WithMounts(mounts))
+
+	if hostCfg.PidMode == "host" {
+		opts = append(opts, oci.WithHostNamespace(specs.PIDNamespace))
+	}
+
+	if hostCfg.ReadonlyRootfs {
+		opts = append(opts, oci.WithRootFSReadonly())
+	}
+
+	if hostCfg.Privileged {
+		opts = append(opts, oci.WithPrivileged, oci.WithAllDevicesAllowed, oci.WithHostDevices)
+	} else {
+		// Docker semantics, the capabilities are dropped before the added ones are added.
+		if len(hostCfg.CapDrop) != 0 {
+			opts = append(opts, withDroppedCapabilities(hostCfg.CapDrop))
+		}
+		if len(hostCfg.CapAdd) != 0 {
+			caps := make([]string, 0, len(hostCfg.CapAdd))
+			for _, capability := range hostCfg.CapAdd {
+				caps = append(caps, containermessage.NormalizeCapability(capability))
+			}
+			opts = append(opts, oci.WithAddedCapabilities(caps))
+		}
+
+		secOpts, err := securityOptSpecOpts(hostCfg.SecurityOpt)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, secOpts...)
+	}
+
+	for _, device := range hostCfg.Devices {
+		opts = append(opts, oci.WithDevices(device.PathOnHost, device.PathInContainer, device.CgroupPermissions))
+	}
+
+	for _, req := range hostCfg.DeviceRequests {
+		opts = append(opts, nvidia.WithGPUs(gpuOpts(req)...))
+	}
+
+	if hostCfg.Memory > 0 {
+		opts = append(opts, oci.WithMemoryLimit(uint64(hostCfg.Memory)))
+	}
+	if hostCfg.MemorySwap > 0 {
+		opts = append(opts, withMemorySwap(hostCfg.MemorySwap))
+	}
+	if hostCfg.NanoCPUs > 0 {
+		period := uint64(100000)
+		opts = append(opts, oci.WithCPUCFS(hostCfg.NanoCPUs*int64(period)/1000000000, period))
+	}
+	if cfg.CPUSet != "" {
+		opts = append(opts, withCPUSet(cfg.CPUSet))
+	}
+	if len(hostCfg.Sysctls) != 0 {
+		opts = append(opts, withSysctls(hostCfg.Sysctls))
+	}
+
+	return opts, nil
+}
+
+// Convert the docker binds and tmpfs into mounts. A bind from a named volume is a bind from a directory in the volume
+// directory of the agent.
+func (b *ContainerdBackend) mounts(binds []string, tmpfs map[string]string) ([]specs.Mount, error) {
+	mounts := []specs.Mount{}
+	for _, bind := range binds {
+		pieces := strings.Split(bind, ":")
+		if len(pieces) < 2 {
+			return nil, fmt.Errorf("invalid bind %v", bind)
+		}
+
+		source := pieces[0]
+		if !filepath.IsAbs(source) {
+			source = path.Join(b.volumeDir(), source)
+		}
+		// docker creates the missing source directories of the binds
+		if _, err := os.Stat(source); os.IsNotExist(err) {
+			if err := os.MkdirAll(source, 0755); err != nil {
+				return nil, fmt.Errorf("unable to create bind source %v, error %v", source, err)
+			}
+		}
+
+		options := []string{"rbind", "rw"}
+		if len(pieces) > 2 && strings.Contains(pieces[2], "ro") {
+			options = []string{"rbind", "ro"}
+		}
+		mounts = append(mounts, specs.Mount{Destination: pieces[1], Type: "bind", Source: source, Options: options})
+	}
+
+	for destination, tmpfsOptions := range tmpfs {
+		options := []string{"nosuid", "nodev", "noexec"}
+		if tmpfsOptions != "" {
+			options = append(options, strings.Split(tmpfsOptions, ",")...)
+		}
+		mounts = append(mounts, specs.Mount{Destination: destination, Type: "tmpfs", Source: "tmpfs", Options: options})
+	}
+	return mounts, nil
+}
+
+// Convert a docker GPU device request into the options of the nvidia container hook.
+func gpuOpts(req docker.DeviceRequest) []nvidia.Opts {
+	opts := []nvidia.Opts{}
+	if len(req.DeviceIDs) != 0 {
+		for _, id := range req.DeviceIDs {
+			if index, err := strconv.Atoi(id); err == nil {
+				opts = append(opts, nvidia.WithDevices(index))
+			} else {
+				opts = append(opts, nvidia.WithDeviceUUIDs(id))
+			}
+		}
+	} else if req.Count < 0 {
+		opts = append(opts, nvidia.WithAllDevices)
+	} else {
+		for index := 0; index < req.Count; index++ {
+			opts = append(opts, nvidia.WithDevices(index))
+		}
+	}
+
+	caps := []nvidia.Capability{}
+	for _, capSet := range req.Capabilities {
+		for _, c := range capSet {
+			if c == "all" {
+				opts = append(opts, nvidia.WithAllCapabilities)
+			} else if c != "gpu" {
+				caps = append(caps, nvidia.Capability(c))
+			}
+		}
+	}
+	if len(caps) != 0 {
+		opts = append(opts, nvidia.WithCapabilities(caps...))
+	}
+	return opts
+}
+
+// Read the entrypoint and command from the config of the image.
+func imageRuntimeConfig(ctx context.Context, image containerd.Image) (*ocispec.ImageConfig, error) {
+	desc, err := image.Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := content.ReadBlob(ctx, image.ContentStore(), desc)
+	if err != nil {
+		return nil, err
+	}
+	var imageSpec ocispec.Image
+	if err := json.Unmarshal(blob, &imageSpec); err != nil {
+		return nil, err
+	}
+	return &imageSpec.Config, nil
+}
+
+// Convert the docker port bindings into CNI port mappings. A binding without a host port gets a free port of the host,
+// which is what docker does.
+func portMappings(bindings map[docker.Port][]docker.PortBinding) ([]gocni.PortMapping, error) {
+	mappings := []gocni.PortMapping{}
+	for port, portBindings := range bindings {
+		containerPort, err := strconv.Atoi(port.Port())
+		if err != nil {
+			return nil, fmt.Errorf("invalid container port %v", port)
+		}
+		for _, binding := range portBindings {
+			hostPort := binding.HostPort
+			if hostPort == "" {
+				if hostPort, err = freeHostPort(port.Proto(), binding.HostIP); err != nil {
+					return nil, err
+				}
+			}
+			hp, err := strconv.Atoi(hostPort)
+			if err != nil {
+				return nil, fmt.Errorf("invalid host port %v", binding.HostPort)
+			}
+			mappings = append(mappings, gocni.PortMapping{
+				HostPort:      int32(hp),
+				ContainerPort: int32(containerPort),
+				Protocol:      port.Proto(),
+				HostIP:        binding.HostIP,
+			})
+		}
+	}
+	return mappings, nil
+}
+
+// Ask the kernel for a free port on the host.
+func freeHostPort(protocol string, hostIP string) (string, error) {
+	address := net.JoinHostPort(hostIP, "0")
+	if protocol == "udp" {
+		conn, err := net.ListenPacket("udp", address)
+		if err != nil {
+			return "", err
+		}
+		defer conn.Close()
+		_, port, err := net.SplitHostPort(conn.LocalAddr().String())
+		return port, err
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	return port, err
+}
+
+// Stop the task, killing it if it does not stop in time.
+func stopTask(ctx context.Context, task containerd.Task) error {
+	status, err := task.Status(ctx)
+	if err != nil {
+		return err
+	} else if status.Status != containerd.Running {
+		return nil
+	}
+
+	exitC, err := task.Wait(ctx)
+	if err != nil {
+		return err
+	}
+	if err := task.Kill(ctx, syscall.SIGTERM); err != nil && !errdefs.IsNotFound(err) {
+		return err
+	}
+	select {
+	case <-exitC:
+		return nil
+	case <-time.After(CONTAINERD_STOP_TIMEOUT_S * time.Second):
+		glog.V(3).Infof("Container %v did not stop within %v seconds, killing it.", task.ID(), CONTAINERD_STOP_TIMEOUT_S)
+	}
+	if err := task.Kill(ctx, syscall.SIGKILL); err != nil && !errdefs.IsNotFound(err) {
+		return err
+	}
+	<-exitC
+	return nil
+}
+
+func isRunning(ctx context.Context, c containerd.Container) bool {
+	if task, err := c.Task(ctx, nil); err != nil {
+		return false
+	} else if status, err := task.Status(ctx); err != nil {
+		return false
+	} else {
+		return status.Status == containerd.Running
+	}
+}
+
+// PauseContainer freezes the processes of a running container, the container keeps its network and its resources.
+func (b *ContainerdBackend) PauseContainer(id string) error {
+	ctx := b.ctx()
+	c, err := b.client.LoadContainer(ctx, id)
+	if err != nil {
+		return err
+	} else if !isRunning(ctx, c) {
+		return nil
+	}
+	task, err := c.Task(ctx, nil)
+	if err != nil {
+		return err
+	}
+	return task.Pause(ctx)
+}
+
+// ResumeContainer thaws the processes of a container that was paused.
+func (b *ContainerdBackend) ResumeContainer(id string) error {
+	ctx := b.ctx()
+	c, err := b.client.LoadContainer(ctx, id)
+	if err != nil {
+		return err
+	}
+	task, err := c.Task(ctx, nil)
+	if err != nil {
+		return err
+	} else if status, err := task.Status(ctx); err != nil {
+		return err
+	} else if status.Status != containerd.Paused {
+		return nil
+	}
+	return task.Resume(ctx)
+}
+
+// TaskPid returns the process id of the task of a running container.
+func (b *ContainerdBackend) TaskPid(id string) (int, error) {
+	ctx := b.ctx()
+	c, err := b.client.LoadContainer(ctx, id)
+	if err != nil {
+		return 0, err
+	} else if !isRunning(ctx, c) {
+		return 0, fmt.Errorf("container is not running")
+	}
+	task, err := c.Task(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	return int(task.Pid()), nil
+}
+
+// Returns the network namespace and the IP address of the container of a pod, which must be running.
+func (b *ContainerdBackend) podNetwork(ctx context.Context, id string) (string, string, error) {
+	c, err := b.client.LoadContainer(ctx, id)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to find container %v, error %v", id, err)
+	} else if !isRunning(ctx, c) {
+		return "", "", fmt.Errorf("container %v is not running", id)
+	}
+	task, err := c.Task(ctx, nil)
+	if err != nil {
+		return "", "", err
+	}
+	info, err := c.Info(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	return netnsPath(task.Pid()), info.Labels[containerdIPLabel], nil
+}
+
+func netnsPath(pid uint32) string {
+	return fmt.Sprintf("/proc/%d/ns/net", pid)
+}
+
+func sharedUsers(labels map[string]string) []string {
+	users := []string{}
+	for _, user := range strings.Split(labels[containerdSharedUsersLabel], ",") {
+		if user != "" {
+			users = append(users, user)
+		}
+	}
+	return users
+}
+
+func stringSliceContains(slice []string, s string) bool {
+	for _, item := range slice {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// The hosts file of a container holds the localhost entries and a line for each name the container can reach.
+func writeHostsFile(file string, hosts map[string]string) error {
+	names := make([]string, 0, len(hosts))
+	for name := range hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("127.0.0.1\tlocalhost\n::1\tlocalhost ip6-localhost ip6-loopback\n")
+	for _, name := range names {
+		if hosts[name] != "" {
+			sb.WriteString(fmt.Sprintf("%v\t%v\n", hosts[name], name))
+		}
+	}
+
+	// The file is bind mounted into the container, so it is rewritten in place rather than replaced.
+	return os.WriteFile(file, []byte(sb.String()), 0644)
+}
+
+func readHostsFile(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hosts := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[1] == "localhost" {
+			continue
+		}
+		for _, name := range fields[1:] {
+			hosts[name] = fields[0]
+		}
+	}
+	return hosts, scanner.Err()
+}
+
+func withAdditionalGIDs(groups []string) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *oci.Spec) error {
+		for _, group := range groups {
+			gid, err := strconv.ParseUint(group, 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid group id %v", group)
+			}
+			s.Process.User.AdditionalGids = append(s.Process.User.AdditionalGids, uint32(gid))
+		}
+		return nil
+	}
+}
+
+func withCPUSet(cpus string) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *oci.Spec) error {
+		if s.Linux.Resources.CPU == nil {
+			s.Linux.Resources.CPU = &specs.LinuxCPU{}
+		}
+		s.Linux.Resources.CPU.Cpus = cpus
+		return nil
+	}
+}
+
+// The swap limit is the limit of the memory and the swap together, as in docker.
+func withMemorySwap(swap int64) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *oci.Spec) error {
+		if s.Linux.Resources.Memory == nil {
+			s.Linux.Resources.Memory = &specs.LinuxMemory{}
+		}
+		s.Linux.Resources.Memory.Swap = &swap
+		return nil
+	}
+}
+
+func withDroppedCapabilities(capDrop []string) oci.SpecOpts {
+	caps := make([]string, 0, len(capDrop))
+	for _, capability := range capDrop {
+		if capability = containermessage.NormalizeCapability(capability); capability == "ALL" {
+			return oci.WithCapabilities([]string{})
+		}
+		caps = append(caps, capability)
+	}
+	return oci.WithDroppedCapabilities(caps)
+}
+
+// Convert the docker seccomp and AppArmor security options. Docker semantics, the default seccomp profile applies unless
+// the service asks for another one or for none. The SELinux options, used with podman, do not apply to containerd.
+func securityOptSpecOpts(securityOpt []string) ([]oci.SpecOpts, error) {
+	opts := []oci.SpecOpts{}
+	seccompSet := false
+	for _, opt := range securityOpt {
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "seccomp":
+			seccompSet = true
+			if kv[1] != containermessage.SECURITY_PROFILE_UNCONFINED {
+				var profile specs.LinuxSeccomp
+				if err := json.Unmarshal([]byte(kv[1]), &profile); err != nil {
+					return nil, fmt.Errorf("unable to demarshal seccomp profile, error %v", err)
+				}
+				opts = append(opts, withSeccompProfile(&profile))
+			}
+		case "apparmor":
+			if kv[1] != containermessage.SECURITY_PROFILE_UNCONFINED {
+				opts = append(opts, oci.WithApparmorProfile(kv[1]))
+			}
+		}
+	}
+	if !seccompSet {
+		opts = append(opts, seccomp.WithDefaultProfile())
+	}
+	return opts, nil
+}
+
+func withSeccompProfile(profile *specs.LinuxSeccomp) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *oci.Spec) error {
+		s.Linux.Seccomp = profile
+		return nil
+	}
+}
+
+func withSysctls(sysctls map[string]string) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *oci.Spec) error {
+		if s.Linux.Sysctl == nil {
+			s.Linux.Sysctl = map[string]string{}
+		}
+		for k, v := range sysctls {
+			s.Linux.Sysctl[k] = v
+		}
+		return nil
+	}
+}
+
+// Creates the containers of the deployment through containerd. The services reach each other, their dependencies and the
+// shared services through the hosts file of their containers. Since the hosts file is written when a container starts,
+// a service reaches the services it depends on and the services started before it.
+func (b *ContainerWorker) containerdResourcesCreate(agreementId string, agreementProtocol string, deployment *containermessage.DeploymentDescription, servicePairs map[string]servicePair, startOrder []string, dependencyHosts map[string]string, fail func(container *docker.Container, name string, err error) error) (persistence.DeploymentConfig, error) {
+
+	ret := persistence.NativeDeploymentConfig{
+		Services: make(map[string]persistence.ServiceConfig, 0),
+	}
+
+	for serviceName, servicePair := range servicePairs {
+		// The containers share a single CNI network, so the outbound traffic of a service cannot be restricted.
+		if servicePair.service.NetworkIsolation != nil {
+			return nil, fail(nil, serviceName, fmt.Errorf("Network isolation of service %v in agreement %v is not supported by the containerd runtime", serviceName, agreementId))
+		}
+		if err := b.ctrd.HasImage(servicePair.serviceConfig.Config.Image); err != nil {
+			return nil, fail(nil, serviceName, fmt.Errorf("Failed to locally inspect image: %v. Original error: %v", servicePair.serviceConfig.Config.Image, err))
+		}
+		ret.Services[serviceName] = *servicePair.serviceConfig
+	}
+
+	// Now that we know we are going to process this deployment, save the deployment config before we create any containers.
+	if agreementProtocol != "" {
+		if _, err := persistence.AgreementDeploymentStarted(b.db, agreementId, agreementProtocol, &ret); err != nil {
+			return nil, err
+		}
+	}
+
+	// create the named volumes that do not exist yet, they are removed during the unregistration process.
+	workloadRWStorageDir, _ := b.workloadStorageDir(agreementId)
+	for serviceName, servicePair := range servicePairs {
+		for _, bind := range servicePair.serviceConfig.HostConfig.Binds {
+			volName := strings.Split(bind, ":")[0]
+			if strings.Contains(volName, "/") || volName == workloadRWStorageDir {
+				continue
+			}
+			if created, err := b.ctrd.CreateVolume(volName); err != nil {
+				return nil, fail(nil, serviceName, fmt.Errorf("Failed to create the volume %v for service %v. %v", volName, serviceName, err))
+			} else if created && b.db != nil {
+				glog.V(3).Infof("Volume %v created for service %v.", volName, serviceName)
+				if err := persistence.SaveContainerVolumeByName(b.db, volName); err != nil {
+					return nil, fmt.Errorf("Failed to get save the volume name %v into the local db. %v", volName, err)
+				}
+			}
+		}
+	}
+
+	hosts := make(map[string]string)
+	for name, ip := range dependencyHosts {
+		hosts[name] = ip
+	}
+
+	// shared services first, then the others in the order of their dependencies
+	for serviceName, servicePair := range servicePairs {
+		if !deployment.ServicePattern.IsShared("singleton", serviceName) {
+			continue
+		}
+
+		shareLabel := "singleton"
+		servicePair.serviceConfig.Config.Labels[LABEL_PREFIX+".service_pattern.shared"] = shareLabel
+		containerName := serviceName
+		if servicePair.service.VariationLabel != "" {
+			containerName = fmt.Sprintf("%v-%v", serviceName, servicePair.service.VariationLabel)
+		}
+
+		ip, err := b.ctrd.StartSharedService(fmt.Sprintf("%v-%v", shareLabel, containerName), agreementId, serviceName, servicePair.serviceConfig, hosts)
+		if err != nil {
+			return nil, fail(nil, containerName, err)
+		} else if ip != "" {
+			hosts[serviceName] = ip
+		}
+	}
+
+	for _, serviceName := range startOrder {
+		servicePair, ok := servicePairs[serviceName]
+		if !ok || deployment.ServicePattern.IsShared("singleton", serviceName) {
+			continue
+		}
+
+		ip, err := b.ctrd.StartService(fmt.Sprintf("%v-%v", agreementId, serviceName), serviceName, servicePair.serviceConfig, hosts)
+		if err == docker.ErrContainerAlreadyExists {
+			continue
+		} else if err != nil {
+			return nil, fail(nil, serviceName, err)
+		} else if ip != "" {
+			hosts[serviceName] = ip
+		}
+	}
+
+	b.shapeAgreementEgress(agreementId)
+
+	for name, _ := range ret.Services {
+		glog.V(1).Infof("Created service %v in agreement %v", name, agreementId)
+	}
+	return &ret, nil
+}
+
+// Returns the names of the dependency services and the IP address of their containers, which take the place of the
+// dependency networks when the containers are run by containerd.
+func containerdDependencyHosts(dependencyContainers []docker.APIContainers) map[string]string {
+	hosts := make(map[string]string)
+	for _, msc := range dependencyContainers {
+		if name, ok := msc.Labels[LABEL_PREFIX+".service_name"]; ok {
+			if nw, ok := msc.Networks.Networks[CONTAINERD_NETWORK_NAME]; ok && nw.IPAddress != "" {
+				hosts[name] = nw.IPAddress
+			}
+		}
+	}
+	return hosts
+}
+
+// When a dependency service is restarted, its containers get new IP addresses. Update the hosts file of the parent
+// containers with them.
+func (b *ContainerWorker) containerdRestoreDependencyHosts(instanceKey string, parentContainers *[]docker.APIContainers) error {
+	containers, err := b.ctrd.ListContainers(false)
+	if err != nil {
+		return err
+	}
+
+	serviceContainers := []docker.APIContainers{}
+	for _, c := range containers {
+		if c.Labels[LABEL_PREFIX+".agreement_id"] == instanceKey {
+			serviceContainers = append(serviceContainers, c)
+		}
+	}
+	hosts := containerdDependencyHosts(serviceContainers)
+
+	for _, parentContainer := range *parentContainers {
+		if err := b.ctrd.UpdateHosts(parentContainer.ID, hosts); err != nil {
+			return fmt.Errorf("unable to update the hosts of parent container %v, error %v", parentContainer.Names, err)
+		}
+		glog.V(3).Infof("ContainerWorker updated the hosts of parent container %v with the restarted service %v: %v", parentContainer.ID, instanceKey, hosts)
+	}
+	return nil
+}
+
+// Removes the containers and the storage of the given agreements or service instances.
+func (b *ContainerWorker) containerdResourcesRemove(agreements []string) error {
+
+	// The credentials of the agreements that have containers are removed with the containers.
+	withContainers := make(map[string]bool)
+	b.ContainersMatchingAgreement(agreements, false, func(container *docker.APIContainers, agreementId string) error {
+		if serviceAndWorkerTypeMatches(b.isDevInstance, container) {
+			withContainers[agreementId] = true
+		}
+		return nil
+	})
+
+	if err := b.ctrd.RemoveAgreements(agreements); err != nil {
+		glog.Errorf("Error removing containers for %v. Error: %v", agreements, err)
+	}
+
+	for _, agreementId := range agreements {
+		if withContainers[agreementId] {
+			// Remove the File Sync Service API authentication credential file.
+			if essToken, err := b.GetAuthenticationManager().RemoveCredential(agreementId, !b.isDevInstance); err != nil {
+				glog.Errorf("Failed to remove FSS Authentication credential file for %v, error %v", agreementId, err)
+			} else if !b.IsDevInstance() {
+				if _, err := persistence.DeleteMSSInstWithESSToken(b.db, essToken); err != nil {
+					glog.Errorf("Failed to remove MicroserviceSecretStatus record for %v, error %v", agreementId, err)
+				}
+			}
+		}
+
+		// Remove the secrets for this agreement from the agent filesystem and db
+		if err := b.GetSecretsManager().DeleteAllSecForAgreement(b.db, agreementId); err != nil {
+			glog.Errorf("Error removing service secrets for agreement %v: %v", agreementId, err)
+		}
+
+		// The workload storage volume is removed with the containers, the workload storage dir is removed here.
+		if workloadRWStorageDir, useVolume := b.workloadStorageDir(agreementId); !useVolume {
+			if err := os.RemoveAll(workloadRWStorageDir); err != nil {
+				glog.Errorf("Failed to remove workloadStorageDir: %v. Error: %v", workloadRWStorageDir, err)
+			}
+		}
+	}
+
+	// Remove the named volumes of the agreements, or keep them for the next version of the service.
+	b.releaseNamedVolumes(agreements)
+
+	return nil
+}
+
+// Delete the volumes created by anax for the containerd containers.
+func deleteLeftoverContainerdVolumes(db *bolt.DB, config *config.HorizonConfig) error {
+	cvs, err := persistence.FindAllUndeletedContainerVolumes(db)
+	if err != nil {
+		return fmt.Errorf("Error retrieving undeleted container volumes from local db. %v", err)
+	}
+
+	for _, cv := range cvs {
+		volume := path.Join(containerdVolumeDir(config.GetContainerdStateDir()), cv.Name)
+		if err := os.RemoveAll(volume); err != nil {
+			// failure to delete the volume should not prevent the process from going on
+			glog.Errorf("Container sync resources. Failed to delete volume %v. %v", volume, err)
+		} else if err := persistence.ArchiveContainerVolumes(db, &cv); err != nil {
+			return err
+		} else {
+			glog.V(3).Infof("Volume %v is removed in cleanup process.", cv.Name)
+		}
+	}
+	return nil
+}
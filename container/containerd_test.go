@@ -0,0 +1,206 @@
+//go:build unit
+// +build unit
+
+package container
+
+import (
+	"context"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/containerd/containerd/oci"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/persistence"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func Test_containerdResourcesCreate_NetworkIsolation(t *testing.T) {
+	w := &ContainerWorker{}
+	servicePairs := map[string]servicePair{
+		"svc1": {
+			service:       &containermessage.Service{Image: "svc1:1.0.0", NetworkIsolation: &containermessage.NetworkIsolation{}},
+			serviceConfig: &persistence.ServiceConfig{Config: docker.Config{Image: "svc1:1.0.0"}},
+		},
+	}
+	failed := ""
+	fail := func(container *docker.Container, name string, err error) error {
+		failed = name
+		return err
+	}
+
+	if _, err := w.containerdResourcesCreate("ag1", "", nil, servicePairs, []string{"svc1"}, nil, fail); err == nil {
+		t.Errorf("a service with network isolation should have been rejected")
+	} else if failed != "svc1" || !strings.Contains(err.Error(), "not supported by the containerd runtime") {
+		t.Errorf("wrong failure of service %v, error %v", failed, err)
+	}
+}
+
+func Test_ContainerdBackend_mounts(t *testing.T) {
+	b := &ContainerdBackend{stateDir: t.TempDir()}
+	hostDir := path.Join(t.TempDir(), "data")
+
+	mounts, err := b.mounts([]string{"vol1:/data", hostDir + ":/host:ro"}, map[string]string{"/tmp": "size=64m"})
+	if err != nil {
+		t.Fatalf("should not return error, but got %v", err)
+	}
+
+	expected := []specs.Mount{
+		{Destination: "/data", Type: "bind", Source: path.Join(b.volumeDir(), "vol1"), Options: []string{"rbind", "rw"}},
+		{Destination: "/host", Type: "bind", Source: hostDir, Options: []string{"rbind", "ro"}},
+		{Destination: "/tmp", Type: "tmpfs", Source: "tmpfs", Options: []string{"nosuid", "nodev", "noexec", "size=64m"}},
+	}
+	if !reflect.DeepEqual(mounts, expected) {
+		t.Errorf("expected mounts %v, but got %v", expected, mounts)
+	}
+
+	// the missing bind sources are created, as docker does
+	for _, dir := range []string{path.Join(b.volumeDir(), "vol1"), hostDir} {
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			t.Errorf("bind source %v should have been created, error %v", dir, err)
+		}
+	}
+
+	if _, err := b.mounts([]string{"vol1"}, nil); err == nil {
+		t.Errorf("a bind without a destination should have returned an error")
+	}
+}
+
+func Test_portMappings(t *testing.T) {
+	bindings := map[docker.Port][]docker.PortBinding{
+		"8080/tcp": {{HostIP: "127.0.0.1", HostPort: "9080"}},
+		"5353/udp": {{HostPort: ""}},
+	}
+
+	mappings, err := portMappings(bindings)
+	if err != nil {
+		t.Fatalf("should not return error, but got %v", err)
+	} else if len(mappings) != 2 {
+		t.Fatalf("expected 2 mappings, but got %v", mappings)
+	}
+	for _, m := range mappings {
+		switch m.Protocol {
+		case "tcp":
+			if m.HostPort != 9080 || m.ContainerPort != 8080 || m.HostIP != "127.0.0.1" {
+				t.Errorf("wrong tcp mapping %v", m)
+			}
+		case "udp":
+			if m.HostPort == 0 || m.ContainerPort != 5353 {
+				t.Errorf("a binding without a host port should get a free port, got %v", m)
+			}
+		default:
+			t.Errorf("unexpected mapping %v", m)
+		}
+	}
+
+	if _, err := portMappings(map[docker.Port][]docker.PortBinding{"80/tcp": {{HostPort: "http"}}}); err == nil {
+		t.Errorf("an invalid host port should have returned an error")
+	}
+}
+
+func Test_HostsFile(t *testing.T) {
+	file := path.Join(t.TempDir(), "hosts")
+	hosts := map[string]string{"svc1": "10.89.0.2", "svc2": "10.89.0.3", "gone": ""}
+
+	if err := writeHostsFile(file, hosts); err != nil {
+		t.Fatalf("should not return error, but got %v", err)
+	}
+	if content, err := os.ReadFile(file); err != nil {
+		t.Fatalf("should not return error, but got %v", err)
+	} else if !strings.HasPrefix(string(content), "127.0.0.1\tlocalhost\n") || !strings.Contains(string(content), "10.89.0.2\tsvc1\n") {
+		t.Errorf("wrong hosts file %v", string(content))
+	}
+
+	// the names without an address are left out
+	if read, err := readHostsFile(file); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if !reflect.DeepEqual(read, map[string]string{"svc1": "10.89.0.2", "svc2": "10.89.0.3"}) {
+		t.Errorf("wrong hosts %v", read)
+	}
+}
+
+// Applies the options to an empty spec.
+func applySpecOpts(t *testing.T, opts ...oci.SpecOpts) *oci.Spec {
+	s := &oci.Spec{Process: &specs.Process{}, Linux: &specs.Linux{Resources: &specs.LinuxResources{}}}
+	for _, opt := range opts {
+		if err := opt(context.Background(), nil, nil, s); err != nil {
+			t.Fatalf("should not return error, but got %v", err)
+		}
+	}
+	return s
+}
+
+func Test_SpecOpts(t *testing.T) {
+	s := applySpecOpts(t, withAdditionalGIDs([]string{"20", "44"}), withCPUSet("0-1"), withMemorySwap(1024), withSysctls(map[string]string{"net.ipv4.ip_forward": "1"}))
+
+	if !reflect.DeepEqual(s.Process.User.AdditionalGids, []uint32{20, 44}) {
+		t.Errorf("wrong additional groups %v", s.Process.User.AdditionalGids)
+	} else if s.Linux.Resources.CPU.Cpus != "0-1" {
+		t.Errorf("wrong cpu set %v", s.Linux.Resources.CPU.Cpus)
+	} else if *s.Linux.Resources.Memory.Swap != 1024 {
+		t.Errorf("wrong swap limit %v", *s.Linux.Resources.Memory.Swap)
+	} else if s.Linux.Sysctl["net.ipv4.ip_forward"] != "1" {
+		t.Errorf("wrong sysctls %v", s.Linux.Sysctl)
+	}
+
+	if err := withAdditionalGIDs([]string{"video"})(context.Background(), nil, nil, s); err == nil {
+		t.Errorf("a group name should have returned an error")
+	}
+}
+
+func Test_securityOptSpecOpts(t *testing.T) {
+
+	// the default seccomp profile applies unless another one is given
+	if opts, err := securityOptSpecOpts(nil); err != nil || len(opts) != 1 {
+		t.Errorf("expected the default seccomp profile, got %v options, error %v", len(opts), err)
+	}
+
+	opts, err := securityOptSpecOpts([]string{`seccomp={"defaultAction":"SCMP_ACT_ALLOW"}`, "apparmor=" + containermessage.SECURITY_PROFILE_UNCONFINED, "label=disable"})
+	if err != nil {
+		t.Fatalf("should not return error, but got %v", err)
+	} else if len(opts) != 1 {
+		t.Fatalf("expected only the seccomp profile, got %v options", len(opts))
+	}
+	if s := applySpecOpts(t, opts...); s.Linux.Seccomp == nil || s.Linux.Seccomp.DefaultAction != specs.ActAllow {
+		t.Errorf("wrong seccomp profile %v", s.Linux.Seccomp)
+	}
+
+	if opts, err := securityOptSpecOpts([]string{"seccomp=" + containermessage.SECURITY_PROFILE_UNCONFINED}); err != nil || len(opts) != 0 {
+		t.Errorf("an unconfined service should have no seccomp profile, got %v options, error %v", len(opts), err)
+	}
+
+	if _, err := securityOptSpecOpts([]string{"seccomp={"}); err == nil {
+		t.Errorf("an invalid seccomp profile should have returned an error")
+	}
+}
+
+func Test_containerdDependencyHosts(t *testing.T) {
+	containers := []docker.APIContainers{
+		{
+			Labels:   map[string]string{LABEL_PREFIX + ".service_name": "svc1"},
+			Networks: docker.NetworkList{Networks: map[string]docker.ContainerNetwork{CONTAINERD_NETWORK_NAME: {IPAddress: "10.89.0.2"}}},
+		},
+		{
+			Labels:   map[string]string{LABEL_PREFIX + ".service_name": "svc2"},
+			Networks: docker.NetworkList{Networks: map[string]docker.ContainerNetwork{"other": {IPAddress: "172.17.0.2"}}},
+		},
+		{
+			Networks: docker.NetworkList{Networks: map[string]docker.ContainerNetwork{CONTAINERD_NETWORK_NAME: {IPAddress: "10.89.0.4"}}},
+		},
+	}
+
+	if hosts := containerdDependencyHosts(containers); !reflect.DeepEqual(hosts, map[string]string{"svc1": "10.89.0.2"}) {
+		t.Errorf("wrong dependency hosts %v", hosts)
+	}
+}
+
+func Test_sharedUsers(t *testing.T) {
+	if users := sharedUsers(map[string]string{containerdSharedUsersLabel: "ag1,,ag2"}); !reflect.DeepEqual(users, []string{"ag1", "ag2"}) {
+		t.Errorf("wrong shared users %v", users)
+	} else if users := sharedUsers(nil); len(users) != 0 {
+		t.Errorf("expected no shared users, got %v", users)
+	}
+}
@@ -0,0 +1,52 @@
+//go:build linux
+// +build linux
+
+package container
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+	"time"
+)
+
+// The time given to udev to create the links and the database entry of a new device node before it is looked at.
+const DEVICE_SETTLE_DELAY = 2 * time.Second
+
+// Listen to the device events of the kernel and tell the worker when a device node is added.
+func (b *ContainerWorker) watchDevices() error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return fmt.Errorf("unable to open the device event socket, error: %v", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: 1}); err != nil {
+		unix.Close(fd)
+		return fmt.Errorf("unable to bind the device event socket, error: %v", err)
+	}
+
+	go func() {
+		defer unix.Close(fd)
+		buf := make([]byte, 64*1024)
+		for !b.IsWorkerShuttingDown() {
+			n, _, err := unix.Recvfrom(fd, buf, 0)
+			if err == unix.EINTR || err == unix.ENOBUFS {
+				continue
+			} else if err != nil {
+				glog.Errorf("ContainerWorker stopped watching device events, error: %v", err)
+				return
+			}
+
+			if event := parseUevent(buf[:n]); event["ACTION"] == "add" && event["DEVNAME"] != "" {
+				cmd := b.NewDeviceChangedCommand(event["DEVNAME"], event["DEVPATH"])
+				time.AfterFunc(DEVICE_SETTLE_DELAY, func() {
+					if !b.IsWorkerShuttingDown() {
+						b.Commands <- cmd
+					}
+				})
+			}
+		}
+	}()
+
+	glog.V(3).Infof("ContainerWorker watching device events")
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package container
+
+import (
+	"errors"
+)
+
+func (b *ContainerWorker) watchDevices() error {
+	return errors.New("device events are only available on linux")
+}
@@ -0,0 +1,309 @@
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// The label that holds the devices of a service container, so that the container can be bound to a device again when
+// the device is plugged back in under another device node, e.g. /dev/ttyUSB0 becoming /dev/ttyUSB1.
+const DEVICES_LABEL = LABEL_PREFIX + ".devices"
+
+// Where udev keeps the properties of the devices, and where the kernel links the device numbers to the devices.
+const (
+	UDEV_DATA_DIR = "/run/udev/data"
+	SYSFS_DIR     = "/sys"
+)
+
+// A device of a service container. The device is identified by its serial number or by the port it is plugged into,
+// which do not change when the kernel gives the device another node.
+type deviceBinding struct {
+	Path   string `json:"path"`   // the host path from the deployment config, a device node or a link to one
+	Target string `json:"target"` // the path of the device in the container
+	Node   string `json:"node"`   // the device node the host path pointed to when the container was created
+	Dev    string `json:"dev"`    // the major:minor numbers of the device node
+	ID     string `json:"id"`     // the identity of the device
+}
+
+func (d deviceBinding) String() string {
+	return fmt.Sprintf("Path: %v, Target: %v, Node: %v, Dev: %v, ID: %v", d.Path, d.Target, d.Node, d.Dev, d.ID)
+}
+
+// Returns the bindings of the devices of a container. Devices that cannot be identified, because they are not there
+// or are not known to udev or sysfs, are left out. They keep their host path.
+func deviceBindings(devices []docker.Device) []deviceBinding {
+	bindings := make([]deviceBinding, 0, len(devices))
+	for _, device := range devices {
+		if binding, err := newDeviceBinding(device.PathOnHost, device.PathInContainer); err != nil {
+			glog.V(3).Infof("ContainerWorker unable to identify device %v, it will not be rebound if it is plugged back in, error: %v", device.PathOnHost, err)
+		} else {
+			bindings = append(bindings, *binding)
+		}
+	}
+	return bindings
+}
+
+func newDeviceBinding(hostPath string, target string) (*deviceBinding, error) {
+	node, err := filepath.EvalSymlinks(hostPath)
+	if err != nil {
+		return nil, err
+	}
+	dev, class, err := deviceNumber(node)
+	if err != nil {
+		return nil, err
+	}
+	id := deviceIdentity(readUdevProperties(class, dev), sysfsDevPath(class, dev))
+	if id == "" {
+		return nil, fmt.Errorf("no identity found for device %v", dev)
+	}
+	return &deviceBinding{Path: hostPath, Target: target, Node: node, Dev: dev, ID: id}, nil
+}
+
+// Returns the major:minor numbers of a device node and whether it is a character (c) or block (b) device.
+func deviceNumber(node string) (string, string, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(node, &st); err != nil {
+		return "", "", err
+	}
+
+	class := ""
+	switch uint32(st.Mode) & unix.S_IFMT {
+	case unix.S_IFCHR:
+		class = "c"
+	case unix.S_IFBLK:
+		class = "b"
+	default:
+		return "", "", fmt.Errorf("%v is not a device node", node)
+	}
+	rdev := uint64(st.Rdev)
+	return fmt.Sprintf("%v:%v", unix.Major(rdev), unix.Minor(rdev)), class, nil
+}
+
+// Returns the properties that udev recorded for a device, or nil when udev is not running on the host.
+func readUdevProperties(class string, dev string) map[string]string {
+	data, err := ioutil.ReadFile(path.Join(UDEV_DATA_DIR, class+dev))
+	if err != nil {
+		return nil
+	}
+	return parseUdevData(data)
+}
+
+// The udev database has a line per record, the properties of the device are the records starting with "E:".
+func parseUdevData(data []byte) map[string]string {
+	props := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "E:") {
+			if kv := strings.SplitN(line[2:], "=", 2); len(kv) == 2 {
+				props[kv[0]] = kv[1]
+			}
+		}
+	}
+	return props
+}
+
+// Returns the sysfs path of a device, relative to /sys, e.g. /devices/pci0000:00/.../1-1:1.0/ttyUSB0/tty/ttyUSB0.
+func sysfsDevPath(class string, dev string) string {
+	dir := "char"
+	if class == "b" {
+		dir = "block"
+	}
+	devLink := path.Join(SYSFS_DIR, "dev", dir, dev)
+	if link, err := os.Readlink(devLink); err != nil {
+		return ""
+	} else {
+		return strings.TrimPrefix(path.Join(path.Dir(devLink), link), SYSFS_DIR)
+	}
+}
+
+// The identity of a device is its serial number, with the interface number for the devices that have several ports,
+// otherwise it is the port the device is plugged into. Without udev, the port is taken from the sysfs path of the device.
+func deviceIdentity(props map[string]string, devPath string) string {
+	if serial := props["ID_SERIAL"]; serial != "" {
+		return "serial:" + serial + ":" + props["ID_USB_INTERFACE_NUM"]
+	} else if idPath := props["ID_PATH"]; idPath != "" {
+		return "path:" + idPath
+	} else if devPath != "" {
+		return "sysfs:" + parentDevPath(devPath)
+	}
+	return ""
+}
+
+// Strips the name of the device node from its sysfs path, along with the class directory the kernel puts in between,
+// so that /devices/.../1-1:1.0/ttyUSB0/tty/ttyUSB0 and /devices/.../1-1:1.0/ttyUSB1/tty/ttyUSB1 have the same parent.
+func parentDevPath(devPath string) string {
+	name := path.Base(devPath)
+	parent := path.Dir(devPath)
+	if path.Base(path.Dir(parent)) == name {
+		parent = path.Dir(path.Dir(parent))
+	}
+	return parent
+}
+
+// Parses a kernel device event, a header line "action@devpath" followed by KEY=VALUE lines, all ending with a NUL.
+func parseUevent(msg []byte) map[string]string {
+	props := make(map[string]string)
+	for _, field := range bytes.Split(msg, []byte{0}) {
+		if kv := strings.SplitN(string(field), "=", 2); len(kv) == 2 {
+			props[kv[0]] = kv[1]
+		}
+	}
+	return props
+}
+
+// Returns the bindings whose device is the given device and that are bound to another device node, updated to the
+// new device node.
+func reboundDevices(bindings []deviceBinding, id string, node string, dev string) ([]deviceBinding, bool) {
+	changed := false
+	for ix := range bindings {
+		if bindings[ix].ID == id && (bindings[ix].Node != node || bindings[ix].Dev != dev) {
+			bindings[ix].Node = node
+			bindings[ix].Dev = dev
+			changed = true
+		}
+	}
+	return bindings, changed
+}
+
+// Returns the host path that binds a device to the container. A link from the deployment config that now points to the
+// new device node is kept, e.g. /dev/serial/by-id/..., otherwise the device node itself is used.
+func reboundHostPath(binding deviceBinding) string {
+	if binding.Path != binding.Node {
+		if node, err := filepath.EvalSymlinks(binding.Path); err == nil && node == binding.Node {
+			return binding.Path
+		}
+	}
+	return binding.Node
+}
+
+// A device node was added on the host. The service containers bound to the same device under another device node are
+// recreated with the new device node, rather than being left bound to a device node that no longer exists until the
+// agreement is cancelled.
+func (b *ContainerWorker) rebindDevice(cmd *DeviceChangedCommand) {
+	node := path.Join("/dev", cmd.DevName)
+	dev, class, err := deviceNumber(node)
+	if err != nil {
+		// The device was unplugged again.
+		glog.V(5).Infof("ContainerWorker ignoring device event for %v, error: %v", node, err)
+		return
+	}
+	id := deviceIdentity(readUdevProperties(class, dev), cmd.DevPath)
+	if id == "" {
+		return
+	}
+
+	containers, err := b.client.ListContainers(docker.ListContainersOptions{
+		All:     true,
+		Filters: map[string][]string{"label": []string{DEVICES_LABEL}},
+	})
+	if err != nil {
+		glog.Errorf("ContainerWorker unable to list containers to rebind device %v, error: %v", node, err)
+		return
+	}
+
+	for _, container := range containers {
+		var bindings []deviceBinding
+		if err := json.Unmarshal([]byte(container.Labels[DEVICES_LABEL]), &bindings); err != nil {
+			glog.Errorf("ContainerWorker unable to demarshal devices label %v of container %v, error: %v", container.Labels[DEVICES_LABEL], container.Names, err)
+			continue
+		}
+		if bindings, changed := reboundDevices(bindings, id, node, dev); changed {
+			if err := b.recreateWithDevices(container.ID, bindings); err != nil {
+				glog.Errorf("ContainerWorker unable to rebind container %v to device %v, error: %v", container.Names, node, err)
+			} else {
+				glog.V(3).Infof("ContainerWorker rebound container %v to device %v", container.Names, node)
+			}
+		}
+	}
+}
+
+// Docker cannot change the devices of a container, so the container is replaced by a container with the same name,
+// configuration and network aliases, and the new devices.
+func (b *ContainerWorker) recreateWithDevices(containerId string, bindings []deviceBinding) error {
+	container, err := b.client.InspectContainer(containerId)
+	if err != nil {
+		return err
+	}
+
+	hostConfig := container.HostConfig
+	for ix, device := range hostConfig.Devices {
+		for _, binding := range bindings {
+			if device.PathInContainer == binding.Target {
+				hostConfig.Devices[ix].PathOnHost = reboundHostPath(binding)
+			}
+		}
+	}
+
+	if label, err := json.Marshal(bindings); err != nil {
+		return err
+	} else {
+		container.Config.Labels[DEVICES_LABEL] = string(label)
+	}
+
+	// The host name defaults to the id of the container, let docker give the new container its own.
+	if strings.HasPrefix(container.ID, container.Config.Hostname) {
+		container.Config.Hostname = ""
+	}
+
+	// The first network is given when creating the container, the others are connected after it is created. The alias
+	// docker adds for the id of the container is left out.
+	endpointsConfig := make(map[string]*docker.EndpointConfig)
+	otherEndpoints := make(map[string]*docker.EndpointConfig)
+	if hostConfig.NetworkMode != "host" && container.NetworkSettings != nil {
+		for name, network := range container.NetworkSettings.Networks {
+			aliases := make([]string, 0)
+			for _, alias := range network.Aliases {
+				if !strings.HasPrefix(container.ID, alias) {
+					aliases = append(aliases, alias)
+				}
+			}
+			endpoint := &docker.EndpointConfig{Aliases: aliases, NetworkID: network.NetworkID}
+			if len(endpointsConfig) == 0 {
+				endpointsConfig[name] = endpoint
+			} else {
+				otherEndpoints[name] = endpoint
+			}
+		}
+	}
+
+	if err := b.client.RemoveContainer(docker.RemoveContainerOptions{ID: container.ID, RemoveVolumes: false, Force: true}); err != nil {
+		return fmt.Errorf("unable to remove container, error: %v", err)
+	}
+
+	newContainer, err := b.client.CreateContainer(docker.CreateContainerOptions{
+		Name:       strings.TrimPrefix(container.Name, "/"),
+		Config:     container.Config,
+		HostConfig: hostConfig,
+		NetworkingConfig: &docker.NetworkingConfig{
+			EndpointsConfig: endpointsConfig,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to create container, error: %v", err)
+	}
+
+	for _, endpoint := range otherEndpoints {
+		if err := b.client.ConnectNetwork(endpoint.NetworkID, docker.NetworkConnectionOptions{
+			Container:      newContainer.ID,
+			EndpointConfig: endpoint,
+			Force:          true,
+		}); err != nil {
+			return fmt.Errorf("unable to connect network %v, error: %v", endpoint.NetworkID, err)
+		}
+	}
+
+	if err := b.client.StartContainer(newContainer.ID, nil); err != nil {
+		return fmt.Errorf("unable to start container, error: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package container
+
+import (
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/persistence"
+	"strconv"
+)
+
+// The label that holds the rate, in kilobits per second, at which a service container can send.
+const EGRESS_LABEL = LABEL_PREFIX + ".max_egress_kbps"
+
+// Returns the rate a service container can send at: the limit of the service, capped by the limit of the node
+// policy. 0 means no limit.
+func egressLimit(serviceKbps int64, nodeKbps int64) int64 {
+	if serviceKbps == 0 || (nodeKbps != 0 && nodeKbps < serviceKbps) {
+		return nodeKbps
+	}
+	return serviceKbps
+}
+
+// Returns the limit the node policy sets on the egress of each service container, 0 if there is none.
+func (w *ContainerWorker) nodeEgressLimit() (int64, error) {
+	if w.db == nil {
+		return 0, nil
+	}
+	nodePol, err := persistence.FindNodePolicy(w.db)
+	if err != nil {
+		return 0, err
+	} else if nodePol == nil {
+		return 0, nil
+	}
+	deployPol := nodePol.GetDeploymentPolicy()
+	if deployPol == nil || !deployPol.Properties.HasProperty(externalpolicy.PROP_NODE_MAX_EGRESS_KBPS) {
+		return 0, nil
+	}
+
+	prop, _ := deployPol.Properties.GetProperty(externalpolicy.PROP_NODE_MAX_EGRESS_KBPS)
+	var kbps int64
+	switch v := prop.Value.(type) {
+	case float64:
+		kbps = int64(v)
+	case int:
+		kbps = int64(v)
+	case string:
+		if kbps, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return 0, fmt.Errorf("value of property %v must be a number of kilobits per second, error %v", prop.Name, err)
+		}
+	default:
+		return 0, fmt.Errorf("value of property %v must be a number of kilobits per second, it is %v", prop.Name, prop.Value)
+	}
+	if kbps < 0 {
+		return 0, fmt.Errorf("value of property %v cannot be negative", prop.Name)
+	}
+	return kbps, nil
+}
+
+// Returns the commands that shape the egress of a network interface with a single HTB class, so that the packets
+// over the rate are queued rather than dropped.
+func htbCommands(dev string, kbps int64) [][]string {
+	rate := fmt.Sprintf("%vkbit", kbps)
+	return [][]string{
+		{"qdisc", "replace", "dev", dev, "root", "handle", "1:", "htb", "default", "10"},
+		{"class", "replace", "dev", dev, "parent", "1:", "classid", "1:10", "htb", "rate", rate, "ceil", rate},
+	}
+}
+
+// Shapes the egress of the running service containers that have a limit. A container restarted by the container
+// engine gets a new network namespace, so this is done when the containers are started and every time they are
+// checked. The interfaces that are already shaped are left alone.
+func (b *ContainerWorker) shapeContainersEgress(containers []docker.APIContainers) {
+	for _, container := range containers {
+		label, ok := container.Labels[EGRESS_LABEL]
+		if !ok {
+			continue
+		}
+		kbps, err := strconv.ParseInt(label, 10, 64)
+		if err != nil || kbps <= 0 {
+			glog.Errorf("ContainerWorker found invalid egress limit %v on container %v", label, container.Names)
+			continue
+		}
+
+		if pid, err := b.containerPid(container.ID); err != nil {
+			glog.Errorf("ContainerWorker unable to limit the egress of container %v, error: %v", container.Names, err)
+		} else if shaped, err := shapeEgress(pid, kbps); err != nil {
+			glog.Errorf("ContainerWorker unable to limit the egress of container %v, error: %v", container.Names, err)
+		} else if len(shaped) != 0 {
+			glog.V(3).Infof("ContainerWorker limited the egress of container %v to %v kbit/s on %v", container.Names, kbps, shaped)
+		}
+	}
+}
+
+// Shapes the egress of the containers of an agreement, and of the shared containers, once they are started.
+func (b *ContainerWorker) shapeAgreementEgress(agreementId string) {
+	containers := make([]docker.APIContainers, 0)
+	b.ContainersMatchingAgreement([]string{agreementId}, true, func(container *docker.APIContainers, agreementId string) error {
+		if container.State == "running" {
+			containers = append(containers, *container)
+		}
+		return nil
+	})
+	b.shapeContainersEgress(containers)
+}
+
+// Returns the process id of the main process of a running container.
+func (b *ContainerWorker) containerPid(id string) (int, error) {
+	if b.ctrd != nil {
+		return b.ctrd.TaskPid(id)
+	}
+	container, err := b.client.InspectContainer(id)
+	if err != nil {
+		return 0, err
+	} else if !container.State.Running || container.State.Pid == 0 {
+		return 0, fmt.Errorf("container is not running")
+	}
+	return container.State.Pid, nil
+}
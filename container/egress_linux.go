@@ -0,0 +1,79 @@
+//go:build linux
+// +build linux
+
+package container
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Shapes the egress of the network interfaces of a container, from inside the network namespace of the container,
+// with the tc command of the host. Returns the interfaces that were shaped.
+func shapeEgress(pid int, kbps int64) ([]string, error) {
+	tc, err := exec.LookPath("tc")
+	if err != nil {
+		return nil, fmt.Errorf("the tc command is not installed, error %v", err)
+	}
+
+	containerNs, err := os.Open(fmt.Sprintf("/proc/%v/ns/net", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer containerNs.Close()
+
+	// The thread is switched to the network namespace of the container, and so are the commands it starts. If it
+	// cannot be switched back, it is left locked so that it ends with the goroutine.
+	runtime.LockOSThread()
+	agentNs, err := os.Open(fmt.Sprintf("/proc/self/task/%v/ns/net", unix.Gettid()))
+	if err != nil {
+		runtime.UnlockOSThread()
+		return nil, err
+	}
+	defer agentNs.Close()
+
+	if err := unix.Setns(int(containerNs.Fd()), unix.CLONE_NEWNET); err != nil {
+		runtime.UnlockOSThread()
+		return nil, fmt.Errorf("unable to enter the network namespace of process %v, error %v", pid, err)
+	}
+
+	shaped, shapeErr := shapeInterfaces(tc, kbps)
+
+	if err := unix.Setns(int(agentNs.Fd()), unix.CLONE_NEWNET); err != nil {
+		return shaped, fmt.Errorf("unable to leave the network namespace of process %v, error %v", pid, err)
+	}
+	runtime.UnlockOSThread()
+	return shaped, shapeErr
+}
+
+// Shapes the interfaces of the current network namespace that are not shaped yet, except the loopback.
+func shapeInterfaces(tc string, kbps int64) ([]string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	shaped := make([]string, 0)
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if out, err := exec.Command(tc, "qdisc", "show", "dev", iface.Name).CombinedOutput(); err != nil {
+			return shaped, fmt.Errorf("unable to show the queues of %v, error %v: %v", iface.Name, err, strings.TrimSpace(string(out)))
+		} else if strings.Contains(string(out), "qdisc htb 1: root") {
+			continue
+		}
+		for _, args := range htbCommands(iface.Name, kbps) {
+			if out, err := exec.Command(tc, args...).CombinedOutput(); err != nil {
+				return shaped, fmt.Errorf("unable to run tc %v, error %v: %v", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+			}
+		}
+		shaped = append(shaped, iface.Name)
+	}
+	return shaped, nil
+}
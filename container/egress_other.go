@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package container
+
+import (
+	"errors"
+)
+
+func shapeEgress(pid int, kbps int64) ([]string, error) {
+	return nil, errors.New("egress limits are only available on linux")
+}
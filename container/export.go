@@ -0,0 +1,99 @@
+package container
+
+import (
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/eventlog"
+	"github.com/open-horizon/anax/persistence"
+	"os"
+	"path"
+	"strings"
+)
+
+// Returns the file a named volume of a service container is exported to. The volumes of an agreement are exported to
+// a directory of the agreement, one tar file per service and volume.
+func volumeExportFile(exportPath string, agreementId string, serviceName string, volumeName string) string {
+	name := strings.Replace(serviceName+"-"+volumeName, "/", "_", -1)
+	return path.Join(exportPath, agreementId, name+".tar")
+}
+
+// Stops a service container and exports the named volumes of its agreement it mounts, so that the data the service
+// buffered is not lost when the node is unregistered. The container is stopped gracefully first, running its pre_stop
+// command, so that the service can flush its data to the volumes. The container is still removed if the export fails.
+func (b *ContainerWorker) exportContainerVolumes(container *docker.APIContainers, agreementId string) {
+	cvs, err := persistence.FindContainerVolumes(b.db, []persistence.ContainerVolumeFilter{persistence.UnarchivedCVFilter(), persistence.AgreementCVFilter(agreementId)})
+	if err != nil {
+		glog.Errorf("ContainerWorker unable to retrieve the named volumes of %v from the database, error %v", agreementId, err)
+		return
+	}
+
+	mounts := make([]docker.APIMount, 0)
+	for _, m := range container.Mounts {
+		for _, cv := range cvs {
+			if m.Type == "volume" && m.Name == cv.Name {
+				mounts = append(mounts, m)
+				break
+			}
+		}
+	}
+	if len(mounts) == 0 {
+		return
+	}
+
+	stopTimeout, preStop := getStopLabels(container.Labels)
+	if stopTimeout == 0 {
+		stopTimeout = containermessage.DEFAULT_STOP_TIMEOUT_S
+	}
+	if err := gracefulStop(b.client, agreementId, container.ID, stopTimeout, preStop); err != nil {
+		if _, ok := err.(*docker.NoSuchContainer); ok {
+			return
+		} else if _, ok := err.(*docker.ContainerNotRunning); !ok {
+			glog.Warningf("ContainerWorker unable to stop container %v in agreement %v before exporting its volumes, error: %v", container.Names, agreementId, err)
+		}
+	}
+
+	serviceName := container.Labels[LABEL_PREFIX+".service_name"]
+	for _, m := range mounts {
+		file := volumeExportFile(b.exportPath, agreementId, serviceName, m.Name)
+		if err := b.exportVolume(container.ID, m.Destination, file); err != nil {
+			glog.Errorf("ContainerWorker unable to export volume %v of container %v, error: %v", m.Name, container.Names, err)
+			eventlog.LogServiceEvent2(b.db, persistence.SEVERITY_ERROR,
+				persistence.NewMessageMeta(EL_CONT_VOLUME_EXPORT_ERROR, m.Name, serviceName, agreementId, err.Error()),
+				persistence.EC_ERROR_EXPORT_VOLUME,
+				"", "", "", "", "", []string{agreementId})
+		} else {
+			glog.V(3).Infof("ContainerWorker exported volume %v of container %v to %v", m.Name, container.Names, file)
+			eventlog.LogServiceEvent2(b.db, persistence.SEVERITY_INFO,
+				persistence.NewMessageMeta(EL_CONT_VOLUME_EXPORTED, m.Name, serviceName, agreementId, file),
+				persistence.EC_VOLUME_EXPORTED,
+				"", "", "", "", "", []string{agreementId})
+		}
+	}
+}
+
+// Writes the content of a directory of a container to a tar file. A partial file is removed.
+func (b *ContainerWorker) exportVolume(containerId string, dir string, file string) error {
+	if err := os.MkdirAll(path.Dir(file), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	err = b.client.DownloadFromContainer(containerId, docker.DownloadFromContainerOptions{
+		Path:         dir,
+		OutputStream: f,
+	})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(file)
+		return fmt.Errorf("unable to copy %v to %v: %v", dir, file, err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package container
+
+import (
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+)
+
+// The seconds a container of a paused service has to exit once it is sent a SIGTERM, when the service does not set
+// a stop_timeout.
+const PAUSE_STOP_TIMEOUT_S = 10
+
+// Stops the containers of the given agreement or service instance when its service is paused by the node owner, or
+// starts them again when the service is resumed. The shared containers are left alone, they are used by other services.
+// The docker containers are stopped the same way as when the agreement ends, but they are not removed. The containerd
+// containers are frozen, because the task of a containerd container cannot be restarted without setting up the
+// container again.
+func (b *ContainerWorker) pauseContainers(key string, pause bool) error {
+	change := func(container *docker.APIContainers, key string) error {
+		if b.ctrd != nil {
+			if pause {
+				return b.ctrd.PauseContainer(container.ID)
+			}
+			return b.ctrd.ResumeContainer(container.ID)
+		}
+
+		if pause && container.State == "running" {
+			glog.V(3).Infof("Stopping container %v of paused service %v.", container.ID, key)
+			var err error
+			if stopTimeout, preStop := getStopLabels(container.Labels); stopTimeout != 0 {
+				err = gracefulStop(b.client, key, container.ID, stopTimeout, preStop)
+			} else {
+				err = b.client.StopContainer(container.ID, PAUSE_STOP_TIMEOUT_S)
+			}
+			if _, ok := err.(*docker.ContainerNotRunning); ok {
+				return nil
+			}
+			return err
+		} else if !pause && container.State != "running" {
+			glog.V(3).Infof("Starting container %v of resumed service %v.", container.ID, key)
+			if err := b.client.StartContainer(container.ID, nil); err != nil {
+				if _, ok := err.(*docker.ContainerAlreadyRunning); !ok {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	return b.ContainersMatchingAgreement([]string{key}, false, change)
+}
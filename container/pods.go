@@ -0,0 +1,75 @@
+package container
+
+import (
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"strings"
+)
+
+// The label of the containers that join the network namespace of another container of their service, like the
+// containers of a kubernetes pod. The value is the name of the service whose container they join.
+const POD_LABEL = LABEL_PREFIX + ".pod"
+
+// The network mode of a container that joins the network namespace of another container, see docker run --network.
+const CONTAINER_NETWORK_PREFIX = "container:"
+
+// Returns the network mode that joins the container of a service in the same agreement.
+func podNetworkMode(agreementId string, pod string) string {
+	return fmt.Sprintf("%v%v-%v", CONTAINER_NETWORK_PREFIX, agreementId, pod)
+}
+
+// Returns the name of the container whose network namespace a container joins, or an empty string if the container
+// has its own network.
+func podContainer(networkMode string) string {
+	if strings.HasPrefix(networkMode, CONTAINER_NETWORK_PREFIX) {
+		return strings.TrimPrefix(networkMode, CONTAINER_NETWORK_PREFIX)
+	}
+	return ""
+}
+
+// A container that joined the network namespace of the pod before the pod's container was last started is left in
+// the network namespace of the stopped container, with no network.
+func isStalePodMember(pod *docker.Container, member *docker.Container) bool {
+	return pod.State.Running && member.State.Running && pod.State.StartedAt.After(member.State.StartedAt)
+}
+
+// When the container engine restarts the container of a pod, it gets a new network namespace. The containers that
+// joined it are restarted too, so that the containers of the pod share a lifecycle. The containers are those of the
+// same agreement or service instance.
+func (b *ContainerWorker) restartStalePodMembers(containers []docker.APIContainers) {
+	if b.ctrd != nil {
+		// containerd does not restart containers, the whole service is restarted when one of them exits.
+		return
+	}
+
+	for _, member := range containers {
+		pod, ok := member.Labels[POD_LABEL]
+		if !ok {
+			continue
+		}
+		for _, c := range containers {
+			if c.Labels[LABEL_PREFIX+".service_name"] != pod || c.Labels[LABEL_PREFIX+".agreement_id"] != member.Labels[LABEL_PREFIX+".agreement_id"] {
+				continue
+			}
+
+			podContainer, err := b.client.InspectContainer(c.ID)
+			if err != nil {
+				glog.Errorf("ContainerWorker unable to inspect container %v, error: %v", c.Names, err)
+				continue
+			}
+			memberContainer, err := b.client.InspectContainer(member.ID)
+			if err != nil {
+				glog.Errorf("ContainerWorker unable to inspect container %v, error: %v", member.Names, err)
+				continue
+			}
+
+			if isStalePodMember(podContainer, memberContainer) {
+				glog.V(3).Infof("ContainerWorker restarting container %v to join the network of restarted container %v", member.Names, c.Names)
+				if err := b.client.RestartContainer(member.ID, 10); err != nil {
+					glog.Errorf("ContainerWorker unable to restart container %v, error: %v", member.Names, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package container
+
+import (
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+)
+
+// ContainerRuntime hides the differences between the container engines the container worker can drive. Both engines are
+// driven through the docker API, dockerd natively and podman through its docker compatible socket, but they differ in
+// the log driver they support by default, the security options needed by the service containers and the options they
+// accept on a bridge network.
+type ContainerRuntime interface {
+	Name() string
+	DefaultLogDriver() string
+	SecurityOpts() []string
+	BridgeOptions() map[string]interface{}
+}
+
+// Returns the runtime for the configured container engine. If no engine is configured, the engine is detected from the
+// version info returned by the engine behind the client.
+func NewContainerRuntime(client *docker.Client, configured string) (ContainerRuntime, error) {
+	if configured == "" {
+		svType, err := GetServerEnginType(client)
+		if err != nil {
+			return nil, err
+		}
+		configured = svType
+	}
+
+	switch configured {
+	case API_SERVER_TYPE_DOCKER:
+		return new(dockerRuntime), nil
+	case API_SERVER_TYPE_PODMAN:
+		return new(podmanRuntime), nil
+	case API_SERVER_TYPE_CONTAINERD:
+		return new(containerdRuntime), nil
+	default:
+		return nil, fmt.Errorf("unsupported container runtime %v, must be %v, %v or %v", configured, API_SERVER_TYPE_DOCKER, API_SERVER_TYPE_PODMAN, API_SERVER_TYPE_CONTAINERD)
+	}
+}
+
+// The docker engine.
+type dockerRuntime struct{}
+
+func (r *dockerRuntime) Name() string {
+	return API_SERVER_TYPE_DOCKER
+}
+
+func (r *dockerRuntime) DefaultLogDriver() string {
+	return LOG_DRIVER_SYSLOG
+}
+
+func (r *dockerRuntime) SecurityOpts() []string {
+	return []string{}
+}
+
+func (r *dockerRuntime) BridgeOptions() map[string]interface{} {
+	return map[string]interface{}{
+		"com.docker.network.bridge.enable_icc":           "true",
+		"com.docker.network.bridge.enable_ip_masquerade": "true",
+		"com.docker.network.bridge.default_bridge":       "false",
+	}
+}
+
+// The podman engine. Podman rejects the dockerd specific bridge options, its bridges already allow inter-container
+// traffic and masquerade outbound traffic.
+type podmanRuntime struct{}
+
+func (r *podmanRuntime) Name() string {
+	return API_SERVER_TYPE_PODMAN
+}
+
+func (r *podmanRuntime) DefaultLogDriver() string {
+	return LOG_DRIVER_JOURNALD
+}
+
+// Needed in case SELinux is enabled with podman. Podman won't allow the use of the unix domain socket without it.
+func (r *podmanRuntime) SecurityOpts() []string {
+	return []string{"label=disable"}
+}
+
+func (r *podmanRuntime) BridgeOptions() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
+// Containerd, driven directly rather than through the docker API. The output of the containers goes to a log file per
+// container, and there are no bridge networks since the containers share a single CNI network.
+type containerdRuntime struct{}
+
+func (r *containerdRuntime) Name() string {
+	return API_SERVER_TYPE_CONTAINERD
+}
+
+func (r *containerdRuntime) DefaultLogDriver() string {
+	return ""
+}
+
+func (r *containerdRuntime) SecurityOpts() []string {
+	return []string{}
+}
+
+func (r *containerdRuntime) BridgeOptions() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
+// Returns the runtime of the container engine, detecting it the first time it is needed.
+func (w *ContainerWorker) resolveRuntime() (ContainerRuntime, error) {
+	if w.runtime == nil {
+		configured := ""
+		if w.Config != nil {
+			configured = w.Config.GetContainerRuntime()
+		}
+		rt, err := NewContainerRuntime(w.client, configured)
+		if err != nil {
+			return nil, err
+		}
+		glog.V(3).Infof("Using the %v container runtime.", rt.Name())
+		w.runtime = rt
+	}
+	return w.runtime, nil
+}
+
+// Returns the runtime of the container engine. The docker runtime is assumed when the engine cannot be detected.
+func (w *ContainerWorker) GetRuntime() ContainerRuntime {
+	rt, err := w.resolveRuntime()
+	if err != nil {
+		glog.Errorf("Unable to determine the container runtime, assuming %v. %v", API_SERVER_TYPE_DOCKER, err)
+		return new(dockerRuntime)
+	}
+	return rt
+}
@@ -0,0 +1,129 @@
+package container
+
+import (
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"strings"
+)
+
+// The network shared by the service containers of all the agreements when the ServiceDNS setting of the agent
+// configuration is on. The containers of an agreement reach each other on the agreement network, and the services
+// they depend on on the dependency networks, but the services of other agreements can only be reached on this network.
+const SERVICE_DNS_NETWORK = "horizon"
+
+// The domain of the names of the service containers on the shared network, e.g. gps.horizon. The names do not clash
+// with the names of the services on the agreement and dependency networks, which keep resolving to their own containers.
+const SERVICE_DNS_DOMAIN = "horizon"
+
+// Returns the names of a service container on the shared network, <service name>.horizon, and for the variations of a
+// singleton service, <service name>-<variation>.horizon as well. When several agreements run the same service, the
+// name resolves to the containers of all of them.
+func serviceDNSAliases(labels map[string]string) []string {
+	name := labels[LABEL_PREFIX+".service_name"]
+	if name == "" {
+		return nil
+	}
+	aliases := []string{name + "." + SERVICE_DNS_DOMAIN}
+	if variation := labels[LABEL_PREFIX+".variation"]; variation != "" {
+		aliases = append(aliases, name+"-"+variation+"."+SERVICE_DNS_DOMAIN)
+	}
+	return aliases
+}
+
+// The shared network is created by the agent, or by the agent of 'hzn dev'.
+func isServiceDNSNetwork(net *docker.Network) bool {
+	if net.Name != SERVICE_DNS_NETWORK {
+		return false
+	}
+	_, anaxNet := net.Labels[LABEL_PREFIX+".network"]
+	_, devNet := net.Labels[LABEL_PREFIX+".dev_network"]
+	return anaxNet || devNet
+}
+
+// Returns the shared network, creating it when the first service container is connected to it.
+func (b *ContainerWorker) serviceDNSNetwork() (*docker.Network, error) {
+	nws, err := b.client.FilteredListNetworks(docker.NetworkFilterOpts{"name": {SERVICE_DNS_NETWORK: true}})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list networks, error %v", err)
+	}
+
+	// The name filter matches the networks whose names contain the name.
+	for ix := range nws {
+		if nws[ix].Name != SERVICE_DNS_NETWORK {
+			continue
+		} else if !isServiceDNSNetwork(&nws[ix]) {
+			return nil, fmt.Errorf("network %v was not created by the agent", SERVICE_DNS_NETWORK)
+		}
+		return &nws[ix], nil
+	}
+
+	glog.V(3).Infof("ContainerWorker creating shared service network %v", SERVICE_DNS_NETWORK)
+	return MakeBridge(b.client, b.GetRuntime(), SERVICE_DNS_NETWORK, false, false, b.isDevInstance, b.Config.GetServiceNetworkIPv6Prefix())
+}
+
+// Connects the service containers of an agreement to the shared network under their service names. The containers
+// that use the host network, or that are already connected, e.g. a singleton service started by another agreement,
+// are skipped. The containers could be a *docker.APIContainers or a *docker.Container.
+func (b *ContainerWorker) connectServiceDNS(containers []interface{}) error {
+	network, err := b.serviceDNSNetwork()
+	if err != nil {
+		return err
+	}
+
+	for _, con := range containers {
+		var id string
+		switch c := con.(type) {
+		case *docker.Container:
+			id = c.ID
+		case *docker.APIContainers:
+			id = c.ID
+		default:
+			continue
+		}
+
+		container, err := b.client.InspectContainer(id)
+		if err != nil {
+			return fmt.Errorf("unable to inspect container %v, error %v", id, err)
+		} else if container.HostConfig == nil || container.HostConfig.NetworkMode == "host" || strings.HasPrefix(container.HostConfig.NetworkMode, "container:") {
+			continue
+		} else if container.NetworkSettings != nil {
+			if _, connected := container.NetworkSettings.Networks[SERVICE_DNS_NETWORK]; connected {
+				continue
+			}
+		}
+
+		aliases := serviceDNSAliases(container.Config.Labels)
+		if len(aliases) == 0 {
+			continue
+		}
+
+		if err := b.client.ConnectNetwork(network.ID, docker.NetworkConnectionOptions{
+			Container:      container.ID,
+			EndpointConfig: &docker.EndpointConfig{Aliases: aliases, NetworkID: network.ID},
+			Force:          true,
+		}); err != nil {
+			return fmt.Errorf("failed to connect container %v to network %v, error %v", container.Name, network.Name, err)
+		}
+		glog.V(3).Infof("ContainerWorker connected container %v to network %v as %v", container.Name, network.Name, aliases)
+	}
+	return nil
+}
+
+// Removes the shared network once the last service container connected to it is gone. The names of the containers are
+// removed from the network by the container engine along with the containers.
+func (b *ContainerWorker) releaseServiceDNSNetwork(networks []docker.Network) {
+	for _, net := range networks {
+		if !isServiceDNSNetwork(&net) {
+			continue
+		} else if netInfo, err := b.client.NetworkInfo(net.ID); err != nil {
+			glog.Errorf("Failure getting network info for %v. Error: %v", net.Name, err)
+		} else if len(netInfo.Containers) != 0 {
+			glog.V(5).Infof("Shared service network %v has containers %v, so leave it alone", net.Name, netInfo.Containers)
+		} else if err := b.client.RemoveNetwork(net.ID); err != nil {
+			glog.Errorf("Failure removing network: %v. Error: %v", net.Name, err)
+		} else {
+			glog.V(3).Infof("ContainerWorker removed unused shared service network %v", net.Name)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/containermessage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The labels of the service containers that are stopped gracefully: the seconds the container has to exit once it is
+// sent a SIGTERM, and the command run in the container before, in JSON.
+const STOP_TIMEOUT_LABEL = LABEL_PREFIX + ".stop_timeout"
+const PRE_STOP_LABEL = LABEL_PREFIX + ".pre_stop"
+
+// The most output of a pre_stop command that is logged.
+const PRE_STOP_MAX_OUTPUT = 1024
+
+// Sets the labels from which the container is stopped gracefully when the agreement ends, even if the agent restarted.
+func setStopLabels(labels map[string]string, service *containermessage.Service) error {
+	labels[STOP_TIMEOUT_LABEL] = strconv.Itoa(service.StopTimeoutS())
+	if len(service.PreStop) != 0 {
+		if preStop, err := json.Marshal(service.PreStop); err != nil {
+			return err
+		} else {
+			labels[PRE_STOP_LABEL] = string(preStop)
+		}
+	}
+	return nil
+}
+
+// Returns the stop timeout and the pre_stop command of a container, a 0 timeout when the container is killed instead.
+func getStopLabels(labels map[string]string) (int, []string) {
+	stopTimeout, err := strconv.Atoi(labels[STOP_TIMEOUT_LABEL])
+	if err != nil || stopTimeout <= 0 {
+		return 0, nil
+	}
+	var preStop []string
+	if l, ok := labels[PRE_STOP_LABEL]; ok {
+		if err := json.Unmarshal([]byte(l), &preStop); err != nil {
+			glog.Warningf("Ignoring the pre_stop command %v of the container, error: %v", l, err)
+			preStop = nil
+		}
+	}
+	return stopTimeout, preStop
+}
+
+// Runs the pre_stop command of the container, then sends it a SIGTERM and kills it if it has not exited within the stop
+// timeout. The pre_stop command counts towards the stop timeout, so that a command that hangs does not hold up the
+// agreement cancellation.
+func gracefulStop(client *docker.Client, agreementId string, containerId string, stopTimeout int, preStop []string) error {
+	deadline := time.Now().Add(time.Duration(stopTimeout) * time.Second)
+
+	if len(preStop) != 0 {
+		glog.V(3).Infof("Running pre_stop command %v in container %v from agreement: %v.", preStop, containerId, agreementId)
+		if err := runPreStop(client, containerId, preStop, deadline); err != nil {
+			if _, ok := err.(*docker.NoSuchContainer); ok {
+				return err
+			} else if _, ok := err.(*docker.ContainerNotRunning); ok {
+				return err
+			}
+			glog.Warningf("The pre_stop command of container %v in agreement: %v failed. Error: %v. Stopping the container.", containerId, agreementId, err)
+		}
+	}
+
+	remaining := uint(time.Until(deadline).Seconds())
+	glog.V(3).Infof("Sending SIGTERM to container %v from agreement: %v, it will be killed in %v seconds.", containerId, agreementId, remaining)
+	return client.StopContainer(containerId, remaining)
+}
+
+// Runs a command in the container and waits for it to exit, until the deadline. An error is returned if the command
+// could not be run, timed out or exited with a non-zero status.
+func runPreStop(client *docker.Client, containerId string, cmd []string, deadline time.Time) error {
+	exec, err := client.CreateExec(docker.CreateExecOptions{
+		Container:    containerId,
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	var output bytes.Buffer
+	err = client.StartExec(exec.ID, docker.StartExecOptions{
+		OutputStream: &output,
+		ErrorStream:  &output,
+		Context:      ctx,
+	})
+	if ctx.Err() != nil {
+		return fmt.Errorf("the command did not exit within the stop timeout")
+	} else if err != nil {
+		return err
+	}
+
+	out := strings.TrimSpace(output.String())
+	if len(out) > PRE_STOP_MAX_OUTPUT {
+		out = out[:PRE_STOP_MAX_OUTPUT]
+	}
+	if inspect, err := client.InspectExec(exec.ID); err != nil {
+		return err
+	} else if inspect.ExitCode != 0 {
+		return fmt.Errorf("exit code %v, output: %v", inspect.ExitCode, out)
+	}
+	glog.V(5).Infof("The pre_stop command of container %v exited, output: %v", containerId, out)
+	return nil
+}
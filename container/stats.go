@@ -0,0 +1,77 @@
+package container
+
+import (
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/persistence"
+	"strings"
+	"time"
+)
+
+// How long to wait for the docker stats of a container. Docker samples the CPU usage twice, a second apart.
+const DOCKER_STATS_TIMEOUT = 10 * time.Second
+
+// Returns the resource usage of a running container from a single sample of the docker stats API.
+func GetDockerContainerStats(client *docker.Client, id string, name string) (*persistence.ContainerStats, error) {
+	statsC := make(chan *docker.Stats, 1)
+	errC := make(chan error, 1)
+	go func() {
+		errC <- client.Stats(docker.StatsOptions{ID: id, Stats: statsC, Stream: false, Timeout: DOCKER_STATS_TIMEOUT, InactivityTimeout: DOCKER_STATS_TIMEOUT})
+	}()
+
+	var sample *docker.Stats
+	for s := range statsC {
+		sample = s
+	}
+	if err := <-errC; err != nil {
+		return nil, err
+	} else if sample == nil {
+		return nil, fmt.Errorf("no stats returned for container %v", name)
+	}
+
+	stats := convertDockerStats(name, sample)
+	return &stats, nil
+}
+
+// Converts the docker stats of a container the same way the docker stats command does: the CPU usage is the share of
+// the CPU time of the host used by the container between the two samples, and the page cache that can be reclaimed is
+// not counted in the memory usage.
+func convertDockerStats(name string, s *docker.Stats) persistence.ContainerStats {
+	stats := persistence.ContainerStats{Name: name, MemoryLimit: s.MemoryStats.Limit}
+
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemCPUUsage) - float64(s.PreCPUStats.SystemCPUUsage)
+	cpus := float64(s.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(s.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		stats.CPUPercent = cpuDelta / systemDelta * cpus * 100
+	}
+
+	// cgroup v1 reports total_inactive_file, cgroup v2 inactive_file.
+	inactive := s.MemoryStats.Stats.TotalInactiveFile
+	if inactive == 0 {
+		inactive = s.MemoryStats.Stats.InactiveFile
+	}
+	stats.MemoryUsage = s.MemoryStats.Usage
+	if inactive < stats.MemoryUsage {
+		stats.MemoryUsage -= inactive
+	}
+
+	for _, nw := range s.Networks {
+		stats.NetworkRxBytes += nw.RxBytes
+		stats.NetworkTxBytes += nw.TxBytes
+	}
+
+	// cgroup v1 reports the operations capitalized, cgroup v2 in lower case.
+	for _, entry := range s.BlkioStats.IOServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			stats.BlockReadBytes += entry.Value
+		case "write":
+			stats.BlockWriteBytes += entry.Value
+		}
+	}
+	return stats
+}
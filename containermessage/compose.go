@@ -0,0 +1,609 @@
+package containermessage
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"gopkg.in/yaml.v2"
+)
+
+/*
+ *
+ * A service deployment can also be a docker compose file (a subset of the version 3 format), encoded in base64:
+ *
+ * {
+ *   "compose": "<base64 encoded docker-compose.yaml>"
+ * }
+ *
+ * The compose file is translated into the native deployment description when the deployment string is unmarshalled.
+ * Each compose service becomes a native service. All the services of the deployment are attached to the same
+ * agreement network, so the compose networks only have to be declared. Named volumes become the named volumes of
+ * the services, retained across upgrades like compose keeps them across "down" and "up", and depends_on sets the order
+ * in which the containers are started.
+ */
+
+// The key in the deployment string that holds the compose file
+const COMPOSE_DEPLOYMENT_KEY = "compose"
+
+type ComposeFile struct {
+	Version  string                          `yaml:"version"`
+	Services map[string]*ComposeService      `yaml:"services"`
+	Networks map[string]*ComposeNetwork      `yaml:"networks"`
+	Volumes  map[string]*ComposeVolumeConfig `yaml:"volumes"`
+}
+
+type ComposeService struct {
+	Image       string                 `yaml:"image"`
+	Command     interface{}            `yaml:"command"`     // string or list of strings
+	Entrypoint  interface{}            `yaml:"entrypoint"`  // string or list of strings
+	Environment interface{}            `yaml:"environment"` // list of "KEY=value" or map
+	Ports       []interface{}          `yaml:"ports"`       // "[ip:]host:container[/protocol]", "container[/protocol]" or the long syntax
+	Volumes     []interface{}          `yaml:"volumes"`     // "source:target[:ro]" or the long syntax
+	Devices     []string               `yaml:"devices"`
+	Privileged  bool                   `yaml:"privileged"`
+	CapAdd      []string               `yaml:"cap_add"`
+	NetworkMode string                 `yaml:"network_mode"`
+	Networks    interface{}            `yaml:"networks"`   // list of names or map of names
+	DependsOn   interface{}            `yaml:"depends_on"` // list of names or map of names
+	User        string                 `yaml:"user"`
+	Pid         string                 `yaml:"pid"`
+	Sysctls     interface{}            `yaml:"sysctls"` // list of "key=value" or map
+	SecurityOpt []string               `yaml:"security_opt"`
+	Tmpfs       interface{}            `yaml:"tmpfs"` // string or list of "path[:options]"
+	Logging     *ComposeLogging        `yaml:"logging"`
+	MemLimit    string                 `yaml:"mem_limit"`
+	Cpus        float32                `yaml:"cpus"`
+	Deploy      *ComposeDeploy         `yaml:"deploy"`
+	Restart     string                 `yaml:"restart"`           // "no", "always", "unless-stopped" or "on-failure[:max-retries]"
+	Labels      map[string]interface{} `yaml:"labels"`            // ignored, the agent sets its own labels
+	StopGrace   string                 `yaml:"stop_grace_period"` // a duration, e.g. "1m30s"
+}
+
+type ComposeLogging struct {
+	Driver  string            `yaml:"driver"`
+	Options map[string]string `yaml:"options"`
+}
+
+type ComposeDeploy struct {
+	Resources struct {
+		Limits struct {
+			Cpus   string `yaml:"cpus"`
+			Memory string `yaml:"memory"`
+		} `yaml:"limits"`
+		Reservations struct {
+			Devices []ComposeDeviceRequest `yaml:"devices"`
+		} `yaml:"reservations"`
+	} `yaml:"resources"`
+	RestartPolicy *struct {
+		Condition   string `yaml:"condition"` // "none", "on-failure" or "any"
+		MaxAttempts int    `yaml:"max_attempts"`
+	} `yaml:"restart_policy"`
+}
+
+// A device reservation, only the gpu devices are supported
+type ComposeDeviceRequest struct {
+	Capabilities []string    `yaml:"capabilities"`
+	Count        interface{} `yaml:"count"` // a number or "all"
+	DeviceIDs    []string    `yaml:"device_ids"`
+	Driver       string      `yaml:"driver"`
+}
+
+type ComposeNetwork struct {
+	Driver   string `yaml:"driver"`
+	External bool   `yaml:"external"`
+}
+
+type ComposeVolumeConfig struct {
+	Driver   string `yaml:"driver"`
+	External bool   `yaml:"external"`
+}
+
+type composePort struct {
+	Target    int    `yaml:"target"`
+	Published int    `yaml:"published"`
+	Protocol  string `yaml:"protocol"`
+	HostIP    string `yaml:"host_ip"`
+}
+
+type composeVolume struct {
+	Type     string `yaml:"type"`
+	Source   string `yaml:"source"`
+	Target   string `yaml:"target"`
+	ReadOnly bool   `yaml:"read_only"`
+}
+
+// ConvertComposeDeployment translates the base64 encoded compose file into a native deployment description
+func ConvertComposeDeployment(b64Compose string) (*DeploymentDescription, error) {
+	composeBytes, err := base64.StdEncoding.DecodeString(b64Compose)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("the %v attribute is not base64 encoded: %v", COMPOSE_DEPLOYMENT_KEY, err))
+	}
+	return ConvertCompose(composeBytes)
+}
+
+// ConvertCompose translates the compose file into a native deployment description. An error is returned for the
+// compose attributes that the agent does not support.
+func ConvertCompose(composeBytes []byte) (*DeploymentDescription, error) {
+	compose := new(ComposeFile)
+	if err := yaml.UnmarshalStrict(composeBytes, compose); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to parse the compose file: %v", err))
+	} else if len(compose.Services) == 0 {
+		return nil, errors.New(fmt.Sprintf("the compose file has no services"))
+	} else if compose.Version != "" && !strings.HasPrefix(compose.Version, "3") {
+		return nil, errors.New(fmt.Sprintf("compose file version %v is not supported, only version 3 is supported", compose.Version))
+	}
+
+	for name, network := range compose.Networks {
+		if network != nil && (network.External || (network.Driver != "" && network.Driver != "bridge")) {
+			return nil, errors.New(fmt.Sprintf("network %v is not supported, only bridge networks that are not external are supported", name))
+		}
+	}
+	for name, volume := range compose.Volumes {
+		if volume != nil && (volume.External || (volume.Driver != "" && volume.Driver != "local")) {
+			return nil, errors.New(fmt.Sprintf("volume %v is not supported, only local volumes that are not external are supported", name))
+		}
+	}
+
+	dd := &DeploymentDescription{Services: map[string]*Service{}}
+	for name, cs := range compose.Services {
+		if cs == nil {
+			return nil, errors.New(fmt.Sprintf("service %v has no attributes", name))
+		}
+		svc, err := cs.convert(compose)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("service %v: %v", name, err))
+		}
+		dd.Services[name] = svc
+	}
+
+	if err := dd.ValidatePods(); err != nil {
+		return nil, err
+	} else if _, err := dd.StartOrder(); err != nil {
+		return nil, err
+	}
+	return dd, nil
+}
+
+func (cs *ComposeService) convert(compose *ComposeFile) (*Service, error) {
+	if cs.Image == "" {
+		return nil, errors.New(fmt.Sprintf("image is required, build is not supported"))
+	}
+
+	svc := &Service{
+		Image:       cs.Image,
+		Privileged:  cs.Privileged,
+		Devices:     cs.Devices,
+		CapAdd:      cs.CapAdd,
+		User:        cs.User,
+		PID:         cs.Pid,
+		SecurityOpt: cs.SecurityOpt,
+		MaxCPUs:     cs.Cpus,
+	}
+
+	var err error
+	if svc.Command, err = composeCommand(cs.Command); err != nil {
+		return nil, errors.New(fmt.Sprintf("command: %v", err))
+	} else if svc.Entrypoint, err = composeCommand(cs.Entrypoint); err != nil {
+		return nil, errors.New(fmt.Sprintf("entrypoint: %v", err))
+	} else if svc.Environment, err = composeKeyValues(cs.Environment); err != nil {
+		return nil, errors.New(fmt.Sprintf("environment: %v", err))
+	} else if svc.DependsOn, err = composeNames(cs.DependsOn); err != nil {
+		return nil, errors.New(fmt.Sprintf("depends_on: %v", err))
+	}
+
+	if sysctls, err := composeKeyValues(cs.Sysctls); err != nil {
+		return nil, errors.New(fmt.Sprintf("sysctls: %v", err))
+	} else if len(sysctls) > 0 {
+		svc.Sysctls = map[string]string{}
+		for _, kv := range sysctls {
+			parts := strings.SplitN(kv, "=", 2)
+			svc.Sysctls[parts[0]] = parts[1]
+		}
+	}
+
+	// all the services are on the agreement network, so the compose networks only need to be declared
+	switch {
+	case cs.NetworkMode == "" || cs.NetworkMode == "bridge":
+	case cs.NetworkMode == "host":
+		svc.Network = "host"
+	case strings.HasPrefix(cs.NetworkMode, NETWORK_SERVICE_PREFIX):
+		// the service joins the network namespace of the other service, like the containers of a pod
+		svc.Network = cs.NetworkMode
+	default:
+		return nil, errors.New(fmt.Sprintf("network_mode %v is not supported", cs.NetworkMode))
+	}
+	if networks, err := composeNames(cs.Networks); err != nil {
+		return nil, errors.New(fmt.Sprintf("networks: %v", err))
+	} else {
+		for _, network := range networks {
+			if _, ok := compose.Networks[network]; !ok && network != "default" {
+				return nil, errors.New(fmt.Sprintf("network %v is not declared in the top level networks", network))
+			}
+		}
+	}
+
+	for _, p := range cs.Ports {
+		if err := svc.addComposePort(p); err != nil {
+			return nil, errors.New(fmt.Sprintf("ports: %v", err))
+		}
+	}
+
+	for _, v := range cs.Volumes {
+		if err := svc.addComposeVolume(v, compose.Volumes); err != nil {
+			return nil, errors.New(fmt.Sprintf("volumes: %v", err))
+		}
+	}
+
+	if tmpfs, err := composeStrings(cs.Tmpfs); err != nil {
+		return nil, errors.New(fmt.Sprintf("tmpfs: %v", err))
+	} else {
+		for _, t := range tmpfs {
+			parts := strings.SplitN(t, ":", 2)
+			if svc.Tmpfs == nil {
+				svc.Tmpfs = map[string]string{}
+			}
+			svc.Tmpfs[parts[0]] = ""
+			if len(parts) == 2 {
+				svc.Tmpfs[parts[0]] = parts[1]
+			}
+		}
+	}
+
+	if cs.Logging != nil {
+		svc.LogDriver = cs.Logging.Driver
+		svc.LogOptions = cs.Logging.Options
+	}
+
+	if cs.Restart != "" {
+		if svc.RestartPolicy, err = composeRestartPolicy(cs.Restart); err != nil {
+			return nil, err
+		}
+	}
+
+	if cs.StopGrace != "" {
+		if grace, err := time.ParseDuration(cs.StopGrace); err != nil {
+			return nil, errors.New(fmt.Sprintf("stop_grace_period %v is not a duration", cs.StopGrace))
+		} else {
+			svc.StopTimeout = int(math.Ceil(grace.Seconds()))
+			if err := svc.ValidateStop(); err != nil {
+				return nil, errors.New(fmt.Sprintf("stop_grace_period: %v", err))
+			}
+		}
+	}
+
+	memory := cs.MemLimit
+	if cs.Deploy != nil {
+		if cs.Deploy.Resources.Limits.Memory != "" {
+			memory = cs.Deploy.Resources.Limits.Memory
+		}
+		if cs.Deploy.Resources.Limits.Cpus != "" {
+			if cpus, err := strconv.ParseFloat(cs.Deploy.Resources.Limits.Cpus, 32); err != nil {
+				return nil, errors.New(fmt.Sprintf("deploy.resources.limits.cpus %v is not a number", cs.Deploy.Resources.Limits.Cpus))
+			} else {
+				svc.MaxCPUs = float32(cpus)
+			}
+		}
+		for _, dr := range cs.Deploy.Resources.Reservations.Devices {
+			if svc.GPUs, err = dr.gpus(); err != nil {
+				return nil, errors.New(fmt.Sprintf("deploy.resources.reservations.devices: %v", err))
+			}
+		}
+		if rp := cs.Deploy.RestartPolicy; rp != nil {
+			svc.RestartPolicy = &RestartPolicy{Name: RESTART_POLICY_ALWAYS}
+			switch rp.Condition {
+			case "", "any":
+			case "none":
+				svc.RestartPolicy.Name = RESTART_POLICY_NO
+			case "on-failure":
+				svc.RestartPolicy.Name = RESTART_POLICY_ON_FAILURE
+				svc.RestartPolicy.MaxRetries = rp.MaxAttempts
+			default:
+				return nil, errors.New(fmt.Sprintf("deploy.restart_policy.condition %v is not supported", rp.Condition))
+			}
+			if err := svc.RestartPolicy.Validate(); err != nil {
+				return nil, errors.New(fmt.Sprintf("deploy.restart_policy: %v", err))
+			}
+		}
+	}
+	if memory != "" {
+		if svc.MaxMemoryMb, err = composeMemoryMb(memory); err != nil {
+			return nil, err
+		}
+	}
+
+	return svc, nil
+}
+
+// convert the restart option of a service. The agent removes the containers it stops, so unless-stopped is the same as always.
+func composeRestartPolicy(restart string) (*RestartPolicy, error) {
+	rp := &RestartPolicy{}
+	parts := strings.SplitN(restart, ":", 2)
+	switch {
+	case restart == "always" || restart == "unless-stopped":
+		rp.Name = RESTART_POLICY_ALWAYS
+	case restart == "no":
+		rp.Name = RESTART_POLICY_NO
+	case parts[0] == "on-failure":
+		rp.Name = RESTART_POLICY_ON_FAILURE
+		if len(parts) == 2 {
+			if n, err := strconv.Atoi(parts[1]); err != nil {
+				return nil, errors.New(fmt.Sprintf("restart %v max retries is not a number", restart))
+			} else {
+				rp.MaxRetries = n
+			}
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("restart %v is not supported", restart))
+	}
+	if err := rp.Validate(); err != nil {
+		return nil, errors.New(fmt.Sprintf("restart %v: %v", restart, err))
+	}
+	return rp, nil
+}
+
+// convert a gpu device reservation, the "gpu" capability selects the gpu devices
+func (dr ComposeDeviceRequest) gpus() (*GPUs, error) {
+	gpus := &GPUs{DeviceIDs: dr.DeviceIDs, Driver: dr.Driver}
+
+	isGPU := false
+	for _, c := range dr.Capabilities {
+		if c == "gpu" {
+			isGPU = true
+		} else {
+			gpus.Capabilities = append(gpus.Capabilities, c)
+		}
+	}
+	if !isGPU {
+		return nil, errors.New(fmt.Sprintf("only gpu devices are supported, capabilities %v", dr.Capabilities))
+	}
+
+	switch count := dr.Count.(type) {
+	case nil:
+	case int:
+		gpus.Count = count
+	case string:
+		if count != "all" {
+			return nil, errors.New(fmt.Sprintf("count %v is not a number or all", count))
+		}
+		gpus.Count = -1
+	default:
+		return nil, errors.New(fmt.Sprintf("count %v is not a number or all", count))
+	}
+
+	if _, err := gpus.DeviceRequest(); err != nil {
+		return nil, err
+	}
+	return gpus, nil
+}
+
+// add a port in the short syntax "[ip:]host:container[/protocol]" or "container[/protocol]", or in the long syntax
+func (s *Service) addComposePort(p interface{}) error {
+	var port composePort
+	switch typed := p.(type) {
+	case int:
+		port.Target = typed
+	case string:
+		protocol := ""
+		spec := typed
+		if i := strings.LastIndex(spec, "/"); i >= 0 {
+			spec, protocol = spec[:i], spec[i+1:]
+		}
+		if strings.Contains(spec, "-") {
+			return errors.New(fmt.Sprintf("port ranges are not supported: %v", typed))
+		}
+		parts := strings.Split(spec, ":")
+		var err error
+		switch len(parts) {
+		case 1:
+			port.Target, err = strconv.Atoi(parts[0])
+		case 2:
+			if port.Published, err = strconv.Atoi(parts[0]); err == nil {
+				port.Target, err = strconv.Atoi(parts[1])
+			}
+		case 3:
+			port.HostIP = parts[0]
+			if port.Published, err = strconv.Atoi(parts[1]); err == nil {
+				port.Target, err = strconv.Atoi(parts[2])
+			}
+		default:
+			return errors.New(fmt.Sprintf("port %v is not supported", typed))
+		}
+		if err != nil {
+			return errors.New(fmt.Sprintf("port %v is not valid: %v", typed, err))
+		}
+		port.Protocol = protocol
+	case map[interface{}]interface{}:
+		if err := remarshal(typed, &port); err != nil {
+			return err
+		}
+	default:
+		return errors.New(fmt.Sprintf("port %v has wrong format", p))
+	}
+
+	if port.Target == 0 {
+		return errors.New(fmt.Sprintf("port %v has no container port", p))
+	} else if port.Protocol == "" {
+		port.Protocol = "tcp"
+	}
+
+	if port.Published == 0 {
+		s.EphemeralPorts = append(s.EphemeralPorts, Port{PortAndProtocol: fmt.Sprintf("%d/%s", port.Target, port.Protocol)})
+	} else {
+		s.AddSpecificPortBinding(docker.PortBinding{HostIP: port.HostIP, HostPort: fmt.Sprintf("%d:%d/%s", port.Published, port.Target, port.Protocol)})
+	}
+	return nil
+}
+
+// add a volume in the short syntax "source:target[:mode]" or in the long syntax. The named volumes must be declared
+// in the top level volumes.
+func (s *Service) addComposeVolume(v interface{}, declared map[string]*ComposeVolumeConfig) error {
+	var volume composeVolume
+	switch typed := v.(type) {
+	case string:
+		parts := strings.Split(typed, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return errors.New(fmt.Sprintf("volume %v is not supported, the source and the target are required", typed))
+		}
+		volume.Source, volume.Target = parts[0], parts[1]
+		volume.ReadOnly = len(parts) == 3 && parts[2] == "ro"
+		if strings.HasPrefix(volume.Source, "/") || strings.HasPrefix(volume.Source, ".") {
+			volume.Type = "bind"
+		} else {
+			volume.Type = "volume"
+		}
+	case map[interface{}]interface{}:
+		if err := remarshal(typed, &volume); err != nil {
+			return err
+		}
+	default:
+		return errors.New(fmt.Sprintf("volume %v has wrong format", v))
+	}
+
+	switch volume.Type {
+	case "tmpfs":
+		if s.Tmpfs == nil {
+			s.Tmpfs = map[string]string{}
+		}
+		s.Tmpfs[volume.Target] = ""
+		return nil
+	case "volume":
+		if _, ok := declared[volume.Source]; !ok {
+			return errors.New(fmt.Sprintf("volume %v is not declared in the top level volumes", volume.Source))
+		}
+		named := Volume{Name: volume.Source, Target: volume.Target, ReadOnly: volume.ReadOnly, Retain: VOLUME_RETAIN_UPGRADE}
+		if err := named.Validate(); err != nil {
+			return err
+		}
+		s.Volumes = append(s.Volumes, named)
+		return nil
+	case "bind":
+		if !strings.HasPrefix(volume.Source, "/") {
+			return errors.New(fmt.Sprintf("bind mount %v must use an absolute host path", volume.Source))
+		}
+	default:
+		return errors.New(fmt.Sprintf("volume type %v is not supported", volume.Type))
+	}
+
+	if volume.Source == "" || volume.Target == "" {
+		return errors.New(fmt.Sprintf("volume %v is not supported, the source and the target are required", v))
+	}
+	bind := fmt.Sprintf("%s:%s", volume.Source, volume.Target)
+	if volume.ReadOnly {
+		bind += ":ro"
+	}
+	s.AddFilesystemBinding(bind)
+	return nil
+}
+
+// convert the long syntax of an attribute into its struct
+func remarshal(in interface{}, out interface{}) error {
+	if b, err := yaml.Marshal(in); err != nil {
+		return err
+	} else if err := yaml.UnmarshalStrict(b, out); err != nil {
+		return errors.New(fmt.Sprintf("%v has wrong format: %v", in, err))
+	}
+	return nil
+}
+
+// a string is split on white space, quoting is not supported
+func composeCommand(cmd interface{}) ([]string, error) {
+	if s, ok := cmd.(string); ok {
+		if strings.ContainsAny(s, "\"'") {
+			return nil, errors.New(fmt.Sprintf("quotes are not supported in %v, use the list form", s))
+		}
+		return strings.Fields(s), nil
+	}
+	return composeStrings(cmd)
+}
+
+func composeStrings(in interface{}) ([]string, error) {
+	switch typed := in.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{typed}, nil
+	case []interface{}:
+		out := []string{}
+		for _, v := range typed {
+			out = append(out, fmt.Sprintf("%v", v))
+		}
+		return out, nil
+	}
+	return nil, errors.New(fmt.Sprintf("%v must be a string or a list of strings", in))
+}
+
+// a list of "key=value" or a map, returned as a sorted list of "key=value"
+func composeKeyValues(in interface{}) ([]string, error) {
+	switch typed := in.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		out := []string{}
+		for _, v := range typed {
+			kv := fmt.Sprintf("%v", v)
+			if !strings.Contains(kv, "=") {
+				return nil, errors.New(fmt.Sprintf("%v must have the form key=value", kv))
+			}
+			out = append(out, kv)
+		}
+		return out, nil
+	case map[interface{}]interface{}:
+		out := []string{}
+		for k, v := range typed {
+			if v == nil {
+				v = ""
+			}
+			out = append(out, fmt.Sprintf("%v=%v", k, v))
+		}
+		sort.Strings(out)
+		return out, nil
+	}
+	return nil, errors.New(fmt.Sprintf("%v must be a list or a map", in))
+}
+
+// a list of names or a map keyed by the names, returned sorted
+func composeNames(in interface{}) ([]string, error) {
+	switch typed := in.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		return composeStrings(typed)
+	case map[interface{}]interface{}:
+		out := []string{}
+		for k, v := range typed {
+			if condition, ok := v.(map[interface{}]interface{}); ok {
+				if c, ok := condition["condition"]; ok && c != "service_started" {
+					return nil, errors.New(fmt.Sprintf("condition %v is not supported", c))
+				}
+			}
+			out = append(out, fmt.Sprintf("%v", k))
+		}
+		sort.Strings(out)
+		return out, nil
+	}
+	return nil, errors.New(fmt.Sprintf("%v must be a list or a map", in))
+}
+
+// convert a compose memory size (e.g. 512m, 1g) to megabytes. A size that is not a whole number of megabytes is rounded up,
+// 0 means no limit so a limit under 1 MB cannot be truncated to it.
+func composeMemoryMb(mem string) (int64, error) {
+	units := map[string]float64{"b": 1.0 / (1024 * 1024), "k": 1.0 / 1024, "kb": 1.0 / 1024, "m": 1, "mb": 1, "g": 1024, "gb": 1024}
+	lower := strings.ToLower(strings.TrimSpace(mem))
+	num := strings.TrimRight(lower, "bkmg")
+	factor, ok := units[lower[len(num):]]
+	if !ok && lower[len(num):] == "" {
+		factor, ok = units["b"]
+	}
+	if value, err := strconv.ParseFloat(num, 64); err != nil || !ok || value < 0 {
+		return 0, errors.New(fmt.Sprintf("memory limit %v is not valid", mem))
+	} else {
+		return int64(math.Ceil(value * factor)), nil
+	}
+}
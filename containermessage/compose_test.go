@@ -0,0 +1,223 @@
+//go:build unit
+// +build unit
+
+package containermessage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testCompose = `
+version: "3.8"
+services:
+  web:
+    image: nginx:1.25
+    ports:
+      - "8080:80"
+      - "127.0.0.1:8443:443/tcp"
+      - "9000"
+    environment:
+      MODE: prod
+      LEVEL: 2
+    volumes:
+      - webdata:/usr/share/nginx/html:ro
+      - /var/log/web:/var/log/nginx
+    networks:
+      - front
+    depends_on:
+      - api
+    deploy:
+      resources:
+        limits:
+          memory: 256m
+          cpus: "0.5"
+    restart: always
+  api:
+    image: myorg/api@sha256:0123
+    command: ["serve", "--port", "9090"]
+    environment:
+      - DB=cache
+    depends_on:
+      cache:
+        condition: service_started
+    tmpfs: /tmp
+    restart: on-failure:3
+    deploy:
+      resources:
+        reservations:
+          devices:
+            - driver: nvidia
+              count: 1
+              capabilities: [gpu, compute]
+  proxy:
+    image: envoyproxy/envoy:v1.28
+    network_mode: "service:api"
+  cache:
+    image: redis:7
+    network_mode: host
+    stop_grace_period: 1m30s
+    sysctls:
+      net.core.somaxconn: 1024
+    logging:
+      driver: json-file
+      options:
+        max-size: 5m
+        max-file: 2
+networks:
+  front:
+volumes:
+  webdata:
+`
+
+// Verify that a compose file is translated into the native services.
+func Test_ConvertCompose(t *testing.T) {
+
+	dd, err := ConvertCompose([]byte(testCompose))
+	if err != nil {
+		t.Fatalf("should not return error, but got %v", err)
+	} else if len(dd.Services) != 4 {
+		t.Fatalf("expected 4 services, got %v", dd.Services)
+	}
+
+	web := dd.Services["web"]
+	if len(web.Ports) != 2 || web.Ports[0].HostPort != "8080:80/tcp" || web.Ports[1].HostIP != "127.0.0.1" || web.Ports[1].HostPort != "8443:443/tcp" {
+		t.Errorf("wrong ports %v", web.Ports)
+	} else if len(web.EphemeralPorts) != 1 || web.EphemeralPorts[0].PortAndProtocol != "9000/tcp" {
+		t.Errorf("wrong ephemeral ports %v", web.EphemeralPorts)
+	} else if !reflect.DeepEqual(web.Environment, []string{"LEVEL=2", "MODE=prod"}) {
+		t.Errorf("wrong environment %v", web.Environment)
+	} else if !reflect.DeepEqual(web.Binds, []string{"/var/log/web:/var/log/nginx"}) {
+		t.Errorf("wrong binds %v", web.Binds)
+	} else if !reflect.DeepEqual(web.Volumes, []Volume{{Name: "webdata", Target: "/usr/share/nginx/html", ReadOnly: true, Retain: VOLUME_RETAIN_UPGRADE}}) {
+		t.Errorf("wrong volumes %v", web.Volumes)
+	} else if web.MaxMemoryMb != 256 || web.MaxCPUs != 0.5 {
+		t.Errorf("wrong limits %v %v", web.MaxMemoryMb, web.MaxCPUs)
+	} else if !reflect.DeepEqual(web.DependsOn, []string{"api"}) {
+		t.Errorf("wrong depends_on %v", web.DependsOn)
+	} else if web.RestartPolicy == nil || web.RestartPolicy.Name != RESTART_POLICY_ALWAYS {
+		t.Errorf("wrong restart policy %v", web.RestartPolicy)
+	}
+
+	api := dd.Services["api"]
+	if !reflect.DeepEqual(api.Command, []string{"serve", "--port", "9090"}) {
+		t.Errorf("wrong command %v", api.Command)
+	} else if !reflect.DeepEqual(api.DependsOn, []string{"cache"}) {
+		t.Errorf("wrong depends_on %v", api.DependsOn)
+	} else if _, ok := api.Tmpfs["/tmp"]; !ok {
+		t.Errorf("wrong tmpfs %v", api.Tmpfs)
+	} else if api.GPUs == nil || api.GPUs.Count != 1 || !reflect.DeepEqual(api.GPUs.Capabilities, []string{"compute"}) {
+		t.Errorf("wrong gpus %v", api.GPUs)
+	} else if api.RestartPolicy == nil || api.RestartPolicy.Name != RESTART_POLICY_ON_FAILURE || api.RestartPolicy.MaxRetries != 3 {
+		t.Errorf("wrong restart policy %v", api.RestartPolicy)
+	}
+
+	cache := dd.Services["cache"]
+	if cache.Network != "host" || cache.Sysctls["net.core.somaxconn"] != "1024" {
+		t.Errorf("wrong network %v or sysctls %v", cache.Network, cache.Sysctls)
+	} else if cache.LogDriver != "json-file" || cache.LogOptions["max-size"] != "5m" || cache.LogOptions["max-file"] != "2" {
+		t.Errorf("wrong logging %v %v", cache.LogDriver, cache.LogOptions)
+	} else if cache.RestartPolicy != nil {
+		t.Errorf("wrong restart policy %v", cache.RestartPolicy)
+	} else if cache.StopTimeout != 90 {
+		t.Errorf("wrong stop timeout %v", cache.StopTimeout)
+	}
+
+	if proxy := dd.Services["proxy"]; proxy.PodService() != "api" {
+		t.Errorf("wrong network %v", proxy.Network)
+	}
+
+	if order, err := dd.StartOrder(); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if !reflect.DeepEqual(order, []string{"cache", "api", "proxy", "web"}) {
+		t.Errorf("wrong start order %v", order)
+	}
+}
+
+// Verify that the compose attributes the agent cannot honor are rejected.
+func Test_ConvertCompose_unsupported(t *testing.T) {
+
+	tests := map[string]string{
+		"build":             "services:\n  a:\n    build: .\n",
+		"no image":          "services:\n  a:\n    privileged: true\n",
+		"undeclared vol":    "services:\n  a:\n    image: x\n    volumes:\n      - data:/data\n",
+		"relative bind":     "services:\n  a:\n    image: x\n    volumes:\n      - ./data:/data\n",
+		"external net":      "services:\n  a:\n    image: x\nnetworks:\n  n:\n    external: true\n",
+		"port range":        "services:\n  a:\n    image: x\n    ports:\n      - \"8000-8010:8000-8010\"\n",
+		"network mode":      "services:\n  a:\n    image: x\n    network_mode: \"service:b\"\n",
+		"container network": "services:\n  a:\n    image: x\n    network_mode: \"container:b\"\n",
+		"unknown dep":       "services:\n  a:\n    image: x\n    depends_on:\n      - b\n",
+		"cycle":             "services:\n  a:\n    image: x\n    depends_on: [b]\n  b:\n    image: y\n    depends_on: [a]\n",
+		"healthy":           "services:\n  a:\n    image: x\n    depends_on:\n      b:\n        condition: service_healthy\n  b:\n    image: y\n",
+		"version 2":         "version: \"2\"\nservices:\n  a:\n    image: x\n",
+		"quoted command":    "services:\n  a:\n    image: x\n    command: sh -c 'echo hi'\n",
+		"bad memory":        "services:\n  a:\n    image: x\n    mem_limit: lots\n",
+		"negative memory":   "services:\n  a:\n    image: x\n    mem_limit: -1m\n",
+		"no services":       "version: \"3\"\n",
+		"anonymous volume":  "services:\n  a:\n    image: x\n    volumes:\n      - /data\n",
+		"bad restart":       "services:\n  a:\n    image: x\n    restart: always:2\n",
+		"bad condition":     "services:\n  a:\n    image: x\n    deploy:\n      restart_policy:\n        condition: sometimes\n",
+		"bad grace period":  "services:\n  a:\n    image: x\n    stop_grace_period: forever\n",
+		"not a gpu":         "services:\n  a:\n    image: x\n    deploy:\n      resources:\n        reservations:\n          devices:\n            - capabilities: [tpu]\n",
+	}
+
+	for name, compose := range tests {
+		if _, err := ConvertCompose([]byte(compose)); err == nil {
+			t.Errorf("%v: should have returned an error", name)
+		}
+	}
+}
+
+// Verify that the compose memory sizes are converted to megabytes, and that a limit under 1 MB is not lost.
+func Test_composeMemoryMb(t *testing.T) {
+
+	tests := map[string]int64{
+		"512m":  512,
+		"1g":    1024,
+		"1.5GB": 1536,
+		"2048k": 2,
+		"1536k": 2,
+		"1024":  1,
+		"1b":    1,
+		"0":     0,
+	}
+
+	for mem, expected := range tests {
+		if mb, err := composeMemoryMb(mem); err != nil {
+			t.Errorf("%v: should not return error, but got %v", mem, err)
+		} else if mb != expected {
+			t.Errorf("%v: should be %v MB, but got %v", mem, expected, mb)
+		}
+	}
+}
+
+// Verify that a deployment string holding a compose file unmarshals into the native deployment description.
+func Test_DeploymentDescription_UnmarshalCompose(t *testing.T) {
+
+	b64 := base64.StdEncoding.EncodeToString([]byte(testCompose))
+	depStr := fmt.Sprintf(`{"%v": "%v"}`, COMPOSE_DEPLOYMENT_KEY, b64)
+
+	if dd, err := GetNativeDeployment(depStr); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(dd.Services) != 4 || dd.Services["web"].Image != "nginx:1.25" {
+		t.Errorf("wrong services %v", dd.Services)
+	}
+
+	// a native deployment is not changed
+	dd := new(DeploymentDescription)
+	if err := json.Unmarshal([]byte(`{"services": {"a": {"image": "x", "depends_on": ["b"]}, "b": {"image": "y"}}, "infrastructure": true}`), dd); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if !dd.Infrastructure || len(dd.Services) != 2 || !reflect.DeepEqual(dd.Services["a"].DependsOn, []string{"b"}) {
+		t.Errorf("wrong deployment %v", dd)
+	}
+
+	// both kinds of deployment in the same string
+	depStr = fmt.Sprintf(`{"services": {"a": {"image": "x"}}, "%v": "%v"}`, COMPOSE_DEPLOYMENT_KEY, b64)
+	if err := json.Unmarshal([]byte(depStr), dd); err == nil || !strings.Contains(err.Error(), "both") {
+		t.Errorf("should have returned an error, got %v", err)
+	}
+}
@@ -5,7 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"path"
 	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	docker "github.com/fsouza/go-dockerclient"
@@ -27,10 +31,26 @@ import (
  *       "devices": [
  *         "/dev/bus/usb/001/001:/dev/bus/usb/001/001"
  *       ],
+ *       "gpus": {
+ *         "count": 1,
+ *         "capabilities": ["compute", "utility"]
+ *       },
+ *       "restart_policy": {
+ *         "name": "on-failure",
+ *         "max_retries": 5,
+ *         "backoff_max_s": 300
+ *       },
  *       "binds": [
  *         "/tmp/testdata:/tmp/mydata:ro",
  *         "myvolume1:/tmp/mydata2"
  *       ],
+ *       "volumes": [
+ *         {
+ *           "name": "models",
+ *           "target": "/var/lib/models",
+ *           "retain": "upgrade"
+ *         }
+ *       ],
  *       "ports": [
  *         {
  *           "HostPort":"5200:6414/tcp",
@@ -48,6 +68,12 @@ import (
  *     },
  *     "service_b": {
  *       "image": "...",
+ *       "security_opts": {
+ *         "read_only_root_filesystem": true,
+ *         "seccomp_profile": "default",
+ *         "cap_drop": ["ALL"],
+ *         "cap_add": ["NET_BIND_SERVICE"]
+ *       },
  *       "network_isolation": {
  *         "outbound_permit_only_ignore": "ETH_ACCT_SPECIFIED",
  *         "outbound_permit_only": [
@@ -121,6 +147,114 @@ func (d DeploymentDescription) ServiceNames() []string {
 	return names
 }
 
+// UnmarshalJSON also accepts a deployment that holds a compose file, which is translated into the native services.
+func (d *DeploymentDescription) UnmarshalJSON(data []byte) error {
+	type nativeDeployment DeploymentDescription
+	type polyDType struct {
+		nativeDeployment
+		Compose string `json:"compose,omitempty"`
+	}
+
+	var polyD polyDType
+	if err := json.Unmarshal(data, &polyD); err != nil {
+		return err
+	}
+
+	*d = DeploymentDescription(polyD.nativeDeployment)
+	if polyD.Compose != "" {
+		if len(d.Services) != 0 {
+			return errors.New(fmt.Sprintf("a deployment cannot have both services and a compose file"))
+		}
+		dd, err := ConvertComposeDeployment(polyD.Compose)
+		if err != nil {
+			return err
+		}
+		d.Services = dd.Services
+	}
+	return nil
+}
+
+// StartOrder returns the service names in the order the containers are started, so that each service is started after
+// the services it depends on and after the service whose network it joins. An error is returned if a dependency is not in the deployment or if there is a cycle.
+func (d DeploymentDescription) StartOrder() ([]string, error) {
+	names := d.ServiceNames()
+	sort.Strings(names)
+
+	order := []string{}
+	state := map[string]int{} // 1 while visiting, 2 when done
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		if state[name] == 2 {
+			return nil
+		} else if state[name] == 1 {
+			return errors.New(fmt.Sprintf("services %v have a circular dependency", strings.Join(append(path, name), " -> ")))
+		}
+		state[name] = 1
+		deps := d.Services[name].DependsOn
+		if pod := d.Services[name].PodService(); pod != "" {
+			deps = append([]string{pod}, deps...)
+		}
+		for _, dep := range deps {
+			if _, ok := d.Services[dep]; !ok {
+				return errors.New(fmt.Sprintf("service %v depends on service %v, which is not in the deployment", name, dep))
+			} else if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		order = append(order, name)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, []string{}); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// PodMembers returns the services that join the network namespace of the given service, sorted by name.
+func (d DeploymentDescription) PodMembers(serviceName string) []string {
+	members := []string{}
+	for name, s := range d.Services {
+		if s != nil && name != serviceName && s.PodService() == serviceName {
+			members = append(members, name)
+		}
+	}
+	sort.Strings(members)
+	return members
+}
+
+// ValidatePods checks the services that join the network namespace of another service. The service they join must be
+// in the deployment and have its own bridge network, and it publishes the ports and limits the egress of the whole pod.
+func (d DeploymentDescription) ValidatePods() error {
+	names := d.ServiceNames()
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := d.Services[name]
+		if s == nil || !strings.HasPrefix(s.Network, NETWORK_SERVICE_PREFIX) {
+			continue
+		}
+		pod := s.PodService()
+		if main, ok := d.Services[pod]; !ok || main == nil || pod == name {
+			return errors.New(fmt.Sprintf("service %v joins the network of service %v, which is not another service in the deployment", name, pod))
+		} else if main.PodService() != "" {
+			return errors.New(fmt.Sprintf("service %v joins the network of service %v, which joins the network of service %v", name, pod, main.PodService()))
+		} else if main.Network == "host" {
+			return errors.New(fmt.Sprintf("service %v joins the network of service %v, which uses the host network", name, pod))
+		} else if d.ServicePattern.IsShared("singleton", name) || d.ServicePattern.IsShared("singleton", pod) {
+			return errors.New(fmt.Sprintf("service %v joins the network of service %v, but shared singleton services have their own network", name, pod))
+		} else if s.HasSpecificPortBinding() || len(s.EphemeralPorts) != 0 {
+			return errors.New(fmt.Sprintf("service %v joins the network of service %v, its ports must be published by service %v", name, pod, pod))
+		} else if s.MaxEgressKbps != 0 {
+			return errors.New(fmt.Sprintf("service %v joins the network of service %v, its egress is limited by service %v", name, pod, pod))
+		}
+	}
+	return nil
+}
+
 type Pattern struct {
 	Shared map[string][]string `json:"shared"`
 }
@@ -170,12 +304,22 @@ type Service struct {
 	Entrypoint       []string             `json:"entrypoint,omitempty"`
 	MaxMemoryMb      int64                `json:"max_memory_mb,omitempty"`
 	MaxCPUs          float32              `json:"max_cpus,omitempty"`
-	LogDriver        string               `json:"log_driver,omitempty"` // Docker's log-driver. Syslog will be used as default driver
+	LogDriver        string               `json:"log_driver,omitempty"`  // Docker's log-driver. Syslog will be used as default driver
+	LogOptions       map[string]string    `json:"log_options,omitempty"` // Docker's log-opt for the log driver, e.g. max-size and max-file for json-file
 	Secrets          map[string]Secret    `json:"secrets"`
-	SecurityOpt      []string             `json:"security_opt,omitempty"` // Related to SELinux security for podman
-	PID              string               `json:"pid,omitempty"`          // The process id that the container should run in, see docker run --pid
-	User             string               `json:"user,omitempty"`         // The linux user ID (UID format) in which the container should run, see docker run -user
-	Sysctls          map[string]string    `json:"sysctls,omitempty"`      // The namespaced kernel parameters (sysctls) for this container, see docker run --sysctls
+	SecurityOpt      []string             `json:"security_opt,omitempty"`    // Related to SELinux security for podman
+	PID              string               `json:"pid,omitempty"`             // The process id that the container should run in, see docker run --pid
+	User             string               `json:"user,omitempty"`            // The linux user ID (UID format) in which the container should run, see docker run -user
+	Sysctls          map[string]string    `json:"sysctls,omitempty"`         // The namespaced kernel parameters (sysctls) for this container, see docker run --sysctls
+	DependsOn        []string             `json:"depends_on,omitempty"`      // The services in the same deployment that are started before this one
+	GPUs             *GPUs                `json:"gpus,omitempty"`            // The GPUs the container can use, see docker run --gpus
+	RestartPolicy    *RestartPolicy       `json:"restart_policy,omitempty"`  // How the container is restarted when it exits, always restarted by default
+	Volumes          []Volume             `json:"volumes,omitempty"`         // The named volumes created by the agent and mounted into the container
+	SecurityOpts     *SecurityOpts        `json:"security_opts,omitempty"`   // The hardening of the container: read-only root filesystem, seccomp and AppArmor profiles, capabilities
+	MaxEgressKbps    int64                `json:"max_egress_kbps,omitempty"` // The rate, in kilobits per second, at which the container can send on its network interfaces
+	Checkpoint       bool                 `json:"checkpoint,omitempty"`      // Checkpoint the running container when a service upgrade stops it and restore it from the checkpoint when it is started again
+	StopTimeout      int                  `json:"stop_timeout,omitempty"`    // The seconds the container has to exit after it is sent a SIGTERM before it is killed
+	PreStop          []string             `json:"pre_stop,omitempty"`        // The command run in the container before it is stopped, e.g. to flush its state
 }
 
 func (s *Service) AddFilesystemBinding(bind string) {
@@ -195,6 +339,48 @@ func (s *Service) HasSpecificPortBinding() bool {
 	return false
 }
 
+// The network of a container that joins the network namespace of another container of the deployment, e.g. "service:app".
+const NETWORK_SERVICE_PREFIX = "service:"
+
+// PodService returns the service whose network namespace the container joins, like the containers of a kubernetes pod,
+// or an empty string if the container has its own network.
+func (s *Service) PodService() string {
+	if strings.HasPrefix(s.Network, NETWORK_SERVICE_PREFIX) {
+		return strings.TrimPrefix(s.Network, NETWORK_SERVICE_PREFIX)
+	}
+	return ""
+}
+
+// The seconds a container with a pre_stop command but no stop_timeout has to exit, the same as docker stop.
+const DEFAULT_STOP_TIMEOUT_S = 10
+
+// The longest a container can take to stop. The agent does not start or stop other containers while it waits.
+const MAX_STOP_TIMEOUT_S = 300
+
+// ValidateStop checks how the container is stopped, an error is returned if it is not valid.
+func (s *Service) ValidateStop() error {
+	if s.StopTimeout < 0 || s.StopTimeout > MAX_STOP_TIMEOUT_S {
+		return errors.New(fmt.Sprintf("stop_timeout %v must be between 0 and %v seconds", s.StopTimeout, MAX_STOP_TIMEOUT_S))
+	} else if s.PreStop != nil && (len(s.PreStop) == 0 || s.PreStop[0] == "") {
+		return errors.New(fmt.Sprintf("pre_stop must be a non-empty command"))
+	}
+	return nil
+}
+
+// GracefulStop returns true when the container is stopped with a SIGTERM, after its pre_stop command if it has one,
+// instead of being killed.
+func (s *Service) GracefulStop() bool {
+	return s.StopTimeout != 0 || len(s.PreStop) != 0
+}
+
+// StopTimeoutS returns the seconds the container has to exit once it is sent a SIGTERM.
+func (s *Service) StopTimeoutS() int {
+	if s.StopTimeout != 0 {
+		return s.StopTimeout
+	}
+	return DEFAULT_STOP_TIMEOUT_S
+}
+
 func GetSpecificHostPort(hostPort string) string {
 	p := strings.Split(hostPort, ":")
 	if len(p) > 0 {
@@ -257,6 +443,341 @@ type Port struct {
 	PortAndProtocol string `json:"port_and_protocol"`
 }
 
+// The log drivers that write the logs to files on the device, they can be rotated with max-size and max-file
+var RotatingLogDrivers = []string{"json-file", "local"}
+
+func IsRotatingLogDriver(driver string) bool {
+	for _, d := range RotatingLogDrivers {
+		if d == driver {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateLogOptions checks the log options of a service for the given log driver. The options are passed to the log
+// driver as they are, but the rotation options are checked here so that a bad value does not fail the container start.
+func ValidateLogOptions(driver string, options map[string]string) error {
+	for k, v := range options {
+		switch k {
+		case "max-size":
+			if !IsRotatingLogDriver(driver) {
+				return errors.New(fmt.Sprintf("log option %v is only supported by the %v log drivers", k, RotatingLogDrivers))
+			} else if v != "-1" && !logSizeRegex.MatchString(v) {
+				return errors.New(fmt.Sprintf("log option %v %v is not a size, e.g. 10m", k, v))
+			}
+		case "max-file":
+			if !IsRotatingLogDriver(driver) {
+				return errors.New(fmt.Sprintf("log option %v is only supported by the %v log drivers", k, RotatingLogDrivers))
+			} else if n, err := strconv.Atoi(v); err != nil || n < 1 {
+				return errors.New(fmt.Sprintf("log option %v %v is not a positive number", k, v))
+			}
+		}
+	}
+	return nil
+}
+
+var logSizeRegex = regexp.MustCompile(`^[0-9]+[kmg]?$`)
+
+// The driver that provides the GPUs to the containers
+const GPU_DRIVER_NVIDIA = "nvidia"
+
+// The NVIDIA driver capabilities a service can request, see NVIDIA_DRIVER_CAPABILITIES
+var GPUCapabilities = []string{"all", "compute", "compat32", "graphics", "utility", "video", "display"}
+
+// GPUs requests GPU access for a service container. The GPUs are either the ones listed in device_ids, by UUID or by
+// index, or the first count GPUs. When neither is set, the container can use all the GPUs of the host.
+type GPUs struct {
+	Count        int      `json:"count,omitempty"`        // The number of GPUs, -1 for all of them
+	DeviceIDs    []string `json:"device_ids,omitempty"`   // The UUIDs or the indexes of specific GPUs
+	Capabilities []string `json:"capabilities,omitempty"` // The driver capabilities, e.g. "compute", "utility"
+	Driver       string   `json:"driver,omitempty"`       // Defaults to nvidia
+}
+
+func (g GPUs) String() string {
+	return fmt.Sprintf("Count: %v, DeviceIDs: %v, Capabilities: %v, Driver: %v", g.Count, g.DeviceIDs, g.Capabilities, g.Driver)
+}
+
+// DeviceRequest returns the docker device request for the GPUs. An error is returned if the request is not valid.
+func (g *GPUs) DeviceRequest() (*docker.DeviceRequest, error) {
+	driver := g.Driver
+	if driver == "" {
+		driver = GPU_DRIVER_NVIDIA
+	} else if driver != GPU_DRIVER_NVIDIA {
+		return nil, errors.New(fmt.Sprintf("gpu driver %v is not supported, only %v is supported", driver, GPU_DRIVER_NVIDIA))
+	}
+
+	if g.Count < -1 {
+		return nil, errors.New(fmt.Sprintf("gpu count %v is not valid, it must be -1 for all the gpus or a positive number", g.Count))
+	} else if g.Count != 0 && len(g.DeviceIDs) != 0 {
+		return nil, errors.New(fmt.Sprintf("gpu count and device_ids cannot both be set"))
+	}
+
+	// docker selects the gpu driver with the "gpu" capability, the others are passed to the driver
+	caps := []string{"gpu"}
+	for _, c := range g.Capabilities {
+		found := false
+		for _, gc := range GPUCapabilities {
+			if c == gc {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New(fmt.Sprintf("gpu capability %v is not supported, it must be one of %v", c, GPUCapabilities))
+		}
+		caps = append(caps, c)
+	}
+
+	req := &docker.DeviceRequest{
+		Driver:       driver,
+		Count:        g.Count,
+		DeviceIDs:    g.DeviceIDs,
+		Capabilities: [][]string{caps},
+	}
+	if req.Count == 0 && len(req.DeviceIDs) == 0 {
+		req.Count = -1
+	}
+	return req, nil
+}
+
+// The restart policies of a service container
+const (
+	RESTART_POLICY_ALWAYS     = "always"
+	RESTART_POLICY_ON_FAILURE = "on-failure"
+	RESTART_POLICY_NO         = "no"
+)
+
+var RestartPolicyNames = []string{RESTART_POLICY_ALWAYS, RESTART_POLICY_ON_FAILURE, RESTART_POLICY_NO}
+
+// RestartPolicy controls how a service container is restarted when it exits. The container engine restarts the container
+// according to the name and max_retries. Once the engine gives up, the agent restarts the whole service, doubling the
+// delay between the restarts up to backoff_max_s so that a service that keeps crashing does not hog the device.
+type RestartPolicy struct {
+	Name        string `json:"name,omitempty"`          // always, on-failure or no. Defaults to always
+	MaxRetries  int    `json:"max_retries,omitempty"`   // The number of times the engine restarts the container, only for on-failure. 0 means no limit
+	BackoffMaxS int    `json:"backoff_max_s,omitempty"` // The maximum number of seconds between the restarts of the service by the agent
+}
+
+func (r RestartPolicy) String() string {
+	return fmt.Sprintf("Name: %v, MaxRetries: %v, BackoffMaxS: %v", r.Name, r.MaxRetries, r.BackoffMaxS)
+}
+
+// Validate checks the restart policy, an error is returned if it is not valid.
+func (r *RestartPolicy) Validate() error {
+	found := r.Name == ""
+	for _, n := range RestartPolicyNames {
+		if r.Name == n {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return errors.New(fmt.Sprintf("restart policy %v is not supported, it must be one of %v", r.Name, RestartPolicyNames))
+	} else if r.MaxRetries < 0 {
+		return errors.New(fmt.Sprintf("restart policy max_retries %v cannot be negative", r.MaxRetries))
+	} else if r.MaxRetries != 0 && r.Name != RESTART_POLICY_ON_FAILURE {
+		return errors.New(fmt.Sprintf("restart policy max_retries is only supported by the %v restart policy", RESTART_POLICY_ON_FAILURE))
+	} else if r.BackoffMaxS < 0 {
+		return errors.New(fmt.Sprintf("restart policy backoff_max_s %v cannot be negative", r.BackoffMaxS))
+	}
+	return nil
+}
+
+// DockerRestartPolicy returns the docker restart policy of the container. An error is returned if the policy is not valid.
+func (r *RestartPolicy) DockerRestartPolicy() (docker.RestartPolicy, error) {
+	if err := r.Validate(); err != nil {
+		return docker.RestartPolicy{}, err
+	}
+	switch r.Name {
+	case RESTART_POLICY_ON_FAILURE:
+		return docker.RestartOnFailure(r.MaxRetries), nil
+	case RESTART_POLICY_NO:
+		return docker.NeverRestart(), nil
+	default:
+		return docker.AlwaysRestart(), nil
+	}
+}
+
+// The special seccomp and AppArmor profiles
+const (
+	SECURITY_PROFILE_DEFAULT    = "default"
+	SECURITY_PROFILE_UNCONFINED = "unconfined"
+)
+
+var capabilityRegex = regexp.MustCompile(`^[a-zA-Z_]+$`)
+var apparmorProfileRegex = regexp.MustCompile(`^[a-zA-Z0-9_./-]+$`)
+
+// SecurityOpts hardens a service container. The seccomp profile is "default" for the profile of the container engine,
+// "unconfined" for none, or the absolute path of a JSON profile on the node. The AppArmor profile is the name of a profile
+// loaded on the node, or "unconfined". The capabilities are named with or without the CAP_ prefix, ALL drops all of them.
+type SecurityOpts struct {
+	ReadOnlyRootFilesystem bool     `json:"read_only_root_filesystem,omitempty"`
+	SeccompProfile         string   `json:"seccomp_profile,omitempty"`
+	ApparmorProfile        string   `json:"apparmor_profile,omitempty"`
+	CapDrop                []string `json:"cap_drop,omitempty"`
+	CapAdd                 []string `json:"cap_add,omitempty"`
+}
+
+func (s SecurityOpts) String() string {
+	return fmt.Sprintf("ReadOnlyRootFilesystem: %v, SeccompProfile: %v, ApparmorProfile: %v, CapDrop: %v, CapAdd: %v",
+		s.ReadOnlyRootFilesystem, s.SeccompProfile, s.ApparmorProfile, s.CapDrop, s.CapAdd)
+}
+
+// Validate checks the security options, an error is returned if they are not valid.
+func (s *SecurityOpts) Validate() error {
+	if s.SeccompProfile != "" && s.SeccompProfile != SECURITY_PROFILE_DEFAULT && s.SeccompProfile != SECURITY_PROFILE_UNCONFINED && !path.IsAbs(s.SeccompProfile) {
+		return errors.New(fmt.Sprintf("seccomp_profile %v must be %v, %v or the absolute path of a profile on the node", s.SeccompProfile, SECURITY_PROFILE_DEFAULT, SECURITY_PROFILE_UNCONFINED))
+	} else if s.ApparmorProfile != "" && !apparmorProfileRegex.MatchString(s.ApparmorProfile) {
+		return errors.New(fmt.Sprintf("apparmor_profile %v is not a valid profile name", s.ApparmorProfile))
+	}
+
+	dropped := make(map[string]bool)
+	for _, c := range s.CapDrop {
+		if !capabilityRegex.MatchString(c) {
+			return errors.New(fmt.Sprintf("cap_drop %v is not a valid capability", c))
+		}
+		dropped[NormalizeCapability(c)] = true
+	}
+	for _, c := range s.CapAdd {
+		if !capabilityRegex.MatchString(c) {
+			return errors.New(fmt.Sprintf("cap_add %v is not a valid capability", c))
+		} else if NormalizeCapability(c) == "ALL" {
+			return errors.New("cap_add ALL is not supported, use privileged instead")
+		} else if dropped[NormalizeCapability(c)] {
+			return errors.New(fmt.Sprintf("capability %v cannot be both added and dropped", c))
+		}
+	}
+	return nil
+}
+
+// IsUnconfined returns true when the container runs without a seccomp or AppArmor profile, which like privileged mode
+// is only allowed on nodes that allow privileged services.
+func (s *SecurityOpts) IsUnconfined() bool {
+	return s != nil && (s.SeccompProfile == SECURITY_PROFILE_UNCONFINED || s.ApparmorProfile == SECURITY_PROFILE_UNCONFINED)
+}
+
+// NormalizeCapability returns the capability name in upper case with the CAP_ prefix, or ALL.
+func NormalizeCapability(c string) string {
+	c = strings.ToUpper(c)
+	if c == "ALL" || strings.HasPrefix(c, "CAP_") {
+		return c
+	}
+	return "CAP_" + c
+}
+
+// The retention policies of a named volume
+const (
+	VOLUME_RETAIN_AGREEMENT = "agreement"
+	VOLUME_RETAIN_UPGRADE   = "upgrade"
+)
+
+var VolumeRetainNames = []string{VOLUME_RETAIN_AGREEMENT, VOLUME_RETAIN_UPGRADE}
+
+var volumeNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Volume is a named volume mounted into a service container. The agent creates the volume when the agreement starts and
+// by default removes it when the agreement ends. A volume retained across upgrades is kept when the agreement ends, so
+// that the next version of the same service finds its data, and is removed once no version of the service has run on
+// the node for a while. Services of the same deployment that mount a volume with the same name share it.
+type Volume struct {
+	Name     string `json:"name"`                // The name of the volume, unique within the deployment
+	Target   string `json:"target"`              // The absolute path of the volume in the container
+	ReadOnly bool   `json:"read_only,omitempty"` // Mount the volume read only
+	Retain   string `json:"retain,omitempty"`    // agreement or upgrade. Defaults to agreement
+}
+
+func (v Volume) String() string {
+	return fmt.Sprintf("Name: %v, Target: %v, ReadOnly: %v, Retain: %v", v.Name, v.Target, v.ReadOnly, v.Retain)
+}
+
+// Validate checks the volume, an error is returned if it is not valid.
+func (v *Volume) Validate() error {
+	found := v.Retain == ""
+	for _, n := range VolumeRetainNames {
+		if v.Retain == n {
+			found = true
+			break
+		}
+	}
+	if !volumeNameRegex.MatchString(v.Name) {
+		return errors.New(fmt.Sprintf("volume name %v is not valid, it must start with a letter or a digit followed by letters, digits, '_', '.' or '-'", v.Name))
+	} else if !strings.HasPrefix(v.Target, "/") || strings.Contains(v.Target, ":") {
+		return errors.New(fmt.Sprintf("volume %v target %v must be an absolute path without ':'", v.Name, v.Target))
+	} else if !found {
+		return errors.New(fmt.Sprintf("volume %v retain %v is not supported, it must be one of %v", v.Name, v.Retain, VolumeRetainNames))
+	}
+	return nil
+}
+
+// IsRetained returns true if the volume is kept across the upgrades of the service.
+func (v Volume) IsRetained() bool {
+	return v.Retain == VOLUME_RETAIN_UPGRADE
+}
+
+// Volumes returns the named volumes of all the services of the deployment, keyed by name. An error is returned if a
+// volume is not valid or if the services sharing a volume do not retain it the same way.
+func (d DeploymentDescription) Volumes() (map[string]Volume, error) {
+	volumes := make(map[string]Volume)
+	for serviceName, s := range d.Services {
+		if s == nil {
+			continue
+		}
+		for _, v := range s.Volumes {
+			if err := v.Validate(); err != nil {
+				return nil, errors.New(fmt.Sprintf("service %v: %v", serviceName, err))
+			} else if other, ok := volumes[v.Name]; ok && other.IsRetained() != v.IsRetained() {
+				return nil, errors.New(fmt.Sprintf("service %v: volume %v is shared with another service that retains it differently", serviceName, v.Name))
+			}
+			volumes[v.Name] = v
+		}
+	}
+	return volumes, nil
+}
+
+// ResourceLimits returns the memory, in MB, and the CPUs the containers of the deployment are limited to. A service
+// without limits does not count, it is only constrained by the other containers on the device.
+func (d DeploymentDescription) ResourceLimits() (int64, float64) {
+	memoryMb := int64(0)
+	cpus := float64(0)
+	for _, s := range d.Services {
+		if s != nil {
+			memoryMb += s.MaxMemoryMb
+			cpus += float64(s.MaxCPUs)
+		}
+	}
+	return memoryMb, cpus
+}
+
+// GPULimits returns the number of GPUs the containers of the deployment can use, -1 if a container can use all the
+// GPUs of the host.
+func (d DeploymentDescription) GPULimits() int {
+	gpus := 0
+	for _, s := range d.Services {
+		if s == nil || s.GPUs == nil {
+			continue
+		} else if len(s.GPUs.DeviceIDs) != 0 {
+			gpus += len(s.GPUs.DeviceIDs)
+		} else if s.GPUs.Count > 0 {
+			gpus += s.GPUs.Count
+		} else {
+			return -1
+		}
+	}
+	return gpus
+}
+
+// RestartBackoffMax returns the largest backoff ceiling set by the services of the deployment, 0 if none of them sets one.
+func (d DeploymentDescription) RestartBackoffMax() int {
+	backoffMax := 0
+	for _, s := range d.Services {
+		if s != nil && s.RestartPolicy != nil && s.RestartPolicy.BackoffMaxS > backoffMax {
+			backoffMax = s.RestartPolicy.BackoffMaxS
+		}
+	}
+	return backoffMax
+}
+
 type DynamicOutboundPermitValue struct {
 	DdKey    string   `json:"dd_key"`
 	Encoding Encoding `json:"encoding"`
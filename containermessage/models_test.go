@@ -5,6 +5,7 @@ package containermessage
 
 import (
 	docker "github.com/fsouza/go-dockerclient"
+	"reflect"
 	"testing"
 )
 
@@ -179,3 +180,279 @@ func Test_AddSpecificPortBinding(t *testing.T) {
 		t.Errorf("Service should have 2 specific port bindings but not.")
 	}
 }
+
+func Test_GPUsDeviceRequest(t *testing.T) {
+
+	// all the gpus when neither count nor device_ids are set
+	if req, err := (&GPUs{}).DeviceRequest(); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if req.Driver != GPU_DRIVER_NVIDIA || req.Count != -1 || len(req.Capabilities) != 1 || len(req.Capabilities[0]) != 1 || req.Capabilities[0][0] != "gpu" {
+		t.Errorf("wrong device request %v", req)
+	}
+
+	if req, err := (&GPUs{DeviceIDs: []string{"GPU-3a23c669", "1"}, Capabilities: []string{"compute", "utility"}}).DeviceRequest(); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if req.Count != 0 || len(req.DeviceIDs) != 2 || len(req.Capabilities[0]) != 3 || req.Capabilities[0][2] != "utility" {
+		t.Errorf("wrong device request %v", req)
+	}
+
+	if req, err := (&GPUs{Count: 2}).DeviceRequest(); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if req.Count != 2 {
+		t.Errorf("wrong device request %v", req)
+	}
+
+	invalid := []GPUs{
+		{Count: -2},
+		{Count: 1, DeviceIDs: []string{"0"}},
+		{Capabilities: []string{"tpu"}},
+		{Driver: "amd"},
+	}
+	for _, g := range invalid {
+		if _, err := g.DeviceRequest(); err == nil {
+			t.Errorf("should have returned an error for %v", g)
+		}
+	}
+}
+
+func Test_ValidateLogOptions(t *testing.T) {
+
+	valid := map[string]map[string]string{
+		"json-file": {"max-size": "10m", "max-file": "3"},
+		"local":     {"max-size": "-1", "compress": "true"},
+		"fluentd":   {"fluentd-address": "localhost:24224"},
+		"syslog":    {},
+	}
+	for driver, options := range valid {
+		if err := ValidateLogOptions(driver, options); err != nil {
+			t.Errorf("should not return error for %v %v, but got %v", driver, options, err)
+		}
+	}
+
+	invalid := map[string]map[string]string{
+		"json-file": {"max-size": "10 MB"},
+		"local":     {"max-file": "0"},
+		"syslog":    {"max-size": "10m"},
+		"":          {"max-file": "2"},
+	}
+	for driver, options := range invalid {
+		if err := ValidateLogOptions(driver, options); err == nil {
+			t.Errorf("should have returned an error for %v %v", driver, options)
+		}
+	}
+}
+
+func Test_RestartPolicy(t *testing.T) {
+
+	tests := map[string]RestartPolicy{
+		"always":     {},
+		"on-failure": {Name: RESTART_POLICY_ON_FAILURE, MaxRetries: 5, BackoffMaxS: 60},
+		"no":         {Name: RESTART_POLICY_NO},
+	}
+	for name, rp := range tests {
+		if drp, err := rp.DockerRestartPolicy(); err != nil {
+			t.Errorf("should not return error for %v, but got %v", rp, err)
+		} else if drp.Name != name || drp.MaximumRetryCount != rp.MaxRetries {
+			t.Errorf("wrong docker restart policy %v for %v", drp, rp)
+		}
+	}
+
+	invalid := []RestartPolicy{
+		{Name: "unless-stopped"},
+		{Name: RESTART_POLICY_ALWAYS, MaxRetries: 2},
+		{Name: RESTART_POLICY_ON_FAILURE, MaxRetries: -1},
+		{BackoffMaxS: -10},
+	}
+	for _, rp := range invalid {
+		if _, err := rp.DockerRestartPolicy(); err == nil {
+			t.Errorf("should have returned an error for %v", rp)
+		}
+	}
+
+	dd := DeploymentDescription{Services: map[string]*Service{
+		"a": {Image: "a"},
+		"b": {Image: "b", RestartPolicy: &RestartPolicy{BackoffMaxS: 120}},
+		"c": {Image: "c", RestartPolicy: &RestartPolicy{Name: RESTART_POLICY_NO}},
+	}}
+	if m := dd.RestartBackoffMax(); m != 120 {
+		t.Errorf("wrong backoff max %v", m)
+	}
+}
+
+func Test_ValidateStop(t *testing.T) {
+
+	valid := []Service{
+		{},
+		{StopTimeout: 60},
+		{PreStop: []string{"/usr/bin/flush", "--all"}},
+		{StopTimeout: MAX_STOP_TIMEOUT_S, PreStop: []string{"pg_ctl", "stop"}},
+	}
+	for _, s := range valid {
+		if err := s.ValidateStop(); err != nil {
+			t.Errorf("should not return error for %v, but got %v", s, err)
+		}
+	}
+
+	invalid := []Service{
+		{StopTimeout: -1},
+		{StopTimeout: MAX_STOP_TIMEOUT_S + 1},
+		{PreStop: []string{}},
+		{PreStop: []string{"", "x"}},
+	}
+	for _, s := range invalid {
+		if err := s.ValidateStop(); err == nil {
+			t.Errorf("should have returned an error for %v", s)
+		}
+	}
+
+	if s := (Service{}); s.GracefulStop() {
+		t.Errorf("a service without stop settings should be killed")
+	} else if s := (Service{PreStop: []string{"flush"}}); !s.GracefulStop() || s.StopTimeoutS() != DEFAULT_STOP_TIMEOUT_S {
+		t.Errorf("wrong stop timeout %v for %v", s.StopTimeoutS(), s)
+	} else if s := (Service{StopTimeout: 30}); !s.GracefulStop() || s.StopTimeoutS() != 30 {
+		t.Errorf("wrong stop timeout %v for %v", s.StopTimeoutS(), s)
+	}
+}
+
+func Test_ResourceLimits(t *testing.T) {
+
+	dd := DeploymentDescription{Services: map[string]*Service{
+		"a": {Image: "a", MaxMemoryMb: 256, MaxCPUs: 0.5},
+		"b": {Image: "b", MaxMemoryMb: 128},
+		"c": {Image: "c"},
+	}}
+	if mem, cpus := dd.ResourceLimits(); mem != 384 || cpus != 0.5 {
+		t.Errorf("wrong resource limits %v %v", mem, cpus)
+	}
+}
+
+func Test_GPULimits(t *testing.T) {
+
+	dd := DeploymentDescription{Services: map[string]*Service{
+		"a": {Image: "a", GPUs: &GPUs{Count: 2}},
+		"b": {Image: "b", GPUs: &GPUs{DeviceIDs: []string{"0"}}},
+		"c": {Image: "c"},
+	}}
+	if gpus := dd.GPULimits(); gpus != 3 {
+		t.Errorf("wrong gpu limits %v", gpus)
+	}
+
+	dd.Services["c"].GPUs = &GPUs{Count: -1}
+	if gpus := dd.GPULimits(); gpus != -1 {
+		t.Errorf("a container that can use all the gpus should return -1, but got %v", gpus)
+	}
+}
+
+func Test_Volumes(t *testing.T) {
+
+	dd := DeploymentDescription{Services: map[string]*Service{
+		"a": {Image: "a", Volumes: []Volume{{Name: "data", Target: "/data"}, {Name: "models", Target: "/models", Retain: VOLUME_RETAIN_UPGRADE}}},
+		"b": {Image: "b", Volumes: []Volume{{Name: "models", Target: "/var/models", ReadOnly: true, Retain: VOLUME_RETAIN_UPGRADE}}},
+		"c": {Image: "c"},
+	}}
+	if volumes, err := dd.Volumes(); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(volumes) != 2 || volumes["data"].IsRetained() || !volumes["models"].IsRetained() {
+		t.Errorf("wrong volumes %v", volumes)
+	}
+
+	invalid := []Volume{
+		{Name: "", Target: "/data"},
+		{Name: "my/data", Target: "/data"},
+		{Name: "data", Target: "data"},
+		{Name: "data", Target: "/data:ro"},
+		{Name: "data", Target: "/data", Retain: "forever"},
+	}
+	for _, v := range invalid {
+		if err := v.Validate(); err == nil {
+			t.Errorf("should have returned an error for %v", v)
+		}
+	}
+
+	// the services sharing a volume must retain it the same way
+	dd.Services["c"].Volumes = []Volume{{Name: "models", Target: "/models"}}
+	if _, err := dd.Volumes(); err == nil {
+		t.Errorf("should have returned an error for %v", dd.Services)
+	}
+}
+
+func Test_SecurityOpts(t *testing.T) {
+
+	valid := []SecurityOpts{
+		{},
+		{ReadOnlyRootFilesystem: true, SeccompProfile: SECURITY_PROFILE_DEFAULT, ApparmorProfile: "docker-default"},
+		{SeccompProfile: "/etc/horizon/seccomp/strict.json", CapDrop: []string{"ALL"}, CapAdd: []string{"net_bind_service", "CAP_CHOWN"}},
+		{SeccompProfile: SECURITY_PROFILE_UNCONFINED, ApparmorProfile: SECURITY_PROFILE_UNCONFINED},
+	}
+	for _, so := range valid {
+		if err := so.Validate(); err != nil {
+			t.Errorf("should not return error for %v, but got %v", so, err)
+		}
+	}
+
+	invalid := []SecurityOpts{
+		{SeccompProfile: "strict.json"},
+		{ApparmorProfile: "my profile"},
+		{CapDrop: []string{"NET-RAW"}},
+		{CapAdd: []string{"ALL"}},
+		{CapDrop: []string{"CAP_NET_RAW"}, CapAdd: []string{"net_raw"}},
+	}
+	for _, so := range invalid {
+		if err := so.Validate(); err == nil {
+			t.Errorf("should have returned an error for %v", so)
+		}
+	}
+
+	var nilOpts *SecurityOpts
+	if nilOpts.IsUnconfined() || valid[1].IsUnconfined() || !valid[3].IsUnconfined() {
+		t.Errorf("wrong unconfined security options")
+	}
+	if c := NormalizeCapability("net_admin"); c != "CAP_NET_ADMIN" {
+		t.Errorf("wrong capability %v", c)
+	}
+}
+
+func Test_ValidatePods(t *testing.T) {
+
+	dd := DeploymentDescription{Services: map[string]*Service{
+		"app":   {Image: "app", Ports: []docker.PortBinding{{HostPort: "8080:8080/tcp"}}},
+		"proxy": {Image: "proxy", Network: "service:app"},
+		"logs":  {Image: "logs", Network: "service:app"},
+		"db":    {Image: "db"},
+	}}
+	if err := dd.ValidatePods(); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if members := dd.PodMembers("app"); !reflect.DeepEqual(members, []string{"logs", "proxy"}) {
+		t.Errorf("wrong pod members %v", members)
+	} else if members := dd.PodMembers("db"); len(members) != 0 {
+		t.Errorf("wrong pod members %v", members)
+	} else if order, err := dd.StartOrder(); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if !reflect.DeepEqual(order, []string{"app", "db", "logs", "proxy"}) {
+		t.Errorf("wrong start order %v", order)
+	}
+
+	invalid := map[string]func(d *DeploymentDescription){
+		"unknown service": func(d *DeploymentDescription) { d.Services["proxy"].Network = "service:web" },
+		"no service":      func(d *DeploymentDescription) { d.Services["proxy"].Network = "service:" },
+		"itself":          func(d *DeploymentDescription) { d.Services["db"].Network = "service:db" },
+		"chained":         func(d *DeploymentDescription) { d.Services["db"].Network = "service:proxy" },
+		"host network":    func(d *DeploymentDescription) { d.Services["app"].Network = "host" },
+		"ports":           func(d *DeploymentDescription) { d.Services["proxy"].Ports = []docker.PortBinding{{HostPort: "9901"}} },
+		"ephemeral ports": func(d *DeploymentDescription) {
+			d.Services["proxy"].EphemeralPorts = []Port{{PortAndProtocol: "9901/tcp"}}
+		},
+		"singleton": func(d *DeploymentDescription) { d.ServicePattern.Shared = map[string][]string{"singleton": {"app"}} },
+	}
+	for name, change := range invalid {
+		d := DeploymentDescription{Services: map[string]*Service{}}
+		for n, s := range dd.Services {
+			copied := *s
+			d.Services[n] = &copied
+		}
+		change(&d)
+		if err := d.ValidatePods(); err == nil {
+			t.Errorf("%v: should have returned an error", name)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -726,6 +727,92 @@ func GetMemInfo(meminfo_file string) (uint64, uint64, error) {
 	}
 }
 
+// Get the number of seconds since the local node was booted.
+// If uptime_file is an empty string, this function will get /proc/uptime for Linux.
+func GetUptime(uptime_file string) (uint64, error) {
+	if uptime_file == "" {
+		// does not support
+		if runtime.GOOS == "darwin" {
+			return 0, fmt.Errorf("Does not support mac os for getting uptime.")
+		} else {
+			uptime_file = "/proc/uptime"
+		}
+	}
+
+	content, err := ioutil.ReadFile(uptime_file)
+	if err != nil {
+		return 0, err
+	}
+
+	// the first field is the uptime in seconds, the second is the idle time of all the cpus
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("File %v is empty.", uptime_file)
+	}
+	uptime, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("Unable to parse the uptime %v in %v. %v", fields[0], uptime_file, err)
+	}
+	return uint64(uptime), nil
+}
+
+// The location of the node, as written to the geo location file by a GPS daemon or a script.
+type GeoLocation struct {
+	Lat  float64 `json:"lat"`
+	Long float64 `json:"long"`
+}
+
+// Read the latitude and longitude of the node from the given json file, e.g. {"lat": 41.0064, "long": -111.9393}.
+func GetGeoLocation(geo_file string) (float64, float64, error) {
+	content, err := ioutil.ReadFile(geo_file)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var geo GeoLocation
+	if err := json.Unmarshal(content, &geo); err != nil {
+		return 0, 0, fmt.Errorf("Unable to unmarshal the geo location in %v. %v", geo_file, err)
+	} else if geo.Lat < -90 || geo.Lat > 90 {
+		return 0, 0, fmt.Errorf("The latitude %v in %v must be between -90 and 90.", geo.Lat, geo_file)
+	} else if geo.Long < -180 || geo.Long > 180 {
+		return 0, 0, fmt.Errorf("The longitude %v in %v must be between -180 and 180.", geo.Long, geo_file)
+	}
+	return geo.Lat, geo.Long, nil
+}
+
+// Get the number of NVIDIA GPUs on the local node from the device files, e.g. /dev/nvidia0. If dev_dir is an empty
+// string, this function will look in /dev.
+func GetGPUCount(dev_dir string) (int, error) {
+	if dev_dir == "" {
+		dev_dir = "/dev"
+	}
+
+	files, err := ioutil.ReadDir(dev_dir)
+	if err != nil {
+		return 0, err
+	}
+
+	gpu_count := 0
+	r, _ := regexp.Compile(`^nvidia[0-9]+$`)
+	for _, f := range files {
+		if r.MatchString(f.Name()) {
+			gpu_count++
+		}
+	}
+	return gpu_count, nil
+}
+
+// Get the total disk size and available disk size in MegaBytes of the file system that holds the given path.
+func GetDiskInfo(path string) (uint64, uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	total_disk := (stat.Blocks * uint64(stat.Bsize)) >> 20
+	avail_disk := (stat.Bavail * uint64(stat.Bsize)) >> 20
+	return total_disk, avail_disk, nil
+}
+
 // Converts the given number (in string) to mega bytes. The unit can be MB, KB, GB, or B.
 func ConvertToMB(value string, unit string) (uint64, error) {
 	if s, err := strconv.ParseUint(value, 10, 64); err != nil {
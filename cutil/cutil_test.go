@@ -6,6 +6,9 @@ package cutil
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path"
 	"testing"
 )
 
@@ -347,6 +350,73 @@ func Test_GetMemInfo(t *testing.T) {
 	}
 }
 
+func Test_GetUptime(t *testing.T) {
+	uptime, err := GetUptime("./test/uptime")
+	if err != nil {
+		t.Errorf("GetUptime should not get error but got: %v", err)
+	} else if uptime != 360512 {
+		t.Errorf("Should have 360512 seconds of uptime but got: %v", uptime)
+	}
+}
+
+func Test_GetGeoLocation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "utgeo-")
+	if err != nil {
+		t.Error(err)
+	}
+	defer os.RemoveAll(dir)
+
+	geoFile := path.Join(dir, "geo.json")
+	if err := ioutil.WriteFile(geoFile, []byte(`{"lat": 41.0064, "long": -111.9393}`), 0644); err != nil {
+		t.Error(err)
+	}
+	if lat, long, err := GetGeoLocation(geoFile); err != nil {
+		t.Errorf("GetGeoLocation should not get error but got: %v", err)
+	} else if lat != 41.0064 || long != -111.9393 {
+		t.Errorf("Should have location 41.0064,-111.9393 but got: %v,%v", lat, long)
+	}
+
+	if err := ioutil.WriteFile(geoFile, []byte(`{"lat": 141.0064, "long": -111.9393}`), 0644); err != nil {
+		t.Error(err)
+	}
+	if _, _, err := GetGeoLocation(geoFile); err == nil {
+		t.Errorf("GetGeoLocation should get error for an out of range latitude but did not.")
+	}
+
+	if _, _, err := GetGeoLocation(path.Join(dir, "missing.json")); err == nil {
+		t.Errorf("GetGeoLocation should get error for a missing file but did not.")
+	}
+}
+
+func Test_GetGPUCount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "utgpu-")
+	if err != nil {
+		t.Error(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"nvidia0", "nvidia1", "nvidiactl", "nvidia-uvm", "null"} {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte{}, 0600); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if c, err := GetGPUCount(dir); err != nil {
+		t.Errorf("GetGPUCount should not get error but got: %v", err)
+	} else if c != 2 {
+		t.Errorf("Should have 2 gpus but got: %v", c)
+	}
+}
+
+func Test_GetDiskInfo(t *testing.T) {
+	total_disk, avail_disk, err := GetDiskInfo(".")
+	if err != nil {
+		t.Errorf("GetDiskInfo should not get error but got: %v", err)
+	} else if avail_disk > total_disk {
+		t.Errorf("The available disk %v mb should not be more than the total disk %v mb", avail_disk, total_disk)
+	}
+}
+
 func Test_ConvertToMB(t *testing.T) {
 	v, err := ConvertToMB("1", "GB")
 	if err != nil {
@@ -54,6 +54,8 @@ const (
 	CONTAINER_STOPPING          EventId = "CONTAINER_STOPPING"
 	CONTAINER_DESTROYED         EventId = "CONTAINER_DESTROYED"
 	CONTAINER_MAINTAIN          EventId = "CONTAINER_MAINTAIN"
+	WORKLOAD_PAUSE              EventId = "WORKLOAD_PAUSE"
+	WORKLOAD_RESUME             EventId = "WORKLOAD_RESUME"
 	LOAD_CONTAINER              EventId = "LOAD_CONTAINER"
 	CANCEL_MICROSERVICE         EventId = "CANCEL_MICROSERVICE"
 	CANCEL_MICROSERVICE_NETWORK EventId = "CANCEL_MICROSERVICE_NETWORK"
@@ -94,9 +96,12 @@ const (
 	NODE_PATTERN_CHANGE_SHUTDOWN EventId = "NODE_PATTERN_CHANGE_SHUTDOWN"
 	NODE_PATTERN_CHANGE_REREG    EventId = "NODE_PATTERN_CHANGE_REREG"
 	MESSAGE_STOP                 EventId = "MESSAGE_STOP"
+	CONFIG_RELOADED              EventId = "CONFIG_RELOADED"
 
 	// Service related
 	SERVICE_CONFIG_STATE_CHANGED EventId = "SERVICE_CONFIG_STATE_CHANGED"
+	SERVICE_PAUSED               EventId = "SERVICE_PAUSED"
+	SERVICE_RESUMED              EventId = "SERVICE_RESUMED"
 
 	// Object Policy related
 	OBJECT_POLICY_NEW       EventId = "OBJECT_POLICY_NEW"
@@ -145,6 +150,7 @@ const (
 	AG_TERMINATED EndContractCause = "AG_TERMINATED"
 	AG_ERROR      EndContractCause = "AG_ERROR"
 	AG_FULFILLED  EndContractCause = "AG_FULFILLED"
+	AG_UPGRADED   EndContractCause = "AG_UPGRADED" // a service of the agreement is upgraded or downgraded, the workload is started again by a new agreement
 )
 
 type Message interface {
@@ -856,11 +862,38 @@ func NewAgreementMessage(id EventId, lc *AgreementLaunchContext) *AgreementReach
 	}
 }
 
+// The layers of the images pulled for a deployment. The layers that were already on the node, and the layers that a lazy
+// pulling snapshotter leaves in the registry until the service reads them, are not downloaded.
+type ImagePullStats struct {
+	Layers          int   // the number of layers of the images
+	ReusedLayers    int   // the layers that were already on the node
+	LazyLayers      int   // the layers fetched on demand by the snapshotter
+	DownloadedBytes int64 // the size of the layers that were downloaded
+	SavedBytes      int64 // the size of the reused and lazy layers, when it is known
+}
+
+func (s ImagePullStats) String() string {
+	return fmt.Sprintf("Layers: %v, ReusedLayers: %v, LazyLayers: %v, DownloadedBytes: %v, SavedBytes: %v", s.Layers, s.ReusedLayers, s.LazyLayers, s.DownloadedBytes, s.SavedBytes)
+}
+
+func (s ImagePullStats) DownloadedLayers() int {
+	return s.Layers - s.ReusedLayers - s.LazyLayers
+}
+
+func (s *ImagePullStats) Add(other ImagePullStats) {
+	s.Layers += other.Layers
+	s.ReusedLayers += other.ReusedLayers
+	s.LazyLayers += other.LazyLayers
+	s.DownloadedBytes += other.DownloadedBytes
+	s.SavedBytes += other.SavedBytes
+}
+
 type ImageFetchMessage struct {
 	event                 Event
 	DeploymentDescription *containermessage.DeploymentDescription
 	LaunchContext         interface{}
 	Error                 error
+	PullStats             *ImagePullStats // the layers pulled for the deployment, nil when they are not known
 }
 
 // fulfill interface of events.Message
@@ -869,7 +902,7 @@ func (b *ImageFetchMessage) Event() Event {
 }
 
 func (b *ImageFetchMessage) String() string {
-	return fmt.Sprintf("event: %v, deploymentDescription: %v, launchContext: %v", b.event, b.DeploymentDescription, b.LaunchContext)
+	return fmt.Sprintf("event: %v, deploymentDescription: %v, launchContext: %v, pullStats: %v", b.event, b.DeploymentDescription, b.LaunchContext, b.PullStats)
 }
 
 func (b *ImageFetchMessage) ShortString() string {
@@ -1763,6 +1796,7 @@ type NodeShutdownMessage struct {
 	event      Event
 	block      bool
 	removeNode bool
+	exportPath string // the directory the data volumes of the services are exported to before they are removed
 }
 
 func (n *NodeShutdownMessage) Event() Event {
@@ -1774,7 +1808,7 @@ func (n NodeShutdownMessage) String() string {
 }
 
 func (n NodeShutdownMessage) ShortString() string {
-	return fmt.Sprintf("Event: %v, Blocking: %v, RemoveNode: %v", n.event, n.block, n.removeNode)
+	return fmt.Sprintf("Event: %v, Blocking: %v, RemoveNode: %v, ExportPath: %v", n.event, n.block, n.removeNode, n.exportPath)
 }
 
 func (n NodeShutdownMessage) Blocking() bool {
@@ -1785,13 +1819,18 @@ func (n NodeShutdownMessage) RemoveNode() bool {
 	return n.removeNode
 }
 
-func NewNodeShutdownMessage(id EventId, blocking bool, removeNode bool) *NodeShutdownMessage {
+func (n NodeShutdownMessage) ExportPath() string {
+	return n.exportPath
+}
+
+func NewNodeShutdownMessage(id EventId, blocking bool, removeNode bool, exportPath string) *NodeShutdownMessage {
 	return &NodeShutdownMessage{
 		event: Event{
 			Id: id,
 		},
 		block:      blocking,
 		removeNode: removeNode,
+		exportPath: exportPath,
 	}
 }
 
@@ -2010,6 +2049,62 @@ func NewServiceConfigStateChangeMessage(id EventId, scs []ServiceConfigState) *S
 	}
 }
 
+// A service is paused or resumed by the node owner.
+type ServicePauseMessage struct {
+	event Event
+	Url   string
+	Org   string
+}
+
+func (w *ServicePauseMessage) Event() Event {
+	return w.event
+}
+
+func (w *ServicePauseMessage) String() string {
+	return w.ShortString()
+}
+
+func (w *ServicePauseMessage) ShortString() string {
+	return fmt.Sprintf("Event: %v, Url: %v, Org: %v", w.event, w.Url, w.Org)
+}
+
+func NewServicePauseMessage(id EventId, url string, org string) *ServicePauseMessage {
+	return &ServicePauseMessage{
+		event: Event{
+			Id: id,
+		},
+		Url: url,
+		Org: org,
+	}
+}
+
+// The agent configuration file is reloaded, the given fields are applied to the running config.
+type ConfigReloadedMessage struct {
+	event   Event
+	Applied []string
+}
+
+func (w *ConfigReloadedMessage) Event() Event {
+	return w.event
+}
+
+func (w *ConfigReloadedMessage) String() string {
+	return w.ShortString()
+}
+
+func (w *ConfigReloadedMessage) ShortString() string {
+	return fmt.Sprintf("Event: %v, Applied: %v", w.event, w.Applied)
+}
+
+func NewConfigReloadedMessage(id EventId, applied []string) *ConfigReloadedMessage {
+	return &ConfigReloadedMessage{
+		event: Event{
+			Id: id,
+		},
+		Applied: applied,
+	}
+}
+
 type MMSObjectPolicyMessage struct {
 	event     Event
 	NewPolicy interface{} // Holds an object of type exchange.ObjectDestinationPolicy
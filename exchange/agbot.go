@@ -135,6 +135,40 @@ func CreateAgbotPublicKeyPatch(keyPath string) *PatchAgbotPublicKey {
 	return pdr
 }
 
+// Returns the agbot from the exchange. Temporary exchange errors are retried as configured in the HTTP factory of the context.
+func GetAgbot(ec ExchangeContext, agbotId string) (*Agbot, error) {
+
+	var resp interface{}
+	resp = new(GetAgbotsResponse)
+	targetURL := ec.GetExchangeURL() + "orgs/" + GetOrg(agbotId) + "/agbots/" + GetId(agbotId)
+
+	retryCount := ec.GetHTTPFactory().RetryCount
+	retryInterval := ec.GetHTTPFactory().GetRetryInterval()
+	for {
+		if err, tpErr := InvokeExchange(ec.GetHTTPFactory().NewHTTPClient(nil), "GET", targetURL, ec.GetExchangeId(), ec.GetExchangeToken(), nil, &resp); err != nil {
+			glog.Errorf(rpclogString(err.Error()))
+			return nil, err
+		} else if tpErr != nil {
+			glog.Warningf(rpclogString(tpErr.Error()))
+			if ec.GetHTTPFactory().RetryCount == 0 {
+				time.Sleep(time.Duration(retryInterval) * time.Second)
+				continue
+			} else if retryCount == 0 {
+				return nil, fmt.Errorf("Exceeded %v retries for error: %v", ec.GetHTTPFactory().RetryCount, tpErr)
+			} else {
+				retryCount--
+				time.Sleep(time.Duration(retryInterval) * time.Second)
+				continue
+			}
+		} else if ag, ok := resp.(*GetAgbotsResponse).Agbots[agbotId]; !ok {
+			return nil, fmt.Errorf("agbot %v not in GET response %v as expected", agbotId, resp.(*GetAgbotsResponse).Agbots)
+		} else {
+			glog.V(5).Infof(rpclogString(fmt.Sprintf("retrieved agbot %v from exchange %v", agbotId, ag.ShortString())))
+			return &ag, nil
+		}
+	}
+}
+
 func GetAgbotDeploymentPols(ec ExchangeContext) (map[string]ServedBusinessPolicy, error) {
 
 	var resp interface{}
@@ -0,0 +1,247 @@
+package exchange
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The margin by which an OIDC access token is refreshed before it expires, so that a token is not used while it
+// expires, and a clock that is a little ahead or behind does not make the exchange reject it.
+const DEFAULT_OIDC_CLOCK_SKEW_S = 30
+
+// The lifetime assumed for an OIDC access token when the identity provider does not tell when it expires.
+const DEFAULT_OIDC_TOKEN_LIFETIME_S = 300
+
+// An AuthProvider authenticates the calls to the exchange made with a user's id, instead of the password or token
+// that comes with the id.
+type AuthProvider interface {
+	// Authorization returns the value of the Authorization header of an exchange call.
+	Authorization(httpClient *http.Client) (string, error)
+	// Invalidate discards the credential returned by Authorization, after the exchange has rejected it.
+	Invalidate()
+}
+
+// An auth provider used for the calls of a user to the URLs that start with the prefix.
+type registeredAuthProvider struct {
+	urlPrefix string
+	provider  AuthProvider
+}
+
+// The auth providers of the users, keyed by the exchange id. The provider keyed by "" is used for all the users that
+// do not have their own.
+var authProviders = map[string]registeredAuthProvider{}
+var authProvidersLock sync.Mutex
+
+// Register the auth provider of the given user, or of all the users when the user is empty, for the calls to the URLs
+// that start with the prefix, e.g. the exchange URL so that the calls to the CSS keep the user's password or token. An
+// empty prefix is for all the URLs. A nil provider unregisters it.
+func RegisterAuthProvider(user string, urlPrefix string, provider AuthProvider) {
+	authProvidersLock.Lock()
+	defer authProvidersLock.Unlock()
+	if provider == nil {
+		delete(authProviders, user)
+	} else {
+		authProviders[user] = registeredAuthProvider{urlPrefix: strings.TrimSuffix(urlPrefix, "/"), provider: provider}
+	}
+}
+
+// Returns the auth provider of the given user's call to the URL, nil when the call is authenticated with the user's
+// password or token.
+func GetAuthProvider(user string, urlPath string) AuthProvider {
+	authProvidersLock.Lock()
+	defer authProvidersLock.Unlock()
+	registered, ok := authProviders[user]
+	if !ok {
+		registered, ok = authProviders[""]
+	}
+	if ok && strings.HasPrefix(urlPath, registered.urlPrefix) {
+		return registered.provider
+	}
+	return nil
+}
+
+// Authenticates with the user's id and password or token, the way the exchange calls are authenticated without a
+// provider.
+type BasicAuthProvider struct {
+	User     string
+	Password string
+}
+
+func NewBasicAuthProvider(user string, password string) *BasicAuthProvider {
+	return &BasicAuthProvider{User: user, Password: password}
+}
+
+func (b *BasicAuthProvider) Authorization(httpClient *http.Client) (string, error) {
+	return fmt.Sprintf("Basic %v", base64.StdEncoding.EncodeToString([]byte(b.User+":"+b.Password))), nil
+}
+
+func (b *BasicAuthProvider) Invalidate() {}
+
+// Authenticates with the short-lived access tokens of an OIDC identity provider. The access token is obtained from the
+// token endpoint with the refresh token, or with the client credentials when there is no refresh token or the identity
+// provider rejects it, and refreshed ClockSkew before it expires.
+type OIDCAuthProvider struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scope        string
+	ClockSkew    time.Duration
+	// Called with the new refresh token when the identity provider rotates it, so that it can be persisted.
+	StoreRefreshToken func(refreshToken string) error
+
+	lock         sync.Mutex
+	refreshToken string
+	accessToken  string
+	expiry       time.Time
+	refreshAt    time.Time
+}
+
+func NewOIDCAuthProvider(tokenURL string, clientID string, clientSecret string, scope string, refreshToken string, clockSkew time.Duration, storeRefreshToken func(string) error) *OIDCAuthProvider {
+	if clockSkew <= 0 {
+		clockSkew = DEFAULT_OIDC_CLOCK_SKEW_S * time.Second
+	}
+	return &OIDCAuthProvider{
+		TokenURL:          tokenURL,
+		ClientID:          clientID,
+		ClientSecret:      clientSecret,
+		Scope:             scope,
+		ClockSkew:         clockSkew,
+		StoreRefreshToken: storeRefreshToken,
+		refreshToken:      refreshToken,
+	}
+}
+
+func (o *OIDCAuthProvider) String() string {
+	return fmt.Sprintf("TokenURL: %v, ClientID: %v, Scope: %v, ClockSkew: %v, Expiry: %v", o.TokenURL, o.ClientID, o.Scope, o.ClockSkew, o.expiry)
+}
+
+// The response of the token endpoint, see RFC 6749 section 5.1.
+type oidcTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (o *OIDCAuthProvider) Authorization(httpClient *http.Client) (string, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.accessToken == "" || !time.Now().Before(o.refreshAt) {
+		if err := o.refresh(httpClient); err != nil {
+			return "", err
+		}
+	}
+	return "Bearer " + o.accessToken, nil
+}
+
+func (o *OIDCAuthProvider) Invalidate() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.accessToken = ""
+}
+
+// Get a new access token from the token endpoint. A refresh token that the identity provider rejects, because it has
+// expired or has been revoked, is dropped for the client credentials. The caller holds the lock.
+func (o *OIDCAuthProvider) refresh(httpClient *http.Client) error {
+	if o.refreshToken != "" {
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", o.refreshToken)
+		rejected, err := o.requestToken(httpClient, form)
+		if !rejected || o.ClientSecret == "" {
+			return err
+		}
+
+		glog.Warningf(rpclogString(fmt.Sprintf("the refresh token was rejected, getting an access token with the client credentials instead, error: %v", err)))
+		o.refreshToken = ""
+		if o.StoreRefreshToken != nil {
+			if err := o.StoreRefreshToken(""); err != nil {
+				glog.Errorf(rpclogString(fmt.Sprintf("unable to remove the refresh token from %v, error: %v", o.TokenURL, err)))
+			}
+		}
+	} else if o.ClientSecret == "" {
+		return errors.New(fmt.Sprintf("unable to get an access token from %v, there is neither a refresh token nor a client secret", o.TokenURL))
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	_, err := o.requestToken(httpClient, form)
+	return err
+}
+
+// Request an access token from the token endpoint with the grant in the form. The returned bool is true when the
+// identity provider rejected the grant, see RFC 6749 section 5.2.
+func (o *OIDCAuthProvider) requestToken(httpClient *http.Client, form url.Values) (bool, error) {
+	form.Set("client_id", o.ClientID)
+	if o.ClientSecret != "" {
+		form.Set("client_secret", o.ClientSecret)
+	}
+	if o.Scope != "" {
+		form.Set("scope", o.Scope)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, o.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, errors.New(fmt.Sprintf("unable to create the token request to %v, error: %v", o.TokenURL, err))
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Accept", "application/json")
+
+	requested := time.Now()
+	httpResp, err := httpClient.Do(req)
+	if err != nil {
+		return false, errors.New(fmt.Sprintf("unable to get an access token from %v, error: %v", o.TokenURL, err))
+	}
+	defer httpResp.Body.Close()
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return false, errors.New(fmt.Sprintf("unable to read the token response from %v, error: %v", o.TokenURL, err))
+	} else if httpResp.StatusCode != http.StatusOK {
+		rejected := httpResp.StatusCode == http.StatusBadRequest || httpResp.StatusCode == http.StatusUnauthorized
+		return rejected, errors.New(fmt.Sprintf("unable to get an access token from %v, status: %v, response: %v", o.TokenURL, httpResp.StatusCode, string(body)))
+	}
+
+	tokenResp := oidcTokenResponse{}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return false, errors.New(fmt.Sprintf("unable to demarshal the token response from %v, error: %v", o.TokenURL, err))
+	} else if tokenResp.AccessToken == "" {
+		return false, errors.New(fmt.Sprintf("the token response from %v has no access token", o.TokenURL))
+	} else if tokenResp.TokenType != "" && !strings.EqualFold(tokenResp.TokenType, "bearer") {
+		return false, errors.New(fmt.Sprintf("the token response from %v has an unsupported token type %v", o.TokenURL, tokenResp.TokenType))
+	}
+
+	// The lifetime is counted from the request, the clocks of the identity provider and of this host do not matter.
+	lifetime := time.Duration(tokenResp.ExpiresIn) * time.Second
+	if lifetime <= 0 {
+		lifetime = DEFAULT_OIDC_TOKEN_LIFETIME_S * time.Second
+	}
+	// A token that lives less than twice the clock skew is refreshed halfway through its life.
+	margin := o.ClockSkew
+	if margin > lifetime/2 {
+		margin = lifetime / 2
+	}
+	o.accessToken = tokenResp.AccessToken
+	o.expiry = requested.Add(lifetime)
+	o.refreshAt = o.expiry.Add(-margin)
+	glog.V(3).Infof(rpclogString(fmt.Sprintf("got an access token from %v that expires at %v", o.TokenURL, o.expiry)))
+
+	if tokenResp.RefreshToken != "" && tokenResp.RefreshToken != o.refreshToken {
+		o.refreshToken = tokenResp.RefreshToken
+		if o.StoreRefreshToken != nil {
+			if err := o.StoreRefreshToken(o.refreshToken); err != nil {
+				glog.Errorf(rpclogString(fmt.Sprintf("unable to store the refresh token from %v, error: %v", o.TokenURL, err)))
+			}
+		}
+	}
+	return false, nil
+}
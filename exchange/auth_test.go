@@ -0,0 +1,142 @@
+//go:build unit
+// +build unit
+
+package exchange
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_OIDCAuthProvider(t *testing.T) {
+	grants := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		grants = append(grants, r.Form.Get("grant_type")+":"+r.Form.Get("refresh_token"))
+		fmt.Fprintf(w, `{"access_token":"access%v","token_type":"Bearer","expires_in":3600,"refresh_token":"refresh%v"}`, len(grants), len(grants))
+	}))
+	defer server.Close()
+
+	stored := ""
+	provider := NewOIDCAuthProvider(server.URL, "agent", "", "", "refresh0", 0, func(rt string) error { stored = rt; return nil })
+
+	if auth, err := provider.Authorization(server.Client()); err != nil {
+		t.Fatalf("unable to get an access token, error: %v", err)
+	} else if auth != "Bearer access1" || stored != "refresh1" {
+		t.Errorf("wrong authorization %v or stored refresh token %v", auth, stored)
+	}
+
+	// the access token is reused until it is about to expire
+	if auth, _ := provider.Authorization(server.Client()); auth != "Bearer access1" || len(grants) != 1 {
+		t.Errorf("the access token should be reused, got %v after %v grants", auth, grants)
+	}
+	provider.refreshAt = time.Now().Add(-time.Second)
+	if auth, _ := provider.Authorization(server.Client()); auth != "Bearer access2" {
+		t.Errorf("the access token should be refreshed, got %v", auth)
+	}
+
+	// a rejected access token is refreshed
+	provider.Invalidate()
+	if auth, _ := provider.Authorization(server.Client()); auth != "Bearer access3" || stored != "refresh3" {
+		t.Errorf("the invalidated access token should be refreshed, got %v with refresh token %v", auth, stored)
+	}
+	if strings.Join(grants, ",") != "refresh_token:refresh0,refresh_token:refresh1,refresh_token:refresh2" {
+		t.Errorf("wrong grants %v", grants)
+	}
+	if margin := provider.expiry.Sub(provider.refreshAt); margin != DEFAULT_OIDC_CLOCK_SKEW_S*time.Second {
+		t.Errorf("wrong refresh margin %v", margin)
+	}
+
+	// there is nothing to get an access token with
+	provider = NewOIDCAuthProvider(server.URL, "agent", "", "", "", 0, nil)
+	if _, err := provider.Authorization(server.Client()); err == nil {
+		t.Errorf("an access token should not be got without refresh token or client secret")
+	}
+}
+
+func Test_OIDCAuthProvider_RejectedRefreshToken(t *testing.T) {
+	grants := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		grants = append(grants, r.Form.Get("grant_type"))
+		if r.Form.Get("grant_type") == "refresh_token" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error":"invalid_grant"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":"access%v","token_type":"Bearer","expires_in":3600}`, len(grants))
+	}))
+	defer server.Close()
+
+	// the client credentials are used instead of the rejected refresh token, which is dropped
+	stored := "refresh0"
+	provider := NewOIDCAuthProvider(server.URL, "agent", "secret", "", "refresh0", 0, func(rt string) error { stored = rt; return nil })
+	if auth, err := provider.Authorization(server.Client()); err != nil {
+		t.Fatalf("unable to get an access token, error: %v", err)
+	} else if auth != "Bearer access2" || stored != "" {
+		t.Errorf("wrong authorization %v or stored refresh token %v", auth, stored)
+	}
+	provider.Invalidate()
+	if _, err := provider.Authorization(server.Client()); err != nil {
+		t.Errorf("unable to get an access token, error: %v", err)
+	} else if strings.Join(grants, ",") != "refresh_token,client_credentials,client_credentials" {
+		t.Errorf("wrong grants %v", grants)
+	}
+
+	// without client secret the rejection is returned
+	provider = NewOIDCAuthProvider(server.URL, "agent", "", "", "refresh0", 0, nil)
+	if _, err := provider.Authorization(server.Client()); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("the rejection of the refresh token should have been returned, got %v", err)
+	}
+}
+
+func Test_InitNodeAuthProvider_NoExchangeURL(t *testing.T) {
+	defer func() { nodeAuthProvider, nodeAuthURL = nil, "" }()
+
+	cfg := &config.HorizonConfig{Edge: config.Config{ExchangeAuth: config.ExchangeAuth_OIDC, ExchangeOIDCTokenURL: "https://idp/token", ExchangeOIDCClientID: "agent"}}
+	if err := InitNodeAuthProvider(cfg, nil); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if nodeAuthProvider != nil {
+		t.Errorf("the access tokens should not be used without an exchange URL")
+	}
+
+	// the access tokens would be sent with every call of the node
+	defer RegisterAuthProvider("org/node", "", nil)
+	nodeAuthProvider = NewBasicAuthProvider("org/node", "token")
+	UseNodeAuthProvider("org/node")
+	if p := GetAuthProvider("org/node", "https://css/api/v1/objects/org"); p != nil {
+		t.Errorf("the node should not use an auth provider without an exchange URL, got %v", p)
+	}
+}
+
+func Test_GetAuthProvider(t *testing.T) {
+	defer RegisterAuthProvider("", "", nil)
+	defer RegisterAuthProvider("org/node", "", nil)
+
+	if p := GetAuthProvider("org/node", "https://exchange/v1/orgs/org"); p != nil {
+		t.Errorf("there should be no auth provider, got %v", p)
+	}
+
+	nodeProvider := NewBasicAuthProvider("org/node", "token")
+	RegisterAuthProvider("org/node", "https://exchange/v1/", nodeProvider)
+	if p := GetAuthProvider("org/node", "https://exchange/v1/orgs/org"); p != nodeProvider {
+		t.Errorf("the node should use its auth provider, got %v", p)
+	} else if p := GetAuthProvider("org/node", "https://css/api/v1/objects/org"); p != nil {
+		t.Errorf("the calls to the css should not use the auth provider, got %v", p)
+	} else if p := GetAuthProvider("org/user", "https://exchange/v1/orgs/org"); p != nil {
+		t.Errorf("another user should not use the auth provider of the node, got %v", p)
+	}
+
+	defaultProvider := NewBasicAuthProvider("", "")
+	RegisterAuthProvider("", "", defaultProvider)
+	if p := GetAuthProvider("org/user", "https://css/api/v1/objects/org"); p != defaultProvider {
+		t.Errorf("the users should use the default auth provider, got %v", p)
+	} else if p := GetAuthProvider("org/node", "https://exchange/v1/orgs/org"); p != nodeProvider {
+		t.Errorf("the node should use its auth provider, got %v", p)
+	}
+}
@@ -33,15 +33,22 @@ const NODE_POL_TYPE_CACHE = "NODE_POLICY_CACHE"
 const EXCH_VERS_TYPE_CACHE = "EXCH_VERS_CACHE"
 const ORG_DEF_TYPE_CACHE = "ORG_DEF_CACHE"
 const HA_GROUP_TYPE_CACHE = "HA_GROUP_TYPE_CACHE"
+const PATTERN_TYPE_CACHE = "PATTERN_CACHE"
 
-// This only applies to the exchange version.
+// This only applies to the exchange version and the patterns.
 // All others are monitored for changes theough the changes api
 const CACHE_TIMEOUT_S = 900
 
+// The patterns are monitored for changes through the changes api too, but a node does not handle the pattern changes.
+// A cached pattern is revalidated with its etag after this time, so that a node does not use an old pattern forever.
+const PATTERN_CACHE_TIMEOUT_S = CACHE_TIMEOUT_S
+
 type CacheEntry struct {
 	Resource    interface{} `json:"resource"`
 	LastUpdated uint64      `json:"lastupdated"`
 	Hash        []byte      `json:"hash"`
+	ETag        string      `json:"etag,omitempty"`  // The etag the exchange returned with the resource.
+	Stale       bool        `json:"stale,omitempty"` // The resource has changed, it is only kept to be revalidated with its etag.
 }
 
 // Allow getresources to return a copy of cached resource so that multiple threads can use the same resource concurrently
@@ -62,6 +69,8 @@ func (c *CacheEntry) Copy() interface{} {
 	case exchangecommon.HAGroup:
 		haGroup := c.Resource.(exchangecommon.HAGroup)
 		resourceCopy = *(&haGroup).DeepCopy()
+	case map[string]Pattern:
+		resourceCopy = PatternMap(c.Resource.(map[string]Pattern)).DeepCopy()
 	default:
 		resourceCopy = c.Resource
 	}
@@ -90,8 +99,9 @@ func GetNodePolicyFromCache(nodeOrg string, nodeId string) *ExchangeNodePolicy {
 }
 
 // GetServiceFromCache returns the service definitions of all service versions from the exchange cache if any are present, or nil if it is not
-func GetServiceFromCache(svcOrg string, svcId string, svcArch string) map[string]ServiceDefinition {
-	svc := GetResourceFromCache(ServiceCacheMapKey(svcOrg, svcId, svcArch), SVC_DEF_TYPE_CACHE, 0)
+// or if they were cached more than expirationS seconds ago. An expirationS of 0 never expires them.
+func GetServiceFromCache(svcOrg string, svcId string, svcArch string, expirationS uint64) map[string]ServiceDefinition {
+	svc := GetResourceFromCache(ServiceCacheMapKey(svcOrg, svcId, svcArch), SVC_DEF_TYPE_CACHE, expirationS)
 
 	if typedSvc, ok := svc.(map[string]ServiceDefinition); ok {
 		return typedSvc
@@ -109,6 +119,27 @@ func (s ServiceMap) DeepCopy() map[string]ServiceDefinition {
 	return svcMapCopy
 }
 
+// GetPatternsFromCache returns the patterns of an org, or the given pattern, from the exchange cache if they are present
+// and have not changed, or nil if they are not
+func GetPatternsFromCache(patternOrg string, pattern string) map[string]Pattern {
+	pats := GetResourceFromCache(PatternCacheMapKey(patternOrg, pattern), PATTERN_TYPE_CACHE, PATTERN_CACHE_TIMEOUT_S)
+
+	if typedPats, ok := pats.(map[string]Pattern); ok {
+		return typedPats
+	}
+	return nil
+}
+
+type PatternMap map[string]Pattern
+
+func (p PatternMap) DeepCopy() map[string]Pattern {
+	patMapCopy := make(map[string]Pattern, len(p))
+	for key, val := range p {
+		patMapCopy[key] = *val.DeepCopy()
+	}
+	return patMapCopy
+}
+
 // GetServicePolicyFromCache returns the service policy from the exchange cache if it is present, or nil if it is not
 func GetServicePolicyFromCache(sId string) *ExchangeServicePolicy {
 	svcPol := GetResourceFromCache(sId, SVC_POL_TYPE_CACHE, 0)
@@ -139,9 +170,10 @@ func (s ServiceDockerAuth) DeepCopy() []ImageDockerAuth {
 	return authCopy
 }
 
-// GetServiceKeysFromCache returns the service keys from the exchange cache if it is present, or nil if it is not
-func GetServiceKeysFromCache(sId string) *map[string]string {
-	svcKeys := GetResourceFromCache(sId, SVC_KEY_TYPE_CACHE, 0)
+// GetServiceKeysFromCache returns the service keys from the exchange cache if it is present, or nil if it is not or if they
+// were cached more than expirationS seconds ago. An expirationS of 0 never expires them.
+func GetServiceKeysFromCache(sId string, expirationS uint64) *map[string]string {
+	svcKeys := GetResourceFromCache(sId, SVC_KEY_TYPE_CACHE, expirationS)
 
 	if typedSvcKeys, ok := svcKeys.(map[string]string); ok {
 		return &typedSvcKeys
@@ -213,14 +245,41 @@ func GetResourceFromCache(resourceKey string, resourceType string, expirationS u
 		return nil
 	}
 	expired := uint64(time.Now().Unix())-typedEntry.LastUpdated > expirationS
-	if expirationS > 0 && expired {
+	if (expirationS > 0 && expired) || typedEntry.Stale {
 		return nil
 	}
 	return typedEntry.Copy()
 }
 
+// GetRevalidationEntryFromCache returns the resource from the specified type exchange cache with its etag, even if it
+// has changed or expired, so that the exchange can be asked whether it has changed since. It returns nil when the
+// resource is not present or has no etag.
+func GetRevalidationEntryFromCache(resourceKey string, resourceType string) (interface{}, string) {
+	if ExchangeResourceCache == nil || ExchangeResourceCache.allResources == nil {
+		return nil, ""
+	}
+
+	ExchangeResourceCache.Lock.Lock()
+	defer ExchangeResourceCache.Lock.Unlock()
+
+	resourceCache, ok := ExchangeResourceCache.allResources[resourceType]
+	if !ok {
+		return nil, ""
+	}
+	if typedEntry, ok := resourceCache.Get(resourceKey).(CacheEntry); ok && typedEntry.ETag != "" {
+		return typedEntry.Copy(), typedEntry.ETag
+	}
+	return nil, ""
+}
+
 // UpdateCache will replace or create the provided resource in the given resource type cache
 func UpdateCache(resourceKey string, resourceType string, updatedResource interface{}) {
+	UpdateCacheWithETag(resourceKey, resourceType, updatedResource, "")
+}
+
+// UpdateCacheWithETag will replace or create the provided resource in the given resource type cache, with the etag
+// the exchange returned with it
+func UpdateCacheWithETag(resourceKey string, resourceType string, updatedResource interface{}, etag string) {
 	glog.V(3).Infof("Update exchange cache %s/%s with %v", resourceType, resourceKey, updatedResource)
 
 	if ExchangeResourceCache == nil {
@@ -251,11 +310,13 @@ func UpdateCache(resourceKey string, resourceType string, updatedResource interf
 		}
 	}
 	if existingRecord == nil || !bytes.Equal(existingRecordTyped.Hash, recordHash) {
-		newRecord := CacheEntry{Resource: updatedResource, LastUpdated: uint64(time.Now().Unix()), Hash: recordHash}
+		newRecord := CacheEntry{Resource: updatedResource, LastUpdated: uint64(time.Now().Unix()), Hash: recordHash, ETag: etag}
 		resourceCache.Put(resourceKey, newRecord)
 		return
 	}
 	existingRecordTyped.LastUpdated = uint64(time.Now().Unix())
+	existingRecordTyped.ETag = etag
+	existingRecordTyped.Stale = false
 	resourceCache.Put(resourceKey, existingRecordTyped)
 }
 
@@ -297,6 +358,29 @@ func DeleteCacheResource(resourceType string, resourceKey string) interface{} {
 	return retResource
 }
 
+// MarkCacheResourceStale will mark the cached resource specified as changed, so that it is not returned from the cache
+// anymore but can be revalidated with its etag. A resource without etag is deleted.
+func MarkCacheResourceStale(resourceType string, resourceKey string) {
+	glog.V(5).Infof("Mark exchange cache resource %s/%s stale", resourceType, resourceKey)
+	if ExchangeResourceCache == nil || ExchangeResourceCache.allResources == nil {
+		return
+	}
+
+	ExchangeResourceCache.Lock.Lock()
+	defer ExchangeResourceCache.Lock.Unlock()
+
+	if resourceCache, ok := ExchangeResourceCache.allResources[resourceType]; ok {
+		if typedEntry, ok := resourceCache.Get(resourceKey).(CacheEntry); !ok {
+			return
+		} else if typedEntry.ETag == "" {
+			resourceCache.Delete(resourceKey)
+		} else {
+			typedEntry.Stale = true
+			resourceCache.Put(resourceKey, typedEntry)
+		}
+	}
+}
+
 // DeleteOrgCachedResources will delete all cached resources from the given org
 func DeleteOrgCachedResources(org string) {
 	glog.V(5).Infof("Delete all resources from org %v", org)
@@ -421,6 +505,10 @@ func DeleteCacheResourceFromChange(change ExchangeChange, nodeId string) interfa
 		svcPol := GetServicePolicyFromCache(sIdWithOrg)
 		DeleteCacheResource(SVC_POL_TYPE_CACHE, sIdWithOrg)
 		return svcPol
+	} else if change.IsPattern() {
+		// the patterns of the org are cached together too
+		MarkCacheResourceStale(PATTERN_TYPE_CACHE, PatternCacheMapKey(change.OrgID, change.ID))
+		MarkCacheResourceStale(PATTERN_TYPE_CACHE, PatternCacheMapKey(change.OrgID, ""))
 	} else if change.IsOrg() && (change.Operation == CHANGE_OPERATION_CREATED || change.Operation == CHANGE_OPERATION_DELETED) {
 		DeleteOrgCachedResources(change.OrgID)
 	} else if change.IsOrg() {
@@ -456,6 +544,12 @@ func ServicePolicyCacheMapKey(svcOrg string, svcId string, svcArch string, svcVe
 	return fmt.Sprintf("%s/%s/%s/%s", svcOrg, svcId, svcArch, svcVersion)
 }
 
+// PatternCacheMapKey returns a string to use for the cache map key for a pattern with the given org and name, or for
+// all the patterns of the org when the name is empty
+func PatternCacheMapKey(patternOrg string, pattern string) string {
+	return fmt.Sprintf("%s/%s", patternOrg, pattern)
+}
+
 // NodeCacheMapKey returns a string to use for the cache map key for a node with the given org and id
 func NodeCacheMapKey(nodeOrg string, nodeId string) string {
 	return fmt.Sprintf("%s/%s", nodeOrg, nodeId)
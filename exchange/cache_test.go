@@ -60,7 +60,7 @@ func TestGetServiceFromCache(t *testing.T) {
 
 	UpdateCache(ServiceCacheMapKey("e2edev@somecomp.com", "a-new-service", "amd64"), SVC_DEF_TYPE_CACHE, svcDefs)
 
-	cachedSvcDefs := GetServiceFromCache("e2edev@somecomp.com", "a-new-service", "amd64")
+	cachedSvcDefs := GetServiceFromCache("e2edev@somecomp.com", "a-new-service", "amd64", 0)
 
 	if cachedSvcDefs["0.0.0"].Deployment != "abcdefg12345" || cachedSvcDefs["0.0.1"].Deployment != "gfedcba54321" {
 		t.Errorf("Error: unexpected value found in cached service.")
@@ -124,9 +124,9 @@ func TestDeleteCacheResourceFromChange(t *testing.T) {
 
 	DeleteCacheResourceFromChange(change, "")
 
-	if cachedSvc := GetServiceFromCache("e2edev@somecomp.com", "a-new-service", "amd64"); cachedSvc != nil {
+	if cachedSvc := GetServiceFromCache("e2edev@somecomp.com", "a-new-service", "amd64", 0); cachedSvc != nil {
 		t.Errorf("Error: failed to remove service resource from cache after exchange org create change")
-	} else if cachedSvc = GetServiceFromCache("userdev", "another-service", "amd64"); cachedSvc == nil {
+	} else if cachedSvc = GetServiceFromCache("userdev", "another-service", "amd64", 0); cachedSvc == nil {
 		t.Errorf("Error: service userdev/another-service deleted from cache from change to a different org")
 	} else if cachedNode := GetNodeFromCache("e2edev@somecomp.com", "test-node-1"); cachedNode != nil {
 		t.Errorf("Error: failed to remove node resource from cache after exchange org create change")
@@ -143,6 +143,33 @@ func TestDeleteCacheResourceFromChange(t *testing.T) {
 	}
 }
 
+func TestMarkCacheResourceStale(t *testing.T) {
+	pats := map[string]Pattern{"e2edev@somecomp.com/pat1": Pattern{Label: "pattern 1"}}
+	UpdateCacheWithETag(PatternCacheMapKey("e2edev@somecomp.com", ""), PATTERN_TYPE_CACHE, pats, "etag1")
+	UpdateCache(PatternCacheMapKey("e2edev@somecomp.com", "pat1"), PATTERN_TYPE_CACHE, pats)
+	UpdateCacheWithETag(PatternCacheMapKey("userdev", ""), PATTERN_TYPE_CACHE, map[string]Pattern{"userdev/pat2": Pattern{Label: "pattern 2"}}, "etag2")
+
+	change := ExchangeChange{OrgID: "e2edev@somecomp.com", ID: "pat1", Resource: "pattern"}
+	DeleteCacheResourceFromChange(change, "")
+
+	// the changed patterns are not returned anymore, but the ones with an etag are kept to be revalidated
+	if cachedPats := GetPatternsFromCache("e2edev@somecomp.com", ""); cachedPats != nil {
+		t.Errorf("Error: changed patterns returned from cache.")
+	} else if cachedPats, etag := GetRevalidationEntryFromCache(PatternCacheMapKey("e2edev@somecomp.com", ""), PATTERN_TYPE_CACHE); etag != "etag1" || !reflect.DeepEqual(cachedPats, pats) {
+		t.Errorf("Error: changed patterns with etag not kept for revalidation, got %v with etag %v", cachedPats, etag)
+	} else if cachedPats, _ := GetRevalidationEntryFromCache(PatternCacheMapKey("e2edev@somecomp.com", "pat1"), PATTERN_TYPE_CACHE); cachedPats != nil {
+		t.Errorf("Error: changed pattern without etag kept in cache.")
+	} else if cachedPats := GetPatternsFromCache("userdev", ""); cachedPats == nil {
+		t.Errorf("Error: patterns of userdev removed by a pattern change on a different org")
+	}
+
+	// the revalidated patterns are returned again
+	UpdateCacheWithETag(PatternCacheMapKey("e2edev@somecomp.com", ""), PATTERN_TYPE_CACHE, pats, "etag1")
+	if cachedPats := GetPatternsFromCache("e2edev@somecomp.com", ""); !reflect.DeepEqual(cachedPats, pats) {
+		t.Errorf("Error: revalidated patterns not returned from cache, got %v", cachedPats)
+	}
+}
+
 func TestCopy(t *testing.T) {
 	dev := Device{Token: "12345", Name: "cachedNode", Owner: "Kim", NodeType: "cluster", Pattern: "helloworld", MsgEndPoint: "an endpoint", LastHeartbeat: "23:00", PublicKey: "a key", Arch: "amd64", LastUpdated: "now",
 		RegisteredServices: []Microservice{Microservice{Url: "helloworld1", NumAgreements: 1, Policy: "a policy", ConfigState: "Configured", Properties: []MSProp{MSProp{Name: "HW_WHO", Value: "world", PropType: "string", Op: "=="}, MSProp{Name: "HW_WHO2", Value: "world2", PropType: "string", Op: "=="}}}},
@@ -0,0 +1,83 @@
+package exchange
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"sync"
+)
+
+// Allows the services of every org in the cross org service allow list.
+const CROSS_ORG_ALLOW_ALL = "*"
+
+// The exchange changes of the agent only cover its own org, so the service definitions and signing keys of the other
+// orgs are kept in the exchange cache for this many seconds before they are read again.
+const CROSS_ORG_CACHE_EXPIRATION_S = 300
+
+// The orgs, other than the org of the service, whose services can be required by a service. nil allows all the orgs.
+var crossOrgServiceOrgs []string
+var crossOrgServiceOrgsLock sync.Mutex
+
+// Set the cross org service allow list from the config of the agent.
+func InitCrossOrgServices(cfg *config.HorizonConfig) {
+	if len(cfg.Edge.CrossOrgServiceOrgs) == 0 {
+		return
+	}
+	glog.Infof(rpclogString(fmt.Sprintf("the required services can only be in the org of the service or in %v", cfg.Edge.CrossOrgServiceOrgs)))
+	SetCrossOrgServiceOrgs(cfg.Edge.CrossOrgServiceOrgs)
+}
+
+func SetCrossOrgServiceOrgs(orgs []string) {
+	crossOrgServiceOrgsLock.Lock()
+	defer crossOrgServiceOrgsLock.Unlock()
+	crossOrgServiceOrgs = orgs
+}
+
+// Returns true if a service in svcOrg can require a service in depOrg. A service can always require the services of
+// its own org.
+func IsCrossOrgServiceAllowed(svcOrg string, depOrg string) bool {
+	if svcOrg == depOrg {
+		return true
+	}
+
+	crossOrgServiceOrgsLock.Lock()
+	defer crossOrgServiceOrgsLock.Unlock()
+	if crossOrgServiceOrgs == nil {
+		return true
+	}
+	for _, org := range crossOrgServiceOrgs {
+		if org == depOrg || org == CROSS_ORG_ALLOW_ALL {
+			return true
+		}
+	}
+	return false
+}
+
+// The error returned when a service requires a service of an org that is not in the cross org service allow list.
+type CrossOrgServiceError struct {
+	ServiceOrg string
+	DepURL     string
+	DepOrg     string
+}
+
+func (e *CrossOrgServiceError) Error() string {
+	return fmt.Sprintf("required service %v/%v is not allowed for the services of org %v, add org %v to the cross org service orgs of the agent to allow it.", e.DepOrg, e.DepURL, e.ServiceOrg, e.DepOrg)
+}
+
+func NewCrossOrgServiceError(svcOrg string, depURL string, depOrg string) *CrossOrgServiceError {
+	return &CrossOrgServiceError{ServiceOrg: svcOrg, DepURL: depURL, DepOrg: depOrg}
+}
+
+func IsCrossOrgServiceError(err error) bool {
+	_, ok := err.(*CrossOrgServiceError)
+	return ok
+}
+
+// Returns the number of seconds that a service definition or signing keys of svcOrg are kept in the exchange cache
+// of the caller, 0 for the caller's own org whose cache entries are updated from the exchange changes.
+func crossOrgCacheExpiration(ec ExchangeContext, svcOrg string) uint64 {
+	if GetOrg(ec.GetExchangeId()) == svcOrg {
+		return 0
+	}
+	return CROSS_ORG_CACHE_EXPIRATION_S
+}
@@ -0,0 +1,60 @@
+//go:build unit
+// +build unit
+
+package exchange
+
+import (
+	"github.com/open-horizon/anax/exchangecommon"
+	"testing"
+)
+
+func Test_IsCrossOrgServiceAllowed(t *testing.T) {
+	defer SetCrossOrgServiceOrgs(nil)
+
+	if !IsCrossOrgServiceAllowed("myorg", "otherorg") {
+		t.Errorf("all the orgs should be allowed without an allow list")
+	}
+
+	SetCrossOrgServiceOrgs([]string{"IBM"})
+	if !IsCrossOrgServiceAllowed("myorg", "myorg") {
+		t.Errorf("the org of the service should always be allowed")
+	} else if !IsCrossOrgServiceAllowed("myorg", "IBM") {
+		t.Errorf("the org in the allow list should be allowed")
+	} else if IsCrossOrgServiceAllowed("myorg", "otherorg") {
+		t.Errorf("the org that is not in the allow list should not be allowed")
+	}
+
+	SetCrossOrgServiceOrgs([]string{CROSS_ORG_ALLOW_ALL})
+	if !IsCrossOrgServiceAllowed("myorg", "otherorg") {
+		t.Errorf("all the orgs should be allowed with %v", CROSS_ORG_ALLOW_ALL)
+	}
+}
+
+// A required service of an org that is not in the allow list is not resolved, at any level of the dependencies.
+func Test_ServiceResolver_CrossOrg(t *testing.T) {
+	defer SetCrossOrgServiceOrgs(nil)
+
+	sdMap := map[string][]exchangecommon.ServiceDependency{
+		"http://service1": []exchangecommon.ServiceDependency{
+			exchangecommon.ServiceDependency{URL: "http://my.com/ms/ms1", Org: "IBM", Version: "1.5.0", Arch: "amd64"},
+		},
+		"http://my.com/ms/ms1": []exchangecommon.ServiceDependency{
+			exchangecommon.ServiceDependency{URL: "http://my.com/ms/ms2", Org: "otherOrg", Version: "1.5.0", Arch: "amd64"},
+		},
+	}
+	sh := getRecursiveVariableServiceHandler([]exchangecommon.UserInput{}, sdMap)
+
+	SetCrossOrgServiceOrgs([]string{"IBM", "otherOrg"})
+	if _, _, _, err := ServiceResolver("http://service1", "test", "1.0.0", "amd64", sh); err != nil {
+		t.Errorf("should not have returned err: %v", err)
+	}
+
+	SetCrossOrgServiceOrgs([]string{"IBM"})
+	if _, _, _, err := ServiceResolver("http://service1", "test", "1.0.0", "amd64", sh); !IsCrossOrgServiceError(err) {
+		t.Errorf("should have returned a cross org service error, got %v", err)
+	} else if _, _, _, _, err := ServiceDefResolver("http://service1", "test", "1.0.0", "amd64", sh); !IsCrossOrgServiceError(err) {
+		t.Errorf("should have returned a cross org service error, got %v", err)
+	} else if err.(*CrossOrgServiceError).DepOrg != "otherOrg" || err.(*CrossOrgServiceError).ServiceOrg != "IBM" {
+		t.Errorf("wrong cross org service error %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package exchange
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"sync"
+)
+
+// The agreement protocol messages that are delivered directly between an agent and an agbot, instead of through the
+// exchange mailboxes, have this message id. There is nothing to delete from the exchange for them.
+const DIRECT_MSG_ID = 0
+
+// A transport that delivers the encrypted messages built by ConstructExchangeMessage directly to the receiver, instead
+// of posting them to its exchange mailbox. Send returns an error when the message cannot be delivered that way, and the
+// message is then posted to the exchange.
+type DirectTransport interface {
+	Send(receiverId string, message []byte) error
+}
+
+// The direct transport of the agent to its agbot, nil when it is not configured.
+var directTransport DirectTransport
+var directTransportLock sync.Mutex
+
+func SetDirectTransport(t DirectTransport) {
+	directTransportLock.Lock()
+	defer directTransportLock.Unlock()
+	directTransport = t
+}
+
+// Send an encrypted message to an agbot with the direct transport of the agent. Returns false when the message has to be
+// posted to the exchange instead.
+func SendDirectMessage(receiverId string, message []byte) bool {
+	directTransportLock.Lock()
+	t := directTransport
+	directTransportLock.Unlock()
+
+	if t == nil {
+		return false
+	} else if err := t.Send(receiverId, message); err != nil {
+		glog.V(5).Infof(fmt.Sprintf("unable to send message directly to %v, sending it through the exchange, error: %v", receiverId, err))
+		return false
+	}
+	return true
+}
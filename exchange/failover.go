@@ -0,0 +1,165 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/metrics"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The number of consecutive transport errors after which the circuit breaker of an exchange URL opens.
+const DEFAULT_CIRCUIT_BREAKER_FAILURES = 5
+
+// How long the circuit breaker of an exchange URL stays open before the URL is health checked.
+const DEFAULT_CIRCUIT_BREAKER_OPEN_S = 30
+
+// A base URL of the exchange and the state of its circuit breaker.
+type exchangeEndpoint struct {
+	url       string
+	failures  int       // The number of consecutive transport errors.
+	openUntil time.Time // The circuit breaker is open until then, the calls go to the next URL.
+}
+
+func (e *exchangeEndpoint) String() string {
+	return fmt.Sprintf("URL: %v, Failures: %v, OpenUntil: %v", e.url, e.failures, e.openUntil)
+}
+
+// The base URLs of the same exchange, in the order of preference. The calls to the first URL go to the first one whose
+// circuit breaker is closed. The circuit breaker of a URL opens after MaxFailures consecutive transport errors, and
+// after OpenDuration the URL is health checked before it is used again. When all the circuit breakers are open, the
+// calls fail right away with a transport error, so that a browning out exchange is not loaded more by the retries.
+type ExchangeFailover struct {
+	lock         sync.Mutex
+	endpoints    []*exchangeEndpoint
+	MaxFailures  int
+	OpenDuration time.Duration
+}
+
+func NewExchangeFailover(urls []string, maxFailures int, openDuration time.Duration) *ExchangeFailover {
+	if maxFailures <= 0 {
+		maxFailures = DEFAULT_CIRCUIT_BREAKER_FAILURES
+	}
+	if openDuration <= 0 {
+		openDuration = DEFAULT_CIRCUIT_BREAKER_OPEN_S * time.Second
+	}
+	f := &ExchangeFailover{MaxFailures: maxFailures, OpenDuration: openDuration}
+	for _, u := range urls {
+		f.endpoints = append(f.endpoints, &exchangeEndpoint{url: strings.TrimSuffix(u, "/") + "/"})
+	}
+	return f
+}
+
+// Fail the calls to the ExchangeURL of the agent over to its ExchangeURLs, when they or the circuit breaker are
+// configured.
+func InitExchangeFailover(cfg *config.HorizonConfig) {
+	if cfg.Edge.ExchangeURL == "" || (len(cfg.Edge.ExchangeURLs) == 0 && cfg.Edge.ExchangeCircuitBreakerFailures <= 0) {
+		return
+	}
+	urls := append([]string{cfg.Edge.ExchangeURL}, cfg.Edge.ExchangeURLs...)
+	f := NewExchangeFailover(urls, cfg.Edge.ExchangeCircuitBreakerFailures, time.Duration(cfg.Edge.ExchangeCircuitBreakerOpenS)*time.Second)
+	glog.Infof(rpclogString(fmt.Sprintf("the calls to the exchange fail over between %v", urls)))
+	SetExchangeFailover(f)
+}
+
+// The failover of the exchange URLs of the agent, nil when it is not configured.
+var exchangeFailover *ExchangeFailover
+var exchangeFailoverLock sync.Mutex
+
+func SetExchangeFailover(f *ExchangeFailover) {
+	exchangeFailoverLock.Lock()
+	defer exchangeFailoverLock.Unlock()
+	exchangeFailover = f
+
+	if f != nil {
+		for _, ep := range f.endpoints {
+			endpoint := ep
+			metrics.ExchangeCircuit(endpoint.url, func() bool { return f.isOpen(endpoint) })
+		}
+	}
+}
+
+func getExchangeFailover() *ExchangeFailover {
+	exchangeFailoverLock.Lock()
+	defer exchangeFailoverLock.Unlock()
+	return exchangeFailover
+}
+
+func (f *ExchangeFailover) isOpen(ep *exchangeEndpoint) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return time.Now().Before(ep.openUntil)
+}
+
+// Returns the URL to call instead of the given one, and the endpoint whose result has to be recorded, nil when the URL is
+// not under the first exchange URL. The URL is health checked with the client when its circuit breaker has been open
+// for OpenDuration.
+func (f *ExchangeFailover) route(httpClient *http.Client, urlPath string) (string, *exchangeEndpoint, error) {
+	if len(f.endpoints) == 0 || !strings.HasPrefix(urlPath, f.endpoints[0].url) {
+		return urlPath, nil, nil
+	}
+	resource := strings.TrimPrefix(urlPath, f.endpoints[0].url)
+
+	for _, ep := range f.endpoints {
+		f.lock.Lock()
+		now := time.Now()
+		if ep.openUntil.IsZero() {
+			f.lock.Unlock()
+			return ep.url + resource, ep, nil
+		} else if now.Before(ep.openUntil) {
+			f.lock.Unlock()
+			continue
+		}
+		// Only this call health checks the URL, the others keep failing over until it is closed.
+		ep.openUntil = now.Add(f.OpenDuration)
+		f.lock.Unlock()
+
+		if f.healthCheck(httpClient, ep) {
+			f.record(ep, false)
+			return ep.url + resource, ep, nil
+		}
+	}
+	return "", nil, errors.New(fmt.Sprintf("the circuit breakers of all the exchange URLs are open, %v is not called", urlPath))
+}
+
+// Check that the exchange at the URL answers, with the version API that does not need credentials.
+func (f *ExchangeFailover) healthCheck(httpClient *http.Client, ep *exchangeEndpoint) bool {
+	httpResp, err := httpClient.Get(ep.url + "admin/version")
+	if httpResp != nil && httpResp.Body != nil {
+		httpResp.Body.Close()
+	}
+	if err != nil || httpResp.StatusCode != http.StatusOK {
+		status := 0
+		if httpResp != nil {
+			status = httpResp.StatusCode
+		}
+		glog.Warningf(rpclogString(fmt.Sprintf("health check of exchange URL %v failed, status: %v, error: %v", ep.url, status, err)))
+		return false
+	}
+	glog.Infof(rpclogString(fmt.Sprintf("health check of exchange URL %v succeeded, closing its circuit breaker", ep.url)))
+	return true
+}
+
+// Record the result of a call to the endpoint, opening its circuit breaker after MaxFailures transport errors in a row.
+func (f *ExchangeFailover) record(ep *exchangeEndpoint, transportError bool) {
+	if ep == nil {
+		return
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !transportError {
+		ep.failures = 0
+		ep.openUntil = time.Time{}
+		return
+	}
+	ep.failures++
+	if ep.failures >= f.MaxFailures && ep.openUntil.IsZero() {
+		ep.openUntil = time.Now().Add(f.OpenDuration)
+		glog.Warningf(rpclogString(fmt.Sprintf("opening the circuit breaker of exchange URL %v after %v transport errors", ep.url, ep.failures)))
+	}
+}
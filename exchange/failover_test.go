@@ -0,0 +1,81 @@
+//go:build unit
+// +build unit
+
+package exchange
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ExchangeFailover(t *testing.T) {
+	primaryUp := false
+	calls := []string{}
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "primary"+r.URL.Path)
+		if !primaryUp {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"code":"ok"}`))
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "secondary"+r.URL.Path)
+		w.Write([]byte(`{"code":"ok"}`))
+	}))
+	defer secondary.Close()
+
+	f := NewExchangeFailover([]string{primary.URL + "/v1", secondary.URL + "/v1/"}, 2, time.Hour)
+	SetExchangeFailover(f)
+	defer SetExchangeFailover(nil)
+
+	invoke := func() (error, error) {
+		var resp interface{}
+		resp = new(PutPostDeleteStandardResponse)
+		return InvokeExchange(http.DefaultClient, "GET", primary.URL+"/v1/orgs/myorg", "", "", nil, &resp)
+	}
+
+	// the circuit breaker of the primary opens after 2 transport errors
+	for i := 0; i < 2; i++ {
+		if _, tpErr := invoke(); tpErr == nil {
+			t.Errorf("call %v to the unavailable primary should fail", i)
+		}
+	}
+	if err, tpErr := invoke(); err != nil || tpErr != nil {
+		t.Errorf("the call should fail over to the secondary, got %v %v", err, tpErr)
+	} else if !f.isOpen(f.endpoints[0]) || f.isOpen(f.endpoints[1]) {
+		t.Errorf("wrong circuit breakers %v", f.endpoints)
+	}
+
+	// the primary is used again once it is health checked
+	primaryUp = true
+	f.endpoints[0].openUntil = time.Now().Add(-time.Second)
+	if err, tpErr := invoke(); err != nil || tpErr != nil {
+		t.Errorf("the call should go to the primary, got %v %v", err, tpErr)
+	} else if f.isOpen(f.endpoints[0]) {
+		t.Errorf("the circuit breaker of the primary should be closed, got %v", f.endpoints[0])
+	}
+	if strings.Join(calls, ",") != "primary/v1/orgs/myorg,primary/v1/orgs/myorg,secondary/v1/orgs/myorg,primary/v1/admin/version,primary/v1/orgs/myorg" {
+		t.Errorf("wrong calls %v", calls)
+	}
+
+	// the calls are not made when all the circuit breakers are open
+	calls = []string{}
+	for _, ep := range f.endpoints {
+		ep.openUntil = time.Now().Add(time.Hour)
+	}
+	if _, tpErr := invoke(); tpErr == nil || len(calls) != 0 {
+		t.Errorf("the call should be shed, got %v with calls %v", tpErr, calls)
+	}
+
+	// the calls to other urls are not failed over
+	var resp interface{}
+	resp = new(PutPostDeleteStandardResponse)
+	if err, tpErr := InvokeExchange(http.DefaultClient, "GET", secondary.URL+"/api/v1/objects", "", "", nil, &resp); err != nil || tpErr != nil || len(calls) != 1 {
+		t.Errorf("the call to another url should be made, got %v %v with calls %v", err, tpErr, calls)
+	}
+}
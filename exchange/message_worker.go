@@ -2,6 +2,7 @@ package exchange
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"github.com/boltdb/bolt"
@@ -9,8 +10,10 @@ import (
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/grpctransport"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/worker"
+	"google.golang.org/grpc/credentials"
 	"strconv"
 	"time"
 )
@@ -36,6 +39,7 @@ type ExchangeMessageWorker struct {
 	worker.BaseWorker // embedded field
 	db                *bolt.DB
 	config            *config.HorizonConfig
+	directClient      *grpctransport.Client // The stream to the agbot gRPC agreement transport, nil when it is not configured.
 }
 
 func NewExchangeMessageWorker(name string, cfg *config.HorizonConfig, db *bolt.DB) *ExchangeMessageWorker {
@@ -58,6 +62,63 @@ func NewExchangeMessageWorker(name string, cfg *config.HorizonConfig, db *bolt.D
 	return worker
 }
 
+// Open the stream to the agbot gRPC agreement transport when it is configured and the node is registered. The agreement
+// protocol messages are then sent and received on the stream, and through the exchange when the stream is not open.
+func (w *ExchangeMessageWorker) startDirectTransport() {
+	if w.directClient != nil || w.EC == nil || w.config.Edge.AgbotGRPCAddress == "" {
+		return
+	}
+
+	var creds credentials.TransportCredentials
+	if w.config.Edge.AgbotGRPCInsecure {
+		glog.Warningf(logString(fmt.Sprintf("the stream to the agbot at %v is not protected by TLS", w.config.Edge.AgbotGRPCAddress)))
+	} else {
+		// Trust the same CA certs as the HTTP clients.
+		tlsConf := &tls.Config{MinVersion: tls.VersionTLS12}
+		if t := config.GetHTTPTransport(w.config.Collaborators.HTTPClientFactory.NewHTTPClient(nil)); t != nil && t.TLSClientConfig != nil {
+			tlsConf = t.TLSClientConfig.Clone()
+		}
+		creds = credentials.NewTLS(tlsConf)
+	}
+
+	w.directClient = grpctransport.NewClient(w.config.Edge.AgbotGRPCAddress, w.GetExchangeId(), w.GetExchangeToken(), creds, w.receiveDirectMessage)
+	w.directClient.Start()
+	SetDirectTransport(w.directClient)
+	glog.V(3).Infof(logString(fmt.Sprintf("started the stream to the agbot at %v", w.config.Edge.AgbotGRPCAddress)))
+}
+
+func (w *ExchangeMessageWorker) stopDirectTransport() {
+	if w.directClient != nil {
+		SetDirectTransport(nil)
+		w.directClient.Stop()
+		w.directClient = nil
+	}
+}
+
+// Called for each message received on the stream to the agbot. Like a message from the exchange, the message is only
+// accepted when its sender key is the public key of the agbot in the exchange.
+func (w *ExchangeMessageWorker) receiveDirectMessage(agbotId string, message []byte) {
+	_, myPrivKey, _ := GetKeys("")
+	if _, receivedPubKey, err := DeconstructExchangeMessage(message, myPrivKey); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to deconstruct message from %v, error %v", agbotId, err)))
+	} else if serializedPubKey, err := MarshalPublicKey(receivedPubKey); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to marshal the key from the encrypted message %v, error %v", receivedPubKey, err)))
+	} else if ag, err := GetAgbot(w, agbotId); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to read agbot %v from the exchange, ignoring its message, error %v", agbotId, err)))
+	} else if bytes.Compare(ag.PublicKey, serializedPubKey) != 0 {
+		glog.Errorf(logString(fmt.Sprintf("sender public key from exchange %v is not the same as the sender public key in the encrypted message %v", ag.PublicKey, serializedPubKey)))
+	} else {
+		msg := DeviceMessage{
+			MsgId:       DIRECT_MSG_ID,
+			AgbotId:     agbotId,
+			AgbotPubKey: serializedPubKey,
+			Message:     message,
+			TimeSent:    cutil.FormattedTime(),
+		}
+		w.Commands <- NewDirectMessageCommand(msg)
+	}
+}
+
 // Customized HTTPFactory for limiting retries.
 func newLimitedRetryHTTPFactory(base *config.HTTPClientFactory) *config.HTTPClientFactory {
 	limitedRetryHTTPFactory := &config.HTTPClientFactory{
@@ -77,11 +138,13 @@ func (w *ExchangeMessageWorker) NewEvent(incoming events.Message) {
 	case *events.EdgeRegisteredExchangeMessage:
 		msg, _ := incoming.(*events.EdgeRegisteredExchangeMessage)
 		w.EC = worker.NewExchangeContext(fmt.Sprintf("%v/%v", msg.Org(), msg.DeviceId()), msg.Token(), w.Config.Edge.ExchangeURL, w.Config.GetCSSURL(), w.Config.Edge.AgbotURL, newLimitedRetryHTTPFactory(w.Config.Collaborators.HTTPClientFactory))
+		w.Commands <- NewStartDirectTransportCommand()
 
 	case *events.NodeShutdownCompleteMessage:
 		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
 		switch msg.Event().Id {
 		case events.UNCONFIGURE_COMPLETE:
+			w.Commands <- NewStopDirectTransportCommand()
 			w.Commands <- worker.NewTerminateCommand("shutdown")
 		}
 
@@ -109,6 +172,7 @@ func (w *ExchangeMessageWorker) NewEvent(incoming events.Message) {
 }
 
 func (w *ExchangeMessageWorker) Initialize() bool {
+	w.startDirectTransport()
 	return true
 }
 
@@ -120,6 +184,17 @@ func (w *ExchangeMessageWorker) CommandHandler(command worker.Command) bool {
 			w.AddDeferredCommand(command)
 		}
 
+	case *DirectMessageCommand:
+		cmd, _ := command.(*DirectMessageCommand)
+		glog.V(3).Infof(logString(fmt.Sprintf("reading message from %v on the stream", cmd.Msg.AgbotId)))
+		w.dispatchMessage(&cmd.Msg)
+
+	case *StartDirectTransportCommand:
+		w.startDirectTransport()
+
+	case *StopDirectTransportCommand:
+		w.stopDirectTransport()
+
 	default:
 		return false
 	}
@@ -147,38 +222,43 @@ func (w *ExchangeMessageWorker) handleMessages() bool {
 	for _, msg := range msgs {
 
 		glog.V(3).Infof(logString(fmt.Sprintf("reading message %v from the exchange", msg.MsgId)))
+		w.dispatchMessage(&msg)
 
-		// First get my own keys
-		_, myPrivKey, _ := GetKeys("")
-
-		// Deconstruct and decrypt the message. If there is a problem with the message, it will be deleted.
-		deleteMessage := true
-		if protocolMessage, receivedPubKey, err := DeconstructExchangeMessage(msg.Message, myPrivKey); err != nil {
-			glog.Errorf(logString(fmt.Sprintf("unable to deconstruct exchange message %v, error %v", msg, err)))
-		} else if serializedPubKey, err := MarshalPublicKey(receivedPubKey); err != nil {
-			glog.Errorf(logString(fmt.Sprintf("unable to marshal the key from the encrypted message %v, error %v", receivedPubKey, err)))
-		} else if bytes.Compare(msg.AgbotPubKey, serializedPubKey) != 0 {
-			glog.Errorf(logString(fmt.Sprintf("sender public key from exchange %v is not the same as the sender public key in the encrypted message %v", msg.AgbotPubKey, serializedPubKey)))
-		} else if mBytes, err := json.Marshal(msg); err != nil {
-			glog.Errorf(logString(fmt.Sprintf("error marshalling message %v, error: %v", msg, err)))
-		} else {
-			// The message seems to be good, so don't delete it yet, the worker that handles the message will delete it.
-			deleteMessage = false
-
-			// Send the message to all workers.
-			em := events.NewExchangeDeviceMessage(events.RECEIVED_EXCHANGE_DEV_MSG, msg.AgbotId, mBytes, string(protocolMessage))
-			w.Messages() <- em
-		}
+	}
+	return true
 
-		// If anything went wrong trying to decrypt the message or verify its origin, etc, just delete it. These errors aren't
-		// expected to be retryable.
-		if deleteMessage {
-			w.deleteMessage(&msg)
-		}
+}
 
+// Decrypt a message and send it to all workers.
+func (w *ExchangeMessageWorker) dispatchMessage(msg *DeviceMessage) {
+
+	// First get my own keys
+	_, myPrivKey, _ := GetKeys("")
+
+	// Deconstruct and decrypt the message. If there is a problem with the message, it will be deleted.
+	deleteMessage := true
+	if protocolMessage, receivedPubKey, err := DeconstructExchangeMessage(msg.Message, myPrivKey); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to deconstruct exchange message %v, error %v", msg, err)))
+	} else if serializedPubKey, err := MarshalPublicKey(receivedPubKey); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to marshal the key from the encrypted message %v, error %v", receivedPubKey, err)))
+	} else if bytes.Compare(msg.AgbotPubKey, serializedPubKey) != 0 {
+		glog.Errorf(logString(fmt.Sprintf("sender public key from exchange %v is not the same as the sender public key in the encrypted message %v", msg.AgbotPubKey, serializedPubKey)))
+	} else if mBytes, err := json.Marshal(msg); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("error marshalling message %v, error: %v", msg, err)))
+	} else {
+		// The message seems to be good, so don't delete it yet, the worker that handles the message will delete it.
+		deleteMessage = false
+
+		// Send the message to all workers.
+		em := events.NewExchangeDeviceMessage(events.RECEIVED_EXCHANGE_DEV_MSG, msg.AgbotId, mBytes, string(protocolMessage))
+		w.Messages() <- em
 	}
-	return true
 
+	// If anything went wrong trying to decrypt the message or verify its origin, etc, just delete it. These errors aren't
+	// expected to be retryable.
+	if deleteMessage {
+		w.deleteMessage(msg)
+	}
 }
 
 func (w *ExchangeMessageWorker) getMessages() ([]DeviceMessage, error) {
@@ -214,6 +294,10 @@ func (w *ExchangeMessageWorker) getMessages() ([]DeviceMessage, error) {
 }
 
 func (w *ExchangeMessageWorker) deleteMessage(msg *DeviceMessage) error {
+	if msg.MsgId == DIRECT_MSG_ID {
+		return nil
+	}
+
 	var resp interface{}
 	resp = new(PostDeviceResponse)
 
@@ -256,6 +340,45 @@ func NewMessageCommand() *MessageCommand {
 	return &MessageCommand{}
 }
 
+// A message received on the stream to the agbot.
+type DirectMessageCommand struct {
+	Msg DeviceMessage
+}
+
+func (c DirectMessageCommand) ShortString() string {
+	return fmt.Sprintf("DirectMessageCommand AgbotId: %v", c.Msg.AgbotId)
+}
+
+func NewDirectMessageCommand(msg DeviceMessage) *DirectMessageCommand {
+	return &DirectMessageCommand{
+		Msg: msg,
+	}
+}
+
+// Indicates that the node is registered, so the stream to the agbot can be opened.
+type StartDirectTransportCommand struct {
+}
+
+func (c StartDirectTransportCommand) ShortString() string {
+	return fmt.Sprintf("StartDirectTransportCommand")
+}
+
+func NewStartDirectTransportCommand() *StartDirectTransportCommand {
+	return &StartDirectTransportCommand{}
+}
+
+// Indicates that the node is unregistered, so the stream to the agbot has to be closed.
+type StopDirectTransportCommand struct {
+}
+
+func (c StopDirectTransportCommand) ShortString() string {
+	return fmt.Sprintf("StopDirectTransportCommand")
+}
+
+func NewStopDirectTransportCommand() *StopDirectTransportCommand {
+	return &StopDirectTransportCommand{}
+}
+
 var logString = func(v interface{}) string {
 	return fmt.Sprintf("ExchangeMessageWorker %v", v)
 }
@@ -270,6 +270,67 @@ func PutExchangeDevice(httpClientFactory *config.HTTPClientFactory, deviceId str
 	}
 }
 
+// The error returned by CreateExchangeDevice when the node already exists in the exchange.
+type NodeExistsError struct {
+	NodeId string
+}
+
+func (e *NodeExistsError) Error() string {
+	return fmt.Sprintf("node %v already exists in the exchange", e.NodeId)
+}
+
+// Returns true if the error tells that the node already exists.
+func IsNodeExistsError(err error) bool {
+	_, ok := err.(*NodeExistsError)
+	return ok
+}
+
+// Create a node in the exchange with the credentials of the exchange context, which must be allowed to create nodes in
+// the org of the node. A NodeExistsError is returned when the node already exists, so that an existing node cannot be
+// taken over by creating it again with another token. The PUT is sent with If-None-Match: * so that two nodes created
+// at the same time with the same id cannot both succeed. The node is also looked up first, for the exchanges that do not
+// support the precondition.
+func CreateExchangeDevice(ec ExchangeContext, deviceId string, pdr *PutDeviceRequest) error {
+	targetURL := ec.GetExchangeURL() + "orgs/" + GetOrg(deviceId) + "/nodes/" + GetId(deviceId)
+	httpClientFactory := ec.GetHTTPFactory()
+
+	var resp interface{}
+	resp = new(GetDevicesResponse)
+	if err := InvokeExchangeRetryOnTransportError(httpClientFactory, "GET", targetURL, ec.GetExchangeId(), ec.GetExchangeToken(), nil, &resp); err != nil {
+		return err
+	} else if len(resp.(*GetDevicesResponse).Devices) != 0 {
+		return &NodeExistsError{NodeId: deviceId}
+	}
+
+	cond := &conditionalRequest{CreateOnly: true}
+	resp = new(PutDeviceResponse)
+	retryCount := httpClientFactory.RetryCount
+	retryInterval := httpClientFactory.GetRetryInterval()
+	for {
+		if err, tpErr := invokeExchange(httpClientFactory.NewHTTPClient(nil), "PUT", targetURL, ec.GetExchangeId(), ec.GetExchangeToken(), pdr, cond, &resp); err != nil {
+			glog.Errorf(rpclogString(fmt.Sprintf(err.Error())))
+			return err
+		} else if tpErr != nil {
+			glog.Warningf(rpclogString(fmt.Sprintf(tpErr.Error())))
+			if httpClientFactory.RetryCount == 0 {
+				time.Sleep(time.Duration(retryInterval) * time.Second)
+				continue
+			} else if retryCount == 0 {
+				return NewRetriesExceededError(httpClientFactory.RetryCount, tpErr)
+			} else {
+				retryCount--
+				time.Sleep(time.Duration(retryInterval) * time.Second)
+				continue
+			}
+		} else if cond.Exists {
+			return &NodeExistsError{NodeId: deviceId}
+		} else {
+			glog.V(3).Infof(rpclogString(fmt.Sprintf("created device %v in exchange %v", deviceId, pdr.ShortString())))
+			return nil
+		}
+	}
+}
+
 // Please patch one field at a time.
 type PatchDeviceRequest struct {
 	UserInput          *[]policy.UserInput `json:"userInput,omitempty"`
@@ -393,18 +454,36 @@ func keyBytes() []byte {
 // ----------- for node status ---------------------- //
 
 type ContainerStatus struct {
-	Name    string `json:"name"`
-	Image   string `json:"image"`
-	Created int64  `json:"created"`
-	State   string `json:"state"`
+	Name        string `json:"name"`
+	Image       string `json:"image"`
+	ImageDigest string `json:"imageDigest,omitempty"` // the digest the image is referenced by, e.g. when it was pinned to its digest
+	Created     int64  `json:"created"`
+	State       string `json:"state"`
 }
 
 func (w ContainerStatus) String() string {
 	return fmt.Sprintf("Name: %v, "+
 		"Image: %v, "+
+		"ImageDigest: %v, "+
 		"Created: %v, "+
 		"State: %v",
-		w.Name, w.Image, w.Created, w.State)
+		w.Name, w.Image, w.ImageDigest, w.Created, w.State)
+}
+
+// The resource usage of a service, summed over the containers of all its instances on the node.
+type ServiceStats struct {
+	CPUPercent      float64 `json:"cpuPercent"`
+	MemoryUsage     uint64  `json:"memoryUsage"`
+	NetworkRxBytes  uint64  `json:"networkRxBytes"`
+	NetworkTxBytes  uint64  `json:"networkTxBytes"`
+	BlockReadBytes  uint64  `json:"blockReadBytes"`
+	BlockWriteBytes uint64  `json:"blockWriteBytes"`
+	Collected       int64   `json:"collected"`
+}
+
+func (s ServiceStats) String() string {
+	return fmt.Sprintf("CPUPercent: %v, MemoryUsage: %v, NetworkRxBytes: %v, NetworkTxBytes: %v, BlockReadBytes: %v, BlockWriteBytes: %v, Collected: %v",
+		s.CPUPercent, s.MemoryUsage, s.NetworkRxBytes, s.NetworkTxBytes, s.BlockReadBytes, s.BlockWriteBytes, s.Collected)
 }
 
 type WorkloadStatus struct {
@@ -416,6 +495,8 @@ type WorkloadStatus struct {
 	Containers     []ContainerStatus `json:"containerStatus"`
 	OperatorStatus interface{}       `json:"operatorStatus,omitempty"`
 	ConfigState    string            `json:"configState,omitempty"`
+	Paused         bool              `json:"paused,omitempty"` // the service is paused by the node owner, its containers are stopped
+	Stats          *ServiceStats     `json:"stats,omitempty"`  // only when the agent publishes the service stats
 }
 
 func (w WorkloadStatus) String() string {
@@ -426,8 +507,9 @@ func (w WorkloadStatus) String() string {
 		"Arch: %v, "+
 		"Containers: %v"+
 		"OperatorStatus: %v"+
-		"ConfigState: %v",
-		w.AgreementId, w.ServiceURL, w.Org, w.Version, w.Arch, w.Containers, w.OperatorStatus, w.ConfigState)
+		"ConfigState: %v"+
+		"Stats: %v",
+		w.AgreementId, w.ServiceURL, w.Org, w.Version, w.Arch, w.Containers, w.OperatorStatus, w.ConfigState, w.Stats)
 }
 
 type DeviceStatus struct {
@@ -435,6 +517,7 @@ type DeviceStatus struct {
 	Services        []WorkloadStatus `json:"services"`
 	RunningServices *string          `json:"runningServices,omitempty"`
 	LastUpdated     *string          `json:"lastUpdated,omitempty"`
+	FreeMemory      *int64           `json:"freeMemory,omitempty"` // the free memory of the node in MB, only when the agent publishes it
 }
 
 func (w DeviceStatus) String() string {
@@ -442,8 +525,9 @@ func (w DeviceStatus) String() string {
 		"Connectivity: %v, "+
 			"Services: %v,"+
 			"RunningServices: %v,"+
-			"LastUpdated: %v",
-		w.Connectivity, w.Services, w.RunningServices, w.LastUpdated)
+			"LastUpdated: %v,"+
+			"FreeMemory: %v",
+		w.Connectivity, w.Services, w.RunningServices, w.LastUpdated, freeMemoryString(w.FreeMemory))
 }
 
 func NewDeviceStatus() *DeviceStatus {
@@ -452,12 +536,20 @@ func NewDeviceStatus() *DeviceStatus {
 
 type NodeStatus struct {
 	RunningServices string `json:"runningServices,omitempty"`
+	FreeMemory      *int64 `json:"freeMemory,omitempty"` // the free memory of the node in MB, only when the agent publishes it
 }
 
 func (w NodeStatus) String() string {
 	return fmt.Sprintf(
-		"Running Services: %v",
-		w.RunningServices)
+		"Running Services: %v, Free Memory: %v",
+		w.RunningServices, freeMemoryString(w.FreeMemory))
+}
+
+func freeMemoryString(free *int64) string {
+	if free == nil {
+		return "not reported"
+	}
+	return fmt.Sprintf("%v MB", *free)
 }
 
 func GetNodeStatus(ec ExchangeContext, deviceId string) (*NodeStatus, error) {
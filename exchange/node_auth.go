@@ -0,0 +1,87 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// The auth provider of the node, nil when the agent authenticates to the exchange with the node token, and the exchange
+// URL it is used for.
+var nodeAuthProvider AuthProvider
+var nodeAuthURL string
+
+// Create the auth provider of the node when the agent is configured to authenticate to the exchange with OIDC access
+// tokens. The refresh token comes from the agent db, or the first time from the ExchangeOIDCRefreshTokenFile, and the
+// tokens that the identity provider rotates it to are saved back in the db.
+func InitNodeAuthProvider(cfg *config.HorizonConfig, db *bolt.DB) error {
+	if cfg.Edge.GetExchangeAuth() != config.ExchangeAuth_OIDC {
+		return nil
+	} else if cfg.Edge.ExchangeURL == "" {
+		// The access tokens are only for the exchange, without its URL they would be sent with every call of the node.
+		glog.Warningf(rpclogString("the exchange URL is not configured, the node authenticates to the exchange with the node token"))
+		return nil
+	}
+
+	key, err := persistence.GetExchangeAuthKey(cfg.Edge.GetExchangeAuthKeyFile())
+	if err != nil {
+		return err
+	}
+
+	refreshToken, err := persistence.FindExchangeRefreshToken(db, key)
+	if err != nil {
+		return err
+	} else if refreshToken == "" && cfg.Edge.ExchangeOIDCRefreshTokenFile != "" {
+		if refreshToken, err = readSecretFile(cfg.Edge.ExchangeOIDCRefreshTokenFile); err != nil {
+			return err
+		} else if err := persistence.SaveExchangeRefreshToken(db, key, refreshToken); err != nil {
+			return errors.New(fmt.Sprintf("unable to save the exchange refresh token, error: %v", err))
+		}
+	}
+
+	clientSecret := ""
+	if cfg.Edge.ExchangeOIDCClientSecretFile != "" {
+		if clientSecret, err = readSecretFile(cfg.Edge.ExchangeOIDCClientSecretFile); err != nil {
+			return err
+		}
+	}
+
+	storeRefreshToken := func(refreshToken string) error {
+		return persistence.SaveExchangeRefreshToken(db, key, refreshToken)
+	}
+	nodeAuthProvider = NewOIDCAuthProvider(cfg.Edge.ExchangeOIDCTokenURL, cfg.Edge.ExchangeOIDCClientID, clientSecret, cfg.Edge.ExchangeOIDCScope,
+		refreshToken, time.Duration(cfg.Edge.ExchangeOIDCClockSkewS)*time.Second, storeRefreshToken)
+	nodeAuthURL = cfg.Edge.ExchangeURL
+	glog.V(3).Infof(rpclogString(fmt.Sprintf("the node authenticates to the exchange at %v with %v", nodeAuthURL, nodeAuthProvider)))
+
+	if dev, err := persistence.FindExchangeDevice(db); err != nil {
+		return errors.New(fmt.Sprintf("unable to read the node from the db, error: %v", err))
+	} else if dev != nil {
+		UseNodeAuthProvider(dev.GetId())
+	}
+	return nil
+}
+
+// Authenticate the exchange calls of the node with the given org qualified id with the auth provider of the node, if
+// the agent has one. It is called when the node is registered. The calls to the CSS keep the node token.
+func UseNodeAuthProvider(nodeId string) {
+	if nodeAuthProvider != nil && nodeAuthURL != "" {
+		RegisterAuthProvider(nodeId, nodeAuthURL, nodeAuthProvider)
+	}
+}
+
+func readSecretFile(fileName string) (string, error) {
+	if b, err := ioutil.ReadFile(fileName); err != nil {
+		return "", errors.New(fmt.Sprintf("unable to read %v, error: %v", fileName, err))
+	} else if secret := strings.TrimSpace(string(b)); secret == "" {
+		return "", errors.New(fmt.Sprintf("%v is empty", fileName))
+	} else {
+		return secret, nil
+	}
+}
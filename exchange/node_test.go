@@ -0,0 +1,60 @@
+//go:build unit
+// +build unit
+
+package exchange
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_CreateExchangeDevice(t *testing.T) {
+
+	// the exchange has the nodes in existing, the PUT of the nodes in racing finds that another node was created first
+	existing := map[string]bool{}
+	racing := map[string]bool{}
+	puts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			if existing[r.URL.Path] {
+				writeTestJSON(w, GetDevicesResponse{Devices: map[string]Device{"testorg/node1": {Name: "node1"}}})
+			} else {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case "PUT":
+			puts++
+			if r.Header.Get("If-None-Match") != "*" {
+				t.Errorf("the node should be created conditionally, got If-None-Match %v", r.Header.Get("If-None-Match"))
+			}
+			if racing[r.URL.Path] {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"code":"ok","msg":"node added"}`)
+		}
+	}))
+	defer server.Close()
+	ec := newTestListContext(server)
+
+	if err := CreateExchangeDevice(ec, "testorg/node1", &PutDeviceRequest{Token: "abc"}); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if puts != 1 {
+		t.Errorf("the node should be created, got %v PUTs", puts)
+	}
+
+	existing["/orgs/testorg/nodes/node2"] = true
+	if err := CreateExchangeDevice(ec, "testorg/node2", &PutDeviceRequest{Token: "abc"}); !IsNodeExistsError(err) {
+		t.Errorf("expected a node exists error, but got %v", err)
+	} else if puts != 1 {
+		t.Errorf("an existing node should not be replaced, got %v PUTs", puts)
+	}
+
+	racing["/orgs/testorg/nodes/node3"] = true
+	if err := CreateExchangeDevice(ec, "testorg/node3", &PutDeviceRequest{Token: "abc"}); !IsNodeExistsError(err) {
+		t.Errorf("expected a node exists error, but got %v", err)
+	}
+}
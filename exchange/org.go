@@ -22,11 +22,59 @@ type GetUsersResponse struct {
 
 // Functions and types for working with organizations in the exchange
 type OrgLimits struct {
-	MaxNodes int `json:"maxNodes"`
+	MaxNodes    int `json:"maxNodes"`
+	MaxServices int `json:"maxServices,omitempty"`
 }
 
 func (o OrgLimits) String() string {
-	return fmt.Sprintf("MaxNodes: %v", o.MaxNodes)
+	return fmt.Sprintf("MaxNodes: %v, MaxServices: %v", o.MaxNodes, o.MaxServices)
+}
+
+// The resources of an org that the exchange limits.
+const (
+	ORG_QUOTA_NODES    = "nodes"
+	ORG_QUOTA_SERVICES = "services"
+)
+
+// Returns the max number of the resources that the org can have, 0 when the org has no limit.
+func (o *OrgLimits) GetMax(resource string) int {
+	if o == nil {
+		return 0
+	} else if resource == ORG_QUOTA_NODES {
+		return o.MaxNodes
+	} else if resource == ORG_QUOTA_SERVICES {
+		return o.MaxServices
+	}
+	return 0
+}
+
+// The usage of an organization, returned by the exchange org status API.
+type OrgStatus struct {
+	NumberOfUsers           int  `json:"numberOfUsers"`
+	NumberOfNodes           int  `json:"numberOfNodes"`
+	NumberOfRegisteredNodes int  `json:"numberOfRegisteredNodes"`
+	NumberOfNodeAgreements  int  `json:"numberOfNodeAgreements"`
+	NumberOfServices        *int `json:"numberOfServices,omitempty"` // The older exchanges do not return it.
+}
+
+func (o OrgStatus) String() string {
+	return fmt.Sprintf("NumberOfUsers: %v, NumberOfNodes: %v, NumberOfRegisteredNodes: %v, NumberOfNodeAgreements: %v, NumberOfServices: %v",
+		o.NumberOfUsers, o.NumberOfNodes, o.NumberOfRegisteredNodes, o.NumberOfNodeAgreements, o.NumberOfServices)
+}
+
+// The number of resources an org has, and the max number it can have, 0 when it has no limit.
+type OrgQuotaUsage struct {
+	Used int `json:"used"`
+	Max  int `json:"max"`
+}
+
+func (u OrgQuotaUsage) String() string {
+	return fmt.Sprintf("Used: %v, Max: %v", u.Used, u.Max)
+}
+
+// Returns true when the org cannot have more of the resource.
+func (u OrgQuotaUsage) Reached() bool {
+	return u.Max > 0 && u.Used >= u.Max
 }
 
 type HeartbeatIntervals struct {
@@ -0,0 +1,203 @@
+package exchange
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The default number of nodes or services read from the exchange in one call by the list iterators.
+const DEFAULT_LIST_PAGE_SIZE = 1000
+
+// The query parameters that page the exchange list APIs. The exchange returns at most limit items starting at
+// startIndex, and the lastIndex of the response is where the next page starts.
+const (
+	LIST_LIMIT       = "limit"
+	LIST_START_INDEX = "startIndex"
+)
+
+// The paging state of an exchange list API, shared by the typed iterators. An exchange that does not page the list
+// returns all the items in the first page, the lastIndex of its response is 0.
+type listPager struct {
+	ec         ExchangeContext
+	targetURL  string // The list URL, without the paging query parameters.
+	pageSize   int
+	startIndex int
+	done       bool
+}
+
+func newListPager(ec ExchangeContext, targetURL string, pageSize int) *listPager {
+	if pageSize <= 0 {
+		pageSize = DEFAULT_LIST_PAGE_SIZE
+	}
+	return &listPager{ec: ec, targetURL: targetURL, pageSize: pageSize}
+}
+
+func (p *listPager) String() string {
+	return fmt.Sprintf("URL: %v, PageSize: %v, StartIndex: %v, Done: %v", p.targetURL, p.pageSize, p.startIndex, p.done)
+}
+
+// Read the next page of the list into resp. A list that does not exist in the exchange is an empty one.
+func (p *listPager) fetch(resp interface{}) error {
+	sep := "?"
+	if strings.Contains(p.targetURL, "?") {
+		sep = "&"
+	}
+	targetURL := fmt.Sprintf("%v%v%v=%v&%v=%v", p.targetURL, sep, LIST_LIMIT, p.pageSize, LIST_START_INDEX, p.startIndex)
+
+	retryCount := p.ec.GetHTTPFactory().RetryCount
+	retryInterval := p.ec.GetHTTPFactory().GetRetryInterval()
+	for {
+		if err, tpErr := InvokeExchange(p.ec.GetHTTPFactory().NewHTTPClient(nil), "GET", targetURL, p.ec.GetExchangeId(), p.ec.GetExchangeToken(), nil, &resp); err != nil {
+			if strings.Contains(err.Error(), "status: 404") {
+				p.done = true
+				return nil
+			}
+			glog.Errorf(rpclogString(err.Error()))
+			return err
+		} else if tpErr != nil {
+			glog.Warningf(rpclogString(tpErr.Error()))
+			if p.ec.GetHTTPFactory().RetryCount == 0 {
+				time.Sleep(time.Duration(retryInterval) * time.Second)
+				continue
+			} else if retryCount == 0 {
+				return NewRetriesExceededError(p.ec.GetHTTPFactory().RetryCount, tpErr)
+			} else {
+				retryCount--
+				time.Sleep(time.Duration(retryInterval) * time.Second)
+				continue
+			}
+		} else {
+			return nil
+		}
+	}
+}
+
+// Move to the page after the one that had count items and the given lastIndex. The list is done when the page is not
+// full, or when the exchange did not page it.
+func (p *listPager) advance(count int, lastIndex int) {
+	if count != p.pageSize || lastIndex <= p.startIndex {
+		p.done = true
+	} else {
+		p.startIndex = lastIndex
+	}
+}
+
+// Iterate over the nodes of an org one page at a time, so that the nodes of a large org are not read from the exchange
+// in a single call:
+//
+//	for it := NewNodeIterator(ec, org, 0); it.HasNext(); {
+//		nodes, err := it.Next()
+//		...
+//	}
+type NodeIterator struct {
+	pager *listPager
+}
+
+// Create an iterator over the nodes of the org, pageSize nodes at a time. A pageSize of 0 is the DEFAULT_LIST_PAGE_SIZE.
+func NewNodeIterator(ec ExchangeContext, org string, pageSize int) *NodeIterator {
+	return &NodeIterator{pager: newListPager(ec, fmt.Sprintf("%vorgs/%v/nodes", ec.GetExchangeURL(), org), pageSize)}
+}
+
+func (it *NodeIterator) HasNext() bool {
+	return !it.pager.done
+}
+
+// Returns the next page of nodes, keyed by org qualified node id. It is empty when there are no more nodes.
+func (it *NodeIterator) Next() (map[string]Device, error) {
+	if it.pager.done {
+		return map[string]Device{}, nil
+	}
+
+	var resp interface{}
+	resp = new(GetDevicesResponse)
+	if err := it.pager.fetch(resp); err != nil {
+		return nil, err
+	} else if it.pager.done {
+		return map[string]Device{}, nil
+	}
+
+	page := resp.(*GetDevicesResponse)
+	it.pager.advance(len(page.Devices), page.LastIndex)
+	glog.V(5).Infof(rpclogString(fmt.Sprintf("read %v nodes, %v", len(page.Devices), it.pager)))
+	return page.Devices, nil
+}
+
+// Iterate over the services of an org one page at a time, like the NodeIterator.
+type ServiceIterator struct {
+	pager *listPager
+}
+
+// Create an iterator over the services of the org, pageSize services at a time. A pageSize of 0 is the
+// DEFAULT_LIST_PAGE_SIZE. The services can be filtered by url and arch, empty returns them all.
+func NewServiceIterator(ec ExchangeContext, org string, svcURL string, arch string, pageSize int) *ServiceIterator {
+	query := url.Values{}
+	if svcURL != "" {
+		query.Set("url", svcURL)
+	}
+	if arch != "" {
+		query.Set("arch", arch)
+	}
+	targetURL := fmt.Sprintf("%vorgs/%v/services", ec.GetExchangeURL(), org)
+	if len(query) != 0 {
+		targetURL += "?" + query.Encode()
+	}
+	return &ServiceIterator{pager: newListPager(ec, targetURL, pageSize)}
+}
+
+func (it *ServiceIterator) HasNext() bool {
+	return !it.pager.done
+}
+
+// Returns the next page of services, keyed by org qualified service id. It is empty when there are no more services.
+func (it *ServiceIterator) Next() (map[string]ServiceDefinition, error) {
+	if it.pager.done {
+		return map[string]ServiceDefinition{}, nil
+	}
+
+	var resp interface{}
+	resp = new(GetServicesResponse)
+	if err := it.pager.fetch(resp); err != nil {
+		return nil, err
+	} else if it.pager.done {
+		return map[string]ServiceDefinition{}, nil
+	}
+
+	page := resp.(*GetServicesResponse)
+	page.SupportVersionRange()
+	it.pager.advance(len(page.Services), page.LastIndex)
+	glog.V(5).Infof(rpclogString(fmt.Sprintf("read %v services, %v", len(page.Services), it.pager)))
+	return page.Services, nil
+}
+
+// Get all the nodes of an org, read a page at a time. Prefer the NodeIterator for the orgs that can have many nodes.
+func GetNodes(ec ExchangeContext, org string) (map[string]Device, error) {
+	nodes := make(map[string]Device)
+	for it := NewNodeIterator(ec, org, 0); it.HasNext(); {
+		page, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		for id, node := range page {
+			nodes[id] = node
+		}
+	}
+	return nodes, nil
+}
+
+// Get all the services of an org, read a page at a time.
+func GetServices(ec ExchangeContext, org string) (map[string]ServiceDefinition, error) {
+	services := make(map[string]ServiceDefinition)
+	for it := NewServiceIterator(ec, org, "", "", 0); it.HasNext(); {
+		page, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		for id, svc := range page {
+			services[id] = svc
+		}
+	}
+	return services, nil
+}
@@ -0,0 +1,113 @@
+//go:build unit
+// +build unit
+
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/open-horizon/anax/config"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// The nodes are read a page at a time, until a page is not full.
+func Test_NodeIterator(t *testing.T) {
+
+	numNodes := 5
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		limit, _ := strconv.Atoi(r.URL.Query().Get(LIST_LIMIT))
+		start, _ := strconv.Atoi(r.URL.Query().Get(LIST_START_INDEX))
+
+		nodes := make(map[string]Device)
+		i := start
+		for ; i < numNodes && i < start+limit; i++ {
+			nodes[fmt.Sprintf("testorg/node%v", i)] = Device{Name: fmt.Sprintf("node%v", i)}
+		}
+		writeTestJSON(w, GetDevicesResponse{Devices: nodes, LastIndex: i})
+	}))
+	defer server.Close()
+
+	ec := newTestListContext(server)
+	nodes := make(map[string]Device)
+	for it := NewNodeIterator(ec, "testorg", 2); it.HasNext(); {
+		page, err := it.Next()
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		} else if len(page) > 2 {
+			t.Errorf("the page has %v nodes, expected at most 2", len(page))
+		}
+		for id, node := range page {
+			nodes[id] = node
+		}
+	}
+
+	if len(nodes) != numNodes {
+		t.Errorf("read %v nodes, expected %v: %v", len(nodes), numNodes, nodes)
+	} else if calls != 3 {
+		t.Errorf("made %v calls, expected 3", calls)
+	}
+}
+
+// An exchange that does not page the list returns all the services in one call.
+func Test_ServiceIterator_NotPaged(t *testing.T) {
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Query().Get("url") != "my.service" {
+			t.Errorf("the url filter is missing from %v", r.URL)
+		}
+		writeTestJSON(w, GetServicesResponse{Services: map[string]ServiceDefinition{
+			"testorg/svc1": ServiceDefinition{URL: "my.service", Version: "1.0.0"},
+			"testorg/svc2": ServiceDefinition{URL: "my.service", Version: "2.0.0"},
+		}})
+	}))
+	defer server.Close()
+
+	ec := newTestListContext(server)
+	services := make(map[string]ServiceDefinition)
+	for it := NewServiceIterator(ec, "testorg", "my.service", "", 2); it.HasNext(); {
+		page, err := it.Next()
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		for id, svc := range page {
+			services[id] = svc
+		}
+	}
+
+	if len(services) != 2 || calls != 1 {
+		t.Errorf("read %v services in %v calls, expected 2 services in 1 call", len(services), calls)
+	}
+}
+
+// An org without nodes is an empty list.
+func Test_GetNodes_NotFound(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	if nodes, err := GetNodes(newTestListContext(server), "testorg"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if len(nodes) != 0 {
+		t.Errorf("unexpected nodes %v", nodes)
+	}
+}
+
+func newTestListContext(server *httptest.Server) ExchangeContext {
+	httpClientFactory := &config.HTTPClientFactory{NewHTTPClient: func(*uint) *http.Client { return server.Client() }, RetryCount: 1, RetryInterval: 1}
+	return NewCustomExchangeContext("testorg/user", "password", server.URL+"/", "", httpClientFactory)
+}
+
+func writeTestJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	b, _ := json.Marshal(v)
+	w.Write(b)
+}
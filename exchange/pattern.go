@@ -202,7 +202,9 @@ type GetPatternResponse struct {
 	LastIndex int                `json:"lastIndex,omitempty"`
 }
 
-// Get all the pattern metadata for a specific organization, and pattern if specified.
+// Get all the pattern metadata for a specific organization, and pattern if specified. The patterns are cached until the
+// changes api tells that they have changed, then they are revalidated with the etag the exchange returned with them so
+// that they are only sent again by the exchange when they have changed.
 func GetPatterns(httpClientFactory *config.HTTPClientFactory, org string, pattern string, exURL string, id string, token string) (map[string]Pattern, error) {
 
 	if pattern == "" {
@@ -211,6 +213,17 @@ func GetPatterns(httpClientFactory *config.HTTPClientFactory, org string, patter
 		glog.V(3).Infof(rpclogString(fmt.Sprintf("getting pattern definitions for %v/%v", org, pattern)))
 	}
 
+	if cachedPats := GetPatternsFromCache(org, pattern); cachedPats != nil {
+		glog.V(5).Infof(rpclogString(fmt.Sprintf("found %v patterns for %v/%v in the exchange cache.", len(cachedPats), org, pattern)))
+		return cachedPats, nil
+	}
+
+	cond := new(conditionalRequest)
+	cachedPats, etag := GetRevalidationEntryFromCache(PatternCacheMapKey(org, pattern), PATTERN_TYPE_CACHE)
+	if _, ok := cachedPats.(map[string]Pattern); ok {
+		cond.ETag = etag
+	}
+
 	var resp interface{}
 	resp = new(GetPatternResponse)
 
@@ -225,7 +238,7 @@ func GetPatterns(httpClientFactory *config.HTTPClientFactory, org string, patter
 	retryCount := httpClientFactory.RetryCount
 	retryInterval := httpClientFactory.GetRetryInterval()
 	for {
-		if err, tpErr := InvokeExchange(httpClientFactory.NewHTTPClient(nil), "GET", targetURL, id, token, nil, &resp); err != nil {
+		if err, tpErr := invokeExchange(httpClientFactory.NewHTTPClient(nil), "GET", targetURL, id, token, nil, cond, &resp); err != nil {
 			glog.Errorf(rpclogString(fmt.Sprintf(err.Error())))
 			return nil, err
 		} else if tpErr != nil {
@@ -234,7 +247,7 @@ func GetPatterns(httpClientFactory *config.HTTPClientFactory, org string, patter
 				time.Sleep(time.Duration(retryInterval) * time.Second)
 				continue
 			} else if retryCount == 0 {
-				return nil, fmt.Errorf("Exceeded %v retries for error: %v", httpClientFactory.RetryCount, tpErr)
+				return nil, NewRetriesExceededError(httpClientFactory.RetryCount, tpErr)
 			} else {
 				retryCount--
 				time.Sleep(time.Duration(retryInterval) * time.Second)
@@ -242,10 +255,17 @@ func GetPatterns(httpClientFactory *config.HTTPClientFactory, org string, patter
 			}
 		} else {
 			var pats map[string]Pattern
-			if resp != nil {
+			if cond.NotModified {
+				pats = cachedPats.(map[string]Pattern)
+			} else if resp != nil {
 				pats = resp.(*GetPatternResponse).Patterns
 			}
 
+			// a pattern that is not found is not cached
+			if pats != nil {
+				UpdateCacheWithETag(PatternCacheMapKey(org, pattern), PATTERN_TYPE_CACHE, PatternMap(pats).DeepCopy(), cond.RespETag)
+			}
+
 			if pattern != "" {
 				pat0 := ""
 				for _, pat := range pats {
@@ -6,7 +6,9 @@ package exchange
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/open-horizon/anax/config"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -292,6 +294,58 @@ func Test_IsTraportError(t *testing.T) {
 
 }
 
+func Test_GetPatternsETag(t *testing.T) {
+	ClearAllResourceCache()
+	defer ClearAllResourceCache()
+
+	gets := 0
+	ifNoneMatch := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gets++
+		ifNoneMatch = r.Header.Get("If-None-Match")
+		w.Header().Set("ETag", "etag1")
+		if ifNoneMatch == "etag1" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(`{"patterns":{"testorg/pat1":{"label":"pattern 1","services":[]}}}`))
+	}))
+	defer server.Close()
+
+	httpClientFactory := &config.HTTPClientFactory{NewHTTPClient: func(*uint) *http.Client { return server.Client() }}
+	getPatterns := func() map[string]Pattern {
+		pats, err := GetPatterns(httpClientFactory, "testorg", "", server.URL+"/", "testorg/id", "token")
+		if err != nil {
+			t.Fatalf("unable to get patterns, error: %v", err)
+		} else if len(pats) != 1 || pats["testorg/pat1"].Label != "pattern 1" {
+			t.Errorf("wrong patterns %v", pats)
+		}
+		return pats
+	}
+
+	getPatterns()
+	if gets != 1 || ifNoneMatch != "" {
+		t.Errorf("the patterns should be got unconditionally, got %v requests with etag %v", gets, ifNoneMatch)
+	}
+
+	// unchanged patterns are served from the cache
+	getPatterns()
+	if gets != 1 {
+		t.Errorf("the patterns should be served from the cache, got %v requests", gets)
+	}
+
+	// changed patterns are revalidated with their etag
+	DeleteCacheResourceFromChange(ExchangeChange{OrgID: "testorg", ID: "pat1", Resource: "pattern"}, "")
+	getPatterns()
+	if gets != 2 || ifNoneMatch != "etag1" {
+		t.Errorf("the patterns should be revalidated, got %v requests with etag %v", gets, ifNoneMatch)
+	}
+	getPatterns()
+	if gets != 2 {
+		t.Errorf("the revalidated patterns should be served from the cache, got %v requests", gets)
+	}
+}
+
 // Create a Pattern object from a JSON serialization. The JSON serialization
 // does not have to be a valid pattern serialization, just has to be a valid
 // JSON serialization.
@@ -262,7 +262,7 @@ func GetBusinessPolicies(ec ExchangeContext, org string, policy_id string) (map[
 				time.Sleep(time.Duration(retryInterval) * time.Second)
 				continue
 			} else if retryCount == 0 {
-				return nil, fmt.Errorf("Exceeded %v retries for error: %v", ec.GetHTTPFactory().RetryCount, tpErr)
+				return nil, NewRetriesExceededError(ec.GetHTTPFactory().RetryCount, tpErr)
 			} else {
 				retryCount--
 				time.Sleep(time.Duration(retryInterval) * time.Second)
@@ -9,6 +9,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/metrics"
 	"github.com/open-horizon/anax/semanticversion"
 	"github.com/open-horizon/edge-sync-service/common"
 	"io/ioutil"
@@ -49,6 +50,23 @@ type PutPostDeleteStandardResponse struct {
 // This function is used to invoke an exchange API
 // For GET, the given resp parameter will be untouched when http returns code 404.
 func InvokeExchange(httpClient *http.Client, method string, urlPath string, user string, pw string, params interface{}, resp *interface{}) (error, error) {
+	return invokeExchange(httpClient, method, urlPath, user, pw, params, nil, resp)
+}
+
+// A conditional request. For a GET of a resource that is cached with the etag the exchange returned with it, the etag
+// is sent in If-None-Match so that the exchange returns 304 Not Modified, without the resource, when it has not changed.
+// For a PUT that must only create the resource, If-None-Match is * so that the exchange returns 412 Precondition Failed
+// when the resource already exists.
+type conditionalRequest struct {
+	ETag        string // The etag of the cached resource, empty to get the resource unconditionally.
+	RespETag    string // The etag the exchange returned with the resource, empty if it does not support etags.
+	NotModified bool   // The resource has not changed, the resp parameter is untouched.
+	CreateOnly  bool   // The PUT must not replace an existing resource.
+	Exists      bool   // The resource of a CreateOnly PUT already exists, the resp parameter is untouched.
+}
+
+// Invokes an exchange API, conditionally with cond.
+func invokeExchange(httpClient *http.Client, method string, urlPath string, user string, pw string, params interface{}, cond *conditionalRequest, resp *interface{}) (error, error) {
 
 	if len(method) == 0 {
 		return errors.New(fmt.Sprintf("Error invoking exchange, method name must be specified")), nil
@@ -58,6 +76,19 @@ func InvokeExchange(httpClient *http.Client, method string, urlPath string, user
 		return errors.New(fmt.Sprintf("Error invoking exchange, response object must be specified")), nil
 	}
 
+	// the auth provider is chosen for the url of the exchange that the caller knows, before it fails over
+	authProvider := GetAuthProvider(user, urlPath)
+
+	var endpoint *exchangeEndpoint
+	failover := getExchangeFailover()
+	if failover != nil {
+		routedURL, ep, err := failover.route(httpClient, urlPath)
+		if err != nil {
+			return nil, err
+		}
+		urlPath, endpoint = routedURL, ep
+	}
+
 	// encode the url so that it can accept unicode
 	urlObj, err := url.Parse(urlPath)
 	if err != nil {
@@ -117,12 +148,32 @@ func InvokeExchange(httpClient *http.Client, method string, urlPath string, user
 		if method != "GET" {
 			req.Header.Add("Content-Type", "application/json")
 		}
-		if user != "" && pw != "" {
+		if user != "" && authProvider != nil {
+			if authorization, err := authProvider.Authorization(httpClient); err != nil {
+				return nil, errors.New(fmt.Sprintf("Invocation of %v at %v failed getting the credential of %v, error: %v", method, urlPath, user, err))
+			} else {
+				req.Header.Add("Authorization", authorization)
+			}
+		} else if user != "" && pw != "" {
 			req.Header.Add("Authorization", fmt.Sprintf("Basic %v", base64.StdEncoding.EncodeToString([]byte(user+":"+pw))))
 		}
+		if cond != nil && cond.ETag != "" && method == "GET" {
+			req.Header.Add("If-None-Match", cond.ETag)
+		} else if cond != nil && cond.CreateOnly && method == "PUT" {
+			req.Header.Add("If-None-Match", "*")
+		}
 
 		// If the exchange is down, this call will return an error.
+		start := time.Now()
 		httpResp, err := httpClient.Do(req)
+		if httpResp != nil {
+			metrics.ExchangeRequest(urlObj.Scheme+"://"+urlObj.Host, method, httpResp.StatusCode, time.Since(start))
+		} else {
+			metrics.ExchangeRequest(urlObj.Scheme+"://"+urlObj.Host, method, 0, time.Since(start))
+		}
+		if failover != nil {
+			failover.record(endpoint, IsTransportError(httpResp, err))
+		}
 		if httpResp != nil && httpResp.Body != nil {
 			defer httpResp.Body.Close()
 		}
@@ -143,6 +194,28 @@ func InvokeExchange(httpClient *http.Client, method string, urlPath string, user
 				}
 			}
 
+			if cond != nil {
+				cond.RespETag = httpResp.Header.Get("ETag")
+				if httpResp.StatusCode == http.StatusNotModified && cond.ETag != "" {
+					glog.V(5).Infof(rpclogString(fmt.Sprintf("Got %v. %v at %v has not changed since etag %v", httpResp.StatusCode, method, urlPath, cond.ETag)))
+					cond.NotModified = true
+					if cond.RespETag == "" {
+						cond.RespETag = cond.ETag
+					}
+					return nil, nil
+				} else if httpResp.StatusCode == http.StatusPreconditionFailed && cond.CreateOnly {
+					glog.V(5).Infof(rpclogString(fmt.Sprintf("Got %v. %v at %v already exists", httpResp.StatusCode, method, urlPath)))
+					cond.Exists = true
+					return nil, nil
+				}
+			}
+
+			// The credential of the provider may have been revoked or may have expired early, the next try gets a new one
+			if httpResp.StatusCode == http.StatusUnauthorized && user != "" && authProvider != nil {
+				authProvider.Invalidate()
+				return nil, errors.New(fmt.Sprintf("Invocation of %v at %v was rejected with the credential of %v, HTTP Status: %v", method, urlPath, user, httpResp.Status))
+			}
+
 			// Handle special case of server error
 			if httpResp.StatusCode == http.StatusInternalServerError && strings.Contains(string(outBytes), "timed out") {
 				return nil, errors.New(fmt.Sprintf("Invocation of %v at %v with %v failed invoking HTTP request, error: %v", method, urlPath, requestBody, err))
@@ -318,6 +391,30 @@ func InvokeExchange(httpClient *http.Client, method string, urlPath string, user
 	}
 }
 
+// The error returned when the exchange could not be reached within the retries of the HTTP client factory, so that
+// callers can tell an unreachable exchange from an error returned by the exchange.
+type RetriesExceededError struct {
+	Retries int
+	Err     error
+}
+
+func NewRetriesExceededError(retries int, err error) *RetriesExceededError {
+	return &RetriesExceededError{
+		Retries: retries,
+		Err:     err,
+	}
+}
+
+func (e *RetriesExceededError) Error() string {
+	return fmt.Sprintf("Exceeded %v retries for error: %v", e.Retries, e.Err)
+}
+
+// Returns true if the error tells that the exchange could not be reached.
+func IsRetriesExceededError(err error) bool {
+	_, ok := err.(*RetriesExceededError)
+	return ok
+}
+
 func InvokeExchangeRetryOnTransportError(httpClientFactory *config.HTTPClientFactory, method string, urlPath string, user string, pw string, params interface{}, resp *interface{}) error {
 	retryCount := httpClientFactory.RetryCount
 	retryInterval := httpClientFactory.GetRetryInterval()
@@ -462,14 +559,16 @@ func GetObjectSigningKeys(ec ExchangeContext, oType string, oURL string, oOrg st
 			return nil, errors.New(rpclogString(fmt.Sprintf("GetObjectSigningKeys got wrong version string %v. The version string should be a non-empty single version string.", oVersion)))
 		}
 		ms_resp, ms_id, err := GetService(ec, oURL, oOrg, oVersion, oArch)
-		if err != nil {
+		if IsRetriesExceededError(err) {
+			return nil, err
+		} else if err != nil {
 			return nil, errors.New(rpclogString(fmt.Sprintf("failed to get the service %v %v %v %v.%v", oURL, oOrg, oVersion, oArch, err)))
 		} else if ms_resp == nil {
 			return nil, errors.New(rpclogString(fmt.Sprintf("unable to find the service %v %v %v %v.", oURL, oOrg, oVersion, oArch)))
 		}
 
 		oIndex = ms_id
-		cachedKeys := GetServiceKeysFromCache(oIndex)
+		cachedKeys := GetServiceKeysFromCache(oIndex, crossOrgCacheExpiration(ec, oOrg))
 		if cachedKeys != nil {
 			return *cachedKeys, nil
 		}
@@ -498,7 +597,7 @@ func GetObjectSigningKeys(ec ExchangeContext, oType string, oURL string, oOrg st
 				time.Sleep(time.Duration(retryInterval) * time.Second)
 				continue
 			} else if retryCount == 0 {
-				return nil, fmt.Errorf("Exceeded %v retries for error: %v", ec.GetHTTPFactory().RetryCount, tpErr)
+				return nil, NewRetriesExceededError(ec.GetHTTPFactory().RetryCount, tpErr)
 			} else {
 				retryCount--
 				time.Sleep(time.Duration(retryInterval) * time.Second)
@@ -536,7 +635,7 @@ func GetObjectSigningKeys(ec ExchangeContext, oType string, oURL string, oOrg st
 					time.Sleep(time.Duration(retryInterval) * time.Second)
 					continue
 				} else if retryCount == 0 {
-					return nil, fmt.Errorf("Exceeded %v retries for error: %v", ec.GetHTTPFactory().RetryCount, tpErr)
+					return nil, NewRetriesExceededError(ec.GetHTTPFactory().RetryCount, tpErr)
 				} else {
 					retryCount--
 					time.Sleep(time.Duration(retryInterval) * time.Second)
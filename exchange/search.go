@@ -86,11 +86,12 @@ type SearchExchangePatternRequest struct {
 	Arch         string   `json:"arch,omitempty"`
 	NodeOrgIds   []string `json:"nodeOrgids,omitempty"`
 	SecondsStale int      `json:"secondsStale"`
-	NumEntries   int      `json:"numEntries"`
+	StartIndex   int      `json:"startIndex"` // The index of the first node to return, for paging through the nodes with NumEntries.
+	NumEntries   int      `json:"numEntries"` // The max number of nodes to return, 0 returns them all.
 }
 
 func (a SearchExchangePatternRequest) String() string {
-	return fmt.Sprintf("ServiceURL: %v, SecondsStale: %v, NumEntries: %v", a.ServiceURL, a.SecondsStale, a.NumEntries)
+	return fmt.Sprintf("ServiceURL: %v, SecondsStale: %v, StartIndex: %v, NumEntries: %v", a.ServiceURL, a.SecondsStale, a.StartIndex, a.NumEntries)
 }
 
 type SearchExchangePatternResponse struct {
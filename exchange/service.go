@@ -292,8 +292,8 @@ func getSearchVersion(version string) (string, error) {
 
 // The exchange cache holds all versions of the same service together and will return all like the exchange.
 // This function finds the specific version that we want
-func getServiceFromCache(url string, org string, version string, searchVersion string, arch string) (*ServiceDefinition, string, map[string]ServiceDefinition, error) {
-	svcDefMap := GetServiceFromCache(org, cutil.FormExchangeIdWithSpecRef(url), arch)
+func getServiceFromCache(url string, org string, version string, searchVersion string, arch string, expirationS uint64) (*ServiceDefinition, string, map[string]ServiceDefinition, error) {
+	svcDefMap := GetServiceFromCache(org, cutil.FormExchangeIdWithSpecRef(url), arch, expirationS)
 	if svcDefMap == nil {
 		return nil, "", nil, nil
 	}
@@ -338,7 +338,7 @@ func GetService(ec ExchangeContext, mURL string, mOrg string, mVersion string, m
 		return nil, "", err
 	}
 
-	cachedSvc, cachedSvcId, cachedSvcDefs, err := getServiceFromCache(mURL, mOrg, mVersion, searchVersion, mArch)
+	cachedSvc, cachedSvcId, cachedSvcDefs, err := getServiceFromCache(mURL, mOrg, mVersion, searchVersion, mArch, crossOrgCacheExpiration(ec, mOrg))
 	if err != nil {
 		glog.Errorf("Error getting service from cache: %v", err)
 	}
@@ -367,7 +367,7 @@ func GetService(ec ExchangeContext, mURL string, mOrg string, mVersion string, m
 				time.Sleep(time.Duration(retryInterval) * time.Second)
 				continue
 			} else if retryCount == 0 {
-				return nil, "", fmt.Errorf("Exceeded %v retries for error: %v", ec.GetHTTPFactory().RetryCount, tpErr)
+				return nil, "", NewRetriesExceededError(ec.GetHTTPFactory().RetryCount, tpErr)
 			} else {
 				retryCount--
 				time.Sleep(time.Duration(retryInterval) * time.Second)
@@ -510,7 +510,7 @@ func GetSelectedServices(ec ExchangeContext, mURL string, mOrg string, mVersion
 				time.Sleep(time.Duration(retryInterval) * time.Second)
 				continue
 			} else if retryCount == 0 {
-				return nil, fmt.Errorf("Exceeded %v retries for error: %v", ec.GetHTTPFactory().RetryCount, tpErr)
+				return nil, NewRetriesExceededError(ec.GetHTTPFactory().RetryCount, tpErr)
 			} else {
 				retryCount--
 				time.Sleep(time.Duration(retryInterval) * time.Second)
@@ -609,12 +609,14 @@ func ServiceResolver(wURL string, wOrg string, wVersion string, wArch string, se
 			glog.V(5).Infof(rpclogString(fmt.Sprintf("resolving required services for %v %v %v %v", wURL, wOrg, wVersion, wArch)))
 			for _, sDep := range tlService.RequiredServices {
 
-				// Make sure the required service has the same arch as the service.
+				// Make sure the required service has the same arch as the service, and that its org is allowed for the services of this org.
 				// Convert version to a version range expression (if it's not already an expression) so that the underlying GetService
 				// will return us something in the range required by the service.
 				var serviceDef *ServiceDefinition
 				if sDep.Arch != wArch {
 					return nil, nil, nil, errors.New(fmt.Sprintf("service %v has a different architecture than the top level service.", sDep))
+				} else if !IsCrossOrgServiceAllowed(wOrg, sDep.Org) {
+					return nil, nil, nil, NewCrossOrgServiceError(wOrg, sDep.URL, sDep.Org)
 				} else if vExp, err := semanticversion.Version_Expression_Factory(sDep.Version); err != nil {
 					return nil, nil, nil, errors.New(fmt.Sprintf("unable to create version expression from %v, error %v", sDep.Version, err))
 				} else if apiSpecs, sd, sIds, err := ServiceResolver(sDep.URL, sDep.Org, vExp.Get_expression(), sDep.Arch, serviceHandler); err != nil {
@@ -684,12 +686,14 @@ func ServiceDefResolver(wURL string, wOrg string, wVersion string, wArch string,
 			glog.V(5).Infof(rpclogString(fmt.Sprintf("resolving required services for %v %v %v %v", wURL, wOrg, wVersion, wArch)))
 			for _, sDep := range tlService.RequiredServices {
 
-				// Make sure the required service has the same arch as the service.
+				// Make sure the required service has the same arch as the service, and that its org is allowed for the services of this org.
 				// Convert version to a version range expression (if it's not already an expression) so that the underlying GetService
 				// will return us something in the range required by the service.
 				var serviceDef *ServiceDefinition
 				if sDep.Arch != wArch {
 					return nil, nil, nil, "", errors.New(fmt.Sprintf("service %v has a different architecture than the top level service.", sDep))
+				} else if !IsCrossOrgServiceAllowed(wOrg, sDep.Org) {
+					return nil, nil, nil, "", NewCrossOrgServiceError(wOrg, sDep.URL, sDep.Org)
 				} else if vExp, err := semanticversion.Version_Expression_Factory(sDep.Version); err != nil {
 					return nil, nil, nil, "", errors.New(fmt.Sprintf("unable to create version expression from %v, error %v", sDep.Version, err))
 				} else if apiSpecs, s_map, s_def, s_id, err := ServiceDefResolver(sDep.URL, sDep.Org, vExp.Get_expression(), sDep.Arch, serviceHandler); err != nil {
@@ -733,7 +737,9 @@ func GetServiceDockerAuths(ec ExchangeContext, url string, org string, version s
 
 	// get the service id
 	s_resp, s_id, err := GetService(ec, url, org, version, arch)
-	if err != nil {
+	if IsRetriesExceededError(err) {
+		return nil, err
+	} else if err != nil {
 		return nil, errors.New(rpclogString(fmt.Sprintf("failed to get the service %v %v %v %v.%v", url, org, version, arch, err)))
 	} else if s_resp == nil {
 		return nil, errors.New(rpclogString(fmt.Sprintf("unable to find the service %v %v %v %v.", url, org, version, arch)))
@@ -771,7 +777,7 @@ func GetServiceDockerAuthsWithId(ec ExchangeContext, service_id string) ([]Image
 				time.Sleep(time.Duration(retryInterval) * time.Second)
 				continue
 			} else if retryCount == 0 {
-				return nil, fmt.Errorf("Exceeded %v retries for error: %v", ec.GetHTTPFactory().RetryCount, tpErr)
+				return nil, NewRetriesExceededError(ec.GetHTTPFactory().RetryCount, tpErr)
 			} else {
 				retryCount--
 				time.Sleep(time.Duration(retryInterval) * time.Second)
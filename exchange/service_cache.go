@@ -0,0 +1,140 @@
+package exchange
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"github.com/open-horizon/anax/semanticversion"
+)
+
+// The service definitions and signing keys that the node gets from the exchange are also saved in the local database,
+// so that the services of the node can be verified and restarted while the exchange cannot be reached, e.g. when the
+// agent restarts during an exchange outage. The handlers in this file try the exchange with limited retries first and
+// fall back to the local copy when the exchange is unreachable. When there is no local copy, they keep trying the
+// exchange like the plain handlers.
+
+// A handler for getting a service definition from the exchange, or from the local service cache when the exchange
+// cannot be reached.
+func GetCachingServiceHandler(ec ExchangeContext, limitedRetryEC ExchangeContext, db *bolt.DB) ServiceHandler {
+	return func(wUrl string, wOrg string, wVersion string, wArch string) (*ServiceDefinition, string, error) {
+		sDef, sId, err := GetService(limitedRetryEC, wUrl, wOrg, wVersion, wArch)
+		if IsRetriesExceededError(err) {
+			if cDef, cId, cErr := GetServiceFromLocalCache(db, wUrl, wOrg, wVersion, wArch); cErr != nil {
+				glog.Errorf(rpclogString(fmt.Sprintf("unable to get service %v %v %v %v from the local service cache, error %v", wUrl, wOrg, wVersion, wArch, cErr)))
+			} else if cDef != nil {
+				glog.Warningf(rpclogString(fmt.Sprintf("exchange unreachable, using service %v from the local service cache. %v", cId, err)))
+				return cDef, cId, nil
+			}
+			sDef, sId, err = GetService(ec, wUrl, wOrg, wVersion, wArch)
+		}
+
+		if err == nil && sDef != nil {
+			if cErr := SaveServiceToLocalCache(db, sId, sDef); cErr != nil {
+				glog.Errorf(rpclogString(fmt.Sprintf("unable to save service %v in the local service cache, error %v", sId, cErr)))
+			}
+		}
+		return sDef, sId, err
+	}
+}
+
+// A handler for resolving a service and its dependencies, from the local service cache when the exchange cannot be
+// reached.
+func GetCachingServiceResolverHandler(ec ExchangeContext, limitedRetryEC ExchangeContext, db *bolt.DB) ServiceResolverHandler {
+	return func(wUrl string, wOrg string, wVersion string, wArch string) (*policy.APISpecList, *ServiceDefinition, []string, error) {
+		return ServiceResolver(wUrl, wOrg, wVersion, wArch, GetCachingServiceHandler(ec, limitedRetryEC, db))
+	}
+}
+
+// A handler for getting the signing keys of a service from the exchange, or from the local service cache when the
+// exchange cannot be reached. The signing keys of a pattern are not cached.
+func GetCachingObjectSigningKeysHandler(ec ExchangeContext, limitedRetryEC ExchangeContext, db *bolt.DB) ObjectSigningKeysHandler {
+	return func(oType string, oUrl string, oOrg string, oVersion string, oArch string) (map[string]string, error) {
+		if oType != SERVICE {
+			return GetObjectSigningKeys(ec, oType, oUrl, oOrg, oVersion, oArch)
+		}
+
+		keys, err := GetObjectSigningKeys(limitedRetryEC, oType, oUrl, oOrg, oVersion, oArch)
+		if IsRetriesExceededError(err) {
+			if cached, cErr := persistence.FindCachedService(db, oOrg, oUrl, oVersion, oArch); cErr != nil {
+				glog.Errorf(rpclogString(fmt.Sprintf("unable to get the signing keys of service %v %v %v %v from the local service cache, error %v", oUrl, oOrg, oVersion, oArch, cErr)))
+			} else if cached != nil && cached.SigningKeys != nil {
+				glog.Warningf(rpclogString(fmt.Sprintf("exchange unreachable, using the signing keys of service %v from the local service cache. %v", cached.Key, err)))
+				return cached.SigningKeys, nil
+			}
+			keys, err = GetObjectSigningKeys(ec, oType, oUrl, oOrg, oVersion, oArch)
+		}
+
+		if err == nil {
+			if keys == nil {
+				keys = make(map[string]string)
+			}
+			if cErr := persistence.SaveCachedServiceSigningKeys(db, oOrg, oUrl, oVersion, oArch, keys); cErr != nil {
+				glog.Errorf(rpclogString(fmt.Sprintf("unable to save the signing keys of service %v %v %v %v in the local service cache, error %v", oUrl, oOrg, oVersion, oArch, cErr)))
+			}
+		}
+		return keys, err
+	}
+}
+
+// Saves a service definition from the exchange in the local service cache.
+func SaveServiceToLocalCache(db *bolt.DB, sId string, sDef *ServiceDefinition) error {
+	if def, err := json.Marshal(sDef); err != nil {
+		return errors.New(fmt.Sprintf("unable to marshal service definition %v, error %v", sId, err))
+	} else {
+		return persistence.SaveCachedServiceDefinition(db, sId, GetOrg(sId), sDef.URL, sDef.Version, sDef.Arch, string(def))
+	}
+}
+
+// Returns the service definition with the highest version within the given version or version range from the local
+// service cache, nil if none is cached.
+func GetServiceFromLocalCache(db *bolt.DB, url string, org string, version string, arch string) (*ServiceDefinition, string, error) {
+
+	searchVersion, err := getSearchVersion(version)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var vExp *semanticversion.Version_Expression
+	if searchVersion == "" && version != "" {
+		if vExp, err = semanticversion.Version_Expression_Factory(version); err != nil {
+			return nil, "", errors.New(fmt.Sprintf("unable to create version expression from %v, error %v", version, err))
+		}
+	}
+
+	cachedServices, err := persistence.FindCachedServiceVersions(db, org, url, arch)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var highest *persistence.CachedService
+	for i, c := range cachedServices {
+		if c.Definition == "" {
+			continue
+		} else if searchVersion != "" && c.Version != searchVersion {
+			continue
+		} else if vExp != nil {
+			if inRange, err := vExp.Is_within_range(c.Version); err != nil || !inRange {
+				continue
+			}
+		}
+		if highest == nil {
+			highest = &cachedServices[i]
+		} else if comp, err := semanticversion.CompareVersions(c.Version, highest.Version); err == nil && comp > 0 {
+			highest = &cachedServices[i]
+		}
+	}
+
+	if highest == nil {
+		return nil, "", nil
+	}
+
+	sDef := new(ServiceDefinition)
+	if err := json.Unmarshal([]byte(highest.Definition), sDef); err != nil {
+		return nil, "", errors.New(fmt.Sprintf("unable to unmarshal cached service definition %v, error %v", highest.Key, err))
+	}
+	return sDef, highest.ServiceId, nil
+}
@@ -0,0 +1,77 @@
+//go:build unit
+// +build unit
+
+package exchange
+
+import (
+	"errors"
+	"github.com/boltdb/bolt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+// Verify that the highest cached version of a service within the version range is returned from the local cache.
+func Test_GetServiceFromLocalCache(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "utdb-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := bolt.Open(path.Join(dir, "anax-int.db"), 0600, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, version := range []string{"1.0.0", "1.5.0", "2.0.0"} {
+		sDef := &ServiceDefinition{URL: "http://my.com/ms/ms1", Version: version, Arch: "amd64", Deployment: `{"services":{}}`, DeploymentSignature: "xyzpdq="}
+		if err := SaveServiceToLocalCache(db, "myorg/my.com-ms-ms1_"+version+"_amd64", sDef); err != nil {
+			t.Fatalf("should not return error, but got %v", err)
+		}
+	}
+
+	tests := []struct {
+		version  string
+		expected string
+	}{
+		{"1.5.0", "1.5.0"},
+		{"1.2.0", ""},
+		{"[1.0.0,2.0.0)", "1.5.0"},
+		{"[1.0.0,INFINITY)", "2.0.0"},
+		{"", "2.0.0"},
+		{"[3.0.0,INFINITY)", ""},
+	}
+
+	for _, test := range tests {
+		if sDef, sId, err := GetServiceFromLocalCache(db, "http://my.com/ms/ms1", "myorg", test.version, "amd64"); err != nil {
+			t.Errorf("version %v should not return error, but got %v", test.version, err)
+		} else if test.expected == "" && sDef != nil {
+			t.Errorf("version %v should not be found, but got %v", test.version, sDef)
+		} else if test.expected != "" && (sDef == nil || sDef.Version != test.expected || sId != "myorg/my.com-ms-ms1_"+test.expected+"_amd64") {
+			t.Errorf("version %v should return %v, but got %v %v", test.version, test.expected, sId, sDef)
+		} else if sDef != nil && sDef.DeploymentSignature != "xyzpdq=" {
+			t.Errorf("the deployment signature is not cached, got %v", sDef)
+		}
+	}
+
+	if sDef, _, err := GetServiceFromLocalCache(db, "http://my.com/ms/ms1", "myorg", "", "arm64"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if sDef != nil {
+		t.Errorf("another arch should not be found, but got %v", sDef)
+	}
+}
+
+func Test_IsRetriesExceededError(t *testing.T) {
+	if !IsRetriesExceededError(NewRetriesExceededError(1, errors.New("connection refused"))) {
+		t.Errorf("should be a retries exceeded error")
+	} else if IsRetriesExceededError(errors.New("Exceeded 1 retries for error: connection refused")) {
+		t.Errorf("should not be a retries exceeded error")
+	} else if IsRetriesExceededError(nil) {
+		t.Errorf("nil should not be a retries exceeded error")
+	}
+}
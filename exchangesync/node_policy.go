@@ -144,6 +144,10 @@ func GetProcessedExchangeNodePolicy(pDevice *persistence.ExchangeDevice, getExch
 		//		break
 		//	}
 		//}
+		staleProps := externalpolicy.CopyProperties(exchangeNodePolicy.Properties)
+		if externalpolicy.RemoveStaleDynamicProperties(&staleProps, &builtinPolicy.Properties) {
+			needsBuiltIns = true
+		}
 		for _, bltinProp := range builtinPolicy.Properties {
 			found := false
 			for _, exchProp := range exchangeNodePolicy.Properties {
@@ -160,6 +164,7 @@ func GetProcessedExchangeNodePolicy(pDevice *persistence.ExchangeDevice, getExch
 		if needsBuiltIns {
 			polTemp := exchangeNodePolicy.ExternalPolicy
 			mergedPol = &polTemp
+			externalpolicy.RemoveStaleDynamicProperties(&mergedPol.Properties, &builtinPolicy.Properties)
 			mergedPol.MergeWith(builtinPolicyReadOnly, true)
 			mergedPol.MergeWith(builtinPolicyReadWrite, false)
 		}
@@ -414,6 +419,7 @@ func UpdateNodePolicy(pDevice *persistence.ExchangeDevice, db *bolt.DB, nodePoli
 	builtinNodePol, builtinNodePolReadWrite := externalpolicy.CreateNodeBuiltInPolicy(false, false, top_pol, pDevice.IsEdgeCluster())
 
 	if builtinNodePol != nil {
+		externalpolicy.RemoveStaleDynamicProperties(&nodePolicy.Properties, &builtinNodePol.Properties)
 		(&nodePolicy.ExternalPolicy).MergeWith(builtinNodePol, true)
 	}
 	if builtinNodePolReadWrite != nil {
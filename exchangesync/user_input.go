@@ -333,32 +333,42 @@ func UpdateNodeUserInput(pDevice *persistence.ExchangeDevice, db *bolt.DB,
 	return changedServiceSpecs, nil
 }
 
-// Add the given user input to the exchange node user input.
+// Merge the given user input into the exchange node user input, variable by variable. It returns the services
+// whose user input is changed by the merge, the node user input is not saved when nothing is changed.
 func PatchNodeUserInput(pDevice *persistence.ExchangeDevice, db *bolt.DB,
 	userInputs []policy.UserInput,
 	getDevice exchange.DeviceHandler,
-	patchDevice exchange.PatchDeviceHandler) error {
+	patchDevice exchange.PatchDeviceHandler) (persistence.ServiceSpecs, error) {
 
 	if userInputs == nil || len(userInputs) == 0 {
-		return nil
+		return persistence.ServiceSpecs{}, nil
 	}
 
 	// check if the user input is changed or not on the exchange since last observation,
 	// if it is changed, then reject the patch.
 	changed, exchUserInput, err := ExchangeNodeUserInputChanged(pDevice, db, getDevice)
 	if err != nil {
-		return fmt.Errorf("Failed to check the exchange for the node user input: %v.", err)
+		return nil, fmt.Errorf("Failed to check the exchange for the node user input: %v.", err)
 	} else if changed {
 		// exchange is the master
 		if _, _, err := SyncLocalUserInputWithExchange(db, pDevice, getDevice); err != nil {
-			return fmt.Errorf("Failed to sync the local user input with the exchange for node %v/%v. %v", pDevice.Org, pDevice.Id, err)
+			return nil, fmt.Errorf("Failed to sync the local user input with the exchange for node %v/%v. %v", pDevice.Org, pDevice.Id, err)
 		}
 	}
 
 	// patch the exchange userinput with the newly added one on the node
 	new_ui := policy.MergeUserInputArrays(exchUserInput, userInputs, true)
 
-	return SaveNodeUserInput(pDevice, db, new_ui, getDevice, patchDevice)
+	changedServiceSpecs := GetChangedServices(exchUserInput, new_ui)
+	if len(changedServiceSpecs) == 0 {
+		glog.V(3).Infof("The patch does not change the node user input.")
+		return changedServiceSpecs, nil
+	}
+
+	if err := SaveNodeUserInput(pDevice, db, new_ui, getDevice, patchDevice); err != nil {
+		return nil, err
+	}
+	return changedServiceSpecs, nil
 }
 
 // This fuction saves the given user input into exchange, and local db
@@ -8,6 +8,8 @@ import (
 	"github.com/open-horizon/anax/semanticversion"
 	"os"
 	"runtime"
+	"sync"
+	"time"
 )
 
 // These are built-in property names that can be used in the policies.
@@ -25,6 +27,22 @@ const (
 	PROP_NODE_K8S_NAMESPACE_SCOPED = "openhorizon.kubernetesNamespaceScoped" // Boolean field indicating whter the cluster agent is namespace-scoped
 	PROP_NODE_OS                   = "openhorizon.operatingSystem"           // The operating system the agent is installed on. For containerized agents, this is the host os
 	PROP_NODE_CONTAINERIZED        = "openhorizon.containerized"             // Boolean field indicating whether the agent is running in a container
+	PROP_NODE_MAX_EGRESS_KBPS      = "openhorizon.maxServiceEgressKbps"      // The rate, in kilobits per second, at which each service container can send. Can be set by user, no limit by default.
+
+	// refreshed periodically by the agent from the dynamic state of the node
+	PROP_NODE_LOCALTIME_HOUR = "openhorizon.localtime.hour" // The hour of the day (0-23) in the local time zone of the node
+	PROP_NODE_GEO_LAT        = "openhorizon.geo.lat"        // The latitude of the node, read from the geo location file. Omitted if the file is not configured.
+	PROP_NODE_GEO_LONG       = "openhorizon.geo.long"       // The longitude of the node, read from the geo location file. Omitted if the file is not configured.
+	PROP_NODE_UPTIME         = "openhorizon.uptime"         // The number of whole hours since the node was booted
+
+	// filled in by the agbot from the dynamic state of the node, for the placement constraints of deployment policies
+	PROP_NODE_AGREEMENT_COUNT = "openhorizon.agreementCount" // The number of agreements the node currently has with the agbot
+	PROP_NODE_FREE_MEMORY     = "openhorizon.freeMemory"     // The free memory in MBs, as last published by the node with its status
+
+	// set when an admin of the node's org signs the node policy, verified by the agbot
+	PROP_NODE_SIGNED_PROPERTIES = "openhorizon.signedProperties" // The comma separated names of the node properties covered by the signature
+	PROP_NODE_SIGNED_USERINPUT  = "openhorizon.signedUserInput"  // True when the user input of the node is covered by the signature
+	PROP_NODE_POLICY_SIGNATURE  = "openhorizon.policySignature"  // The signature of the signed node properties and user input, by a key of the node's org
 
 	// for install type
 	OS_CLUSTER   = "cluster"
@@ -50,8 +68,32 @@ const (
 const MAX_MEMEORY = 1048576                            // the unit is MB. This is 1000G
 const DEFAULT_NODE_K8S_NAMESPACE = "openhorizon-agent" // the default cluster name space for cluster type. The default for device type is an emptry string.
 
+// The file the latitude and longitude of the node are read from. Empty omits the geo properties.
+var geoLocationFile string
+var geoLocationFileLock sync.Mutex
+
 func ListReadOnlyProperties() []string {
-	return []string{PROP_NODE_CPU, PROP_NODE_ARCH, PROP_NODE_MEMORY, PROP_NODE_HARDWAREID, PROP_NODE_K8S_VERSION, PROP_NODE_K8S_NAMESPACE, PROP_NODE_K8S_NAMESPACE_SCOPED, PROP_NODE_OS, PROP_NODE_CONTAINERIZED}
+	return []string{PROP_NODE_CPU, PROP_NODE_ARCH, PROP_NODE_MEMORY, PROP_NODE_HARDWAREID, PROP_NODE_K8S_VERSION, PROP_NODE_K8S_NAMESPACE, PROP_NODE_K8S_NAMESPACE_SCOPED, PROP_NODE_OS, PROP_NODE_CONTAINERIZED,
+		PROP_NODE_LOCALTIME_HOUR, PROP_NODE_GEO_LAT, PROP_NODE_GEO_LONG, PROP_NODE_UPTIME}
+}
+
+// The built-in properties whose values change while the agent is running, and the properties of the node property
+// providers. They are refreshed by the agent periodically.
+func ListDynamicProperties() []string {
+	return append([]string{PROP_NODE_LOCALTIME_HOUR, PROP_NODE_GEO_LAT, PROP_NODE_GEO_LONG, PROP_NODE_UPTIME}, listProviderPropertyNames()...)
+}
+
+// Set the file that the geo properties of the node are read from, e.g. one kept up to date by a GPS daemon.
+func SetGeoLocationFile(geoFile string) {
+	geoLocationFileLock.Lock()
+	defer geoLocationFileLock.Unlock()
+	geoLocationFile = geoFile
+}
+
+func getGeoLocationFile() string {
+	geoLocationFileLock.Lock()
+	defer geoLocationFileLock.Unlock()
+	return geoLocationFile
 }
 
 // returns a map of all the built-in properties used by the given node type
@@ -122,6 +164,7 @@ func createClusterNodeBuiltInPolicy(availableMem bool) *ExternalPolicy {
 	} else {
 		builtInPol.Add_Property(Property_Factory(PROP_NODE_MEMORY, totMem), false)
 	}
+	builtInPol.MergeWith(CreateNodeDynamicProperties(time.Now()), false)
 	return &ExternalPolicy{Properties: *builtInPol}
 }
 
@@ -212,6 +255,8 @@ func createDeviceNodeBuiltInPolicy(availableMem bool, omitGenHwId bool, existing
 		nodeBuiltInReadOnlyProps.Add_Property(Property_Factory(PROP_NODE_MEMORY, float64(total_mem)), false)
 	}
 
+	nodeBuiltInReadOnlyProps.MergeWith(CreateNodeDynamicProperties(time.Now()), false)
+
 	buitInPolReadOnly := ExternalPolicy{
 		Properties:  *nodeBuiltInReadOnlyProps,
 		Constraints: []string{},
@@ -224,6 +269,73 @@ func createDeviceNodeBuiltInPolicy(availableMem bool, omitGenHwId bool, existing
 	return &buitInPolReadOnly, &buitInPolReadWrite
 }
 
+// Returns the dynamic built-in properties of the node at the given time, with the properties of the node property
+// providers. The geo properties are omitted if the geo location file is not configured or cannot be read, the uptime
+// if it cannot be found.
+func CreateNodeDynamicProperties(now time.Time) *PropertyList {
+	dynamicProps := new(PropertyList)
+
+	dynamicProps.Add_Property(Property_Factory(PROP_NODE_LOCALTIME_HOUR, float64(now.Hour())), false)
+
+	if geoFile := getGeoLocationFile(); geoFile != "" {
+		if lat, long, err := cutil.GetGeoLocation(geoFile); err != nil {
+			glog.V(2).Infof("Failed to get the geo location of the node. Omitting the geo properties. %v", err)
+		} else {
+			dynamicProps.Add_Property(Property_Factory(PROP_NODE_GEO_LAT, lat), false)
+			dynamicProps.Add_Property(Property_Factory(PROP_NODE_GEO_LONG, long), false)
+		}
+	}
+
+	if uptime, err := cutil.GetUptime(""); err != nil {
+		glog.V(2).Infof("Failed to get the uptime of the node. Omitting the uptime property. %v", err)
+	} else {
+		dynamicProps.Add_Property(Property_Factory(PROP_NODE_UPTIME, float64(uptime/3600)), false)
+	}
+
+	dynamicProps.MergeWith(getProviderProperties(), false)
+
+	return dynamicProps
+}
+
+// Removes the dynamic built-in properties that are in propList but no longer in builtInProps, e.g. the geo properties
+// after the geo location file is removed. It returns true if any property was removed.
+func RemoveStaleDynamicProperties(propList *PropertyList, builtInProps *PropertyList) bool {
+	if propList == nil {
+		return false
+	}
+
+	removed := false
+	newList := PropertyList{}
+	for _, prop := range *propList {
+		if cutil.SliceContains(ListDynamicProperties(), prop.Name) && (builtInProps == nil || !builtInProps.HasProperty(prop.Name)) {
+			removed = true
+		} else {
+			newList = append(newList, prop)
+		}
+	}
+	if removed {
+		*propList = newList
+	}
+	return removed
+}
+
+// Returns true if the values of the dynamic built-in properties in propList are not the same as the ones in dynamicProps.
+func DynamicPropertiesChanged(propList PropertyList, dynamicProps PropertyList) bool {
+	for _, propName := range ListDynamicProperties() {
+		if propList.HasProperty(propName) != dynamicProps.HasProperty(propName) {
+			return true
+		} else if !propList.HasProperty(propName) {
+			continue
+		}
+		prop, _ := propList.GetProperty(propName)
+		dynamicProp, _ := dynamicProps.GetProperty(propName)
+		if prop.Value != dynamicProp.Value {
+			return true
+		}
+	}
+	return false
+}
+
 // create the built-in properties
 func CreateServiceBuiltInPolicy(svcName, svcOrg, svcVersion, svcArch string) *ExternalPolicy {
 	svcBuiltInProps := new(PropertyList)
@@ -253,7 +365,11 @@ func IsNodeBuiltinPropertyName(propName string) bool {
 		propName == PROP_NODE_K8S_NAMESPACE ||
 		propName == PROP_NODE_K8S_NAMESPACE_SCOPED ||
 		propName == PROP_NODE_OS ||
-		propName == PROP_NODE_CONTAINERIZED {
+		propName == PROP_NODE_CONTAINERIZED ||
+		propName == PROP_NODE_LOCALTIME_HOUR ||
+		propName == PROP_NODE_GEO_LAT ||
+		propName == PROP_NODE_GEO_LONG ||
+		propName == PROP_NODE_UPTIME {
 		return true
 	} else {
 		return false
@@ -0,0 +1,86 @@
+//go:build unit
+// +build unit
+
+package externalpolicy
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func Test_CreateNodeDynamicProperties(t *testing.T) {
+	dir, err := ioutil.TempDir("", "utgeo-")
+	if err != nil {
+		t.Error(err)
+	}
+	defer os.RemoveAll(dir)
+	defer SetGeoLocationFile("")
+
+	now := time.Date(2021, time.March, 4, 22, 15, 0, 0, time.Local)
+
+	// no geo location file configured
+	props := CreateNodeDynamicProperties(now)
+	if hour, err := props.GetProperty(PROP_NODE_LOCALTIME_HOUR); err != nil {
+		t.Errorf("Should have property %v but got error: %v", PROP_NODE_LOCALTIME_HOUR, err)
+	} else if hour.Value != float64(22) {
+		t.Errorf("Property %v should be 22 but got: %v", PROP_NODE_LOCALTIME_HOUR, hour.Value)
+	} else if props.HasProperty(PROP_NODE_GEO_LAT) || props.HasProperty(PROP_NODE_GEO_LONG) {
+		t.Errorf("Should not have the geo properties but got: %v", props)
+	}
+
+	// the geo location file is configured
+	geoFile := path.Join(dir, "geo.json")
+	if err := ioutil.WriteFile(geoFile, []byte(`{"lat": 41.0064, "long": -111.9393}`), 0644); err != nil {
+		t.Error(err)
+	}
+	SetGeoLocationFile(geoFile)
+	props = CreateNodeDynamicProperties(now)
+	if lat, err := props.GetProperty(PROP_NODE_GEO_LAT); err != nil {
+		t.Errorf("Should have property %v but got error: %v", PROP_NODE_GEO_LAT, err)
+	} else if lat.Value != 41.0064 {
+		t.Errorf("Property %v should be 41.0064 but got: %v", PROP_NODE_GEO_LAT, lat.Value)
+	} else if long, err := props.GetProperty(PROP_NODE_GEO_LONG); err != nil {
+		t.Errorf("Should have property %v but got error: %v", PROP_NODE_GEO_LONG, err)
+	} else if long.Value != -111.9393 {
+		t.Errorf("Property %v should be -111.9393 but got: %v", PROP_NODE_GEO_LONG, long.Value)
+	}
+}
+
+func Test_DynamicPropertiesChanged(t *testing.T) {
+	nodeProps := PropertyList{*Property_Factory(PROP_NODE_CPU, float64(4)), *Property_Factory(PROP_NODE_LOCALTIME_HOUR, float64(22)),
+		*Property_Factory(PROP_NODE_GEO_LAT, 41.0064), *Property_Factory(PROP_NODE_GEO_LONG, -111.9393)}
+
+	dynamicProps := PropertyList{*Property_Factory(PROP_NODE_LOCALTIME_HOUR, float64(22)),
+		*Property_Factory(PROP_NODE_GEO_LAT, 41.0064), *Property_Factory(PROP_NODE_GEO_LONG, -111.9393)}
+	if DynamicPropertiesChanged(nodeProps, dynamicProps) {
+		t.Errorf("Dynamic properties %v should be the same as the ones in %v", dynamicProps, nodeProps)
+	}
+
+	dynamicProps = PropertyList{*Property_Factory(PROP_NODE_LOCALTIME_HOUR, float64(23)),
+		*Property_Factory(PROP_NODE_GEO_LAT, 41.0064), *Property_Factory(PROP_NODE_GEO_LONG, -111.9393)}
+	if !DynamicPropertiesChanged(nodeProps, dynamicProps) {
+		t.Errorf("Dynamic properties %v should not be the same as the ones in %v", dynamicProps, nodeProps)
+	}
+
+	dynamicProps = PropertyList{*Property_Factory(PROP_NODE_LOCALTIME_HOUR, float64(22))}
+	if !DynamicPropertiesChanged(nodeProps, dynamicProps) {
+		t.Errorf("Dynamic properties %v should not be the same as the ones in %v", dynamicProps, nodeProps)
+	}
+}
+
+func Test_RemoveStaleDynamicProperties(t *testing.T) {
+	nodeProps := PropertyList{*Property_Factory(PROP_NODE_CPU, float64(4)), *Property_Factory(PROP_NODE_LOCALTIME_HOUR, float64(22)),
+		*Property_Factory(PROP_NODE_GEO_LAT, 41.0064), *Property_Factory(PROP_NODE_GEO_LONG, -111.9393), *Property_Factory("camera", true)}
+	builtInProps := PropertyList{*Property_Factory(PROP_NODE_CPU, float64(4)), *Property_Factory(PROP_NODE_LOCALTIME_HOUR, float64(23))}
+
+	if !RemoveStaleDynamicProperties(&nodeProps, &builtInProps) {
+		t.Errorf("The geo properties should be removed from %v", nodeProps)
+	} else if len(nodeProps) != 3 || nodeProps.HasProperty(PROP_NODE_GEO_LAT) || nodeProps.HasProperty(PROP_NODE_GEO_LONG) {
+		t.Errorf("Should have the cpu, localtime and camera properties but got: %v", nodeProps)
+	} else if RemoveStaleDynamicProperties(&nodeProps, &builtInProps) {
+		t.Errorf("No property should be removed from %v", nodeProps)
+	}
+}
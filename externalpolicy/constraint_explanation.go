@@ -0,0 +1,98 @@
+package externalpolicy
+
+import (
+	"fmt"
+)
+
+// The evaluation of a constraint expression against a list of properties, as a tree. A group node combines its clauses
+// with "and" or "or", a leaf node is a single clause with the value of the property it was evaluated against.
+type ConstraintExplanation struct {
+	Op            string                  `json:"op,omitempty"`            // "and" or "or" for a group of clauses
+	Clauses       []ConstraintExplanation `json:"clauses,omitempty"`       // the clauses of a group
+	Property      string                  `json:"property,omitempty"`      // the property name of a clause
+	Operator      string                  `json:"operator,omitempty"`      // the comparison operator of a clause
+	Value         interface{}             `json:"value,omitempty"`         // the value in the clause
+	PropertyValue interface{}             `json:"propertyValue,omitempty"` // the value of the property, not set when the property is missing
+	Missing       bool                    `json:"missing,omitempty"`       // the property is not in the list of properties
+	Match         bool                    `json:"match"`
+}
+
+func (c ConstraintExplanation) String() string {
+	if c.Op != "" {
+		return fmt.Sprintf("Op: %v, Match: %v, Clauses: %v", c.Op, c.Match, c.Clauses)
+	}
+	return fmt.Sprintf("Property: %v, Operator: %v, Value: %v, PropertyValue: %v, Missing: %v, Match: %v", c.Property, c.Operator, c.Value, c.PropertyValue, c.Missing, c.Match)
+}
+
+// Evaluate each clause of the constraint expression against the properties and return the evaluation tree. A nil
+// explanation is returned when there are no constraints, they are satisfied by any properties.
+func (self *ConstraintExpression) Explain(props []Property) (*ConstraintExplanation, error) {
+	if len(*self) == 0 {
+		return nil, nil
+	}
+
+	rp, err := RequiredPropertyFromConstraint(self)
+	if err != nil {
+		return nil, err
+	} else if err := rp.IsValid(); err != nil {
+		return nil, err
+	}
+
+	topMap := make(map[string]interface{})
+	for k := range *rp {
+		topMap[k] = (*rp)[k]
+	}
+	explanation := explainControlOp(&topMap, &props)
+	return &explanation, nil
+}
+
+// This function is called recursively for the nested control operators. A group with a single clause is replaced by
+// the clause so that the tree only shows the groups written in the constraints.
+func explainControlOp(cop *map[string]interface{}, props *[]Property) ConstraintExplanation {
+	controlOp := getControlOperator(cop)
+	group := ConstraintExplanation{Op: controlOp, Clauses: []ConstraintExplanation{}}
+
+	propArray, _ := (*cop)[controlOp].([]interface{})
+	for _, p := range propArray {
+		if prop := isPropertyExpression(p); prop != nil {
+			group.Clauses = append(group.Clauses, explainPropertyExpression(prop, props))
+		} else if cop1 := isControlOp(p); cop1 != nil {
+			group.Clauses = append(group.Clauses, explainControlOp(cop1, props))
+		}
+	}
+
+	if len(group.Clauses) == 1 {
+		return group.Clauses[0]
+	}
+
+	group.Match = controlOp == OP_AND
+	for _, clause := range group.Clauses {
+		if controlOp == OP_AND && !clause.Match {
+			group.Match = false
+		} else if controlOp == OP_OR && clause.Match {
+			group.Match = true
+		}
+	}
+	return group
+}
+
+func explainPropertyExpression(prop *PropertyExpression, props *[]Property) ConstraintExplanation {
+	clause := ConstraintExplanation{
+		Property: prop.Name,
+		Operator: prop.Op,
+		Value:    prop.Value,
+		Missing:  true,
+		Match:    propertyInArray(prop, props),
+	}
+	if value, ok := prop.Value.(string); ok {
+		clause.Value = removeQuotes(removeSpaces(value))
+	}
+	for _, p := range *props {
+		if p.Name == prop.Name {
+			clause.PropertyValue = p.Value
+			clause.Missing = false
+			break
+		}
+	}
+	return clause
+}
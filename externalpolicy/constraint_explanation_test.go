@@ -0,0 +1,49 @@
+//go:build unit
+// +build unit
+
+package externalpolicy
+
+import (
+	_ "github.com/open-horizon/anax/externalpolicy/text_language"
+	"testing"
+)
+
+func Test_Explain(t *testing.T) {
+	props := []Property{*Property_Factory("purpose", "location"), *Property_Factory("openhorizon.cpu", float64(1))}
+
+	ce := ConstraintExpression{"purpose == location && (openhorizon.cpu >= 2 || camera == true)"}
+	exp, err := ce.Explain(props)
+	if err != nil {
+		t.Errorf("Error: unable to explain %v: %v", ce, err)
+	} else if exp.Match {
+		t.Errorf("Error: %v should not match, got %v", ce, exp)
+	} else if exp.Op != OP_AND || len(exp.Clauses) != 2 {
+		t.Errorf("Error: should have an and group of 2 clauses, got %v", exp)
+	} else if purpose := exp.Clauses[0]; !purpose.Match || purpose.Property != "purpose" || purpose.PropertyValue != "location" {
+		t.Errorf("Error: the purpose clause should match, got %v", purpose)
+	} else if or := exp.Clauses[1]; or.Op != OP_OR || or.Match || len(or.Clauses) != 2 {
+		t.Errorf("Error: should have an or group of 2 clauses that does not match, got %v", or)
+	} else if cpu := or.Clauses[0]; cpu.Match || cpu.PropertyValue != float64(1) || cpu.Operator != ">=" {
+		t.Errorf("Error: the cpu clause should not match, got %v", cpu)
+	} else if camera := or.Clauses[1]; camera.Match || !camera.Missing {
+		t.Errorf("Error: the camera property should be missing, got %v", camera)
+	}
+
+	// the result matches IsSatisfiedBy
+	ce = ConstraintExpression{"purpose == location", "openhorizon.cpu < 2"}
+	if exp, err := ce.Explain(props); err != nil || !exp.Match {
+		t.Errorf("Error: %v should match, got %v %v", ce, exp, err)
+	} else if ce.IsSatisfiedBy(props) != nil {
+		t.Errorf("Error: %v should be satisfied", ce)
+	}
+
+	ce = ConstraintExpression{}
+	if exp, err := ce.Explain(props); err != nil || exp != nil {
+		t.Errorf("Error: there is nothing to explain without constraints, got %v %v", exp, err)
+	}
+
+	ce = ConstraintExpression{"purpose == "}
+	if _, err := ce.Explain(props); err == nil {
+		t.Errorf("Error: should have returned an error for an invalid constraint")
+	}
+}
@@ -3,7 +3,9 @@ package externalpolicy
 import (
 	"fmt"
 	"github.com/open-horizon/anax/externalpolicy/plugin_registry"
+	"github.com/open-horizon/anax/i18n"
 	"strings"
+	"unicode/utf8"
 )
 
 // This type implements all the ConstraintLanguage Plugin methods and delegates to plugin system.
@@ -17,6 +19,50 @@ func (c *ConstraintExpression) GetLanguageHandler() (plugin_registry.ConstraintL
 	return plugin_registry.ConstraintLanguagePlugins.GetLanguageHandlerByOne((*c).GetStrings())
 }
 
+// A syntax error in one of the constraints of a ConstraintExpression. The position is the column (starting at 1) in the
+// constraint where the parser could not go on.
+type ConstraintSyntaxError struct {
+	Constraint string
+	Position   int
+	Err        error
+}
+
+func (e *ConstraintSyntaxError) Error() string {
+	return i18n.GetMessagePrinter().Sprintf("syntax error at position %v in constraint %v: %v", e.Position, e.Constraint, e.Err)
+}
+
+// CheckSyntax parses each constraint and returns a *ConstraintSyntaxError for the first one that is not valid. Unlike
+// Validate, it tells where the constraint is wrong, so that the user does not have to guess.
+func (c *ConstraintExpression) CheckSyntax() error {
+	// get message printer because this function is called by CLI
+	msgPrinter := i18n.GetMessagePrinter()
+
+	for _, constraint := range *c {
+		if _, err := plugin_registry.ConstraintLanguagePlugins.ValidatedByOne([]string{constraint}); err == nil {
+			continue
+		}
+
+		position := utf8.RuneCountInString(constraint) + 1
+		err := fmt.Errorf(msgPrinter.Sprintf("the constraint expression contains unmatched parentheses"))
+		for _, handler := range plugin_registry.ConstraintLanguagePlugins {
+			// the parser returns what is left of the constraint when it fails, which gives the position of the error
+			_, remainder, perr := parseConstraintExpression(constraint, handler)
+			remainder = strings.TrimLeft(remainder, " \t\r\n")
+			if perr != nil {
+				position = utf8.RuneCountInString(constraint) - utf8.RuneCountInString(remainder) + 1
+				err = perr
+				break
+			} else if strings.TrimSpace(remainder) != "" {
+				position = utf8.RuneCountInString(constraint) - utf8.RuneCountInString(remainder) + 1
+				err = fmt.Errorf(msgPrinter.Sprintf("unexpected text %v", remainder))
+				break
+			}
+		}
+		return &ConstraintSyntaxError{Constraint: constraint, Position: position, Err: err}
+	}
+	return nil
+}
+
 // Create a simple, empty ConstraintExpression Object.
 func Constraint_Factory() *ConstraintExpression {
 	ce := new(ConstraintExpression)
@@ -106,6 +152,19 @@ func (self *ConstraintExpression) IsSatisfiedBy(props []Property) error {
 	}
 }
 
+// Returns the names of the properties that the constraint expression references.
+func (self *ConstraintExpression) PropertyNames() ([]string, error) {
+	if len(*self) == 0 {
+		return []string{}, nil
+	}
+
+	if rp, err := RequiredPropertyFromConstraint(self); err != nil {
+		return nil, err
+	} else {
+		return rp.PropertyNames(), nil
+	}
+}
+
 func (self *ConstraintExpression) GetStrings() []string {
 	return ([]string(*self))
 }
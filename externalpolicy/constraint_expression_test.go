@@ -337,3 +337,85 @@ func Test_MergeWith(t *testing.T) {
 		t.Errorf("Error: constraints %v should have 4 elements but got %v", ce1, len(*ce1))
 	}
 }
+
+func Test_CheckSyntax(t *testing.T) {
+	ce := ConstraintExpression{"prop == true && prop2 == \"a b\"", "version in [1.1.1,INFINITY) OR (cert == USDA AND size > 3)"}
+	if err := ce.CheckSyntax(); err != nil {
+		t.Errorf("Error: constraints %v should be valid, error: %v", ce, err)
+	}
+
+	// the position of the error in each constraint
+	tests := map[string]int{
+		"prop == true && prop2 = ": 17,
+		"prop == true prop2 == 1":  14,
+		"size > small":             1,
+		"(prop == true":            14,
+		"prop == true)":            14,
+	}
+	for constraint, position := range tests {
+		ce = ConstraintExpression{"prop == true", constraint}
+		if err := ce.CheckSyntax(); err == nil {
+			t.Errorf("Error: constraint %v should not be valid", constraint)
+		} else if serr, ok := err.(*ConstraintSyntaxError); !ok {
+			t.Errorf("Error: wrong error type %T for constraint %v", err, constraint)
+		} else if serr.Constraint != constraint || serr.Position != position {
+			t.Errorf("Error: constraint %v should fail at position %v, got %v in %v", constraint, position, serr.Position, serr.Constraint)
+		}
+	}
+}
+
+func Test_IsSatisfiedBy_NewOperators(t *testing.T) {
+	prop_list := `[{"name":"hostname", "value":"edge-42"},{"name":"agentVersion", "value":"2.31.0", "type":"version"},{"name":"arch", "value":"arm64"},{"name":"groups", "value":"lab,retail", "type":"list of strings"}]`
+	props := create_property_list(prop_list, t)
+
+	satisfied := []string{
+		"hostname =~ \"^edge-[0-9]+$\"",
+		"groups =~ \"^ret\"",
+		"agentVersion >= 2.30.0 && agentVersion < 3",
+		"agentVersion > 2.30.9",
+		"arch in [amd64, arm64]",
+		"agentVersion in [2.30.0, 2.31.0, 2.32.0]",
+		"agentVersion in [2.30.0,3.0.0)",
+	}
+	for _, constraint := range satisfied {
+		ce := ConstraintExpression{constraint}
+		if err := ce.IsSatisfiedBy(*props); err != nil {
+			t.Errorf("Error: %v should be satisfied by %v: %v", constraint, prop_list, err)
+		}
+	}
+
+	notSatisfied := []string{
+		"hostname =~ \"^edge-[a-z]+$\"",
+		"groups =~ \"^factory\"",
+		"agentVersion < 2.31.0",
+		"agentVersion >= 2.32",
+		"arch in [amd64, \"ppc64le\"]",
+		"agentVersion in [2.30.0, 2.32.0, 2.33.0]",
+		"agentVersion in [2.32.0,3.0.0)",
+	}
+	for _, constraint := range notSatisfied {
+		ce := ConstraintExpression{constraint}
+		if err := ce.IsSatisfiedBy(*props); err == nil {
+			t.Errorf("Error: %v should not be satisfied by %v", constraint, prop_list)
+		}
+	}
+}
+
+func Test_PropertyNames(t *testing.T) {
+	ce := ConstraintExpression{"(purpose == location || iame2edev == true) && openhorizon.cpu >= 2", "purpose != network"}
+	if names, err := ce.PropertyNames(); err != nil {
+		t.Errorf("Error: unable to get the property names of %v: %v", ce, err)
+	} else if len(names) != 3 {
+		t.Errorf("Error: should have 3 property names, but got %v", names)
+	}
+
+	ce = ConstraintExpression{}
+	if names, err := ce.PropertyNames(); err != nil || len(names) != 0 {
+		t.Errorf("Error: an empty constraint should not reference any property, but got %v %v", names, err)
+	}
+
+	ce = ConstraintExpression{"purpose == "}
+	if _, err := ce.PropertyNames(); err == nil {
+		t.Errorf("Error: should have returned an error for an invalid constraint")
+	}
+}
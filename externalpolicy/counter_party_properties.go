@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/open-horizon/anax/semanticversion"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -23,8 +24,10 @@ import (
 // _control_operator_    = {"and", "or", "not"}
 // _expression_          = _control_operator_: [_expression_] || property
 // _property_            = "name": _property_name_, "value": _property_value, "op": _comparison_operator_
-// _comparison_operator_ = {"<", "=", ">", "<=", ">=", "!="}
+// _comparison_operator_ = {"<", "=", ">", "<=", ">=", "!=", "in", "=~"}
 // The "=" and "!=" comparison operators can be applied to strings and integers.
+// The "<", ">", "<=" and ">=" comparison operators can also be applied to versions.
+// The "=~" comparison operator matches strings with a regular expression.
 // If the "op" key is missing, then equal is assumed.
 //
 // See the unit tests for examples of valid and invalid syntax
@@ -65,6 +68,7 @@ const lessthaneq = "<="
 const greaterthaneq = ">="
 const notequalto = "!="
 const isin = "in"
+const matches = "=~"
 
 // This struct represents property value expressions to be satisfied
 type PropertyExpression struct {
@@ -170,6 +174,46 @@ func (self *RequiredProperty) satisfied(cop *map[string]interface{}, props *[]Pr
 	return nil
 }
 
+// Returns the names of all the properties referenced in the RequiredProperty expression, each name once.
+func (self *RequiredProperty) PropertyNames() []string {
+	names := []string{}
+	if len(*self) == 0 {
+		return names
+	}
+
+	topMap := make(map[string]interface{})
+	for k := range *self {
+		topMap[k] = (*self)[k]
+	}
+	return collectPropertyNames(&topMap, names)
+}
+
+// This function walks the control operators recursively and appends the property names to the input list.
+func collectPropertyNames(cop *map[string]interface{}, names []string) []string {
+	controlOp := getControlOperator(cop)
+	propArray, ok := (*cop)[controlOp].([]interface{})
+	if !ok {
+		return names
+	}
+	for _, p := range propArray {
+		if prop := isPropertyExpression(p); prop != nil {
+			found := false
+			for _, name := range names {
+				if name == prop.Name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				names = append(names, prop.Name)
+			}
+		} else if cop1 := isControlOp(p); cop1 != nil {
+			names = collectPropertyNames(cop1, names)
+		}
+	}
+	return names
+}
+
 // This function is used to verify that the RequiredProperty expression is syntactically valid.
 func (self *RequiredProperty) IsValid() error {
 
@@ -282,7 +326,7 @@ func controlOperators() map[string]int {
 // of the supported comparison operators.
 func comparisonOperators() map[string]int {
 	// return map[string]int {and:0, or:0, not:0}
-	return map[string]int{lessthan: 0, greaterthan: 0, doubleequalto: 0, equalto: 0, lessthaneq: 0, greaterthaneq: 0, notequalto: 0, isin: 0}
+	return map[string]int{lessthan: 0, greaterthan: 0, doubleequalto: 0, equalto: 0, lessthaneq: 0, greaterthaneq: 0, notequalto: 0, isin: 0, matches: 0}
 }
 
 // Return a map of comparison operators that only work on strings
@@ -462,7 +506,14 @@ func propertyInArray(propexp *PropertyExpression, props *[]Property) bool {
 			} else if isString(p.Value) && isString(propexp.Value) {
 				pValue := removeSpaces(removeQuotes(p.Value.(string)))
 				propexpValue := removeSpaces(removeQuotes(propexp.Value.(string)))
-				if _, ok := stringOperators()[propexp.Op]; !ok {
+				if propexp.Op == matches {
+					return matchesRegex(pValue, removeQuotes(strings.TrimSpace(propexp.Value.(string))), p.Type == LIST_TYPE)
+				} else if _, ok := versionOperators()[propexp.Op]; ok {
+					if p.Type == VERSION_TYPE || p.Type == UNDECLARED_TYPE || p.Type == STRING_TYPE {
+						return compareVersion(pValue, propexp.Op, propexpValue)
+					}
+					return false
+				} else if _, ok := stringOperators()[propexp.Op]; !ok {
 					return false
 				} else if propexp.Op == notequalto {
 					if p.Type == LIST_TYPE {
@@ -470,7 +521,7 @@ func propertyInArray(propexp *PropertyExpression, props *[]Property) bool {
 					}
 					return pValue != propexpValue
 				} else if propexp.Op == isin {
-					if p.Type == VERSION_TYPE || (semanticversion.IsVersionString(pValue) && semanticversion.IsVersionExpression(propexpValue)) {
+					if (p.Type == VERSION_TYPE && (semanticversion.IsVersionString(propexpValue) || semanticversion.IsVersionExpression(propexpValue))) || (semanticversion.IsVersionString(pValue) && semanticversion.IsVersionExpression(propexpValue)) {
 						return containsVersion(pValue, propexpValue)
 					}
 					if p.Type == LIST_TYPE {
@@ -500,6 +551,46 @@ func removeQuotes(value string) string {
 	return value
 }
 
+// Return a map of comparison operators that compare versions.
+func versionOperators() map[string]int {
+	return map[string]int{lessthan: 0, greaterthan: 0, lessthaneq: 0, greaterthaneq: 0}
+}
+
+// Compare the version of the property with the version of the property expression. It is false when one of them is not
+// a single version.
+func compareVersion(version string, op string, expVersion string) bool {
+	if c, err := semanticversion.CompareVersions(version, expVersion); err != nil {
+		return false
+	} else if op == lessthan {
+		return c < 0
+	} else if op == greaterthan {
+		return c > 0
+	} else if op == lessthaneq {
+		return c <= 0
+	} else if op == greaterthaneq {
+		return c >= 0
+	}
+	return false
+}
+
+// Return true if the value matches the regular expression. Each value of a list is matched, the list matches if one of
+// them does.
+func matchesRegex(value string, expr string, isList bool) bool {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return false
+	}
+	if !isList {
+		return re.MatchString(value)
+	}
+	for _, val := range strings.Split(value, ",") {
+		if re.MatchString(removeQuotes(removeSpaces(val))) {
+			return true
+		}
+	}
+	return false
+}
+
 func containsVersion(versRange string, version string) bool {
 	vers, err := semanticversion.Version_Expression_Factory(version)
 	if err != nil {
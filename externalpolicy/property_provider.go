@@ -0,0 +1,59 @@
+package externalpolicy
+
+import (
+	"sync"
+)
+
+// Each node property provider implements this interface. A provider contributes properties, e.g. the model of an
+// attached PLC or the TPM attestation state of the node, that the agent merges into the node policy with the
+// dynamic built-in properties.
+type NodePropertyProvider interface {
+	GetName() string             // The name of the provider, used in the logs.
+	GetIntervalS() int           // Seconds between the refreshes of the properties.
+	PropertyNames() []string     // The names of all the properties the provider can contribute.
+	GetProperties() PropertyList // The properties from the last successful refresh.
+	Refresh() (bool, error)      // Get the properties again, returns true if they changed.
+}
+
+// Global node property provider registry.
+var nodePropertyProviders []NodePropertyProvider
+var nodePropertyProvidersLock sync.Mutex
+
+// Providers call this function to register themselves in the global registry.
+func RegisterNodePropertyProvider(p NodePropertyProvider) {
+	nodePropertyProvidersLock.Lock()
+	defer nodePropertyProvidersLock.Unlock()
+	nodePropertyProviders = append(nodePropertyProviders, p)
+}
+
+// Remove all the providers from the global registry.
+func ClearNodePropertyProviders() {
+	nodePropertyProvidersLock.Lock()
+	defer nodePropertyProvidersLock.Unlock()
+	nodePropertyProviders = nil
+}
+
+func GetNodePropertyProviders() []NodePropertyProvider {
+	nodePropertyProvidersLock.Lock()
+	defer nodePropertyProvidersLock.Unlock()
+	return append([]NodePropertyProvider{}, nodePropertyProviders...)
+}
+
+// Returns the names of the properties of all the registered providers.
+func listProviderPropertyNames() []string {
+	names := []string{}
+	for _, p := range GetNodePropertyProviders() {
+		names = append(names, p.PropertyNames()...)
+	}
+	return names
+}
+
+// Returns the properties of all the registered providers.
+func getProviderProperties() *PropertyList {
+	props := new(PropertyList)
+	for _, p := range GetNodePropertyProviders() {
+		provProps := p.GetProperties()
+		props.MergeWith(&provProps, false)
+	}
+	return props
+}
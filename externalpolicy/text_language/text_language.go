@@ -8,8 +8,10 @@ import (
 	"github.com/open-horizon/anax/externalpolicy/plugin_registry"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/semanticversion"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 func init() {
@@ -114,7 +116,7 @@ func (p *TextConstraintLanguagePlugin) GetNextExpression(expression string) (str
 		}
 
 		nextRune = nextToken.Type
-		if nextRune != def["OpEq"] && nextRune != def["OpComp"] && nextRune != def["OpIn"] {
+		if nextRune != def["OpEq"] && nextRune != def["OpComp"] && nextRune != def["OpIn"] && nextRune != def["OpRegex"] {
 			if len(name) > 3 && name[len(name)-2:] == "in" {
 				op = "in"
 				opType = def["in"]
@@ -135,7 +137,7 @@ func (p *TextConstraintLanguagePlugin) GetNextExpression(expression string) (str
 			nextRune = nextToken.Type
 		}
 
-		if nextRune != def["Str"] && nextRune != def["InStr"] && nextRune != def["QuoteStr"] && nextRune != def["ListStr"] && nextRune != def["Vers"] && nextRune != def["VersRange"] && nextRune != def["Num"] {
+		if nextRune != def["Str"] && nextRune != def["InStr"] && nextRune != def["QuoteStr"] && nextRune != def["ListStr"] && nextRune != def["RegexStr"] && nextRune != def["BracketList"] && nextRune != def["Vers"] && nextRune != def["VersRange"] && nextRune != def["Num"] {
 			return "", expression, fmt.Errorf("Invalid property value. %v%v%v", name, op, nextToken.Value)
 		}
 		if val == "" {
@@ -145,7 +147,13 @@ func (p *TextConstraintLanguagePlugin) GetNextExpression(expression string) (str
 		if err = validOpValuePair(name, op, opType, val, valType, def); err != nil {
 			return "", expression, err
 		}
-		return fmt.Sprintf("%v\a%v\a%v", name, strings.TrimSpace(op), strings.TrimSpace(val)), strings.Replace(expression, fmt.Sprintf(("%v%v%v"), name, op, val), "", 1), nil
+
+		// a list in brackets is evaluated like the same list in quotes
+		expVal := strings.TrimSpace(val)
+		if valType == def["BracketList"] {
+			expVal = bracketListToListStr(expVal)
+		}
+		return fmt.Sprintf("%v\a%v\a%v", name, strings.TrimSpace(op), expVal), strings.Replace(expression, fmt.Sprintf(("%v%v%v"), name, op, val), "", 1), nil
 	}
 	if nextRune == def["OpenParen"] || nextRune == def["CloseParen"] {
 		return "", expression, nil
@@ -193,6 +201,9 @@ func (p *TextConstraintLanguagePlugin) GetNextOperator(expression string) (strin
 // 4. for string types, a quoted string, inside which is a list of comma separated strings provide acceptable values
 // 5. string values that contain spaces must be quoted
 // 6. for the version type, supported values are a single version or a range of versions in the semantic version format (the same as used for service verions). The == operator implies that the value is a single version. The 'in' operator treats the value as a version range. As with service versions, the version 1.0.0 when treated as a version range is equivalent to the explicit range [1.0.0,INFINITY).
+// 7. for the version type, the operators <, >, <=, >= compare the property with a single version
+// 8. the =~ operator matches a string or a list of strings with the regular expression in the quoted value
+// 9. the 'in' operator also takes a list of values in brackets, e.g. [a,b,c]. A list of two versions that ends with ']' is the version range.
 
 // This function checks that the operator is valid for the specified value and validates version ranges with the semanticversion Factory function
 // Returns a property expression struct with numerical values as float64
@@ -200,21 +211,34 @@ func (p *TextConstraintLanguagePlugin) GetNextOperator(expression string) (strin
 func validOpValuePair(name string, op string, opType rune, val interface{}, valType rune, lexMap map[string]rune) error {
 	var err error
 
+	if lexMap["RegexStr"] == valType && lexMap["OpRegex"] != opType {
+		return fmt.Errorf("The value %v can only be used with operator '=~'.", val)
+	}
 	if lexMap["OpEq"] == opType {
 		if lexMap["VersRange"] == valType {
 			return fmt.Errorf("Version range can only use operator 'in'.")
 		}
-		if lexMap["ListStr"] == valType {
+		if lexMap["ListStr"] == valType || lexMap["BracketList"] == valType {
 			return fmt.Errorf("Property type list of strings can only use operator 'in'.")
 		}
 	}
 	if lexMap["OpComp"] == opType {
-		if _, err := strconv.ParseFloat(val.(string), 64); err != nil {
+		if lexMap["Vers"] == valType {
+			// a version comparison
+		} else if _, err := strconv.ParseFloat(val.(string), 64); err != nil {
 			return fmt.Errorf("Cannot use numerical comparison operator %s with value %v.", op, val)
 		}
 	}
+	if lexMap["OpRegex"] == opType {
+		if lexMap["QuoteStr"] != valType && lexMap["ListStr"] != valType && lexMap["RegexStr"] != valType {
+			return fmt.Errorf("The '=~' operator can only be used with a quoted regular expression, found %v.", val)
+		}
+		if _, err := regexp.Compile(strings.Trim(strings.TrimSpace(val.(string)), "\x22")); err != nil {
+			return fmt.Errorf("Invalid regular expression %v. %v", strings.TrimSpace(val.(string)), err)
+		}
+	}
 	if lexMap["OpIn"] == opType {
-		if lexMap["ListStr"] != valType && lexMap["QuoteStr"] != valType && lexMap["VersRange"] != valType && lexMap["Vers"] != valType {
+		if lexMap["ListStr"] != valType && lexMap["QuoteStr"] != valType && lexMap["BracketList"] != valType && lexMap["VersRange"] != valType && lexMap["Vers"] != valType {
 			return fmt.Errorf("The 'in' operator can only be used for types version and list of strings")
 		}
 		if lexMap["VersRange"] == valType {
@@ -229,6 +253,15 @@ func validOpValuePair(name string, op string, opType rune, val interface{}, valT
 	return nil
 }
 
+// Convert a list in brackets, e.g. [a, "b c"], to the same list in quotes, "a,b c".
+func bracketListToListStr(val string) string {
+	items := strings.Split(strings.TrimSuffix(strings.TrimPrefix(val, "["), "]"), ",")
+	for i, item := range items {
+		items[i] = strings.Trim(strings.TrimSpace(item), "\x22")
+	}
+	return fmt.Sprintf("\x22%v\x22", strings.Join(items, ","))
+}
+
 // the unicode character ranges are:
 // \u4E00-\u9FFF CJK common characters
 // \u00A0-\u00FF latin 1 supplement
@@ -237,7 +270,20 @@ func validOpValuePair(name string, op string, opType rune, val interface{}, valT
 // \u1100-\u11FF and \uAC00-\uD7AF hangul (korean)
 // \uFF00-\uFFEF Halfwidth and Fullwidth Forms
 // \u20A0-\u20CF Currency Symbols
+//
+// Building the lexer from the grammar is far more expensive than lexing a constraint, and the definition is read-only,
+// so it is built once and shared.
+var lexerDef lexer.Definition
+var lexerDefOnce sync.Once
+
 func getLexer() lexer.Definition {
+	lexerDefOnce.Do(func() {
+		lexerDef = newLexer()
+	})
+	return lexerDef
+}
+
+func newLexer() lexer.Definition {
 	return lexer.Must(ebnf.New(`
 	  alphanumeric = digit | alpha .
 
@@ -252,9 +298,12 @@ func getLexer() lexer.Definition {
 
 		OpComp =  {whitespace} ( ["="] (">" | "<") ["="] ) {whitespace} .
 		OpIn =  {whitespace} "in" {whitespace} .
+	  OpRegex = {whitespace} "=~" {whitespace} .
 	  OpEq =  {whitespace}  ( "!=" | "="["="] )  {whitespace} .
 
 	  VersRange = {whitespace}  ( "(" | "[" )  vers {whitespace}  "," {whitespace}  (vers | "INFINITY")  ("]" | ")").
+	  BracketList = {whitespace} "[" listchar {listchar} "]" .
+	  listchar = alphanumeric | "_" | "-" | "/" | "!" | "?" | "+" | "~" | "'" | "." | "," | " " | "\t" | "\x22" .
 		Vers = {whitespace}  vers .
 	  Num = {whitespace} ["-"] digit {digit} ["." {digit}] .
 	  whitespace = "\n" | "\r" | "\t" | " " .
@@ -265,6 +314,8 @@ func getLexer() lexer.Definition {
 	  Str =  {whitespace} (alphanumeric | "_" | "-" | "/" | "!" | "?" | "+" | "~" | "'" | ".") {alphanumeric | "_" | "-" | "/" | "!" | "?" | "+" | "~" | "'" | "."} .
 	  QuoteStr = {whitespace} "\x22" (alphanumeric  | "_" | "-" |  "/" | "!" | "?" | "+" | "~" | "." | "'" | " " | "\t") {alphanumeric | "_" | "-" |  "/" | "!" | "?" | "+" | "~" | "." | "'" | " " | "\t" } "\x22" .
 		ListStr = {whitespace} "\x22" (alphanumeric  | "_" | "-" |  "/" | "!" | "?" | "+" | "~" | "." | "'" | "," | " " | "\t") {alphanumeric | "_" | "-" |  "/" | "!" | "?" | "+" | "~" | "." | "'" | "," | " " | "\t" } "\x22" .
+	  RegexStr = {whitespace} "\x22" regexchar {regexchar} "\x22" .
+	  regexchar = "\t" | " "…"!" | "#"…"\uFFFF" .
 
 
 	  Unused = digit .`))
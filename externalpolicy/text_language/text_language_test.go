@@ -367,3 +367,46 @@ func Test_GetNextExpression_Succeed(t *testing.T) {
 	}
 
 }
+
+func Test_GetNextExpression_NewOperators(t *testing.T) {
+	textConstraintLanguagePlugin := NewTextConstraintLanguagePlugin()
+
+	tests := []struct {
+		constraint string
+		expression string
+	}{
+		{"hostname =~ \"^edge-[0-9]+(\\.lab)?$\"", "hostname\a=~\a\"^edge-[0-9]+(\\.lab)?$\""},
+		{"hostname=~\"edge\"", "hostname\a=~\a\"edge\""},
+		{"agentVersion >= 2.30.0", "agentVersion\a>=\a2.30.0"},
+		{"agentVersion < 3.1", "agentVersion\a<\a3.1"},
+		{"arch in [amd64, arm64, \"ppc64le\"]", "arch\ain\a\"amd64,arm64,ppc64le\""},
+		{"version in [1.0.0,2.0.0]", "version\ain\a[1.0.0,2.0.0]"},
+		{"version in [1.0.0,1.1.0,2.0.0]", "version\ain\a\"1.0.0,1.1.0,2.0.0\""},
+	}
+
+	for _, test := range tests {
+		if exp, rem, err := textConstraintLanguagePlugin.GetNextExpression(test.constraint + " && cpu == 3"); err != nil {
+			t.Errorf("Error parsing constraint expression %v with GetNextExpression: %v", test.constraint, err)
+		} else if exp != test.expression {
+			t.Errorf("Wrong expression %q for %v, expected %q", exp, test.constraint, test.expression)
+		} else if rem != " && cpu == 3" {
+			t.Errorf("Wrong remainder %q for %v", rem, test.constraint)
+		}
+	}
+}
+
+func Test_Validate_NewOperators_Failed(t *testing.T) {
+	textConstraintLanguagePlugin := NewTextConstraintLanguagePlugin()
+
+	for _, constraint := range []string{
+		"hostname =~ \"edge-[0-9\"",
+		"hostname =~ edge",
+		"hostname == \"^edge-.*$\"",
+		"arch == [amd64, arm64]",
+		"agentVersion > newest",
+	} {
+		if validated, _, err := textConstraintLanguagePlugin.Validate(interface{}([]string{constraint})); validated || err == nil {
+			t.Errorf("Validation of %v should fail but did not", constraint)
+		}
+	}
+}
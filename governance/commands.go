@@ -133,6 +133,42 @@ func (w *GovernanceWorker) NewUpdateMicroserviceCommand(key string, started bool
 	}
 }
 
+// ==============================================================================================================
+// Retry a failed service instance once the backoff delay is over
+type RetryMicroserviceCommand struct {
+	MsDefKey  string
+	MsInstKey string
+	RetryTime uint64 // the time, in seconds since the epoch, before which the service is not retried
+}
+
+func (c RetryMicroserviceCommand) ShortString() string {
+	return fmt.Sprintf("RetryServiceCommand: MsDefKey %v, MsInstKey %v, RetryTime %v", c.MsDefKey, c.MsInstKey, c.RetryTime)
+}
+
+func (w *GovernanceWorker) NewRetryMicroserviceCommand(msdef_key string, msinst_key string, retry_time uint64) *RetryMicroserviceCommand {
+	return &RetryMicroserviceCommand{
+		MsDefKey:  msdef_key,
+		MsInstKey: msinst_key,
+		RetryTime: retry_time,
+	}
+}
+
+// ==============================================================================================================
+// Retry an image fetch that failed with an auth error if the image auths were updated in the exchange
+type ImageAuthErrorCommand struct {
+	Msg *events.ImageFetchMessage
+}
+
+func (c ImageAuthErrorCommand) ShortString() string {
+	return fmt.Sprintf("ImageAuthErrorCommand: Msg %v", c.Msg.ShortString())
+}
+
+func (w *GovernanceWorker) NewImageAuthErrorCommand(msg *events.ImageFetchMessage) *ImageAuthErrorCommand {
+	return &ImageAuthErrorCommand{
+		Msg: msg,
+	}
+}
+
 // ==============================================================================================================
 type ReportDeviceStatusCommand struct {
 	configStates []events.ServiceConfigState
@@ -218,6 +254,22 @@ func (w *GovernanceWorker) NewServiceSuspendedCommand(scs []events.ServiceConfig
 	return &ServiceSuspendedCommand{ServiceConfigState: scs}
 }
 
+// ==============================================================================================================
+// Service paused or resumed by the node owner
+type ServicePauseCommand struct {
+	Url   string
+	Org   string
+	Pause bool
+}
+
+func (c ServicePauseCommand) ShortString() string {
+	return fmt.Sprintf("ServicePauseCommand: Url %v, Org %v, Pause %v.", c.Url, c.Org, c.Pause)
+}
+
+func (w *GovernanceWorker) NewServicePauseCommand(url string, org string, pause bool) *ServicePauseCommand {
+	return &ServicePauseCommand{Url: url, Org: org, Pause: pause}
+}
+
 // ==============================================================================================================
 // Update (re-generate) node side policies
 type UpdatePolicyCommand struct {
@@ -40,6 +40,8 @@ const MICROSERVICE_GOVERNOR = "MicroserviceGovernor"
 const BC_GOVERNOR = "BlockchainGovernor"
 const SURFACEERRORS = "SurfaceExchErrors"
 const NODESTATUS = "NodeStatus"
+const SERVICESTATS = "ServiceStats"
+const IMAGEAUTHS = "ImageAuthRefresh"
 
 // Keys for the exchange errors cache in the worker
 const EXCHANGE_ERRORS = "ExchangeErrors"
@@ -58,6 +60,8 @@ type GovernanceWorker struct {
 	exchErrors        cache.Cache
 	noworkDispatch    int64 // The last time the NoWorkHandler was dispatched.
 	essCleanedUp      bool
+	statsReported     int64 // The last time the service stats were published with the node status.
+	memoryReported    int64 // The free memory, in MB, last published with the node status.
 }
 
 func NewGovernanceWorker(name string, cfg *config.HorizonConfig, db *bolt.DB, pm *policy.PolicyManager) *GovernanceWorker {
@@ -148,77 +152,11 @@ func (w *GovernanceWorker) NewEvent(incoming events.Message) {
 	case *events.ImageFetchMessage:
 		msg, _ := incoming.(*events.ImageFetchMessage)
 
-		switch msg.LaunchContext.(type) {
-		case *events.AgreementLaunchContext:
-			var reason uint
-			lc := msg.LaunchContext.(*events.AgreementLaunchContext)
-
-			// get reason code from different image fetch error
-			switch msg.Event().Id {
-			case events.IMAGE_FETCHED:
-				reason = 0
-			case events.IMAGE_DATA_ERROR:
-				reason = w.producerPH[lc.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_IMAGE_DATA_ERROR)
-			case events.IMAGE_FETCH_ERROR:
-				reason = w.producerPH[lc.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_IMAGE_FETCH_FAILURE)
-			case events.IMAGE_FETCH_AUTH_ERROR:
-				reason = w.producerPH[lc.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_IMAGE_FETCH_AUTH_FAILURE)
-			case events.IMAGE_SIG_VERIF_ERROR:
-				reason = w.producerPH[lc.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_IMAGE_SIG_VERIF_FAILURE)
-			default:
-				reason = w.producerPH[lc.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_IMAGE_FETCH_FAILURE)
-			}
-
-			if ags, err := persistence.FindEstablishedAgreements(w.db, lc.AgreementProtocol, []persistence.EAFilter{persistence.UnarchivedEAFilter(), persistence.IdEAFilter(lc.AgreementId)}); err != nil {
-				glog.Errorf(logString(fmt.Sprintf("unable to retrieve agreement %v from database, error %v", lc.AgreementId, err)))
-				eventlog.LogDatabaseEvent(w.db, persistence.SEVERITY_ERROR,
-					persistence.NewMessageMeta(EL_GOV_ERR_RETRIEVE_AG_FROM_DB, lc.AgreementId, err.Error()),
-					persistence.EC_DATABASE_ERROR)
-			} else if len(ags) != 1 {
-				glog.Warningf(logString(fmt.Sprintf("unable to retrieve single agreement %v from database.", lc.AgreementId)))
-			} else {
-				if reason == 0 {
-					eventlog.LogAgreementEvent(
-						w.db,
-						persistence.SEVERITY_INFO,
-						persistence.NewMessageMeta(EL_GOV_IMAGE_LOADED, ags[0].RunningWorkload.Org, ags[0].RunningWorkload.URL),
-						fmt.Sprintf(persistence.EC_IMAGE_LOADED),
-						ags[0])
-				} else {
-					var errDetails = "unknown error"
-					if msg.Error != nil {
-						errDetails = msg.Error.Error()
-					}
-					eventlog.LogAgreementEvent(
-						w.db,
-						persistence.SEVERITY_ERROR,
-						persistence.NewMessageMeta(EL_GOV_ERR_LOADING_IMG, ags[0].RunningWorkload.Org, ags[0].RunningWorkload.URL, errDetails),
-						persistence.EC_ERROR_IMAGE_LOADE,
-						ags[0])
-					cmd := w.NewCleanupExecutionCommand(lc.AgreementProtocol, lc.AgreementId, reason, nil)
-					w.Commands <- cmd
-				}
-			}
-		case *events.ContainerLaunchContext:
-			lc := msg.LaunchContext.(*events.ContainerLaunchContext)
-			serviceInfo := lc.GetServicePathElement()
-			if msg.Event().Id == events.IMAGE_FETCHED {
-				eventlog.LogServiceEvent2(
-					w.db,
-					persistence.SEVERITY_INFO,
-					persistence.NewMessageMeta(EL_GOV_IMAGE_LOADED_FOR_SVC, serviceInfo.Org, serviceInfo.URL),
-					persistence.EC_IMAGE_LOADED,
-					"", serviceInfo.URL, "", serviceInfo.Version, "", lc.AgreementIds)
-			} else {
-				eventlog.LogServiceEvent2(
-					w.db,
-					persistence.SEVERITY_ERROR,
-					persistence.NewMessageMeta(EL_GOV_ERR_LOADING_IMG_FOR_SVC, serviceInfo.Org, serviceInfo.URL),
-					persistence.EC_ERROR_IMAGE_LOADE,
-					"", serviceInfo.URL, "", serviceInfo.Version, "", lc.AgreementIds)
-				cmd := w.NewUpdateMicroserviceCommand(lc.Name, false, microservice.MS_IMAGE_FETCH_FAILED, microservice.DecodeReasonCode(microservice.MS_IMAGE_FETCH_FAILED))
-				w.Commands <- cmd
-			}
+		// the image auths may have been rotated in the exchange, the pull is retried with the current ones if they changed
+		if msg.Event().Id == events.IMAGE_FETCH_AUTH_ERROR && w.Config.Edge.TrustDockerAuthFromOrg {
+			w.Commands <- w.NewImageAuthErrorCommand(msg)
+		} else {
+			w.handleImageFetch(msg)
 		}
 
 	case *events.InitAgreementCancelationMessage:
@@ -345,6 +283,13 @@ func (w *GovernanceWorker) NewEvent(incoming events.Message) {
 			w.Commands <- w.NewReportDeviceStatusCommand(msg.ServiceConfigState)
 		}
 
+	case *events.ServicePauseMessage:
+		msg, _ := incoming.(*events.ServicePauseMessage)
+		switch msg.Event().Id {
+		case events.SERVICE_PAUSED, events.SERVICE_RESUMED:
+			w.Commands <- w.NewServicePauseCommand(msg.Url, msg.Org, msg.Event().Id == events.SERVICE_PAUSED)
+		}
+
 	case *events.UpdatePolicyMessage:
 		msg, _ := incoming.(*events.UpdatePolicyMessage)
 		switch msg.Event().Id {
@@ -397,6 +342,89 @@ func (w *GovernanceWorker) NewEvent(incoming events.Message) {
 	return
 }
 
+// Records the result of the image fetch of an agreement or a service, and cleans up after a failure.
+func (w *GovernanceWorker) handleImageFetch(msg *events.ImageFetchMessage) {
+	switch msg.LaunchContext.(type) {
+	case *events.AgreementLaunchContext:
+		var reason uint
+		lc := msg.LaunchContext.(*events.AgreementLaunchContext)
+
+		// get reason code from different image fetch error
+		switch msg.Event().Id {
+		case events.IMAGE_FETCHED:
+			reason = 0
+		case events.IMAGE_DATA_ERROR:
+			reason = w.producerPH[lc.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_IMAGE_DATA_ERROR)
+		case events.IMAGE_FETCH_ERROR:
+			reason = w.producerPH[lc.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_IMAGE_FETCH_FAILURE)
+		case events.IMAGE_FETCH_AUTH_ERROR:
+			reason = w.producerPH[lc.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_IMAGE_FETCH_AUTH_FAILURE)
+		case events.IMAGE_SIG_VERIF_ERROR:
+			reason = w.producerPH[lc.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_IMAGE_SIG_VERIF_FAILURE)
+		default:
+			reason = w.producerPH[lc.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_IMAGE_FETCH_FAILURE)
+		}
+
+		if ags, err := persistence.FindEstablishedAgreements(w.db, lc.AgreementProtocol, []persistence.EAFilter{persistence.UnarchivedEAFilter(), persistence.IdEAFilter(lc.AgreementId)}); err != nil {
+			glog.Errorf(logString(fmt.Sprintf("unable to retrieve agreement %v from database, error %v", lc.AgreementId, err)))
+			eventlog.LogDatabaseEvent(w.db, persistence.SEVERITY_ERROR,
+				persistence.NewMessageMeta(EL_GOV_ERR_RETRIEVE_AG_FROM_DB, lc.AgreementId, err.Error()),
+				persistence.EC_DATABASE_ERROR)
+		} else if len(ags) != 1 {
+			glog.Warningf(logString(fmt.Sprintf("unable to retrieve single agreement %v from database.", lc.AgreementId)))
+		} else {
+			if reason == 0 {
+				eventlog.LogAgreementEvent(
+					w.db,
+					persistence.SEVERITY_INFO,
+					imageLoadedMessageMeta(EL_GOV_IMAGE_LOADED, EL_GOV_IMAGE_LOADED_DELTA, EL_GOV_IMAGE_LOADED_DELTA_SAVED, ags[0].RunningWorkload.Org, ags[0].RunningWorkload.URL, msg.PullStats),
+					fmt.Sprintf(persistence.EC_IMAGE_LOADED),
+					ags[0])
+				wl := ags[0].RunningWorkload
+				w.cacheImageDigests(wl.Org, wl.URL, wl.Version, wl.Arch, msg.DeploymentDescription)
+			} else {
+				var errDetails = "unknown error"
+				if msg.Error != nil {
+					errDetails = msg.Error.Error()
+				}
+				eventlog.LogAgreementEvent(
+					w.db,
+					persistence.SEVERITY_ERROR,
+					persistence.NewMessageMeta(EL_GOV_ERR_LOADING_IMG, ags[0].RunningWorkload.Org, ags[0].RunningWorkload.URL, errDetails),
+					persistence.EC_ERROR_IMAGE_LOADE,
+					ags[0])
+				cmd := w.NewCleanupExecutionCommand(lc.AgreementProtocol, lc.AgreementId, reason, nil)
+				w.Commands <- cmd
+			}
+		}
+	case *events.ContainerLaunchContext:
+		lc := msg.LaunchContext.(*events.ContainerLaunchContext)
+		serviceInfo := lc.GetServicePathElement()
+		if msg.Event().Id == events.IMAGE_FETCHED {
+			eventlog.LogServiceEvent2(
+				w.db,
+				persistence.SEVERITY_INFO,
+				imageLoadedMessageMeta(EL_GOV_IMAGE_LOADED_FOR_SVC, EL_GOV_IMAGE_LOADED_FOR_SVC_DELTA, EL_GOV_IMAGE_LOADED_FOR_SVC_DELTA_SAVED, serviceInfo.Org, serviceInfo.URL, msg.PullStats),
+				persistence.EC_IMAGE_LOADED,
+				"", serviceInfo.URL, "", serviceInfo.Version, "", lc.AgreementIds)
+			if msi, err := persistence.FindMicroserviceInstanceWithKey(w.db, lc.Name); err == nil && msi != nil {
+				if msdef, err := persistence.FindMicroserviceDefWithKey(w.db, msi.MicroserviceDefId); err == nil && msdef != nil {
+					w.cacheImageDigests(msdef.Org, msdef.SpecRef, msdef.Version, msdef.Arch, msg.DeploymentDescription)
+				}
+			}
+		} else {
+			eventlog.LogServiceEvent2(
+				w.db,
+				persistence.SEVERITY_ERROR,
+				persistence.NewMessageMeta(EL_GOV_ERR_LOADING_IMG_FOR_SVC, serviceInfo.Org, serviceInfo.URL),
+				persistence.EC_ERROR_IMAGE_LOADE,
+				"", serviceInfo.URL, "", serviceInfo.Version, "", lc.AgreementIds)
+			cmd := w.NewUpdateMicroserviceCommand(lc.Name, false, microservice.MS_IMAGE_FETCH_FAILED, microservice.DecodeReasonCode(microservice.MS_IMAGE_FETCH_FAILED))
+			w.Commands <- cmd
+		}
+	}
+}
+
 // Make sure that every agreement we have is in a valid state or proceeding to valid states in a timely fashion. If not,
 // cancel the agreement and allow the agbots to re-make them if necessary.
 func (w *GovernanceWorker) governAgreements() {
@@ -416,6 +444,17 @@ func (w *GovernanceWorker) governAgreements() {
 		verifyAgreements := false
 		// If there are agreements in the database then we will assume that the device is already registered
 		for _, ag := range establishedAgreements {
+			// Cancel the agreement once the grace period of its preemption by a higher priority deployment policy is over.
+			if ag.AgreementPreemptTime != 0 && ag.AgreementPreemptTime <= uint64(time.Now().Unix()) {
+				glog.V(3).Infof(logString(fmt.Sprintf("terminating agreement %v because it is preempted by a higher priority deployment policy.", ag.CurrentAgreementId)))
+				reason := w.producerPH[ag.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_PREEMPTED)
+				eventlog.LogAgreementEvent(w.db, persistence.SEVERITY_INFO,
+					persistence.NewMessageMeta(EL_GOV_START_TERM_AG_WITH_REASON, ag.RunningWorkload.URL, w.producerPH[ag.AgreementProtocol].GetTerminationReason(reason)),
+					persistence.EC_CANCEL_AGREEMENT_PREEMPTED, ag)
+				w.cancelGovernedAgreement(&ag, reason)
+				continue
+			}
+
 			bcType, bcName, bcOrg := w.producerPH[ag.AgreementProtocol].GetKnownBlockchain(&ag)
 			protocolHandler := w.producerPH[ag.AgreementProtocol].AgreementProtocolHandler(bcType, bcName, bcOrg)
 			if ag.AgreementFinalizedTime == 0 { // TODO: might need to change this to be a protocol specific check
@@ -572,25 +611,26 @@ func (w *GovernanceWorker) governContainers() int {
 	// go govern
 	glog.V(4).Infof(logString(fmt.Sprintf("governing containers")))
 
-	// Create a new filter for unfinalized agreements
-	runningFilter := func() persistence.EAFilter {
-		return func(a persistence.EstablishedAgreement) bool {
-			return a.AgreementExecutionStartTime != 0 && a.AgreementTerminatedTime == 0 && a.CounterPartyAddress != ""
-		}
-	}
-
-	if establishedAgreements, err := persistence.FindEstablishedAgreementsAllProtocols(w.db, policy.AllAgreementProtocols(), []persistence.EAFilter{persistence.UnarchivedEAFilter(), runningFilter()}); err != nil {
+	if establishedAgreements, err := persistence.FindEstablishedAgreementsAllProtocols(w.db, policy.AllAgreementProtocols(), []persistence.EAFilter{persistence.UnarchivedEAFilter(), runningAgreementFilter()}); err != nil {
 		glog.Errorf(logString(fmt.Sprintf("Unable to retrieve running agreements from database, error: %v", err)))
 	} else {
 		for _, ag := range establishedAgreements {
 
-			// Make sure containers are still running.
-			glog.V(3).Infof(logString(fmt.Sprintf("fire event to ensure containers are still up for agreement %v.", ag.CurrentAgreementId)))
-
 			clusterNamespace, err := w.GetRequestedClusterNamespaceFromAg(&ag)
 			if err != nil {
 				glog.Errorf(logString(fmt.Sprintf("Failed to get cluster namespace from agreeent %v. %v", ag.CurrentAgreementId, err)))
 			}
+
+			// The containers of a paused service are kept stopped, in case they were started again outside of the agent.
+			if persistence.IsServicePaused(w.db, ag.RunningWorkload.Org, ag.RunningWorkload.URL) {
+				glog.V(3).Infof(logString(fmt.Sprintf("fire event to ensure containers are still paused for agreement %v.", ag.CurrentAgreementId)))
+				w.Messages() <- events.NewGovernanceMaintenanceMessage(events.WORKLOAD_PAUSE, ag.AgreementProtocol, ag.CurrentAgreementId, clusterNamespace, ag.GetDeploymentConfig())
+				continue
+			}
+
+			// Make sure containers are still running.
+			glog.V(3).Infof(logString(fmt.Sprintf("fire event to ensure containers are still up for agreement %v.", ag.CurrentAgreementId)))
+
 			// current contract, ensure workloads still running
 			w.Messages() <- events.NewGovernanceMaintenanceMessage(events.CONTAINER_MAINTAIN, ag.AgreementProtocol, ag.CurrentAgreementId, clusterNamespace, ag.GetDeploymentConfig())
 
@@ -599,6 +639,13 @@ func (w *GovernanceWorker) governContainers() int {
 	return 0
 }
 
+// A filter for the agreements whose workload is started and not terminated.
+func runningAgreementFilter() persistence.EAFilter {
+	return func(a persistence.EstablishedAgreement) bool {
+		return a.AgreementExecutionStartTime != 0 && a.AgreementTerminatedTime == 0 && a.CounterPartyAddress != ""
+	}
+}
+
 func (w *GovernanceWorker) reportBlockchains() int {
 
 	// go govern
@@ -789,6 +836,16 @@ func (w *GovernanceWorker) Initialize() bool {
 	// Fire up the microservice governor
 	w.DispatchSubworker(MICROSERVICE_GOVERNOR, w.governMicroservices, 60, false)
 
+	// refresh the image auths of the running services before the registry tokens expire
+	if interval := w.BaseWorker.Manager.Config.GetImageAuthRefreshInterval(); interval > 0 {
+		w.DispatchSubworker(IMAGEAUTHS, w.refreshImageAuths, interval, false)
+	}
+
+	// collect the resource usage of the service containers
+	if interval := w.BaseWorker.Manager.Config.GetServiceStatsInterval(); interval > 0 {
+		w.DispatchSubworker(SERVICESTATS, w.collectServiceStats, interval, false)
+	}
+
 	// for the policy case update the exchange with the latest registeredServices
 	if w.devicePattern == "" {
 		w.UpdateRegisteredServicesWithAgreement()
@@ -1368,6 +1425,28 @@ func (w *GovernanceWorker) CommandHandler(command worker.Command) bool {
 				}
 			}
 		}
+	case *RetryMicroserviceCommand:
+		cmd, _ := command.(*RetryMicroserviceCommand)
+
+		glog.V(5).Infof(logString(fmt.Sprintf("Retry service if the backoff is over. %v", cmd)))
+
+		if !w.IsWorkerShuttingDown() {
+			if uint64(time.Now().Unix()) < cmd.RetryTime {
+				w.AddDeferredCommand(cmd)
+			} else {
+				w.retryMicroserviceAfterBackoff(cmd.MsDefKey, cmd.MsInstKey)
+			}
+		}
+
+	case *ImageAuthErrorCommand:
+		cmd, _ := command.(*ImageAuthErrorCommand)
+
+		glog.V(5).Infof(logString(fmt.Sprintf("Retry image fetch with the current image auths. %v", cmd)))
+
+		if w.IsWorkerShuttingDown() || !w.retryImageFetch(cmd.Msg) {
+			w.handleImageFetch(cmd.Msg)
+		}
+
 	case *UpgradeMicroserviceCommand:
 		cmd, _ := command.(*UpgradeMicroserviceCommand)
 
@@ -1418,6 +1497,12 @@ func (w *GovernanceWorker) CommandHandler(command worker.Command) bool {
 
 		w.handleServiceSuspended(cmd.ServiceConfigState)
 
+	case *ServicePauseCommand:
+		cmd, _ := command.(*ServicePauseCommand)
+		glog.V(5).Infof(logString(fmt.Sprintf("%v", cmd)))
+
+		w.handleServicePause(cmd.Url, cmd.Org, cmd.Pause)
+
 	case *UpdatePolicyCommand:
 		cmd, _ := command.(*UpdatePolicyCommand)
 		glog.V(5).Infof(logString(fmt.Sprintf("%v", cmd)))
@@ -1552,18 +1637,10 @@ func (w *GovernanceWorker) RecordReply(proposal abstractprotocol.Proposal, proto
 		img_auths := make([]events.ImageDockerAuth, 0)
 		if w.deviceType == persistence.DEVICE_TYPE_DEVICE {
 			if w.Config.Edge.TrustDockerAuthFromOrg {
-				if ias, err := exchange.GetHTTPServiceDockerAuthsHandler(w)(workload.WorkloadURL, workload.Org, workload.Version, workload.Arch); err != nil {
+				if ias, err := w.getImageDockerAuths(w, workload.WorkloadURL, workload.Org, workload.Version, workload.Arch); err != nil {
 					return errors.New(logString(fmt.Sprintf("received error querying exchange for service image auths: %v, error %v", workload, err)))
 				} else {
-					if ias != nil {
-						for _, iau_temp := range ias {
-							username := iau_temp.UserName
-							if username == "" {
-								username = "token"
-							}
-							img_auths = append(img_auths, events.ImageDockerAuth{Registry: iau_temp.Registry, UserName: username, Password: iau_temp.Token})
-						}
-					}
+					img_auths = ias
 				}
 			}
 		}
@@ -1589,7 +1666,7 @@ func (w *GovernanceWorker) RecordReply(proposal abstractprotocol.Proposal, proto
 		// get the metadata for the version we are running and then add in any unset default user inputs.
 		var serviceDef *exchange.ServiceDefinition
 		serviceId := ""
-		if _, sDef, allIDs, err := exchange.GetHTTPServiceResolverHandler(w)(workload.WorkloadURL, workload.Org, workload.Version, workload.Arch); err != nil {
+		if _, sDef, allIDs, err := exchange.GetCachingServiceResolverHandler(w, w.limitedRetryEC, w.db)(workload.WorkloadURL, workload.Org, workload.Version, workload.Arch); err != nil {
 			return fmt.Errorf(logString(fmt.Sprintf("Received error querying exchange for service metadata: %v/%v, error %v", workload.Org, workload.WorkloadURL, err)))
 		} else if sDef == nil {
 			return fmt.Errorf(logString(fmt.Sprintf("Cound not find service metadata for %v/%v.", workload.Org, workload.WorkloadURL)))
@@ -1632,10 +1709,12 @@ func (w *GovernanceWorker) RecordReply(proposal abstractprotocol.Proposal, proto
 
 		lc.EnvironmentAdditions = &envAdds
 
+		// Save the secrets bound to the services. On a cluster they are mounted into the operator from a kubernetes secret.
+		if err := w.processServiceSecrets(tcPolicy, proposal.AgreementId()); err != nil {
+			return err
+		}
+
 		if w.deviceType == persistence.DEVICE_TYPE_DEVICE {
-			if err := w.processServiceSecrets(tcPolicy, proposal.AgreementId()); err != nil {
-				return err
-			}
 			// Make a list of service dependencies for this workload. For sevices, it is just the top level dependencies.
 			deps := serviceDef.GetServiceDependencies()
 
@@ -1705,7 +1784,7 @@ func (w *GovernanceWorker) processDependencies(dependencyPath []persistence.Serv
 
 	for _, sDep := range *deps {
 
-		msdef, err := microservice.FindOrCreateMicroserviceDef(w.db, sDep.URL, sDep.Org, sDep.Version, sDep.Arch, false, w.devicePattern != "", exchange.GetHTTPServiceHandler(w))
+		msdef, err := microservice.FindOrCreateMicroserviceDef(w.db, sDep.URL, sDep.Org, sDep.Version, sDep.Arch, false, w.devicePattern != "", exchange.GetCachingServiceHandler(w, w.limitedRetryEC, w.db))
 		if err != nil {
 			return ms_specs, fmt.Errorf(logString(fmt.Sprintf("failed to get or create service definition for dependent service for agreement %v. %v", agreementId, err)))
 		}
@@ -1967,6 +2046,10 @@ func (w *GovernanceWorker) deleteProducerAgreement(url string, deviceId string,
 }
 
 func (w *GovernanceWorker) deleteMessage(msg *exchange.DeviceMessage) error {
+	if msg.MsgId == exchange.DIRECT_MSG_ID {
+		return nil
+	}
+
 	var resp interface{}
 	resp = new(exchange.PostDeviceResponse)
 	targetURL := w.GetExchangeURL() + "orgs/" + exchange.GetOrg(w.GetExchangeId()) + "/nodes/" + exchange.GetId(w.GetExchangeId()) + "/msgs/" + strconv.Itoa(msg.MsgId)
@@ -1986,6 +2069,11 @@ func (w *GovernanceWorker) deleteMessage(msg *exchange.DeviceMessage) error {
 }
 
 func (w *GovernanceWorker) messageInExchange(msgId int) (bool, error) {
+	// A message received on the stream to the agbot is not in the exchange.
+	if msgId == exchange.DIRECT_MSG_ID {
+		return true, nil
+	}
+
 	var resp interface{}
 	resp = new(exchange.GetDeviceMessageResponse)
 	targetURL := w.GetExchangeURL() + "orgs/" + exchange.GetOrg(w.GetExchangeId()) + "/nodes/" + exchange.GetId(w.GetExchangeId()) + "/msgs/" + strconv.Itoa(msgId)
@@ -2013,6 +2101,17 @@ var logString = func(v interface{}) string {
 	return fmt.Sprintf("GovernanceWorker: %v", v)
 }
 
+// Returns the event log message of an image load. When the layers of the images are known, the message tells how many
+// of them were downloaded, and how many bytes were saved when that is known too.
+func imageLoadedMessageMeta(loaded string, delta string, deltaSaved string, org string, url string, stats *events.ImagePullStats) *persistence.MessageMeta {
+	if stats == nil || stats.Layers == 0 {
+		return persistence.NewMessageMeta(loaded, org, url)
+	} else if stats.SavedBytes == 0 {
+		return persistence.NewMessageMeta(delta, org, url, stats.DownloadedLayers(), stats.Layers, stats.DownloadedBytes)
+	}
+	return persistence.NewMessageMeta(deltaSaved, org, url, stats.DownloadedLayers(), stats.Layers, stats.DownloadedBytes, stats.SavedBytes)
+}
+
 // go through all the protocols and find the agreements with given agreement ids from the db
 func (w *GovernanceWorker) FindEstablishedAgreementsWithIds(agreementIds []string) ([]persistence.EstablishedAgreement, error) {
 
@@ -0,0 +1,124 @@
+package governance
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/eventlog"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/persistence"
+	"reflect"
+)
+
+// Returns the docker auths of the images of a service from the exchange, in the form used to pull the images. The
+// exchange context tells how long to retry when the exchange cannot be reached.
+func (w *GovernanceWorker) getImageDockerAuths(ec exchange.ExchangeContext, url string, org string, version string, arch string) ([]events.ImageDockerAuth, error) {
+	img_auths := make([]events.ImageDockerAuth, 0)
+	if ias, err := exchange.GetHTTPServiceDockerAuthsHandler(ec)(url, org, version, arch); err != nil {
+		return nil, err
+	} else {
+		for _, iau_temp := range ias {
+			username := iau_temp.UserName
+			if username == "" {
+				username = "token"
+			}
+			img_auths = append(img_auths, events.ImageDockerAuth{Registry: iau_temp.Registry, UserName: username, Password: iau_temp.Token})
+		}
+	}
+	return img_auths, nil
+}
+
+// Gets the current docker auths of a service from the exchange after an image pull failed with an auth error, bypassing
+// the cached auths in case the exchange change that updated them was missed. Returns the new auths when they differ
+// from the ones the pull was tried with, nil when the pull should not be retried.
+func (w *GovernanceWorker) refreshImageDockerAuths(url string, org string, version string, arch string, failedAuths []events.ImageDockerAuth) []events.ImageDockerAuth {
+	if !w.Config.Edge.TrustDockerAuthFromOrg {
+		return nil
+	}
+
+	exchange.DeleteCache(exchange.SVC_DOCKAUTH_TYPE_CACHE)
+	img_auths, err := w.getImageDockerAuths(w, url, org, version, arch)
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("received error querying exchange for service image auths: %v/%v version %v, error %v", org, url, version, err)))
+		return nil
+	} else if len(img_auths) == 0 || reflect.DeepEqual(img_auths, failedAuths) {
+		return nil
+	}
+
+	glog.V(3).Infof(logString(fmt.Sprintf("image auths of service %v/%v version %v were updated in the exchange, retrying the image pull.", org, url, version)))
+	return img_auths
+}
+
+// Publishes the launch context of an image fetch that failed with an auth error again, with the current docker auths of
+// the service, if they were updated in the exchange. Returns false when the fetch is not retried.
+func (w *GovernanceWorker) retryImageFetch(msg *events.ImageFetchMessage) bool {
+	switch msg.LaunchContext.(type) {
+	case *events.AgreementLaunchContext:
+		lc := msg.LaunchContext.(*events.AgreementLaunchContext)
+
+		ags, err := persistence.FindEstablishedAgreements(w.db, lc.AgreementProtocol, []persistence.EAFilter{persistence.UnarchivedEAFilter(), persistence.IdEAFilter(lc.AgreementId)})
+		if err != nil || len(ags) != 1 {
+			return false
+		}
+		wl := ags[0].RunningWorkload
+		if img_auths := w.refreshImageDockerAuths(wl.URL, wl.Org, wl.Version, wl.Arch, lc.Configure.ImageDockerAuths); img_auths != nil {
+			eventlog.LogAgreementEvent(
+				w.db,
+				persistence.SEVERITY_INFO,
+				persistence.NewMessageMeta(EL_GOV_IMAGE_AUTH_REFRESHED, wl.Org, wl.URL),
+				persistence.EC_IMAGE_AUTH_REFRESHED,
+				ags[0])
+			lc.Configure.ImageDockerAuths = img_auths
+			w.Messages() <- events.NewAgreementMessage(events.AGREEMENT_REACHED, lc)
+			return true
+		}
+
+	case *events.ContainerLaunchContext:
+		lc := msg.LaunchContext.(*events.ContainerLaunchContext)
+
+		msi, err := persistence.FindMicroserviceInstanceWithKey(w.db, lc.Name)
+		if err != nil || msi == nil {
+			return false
+		}
+		msdef, err := persistence.FindMicroserviceDefWithKey(w.db, msi.MicroserviceDefId)
+		if err != nil || msdef == nil {
+			return false
+		}
+		if img_auths := w.refreshImageDockerAuths(msdef.SpecRef, msdef.Org, msdef.Version, msdef.Arch, lc.Configure.ImageDockerAuths); img_auths != nil {
+			eventlog.LogServiceEvent2(
+				w.db,
+				persistence.SEVERITY_INFO,
+				persistence.NewMessageMeta(EL_GOV_IMAGE_AUTH_REFRESHED, msdef.Org, msdef.SpecRef),
+				persistence.EC_IMAGE_AUTH_REFRESHED,
+				"", msdef.SpecRef, msdef.Org, msdef.Version, msdef.Arch, lc.AgreementIds)
+			lc.Configure.ImageDockerAuths = img_auths
+			w.Messages() <- events.NewLoadContainerMessage(events.LOAD_CONTAINER, lc)
+			return true
+		}
+	}
+	return false
+}
+
+// Drops the cached docker auths of the services and gets the current ones of the running services from the exchange,
+// so that registry tokens rotated in the exchange are used by the next image pull, e.g. for a service upgrade, and
+// the services whose auths can no longer be read are reported before their images have to be pulled again.
+func (w *GovernanceWorker) refreshImageAuths() int {
+	interval := w.Config.GetImageAuthRefreshInterval()
+	if w.deviceType != persistence.DEVICE_TYPE_DEVICE || !w.Config.Edge.TrustDockerAuthFromOrg {
+		return interval
+	}
+
+	exchange.DeleteCache(exchange.SVC_DOCKAUTH_TYPE_CACHE)
+
+	msdefs, err := persistence.FindMicroserviceDefs(w.db, []persistence.MSFilter{persistence.UnarchivedMSFilter()})
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("Error retrieving all service definitions from database, error: %v", err)))
+		return interval
+	}
+	for _, msdef := range msdefs {
+		if _, err := w.getImageDockerAuths(w.limitedRetryEC, msdef.SpecRef, msdef.Org, msdef.Version, msdef.Arch); err != nil {
+			glog.Warningf(logString(fmt.Sprintf("unable to refresh the image auths of service %v/%v version %v, error %v", msdef.Org, msdef.SpecRef, msdef.Version, err)))
+		}
+	}
+	return interval
+}
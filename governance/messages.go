@@ -31,10 +31,15 @@ const (
 	EL_GOV_ERR_UPDATE_REGSVCS_IN_EXCH  = "Error updating registeredServices for node %v in the Exchange: %v"
 
 	// image
-	EL_GOV_IMAGE_LOADED            = "Image loaded for %v/%v."
-	EL_GOV_IMAGE_LOADED_FOR_SVC    = "Image loaded for service %v/%v."
-	EL_GOV_ERR_LOADING_IMG         = "Error loading image for %v/%v. Reason: %v"
-	EL_GOV_ERR_LOADING_IMG_FOR_SVC = "Error loading image for service %v/%v."
+	EL_GOV_IMAGE_LOADED                     = "Image loaded for %v/%v."
+	EL_GOV_IMAGE_LOADED_FOR_SVC             = "Image loaded for service %v/%v."
+	EL_GOV_IMAGE_LOADED_DELTA               = "Image loaded for %v/%v, downloaded %v of %v layers (%v bytes)."
+	EL_GOV_IMAGE_LOADED_DELTA_SAVED         = "Image loaded for %v/%v, downloaded %v of %v layers (%v bytes), saved %v bytes."
+	EL_GOV_IMAGE_LOADED_FOR_SVC_DELTA       = "Image loaded for service %v/%v, downloaded %v of %v layers (%v bytes)."
+	EL_GOV_IMAGE_LOADED_FOR_SVC_DELTA_SAVED = "Image loaded for service %v/%v, downloaded %v of %v layers (%v bytes), saved %v bytes."
+	EL_GOV_ERR_LOADING_IMG                  = "Error loading image for %v/%v. Reason: %v"
+	EL_GOV_ERR_LOADING_IMG_FOR_SVC          = "Error loading image for service %v/%v."
+	EL_GOV_IMAGE_AUTH_REFRESHED             = "Image registry credentials for %v/%v were updated in the Exchange, retrying the image pull."
 
 	// agreement
 	EL_GOV_START_TERM_AG_WITH_REASON    = "Start terminating agreement for %v. Termination reason: %v"
@@ -145,8 +150,13 @@ func MarkI18nMessages() {
 	// image
 	msgPrinter.Sprintf(EL_GOV_IMAGE_LOADED)
 	msgPrinter.Sprintf(EL_GOV_IMAGE_LOADED_FOR_SVC)
+	msgPrinter.Sprintf(EL_GOV_IMAGE_LOADED_DELTA)
+	msgPrinter.Sprintf(EL_GOV_IMAGE_LOADED_DELTA_SAVED)
+	msgPrinter.Sprintf(EL_GOV_IMAGE_LOADED_FOR_SVC_DELTA)
+	msgPrinter.Sprintf(EL_GOV_IMAGE_LOADED_FOR_SVC_DELTA_SAVED)
 	msgPrinter.Sprintf(EL_GOV_ERR_LOADING_IMG)
 	msgPrinter.Sprintf(EL_GOV_ERR_LOADING_IMG_FOR_SVC)
+	msgPrinter.Sprintf(EL_GOV_IMAGE_AUTH_REFRESHED)
 
 	// agreement
 	msgPrinter.Sprintf(EL_GOV_START_TERM_AG_WITH_REASON)
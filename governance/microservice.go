@@ -5,11 +5,13 @@ import (
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/api"
 	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/containermessage"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/metrics"
 	"github.com/open-horizon/anax/microservice"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
@@ -31,6 +33,14 @@ func (w *GovernanceWorker) governMicroservices() int {
 		for _, msi := range ms_instances {
 			// only check the ones that have containers started already and not in the middle of cleanup
 			if hasWL, _ := msi.HasWorkload(w.db); hasWL && msi.ExecutionStartTime != 0 && msi.CleanupStartTime == 0 {
+				// the containers of a paused service are kept stopped
+				if persistence.IsServicePaused(w.db, msi.Org, msi.SpecRef) {
+					if !msi.IsTopLevelService() {
+						w.Messages() <- events.NewMicroserviceMaintenanceMessage(events.WORKLOAD_PAUSE, msi.GetKey())
+					}
+					continue
+				}
+
 				glog.V(3).Infof(logString(fmt.Sprintf("fire event to ensure service containers are still up for service instance %v.", msi.GetKey())))
 
 				// ensure containers are still running
@@ -43,6 +53,21 @@ func (w *GovernanceWorker) governMicroservices() int {
 
 // Since we changed to saving the signing key with the agreement id, we need to make sure we delete the key when done with it
 // to avoid filling up the filesystem
+// Returns the key files of the signing keys of the service org that were saved from the exchange, when the service is in
+// another org than the node. Otherwise it returns all the key files.
+func (w *GovernanceWorker) crossOrgPemFiles(svcOrg string, signingKeys []string, pemFiles []string) []string {
+	if svcOrg == exchange.GetOrg(w.GetExchangeId()) || len(signingKeys) == 0 {
+		return pemFiles
+	}
+
+	orgPemFiles := make([]string, 0, len(signingKeys))
+	for _, key := range signingKeys {
+		orgPemFiles = append(orgPemFiles, fmt.Sprintf("%v/%v", w.Config.UserPublicKeyPath(), key))
+	}
+	glog.V(3).Infof(logString(fmt.Sprintf("verifying the service of org %v with the signing keys of its org %v", svcOrg, orgPemFiles)))
+	return orgPemFiles
+}
+
 func (w *GovernanceWorker) cleanupSigningKeys(keys []string) {
 
 	errHandler := func(keyname string) api.ErrorHandler {
@@ -132,9 +157,10 @@ func (w *GovernanceWorker) StartMicroservice(ms_key string, agreementId string,
 			ms_workload.WorkloadPassword = ""
 			ms_workload.DeploymentUserInfo = ""
 
-			// get microservice/service keys and save it to the user keys.
+			// get microservice/service keys and save it to the user keys. The keys are taken from the local service cache when
+			// the exchange cannot be reached, so that the service can be verified and restarted during an exchange outage.
 			if w.Config.Edge.TrustCertUpdatesFromOrg {
-				key_map, err := exchange.GetHTTPObjectSigningKeysHandler(w)(exchange.SERVICE, msdef.SpecRef, msdef.Org, msdef.Version, msdef.Arch)
+				key_map, err := exchange.GetCachingObjectSigningKeysHandler(w, w.limitedRetryEC, w.db)(exchange.SERVICE, msdef.SpecRef, msdef.Org, msdef.Version, msdef.Arch)
 				if err != nil {
 					return nil, fmt.Errorf(logString(fmt.Sprintf("received error getting signing keys from the exchange: %v/%v %v %v. %v", msdef.Org, msdef.SpecRef, msdef.Version, msdef.Arch, err)))
 				}
@@ -172,11 +198,12 @@ func (w *GovernanceWorker) StartMicroservice(ms_key string, agreementId string,
 				}
 			}
 
-			// Verify the deployment signature
+			// Verify the deployment signature. A service of another org is only verified with the signing keys of its own org
+			// when they are in the exchange, so that the keys trusted for the node's org cannot vouch for it.
 			if pemFiles, err := w.Config.Collaborators.KeyFileNamesFetcher.GetKeyFileNames(w.Config.Edge.PublicKeyPath, w.Config.UserPublicKeyPath()); err != nil {
 				w.cleanupSigningKeys(signingKeys)
 				return nil, fmt.Errorf(logString(fmt.Sprintf("received error getting pem key files: %v", err)))
-			} else if err := ms_workload.HasValidSignature(pemFiles); err != nil {
+			} else if err := ms_workload.HasValidSignature(w.crossOrgPemFiles(msdef.Org, signingKeys, pemFiles)); err != nil {
 				w.cleanupSigningKeys(signingKeys)
 				return nil, fmt.Errorf(logString(fmt.Sprintf("service container has invalid deployment signature %v for %v", ms_workload.DeploymentSignature, ms_workload.Deployment)))
 			}
@@ -189,7 +216,7 @@ func (w *GovernanceWorker) StartMicroservice(ms_key string, agreementId string,
 			// be greater than the dependency version.
 			ms_specs := []events.MicroserviceSpec{}
 			for _, rs := range msdef.RequiredServices {
-				msdef_dep, err := microservice.FindOrCreateMicroserviceDef(w.db, rs.URL, rs.Org, rs.Version, rs.Arch, false, w.devicePattern != "", exchange.GetHTTPServiceHandler(w))
+				msdef_dep, err := microservice.FindOrCreateMicroserviceDef(w.db, rs.URL, rs.Org, rs.Version, rs.Arch, false, w.devicePattern != "", exchange.GetCachingServiceHandler(w, w.limitedRetryEC, w.db))
 				if err != nil {
 					return nil, fmt.Errorf(logString(fmt.Sprintf("failed to get or create service definition for for %v/%v: %v", rs.Org, rs.URL, err)))
 				} else {
@@ -211,20 +238,13 @@ func (w *GovernanceWorker) StartMicroservice(ms_key string, agreementId string,
 			}
 
 			// get the image auth for service (we have to try even for microservice because we do not know if this is ms or svc.)
+			// The auths are not cached, the images are pulled without them when the exchange cannot be reached.
 			img_auths := make([]events.ImageDockerAuth, 0)
 			if w.Config.Edge.TrustDockerAuthFromOrg {
-				if ias, err := exchange.GetHTTPServiceDockerAuthsHandler(w)(msdef.SpecRef, msdef.Org, msdef.Version, msdef.Arch); err != nil {
+				if ias, err := w.getImageDockerAuths(w.limitedRetryEC, msdef.SpecRef, msdef.Org, msdef.Version, msdef.Arch); err != nil {
 					glog.V(5).Infof(logString(fmt.Sprintf("received error querying exchange for service image auths: %v/%v version %v, error %v", msdef.Org, msdef.SpecRef, msdef.Version, err)))
 				} else {
-					if ias != nil {
-						for _, iau_temp := range ias {
-							username := iau_temp.UserName
-							if username == "" {
-								username = "token"
-							}
-							img_auths = append(img_auths, events.ImageDockerAuth{Registry: iau_temp.Registry, UserName: username, Password: iau_temp.Token})
-						}
-					}
+					img_auths = ias
 				}
 			}
 
@@ -349,8 +369,12 @@ func (w *GovernanceWorker) CleanupMicroservice(spec_ref string, version string,
 				glog.Errorf(logString(fmt.Sprintf("Failed to get cluster namespace from agreeent %v. %v", ag.CurrentAgreementId, err)))
 			}
 
-			// send the event to the container so that the workloads can be deleted
-			w.Messages() <- events.NewGovernanceWorkloadCancelationMessage(events.AGREEMENT_ENDED, events.AG_TERMINATED, ag.AgreementProtocol, ag.CurrentAgreementId, clusterNamespace, ag.GetDeploymentConfig())
+			// send the event to the container so that the workloads can be deleted, and checkpointed if the service is upgraded
+			cause := events.AG_TERMINATED
+			if ms_reason_code == microservice.MS_DELETED_BY_UPGRADE_PROCESS || ms_reason_code == microservice.MS_DELETED_BY_DOWNGRADE_PROCESS {
+				cause = events.AG_UPGRADED
+			}
+			w.Messages() <- events.NewGovernanceWorkloadCancelationMessage(events.AGREEMENT_ENDED, cause, ag.AgreementProtocol, ag.CurrentAgreementId, clusterNamespace, ag.GetDeploymentConfig())
 
 			var ag_reason_code uint
 			switch ms_reason_code {
@@ -409,6 +433,11 @@ func (w *GovernanceWorker) UpgradeMicroservice(msdef *persistence.MicroserviceDe
 		return fmt.Errorf(logString(fmt.Sprintf("Failed to update the UpgradeStartTime for service def %v/%v version %v id %v. %v", new_msdef.Org, new_msdef.SpecRef, new_msdef.Version, new_msdef.Id, err)))
 	}
 
+	// the old version is no longer needed to restart the service when the exchange cannot be reached
+	if err := persistence.DeleteCachedService(w.db, msdef.Org, msdef.SpecRef, msdef.Version, msdef.Arch); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("Failed to delete service %v/%v version %v from the local service cache. %v", msdef.Org, msdef.SpecRef, msdef.Version, err)))
+	}
+
 	// clean up old microservice
 	var eClearError error
 	var ms_insts []persistence.MicroserviceInstance
@@ -491,6 +520,7 @@ func (w *GovernanceWorker) UpgradeMicroservice(msdef *persistence.MicroserviceDe
 func (w *GovernanceWorker) RetryMicroservice(msi *persistence.MicroserviceInstance) error {
 	inst_key := msi.GetKey()
 	glog.V(5).Infof(logString(fmt.Sprintf("RetryMicroservice will restart all the containers for %v. Retry count: %v.", inst_key, msi.CurrentRetryCount+1)))
+	metrics.ContainerRestart(cutil.FormOrgSpecUrl(msi.SpecRef, msi.Org))
 
 	// increment the retry count
 	if _, err := persistence.UpdateMSInstanceCurrentRetryCount(w.db, inst_key, msi.CurrentRetryCount+1); err != nil {
@@ -797,6 +827,17 @@ func (w *GovernanceWorker) handleMicroserviceExecFailure(msdef *persistence.Micr
 	}
 
 	if need_retry {
+		// wait before the retry so that a service that keeps failing does not restart in a tight loop
+		if backoff := w.getMicroserviceRetryBackoff(msdef, msi.CurrentRetryCount); backoff > 0 {
+			if _, err := persistence.MSInstanceRetryPending(w.db, msinst_key); err != nil {
+				glog.Errorf(logString(fmt.Sprintf("error marking the retry pending for service instance %v in db. %v", msinst_key, err)))
+				return
+			}
+			glog.V(3).Infof(logString(fmt.Sprintf("retrying failed service instance %v in %v seconds", msinst_key, backoff)))
+			w.AddDeferredCommand(w.NewRetryMicroserviceCommand(msdef.Id, msinst_key, uint64(time.Now().Unix())+uint64(backoff)))
+			return
+		}
+
 		current_retry := msi.CurrentRetryCount + 1
 		// start the retry
 		eventlog.LogServiceEvent2(w.db, persistence.SEVERITY_INFO,
@@ -839,6 +880,72 @@ func (w *GovernanceWorker) handleMicroserviceExecFailure(msdef *persistence.Micr
 	}
 }
 
+// Returns the number of seconds to wait before retrying a failed service instance that has already been tried the given
+// number of times in the current retry cycle. The delay doubles with each retry, up to the backoff_max_s of the restart
+// policy of the service or the configured maximum.
+func (w *GovernanceWorker) getMicroserviceRetryBackoff(msdef *persistence.MicroserviceDefinition, tries uint) int {
+	backoffMax := w.Config.GetServiceRetryBackoffMax()
+	if deploymentDesc, err := containermessage.GetNativeDeployment(msdef.GetDeploymentString()); err == nil {
+		if m := deploymentDesc.RestartBackoffMax(); m != 0 {
+			backoffMax = m
+		}
+	}
+	return retryBackoff(tries, w.Config.GetServiceRetryBackoff(), backoffMax)
+}
+
+// Returns the delay before the next retry when there have been the given number of tries, the first try being the
+// original execution. The delay starts at backoff and doubles with each retry, but never exceeds backoffMax.
+func retryBackoff(tries uint, backoff int, backoffMax int) int {
+	if backoff > backoffMax {
+		return backoffMax
+	}
+	delay := backoff
+	for i := uint(1); i < tries && delay < backoffMax; i++ {
+		delay *= 2
+	}
+	if delay > backoffMax {
+		return backoffMax
+	}
+	return delay
+}
+
+// Retry a failed service instance whose retry was delayed by the backoff.
+func (w *GovernanceWorker) retryMicroserviceAfterBackoff(msdef_key string, msinst_key string) {
+	msdef, err := persistence.FindMicroserviceDefWithKey(w.db, msdef_key)
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("error getting service definition %v from db. %v", msdef_key, err)))
+		return
+	} else if msdef == nil {
+		glog.Errorf(logString(fmt.Sprintf("no service definition record in db for %v.", msdef_key)))
+		return
+	}
+
+	msi, err := persistence.FindMicroserviceInstanceWithKey(w.db, msinst_key)
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("error getting service instance %v from db. %v", msinst_key, err)))
+		return
+	} else if msi == nil || msi.Archived || msi.CleanupStartTime != 0 {
+		// the service instance was cleaned up while waiting, there is nothing to retry
+		glog.V(3).Infof(logString(fmt.Sprintf("service instance %v is gone, not retrying it.", msinst_key)))
+		return
+	}
+
+	current_retry := msi.CurrentRetryCount + 1
+	eventlog.LogServiceEvent2(w.db, persistence.SEVERITY_INFO,
+		persistence.NewMessageMeta(EL_GOV_START_SVC_RETRY, strconv.Itoa(int(current_retry)), msdef.SpecRef, msdef.Version),
+		persistence.EC_START_RETRY_DEPENDENT_SERVICE,
+		msinst_key, msdef.SpecRef, msdef.Org, msdef.Version, msdef.Arch, []string{})
+
+	if err := w.RetryMicroservice(msi); err != nil {
+		eventlog.LogServiceEvent2(w.db, persistence.SEVERITY_ERROR,
+			persistence.NewMessageMeta(EL_GOV_FAILED_SVC_RETRY, strconv.Itoa(int(current_retry)), msdef.SpecRef, msdef.Version),
+			persistence.EC_ERROR_START_RETRY_DEPENDENT_SERVICE,
+			msinst_key, msdef.SpecRef, msdef.Org, msdef.Version, msdef.Arch, []string{})
+		glog.Errorf(logString(fmt.Sprintf("error retrying number %v for failed dependent service %v. %v", current_retry, msinst_key, err)))
+		w.handleMicroserviceExecFailure(msdef, msinst_key)
+	}
+}
+
 // Given a microservice id and check if it is set for upgrade, if yes do the upgrade
 func (w *GovernanceWorker) handleMicroserviceUpgrade(msdef_id string) {
 	glog.V(3).Infof(logString(fmt.Sprintf("handling service upgrade for service id %v", msdef_id)))
@@ -74,3 +74,15 @@ func Test_composeNewRegisteredServices(t *testing.T) {
 	assert.False(t, isSame, "The elements should not be the same.")
 	assert.True(t, len(newRS) == 4, "The number of the elements should be 4")
 }
+
+func Test_retryBackoff(t *testing.T) {
+	// the original execution is the first try, the delay doubles with each retry
+	assert.Equal(t, 10, retryBackoff(1, 10, 300))
+	assert.Equal(t, 20, retryBackoff(2, 10, 300))
+	assert.Equal(t, 80, retryBackoff(4, 10, 300))
+
+	// the delay never exceeds the ceiling
+	assert.Equal(t, 300, retryBackoff(6, 10, 300))
+	assert.Equal(t, 300, retryBackoff(100, 10, 300))
+	assert.Equal(t, 5, retryBackoff(1, 10, 5))
+}
@@ -219,7 +219,7 @@ func (w *GovernanceWorker) handleNodeExchPatternChanged(shutdown bool, new_patte
 				return
 			}
 			// set the node shutdown message
-			w.Messages() <- events.NewNodeShutdownMessage(events.START_UNCONFIGURE, false, false)
+			w.Messages() <- events.NewNodeShutdownMessage(events.START_UNCONFIGURE, false, false, "")
 		}
 	} else {
 		// the device is up again and rereg the device with the new pattern
@@ -0,0 +1,33 @@
+package governance
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/persistence"
+)
+
+// Saves the images of a service that were fetched by digest in the local service cache, so that the images the
+// service was last run with are known when it is restarted while the exchange cannot be reached. The images are
+// referenced by digest when the deployment pins them or the ImageDigestPolicy of the agent pinned them on the pull.
+func (w *GovernanceWorker) cacheImageDigests(org string, url string, version string, arch string, dd *containermessage.DeploymentDescription) {
+	if dd == nil {
+		return
+	}
+
+	digests := make(map[string]string)
+	for name, service := range dd.Services {
+		if service == nil {
+			continue
+		} else if _, _, _, digest := cutil.ParseDockerImagePath(service.Image); digest != "" {
+			digests[name] = service.Image
+		}
+	}
+
+	if len(digests) == 0 {
+		return
+	} else if err := persistence.SaveCachedServiceImageDigests(w.db, org, url, version, arch, digests); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to save the image digests of service %v/%v version %v in the local service cache, error %v", org, url, version, err)))
+	}
+}
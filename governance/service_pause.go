@@ -0,0 +1,51 @@
+package governance
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+)
+
+// Stops or starts the running instances of a service that is paused or resumed by the node owner. The agreements of the
+// service are kept. The instances of the service that is the top level service of an agreement are handled with the
+// agreement, the instances of a dependent service are handled by their service instance key.
+func (w *GovernanceWorker) handleServicePause(url string, org string, pause bool) {
+
+	eventId := events.WORKLOAD_RESUME
+	if pause {
+		eventId = events.WORKLOAD_PAUSE
+	}
+
+	glog.V(3).Infof(logString(fmt.Sprintf("handle %v for service %v/%v", eventId, org, url)))
+
+	if establishedAgreements, err := persistence.FindEstablishedAgreementsAllProtocols(w.db, policy.AllAgreementProtocols(), []persistence.EAFilter{persistence.UnarchivedEAFilter(), runningAgreementFilter()}); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("Unable to retrieve running agreements from database, error: %v", err)))
+	} else {
+		for _, ag := range establishedAgreements {
+			if ag.RunningWorkload.URL != url || ag.RunningWorkload.Org != org {
+				continue
+			}
+
+			clusterNamespace, err := w.GetRequestedClusterNamespaceFromAg(&ag)
+			if err != nil {
+				glog.Errorf(logString(fmt.Sprintf("Failed to get cluster namespace from agreeent %v. %v", ag.CurrentAgreementId, err)))
+			}
+			w.Messages() <- events.NewGovernanceMaintenanceMessage(eventId, ag.AgreementProtocol, ag.CurrentAgreementId, clusterNamespace, ag.GetDeploymentConfig())
+		}
+	}
+
+	if msInsts, err := persistence.FindMicroserviceInstances(w.db, []persistence.MIFilter{persistence.UnarchivedMIFilter(), persistence.NotCleanedUpMIFilter()}); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("Error retrieving all service instances from database, error: %v", err)))
+	} else {
+		for _, msi := range msInsts {
+			if msi.SpecRef == url && msi.Org == org && !msi.IsTopLevelService() && msi.ExecutionStartTime != 0 {
+				w.Messages() <- events.NewMicroserviceMaintenanceMessage(eventId, msi.GetKey())
+			}
+		}
+	}
+
+	// the paused state of the service is reported in the node status
+	w.Commands <- w.NewReportDeviceStatusCommand(nil)
+}
@@ -155,6 +155,30 @@ func (w *GovernanceWorker) nodeShutdown(cmd *NodeShutdownCommand) {
 		return
 	}
 
+	// Delete the local copies of the service definitions from the exchange
+	if err := persistence.DeleteServiceCache(w.db); err != nil {
+		w.completedWithError(logString(err.Error()))
+		return
+	}
+
+	// Forget the services paused by the node owner
+	if err := persistence.DeletePausedServices(w.db); err != nil {
+		w.completedWithError(logString(err.Error()))
+		return
+	}
+
+	// Forget the negotiation history of the agreements
+	if err := persistence.DeleteAgreementHistories(w.db); err != nil {
+		w.completedWithError(logString(err.Error()))
+		return
+	}
+
+	// Turn off the maintenance mode of the node
+	if err := persistence.DeleteNodeMaintenance(w.db); err != nil {
+		w.completedWithError(logString(err.Error()))
+		return
+	}
+
 	// remove the docker volumes that are created by anax if device type is "device"
 	if w.deviceType == persistence.DEVICE_TYPE_DEVICE {
 		if err := container.DeleteLeftoverDockerVolumes(w.db, w.Config); err != nil {
@@ -0,0 +1,173 @@
+package governance
+
+import (
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/container"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/kube_operator"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"sync"
+	"time"
+)
+
+// The service stats are published with the node status at most this often, so that the usage changing all the time
+// does not make the node write its status to the exchange every minute.
+const SERVICE_STATS_REPORT_INTERVAL = 300
+
+// Collects the CPU, memory, network and block IO usage of the containers of the services running on the node, and
+// caches it in the local db for the agent API and the node status.
+func (w *GovernanceWorker) collectServiceStats() int {
+	interval := w.Config.GetServiceStatsInterval()
+
+	var collect func(msdef *persistence.MicroserviceDefinition, msi persistence.MicroserviceInstInterface) ([]persistence.ContainerStats, error)
+	if w.deviceType == persistence.DEVICE_TYPE_DEVICE {
+		if w.Config.IsContainerdRuntime() {
+			glog.V(3).Infof(logString("service stats are not collected when the agent drives containerd directly."))
+			return 3600
+		} else if w.Config.Edge.DockerEndpoint == "" {
+			return 3600
+		}
+		client, err := docker.NewClient(w.Config.Edge.DockerEndpoint)
+		if err != nil {
+			glog.Errorf(logString(fmt.Sprintf("Failed to instantiate docker Client: %v", err)))
+			return interval
+		}
+		containers, err := client.ListContainers(docker.ListContainersOptions{})
+		if err != nil {
+			glog.Errorf(logString(fmt.Sprintf("Unable to get list of running containers: %v", err)))
+			return interval
+		}
+		collect = func(msdef *persistence.MicroserviceDefinition, msi persistence.MicroserviceInstInterface) ([]persistence.ContainerStats, error) {
+			return dockerServiceStats(client, msdef, msi, containers)
+		}
+	} else {
+		collect = w.kubeServiceStats
+	}
+
+	stats := make([]persistence.ServiceStats, 0)
+	if msdefs, err := persistence.FindMicroserviceDefs(w.db, []persistence.MSFilter{persistence.UnarchivedMSFilter()}); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("Error retrieving all service definitions from database, error: %v", err)))
+		return interval
+	} else {
+		for ix := range msdefs {
+			msdef := &msdefs[ix]
+			msinsts, err := persistence.GetAllMicroserviceInstancesWithDefId(w.db, msdef.Id, false, false)
+			if err != nil {
+				glog.Errorf(logString(fmt.Sprintf("Error retrieving all service instances for %v from database, error: %v", msdef.SpecRef, err)))
+				continue
+			}
+			for jx := range msinsts {
+				cstats, err := collect(msdef, msinsts[jx])
+				if err != nil {
+					glog.Errorf(logString(fmt.Sprintf("Error collecting the stats of service instance %v, error: %v", msinsts[jx].GetKey(), err)))
+				} else if len(cstats) != 0 {
+					stats = append(stats, persistence.ServiceStats{
+						InstanceId: msinsts[jx].GetKey(),
+						ServiceURL: msdef.SpecRef,
+						Org:        msdef.Org,
+						Version:    msdef.Version,
+						Arch:       msdef.Arch,
+						Containers: cstats,
+						Collected:  time.Now().Unix(),
+					})
+				}
+			}
+		}
+	}
+
+	glog.V(5).Infof(logString(fmt.Sprintf("collected service stats: %v", stats)))
+	if err := persistence.SaveServiceStats(w.db, stats); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("Error saving the service stats, error: %v", err)))
+	}
+	return interval
+}
+
+// Returns the stats of the running containers of a service instance, sampled at the same time.
+func dockerServiceStats(client *docker.Client, msdef *persistence.MicroserviceDefinition, msi persistence.MicroserviceInstInterface, containers []docker.APIContainers) ([]persistence.ContainerStats, error) {
+	deployment, _ := msdef.GetDeployment()
+	deploymentDesc, err := containermessage.GetNativeDeployment(deployment)
+	if err != nil {
+		return nil, nil
+	}
+
+	stats := make([]persistence.ContainerStats, 0)
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for serviceName := range deploymentDesc.Services {
+		for _, c := range containers {
+			if len(c.Names) == 0 || c.Names[0] != "/"+msi.GetKey()+"-"+serviceName {
+				continue
+			}
+			wg.Add(1)
+			go func(id string, name string) {
+				defer wg.Done()
+				if cs, err := container.GetDockerContainerStats(client, id, name); err != nil {
+					glog.Errorf(logString(fmt.Sprintf("Unable to get the stats of container %v, error: %v", name, err)))
+				} else {
+					lock.Lock()
+					stats = append(stats, *cs)
+					lock.Unlock()
+				}
+			}(c.ID, c.Names[0])
+		}
+	}
+	wg.Wait()
+	return stats, nil
+}
+
+// Returns the stats of the containers of the operator of a service instance on a cluster.
+func (w *GovernanceWorker) kubeServiceStats(msdef *persistence.MicroserviceDefinition, msi persistence.MicroserviceInstInterface) ([]persistence.ContainerStats, error) {
+	kd, err := persistence.GetKubeDeployment(msdef.ClusterDeployment)
+	if msdef.ClusterDeployment == "" || err != nil || !msi.IsTopLevelService() {
+		return nil, nil
+	}
+
+	ags, err := persistence.FindEstablishedAgreementsAllProtocols(w.db, policy.AllAgreementProtocols(), []persistence.EAFilter{persistence.UnarchivedEAFilter(), persistence.IdEAFilter(msi.GetKey())})
+	if err != nil {
+		return nil, err
+	} else if len(ags) != 1 {
+		return nil, nil
+	}
+	reqNamespace, err := w.GetRequestedClusterNamespaceFromAg(&ags[0])
+	if err != nil {
+		return nil, err
+	}
+
+	kc, err := kube_operator.NewKubeClient()
+	if err != nil {
+		return nil, err
+	}
+	return kc.ContainerStats(kd.OperatorYamlArchive, kd.Metadata, msi.GetKey(), reqNamespace)
+}
+
+// Sums the stats of the instances of each service for the node status. The stats of a service are left out when none of
+// its instances has stats.
+func serviceStatsRollups(services []exchange.WorkloadStatus, stats []persistence.ServiceStats) {
+	for ix := range services {
+		var rollup *exchange.ServiceStats
+		for _, s := range stats {
+			if s.ServiceURL != services[ix].ServiceURL || s.Org != services[ix].Org || s.Version != services[ix].Version {
+				continue
+			}
+			if rollup == nil {
+				rollup = new(exchange.ServiceStats)
+			}
+			for _, cs := range s.Containers {
+				rollup.CPUPercent += cs.CPUPercent
+				rollup.MemoryUsage += cs.MemoryUsage
+				rollup.NetworkRxBytes += cs.NetworkRxBytes
+				rollup.NetworkTxBytes += cs.NetworkTxBytes
+				rollup.BlockReadBytes += cs.BlockReadBytes
+				rollup.BlockWriteBytes += cs.BlockWriteBytes
+			}
+			if s.Collected > rollup.Collected {
+				rollup.Collected = s.Collected
+			}
+		}
+		services[ix].Stats = rollup
+	}
+}
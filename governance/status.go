@@ -1,6 +1,8 @@
 package governance
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	docker "github.com/fsouza/go-dockerclient"
@@ -12,13 +14,19 @@ import (
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/exchangesync"
 	"github.com/open-horizon/anax/helm"
+	"github.com/open-horizon/anax/hostprocess"
 	"github.com/open-horizon/anax/kube_operator"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
+	"github.com/open-horizon/anax/wasm"
 	"reflect"
 	"time"
 )
 
+// The free memory is published with the node status when it has changed by more than this percentage, so that small
+// variations do not make the node write its status to the exchange every minute.
+const FREE_MEMORY_REPORT_CHANGE = 10
+
 // Report the containers status and connectivity status to the exchange.
 func (w *GovernanceWorker) ReportDeviceStatus() int {
 	return w.reportDeviceStatus(nil)
@@ -30,12 +38,6 @@ func (w *GovernanceWorker) reportDeviceStatus(cfgStates []events.ServiceConfigSt
 		return 3600
 	}
 
-	// for 'device' node type, it has to have DockerEndpoint set
-	if w.Config.Edge.DockerEndpoint == "" && w.deviceType == persistence.DEVICE_TYPE_DEVICE {
-		glog.Infof("Skip reporting the status to the exchange because DockerEndpoint is not set in the configuration.")
-		return 3600
-	}
-
 	glog.Info("started the status report to the exchange.")
 
 	w.deviceStatus = nil
@@ -43,7 +45,14 @@ func (w *GovernanceWorker) reportDeviceStatus(cfgStates []events.ServiceConfigSt
 
 	// get docker containers
 	containers := make([]docker.APIContainers, 0)
-	if w.deviceType == persistence.DEVICE_TYPE_DEVICE {
+	if w.deviceType == persistence.DEVICE_TYPE_DEVICE && w.Config.IsContainerdRuntime() {
+		if ctrdContainers, err := container.ListContainerdContainers(w.Config, false); err != nil {
+			glog.Errorf(logString(fmt.Sprintf("Unable to get list of running containers: %v", err)))
+		} else {
+			containers = ctrdContainers
+		}
+	} else if w.deviceType == persistence.DEVICE_TYPE_DEVICE && w.Config.Edge.DockerEndpoint != "" {
+		// a 'device' node without a DockerEndpoint only runs services deployed as WebAssembly modules or host processes
 		if client, err := docker.NewClient(w.Config.Edge.DockerEndpoint); err != nil {
 			glog.Errorf(logString(fmt.Sprintf("Failed to instantiate docker Client: %v", err)))
 		} else {
@@ -108,11 +117,11 @@ func (w *GovernanceWorker) reportDeviceStatus(cfgStates []events.ServiceConfigSt
 		}
 	}
 
-	// only save the ones that have non empty containers or config state as suspended
+	// only save the ones that have non empty containers or config state as suspended or that are paused
 	var device_status_new exchange.DeviceStatus
 	device_status_new.Services = make([]exchange.WorkloadStatus, 0)
 	for i, workload := range device_status.Services {
-		if workload.ConfigState == exchange.SERVICE_CONFIGSTATE_SUSPENDED || len(workload.Containers) > 0 {
+		if workload.ConfigState == exchange.SERVICE_CONFIGSTATE_SUSPENDED || workload.Paused || len(workload.Containers) > 0 {
 			device_status_new.Services = append(device_status_new.Services, device_status.Services[i])
 		}
 	}
@@ -133,11 +142,41 @@ func (w *GovernanceWorker) reportDeviceStatus(cfgStates []events.ServiceConfigSt
 
 	statusChanged = changeInWorkloadStatuses(unmarshalledNodeStatus, oldWlStatus)
 
+	// the service stats change all the time, they are published with the status when it changes or every few minutes
+	if w.Config.Edge.ServiceStatsReport && w.Config.GetServiceStatsInterval() > 0 {
+		if stats, err := persistence.FindServiceStats(w.db); err != nil {
+			glog.Errorf(logString(fmt.Sprintf("Unable to read the service stats, error: %v", err)))
+		} else {
+			serviceStatsRollups(device_status_new.Services, stats)
+			if time.Now().Unix()-w.statsReported >= SERVICE_STATS_REPORT_INTERVAL {
+				statusChanged = true
+			}
+			if statusChanged {
+				w.statsReported = time.Now().Unix()
+			}
+		}
+	}
+
+	// the free memory is used by the agbots for the placement constraints of the deployment policies, it is published
+	// with the status when it changes significantly
+	if w.Config.Edge.ReportFreeMemory {
+		if free, err := w.getFreeMemory(); err != nil {
+			glog.Errorf(logString(fmt.Sprintf("Unable to read the free memory of the node, error: %v", err)))
+		} else {
+			device_status_new.FreeMemory = &free
+			if freeMemoryChanged(w.memoryReported, free) {
+				statusChanged = true
+			}
+		}
+	}
+
 	if statusChanged {
 		glog.V(5).Infof(logString(fmt.Sprintf("device status to report to the exchange: %v", device_status_new)))
 
 		if err := w.writeStatusToExchange(&device_status_new); err != nil {
 			glog.Errorf(logString(err))
+		} else if device_status_new.FreeMemory != nil {
+			w.memoryReported = *device_status_new.FreeMemory
 		}
 		if err := persistence.SaveNodeStatus(w.db, convertToPersistenceType(device_status_new.Services)); err != nil {
 			glog.Errorf(logString(err))
@@ -148,6 +187,28 @@ func (w *GovernanceWorker) reportDeviceStatus(cfgStates []events.ServiceConfigSt
 	return 60
 }
 
+// Returns the free memory of the node in MB, the available memory of all the nodes for a cluster.
+func (w *GovernanceWorker) getFreeMemory() (int64, error) {
+	if w.deviceType == persistence.DEVICE_TYPE_CLUSTER {
+		availMem, _, _, _, _, _, _, err := cutil.GetClusterCountInfo()
+		return int64(availMem), err
+	}
+	_, availMem, err := cutil.GetMemInfo("")
+	return int64(availMem), err
+}
+
+// Returns true when the free memory has changed significantly since it was last published.
+func freeMemoryChanged(reported int64, free int64) bool {
+	if reported == 0 {
+		return free != 0
+	}
+	diff := free - reported
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff*100 > reported*FREE_MEMORY_REPORT_CHANGE
+}
+
 // Update the services with configstate of the old suspended services.
 func updateWithOldSuspendedServices(updatedServices []exchange.WorkloadStatus, oldServices []persistence.WorkloadStatus) []exchange.WorkloadStatus {
 	newStatus := make([]exchange.WorkloadStatus, len(updatedServices))
@@ -192,6 +253,7 @@ func (w *GovernanceWorker) getServiceStatus(containers []docker.APIContainers) (
 			msdef_status.Org = msdef.Org
 			msdef_status.Version = msdef.Version
 			msdef_status.Arch = msdef.Arch
+			msdef_status.Paused = persistence.IsServicePaused(w.db, msdef.Org, msdef.SpecRef)
 			msdef_status.Containers = make([]exchange.ContainerStatus, 0)
 			deployment := ""
 			reqNamespace := ""
@@ -228,7 +290,11 @@ func (w *GovernanceWorker) getServiceStatus(containers []docker.APIContainers) (
 			} else if msinsts != nil {
 				for _, msi := range msinsts {
 					glog.V(3).Infof("Gathering status for msdef: %v/%v, working on instance %v", msdef.Org, msdef.SpecRef, msi.GetKey())
-					if deployment != "" {
+					if wd, err := persistence.GetWasmDeployment(deployment); err == nil {
+						msdef_status.Containers = append(msdef_status.Containers, GetWasmModuleStatus(w.Config.GetWasmStateDir(), msi.GetKey(), wd))
+					} else if hd, err := persistence.GetHostProcessDeployment(deployment); err == nil {
+						msdef_status.Containers = append(msdef_status.Containers, GetHostProcessStatus(msi.GetKey(), hd))
+					} else if deployment != "" {
 						if cstatus, err := GetContainerStatus(deployment, msi.GetKey(), !msi.IsTopLevelService(), containers, reqNamespace); err != nil {
 							return nil, fmt.Errorf(logString(fmt.Sprintf("Error getting service container status for %v. %v", msdef.SpecRef, err)))
 						} else {
@@ -252,6 +318,30 @@ func (w *GovernanceWorker) getServiceStatus(containers []docker.APIContainers) (
 	return status, nil
 }
 
+// The status of the WebAssembly module of a service, reported in the place of the status of its containers. The image
+// is the digest of the module.
+func GetWasmModuleStatus(stateDir string, key string, wd *persistence.WasmDeploymentConfig) exchange.ContainerStatus {
+	var container_status exchange.ContainerStatus
+	container_status.Name = fmt.Sprintf("Wasm module: %v", key)
+	if module, err := base64.StdEncoding.DecodeString(wd.WasmModule); err == nil {
+		container_status.ImageDigest = fmt.Sprintf("sha256:%x", sha256.Sum256(module))
+	}
+	container_status.State, container_status.Created = wasm.GetModuleStatus(stateDir, key)
+	return container_status
+}
+
+// The status of the host process of a service, reported in the place of the status of its containers. The image is the
+// digest of the binary.
+func GetHostProcessStatus(key string, hd *persistence.HostProcessDeploymentConfig) exchange.ContainerStatus {
+	var container_status exchange.ContainerStatus
+	container_status.Name = fmt.Sprintf("Host process: %v", key)
+	if binary, err := base64.StdEncoding.DecodeString(hd.Binary); err == nil {
+		container_status.ImageDigest = fmt.Sprintf("sha256:%x", sha256.Sum256(binary))
+	}
+	container_status.State, container_status.Created = hostprocess.GetProcessStatus(key)
+	return container_status
+}
+
 // find container status
 
 func GetContainerStatus(deployment string, key string, infrastructure bool, containers []docker.APIContainers, reqClusterNamespace string) ([]exchange.ContainerStatus, error) {
@@ -274,6 +364,7 @@ func GetContainerStatus(deployment string, key string, infrastructure bool, cont
 					if cname == "/"+key+"-"+serviceName {
 						container_status.Name = container.Names[0]
 						container_status.Image = container.Image
+						_, _, _, container_status.ImageDigest = cutil.ParseDockerImagePath(container.Image)
 						container_status.Created = container.Created
 						container_status.State = container.State
 						break
@@ -418,7 +509,7 @@ func changeInWorkloadStatuses(newStatuses []persistence.WorkloadStatus, oldStatu
 				if changeInContainerStatuses(newStatus.Containers, oldStatus.Containers) {
 					return true
 				}
-				if oldStatus.ConfigState != newStatus.ConfigState {
+				if oldStatus.ConfigState != newStatus.ConfigState || oldStatus.Paused != newStatus.Paused {
 					return true
 				}
 				matches++
@@ -477,7 +568,7 @@ func convertToPersistenceType(workload []exchange.WorkloadStatus) []persistence.
 	for _, wlStatus := range workload {
 		newPersistentWlStatus := persistence.WorkloadStatus{AgreementId: wlStatus.AgreementId,
 			ServiceURL: wlStatus.ServiceURL, Org: wlStatus.Org, Version: wlStatus.Version,
-			Arch: wlStatus.Arch, OperatorStatus: wlStatus.OperatorStatus, ConfigState: wlStatus.ConfigState}
+			Arch: wlStatus.Arch, OperatorStatus: wlStatus.OperatorStatus, ConfigState: wlStatus.ConfigState, Paused: wlStatus.Paused}
 		newPersistentWlStatus.Containers = converContainerStatusToPersistenceType(wlStatus.Containers)
 		persistentWls = append(persistentWls, newPersistentWlStatus)
 	}
@@ -487,7 +578,7 @@ func convertToPersistenceType(workload []exchange.WorkloadStatus) []persistence.
 func converContainerStatusToPersistenceType(containers []exchange.ContainerStatus) []persistence.ContainerStatus {
 	persistentCStatuses := []persistence.ContainerStatus{}
 	for _, cStatus := range containers {
-		persistentCStatuses = append(persistentCStatuses, persistence.ContainerStatus{Name: cStatus.Name, Image: cStatus.Image, Created: cStatus.Created, State: cStatus.State})
+		persistentCStatuses = append(persistentCStatuses, persistence.ContainerStatus{Name: cStatus.Name, Image: cStatus.Image, ImageDigest: cStatus.ImageDigest, Created: cStatus.Created, State: cStatus.State})
 	}
 	return persistentCStatuses
 }
@@ -134,3 +134,13 @@ func statusArrayIsSame(a1 []exchange.ContainerStatus, a2 []exchange.ContainerSta
 
 	return true
 }
+
+func Test_freeMemoryChanged(t *testing.T) {
+	assert.True(t, freeMemoryChanged(0, 512), "nothing reported yet")
+	assert.False(t, freeMemoryChanged(0, 0), "no free memory reported and none free")
+	assert.False(t, freeMemoryChanged(1000, 1000), "unchanged")
+	assert.False(t, freeMemoryChanged(1000, 1100), "10 percent more")
+	assert.False(t, freeMemoryChanged(1000, 900), "10 percent less")
+	assert.True(t, freeMemoryChanged(1000, 1101), "more than 10 percent more")
+	assert.True(t, freeMemoryChanged(1000, 899), "more than 10 percent less")
+}
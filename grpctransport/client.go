@@ -0,0 +1,141 @@
+package grpctransport
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"sync"
+	"time"
+)
+
+// How long to wait before opening the stream again after it closed.
+const CLIENT_RETRY_S = 30
+
+// The agent side of the transport. It keeps a stream open to one agbot, opening it again when it closes. The agbot is
+// trusted through the TLS credentials of the connection.
+type Client struct {
+	lock    sync.Mutex
+	address string
+	nodeId  string
+	token   string
+	creds   credentials.TransportCredentials // nil for an insecure connection
+	handler Handler
+	agbotId string             // The agbot at the other end of the open stream, empty when there is no stream.
+	stream  grpc.ClientStream  // The open stream.
+	cancel  context.CancelFunc // Stops the client.
+}
+
+func NewClient(address string, nodeId string, token string, creds credentials.TransportCredentials, handler Handler) *Client {
+	return &Client{
+		address: address,
+		nodeId:  nodeId,
+		token:   token,
+		creds:   creds,
+		handler: handler,
+	}
+}
+
+// Start keeping the stream open in the background.
+func (c *Client) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.lock.Lock()
+	c.cancel = cancel
+	c.lock.Unlock()
+
+	go func() {
+		for ctx.Err() == nil {
+			if err := c.run(ctx); err != nil && ctx.Err() == nil {
+				glog.Warningf(logString(fmt.Sprintf("stream to %v closed, opening it again in %v seconds, error: %v", c.address, CLIENT_RETRY_S, err)))
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(CLIENT_RETRY_S * time.Second):
+			}
+		}
+	}()
+}
+
+func (c *Client) Stop() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+// Returns the id of the agbot at the other end of the open stream, or empty when there is no stream.
+func (c *Client) AgbotId() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.agbotId
+}
+
+// Send an encrypted message to an agbot. ErrNotConnected is returned when the stream is not open to that agbot.
+func (c *Client) Send(agbotId string, message []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.stream == nil || c.agbotId != agbotId {
+		return ErrNotConnected
+	} else if err := c.stream.SendMsg(&wrapperspb.BytesValue{Value: message}); err != nil {
+		return fmt.Errorf("unable to send message to %v, error: %v", agbotId, err)
+	}
+	return nil
+}
+
+// Open the stream and receive the messages until it closes.
+func (c *Client) run(ctx context.Context) error {
+	if c.creds != nil {
+		return c.runWithOptions(ctx, grpc.WithTransportCredentials(c.creds))
+	}
+	return c.runWithOptions(ctx, grpc.WithTransportCredentials(insecure.NewCredentials()))
+}
+
+func (c *Client) runWithOptions(ctx context.Context, opts ...grpc.DialOption) error {
+
+	conn, err := grpc.DialContext(ctx, c.address, opts...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	streamCtx := metadata.AppendToOutgoingContext(ctx, authorizationKey, basicAuth(c.nodeId, c.token))
+	stream, err := conn.NewStream(streamCtx, &serviceDesc.Streams[0], connectMethod)
+	if err != nil {
+		return err
+	}
+
+	header, err := stream.Header()
+	if err != nil {
+		return err
+	} else if len(header.Get(agbotIdKey)) == 0 {
+		// The agbot rejected the stream, the error is returned by the first receive.
+		return stream.RecvMsg(new(wrapperspb.BytesValue))
+	}
+	agbotId := header.Get(agbotIdKey)[0]
+
+	c.lock.Lock()
+	c.stream = stream
+	c.agbotId = agbotId
+	c.lock.Unlock()
+	glog.V(3).Infof(logString(fmt.Sprintf("connected to agbot %v at %v", agbotId, c.address)))
+
+	defer func() {
+		c.lock.Lock()
+		c.stream = nil
+		c.agbotId = ""
+		c.lock.Unlock()
+	}()
+
+	for {
+		msg := new(wrapperspb.BytesValue)
+		if err := stream.RecvMsg(msg); err != nil {
+			return err
+		}
+		c.handler(agbotId, msg.Value)
+	}
+}
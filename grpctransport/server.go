@@ -0,0 +1,136 @@
+package grpctransport
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"net"
+	"sync"
+)
+
+// Checks the exchange credentials of a node opening a stream.
+type Authenticator func(nodeId string, token string) error
+
+// The agbot side of the transport. It keeps the open stream of each node, so that the messages for a node are sent on
+// its stream. When a node opens a new stream, the older one is no longer used.
+type Server struct {
+	lock    sync.Mutex
+	agbotId string
+	auth    Authenticator
+	handler Handler
+	streams map[string]*serverStream // The open streams, keyed by org qualified node id.
+	grpcSrv *grpc.Server
+}
+
+type serverStream struct {
+	lock   sync.Mutex
+	stream grpc.ServerStream
+	closed bool
+}
+
+func NewServer(agbotId string, auth Authenticator, handler Handler, opts ...grpc.ServerOption) *Server {
+	s := &Server{
+		agbotId: agbotId,
+		auth:    auth,
+		handler: handler,
+		streams: make(map[string]*serverStream),
+		grpcSrv: grpc.NewServer(opts...),
+	}
+	s.grpcSrv.RegisterService(&serviceDesc, s)
+	return s
+}
+
+// Serve the streams on the listener until the server is stopped.
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpcSrv.Serve(lis)
+}
+
+func (s *Server) Stop() {
+	s.grpcSrv.Stop()
+}
+
+// Returns true if the node has an open stream.
+func (s *Server) Connected(nodeId string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, ok := s.streams[nodeId]
+	return ok
+}
+
+// Send an encrypted message to a node on its stream. ErrNotConnected is returned when the node has no stream.
+func (s *Server) Send(nodeId string, message []byte) error {
+	s.lock.Lock()
+	ss, ok := s.streams[nodeId]
+	s.lock.Unlock()
+	if !ok {
+		return ErrNotConnected
+	}
+
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	if ss.closed {
+		return ErrNotConnected
+	} else if err := ss.stream.SendMsg(&wrapperspb.BytesValue{Value: message}); err != nil {
+		return fmt.Errorf("unable to send message to %v, error: %v", nodeId, err)
+	}
+	return nil
+}
+
+func (s *Server) connect(stream grpc.ServerStream) error {
+
+	nodeId, err := s.authenticate(stream)
+	if err != nil {
+		glog.Warningf(logString(fmt.Sprintf("rejected stream, error: %v", err)))
+		return status.Error(codes.Unauthenticated, err.Error())
+	} else if err := stream.SendHeader(metadata.Pairs(agbotIdKey, s.agbotId)); err != nil {
+		return err
+	}
+
+	ss := &serverStream{stream: stream}
+	s.lock.Lock()
+	s.streams[nodeId] = ss
+	s.lock.Unlock()
+	glog.V(3).Infof(logString(fmt.Sprintf("node %v connected", nodeId)))
+
+	defer func() {
+		ss.lock.Lock()
+		ss.closed = true
+		ss.lock.Unlock()
+
+		s.lock.Lock()
+		if s.streams[nodeId] == ss {
+			delete(s.streams, nodeId)
+		}
+		s.lock.Unlock()
+		glog.V(3).Infof(logString(fmt.Sprintf("node %v disconnected", nodeId)))
+	}()
+
+	for {
+		msg := new(wrapperspb.BytesValue)
+		if err := stream.RecvMsg(msg); err != nil {
+			return nil
+		}
+		s.handler(nodeId, msg.Value)
+	}
+}
+
+// Returns the id of the node from the credentials in the stream metadata.
+func (s *Server) authenticate(stream grpc.ServerStream) (string, error) {
+	md, ok := metadata.FromIncomingContext(stream.Context())
+	if !ok || len(md.Get(authorizationKey)) == 0 {
+		return "", errors.New("no credentials")
+	}
+
+	id, token, err := parseBasicAuth(md.Get(authorizationKey)[0])
+	if err != nil {
+		return "", err
+	} else if err := s.auth(id, token); err != nil {
+		return "", fmt.Errorf("node %v could not be authenticated, error: %v", id, err)
+	}
+	return id, nil
+}
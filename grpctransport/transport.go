@@ -0,0 +1,66 @@
+// Package grpctransport delivers agreement protocol messages directly between agents and agbots over a gRPC stream,
+// instead of through the exchange mailboxes. The agbot serves the stream and the agent connects to it, so only the agent
+// needs to reach the agbot. A message that cannot be sent on a stream is sent through the exchange as before.
+package grpctransport
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"strings"
+)
+
+const ServiceName = "horizon.agreement.v1.AgreementTransport"
+const connectMethod = "/" + ServiceName + "/Connect"
+
+// The metadata keys of the stream.
+const authorizationKey = "authorization"
+const agbotIdKey = "agbot-id"
+
+// Returned by Send when there is no stream to the receiver. The message has to be sent through the exchange.
+var ErrNotConnected = errors.New("no direct connection to the receiver")
+
+// Called with the org qualified exchange id of the sender and the encrypted message, for each message received on a
+// stream. The sender id is the authenticated identity of the peer of the stream.
+type Handler func(senderId string, message []byte)
+
+// The server side of the stream is registered without generated code, the messages are google.protobuf.BytesValue.
+type connectServer interface {
+	connect(stream grpc.ServerStream) error
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*connectServer)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Connect",
+			Handler:       func(srv interface{}, stream grpc.ServerStream) error { return srv.(connectServer).connect(stream) },
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "agreement_transport.proto",
+}
+
+func basicAuth(id string, token string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(id+":"+token))
+}
+
+func parseBasicAuth(auth string) (string, string, error) {
+	if !strings.HasPrefix(auth, "Basic ") {
+		return "", "", errors.New("the authorization is not basic credentials")
+	} else if decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "Basic ")); err != nil {
+		return "", "", fmt.Errorf("unable to decode the credentials, error: %v", err)
+	} else if parts := strings.SplitN(string(decoded), ":", 2); len(parts) != 2 || parts[0] == "" {
+		return "", "", errors.New("the credentials are not id:token")
+	} else {
+		return parts[0], parts[1], nil
+	}
+}
+
+// Logging function
+var logString = func(v interface{}) string {
+	return fmt.Sprintf("gRPC Transport: %v", v)
+}
@@ -0,0 +1,122 @@
+//go:build unit
+// +build unit
+
+package grpctransport
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"testing"
+	"time"
+)
+
+type received struct {
+	sender  string
+	message string
+}
+
+func testServer(t *testing.T) (*Server, *bufconn.Listener, chan received) {
+	auth := func(nodeId string, token string) error {
+		if nodeId == "myorg/node1" && token == "secret" {
+			return nil
+		}
+		return errors.New("bad credentials")
+	}
+
+	msgs := make(chan received, 10)
+	s := NewServer("myorg/agbot", auth, func(sender string, message []byte) { msgs <- received{sender, string(message)} })
+	lis := bufconn.Listen(1024 * 1024)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return s, lis, msgs
+}
+
+func testClient(lis *bufconn.Listener, token string, msgs chan received) *Client {
+	c := NewClient("bufnet", "myorg/node1", token, nil, func(sender string, message []byte) { msgs <- received{sender, string(message)} })
+	return c
+}
+
+func dialer(lis *bufconn.Listener) grpc.DialOption {
+	return grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) { return lis.Dial() })
+}
+
+// Open a stream the way the client does, through the bufconn listener.
+func runClient(t *testing.T, c *Client, lis *bufconn.Listener) chan error {
+	done := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		done <- c.runWithOptions(ctx, dialer(lis), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}()
+	return done
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %v", what)
+}
+
+func Test_Transport_RoundTrip(t *testing.T) {
+	s, lis, agbotMsgs := testServer(t)
+	nodeMsgs := make(chan received, 10)
+	c := testClient(lis, "secret", nodeMsgs)
+
+	if err := s.Send("myorg/node1", []byte("early")); err != ErrNotConnected {
+		t.Errorf("expected ErrNotConnected before the node connects, got %v", err)
+	}
+
+	runClient(t, c, lis)
+	waitFor(t, "the node to connect", func() bool { return s.Connected("myorg/node1") && c.AgbotId() == "myorg/agbot" })
+
+	if err := s.Send("myorg/node1", []byte("proposal")); err != nil {
+		t.Errorf("unexpected error sending to the node: %v", err)
+	} else if m := <-nodeMsgs; m.sender != "myorg/agbot" || m.message != "proposal" {
+		t.Errorf("node received %v", m)
+	}
+
+	if err := c.Send("myorg/otheragbot", []byte("reply")); err != ErrNotConnected {
+		t.Errorf("expected ErrNotConnected for another agbot, got %v", err)
+	} else if err := c.Send("myorg/agbot", []byte("reply")); err != nil {
+		t.Errorf("unexpected error sending to the agbot: %v", err)
+	} else if m := <-agbotMsgs; m.sender != "myorg/node1" || m.message != "reply" {
+		t.Errorf("agbot received %v", m)
+	}
+}
+
+func Test_Transport_Unauthenticated(t *testing.T) {
+	s, lis, _ := testServer(t)
+	c := testClient(lis, "wrong", make(chan received, 10))
+
+	select {
+	case err := <-runClient(t, c, lis):
+		if err == nil {
+			t.Errorf("expected an error for bad credentials")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the stream with bad credentials was not closed")
+	}
+
+	if s.Connected("myorg/node1") || c.AgbotId() != "" {
+		t.Errorf("the node should not be connected")
+	}
+}
+
+func Test_parseBasicAuth(t *testing.T) {
+	if id, token, err := parseBasicAuth(basicAuth("myorg/node1", "a:b")); err != nil || id != "myorg/node1" || token != "a:b" {
+		t.Errorf("wrong credentials %v %v %v", id, token, err)
+	}
+	for _, auth := range []string{"", "Bearer x", "Basic !!", basicAuth("", "token")} {
+		if _, _, err := parseBasicAuth(auth); err == nil {
+			t.Errorf("expected an error for %v", auth)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package hostprocess
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/persistence"
+	"os"
+	"os/user"
+	"path/filepath"
+)
+
+// The states of a host process, reported in the node status in the place of the state of a container.
+const PROCESS_RUNNING = "running"
+const PROCESS_EXITED = "exited"
+const PROCESS_NOT_STARTED = "not started"
+
+// The process manager writes the binary and the environment of the process of an agreement to its directory and runs
+// the process in a systemd unit.
+type ProcessManager struct {
+	dir     string   // the directory holding the binaries and the environment files, only writable by root
+	enabled bool     // the node allows host processes
+	users   []string // the host users the processes can run as, in the place of a dynamic user
+}
+
+func NewProcessManager(dir string, enabled bool, users []string) *ProcessManager {
+	return &ProcessManager{
+		dir:     dir,
+		enabled: enabled,
+		users:   users,
+	}
+}
+
+// Returns an error if the node does not allow the process. The host processes have to be enabled in the agent
+// configuration, and a process can only run as a host user that the configuration lists, never as root.
+func (m *ProcessManager) CheckAllowed(hd *persistence.HostProcessDeploymentConfig) error {
+	if !m.enabled {
+		return errors.New(fmt.Sprintf("host processes are not enabled on this node, set HostProcessEnabled in the agent configuration to run them"))
+	} else if hd.User == "" {
+		return nil
+	} else if !cutil.SliceContains(m.users, hd.User) {
+		return errors.New(fmt.Sprintf("the process cannot run as user %v, it is not in HostProcessUsers of the agent configuration", hd.User))
+	} else if u, err := user.Lookup(hd.User); err != nil {
+		return errors.New(fmt.Sprintf("unable to run the process as user %v: %v", hd.User, err))
+	} else if u.Uid == "0" {
+		return errors.New(fmt.Sprintf("the process cannot run as user %v, it is root", hd.User))
+	}
+	return nil
+}
+
+// Starts the process of an agreement. A unit left over from an earlier run of the same agreement is replaced.
+func (m *ProcessManager) Start(agId string, hd *persistence.HostProcessDeploymentConfig, env map[string]string) error {
+
+	if err := m.CheckAllowed(hd); err != nil {
+		return err
+	} else if err := CheckSystemd(); err != nil {
+		return errors.New(fmt.Sprintf("unable to run host processes: %v", err))
+	}
+
+	binary, err := base64.StdEncoding.DecodeString(hd.Binary)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to decode the binary: %v", err))
+	}
+
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return errors.New(fmt.Sprintf("unable to create directory %v: %v", m.dir, err))
+	} else if err := os.WriteFile(BinaryPath(m.dir, agId), binary, 0755); err != nil {
+		return errors.New(fmt.Sprintf("unable to write the binary: %v", err))
+	} else if err := os.WriteFile(envFilePath(m.dir, agId), []byte(envFileContents(env)), 0600); err != nil {
+		return errors.New(fmt.Sprintf("unable to write the environment file: %v", err))
+	}
+
+	if status, err := GetUnitStatus(agId); err == nil && status.LoadState == "loaded" {
+		glog.Warningf(hplog(fmt.Sprintf("replacing unit %v, %v", UnitName(agId), status)))
+		if err := StopUnit(agId); err != nil {
+			return err
+		}
+	}
+
+	return StartUnit(agId, hd, BinaryPath(m.dir, agId), envFilePath(m.dir, agId))
+}
+
+// Stops the process of an agreement and removes its files.
+func (m *ProcessManager) Stop(agId string) error {
+	err := StopUnit(agId)
+	for _, p := range []string{BinaryPath(m.dir, agId), envFilePath(m.dir, agId)} {
+		if rerr := os.Remove(p); rerr != nil && !os.IsNotExist(rerr) {
+			glog.Warningf(hplog(fmt.Sprintf("unable to remove %v: %v", p, rerr)))
+		}
+	}
+	return err
+}
+
+// Returns an error if the process of an agreement is no longer running and will not be restarted by systemd, e.g.
+// because it ran out of retries.
+func (m *ProcessManager) Maintain(agId string) error {
+	if status, err := GetUnitStatus(agId); err != nil {
+		return err
+	} else if !status.Running() {
+		return errors.New(fmt.Sprintf("the process of agreement %v is not running, %v", agId, status))
+	}
+	return nil
+}
+
+// Returns the state of the process of an agreement and the time it was started.
+func GetProcessStatus(agId string) (string, int64) {
+	if status, err := GetUnitStatus(agId); err != nil || status.LoadState != "loaded" {
+		return PROCESS_NOT_STARTED, 0
+	} else if status.Running() {
+		return PROCESS_RUNNING, status.Started
+	} else {
+		return PROCESS_EXITED, status.Started
+	}
+}
+
+func BinaryPath(dir string, agId string) string {
+	return filepath.Join(dir, agId)
+}
+
+func envFilePath(dir string, agId string) string {
+	return filepath.Join(dir, agId+".env")
+}
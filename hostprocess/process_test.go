@@ -0,0 +1,42 @@
+//go:build unit
+// +build unit
+
+package hostprocess
+
+import (
+	"github.com/open-horizon/anax/persistence"
+	"os/user"
+	"testing"
+)
+
+func Test_CheckAllowed(t *testing.T) {
+
+	current, err := user.Current()
+	if err != nil {
+		t.Fatalf("unable to get the current user: %v", err)
+	}
+
+	dynamic := &persistence.HostProcessDeploymentConfig{}
+	named := &persistence.HostProcessDeploymentConfig{User: current.Username}
+	root := &persistence.HostProcessDeploymentConfig{User: "root"}
+
+	tests := []struct {
+		name    string
+		m       *ProcessManager
+		hd      *persistence.HostProcessDeploymentConfig
+		allowed bool
+	}{
+		{"not enabled", NewProcessManager("", false, nil), dynamic, false},
+		{"dynamic user", NewProcessManager("", true, nil), dynamic, true},
+		{"user not listed", NewProcessManager("", true, nil), named, false},
+		{"user listed", NewProcessManager("", true, []string{current.Username}), named, current.Uid != "0"},
+		{"root listed", NewProcessManager("", true, []string{"root"}), root, false},
+		{"unknown user", NewProcessManager("", true, []string{"no-such-user"}), &persistence.HostProcessDeploymentConfig{User: "no-such-user"}, false},
+	}
+
+	for _, test := range tests {
+		if err := test.m.CheckAllowed(test.hd); (err == nil) != test.allowed {
+			t.Errorf("%v: expected allowed %v, but got error %v", test.name, test.allowed, err)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package hostprocess
+
+import (
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/worker"
+)
+
+// The host process worker runs the services whose deployment is a signed binary, on hosts where no container runtime is
+// permitted, when the agent configuration enables them. The process runs in a systemd unit under its own unprivileged
+// user, gets the same environment variables as the containers of a service and logs to syslog under the same tag as a
+// container, so that hzn service log works.
+type HostProcessWorker struct {
+	worker.BaseWorker // embedded field
+	db                *bolt.DB
+	processes         *ProcessManager
+}
+
+func NewHostProcessWorker(name string, config *config.HorizonConfig, db *bolt.DB) *HostProcessWorker {
+
+	worker := &HostProcessWorker{
+		BaseWorker: worker.NewBaseWorker(name, config, nil),
+		db:         db,
+		processes:  NewProcessManager(config.GetHostProcessDir(), config.Edge.HostProcessEnabled, config.Edge.HostProcessUsers),
+	}
+
+	glog.Info(hplog(fmt.Sprintf("Starting Host Process worker")))
+	worker.Start(worker, 0)
+	return worker
+}
+
+func (w *HostProcessWorker) Messages() chan events.Message {
+	return w.BaseWorker.Manager.Messages
+}
+
+func (w *HostProcessWorker) NewEvent(incoming events.Message) {
+
+	switch incoming.(type) {
+	case *events.AgreementReachedMessage:
+		msg, _ := incoming.(*events.AgreementReachedMessage)
+
+		fCmd := NewInstallCommand(msg.LaunchContext())
+		w.Commands <- fCmd
+
+	case *events.GovernanceWorkloadCancelationMessage:
+		msg, _ := incoming.(*events.GovernanceWorkloadCancelationMessage)
+
+		switch msg.Event().Id {
+		case events.AGREEMENT_ENDED:
+			cmd := NewUnInstallCommand(msg.AgreementProtocol, msg.AgreementId, msg.Deployment)
+			w.Commands <- cmd
+		}
+
+	case *events.GovernanceMaintenanceMessage:
+		msg, _ := incoming.(*events.GovernanceMaintenanceMessage)
+
+		switch msg.Event().Id {
+		case events.CONTAINER_MAINTAIN:
+			cmd := NewMaintenanceCommand(msg.AgreementProtocol, msg.AgreementId, msg.Deployment)
+			w.Commands <- cmd
+		}
+
+	case *events.NodeShutdownCompleteMessage:
+		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
+		switch msg.Event().Id {
+		case events.UNCONFIGURE_COMPLETE:
+			w.Commands <- worker.NewTerminateCommand("shutdown")
+		}
+
+	default: //nothing
+
+	}
+
+	return
+}
+
+func (w *HostProcessWorker) CommandHandler(command worker.Command) bool {
+
+	switch command.(type) {
+	case *InstallCommand:
+
+		cmd := command.(*InstallCommand)
+		if lc := w.getLaunchContext(cmd.LaunchContext); lc == nil {
+			glog.Errorf(hplog(fmt.Sprintf("incoming event was not a known launch context: %T", cmd.LaunchContext)))
+		} else {
+			glog.V(5).Infof(hplog(fmt.Sprintf("LaunchContext(%T) for agreement: %v", lc, lc.AgreementId)))
+
+			// Check the deployment string to see if it's a host process deployment.
+			deploymentConfig := lc.ContainerConfig().Deployment
+			env := map[string]string{}
+			if lc.EnvironmentAdditions != nil {
+				env = *lc.EnvironmentAdditions
+			}
+
+			if wd, err := persistence.GetHostProcessDeployment(deploymentConfig); err != nil {
+				glog.V(5).Infof(hplog(fmt.Sprintf("ignoring non-host process deployment: %v", err)))
+				return true
+			} else if err := wd.Validate(); err != nil {
+				glog.Errorf(hplog(fmt.Sprintf("invalid host process deployment for agreement %v: %v", lc.AgreementId, err)))
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, wd)
+			} else if _, err := persistence.AgreementDeploymentStarted(w.db, lc.AgreementId, lc.AgreementProtocol, wd); err != nil {
+				glog.Errorf(hplog(fmt.Sprintf("received error updating database deployment state, %v", err)))
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, wd)
+			} else if err := w.processes.Start(lc.AgreementId, wd, env); err != nil {
+				glog.Errorf(hplog(fmt.Sprintf("failed to run the host process after agreement negotiation: %v", err)))
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, wd)
+			} else {
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_BEGUN, lc.AgreementProtocol, lc.AgreementId, wd)
+			}
+		}
+
+	case *UnInstallCommand:
+
+		cmd := command.(*UnInstallCommand)
+
+		// Make sure it's a host process deployment.
+		wdc, ok := cmd.Deployment.(*persistence.HostProcessDeploymentConfig)
+		if !ok {
+			glog.V(5).Infof(hplog(fmt.Sprintf("ignoring non-host process deployment: %v", cmd.Deployment)))
+			return true
+		}
+
+		glog.V(3).Infof(hplog(fmt.Sprintf("stopping the process of agreement %v", cmd.CurrentAgreementId)))
+		if err := w.processes.Stop(cmd.CurrentAgreementId); err != nil {
+			glog.Errorf(hplog(fmt.Sprintf("failed to stop the host process after agreement cancellation: %v", err)))
+		}
+
+		w.Messages() <- events.NewWorkloadMessage(events.WORKLOAD_DESTROYED, cmd.AgreementProtocol, cmd.CurrentAgreementId, wdc)
+
+	case *MaintenanceCommand:
+		cmd := command.(*MaintenanceCommand)
+
+		wdc, ok := cmd.Deployment.(*persistence.HostProcessDeploymentConfig)
+		if !ok {
+			glog.V(5).Infof(hplog(fmt.Sprintf("ignoring non-host process maintenance command: %v", cmd)))
+			return true
+		}
+
+		glog.V(3).Infof(hplog(fmt.Sprintf("received maintenance command: %v", cmd)))
+		if err := w.processes.Maintain(cmd.AgreementId); err != nil {
+			glog.Errorf(hplog(fmt.Sprintf("%v", err)))
+			// Ask governer to cancel the agreement.
+			w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, cmd.AgreementProtocol, cmd.AgreementId, wdc)
+		}
+
+	default:
+		return false
+	}
+	return true
+
+}
+
+func (w *HostProcessWorker) getLaunchContext(launchContext interface{}) *events.AgreementLaunchContext {
+	switch launchContext.(type) {
+	case *events.AgreementLaunchContext:
+		lc := launchContext.(*events.AgreementLaunchContext)
+		return lc
+	}
+	return nil
+}
+
+var hplog = func(v interface{}) string {
+	return fmt.Sprintf("Host Process Worker: %v", v)
+}
@@ -0,0 +1,194 @@
+package hostprocess
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/persistence"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The host processes are run as transient systemd service units, so that systemd runs them under their own user,
+// restarts them, limits their resources and sends their output to the journal, and they keep running when the agent
+// restarts, like the service containers.
+
+const SYSTEMD_RUN = "systemd-run"
+const SYSTEMCTL = "systemctl"
+
+// The directory that only exists when systemd is the init system of the host.
+const SYSTEMD_RUNTIME_DIR = "/run/systemd/system"
+
+// How long the process has to exit after it is sent a SIGTERM before it is killed, the same as the container engine.
+const STOP_TIMEOUT_S = 10
+
+// The delay before systemd restarts a process that exited.
+const RESTART_SEC = 1
+
+// The layout of the timestamps shown by systemctl.
+const SYSTEMD_TIMESTAMP_LAYOUT = "Mon 2006-01-02 15:04:05 MST"
+
+// The state of a unit, from systemctl show.
+type UnitStatus struct {
+	LoadState   string // loaded or not-found
+	ActiveState string // active, activating (e.g. waiting to be restarted), deactivating, inactive or failed
+	SubState    string // running, auto-restart, exited, ...
+	Started     int64  // the time the process entered the active state, in seconds since the epoch
+}
+
+func (s UnitStatus) String() string {
+	return fmt.Sprintf("LoadState: %v, ActiveState: %v, SubState: %v, Started: %v", s.LoadState, s.ActiveState, s.SubState, s.Started)
+}
+
+// Running returns true when the process is running or about to be restarted by systemd.
+func (s UnitStatus) Running() bool {
+	return s.ActiveState == "active" || s.ActiveState == "activating" || s.ActiveState == "reloading"
+}
+
+// Returns an error if the host cannot run the host processes.
+func CheckSystemd() error {
+	if _, err := os.Stat(SYSTEMD_RUNTIME_DIR); err != nil {
+		return errors.New(fmt.Sprintf("systemd is not the init system of the host: %v", err))
+	} else if _, err := exec.LookPath(SYSTEMD_RUN); err != nil {
+		return errors.New(fmt.Sprintf("%v is not installed: %v", SYSTEMD_RUN, err))
+	}
+	return nil
+}
+
+// UnitName returns the name of the unit that runs the process of an agreement.
+func UnitName(agId string) string {
+	return fmt.Sprintf("horizon-%v.service", strings.ToLower(agId))
+}
+
+// LogTag returns the syslog identifier of the output of the process of an agreement, the same tag as the log of a
+// service container so that hzn service log finds it.
+func LogTag(agId string) string {
+	return fmt.Sprintf("workload-%v_%v", strings.ToLower(agId), persistence.HOST_PROCESS_LOG_NAME)
+}
+
+// Starts the process of an agreement in a transient unit.
+func StartUnit(agId string, hd *persistence.HostProcessDeploymentConfig, binary string, envFile string) error {
+	args := runArgs(agId, hd, binary, envFile)
+	glog.V(5).Infof(hplog(fmt.Sprintf("running %v %v", SYSTEMD_RUN, args)))
+	if out, err := exec.Command(SYSTEMD_RUN, args...).CombinedOutput(); err != nil {
+		return errors.New(fmt.Sprintf("unable to start unit %v: %v, output: %v", UnitName(agId), err, strings.TrimSpace(string(out))))
+	}
+	return nil
+}
+
+// Stops the process of an agreement and unloads its unit, which is left loaded when the process failed.
+func StopUnit(agId string) error {
+	unit := UnitName(agId)
+	if out, err := exec.Command(SYSTEMCTL, "stop", unit).CombinedOutput(); err != nil {
+		return errors.New(fmt.Sprintf("unable to stop unit %v: %v, output: %v", unit, err, strings.TrimSpace(string(out))))
+	}
+	exec.Command(SYSTEMCTL, "reset-failed", unit).Run()
+	return nil
+}
+
+// Returns the state of the unit of an agreement.
+func GetUnitStatus(agId string) (*UnitStatus, error) {
+	unit := UnitName(agId)
+	out, err := exec.Command(SYSTEMCTL, "show", unit, "--property=LoadState,ActiveState,SubState,ActiveEnterTimestamp").Output()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to get the state of unit %v: %v", unit, err))
+	}
+	return parseUnitStatus(string(out)), nil
+}
+
+// Returns the systemd-run arguments that run the process of an agreement. The environment variables are read from a
+// file that only root can read, so that their values, e.g. the user input of the service, do not show in the
+// properties of the unit.
+func runArgs(agId string, hd *persistence.HostProcessDeploymentConfig, binary string, envFile string) []string {
+	args := []string{
+		"--unit=" + UnitName(agId),
+		"--description=Horizon service of agreement " + agId,
+		"--property=EnvironmentFile=" + envFile,
+		"--property=SyslogIdentifier=" + LogTag(agId),
+		fmt.Sprintf("--property=TimeoutStopSec=%v", STOP_TIMEOUT_S),
+		"--property=StateDirectory=" + strings.TrimSuffix(UnitName(agId), ".service"),
+		"--property=NoNewPrivileges=yes",
+	}
+
+	// A service that does not name its user gets a user allocated by systemd for as long as the process runs.
+	if hd.User != "" {
+		args = append(args, "--property=User="+hd.User)
+	} else {
+		args = append(args, "--property=DynamicUser=yes")
+	}
+
+	if hd.MaxMemoryMb != 0 {
+		args = append(args, fmt.Sprintf("--property=MemoryMax=%vM", hd.MaxMemoryMb))
+	}
+	if hd.MaxCPUs != 0 {
+		args = append(args, fmt.Sprintf("--property=CPUQuota=%v%%", int(hd.MaxCPUs*100)))
+	}
+
+	args = append(args, restartProperties(hd.RestartPolicy)...)
+	args = append(args, "--", binary)
+	return append(args, hd.Args...)
+}
+
+// Maps the restart policy of the service to the restart settings of the unit. The process is restarted without a
+// limit, except with the on-failure policy and max_retries, after which systemd leaves the unit failed.
+func restartProperties(rp *containermessage.RestartPolicy) []string {
+	restart := "always"
+	limit := []string{"--property=StartLimitIntervalSec=0"}
+	if rp != nil {
+		switch rp.Name {
+		case containermessage.RESTART_POLICY_NO:
+			restart = "no"
+		case containermessage.RESTART_POLICY_ON_FAILURE:
+			restart = "on-failure"
+			if rp.MaxRetries != 0 {
+				limit = []string{"--property=StartLimitIntervalSec=infinity", fmt.Sprintf("--property=StartLimitBurst=%v", rp.MaxRetries+1)}
+			}
+		}
+	}
+	return append([]string{"--property=Restart=" + restart, fmt.Sprintf("--property=RestartSec=%v", RESTART_SEC)}, limit...)
+}
+
+// Returns the contents of an environment file that systemd reads the environment variables of the process from. The
+// values are double quoted, with the characters that systemd unescapes in double quotes escaped.
+func envFileContents(env map[string]string) string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", `$`, `\$`)
+	var contents strings.Builder
+	for _, name := range names {
+		contents.WriteString(fmt.Sprintf("%v=\"%v\"\n", name, escaper.Replace(env[name])))
+	}
+	return contents.String()
+}
+
+// Parses the output of systemctl show, one property=value per line.
+func parseUnitStatus(out string) *UnitStatus {
+	status := new(UnitStatus)
+	for _, line := range strings.Split(out, "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "LoadState":
+			status.LoadState = kv[1]
+		case "ActiveState":
+			status.ActiveState = kv[1]
+		case "SubState":
+			status.SubState = kv[1]
+		case "ActiveEnterTimestamp":
+			if t, err := time.Parse(SYSTEMD_TIMESTAMP_LAYOUT, kv[1]); err == nil {
+				status.Started = t.Unix()
+			}
+		}
+	}
+	return status
+}
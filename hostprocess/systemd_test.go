@@ -0,0 +1,96 @@
+//go:build unit
+// +build unit
+
+package hostprocess
+
+import (
+	"flag"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/persistence"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func init() {
+	// Enable glog tracing in the tested functions. The output will be displayed when -v is
+	// passed on the go test command.
+	flag.Set("alsologtostderr", "true")
+	flag.Set("v", "7")
+	// no need to parse flags, that's done by test framework
+}
+
+func Test_runArgs(t *testing.T) {
+
+	hd := &persistence.HostProcessDeploymentConfig{
+		Binary:      "f0VMRgIBAQ==",
+		Args:        []string{"--port", "8080"},
+		User:        "myservice",
+		MaxMemoryMb: 64,
+		MaxCPUs:     0.5,
+	}
+
+	args := runArgs("AAAA", hd, "/var/horizon/hostprocess/AAAA", "/var/horizon/hostprocess/AAAA.env")
+	expected := []string{
+		"--unit=horizon-aaaa.service",
+		"--description=Horizon service of agreement AAAA",
+		"--property=EnvironmentFile=/var/horizon/hostprocess/AAAA.env",
+		"--property=SyslogIdentifier=workload-aaaa_process",
+		"--property=TimeoutStopSec=10",
+		"--property=StateDirectory=horizon-aaaa",
+		"--property=NoNewPrivileges=yes",
+		"--property=User=myservice",
+		"--property=MemoryMax=64M",
+		"--property=CPUQuota=50%",
+		"--property=Restart=always",
+		"--property=RestartSec=1",
+		"--property=StartLimitIntervalSec=0",
+		"--", "/var/horizon/hostprocess/AAAA", "--port", "8080",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("wrong args %v, expected %v", args, expected)
+	}
+
+	// A dynamic user is allocated when the deployment does not name one.
+	hd.User = ""
+	if args := runArgs("AAAA", hd, "/bin/true", "/dev/null"); !strings.Contains(strings.Join(args, " "), "--property=DynamicUser=yes") {
+		t.Errorf("the args %v should allocate a dynamic user", args)
+	}
+}
+
+func Test_restartProperties(t *testing.T) {
+
+	if p := restartProperties(&containermessage.RestartPolicy{Name: containermessage.RESTART_POLICY_NO}); p[0] != "--property=Restart=no" {
+		t.Errorf("wrong restart properties %v for the no restart policy", p)
+	}
+
+	p := restartProperties(&containermessage.RestartPolicy{Name: containermessage.RESTART_POLICY_ON_FAILURE, MaxRetries: 3})
+	expected := []string{"--property=Restart=on-failure", "--property=RestartSec=1", "--property=StartLimitIntervalSec=infinity", "--property=StartLimitBurst=4"}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("wrong restart properties %v, expected %v", p, expected)
+	}
+}
+
+func Test_envFileContents(t *testing.T) {
+
+	env := map[string]string{"MY_INPUT": `say "hi" to $USER`, "HZN_AGREEMENTID": "aaaa"}
+	contents := envFileContents(env)
+	expected := "HZN_AGREEMENTID=\"aaaa\"\nMY_INPUT=\"say \\\"hi\\\" to \\$USER\"\n"
+	if contents != expected {
+		t.Errorf("wrong environment file %v, expected %v", contents, expected)
+	}
+}
+
+func Test_parseUnitStatus(t *testing.T) {
+
+	out := "LoadState=loaded\nActiveState=active\nSubState=running\nActiveEnterTimestamp=Thu 2026-10-15 10:20:30 UTC\n"
+	status := parseUnitStatus(out)
+	if status.LoadState != "loaded" || !status.Running() || status.SubState != "running" || status.Started != 1792059630 {
+		t.Errorf("wrong status %v parsed from %v", status, out)
+	}
+
+	out = "LoadState=not-found\nActiveState=inactive\nSubState=dead\nActiveEnterTimestamp=\n"
+	if status := parseUnitStatus(out); status.Running() || status.Started != 0 {
+		t.Errorf("wrong status %v parsed from %v", status, out)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/container"
 	"github.com/open-horizon/anax/containermessage"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/persistence"
@@ -18,6 +19,7 @@ type ImageFetchWorker struct {
 	worker.BaseWorker // embedded field
 	db                *bolt.DB
 	client            *docker.Client
+	ctrd              *container.ContainerdBackend
 }
 
 func NewImageFetchWorker(name string, config *config.HorizonConfig, db *bolt.DB) *ImageFetchWorker {
@@ -38,10 +40,20 @@ func NewImageFetchWorker(name string, config *config.HorizonConfig, db *bolt.DB)
 		}
 	}
 
+	var ctrd *container.ContainerdBackend
+	if config.IsContainerdRuntime() {
+		ctrd, err = container.NewContainerdBackend(config)
+		if err != nil {
+			glog.Errorf("Failed to instantiate containerd Client: %v", err)
+			panic("Unable to instantiate containerd Client")
+		}
+	}
+
 	worker := &ImageFetchWorker{
 		BaseWorker: worker.NewBaseWorker(name, config, nil),
 		db:         db,
 		client:     client,
+		ctrd:       ctrd,
 	}
 
 	worker.Start(worker, 0)
@@ -192,8 +204,8 @@ func processDeployment(cfg *config.HorizonConfig, containerConfig events.Contain
 	return pemFiles, &deploymentDesc, nil
 }
 
-func processFetch(cfg *config.HorizonConfig, client *docker.Client, db *bolt.DB, deploymentDesc *containermessage.DeploymentDescription, imageDockerAuths []events.ImageDockerAuth) error {
-	if client == nil {
+func processFetch(cfg *config.HorizonConfig, client *docker.Client, ctrd *container.ContainerdBackend, db *bolt.DB, deploymentDesc *containermessage.DeploymentDescription, imageDockerAuths []events.ImageDockerAuth, stats *events.ImagePullStats) error {
+	if client == nil && ctrd == nil {
 		return fmt.Errorf("Docker client is nil. Please make sure DockerEndpoint is set in the configuration file.")
 	}
 
@@ -211,17 +223,20 @@ func processFetch(cfg *config.HorizonConfig, client *docker.Client, db *bolt.DB,
 		glog.Errorf("Failed to fetch authentication facts from the attributes before processing packages and / or Docker pulls: %v. Continuing anyway", err)
 	}
 
-	return fetchImage(cfg, client, db, deploymentDesc, dockerAuthConfigurations)
+	if ctrd != nil {
+		return pullImagesWithContainerd(cfg.Edge, dockerAuthConfigurations, ctrd, deploymentDesc, stats)
+	}
+	return fetchImage(cfg, client, db, deploymentDesc, dockerAuthConfigurations, stats)
 }
 
-func fetchImage(cfg *config.HorizonConfig, client *docker.Client, db *bolt.DB, deploymentDesc *containermessage.DeploymentDescription, dockerAuthConfigurations map[string][]docker.AuthConfiguration) error {
+func fetchImage(cfg *config.HorizonConfig, client *docker.Client, db *bolt.DB, deploymentDesc *containermessage.DeploymentDescription, dockerAuthConfigurations map[string][]docker.AuthConfiguration, stats *events.ImagePullStats) error {
 
 	skipCheckFn := SkipCheckFn(client)
 	// using Docker pull (newer option, uses docker client to pull images from repos in image names in deployment description)
 	// Note: we don't want to make this a fallback option, it's a potential security vector
 	glog.V(3).Infof("Using Docker pull mechanism to retrieve and load Docker images into local registry")
 
-	fetchErr := pullImageFromRepos(cfg.Edge, dockerAuthConfigurations, client, &skipCheckFn, deploymentDesc)
+	fetchErr := pullImageFromRepos(cfg.Edge, dockerAuthConfigurations, client, &skipCheckFn, deploymentDesc, stats)
 	return fetchErr
 }
 
@@ -258,7 +273,7 @@ func ProcessImageFetch(cfg *config.HorizonConfig, client *docker.Client, contain
 		return fmt.Errorf("Error Unmarshalling deployment string %v, error: %v", containerConfig.Deployment, err)
 	}
 
-	return fetchImage(cfg, client, nil, &deploymentDesc, dockerAuthNew)
+	return fetchImage(cfg, client, nil, &deploymentDesc, dockerAuthNew, new(events.ImagePullStats))
 }
 
 func (b *ImageFetchWorker) CommandHandler(command worker.Command) bool {
@@ -294,7 +309,8 @@ func (b *ImageFetchWorker) CommandHandler(command worker.Command) bool {
 				return true
 			}
 
-			if fetchErr := processFetch(b.Config, b.client, b.db, deploymentDesc, lc.ContainerConfig().ImageDockerAuths); fetchErr != nil {
+			stats := new(events.ImagePullStats)
+			if fetchErr := processFetch(b.Config, b.client, b.ctrd, b.db, deploymentDesc, lc.ContainerConfig().ImageDockerAuths, stats); fetchErr != nil {
 				var id events.EventId
 				if strings.Contains(fetchErr.Error(), "Auth error") {
 					id = events.IMAGE_FETCH_AUTH_ERROR
@@ -304,7 +320,9 @@ func (b *ImageFetchWorker) CommandHandler(command worker.Command) bool {
 				glog.Errorf("Failed to fetch image files: %v", fetchErr)
 				b.Messages() <- events.NewImageFetchMessage(id, deploymentDesc, lc, fetchErr)
 			} else {
-				b.Messages() <- events.NewImageFetchMessage(events.IMAGE_FETCHED, deploymentDesc, lc, nil)
+				msg := events.NewImageFetchMessage(events.IMAGE_FETCHED, deploymentDesc, lc, nil)
+				msg.PullStats = stats
+				b.Messages() <- msg
 			}
 
 		}
@@ -3,11 +3,15 @@ package imagefetch
 import (
 	docker "github.com/fsouza/go-dockerclient"
 
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/container"
 	"github.com/open-horizon/anax/containermessage"
 	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/events"
 	"os"
 	"strings"
 	"time"
@@ -67,7 +71,7 @@ func authDockerFile(config config.Config, authConfigs map[string][]docker.AuthCo
 	return nil
 }
 
-func pullImageFromRepos(config config.Config, authConfigs map[string][]docker.AuthConfiguration, client *docker.Client, skipPartFetchFn *func(repotag string) (bool, error), deploymentDesc *containermessage.DeploymentDescription) error {
+func pullImageFromRepos(config config.Config, authConfigs map[string][]docker.AuthConfiguration, client *docker.Client, skipPartFetchFn *func(repotag string) (bool, error), deploymentDesc *containermessage.DeploymentDescription, stats *events.ImagePullStats) error {
 
 	// append docker auth from docker file
 	authDockerFile(config, authConfigs)
@@ -83,7 +87,19 @@ func pullImageFromRepos(config config.Config, authConfigs map[string][]docker.Au
 		if path == "" {
 			glog.Errorf("Invalid image name format specified: %v", service.Image)
 			return fmt.Errorf("Invalid image name format specified: %v", service.Image)
+		} else if err := checkImageDigest(config, name, service.Image, digest); err != nil {
+			glog.Errorf(err.Error())
+			return err
+		}
+		// An image referenced by its digest cannot change, so it is not pulled again when it is already on the node. This
+		// lets the services of a node registered with a bundle start without access to the image registry.
+		if digest != "" {
+			if _, err := client.InspectImage(service.Image); err == nil {
+				glog.V(3).Infof("Image %v for service %v is already on the node", service.Image, name)
+				continue
+			}
 		}
+
 		// the image name format is [[repo][:port]/][somedir/]image[:tag][@digest].
 		// tag and digest do not contain '/'
 		if digest != "" {
@@ -117,57 +133,223 @@ func pullImageFromRepos(config config.Config, authConfigs map[string][]docker.Au
 			}
 		}
 
-		// default the doman to docker io.
-		if domain == "" {
-			domain = "docker.io"
+		// get all the auths for this domain or repo.
+		auth_array := domainAuths(authConfigs, domain)
+
+		// try auths one at a time
+		var err error
+		for i, auth := range auth_array {
+			err = pullSingleImageFromRepo(client, opts, auth, stats)
+			if err == nil {
+				break
+			} else if i < len(auth_array)-1 {
+				glog.V(5).Infof("Docker image pull(s) failed for service %v docker image %v with auth name %v. Error: %v. Try next auth.", name, service.Image, auth.Username, err)
+			}
 		}
 
-		// get all the auths for this domain or repo.
-		auth_array := []docker.AuthConfiguration{}
-		for k, _ := range authConfigs {
-			// for "docker.io" repo, the repo string in ~/.docker/config.json is something like:
-			// "https://index.docker.io/v1/"
-			if k == domain || (domain == "docker.io" && strings.Contains(k, domain)) {
-				auth_array = append(auth_array, authConfigs[k]...)
+		// if all auths failed or no auth specified for this domain, try without auth
+		if err != nil || len(auth_array) == 0 {
+			glog.V(5).Infof("Pulling image %v without auth.", service.Image)
+			err = pullSingleImageFromRepo(client, opts, docker.AuthConfiguration{}, stats)
+		}
+
+		if err != nil {
+			glog.Errorf("Docker image pull(s) failed for docker image %v. Error: %v.", service.Image, err)
+			return err
+		} else {
+			glog.V(3).Infof("Succeeded fetching image %v for service %v", service.Image, name)
+		}
+
+		if pinImageDigest(config, digest) {
+			if pinned, err := dockerPinImage(client, service.Image); err != nil {
+				glog.Errorf("Unable to pin image %v of service %v to its digest. Error: %v.", service.Image, name, err)
+				return err
+			} else {
+				glog.V(3).Infof("Pinned image %v of service %v to %v", service.Image, name, pinned)
+				service.Image = pinned
 			}
 		}
+	}
+
+	return nil
+}
+
+// Returns an error if the image of a service is not referenced by digest and the image digest policy of the agent
+// requires it.
+func checkImageDigest(cfg config.Config, serviceName string, image string, digest string) error {
+	if digest == "" && cfg.GetImageDigestPolicy() == config.ImageDigestPolicy_REQUIRE {
+		return fmt.Errorf("Image %v of service %v is not referenced by digest, which the ImageDigestPolicy of the agent requires.", image, serviceName)
+	}
+	return nil
+}
+
+// Returns true if a pulled image that is referenced by tag is to be pinned to its digest.
+func pinImageDigest(cfg config.Config, digest string) bool {
+	return digest == "" && cfg.GetImageDigestPolicy() == config.ImageDigestPolicy_PIN
+}
+
+// Returns the reference of a pulled docker image by its repo digest, so that the container is created from the image
+// that was pulled even if its tag is moved to another image later.
+func dockerPinImage(client *docker.Client, image string) (string, error) {
+	img, err := client.InspectImage(image)
+	if err != nil {
+		return "", err
+	}
+
+	domain, path, _, _ := cutil.ParseDockerImagePath(image)
+	repo := cutil.FormDockerImageName(domain, path, "", "")
+	if pinned := repoDigest(repo, img.RepoDigests); pinned != "" {
+		return pinned, nil
+	}
+	return "", fmt.Errorf("image %v has no digest for repository %v, repo digests are %v", image, repo, img.RepoDigests)
+}
+
+// Returns the repo digest of an image for the given repository. Docker names the images of docker hub without their
+// domain and library path, and podman with them.
+func repoDigest(repo string, repoDigests []string) string {
+	familiar := func(r string) string {
+		for _, prefix := range []string{"docker.io/", "index.docker.io/", "library/"} {
+			r = strings.TrimPrefix(r, prefix)
+		}
+		return r
+	}
+
+	for _, rd := range repoDigests {
+		if parts := strings.SplitN(rd, "@", 2); len(parts) == 2 && familiar(parts[0]) == familiar(repo) {
+			return rd
+		}
+	}
+	return ""
+}
+
+// Returns the auths for the domain of an image, the domain defaults to docker io.
+func domainAuths(authConfigs map[string][]docker.AuthConfiguration, domain string) []docker.AuthConfiguration {
+	if domain == "" {
+		domain = "docker.io"
+	}
+
+	auth_array := []docker.AuthConfiguration{}
+	for k, _ := range authConfigs {
+		// for "docker.io" repo, the repo string in ~/.docker/config.json is something like:
+		// "https://index.docker.io/v1/"
+		if k == domain || (domain == "docker.io" && strings.Contains(k, domain)) {
+			auth_array = append(auth_array, authConfigs[k]...)
+		}
+	}
+	return auth_array
+}
+
+// Pull the images of the deployment into containerd. The auths are tried the same way as for docker.
+func pullImagesWithContainerd(config config.Config, authConfigs map[string][]docker.AuthConfiguration, ctrd *container.ContainerdBackend, deploymentDesc *containermessage.DeploymentDescription, stats *events.ImagePullStats) error {
+
+	// append docker auth from docker file
+	authDockerFile(config, authConfigs)
+
+	for name, service := range deploymentDesc.Services {
+
+		glog.V(3).Infof("Pulling image %v for service %v with containerd", service.Image, name)
+
+		domain, path, _, digest := cutil.ParseDockerImagePath(service.Image)
+		if path == "" {
+			glog.Errorf("Invalid image name format specified: %v", service.Image)
+			return fmt.Errorf("Invalid image name format specified: %v", service.Image)
+		} else if err := checkImageDigest(config, name, service.Image, digest); err != nil {
+			glog.Errorf(err.Error())
+			return err
+		}
 
 		// try auths one at a time
+		auth_array := domainAuths(authConfigs, domain)
 		var err error
 		for i, auth := range auth_array {
-			err = pullSingleImageFromRepo(client, opts, auth)
+			err = pullSingleImageWithContainerd(ctrd, service.Image, auth, stats)
 			if err == nil {
 				break
 			} else if i < len(auth_array)-1 {
-				glog.V(5).Infof("Docker image pull(s) failed for service %v docker image %v with auth name %v. Error: %v. Try next auth.", name, service.Image, auth.Username, err)
+				glog.V(5).Infof("Image pull(s) failed for service %v image %v with auth name %v. Error: %v. Try next auth.", name, service.Image, auth.Username, err)
 			}
 		}
 
 		// if all auths failed or no auth specified for this domain, try without auth
 		if err != nil || len(auth_array) == 0 {
 			glog.V(5).Infof("Pulling image %v without auth.", service.Image)
-			err = pullSingleImageFromRepo(client, opts, docker.AuthConfiguration{})
+			err = pullSingleImageWithContainerd(ctrd, service.Image, docker.AuthConfiguration{}, stats)
 		}
 
 		if err != nil {
-			glog.Errorf("Docker image pull(s) failed for docker image %v. Error: %v.", service.Image, err)
+			glog.Errorf("Image pull(s) failed for image %v. Error: %v.", service.Image, err)
 			return err
 		} else {
 			glog.V(3).Infof("Succeeded fetching image %v for service %v", service.Image, name)
 		}
+
+		if pinImageDigest(config, digest) {
+			if pinned, err := ctrd.PinImage(service.Image); err != nil {
+				glog.Errorf("Unable to pin image %v of service %v to its digest. Error: %v.", service.Image, name, err)
+				return err
+			} else {
+				glog.V(3).Infof("Pinned image %v of service %v to %v", service.Image, name, pinned)
+				service.Image = pinned
+			}
+		}
 	}
 
 	return nil
 }
 
+// Returns true if an image pull failed because the registry did not accept the auth, e.g. because its token expired or
+// was rotated. The pull is then not retried with the same auth.
+func isAuthError(msg string) bool {
+	msg = strings.ToLower(msg)
+	for _, s := range []string{"cred", "unauthorized", "403 forbidden", "denied", "authentication required"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// This function try maxPullAttempts times to pull the image into containerd. It exits out imediately if there is auth error.
+// The layers of the image are added to the stats.
+func pullSingleImageWithContainerd(ctrd *container.ContainerdBackend, image string, auth docker.AuthConfiguration, stats *events.ImagePullStats) error {
+	glog.V(5).Infof("Pulling image %v with auth name %v.", image, auth.Username)
+
+	var err error
+	for pullAttempts := 1; pullAttempts <= maxPullAttempts; pullAttempts++ {
+		var imageStats *events.ImagePullStats
+		if imageStats, err = ctrd.PullImage(image, auth); err == nil {
+			glog.V(3).Infof("Pulled image %v: %v", image, imageStats)
+			stats.Add(*imageStats)
+			return nil
+		} else if isAuthError(err.Error()) {
+			return fmt.Errorf("Auth error. Msg: Aborting fetch of image %v., InternalError: %v.", image, err)
+		} else if pullAttempts != maxPullAttempts {
+			glog.V(5).Infof("Waiting %d seconds before retry. Error: %v", pullAttemptDelayS, err)
+			time.Sleep(pullAttemptDelayS * time.Second)
+		}
+	}
+	glog.V(5).Infof("Max pull attempts reached (%d) for fetching image %v. Error: %v", maxPullAttempts, image, err)
+	return err
+}
+
 // This function try maxPullAttempts times to pull the image from the repo. It exits out imediately if there is auth error.
-func pullSingleImageFromRepo(client *docker.Client, opts docker.PullImageOptions, auth docker.AuthConfiguration) error {
+// The layers of the image are added to the stats.
+func pullSingleImageFromRepo(client *docker.Client, opts docker.PullImageOptions, auth docker.AuthConfiguration, stats *events.ImagePullStats) error {
 	glog.V(5).Infof("Pulling image %v with auth name %v.", opts, auth.Username)
 
+	// the progress messages of the pull tell which layers were downloaded
+	var output bytes.Buffer
+	opts.OutputStream = &output
+	opts.RawJSONStream = true
+
 	var pullAttempts int
 
 	for pullAttempts <= maxPullAttempts {
+		output.Reset()
 		if err := client.PullImage(opts, auth); err == nil {
+			imageStats := dockerPullStats(output.Bytes())
+			glog.V(3).Infof("Pulled image %v:%v: %v", opts.Repository, opts.Tag, imageStats)
+			stats.Add(imageStats)
 			return nil
 		} else {
 			pullAttempts++
@@ -176,7 +358,7 @@ func pullSingleImageFromRepo(client *docker.Client, opts docker.PullImageOptions
 			switch err.(type) {
 			case *docker.Error:
 				dErr := err.(*docker.Error)
-				if strings.Contains(dErr.Message, "cred") {
+				if isAuthError(dErr.Message) {
 					msg := fmt.Sprintf("Aborting fetch of Docker image %v.", opts.Repository)
 					return fmt.Errorf("Auth error. Msg: %v, InternalError: %v.", msg, dErr)
 				}
@@ -204,6 +386,49 @@ func pullSingleImageFromRepo(client *docker.Client, opts docker.PullImageOptions
 	return nil
 }
 
+// A progress message of a docker image pull. The id of a layer message is the short digest of the layer.
+type dockerPullMessage struct {
+	Status         string `json:"status"`
+	ID             string `json:"id"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+}
+
+// Returns the layers of a docker image pull from its progress messages. Docker only downloads the layers that are not
+// already on the node, but it does not tell their size, so the saved bytes are not known.
+func dockerPullStats(output []byte) events.ImagePullStats {
+	downloaded := make(map[string]int64)
+	reused := make(map[string]bool)
+
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	for {
+		var msg dockerPullMessage
+		if err := decoder.Decode(&msg); err != nil {
+			break
+		}
+		switch msg.Status {
+		case "Already exists":
+			reused[msg.ID] = true
+		case "Pulling fs layer", "Waiting":
+			if _, ok := downloaded[msg.ID]; !ok {
+				downloaded[msg.ID] = 0
+			}
+		case "Downloading":
+			if msg.ProgressDetail.Total > downloaded[msg.ID] {
+				downloaded[msg.ID] = msg.ProgressDetail.Total
+			}
+		}
+	}
+
+	stats := events.ImagePullStats{Layers: len(downloaded) + len(reused), ReusedLayers: len(reused)}
+	for _, size := range downloaded {
+		stats.DownloadedBytes += size
+	}
+	return stats
+}
+
 func listImages(client *docker.Client) ([]docker.APIImages, error) {
 
 	if images, err := client.ListImages(docker.ListImagesOptions{
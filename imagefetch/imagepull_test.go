@@ -62,3 +62,79 @@ func Test_authDockerFile(t *testing.T) {
 	assert.Equal(t, 1, len(dockerAuthConfigurations["myrepo3.com"]), "The docker auth array should have 1 items.")
 
 }
+
+func Test_dockerPullStats(t *testing.T) {
+
+	output := `{"status":"Pulling from myorg/myservice","id":"1.2.0"}
+{"status":"Already exists","progressDetail":{},"id":"31e352740f53"}
+{"status":"Already exists","progressDetail":{},"id":"5e8bd2b4f1f5"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"a0b1c2d3e4f5"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"b1c2d3e4f5a6"}
+{"status":"Waiting","progressDetail":{},"id":"b1c2d3e4f5a6"}
+{"status":"Downloading","progressDetail":{"current":512,"total":2048},"progress":"[====>   ]","id":"a0b1c2d3e4f5"}
+{"status":"Downloading","progressDetail":{"current":2048,"total":2048},"progress":"[========>]","id":"a0b1c2d3e4f5"}
+{"status":"Download complete","progressDetail":{},"id":"a0b1c2d3e4f5"}
+{"status":"Downloading","progressDetail":{"current":100,"total":300},"progress":"[==>     ]","id":"b1c2d3e4f5a6"}
+{"status":"Pull complete","progressDetail":{},"id":"a0b1c2d3e4f5"}
+{"status":"Pull complete","progressDetail":{},"id":"b1c2d3e4f5a6"}
+{"status":"Digest: sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"}
+{"status":"Status: Downloaded newer image for myorg/myservice:1.2.0"}
+`
+	stats := dockerPullStats([]byte(output))
+	assert.Equal(t, 4, stats.Layers, "the image should have 4 layers.")
+	assert.Equal(t, 2, stats.ReusedLayers, "2 layers should have been reused.")
+	assert.Equal(t, 2, stats.DownloadedLayers(), "2 layers should have been downloaded.")
+	assert.Equal(t, int64(2348), stats.DownloadedBytes, "the size of the downloaded layers is wrong.")
+	assert.Equal(t, int64(0), stats.SavedBytes, "docker does not tell the size of the reused layers.")
+
+	// an image that is up to date has no layer messages
+	stats = dockerPullStats([]byte(`{"status":"Pulling from myorg/myservice","id":"1.2.0"}
+{"status":"Status: Image is up to date for myorg/myservice:1.2.0"}
+`))
+	assert.Equal(t, 0, stats.Layers, "an image that is up to date should have no layers.")
+}
+
+func Test_isAuthError(t *testing.T) {
+	for _, msg := range []string{
+		"Get https://myrepo1.com/v2/: unauthorized: authentication required",
+		"pull access denied for myrepo1.com/app, repository does not exist or may require 'docker login'",
+		"failed to fetch anonymous token: 401 Unauthorized",
+		"unexpected status: 403 Forbidden",
+		"no basic auth credentials",
+	} {
+		assert.True(t, isAuthError(msg), msg)
+	}
+	for _, msg := range []string{
+		"manifest for myrepo1.com/app:1.0 not found",
+		"dial tcp: lookup myrepo1.com: no such host",
+	} {
+		assert.False(t, isAuthError(msg), msg)
+	}
+}
+
+func Test_repoDigest(t *testing.T) {
+	dgst := "sha256:6d4c6b1b5dfcd2d7b84f9d4b0b9f1e4e3a2c1b0a9f8e7d6c5b4a39281706f5e4"
+
+	assert.Equal(t, "ubuntu@"+dgst, repoDigest("ubuntu", []string{"ubuntu@" + dgst}))
+	assert.Equal(t, "docker.io/library/ubuntu@"+dgst, repoDigest("ubuntu", []string{"docker.io/library/ubuntu@" + dgst}))
+	assert.Equal(t, "ubuntu@"+dgst, repoDigest("docker.io/library/ubuntu", []string{"ubuntu@" + dgst}))
+	assert.Equal(t, "myrepo1.com:5000/a/b@"+dgst, repoDigest("myrepo1.com:5000/a/b", []string{"myrepo2.com/a/b@sha256:0123", "myrepo1.com:5000/a/b@" + dgst}))
+	assert.Equal(t, "", repoDigest("myrepo1.com/a/b", []string{"myrepo2.com/a/b@" + dgst}))
+	assert.Equal(t, "", repoDigest("ubuntu", nil))
+}
+
+func Test_checkImageDigest(t *testing.T) {
+	cfg := config.Config{}
+	assert.Nil(t, checkImageDigest(cfg, "svc1", "myrepo1.com/a/b:1.0", ""))
+	assert.False(t, pinImageDigest(cfg, ""))
+
+	cfg.ImageDigestPolicy = "Require"
+	assert.NotNil(t, checkImageDigest(cfg, "svc1", "myrepo1.com/a/b:1.0", ""))
+	assert.Nil(t, checkImageDigest(cfg, "svc1", "myrepo1.com/a/b@sha256:0123", "sha256:0123"))
+	assert.False(t, pinImageDigest(cfg, ""))
+
+	cfg.ImageDigestPolicy = "pin"
+	assert.Nil(t, checkImageDigest(cfg, "svc1", "myrepo1.com/a/b:1.0", ""))
+	assert.True(t, pinImageDigest(cfg, ""))
+	assert.False(t, pinImageDigest(cfg, "sha256:0123"))
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/cutil"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
@@ -25,7 +26,7 @@ import (
 
 type APIObjectInterface interface {
 	Install(c KubeClient, namespace string) error
-	Uninstall(c KubeClient, namespace string)
+	Uninstall(c KubeClient, namespace string) error
 	Status(c KubeClient, namespace string) (interface{}, error)
 	Name() string
 }
@@ -43,8 +44,15 @@ func sortAPIObjects(allObjects []APIObjects, customResources map[string][]*unstr
 		}
 	}
 
+	// get the replica policy from metadata
+	replicaPolicy, err := GetReplicaPolicy(metadata)
+	if err != nil {
+		return nil, namespace, err
+	}
+
 	// parse operator
 	objMap := map[string][]APIObjectInterface{}
+	crdKinds := []string{}
 	for _, obj := range allObjects {
 		switch obj.Type.Kind {
 		case K8S_NAMESPACE_TYPE:
@@ -96,7 +104,7 @@ func sortAPIObjects(allObjects []APIObjects, customResources map[string][]*unstr
 						return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: multiple namespaces specified in operator: %s and %s", namespace, typedDeployment.ObjectMeta.Namespace)))
 					}
 				}
-				newDeployment := DeploymentAppsV1{DeploymentObject: typedDeployment, EnvVarMap: envVarMap, AgreementId: agreementId}
+				newDeployment := DeploymentAppsV1{DeploymentObject: typedDeployment, EnvVarMap: envVarMap, AgreementId: agreementId, ReplicaPolicy: replicaPolicy}
 				if newDeployment.Name() != "" {
 					glog.V(4).Infof(kwlog(fmt.Sprintf("Found kubernetes deployment object %s.", newDeployment.Name())))
 					objMap[K8S_DEPLOYMENT_TYPE] = append(objMap[K8S_DEPLOYMENT_TYPE], newDeployment)
@@ -106,6 +114,18 @@ func sortAPIObjects(allObjects []APIObjects, customResources map[string][]*unstr
 			} else {
 				return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: deployment object has unrecognized type %T: %v", obj.Object, obj.Object)))
 			}
+		case K8S_HPA_TYPE:
+			if typedHPA, ok := obj.Object.(*autoscalingv2.HorizontalPodAutoscaler); ok {
+				newHPA := HorizontalPodAutoscalerV2{HPAObject: typedHPA, ReplicaPolicy: replicaPolicy}
+				if newHPA.Name() != "" {
+					glog.V(4).Infof(kwlog(fmt.Sprintf("Found kubernetes horizontal pod autoscaler object %s.", newHPA.Name())))
+					objMap[K8S_HPA_TYPE] = append(objMap[K8S_HPA_TYPE], newHPA)
+				} else {
+					return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: horizontal pod autoscaler object must have a name in its metadata section.")))
+				}
+			} else {
+				return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: horizontal pod autoscaler object has unrecognized type %T: %v", obj.Object, obj.Object)))
+			}
 		case K8S_SERVICEACCOUNT_TYPE:
 			if typedServiceAccount, ok := obj.Object.(*corev1.ServiceAccount); ok {
 				newServiceAccount := ServiceAccountCoreV1{ServiceAccountObject: typedServiceAccount}
@@ -124,11 +144,23 @@ func sortAPIObjects(allObjects []APIObjects, customResources map[string][]*unstr
 				if kind == "" {
 					return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: custom resource definition object missing kind field.", obj.Object)))
 				}
+				crdKinds = append(crdKinds, kind)
 				customResourceList, ok := customResources[kind]
 				if !ok {
 					return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: no custom resource object with kind %v found in %v.", kind, customResources)))
 				}
-				newCustomResource := CustomResourceV1Beta1{CustomResourceDefinitionObject: typedCRD, CustomResourceObjectList: customResourceList, InstallTimeout: crInstallTimeout}
+				if typedCRD.Name == "" {
+					return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: custom resource definition object must have a name in its metadata section.")))
+				}
+				// install the v1 equivalent when possible, newer clusters do not serve v1beta1
+				v1CRD, convErr := convertCRDV1Beta1ToV1(typedCRD)
+				if convErr == nil {
+					glog.V(4).Infof(kwlog(fmt.Sprintf("Found kubernetes custom resource definition object %s, converted from %v to %v.", typedCRD.Name, crdv1beta1.SchemeGroupVersion, crdv1.SchemeGroupVersion)))
+					objMap[K8S_CRD_TYPE] = append(objMap[K8S_CRD_TYPE], CustomResourceV1{CustomResourceDefinitionObject: v1CRD, CustomResourceObjectList: customResourceList, InstallTimeout: crInstallTimeout})
+					continue
+				}
+				glog.Warningf(kwlog(fmt.Sprintf("unable to convert custom resource definition %v to %v: %v", typedCRD.Name, crdv1.SchemeGroupVersion, convErr)))
+				newCustomResource := CustomResourceV1Beta1{CustomResourceDefinitionObject: typedCRD, CustomResourceObjectList: customResourceList, InstallTimeout: crInstallTimeout, ConversionError: convErr.Error()}
 				if newCustomResource.Name() != "" {
 					glog.V(4).Infof(kwlog(fmt.Sprintf("Found kubernetes custom resource definition object %s.", newCustomResource.Name())))
 					objMap[K8S_CRD_TYPE] = append(objMap[K8S_CRD_TYPE], newCustomResource)
@@ -140,6 +172,7 @@ func sortAPIObjects(allObjects []APIObjects, customResources map[string][]*unstr
 				if kind == "" {
 					return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: custom resource definition object missing kind field.", obj.Object)))
 				}
+				crdKinds = append(crdKinds, kind)
 				customResourceList, ok := customResources[kind]
 				if !ok {
 					return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: no custom resource object with kind %v found in %v.", kind, customResources)))
@@ -160,6 +193,29 @@ func sortAPIObjects(allObjects []APIObjects, customResources map[string][]*unstr
 		}
 	}
 
+	// the prometheus-operator kinds are custom resources whose CRDs are already in the cluster. They are only
+	// handled here if the operator does not ship the CRD for them itself.
+	for _, kind := range getMonitoringK8sKinds() {
+		if cutil.SliceContains(crdKinds, kind) {
+			continue
+		}
+		for _, monitor := range customResources[kind] {
+			gv, err := schema.ParseGroupVersion(monitor.GetAPIVersion())
+			if err != nil {
+				return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: failed to parse group version %s of %v object: %v", monitor.GetAPIVersion(), kind, err)))
+			} else if gv.Group != K8S_MONITORING_GROUP {
+				continue
+			}
+			newMonitor := OtherObject{Object: monitor, GVK: &schema.GroupVersionKind{Group: gv.Group, Version: gv.Version, Kind: kind}}
+			if newMonitor.Name() != "" {
+				glog.V(4).Infof(kwlog(fmt.Sprintf("Found %v object %s.", kind, newMonitor.Name())))
+				objMap[kind] = append(objMap[kind], newMonitor)
+			} else {
+				return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: %v object must have a name in its metadata section.", kind)))
+			}
+		}
+	}
+
 	return objMap, namespace, nil
 }
 
@@ -187,7 +243,7 @@ func (o OtherObject) Install(c KubeClient, namespace string) error {
 	return nil
 }
 
-func (o OtherObject) Uninstall(c KubeClient, namespace string) {
+func (o OtherObject) Uninstall(c KubeClient, namespace string) error {
 	name := o.Name()
 	glog.V(3).Infof(kwlog(fmt.Sprintf("attempting to delete object %v with GroupVersionResource %v", name, o.gvr())))
 
@@ -197,11 +253,10 @@ func (o OtherObject) Uninstall(c KubeClient, namespace string) {
 		glog.V(3).Infof(kwlog(fmt.Sprintf("successfully deleted namespaced object %v with GroupVersionResource %v", name, o.gvr())))
 	} else if err2 := dynClient.Delete(context.Background(), name, metav1.DeleteOptions{}); err2 == nil {
 		glog.V(3).Infof(kwlog(fmt.Sprintf("successfully deleted cluster-wide object %v with GroupVersionResource %v", name, o.gvr())))
-	} else {
-		glog.Errorf(kwlog(fmt.Sprintf("Failed to uninstall %v object %v: %v, %v", o.gvr(), name, err1, err2)))
+	} else if !errors.IsNotFound(err1) || !errors.IsNotFound(err2) {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Failed to uninstall %v object %v: %v, %v", o.gvr(), name, err1, err2)))
 	}
-
-	glog.V(3).Infof(kwlog(fmt.Sprintf("successfully deleted object %v with GroupVersionResource %v", name, o.GVK)))
+	return nil
 }
 
 func (o OtherObject) Name() string {
@@ -225,23 +280,66 @@ type NamespaceCoreV1 struct {
 func (n NamespaceCoreV1) Install(c KubeClient, namespace string) error {
 	glog.V(3).Infof(kwlog(fmt.Sprintf("attempting to create namespace %v", n.NamespaceObject)))
 	_, err := c.Client.CoreV1().Namespaces().Create(context.Background(), n.NamespaceObject, metav1.CreateOptions{})
-	if err != nil {
-		// If the namespace already exists this is not a problem
+	if err != nil && errors.IsAlreadyExists(err) {
+		// If the namespace already exists this is not a problem, but it needs the labels and annotations of the operator
+		return n.updateMetadata(c)
+	} else if err != nil {
 		glog.Warningf(kwlog(fmt.Sprintf("Failed to create namespace %s. Continuing with installation.", n.Name())))
 	}
 	return nil
 }
 
-func (n NamespaceCoreV1) Uninstall(c KubeClient, namespace string) {
+// updateMetadata sets the labels and annotations of the namespace object on the namespace that exists in the cluster
+func (n NamespaceCoreV1) updateMetadata(c KubeClient) error {
+	if len(n.NamespaceObject.Labels) == 0 && len(n.NamespaceObject.Annotations) == 0 {
+		return nil
+	}
+	live, err := c.Client.CoreV1().Namespaces().Get(context.Background(), n.Name(), metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error reading namespace %s to set its labels and annotations: %v", n.Name(), err)))
+	}
+
+	changed := false
+	for key, value := range n.NamespaceObject.Labels {
+		if current, ok := live.Labels[key]; !ok || current != value {
+			if live.Labels == nil {
+				live.Labels = map[string]string{}
+			}
+			live.Labels[key] = value
+			changed = true
+		}
+	}
+	for key, value := range n.NamespaceObject.Annotations {
+		if current, ok := live.Annotations[key]; !ok || current != value {
+			if live.Annotations == nil {
+				live.Annotations = map[string]string{}
+			}
+			live.Annotations[key] = value
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	glog.V(3).Infof(kwlog(fmt.Sprintf("setting labels %v and annotations %v on existing namespace %s", n.NamespaceObject.Labels, n.NamespaceObject.Annotations, n.Name())))
+	if _, err := c.Client.CoreV1().Namespaces().Update(context.Background(), live, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error setting the labels and annotations of namespace %s: %v", n.Name(), err)))
+	}
+	return nil
+}
+
+func (n NamespaceCoreV1) Uninstall(c KubeClient, namespace string) error {
 	if namespace == cutil.GetClusterNamespace() {
 		glog.V(3).Infof(kwlog(fmt.Sprintf("skipping deletion of namespace used by agent %v", n.NamespaceObject)))
-		return
+		return nil
 	}
 	glog.V(3).Infof(kwlog(fmt.Sprintf("deleting namespace %v", n.NamespaceObject)))
 	err := c.Client.CoreV1().Namespaces().Delete(context.Background(), n.Name(), metav1.DeleteOptions{})
-	if err != nil {
-		glog.Errorf(kwlog(fmt.Sprintf("unable to delete namespace %s. Error: %v", n.Name(), err)))
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf(kwlog(fmt.Sprintf("unable to delete namespace %s. Error: %v", n.Name(), err)))
 	}
+	return nil
 }
 
 func (n NamespaceCoreV1) Status(c KubeClient, namespace string) (interface{}, error) {
@@ -275,12 +373,13 @@ func (r RoleRbacV1) Install(c KubeClient, namespace string) error {
 	return nil
 }
 
-func (r RoleRbacV1) Uninstall(c KubeClient, namespace string) {
+func (r RoleRbacV1) Uninstall(c KubeClient, namespace string) error {
 	glog.V(3).Infof(kwlog(fmt.Sprintf("deleting role %s", r.Name())))
 	err := c.Client.RbacV1().Roles(namespace).Delete(context.Background(), r.Name(), metav1.DeleteOptions{})
-	if err != nil {
-		glog.Errorf(kwlog(fmt.Sprintf("unable to delete role %s. Error: %v", r.Name(), err)))
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf(kwlog(fmt.Sprintf("unable to delete role %s. Error: %v", r.Name(), err)))
 	}
+	return nil
 }
 
 func (r RoleRbacV1) Status(c KubeClient, namespace string) (interface{}, error) {
@@ -310,12 +409,13 @@ func (rb RolebindingRbacV1) Install(c KubeClient, namespace string) error {
 	return nil
 }
 
-func (rb RolebindingRbacV1) Uninstall(c KubeClient, namespace string) {
+func (rb RolebindingRbacV1) Uninstall(c KubeClient, namespace string) error {
 	glog.V(3).Infof(kwlog(fmt.Sprintf("deleting role binding %s", rb.RolebindingObject.ObjectMeta.Name)))
 	err := c.Client.RbacV1().RoleBindings(namespace).Delete(context.Background(), rb.RolebindingObject.ObjectMeta.Name, metav1.DeleteOptions{})
-	if err != nil {
-		glog.Errorf(kwlog(fmt.Sprintf("unable to delete role binding %s. Error: %v", rb.RolebindingObject.ObjectMeta.Name, err)))
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf(kwlog(fmt.Sprintf("unable to delete role binding %s. Error: %v", rb.RolebindingObject.ObjectMeta.Name, err)))
 	}
+	return nil
 }
 
 func (rb RolebindingRbacV1) Status(c KubeClient, namespace string) (interface{}, error) {
@@ -344,12 +444,13 @@ func (sa ServiceAccountCoreV1) Install(c KubeClient, namespace string) error {
 	return nil
 }
 
-func (sa ServiceAccountCoreV1) Uninstall(c KubeClient, namespace string) {
+func (sa ServiceAccountCoreV1) Uninstall(c KubeClient, namespace string) error {
 	glog.V(3).Infof(kwlog(fmt.Sprintf("deleting service account %s", sa.ServiceAccountObject.ObjectMeta.Name)))
 	err := c.Client.CoreV1().ServiceAccounts(namespace).Delete(context.Background(), sa.ServiceAccountObject.ObjectMeta.Name, metav1.DeleteOptions{})
-	if err != nil {
-		glog.Errorf(kwlog(fmt.Sprintf("unable to delete service account %s. Error: %v", sa.ServiceAccountObject.ObjectMeta.Name, err)))
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf(kwlog(fmt.Sprintf("unable to delete service account %s. Error: %v", sa.ServiceAccountObject.ObjectMeta.Name, err)))
 	}
+	return nil
 }
 
 func (sa ServiceAccountCoreV1) Status(c KubeClient, namespace string) (interface{}, error) {
@@ -367,6 +468,8 @@ type DeploymentAppsV1 struct {
 	DeploymentObject *appsv1.Deployment
 	EnvVarMap        map[string]string
 	AgreementId      string
+	ReplicaPolicy    *ReplicaPolicy
+	ServiceSecrets   map[string][]byte
 }
 
 func (d DeploymentAppsV1) Install(c KubeClient, namespace string) error {
@@ -385,8 +488,21 @@ func (d DeploymentAppsV1) Install(c KubeClient, namespace string) error {
 		return err
 	}
 
+	// Create the secret holding the service secrets bound in the deployment policy or pattern.
+	secretName := ""
+	if len(d.ServiceSecrets) > 0 {
+		secretName, err = c.CreateServiceSecret(d.ServiceSecrets, d.AgreementId, namespace)
+		if err != nil && errors.IsAlreadyExists(err) {
+			c.deleteServiceSecret(d.AgreementId, namespace)
+			secretName, err = c.CreateServiceSecret(d.ServiceSecrets, d.AgreementId, namespace)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
 	// Let the operator know about the config map
-	dWithEnv := addConfigMapVarToDeploymentObject(*d.DeploymentObject, mapName)
+	dWithEnv := d.resolve(mapName, secretName)
 	_, err = c.Client.AppsV1().Deployments(namespace).Create(context.Background(), &dWithEnv, metav1.CreateOptions{})
 	if err != nil && errors.IsAlreadyExists(err) {
 		d.Uninstall(c, namespace)
@@ -399,20 +515,44 @@ func (d DeploymentAppsV1) Install(c KubeClient, namespace string) error {
 	return nil
 }
 
-func (d DeploymentAppsV1) Uninstall(c KubeClient, namespace string) {
+// return the deployment as it is created in the cluster, with the reference to the env var config map, the service
+// secrets mounted if there is a secret name, and the initial replica count kept within the bounds of the replica policy
+func (d DeploymentAppsV1) resolve(configMapName string, secretName string) appsv1.Deployment {
+	dWithEnv := addConfigMapVarToDeploymentObject(*d.DeploymentObject.DeepCopy(), configMapName)
+	if secretName != "" {
+		dWithEnv = addServiceSecretToDeploymentObject(dWithEnv, secretName)
+	}
+
+	if d.ReplicaPolicy != nil {
+		replicas := int32(1)
+		if dWithEnv.Spec.Replicas != nil {
+			replicas = *dWithEnv.Spec.Replicas
+		}
+		replicas = d.ReplicaPolicy.Clamp(replicas)
+		dWithEnv.Spec.Replicas = &replicas
+	}
+	return dWithEnv
+}
+
+func (d DeploymentAppsV1) Uninstall(c KubeClient, namespace string) error {
 	glog.V(3).Infof(kwlog(fmt.Sprintf("deleting deployment %s", d.DeploymentObject.ObjectMeta.Name)))
+	var uninstallErr error
 	err := c.Client.AppsV1().Deployments(namespace).Delete(context.Background(), d.DeploymentObject.ObjectMeta.Name, metav1.DeleteOptions{})
-	if err != nil {
-		glog.Errorf(kwlog(fmt.Sprintf("unable to delete deployment %s. Error: %v", d.DeploymentObject.ObjectMeta.Name, err)))
+	if err != nil && !errors.IsNotFound(err) {
+		uninstallErr = fmt.Errorf(kwlog(fmt.Sprintf("unable to delete deployment %s. Error: %v", d.DeploymentObject.ObjectMeta.Name, err)))
 	}
 
 	configMapName := fmt.Sprintf("%s-%s", HZN_ENV_VARS, d.AgreementId)
 	glog.V(3).Infof(kwlog(fmt.Sprintf("deleting config map %v", configMapName)))
 	// Delete the agreement config map
 	err = c.Client.CoreV1().ConfigMaps(namespace).Delete(context.Background(), configMapName, metav1.DeleteOptions{})
-	if err != nil {
-		glog.Errorf(kwlog(fmt.Sprintf("unable to delete config map %s. Error: %v", configMapName, err)))
+	if err != nil && !errors.IsNotFound(err) && uninstallErr == nil {
+		uninstallErr = fmt.Errorf(kwlog(fmt.Sprintf("unable to delete config map %s. Error: %v", configMapName, err)))
 	}
+
+	// Delete the agreement service secrets, if there are any
+	c.deleteServiceSecret(d.AgreementId, namespace)
+	return uninstallErr
 }
 
 // Status will be the status of the operator pod
@@ -432,6 +572,65 @@ func (d DeploymentAppsV1) Name() string {
 	return d.DeploymentObject.ObjectMeta.Name
 }
 
+//----------------HorizontalPodAutoscaler----------------
+// The min and max replicas of the autoscaler are overridden by the replica policy in the metadata, if there is one
+
+type HorizontalPodAutoscalerV2 struct {
+	HPAObject     *autoscalingv2.HorizontalPodAutoscaler
+	ReplicaPolicy *ReplicaPolicy
+}
+
+func (h HorizontalPodAutoscalerV2) Install(c KubeClient, namespace string) error {
+	glog.V(3).Infof(kwlog(fmt.Sprintf("creating horizontal pod autoscaler %v", h)))
+	hpa := h.resolve()
+
+	_, err := c.Client.AutoscalingV2().HorizontalPodAutoscalers(namespace).Create(context.Background(), hpa, metav1.CreateOptions{})
+	if err != nil && errors.IsAlreadyExists(err) {
+		h.Uninstall(c, namespace)
+		_, err = c.Client.AutoscalingV2().HorizontalPodAutoscalers(namespace).Create(context.Background(), hpa, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error creating the horizontal pod autoscaler: %v", err)))
+	}
+	return nil
+}
+
+// return a copy of the autoscaler with the min and max replicas of the replica policy applied
+func (h HorizontalPodAutoscalerV2) resolve() *autoscalingv2.HorizontalPodAutoscaler {
+	hpa := h.HPAObject.DeepCopy()
+	if h.ReplicaPolicy != nil {
+		if h.ReplicaPolicy.MinReplicas > 0 {
+			minReplicas := h.ReplicaPolicy.MinReplicas
+			hpa.Spec.MinReplicas = &minReplicas
+		}
+		if h.ReplicaPolicy.MaxReplicas > 0 {
+			hpa.Spec.MaxReplicas = h.ReplicaPolicy.MaxReplicas
+		}
+	}
+	return hpa
+}
+
+func (h HorizontalPodAutoscalerV2) Uninstall(c KubeClient, namespace string) error {
+	glog.V(3).Infof(kwlog(fmt.Sprintf("deleting horizontal pod autoscaler %s", h.Name())))
+	err := c.Client.AutoscalingV2().HorizontalPodAutoscalers(namespace).Delete(context.Background(), h.Name(), metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf(kwlog(fmt.Sprintf("unable to delete horizontal pod autoscaler %s. Error: %v", h.Name(), err)))
+	}
+	return nil
+}
+
+func (h HorizontalPodAutoscalerV2) Status(c KubeClient, namespace string) (interface{}, error) {
+	hpa, err := c.Client.AutoscalingV2().HorizontalPodAutoscalers(namespace).Get(context.Background(), h.Name(), metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error getting horizontal pod autoscaler status: %v", err)))
+	}
+	return hpa.Status, nil
+}
+
+func (h HorizontalPodAutoscalerV2) Name() string {
+	return h.HPAObject.ObjectMeta.Name
+}
+
 //----------------CRD & CR----------------
 // A new version requires a new CRD client type and adding the version scheme in getK8sObjectFromYaml
 
@@ -450,6 +649,8 @@ type CustomResourceV1Beta1 struct {
 	CustomResourceDefinitionObject *crdv1beta1.CustomResourceDefinition
 	CustomResourceObjectList       []*unstructured.Unstructured
 	InstallTimeout                 int64
+	EstablishedTimeout             int64
+	ConversionError                string // the reason the definition could not be converted to v1
 }
 
 func (cr CustomResourceV1Beta1) Install(c KubeClient, namespace string) error {
@@ -467,6 +668,10 @@ func (cr CustomResourceV1Beta1) Install(c KubeClient, namespace string) error {
 		return fmt.Errorf("Error installing custom resource definition: %v", err)
 	}
 
+	if err := waitForCRDV1Beta1Established(cr.Name(), cr.EstablishedTimeout); err != nil {
+		return err
+	}
+
 	// Client for creating the CR in the cluster
 	dynClient, err := NewDynamicKubeClient()
 	if err != nil {
@@ -508,20 +713,19 @@ func (cr CustomResourceV1Beta1) Install(c KubeClient, namespace string) error {
 	return nil
 }
 
-func (cr CustomResourceV1Beta1) Uninstall(c KubeClient, namespace string) {
+func (cr CustomResourceV1Beta1) Uninstall(c KubeClient, namespace string) error {
 	glog.V(3).Infof(kwlog(fmt.Sprintf("deleting operator custom resource created by this CRD %v %v %v %v", cr.Name(), cr.kind(), cr.group(), cr.versions())))
 
 	dynClient, err := NewDynamicKubeClient()
 	if err != nil {
-		glog.Errorf(kwlog(fmt.Sprintf("Error: unable to get a kubernetes dynamic client for uninstalling the custom resource: %v", err)))
-		return
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error: unable to get a kubernetes dynamic client for uninstalling the custom resource: %v", err)))
 	}
 	gvr, err := cr.gvr()
 	if err != nil {
-		glog.Errorf("%v", err)
-		return
+		return err
 	}
 	crClient := dynClient.Resource(*gvr)
+	var uninstallErr error
 
 	for _, customResourceObject := range cr.CustomResourceObjectList {
 		var newCrName string
@@ -541,12 +745,14 @@ func (cr CustomResourceV1Beta1) Uninstall(c KubeClient, namespace string) {
 		glog.V(3).Infof(kwlog(fmt.Sprintf("deleting operator custom resource %v", newCrName)))
 
 		err = crClient.Namespace(namespace).Delete(context.Background(), newCrName, metav1.DeleteOptions{})
-		if err != nil {
-			glog.Warningf(kwlog(fmt.Sprintf("unable to delete operator custom resource %s. Error: %v", newCrName, err)))
-		} else {
+		if err != nil && !errors.IsNotFound(err) {
+			if uninstallErr == nil {
+				uninstallErr = fmt.Errorf(kwlog(fmt.Sprintf("unable to delete operator custom resource %s. Error: %v", newCrName, err)))
+			}
+		} else if err == nil {
 			err = cr.waitForCRUninstall(c, namespace, 0, newCrName)
-			if err != nil {
-				glog.Errorf(kwlog(fmt.Sprintf("%v", err)))
+			if err != nil && uninstallErr == nil {
+				uninstallErr = err
 			}
 		}
 
@@ -564,15 +770,15 @@ func (cr CustomResourceV1Beta1) Uninstall(c KubeClient, namespace string) {
 		// CRDs need a different client
 		apiClient, err := NewCRDV1beta1Client()
 		if err != nil {
-			glog.Errorf(kwlog(fmt.Sprintf("Error: unable to get a kubernetes CustomResourceDefinition client for uninstall: %v", err)))
-			return
+			return fmt.Errorf(kwlog(fmt.Sprintf("Error: unable to get a kubernetes CustomResourceDefinition client for uninstall: %v", err)))
 		}
 		crds := apiClient.CustomResourceDefinitions()
 		err = crds.Delete(context.Background(), cr.Name(), metav1.DeleteOptions{})
-		if err != nil {
-			glog.Errorf(kwlog(fmt.Sprintf("unable to delete operator custom resource definition %s. Error: %v", cr.Name(), err)))
+		if err != nil && !errors.IsNotFound(err) && uninstallErr == nil {
+			uninstallErr = fmt.Errorf(kwlog(fmt.Sprintf("unable to delete operator custom resource definition %s. Error: %v", cr.Name(), err)))
 		}
 	}
+	return uninstallErr
 }
 
 func (cr CustomResourceV1Beta1) waitForCRUninstall(c KubeClient, namespace string, timeoutS int, crName string) error {
@@ -692,6 +898,7 @@ type CustomResourceV1 struct {
 	CustomResourceDefinitionObject *crdv1.CustomResourceDefinition
 	CustomResourceObjectList       []*unstructured.Unstructured
 	InstallTimeout                 int64
+	EstablishedTimeout             int64
 }
 
 func (cr CustomResourceV1) Install(c KubeClient, namespace string) error {
@@ -708,6 +915,10 @@ func (cr CustomResourceV1) Install(c KubeClient, namespace string) error {
 		return fmt.Errorf(kwlog(fmt.Sprintf("Error: failed to create custom resource definition %s: %v", cr.Name(), err)))
 	}
 
+	if err := waitForCRDV1Established(cr.Name(), cr.EstablishedTimeout); err != nil {
+		return err
+	}
+
 	// client for interacting with unknown types including custom resource types
 	dynClient, err := NewDynamicKubeClient()
 	if err != nil {
@@ -749,20 +960,19 @@ func (cr CustomResourceV1) Install(c KubeClient, namespace string) error {
 	return nil
 }
 
-func (cr CustomResourceV1) Uninstall(c KubeClient, namespace string) {
+func (cr CustomResourceV1) Uninstall(c KubeClient, namespace string) error {
 	glog.V(3).Infof(kwlog(fmt.Sprintf("deleting operator custom resource created by this CRD %v %v %v %v", cr.Name(), cr.kind(), cr.group(), cr.versions())))
 
 	dynClient, err := NewDynamicKubeClient()
 	if err != nil {
-		glog.Errorf(kwlog(fmt.Sprintf("Error: unable to get a kubernetes dynamic client for uninstalling the custom resource: %v", err)))
-		return
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error: unable to get a kubernetes dynamic client for uninstalling the custom resource: %v", err)))
 	}
 	gvr, err := cr.gvr()
 	if err != nil {
-		glog.Errorf("%v", err)
-		return
+		return err
 	}
 	crClient := dynClient.Resource(*gvr)
+	var uninstallErr error
 	for _, customResourceObject := range cr.CustomResourceObjectList {
 		var newCrName string
 		if metaInterf, ok := customResourceObject.Object["metadata"]; ok {
@@ -781,12 +991,14 @@ func (cr CustomResourceV1) Uninstall(c KubeClient, namespace string) {
 
 		glog.V(3).Infof(kwlog(fmt.Sprintf("deleting operator custom resource %v", newCrName))) // newCrName: example-nginxoperator, cr.Name(): nginxoperators.nginx.operator.com
 		err = crClient.Namespace(namespace).Delete(context.Background(), newCrName, metav1.DeleteOptions{})
-		if err != nil {
-			glog.Warningf(kwlog(fmt.Sprintf("unable to delete operator custom resource %s. Error: %v", newCrName, err)))
-		} else {
+		if err != nil && !errors.IsNotFound(err) {
+			if uninstallErr == nil {
+				uninstallErr = fmt.Errorf(kwlog(fmt.Sprintf("unable to delete operator custom resource %s. Error: %v", newCrName, err)))
+			}
+		} else if err == nil {
 			err = cr.waitForCRUninstall(c, namespace, 0, newCrName)
-			if err != nil {
-				glog.Errorf(fmt.Sprintf("%v", err))
+			if err != nil && uninstallErr == nil {
+				uninstallErr = err
 			}
 		}
 	}
@@ -803,17 +1015,16 @@ func (cr CustomResourceV1) Uninstall(c KubeClient, namespace string) {
 		// CRDs need a different client
 		apiClient, err := NewCRDV1Client()
 		if err != nil {
-			glog.Errorf(kwlog(fmt.Sprintf("Error: unable to get a kubernetes CustomResourceDefinition client for uninstall: %v", err)))
-			return
+			return fmt.Errorf(kwlog(fmt.Sprintf("Error: unable to get a kubernetes CustomResourceDefinition client for uninstall: %v", err)))
 		}
 		crds := apiClient.CustomResourceDefinitions()
 		err = crds.Delete(context.Background(), cr.Name(), metav1.DeleteOptions{})
-		if err != nil {
-			glog.Errorf(kwlog(fmt.Sprintf("unable to delete operator custom resource definition %s. Error: %v", cr.Name(), err)))
+		if err != nil && !errors.IsNotFound(err) && uninstallErr == nil {
+			uninstallErr = fmt.Errorf(kwlog(fmt.Sprintf("unable to delete operator custom resource definition %s. Error: %v", cr.Name(), err)))
 		}
 
 	}
-
+	return uninstallErr
 }
 
 func (cr CustomResourceV1) waitForCRUninstall(c KubeClient, namespace string, timeoutS int, crName string) error {
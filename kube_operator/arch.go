@@ -0,0 +1,151 @@
+package kube_operator
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/authn/k8schain"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/open-horizon/anax/cutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+const (
+	// The well known node label that holds the architecture of the node
+	K8S_NODE_ARCH_LABEL = "kubernetes.io/arch"
+
+	// The time to read the manifest of an image from its registry, so that an unreachable registry does not block the install
+	IMAGE_MANIFEST_TIMEOUT_S = 30
+)
+
+// CheckImageArchitectures verifies that every image of the operator deployments is available for the architecture of at
+// least one schedulable node in the cluster, so that the install fails with a clear error instead of leaving the pods in
+// ImagePullBackOff. The check is skipped if the nodes cannot be listed, and an image is skipped if its manifest cannot be read.
+func (c KubeClient) CheckImageArchitectures(apiObjMap map[string][]APIObjectInterface, namespace string) error {
+	nodeArchs, err := c.GetNodeArchitectures()
+	if err != nil {
+		if errors.IsForbidden(err) {
+			glog.Warningf(kwlog(fmt.Sprintf("agent is not allowed to list the cluster nodes, skipping the image architecture check: %v", err)))
+			return nil
+		}
+		return err
+	} else if len(nodeArchs) == 0 {
+		return nil
+	}
+
+	for _, obj := range apiObjMap[K8S_DEPLOYMENT_TYPE] {
+		d, ok := obj.(DeploymentAppsV1)
+		if !ok || d.DeploymentObject == nil {
+			continue
+		}
+		podSpec := d.DeploymentObject.Spec.Template.Spec
+		keychain := c.getImageKeychain(namespace, podSpec)
+
+		containers := append(append([]corev1.Container{}, podSpec.InitContainers...), podSpec.Containers...)
+		for _, container := range containers {
+			ctx, cancel := context.WithTimeout(context.Background(), IMAGE_MANIFEST_TIMEOUT_S*time.Second)
+			imageArchs, err := GetImageArchitectures(ctx, container.Image, keychain)
+			cancel()
+			if err != nil {
+				glog.Warningf(kwlog(fmt.Sprintf("unable to read the manifest of image %v, skipping the architecture check for it: %v", container.Image, err)))
+				continue
+			}
+			found := false
+			for _, arch := range imageArchs {
+				if cutil.SliceContains(nodeArchs, arch) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf(kwlog(fmt.Sprintf("Error: image %v of deployment %v is built for architectures %v, but the cluster nodes have architectures %v.", container.Image, d.Name(), imageArchs, nodeArchs)))
+			}
+			glog.V(5).Infof(kwlog(fmt.Sprintf("image %v architectures %v match the cluster node architectures %v", container.Image, imageArchs, nodeArchs)))
+		}
+	}
+	return nil
+}
+
+// GetNodeArchitectures returns the architectures of the schedulable nodes in the cluster
+func (c KubeClient) GetNodeArchitectures() ([]string, error) {
+	nodeList, err := c.Client.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	archs := []string{}
+	for _, node := range nodeList.Items {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		arch := node.Labels[K8S_NODE_ARCH_LABEL]
+		if arch == "" {
+			arch = node.Status.NodeInfo.Architecture
+		}
+		if arch != "" && !cutil.SliceContains(archs, arch) {
+			archs = append(archs, arch)
+		}
+	}
+	return archs, nil
+}
+
+// use the image pull secrets of the deployment to read the image manifests, fall back to the default credentials
+func (c KubeClient) getImageKeychain(namespace string, podSpec corev1.PodSpec) authn.Keychain {
+	pullSecrets := []string{}
+	for _, secret := range podSpec.ImagePullSecrets {
+		pullSecrets = append(pullSecrets, secret.Name)
+	}
+	keychain, err := k8schain.New(context.Background(), c.Client, k8schain.Options{Namespace: namespace, ServiceAccountName: podSpec.ServiceAccountName, ImagePullSecrets: pullSecrets})
+	if err != nil {
+		glog.Warningf(kwlog(fmt.Sprintf("unable to get the image pull credentials in namespace %v, using the default credentials: %v", namespace, err)))
+		return authn.DefaultKeychain
+	}
+	return keychain
+}
+
+// GetImageArchitectures returns the architectures that the image is built for. For a manifest list these are the
+// architectures of all the platforms in the list, otherwise it is the architecture in the image config. The registry is
+// read until the deadline of the context.
+func GetImageArchitectures(ctx context.Context, image string, keychain authn.Keychain) ([]string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := remote.Get(ref, remote.WithAuthFromKeychain(keychain), remote.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	archs := []string{}
+	if desc.MediaType.IsIndex() {
+		index, err := desc.ImageIndex()
+		if err != nil {
+			return nil, err
+		}
+		manifest, err := index.IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range manifest.Manifests {
+			if m.Platform != nil && m.Platform.Architecture != "" && m.Platform.Architecture != "unknown" && !cutil.SliceContains(archs, m.Platform.Architecture) {
+				archs = append(archs, m.Platform.Architecture)
+			}
+		}
+	} else {
+		img, err := desc.Image()
+		if err != nil {
+			return nil, err
+		}
+		config, err := img.ConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		archs = append(archs, config.Architecture)
+	}
+	return archs, nil
+}
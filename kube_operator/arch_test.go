@@ -0,0 +1,39 @@
+//go:build unit
+// +build unit
+
+package kube_operator
+
+import (
+	"context"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_GetImageArchitectures_deadline(t *testing.T) {
+
+	// a registry that does not answer
+	done := make(chan bool)
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer registry.Close()
+	defer close(done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	image := strings.TrimPrefix(registry.URL, "http://") + "/org/app:1.0"
+	if _, err := GetImageArchitectures(ctx, image, authn.DefaultKeychain); err == nil {
+		t.Errorf("reading the manifest from a registry that does not answer should have returned an error")
+	} else if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("reading the manifest should stop at the deadline, took %v", elapsed)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/persistence"
 	olmv1scheme "github.com/operator-framework/api/pkg/operators/v1"
 	olmv1alpha1scheme "github.com/operator-framework/api/pkg/operators/v1alpha1"
 	olmv1client "github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/clientset/versioned/typed/operators/v1"
@@ -16,6 +17,7 @@ import (
 	"io"
 	"io/ioutil"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	v1scheme "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
@@ -29,6 +31,7 @@ import (
 	dynamic "k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	"reflect"
 	"strings"
 )
@@ -43,15 +46,26 @@ const (
 	K8S_ROLE_TYPE               = "Role"
 	K8S_ROLEBINDING_TYPE        = "RoleBinding"
 	K8S_DEPLOYMENT_TYPE         = "Deployment"
+	K8S_HPA_TYPE                = "HorizontalPodAutoscaler"
 	K8S_SERVICEACCOUNT_TYPE     = "ServiceAccount"
 	K8S_CRD_TYPE                = "CustomResourceDefinition"
 	K8S_NAMESPACE_TYPE          = "Namespace"
 	K8S_UNSTRUCTURED_TYPE       = "Unstructured"
 	K8S_OLM_OPERATOR_GROUP_TYPE = "OperatorGroup"
+	K8S_SERVICEMONITOR_TYPE     = "ServiceMonitor"
+	K8S_PODMONITOR_TYPE         = "PodMonitor"
+
+	// The api group of the prometheus-operator monitoring kinds
+	K8S_MONITORING_GROUP = "monitoring.coreos.com"
 )
 
 func getBaseK8sKinds() []string {
-	return []string{K8S_NAMESPACE_TYPE, K8S_ROLE_TYPE, K8S_ROLEBINDING_TYPE, K8S_DEPLOYMENT_TYPE, K8S_SERVICEACCOUNT_TYPE, K8S_CRD_TYPE}
+	return []string{K8S_NAMESPACE_TYPE, K8S_ROLE_TYPE, K8S_ROLEBINDING_TYPE, K8S_DEPLOYMENT_TYPE, K8S_HPA_TYPE, K8S_SERVICEACCOUNT_TYPE, K8S_CRD_TYPE}
+}
+
+// The prometheus-operator kinds are installed after the deployment and deleted before the CRDs
+func getMonitoringK8sKinds() []string {
+	return []string{K8S_SERVICEMONITOR_TYPE, K8S_PODMONITOR_TYPE}
 }
 
 func getDangerKinds() []string {
@@ -62,6 +76,10 @@ func IsBaseK8sType(kind string) bool {
 	return cutil.SliceContains(getBaseK8sKinds(), kind)
 }
 
+func IsMonitoringK8sType(kind string) bool {
+	return cutil.SliceContains(getMonitoringK8sKinds(), kind)
+}
+
 func IsDangerType(kind string) bool {
 	return cutil.SliceContains(getDangerKinds(), kind)
 }
@@ -84,6 +102,17 @@ type KubeClient struct {
 	DynClient         dynamic.Interface
 	OLMV1Alpha1Client olmv1alpha1client.OperatorsV1alpha1Client
 	OLMV1Client       olmv1client.OperatorsV1Client
+	EventHandler      ObjectEventHandler
+}
+
+// ObjectEventHandler is called for every lifecycle step of the objects in the operator so that the caller can record it in the eventlog
+type ObjectEventHandler func(severity string, messageMeta *persistence.MessageMeta, eventCode string)
+
+// report a lifecycle step to the event handler, if there is one
+func (c KubeClient) logObjectEvent(severity string, messageMeta *persistence.MessageMeta, eventCode string) {
+	if c.EventHandler != nil {
+		c.EventHandler(severity, messageMeta, eventCode)
+	}
 }
 
 // KubeStatus contains the status of operator pods and a user-defined status object
@@ -100,11 +129,21 @@ type ContainerStatus struct {
 }
 
 func NewKubeClient() (*KubeClient, error) {
-	clientset, err := cutil.NewKubeClient()
+	config, err := cutil.NewKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+	return NewKubeClientForConfig(config)
+}
+
+// NewKubeClientForConfig returns a client of the cluster of the given config, the agent uses the config of the cluster
+// it runs in and the dev tools use the config of a local development cluster.
+func NewKubeClientForConfig(config *rest.Config) (*KubeClient, error) {
+	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
-	dynClient, err := NewDynamicKubeClient()
+	dynClient, err := dynamic.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
@@ -121,14 +160,73 @@ func NewDynamicKubeClient() (dynamic.Interface, error) {
 	return clientset, nil
 }
 
+// Install the objects of built-in k8s types in the order of getBaseK8sKinds. The deployments are waited for after all the
+// objects are installed, the pods of a deployment cannot start before the service account and other objects it refers to
+// exist. The other objects are waited for right after they are installed, e.g. a namespace has to be active before the
+// objects in it are installed.
+func (c KubeClient) installBaseK8sObjects(apiObjMap map[string][]APIObjectInterface, namespace string, waitForReady func(kind string, obj APIObjectInterface) error) error {
+	for _, componentType := range getBaseK8sKinds() {
+		for _, componentObj := range apiObjMap[componentType] {
+			err := componentObj.Install(c, namespace)
+			if err == nil && componentType != K8S_DEPLOYMENT_TYPE {
+				err = waitForReady(componentType, componentObj)
+			}
+			if err != nil {
+				c.logObjectEvent(persistence.SEVERITY_ERROR, persistence.NewMessageMeta(EL_KUBE_OBJECT_INSTALL_ERROR, componentType, componentObj.Name(), namespace, err.Error()), persistence.EC_ERROR_K8S_OBJECT_INSTALL)
+				return err
+			}
+			glog.Infof(kwlog(fmt.Sprintf("successfully installed %v %v", componentType, componentObj.Name())))
+			c.logObjectEvent(persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_KUBE_OBJECT_INSTALLED, componentType, componentObj.Name(), namespace), persistence.EC_K8S_OBJECT_INSTALLED)
+		}
+	}
+
+	for _, componentObj := range apiObjMap[K8S_DEPLOYMENT_TYPE] {
+		if err := waitForReady(K8S_DEPLOYMENT_TYPE, componentObj); err != nil {
+			c.logObjectEvent(persistence.SEVERITY_ERROR, persistence.NewMessageMeta(EL_KUBE_OBJECT_INSTALL_ERROR, K8S_DEPLOYMENT_TYPE, componentObj.Name(), namespace, err.Error()), persistence.EC_ERROR_K8S_OBJECT_INSTALL)
+			return err
+		}
+	}
+	return nil
+}
+
 // Install creates the objects specified in the operator deployment in the cluster and creates the custom resource to start the operator
-func (c KubeClient) Install(tar string, metadata map[string]interface{}, envVars map[string]string, agId string, reqNamespace string, crInstallTimeout int64) error {
+func (c KubeClient) Install(tar string, metadata map[string]interface{}, envVars map[string]string, serviceSecrets map[string][]byte, agId string, reqNamespace string, timeouts InstallTimeouts, imagePolicy ImagePolicy) error {
 
-	apiObjMap, opNamespace, err := ProcessDeployment(tar, metadata, envVars, agId, crInstallTimeout)
+	apiObjMap, opNamespace, err := ProcessDeployment(tar, metadata, envVars, agId, timeouts.CustomResource)
 	if err != nil {
 		return err
 	}
 
+	// the custom resource definitions wait to be established before their custom resources are created
+	for i, crd := range apiObjMap[K8S_CRD_TYPE] {
+		switch typedCRD := crd.(type) {
+		case CustomResourceV1:
+			typedCRD.EstablishedTimeout = timeouts.CRDEstablished
+			apiObjMap[K8S_CRD_TYPE][i] = typedCRD
+		case CustomResourceV1Beta1:
+			typedCRD.EstablishedTimeout = timeouts.CRDEstablished
+			apiObjMap[K8S_CRD_TYPE][i] = typedCRD
+		}
+	}
+
+	// fail before installing anything if a custom resource definition can only be installed with an api the cluster does not serve
+	if err := c.checkCRDV1Beta1Served(apiObjMap); err != nil {
+		return err
+	}
+
+	// the service secrets are mounted into the operator deployment
+	for i, d := range apiObjMap[K8S_DEPLOYMENT_TYPE] {
+		if typedDeployment, ok := d.(DeploymentAppsV1); ok {
+			typedDeployment.ServiceSecrets = serviceSecrets
+			apiObjMap[K8S_DEPLOYMENT_TYPE][i] = typedDeployment
+		}
+	}
+
+	// point the operator images to the local registry mirror and check them against the allow list
+	if err := imagePolicy.Apply(apiObjMap); err != nil {
+		return err
+	}
+
 	// get and check namespace
 	namespace := getFinalNamespace(reqNamespace, opNamespace)
 	nodeNamespace := cutil.GetClusterNamespace()
@@ -136,33 +234,49 @@ func (c KubeClient) Install(tar string, metadata map[string]interface{}, envVars
 		return fmt.Errorf("Service failed to start for agreement %v. Could not deploy service into namespace %v because the agent's namespace is %v and it restricts all services to have the same namespace.", agId, namespace, nodeNamespace)
 	}
 
+	// make sure the operator images can run on the cluster nodes
+	if err := c.CheckImageArchitectures(apiObjMap, namespace); err != nil {
+		return err
+	}
+
 	// If the namespace was specified in the deployment then create the namespace object so it can be created
-	if _, ok := apiObjMap[K8S_NAMESPACE_TYPE]; !ok && namespace != nodeNamespace {
-		nsObj := corev1.Namespace{TypeMeta: metav1.TypeMeta{Kind: "Namespace"}, ObjectMeta: metav1.ObjectMeta{Name: namespace}}
-		apiObjMap[K8S_NAMESPACE_TYPE] = []APIObjectInterface{NamespaceCoreV1{NamespaceObject: &nsObj}}
+	if err := addNamespaceObject(apiObjMap, metadata, namespace, nodeNamespace); err != nil {
+		return err
 	}
 
-	baseK8sComponents := getBaseK8sKinds()
+	c.logObjectEvent(persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_KUBE_START_INSTALL, agId, namespace), persistence.EC_START_K8S_OPERATOR_INSTALL)
 
 	// install all the objects of built-in k8s types
-	for _, componentType := range baseK8sComponents {
+	if err = c.installBaseK8sObjects(apiObjMap, namespace, func(kind string, obj APIObjectInterface) error {
+		return c.waitForReady(kind, obj, namespace, timeouts)
+	}); err != nil {
+		return err
+	}
+
+	// install the monitoring objects now that the deployment they monitor exists
+	for _, componentType := range getMonitoringK8sKinds() {
 		for _, componentObj := range apiObjMap[componentType] {
 			if err = componentObj.Install(c, namespace); err != nil {
+				c.logObjectEvent(persistence.SEVERITY_ERROR, persistence.NewMessageMeta(EL_KUBE_OBJECT_INSTALL_ERROR, componentType, componentObj.Name(), namespace, err.Error()), persistence.EC_ERROR_K8S_OBJECT_INSTALL)
 				return err
 			}
 			glog.Infof(kwlog(fmt.Sprintf("successfully installed %v %v", componentType, componentObj.Name())))
+			c.logObjectEvent(persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_KUBE_OBJECT_INSTALLED, componentType, componentObj.Name(), namespace), persistence.EC_K8S_OBJECT_INSTALLED)
 		}
 	}
 
 	// install any remaining components of unknown type
 	for _, unknownObj := range apiObjMap[K8S_UNSTRUCTURED_TYPE] {
 		if err = unknownObj.Install(c, namespace); err != nil {
+			c.logObjectEvent(persistence.SEVERITY_ERROR, persistence.NewMessageMeta(EL_KUBE_OBJECT_INSTALL_ERROR, K8S_UNSTRUCTURED_TYPE, unknownObj.Name(), namespace, err.Error()), persistence.EC_ERROR_K8S_OBJECT_INSTALL)
 			return err
 		}
 		glog.Infof(kwlog(fmt.Sprintf("successfully installed %v", unknownObj.Name())))
+		c.logObjectEvent(persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_KUBE_OBJECT_INSTALLED, K8S_UNSTRUCTURED_TYPE, unknownObj.Name(), namespace), persistence.EC_K8S_OBJECT_INSTALLED)
 	}
 
 	glog.V(3).Infof(kwlog(fmt.Sprintf("all operator objects installed")))
+	c.logObjectEvent(persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_KUBE_INSTALL_COMPLETE, agId, namespace), persistence.EC_K8S_OPERATOR_INSTALL_COMPLETE)
 
 	return nil
 }
@@ -176,8 +290,32 @@ func (c KubeClient) Uninstall(tar string, metadata map[string]interface{}, agId
 	}
 	namespace := getFinalNamespace(reqNamespace, opNamespace)
 
+	c.logObjectEvent(persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_KUBE_START_UNINSTALL, agId, namespace), persistence.EC_START_K8S_OPERATOR_UNINSTALL)
+
+	// the remaining objects are removed when one cannot be, the first error is returned
+	var uninstallErr error
+	uninstall := func(componentType string, componentObj APIObjectInterface) {
+		glog.Infof(kwlog(fmt.Sprintf("attempting to uninstall %v %v", componentType, componentObj.Name())))
+		if err := componentObj.Uninstall(c, namespace); err != nil {
+			glog.Errorf(kwlog(fmt.Sprintf("unable to uninstall %v %v: %v", componentType, componentObj.Name(), err)))
+			c.logObjectEvent(persistence.SEVERITY_ERROR, persistence.NewMessageMeta(EL_KUBE_OBJECT_UNINSTALL_ERROR, componentType, componentObj.Name(), namespace, err.Error()), persistence.EC_ERROR_K8S_OBJECT_UNINSTALL)
+			if uninstallErr == nil {
+				uninstallErr = err
+			}
+		} else {
+			c.logObjectEvent(persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_KUBE_OBJECT_UNINSTALLED, componentType, componentObj.Name(), namespace), persistence.EC_K8S_OBJECT_UNINSTALLED)
+		}
+	}
+
+	// remove the monitoring objects before the CRDs
+	for _, componentType := range getMonitoringK8sKinds() {
+		for _, componentObj := range apiObjMap[componentType] {
+			uninstall(componentType, componentObj)
+		}
+	}
+
 	for _, crd := range apiObjMap[K8S_CRD_TYPE] {
-		crd.Uninstall(c, namespace)
+		uninstall(K8S_CRD_TYPE, crd)
 	}
 
 	baseK8sComponents := getBaseK8sKinds()
@@ -186,18 +324,21 @@ func (c KubeClient) Uninstall(tar string, metadata map[string]interface{}, agId
 	for i := len(baseK8sComponents) - 1; i >= 0; i-- {
 		componentType := baseK8sComponents[i]
 		for _, componentObj := range apiObjMap[componentType] {
-			glog.Infof(kwlog(fmt.Sprintf("attempting to uninstall %v %v", componentType, componentObj.Name())))
-			componentObj.Uninstall(c, namespace)
+			uninstall(componentType, componentObj)
 		}
 	}
 
 	// uninstall any remaining components of unknown type
 	for _, unknownObj := range apiObjMap[K8S_UNSTRUCTURED_TYPE] {
-		glog.Infof(kwlog(fmt.Sprintf("attempting to uninstall %v", unknownObj.Name())))
-		unknownObj.Uninstall(c, namespace)
+		uninstall(K8S_UNSTRUCTURED_TYPE, unknownObj)
+	}
+
+	if uninstallErr != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("failed to remove all the operator objects of agreement %v from the cluster: %v", agId, uninstallErr)))
 	}
 
 	glog.V(3).Infof(kwlog(fmt.Sprintf("Completed removal of all operator objects from the cluster.")))
+	c.logObjectEvent(persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_KUBE_UNINSTALL_COMPLETE, agId, namespace), persistence.EC_K8S_OPERATOR_UNINSTALLED)
 	return nil
 }
 func (c KubeClient) OperatorStatus(tar string, metadata map[string]interface{}, agId string, reqNamespace string) (interface{}, error) {
@@ -388,7 +529,7 @@ func getK8sObjectFromYaml(yamlFiles []YamlFile, sch *runtime.Scheme) ([]APIObjec
 	}
 
 	for _, fileStr := range indivYamls {
-		decode := serializer.NewCodecFactory(sch).UniversalDecoder(v1beta1scheme.SchemeGroupVersion, v1scheme.SchemeGroupVersion, rbacv1.SchemeGroupVersion, appsv1.SchemeGroupVersion, corev1.SchemeGroupVersion, olmv1alpha1scheme.SchemeGroupVersion, olmv1scheme.SchemeGroupVersion).Decode
+		decode := serializer.NewCodecFactory(sch).UniversalDecoder(v1beta1scheme.SchemeGroupVersion, v1scheme.SchemeGroupVersion, rbacv1.SchemeGroupVersion, appsv1.SchemeGroupVersion, autoscalingv2.SchemeGroupVersion, corev1.SchemeGroupVersion, olmv1alpha1scheme.SchemeGroupVersion, olmv1scheme.SchemeGroupVersion).Decode
 		obj, gvk, err := decode([]byte(fileStr.Body), nil, nil)
 
 		if err != nil {
@@ -0,0 +1,135 @@
+//go:build unit
+// +build unit
+
+package kube_operator
+
+import (
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// A fake cluster that records the objects installed in it.
+type fakeCluster struct {
+	installed map[string]bool
+}
+
+type fakeServiceAccount struct {
+	ServiceAccountCoreV1
+	cluster *fakeCluster
+}
+
+func (sa fakeServiceAccount) Install(c KubeClient, namespace string) error {
+	sa.cluster.installed[K8S_SERVICEACCOUNT_TYPE+"/"+sa.Name()] = true
+	return nil
+}
+
+type fakeDeployment struct {
+	DeploymentAppsV1
+	cluster *fakeCluster
+}
+
+func (d fakeDeployment) Install(c KubeClient, namespace string) error {
+	d.cluster.installed[K8S_DEPLOYMENT_TYPE+"/"+d.Name()] = true
+	return nil
+}
+
+func Test_installBaseK8sObjects_ServiceAccountName(t *testing.T) {
+	cluster := &fakeCluster{installed: map[string]bool{}}
+
+	sa := fakeServiceAccount{ServiceAccountCoreV1{ServiceAccountObject: &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "operator-sa"}}}, cluster}
+	dep := fakeDeployment{DeploymentAppsV1{DeploymentObject: &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "operator"},
+		Spec:       appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{ServiceAccountName: "operator-sa"}}},
+	}}, cluster}
+	apiObjMap := map[string][]APIObjectInterface{
+		K8S_SERVICEACCOUNT_TYPE: {sa},
+		K8S_DEPLOYMENT_TYPE:     {dep},
+	}
+
+	// the pods of the deployment only roll out when its service account exists
+	waited := []string{}
+	waitForReady := func(kind string, obj APIObjectInterface) error {
+		waited = append(waited, kind)
+		if kind == K8S_DEPLOYMENT_TYPE {
+			saName := obj.(fakeDeployment).DeploymentObject.Spec.Template.Spec.ServiceAccountName
+			if !cluster.installed[K8S_SERVICEACCOUNT_TYPE+"/"+saName] {
+				return fmt.Errorf("deployment %v did not roll out, service account %v does not exist", obj.Name(), saName)
+			}
+		}
+		return nil
+	}
+
+	if err := (KubeClient{}).installBaseK8sObjects(apiObjMap, "ns", waitForReady); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if !cluster.installed[K8S_DEPLOYMENT_TYPE+"/operator"] || !cluster.installed[K8S_SERVICEACCOUNT_TYPE+"/operator-sa"] {
+		t.Errorf("the deployment and service account should be installed, but got %v", cluster.installed)
+	} else if len(waited) != 2 || waited[1] != K8S_DEPLOYMENT_TYPE {
+		t.Errorf("the deployment should be waited for last, but got %v", waited)
+	}
+
+	// a deployment that does not roll out fails the install
+	failing := func(kind string, obj APIObjectInterface) error {
+		if kind == K8S_DEPLOYMENT_TYPE {
+			return fmt.Errorf("deployment %v did not roll out", obj.Name())
+		}
+		return nil
+	}
+	if err := (KubeClient{}).installBaseK8sObjects(apiObjMap, "ns", failing); err == nil {
+		t.Errorf("a deployment that does not roll out should have returned an error")
+	}
+}
+
+// A kube client whose API server is the given handler.
+func newTestKubeClient(t *testing.T, handler http.HandlerFunc) KubeClient {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("unable to create the kube client, error: %v", err)
+	}
+	return KubeClient{Client: client}
+}
+
+// A kube client whose API server answers the requests with the given status, and counts the calls.
+func statusKubeClient(t *testing.T, status int, calls *int) KubeClient {
+	return newTestKubeClient(t, func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","code":%v}`, status)
+	})
+}
+
+func Test_Uninstall_Errors(t *testing.T) {
+	role := RoleRbacV1{RoleObject: &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "operator-role"}}}
+	sa := ServiceAccountCoreV1{ServiceAccountObject: &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "operator-sa"}}}
+
+	// an object that is already gone is uninstalled
+	calls := 0
+	c := statusKubeClient(t, http.StatusNotFound, &calls)
+	for _, obj := range []APIObjectInterface{role, sa} {
+		if err := obj.Uninstall(c, "ns"); err != nil {
+			t.Errorf("deleting a missing %v should not return error, but got %v", obj.Name(), err)
+		}
+	}
+
+	// the failure to delete an object is returned
+	c = statusKubeClient(t, http.StatusForbidden, &calls)
+	for _, obj := range []APIObjectInterface{role, sa} {
+		if err := obj.Uninstall(c, "ns"); err == nil {
+			t.Errorf("the failure to delete %v should have returned an error", obj.Name())
+		}
+	}
+	if calls != 4 {
+		t.Errorf("expected 4 deletes, got %v", calls)
+	}
+}
@@ -72,3 +72,33 @@ func NewMaintenanceCommand(protocol string, agreementId string, clusterNamespace
 		Deployment:        deployment,
 	}
 }
+
+type PauseCommand struct {
+	AgreementProtocol string
+	AgreementId       string
+	ClusterNamespace  string
+	Deployment        persistence.DeploymentConfig
+	Pause             bool
+}
+
+func (c PauseCommand) String() string {
+	deployment_string := ""
+	if c.Deployment != nil {
+		deployment_string = c.Deployment.ToString()
+	}
+	return fmt.Sprintf("AgreementProtocol: %v, AgreementId: %v, ClusterNamespace: %v, Deployment: %v, Pause: %v", c.AgreementProtocol, c.AgreementId, c.ClusterNamespace, deployment_string, c.Pause)
+}
+
+func (c PauseCommand) ShortString() string {
+	return c.String()
+}
+
+func NewPauseCommand(protocol string, agreementId string, clusterNamespace string, deployment persistence.DeploymentConfig, pause bool) *PauseCommand {
+	return &PauseCommand{
+		AgreementProtocol: protocol,
+		AgreementId:       agreementId,
+		ClusterNamespace:  clusterNamespace,
+		Deployment:        deployment,
+		Pause:             pause,
+	}
+}
@@ -0,0 +1,75 @@
+package kube_operator
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	crdv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// The remediation shown when a v1beta1 custom resource definition cannot be installed
+const CRD_V1BETA1_REMEDIATION = "Kubernetes 1.22 and later only serve apiextensions.k8s.io/v1 custom resource definitions. Convert the custom resource definition in the operator yaml archive to apiextensions.k8s.io/v1, with a structural openAPIV3Schema for each version, and publish the service again."
+
+// convertCRDV1Beta1ToV1 converts the v1beta1 custom resource definition to v1 so that it can be installed in any cluster.
+// An error is returned if the v1 definition would behave differently, which is the case when a version has no schema or
+// when unknown fields are preserved without the schema saying so.
+func convertCRDV1Beta1ToV1(in *crdv1beta1.CustomResourceDefinition) (*crdv1.CustomResourceDefinition, error) {
+	internal := &apiextensions.CustomResourceDefinition{}
+	if err := crdv1beta1.Convert_v1beta1_CustomResourceDefinition_To_apiextensions_CustomResourceDefinition(in, internal, nil); err != nil {
+		return nil, err
+	}
+	out := &crdv1.CustomResourceDefinition{}
+	if err := crdv1.Convert_apiextensions_CustomResourceDefinition_To_v1_CustomResourceDefinition(internal, out, nil); err != nil {
+		return nil, err
+	}
+
+	for _, version := range out.Spec.Versions {
+		if version.Schema == nil || version.Schema.OpenAPIV3Schema == nil {
+			return nil, fmt.Errorf("version %v has no openAPIV3Schema", version.Name)
+		}
+	}
+
+	// v1 always prunes the unknown fields, unless the schema preserves them
+	if out.Spec.PreserveUnknownFields {
+		for _, version := range out.Spec.Versions {
+			if preserve := version.Schema.OpenAPIV3Schema.XPreserveUnknownFields; preserve == nil || !*preserve {
+				return nil, fmt.Errorf("spec.preserveUnknownFields is not false and the schema of version %v does not set x-kubernetes-preserve-unknown-fields", version.Name)
+			}
+		}
+		out.Spec.PreserveUnknownFields = false
+	}
+
+	// conversionReviewVersions is required in v1, v1beta1 defaulted it to v1beta1
+	if out.Spec.Conversion != nil && out.Spec.Conversion.Strategy == crdv1.WebhookConverter {
+		if out.Spec.Conversion.Webhook == nil {
+			return nil, fmt.Errorf("the webhook conversion strategy has no webhook client config")
+		} else if len(out.Spec.Conversion.Webhook.ConversionReviewVersions) == 0 {
+			out.Spec.Conversion.Webhook.ConversionReviewVersions = []string{crdv1beta1.SchemeGroupVersion.Version}
+		}
+	}
+
+	out.TypeMeta = typeMeta(crdv1.SchemeGroupVersion.String(), K8S_CRD_TYPE)
+	out.Status = crdv1.CustomResourceDefinitionStatus{}
+	return out, nil
+}
+
+// checkCRDV1Beta1Served returns an error with the remediation if the package has v1beta1 custom resource definitions that
+// could not be converted to v1 and the cluster no longer serves v1beta1. It is called before any object is installed.
+func (c KubeClient) checkCRDV1Beta1Served(apiObjMap map[string][]APIObjectInterface) error {
+	for _, obj := range apiObjMap[K8S_CRD_TYPE] {
+		if crd, ok := obj.(CustomResourceV1Beta1); ok {
+			_, err := c.Client.Discovery().ServerResourcesForGroupVersion(crdv1beta1.SchemeGroupVersion.String())
+			if err == nil {
+				return nil
+			} else if errors.IsNotFound(err) {
+				return fmt.Errorf(kwlog(fmt.Sprintf("Error: custom resource definition %v uses %v, which is not served by this cluster, and it cannot be converted to %v: %v. %v", crd.Name(), crdv1beta1.SchemeGroupVersion, crdv1.SchemeGroupVersion, crd.ConversionError, CRD_V1BETA1_REMEDIATION)))
+			}
+			// let the install report the error if the api server cannot be reached
+			glog.Warningf(kwlog(fmt.Sprintf("unable to check if the cluster serves %v: %v", crdv1beta1.SchemeGroupVersion, err)))
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+//go:build unit
+// +build unit
+
+package kube_operator
+
+import (
+	"fmt"
+	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	crdv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// A v1beta1 custom resource definition with the given validation schema for all its versions.
+func testCRDV1Beta1(validation *crdv1beta1.CustomResourceValidation, preserveUnknownFields bool) *crdv1beta1.CustomResourceDefinition {
+	return &crdv1beta1.CustomResourceDefinition{
+		TypeMeta:   metav1.TypeMeta{APIVersion: crdv1beta1.SchemeGroupVersion.String(), Kind: K8S_CRD_TYPE},
+		ObjectMeta: metav1.ObjectMeta{Name: "widgets.example.com"},
+		Spec: crdv1beta1.CustomResourceDefinitionSpec{
+			Group:                 "example.com",
+			Names:                 crdv1beta1.CustomResourceDefinitionNames{Plural: "widgets", Kind: "Widget"},
+			Scope:                 crdv1beta1.NamespaceScoped,
+			Versions:              []crdv1beta1.CustomResourceDefinitionVersion{{Name: "v1alpha1", Served: true, Storage: true}},
+			Validation:            validation,
+			PreserveUnknownFields: &preserveUnknownFields,
+		},
+	}
+}
+
+func testValidation(preserve bool) *crdv1beta1.CustomResourceValidation {
+	schema := &crdv1beta1.JSONSchemaProps{Type: "object", Properties: map[string]crdv1beta1.JSONSchemaProps{"spec": {Type: "object"}}}
+	if preserve {
+		schema.XPreserveUnknownFields = &preserve
+	}
+	return &crdv1beta1.CustomResourceValidation{OpenAPIV3Schema: schema}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func Test_convertCRDV1Beta1ToV1(t *testing.T) {
+
+	webhook := testCRDV1Beta1(testValidation(false), false)
+	webhook.Spec.Conversion = &crdv1beta1.CustomResourceConversion{Strategy: crdv1beta1.WebhookConverter, WebhookClientConfig: &crdv1beta1.WebhookClientConfig{URL: strPtr("https://convert.example.com")}}
+	noWebhookConfig := testCRDV1Beta1(testValidation(false), false)
+	noWebhookConfig.Spec.Conversion = &crdv1beta1.CustomResourceConversion{Strategy: crdv1beta1.WebhookConverter}
+
+	tests := []struct {
+		name   string
+		in     *crdv1beta1.CustomResourceDefinition
+		errMsg string
+	}{
+		{"schema", testCRDV1Beta1(testValidation(false), false), ""},
+		{"unknown fields preserved by the schema", testCRDV1Beta1(testValidation(true), true), ""},
+		{"webhook", webhook, ""},
+		{"no schema", testCRDV1Beta1(nil, false), "has no openAPIV3Schema"},
+		{"unknown fields preserved without schema", testCRDV1Beta1(testValidation(false), true), "x-kubernetes-preserve-unknown-fields"},
+		{"webhook without client config", noWebhookConfig, "no webhook client config"},
+	}
+
+	for _, test := range tests {
+		out, err := convertCRDV1Beta1ToV1(test.in)
+		if test.errMsg != "" {
+			if err == nil || !strings.Contains(err.Error(), test.errMsg) {
+				t.Errorf("%v: expected error %v, but got %v", test.name, test.errMsg, err)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%v: should not return error, but got %v", test.name, err)
+			continue
+		}
+
+		if out.APIVersion != crdv1.SchemeGroupVersion.String() || out.Kind != K8S_CRD_TYPE || out.Name != "widgets.example.com" {
+			t.Errorf("%v: wrong type or name %v %v %v", test.name, out.APIVersion, out.Kind, out.Name)
+		} else if len(out.Spec.Versions) != 1 || out.Spec.Versions[0].Schema == nil || out.Spec.Versions[0].Schema.OpenAPIV3Schema.Type != "object" {
+			t.Errorf("%v: the schema should be moved to the version, got %v", test.name, out.Spec.Versions)
+		} else if out.Spec.PreserveUnknownFields {
+			t.Errorf("%v: the unknown fields should be pruned", test.name)
+		}
+	}
+
+	// v1 requires the conversion review versions of the webhook
+	if out, err := convertCRDV1Beta1ToV1(webhook); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if out.Spec.Conversion.Webhook == nil || fmt.Sprint(out.Spec.Conversion.Webhook.ConversionReviewVersions) != "[v1beta1]" {
+		t.Errorf("wrong webhook conversion %v", out.Spec.Conversion.Webhook)
+	}
+}
+
+func Test_checkCRDV1Beta1Served(t *testing.T) {
+	v1beta1 := map[string][]APIObjectInterface{K8S_CRD_TYPE: {CustomResourceV1Beta1{CustomResourceDefinitionObject: testCRDV1Beta1(nil, false), ConversionError: "no schema"}}}
+
+	// the cluster is not asked when there is no v1beta1 custom resource definition
+	calls := 0
+	c := statusKubeClient(t, http.StatusNotFound, &calls)
+	if err := c.checkCRDV1Beta1Served(map[string][]APIObjectInterface{}); err != nil || calls != 0 {
+		t.Errorf("expected no error and no call, got %v after %v calls", err, calls)
+	}
+
+	// v1beta1 is not served
+	if err := c.checkCRDV1Beta1Served(v1beta1); err == nil || !strings.Contains(err.Error(), CRD_V1BETA1_REMEDIATION) {
+		t.Errorf("expected the remediation, but got %v", err)
+	}
+
+	// v1beta1 is served
+	c = newTestKubeClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"kind":"APIResourceList","apiVersion":"v1","groupVersion":"%v","resources":[]}`, crdv1beta1.SchemeGroupVersion)
+	})
+	if err := c.checkCRDV1Beta1Served(v1beta1); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	}
+
+	// the install reports the other errors
+	c = statusKubeClient(t, http.StatusInternalServerError, &calls)
+	if err := c.checkCRDV1Beta1Served(v1beta1); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	}
+}
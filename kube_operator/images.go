@@ -0,0 +1,147 @@
+package kube_operator
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	corev1 "k8s.io/api/core/v1"
+	"strings"
+)
+
+// ImagePolicy controls where the operator images are pulled from. It is used by air-gapped clusters to redirect
+// all the images to a local registry mirror and to make sure that only known images are deployed.
+type ImagePolicy struct {
+	RegistryMirror string
+	AllowList      []string
+}
+
+func (p ImagePolicy) String() string {
+	return fmt.Sprintf("RegistryMirror: %v, AllowList: %v", p.RegistryMirror, p.AllowList)
+}
+
+func NewImagePolicy(cfg *config.HorizonConfig) ImagePolicy {
+	return ImagePolicy{
+		RegistryMirror: strings.TrimSuffix(cfg.Edge.K8sImageRegistryMirror, "/"),
+		AllowList:      cfg.Edge.K8sImageAllowList,
+	}
+}
+
+// Apply rewrites the images of the operator deployments and custom resources to the registry mirror and verifies
+// that the resulting images are in the allow list.
+func (p ImagePolicy) Apply(apiObjMap map[string][]APIObjectInterface) error {
+	if p.RegistryMirror == "" && len(p.AllowList) == 0 {
+		return nil
+	}
+
+	for _, obj := range apiObjMap[K8S_DEPLOYMENT_TYPE] {
+		if d, ok := obj.(DeploymentAppsV1); ok && d.DeploymentObject != nil {
+			podSpec := &d.DeploymentObject.Spec.Template.Spec
+			if err := p.applyToContainers(podSpec.InitContainers, d.Name()); err != nil {
+				return err
+			} else if err := p.applyToContainers(podSpec.Containers, d.Name()); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, obj := range apiObjMap[K8S_CRD_TYPE] {
+		var crList []map[string]interface{}
+		switch cr := obj.(type) {
+		case CustomResourceV1:
+			for _, u := range cr.CustomResourceObjectList {
+				crList = append(crList, u.Object)
+			}
+		case CustomResourceV1Beta1:
+			for _, u := range cr.CustomResourceObjectList {
+				crList = append(crList, u.Object)
+			}
+		}
+		for _, crObj := range crList {
+			if err := p.applyToUnstructured(crObj, obj.Name()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p ImagePolicy) applyToContainers(containers []corev1.Container, owner string) error {
+	for i := range containers {
+		if image, err := p.applyToImage(containers[i].Image, owner); err != nil {
+			return err
+		} else {
+			containers[i].Image = image
+		}
+	}
+	return nil
+}
+
+// recursively rewrite the values of all the "image" attributes in the custom resource
+func (p ImagePolicy) applyToUnstructured(obj interface{}, owner string) error {
+	switch typed := obj.(type) {
+	case map[string]interface{}:
+		for key, value := range typed {
+			if image, ok := value.(string); ok && key == "image" {
+				if newImage, err := p.applyToImage(image, owner); err != nil {
+					return err
+				} else {
+					typed[key] = newImage
+				}
+			} else if err := p.applyToUnstructured(value, owner); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, elem := range typed {
+			if err := p.applyToUnstructured(elem, owner); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p ImagePolicy) applyToImage(image string, owner string) (string, error) {
+	newImage := image
+	if p.RegistryMirror != "" {
+		newImage = RewriteImageRegistry(image, p.RegistryMirror)
+		glog.V(3).Infof(kwlog(fmt.Sprintf("rewrote image %v to %v in %v", image, newImage, owner)))
+	}
+	if !p.IsAllowed(newImage) {
+		return "", fmt.Errorf(kwlog(fmt.Sprintf("Error: image %v in %v is not in the image allow list %v", newImage, owner, p.AllowList)))
+	}
+	return newImage, nil
+}
+
+// IsAllowed returns true if the image matches one of the prefixes in the allow list, or if the allow list is empty. A
+// prefix only matches whole path components, so "registry.io/org" allows "registry.io/org/app:1.0" and
+// "registry.io/org:1.0" but not "registry.io/org-evil/app".
+func (p ImagePolicy) IsAllowed(image string) bool {
+	if len(p.AllowList) == 0 {
+		return true
+	}
+	for _, prefix := range p.AllowList {
+		if prefix == "" || !strings.HasPrefix(image, prefix) {
+			continue
+		} else if len(image) == len(prefix) || strings.ContainsAny(prefix[len(prefix)-1:], "/:@") || strings.ContainsAny(image[len(prefix):len(prefix)+1], "/:@") {
+			return true
+		}
+	}
+	return false
+}
+
+// RewriteImageRegistry replaces the registry of the given image with the mirror. Images without a registry
+// are assumed to come from docker hub, so the implicit "library" repository is kept for official images.
+func RewriteImageRegistry(image string, mirror string) string {
+	if image == "" || mirror == "" {
+		return image
+	}
+
+	repo := image
+	if parts := strings.SplitN(image, "/", 2); len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		repo = parts[1]
+	} else if len(parts) == 1 {
+		repo = "library/" + image
+	}
+	return fmt.Sprintf("%v/%v", strings.TrimSuffix(mirror, "/"), repo)
+}
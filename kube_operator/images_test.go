@@ -0,0 +1,62 @@
+//go:build unit
+// +build unit
+
+package kube_operator
+
+import (
+	"testing"
+)
+
+func Test_ImagePolicy_IsAllowed(t *testing.T) {
+
+	p := ImagePolicy{AllowList: []string{"registry.io/org", "mirror.io/", "quay.io/team/app@"}}
+
+	tests := []struct {
+		image   string
+		allowed bool
+	}{
+		{"registry.io/org/app:1.0", true},
+		{"registry.io/org:1.0", true},
+		{"registry.io/org@sha256:abcd", true},
+		{"registry.io/org", true},
+		{"registry.io/org-evil/app:1.0", false},
+		{"registry.io/orgx", false},
+		{"registry.io/other/app", false},
+		{"mirror.io/anything/app:2", true},
+		{"mirror.iox/app", false},
+		{"quay.io/team/app@sha256:abcd", true},
+		{"quay.io/team/app:latest", false},
+	}
+
+	for _, test := range tests {
+		if allowed := p.IsAllowed(test.image); allowed != test.allowed {
+			t.Errorf("image %v: expected allowed %v, but got %v", test.image, test.allowed, allowed)
+		}
+	}
+
+	if !(ImagePolicy{}).IsAllowed("anything") {
+		t.Errorf("an empty allow list should allow all the images")
+	} else if (ImagePolicy{AllowList: []string{""}}).IsAllowed("anything") {
+		t.Errorf("an empty prefix should not allow all the images")
+	}
+}
+
+func Test_RewriteImageRegistry(t *testing.T) {
+
+	tests := []struct {
+		image    string
+		expected string
+	}{
+		{"nginx", "mirror.local/library/nginx"},
+		{"org/app:1.0", "mirror.local/org/app:1.0"},
+		{"registry.io/org/app:1.0", "mirror.local/org/app:1.0"},
+		{"localhost/app", "mirror.local/app"},
+		{"registry.io:5000/app", "mirror.local/app"},
+	}
+
+	for _, test := range tests {
+		if image := RewriteImageRegistry(test.image, "mirror.local/"); image != test.expected {
+			t.Errorf("image %v: expected %v, but got %v", test.image, test.expected, image)
+		}
+	}
+}
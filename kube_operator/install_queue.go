@@ -0,0 +1,112 @@
+package kube_operator
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"sync"
+)
+
+// The number of items that can wait for a namespace, the work submitted when the queue of the namespace is full is rejected
+const MAX_QUEUED_PER_NAMESPACE = 100
+
+// installQueue runs the install, uninstall and maintenance work of the kube worker so that operators in different
+// namespaces are handled in parallel, while the work for the same namespace is done one at a time in the order it was
+// submitted. The number of namespaces handled at the same time is bounded. Work submitted with the key of work that is
+// still waiting replaces it, so that e.g. the periodic maintenance of an agreement is not queued more than once, and the
+// number of items waiting for a namespace is bounded.
+type installQueue struct {
+	lock      sync.Mutex
+	pending   map[string][]queuedJob // the work waiting for each namespace, the first entry is the one running
+	slots     chan bool              // one entry for each namespace being handled
+	maxQueued int
+	stopped   bool
+}
+
+type queuedJob struct {
+	key string
+	job func()
+}
+
+func newInstallQueue(maxConcurrent int) *installQueue {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	return &installQueue{
+		pending:   map[string][]queuedJob{},
+		slots:     make(chan bool, maxConcurrent),
+		maxQueued: MAX_QUEUED_PER_NAMESPACE,
+	}
+}
+
+// submit adds the work to the queue of the namespace, and starts handling the namespace if nothing was queued for it. An
+// error is returned when the work is not queued, because the queue is stopped or the queue of the namespace is full.
+func (q *installQueue) submit(namespace string, key string, job func()) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.stopped {
+		return fmt.Errorf("the install queue is stopped")
+	}
+
+	queue := q.pending[namespace]
+	for i := 1; i < len(queue); i++ {
+		if queue[i].key == key {
+			glog.V(5).Infof(kwlog(fmt.Sprintf("replaced queued work %v for namespace %v", key, namespace)))
+			queue[i].job = job
+			return nil
+		}
+	}
+	if len(queue) > q.maxQueued {
+		return fmt.Errorf("%v items are already queued for namespace %v", len(queue)-1, namespace)
+	}
+
+	q.pending[namespace] = append(queue, queuedJob{key: key, job: job})
+	if len(q.pending[namespace]) == 1 {
+		go q.run(namespace)
+	} else {
+		glog.V(5).Infof(kwlog(fmt.Sprintf("queued work %v for namespace %v behind %v other item(s)", key, namespace, len(q.pending[namespace])-1)))
+	}
+	return nil
+}
+
+// stop drops the work that is waiting and ends the handling of the namespaces once the work that is running is done
+func (q *installQueue) stop() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.stopped = true
+	for namespace, queue := range q.pending {
+		if len(queue) > 1 {
+			glog.V(3).Infof(kwlog(fmt.Sprintf("dropping %v queued item(s) for namespace %v", len(queue)-1, namespace)))
+			q.pending[namespace] = queue[:1]
+		}
+	}
+}
+
+// run does the queued work of the namespace until there is none left
+func (q *installQueue) run(namespace string) {
+	q.slots <- true
+	defer func() { <-q.slots }()
+
+	for {
+		q.lock.Lock()
+		if q.stopped {
+			delete(q.pending, namespace)
+			q.lock.Unlock()
+			return
+		}
+		job := q.pending[namespace][0].job
+		q.lock.Unlock()
+
+		job()
+
+		q.lock.Lock()
+		q.pending[namespace] = q.pending[namespace][1:]
+		if len(q.pending[namespace]) == 0 {
+			delete(q.pending, namespace)
+			q.lock.Unlock()
+			return
+		}
+		q.lock.Unlock()
+	}
+}
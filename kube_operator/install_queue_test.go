@@ -0,0 +1,186 @@
+//go:build unit
+// +build unit
+
+package kube_operator
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waits for the work of the queue to be done
+func waitForQueue(t *testing.T, wg *sync.WaitGroup) {
+	done := make(chan bool)
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the queued work was not done in time")
+	}
+}
+
+func Test_installQueue_order(t *testing.T) {
+
+	q := newInstallQueue(4)
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	done := map[string][]int{}
+
+	for i := 0; i < 20; i++ {
+		for _, namespace := range []string{"ns1", "ns2"} {
+			i, namespace := i, namespace
+			wg.Add(1)
+			if err := q.submit(namespace, fmt.Sprintf("job%v", i), func() {
+				defer wg.Done()
+				lock.Lock()
+				defer lock.Unlock()
+				done[namespace] = append(done[namespace], i)
+			}); err != nil {
+				t.Fatalf("should not return error, but got %v", err)
+			}
+		}
+	}
+	waitForQueue(t, &wg)
+
+	for _, namespace := range []string{"ns1", "ns2"} {
+		if len(done[namespace]) != 20 {
+			t.Errorf("all the work of namespace %v should be done, got %v", namespace, done[namespace])
+		}
+		for i, job := range done[namespace] {
+			if job != i {
+				t.Errorf("the work of namespace %v should be done in order, got %v", namespace, done[namespace])
+				break
+			}
+		}
+	}
+}
+
+func Test_installQueue_concurrency(t *testing.T) {
+
+	q := newInstallQueue(2)
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	running, maxRunning := map[string]int{}, map[string]int{}
+	total, maxTotal := 0, 0
+
+	for i := 0; i < 5; i++ {
+		for _, namespace := range []string{"ns1", "ns2", "ns3", "ns4"} {
+			namespace := namespace
+			wg.Add(1)
+			q.submit(namespace, fmt.Sprintf("job%v", i), func() {
+				defer wg.Done()
+				lock.Lock()
+				running[namespace]++
+				total++
+				if running[namespace] > maxRunning[namespace] {
+					maxRunning[namespace] = running[namespace]
+				}
+				if total > maxTotal {
+					maxTotal = total
+				}
+				lock.Unlock()
+
+				time.Sleep(5 * time.Millisecond)
+
+				lock.Lock()
+				running[namespace]--
+				total--
+				lock.Unlock()
+			})
+		}
+	}
+	waitForQueue(t, &wg)
+
+	if maxTotal > 2 {
+		t.Errorf("at most 2 namespaces should be handled at the same time, got %v", maxTotal)
+	}
+	for namespace, max := range maxRunning {
+		if max != 1 {
+			t.Errorf("the work of namespace %v should be done one at a time, got %v", namespace, max)
+		}
+	}
+}
+
+func Test_installQueue_bounded(t *testing.T) {
+
+	q := newInstallQueue(1)
+	q.maxQueued = 2
+	release := make(chan bool)
+	var wg sync.WaitGroup
+	done := []string{}
+	var lock sync.Mutex
+	record := func(name string) func() {
+		return func() {
+			defer wg.Done()
+			lock.Lock()
+			defer lock.Unlock()
+			done = append(done, name)
+		}
+	}
+
+	// the first job blocks the namespace
+	wg.Add(3)
+	q.submit("ns", "running", func() {
+		<-release
+		record("running")()
+	})
+	if err := q.submit("ns", "maintain/ag1", record("maintain1")); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if err := q.submit("ns", "maintain/ag1", record("maintain2")); err != nil {
+		t.Errorf("work with the key of waiting work should replace it, but got %v", err)
+	} else if err := q.submit("ns", "install/ag2", record("install")); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if err := q.submit("ns", "install/ag3", record("rejected")); err == nil {
+		t.Errorf("a full queue should reject the work")
+	}
+
+	close(release)
+	waitForQueue(t, &wg)
+	if fmt.Sprint(done) != "[running maintain2 install]" {
+		t.Errorf("wrong work done %v", done)
+	}
+}
+
+func Test_installQueue_stop(t *testing.T) {
+
+	q := newInstallQueue(1)
+	started, release := make(chan bool), make(chan bool)
+	var wg sync.WaitGroup
+	ran := false
+
+	wg.Add(1)
+	q.submit("ns", "running", func() {
+		defer wg.Done()
+		close(started)
+		<-release
+	})
+	q.submit("ns", "waiting", func() { ran = true })
+
+	<-started
+	q.stop()
+	if err := q.submit("ns", "late", func() { ran = true }); err == nil {
+		t.Errorf("a stopped queue should reject the work")
+	}
+	close(release)
+	waitForQueue(t, &wg)
+
+	// let the namespace worker see the stop
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		q.lock.Lock()
+		n := len(q.pending)
+		q.lock.Unlock()
+		if n == 0 {
+			break
+		}
+	}
+	if ran {
+		t.Errorf("the waiting work should have been dropped")
+	} else if len(q.pending) != 0 {
+		t.Errorf("the namespace workers should have ended, got %v", q.pending)
+	}
+}
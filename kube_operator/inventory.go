@@ -0,0 +1,172 @@
+package kube_operator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/cutil"
+	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"time"
+)
+
+const (
+	// The custom resource the agent publishes in its own namespace for each agreement, so that cluster tools can see the
+	// workloads managed by the agent
+	AGENT_WORKLOADS_GROUP   = "openhorizon.org"
+	AGENT_WORKLOADS_VERSION = "v1alpha1"
+	AGENT_WORKLOADS_KIND    = "AgentWorkloads"
+	AGENT_WORKLOADS_PLURAL  = "agentworkloads"
+
+	// The health reported in the AgentWorkloads custom resource
+	WORKLOAD_HEALTH_INSTALLED = "Installed"
+	WORKLOAD_HEALTH_RUNNING   = "Running"
+	WORKLOAD_HEALTH_DEGRADED  = "Degraded"
+
+	// The number of seconds to wait for the AgentWorkloads custom resource definition to be established
+	AGENT_WORKLOADS_CRD_TIMEOUT_S = 30
+)
+
+var agentWorkloadsGVR = schema.GroupVersionResource{Group: AGENT_WORKLOADS_GROUP, Version: AGENT_WORKLOADS_VERSION, Resource: AGENT_WORKLOADS_PLURAL}
+
+// the custom resource definition of the AgentWorkloads resource, the agent creates it if it does not exist
+func agentWorkloadsCRD() *crdv1.CustomResourceDefinition {
+	preserve := true
+	objSchema := crdv1.JSONSchemaProps{Type: "object", XPreserveUnknownFields: &preserve}
+	return &crdv1.CustomResourceDefinition{
+		TypeMeta:   typeMeta(crdv1.SchemeGroupVersion.String(), K8S_CRD_TYPE),
+		ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s.%s", AGENT_WORKLOADS_PLURAL, AGENT_WORKLOADS_GROUP)},
+		Spec: crdv1.CustomResourceDefinitionSpec{
+			Group: AGENT_WORKLOADS_GROUP,
+			Names: crdv1.CustomResourceDefinitionNames{Plural: AGENT_WORKLOADS_PLURAL, Singular: "agentworkload", Kind: AGENT_WORKLOADS_KIND, ListKind: AGENT_WORKLOADS_KIND + "List"},
+			Scope: crdv1.NamespaceScoped,
+			Versions: []crdv1.CustomResourceDefinitionVersion{{
+				Name:    AGENT_WORKLOADS_VERSION,
+				Served:  true,
+				Storage: true,
+				Schema: &crdv1.CustomResourceValidation{OpenAPIV3Schema: &crdv1.JSONSchemaProps{
+					Type:       "object",
+					Properties: map[string]crdv1.JSONSchemaProps{"spec": objSchema, "status": objSchema},
+				}},
+				AdditionalPrinterColumns: []crdv1.CustomResourceColumnDefinition{
+					{Name: "Namespace", Type: "string", JSONPath: ".spec.namespace"},
+					{Name: "Health", Type: "string", JSONPath: ".status.health"},
+				},
+			}},
+		},
+	}
+}
+
+// create the AgentWorkloads custom resource definition if it is not in the cluster yet
+func (c KubeClient) ensureAgentWorkloadsCRD() error {
+	apiClient, err := NewCRDV1Client()
+	if err != nil {
+		return err
+	}
+	crd := agentWorkloadsCRD()
+	if _, err := apiClient.CustomResourceDefinitions().Get(context.Background(), crd.Name, metav1.GetOptions{}); err == nil {
+		return nil
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+
+	glog.V(3).Infof(kwlog(fmt.Sprintf("creating custom resource definition %v", crd.Name)))
+	if _, err := apiClient.CustomResourceDefinitions().Create(context.Background(), crd, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return waitForCRDV1Established(crd.Name, AGENT_WORKLOADS_CRD_TIMEOUT_S)
+}
+
+// PublishWorkloadInventory creates or updates the AgentWorkloads custom resource of the agreement in the agent's namespace.
+// It holds the digest of the operator package, the namespace and objects of the operator and the health reported by the
+// agent. The message is the reason the workload is not healthy, if any. The custom resource is only updated when its
+// content changes, so lastUpdated is the time of the last change and the watchers of the resource are not woken up by
+// every maintenance cycle.
+func (c KubeClient) PublishWorkloadInventory(tar string, metadata map[string]interface{}, agId string, reqNamespace string, health string, message string) error {
+	apiObjMap, opNamespace, err := ProcessDeployment(tar, metadata, map[string]string{}, agId, 0)
+	if err != nil {
+		return err
+	}
+	namespace := getFinalNamespace(reqNamespace, opNamespace)
+
+	if err := c.ensureAgentWorkloadsCRD(); err != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error creating the %v custom resource definition: %v", AGENT_WORKLOADS_KIND, err)))
+	}
+
+	objects := []interface{}{}
+	kinds := append(getBaseK8sKinds(), getMonitoringK8sKinds()...)
+	kinds = append(kinds, K8S_UNSTRUCTURED_TYPE)
+	for _, kind := range kinds {
+		for _, obj := range apiObjMap[kind] {
+			objects = append(objects, map[string]interface{}{"kind": kind, "name": obj.Name()})
+		}
+	}
+
+	digest := sha256.Sum256([]byte(tar))
+	inventory := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": fmt.Sprintf("%s/%s", AGENT_WORKLOADS_GROUP, AGENT_WORKLOADS_VERSION),
+		"kind":       AGENT_WORKLOADS_KIND,
+		"metadata":   map[string]interface{}{"name": agId},
+		"spec": map[string]interface{}{
+			"agreementId":   agId,
+			"packageDigest": fmt.Sprintf("sha256:%s", hex.EncodeToString(digest[:])),
+			"namespace":     namespace,
+			"objects":       objects,
+		},
+		"status": map[string]interface{}{
+			"health":      health,
+			"message":     message,
+			"lastUpdated": time.Now().UTC().Format(time.RFC3339),
+		},
+	}}
+
+	crClient := c.DynClient.Resource(agentWorkloadsGVR).Namespace(cutil.GetClusterNamespace())
+	live, err := crClient.Get(context.Background(), agId, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = crClient.Create(context.Background(), inventory, metav1.CreateOptions{})
+	} else if err == nil {
+		if !inventoryChanged(live, inventory) {
+			glog.V(5).Infof(kwlog(fmt.Sprintf("%v %v is unchanged with health %v", AGENT_WORKLOADS_KIND, agId, health)))
+			return nil
+		}
+		inventory.SetResourceVersion(live.GetResourceVersion())
+		_, err = crClient.Update(context.Background(), inventory, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error publishing %v %v: %v", AGENT_WORKLOADS_KIND, agId, err)))
+	}
+	glog.V(5).Infof(kwlog(fmt.Sprintf("published %v %v with health %v", AGENT_WORKLOADS_KIND, agId, health)))
+	return nil
+}
+
+// DeleteWorkloadInventory deletes the AgentWorkloads custom resource of the agreement
+func (c KubeClient) DeleteWorkloadInventory(agId string) error {
+	err := c.DynClient.Resource(agentWorkloadsGVR).Namespace(cutil.GetClusterNamespace()).Delete(context.Background(), agId, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error deleting %v %v: %v", AGENT_WORKLOADS_KIND, agId, err)))
+	}
+	return nil
+}
+
+// inventoryChanged returns true if the spec, health or message of the inventory differ from the live custom resource
+func inventoryChanged(live *unstructured.Unstructured, inventory *unstructured.Unstructured) bool {
+	liveSpec, _ := json.Marshal(live.Object["spec"])
+	spec, _ := json.Marshal(inventory.Object["spec"])
+	if string(liveSpec) != string(spec) {
+		return true
+	}
+	for _, field := range []string{"health", "message"} {
+		liveValue, _, _ := unstructured.NestedString(live.Object, "status", field)
+		value, _, _ := unstructured.NestedString(inventory.Object, "status", field)
+		if liveValue != value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+//go:build unit
+// +build unit
+
+package kube_operator
+
+import (
+	"encoding/json"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"testing"
+)
+
+// An AgentWorkloads custom resource as the agent builds it.
+func testInventory(digest string, health string, message string, lastUpdated string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"agreementId":   "ag1",
+			"packageDigest": digest,
+			"namespace":     "operator-ns",
+			"objects":       []interface{}{map[string]interface{}{"kind": K8S_DEPLOYMENT_TYPE, "name": "operator"}},
+		},
+		"status": map[string]interface{}{"health": health, "message": message, "lastUpdated": lastUpdated},
+	}}
+}
+
+// The custom resource as it is read back from the API server.
+func liveInventory(t *testing.T, inventory *unstructured.Unstructured) *unstructured.Unstructured {
+	live := &unstructured.Unstructured{}
+	if b, err := json.Marshal(inventory.Object); err != nil {
+		t.Fatalf("unable to marshal the inventory, error: %v", err)
+	} else if err := json.Unmarshal(b, &live.Object); err != nil {
+		t.Fatalf("unable to unmarshal the inventory, error: %v", err)
+	}
+	live.SetResourceVersion("42")
+	return live
+}
+
+func Test_inventoryChanged(t *testing.T) {
+	live := liveInventory(t, testInventory("sha256:a", "healthy", "", "2026-10-15T10:00:00Z"))
+
+	tests := []struct {
+		name      string
+		inventory *unstructured.Unstructured
+		changed   bool
+	}{
+		{"only lastUpdated", testInventory("sha256:a", "healthy", "", "2026-10-15T10:05:00Z"), false},
+		{"package", testInventory("sha256:b", "healthy", "", "2026-10-15T10:05:00Z"), true},
+		{"health", testInventory("sha256:a", "unhealthy", "", "2026-10-15T10:05:00Z"), true},
+		{"message", testInventory("sha256:a", "healthy", "restarting", "2026-10-15T10:05:00Z"), true},
+	}
+
+	for _, test := range tests {
+		if changed := inventoryChanged(live, test.inventory); changed != test.changed {
+			t.Errorf("%v: expected changed %v, but got %v", test.name, test.changed, changed)
+		}
+	}
+
+	// a change of the objects is a change
+	inventory := testInventory("sha256:a", "healthy", "", "2026-10-15T10:05:00Z")
+	inventory.Object["spec"].(map[string]interface{})["objects"] = []interface{}{}
+	if !inventoryChanged(live, inventory) {
+		t.Errorf("a change of the objects should be a change")
+	}
+}
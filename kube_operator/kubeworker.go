@@ -1,24 +1,69 @@
 package kube_operator
 
 import (
+	"encoding/base64"
 	"fmt"
 	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/worker"
+	"strings"
 )
 
+const (
+	EL_KUBE_START_INSTALL           = "Start installing the kube operator for agreement %v in namespace %v."
+	EL_KUBE_INSTALL_COMPLETE        = "Completed installing the kube operator for agreement %v in namespace %v."
+	EL_KUBE_OBJECT_INSTALLED        = "Installed %v %v in namespace %v."
+	EL_KUBE_OBJECT_INSTALL_ERROR    = "Error installing %v %v in namespace %v: %v"
+	EL_KUBE_START_UNINSTALL         = "Start uninstalling the kube operator for agreement %v in namespace %v."
+	EL_KUBE_OBJECT_UNINSTALLED      = "Uninstalled %v %v in namespace %v."
+	EL_KUBE_OBJECT_UNINSTALL_ERROR  = "Error uninstalling %v %v in namespace %v: %v"
+	EL_KUBE_UNINSTALL_COMPLETE      = "Completed uninstalling the kube operator for agreement %v in namespace %v."
+	EL_KUBE_OPERATOR_SCALED         = "Scaled deployment %v in namespace %v from %v to %v replicas."
+	EL_KUBE_OPERATOR_PAUSED         = "Paused deployment %v in namespace %v, scaled it from %v to 0 replicas."
+	EL_KUBE_OPERATOR_RESUMED        = "Resumed deployment %v in namespace %v, scaled it to %v replicas."
+	EL_KUBE_SERVICE_SECRETS_UPDATED = "Updated the service secrets in secret %v in namespace %v."
+	EL_KUBE_OBJECT_DRIFT_REPAIRED   = "Re-applied %v %v in namespace %v because it was deleted or changed outside of the agent."
+)
+
+// This is does nothing useful at run time.
+// This code is only used in compileing time to make the eventlog messages gets into the catalog so that
+// they can be translated.
+// The event log messages will be saved in English. But the CLI can request them in different languages.
+func MarkI18nMessages() {
+	// get message printer. anax default language is English
+	msgPrinter := i18n.GetMessagePrinter()
+
+	msgPrinter.Sprintf(EL_KUBE_START_INSTALL)
+	msgPrinter.Sprintf(EL_KUBE_INSTALL_COMPLETE)
+	msgPrinter.Sprintf(EL_KUBE_OBJECT_INSTALLED)
+	msgPrinter.Sprintf(EL_KUBE_OBJECT_INSTALL_ERROR)
+	msgPrinter.Sprintf(EL_KUBE_START_UNINSTALL)
+	msgPrinter.Sprintf(EL_KUBE_OBJECT_UNINSTALLED)
+	msgPrinter.Sprintf(EL_KUBE_OBJECT_UNINSTALL_ERROR)
+	msgPrinter.Sprintf(EL_KUBE_UNINSTALL_COMPLETE)
+	msgPrinter.Sprintf(EL_KUBE_OPERATOR_SCALED)
+	msgPrinter.Sprintf(EL_KUBE_OPERATOR_PAUSED)
+	msgPrinter.Sprintf(EL_KUBE_OPERATOR_RESUMED)
+	msgPrinter.Sprintf(EL_KUBE_SERVICE_SECRETS_UPDATED)
+	msgPrinter.Sprintf(EL_KUBE_OBJECT_DRIFT_REPAIRED)
+}
+
 type KubeWorker struct {
 	worker.BaseWorker
-	db *bolt.DB
+	db    *bolt.DB
+	queue *installQueue
 }
 
 func NewKubeWorker(name string, config *config.HorizonConfig, db *bolt.DB) *KubeWorker {
 	worker := &KubeWorker{
 		BaseWorker: worker.NewBaseWorker(name, config, nil),
 		db:         db,
+		queue:      newInstallQueue(config.GetK8sMaxConcurrentInstalls()),
 	}
 	glog.Info(kwlog(fmt.Sprintf("Starting Kubernetes Worker")))
 	worker.Start(worker, 0)
@@ -52,12 +97,15 @@ func (w *KubeWorker) NewEvent(incoming events.Message) {
 		case events.CONTAINER_MAINTAIN:
 			cmd := NewMaintenanceCommand(msg.AgreementProtocol, msg.AgreementId, msg.ClusterNamespace, msg.Deployment)
 			w.Commands <- cmd
+		case events.WORKLOAD_PAUSE, events.WORKLOAD_RESUME:
+			w.Commands <- NewPauseCommand(msg.AgreementProtocol, msg.AgreementId, msg.ClusterNamespace, msg.Deployment, msg.Event().Id == events.WORKLOAD_PAUSE)
 		}
 
 	case *events.NodeShutdownCompleteMessage:
 		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
 		switch msg.Event().Id {
 		case events.UNCONFIGURE_COMPLETE:
+			w.queue.stop()
 			w.Commands <- worker.NewTerminateCommand("shutdown")
 		}
 
@@ -87,31 +135,24 @@ func (w *KubeWorker) CommandHandler(command worker.Command) bool {
 			if kd, err := persistence.GetKubeDeployment(deploymentConfig); err != nil {
 				glog.Errorf(kwlog(fmt.Sprintf("error getting kube deployment configuration: %v", err)))
 				return true
-			} else if _, err := persistence.AgreementDeploymentStarted(w.db, lc.AgreementId, lc.AgreementProtocol, kd); err != nil {
-				glog.Errorf(kwlog(fmt.Sprintf("received error updating database deployment state, %v", err)))
-				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, kd)
-				return true
-			} else if err := w.processKubeOperator(lc, kd, w.Config.GetK8sCRInstallTimeouts()); err != nil {
-				glog.Errorf(kwlog(fmt.Sprintf("failed to process kube package after agreement negotiation: %v", err)))
-				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, kd)
-				return true
 			} else {
-				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_BEGUN, lc.AgreementProtocol, lc.AgreementId, kd)
+				if err := w.queue.submit(operatorNamespace(kd, lc.Configure.ClusterNamespace), "install/"+lc.AgreementId, func() { w.handleInstall(lc, kd) }); err != nil {
+					glog.Errorf(kwlog(fmt.Sprintf("unable to install the kube operator for agreement %v: %v", lc.AgreementId, err)))
+					w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, kd)
+				}
 			}
 		}
 	case *UnInstallCommand:
 		cmd := command.(*UnInstallCommand)
-		glog.V(3).Infof(kwlog(fmt.Sprintf("uninstalling operator from agreement %v", cmd.CurrentAgreementId)))
 
 		kdc, ok := cmd.Deployment.(*persistence.KubeDeploymentConfig)
 		if !ok {
 			glog.Warningf(kwlog(fmt.Sprintf("ignoring non-Kube cancelation command %v", cmd)))
 			return true
-		} else if err := w.uninstallKubeOperator(kdc, cmd.CurrentAgreementId, cmd.AgreementProtocol, cmd.ClusterNamespace); err != nil {
-			glog.Errorf(kwlog(fmt.Sprintf("failed to uninstall kube operator %v", cmd.Deployment)))
 		}
-
-		w.Messages() <- events.NewWorkloadMessage(events.WORKLOAD_DESTROYED, cmd.AgreementProtocol, cmd.CurrentAgreementId, kdc)
+		if err := w.queue.submit(operatorNamespace(kdc, cmd.ClusterNamespace), "uninstall/"+cmd.CurrentAgreementId, func() { w.handleUninstall(cmd, kdc) }); err != nil {
+			glog.Errorf(kwlog(fmt.Sprintf("unable to uninstall the kube operator for agreement %v: %v", cmd.CurrentAgreementId, err)))
+		}
 	case *MaintenanceCommand:
 		cmd := command.(*MaintenanceCommand)
 		glog.V(3).Infof(kwlog(fmt.Sprintf("received maintenance command %v", cmd)))
@@ -119,9 +160,26 @@ func (w *KubeWorker) CommandHandler(command worker.Command) bool {
 		kdc, ok := cmd.Deployment.(*persistence.KubeDeploymentConfig)
 		if !ok {
 			glog.Warningf(kwlog(fmt.Sprintf("ignoring non-Kube maintenence command: %v", cmd)))
-		} else if err := w.operatorStatus(kdc, "Running", cmd.AgreementId, cmd.AgreementProtocol, cmd.ClusterNamespace); err != nil {
-			glog.Errorf(kwlog(fmt.Sprintf("%v", err)))
-			w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, cmd.AgreementProtocol, cmd.AgreementId, kdc)
+			return true
+		}
+		if err := w.queue.submit(operatorNamespace(kdc, cmd.ClusterNamespace), "maintain/"+cmd.AgreementId, func() { w.handleMaintenance(cmd, kdc) }); err != nil {
+			glog.Errorf(kwlog(fmt.Sprintf("unable to maintain the kube operator for agreement %v: %v", cmd.AgreementId, err)))
+		}
+	case *PauseCommand:
+		cmd := command.(*PauseCommand)
+		glog.V(3).Infof(kwlog(fmt.Sprintf("received pause command %v", cmd)))
+
+		kdc, ok := cmd.Deployment.(*persistence.KubeDeploymentConfig)
+		if !ok {
+			glog.V(5).Infof(kwlog(fmt.Sprintf("ignoring non-Kube pause command: %v", cmd)))
+			return true
+		}
+		if err := w.queue.submit(operatorNamespace(kdc, cmd.ClusterNamespace), "pause/"+cmd.AgreementId, func() {
+			if err := w.pauseKubeOperator(kdc, cmd.AgreementId, cmd.AgreementProtocol, cmd.ClusterNamespace, cmd.Pause); err != nil {
+				glog.Errorf(kwlog(fmt.Sprintf("failed to change the paused state of the kube operator for agreement %v: %v", cmd.AgreementId, err)))
+			}
+		}); err != nil {
+			glog.Errorf(kwlog(fmt.Sprintf("unable to change the paused state of the kube operator for agreement %v: %v", cmd.AgreementId, err)))
 		}
 	default:
 		return true
@@ -129,6 +187,60 @@ func (w *KubeWorker) CommandHandler(command worker.Command) bool {
 	return true
 }
 
+func (w *KubeWorker) handleInstall(lc *events.AgreementLaunchContext, kd *persistence.KubeDeploymentConfig) {
+	if _, err := persistence.AgreementDeploymentStarted(w.db, lc.AgreementId, lc.AgreementProtocol, kd); err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("received error updating database deployment state, %v", err)))
+		w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, kd)
+	} else if err := w.processKubeOperator(lc, kd, NewInstallTimeouts(w.Config)); err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("failed to process kube package after agreement negotiation: %v", err)))
+		w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, kd)
+	} else {
+		w.publishWorkloadInventory(kd, lc.AgreementId, lc.Configure.ClusterNamespace, WORKLOAD_HEALTH_INSTALLED, "")
+		w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_BEGUN, lc.AgreementProtocol, lc.AgreementId, kd)
+	}
+}
+
+func (w *KubeWorker) handleUninstall(cmd *UnInstallCommand, kdc *persistence.KubeDeploymentConfig) {
+	glog.V(3).Infof(kwlog(fmt.Sprintf("uninstalling operator from agreement %v", cmd.CurrentAgreementId)))
+
+	if err := w.uninstallKubeOperator(kdc, cmd.CurrentAgreementId, cmd.AgreementProtocol, cmd.ClusterNamespace); err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("failed to uninstall kube operator %v: %v", cmd.Deployment, err)))
+	}
+	w.deleteWorkloadInventory(cmd.CurrentAgreementId)
+
+	w.Messages() <- events.NewWorkloadMessage(events.WORKLOAD_DESTROYED, cmd.AgreementProtocol, cmd.CurrentAgreementId, kdc)
+}
+
+func (w *KubeWorker) handleMaintenance(cmd *MaintenanceCommand, kdc *persistence.KubeDeploymentConfig) {
+	// put back the objects that were changed outside of the agent before checking the operator status
+	if err := w.reconcileKubeOperator(kdc, cmd.AgreementId, cmd.AgreementProtocol, cmd.ClusterNamespace); err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("failed to reconcile kube operator for agreement %v: %v", cmd.AgreementId, err)))
+	}
+
+	if err := w.operatorStatus(kdc, "Running", cmd.AgreementId, cmd.AgreementProtocol, cmd.ClusterNamespace); err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("%v", err)))
+		w.publishWorkloadInventory(kdc, cmd.AgreementId, cmd.ClusterNamespace, WORKLOAD_HEALTH_DEGRADED, err.Error())
+		w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, cmd.AgreementProtocol, cmd.AgreementId, kdc)
+	} else {
+		w.publishWorkloadInventory(kdc, cmd.AgreementId, cmd.ClusterNamespace, WORKLOAD_HEALTH_RUNNING, "")
+		if err := w.scaleKubeOperator(kdc, cmd.AgreementId, cmd.AgreementProtocol, cmd.ClusterNamespace); err != nil {
+			glog.Errorf(kwlog(fmt.Sprintf("failed to scale kube operator for agreement %v: %v", cmd.AgreementId, err)))
+		}
+		if err := w.updateServiceSecrets(kdc, cmd.AgreementId, cmd.AgreementProtocol, cmd.ClusterNamespace); err != nil {
+			glog.Errorf(kwlog(fmt.Sprintf("failed to update the service secrets for agreement %v: %v", cmd.AgreementId, err)))
+		}
+	}
+}
+
+// operatorNamespace returns the namespace the operator is installed in, which is the key used to order the work in the queue
+func operatorNamespace(kd *persistence.KubeDeploymentConfig, reqNamespace string) string {
+	opNamespace := ""
+	if kd.Metadata != nil {
+		opNamespace, _ = kd.Metadata["namespace"].(string)
+	}
+	return getFinalNamespace(reqNamespace, opNamespace)
+}
+
 func (w *KubeWorker) getLaunchContext(launchContext interface{}) *events.AgreementLaunchContext {
 	switch launchContext.(type) {
 	case *events.AgreementLaunchContext:
@@ -138,14 +250,19 @@ func (w *KubeWorker) getLaunchContext(launchContext interface{}) *events.Agreeme
 	return nil
 }
 
-func (w *KubeWorker) processKubeOperator(lc *events.AgreementLaunchContext, kd *persistence.KubeDeploymentConfig, crInstallTimeout int64) error {
+func (w *KubeWorker) processKubeOperator(lc *events.AgreementLaunchContext, kd *persistence.KubeDeploymentConfig, timeouts InstallTimeouts) error {
 	glog.V(3).Infof(kwlog(fmt.Sprintf("begin install of Kube Deployment %s", lc.AgreementId)))
 
 	client, err := NewKubeClient()
 	if err != nil {
 		return err
 	}
-	err = client.Install(kd.OperatorYamlArchive, kd.Metadata, *(lc.EnvironmentAdditions), lc.AgreementId, lc.Configure.ClusterNamespace, crInstallTimeout)
+	serviceSecrets, err := w.getServiceSecrets(lc.AgreementId, lc.AgreementProtocol)
+	if err != nil {
+		return err
+	}
+	client.EventHandler = w.agreementEventHandler(lc.AgreementId, lc.AgreementProtocol)
+	err = client.Install(kd.OperatorYamlArchive, kd.Metadata, *(lc.EnvironmentAdditions), serviceSecrets, lc.AgreementId, lc.Configure.ClusterNamespace, timeouts, NewImagePolicy(w.Config))
 	if err != nil {
 		return err
 	}
@@ -159,6 +276,7 @@ func (w *KubeWorker) uninstallKubeOperator(kd *persistence.KubeDeploymentConfig,
 	if err != nil {
 		return err
 	}
+	client.EventHandler = w.agreementEventHandler(agId, agp)
 	err = client.Uninstall(kd.OperatorYamlArchive, kd.Metadata, agId, reqNamespace)
 	if err != nil {
 		return err
@@ -189,6 +307,123 @@ func (w *KubeWorker) operatorStatus(kd *persistence.KubeDeploymentConfig, intend
 	return nil
 }
 
+func (w *KubeWorker) scaleKubeOperator(kd *persistence.KubeDeploymentConfig, agId string, agp string, reqNamespace string) error {
+	if _, ok := kd.Metadata[REPLICA_POLICY_KEY]; !ok {
+		return nil
+	}
+
+	client, err := NewKubeClient()
+	if err != nil {
+		return err
+	}
+	client.EventHandler = w.agreementEventHandler(agId, agp)
+	return client.Scale(kd.OperatorYamlArchive, kd.Metadata, agId, reqNamespace)
+}
+
+func (w *KubeWorker) pauseKubeOperator(kd *persistence.KubeDeploymentConfig, agId string, agp string, reqNamespace string, pause bool) error {
+	client, err := NewKubeClient()
+	if err != nil {
+		return err
+	}
+	client.EventHandler = w.agreementEventHandler(agId, agp)
+	return client.SetPaused(kd.OperatorYamlArchive, kd.Metadata, agId, reqNamespace, pause)
+}
+
+// The AgentWorkloads custom resource is informational, so failing to publish it does not fail the workload
+func (w *KubeWorker) publishWorkloadInventory(kd *persistence.KubeDeploymentConfig, agId string, reqNamespace string, health string, message string) {
+	client, err := NewKubeClient()
+	if err == nil {
+		err = client.PublishWorkloadInventory(kd.OperatorYamlArchive, kd.Metadata, agId, reqNamespace, health, strings.TrimSpace(message))
+	}
+	if err != nil {
+		glog.Warningf(kwlog(fmt.Sprintf("unable to publish the workload inventory for agreement %v: %v", agId, err)))
+	}
+}
+
+func (w *KubeWorker) deleteWorkloadInventory(agId string) {
+	client, err := NewKubeClient()
+	if err == nil {
+		err = client.DeleteWorkloadInventory(agId)
+	}
+	if err != nil {
+		glog.Warningf(kwlog(fmt.Sprintf("unable to delete the workload inventory for agreement %v: %v", agId, err)))
+	}
+}
+
+func (w *KubeWorker) reconcileKubeOperator(kd *persistence.KubeDeploymentConfig, agId string, agp string, reqNamespace string) error {
+	serviceSecrets, err := w.getServiceSecrets(agId, agp)
+	if err != nil {
+		return err
+	}
+
+	client, err := NewKubeClient()
+	if err != nil {
+		return err
+	}
+	client.EventHandler = w.agreementEventHandler(agId, agp)
+	return client.Reconcile(kd.OperatorYamlArchive, kd.Metadata, serviceSecrets, agId, reqNamespace, NewImagePolicy(w.Config))
+}
+
+func (w *KubeWorker) updateServiceSecrets(kd *persistence.KubeDeploymentConfig, agId string, agp string, reqNamespace string) error {
+	serviceSecrets, err := w.getServiceSecrets(agId, agp)
+	if err != nil {
+		return err
+	} else if len(serviceSecrets) == 0 {
+		return nil
+	}
+
+	client, err := NewKubeClient()
+	if err != nil {
+		return err
+	}
+	client.EventHandler = w.agreementEventHandler(agId, agp)
+	return client.UpdateServiceSecrets(kd.OperatorYamlArchive, kd.Metadata, agId, reqNamespace, serviceSecrets)
+}
+
+// get the decoded contents of the secrets bound to the top level service of the agreement, keyed by secret name
+func (w *KubeWorker) getServiceSecrets(agId string, agp string) (map[string][]byte, error) {
+	agSecrets, err := persistence.FindAgreementSecrets(w.db, agId)
+	if err != nil {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("unable to retrieve the secrets for agreement %v from database, error %v", agId, err)))
+	} else if agSecrets == nil || len(*agSecrets) == 0 {
+		return nil, nil
+	}
+
+	ags, err := persistence.FindEstablishedAgreements(w.db, agp, []persistence.EAFilter{persistence.IdEAFilter(agId)})
+	if err != nil {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("unable to retrieve agreement %v from database, error %v", agId, err)))
+	} else if len(ags) != 1 {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("unable to find agreement %v in the database", agId)))
+	}
+	workload := ags[0].RunningWorkload
+
+	serviceSecrets := map[string][]byte{}
+	for _, sec := range *agSecrets {
+		if sec.SvcOrgid != workload.Org || sec.SvcUrl != workload.URL {
+			continue
+		}
+		if contents, err := base64.StdEncoding.DecodeString(sec.SvcSecretValue); err != nil {
+			return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error decoding base64 encoded secret %v for agreement %v: %v", sec.SvcSecretName, agId, err)))
+		} else {
+			serviceSecrets[sec.SvcSecretName] = contents
+		}
+	}
+	return serviceSecrets, nil
+}
+
+// returns an event handler that saves the kube object lifecycle steps in the eventlog of the given agreement
+func (w *KubeWorker) agreementEventHandler(agId string, agp string) ObjectEventHandler {
+	return func(severity string, messageMeta *persistence.MessageMeta, eventCode string) {
+		if ags, err := persistence.FindEstablishedAgreements(w.db, agp, []persistence.EAFilter{persistence.IdEAFilter(agId)}); err != nil {
+			glog.Errorf(kwlog(fmt.Sprintf("unable to retrieve agreement %v from database, error %v", agId, err)))
+		} else if len(ags) != 1 {
+			glog.Warningf(kwlog(fmt.Sprintf("unable to find agreement %v in the database, not saving event %v", agId, eventCode)))
+		} else if err := eventlog.LogAgreementEvent(w.db, severity, messageMeta, eventCode, ags[0]); err != nil {
+			glog.Errorf(kwlog(fmt.Sprintf("unable to save event %v for agreement %v, error %v", eventCode, agId, err)))
+		}
+	}
+}
+
 var kwlog = func(v interface{}) string {
 	return fmt.Sprintf("Kubernetes Worker: %v", v)
 }
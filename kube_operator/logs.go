@@ -0,0 +1,87 @@
+package kube_operator
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sync"
+	"time"
+)
+
+// The log records of the containers returned by ServiceLogs.
+type LogOptions struct {
+	Container string    // Only the records of the containers with this name, all the containers when empty.
+	Follow    bool      // Keep returning the new records until the context is done.
+	Since     time.Time // Only the records logged at or after this time, all the records when zero.
+	Tail      int       // Only the last records of each container, all the records when negative.
+}
+
+// ServiceLogs reads the logs of the containers of the pods in the namespace of the operator, and passes each record to
+// the output function with the name of its container, <pod name>/<container name>. The logs of all the containers are
+// read at the same time, the output function is never called concurrently. Reading stops at the first error returned
+// by the output function.
+func (c KubeClient) ServiceLogs(ctx context.Context, tar string, metadata map[string]interface{}, agId string, reqNamespace string, opts LogOptions, output func(name string, line string) error) error {
+	_, opNamespace, err := ProcessDeployment(tar, metadata, map[string]string{}, agId, 0)
+	if err != nil {
+		return err
+	}
+	namespace := getFinalNamespace(reqNamespace, opNamespace)
+
+	pods, err := c.Client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error listing the pods in namespace %v: %v", namespace, err)))
+	}
+
+	podLogOpts := corev1.PodLogOptions{Follow: opts.Follow}
+	if !opts.Since.IsZero() {
+		since := metav1.NewTime(opts.Since)
+		podLogOpts.SinceTime = &since
+	}
+	if opts.Tail >= 0 {
+		tail := int64(opts.Tail)
+		podLogOpts.TailLines = &tail
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var outErr error
+	for _, pod := range pods.Items {
+		for _, container := range pod.Spec.Containers {
+			if opts.Container != "" && opts.Container != container.Name {
+				continue
+			}
+			containerOpts := podLogOpts
+			containerOpts.Container = container.Name
+			stream, err := c.Client.CoreV1().Pods(namespace).GetLogs(pod.Name, &containerOpts).Stream(ctx)
+			if err != nil {
+				// Stop reading the logs of the other containers before returning.
+				cancel()
+				wg.Wait()
+				return fmt.Errorf(kwlog(fmt.Sprintf("Error getting the logs of container %v in pod %v: %v", container.Name, pod.Name, err)))
+			}
+
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+				defer stream.Close()
+				scanner := bufio.NewScanner(stream)
+				for scanner.Scan() {
+					lock.Lock()
+					if outErr == nil {
+						if outErr = output(name, scanner.Text()); outErr != nil {
+							cancel()
+						}
+					}
+					lock.Unlock()
+				}
+			}(pod.Name + "/" + container.Name)
+		}
+	}
+	wg.Wait()
+	return outErr
+}
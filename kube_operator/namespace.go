@@ -0,0 +1,106 @@
+package kube_operator
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"strings"
+)
+
+const (
+	// The name of the attribute in the clusterDeployment metadata that holds the labels and annotations of the namespace
+	NAMESPACE_METADATA_KEY = "namespaceMetadata"
+)
+
+// NamespaceMetadata holds the labels and annotations that the agent sets on the namespace it creates for the operator,
+// e.g. pod-security.kubernetes.io/enforce or istio-injection, so that the namespace satisfies the cluster admission policies.
+type NamespaceMetadata struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+func (n NamespaceMetadata) String() string {
+	return fmt.Sprintf("Labels: %v, Annotations: %v", n.Labels, n.Annotations)
+}
+
+func (n NamespaceMetadata) Validate() error {
+	for key, value := range n.Labels {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("label key %v is invalid: %v", key, strings.Join(errs, "; "))
+		} else if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("value %v of label %v is invalid: %v", value, key, strings.Join(errs, "; "))
+		}
+	}
+	for key := range n.Annotations {
+		if errs := validation.IsQualifiedName(strings.ToLower(key)); len(errs) > 0 {
+			return fmt.Errorf("annotation key %v is invalid: %v", key, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// apply adds the labels and annotations to the namespace. They take precedence over the ones in the operator yaml archive.
+func (n NamespaceMetadata) apply(ns *corev1.Namespace) {
+	if len(n.Labels) > 0 && ns.ObjectMeta.Labels == nil {
+		ns.ObjectMeta.Labels = map[string]string{}
+	}
+	for key, value := range n.Labels {
+		ns.ObjectMeta.Labels[key] = value
+	}
+	if len(n.Annotations) > 0 && ns.ObjectMeta.Annotations == nil {
+		ns.ObjectMeta.Annotations = map[string]string{}
+	}
+	for key, value := range n.Annotations {
+		ns.ObjectMeta.Annotations[key] = value
+	}
+}
+
+// GetNamespaceMetadata returns the namespace labels and annotations from the clusterDeployment metadata, nil if there are none
+func GetNamespaceMetadata(metadata map[string]interface{}) (*NamespaceMetadata, error) {
+	if metadata == nil {
+		return nil, nil
+	}
+	nm, ok := metadata[NAMESPACE_METADATA_KEY]
+	if !ok || nm == nil {
+		return nil, nil
+	}
+
+	nsMeta := new(NamespaceMetadata)
+	if jBytes, err := json.Marshal(nm); err != nil {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error marshaling the namespace metadata %v: %v", nm, err)))
+	} else if err := json.Unmarshal(jBytes, nsMeta); err != nil {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error: the %v attribute in the metadata has wrong format: %v", NAMESPACE_METADATA_KEY, err)))
+	} else if err := nsMeta.Validate(); err != nil {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error: invalid %v in the metadata: %v", NAMESPACE_METADATA_KEY, err)))
+	}
+	return nsMeta, nil
+}
+
+// addNamespaceObject adds the namespace object to be created for the operator if the operator yaml archive does not
+// have one and the namespace is not the agent's namespace, then sets the labels and annotations from the metadata on it.
+// The agent's namespace is not changed, so the labels and annotations are not applied when the operator is installed in it.
+func addNamespaceObject(apiObjMap map[string][]APIObjectInterface, metadata map[string]interface{}, namespace string, nodeNamespace string) error {
+	if _, ok := apiObjMap[K8S_NAMESPACE_TYPE]; !ok && namespace != nodeNamespace {
+		nsObj := corev1.Namespace{TypeMeta: metav1.TypeMeta{Kind: "Namespace"}, ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		apiObjMap[K8S_NAMESPACE_TYPE] = []APIObjectInterface{NamespaceCoreV1{NamespaceObject: &nsObj}}
+	}
+
+	nsMeta, err := GetNamespaceMetadata(metadata)
+	if err != nil || nsMeta == nil {
+		return err
+	}
+	applied := false
+	for _, obj := range apiObjMap[K8S_NAMESPACE_TYPE] {
+		if ns, ok := obj.(NamespaceCoreV1); ok {
+			nsMeta.apply(ns.NamespaceObject)
+			applied = true
+		}
+	}
+	if !applied && (len(nsMeta.Labels) > 0 || len(nsMeta.Annotations) > 0) {
+		glog.Warningf(kwlog(fmt.Sprintf("the %v %v are not applied, the operator is installed in the agent namespace %v which is not changed by the agent", NAMESPACE_METADATA_KEY, nsMeta, namespace)))
+	}
+	return nil
+}
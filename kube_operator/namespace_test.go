@@ -0,0 +1,89 @@
+//go:build unit
+// +build unit
+
+package kube_operator
+
+import (
+	"encoding/json"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func Test_addNamespaceObject(t *testing.T) {
+	metadata := map[string]interface{}{NAMESPACE_METADATA_KEY: map[string]interface{}{"labels": map[string]interface{}{"istio-injection": "enabled"}}}
+	namespaceOf := func(apiObjMap map[string][]APIObjectInterface) *corev1.Namespace {
+		if objs := apiObjMap[K8S_NAMESPACE_TYPE]; len(objs) == 1 {
+			return objs[0].(NamespaceCoreV1).NamespaceObject
+		}
+		return nil
+	}
+
+	// the namespace created for the operator gets the labels
+	apiObjMap := map[string][]APIObjectInterface{}
+	if err := addNamespaceObject(apiObjMap, metadata, "operator-ns", "agent-ns"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if ns := namespaceOf(apiObjMap); ns == nil || ns.Name != "operator-ns" || ns.Labels["istio-injection"] != "enabled" {
+		t.Errorf("wrong namespace object %v", ns)
+	}
+
+	// the labels take precedence over the ones of the namespace in the archive
+	archiveNs := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "operator-ns", Labels: map[string]string{"istio-injection": "disabled", "team": "a"}}}
+	apiObjMap = map[string][]APIObjectInterface{K8S_NAMESPACE_TYPE: {NamespaceCoreV1{NamespaceObject: archiveNs}}}
+	if err := addNamespaceObject(apiObjMap, metadata, "operator-ns", "agent-ns"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if !reflect.DeepEqual(archiveNs.Labels, map[string]string{"istio-injection": "enabled", "team": "a"}) {
+		t.Errorf("wrong labels %v", archiveNs.Labels)
+	}
+
+	// the agent namespace is not changed
+	apiObjMap = map[string][]APIObjectInterface{}
+	if err := addNamespaceObject(apiObjMap, metadata, "agent-ns", "agent-ns"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if ns := namespaceOf(apiObjMap); ns != nil {
+		t.Errorf("the agent namespace should not be created, got %v", ns)
+	}
+
+	// invalid labels are rejected
+	invalid := map[string]interface{}{NAMESPACE_METADATA_KEY: map[string]interface{}{"labels": map[string]interface{}{"bad key!": "x"}}}
+	if err := addNamespaceObject(map[string][]APIObjectInterface{}, invalid, "operator-ns", "agent-ns"); err == nil {
+		t.Errorf("an invalid label should have returned an error")
+	}
+}
+
+func Test_NamespaceCoreV1_Install_Existing(t *testing.T) {
+	var updated *corev1.Namespace
+	c := newTestKubeClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodPost:
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprintf(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"AlreadyExists","code":409}`)
+		case http.MethodGet:
+			fmt.Fprintf(w, `{"kind":"Namespace","apiVersion":"v1","metadata":{"name":"operator-ns","labels":{"team":"a"}}}`)
+		case http.MethodPut:
+			updated = &corev1.Namespace{}
+			json.NewDecoder(r.Body).Decode(updated)
+			json.NewEncoder(w).Encode(updated)
+		}
+	})
+
+	ns := NamespaceCoreV1{NamespaceObject: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "operator-ns", Labels: map[string]string{"istio-injection": "enabled"}}}}
+	if err := ns.Install(c, "operator-ns"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if updated == nil || !reflect.DeepEqual(updated.Labels, map[string]string{"istio-injection": "enabled", "team": "a"}) {
+		t.Errorf("the labels should be added to the existing namespace, got %v", updated)
+	}
+
+	// the namespace is not updated when it already has the labels
+	updated = nil
+	ns = NamespaceCoreV1{NamespaceObject: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "operator-ns", Labels: map[string]string{"team": "a"}}}}
+	if err := ns.Install(c, "operator-ns"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if updated != nil {
+		t.Errorf("the namespace should not be updated, got %v", updated)
+	}
+}
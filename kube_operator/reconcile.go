@@ -0,0 +1,209 @@
+package kube_operator
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/persistence"
+	appsv1 "k8s.io/api/apps/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"reflect"
+)
+
+const (
+	// Objects with this annotation set to "true" are not reconciled by the agent, so that a cluster admin can make changes to them
+	RECONCILE_OPT_OUT_ANNOTATION = "openhorizon.org/skip-reconcile"
+)
+
+// Reconcile compares the live Deployment and RBAC objects of the operator with the objects in the operator package and
+// re-applies the objects that were deleted or changed by a cluster admin or another controller. The replica count of the
+// Deployment is not compared because it can be changed by the agent's replica policy or by an autoscaler.
+func (c KubeClient) Reconcile(tar string, metadata map[string]interface{}, serviceSecrets map[string][]byte, agId string, reqNamespace string, imagePolicy ImagePolicy) error {
+	apiObjMap, opNamespace, err := ProcessDeployment(tar, metadata, map[string]string{}, agId, 0)
+	if err != nil {
+		return err
+	}
+	if err := imagePolicy.Apply(apiObjMap); err != nil {
+		return err
+	}
+	namespace := getFinalNamespace(reqNamespace, opNamespace)
+
+	for _, kind := range []string{K8S_SERVICEACCOUNT_TYPE, K8S_ROLE_TYPE, K8S_ROLEBINDING_TYPE, K8S_DEPLOYMENT_TYPE} {
+		for _, obj := range apiObjMap[kind] {
+			var repaired bool
+			var err error
+			switch typed := obj.(type) {
+			case ServiceAccountCoreV1:
+				repaired, err = c.reconcileServiceAccount(typed, namespace)
+			case RoleRbacV1:
+				repaired, err = c.reconcileRole(typed, namespace)
+			case RolebindingRbacV1:
+				repaired, err = c.reconcileRoleBinding(typed, namespace)
+			case DeploymentAppsV1:
+				typed.ServiceSecrets = serviceSecrets
+				repaired, err = c.reconcileDeployment(typed, namespace)
+			}
+			if err != nil {
+				return fmt.Errorf(kwlog(fmt.Sprintf("Error reconciling %v %v for agreement %v: %v", kind, obj.Name(), agId, err)))
+			} else if repaired {
+				glog.Infof(kwlog(fmt.Sprintf("re-applied %v %v for agreement %v", kind, obj.Name(), agId)))
+				c.logObjectEvent(persistence.SEVERITY_WARN, persistence.NewMessageMeta(EL_KUBE_OBJECT_DRIFT_REPAIRED, kind, obj.Name(), namespace), persistence.EC_K8S_OBJECT_DRIFT_REPAIRED)
+			}
+		}
+	}
+	return nil
+}
+
+func (c KubeClient) reconcileServiceAccount(sa ServiceAccountCoreV1, namespace string) (bool, error) {
+	_, err := c.Client.CoreV1().ServiceAccounts(namespace).Get(context.Background(), sa.Name(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = c.Client.CoreV1().ServiceAccounts(namespace).Create(context.Background(), sa.ServiceAccountObject, metav1.CreateOptions{})
+		return err == nil, err
+	}
+	return false, err
+}
+
+func (c KubeClient) reconcileRole(r RoleRbacV1, namespace string) (bool, error) {
+	live, err := c.Client.RbacV1().Roles(namespace).Get(context.Background(), r.Name(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = c.Client.RbacV1().Roles(namespace).Create(context.Background(), r.RoleObject, metav1.CreateOptions{})
+		return err == nil, err
+	} else if err != nil || skipReconcile(live.ObjectMeta) {
+		return false, err
+	} else if isSubset(r.RoleObject.Rules, live.Rules) {
+		return false, nil
+	}
+
+	live.Rules = r.RoleObject.Rules
+	_, err = c.Client.RbacV1().Roles(namespace).Update(context.Background(), live, metav1.UpdateOptions{})
+	return err == nil, err
+}
+
+func (c KubeClient) reconcileRoleBinding(rb RolebindingRbacV1, namespace string) (bool, error) {
+	live, err := c.Client.RbacV1().RoleBindings(namespace).Get(context.Background(), rb.Name(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = c.Client.RbacV1().RoleBindings(namespace).Create(context.Background(), rb.RolebindingObject, metav1.CreateOptions{})
+		return err == nil, err
+	} else if err != nil || skipReconcile(live.ObjectMeta) {
+		return false, err
+	} else if isSubset(rb.RolebindingObject.RoleRef, live.RoleRef) && isSubset(rb.RolebindingObject.Subjects, live.Subjects) {
+		return false, nil
+	}
+
+	// the role reference cannot be changed, so the binding is replaced
+	if !reflect.DeepEqual(rb.RolebindingObject.RoleRef, live.RoleRef) {
+		if err := c.Client.RbacV1().RoleBindings(namespace).Delete(context.Background(), rb.Name(), metav1.DeleteOptions{}); err != nil {
+			return false, err
+		}
+		_, err = c.Client.RbacV1().RoleBindings(namespace).Create(context.Background(), rb.RolebindingObject, metav1.CreateOptions{})
+		return err == nil, err
+	}
+
+	live.Subjects = rb.RolebindingObject.Subjects
+	_, err = c.Client.RbacV1().RoleBindings(namespace).Update(context.Background(), live, metav1.UpdateOptions{})
+	return err == nil, err
+}
+
+func (c KubeClient) reconcileDeployment(d DeploymentAppsV1, namespace string) (bool, error) {
+	secretName := ""
+	if len(d.ServiceSecrets) > 0 {
+		secretName = serviceSecretName(d.AgreementId)
+	}
+	desired := d.resolve(fmt.Sprintf("%s-%s", HZN_ENV_VARS, d.AgreementId), secretName)
+
+	live, err := c.Client.AppsV1().Deployments(namespace).Get(context.Background(), d.Name(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = c.Client.AppsV1().Deployments(namespace).Create(context.Background(), &desired, metav1.CreateOptions{})
+		return err == nil, err
+	} else if err != nil || skipReconcile(live.ObjectMeta) {
+		return false, err
+	}
+
+	// the replica count is owned by the replica policy or the autoscaler once the deployment is running
+	desiredSpec := desired.Spec.DeepCopy()
+	desiredSpec.Replicas = live.Spec.Replicas
+	if isSubset(desiredSpec, &live.Spec) {
+		return false, nil
+	}
+
+	live.Spec = *desiredSpec
+	_, err = c.Client.AppsV1().Deployments(namespace).Update(context.Background(), live, metav1.UpdateOptions{})
+	return err == nil, err
+}
+
+func skipReconcile(meta metav1.ObjectMeta) bool {
+	return meta.Annotations[RECONCILE_OPT_OUT_ANNOTATION] == "true"
+}
+
+// isSubset returns true if every field that is set in the desired object has the same value in the live object. Fields
+// that are only set in the live object are ignored, because the api server fills in defaults for the unset fields.
+func isSubset(desired interface{}, live interface{}) bool {
+	desiredMap, err1 := toUnstructuredValue(desired)
+	liveMap, err2 := toUnstructuredValue(live)
+	if err1 != nil || err2 != nil {
+		return reflect.DeepEqual(desired, live)
+	}
+	return isValueSubset(desiredMap, liveMap)
+}
+
+// convert a typed k8s value to the generic form used by the unstructured objects
+func toUnstructuredValue(obj interface{}) (interface{}, error) {
+	switch typed := obj.(type) {
+	case []rbacv1.PolicyRule:
+		return toUnstructuredList(len(typed), func(i int) interface{} { return &typed[i] })
+	case []rbacv1.Subject:
+		return toUnstructuredList(len(typed), func(i int) interface{} { return &typed[i] })
+	case rbacv1.RoleRef:
+		return runtime.DefaultUnstructuredConverter.ToUnstructured(&typed)
+	case *appsv1.DeploymentSpec:
+		return runtime.DefaultUnstructuredConverter.ToUnstructured(typed)
+	}
+	return nil, fmt.Errorf("unsupported type %T", obj)
+}
+
+func toUnstructuredList(length int, elem func(int) interface{}) (interface{}, error) {
+	list := []interface{}{}
+	for i := 0; i < length; i++ {
+		if u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(elem(i)); err != nil {
+			return nil, err
+		} else {
+			list = append(list, u)
+		}
+	}
+	return list, nil
+}
+
+func isValueSubset(desired interface{}, live interface{}) bool {
+	switch typedDesired := desired.(type) {
+	case map[string]interface{}:
+		typedLive, ok := live.(map[string]interface{})
+		if !ok {
+			return len(typedDesired) == 0
+		}
+		for key, value := range typedDesired {
+			if !isValueSubset(value, typedLive[key]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		typedLive, ok := live.([]interface{})
+		if !ok {
+			return len(typedDesired) == 0
+		} else if len(typedDesired) != len(typedLive) {
+			return false
+		}
+		for i := range typedDesired {
+			if !isValueSubset(typedDesired[i], typedLive[i]) {
+				return false
+			}
+		}
+		return true
+	case nil:
+		return true
+	}
+	return reflect.DeepEqual(desired, live)
+}
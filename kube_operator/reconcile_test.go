@@ -0,0 +1,55 @@
+//go:build unit
+// +build unit
+
+package kube_operator
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func Test_isSubset(t *testing.T) {
+	rules := []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}}}
+	replicas, history := int32(1), int32(10)
+	spec := func(image string) *appsv1.DeploymentSpec {
+		return &appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "operator"}},
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "operator", Image: image}}}},
+		}
+	}
+	defaulted := spec("operator:1.0")
+	defaulted.RevisionHistoryLimit = &history
+	defaulted.Template.Spec.RestartPolicy = corev1.RestartPolicyAlways
+	defaulted.Template.Spec.Containers[0].ImagePullPolicy = corev1.PullIfNotPresent
+
+	tests := []struct {
+		name    string
+		desired interface{}
+		live    interface{}
+		subset  bool
+	}{
+		{"same rules", rules, []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}}}, true},
+		{"changed verbs", rules, []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list", "delete"}}}, false},
+		{"extra rule", rules, append([]rbacv1.PolicyRule{{Resources: []string{"secrets"}, Verbs: []string{"get"}}}, rules...), false},
+		{"rules removed", rules, []rbacv1.PolicyRule{}, false},
+		{"no rules", []rbacv1.PolicyRule{}, []rbacv1.PolicyRule{}, true},
+		{"subject namespace defaulted", []rbacv1.Subject{{Kind: "ServiceAccount", Name: "sa"}}, []rbacv1.Subject{{Kind: "ServiceAccount", Name: "sa", Namespace: "ns"}}, true},
+		{"other subject", []rbacv1.Subject{{Kind: "ServiceAccount", Name: "sa"}}, []rbacv1.Subject{{Kind: "ServiceAccount", Name: "other"}}, false},
+		{"same role ref", rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "Role", Name: "role"}, rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "Role", Name: "role"}, true},
+		{"other role ref", rbacv1.RoleRef{Kind: "Role", Name: "role"}, rbacv1.RoleRef{Kind: "ClusterRole", Name: "role"}, false},
+		{"deployment defaulted by the api server", spec("operator:1.0"), defaulted, true},
+		{"deployment image changed", spec("operator:1.0"), spec("operator:2.0"), false},
+		{"unsupported type equal", 1, 1, true},
+		{"unsupported type different", 1, 2, false},
+	}
+
+	for _, test := range tests {
+		if subset := isSubset(test.desired, test.live); subset != test.subset {
+			t.Errorf("%v: expected %v, but got %v", test.name, test.subset, subset)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package kube_operator
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/cutil"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	crdv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RenderDeployment resolves the operator deployment the same way Install does, but without contacting a cluster.
+// It returns the objects that the agent would create, in install order, with the namespace set, the env var config
+// map added and the registry mirror applied. It is used by the CLI so that authors can see what will be applied.
+func RenderDeployment(tar string, metadata map[string]interface{}, envVars map[string]string, agId string, reqNamespace string, imagePolicy ImagePolicy) ([]interface{}, string, error) {
+
+	apiObjMap, opNamespace, err := ProcessDeployment(tar, metadata, envVars, agId, 0)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := imagePolicy.Apply(apiObjMap); err != nil {
+		return nil, "", err
+	}
+
+	namespace := getFinalNamespace(reqNamespace, opNamespace)
+	nodeNamespace := cutil.GetClusterNamespace()
+
+	if err := addNamespaceObject(apiObjMap, metadata, namespace, nodeNamespace); err != nil {
+		return nil, "", err
+	}
+
+	rendered := []interface{}{}
+
+	kinds := append(getBaseK8sKinds(), getMonitoringK8sKinds()...)
+	kinds = append(kinds, K8S_UNSTRUCTURED_TYPE)
+	for _, kind := range kinds {
+		for _, obj := range apiObjMap[kind] {
+			objs, err := renderObject(obj, namespace)
+			if err != nil {
+				return nil, "", err
+			}
+			rendered = append(rendered, objs...)
+		}
+	}
+
+	return rendered, namespace, nil
+}
+
+// convert an api object to the k8s objects that are created for it in the given namespace
+func renderObject(obj APIObjectInterface, namespace string) ([]interface{}, error) {
+	switch typed := obj.(type) {
+	case NamespaceCoreV1:
+		ns := typed.NamespaceObject.DeepCopy()
+		ns.TypeMeta = typeMeta(corev1.SchemeGroupVersion.String(), K8S_NAMESPACE_TYPE)
+		return []interface{}{ns}, nil
+	case RoleRbacV1:
+		role := typed.RoleObject.DeepCopy()
+		role.TypeMeta = typeMeta(rbacv1.SchemeGroupVersion.String(), K8S_ROLE_TYPE)
+		role.Namespace = namespace
+		return []interface{}{role}, nil
+	case RolebindingRbacV1:
+		rb := typed.RolebindingObject.DeepCopy()
+		rb.TypeMeta = typeMeta(rbacv1.SchemeGroupVersion.String(), K8S_ROLEBINDING_TYPE)
+		rb.Namespace = namespace
+		return []interface{}{rb}, nil
+	case ServiceAccountCoreV1:
+		sa := typed.ServiceAccountObject.DeepCopy()
+		sa.TypeMeta = typeMeta(corev1.SchemeGroupVersion.String(), K8S_SERVICEACCOUNT_TYPE)
+		sa.Namespace = namespace
+		return []interface{}{sa}, nil
+	case DeploymentAppsV1:
+		envAdds := cutil.RemoveESSEnvVars(typed.EnvVarMap, config.ENVVAR_PREFIX)
+		delete(envAdds, "")
+		configMap := corev1.ConfigMap{
+			TypeMeta:   typeMeta(corev1.SchemeGroupVersion.String(), "ConfigMap"),
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-%s", HZN_ENV_VARS, typed.AgreementId), Namespace: namespace},
+			Data:       envAdds,
+		}
+		d := typed.resolve(configMap.Name, "")
+		d.TypeMeta = typeMeta(appsv1.SchemeGroupVersion.String(), K8S_DEPLOYMENT_TYPE)
+		d.Namespace = namespace
+		return []interface{}{configMap, d}, nil
+	case HorizontalPodAutoscalerV2:
+		hpa := typed.resolve()
+		hpa.TypeMeta = typeMeta(autoscalingv2.SchemeGroupVersion.String(), K8S_HPA_TYPE)
+		hpa.Namespace = namespace
+		return []interface{}{hpa}, nil
+	case CustomResourceV1:
+		crd := typed.CustomResourceDefinitionObject.DeepCopy()
+		crd.TypeMeta = typeMeta(crdv1.SchemeGroupVersion.String(), K8S_CRD_TYPE)
+		objs := []interface{}{crd}
+		for _, cr := range typed.CustomResourceObjectList {
+			crCopy := cr.DeepCopy()
+			crCopy.SetNamespace(namespace)
+			objs = append(objs, crCopy.Object)
+		}
+		return objs, nil
+	case CustomResourceV1Beta1:
+		crd := typed.CustomResourceDefinitionObject.DeepCopy()
+		crd.TypeMeta = typeMeta(crdv1beta1.SchemeGroupVersion.String(), K8S_CRD_TYPE)
+		objs := []interface{}{crd}
+		for _, cr := range typed.CustomResourceObjectList {
+			crCopy := cr.DeepCopy()
+			crCopy.SetNamespace(namespace)
+			objs = append(objs, crCopy.Object)
+		}
+		return objs, nil
+	case OtherObject:
+		// the agent tries the namespace first, so show the object as namespaced
+		other := typed.Object.DeepCopy()
+		other.SetNamespace(namespace)
+		return []interface{}{other.Object}, nil
+	}
+	return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error: unable to render object %v of type %T", obj.Name(), obj)))
+}
+
+func typeMeta(apiVersion string, kind string) metav1.TypeMeta {
+	return metav1.TypeMeta{APIVersion: apiVersion, Kind: kind}
+}
@@ -0,0 +1,96 @@
+//go:build unit
+// +build unit
+
+package kube_operator
+
+import (
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"strings"
+	"testing"
+)
+
+const testServiceAccount = `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: operator
+`
+
+// Returns the kinds of the rendered objects, in order.
+func renderedKinds(objs []interface{}) []string {
+	kinds := []string{}
+	for _, obj := range objs {
+		switch typed := obj.(type) {
+		case *corev1.Namespace:
+			kinds = append(kinds, typed.Kind)
+		case corev1.ConfigMap:
+			kinds = append(kinds, typed.Kind)
+		case appsv1.Deployment:
+			kinds = append(kinds, typed.Kind)
+		case *corev1.ServiceAccount:
+			kinds = append(kinds, typed.Kind)
+		default:
+			kinds = append(kinds, fmt.Sprintf("%T", obj))
+		}
+	}
+	return kinds
+}
+
+func Test_RenderDeployment(t *testing.T) {
+	t.Setenv("AGENT_NAMESPACE", "agent-ns")
+	tar := operatorArchive(t, map[string]string{
+		"deployment.yaml":     fmt.Sprintf(testOperatorDeployment, ""),
+		"serviceaccount.yaml": testServiceAccount,
+	})
+	metadata := map[string]interface{}{NAMESPACE_METADATA_KEY: map[string]interface{}{"labels": map[string]interface{}{"team": "a"}}}
+	envVars := map[string]string{"MY_VAR": "1", "HZN_ESS_AUTH": "/ess-auth"}
+
+	objs, namespace, err := RenderDeployment(tar, metadata, envVars, "ag1", "operator-ns", ImagePolicy{RegistryMirror: "mirror.local"})
+	if err != nil {
+		t.Fatalf("should not return error, but got %v", err)
+	} else if namespace != "operator-ns" {
+		t.Errorf("expected namespace operator-ns, but got %v", namespace)
+	}
+
+	// the objects are in install order, with the namespace and the env var config map added
+	if kinds := renderedKinds(objs); fmt.Sprint(kinds) != fmt.Sprintf("[%v ConfigMap %v %v]", K8S_NAMESPACE_TYPE, K8S_DEPLOYMENT_TYPE, K8S_SERVICEACCOUNT_TYPE) {
+		t.Fatalf("wrong rendered objects %v", kinds)
+	}
+
+	if ns := objs[0].(*corev1.Namespace); ns.Name != "operator-ns" || ns.Labels["team"] != "a" {
+		t.Errorf("wrong namespace %v", ns)
+	}
+	if cm := objs[1].(corev1.ConfigMap); cm.Name != HZN_ENV_VARS+"-ag1" || cm.Namespace != "operator-ns" || cm.Data["MY_VAR"] != "1" {
+		t.Errorf("wrong env var config map %v", cm)
+	} else if _, ok := cm.Data["HZN_ESS_AUTH"]; ok {
+		t.Errorf("the ESS env vars should not be in the config map, got %v", cm.Data)
+	}
+	if d := objs[2].(appsv1.Deployment); d.Namespace != "operator-ns" || d.APIVersion != appsv1.SchemeGroupVersion.String() {
+		t.Errorf("wrong deployment %v", d)
+	} else if image := d.Spec.Template.Spec.Containers[0].Image; !strings.HasPrefix(image, "mirror.local/") {
+		t.Errorf("the image should be pulled from the mirror, got %v", image)
+	}
+	if sa := objs[3].(*corev1.ServiceAccount); sa.Namespace != "operator-ns" {
+		t.Errorf("wrong service account namespace %v", sa.Namespace)
+	}
+
+	// the agent namespace is used by default and is not rendered
+	if objs, namespace, err := RenderDeployment(tar, nil, map[string]string{}, "ag1", "", ImagePolicy{}); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if namespace != "agent-ns" {
+		t.Errorf("expected the agent namespace, but got %v", namespace)
+	} else if kinds := renderedKinds(objs); kinds[0] == K8S_NAMESPACE_TYPE {
+		t.Errorf("the agent namespace should not be rendered, got %v", kinds)
+	}
+
+	// an image outside of the allow list is rejected
+	if _, _, err := RenderDeployment(tar, nil, map[string]string{}, "ag1", "", ImagePolicy{AllowList: []string{"registry.io/org"}}); err == nil {
+		t.Errorf("an image outside of the allow list should have returned an error")
+	}
+
+	// an invalid archive is an error
+	if _, _, err := RenderDeployment("not an archive", nil, map[string]string{}, "ag1", "", ImagePolicy{}); err == nil {
+		t.Errorf("an invalid archive should have returned an error")
+	}
+}
@@ -0,0 +1,90 @@
+package kube_operator
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/semanticversion"
+	yaml "gopkg.in/yaml.v2"
+	"sort"
+	"strings"
+)
+
+// The key in the clusterDeployment metadata for the kubernetes versions the operator supports, a version range such as
+// "[1.24.0,1.30.0)". The operator can be installed on any version when it is omitted.
+const KUBE_VERSION_METADATA_KEY = "kubernetesVersion"
+
+// The cluster scoped kinds that can be in an operator. An agent that is namespace scoped only has a Role in its own
+// namespace, so it cannot create them.
+func getClusterScopedKinds() []string {
+	return []string{K8S_CRD_TYPE, "ClusterRole", "ClusterRoleBinding", "PersistentVolume", "StorageClass", "PriorityClass",
+		"ValidatingWebhookConfiguration", "MutatingWebhookConfiguration", "APIService"}
+}
+
+// The kinds of the operator lifecycle manager. They can only be used in a cluster where OLM is installed, and OLM
+// installs the cluster scoped objects of the operators it manages, so they need an agent that is cluster scoped too.
+func getOLMKinds() []string {
+	return []string{K8S_OLM_OPERATOR_GROUP_TYPE, "Subscription", "ClusterServiceVersion", "CatalogSource", "InstallPlan"}
+}
+
+func IsClusterScopedKind(kind string) bool {
+	return cutil.SliceContains(getClusterScopedKinds(), kind)
+}
+
+func IsOLMKind(kind string) bool {
+	return cutil.SliceContains(getOLMKinds(), kind)
+}
+
+// GetKubeVersionRange returns the kubernetes version range from the clusterDeployment metadata, nil if there is none
+func GetKubeVersionRange(metadata map[string]interface{}) (*semanticversion.Version_Expression, error) {
+	if metadata == nil {
+		return nil, nil
+	}
+	kv, ok := metadata[KUBE_VERSION_METADATA_KEY]
+	if !ok || kv == nil {
+		return nil, nil
+	}
+
+	if kvStr, ok := kv.(string); !ok {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error: the %v attribute in the metadata must be a string, it is %T", KUBE_VERSION_METADATA_KEY, kv)))
+	} else if vExp, err := semanticversion.Version_Expression_Factory(kvStr); err != nil {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error: invalid %v in the metadata: %v", KUBE_VERSION_METADATA_KEY, err)))
+	} else {
+		return vExp, nil
+	}
+}
+
+// NormalizeKubeVersion returns the semantic version of a kubernetes server version such as v1.27.3+k3s1, that is 1.27.3
+func NormalizeKubeVersion(version string) string {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if ix := strings.IndexAny(version, "+-"); ix != -1 {
+		version = version[:ix]
+	}
+	return version
+}
+
+// GetOperatorKinds returns the sorted kinds of the objects in the operator yaml archive, without installing or converting them.
+func GetOperatorKinds(tar string) ([]string, error) {
+	yamls, err := getYamlFromTarGz(tar)
+	if err != nil {
+		return nil, err
+	}
+
+	kinds := []string{}
+	for _, file := range yamls {
+		for _, doc := range strings.Split(file.Body, "---") {
+			if strings.TrimSpace(doc) == "" {
+				continue
+			}
+			var obj struct {
+				Kind string `yaml:"kind"`
+			}
+			if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+				return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error unmarshaling the yaml file %v in the operator: %v", file.Header.Name, err)))
+			} else if obj.Kind != "" && !cutil.SliceContains(kinds, obj.Kind) {
+				kinds = append(kinds, obj.Kind)
+			}
+		}
+	}
+	sort.Strings(kinds)
+	return kinds, nil
+}
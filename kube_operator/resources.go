@@ -0,0 +1,144 @@
+package kube_operator
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ResourceCapacity holds the cpu and memory quantities used when comparing the operator requests against the cluster capacity
+type ResourceCapacity struct {
+	CPU    resource.Quantity
+	Memory resource.Quantity
+}
+
+func (r ResourceCapacity) String() string {
+	return fmt.Sprintf("CPU: %v, Memory: %v", r.CPU.String(), r.Memory.String())
+}
+
+// CheckResourceCapacity compares the resource requests of the operator deployment in the given tar against the allocatable
+// capacity of the cluster. It returns false and a reason if the cluster does not have enough capacity for the operator.
+// If the agent is not allowed to read the node status, the check is skipped and true is returned.
+func (c KubeClient) CheckResourceCapacity(tar string, metadata map[string]interface{}, agId string) (bool, string, error) {
+	apiObjMap, _, err := ProcessDeployment(tar, metadata, map[string]string{}, agId, 0)
+	if err != nil {
+		return false, "", err
+	}
+
+	requests := ResourceCapacity{}
+	for _, obj := range apiObjMap[K8S_DEPLOYMENT_TYPE] {
+		if d, ok := obj.(DeploymentAppsV1); ok {
+			addDeploymentRequests(&requests, d.DeploymentObject)
+		}
+	}
+	if requests.CPU.IsZero() && requests.Memory.IsZero() {
+		glog.V(3).Infof(kwlog(fmt.Sprintf("operator deployment for agreement %v has no resource requests, skipping resource preflight check", agId)))
+		return true, "", nil
+	}
+
+	available, err := c.GetAvailableCapacity()
+	if err != nil {
+		if errors.IsForbidden(err) {
+			glog.Warningf(kwlog(fmt.Sprintf("agent is not allowed to read the cluster node status, skipping resource preflight check for agreement %v: %v", agId, err)))
+			return true, "", nil
+		}
+		return false, "", err
+	}
+
+	glog.V(3).Infof(kwlog(fmt.Sprintf("resource preflight check for agreement %v. Requested: %v. Available: %v", agId, requests, available)))
+
+	if requests.CPU.Cmp(available.CPU) > 0 {
+		return false, fmt.Sprintf("the operator requests %v cpu but the cluster only has %v cpu available", requests.CPU.String(), available.CPU.String()), nil
+	} else if requests.Memory.Cmp(available.Memory) > 0 {
+		return false, fmt.Sprintf("the operator requests %v memory but the cluster only has %v memory available", requests.Memory.String(), available.Memory.String()), nil
+	}
+	return true, "", nil
+}
+
+// GetAvailableCapacity returns the allocatable cpu and memory summed from the status of all the schedulable nodes in the cluster,
+// minus the requests of the pods that are currently running. If the pods cannot be listed, only the allocatable capacity is returned.
+func (c KubeClient) GetAvailableCapacity() (*ResourceCapacity, error) {
+	_, available, requested, err := c.GetClusterResources()
+	if err != nil {
+		return nil, err
+	}
+
+	available.CPU.Sub(requested.CPU)
+	available.Memory.Sub(requested.Memory)
+	return available, nil
+}
+
+// GetClusterResources returns the cpu and memory capacity and allocatable capacity summed from the status of all the
+// schedulable nodes in the cluster, and the resources requested by the pods that are currently running. If the pods
+// cannot be listed, the requested resources are zero.
+func (c KubeClient) GetClusterResources() (*ResourceCapacity, *ResourceCapacity, *ResourceCapacity, error) {
+	nodeList, err := c.Client.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	capacity := ResourceCapacity{}
+	allocatable := ResourceCapacity{}
+	for _, node := range nodeList.Items {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		if cpu, ok := node.Status.Capacity[corev1.ResourceCPU]; ok {
+			capacity.CPU.Add(cpu)
+		}
+		if mem, ok := node.Status.Capacity[corev1.ResourceMemory]; ok {
+			capacity.Memory.Add(mem)
+		}
+		if cpu, ok := node.Status.Allocatable[corev1.ResourceCPU]; ok {
+			allocatable.CPU.Add(cpu)
+		}
+		if mem, ok := node.Status.Allocatable[corev1.ResourceMemory]; ok {
+			allocatable.Memory.Add(mem)
+		}
+	}
+
+	requested := ResourceCapacity{}
+	podList, err := c.Client.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{FieldSelector: "status.phase!=Succeeded,status.phase!=Failed"})
+	if err != nil {
+		glog.Warningf(kwlog(fmt.Sprintf("unable to list the pods in the cluster, using the allocatable node capacity only: %v", err)))
+		return &capacity, &allocatable, &requested, nil
+	}
+	for _, pod := range podList.Items {
+		for _, container := range pod.Spec.Containers {
+			if cpu, ok := container.Resources.Requests[corev1.ResourceCPU]; ok {
+				requested.CPU.Add(cpu)
+			}
+			if mem, ok := container.Resources.Requests[corev1.ResourceMemory]; ok {
+				requested.Memory.Add(mem)
+			}
+		}
+	}
+
+	return &capacity, &allocatable, &requested, nil
+}
+
+// add the resources requested by all the replicas of the deployment to the given total
+func addDeploymentRequests(total *ResourceCapacity, deployment *appsv1.Deployment) {
+	if deployment == nil {
+		return
+	}
+
+	replicas := int64(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = int64(*deployment.Spec.Replicas)
+	}
+
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if cpu, ok := container.Resources.Requests[corev1.ResourceCPU]; ok {
+			total.CPU.Add(*resource.NewMilliQuantity(cpu.MilliValue()*replicas, resource.DecimalSI))
+		}
+		if mem, ok := container.Resources.Requests[corev1.ResourceMemory]; ok {
+			total.Memory.Add(*resource.NewQuantity(mem.Value()*replicas, resource.BinarySI))
+		}
+	}
+}
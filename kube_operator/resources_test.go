@@ -0,0 +1,140 @@
+//go:build unit
+// +build unit
+
+package kube_operator
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Returns the base64 encoded operator yaml archive holding the given files.
+func operatorArchive(t *testing.T, files map[string]string) string {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, body := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(body))}); err != nil {
+			t.Fatalf("unable to write the archive, error: %v", err)
+		} else if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatalf("unable to write the archive, error: %v", err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+const testOperatorDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: operator
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: operator
+  template:
+    metadata:
+      labels:
+        app: operator
+    spec:
+      containers:
+      - name: operator
+        image: operator:1.0
+%v`
+
+const testOperatorRequests = `        resources:
+          requests:
+            cpu: 500m
+            memory: 256Mi
+`
+
+// A kube client whose API server has the given nodes and pods, in the form "cpu/memory". The pods are running, the
+// nodes starting with "!" are unschedulable. A status other than OK is returned for the node list instead of the nodes.
+func capacityKubeClient(t *testing.T, nodes []string, pods []string, nodeStatus int, calls *int) KubeClient {
+	resources := func(s string) string {
+		pieces := strings.Split(s, "/")
+		return fmt.Sprintf(`{"cpu":"%v","memory":"%v"}`, pieces[0], pieces[1])
+	}
+	return newTestKubeClient(t, func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Header().Set("Content-Type", "application/json")
+		items := []string{}
+		switch r.URL.Path {
+		case "/api/v1/nodes":
+			if nodeStatus != http.StatusOK {
+				w.WriteHeader(nodeStatus)
+				fmt.Fprintf(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","code":%v}`, nodeStatus)
+				return
+			}
+			for _, node := range nodes {
+				unschedulable := strings.HasPrefix(node, "!")
+				res := resources(strings.TrimPrefix(node, "!"))
+				items = append(items, fmt.Sprintf(`{"spec":{"unschedulable":%v},"status":{"capacity":%v,"allocatable":%v}}`, unschedulable, res, res))
+			}
+			fmt.Fprintf(w, `{"kind":"NodeList","apiVersion":"v1","items":[%v]}`, strings.Join(items, ","))
+		case "/api/v1/pods":
+			for _, pod := range pods {
+				items = append(items, fmt.Sprintf(`{"spec":{"containers":[{"name":"c","resources":{"requests":%v}}]}}`, resources(pod)))
+			}
+			fmt.Fprintf(w, `{"kind":"PodList","apiVersion":"v1","items":[%v]}`, strings.Join(items, ","))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func Test_CheckResourceCapacity(t *testing.T) {
+	withRequests := operatorArchive(t, map[string]string{"deployment.yaml": fmt.Sprintf(testOperatorDeployment, testOperatorRequests)})
+
+	// the operator requests 1 cpu and 512Mi of memory for its 2 replicas
+	tests := []struct {
+		name       string
+		nodes      []string
+		pods       []string
+		nodeStatus int
+		fits       bool
+		reason     string
+		hasErr     bool
+	}{
+		{"fits", []string{"2/4Gi"}, []string{"500m/1Gi"}, http.StatusOK, true, "", false},
+		{"fits exactly", []string{"1500m/1Gi"}, []string{"500m/512Mi"}, http.StatusOK, true, "", false},
+		{"not enough cpu", []string{"2/4Gi"}, []string{"1500m/1Gi"}, http.StatusOK, false, "cpu", false},
+		{"not enough memory", []string{"2/1Gi"}, []string{"500m/768Mi"}, http.StatusOK, false, "memory", false},
+		{"summed over the nodes", []string{"500m/256Mi", "500m/256Mi"}, nil, http.StatusOK, true, "", false},
+		{"unschedulable node", []string{"500m/256Mi", "!4/8Gi"}, nil, http.StatusOK, false, "cpu", false},
+		{"node status forbidden", nil, nil, http.StatusForbidden, true, "", false},
+		{"node status error", nil, nil, http.StatusInternalServerError, false, "", true},
+	}
+
+	for _, test := range tests {
+		calls := 0
+		c := capacityKubeClient(t, test.nodes, test.pods, test.nodeStatus, &calls)
+		fits, reason, err := c.CheckResourceCapacity(withRequests, nil, "ag1")
+		if (err != nil) != test.hasErr {
+			t.Errorf("%v: expected error %v, but got %v", test.name, test.hasErr, err)
+		} else if fits != test.fits || !strings.Contains(reason, test.reason) {
+			t.Errorf("%v: expected fits %v with reason %v, but got %v with reason %v", test.name, test.fits, test.reason, fits, reason)
+		}
+	}
+
+	// the cluster is not asked when the operator has no requests
+	calls := 0
+	c := capacityKubeClient(t, []string{"100m/64Mi"}, nil, http.StatusOK, &calls)
+	noRequests := operatorArchive(t, map[string]string{"deployment.yaml": fmt.Sprintf(testOperatorDeployment, "")})
+	if fits, _, err := c.CheckResourceCapacity(noRequests, nil, "ag1"); err != nil || !fits || calls != 0 {
+		t.Errorf("expected the operator to fit without calls, got %v, error %v after %v calls", fits, err, calls)
+	}
+
+	// an invalid archive is an error
+	if _, _, err := c.CheckResourceCapacity("not an archive", nil, "ag1"); err == nil {
+		t.Errorf("an invalid archive should have returned an error")
+	}
+}
@@ -0,0 +1,238 @@
+package kube_operator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/persistence"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// The name of the attribute in the clusterDeployment metadata that holds the replica policy
+	REPLICA_POLICY_KEY = "replicaPolicy"
+
+	// The annotation of a paused operator deployment that holds the number of replicas it had before it was paused
+	PAUSED_REPLICAS_ANNOTATION = "openhorizon.org/paused-replicas"
+)
+
+// ReplicaPolicy controls how the agent scales the operator deployment. If the package contains a HorizontalPodAutoscaler,
+// the min and max replicas are applied to the autoscaler and the scaling is left to kubernetes. Otherwise the agent scales
+// the operator deployment itself based on the metric reported in the status of the operator's custom resource.
+type ReplicaPolicy struct {
+	MinReplicas int32   `json:"minReplicas,omitempty"`
+	MaxReplicas int32   `json:"maxReplicas,omitempty"`
+	Metric      string  `json:"metric,omitempty"`      // dot separated path of the total load metric of all the replicas within the custom resource status, e.g. "metrics.load"
+	TargetValue float64 `json:"targetValue,omitempty"` // the value of the metric that a single replica is expected to handle
+}
+
+func (r ReplicaPolicy) String() string {
+	return fmt.Sprintf("MinReplicas: %v, MaxReplicas: %v, Metric: %v, TargetValue: %v", r.MinReplicas, r.MaxReplicas, r.Metric, r.TargetValue)
+}
+
+// Validate checks that the replica policy is consistent
+func (r ReplicaPolicy) Validate() error {
+	if r.MinReplicas < 0 || r.MaxReplicas < 0 {
+		return fmt.Errorf("minReplicas and maxReplicas cannot be negative")
+	} else if r.MaxReplicas > 0 && r.MinReplicas > r.MaxReplicas {
+		return fmt.Errorf("minReplicas %v cannot be greater than maxReplicas %v", r.MinReplicas, r.MaxReplicas)
+	} else if r.Metric != "" && r.TargetValue <= 0 {
+		return fmt.Errorf("targetValue must be greater than 0 when a metric is specified")
+	}
+	return nil
+}
+
+// Clamp returns the given replica count bounded by the min and max replicas of the policy
+func (r ReplicaPolicy) Clamp(replicas int32) int32 {
+	if r.MinReplicas > 0 && replicas < r.MinReplicas {
+		replicas = r.MinReplicas
+	}
+	if r.MaxReplicas > 0 && replicas > r.MaxReplicas {
+		replicas = r.MaxReplicas
+	}
+	return replicas
+}
+
+// DesiredReplicas calculates the number of replicas needed to handle the total load reported by the metric, each replica
+// handling up to the target value. There is at least one replica.
+func (r ReplicaPolicy) DesiredReplicas(metricValue float64) int32 {
+	desired := int32(math.Ceil(metricValue / r.TargetValue))
+	if desired < 1 {
+		desired = 1
+	}
+	return r.Clamp(desired)
+}
+
+// GetReplicaPolicy returns the replica policy from the clusterDeployment metadata, nil if there is none
+func GetReplicaPolicy(metadata map[string]interface{}) (*ReplicaPolicy, error) {
+	if metadata == nil {
+		return nil, nil
+	}
+	rp, ok := metadata[REPLICA_POLICY_KEY]
+	if !ok || rp == nil {
+		return nil, nil
+	}
+
+	policy := new(ReplicaPolicy)
+	if jBytes, err := json.Marshal(rp); err != nil {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error marshaling the replica policy %v: %v", rp, err)))
+	} else if err := json.Unmarshal(jBytes, policy); err != nil {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error: the %v attribute in the metadata has wrong format: %v", REPLICA_POLICY_KEY, err)))
+	} else if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error: invalid %v in the metadata: %v", REPLICA_POLICY_KEY, err)))
+	}
+	return policy, nil
+}
+
+// Scale adjusts the number of replicas of the operator deployment according to the replica policy in the metadata.
+// Nothing is done if there is no replica policy, if the policy has no metric, or if the package contains a HorizontalPodAutoscaler.
+func (c KubeClient) Scale(tar string, metadata map[string]interface{}, agId string, reqNamespace string) error {
+	apiObjMap, opNamespace, err := ProcessDeployment(tar, metadata, map[string]string{}, agId, 0)
+	if err != nil {
+		return err
+	}
+	namespace := getFinalNamespace(reqNamespace, opNamespace)
+
+	policy, err := GetReplicaPolicy(metadata)
+	if err != nil {
+		return err
+	} else if policy == nil || policy.Metric == "" || len(apiObjMap[K8S_HPA_TYPE]) > 0 {
+		return nil
+	} else if len(apiObjMap[K8S_DEPLOYMENT_TYPE]) < 1 {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error: failed to find operator deployment object.")))
+	}
+
+	metricValue, found := c.getCRMetric(apiObjMap, namespace, policy.Metric)
+	if !found {
+		glog.V(3).Infof(kwlog(fmt.Sprintf("metric %v not yet reported by the custom resource for agreement %v", policy.Metric, agId)))
+		return nil
+	}
+
+	deploymentName := apiObjMap[K8S_DEPLOYMENT_TYPE][0].Name()
+	deployments := c.Client.AppsV1().Deployments(namespace)
+	scale, err := deployments.GetScale(context.Background(), deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error getting the scale of deployment %v: %v", deploymentName, err)))
+	}
+
+	desired := policy.DesiredReplicas(metricValue)
+	if desired == scale.Spec.Replicas {
+		return nil
+	}
+
+	glog.V(3).Infof(kwlog(fmt.Sprintf("scaling deployment %v for agreement %v from %v to %v replicas, metric %v is %v", deploymentName, agId, scale.Spec.Replicas, desired, policy.Metric, metricValue)))
+	oldReplicas := scale.Spec.Replicas
+	scale.Spec.Replicas = desired
+	if _, err := deployments.UpdateScale(context.Background(), deploymentName, scale, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error scaling deployment %v to %v replicas: %v", deploymentName, desired, err)))
+	}
+	c.logObjectEvent(persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_KUBE_OPERATOR_SCALED, deploymentName, namespace, oldReplicas, desired), persistence.EC_K8S_OPERATOR_SCALED)
+	return nil
+}
+
+// SetPaused scales the operator deployment to zero replicas when its service is paused by the node owner, or back to the
+// number of replicas it had when the service is resumed. The previous number of replicas is kept in an annotation of the
+// deployment, so that the deployment is scaled back correctly after the agent restarts.
+func (c KubeClient) SetPaused(tar string, metadata map[string]interface{}, agId string, reqNamespace string, pause bool) error {
+	apiObjMap, opNamespace, err := ProcessDeployment(tar, metadata, map[string]string{}, agId, 0)
+	if err != nil {
+		return err
+	} else if len(apiObjMap[K8S_DEPLOYMENT_TYPE]) < 1 {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error: failed to find operator deployment object.")))
+	}
+	namespace := getFinalNamespace(reqNamespace, opNamespace)
+
+	deploymentName := apiObjMap[K8S_DEPLOYMENT_TYPE][0].Name()
+	deployments := c.Client.AppsV1().Deployments(namespace)
+	deployment, err := deployments.Get(context.Background(), deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error getting deployment %v: %v", deploymentName, err)))
+	}
+
+	pausedReplicas, paused := deployment.Annotations[PAUSED_REPLICAS_ANNOTATION]
+	if pause == paused {
+		return nil
+	}
+
+	var replicas int32 = 1
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+
+	if pause {
+		if deployment.Annotations == nil {
+			deployment.Annotations = map[string]string{}
+		}
+		deployment.Annotations[PAUSED_REPLICAS_ANNOTATION] = strconv.Itoa(int(replicas))
+		var zero int32 = 0
+		deployment.Spec.Replicas = &zero
+	} else {
+		restored := int32(1)
+		if r, err := strconv.Atoi(pausedReplicas); err == nil && r > 0 {
+			restored = int32(r)
+		}
+		delete(deployment.Annotations, PAUSED_REPLICAS_ANNOTATION)
+		deployment.Spec.Replicas = &restored
+	}
+
+	glog.V(3).Infof(kwlog(fmt.Sprintf("changing deployment %v for agreement %v from %v to %v replicas, paused: %v", deploymentName, agId, replicas, *deployment.Spec.Replicas, pause)))
+	if _, err := deployments.Update(context.Background(), deployment, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error scaling deployment %v to %v replicas: %v", deploymentName, *deployment.Spec.Replicas, err)))
+	}
+
+	if pause {
+		c.logObjectEvent(persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_KUBE_OPERATOR_PAUSED, deploymentName, namespace, replicas), persistence.EC_K8S_OPERATOR_PAUSED)
+	} else {
+		c.logObjectEvent(persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_KUBE_OPERATOR_RESUMED, deploymentName, namespace, *deployment.Spec.Replicas), persistence.EC_K8S_OPERATOR_RESUMED)
+	}
+	return nil
+}
+
+// get the value of the metric from the status of the operator's custom resources
+func (c KubeClient) getCRMetric(apiObjMap map[string][]APIObjectInterface, namespace string, metric string) (float64, bool) {
+	for _, crd := range apiObjMap[K8S_CRD_TYPE] {
+		statuses, err := crd.Status(c, namespace)
+		if err != nil {
+			glog.Warningf(kwlog(fmt.Sprintf("unable to get the status of custom resource %v: %v", crd.Name(), err)))
+			continue
+		}
+		if statusList, ok := statuses.([]interface{}); ok {
+			for _, status := range statusList {
+				if value, found := getMetricValue(status, metric); found {
+					return value, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// find the numeric value at the dot separated path in the given status object
+func getMetricValue(status interface{}, path string) (float64, bool) {
+	current := status
+	for _, key := range strings.Split(path, ".") {
+		if m, ok := current.(map[string]interface{}); !ok {
+			return 0, false
+		} else if current, ok = m[key]; !ok {
+			return 0, false
+		}
+	}
+
+	switch v := current.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,49 @@
+//go:build unit
+// +build unit
+
+package kube_operator
+
+import (
+	"testing"
+)
+
+func Test_DesiredReplicas(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		policy   ReplicaPolicy
+		metric   float64
+		expected int32
+	}{
+		{"exact", ReplicaPolicy{Metric: "load", TargetValue: 10}, 30, 3},
+		{"rounded up", ReplicaPolicy{Metric: "load", TargetValue: 10}, 31, 4},
+		{"below one replica", ReplicaPolicy{Metric: "load", TargetValue: 10}, 4, 1},
+		{"no load", ReplicaPolicy{Metric: "load", TargetValue: 10}, 0, 1},
+		{"min replicas", ReplicaPolicy{MinReplicas: 2, Metric: "load", TargetValue: 10}, 5, 2},
+		{"max replicas", ReplicaPolicy{MaxReplicas: 5, Metric: "load", TargetValue: 10}, 100, 5},
+		{"fractional target", ReplicaPolicy{Metric: "load", TargetValue: 0.5}, 1.2, 3},
+	}
+
+	for _, test := range tests {
+		if desired := test.policy.DesiredReplicas(test.metric); desired != test.expected {
+			t.Errorf("%v: expected %v replicas, but got %v", test.name, test.expected, desired)
+		}
+	}
+}
+
+func Test_getMetricValue(t *testing.T) {
+
+	status := map[string]interface{}{"metrics": map[string]interface{}{"load": 12.5, "queue": "7", "name": "x"}}
+
+	if v, found := getMetricValue(status, "metrics.load"); !found || v != 12.5 {
+		t.Errorf("expected 12.5, but got %v %v", v, found)
+	} else if v, found := getMetricValue(status, "metrics.queue"); !found || v != 7 {
+		t.Errorf("a numeric string should be read, got %v %v", v, found)
+	} else if _, found := getMetricValue(status, "metrics.name"); found {
+		t.Errorf("a non numeric metric should not be found")
+	} else if _, found := getMetricValue(status, "metrics.load.value"); found {
+		t.Errorf("a path below a number should not be found")
+	} else if _, found := getMetricValue(status, "missing"); found {
+		t.Errorf("a missing metric should not be found")
+	}
+}
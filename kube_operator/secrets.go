@@ -0,0 +1,88 @@
+package kube_operator
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+)
+
+const (
+	// Name prefix for the secret holding the service secrets. Only characters allowed: [a-z] "." and "-"
+	HZN_SERVICE_SECRETS = "hzn-service-secrets"
+	// Name of the volume used to mount the service secrets into the operator containers
+	HZN_SERVICE_SECRETS_VOLUME = "hzn-service-secrets"
+)
+
+func serviceSecretName(agId string) string {
+	return fmt.Sprintf("%s-%s", HZN_SERVICE_SECRETS, agId)
+}
+
+// CreateServiceSecret creates a secret with the given service secrets. The keys are the secret names from the
+// secret binding and the values are the secret contents.
+func (c KubeClient) CreateServiceSecret(secrets map[string][]byte, agId string, namespace string) (string, error) {
+	secret := corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: serviceSecretName(agId)}, Type: corev1.SecretTypeOpaque, Data: secrets}
+	res, err := c.Client.CoreV1().Secrets(namespace).Create(context.Background(), &secret, metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+	return res.ObjectMeta.Name, nil
+}
+
+func (c KubeClient) deleteServiceSecret(agId string, namespace string) {
+	secretName := serviceSecretName(agId)
+	err := c.Client.CoreV1().Secrets(namespace).Delete(context.Background(), secretName, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		glog.Errorf(kwlog(fmt.Sprintf("unable to delete secret %s. Error: %v", secretName, err)))
+	} else if err == nil {
+		glog.V(3).Infof(kwlog(fmt.Sprintf("deleted secret %v", secretName)))
+	}
+}
+
+// UpdateServiceSecrets replaces the contents of the service secret of the agreement when the secrets have been
+// rotated. The kubelet refreshes the mounted files in the running operator pods, so the pods are not restarted.
+func (c KubeClient) UpdateServiceSecrets(tar string, metadata map[string]interface{}, agId string, reqNamespace string, secrets map[string][]byte) error {
+	_, opNamespace, err := ProcessDeployment(tar, metadata, map[string]string{}, agId, 0)
+	if err != nil {
+		return err
+	}
+	namespace := getFinalNamespace(reqNamespace, opNamespace)
+
+	secretName := serviceSecretName(agId)
+	secret, err := c.Client.CoreV1().Secrets(namespace).Get(context.Background(), secretName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// the secret is only created when the operator is installed with secrets bound to it
+			return nil
+		}
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error getting secret %v: %v", secretName, err)))
+	} else if reflect.DeepEqual(secret.Data, secrets) {
+		return nil
+	}
+
+	secret.Data = secrets
+	if _, err := c.Client.CoreV1().Secrets(namespace).Update(context.Background(), secret, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error updating secret %v: %v", secretName, err)))
+	}
+	glog.V(3).Infof(kwlog(fmt.Sprintf("updated service secrets for agreement %v", agId)))
+	c.logObjectEvent(persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_KUBE_SERVICE_SECRETS_UPDATED, secretName, namespace), persistence.EC_K8S_SERVICE_SECRETS_UPDATED)
+	return nil
+}
+
+// mount the service secret into all the containers of the deployment at the same path used for docker services
+func addServiceSecretToDeploymentObject(deployment appsv1.Deployment, secretName string) appsv1.Deployment {
+	volume := corev1.Volume{Name: HZN_SERVICE_SECRETS_VOLUME, VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: secretName}}}
+	deployment.Spec.Template.Spec.Volumes = append(deployment.Spec.Template.Spec.Volumes, volume)
+
+	volumeMount := corev1.VolumeMount{Name: HZN_SERVICE_SECRETS_VOLUME, MountPath: config.HZN_SECRETS_MOUNT, ReadOnly: true}
+	for i := range deployment.Spec.Template.Spec.Containers {
+		deployment.Spec.Template.Spec.Containers[i].VolumeMounts = append(deployment.Spec.Template.Spec.Containers[i].VolumeMounts, volumeMount)
+	}
+	return deployment
+}
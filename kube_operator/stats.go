@@ -0,0 +1,62 @@
+package kube_operator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/open-horizon/anax/persistence"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// The pod metrics of the kubernetes metrics API, served by the metrics-server of the cluster.
+type podMetricsList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Containers []struct {
+			Name  string            `json:"name"`
+			Usage map[string]string `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
+}
+
+// ContainerStats returns the CPU and memory usage of the containers of the pods in the namespace of the operator, from
+// the kubernetes metrics API. The metrics API does not report the network and block IO of the containers. The cluster
+// must run the metrics-server.
+func (c KubeClient) ContainerStats(tar string, metadata map[string]interface{}, agId string, reqNamespace string) ([]persistence.ContainerStats, error) {
+	_, opNamespace, err := ProcessDeployment(tar, metadata, map[string]string{}, agId, 0)
+	if err != nil {
+		return nil, err
+	}
+	namespace := getFinalNamespace(reqNamespace, opNamespace)
+
+	raw, err := c.Client.RESTClient().Get().AbsPath("/apis/metrics.k8s.io/v1beta1/namespaces", namespace, "pods").DoRaw(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error getting the pod metrics in namespace %v: %v", namespace, err)))
+	}
+	return convertPodMetrics(raw)
+}
+
+// Converts the pod metrics to the stats of their containers, named <pod name>/<container name>.
+func convertPodMetrics(raw []byte) ([]persistence.ContainerStats, error) {
+	metrics := podMetricsList{}
+	if err := json.Unmarshal(raw, &metrics); err != nil {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error unmarshaling the pod metrics: %v", err)))
+	}
+
+	stats := make([]persistence.ContainerStats, 0)
+	for _, pod := range metrics.Items {
+		for _, container := range pod.Containers {
+			cs := persistence.ContainerStats{Name: pod.Metadata.Name + "/" + container.Name}
+			if cpu, err := resource.ParseQuantity(container.Usage["cpu"]); err == nil {
+				cs.CPUPercent = cpu.AsApproximateFloat64() * 100
+			}
+			if memory, err := resource.ParseQuantity(container.Usage["memory"]); err == nil {
+				cs.MemoryUsage = uint64(memory.Value())
+			}
+			stats = append(stats, cs)
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,180 @@
+package kube_operator
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	crdv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+const (
+	// How often the agent checks the state of an object it is waiting for
+	WAIT_POLL_INTERVAL_S = 5
+)
+
+// InstallTimeouts holds the number of seconds to wait for each kind of object to become ready during the install.
+// A zero timeout means the agent does not wait for that kind of object.
+type InstallTimeouts struct {
+	CustomResource    int64
+	DeploymentRollout int64
+	CRDEstablished    int64
+	NamespaceActive   int64
+}
+
+func (t InstallTimeouts) String() string {
+	return fmt.Sprintf("CustomResource: %v, DeploymentRollout: %v, CRDEstablished: %v, NamespaceActive: %v", t.CustomResource, t.DeploymentRollout, t.CRDEstablished, t.NamespaceActive)
+}
+
+func NewInstallTimeouts(cfg *config.HorizonConfig) InstallTimeouts {
+	return InstallTimeouts{
+		CustomResource:    cfg.GetK8sCRInstallTimeouts(),
+		DeploymentRollout: cfg.GetK8sDeploymentRolloutTimeoutS(),
+		CRDEstablished:    cfg.GetK8sCRDEstablishedTimeoutS(),
+		NamespaceActive:   cfg.GetK8sNamespaceActiveTimeoutS(),
+	}
+}
+
+// waitForReady waits for the installed object to become ready, if there is a timeout for its kind
+func (c KubeClient) waitForReady(kind string, obj APIObjectInterface, namespace string, timeouts InstallTimeouts) error {
+	switch kind {
+	case K8S_NAMESPACE_TYPE:
+		return c.waitForNamespaceActive(obj.Name(), timeouts.NamespaceActive)
+	case K8S_DEPLOYMENT_TYPE:
+		return c.waitForDeploymentRollout(obj.Name(), namespace, timeouts.DeploymentRollout)
+	}
+	return nil
+}
+
+// poll the condition function until it returns true or the timeout is exceeded
+func waitFor(timeoutS int64, condition func() (bool, error)) (bool, error) {
+	for {
+		if done, err := condition(); err != nil {
+			return false, err
+		} else if done {
+			return true, nil
+		} else if timeoutS <= 0 {
+			return false, nil
+		}
+		time.Sleep(WAIT_POLL_INTERVAL_S * time.Second)
+		timeoutS = timeoutS - WAIT_POLL_INTERVAL_S
+	}
+}
+
+func (c KubeClient) waitForNamespaceActive(name string, timeoutS int64) error {
+	if timeoutS <= 0 {
+		return nil
+	}
+	glog.V(3).Infof(kwlog(fmt.Sprintf("waiting up to %vs for namespace %v to become active", timeoutS, name)))
+
+	var phase corev1.NamespacePhase
+	if active, err := waitFor(timeoutS, func() (bool, error) {
+		ns, err := c.Client.CoreV1().Namespaces().Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		phase = ns.Status.Phase
+		return phase == corev1.NamespaceActive, nil
+	}); err != nil {
+		return err
+	} else if !active {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error: namespace %v did not become active within %v seconds. Namespace phase is %v", name, timeoutS, phase)))
+	}
+	return nil
+}
+
+func (c KubeClient) waitForDeploymentRollout(name string, namespace string, timeoutS int64) error {
+	if timeoutS <= 0 {
+		return nil
+	}
+	glog.V(3).Infof(kwlog(fmt.Sprintf("waiting up to %vs for deployment %v to roll out", timeoutS, name)))
+
+	var status appsv1.DeploymentStatus
+	if rolledOut, err := waitFor(timeoutS, func() (bool, error) {
+		d, err := c.Client.AppsV1().Deployments(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		status = d.Status
+		for _, cond := range d.Status.Conditions {
+			if cond.Type == appsv1.DeploymentProgressing && cond.Reason == "ProgressDeadlineExceeded" {
+				return false, fmt.Errorf(kwlog(fmt.Sprintf("Error: deployment %v exceeded its progress deadline: %v", name, cond.Message)))
+			}
+		}
+		replicas := int32(1)
+		if d.Spec.Replicas != nil {
+			replicas = *d.Spec.Replicas
+		}
+		return d.Status.ObservedGeneration >= d.Generation && d.Status.UpdatedReplicas == replicas && d.Status.AvailableReplicas == replicas, nil
+	}); err != nil {
+		return err
+	} else if !rolledOut {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error: deployment %v did not roll out within %v seconds. Updated replicas: %v, available replicas: %v", name, timeoutS, status.UpdatedReplicas, status.AvailableReplicas)))
+	}
+	return nil
+}
+
+// wait for the v1 custom resource definition to have the Established condition
+func waitForCRDV1Established(name string, timeoutS int64) error {
+	if timeoutS <= 0 {
+		return nil
+	}
+	apiClient, err := NewCRDV1Client()
+	if err != nil {
+		return err
+	}
+	glog.V(3).Infof(kwlog(fmt.Sprintf("waiting up to %vs for custom resource definition %v to be established", timeoutS, name)))
+
+	if established, err := waitFor(timeoutS, func() (bool, error) {
+		crd, err := apiClient.CustomResourceDefinitions().Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		for _, cond := range crd.Status.Conditions {
+			if cond.Type == crdv1.Established && cond.Status == crdv1.ConditionTrue {
+				return true, nil
+			}
+		}
+		return false, nil
+	}); err != nil {
+		return err
+	} else if !established {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error: custom resource definition %v was not established within %v seconds", name, timeoutS)))
+	}
+	return nil
+}
+
+// wait for the v1beta1 custom resource definition to have the Established condition
+func waitForCRDV1Beta1Established(name string, timeoutS int64) error {
+	if timeoutS <= 0 {
+		return nil
+	}
+	apiClient, err := NewCRDV1beta1Client()
+	if err != nil {
+		return err
+	}
+	glog.V(3).Infof(kwlog(fmt.Sprintf("waiting up to %vs for custom resource definition %v to be established", timeoutS, name)))
+
+	if established, err := waitFor(timeoutS, func() (bool, error) {
+		crd, err := apiClient.CustomResourceDefinitions().Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		for _, cond := range crd.Status.Conditions {
+			if cond.Type == crdv1beta1.Established && cond.Status == crdv1beta1.ConditionTrue {
+				return true, nil
+			}
+		}
+		return false, nil
+	}); err != nil {
+		return err
+	} else if !established {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error: custom resource definition %v was not established within %v seconds", name, timeoutS)))
+	}
+	return nil
+}
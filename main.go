@@ -19,8 +19,10 @@ import (
 	"github.com/open-horizon/anax/container"
 	"github.com/open-horizon/anax/download"
 	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/externalpolicy"
 	_ "github.com/open-horizon/anax/externalpolicy/text_language"
 	"github.com/open-horizon/anax/governance"
+	"github.com/open-horizon/anax/hostprocess"
 	"github.com/open-horizon/anax/i18n"
 	_ "github.com/open-horizon/anax/i18n_messages"
 	"github.com/open-horizon/anax/imagefetch"
@@ -28,7 +30,9 @@ import (
 	"github.com/open-horizon/anax/nodemanagement"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
+	"github.com/open-horizon/anax/propertyprovider"
 	"github.com/open-horizon/anax/resource"
+	"github.com/open-horizon/anax/wasm"
 	"github.com/open-horizon/anax/worker"
 	"os"
 	"os/signal"
@@ -64,6 +68,9 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	if err := cfg.Edge.SetLogVerbosity(); err != nil {
+		panic(err)
+	}
 	glog.V(2).Infof("Using config: %v", cfg.String())
 	glog.V(2).Infof("GOMAXPROCS: %v", runtime.GOMAXPROCS(-1))
 
@@ -142,6 +149,31 @@ func main() {
 		panic(err)
 	}
 
+	// The calls to the exchange may fail over to the other URLs of the exchange.
+	exchange.InitExchangeFailover(cfg)
+
+	// The services of the node may only require the services of the orgs in the cross org allow list.
+	exchange.InitCrossOrgServices(cfg)
+
+	// The geo built-in properties of the node are read from the configured geo location file.
+	externalpolicy.SetGeoLocationFile(cfg.Edge.GeoLocationFile)
+
+	// The external programs in the config contribute their properties to the node policy.
+	if db != nil {
+		if err := propertyprovider.InitNodePropertyProviders(cfg); err != nil {
+			glog.Errorf("Unable to initialize the node property providers, terminating.")
+			panic(err)
+		}
+	}
+
+	// The node may authenticate to the exchange with the access tokens of an identity provider instead of its token.
+	if db != nil {
+		if err := exchange.InitNodeAuthProvider(cfg, db); err != nil {
+			glog.Errorf("Unable to initialize the exchange auth provider of the node, terminating.")
+			panic(err)
+		}
+	}
+
 	// Get the device side policy manager started early so that all the workers can use it.
 	// Make sure the policy directory is in place.
 	var pm *policy.PolicyManager
@@ -166,7 +198,7 @@ func main() {
 	// start workers
 	workers := worker.NewMessageHandlerRegistry()
 
-	workers.Add(agreementbot.NewAgreementBotWorker("AgBot", cfg, agbotDB, agbotSecrets))
+	workers.Add(agreementbot.NewAgreementBotWorker(agreementbot.AGBOT_WORKER, cfg, agbotDB, agbotSecrets))
 	if cfg.AgreementBot.APIListen != "" {
 		workers.Add(agreementbot.NewAPIListener("AgBot API", cfg, agbotDB, *configFile, agbotSecrets))
 	}
@@ -178,7 +210,7 @@ func main() {
 	}
 
 	if db != nil {
-		workers.Add(api.NewAPIListener("API", cfg, db, pm))
+		workers.Add(api.NewAPIListener("API", cfg, db, pm, *configFile))
 		workers.Add(agreement.NewAgreementWorker("Agreement", cfg, db, pm))
 		workers.Add(governance.NewGovernanceWorker("Governance", cfg, db, pm))
 		workers.Add(exchange.NewExchangeMessageWorker("ExchangeMessages", cfg, db))
@@ -189,13 +221,15 @@ func main() {
 			workers.Add(imageWorker)
 		}
 		workers.Add(kube_operator.NewKubeWorker("Kube", cfg, db))
+		workers.Add(wasm.NewWasmWorker("Wasm", cfg, db))
+		workers.Add(hostprocess.NewHostProcessWorker("HostProcess", cfg, db))
 		workers.Add(resource.NewResourceWorker("Resource", cfg, db, authm))
 		workers.Add(changes.NewChangesWorker("ExchangeChanges", cfg, db))
 		workers.Add(nodemanagement.NewNodeManagementWorker("NodeManagement", cfg, db))
 		workers.Add(download.NewDownloadWorker("Download", cfg, db))
 
 		// add cluster upgrade worker only when it is edge cluster
-		if cfg.Edge.DockerEndpoint == "" {
+		if cfg.Edge.DockerEndpoint == "" && !cfg.IsContainerdRuntime() {
 			workers.Add(clusterupgrade.NewClusterUpgradeWorker("ClusterUpgrade", cfg, db))
 		}
 	}
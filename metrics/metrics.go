@@ -0,0 +1,370 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The metrics of the agent, exposed in the Prometheus text format by the GET /metrics API.
+const (
+	WORKER_QUEUE_DEPTH        = "anax_worker_queue_depth"
+	AGREEMENTS                = "anax_agreements"
+	EXCHANGE_REQUESTS         = "anax_exchange_requests_total"
+	EXCHANGE_REQUEST_DURATION = "anax_exchange_request_duration_seconds"
+	EXCHANGE_CIRCUIT_OPEN     = "anax_exchange_circuit_open"
+	CONTAINER_RESTARTS        = "anax_container_restarts_total"
+	HEARTBEATS                = "anax_heartbeats_total"
+)
+
+// The metrics of the agbot, exposed in the Prometheus text format by the GET /metrics API of the agbot.
+const (
+	AGBOT_PROPOSALS            = "anax_agbot_proposals_total"
+	AGBOT_REPLIES              = "anax_agbot_proposal_replies_total"
+	AGBOT_AGREEMENTS_FINALIZED = "anax_agbot_agreements_finalized_total"
+	AGBOT_AGREEMENTS           = "anax_agbot_agreements"
+	AGBOT_NEGOTIATION_FAILURES = "anax_agbot_negotiation_failures_total"
+	AGBOT_DATABASE_DURATION    = "anax_agbot_database_duration_seconds"
+)
+
+const (
+	COUNTER   = "counter"
+	GAUGE     = "gauge"
+	HISTOGRAM = "histogram"
+)
+
+// The content type of the Prometheus text format.
+const CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+
+// The buckets of the exchange request duration histogram, in seconds.
+var exchangeDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// The buckets of the agbot database operation duration histogram, in seconds.
+var databaseDurationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 5}
+
+var defaultRegistry = NewRegistry()
+
+func init() {
+	defaultRegistry.Describe(WORKER_QUEUE_DEPTH, GAUGE, "The number of commands waiting in the queue of each worker.", nil)
+	defaultRegistry.Describe(AGREEMENTS, GAUGE, "The number of agreements on the node in each state.", nil)
+	defaultRegistry.Describe(EXCHANGE_REQUESTS, COUNTER, "The number of requests to the exchange by endpoint, method and HTTP status code, the code is \"error\" when no response was received.", nil)
+	defaultRegistry.Describe(EXCHANGE_REQUEST_DURATION, HISTOGRAM, "The duration of the requests to the exchange by method.", exchangeDurationBuckets)
+	defaultRegistry.Describe(EXCHANGE_CIRCUIT_OPEN, GAUGE, "Whether the circuit breaker of each exchange endpoint is open, 1 when the calls fail over to the next endpoint.", nil)
+	defaultRegistry.Describe(CONTAINER_RESTARTS, COUNTER, "The number of times the containers of a service were restarted after a failure.", nil)
+	defaultRegistry.Describe(HEARTBEATS, COUNTER, "The number of heartbeats to the exchange by result.", nil)
+
+	defaultRegistry.Describe(AGBOT_PROPOSALS, COUNTER, "The number of agreement proposals sent to nodes by protocol.", nil)
+	defaultRegistry.Describe(AGBOT_REPLIES, COUNTER, "The number of replies to agreement proposals by protocol and decision, the decision is \"accepted\" or \"rejected\".", nil)
+	defaultRegistry.Describe(AGBOT_AGREEMENTS_FINALIZED, COUNTER, "The number of agreements finalized by protocol.", nil)
+	defaultRegistry.Describe(AGBOT_AGREEMENTS, GAUGE, "The number of agreements in each database partition by state, the state is \"active\" or \"archived\".", nil)
+	defaultRegistry.Describe(AGBOT_NEGOTIATION_FAILURES, COUNTER, "The number of times the agbot decided not to propose an agreement to a node by the stage of the negotiation that failed.", nil)
+	defaultRegistry.Describe(AGBOT_DATABASE_DURATION, HISTOGRAM, "The duration of the agbot database operations by operation.", databaseDurationBuckets)
+}
+
+func GetRegistry() *Registry {
+	return defaultRegistry
+}
+
+// The labels of a metric sample, e.g. {"worker": "AgBot"}.
+type Labels map[string]string
+
+// Render the labels in the Prometheus text format, sorted by name so that the same labels always give the same key.
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(l))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%v=\"%v\"", name, escapeLabelValue(l[name])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(v)
+}
+
+// The value of a metric for one set of labels.
+type series struct {
+	labels  Labels
+	value   float64
+	buckets []uint64 // the histogram observations in each bucket, not cumulative
+	count   uint64
+	sum     float64
+	getter  func() float64 // the gauge value is read when the metrics are written
+}
+
+type family struct {
+	name    string
+	mtype   string
+	help    string
+	buckets []float64
+	series  map[string]*series
+}
+
+// A set of metrics that is safe to update from the worker go routines.
+type Registry struct {
+	lock     sync.Mutex
+	families map[string]*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		families: make(map[string]*family),
+	}
+}
+
+// Add a metric to the registry, buckets are only used by a histogram.
+func (r *Registry) Describe(name string, mtype string, help string, buckets []float64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.families[name] = &family{
+		name:    name,
+		mtype:   mtype,
+		help:    help,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+}
+
+// Return the series of the metric with the given labels, creating it when needed. The caller holds the lock.
+func (r *Registry) getSeries(name string, labels Labels) *series {
+	f, ok := r.families[name]
+	if !ok {
+		return nil
+	}
+	key := labels.String()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: labels}
+		if f.mtype == HISTOGRAM {
+			s.buckets = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Add a value to a counter.
+func (r *Registry) Add(name string, labels Labels, v float64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if s := r.getSeries(name, labels); s != nil {
+		s.value += v
+	}
+}
+
+// Set the value of a gauge.
+func (r *Registry) Set(name string, labels Labels, v float64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if s := r.getSeries(name, labels); s != nil {
+		s.value = v
+		s.getter = nil
+	}
+}
+
+// Set a function that returns the value of a gauge when the metrics are written.
+func (r *Registry) SetFunc(name string, labels Labels, getter func() float64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if s := r.getSeries(name, labels); s != nil {
+		s.getter = getter
+	}
+}
+
+// Remove all the values of a metric, e.g. before setting gauges for a new set of labels.
+func (r *Registry) Reset(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if f, ok := r.families[name]; ok {
+		f.series = make(map[string]*series)
+	}
+}
+
+// Add an observation to a histogram.
+func (r *Registry) Observe(name string, labels Labels, v float64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if s := r.getSeries(name, labels); s != nil {
+		for i, bound := range r.families[name].buckets {
+			if v <= bound {
+				s.buckets[i]++
+				break
+			}
+		}
+		s.count++
+		s.sum += v
+	}
+}
+
+// Write all the metrics in the Prometheus text format, sorted by name and labels.
+func (r *Registry) Write(w io.Writer) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	bw := bufio.NewWriter(w)
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := r.families[name]
+		fmt.Fprintf(bw, "# HELP %v %v\n", f.name, f.help)
+		fmt.Fprintf(bw, "# TYPE %v %v\n", f.name, f.mtype)
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := f.series[key]
+			if f.mtype != HISTOGRAM {
+				value := s.value
+				if s.getter != nil {
+					value = s.getter()
+				}
+				fmt.Fprintf(bw, "%v%v %v\n", f.name, key, formatValue(value))
+				continue
+			}
+
+			var cumulative uint64
+			for i, bound := range f.buckets {
+				cumulative += s.buckets[i]
+				fmt.Fprintf(bw, "%v_bucket%v %v\n", f.name, withLabel(s.labels, "le", formatValue(bound)), cumulative)
+			}
+			fmt.Fprintf(bw, "%v_bucket%v %v\n", f.name, withLabel(s.labels, "le", "+Inf"), s.count)
+			fmt.Fprintf(bw, "%v_sum%v %v\n", f.name, key, formatValue(s.sum))
+			fmt.Fprintf(bw, "%v_count%v %v\n", f.name, key, s.count)
+		}
+	}
+	return bw.Flush()
+}
+
+func withLabel(labels Labels, name string, value string) string {
+	l := Labels{name: value}
+	for k, v := range labels {
+		l[k] = v
+	}
+	return l.String()
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	} else if math.IsInf(v, -1) {
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Count a request to the exchange endpoint, the scheme and host that served it, and its duration. The code is the HTTP
+// status code, 0 when there was no response.
+func ExchangeRequest(endpoint string, method string, code int, duration time.Duration) {
+	status := "error"
+	if code != 0 {
+		status = strconv.Itoa(code)
+	}
+	defaultRegistry.Add(EXCHANGE_REQUESTS, Labels{"endpoint": endpoint, "method": method, "code": status}, 1)
+	defaultRegistry.Observe(EXCHANGE_REQUEST_DURATION, Labels{"method": method}, duration.Seconds())
+}
+
+// Report whether the circuit breaker of the exchange endpoint is open when the metrics are written.
+func ExchangeCircuit(endpoint string, open func() bool) {
+	defaultRegistry.SetFunc(EXCHANGE_CIRCUIT_OPEN, Labels{"endpoint": endpoint}, func() float64 {
+		if open() {
+			return 1
+		}
+		return 0
+	})
+}
+
+// Count a heartbeat to the exchange.
+func Heartbeat(success bool) {
+	result := "success"
+	if !success {
+		result = "failure"
+	}
+	defaultRegistry.Add(HEARTBEATS, Labels{"result": result}, 1)
+}
+
+// Count a restart of the containers of a service.
+func ContainerRestart(service string) {
+	defaultRegistry.Add(CONTAINER_RESTARTS, Labels{"service": service}, 1)
+}
+
+// Report the queue depth of a worker when the metrics are written.
+func WorkerQueue(worker string, depth func() int) {
+	defaultRegistry.SetFunc(WORKER_QUEUE_DEPTH, Labels{"worker": worker}, func() float64 { return float64(depth()) })
+}
+
+// Replace the agreement counts, keyed by state.
+func SetAgreements(counts map[string]int) {
+	defaultRegistry.Reset(AGREEMENTS)
+	for state, count := range counts {
+		defaultRegistry.Set(AGREEMENTS, Labels{"state": state}, float64(count))
+	}
+}
+
+// Count an agreement proposal sent by the agbot.
+func Proposal(protocol string) {
+	defaultRegistry.Add(AGBOT_PROPOSALS, Labels{"protocol": protocol}, 1)
+}
+
+// Count a reply to an agreement proposal of the agbot.
+func ProposalReply(protocol string, accepted bool) {
+	decision := "accepted"
+	if !accepted {
+		decision = "rejected"
+	}
+	defaultRegistry.Add(AGBOT_REPLIES, Labels{"protocol": protocol, "decision": decision}, 1)
+}
+
+// Count an agreement finalized by the agbot.
+func AgreementFinalized(protocol string) {
+	defaultRegistry.Add(AGBOT_AGREEMENTS_FINALIZED, Labels{"protocol": protocol}, 1)
+}
+
+// Count a negotiation that the agbot stopped before making a proposal, by the stage that failed.
+func NegotiationFailure(stage string) {
+	defaultRegistry.Add(AGBOT_NEGOTIATION_FAILURES, Labels{"stage": stage}, 1)
+}
+
+// Record the duration of an agbot database operation.
+func DatabaseOperation(operation string, duration time.Duration) {
+	defaultRegistry.Observe(AGBOT_DATABASE_DURATION, Labels{"operation": operation}, duration.Seconds())
+}
+
+// Replace the agbot agreement counts of the partitions, keyed by partition.
+func SetAgbotAgreements(active map[string]int64, archived map[string]int64) {
+	defaultRegistry.Reset(AGBOT_AGREEMENTS)
+	for partition, count := range active {
+		defaultRegistry.Set(AGBOT_AGREEMENTS, Labels{"partition": partition, "state": "active"}, float64(count))
+	}
+	for partition, count := range archived {
+		defaultRegistry.Set(AGBOT_AGREEMENTS, Labels{"partition": partition, "state": "archived"}, float64(count))
+	}
+}
@@ -0,0 +1,90 @@
+//go:build unit
+// +build unit
+
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Write counters, gauges and histograms in the Prometheus text format.
+func Test_Registry_Write(t *testing.T) {
+
+	r := NewRegistry()
+	r.Describe("test_requests_total", COUNTER, "The requests.", nil)
+	r.Describe("test_queue", GAUGE, "The queue.", nil)
+	r.Describe("test_duration_seconds", HISTOGRAM, "The duration.", []float64{0.1, 1})
+
+	r.Add("test_requests_total", Labels{"method": "GET", "code": "200"}, 1)
+	r.Add("test_requests_total", Labels{"code": "200", "method": "GET"}, 2)
+	r.Add("test_requests_total", Labels{"method": "PUT", "code": "error"}, 1)
+	r.Add("test_unknown", nil, 1)
+
+	depth := 3
+	r.SetFunc("test_queue", Labels{"worker": "a\"b"}, func() float64 { return float64(depth) })
+	depth = 5
+
+	r.Observe("test_duration_seconds", Labels{"method": "GET"}, 0.05)
+	r.Observe("test_duration_seconds", Labels{"method": "GET"}, 0.5)
+	r.Observe("test_duration_seconds", Labels{"method": "GET"}, 5)
+
+	var out bytes.Buffer
+	if err := r.Write(&out); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	}
+
+	expected := `# HELP test_duration_seconds The duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.1",method="GET"} 1
+test_duration_seconds_bucket{le="1",method="GET"} 2
+test_duration_seconds_bucket{le="+Inf",method="GET"} 3
+test_duration_seconds_sum{method="GET"} 5.55
+test_duration_seconds_count{method="GET"} 3
+# HELP test_queue The queue.
+# TYPE test_queue gauge
+test_queue{worker="a\"b"} 5
+# HELP test_requests_total The requests.
+# TYPE test_requests_total counter
+test_requests_total{code="200",method="GET"} 3
+test_requests_total{code="error",method="PUT"} 1
+`
+	if out.String() != expected {
+		t.Errorf("wrong output:\n%v\nexpected:\n%v", out.String(), expected)
+	}
+}
+
+// The agreement gauges are replaced on each update.
+func Test_SetAgreements(t *testing.T) {
+
+	SetAgreements(map[string]int{"executing": 2, "archived": 1})
+	SetAgreements(map[string]int{"executing": 1})
+
+	var out bytes.Buffer
+	if err := GetRegistry().Write(&out); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if !strings.Contains(out.String(), "anax_agreements{state=\"executing\"} 1\n") {
+		t.Errorf("executing agreements not found in:\n%v", out.String())
+	} else if strings.Contains(out.String(), "state=\"archived\"") {
+		t.Errorf("archived agreements should have been removed:\n%v", out.String())
+	}
+}
+
+// The agbot agreement gauges are labelled by partition and state, and replaced on each update.
+func Test_SetAgbotAgreements(t *testing.T) {
+
+	SetAgbotAgreements(map[string]int64{"p1": 4, "p2": 1}, map[string]int64{"p1": 7})
+	SetAgbotAgreements(map[string]int64{"p1": 3}, map[string]int64{"p1": 8})
+
+	var out bytes.Buffer
+	if err := GetRegistry().Write(&out); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if !strings.Contains(out.String(), "anax_agbot_agreements{partition=\"p1\",state=\"active\"} 3\n") {
+		t.Errorf("active agreements not found in:\n%v", out.String())
+	} else if !strings.Contains(out.String(), "anax_agbot_agreements{partition=\"p1\",state=\"archived\"} 8\n") {
+		t.Errorf("archived agreements not found in:\n%v", out.String())
+	} else if strings.Contains(out.String(), "partition=\"p2\"") {
+		t.Errorf("partition p2 should have been removed:\n%v", out.String())
+	}
+}
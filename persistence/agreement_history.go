@@ -0,0 +1,176 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"time"
+)
+
+// agreement history table name
+const AGREEMENT_HISTORY = "agreement_history"
+
+// The number of agreements whose history is kept, the history of the oldest agreement is removed to make room for a new one.
+const MAX_AGREEMENT_HISTORIES = 200
+
+// The steps of the negotiation and the life of an agreement on the node.
+const (
+	AG_HISTORY_PROPOSAL_RECEIVED  = "proposal_received"
+	AG_HISTORY_PROPOSAL_REJECTED  = "proposal_rejected"
+	AG_HISTORY_AGREEMENT_CREATED  = "agreement_created"
+	AG_HISTORY_AGREEMENT_REACHED  = "agreement_reached"
+	AG_HISTORY_FINALIZED          = "finalized"
+	AG_HISTORY_EXECUTION_STARTED  = "execution_started"
+	AG_HISTORY_TERMINATED         = "terminated"
+	AG_HISTORY_AGREEMENT_DELETED  = "agreement_deleted"
+	AG_HISTORY_AGREEMENT_ARCHIVED = "agreement_archived"
+)
+
+// One step in the history of an agreement.
+type AgreementHistoryEntry struct {
+	Time       uint64   `json:"time"`
+	Event      string   `json:"event"`
+	Actor      string   `json:"actor,omitempty"`       // the agbot that sent the proposal or the node that answered it
+	Policies   []string `json:"policies,omitempty"`    // the pattern and the policies that were evaluated
+	Services   []string `json:"services,omitempty"`    // the services of the agreement
+	ReasonCode uint64   `json:"reason_code,omitempty"` // the termination reason code
+	Reason     string   `json:"reason,omitempty"`      // why the proposal was rejected or the agreement was terminated
+}
+
+func NewAgreementHistoryEntry(event string, actor string, reason string) *AgreementHistoryEntry {
+	return &AgreementHistoryEntry{
+		Time:   uint64(time.Now().Unix()),
+		Event:  event,
+		Actor:  actor,
+		Reason: reason,
+	}
+}
+
+func (e AgreementHistoryEntry) String() string {
+	return fmt.Sprintf("Time: %v, "+
+		"Event: %v, "+
+		"Actor: %v, "+
+		"Policies: %v, "+
+		"Services: %v, "+
+		"ReasonCode: %v, "+
+		"Reason: %v",
+		e.Time, e.Event, e.Actor, e.Policies, e.Services, e.ReasonCode, e.Reason)
+}
+
+// The timeline of an agreement, from the proposal to the termination, in the order the steps happened.
+type AgreementHistory struct {
+	AgreementId string                  `json:"agreement_id"`
+	Protocol    string                  `json:"protocol,omitempty"`
+	Entries     []AgreementHistoryEntry `json:"entries"`
+}
+
+func (h AgreementHistory) String() string {
+	return fmt.Sprintf("AgreementId: %v, "+
+		"Protocol: %v, "+
+		"Entries: %v",
+		h.AgreementId, h.Protocol, h.Entries)
+}
+
+// append an entry to the history of the agreement. When the history of a new agreement is started and the db already
+// holds MAX_AGREEMENT_HISTORIES histories, the oldest one is removed.
+func AddAgreementHistoryEntry(db *bolt.DB, agreementId string, protocol string, entry *AgreementHistoryEntry) error {
+	if agreementId == "" {
+		return fmt.Errorf("Agreement id empty, cannot add %v to the agreement history", entry.Event)
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(AGREEMENT_HISTORY))
+		if err != nil {
+			return err
+		}
+
+		history := AgreementHistory{AgreementId: agreementId, Entries: []AgreementHistoryEntry{}}
+		if current := bucket.Get([]byte(agreementId)); current != nil {
+			if err := json.Unmarshal(current, &history); err != nil {
+				return fmt.Errorf("Failed to unmarshal the history of agreement %v. Error: %v", agreementId, err)
+			}
+		} else if err := pruneAgreementHistories(bucket); err != nil {
+			return err
+		}
+
+		if history.Protocol == "" {
+			history.Protocol = protocol
+		}
+		history.Entries = append(history.Entries, *entry)
+
+		if serial, err := json.Marshal(history); err != nil {
+			return fmt.Errorf("Failed to serialize the history of agreement %v. Error: %v", agreementId, err)
+		} else {
+			return bucket.Put([]byte(agreementId), serial)
+		}
+	})
+}
+
+// remove the histories that were started first until there is room for a new one.
+func pruneAgreementHistories(bucket *bolt.Bucket) error {
+	started := make(map[string]uint64)
+	bucket.ForEach(func(k, v []byte) error {
+		var h AgreementHistory
+		if err := json.Unmarshal(v, &h); err != nil || len(h.Entries) == 0 {
+			started[string(k)] = 0
+		} else {
+			started[string(k)] = h.Entries[0].Time
+		}
+		return nil
+	})
+
+	for len(started) >= MAX_AGREEMENT_HISTORIES {
+		oldest := ""
+		for id, t := range started {
+			if oldest == "" || t < started[oldest] || (t == started[oldest] && id < oldest) {
+				oldest = id
+			}
+		}
+		if err := bucket.Delete([]byte(oldest)); err != nil {
+			return fmt.Errorf("Unable to delete the history of agreement %v: %v", oldest, err)
+		}
+		delete(started, oldest)
+	}
+	return nil
+}
+
+// add an entry to the agreement history, only logging the error. The history must not get in the way of the agreement.
+func recordAgreementHistory(db *bolt.DB, agreementId string, protocol string, entry *AgreementHistoryEntry) {
+	if err := AddAgreementHistoryEntry(db, agreementId, protocol, entry); err != nil {
+		glog.Errorf("Unable to add %v to the history of agreement %v, error: %v", entry.Event, agreementId, err)
+	}
+}
+
+// find the history of the given agreement, nil if there is none.
+func FindAgreementHistory(db *bolt.DB, agreementId string) (*AgreementHistory, error) {
+	var history *AgreementHistory
+
+	readErr := db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(AGREEMENT_HISTORY)); b != nil {
+			if v := b.Get([]byte(agreementId)); v != nil {
+				var h AgreementHistory
+				if err := json.Unmarshal(v, &h); err != nil {
+					return fmt.Errorf("Unable to deserialize the history of agreement %v: %v. Error: %v", agreementId, string(v), err)
+				}
+				history = &h
+			}
+		}
+		return nil // end the transaction
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return history, nil
+}
+
+// delete the histories of all the agreements from the db.
+func DeleteAgreementHistories(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(AGREEMENT_HISTORY)) != nil {
+			return tx.DeleteBucket([]byte(AGREEMENT_HISTORY))
+		}
+		return nil
+	})
+}
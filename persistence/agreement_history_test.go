@@ -0,0 +1,94 @@
+//go:build unit
+// +build unit
+
+package persistence
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Verify that the agreement state changes are added to the history of the agreement in order.
+func Test_AgreementHistory(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	if h, err := FindAgreementHistory(db, "ag1"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if h != nil {
+		t.Errorf("expected no history, got %v", h)
+	}
+
+	received := NewAgreementHistoryEntry(AG_HISTORY_PROPOSAL_RECEIVED, "myorg/agbot1", "")
+	received.Policies = []string{"pattern myorg/p1"}
+	if err := AddAgreementHistoryEntry(db, "ag1", "Basic", received); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	}
+
+	wi, _ := NewWorkloadInfo("url", "org", "version", "")
+	if _, err := NewEstablishedAgreement(db, "name1", "ag1", "myorg/agbot1", "{}", "Basic", 1, []ServiceSpec{}, "", "", "", "", "", wi, 180); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if _, err := AgreementStateAccepted(db, "ag1", "Basic"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if _, err := AgreementStateTerminated(db, "ag1", 200, "node policy changed", "Basic"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	}
+
+	if h, err := FindAgreementHistory(db, "ag1"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if h == nil || h.Protocol != "Basic" || len(h.Entries) != 4 {
+		t.Errorf("wrong history %v", h)
+	} else if h.Entries[0].Event != AG_HISTORY_PROPOSAL_RECEIVED || h.Entries[0].Policies[0] != "pattern myorg/p1" {
+		t.Errorf("wrong first entry %v", h.Entries[0])
+	} else if h.Entries[1].Event != AG_HISTORY_AGREEMENT_CREATED || h.Entries[1].Actor != "myorg/agbot1" {
+		t.Errorf("wrong second entry %v", h.Entries[1])
+	} else if h.Entries[2].Event != AG_HISTORY_AGREEMENT_REACHED {
+		t.Errorf("wrong third entry %v", h.Entries[2])
+	} else if h.Entries[3].Event != AG_HISTORY_TERMINATED || h.Entries[3].ReasonCode != 200 || h.Entries[3].Reason != "node policy changed" {
+		t.Errorf("wrong fourth entry %v", h.Entries[3])
+	}
+
+	if err := DeleteAgreementHistories(db); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if h, err := FindAgreementHistory(db, "ag1"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if h != nil {
+		t.Errorf("expected no history, got %v", h)
+	}
+}
+
+// Verify that the history of the oldest agreement is removed to make room for a new one.
+func Test_AgreementHistoryPruned(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	for i := 0; i <= MAX_AGREEMENT_HISTORIES; i++ {
+		entry := NewAgreementHistoryEntry(AG_HISTORY_PROPOSAL_RECEIVED, "myorg/agbot1", "")
+		entry.Time = uint64(1000 + i)
+		if err := AddAgreementHistoryEntry(db, fmt.Sprintf("ag%v", i), "Basic", entry); err != nil {
+			t.Errorf("should not return error, but got %v", err)
+		}
+	}
+
+	if h, err := FindAgreementHistory(db, "ag0"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if h != nil {
+		t.Errorf("expected the oldest history to be removed, got %v", h)
+	} else if h, err := FindAgreementHistory(db, "ag1"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if h == nil {
+		t.Errorf("expected the history of ag1 to be kept")
+	} else if h, err := FindAgreementHistory(db, fmt.Sprintf("ag%v", MAX_AGREEMENT_HISTORIES)); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if h == nil {
+		t.Errorf("expected the newest history to be kept")
+	}
+}
@@ -17,6 +17,10 @@ type ContainerVolume struct {
 	Name         string `json:"name"`
 	CreationTime uint64 `json:"creation_time"`
 	ArchiveTime  uint64 `json:"archive_time"`
+	AgreementId  string `json:"agreement_id,omitempty"` // the agreement or service instance using a named volume of the deployment config
+	ServiceURL   string `json:"service_url,omitempty"`  // the org qualified url of the service using the named volume
+	Retain       string `json:"retain,omitempty"`       // the retention policy of the named volume
+	ReleaseTime  uint64 `json:"release_time,omitempty"` // the time the agreement using a retained named volume ended
 }
 
 func NewContainerVolume(name string) *ContainerVolume {
@@ -31,8 +35,12 @@ func (w ContainerVolume) String() string {
 	return fmt.Sprintf("RecordId: %v, "+
 		"Name: %v, "+
 		"CreationTime: %v, "+
-		"ArchiveTime: %v",
-		w.RecordId, w.Name, w.CreationTime, w.ArchiveTime)
+		"ArchiveTime: %v, "+
+		"AgreementId: %v, "+
+		"ServiceURL: %v, "+
+		"Retain: %v, "+
+		"ReleaseTime: %v",
+		w.RecordId, w.Name, w.CreationTime, w.ArchiveTime, w.AgreementId, w.ServiceURL, w.Retain, w.ReleaseTime)
 }
 
 func (w ContainerVolume) ShortString() string {
@@ -67,12 +75,46 @@ func SaveContainerVolume(db *bolt.DB, container_volume *ContainerVolume) error {
 	return writeErr
 }
 
-// save the container volume into db.
+// save the container volume into db, unless the volume is already known.
 func SaveContainerVolumeByName(db *bolt.DB, name string) error {
+	if cvs, err := FindContainerVolumes(db, []ContainerVolumeFilter{UnarchivedCVFilter(), NameCVFilter(name)}); err != nil {
+		return err
+	} else if len(cvs) != 0 {
+		return nil
+	}
+	pcv := NewContainerVolume(name)
+	return SaveContainerVolume(db, pcv)
+}
+
+// Save the named volume of a deployment config, used by the given agreement or service instance. The record of a volume
+// that is already known, e.g. a volume retained from a previous version of the service, is taken over by the agreement.
+func SaveNamedContainerVolume(db *bolt.DB, name string, agreementId string, serviceURL string, retain string) error {
 	pcv := NewContainerVolume(name)
+	if cvs, err := FindContainerVolumes(db, []ContainerVolumeFilter{UnarchivedCVFilter(), NameCVFilter(name)}); err != nil {
+		return err
+	} else if len(cvs) != 0 {
+		pcv = &cvs[0]
+	}
+
+	pcv.AgreementId = agreementId
+	pcv.ServiceURL = serviceURL
+	pcv.Retain = retain
+	pcv.ReleaseTime = 0
 	return SaveContainerVolume(db, pcv)
 }
 
+// Mark the named volume as no longer used by its agreement.
+func ReleaseContainerVolume(db *bolt.DB, cv *ContainerVolume) error {
+	if cv == nil {
+		return nil
+	}
+	cv.ReleaseTime = uint64(time.Now().Unix())
+	if err := SaveContainerVolume(db, cv); err != nil {
+		return fmt.Errorf("Failed to release the container volume %v. %v", cv.Name, err)
+	}
+	return nil
+}
+
 // Find the container volumes that are not deleted yet
 func FindAllUndeletedContainerVolumes(db *bolt.DB) ([]ContainerVolume, error) {
 	return FindContainerVolumes(db, []ContainerVolumeFilter{UnarchivedCVFilter()})
@@ -103,6 +145,16 @@ func NameCVFilter(name string) ContainerVolumeFilter {
 	return func(c ContainerVolume) bool { return c.Name == name }
 }
 
+// filter on the agreement using a named volume
+func AgreementCVFilter(agreementId string) ContainerVolumeFilter {
+	return func(c ContainerVolume) bool { return c.AgreementId == agreementId }
+}
+
+// filter on the named volumes of the deployment configs
+func NamedCVFilter() ContainerVolumeFilter {
+	return func(c ContainerVolume) bool { return c.AgreementId != "" }
+}
+
 // find container volumes from the db for the given filters
 func FindContainerVolumes(db *bolt.DB, filters []ContainerVolumeFilter) ([]ContainerVolume, error) {
 	cvs := make([]ContainerVolume, 0)
@@ -0,0 +1,42 @@
+//go:build unit
+// +build unit
+
+package persistence
+
+import (
+	"testing"
+)
+
+// Verify that a retained named volume is taken over by the next agreement of the service.
+func Test_SaveNamedContainerVolume(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	if err := SaveContainerVolumeByName(db, "models"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if err := SaveNamedContainerVolume(db, "models", "ag1", "myorg/mysvc", "upgrade"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	}
+
+	cvs, err := FindContainerVolumes(db, []ContainerVolumeFilter{UnarchivedCVFilter(), AgreementCVFilter("ag1")})
+	if err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(cvs) != 1 || cvs[0].ServiceURL != "myorg/mysvc" || cvs[0].Retain != "upgrade" {
+		t.Fatalf("wrong container volumes %v", cvs)
+	} else if err := ReleaseContainerVolume(db, &cvs[0]); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	}
+
+	// the next version of the service takes over the released volume
+	if err := SaveNamedContainerVolume(db, "models", "ag2", "myorg/mysvc", "upgrade"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if cvs, err := FindContainerVolumes(db, []ContainerVolumeFilter{UnarchivedCVFilter(), NamedCVFilter()}); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(cvs) != 1 || cvs[0].AgreementId != "ag2" || cvs[0].ReleaseTime != 0 {
+		t.Errorf("wrong container volumes %v", cvs)
+	}
+}
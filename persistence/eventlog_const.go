@@ -69,6 +69,14 @@ const (
 	EC_NODE_HEARTBEAT_FAILED   = "node_heartbeat_failed"
 	EC_NODE_HEARTBEAT_RESTORED = "node_heartbeat_restored"
 
+	// agent configuration
+	EC_CONFIG_RELOADED       = "config_reloaded"
+	EC_ERROR_CONFIG_RELOADED = "error_config_reloaded"
+
+	// node maintenance
+	EC_NODE_MAINTENANCE_ON  = "node_maintenance_on"
+	EC_NODE_MAINTENANCE_OFF = "node_maintenance_off"
+
 	// service configuration
 	EC_START_SERVICE_CONFIG                = "start_service_configuration"
 	EC_SERVICE_CONFIG_COMPLETE             = "service_configuration_complete"
@@ -81,6 +89,10 @@ const (
 	EC_CHANGING_SERVICE_CONFIGSTATE_COMPLETE = "changing_service_configuration_state_complete"
 	EC_ERROR_CHANGING_SERVICE_CONFIGSTATE    = "error_changing_service_configuration_state"
 
+	// service pause
+	EC_SERVICE_PAUSED  = "service_paused"
+	EC_SERVICE_RESUMED = "service_resumed"
+
 	// agreement related event code
 	EC_RECEIVED_PROPOSAL         = "received_proposal"
 	EC_IGNORE_PROPOSAL           = "ignore_proposal"
@@ -88,6 +100,10 @@ const (
 	EC_ERROR_IN_PROPOSAL         = "error_in_proposal"
 	EC_ERROR_PROCESSING_PROPOSAL = "error_processing_proposal"
 
+	EC_ERROR_INSUFFICIENT_CLUSTER_RESOURCES = "error_insufficient_cluster_resources"
+	EC_ERROR_INSUFFICIENT_DEVICE_RESOURCES  = "error_insufficient_device_resources"
+	EC_PREEMPTING_AGREEMENT                 = "preempting_agreement"
+
 	EC_RECEIVED_REPLYACK_MESSAGE         = "received_replyack_message"
 	EC_IGNORE_REPLYACK_MESSAGE           = "ignore_replyack_message"
 	EC_ERROR_PROCESSING_REPLYACT_MESSAGE = "error_ptocessing_replyack_message"
@@ -123,14 +139,36 @@ const (
 	EC_CANCEL_AGREEMENT_PER_AGBOT         = "cancel_agreement_per_agbot_request"
 	EC_CANCEL_AGREEMENT_SERVICE_SUSPENDED = "cancel_agreement_service_suspended"
 	EC_CANCEL_AGREEMENT_POLICY_CHANGED    = "cancel_agreement_policy_changed"
+	EC_CANCEL_AGREEMENT_PREEMPTED         = "cancel_agreement_preempted"
 
 	EC_CONTAINER_RUNNING          = "container_running"
 	EC_CONTAINER_STOPPED          = "container_stopped"
 	EC_ERROR_IN_DEPLOYMENT_CONFIG = "error_in_deployment_configuration"
 	EC_ERROR_START_CONTAINER      = "error_start_container"
+	EC_CONTAINER_CHECKPOINTED     = "container_checkpointed"
+	EC_ERROR_CHECKPOINT_CONTAINER = "error_checkpoint_container"
+	EC_CONTAINER_RESTORED         = "container_restored"
+	EC_ERROR_RESTORE_CONTAINER    = "error_restore_container"
+	EC_VOLUME_EXPORTED            = "volume_exported"
+	EC_ERROR_EXPORT_VOLUME        = "error_export_volume"
+
+	EC_START_K8S_OPERATOR_INSTALL    = "start_k8s_operator_install"
+	EC_K8S_OPERATOR_INSTALL_COMPLETE = "k8s_operator_install_complete"
+	EC_K8S_OBJECT_INSTALLED          = "k8s_object_installed"
+	EC_ERROR_K8S_OBJECT_INSTALL      = "error_k8s_object_install"
+	EC_START_K8S_OPERATOR_UNINSTALL  = "start_k8s_operator_uninstall"
+	EC_K8S_OBJECT_UNINSTALLED        = "k8s_object_uninstalled"
+	EC_ERROR_K8S_OBJECT_UNINSTALL    = "error_k8s_object_uninstall"
+	EC_K8S_OPERATOR_UNINSTALLED      = "k8s_operator_uninstall_complete"
+	EC_K8S_OPERATOR_SCALED           = "k8s_operator_scaled"
+	EC_K8S_OPERATOR_PAUSED           = "k8s_operator_paused"
+	EC_K8S_OPERATOR_RESUMED          = "k8s_operator_resumed"
+	EC_K8S_SERVICE_SECRETS_UPDATED   = "k8s_service_secrets_updated"
+	EC_K8S_OBJECT_DRIFT_REPAIRED     = "k8s_object_drift_repaired"
 
 	EC_IMAGE_LOADED                       = "image_loaded"
 	EC_ERROR_IMAGE_LOADE                  = "error_image_load"
+	EC_IMAGE_AUTH_REFRESHED               = "image_auth_refreshed"
 	EC_ERROR_AGREEMENT_VERIFICATION       = "error_in_agreement_verification"
 	EC_ERROR_DELETE_AGREEMENT_IN_EXCHANGE = "error_delete_agreement_in_exchange"
 
@@ -10,6 +10,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -233,6 +234,27 @@ func GetLastUnregistrationTime(db *bolt.DB) (uint64, error) {
 	}
 }
 
+// The functions that are called with every event log saved in the db, so that the event logs can be pushed to the
+// clients of the agent API as they occur.
+var eventLogListeners []func(EventLog)
+var eventLogListenersLock sync.RWMutex
+
+// Register a function that is called with each new event log. It is called on the thread that saves the event log,
+// so it must not block.
+func AddEventLogListener(listener func(EventLog)) {
+	eventLogListenersLock.Lock()
+	defer eventLogListenersLock.Unlock()
+	eventLogListeners = append(eventLogListeners, listener)
+}
+
+func notifyEventLogListeners(event_log EventLog) {
+	eventLogListenersLock.RLock()
+	defer eventLogListenersLock.RUnlock()
+	for _, listener := range eventLogListeners {
+		listener(event_log)
+	}
+}
+
 // save the event log record into db.
 func SaveEventLog(db *bolt.DB, event_log *EventLog) error {
 	writeErr := db.Update(func(tx *bolt.Tx) error {
@@ -253,6 +275,9 @@ func SaveEventLog(db *bolt.DB, event_log *EventLog) error {
 	})
 
 	NewErrorLog(db, *event_log)
+	if writeErr == nil {
+		notifyEventLogListeners(*event_log)
+	}
 	return writeErr
 }
 
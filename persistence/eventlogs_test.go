@@ -476,3 +476,23 @@ func Test_SaveLastUnregistrationTime(t *testing.T) {
 	assert.False(t, e8.Matches(selectors), "Test eventlog Matches.")
 
 }
+
+// Verify that the listeners are called with each event log saved in the db.
+func Test_EventLogListener(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	saved := []EventLog{}
+	AddEventLogListener(func(el EventLog) { saved = append(saved, el) })
+
+	el := NewEventLog(SEVERITY_INFO, NewMessageMeta("Start agreement %v", "ag1"), EC_AGREEMENT_REACHED, SRC_TYPE_AG, AgreementEventSource{AgreementId: "ag1"})
+	if err := SaveEventLog(db, el); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(saved) != 1 || saved[0].Id != el.Id || saved[0].Id == "" {
+		t.Errorf("the listener should have been called with the saved event log, but got %v", saved)
+	}
+}
@@ -0,0 +1,108 @@
+package persistence
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// exchange auth table name
+const EXCHANGE_AUTH = "exchange_auth"
+
+const EXCHANGE_REFRESH_TOKEN = "refresh_token"
+
+// The size of the AES-256 key that encrypts the exchange refresh token.
+const EXCHANGE_AUTH_KEY_SIZE = 32
+
+// Get the key that encrypts the exchange refresh token in the db from the given file. The key is created if the file
+// does not exist. The file is only readable by the user running the agent, so that a copy of the db alone does not
+// reveal the token.
+func GetExchangeAuthKey(keyFile string) ([]byte, error) {
+	if key, err := ioutil.ReadFile(keyFile); err == nil {
+		if len(key) != EXCHANGE_AUTH_KEY_SIZE {
+			return nil, errors.New(fmt.Sprintf("the exchange auth key in %v has %v bytes, expected %v", keyFile, len(key), EXCHANGE_AUTH_KEY_SIZE))
+		}
+		return key, nil
+	} else if !os.IsNotExist(err) {
+		return nil, errors.New(fmt.Sprintf("unable to read the exchange auth key from %v, error: %v", keyFile, err))
+	}
+
+	key := make([]byte, EXCHANGE_AUTH_KEY_SIZE)
+	if _, err := rand.Read(key); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to create the exchange auth key, error: %v", err))
+	} else if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to create the directory of the exchange auth key %v, error: %v", keyFile, err))
+	} else if err := ioutil.WriteFile(keyFile, key, 0600); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to write the exchange auth key to %v, error: %v", keyFile, err))
+	}
+	return key, nil
+}
+
+// save the refresh token with which the agent gets the access tokens of the exchange into the db, encrypted with the key.
+func SaveExchangeRefreshToken(db *bolt.DB, key []byte, refreshToken string) error {
+	gcm, err := exchangeAuthCipher(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("Failed to create the nonce of the exchange refresh token. Error: %v", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(refreshToken), []byte(EXCHANGE_REFRESH_TOKEN))
+
+	return db.Update(func(tx *bolt.Tx) error {
+		if bucket, err := tx.CreateBucketIfNotExists([]byte(EXCHANGE_AUTH)); err != nil {
+			return err
+		} else {
+			return bucket.Put([]byte(EXCHANGE_REFRESH_TOKEN), sealed)
+		}
+	})
+}
+
+// find the refresh token of the exchange, decrypted with the key. It is empty if there is none.
+func FindExchangeRefreshToken(db *bolt.DB, key []byte) (string, error) {
+	var sealed []byte
+
+	readErr := db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(EXCHANGE_AUTH)); b != nil {
+			if v := b.Get([]byte(EXCHANGE_REFRESH_TOKEN)); v != nil {
+				sealed = append([]byte{}, v...)
+			}
+		}
+		return nil // end the transaction
+	})
+
+	if readErr != nil {
+		return "", readErr
+	} else if sealed == nil {
+		return "", nil
+	}
+
+	gcm, err := exchangeAuthCipher(key)
+	if err != nil {
+		return "", err
+	} else if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("The exchange refresh token db record is too short")
+	}
+	refreshToken, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(EXCHANGE_REFRESH_TOKEN))
+	if err != nil {
+		return "", fmt.Errorf("Unable to decrypt the exchange refresh token db record, the exchange auth key may have changed. Error: %v", err)
+	}
+	return string(refreshToken), nil
+}
+
+func exchangeAuthCipher(key []byte) (cipher.AEAD, error) {
+	if block, err := aes.NewCipher(key); err != nil {
+		return nil, fmt.Errorf("Failed to create the cipher of the exchange refresh token. Error: %v", err)
+	} else if gcm, err := cipher.NewGCM(block); err != nil {
+		return nil, fmt.Errorf("Failed to create the cipher of the exchange refresh token. Error: %v", err)
+	} else {
+		return gcm, nil
+	}
+}
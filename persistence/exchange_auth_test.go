@@ -0,0 +1,46 @@
+//go:build unit
+// +build unit
+
+package persistence
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// Verify that the exchange refresh token is kept encrypted and can only be read with the same key.
+func Test_ExchangeRefreshToken(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	keyFile := filepath.Join(dir, "keys", "exchange_auth.key")
+	key, err := GetExchangeAuthKey(keyFile)
+	if err != nil {
+		t.Fatalf("should not return error, but got %v", err)
+	} else if sameKey, err := GetExchangeAuthKey(keyFile); err != nil || string(sameKey) != string(key) {
+		t.Errorf("the key should be read back from %v, got error %v", keyFile, err)
+	}
+
+	if token, err := FindExchangeRefreshToken(db, key); err != nil || token != "" {
+		t.Errorf("there should be no refresh token, got %v with error %v", token, err)
+	}
+
+	if err := SaveExchangeRefreshToken(db, key, "refresh1"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if err := SaveExchangeRefreshToken(db, key, "refresh2"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	}
+
+	if token, err := FindExchangeRefreshToken(db, key); err != nil || token != "refresh2" {
+		t.Errorf("wrong refresh token %v with error %v", token, err)
+	}
+
+	otherKey, _ := GetExchangeAuthKey(filepath.Join(dir, "other.key"))
+	if token, err := FindExchangeRefreshToken(db, otherKey); err == nil {
+		t.Errorf("the refresh token should not be decrypted with another key, got %v", token)
+	}
+}
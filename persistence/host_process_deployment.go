@@ -0,0 +1,117 @@
+package persistence
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/open-horizon/anax/containermessage"
+)
+
+// The name the output of a host process is logged under, in the place of the name of a container, so that the syslog
+// tag of the process is workload-<agreement id>_process.
+const HOST_PROCESS_LOG_NAME = "process"
+
+// The structure of the json string in the deployment field of a service definition when the
+// service is a binary run directly on the host, on platforms where no container runtime is permitted.
+
+type HostProcessDeploymentConfig struct {
+	Binary        string                          `json:"binary"`                   // base64 encoded executable, covered by the deployment signature
+	Args          []string                        `json:"args,omitempty"`           // The arguments passed to the binary
+	User          string                          `json:"user,omitempty"`           // The host user the process runs as, a dynamic user allocated for the service when empty
+	MaxMemoryMb   int64                           `json:"max_memory_mb,omitempty"`  // The memory the process can use
+	MaxCPUs       float32                         `json:"max_cpus,omitempty"`       // The number of CPUs the process can use
+	RestartPolicy *containermessage.RestartPolicy `json:"restart_policy,omitempty"` // How the process is restarted when it exits, always restarted by default
+}
+
+func (h HostProcessDeploymentConfig) String() string {
+	maxBinaryLength := 25
+	if len(h.Binary) < maxBinaryLength {
+		maxBinaryLength = len(h.Binary)
+	}
+	return fmt.Sprintf("Binary %v, Args %v, User %v, MaxMemoryMb %v, MaxCPUs %v, RestartPolicy %v", h.Binary[:maxBinaryLength], h.Args, h.User, h.MaxMemoryMb, h.MaxCPUs, h.RestartPolicy)
+}
+
+func IsHostProcess(dep map[string]interface{}) bool {
+	if _, ok := dep["binary"]; ok {
+		return true
+	}
+	return false
+}
+
+// Validate checks the deployment before the process is run, an error is returned if it is not valid. A host process
+// cannot run as root, a service that needs to be privileged has to run in a container.
+func (h *HostProcessDeploymentConfig) Validate() error {
+	if _, err := base64.StdEncoding.DecodeString(h.Binary); err != nil {
+		return errors.New(fmt.Sprintf("binary is not base64 encoded: %v", err))
+	} else if h.User == "root" || h.User == "0" {
+		return errors.New(fmt.Sprintf("the process cannot run as user %v", h.User))
+	} else if h.MaxMemoryMb < 0 {
+		return errors.New(fmt.Sprintf("max_memory_mb %v cannot be negative", h.MaxMemoryMb))
+	} else if h.MaxCPUs < 0 {
+		return errors.New(fmt.Sprintf("max_cpus %v cannot be negative", h.MaxCPUs))
+	}
+	if h.RestartPolicy != nil {
+		if err := h.RestartPolicy.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Functions that allow HostProcessDeploymentConfig to support the DeploymentConfig interface.
+
+func (h *HostProcessDeploymentConfig) IsNative() bool {
+	return false
+}
+
+func (h *HostProcessDeploymentConfig) ToPersistentForm() (map[string]interface{}, error) {
+	ret := make(map[string]interface{})
+
+	// Marshal to JSON form so that we can unmarshal as a map[string]interface{}.
+	if jBytes, err := json.Marshal(h); err != nil {
+		return ret, errors.New(fmt.Sprintf("error marshalling host process deployment: %v, error: %v", h, err))
+	} else if err := json.Unmarshal(jBytes, &ret); err != nil {
+		return ret, errors.New(fmt.Sprintf("error unmarshalling host process deployment: %v, error: %v", string(jBytes), err))
+	}
+
+	return ret, nil
+}
+
+func (h *HostProcessDeploymentConfig) FromPersistentForm(pf map[string]interface{}) error {
+
+	// Marshal to JSON form so that we can unmarshal as a HostProcessDeploymentConfig.
+	if jBytes, err := json.Marshal(pf); err != nil {
+		return errors.New(fmt.Sprintf("error marshalling host process persistent deployment: %v, error: %v", h, err))
+	} else if err := json.Unmarshal(jBytes, h); err != nil {
+		return errors.New(fmt.Sprintf("error unmarshalling host process persistent deployment: %v, error: %v", string(jBytes), err))
+	}
+
+	return nil
+}
+
+func (h *HostProcessDeploymentConfig) ToString() string {
+	if h != nil {
+		return h.String()
+	} else {
+		return ""
+	}
+}
+
+// Given a deployment string, unmarshal it as a HostProcessDeployment object. It might not be a HostProcessDeployment, so
+// we have to verify what was just unmarshalled.
+func GetHostProcessDeployment(depStr string) (*HostProcessDeploymentConfig, error) {
+
+	hd := new(HostProcessDeploymentConfig)
+	err := json.Unmarshal([]byte(depStr), hd)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error unmarshalling deployment config as HostProcessDeployment: %v", err))
+	}
+
+	if len(hd.Binary) == 0 {
+		return nil, errors.New(fmt.Sprintf("deployment config is not a HostProcessDeployment"))
+	}
+
+	return hd, nil
+
+}
@@ -0,0 +1,83 @@
+//go:build unit
+// +build unit
+
+package persistence
+
+import (
+	"github.com/open-horizon/anax/containermessage"
+	"testing"
+)
+
+func Test_DecodeHostProcessDeployment(t *testing.T) {
+
+	dep := `{"binary":"f0VMRgIBAQ==","args":["--verbose"],"user":"myservice"}`
+	if hd, err := GetHostProcessDeployment(dep); err != nil {
+		t.Errorf("Error extracting host process deployment %v, error: %v", dep, err)
+	} else if hd.Binary != "f0VMRgIBAQ==" || len(hd.Args) != 1 || hd.User != "myservice" {
+		t.Errorf("Extracted host process deployment %v does not match %v", hd, dep)
+	}
+
+	// Neither a native nor a wasm deployment is a host process deployment.
+	for _, other := range []string{`{"services":{"test":{"image":"test:1.0"}}}`, `{"wasm_module":"AGFzbQEAAAA="}`} {
+		if hd, err := GetHostProcessDeployment(other); err == nil {
+			t.Errorf("Should be an error returned for %v", other)
+		} else if hd != nil {
+			t.Errorf("Should not return an object %v", hd)
+		}
+	}
+
+}
+
+func Test_ValidateHostProcessDeployment(t *testing.T) {
+
+	hd := HostProcessDeploymentConfig{Binary: "f0VMRgIBAQ==", User: "myservice", MaxCPUs: 0.5}
+	if err := hd.Validate(); err != nil {
+		t.Errorf("unexpected error validating %v: %v", hd, err)
+	}
+
+	hd.User = "root"
+	if err := hd.Validate(); err == nil {
+		t.Errorf("Should be an error returned for %v", hd)
+	}
+
+	hd.User = ""
+	hd.MaxMemoryMb = -1
+	if err := hd.Validate(); err == nil {
+		t.Errorf("Should be an error returned for %v", hd)
+	}
+
+	hd.MaxMemoryMb = 0
+	hd.RestartPolicy = &containermessage.RestartPolicy{Name: "sometimes"}
+	if err := hd.Validate(); err == nil {
+		t.Errorf("Should be an error returned for %v", hd)
+	}
+
+}
+
+func Test_HostProcessPersistToFrom(t *testing.T) {
+
+	hd := HostProcessDeploymentConfig{
+		Binary:        "f0VMRgIBAQ==",
+		Args:          []string{"a", "b"},
+		MaxMemoryMb:   64,
+		RestartPolicy: &containermessage.RestartPolicy{Name: containermessage.RESTART_POLICY_ON_FAILURE, MaxRetries: 3},
+	}
+
+	// Convert the object to persistent form.
+	if pf, err := hd.ToPersistentForm(); err != nil {
+		t.Errorf("unexpected error changing to persistent form: %v", err)
+	} else if !IsHostProcess(pf) || IsWasm(pf) || IsHelm(pf) || IsKube(pf) {
+		t.Errorf("persistent form not as expected, is: %v", pf)
+	} else {
+
+		// Now change it back to non-persistent form.
+		nhd := HostProcessDeploymentConfig{}
+		if err := nhd.FromPersistentForm(pf); err != nil {
+			t.Errorf("unexpected error changing from persistent form: %v", err)
+		} else if hd.Binary != nhd.Binary || len(nhd.Args) != 2 || hd.MaxMemoryMb != nhd.MaxMemoryMb || nhd.RestartPolicy == nil || nhd.RestartPolicy.MaxRetries != 3 {
+			t.Errorf("object from persistent form: %v doesnt match original: %v", nhd, hd)
+		}
+
+	}
+
+}
@@ -1151,6 +1151,15 @@ func ResetMsInstanceExecutionStatus(db *bolt.DB, key string) (*MicroserviceInsta
 	})
 }
 
+// Called when the retry of a failed service instance is delayed. The instance is no longer considered started so that
+// its containers are not checked again until the retry, but the failure is kept.
+func MSInstanceRetryPending(db *bolt.DB, key string) (*MicroserviceInstance, error) {
+	return microserviceInstanceStateUpdate(db, key, func(c MicroserviceInstance) *MicroserviceInstance {
+		c.ExecutionStartTime = 0
+		return &c
+	})
+}
+
 // update the micorserive instance
 func microserviceInstanceStateUpdate(db *bolt.DB, key string, fn func(MicroserviceInstance) *MicroserviceInstance) (*MicroserviceInstance, error) {
 
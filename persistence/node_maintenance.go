@@ -0,0 +1,90 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"time"
+)
+
+// node maintenance table name
+const NODE_MAINTENANCE = "node_maintenance"
+
+// The maintenance mode of the node, set by the node owner for a planned servicing window. While the node is in
+// maintenance mode, the agent does not accept new agreements. When Drain is set, the existing agreements were
+// cancelled when the maintenance mode was turned on.
+type NodeMaintenance struct {
+	Drain     bool   `json:"drain"`
+	StartTime uint64 `json:"start_time"`
+}
+
+func NewNodeMaintenance(drain bool) *NodeMaintenance {
+	return &NodeMaintenance{
+		Drain:     drain,
+		StartTime: uint64(time.Now().Unix()),
+	}
+}
+
+func (m NodeMaintenance) String() string {
+	return fmt.Sprintf("Drain: %v, "+
+		"StartTime: %v",
+		m.Drain, m.StartTime)
+}
+
+// save the node maintenance mode into db.
+func SaveNodeMaintenance(db *bolt.DB, maintenance *NodeMaintenance) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if bucket, err := tx.CreateBucketIfNotExists([]byte(NODE_MAINTENANCE)); err != nil {
+			return err
+		} else if serial, err := json.Marshal(*maintenance); err != nil {
+			return fmt.Errorf("Failed to serialize the node maintenance object: %v. Error: %v", *maintenance, err)
+		} else {
+			return bucket.Put([]byte(NODE_MAINTENANCE), serial)
+		}
+	})
+}
+
+// find the node maintenance mode, nil if the node is not in maintenance mode.
+func FindNodeMaintenance(db *bolt.DB) (*NodeMaintenance, error) {
+	var maintenance *NodeMaintenance
+
+	readErr := db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(NODE_MAINTENANCE)); b != nil {
+			if v := b.Get([]byte(NODE_MAINTENANCE)); v != nil {
+				var m NodeMaintenance
+				if err := json.Unmarshal(v, &m); err != nil {
+					return fmt.Errorf("Unable to deserialize NodeMaintenance db record: %v. Error: %v", string(v), err)
+				}
+				maintenance = &m
+			}
+		}
+		return nil // end the transaction
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return maintenance, nil
+}
+
+// delete the node maintenance mode from the db, when it is turned off or the node is unregistered.
+func DeleteNodeMaintenance(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(NODE_MAINTENANCE)) != nil {
+			return tx.DeleteBucket([]byte(NODE_MAINTENANCE))
+		}
+		return nil
+	})
+}
+
+// returns true if the node is in maintenance mode. Errors reading the db are logged and the node is considered
+// not in maintenance mode.
+func IsNodeInMaintenance(db *bolt.DB) bool {
+	if maintenance, err := FindNodeMaintenance(db); err != nil {
+		glog.Errorf("Unable to read the node maintenance mode from the db. Error: %v", err)
+		return false
+	} else {
+		return maintenance != nil
+	}
+}
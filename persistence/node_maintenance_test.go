@@ -0,0 +1,46 @@
+//go:build unit
+// +build unit
+
+package persistence
+
+import (
+	"testing"
+)
+
+// Verify that the node maintenance mode is kept until it is turned off.
+func Test_NodeMaintenance(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	if IsNodeInMaintenance(db) {
+		t.Errorf("node should not be in maintenance mode")
+	} else if err := DeleteNodeMaintenance(db); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	}
+
+	if err := SaveNodeMaintenance(db, NewNodeMaintenance(false)); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if err := SaveNodeMaintenance(db, NewNodeMaintenance(true)); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	}
+
+	if m, err := FindNodeMaintenance(db); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if m == nil || !m.Drain || m.StartTime == 0 {
+		t.Errorf("wrong node maintenance %v", m)
+	} else if !IsNodeInMaintenance(db) {
+		t.Errorf("node should be in maintenance mode")
+	}
+
+	if err := DeleteNodeMaintenance(db); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if m, err := FindNodeMaintenance(db); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if m != nil {
+		t.Errorf("node should not be in maintenance mode, but got %v", m)
+	}
+}
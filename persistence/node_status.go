@@ -17,13 +17,15 @@ type WorkloadStatus struct {
 	Containers     []ContainerStatus `json:"containerStatus"`
 	OperatorStatus interface{}       `json:"operatorStatus,omitempty"`
 	ConfigState    string            `json:"configState,omitempty"`
+	Paused         bool              `json:"paused,omitempty"`
 }
 
 type ContainerStatus struct {
-	Name    string `json:"name"`
-	Image   string `json:"image"`
-	Created int64  `json:"created"`
-	State   string `json:"state"`
+	Name        string `json:"name"`
+	Image       string `json:"image"`
+	ImageDigest string `json:"imageDigest,omitempty"`
+	Created     int64  `json:"created"`
+	State       string `json:"state"`
 }
 
 // FindNodeStatus returns the node status currently in the local db
@@ -0,0 +1,134 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"time"
+)
+
+// paused service table name
+const PAUSED_SERVICES = "paused_services"
+
+// A service that is paused by the node owner. The containers of the service are stopped (or its operator deployment is
+// scaled to zero on a cluster) but the agreements of the service are kept, until the service is resumed.
+type PausedService struct {
+	Org        string `json:"org"`
+	URL        string `json:"url"`
+	PausedTime uint64 `json:"paused_time"`
+}
+
+func NewPausedService(org string, url string) *PausedService {
+	return &PausedService{
+		Org:        org,
+		URL:        url,
+		PausedTime: uint64(time.Now().Unix()),
+	}
+}
+
+func (p PausedService) String() string {
+	return fmt.Sprintf("Org: %v, "+
+		"URL: %v, "+
+		"PausedTime: %v",
+		p.Org, p.URL, p.PausedTime)
+}
+
+func pausedServiceKey(org string, url string) string {
+	return fmt.Sprintf("%v/%v", org, url)
+}
+
+// save the paused service into db, keeping the pause time when the service is already paused.
+func SavePausedService(db *bolt.DB, paused *PausedService) error {
+	key := pausedServiceKey(paused.Org, paused.URL)
+	return db.Update(func(tx *bolt.Tx) error {
+		if bucket, err := tx.CreateBucketIfNotExists([]byte(PAUSED_SERVICES)); err != nil {
+			return err
+		} else if bucket.Get([]byte(key)) != nil {
+			return nil
+		} else if serial, err := json.Marshal(*paused); err != nil {
+			return fmt.Errorf("Failed to serialize the paused service object: %v. Error: %v", *paused, err)
+		} else {
+			return bucket.Put([]byte(key), serial)
+		}
+	})
+}
+
+// delete the paused service with the given org and url from the db, when the service is resumed.
+func DeletePausedService(db *bolt.DB, org string, url string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket([]byte(PAUSED_SERVICES)); bucket != nil {
+			return bucket.Delete([]byte(pausedServiceKey(org, url)))
+		}
+		return nil
+	})
+}
+
+// find the paused service with the given org and url, nil if the service is not paused.
+func FindPausedService(db *bolt.DB, org string, url string) (*PausedService, error) {
+	var paused *PausedService
+
+	readErr := db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(PAUSED_SERVICES)); b != nil {
+			if v := b.Get([]byte(pausedServiceKey(org, url))); v != nil {
+				var p PausedService
+				if err := json.Unmarshal(v, &p); err != nil {
+					return fmt.Errorf("Unable to deserialize PausedService db record: %v. Error: %v", string(v), err)
+				}
+				paused = &p
+			}
+		}
+		return nil // end the transaction
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return paused, nil
+}
+
+// find all the paused services in the db.
+func FindPausedServices(db *bolt.DB) ([]PausedService, error) {
+	paused := make([]PausedService, 0)
+
+	readErr := db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(PAUSED_SERVICES)); b != nil {
+			b.ForEach(func(k, v []byte) error {
+				var p PausedService
+				if err := json.Unmarshal(v, &p); err != nil {
+					glog.Errorf("Unable to deserialize PausedService db record: %v. Error: %v", string(v), err)
+				} else {
+					paused = append(paused, p)
+				}
+				return nil
+			})
+		}
+		return nil // end the transaction
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return paused, nil
+}
+
+// returns true if the service with the given org and url is paused. Errors reading the db are logged and the
+// service is considered not paused.
+func IsServicePaused(db *bolt.DB, org string, url string) bool {
+	if paused, err := FindPausedService(db, org, url); err != nil {
+		glog.Errorf("Unable to read the paused service %v from the db. Error: %v", pausedServiceKey(org, url), err)
+		return false
+	} else {
+		return paused != nil
+	}
+}
+
+// delete all the paused services from the db, when the node is unregistered.
+func DeletePausedServices(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(PAUSED_SERVICES)) != nil {
+			return tx.DeleteBucket([]byte(PAUSED_SERVICES))
+		}
+		return nil
+	})
+}
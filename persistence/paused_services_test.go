@@ -0,0 +1,58 @@
+//go:build unit
+// +build unit
+
+package persistence
+
+import (
+	"testing"
+)
+
+// Verify that a service is paused until it is resumed, and that pausing it again keeps the original pause time.
+func Test_PausedService(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	if IsServicePaused(db, "myorg", "mysvc") {
+		t.Errorf("service should not be paused")
+	}
+
+	p := NewPausedService("myorg", "mysvc")
+	p.PausedTime = 100
+	if err := SavePausedService(db, p); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if err := SavePausedService(db, NewPausedService("myorg", "mysvc")); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if err := SavePausedService(db, NewPausedService("otherorg", "mysvc")); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	}
+
+	if paused, err := FindPausedService(db, "myorg", "mysvc"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if paused == nil || paused.PausedTime != 100 {
+		t.Errorf("wrong paused service %v", paused)
+	} else if all, err := FindPausedServices(db); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(all) != 2 {
+		t.Errorf("there should be 2 paused services, but got %v", all)
+	}
+
+	if err := DeletePausedService(db, "myorg", "mysvc"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if IsServicePaused(db, "myorg", "mysvc") {
+		t.Errorf("service should be resumed")
+	} else if !IsServicePaused(db, "otherorg", "mysvc") {
+		t.Errorf("service in the other org should still be paused")
+	}
+
+	if err := DeletePausedServices(db); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if all, err := FindPausedServices(db); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(all) != 0 {
+		t.Errorf("there should be no paused services, but got %v", all)
+	}
+}
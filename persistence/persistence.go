@@ -86,9 +86,10 @@ type EstablishedAgreement struct {
 	BlockchainOrg                   string                   `json:"blockchain_org,omitempty"`        // the org of the blockchain instance
 	RunningWorkload                 WorkloadInfo             `json:"workload_to_run,omitempty"`       // For display purposes, a copy of the workload info that this agreement is managing. It should be the same info that is buried inside the proposal.
 	AgreementTimeout                uint64                   `json:"agreement_timeout"`
-	ServiceDefId                    string                   `json:"service_definition_id"`         // stores the microservice definiton id
-	FailedVerAttempts               uint64                   `json:"failed_verification_attempts"`  // number of times a agreementverify has failed for this agreement
-	LastVerAttemptUpdateTime        uint64                   `json:"last_verification_update_time"` // time the FailedVerAttempts field was last updated
+	ServiceDefId                    string                   `json:"service_definition_id"`            // stores the microservice definiton id
+	FailedVerAttempts               uint64                   `json:"failed_verification_attempts"`     // number of times a agreementverify has failed for this agreement
+	LastVerAttemptUpdateTime        uint64                   `json:"last_verification_update_time"`    // time the FailedVerAttempts field was last updated
+	AgreementPreemptTime            uint64                   `json:"agreement_preempt_time,omitempty"` // time when the agreement will be cancelled to make room for a higher priority deployment policy
 }
 
 func (c EstablishedAgreement) String() string {
@@ -123,14 +124,15 @@ func (c EstablishedAgreement) String() string {
 		"RunningWorkload: %v, "+
 		"AgreementTimeout: %v, "+
 		"ServiceDefId: %v, "+
-		"FailedVerAttempts: %v",
-		"LastVerAttemptUpdateTime: %v",
+		"FailedVerAttempts: %v, "+
+		"LastVerAttemptUpdateTime: %v, "+
+		"AgreementPreemptTime: %v",
 		c.Name, c.DependentServices, c.Archived, c.CurrentAgreementId, c.ConsumerId, c.CounterPartyAddress, ServiceConfigNames(&c.CurrentDeployment),
 		"********", c.ProposalSig,
 		c.AgreementCreationTime, c.AgreementExecutionStartTime, c.AgreementAcceptedTime, c.AgreementBCUpdateAckTime, c.AgreementFinalizedTime,
 		c.AgreementDataReceivedTime, c.AgreementTerminatedTime, c.AgreementForceTerminatedTime, c.TerminatedReason, c.TerminatedDescription,
 		c.AgreementProtocol, c.ProtocolVersion, c.AgreementProtocolTerminatedTime, c.WorkloadTerminatedTime,
-		c.MeteringNotificationMsg, c.BlockchainType, c.BlockchainName, c.BlockchainOrg, c.RunningWorkload, c.AgreementTimeout, c.ServiceDefId, c.FailedVerAttempts, c.LastVerAttemptUpdateTime)
+		c.MeteringNotificationMsg, c.BlockchainType, c.BlockchainName, c.BlockchainOrg, c.RunningWorkload, c.AgreementTimeout, c.ServiceDefId, c.FailedVerAttempts, c.LastVerAttemptUpdateTime, c.AgreementPreemptTime)
 
 }
 
@@ -184,7 +186,7 @@ func NewEstablishedAgreement(db *bolt.DB, name string, agreementId string, consu
 		FailedVerAttempts:               0,
 	}
 
-	return newAg, db.Update(func(tx *bolt.Tx) error {
+	err := db.Update(func(tx *bolt.Tx) error {
 
 		if b, err := tx.CreateBucketIfNotExists([]byte(E_AGREEMENTS + "-" + protocol)); err != nil {
 			return err
@@ -197,6 +199,11 @@ func NewEstablishedAgreement(db *bolt.DB, name string, agreementId string, consu
 		// success, close tx
 		return nil
 	})
+
+	if err == nil {
+		recordAgreementHistory(db, agreementId, protocol, NewAgreementHistoryEntry(AG_HISTORY_AGREEMENT_CREATED, consumerId, ""))
+	}
+	return newAg, err
 }
 
 func (c *EstablishedAgreement) ShortString() string {
@@ -336,7 +343,7 @@ func (a *EstablishedAgreement) GetDeploymentConfig() DeploymentConfig {
 		nd.Services = a.CurrentDeployment
 		return nd
 
-		// The extended deployment config must be in use, so return it. It could be kube or helm.
+		// The extended deployment config must be in use, so return it. It could be kube, helm, wasm or a host process.
 	} else if IsKube(a.ExtendedDeployment) {
 		cd := new(KubeDeploymentConfig)
 		if err := cd.FromPersistentForm(a.ExtendedDeployment); err != nil {
@@ -349,33 +356,57 @@ func (a *EstablishedAgreement) GetDeploymentConfig() DeploymentConfig {
 			glog.Errorf("Unable to convert helm deployment %v to persistent form, error %v", a.ExtendedDeployment, err)
 		}
 		return hd
+	} else if IsWasm(a.ExtendedDeployment) {
+		wd := new(WasmDeploymentConfig)
+		if err := wd.FromPersistentForm(a.ExtendedDeployment); err != nil {
+			glog.Errorf("Unable to convert wasm deployment %v to persistent form, error %v", a.ExtendedDeployment, err)
+		}
+		return wd
+	} else if IsHostProcess(a.ExtendedDeployment) {
+		hd := new(HostProcessDeploymentConfig)
+		if err := hd.FromPersistentForm(a.ExtendedDeployment); err != nil {
+			glog.Errorf("Unable to convert host process deployment %v to persistent form, error %v", a.ExtendedDeployment, err)
+		}
+		return hd
 	}
 
 	return nil
 }
 
 func ArchiveEstablishedAgreement(db *bolt.DB, agreementId string, protocol string) (*EstablishedAgreement, error) {
-	return agreementStateUpdate(db, agreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
+	ag, err := agreementStateUpdate(db, agreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
 		c.Archived = true
 		c.CurrentDeployment = map[string]ServiceConfig{}
 		return &c
 	})
+	if err == nil {
+		recordAgreementHistory(db, agreementId, protocol, NewAgreementHistoryEntry(AG_HISTORY_AGREEMENT_ARCHIVED, "", ""))
+	}
+	return ag, err
 }
 
 // set agreement state to execution started
 func AgreementStateExecutionStarted(db *bolt.DB, dbAgreementId string, protocol string) (*EstablishedAgreement, error) {
-	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
+	ag, err := agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
 		c.AgreementExecutionStartTime = uint64(time.Now().Unix())
 		return &c
 	})
+	if err == nil {
+		recordAgreementHistory(db, dbAgreementId, protocol, NewAgreementHistoryEntry(AG_HISTORY_EXECUTION_STARTED, "", ""))
+	}
+	return ag, err
 }
 
 // set agreement state to accepted, a positive reply is being sent
 func AgreementStateAccepted(db *bolt.DB, dbAgreementId string, protocol string) (*EstablishedAgreement, error) {
-	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
+	ag, err := agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
 		c.AgreementAcceptedTime = uint64(time.Now().Unix())
 		return &c
 	})
+	if err == nil {
+		recordAgreementHistory(db, dbAgreementId, protocol, NewAgreementHistoryEntry(AG_HISTORY_AGREEMENT_REACHED, "", ""))
+	}
+	return ag, err
 }
 
 // set the eth signature of the proposal
@@ -404,10 +435,14 @@ func AgreementStateBCUpdateAcked(db *bolt.DB, dbAgreementId string, protocol str
 
 // set agreement state to finalized
 func AgreementStateFinalized(db *bolt.DB, dbAgreementId string, protocol string) (*EstablishedAgreement, error) {
-	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
+	ag, err := agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
 		c.AgreementFinalizedTime = uint64(time.Now().Unix())
 		return &c
 	})
+	if err == nil {
+		recordAgreementHistory(db, dbAgreementId, protocol, NewAgreementHistoryEntry(AG_HISTORY_FINALIZED, "", ""))
+	}
+	return ag, err
 }
 
 // set deployment config because execution is about to begin
@@ -428,12 +463,26 @@ func AgreementDeploymentStarted(db *bolt.DB, dbAgreementId string, protocol stri
 
 // set agreement state to terminated
 func AgreementStateTerminated(db *bolt.DB, dbAgreementId string, reason uint64, reasonString string, protocol string) (*EstablishedAgreement, error) {
-	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
+	ag, err := agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
 		c.AgreementTerminatedTime = uint64(time.Now().Unix())
 		c.TerminatedReason = reason
 		c.TerminatedDescription = reasonString
 		return &c
 	})
+	if err == nil {
+		entry := NewAgreementHistoryEntry(AG_HISTORY_TERMINATED, "", reasonString)
+		entry.ReasonCode = reason
+		recordAgreementHistory(db, dbAgreementId, protocol, entry)
+	}
+	return ag, err
+}
+
+// set the time when the agreement will be preempted by a higher priority deployment policy
+func AgreementStatePreempting(db *bolt.DB, dbAgreementId string, protocol string, preemptTime uint64) (*EstablishedAgreement, error) {
+	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
+		c.AgreementPreemptTime = preemptTime
+		return &c
+	})
 }
 
 // reset agreement state to not-terminated so that we can retry the termination
@@ -526,7 +575,7 @@ func DeleteEstablishedAgreement(db *bolt.DB, agreementId string, protocol string
 			return fmt.Errorf("Expecting 1 records with id: %v, found %v", agreementId, agreements)
 		} else {
 
-			return db.Update(func(tx *bolt.Tx) error {
+			err := db.Update(func(tx *bolt.Tx) error {
 
 				if b, err := tx.CreateBucketIfNotExists([]byte(E_AGREEMENTS + "-" + protocol)); err != nil {
 					return err
@@ -536,6 +585,10 @@ func DeleteEstablishedAgreement(db *bolt.DB, agreementId string, protocol string
 					return nil
 				}
 			})
+			if err == nil {
+				recordAgreementHistory(db, agreementId, protocol, NewAgreementHistoryEntry(AG_HISTORY_AGREEMENT_DELETED, "", ""))
+			}
+			return err
 		}
 	}
 }
@@ -622,6 +675,9 @@ func persistUpdatedAgreement(db *bolt.DB, dbAgreementId string, protocol string,
 				if mod.WorkloadTerminatedTime == 0 { // 1 transition from zero to non-zero
 					mod.WorkloadTerminatedTime = update.WorkloadTerminatedTime
 				}
+				if mod.AgreementPreemptTime == 0 { // 1 transition from zero to non-zero
+					mod.AgreementPreemptTime = update.AgreementPreemptTime
+				}
 				if update.MeteringNotificationMsg != (MeteringNotification{}) { // only save non-empty values
 					mod.MeteringNotificationMsg = update.MeteringNotificationMsg
 				}
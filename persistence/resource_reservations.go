@@ -0,0 +1,104 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"time"
+)
+
+// resource reservation table name
+const RESOURCE_RESERVATIONS = "resource_reservations"
+
+// The memory, CPU and GPU limits of the containers of an agreement or a service instance. The limits are reserved on the
+// device while the containers exist, so that the node does not accept more work than it can run.
+type ResourceReservation struct {
+	Key          string  `json:"key"`            // the agreement id or the service instance key
+	MemoryMb     int64   `json:"memory_mb"`      // the sum of the memory limits of the containers
+	CPUs         float64 `json:"cpus"`           // the sum of the CPU limits of the containers
+	GPUs         int     `json:"gpus,omitempty"` // the number of GPUs the containers can use, -1 for all of them
+	CreationTime uint64  `json:"creation_time"`
+}
+
+func NewResourceReservation(key string, memoryMb int64, cpus float64, gpus int) *ResourceReservation {
+	return &ResourceReservation{
+		Key:          key,
+		MemoryMb:     memoryMb,
+		CPUs:         cpus,
+		GPUs:         gpus,
+		CreationTime: uint64(time.Now().Unix()),
+	}
+}
+
+func (r ResourceReservation) String() string {
+	return fmt.Sprintf("Key: %v, "+
+		"MemoryMb: %v, "+
+		"CPUs: %v, "+
+		"GPUs: %v, "+
+		"CreationTime: %v",
+		r.Key, r.MemoryMb, r.CPUs, r.GPUs, r.CreationTime)
+}
+
+// save the resource reservation into db, replacing the previous one for the same key.
+func SaveResourceReservation(db *bolt.DB, reservation *ResourceReservation) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if bucket, err := tx.CreateBucketIfNotExists([]byte(RESOURCE_RESERVATIONS)); err != nil {
+			return err
+		} else if serial, err := json.Marshal(*reservation); err != nil {
+			return fmt.Errorf("Failed to serialize the resource reservation object: %v. Error: %v", *reservation, err)
+		} else {
+			return bucket.Put([]byte(reservation.Key), serial)
+		}
+	})
+}
+
+// delete the resource reservation of the given agreement id or service instance key from the db.
+func DeleteResourceReservation(db *bolt.DB, key string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket([]byte(RESOURCE_RESERVATIONS)); bucket != nil {
+			return bucket.Delete([]byte(key))
+		}
+		return nil
+	})
+}
+
+// find all the resource reservations in the db.
+func FindResourceReservations(db *bolt.DB) ([]ResourceReservation, error) {
+	reservations := make([]ResourceReservation, 0)
+
+	readErr := db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(RESOURCE_RESERVATIONS)); b != nil {
+			b.ForEach(func(k, v []byte) error {
+				var r ResourceReservation
+				if err := json.Unmarshal(v, &r); err != nil {
+					glog.Errorf("Unable to deserialize ResourceReservation db record: %v. Error: %v", v, err)
+				} else {
+					reservations = append(reservations, r)
+				}
+				return nil
+			})
+		}
+		return nil // end the transaction
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return reservations, nil
+}
+
+// Returns the memory, in MB, and the CPUs reserved by all the agreements and service instances on the device.
+func TotalResourceReservations(db *bolt.DB) (int64, float64, error) {
+	memoryMb := int64(0)
+	cpus := float64(0)
+	if reservations, err := FindResourceReservations(db); err != nil {
+		return 0, 0, err
+	} else {
+		for _, r := range reservations {
+			memoryMb += r.MemoryMb
+			cpus += r.CPUs
+		}
+	}
+	return memoryMb, cpus, nil
+}
@@ -0,0 +1,44 @@
+//go:build unit
+// +build unit
+
+package persistence
+
+import (
+	"testing"
+)
+
+// Verify that the reservations are summed up and that a reservation is replaced or released by its key.
+func Test_TotalResourceReservations(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	if mem, cpus, err := TotalResourceReservations(db); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if mem != 0 || cpus != 0 {
+		t.Errorf("expected no reservations, got %v %v", mem, cpus)
+	}
+
+	for _, r := range []*ResourceReservation{NewResourceReservation("ag1", 256, 0.5, 0), NewResourceReservation("ag2", 512, 0, 1), NewResourceReservation("ag1", 128, 1, 0)} {
+		if err := SaveResourceReservation(db, r); err != nil {
+			t.Errorf("should not return error, but got %v", err)
+		}
+	}
+
+	if mem, cpus, err := TotalResourceReservations(db); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if mem != 640 || cpus != 1 {
+		t.Errorf("wrong reservations %v %v", mem, cpus)
+	}
+
+	if err := DeleteResourceReservation(db, "ag2"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if reservations, err := FindResourceReservations(db); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(reservations) != 1 || reservations[0].Key != "ag1" || reservations[0].MemoryMb != 128 {
+		t.Errorf("wrong reservations %v", reservations)
+	}
+}
@@ -99,6 +99,30 @@ func FindAgreementSecrets(db *bolt.DB, agId string) (*[]PersistedServiceSecret,
 	return psecretRec, readErr
 }
 
+// Replace the values of the agreement secrets that match the updated secrets. Returns true if any secret was updated.
+func UpdateAgreementSecrets(db *bolt.DB, agId string, updatedSecrets []PersistedServiceSecret) (bool, error) {
+	agSecrets, err := FindAgreementSecrets(db, agId)
+	if err != nil || agSecrets == nil {
+		return false, err
+	}
+
+	updated := false
+	for ix, agSec := range *agSecrets {
+		for _, updatedSec := range updatedSecrets {
+			if agSec.SvcOrgid == updatedSec.SvcOrgid && agSec.SvcUrl == updatedSec.SvcUrl && agSec.SvcSecretName == updatedSec.SvcSecretName && agSec.SvcSecretValue != updatedSec.SvcSecretValue {
+				(*agSecrets)[ix].SvcSecretValue = updatedSec.SvcSecretValue
+				(*agSecrets)[ix].TimeLastUpdated = uint64(time.Now().Unix())
+				updated = true
+			}
+		}
+	}
+
+	if updated {
+		return true, SaveAgreementSecrets(db, agId, agSecrets)
+	}
+	return false, nil
+}
+
 func DeleteAgreementSecrets(db *bolt.DB, agId string) error {
 	if db == nil {
 		return nil
@@ -0,0 +1,62 @@
+//go:build unit
+// +build unit
+
+package persistence
+
+import (
+	"testing"
+)
+
+// Verify that rotated secrets replace the values saved with the agreement and other secrets are left alone.
+func Test_UpdateAgreementSecrets(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	agId := "ag1"
+	secrets := []PersistedServiceSecret{
+		{SvcOrgid: "myorg", SvcUrl: "svc1", SvcSecretName: "sec1", SvcSecretValue: "dmFsMQ==", AgreementIds: []string{agId}},
+		{SvcOrgid: "myorg", SvcUrl: "svc1", SvcSecretName: "sec2", SvcSecretValue: "dmFsMg==", AgreementIds: []string{agId}},
+	}
+	if err := SaveAgreementSecrets(db, agId, &secrets); err != nil {
+		t.Errorf("failed to save agreement secrets, error %v", err)
+	}
+
+	// no agreement secrets for this agreement
+	if updated, err := UpdateAgreementSecrets(db, "ag2", secrets); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if updated {
+		t.Errorf("should not have updated secrets for an unknown agreement")
+	}
+
+	rotated := []PersistedServiceSecret{{SvcOrgid: "myorg", SvcUrl: "svc1", SvcSecretName: "sec2", SvcSecretValue: "bmV3dmFs"}}
+	if updated, err := UpdateAgreementSecrets(db, agId, rotated); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if !updated {
+		t.Errorf("should have updated the secret")
+	}
+
+	if agSecrets, err := FindAgreementSecrets(db, agId); err != nil {
+		t.Errorf("failed to find agreement secrets, error %v", err)
+	} else if agSecrets == nil || len(*agSecrets) != 2 {
+		t.Errorf("expected 2 agreement secrets, got %v", agSecrets)
+	} else {
+		for _, sec := range *agSecrets {
+			if sec.SvcSecretName == "sec1" && sec.SvcSecretValue != "dmFsMQ==" {
+				t.Errorf("secret sec1 should not have changed, got %v", sec.SvcSecretValue)
+			} else if sec.SvcSecretName == "sec2" && (sec.SvcSecretValue != "bmV3dmFs" || sec.TimeLastUpdated == 0) {
+				t.Errorf("secret sec2 should have been updated, got %v", sec)
+			}
+		}
+	}
+
+	// same value again is not an update
+	if updated, err := UpdateAgreementSecrets(db, agId, rotated); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if updated {
+		t.Errorf("should not have updated a secret with the same value")
+	}
+}
@@ -0,0 +1,163 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"time"
+)
+
+// service cache table name
+const SERVICE_CACHE = "service_cache"
+
+// A service definition resolved from the exchange, with the keys its deployment is signed with and the digests of the
+// images it was last run with. The cached services let the node verify and restart its services while the exchange
+// cannot be reached.
+type CachedService struct {
+	Key          string            `json:"key"`        // org/url_version_arch
+	ServiceId    string            `json:"service_id"` // the id of the service in the exchange
+	Org          string            `json:"org"`
+	URL          string            `json:"url"`
+	Version      string            `json:"version"`
+	Arch         string            `json:"arch"`
+	Definition   string            `json:"definition"`              // the service definition from the exchange, in JSON, including the deployment string and its signature
+	SigningKeys  map[string]string `json:"signing_keys,omitempty"`  // the signing keys of the service from the exchange, keyed by name
+	ImageDigests map[string]string `json:"image_digests,omitempty"` // the images of the service referenced by digest, keyed by the name of the service in the deployment
+	LastUpdated  uint64            `json:"last_updated"`
+}
+
+func (c CachedService) String() string {
+	return fmt.Sprintf("Key: %v, "+
+		"ServiceId: %v, "+
+		"SigningKeys: %v, "+
+		"ImageDigests: %v, "+
+		"LastUpdated: %v",
+		c.Key, c.ServiceId, len(c.SigningKeys), c.ImageDigests, c.LastUpdated)
+}
+
+// Returns the key of the cached service with the given org, url, version and arch.
+func CachedServiceKey(org string, url string, version string, arch string) string {
+	return fmt.Sprintf("%v/%v_%v_%v", org, url, version, arch)
+}
+
+// save the service definition into the cache, keeping the signing keys and the image digests of the cached service.
+func SaveCachedServiceDefinition(db *bolt.DB, serviceId string, org string, url string, version string, arch string, definition string) error {
+	return updateCachedService(db, org, url, version, arch, func(c *CachedService) {
+		c.ServiceId = serviceId
+		c.Definition = definition
+	})
+}
+
+// save the signing keys of a service into the cache.
+func SaveCachedServiceSigningKeys(db *bolt.DB, org string, url string, version string, arch string, keys map[string]string) error {
+	return updateCachedService(db, org, url, version, arch, func(c *CachedService) {
+		c.SigningKeys = keys
+	})
+}
+
+// save the digest references of the images of a service into the cache, replacing the ones of the same deployment services.
+func SaveCachedServiceImageDigests(db *bolt.DB, org string, url string, version string, arch string, digests map[string]string) error {
+	return updateCachedService(db, org, url, version, arch, func(c *CachedService) {
+		if c.ImageDigests == nil {
+			c.ImageDigests = make(map[string]string)
+		}
+		for name, image := range digests {
+			c.ImageDigests[name] = image
+		}
+	})
+}
+
+func updateCachedService(db *bolt.DB, org string, url string, version string, arch string, update func(c *CachedService)) error {
+	key := CachedServiceKey(org, url, version, arch)
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(SERVICE_CACHE))
+		if err != nil {
+			return err
+		}
+
+		c := CachedService{Key: key, Org: org, URL: url, Version: version, Arch: arch}
+		if v := bucket.Get([]byte(key)); v != nil {
+			if err := json.Unmarshal(v, &c); err != nil {
+				glog.Errorf("Unable to deserialize CachedService db record: %v, replacing it. Error: %v", string(v), err)
+			}
+		}
+
+		update(&c)
+		c.LastUpdated = uint64(time.Now().Unix())
+		if serial, err := json.Marshal(c); err != nil {
+			return fmt.Errorf("Failed to serialize the cached service object: %v. Error: %v", c, err)
+		} else {
+			return bucket.Put([]byte(key), serial)
+		}
+	})
+}
+
+// find the cached service with the given org, url, version and arch, nil if the service is not cached.
+func FindCachedService(db *bolt.DB, org string, url string, version string, arch string) (*CachedService, error) {
+	var cached *CachedService
+	key := CachedServiceKey(org, url, version, arch)
+
+	readErr := db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(SERVICE_CACHE)); b != nil {
+			if v := b.Get([]byte(key)); v != nil {
+				var c CachedService
+				if err := json.Unmarshal(v, &c); err != nil {
+					return fmt.Errorf("Unable to deserialize CachedService db record: %v. Error: %v", string(v), err)
+				}
+				cached = &c
+			}
+		}
+		return nil // end the transaction
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return cached, nil
+}
+
+// find all the cached versions of the service with the given org, url and arch.
+func FindCachedServiceVersions(db *bolt.DB, org string, url string, arch string) ([]CachedService, error) {
+	cached := make([]CachedService, 0)
+
+	readErr := db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(SERVICE_CACHE)); b != nil {
+			b.ForEach(func(k, v []byte) error {
+				var c CachedService
+				if err := json.Unmarshal(v, &c); err != nil {
+					glog.Errorf("Unable to deserialize CachedService db record: %v. Error: %v", string(v), err)
+				} else if c.Org == org && c.URL == url && c.Arch == arch {
+					cached = append(cached, c)
+				}
+				return nil
+			})
+		}
+		return nil // end the transaction
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return cached, nil
+}
+
+// delete the cached service with the given org, url, version and arch from the db.
+func DeleteCachedService(db *bolt.DB, org string, url string, version string, arch string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket([]byte(SERVICE_CACHE)); bucket != nil {
+			return bucket.Delete([]byte(CachedServiceKey(org, url, version, arch)))
+		}
+		return nil
+	})
+}
+
+// delete all the cached services from the db, when the node is unregistered.
+func DeleteServiceCache(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(SERVICE_CACHE)) != nil {
+			return tx.DeleteBucket([]byte(SERVICE_CACHE))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,60 @@
+//go:build unit
+// +build unit
+
+package persistence
+
+import (
+	"testing"
+)
+
+// Verify that the definition, the signing keys and the image digests of a cached service are saved independently.
+func Test_CachedService(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	if c, err := FindCachedService(db, "myorg", "mysvc", "1.0.0", "amd64"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if c != nil {
+		t.Errorf("service should not be cached, but got %v", c)
+	}
+
+	if err := SaveCachedServiceSigningKeys(db, "myorg", "mysvc", "1.0.0", "amd64", map[string]string{"key1.pem": "content"}); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if err := SaveCachedServiceDefinition(db, "myorg/mysvc_1.0.0_amd64", "myorg", "mysvc", "1.0.0", "amd64", `{"url":"mysvc"}`); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if err := SaveCachedServiceImageDigests(db, "myorg", "mysvc", "1.0.0", "amd64", map[string]string{"mysvc": "mysvc@sha256:1234"}); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if err := SaveCachedServiceDefinition(db, "myorg/mysvc_2.0.0_amd64", "myorg", "mysvc", "2.0.0", "amd64", `{"url":"mysvc"}`); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	}
+
+	if c, err := FindCachedService(db, "myorg", "mysvc", "1.0.0", "amd64"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if c == nil {
+		t.Errorf("service should be cached")
+	} else if c.ServiceId != "myorg/mysvc_1.0.0_amd64" || c.Definition != `{"url":"mysvc"}` || c.SigningKeys["key1.pem"] != "content" || c.ImageDigests["mysvc"] != "mysvc@sha256:1234" || c.LastUpdated == 0 {
+		t.Errorf("wrong cached service %v", c)
+	}
+
+	if cs, err := FindCachedServiceVersions(db, "myorg", "mysvc", "amd64"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(cs) != 2 {
+		t.Errorf("there should be 2 cached versions, but got %v", cs)
+	} else if cs, err := FindCachedServiceVersions(db, "myorg", "mysvc", "arm64"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(cs) != 0 {
+		t.Errorf("there should be no cached versions, but got %v", cs)
+	}
+
+	if err := DeleteCachedService(db, "myorg", "mysvc", "1.0.0", "amd64"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if c, err := FindCachedService(db, "myorg", "mysvc", "1.0.0", "amd64"); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if c != nil {
+		t.Errorf("service should be deleted, but got %v", c)
+	}
+}
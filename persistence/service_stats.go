@@ -0,0 +1,80 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+)
+
+const SERVICE_STATS = "service_stats"
+
+// The resource usage of a service container, from the last sample of the container engine or of the kubernetes metrics.
+// The network and block IO counters are the totals since the container started.
+type ContainerStats struct {
+	Name            string  `json:"name"`
+	CPUPercent      float64 `json:"cpu_percent"`  // 100 is one full CPU
+	MemoryUsage     uint64  `json:"memory_usage"` // bytes, without the page cache that can be reclaimed
+	MemoryLimit     uint64  `json:"memory_limit,omitempty"`
+	NetworkRxBytes  uint64  `json:"network_rx_bytes"`
+	NetworkTxBytes  uint64  `json:"network_tx_bytes"`
+	BlockReadBytes  uint64  `json:"block_read_bytes"`
+	BlockWriteBytes uint64  `json:"block_write_bytes"`
+}
+
+func (c ContainerStats) String() string {
+	return fmt.Sprintf("Name: %v, CPUPercent: %v, MemoryUsage: %v, MemoryLimit: %v, NetworkRxBytes: %v, NetworkTxBytes: %v, BlockReadBytes: %v, BlockWriteBytes: %v",
+		c.Name, c.CPUPercent, c.MemoryUsage, c.MemoryLimit, c.NetworkRxBytes, c.NetworkTxBytes, c.BlockReadBytes, c.BlockWriteBytes)
+}
+
+// The resource usage of the containers of a service instance.
+type ServiceStats struct {
+	InstanceId string           `json:"instance_id"` // the agreement id of a top level service, or the key of a service instance
+	ServiceURL string           `json:"service_url"`
+	Org        string           `json:"org"`
+	Version    string           `json:"version"`
+	Arch       string           `json:"arch"`
+	Containers []ContainerStats `json:"containers"`
+	Collected  int64            `json:"collected"` // the time the stats were collected, in seconds since the epoch
+}
+
+func (s ServiceStats) String() string {
+	return fmt.Sprintf("InstanceId: %v, ServiceURL: %v, Org: %v, Version: %v, Arch: %v, Containers: %v, Collected: %v",
+		s.InstanceId, s.ServiceURL, s.Org, s.Version, s.Arch, s.Containers, s.Collected)
+}
+
+// FindServiceStats returns the last stats collected for the service instances running on the node.
+func FindServiceStats(db *bolt.DB) ([]ServiceStats, error) {
+	stats := make([]ServiceStats, 0)
+
+	readErr := db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(SERVICE_STATS)); b != nil {
+			if v := b.Get([]byte(SERVICE_STATS)); v != nil {
+				if err := json.Unmarshal(v, &stats); err != nil {
+					return fmt.Errorf("Unable to deserialize service stats record: %v", v)
+				}
+			}
+		}
+		return nil // end transaction
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return stats, nil
+}
+
+// SaveServiceStats replaces the stats of the service instances in the local db.
+func SaveServiceStats(db *bolt.DB, stats []ServiceStats) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(SERVICE_STATS))
+		if err != nil {
+			return err
+		}
+
+		if serial, err := json.Marshal(stats); err != nil {
+			return fmt.Errorf("Failed to serialize service stats: %v. Error: %v", stats, err)
+		} else {
+			return b.Put([]byte(SERVICE_STATS), serial)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package persistence
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/open-horizon/anax/containermessage"
+	"strings"
+)
+
+// The structure of the json string in the deployment field of a service definition when the
+// service is a WebAssembly module run by wasmtime on a device without a container engine.
+
+type WasmDeploymentConfig struct {
+	WasmModule    string                          `json:"wasm_module"`              // base64 encoded binary of the WebAssembly (WASI) module
+	Args          []string                        `json:"args,omitempty"`           // The arguments passed to the module after its name
+	Binds         []string                        `json:"binds,omitempty"`          // The host directories the module can access, host_dir:guest_dir
+	MaxMemoryMB   int64                           `json:"max_memory_mb,omitempty"`  // The size the linear memory of the module can grow to
+	RestartPolicy *containermessage.RestartPolicy `json:"restart_policy,omitempty"` // How the module is restarted when it exits, always restarted by default
+}
+
+func NewWasmDeployment(wasmModule string, args []string) *WasmDeploymentConfig {
+	wd := new(WasmDeploymentConfig)
+	wd.WasmModule = wasmModule
+	wd.Args = args
+	return wd
+}
+
+func (w WasmDeploymentConfig) String() string {
+	maxModuleLength := 25
+	if len(w.WasmModule) < maxModuleLength {
+		maxModuleLength = len(w.WasmModule)
+	}
+	return fmt.Sprintf("Module %v, Args %v, Binds %v, MaxMemoryMB %v, RestartPolicy %v", w.WasmModule[:maxModuleLength], w.Args, w.Binds, w.MaxMemoryMB, w.RestartPolicy)
+}
+
+func IsWasm(dep map[string]interface{}) bool {
+	if _, ok := dep["wasm_module"]; ok {
+		return true
+	}
+	return false
+}
+
+// Validate checks the deployment before the module is run, an error is returned if it is not valid.
+func (w *WasmDeploymentConfig) Validate() error {
+	if _, err := base64.StdEncoding.DecodeString(w.WasmModule); err != nil {
+		return errors.New(fmt.Sprintf("wasm_module is not base64 encoded: %v", err))
+	} else if w.MaxMemoryMB < 0 {
+		return errors.New(fmt.Sprintf("max_memory_mb %v cannot be negative", w.MaxMemoryMB))
+	}
+	for _, bind := range w.Binds {
+		if strings.SplitN(bind, ":", 2)[0] == "" {
+			return errors.New(fmt.Sprintf("bind %v has no host directory", bind))
+		}
+	}
+	if w.RestartPolicy != nil {
+		if err := w.RestartPolicy.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Functions that allow WasmDeploymentConfig to support the DeploymentConfig interface.
+
+func (w *WasmDeploymentConfig) IsNative() bool {
+	return false
+}
+
+func (w *WasmDeploymentConfig) ToPersistentForm() (map[string]interface{}, error) {
+	ret := make(map[string]interface{})
+
+	// Marshal to JSON form so that we can unmarshal as a map[string]interface{}.
+	if jBytes, err := json.Marshal(w); err != nil {
+		return ret, errors.New(fmt.Sprintf("error marshalling wasm deployment: %v, error: %v", w, err))
+	} else if err := json.Unmarshal(jBytes, &ret); err != nil {
+		return ret, errors.New(fmt.Sprintf("error unmarshalling wasm deployment: %v, error: %v", string(jBytes), err))
+	}
+
+	return ret, nil
+}
+
+func (w *WasmDeploymentConfig) FromPersistentForm(pf map[string]interface{}) error {
+
+	// Marshal to JSON form so that we can unmarshal as a WasmDeploymentConfig.
+	if jBytes, err := json.Marshal(pf); err != nil {
+		return errors.New(fmt.Sprintf("error marshalling wasm persistent deployment: %v, error: %v", w, err))
+	} else if err := json.Unmarshal(jBytes, w); err != nil {
+		return errors.New(fmt.Sprintf("error unmarshalling wasm persistent deployment: %v, error: %v", string(jBytes), err))
+	}
+
+	return nil
+}
+
+func (w *WasmDeploymentConfig) ToString() string {
+	if w != nil {
+		return w.String()
+	} else {
+		return ""
+	}
+}
+
+// Given a deployment string, unmarshal it as a WasmDeployment object. It might not be a WasmDeployment, so
+// we have to verify what was just unmarshalled.
+func GetWasmDeployment(depStr string) (*WasmDeploymentConfig, error) {
+
+	wd := new(WasmDeploymentConfig)
+	err := json.Unmarshal([]byte(depStr), wd)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error unmarshalling deployment config as WasmDeployment: %v", err))
+	}
+
+	if len(wd.WasmModule) == 0 {
+		return nil, errors.New(fmt.Sprintf("deployment config is not a WasmDeployment"))
+	}
+
+	return wd, nil
+
+}
@@ -0,0 +1,90 @@
+//go:build unit
+// +build unit
+
+package persistence
+
+import (
+	"encoding/json"
+	"github.com/open-horizon/anax/containermessage"
+	"testing"
+)
+
+func Test_DecodeWasmDeployment(t *testing.T) {
+
+	wd := NewWasmDeployment("AGFzbQEAAAA=", []string{"--verbose"})
+
+	wdBytes, err := json.Marshal(wd)
+	if err != nil {
+		t.Errorf("Error marshalling %v, error: %v", wd, err)
+	}
+
+	newWD, gwdErr := GetWasmDeployment(string(wdBytes))
+	if gwdErr != nil {
+		t.Errorf("Error extracting wasm deployment %v, error: %v", string(wdBytes), err)
+	} else if newWD.WasmModule != wd.WasmModule || len(newWD.Args) != 1 || newWD.Args[0] != "--verbose" {
+		t.Errorf("Extracted wasm deployment %v does not match original %v", newWD, wd)
+	}
+
+	// A native deployment is not a wasm deployment.
+	native := `{"services":{"test":{"image":"test:1.0"}}}`
+	if newWD, gwdErr := GetWasmDeployment(native); gwdErr == nil {
+		t.Errorf("Should be an error returned for %v", native)
+	} else if newWD != nil {
+		t.Errorf("Should not return an object %v", newWD)
+	}
+
+}
+
+func Test_ValidateWasmDeployment(t *testing.T) {
+
+	wd := WasmDeploymentConfig{WasmModule: "AGFzbQEAAAA=", Binds: []string{"/var/data:/data"}}
+	if err := wd.Validate(); err != nil {
+		t.Errorf("unexpected error validating %v: %v", wd, err)
+	}
+
+	wd.WasmModule = "not base64!"
+	if err := wd.Validate(); err == nil {
+		t.Errorf("Should be an error returned for %v", wd)
+	}
+
+	wd.WasmModule = "AGFzbQEAAAA="
+	wd.Binds = []string{":/data"}
+	if err := wd.Validate(); err == nil {
+		t.Errorf("Should be an error returned for %v", wd)
+	}
+
+	wd.Binds = nil
+	wd.RestartPolicy = &containermessage.RestartPolicy{Name: "sometimes"}
+	if err := wd.Validate(); err == nil {
+		t.Errorf("Should be an error returned for %v", wd)
+	}
+
+}
+
+func Test_WasmPersistToFrom(t *testing.T) {
+
+	wd := WasmDeploymentConfig{
+		WasmModule:    "AGFzbQEAAAA=",
+		Args:          []string{"a", "b"},
+		MaxMemoryMB:   16,
+		RestartPolicy: &containermessage.RestartPolicy{Name: containermessage.RESTART_POLICY_ON_FAILURE, MaxRetries: 3},
+	}
+
+	// Convert the object to persistent form.
+	if pf, err := wd.ToPersistentForm(); err != nil {
+		t.Errorf("unexpected error changing to persistent form: %v", err)
+	} else if !IsWasm(pf) || IsHelm(pf) || IsKube(pf) {
+		t.Errorf("persistent form not as expected, is: %v", pf)
+	} else {
+
+		// Now change it back to non-persistent form.
+		nwd := WasmDeploymentConfig{}
+		if err := nwd.FromPersistentForm(pf); err != nil {
+			t.Errorf("unexpected error changing from persistent form: %v", err)
+		} else if wd.WasmModule != nwd.WasmModule || len(nwd.Args) != 2 || wd.MaxMemoryMB != nwd.MaxMemoryMB || nwd.RestartPolicy == nil || nwd.RestartPolicy.MaxRetries != 3 {
+			t.Errorf("object from persistent form: %v doesnt match original: %v", nwd, wd)
+		}
+
+	}
+
+}
@@ -0,0 +1,164 @@
+package policy
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/externalpolicy"
+	"sync"
+)
+
+// The AgreementIndex tracks, for each agreement, the node properties that the constraints of the consumer policy
+// reference and the values those properties had when the agreement was last found compatible. A node policy change
+// can only make an agreement incompatible when it changes one of those values or the constraints of the node, so
+// the agbot uses the index to re-evaluate just the agreements a change affects instead of every agreement on the node.
+type AgreementIndex struct {
+	lock       sync.Mutex
+	agreements map[string]*indexedAgreement // The indexed agreements, by agreement id
+	byNode     map[string]map[string]bool   // The ids of the indexed agreements, by node id
+}
+
+type indexedAgreement struct {
+	nodeId          string                              // The node the agreement is with
+	allProperties   bool                                // Any change to the node properties affects the agreement
+	properties      map[string]*externalpolicy.Property // The referenced node properties, nil if the node did not have it
+	nodeProperties  externalpolicy.PropertyList         // All the node properties, kept only when allProperties is set
+	nodeConstraints externalpolicy.ConstraintExpression // The constraints of the node
+}
+
+func NewAgreementIndex() *AgreementIndex {
+	return &AgreementIndex{
+		agreements: make(map[string]*indexedAgreement),
+		byNode:     make(map[string]map[string]bool),
+	}
+}
+
+func (ai *AgreementIndex) String() string {
+	ai.lock.Lock()
+	defer ai.lock.Unlock()
+	return fmt.Sprintf("AgreementIndex: agreements: %v, nodes: %v", len(ai.agreements), len(ai.byNode))
+}
+
+// Index an agreement after its consumer policy was found compatible with the node policy. The consumer policy is the
+// merged deployment and service policy of the agreement. Set allProperties when something other than the constraints
+// depends on the node properties, e.g. the fleet membership of the node, so that any property change affects the
+// agreement. An agreement that is already indexed is replaced.
+func (ai *AgreementIndex) Add(agreementId string, nodeId string, consumerPol *Policy, nodePol *Policy, allProperties bool) error {
+	if ai == nil {
+		return nil
+	}
+
+	names, err := consumerPol.Constraints.PropertyNames()
+	if err != nil {
+		ai.Remove(agreementId)
+		return fmt.Errorf("unable to get the properties referenced by the constraints %v, error %v", consumerPol.Constraints, err)
+	}
+
+	// whether the node allows privileged services is checked for every agreement, and the signature of the node policy
+	// decides which properties are trusted.
+	names = append(names, externalpolicy.PROP_NODE_PRIVILEGED, externalpolicy.PROP_NODE_POLICY_SIGNATURE, externalpolicy.PROP_NODE_SIGNED_PROPERTIES, externalpolicy.PROP_NODE_SIGNED_USERINPUT)
+
+	entry := &indexedAgreement{
+		nodeId:          nodeId,
+		allProperties:   allProperties,
+		properties:      make(map[string]*externalpolicy.Property, len(names)),
+		nodeConstraints: append(externalpolicy.ConstraintExpression{}, nodePol.Constraints...),
+	}
+	for _, name := range names {
+		entry.properties[name] = getNodeProperty(nodePol, name)
+	}
+	if allProperties {
+		entry.nodeProperties = externalpolicy.CopyProperties(nodePol.Properties)
+	}
+
+	ai.lock.Lock()
+	defer ai.lock.Unlock()
+
+	ai.removeAgreement(agreementId)
+	ai.agreements[agreementId] = entry
+	if _, ok := ai.byNode[nodeId]; !ok {
+		ai.byNode[nodeId] = make(map[string]bool)
+	}
+	ai.byNode[nodeId][agreementId] = true
+	return nil
+}
+
+// Remove an agreement from the index, e.g. when it is cancelled or its policies could not be verified.
+func (ai *AgreementIndex) Remove(agreementId string) {
+	if ai == nil {
+		return
+	}
+	ai.lock.Lock()
+	defer ai.lock.Unlock()
+	ai.removeAgreement(agreementId)
+}
+
+// The caller must hold the lock.
+func (ai *AgreementIndex) removeAgreement(agreementId string) {
+	if entry, ok := ai.agreements[agreementId]; ok {
+		delete(ai.agreements, agreementId)
+		delete(ai.byNode[entry.nodeId], agreementId)
+		if len(ai.byNode[entry.nodeId]) == 0 {
+			delete(ai.byNode, entry.nodeId)
+		}
+	}
+}
+
+// Returns true if the new node policy could change the outcome of the policy compatibility check of the agreement.
+// An agreement that is not in the index is always affected.
+func (ai *AgreementIndex) NodePolicyAffects(agreementId string, nodePol *Policy) bool {
+	if ai == nil {
+		return true
+	}
+
+	ai.lock.Lock()
+	defer ai.lock.Unlock()
+
+	entry, ok := ai.agreements[agreementId]
+	if !ok || nodePol == nil {
+		return true
+	} else if !entry.nodeConstraints.IsSame(nodePol.Constraints) {
+		return true
+	} else if entry.allProperties {
+		return !entry.nodeProperties.IsSame(nodePol.Properties)
+	}
+
+	for name, oldProp := range entry.properties {
+		newProp := getNodeProperty(nodePol, name)
+		if (oldProp == nil) != (newProp == nil) {
+			return true
+		} else if oldProp != nil && !oldProp.IsSame(*newProp) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the ids of the indexed agreements with the given node.
+func (ai *AgreementIndex) AgreementsForNode(nodeId string) []string {
+	if ai == nil {
+		return []string{}
+	}
+	ai.lock.Lock()
+	defer ai.lock.Unlock()
+
+	ids := make([]string, 0, len(ai.byNode[nodeId]))
+	for id := range ai.byNode[nodeId] {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (ai *AgreementIndex) Len() int {
+	if ai == nil {
+		return 0
+	}
+	ai.lock.Lock()
+	defer ai.lock.Unlock()
+	return len(ai.agreements)
+}
+
+func getNodeProperty(nodePol *Policy, name string) *externalpolicy.Property {
+	if prop, err := nodePol.Properties.GetProperty(name); err == nil {
+		return &prop
+	}
+	return nil
+}
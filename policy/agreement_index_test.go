@@ -0,0 +1,183 @@
+//go:build unit
+// +build unit
+
+package policy
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/externalpolicy"
+	_ "github.com/open-horizon/anax/externalpolicy/text_language"
+	"testing"
+)
+
+func Test_AgreementIndex(t *testing.T) {
+	ai := NewAgreementIndex()
+
+	consumerPol := Policy_Factory("deployment")
+	consumerPol.Constraints = externalpolicy.ConstraintExpression{"purpose == location && openhorizon.cpu >= 2"}
+
+	nodePol := Policy_Factory("node")
+	nodePol.Properties = externalpolicy.PropertyList{*externalpolicy.Property_Factory("purpose", "location"), *externalpolicy.Property_Factory("openhorizon.cpu", 4.0),
+		*externalpolicy.Property_Factory("openhorizon.localtime.hour", 10.0)}
+	nodePol.Constraints = externalpolicy.ConstraintExpression{"iame2edev == true"}
+
+	if !ai.NodePolicyAffects("ag1", nodePol) {
+		t.Errorf("an agreement that is not indexed should always be affected")
+	}
+
+	if err := ai.Add("ag1", "myorg/node1", consumerPol, nodePol, false); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if ids := ai.AgreementsForNode("myorg/node1"); len(ids) != 1 || ids[0] != "ag1" {
+		t.Errorf("node myorg/node1 should have agreement ag1, but got %v", ids)
+	}
+
+	// a property the constraints do not reference
+	newPol := nodePol.DeepCopy()
+	newPol.Properties = externalpolicy.PropertyList{*externalpolicy.Property_Factory("purpose", "location"), *externalpolicy.Property_Factory("openhorizon.cpu", 4.0),
+		*externalpolicy.Property_Factory("openhorizon.localtime.hour", 11.0), *externalpolicy.Property_Factory("camera", true)}
+	if ai.NodePolicyAffects("ag1", newPol) {
+		t.Errorf("a change to an unreferenced property should not affect the agreement")
+	}
+
+	// a referenced property changed
+	newPol.Properties = externalpolicy.PropertyList{*externalpolicy.Property_Factory("purpose", "network"), *externalpolicy.Property_Factory("openhorizon.cpu", 4.0)}
+	if !ai.NodePolicyAffects("ag1", newPol) {
+		t.Errorf("a change to a referenced property should affect the agreement")
+	}
+
+	// a referenced property was removed
+	newPol.Properties = externalpolicy.PropertyList{*externalpolicy.Property_Factory("purpose", "location")}
+	if !ai.NodePolicyAffects("ag1", newPol) {
+		t.Errorf("removing a referenced property should affect the agreement")
+	}
+
+	// privileged is always referenced
+	newPol.Properties = externalpolicy.PropertyList{*externalpolicy.Property_Factory("purpose", "location"), *externalpolicy.Property_Factory("openhorizon.cpu", 4.0),
+		*externalpolicy.Property_Factory(externalpolicy.PROP_NODE_PRIVILEGED, true)}
+	if !ai.NodePolicyAffects("ag1", newPol) {
+		t.Errorf("adding the %v property should affect the agreement", externalpolicy.PROP_NODE_PRIVILEGED)
+	}
+
+	// the node constraints changed
+	newPol = nodePol.DeepCopy()
+	newPol.Constraints = externalpolicy.ConstraintExpression{"iame2edev == false"}
+	if !ai.NodePolicyAffects("ag1", newPol) {
+		t.Errorf("a change to the node constraints should affect the agreement")
+	}
+
+	// any property change affects an agreement indexed with allProperties
+	if err := ai.Add("ag1", "myorg/node1", consumerPol, nodePol, true); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if ai.Len() != 1 {
+		t.Errorf("re-indexing an agreement should replace it, but the index has %v agreements", ai.Len())
+	}
+	newPol = nodePol.DeepCopy()
+	newPol.Properties = externalpolicy.PropertyList{*externalpolicy.Property_Factory("purpose", "location"), *externalpolicy.Property_Factory("openhorizon.cpu", 4.0),
+		*externalpolicy.Property_Factory("openhorizon.localtime.hour", 11.0)}
+	if !ai.NodePolicyAffects("ag1", newPol) {
+		t.Errorf("any property change should affect an agreement indexed with allProperties")
+	} else if ai.NodePolicyAffects("ag1", nodePol) {
+		t.Errorf("the same node policy should not affect the agreement")
+	}
+
+	ai.Remove("ag1")
+	if ai.Len() != 0 || len(ai.AgreementsForNode("myorg/node1")) != 0 {
+		t.Errorf("the index should be empty, but got %v", ai)
+	}
+
+	// an agreement whose constraints cannot be parsed is not indexed
+	consumerPol.Constraints = externalpolicy.ConstraintExpression{"purpose == "}
+	if err := ai.Add("ag2", "myorg/node1", consumerPol, nodePol, false); err == nil {
+		t.Errorf("should have returned an error for invalid constraints")
+	} else if ai.Len() != 0 {
+		t.Errorf("the index should be empty, but got %v", ai)
+	}
+}
+
+func Test_AgreementIndex_nil(t *testing.T) {
+	var ai *AgreementIndex
+
+	if err := ai.Add("ag1", "myorg/node1", Policy_Factory("deployment"), Policy_Factory("node"), false); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if ids := ai.AgreementsForNode("myorg/node1"); len(ids) != 0 {
+		t.Errorf("a nil index should have no agreements, but got %v", ids)
+	} else if ai.Len() != 0 {
+		t.Errorf("a nil index should be empty, but got %v", ai.Len())
+	} else if !ai.NodePolicyAffects("ag1", Policy_Factory("node")) {
+		t.Errorf("a nil index should affect every agreement")
+	}
+	ai.Remove("ag1")
+}
+
+// Create the policies of an agbot with the given number of agreements, each with its own node, and the node policies
+// after the local hour of all the nodes changed.
+func createBenchmarkPolicies(numAgreements int) (*Policy, []*Policy, []*Policy) {
+	consumerPol := Policy_Factory("deployment")
+	consumerPol.Constraints = externalpolicy.ConstraintExpression{"purpose == location && openhorizon.cpu >= 2 && (model == rpi4 || model == jetson)"}
+	consumerPol.Add_Agreement_Protocol(AgreementProtocol_Factory(BasicProtocol))
+
+	nodePols := make([]*Policy, numAgreements)
+	newNodePols := make([]*Policy, numAgreements)
+	for i := 0; i < numAgreements; i++ {
+		nodePol := Policy_Factory(fmt.Sprintf("node%v", i))
+		nodePol.Properties = externalpolicy.PropertyList{*externalpolicy.Property_Factory("purpose", "location"), *externalpolicy.Property_Factory("openhorizon.cpu", 4.0),
+			*externalpolicy.Property_Factory("model", "rpi4"), *externalpolicy.Property_Factory("openhorizon.localtime.hour", 10.0)}
+		nodePol.Add_Agreement_Protocol(AgreementProtocol_Factory(BasicProtocol))
+		nodePols[i] = nodePol
+
+		newNodePol := nodePol.DeepCopy()
+		newNodePol.Properties = externalpolicy.CopyProperties(nodePol.Properties)
+		newNodePol.Properties[3] = *externalpolicy.Property_Factory("openhorizon.localtime.hour", 11.0)
+		newNodePols[i] = newNodePol
+	}
+	return consumerPol, nodePols, newNodePols
+}
+
+// Re-evaluate all the 10k agreements after a node policy change.
+func Benchmark_NodePolicyChange_Full(b *testing.B) {
+	consumerPol, _, newNodePols := createBenchmarkPolicies(10000)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, nodePol := range newNodePols {
+			if err := Are_Compatible(nodePol, consumerPol, nil); err != nil {
+				b.Fatalf("policies should be compatible, but got %v", err)
+			}
+		}
+	}
+}
+
+// Re-evaluate only the agreements among the 10k that the node policy change affects.
+func Benchmark_NodePolicyChange_Indexed(b *testing.B) {
+	consumerPol, nodePols, newNodePols := createBenchmarkPolicies(10000)
+	ai := NewAgreementIndex()
+	for i, nodePol := range nodePols {
+		if err := ai.Add(fmt.Sprintf("ag%v", i), nodePol.Header.Name, consumerPol, nodePol, false); err != nil {
+			b.Fatalf("should not return error, but got %v", err)
+		}
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i, nodePol := range newNodePols {
+			if !ai.NodePolicyAffects(fmt.Sprintf("ag%v", i), nodePol) {
+				continue
+			} else if err := Are_Compatible(nodePol, consumerPol, nil); err != nil {
+				b.Fatalf("policies should be compatible, but got %v", err)
+			}
+		}
+	}
+}
+
+// Index the 10k agreements, which the agbot does after each successful re-evaluation.
+func Benchmark_AgreementIndex_Add(b *testing.B) {
+	consumerPol, nodePols, _ := createBenchmarkPolicies(10000)
+	ai := NewAgreementIndex()
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i, nodePol := range nodePols {
+			ai.Add(fmt.Sprintf("ag%v", i), nodePol.Header.Name, consumerPol, nodePol, false)
+		}
+	}
+}
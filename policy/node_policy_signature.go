@@ -0,0 +1,148 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/rsapss-tool/sign"
+	"github.com/open-horizon/rsapss-tool/verify"
+	"sort"
+	"strings"
+)
+
+// A node policy is signed by an admin of the node's org so that the agbot can trust the properties a node claims, e.g. its
+// hardware id or that it is certified, instead of taking the word of whoever can change the node policy. The signature
+// covers the named node properties, the node id so that it cannot be copied to another node, and optionally the user
+// input of the node. It is kept in the node policy itself, in these properties:
+//
+//	openhorizon.signedProperties - the comma separated names of the signed properties
+//	openhorizon.signedUserInput  - true when the user input of the node is signed
+//	openhorizon.policySignature  - the signature, by a key of the node's org
+//
+// The properties are signed as the agbot sees them, the top level properties of the node policy merged with its
+// deployment properties.
+
+// The content that is signed.
+type nodePolicySignaturePayload struct {
+	NodeId     string           `json:"nodeId"`
+	Properties []signedProperty `json:"properties"`
+	UserInput  []UserInput      `json:"userInput,omitempty"`
+}
+
+type signedProperty struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// Returns the bytes a node policy signature is computed over. A signed property that is not in the properties is an
+// error, the signature would not protect it.
+func NodePolicySignaturePayload(nodeId string, props externalpolicy.PropertyList, signedNames []string, userInput []UserInput, signUserInput bool) ([]byte, error) {
+	names := make([]string, len(signedNames))
+	copy(names, signedNames)
+	sort.Strings(names)
+
+	payload := nodePolicySignaturePayload{NodeId: nodeId, Properties: []signedProperty{}}
+	for _, name := range names {
+		prop, err := props.GetProperty(name)
+		if err != nil {
+			return nil, fmt.Errorf("signed property %v is not in the node policy", name)
+		}
+		payload.Properties = append(payload.Properties, signedProperty{Name: prop.Name, Value: prop.Value})
+	}
+	if signUserInput {
+		payload.UserInput = userInput
+		if payload.UserInput == nil {
+			payload.UserInput = []UserInput{}
+		}
+	}
+	return json.Marshal(payload)
+}
+
+// Sign the named properties of the node policy, and the user input when signUserInput is set, with the private key. The
+// signature properties are added to the top level properties of the policy.
+func SignNodePolicy(privKeyFile string, nodeId string, nodePol *exchangecommon.NodePolicy, signedNames []string, userInput []UserInput, signUserInput bool) error {
+	if len(signedNames) == 0 && !signUserInput {
+		return fmt.Errorf("there are no properties or user input to sign")
+	}
+	for _, name := range signedNames {
+		if name == externalpolicy.PROP_NODE_SIGNED_PROPERTIES || name == externalpolicy.PROP_NODE_SIGNED_USERINPUT || name == externalpolicy.PROP_NODE_POLICY_SIGNATURE {
+			return fmt.Errorf("property %v cannot be signed", name)
+		} else if strings.Contains(name, ",") {
+			return fmt.Errorf("property %v cannot be signed, the name contains a comma", name)
+		}
+	}
+
+	payload, err := NodePolicySignaturePayload(nodeId, nodePol.GetDeploymentPolicy().Properties, signedNames, userInput, signUserInput)
+	if err != nil {
+		return err
+	}
+	signature, err := sign.Input(privKeyFile, payload)
+	if err != nil {
+		return fmt.Errorf("unable to sign the node policy with %v: %v", privKeyFile, err)
+	}
+
+	nodePol.Properties.Add_Property(externalpolicy.Property_Factory(externalpolicy.PROP_NODE_SIGNED_PROPERTIES, strings.Join(signedNames, ",")), true)
+	nodePol.Properties.Add_Property(externalpolicy.Property_Factory(externalpolicy.PROP_NODE_SIGNED_USERINPUT, signUserInput), true)
+	nodePol.Properties.Add_Property(externalpolicy.Property_Factory(externalpolicy.PROP_NODE_POLICY_SIGNATURE, signature), true)
+	return nil
+}
+
+// Returns true if the properties of a node policy have a signature.
+func IsNodePolicySigned(props externalpolicy.PropertyList) bool {
+	return props.HasProperty(externalpolicy.PROP_NODE_POLICY_SIGNATURE)
+}
+
+// Verify the signature of a node policy with the public keys of the node's org, and return the names of the signed
+// properties. The user input of the node is only read when it is signed. Nothing is returned when the policy is not
+// signed. An error is returned when the signature is malformed or not made by any of the keys, the policy was changed
+// after it was signed or the signature was made for another node.
+func VerifyNodePolicySignature(keyFiles []string, nodeId string, props externalpolicy.PropertyList, getUserInput func() ([]UserInput, error)) ([]string, error) {
+	if !IsNodePolicySigned(props) {
+		return nil, nil
+	}
+
+	sigProp, _ := props.GetProperty(externalpolicy.PROP_NODE_POLICY_SIGNATURE)
+	signature, ok := sigProp.Value.(string)
+	if !ok || signature == "" {
+		return nil, fmt.Errorf("property %v must be a signature string", externalpolicy.PROP_NODE_POLICY_SIGNATURE)
+	}
+
+	signedNames := []string{}
+	if namesProp, err := props.GetProperty(externalpolicy.PROP_NODE_SIGNED_PROPERTIES); err == nil {
+		if names, ok := namesProp.Value.(string); !ok {
+			return nil, fmt.Errorf("property %v must be a comma separated list of property names", externalpolicy.PROP_NODE_SIGNED_PROPERTIES)
+		} else if names != "" {
+			for _, name := range strings.Split(names, ",") {
+				signedNames = append(signedNames, strings.TrimSpace(name))
+			}
+		}
+	}
+
+	signUserInput := false
+	if uiProp, err := props.GetProperty(externalpolicy.PROP_NODE_SIGNED_USERINPUT); err == nil {
+		if signUserInput, ok = uiProp.Value.(bool); !ok {
+			return nil, fmt.Errorf("property %v must be a boolean", externalpolicy.PROP_NODE_SIGNED_USERINPUT)
+		}
+	}
+
+	var userInput []UserInput
+	if signUserInput {
+		var err error
+		if userInput, err = getUserInput(); err != nil {
+			return nil, fmt.Errorf("unable to get the user input of node %v to verify the node policy signature: %v", nodeId, err)
+		}
+	}
+
+	payload, err := NodePolicySignaturePayload(nodeId, props, signedNames, userInput, signUserInput)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keyFiles) == 0 {
+		return nil, fmt.Errorf("there are no public keys to verify the node policy signature of node %v", nodeId)
+	} else if verified, _, failed := verify.InputVerifiedByAnyKey(keyFiles, signature, payload); !verified {
+		return nil, fmt.Errorf("the node policy signature of node %v is not valid for its properties %v and user input, errors: %v", nodeId, signedNames, failed)
+	}
+	return signedNames, nil
+}
@@ -0,0 +1,135 @@
+//go:build unit
+// +build unit
+
+package policy
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/rsapss-tool/generatekeys"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Create a key pair for the org in a temporary directory, returns the private and public key files.
+func createSigningKeys(t *testing.T, org string) (string, string) {
+	dir, err := os.MkdirTemp("", "nodepolsig")
+	if err != nil {
+		t.Fatalf("unable to create the key directory, error %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	files, err := generatekeys.Write(dir, 2048, "admin", org, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unable to create the keys, error %v", err)
+	}
+	var privKey, pubKey string
+	for _, f := range files {
+		if strings.HasSuffix(f, "private.key") {
+			privKey = f
+		} else if strings.HasSuffix(f, "public.pem") {
+			pubKey = f
+		}
+	}
+	if privKey == "" || pubKey == "" {
+		t.Fatalf("unable to find the created keys in %v", files)
+	}
+	return privKey, pubKey
+}
+
+func Test_NodePolicySignature(t *testing.T) {
+	privKey, pubKey := createSigningKeys(t, "myorg")
+	_, otherPubKey := createSigningKeys(t, "otherorg")
+
+	nodePol := &exchangecommon.NodePolicy{
+		ExternalPolicy: externalpolicy.ExternalPolicy{
+			Properties: externalpolicy.PropertyList{*externalpolicy.Property_Factory("certified", true), *externalpolicy.Property_Factory("purpose", "location")},
+		},
+		Deployment: externalpolicy.ExternalPolicy{
+			Properties: externalpolicy.PropertyList{*externalpolicy.Property_Factory(externalpolicy.PROP_NODE_HARDWAREID, "hw123")},
+		},
+	}
+	userInput := []UserInput{{ServiceOrgid: "myorg", ServiceUrl: "weather", Inputs: []Input{{Name: "HZN_LAT", Value: 41.9}}}}
+	noUserInput := func() ([]UserInput, error) {
+		return nil, fmt.Errorf("the user input should not be read")
+	}
+	getUserInput := func() ([]UserInput, error) {
+		return userInput, nil
+	}
+
+	// a policy that is not signed
+	if names, err := VerifyNodePolicySignature([]string{pubKey}, "myorg/node1", nodePol.GetDeploymentPolicy().Properties, noUserInput); err != nil || names != nil {
+		t.Errorf("an unsigned policy should not have signed properties, but got %v, error %v", names, err)
+	}
+
+	// the signature properties cannot be signed
+	if err := SignNodePolicy(privKey, "myorg/node1", nodePol.DeepCopy(), []string{externalpolicy.PROP_NODE_POLICY_SIGNATURE}, nil, false); err == nil {
+		t.Errorf("signing the signature property should have returned an error")
+	}
+
+	// a property that is not in the policy cannot be signed
+	if err := SignNodePolicy(privKey, "myorg/node1", nodePol.DeepCopy(), []string{"gpu"}, nil, false); err == nil {
+		t.Errorf("signing a missing property should have returned an error")
+	}
+
+	// sign a top level and a deployment property
+	signedPol := nodePol.DeepCopy()
+	if err := SignNodePolicy(privKey, "myorg/node1", signedPol, []string{"certified", externalpolicy.PROP_NODE_HARDWAREID}, nil, false); err != nil {
+		t.Fatalf("should not return error, but got %v", err)
+	}
+	props := signedPol.GetDeploymentPolicy().Properties
+	if names, err := VerifyNodePolicySignature([]string{otherPubKey, pubKey}, "myorg/node1", props, noUserInput); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(names) != 2 || names[0] != "certified" || names[1] != externalpolicy.PROP_NODE_HARDWAREID {
+		t.Errorf("the signed properties should be certified and %v, but got %v", externalpolicy.PROP_NODE_HARDWAREID, names)
+	}
+
+	// an unsigned property can change
+	changed := externalpolicy.CopyProperties(props)
+	changed.Add_Property(externalpolicy.Property_Factory("purpose", "network"), true)
+	if _, err := VerifyNodePolicySignature([]string{pubKey}, "myorg/node1", changed, noUserInput); err != nil {
+		t.Errorf("changing an unsigned property should not return error, but got %v", err)
+	}
+
+	// a signed property was forged
+	changed = externalpolicy.CopyProperties(props)
+	changed.Add_Property(externalpolicy.Property_Factory(externalpolicy.PROP_NODE_HARDWAREID, "hw456"), true)
+	if _, err := VerifyNodePolicySignature([]string{pubKey}, "myorg/node1", changed, noUserInput); err == nil {
+		t.Errorf("a forged signed property should have returned an error")
+	}
+
+	// the signature was copied to another node
+	if _, err := VerifyNodePolicySignature([]string{pubKey}, "myorg/node2", props, noUserInput); err == nil {
+		t.Errorf("a signature copied to another node should have returned an error")
+	}
+
+	// not signed by a key of the org
+	if _, err := VerifyNodePolicySignature([]string{otherPubKey}, "myorg/node1", props, noUserInput); err == nil {
+		t.Errorf("a signature that no key verifies should have returned an error")
+	} else if _, err := VerifyNodePolicySignature([]string{}, "myorg/node1", props, noUserInput); err == nil {
+		t.Errorf("a signature without keys should have returned an error")
+	}
+
+	// sign the user input
+	signedPol = nodePol.DeepCopy()
+	if err := SignNodePolicy(privKey, "myorg/node1", signedPol, []string{"certified"}, userInput, true); err != nil {
+		t.Fatalf("should not return error, but got %v", err)
+	}
+	props = signedPol.GetDeploymentPolicy().Properties
+	if names, err := VerifyNodePolicySignature([]string{pubKey}, "myorg/node1", props, getUserInput); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(names) != 1 || names[0] != "certified" {
+		t.Errorf("the signed properties should be certified, but got %v", names)
+	}
+
+	// the user input was changed
+	forgedUserInput := func() ([]UserInput, error) {
+		return []UserInput{{ServiceOrgid: "myorg", ServiceUrl: "weather", Inputs: []Input{{Name: "HZN_LAT", Value: 12.3}}}}, nil
+	}
+	if _, err := VerifyNodePolicySignature([]string{pubKey}, "myorg/node1", props, forgedUserInput); err == nil {
+		t.Errorf("a forged user input should have returned an error")
+	}
+}
@@ -72,6 +72,7 @@ type Policy struct {
 	SecretBinding      []exchangecommon.SecretBinding      `json:"secretBinding,omitempty"`    // This structure has the servive secret name to secret provider name mappings
 	SecretDetails      []exchangecommon.SecretBinding      `json:"secretDetails,omitempty"`    // This structure has the service secret name to secret details mappings
 	ClusterNamespace   string                              `json:"clusterNamespace,omitempty"` // the namespace for the service to be deployed
+	Priority           int                                 `json:"priority,omitempty"`         // the priority of the deployment policy, agreements with a lower priority can be preempted for this one
 }
 
 // These functions are used to create Policy objects. You can create the base object
@@ -132,6 +133,7 @@ func (self *Policy) DeepCopy() *Policy {
 	}
 
 	newPolicy.ClusterNamespace = self.ClusterNamespace
+	newPolicy.Priority = self.Priority
 
 	return newPolicy
 }
@@ -366,6 +368,7 @@ func Create_Terms_And_Conditions(producer_policy *Policy, consumer_policy *Polic
 		}
 
 		merged_pol.ClusterNamespace = consumer_policy.ClusterNamespace
+		merged_pol.Priority = consumer_policy.Priority
 
 		return merged_pol, nil
 	}
@@ -512,6 +515,7 @@ func (self *Policy) String() string {
 	res += fmt.Sprintf("SecretBinding: %v\n", self.SecretBinding)
 
 	res += fmt.Sprintf("ClusterNamespace: %v\n", self.ClusterNamespace)
+	res += fmt.Sprintf("Priority: %v\n", self.Priority)
 
 	return res
 }
@@ -0,0 +1,129 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/open-horizon/anax/externalpolicy"
+	"math"
+	"sort"
+)
+
+// A scoring rule of a deployment policy. When more nodes match the policy than the agbot can place the service on, the
+// nodes are ranked by the sum of the weighted terms of the rules. A rule without a value prefers the nodes with a higher
+// value of a numeric property, or a negative weight a lower value. A boolean property counts as 1 when it is true. A rule
+// with a value prefers the nodes whose property has that value.
+type ScoringRule struct {
+	Property string      `json:"property"`
+	Weight   float64     `json:"weight"`
+	Value    interface{} `json:"value,omitempty"`
+}
+
+func (r ScoringRule) String() string {
+	return fmt.Sprintf("Property: %v, Weight: %v, Value: %v", r.Property, r.Weight, r.Value)
+}
+
+func (r ScoringRule) Validate() error {
+	if r.Property == "" {
+		return fmt.Errorf("scoring rule must have a property")
+	} else if r.Weight == 0 || math.IsNaN(r.Weight) || math.IsInf(r.Weight, 0) {
+		return fmt.Errorf("scoring rule for property %v must have a non-zero weight, it is %v", r.Property, r.Weight)
+	}
+	return nil
+}
+
+// A node to be ranked, with its properties and the properties filled in from its dynamic state.
+type ScoringCandidate struct {
+	Id         string
+	Properties externalpolicy.PropertyList
+}
+
+// Returns the score of each candidate, in the order of the candidates. Each rule adds its weight times a term between
+// 0 and 1. The numeric values are scaled to that range between the lowest and highest value among the candidates, so
+// that properties with different units can be weighted against each other. A candidate without the property gets the
+// term of the least preferred value.
+func ScoreCandidates(rules []ScoringRule, candidates []ScoringCandidate) []float64 {
+	scores := make([]float64, len(candidates))
+
+	for _, rule := range rules {
+		terms := make([]float64, len(candidates))
+		found := make([]bool, len(candidates))
+		min, max := math.Inf(1), math.Inf(-1)
+
+		for i, c := range candidates {
+			prop, err := c.Properties.GetProperty(rule.Property)
+			if err != nil {
+				continue
+			}
+			if rule.Value != nil {
+				if fmt.Sprintf("%v", prop.Value) == fmt.Sprintf("%v", rule.Value) {
+					terms[i] = 1
+				}
+				found[i] = true
+			} else if v, ok := scoringNumber(prop.Value); ok {
+				terms[i], found[i] = v, true
+				min, max = math.Min(min, v), math.Max(max, v)
+			}
+		}
+
+		for i := range candidates {
+			if !found[i] {
+				// the least preferred value, the lowest for a positive weight and the highest for a negative one
+				if rule.Weight > 0 {
+					terms[i] = 0
+				} else {
+					terms[i] = 1
+				}
+			} else if rule.Value == nil {
+				if max > min {
+					terms[i] = (terms[i] - min) / (max - min)
+				} else {
+					terms[i] = 1
+				}
+			}
+			scores[i] += rule.Weight * terms[i]
+		}
+	}
+	return scores
+}
+
+// Returns the candidates ordered by their score, the highest first. Candidates with the same score keep their order.
+func RankCandidates(rules []ScoringRule, candidates []ScoringCandidate) []ScoringCandidate {
+	scores := ScoreCandidates(rules, candidates)
+
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+
+	ranked := make([]ScoringCandidate, len(candidates))
+	for i, idx := range order {
+		ranked[i] = candidates[idx]
+	}
+	return ranked
+}
+
+// Returns the numeric value of a property value, a boolean is 1 when true.
+func scoringNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,74 @@
+//go:build unit
+// +build unit
+
+package policy
+
+import (
+	"github.com/open-horizon/anax/externalpolicy"
+	"testing"
+)
+
+func Test_ScoringRule_Validate(t *testing.T) {
+	if err := (ScoringRule{Property: "openhorizon.freeMemory", Weight: 2}).Validate(); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	}
+	if err := (ScoringRule{Property: "gpu", Weight: -1, Value: true}).Validate(); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	}
+	if err := (ScoringRule{Weight: 1}).Validate(); err == nil {
+		t.Errorf("should have returned an error for a rule without a property")
+	}
+	if err := (ScoringRule{Property: "gpu"}).Validate(); err == nil {
+		t.Errorf("should have returned an error for a rule without a weight")
+	}
+}
+
+func Test_RankCandidates(t *testing.T) {
+	candidates := []ScoringCandidate{
+		{Id: "node1", Properties: externalpolicy.PropertyList{*externalpolicy.Property_Factory("openhorizon.freeMemory", 512.0), *externalpolicy.Property_Factory("model", "rpi4")}},
+		{Id: "node2", Properties: externalpolicy.PropertyList{*externalpolicy.Property_Factory("openhorizon.freeMemory", 2048.0), *externalpolicy.Property_Factory("model", "jetson")}},
+		{Id: "node3", Properties: externalpolicy.PropertyList{*externalpolicy.Property_Factory("model", "jetson"), *externalpolicy.Property_Factory("gpu", true)}},
+		{Id: "node4", Properties: externalpolicy.PropertyList{*externalpolicy.Property_Factory("openhorizon.freeMemory", 1024.0), *externalpolicy.Property_Factory("gpu", false)}},
+	}
+
+	checkOrder := func(ranked []ScoringCandidate, expected []string) {
+		if len(ranked) != len(expected) {
+			t.Errorf("expected %v candidates, but got %v", len(expected), len(ranked))
+			return
+		}
+		for i, id := range expected {
+			if ranked[i].Id != id {
+				t.Errorf("expected candidate %v at rank %v, but got %v", id, i, ranked[i].Id)
+			}
+		}
+	}
+
+	// no rules keep the order
+	checkOrder(RankCandidates(nil, candidates), []string{"node1", "node2", "node3", "node4"})
+
+	// prefer more free memory, a node that does not publish it is last
+	checkOrder(RankCandidates([]ScoringRule{{Property: "openhorizon.freeMemory", Weight: 1}}, candidates), []string{"node2", "node4", "node1", "node3"})
+
+	// prefer less free memory, a node that does not publish it is still last
+	checkOrder(RankCandidates([]ScoringRule{{Property: "openhorizon.freeMemory", Weight: -1}}, candidates), []string{"node1", "node4", "node2", "node3"})
+
+	// prefer a property value
+	checkOrder(RankCandidates([]ScoringRule{{Property: "model", Weight: 1, Value: "jetson"}}, candidates), []string{"node2", "node3", "node1", "node4"})
+
+	// a boolean property counts as 1 when true
+	checkOrder(RankCandidates([]ScoringRule{{Property: "gpu", Weight: 1}}, candidates), []string{"node3", "node1", "node2", "node4"})
+
+	// the terms are weighted against each other, the free memory is scaled between 512 and 2048
+	rules := []ScoringRule{{Property: "gpu", Weight: 1, Value: true}, {Property: "openhorizon.freeMemory", Weight: 3}}
+	scores := ScoreCandidates(rules, candidates)
+	expected := []float64{0, 3, 1, 1}
+	for i := range expected {
+		if diff := scores[i] - expected[i]; diff > 0.0001 || diff < -0.0001 {
+			t.Errorf("expected score %v for %v, but got %v", expected[i], candidates[i].Id, scores[i])
+		}
+	}
+	checkOrder(RankCandidates(rules, candidates), []string{"node2", "node3", "node4", "node1"})
+
+	// a single candidate has the full term
+	checkOrder(RankCandidates([]ScoringRule{{Property: "openhorizon.freeMemory", Weight: 1}}, candidates[:1]), []string{"node1"})
+}
@@ -293,6 +293,8 @@ func (c *BasicProtocolHandler) GetTerminationCode(reason string) uint {
 		return basicprotocol.CANCEL_NODE_PATTERN_CHANGED
 	case TERM_FAILED_AGREEMENT_VERIFY:
 		return basicprotocol.CANCEL_FAILED_AGREEMENT_VERIFY
+	case TERM_REASON_PREEMPTED:
+		return basicprotocol.CANCEL_PREEMPTED
 	default:
 		return 999
 	}
@@ -0,0 +1,153 @@
+package producer
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/abstractprotocol"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/eventlog"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"sort"
+	"time"
+)
+
+// An agreement that could be preempted, with the deployment policy priority from its proposal and the resources it reserves.
+type preemptCandidate struct {
+	ag       persistence.EstablishedAgreement
+	priority int
+	memoryMb int64
+	cpus     float64
+}
+
+// Choose the agreements of lower priority deployment policies to preempt so that the memory and CPUs needed by a proposal
+// fit on the device. The lowest priority agreements are preempted first and, for the same priority, the newest ones. The
+// agreements that are already being preempted are about to free their resources, so they are counted as freed without
+// being chosen again. Returns nothing when preempting all the lower priority agreements would still not make enough room.
+func choosePreemptions(memoryMb int64, cpus float64, freeMemoryMb int64, freeCPUs float64, candidates []preemptCandidate) []preemptCandidate {
+
+	fits := func() bool {
+		return (memoryMb == 0 || memoryMb <= freeMemoryMb) && (cpus == 0 || cpus <= freeCPUs)
+	}
+
+	chosen := make([]preemptCandidate, 0)
+	others := make([]preemptCandidate, 0, len(candidates))
+	for _, c := range candidates {
+		if c.ag.AgreementPreemptTime != 0 {
+			freeMemoryMb += c.memoryMb
+			freeCPUs += c.cpus
+		} else {
+			others = append(others, c)
+		}
+	}
+
+	sort.SliceStable(others, func(i, j int) bool {
+		if others[i].priority != others[j].priority {
+			return others[i].priority < others[j].priority
+		}
+		return others[i].ag.AgreementCreationTime > others[j].ag.AgreementCreationTime
+	})
+
+	for _, c := range others {
+		if fits() {
+			break
+		} else if c.memoryMb == 0 && c.cpus == 0 {
+			continue
+		}
+		chosen = append(chosen, c)
+		freeMemoryMb += c.memoryMb
+		freeCPUs += c.cpus
+	}
+
+	if !fits() {
+		return nil
+	}
+	return chosen
+}
+
+// When the device does not have enough resources for the services in a proposal, preempt the agreements of lower priority
+// deployment policies to make room for them. The chosen agreements keep running for the preemption grace period and are
+// then cancelled by the governance worker. The proposal itself is still rejected, the agbot proposes again once the
+// resources are free. Returns the agreements that are being preempted.
+func (w *BaseProducerProtocolHandler) PreemptDeviceResources(ph abstractprotocol.ProtocolHandler, tcPolicy *policy.Policy, dev *persistence.ExchangeDevice, agId string) ([]persistence.EstablishedAgreement, error) {
+	preempted := make([]persistence.EstablishedAgreement, 0)
+	if tcPolicy.Priority == 0 || dev.GetNodeType() == persistence.DEVICE_TYPE_CLUSTER || len(tcPolicy.Workloads) == 0 || tcPolicy.Workloads[0].Deployment == "" {
+		return preempted, nil
+	}
+
+	dd, err := containermessage.GetNativeDeployment(tcPolicy.Workloads[0].Deployment)
+	if err != nil {
+		return preempted, nil
+	}
+	memoryMb, cpus := dd.ResourceLimits()
+
+	// The resources left on the device.
+	reservedMemoryMb, reservedCPUs, err := persistence.TotalResourceReservations(w.db)
+	if err != nil {
+		return nil, err
+	}
+	totalMemoryMb, _, err := cutil.GetMemInfo("")
+	if err != nil {
+		return nil, err
+	}
+	totalCPUs, err := cutil.GetCPUCount("")
+	if err != nil {
+		return nil, err
+	}
+
+	// The resources reserved by each agreement.
+	reservations, err := persistence.FindResourceReservations(w.db)
+	if err != nil {
+		return nil, err
+	}
+	reserved := make(map[string]persistence.ResourceReservation)
+	for _, r := range reservations {
+		reserved[r.Key] = r
+	}
+
+	notTerminated := func() persistence.EAFilter {
+		return func(a persistence.EstablishedAgreement) bool { return a.AgreementTerminatedTime == 0 }
+	}
+	ags, err := persistence.FindEstablishedAgreements(w.db, w.Name(), []persistence.EAFilter{persistence.UnarchivedEAFilter(), notTerminated()})
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]preemptCandidate, 0)
+	for _, ag := range ags {
+		if proposal, err := ph.DemarshalProposal(ag.Proposal); err != nil {
+			glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("unable to demarshal proposal for agreement %v, error %v", ag.CurrentAgreementId, err)))
+		} else if agPolicy, err := policy.DemarshalPolicy(proposal.TsAndCs()); err != nil {
+			glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("unable to demarshal TsAndCs of agreement %v, error %v", ag.CurrentAgreementId, err)))
+		} else if agPolicy.Priority < tcPolicy.Priority {
+			r := reserved[ag.CurrentAgreementId]
+			candidates = append(candidates, preemptCandidate{ag: ag, priority: agPolicy.Priority, memoryMb: r.MemoryMb, cpus: r.CPUs})
+		}
+	}
+
+	chosen := choosePreemptions(memoryMb, cpus, int64(totalMemoryMb)-reservedMemoryMb, float64(totalCPUs)-reservedCPUs, candidates)
+	if len(chosen) == 0 {
+		glog.V(3).Infof(BPPHlogString(w.Name(), fmt.Sprintf("no lower priority agreements to preempt for agreement %v.", agId)))
+		return preempted, nil
+	}
+
+	grace := w.config.GetPreemptionGrace()
+	preemptTime := uint64(time.Now().Unix()) + uint64(grace)
+	workload := tcPolicy.Workloads[0]
+	for _, c := range chosen {
+		if ag, err := persistence.AgreementStatePreempting(w.db, c.ag.CurrentAgreementId, w.Name(), preemptTime); err != nil {
+			glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("unable to mark agreement %v for preemption, error %v", c.ag.CurrentAgreementId, err)))
+		} else {
+			glog.V(3).Infof(BPPHlogString(w.Name(), fmt.Sprintf("preempting agreement %v with priority %v in %v seconds for agreement %v with priority %v.", ag.CurrentAgreementId, c.priority, grace, agId, tcPolicy.Priority)))
+			eventlog.LogAgreementEvent(
+				w.db,
+				persistence.SEVERITY_WARN,
+				persistence.NewMessageMeta(EL_PROD_PREEMPTING_AG, ag.RunningWorkload.URL, grace, workload.Org, workload.WorkloadURL, tcPolicy.Priority),
+				persistence.EC_PREEMPTING_AGREEMENT,
+				*ag)
+			preempted = append(preempted, *ag)
+		}
+	}
+	return preempted, nil
+}
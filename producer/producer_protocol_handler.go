@@ -10,12 +10,14 @@ import (
 	"github.com/open-horizon/anax/api"
 	"github.com/open-horizon/anax/compcheck"
 	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/containermessage"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/externalpolicy"
 	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/kube_operator"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/anax/worker"
@@ -30,6 +32,10 @@ const (
 	EL_PROD_NODE_REJECTED_PROPOSAL_MSG = "Node received Proposal message using agreement %v for service %v/%v from the agbot %v."
 	EL_PROD_NODE_REJECTED_PROPOSAL     = "Node rejected the proposal for service %v/%v."
 	EL_PROD_ERR_HANDLE_PROPOSAL        = "Error handling proposal for service %v/%v. Error: %v"
+	EL_PROD_INSUFFICIENT_CLUSTER_RES   = "Node rejected the proposal for service %v/%v because the cluster does not have enough resources: %v"
+	EL_PROD_INSUFFICIENT_DEVICE_RES    = "Node rejected the proposal for service %v/%v because the device does not have enough resources: %v"
+	EL_PROD_PREEMPTING_AG              = "Agreement for service %v will be cancelled in %v seconds to make room for service %v/%v of a deployment policy with priority %v."
+	EL_PROD_PREEMPTING_FOR_PROPOSAL    = "Node is preempting %v lower priority agreements to make room for service %v/%v of a deployment policy with priority %v."
 )
 
 // This is does nothing useful at run time.
@@ -46,6 +52,10 @@ func MarkI18nMessages() {
 	msgPrinter.Sprintf(EL_PROD_NODE_REJECTED_PROPOSAL_MSG)
 	msgPrinter.Sprintf(EL_PROD_NODE_REJECTED_PROPOSAL)
 	msgPrinter.Sprintf(EL_PROD_ERR_HANDLE_PROPOSAL)
+	msgPrinter.Sprintf(EL_PROD_INSUFFICIENT_CLUSTER_RES)
+	msgPrinter.Sprintf(EL_PROD_INSUFFICIENT_DEVICE_RES)
+	msgPrinter.Sprintf(EL_PROD_PREEMPTING_AG)
+	msgPrinter.Sprintf(EL_PROD_PREEMPTING_FOR_PROPOSAL)
 }
 
 func CreateProducerPH(name string, cfg *config.HorizonConfig, db *bolt.DB, pm *policy.PolicyManager, ec exchange.ExchangeContext) ProducerProtocolHandler {
@@ -146,6 +156,10 @@ func (w *BaseProducerProtocolHandler) sendMessage(mt interface{}, pay []byte) er
 		// Marshal it into a byte array
 	} else if msgBody, err := json.Marshal(encryptedMsg); err != nil {
 		return errors.New(fmt.Sprintf("Unable to marshal exchange message %v, error %v", encryptedMsg, err))
+		// Send it on the stream to the agbot if there is one
+	} else if exchange.SendDirectMessage(messageTarget.ReceiverExchangeId, msgBody) {
+		glog.V(5).Infof(BPPHlogString(w.Name(), fmt.Sprintf("Sent message for %v on the stream to the agbot.", messageTarget.ReceiverExchangeId)))
+		return nil
 		// Send it to the device's message queue
 	} else {
 		pm := exchange.CreatePostMessage(msgBody, w.config.Edge.ExchangeMessageTTL)
@@ -241,6 +255,10 @@ func (w *BaseProducerProtocolHandler) HandleProposal(ph abstractprotocol.Protoco
 			proposal.ConsumerId(),
 			proposal.Protocol())
 
+		received := persistence.NewAgreementHistoryEntry(persistence.AG_HISTORY_PROPOSAL_RECEIVED, proposal.ConsumerId(), "")
+		received.Policies, received.Services = proposalHistoryPolicies(tcPolicy)
+		w.recordHistory(proposal, received)
+
 		err_log_event := ""
 
 		// Keep track of any signing keys we download so we can delete them when done
@@ -278,6 +296,56 @@ func (w *BaseProducerProtocolHandler) HandleProposal(ph abstractprotocol.Protoco
 			glog.Errorf(BPPHlogString(w.Name(), "pattern name matching failed, ignoring proposal"))
 			err_log_event = "Pattern name matching failed, ignoring proposal"
 			handled = true
+		} else if rmatch, reason, err := w.MatchClusterResources(tcPolicy, dev, proposal.AgreementId()); err != nil {
+			glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("received error checking cluster resources, %v", err)))
+			err_log_event = fmt.Sprintf("Received error checking cluster resources, %v", err)
+			handled = true
+		} else if !rmatch {
+			glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("cluster resource preflight check failed, ignoring proposal: %v", reason)))
+			eventlog.LogAgreementEvent2(
+				w.db,
+				persistence.SEVERITY_ERROR,
+				persistence.NewMessageMeta(EL_PROD_INSUFFICIENT_CLUSTER_RES, worg, wls, reason),
+				persistence.EC_ERROR_INSUFFICIENT_CLUSTER_RESOURCES,
+				proposal.AgreementId(),
+				persistence.WorkloadInfo{URL: wls, Org: worg, Version: wversion, Arch: warch},
+				ConvertToServiceSpecs(tcPolicy.APISpecs),
+				proposal.ConsumerId(),
+				proposal.Protocol())
+			w.recordRejection(proposal, fmt.Sprintf("Cluster resource preflight check failed: %v", reason))
+			handled = true
+		} else if rmatch, reason, err := w.MatchDeviceResources(tcPolicy, dev, proposal.AgreementId()); err != nil {
+			glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("received error checking device resources, %v", err)))
+			err_log_event = fmt.Sprintf("Received error checking device resources, %v", err)
+			handled = true
+		} else if !rmatch {
+			glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("device resource check failed, ignoring proposal: %v", reason)))
+			eventlog.LogAgreementEvent2(
+				w.db,
+				persistence.SEVERITY_ERROR,
+				persistence.NewMessageMeta(EL_PROD_INSUFFICIENT_DEVICE_RES, worg, wls, reason),
+				persistence.EC_ERROR_INSUFFICIENT_DEVICE_RESOURCES,
+				proposal.AgreementId(),
+				persistence.WorkloadInfo{URL: wls, Org: worg, Version: wversion, Arch: warch},
+				ConvertToServiceSpecs(tcPolicy.APISpecs),
+				proposal.ConsumerId(),
+				proposal.Protocol())
+			w.recordRejection(proposal, fmt.Sprintf("Device resource check failed: %v", reason))
+			if preempted, err := w.PreemptDeviceResources(ph, tcPolicy, dev, proposal.AgreementId()); err != nil {
+				glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("received error preempting lower priority agreements, %v", err)))
+			} else if len(preempted) != 0 {
+				eventlog.LogAgreementEvent2(
+					w.db,
+					persistence.SEVERITY_INFO,
+					persistence.NewMessageMeta(EL_PROD_PREEMPTING_FOR_PROPOSAL, len(preempted), worg, wls, tcPolicy.Priority),
+					persistence.EC_PREEMPTING_AGREEMENT,
+					proposal.AgreementId(),
+					persistence.WorkloadInfo{URL: wls, Org: worg, Version: wversion, Arch: warch},
+					ConvertToServiceSpecs(tcPolicy.APISpecs),
+					proposal.ConsumerId(),
+					proposal.Protocol())
+			}
+			handled = true
 		} else if ag, found, err := w.FindAgreementWithSameWorkload(ph, tcPolicy.Header.Name); err != nil {
 			glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("error finding agreement with TsAndCs name '%v', error %v", tcPolicy.Header.Name, err)))
 			err_log_event = fmt.Sprintf("Error finding agreement with TsAndCs (Terms And Conditions) name '%v', error %v", tcPolicy.Header.Name, err)
@@ -294,6 +362,7 @@ func (w *BaseProducerProtocolHandler) HandleProposal(ph abstractprotocol.Protoco
 				ConvertToServiceSpecs(tcPolicy.APISpecs),
 				proposal.ConsumerId(),
 				proposal.Protocol())
+			w.recordRejection(proposal, fmt.Sprintf("Agreement %v with TsAndCs name '%v' already exists", ag.CurrentAgreementId, tcPolicy.Header.Name))
 			handled = true
 		} else if err := w.saveSigningKeys(tcPolicy, proposal.AgreementId(), &signingKeys); err != nil {
 			glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("received error handling signing keys from the exchange: %v", err)))
@@ -345,6 +414,7 @@ func (w *BaseProducerProtocolHandler) HandleProposal(ph abstractprotocol.Protoco
 						ConvertToServiceSpecs(tcPolicy.APISpecs),
 						proposal.ConsumerId(),
 						proposal.Protocol())
+					w.recordRejection(proposal, "The node policy does not accept the proposal")
 				}
 				w.cleanupSigningKeys(signingKeys)
 				return handled, r, tcPolicy
@@ -363,11 +433,40 @@ func (w *BaseProducerProtocolHandler) HandleProposal(ph abstractprotocol.Protoco
 				ConvertToServiceSpecs(tcPolicy.APISpecs),
 				proposal.ConsumerId(),
 				proposal.Protocol())
+			w.recordRejection(proposal, err_log_event)
 		}
 	}
 	return handled, nil, nil
 }
 
+// Add a step of the proposal negotiation to the agreement history, the history must not get in the way of the proposal.
+func (w *BaseProducerProtocolHandler) recordHistory(proposal abstractprotocol.Proposal, entry *persistence.AgreementHistoryEntry) {
+	if err := persistence.AddAgreementHistoryEntry(w.db, proposal.AgreementId(), proposal.Protocol(), entry); err != nil {
+		glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("unable to add %v to the history of agreement %v, error: %v", entry.Event, proposal.AgreementId(), err)))
+	}
+}
+
+func (w *BaseProducerProtocolHandler) recordRejection(proposal abstractprotocol.Proposal, reason string) {
+	w.recordHistory(proposal, persistence.NewAgreementHistoryEntry(persistence.AG_HISTORY_PROPOSAL_REJECTED, w.ec.GetExchangeId(), reason))
+}
+
+// The pattern and the policy that the agbot matched with the node, and the services of the proposal.
+func proposalHistoryPolicies(tcPolicy *policy.Policy) ([]string, []string) {
+	policies := []string{}
+	if tcPolicy.PatternId != "" {
+		policies = append(policies, fmt.Sprintf("pattern %v", tcPolicy.PatternId))
+	}
+	if tcPolicy.Header.Name != "" {
+		policies = append(policies, tcPolicy.Header.Name)
+	}
+
+	services := []string{}
+	for _, wl := range tcPolicy.Workloads {
+		services = append(services, fmt.Sprintf("%v/%v version %v arch %v", wl.Org, wl.WorkloadURL, wl.Version, wl.Arch))
+	}
+	return policies, services
+}
+
 // This function gets the pattern and workload's signing keys and save them to anax
 func (w *BaseProducerProtocolHandler) saveSigningKeys(pol *policy.Policy, agreementId string, signingKeys *[]string) error {
 
@@ -498,6 +597,74 @@ func (w *BaseProducerProtocolHandler) MatchClusterNamespace(tcPolicy *policy.Pol
 	return true, nil
 }
 
+// check if the cluster has enough allocatable capacity for the resources requested by the operator deployment.
+// Returns false and the reason if the operator will not fit in the cluster.
+func (w *BaseProducerProtocolHandler) MatchClusterResources(tcPolicy *policy.Policy, dev *persistence.ExchangeDevice, agId string) (bool, string, error) {
+	if dev == nil {
+		return false, "", fmt.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("device is not configured to accept agreement yet.")))
+	} else if dev.GetNodeType() != persistence.DEVICE_TYPE_CLUSTER || len(tcPolicy.Workloads) == 0 || tcPolicy.Workloads[0].ClusterDeployment == "" {
+		return true, "", nil
+	}
+
+	workload := tcPolicy.Workloads[0]
+	if kd, err := persistence.GetKubeDeployment(workload.ClusterDeployment); err != nil {
+		return false, "", err
+	} else if client, err := kube_operator.NewKubeClient(); err != nil {
+		return false, "", err
+	} else if fits, reason, err := client.CheckResourceCapacity(kd.OperatorYamlArchive, kd.Metadata, agId); err != nil {
+		return false, "", err
+	} else {
+		if fits {
+			glog.V(5).Infof(BPPHlogString(w.Name(), fmt.Sprintf("cluster has enough resources for the operator in agreement %v.", agId)))
+		}
+		return fits, reason, nil
+	}
+}
+
+// Check if the device has room for the memory and CPU limits of the services in the proposal on top of the limits
+// reserved by the containers already running on the device. Services without limits do not reserve anything, and the
+// dependent services of the proposal are only counted once they are running.
+func (w *BaseProducerProtocolHandler) MatchDeviceResources(tcPolicy *policy.Policy, dev *persistence.ExchangeDevice, agId string) (bool, string, error) {
+	if dev.GetNodeType() == persistence.DEVICE_TYPE_CLUSTER || len(tcPolicy.Workloads) == 0 || tcPolicy.Workloads[0].Deployment == "" {
+		return true, "", nil
+	}
+
+	dd, err := containermessage.GetNativeDeployment(tcPolicy.Workloads[0].Deployment)
+	if err != nil {
+		glog.V(5).Infof(BPPHlogString(w.Name(), fmt.Sprintf("no container resources to check for agreement %v: %v", agId, err)))
+		return true, "", nil
+	}
+
+	memoryMb, cpus := dd.ResourceLimits()
+	if memoryMb == 0 && cpus == 0 {
+		return true, "", nil
+	}
+
+	reservedMemoryMb, reservedCPUs, err := persistence.TotalResourceReservations(w.db)
+	if err != nil {
+		return false, "", err
+	}
+
+	if memoryMb != 0 {
+		if totalMemoryMb, _, err := cutil.GetMemInfo(""); err != nil {
+			return false, "", err
+		} else if reservedMemoryMb+memoryMb > int64(totalMemoryMb) {
+			return false, fmt.Sprintf("the services need %vMB of memory, %vMB of the %vMB of the device is already reserved", memoryMb, reservedMemoryMb, totalMemoryMb), nil
+		}
+	}
+
+	if cpus != 0 {
+		if totalCPUs, err := cutil.GetCPUCount(""); err != nil {
+			return false, "", err
+		} else if reservedCPUs+cpus > float64(totalCPUs) {
+			return false, fmt.Sprintf("the services need %v CPUs, %v of the %v CPUs of the device are already reserved", cpus, reservedCPUs, totalCPUs), nil
+		}
+	}
+
+	glog.V(5).Infof(BPPHlogString(w.Name(), fmt.Sprintf("device has enough resources for the services in agreement %v.", agId)))
+	return true, "", nil
+}
+
 // check if the proposal has the same pattern
 func (w *BaseProducerProtocolHandler) MatchPattern(tcPolicy *policy.Policy, dev *persistence.ExchangeDevice) (bool, error) {
 	if dev == nil {
@@ -685,6 +852,7 @@ const TERM_REASON_SERVICE_SUSPENDED = "ServiceSuspended"
 const TERM_REASON_NODE_USERINPUT_CHANGED = "NodeUserInputChanged"
 const TERM_REASON_NODE_PATTERN_CHANGED = "NodePatternChanged"
 const TERM_FAILED_AGREEMENT_VERIFY = "FailedAgreementVerify"
+const TERM_REASON_PREEMPTED = "Preempted"
 
 // ==============================================================================================================
 type ExchangeMessageCommand struct {
@@ -0,0 +1,41 @@
+//go:build unit
+// +build unit
+
+package producer
+
+import (
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"testing"
+)
+
+func Test_MatchClusterResources(t *testing.T) {
+	w := &BaseProducerProtocolHandler{name: "test"}
+	clusterPolicy := func(clusterDeployment string) *policy.Policy {
+		return &policy.Policy{Workloads: []policy.Workload{{ClusterDeployment: clusterDeployment}}}
+	}
+
+	tests := []struct {
+		name   string
+		pol    *policy.Policy
+		dev    *persistence.ExchangeDevice
+		fits   bool
+		hasErr bool
+	}{
+		{"nil device", clusterPolicy(`{"operatorYamlArchive":"abc"}`), nil, false, true},
+		{"device node", clusterPolicy(`{"operatorYamlArchive":"abc"}`), &persistence.ExchangeDevice{NodeType: persistence.DEVICE_TYPE_DEVICE}, true, false},
+		{"node type not set", clusterPolicy(`{"operatorYamlArchive":"abc"}`), &persistence.ExchangeDevice{}, true, false},
+		{"no workloads", &policy.Policy{}, &persistence.ExchangeDevice{NodeType: persistence.DEVICE_TYPE_CLUSTER}, true, false},
+		{"no cluster deployment", clusterPolicy(""), &persistence.ExchangeDevice{NodeType: persistence.DEVICE_TYPE_CLUSTER}, true, false},
+		{"invalid cluster deployment", clusterPolicy(`{"metadata":{}}`), &persistence.ExchangeDevice{NodeType: persistence.DEVICE_TYPE_CLUSTER}, false, true},
+	}
+
+	for _, test := range tests {
+		fits, reason, err := w.MatchClusterResources(test.pol, test.dev, "ag1")
+		if (err != nil) != test.hasErr {
+			t.Errorf("%v: expected error %v, but got %v", test.name, test.hasErr, err)
+		} else if fits != test.fits {
+			t.Errorf("%v: expected fits %v, but got %v with reason %v", test.name, test.fits, fits, reason)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package propertyprovider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/externalpolicy"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A node property provider that runs an external program. The program writes the properties to its stdout as a json
+// array of {"name": ..., "value": ...}, which are validated against the schema of the provider in the agent config.
+type ExecProvider struct {
+	cfg        config.NodePropertyProvider
+	properties externalpolicy.PropertyList
+	lock       sync.Mutex
+}
+
+func NewExecProvider(cfg config.NodePropertyProvider) (*ExecProvider, error) {
+	validTypes := []string{externalpolicy.STRING_TYPE, externalpolicy.VERSION_TYPE, externalpolicy.BOOLEAN_TYPE, externalpolicy.INTEGER_TYPE, externalpolicy.FLOAT_TYPE, externalpolicy.LIST_TYPE}
+	for name, propType := range cfg.Properties {
+		if !cutil.SliceContains(validTypes, propType) {
+			return nil, fmt.Errorf("property %v of node property provider %v has invalid type %v, allowed types are %v", name, cfg.Name, propType, validTypes)
+		}
+	}
+
+	return &ExecProvider{
+		cfg:        cfg,
+		properties: externalpolicy.PropertyList{},
+	}, nil
+}
+
+func (p *ExecProvider) GetName() string {
+	return p.cfg.Name
+}
+
+func (p *ExecProvider) GetIntervalS() int {
+	return p.cfg.IntervalS
+}
+
+func (p *ExecProvider) PropertyNames() []string {
+	names := make([]string, 0, len(p.cfg.Properties))
+	for name := range p.cfg.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (p *ExecProvider) GetProperties() externalpolicy.PropertyList {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return externalpolicy.CopyProperties(p.properties)
+}
+
+// Run the program and save the properties it writes. The properties from the last successful run are kept when the
+// program fails or writes properties that do not match the schema.
+func (p *ExecProvider) Refresh() (bool, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(p.cfg.Command, p.cfg.Args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// run the program in its own process group so that the processes it started are killed with it on a timeout,
+	// otherwise they keep its stdout open.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return false, fmt.Errorf("node property provider %v failed to start: %v", p.cfg.Name, err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return false, fmt.Errorf("node property provider %v failed: %v, stderr: %v", p.cfg.Name, err, strings.TrimSpace(stderr.String()))
+		}
+	case <-time.After(time.Duration(p.cfg.TimeoutS) * time.Second):
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		return false, fmt.Errorf("node property provider %v timed out after %v seconds", p.cfg.Name, p.cfg.TimeoutS)
+	}
+
+	props, err := ValidateProperties(stdout.Bytes(), p.cfg.Properties)
+	if err != nil {
+		return false, fmt.Errorf("node property provider %v: %v", p.cfg.Name, err)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if props.IsSame(p.properties) {
+		return false, nil
+	}
+	glog.V(3).Infof(provLogString(fmt.Sprintf("node property provider %v changed the properties from %v to %v", p.cfg.Name, p.properties.ShortString(), props.ShortString())))
+	p.properties = props
+	return true, nil
+}
+
+// Parse the output of a provider and validate the properties in it against the schema of the provider, the type of
+// each property name it can contribute. The declared types are set on the returned properties.
+func ValidateProperties(output []byte, schema map[string]string) (externalpolicy.PropertyList, error) {
+	var props externalpolicy.PropertyList
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	if err := decoder.Decode(&props); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal the properties %v. %v", string(output), err)
+	}
+
+	validated := externalpolicy.PropertyList{}
+	for _, prop := range props {
+		propType, ok := schema[prop.Name]
+		if !ok {
+			return nil, fmt.Errorf("property %v is not in the schema of the provider", prop.Name)
+		} else if prop.Type != externalpolicy.UNDECLARED_TYPE && prop.Type != propType {
+			return nil, fmt.Errorf("property %v has type %v, the schema of the provider declares %v", prop.Name, prop.Type, propType)
+		}
+		prop.Type = propType
+		if err := validated.Add_Property(&prop, false); err != nil {
+			return nil, err
+		}
+	}
+	return validated, nil
+}
+
+// Create the node property providers in the agent config, get their properties for the first time and register them
+// in the global registry of the externalpolicy package, so that their properties are merged into the node policy.
+func InitNodePropertyProviders(cfg *config.HorizonConfig) error {
+	for _, provCfg := range cfg.Edge.NodePropertyProviders {
+		p, err := NewExecProvider(provCfg)
+		if err != nil {
+			return err
+		}
+		if _, err := p.Refresh(); err != nil {
+			glog.Errorf(provLogString(fmt.Sprintf("unable to get the initial properties. %v", err)))
+		}
+		glog.V(3).Infof(provLogString(fmt.Sprintf("registered node property provider %v for properties %v", p.GetName(), p.PropertyNames())))
+		externalpolicy.RegisterNodePropertyProvider(p)
+	}
+	return nil
+}
+
+var provLogString = func(v interface{}) string {
+	return fmt.Sprintf("NodePropertyProvider: %v", v)
+}
@@ -0,0 +1,99 @@
+//go:build unit
+// +build unit
+
+package propertyprovider
+
+import (
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/externalpolicy"
+	"testing"
+	"time"
+)
+
+func Test_ValidateProperties(t *testing.T) {
+	schema := map[string]string{"plc.model": externalpolicy.STRING_TYPE, "plc.slots": externalpolicy.INTEGER_TYPE, "tpm.attested": externalpolicy.BOOLEAN_TYPE}
+
+	if props, err := ValidateProperties([]byte(`[{"name":"plc.model","value":"S7-1500"},{"name":"plc.slots","value":4},{"name":"tpm.attested","value":true}]`), schema); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if len(props) != 3 {
+		t.Errorf("should have 3 properties, but got %v", props)
+	} else if prop, _ := props.GetProperty("plc.slots"); prop.Type != externalpolicy.INTEGER_TYPE {
+		t.Errorf("property plc.slots should have the type of the schema, but got %v", prop)
+	}
+
+	for _, bad := range []string{
+		`not json`,
+		`[{"name":"plc.firmware","value":"1.0"}]`,
+		`[{"name":"plc.slots","value":4.5}]`,
+		`[{"name":"tpm.attested","value":"yes"}]`,
+		`[{"name":"plc.model","value":"S7-1500","type":"version"}]`,
+		`[{"name":"plc.model","value":"S7-1500"},{"name":"plc.model","value":"S7-1200"}]`,
+	} {
+		if _, err := ValidateProperties([]byte(bad), schema); err == nil {
+			t.Errorf("should have returned an error for %v", bad)
+		}
+	}
+}
+
+func Test_ExecProvider(t *testing.T) {
+	if _, err := NewExecProvider(config.NodePropertyProvider{Name: "plc", Command: "/bin/sh", Properties: map[string]string{"plc.model": "text"}}); err == nil {
+		t.Errorf("should have returned an error for an invalid property type")
+	}
+
+	cfg := config.NodePropertyProvider{Name: "plc", Command: "/bin/sh", Args: []string{"-c", `echo '[{"name":"plc.model","value":"S7-1500"}]'`}, TimeoutS: 5,
+		Properties: map[string]string{"plc.model": externalpolicy.STRING_TYPE}}
+	p, err := NewExecProvider(cfg)
+	if err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if changed, err := p.Refresh(); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if !changed {
+		t.Errorf("the first refresh should change the properties")
+	} else if props := p.GetProperties(); len(props) != 1 || props[0].Value != "S7-1500" {
+		t.Errorf("should have property plc.model, but got %v", props)
+	} else if changed, err := p.Refresh(); err != nil || changed {
+		t.Errorf("the second refresh should not change the properties, but got %v %v", changed, err)
+	}
+
+	// a failed run keeps the properties of the last successful run
+	p.cfg.Args = []string{"-c", "echo 'no PLC attached' >&2; exit 1"}
+	if _, err := p.Refresh(); err == nil {
+		t.Errorf("should have returned an error for a failed run")
+	} else if props := p.GetProperties(); len(props) != 1 {
+		t.Errorf("should still have property plc.model, but got %v", props)
+	}
+
+	p.cfg.Args = []string{"-c", "sleep 5"}
+	p.cfg.TimeoutS = 1
+	start := time.Now()
+	if _, err := p.Refresh(); err == nil {
+		t.Errorf("should have returned an error for a run that timed out")
+	} else if time.Since(start) > 4*time.Second {
+		t.Errorf("the run should have been killed after 1 second")
+	}
+}
+
+func Test_ExecProvider_dynamic_properties(t *testing.T) {
+	defer externalpolicy.ClearNodePropertyProviders()
+
+	cfg := config.NodePropertyProvider{Name: "tpm", Command: "/bin/sh", Args: []string{"-c", `echo '[{"name":"tpm.attested","value":true}]'`}, TimeoutS: 5,
+		Properties: map[string]string{"tpm.attested": externalpolicy.BOOLEAN_TYPE}}
+	p, err := NewExecProvider(cfg)
+	if err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	} else if _, err := p.Refresh(); err != nil {
+		t.Errorf("should not return error, but got %v", err)
+	}
+	externalpolicy.RegisterNodePropertyProvider(p)
+
+	props := externalpolicy.CreateNodeDynamicProperties(time.Now())
+	if prop, err := props.GetProperty("tpm.attested"); err != nil || prop.Value != true {
+		t.Errorf("the dynamic properties should have tpm.attested, but got %v", props)
+	}
+
+	nodeProps := externalpolicy.PropertyList{*externalpolicy.Property_Factory("tpm.attested", true), *externalpolicy.Property_Factory("camera", true)}
+	builtInProps := externalpolicy.PropertyList{}
+	if !externalpolicy.RemoveStaleDynamicProperties(&nodeProps, &builtInProps) || nodeProps.HasProperty("tpm.attested") || !nodeProps.HasProperty("camera") {
+		t.Errorf("only the stale provider property should be removed, but got %v", nodeProps)
+	}
+}
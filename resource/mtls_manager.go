@@ -0,0 +1,229 @@
+package resource
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/cutil"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"os/user"
+	"path"
+	"strconv"
+	"time"
+)
+
+const (
+	mtlsCADir          = "CA"
+	mtlsCAKeyFile      = "ca-key.pem"
+	mtlsCADaysValidFor = 3650
+)
+
+// The MTLSManager issues the certificates that the service containers use to authenticate each other with mutual TLS.
+// The certificates are signed by a CA that is private to the node. Each agreement and service instance gets its own
+// certificate, key and a copy of the CA certificate in a directory named by its key, which is mounted into its containers.
+type MTLSManager struct {
+	Path string
+}
+
+func NewMTLSManager(mtlsPath string) *MTLSManager {
+	return &MTLSManager{
+		Path: mtlsPath,
+	}
+}
+
+func (m MTLSManager) String() string {
+	return fmt.Sprintf("MTLS Manager: "+
+		"Path: %v", m.Path)
+}
+
+func (m *MTLSManager) GetCertificatePath(key string) string {
+	return path.Join(m.Path, key)
+}
+
+// Create the certificate of an agreement or a service instance and write it, with its key and the CA certificate, into
+// the Agent's host file system. The certificate is issued to the service identity and is valid for the host names of the
+// services in the deployment. For secure certificates, the files can only be read by the group of the agreement, the same
+// group as the FSS authentication credential of the agreement.
+func (m *MTLSManager) CreateCertificate(key string, commonName string, dnsNames []string, secure bool) error {
+	ca, caKey, err := m.loadOrCreateCA()
+	if err != nil {
+		return err
+	}
+
+	priv, err := rsa.GenerateKey(rand.Reader, rsaBits)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to generate private key for the certificate of %v, error: %v", key, err))
+	}
+
+	notBefore := time.Now()
+	template := x509.Certificate{
+		SerialNumber: newSerialNumber(),
+		Subject: pkix.Name{
+			OrganizationalUnit: []string{"Edge Service"},
+			CommonName:         commonName,
+		},
+		NotBefore:   notBefore,
+		NotAfter:    notBefore.Add(daysValidFor * 24 * time.Hour),
+		KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:    dnsNames,
+	}
+
+	derBytes, err := x509.CreateCertificate(rand.Reader, &template, ca, &priv.PublicKey, caKey)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to create the certificate of %v, error: %v", key, err))
+	}
+
+	var dirMode, fileMode os.FileMode
+	uid, gid := -1, -1
+	if secure {
+		if currUser, err := user.Current(); err != nil {
+			return errors.New("unable to get current OS user")
+		} else if uid, err = strconv.Atoi(currUser.Uid); err != nil {
+			return errors.New("unable to convert current user uid from string to int")
+		}
+
+		groupName := cutil.GetHashFromString(key)
+		if group, err := user.LookupGroup(groupName); err != nil {
+			return errors.New(fmt.Sprintf("unable to find group %v created for %v", groupName, key))
+		} else if gid, err = strconv.Atoi(group.Gid); err != nil {
+			return errors.New(fmt.Sprintf("failed to get group id %v as string, error: %v", group.Gid, err))
+		}
+		dirMode, fileMode = 0750, 0640
+	} else {
+		dirMode, fileMode = 0755, 0644
+	}
+
+	certDir := m.GetCertificatePath(key)
+	files := map[string][]byte{
+		config.HZN_MTLS_CA_FILE:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}),
+		config.HZN_MTLS_CERT_FILE: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes}),
+		config.HZN_MTLS_KEY_FILE:  pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)}),
+	}
+
+	if err := os.MkdirAll(certDir, dirMode); err != nil {
+		return errors.New(fmt.Sprintf("unable to create directory path %v for the certificate, error: %v", certDir, err))
+	} else if secure {
+		if err := os.Chown(certDir, uid, gid); err != nil {
+			return errors.New(fmt.Sprintf("unable to change group to %v for the certificate folder %v, error: %v", gid, certDir, err))
+		}
+	}
+
+	for name, content := range files {
+		fileName := path.Join(certDir, name)
+		if err := ioutil.WriteFile(fileName, content, fileMode); err != nil {
+			return errors.New(fmt.Sprintf("unable to write certificate file %v, error: %v", fileName, err))
+		} else if secure {
+			if err := os.Chown(fileName, uid, gid); err != nil {
+				return errors.New(fmt.Sprintf("unable to change group to %v for the certificate file %v, error: %v", gid, fileName, err))
+			}
+		}
+	}
+
+	glog.V(5).Infof(mtlsLogString(fmt.Sprintf("Created certificate for %v, issued to %v for %v.", key, commonName, dnsNames)))
+	return nil
+}
+
+// Remove the certificate of an agreement or a service instance from the Agent's host file system.
+func (m *MTLSManager) RemoveCertificate(key string) error {
+	if err := os.RemoveAll(m.GetCertificatePath(key)); err != nil {
+		return errors.New(fmt.Sprintf("unable to remove certificate directory %v, error: %v", m.GetCertificatePath(key), err))
+	}
+	glog.V(5).Infof(mtlsLogString(fmt.Sprintf("Removed certificate for %v.", key)))
+	return nil
+}
+
+// Remove all the certificates and the CA, a new CA is created for the next certificate.
+func (m *MTLSManager) RemoveAll() error {
+	if err := os.RemoveAll(m.Path); err != nil {
+		return errors.New(fmt.Sprintf("unable to remove certificate directory %v, error: %v", m.Path, err))
+	}
+	return nil
+}
+
+// Load the CA of the node, creating it the first time. The CA key never leaves the CA directory, only the CA certificate
+// is given to the services.
+func (m *MTLSManager) loadOrCreateCA() (*x509.Certificate, *rsa.PrivateKey, error) {
+	caDir := path.Join(m.Path, mtlsCADir)
+	certFile := path.Join(caDir, config.HZN_MTLS_CA_FILE)
+	keyFile := path.Join(caDir, mtlsCAKeyFile)
+
+	if certPEM, err := ioutil.ReadFile(certFile); err == nil {
+		keyPEM, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, nil, errors.New(fmt.Sprintf("unable to read CA key %v, error: %v", keyFile, err))
+		}
+		certBlock, _ := pem.Decode(certPEM)
+		keyBlock, _ := pem.Decode(keyPEM)
+		if certBlock == nil || keyBlock == nil {
+			return nil, nil, errors.New(fmt.Sprintf("unable to decode CA in %v", caDir))
+		} else if ca, err := x509.ParseCertificate(certBlock.Bytes); err != nil {
+			return nil, nil, errors.New(fmt.Sprintf("unable to parse CA certificate %v, error: %v", certFile, err))
+		} else if caKey, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes); err != nil {
+			return nil, nil, errors.New(fmt.Sprintf("unable to parse CA key %v, error: %v", keyFile, err))
+		} else {
+			return ca, caKey, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, nil, errors.New(fmt.Sprintf("unable to read CA certificate %v, error: %v", certFile, err))
+	}
+
+	caKey, err := rsa.GenerateKey(rand.Reader, rsaBits)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("unable to generate private key for the CA, error: %v", err))
+	}
+
+	notBefore := time.Now()
+	template := x509.Certificate{
+		SerialNumber: newSerialNumber(),
+		Subject: pkix.Name{
+			OrganizationalUnit: []string{"Edge Node"},
+			CommonName:         "Horizon service CA",
+		},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(mtlsCADaysValidFor * 24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("unable to create CA certificate, error: %v", err))
+	}
+	ca, err := x509.ParseCertificate(derBytes)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("unable to parse CA certificate, error: %v", err))
+	}
+
+	if err := os.MkdirAll(caDir, 0700); err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("unable to create directory path %v for the CA, error: %v", caDir, err))
+	} else if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(caKey)}), 0600); err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("unable to write CA key %v, error: %v", keyFile, err))
+	} else if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes}), 0644); err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("unable to write CA certificate %v, error: %v", certFile, err))
+	}
+
+	glog.V(3).Infof(mtlsLogString(fmt.Sprintf("Created service CA in %v.", caDir)))
+	return ca, caKey, nil
+}
+
+func newSerialNumber() *big.Int {
+	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
+	if serialNumber, err := rand.Int(rand.Reader, serialNumberLimit); err == nil {
+		return serialNumber
+	}
+	return big.NewInt(time.Now().UnixNano())
+}
+
+var mtlsLogString = func(v interface{}) string {
+	return fmt.Sprintf("MTLS Manager: %v", v)
+}
@@ -43,6 +43,11 @@ func (s SecretsManager) ProcessServiceSecretsWithInstanceId(agId string, msInstK
 }
 
 func (s SecretsManager) ProcessServiceSecretUpdates(agId string, updatedSecList []persistence.PersistedServiceSecret) error {
+	// The agreement secrets are used by the cluster services, which have no microservice instance.
+	if _, err := persistence.UpdateAgreementSecrets(s.db, agId, updatedSecList); err != nil {
+		return err
+	}
+
 	for _, updatedSec := range updatedSecList {
 		existingSvcSecList, err := persistence.FindAllServiceSecretsWithSpecs(s.db, updatedSec.SvcUrl, updatedSec.SvcOrgid)
 		if err != nil {
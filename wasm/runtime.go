@@ -0,0 +1,415 @@
+package wasm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/persistence"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The states of a module reported in the node status, in the place of the state of a container.
+const (
+	MODULE_RUNNING     = "running"
+	MODULE_EXITED      = "exited"
+	MODULE_NOT_STARTED = "not started"
+)
+
+// How long a module has to exit after it is sent a SIGTERM before it is killed.
+const STOP_TIMEOUT = 10 * time.Second
+
+// The size of the log of a module above which it is moved to the .1 file, the previous .1 file is dropped.
+const MAX_LOG_SIZE = 10 * 1024 * 1024
+
+// The delay before the first restart of a module that exited, it doubles with each restart up to RESTART_BACKOFF_MAX.
+const RESTART_BACKOFF = time.Second
+const RESTART_BACKOFF_MAX = time.Minute
+
+// The command line and environment a module is run with. It is saved in the state dir so that the module can be
+// restarted after the agent itself restarted.
+type runSpec struct {
+	Args          []string                        `json:"args"`
+	Env           []string                        `json:"env"`
+	RestartPolicy *containermessage.RestartPolicy `json:"restart_policy,omitempty"`
+}
+
+// A module run by the runtime. The process is replaced each time the module is restarted.
+type process struct {
+	spec     runSpec
+	pid      int
+	start    uint64 // the start time of the process, in clock ticks since boot
+	restarts int
+	exited   bool  // exited and not restarted
+	exitErr  error // why the module is not restarted
+	stopped  bool  // being stopped by the agent
+}
+
+// Runtime runs the WebAssembly modules of the agreements with the wasmtime CLI and restarts them when they exit,
+// according to their restart policy, the way the container engine restarts the service containers. The files of a
+// module are kept in the state dir, named after the agreement id.
+type Runtime struct {
+	path     string
+	stateDir string
+	lock     sync.Mutex
+	procs    map[string]*process
+}
+
+func NewRuntime(path string, stateDir string) *Runtime {
+	return &Runtime{
+		path:     path,
+		stateDir: stateDir,
+		procs:    make(map[string]*process),
+	}
+}
+
+// Start writes the module of an agreement to the state dir and runs it with the environment variables of the service.
+func (r *Runtime) Start(id string, wd *persistence.WasmDeploymentConfig, env map[string]string) error {
+	module, err := base64.StdEncoding.DecodeString(wd.WasmModule)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to decode the module of %v: %v", id, err))
+	} else if err := os.MkdirAll(r.stateDir, 0700); err != nil {
+		return errors.New(fmt.Sprintf("unable to create the state dir %v: %v", r.stateDir, err))
+	} else if err := os.WriteFile(modulePath(r.stateDir, id), module, 0600); err != nil {
+		return errors.New(fmt.Sprintf("unable to write the module of %v: %v", id, err))
+	}
+
+	spec := runSpec{
+		Args:          runArgs(wd, modulePath(r.stateDir, id), env),
+		Env:           runEnv(env),
+		RestartPolicy: wd.RestartPolicy,
+	}
+	if specBytes, err := json.Marshal(spec); err != nil {
+		return errors.New(fmt.Sprintf("unable to marshal the run spec of %v: %v", id, err))
+	} else if err := os.WriteFile(specPath(r.stateDir, id), specBytes, 0600); err != nil {
+		return errors.New(fmt.Sprintf("unable to write the run spec of %v: %v", id, err))
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if p, ok := r.procs[id]; ok && !p.exited {
+		return errors.New(fmt.Sprintf("the module of %v is already running", id))
+	}
+	p := &process{spec: spec}
+	r.procs[id] = p
+	return r.run(id, p)
+}
+
+// Stop stops the module of an agreement and removes its files. The module is killed if it does not exit in time. The
+// signals are sent to the process group of the module, and only when the process is still the one that was started for
+// the module, so that a process that reused the pid is not stopped.
+func (r *Runtime) Stop(id string) error {
+	r.lock.Lock()
+	pid, start := 0, uint64(0)
+	if p, ok := r.procs[id]; ok {
+		p.stopped = true
+		pid, start = p.pid, p.start
+		delete(r.procs, id)
+	} else {
+		// The agent restarted since the module was started.
+		pid, start = readPid(r.stateDir, id)
+	}
+	r.lock.Unlock()
+
+	var err error
+	if moduleAlive(r.stateDir, id, pid, start) {
+		glog.V(3).Infof(wwlog(fmt.Sprintf("stopping the module of %v, pid %v", id, pid)))
+		syscall.Kill(-pid, syscall.SIGTERM)
+		for begin := time.Now(); moduleAlive(r.stateDir, id, pid, start) && time.Since(begin) < STOP_TIMEOUT; {
+			time.Sleep(100 * time.Millisecond)
+		}
+		if moduleAlive(r.stateDir, id, pid, start) {
+			if kerr := syscall.Kill(-pid, syscall.SIGKILL); kerr != nil {
+				err = errors.New(fmt.Sprintf("unable to kill the module of %v, pid %v: %v", id, pid, kerr))
+			}
+		}
+	}
+
+	for _, file := range []string{modulePath(r.stateDir, id), specPath(r.stateDir, id), pidPath(r.stateDir, id), LogPath(r.stateDir, id), LogPath(r.stateDir, id) + ".1"} {
+		if rerr := os.Remove(file); rerr != nil && !os.IsNotExist(rerr) {
+			glog.Warningf(wwlog(fmt.Sprintf("unable to remove %v: %v", file, rerr)))
+		}
+	}
+	return err
+}
+
+// Maintain returns an error if the module of an agreement exited and is not restarted. A module that was started
+// before the agent restarted is only checked, it is restarted from its run spec if it is no longer running. The log of
+// the module is rotated when it is too large.
+func (r *Runtime) Maintain(id string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	rotateLog(LogPath(r.stateDir, id))
+
+	if p, ok := r.procs[id]; ok {
+		if p.exited {
+			return errors.New(fmt.Sprintf("the module of %v exited and is not restarted: %v", id, p.exitErr))
+		}
+		return nil
+	}
+
+	if pid, start := readPid(r.stateDir, id); moduleAlive(r.stateDir, id, pid, start) {
+		return nil
+	}
+	spec, err := readSpec(r.stateDir, id)
+	if err != nil {
+		return errors.New(fmt.Sprintf("the module of %v is not running and cannot be restarted: %v", id, err))
+	} else if !shouldRestart(spec.RestartPolicy, errors.New("exited"), 0) {
+		return errors.New(fmt.Sprintf("the module of %v exited and is not restarted, restart policy %v", id, spec.RestartPolicy))
+	}
+	glog.V(3).Infof(wwlog(fmt.Sprintf("restarting the module of %v, it exited while the agent was not running", id)))
+	p := &process{spec: *spec}
+	r.procs[id] = p
+	return r.run(id, p)
+}
+
+// Runs the module process in its own process group, with its output appended to the log file of the module. The caller
+// holds the lock.
+func (r *Runtime) run(id string, p *process) error {
+	rotateLog(LogPath(r.stateDir, id))
+	logFile, err := os.OpenFile(LogPath(r.stateDir, id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to open the log of %v: %v", id, err))
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(r.path, p.spec.Args...)
+	cmd.Env = p.spec.Env
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		p.exited = true
+		p.exitErr = err
+		return errors.New(fmt.Sprintf("unable to run the module of %v with %v: %v", id, r.path, err))
+	}
+
+	p.pid = cmd.Process.Pid
+	p.start, _ = processStart(p.pid)
+	p.exited = false
+	if err := os.WriteFile(pidPath(r.stateDir, id), []byte(fmt.Sprintf("%v %v", p.pid, p.start)), 0600); err != nil {
+		glog.Warningf(wwlog(fmt.Sprintf("unable to write the pid file of %v: %v", id, err)))
+	}
+	glog.V(3).Infof(wwlog(fmt.Sprintf("started the module of %v, pid %v", id, p.pid)))
+
+	go r.supervise(id, p, cmd)
+	return nil
+}
+
+// Waits for the module process to exit and restarts it if its restart policy says so.
+func (r *Runtime) supervise(id string, p *process, cmd *exec.Cmd) {
+	exitErr := cmd.Wait()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if p.stopped || r.procs[id] != p {
+		return
+	} else if !shouldRestart(p.spec.RestartPolicy, exitErr, p.restarts) {
+		glog.Warningf(wwlog(fmt.Sprintf("the module of %v exited, error: %v, it is not restarted", id, exitErr)))
+		p.exited = true
+		p.exitErr = exitErr
+		return
+	}
+
+	p.restarts++
+	delay := restartBackoff(p.restarts)
+	glog.Warningf(wwlog(fmt.Sprintf("the module of %v exited, error: %v, restarting it in %v", id, exitErr, delay)))
+	time.AfterFunc(delay, func() {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		if p.stopped || r.procs[id] != p {
+			return
+		} else if err := r.run(id, p); err != nil {
+			glog.Errorf(wwlog(err.Error()))
+		}
+	})
+}
+
+// GetModuleStatus returns the state of the module of an agreement from its pid file, and the time it was started.
+func GetModuleStatus(stateDir string, id string) (string, int64) {
+	info, err := os.Stat(pidPath(stateDir, id))
+	if err != nil {
+		return MODULE_NOT_STARTED, 0
+	} else if pid, start := readPid(stateDir, id); !moduleAlive(stateDir, id, pid, start) {
+		return MODULE_EXITED, info.ModTime().Unix()
+	}
+	return MODULE_RUNNING, info.ModTime().Unix()
+}
+
+// Returns true when a module that exited is restarted. The modules are always restarted unless the service asks
+// otherwise, a module that exited with an error is restarted by the on-failure policy until max_retries is reached.
+func shouldRestart(rp *containermessage.RestartPolicy, exitErr error, restarts int) bool {
+	if rp == nil {
+		return true
+	}
+	switch rp.Name {
+	case containermessage.RESTART_POLICY_NO:
+		return false
+	case containermessage.RESTART_POLICY_ON_FAILURE:
+		return exitErr != nil && (rp.MaxRetries == 0 || restarts < rp.MaxRetries)
+	default:
+		return true
+	}
+}
+
+func restartBackoff(restarts int) time.Duration {
+	delay := RESTART_BACKOFF
+	for i := 1; i < restarts && delay < RESTART_BACKOFF_MAX; i++ {
+		delay *= 2
+	}
+	if delay > RESTART_BACKOFF_MAX {
+		delay = RESTART_BACKOFF_MAX
+	}
+	return delay
+}
+
+// Returns the wasmtime arguments that run a module. The environment variables are passed by name so that their values,
+// e.g. the user input of the service, do not show on the command line of the process.
+func runArgs(wd *persistence.WasmDeploymentConfig, module string, env map[string]string) []string {
+	args := []string{"run"}
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "--env", name)
+	}
+
+	for _, bind := range wd.Binds {
+		dirs := strings.SplitN(bind, ":", 2)
+		if len(dirs) == 1 || dirs[1] == "" {
+			args = append(args, "--dir", dirs[0])
+		} else {
+			args = append(args, "--dir", dirs[0]+"::"+dirs[1])
+		}
+	}
+
+	if wd.MaxMemoryMB != 0 {
+		args = append(args, "-W", fmt.Sprintf("max-memory-size=%v", wd.MaxMemoryMB*1024*1024))
+	}
+
+	args = append(args, module)
+	return append(args, wd.Args...)
+}
+
+// Returns the environment of the wasmtime process, from which the environment variables of the module are taken.
+func runEnv(env map[string]string) []string {
+	runEnv := make([]string, 0, len(env)+1)
+	if path, ok := os.LookupEnv("PATH"); ok {
+		runEnv = append(runEnv, "PATH="+path)
+	}
+	for name, value := range env {
+		runEnv = append(runEnv, name+"="+value)
+	}
+	sort.Strings(runEnv)
+	return runEnv
+}
+
+// Returns true when the process is running the module of the agreement, i.e. the process has the start time saved when
+// the module was started and its command line has the module file. A pid in the pid file can have been reused by another
+// process since the module exited, e.g. after a reboot.
+func moduleAlive(stateDir string, id string, pid int, start uint64) bool {
+	if pid <= 0 {
+		return false
+	} else if procStart, err := processStart(pid); err != nil || (start != 0 && procStart != start) {
+		return false
+	} else if cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%v/cmdline", pid)); err != nil {
+		return false
+	} else {
+		for _, arg := range strings.Split(string(cmdline), "\x00") {
+			if arg == modulePath(stateDir, id) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Returns the start time of a process, in clock ticks since boot, which is field 22 of /proc/<pid>/stat. The fields are
+// counted after the command name, which is in parentheses and can contain spaces.
+func processStart(pid int) (uint64, error) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%v/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(stat[strings.LastIndex(string(stat), ")")+1:]))
+	if len(fields) < 20 {
+		return 0, errors.New(fmt.Sprintf("unexpected /proc/%v/stat: %s", pid, stat))
+	}
+	return strconv.ParseUint(fields[19], 10, 64)
+}
+
+// Returns the pid and the start time of the module process from the pid file. The start time is 0 in the pid files
+// written before it was saved.
+func readPid(stateDir string, id string) (int, uint64) {
+	pidBytes, err := os.ReadFile(pidPath(stateDir, id))
+	if err != nil {
+		return 0, 0
+	}
+	fields := strings.Fields(string(pidBytes))
+	if len(fields) == 0 {
+		return 0, 0
+	} else if pid, err := strconv.Atoi(fields[0]); err != nil {
+		return 0, 0
+	} else if len(fields) == 1 {
+		return pid, 0
+	} else if start, err := strconv.ParseUint(fields[1], 10, 64); err != nil {
+		return 0, 0
+	} else {
+		return pid, start
+	}
+}
+
+// Moves the log of a module to the .1 file when it is larger than MAX_LOG_SIZE. The module keeps its log file open in
+// append mode, so the log is copied and truncated rather than renamed. The output written during the copy is lost.
+func rotateLog(logPath string) {
+	if info, err := os.Stat(logPath); err != nil || info.Size() <= MAX_LOG_SIZE {
+		return
+	} else if out, err := os.ReadFile(logPath); err != nil {
+		glog.Warningf(wwlog(fmt.Sprintf("unable to read the log %v: %v", logPath, err)))
+	} else if err := os.WriteFile(logPath+".1", out, 0600); err != nil {
+		glog.Warningf(wwlog(fmt.Sprintf("unable to write the log %v.1: %v", logPath, err)))
+	} else if err := os.Truncate(logPath, 0); err != nil {
+		glog.Warningf(wwlog(fmt.Sprintf("unable to truncate the log %v: %v", logPath, err)))
+	}
+}
+
+func readSpec(stateDir string, id string) (*runSpec, error) {
+	spec := new(runSpec)
+	if specBytes, err := os.ReadFile(specPath(stateDir, id)); err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(specBytes, spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func modulePath(stateDir string, id string) string {
+	return filepath.Join(stateDir, id+".wasm")
+}
+
+func specPath(stateDir string, id string) string {
+	return filepath.Join(stateDir, id+".json")
+}
+
+func pidPath(stateDir string, id string) string {
+	return filepath.Join(stateDir, id+".pid")
+}
+
+// LogPath returns the file the output of the module of an agreement is written to.
+func LogPath(stateDir string, id string) string {
+	return filepath.Join(stateDir, id+".log")
+}
@@ -0,0 +1,186 @@
+//go:build unit
+// +build unit
+
+package wasm
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/persistence"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	// Enable glog tracing in the tested functions. The output will be displayed when -v is
+	// passed on the go test command.
+	flag.Set("alsologtostderr", "true")
+	flag.Set("v", "7")
+	// no need to parse flags, that's done by test framework
+}
+
+func Test_runArgs(t *testing.T) {
+
+	wd := &persistence.WasmDeploymentConfig{
+		WasmModule:  "AGFzbQEAAAA=",
+		Args:        []string{"--port", "8080"},
+		Binds:       []string{"/var/data:/data", "/tmp"},
+		MaxMemoryMB: 2,
+	}
+	env := map[string]string{"HZN_AGREEMENTID": "aaaa", "MY_INPUT": "secret value"}
+
+	args := runArgs(wd, "/var/horizon/wasm/aaaa.wasm", env)
+	expected := []string{"run", "--env", "HZN_AGREEMENTID", "--env", "MY_INPUT", "--dir", "/var/data::/data", "--dir", "/tmp",
+		"-W", "max-memory-size=2097152", "/var/horizon/wasm/aaaa.wasm", "--port", "8080"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("wrong args %v, expected %v", args, expected)
+	}
+
+	// The values of the environment variables are not on the command line.
+	for _, arg := range args {
+		if strings.Contains(arg, "secret") {
+			t.Errorf("the args %v contain the value of an environment variable", args)
+		}
+	}
+
+	runEnv := runEnv(env)
+	if len(runEnv) < 2 || !strings.Contains(strings.Join(runEnv, ","), "MY_INPUT=secret value") {
+		t.Errorf("wrong environment %v", runEnv)
+	}
+}
+
+func Test_shouldRestart(t *testing.T) {
+
+	failed := errors.New("exit status 1")
+
+	if !shouldRestart(nil, nil, 5) {
+		t.Errorf("a module without a restart policy should always be restarted")
+	} else if !shouldRestart(&containermessage.RestartPolicy{Name: containermessage.RESTART_POLICY_ALWAYS}, nil, 5) {
+		t.Errorf("the always restart policy should restart the module")
+	} else if shouldRestart(&containermessage.RestartPolicy{Name: containermessage.RESTART_POLICY_NO}, failed, 0) {
+		t.Errorf("the no restart policy should not restart the module")
+	}
+
+	onFailure := &containermessage.RestartPolicy{Name: containermessage.RESTART_POLICY_ON_FAILURE, MaxRetries: 2}
+	if shouldRestart(onFailure, nil, 0) {
+		t.Errorf("the on-failure restart policy should not restart a module that exited successfully")
+	} else if !shouldRestart(onFailure, failed, 1) {
+		t.Errorf("the on-failure restart policy should restart a module that failed")
+	} else if shouldRestart(onFailure, failed, 2) {
+		t.Errorf("the on-failure restart policy should stop restarting the module after max_retries")
+	}
+
+	if d := restartBackoff(1); d != RESTART_BACKOFF {
+		t.Errorf("wrong first restart delay %v", d)
+	} else if d := restartBackoff(3); d != 4*RESTART_BACKOFF {
+		t.Errorf("wrong third restart delay %v", d)
+	} else if d := restartBackoff(20); d != RESTART_BACKOFF_MAX {
+		t.Errorf("wrong restart delay %v, expected the max", d)
+	}
+}
+
+func Test_StartStop(t *testing.T) {
+
+	// A fake wasmtime that prints the environment variable of the module and keeps running, with the module file on its
+	// command line.
+	dir := t.TempDir()
+	wasmtime := filepath.Join(dir, "wasmtime")
+	if err := os.WriteFile(wasmtime, []byte("#!/bin/sh\necho \"input=$MY_INPUT\"\nwhile true; do sleep 1; done\n"), 0700); err != nil {
+		t.Fatalf("unable to write the fake wasmtime: %v", err)
+	}
+
+	stateDir := filepath.Join(dir, "state")
+	r := NewRuntime(wasmtime, stateDir)
+	wd := &persistence.WasmDeploymentConfig{WasmModule: "AGFzbQEAAAA="}
+
+	if err := r.Start("aaaa", wd, map[string]string{"MY_INPUT": "hello"}); err != nil {
+		t.Fatalf("unexpected error starting the module: %v", err)
+	} else if state, _ := GetModuleStatus(stateDir, "aaaa"); state != MODULE_RUNNING {
+		t.Errorf("the module should be running, is %v", state)
+	} else if err := r.Maintain("aaaa"); err != nil {
+		t.Errorf("unexpected error maintaining the module: %v", err)
+	}
+
+	// Wait for the output of the module.
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(50 * time.Millisecond) {
+		if out, _ := os.ReadFile(LogPath(stateDir, "aaaa")); strings.Contains(string(out), "input=hello") {
+			break
+		}
+	}
+	if out, _ := os.ReadFile(LogPath(stateDir, "aaaa")); !strings.Contains(string(out), "input=hello") {
+		t.Errorf("the log of the module %v does not contain its environment variable", string(out))
+	}
+
+	if err := r.Stop("aaaa"); err != nil {
+		t.Errorf("unexpected error stopping the module: %v", err)
+	} else if state, _ := GetModuleStatus(stateDir, "aaaa"); state != MODULE_NOT_STARTED {
+		t.Errorf("the module should be removed, is %v", state)
+	} else if _, err := os.Stat(modulePath(stateDir, "aaaa")); !os.IsNotExist(err) {
+		t.Errorf("the module file should be removed: %v", err)
+	}
+}
+
+func Test_moduleAlive(t *testing.T) {
+
+	stateDir := t.TempDir()
+	pid := os.Getpid()
+	start, err := processStart(pid)
+	if err != nil {
+		t.Fatalf("unable to read the start time of the test process: %v", err)
+	}
+
+	// The test process is not running the module, e.g. it reused the pid of a module that exited.
+	if moduleAlive(stateDir, "aaaa", pid, start) {
+		t.Errorf("a process without the module on its command line should not be the module")
+	} else if moduleAlive(stateDir, "aaaa", 0, 0) {
+		t.Errorf("no pid should not be the module")
+	}
+
+	if err := os.WriteFile(pidPath(stateDir, "aaaa"), []byte(fmt.Sprintf("%v %v", pid, start)), 0600); err != nil {
+		t.Fatalf("unable to write the pid file: %v", err)
+	} else if p, s := readPid(stateDir, "aaaa"); p != pid || s != start {
+		t.Errorf("wrong pid %v and start time %v", p, s)
+	}
+
+	// An agent restarted with a pid file of another process does not stop it.
+	if err := NewRuntime("wasmtime", stateDir).Stop("aaaa"); err != nil {
+		t.Errorf("unexpected error stopping the module: %v", err)
+	}
+
+	if err := os.WriteFile(pidPath(stateDir, "bbbb"), []byte(strconv.Itoa(pid)), 0600); err != nil {
+		t.Fatalf("unable to write the pid file: %v", err)
+	} else if p, s := readPid(stateDir, "bbbb"); p != pid || s != 0 {
+		t.Errorf("a pid file without start time should be read, got %v and %v", p, s)
+	} else if state, _ := GetModuleStatus(stateDir, "bbbb"); state != MODULE_EXITED {
+		t.Errorf("the module should have exited, is %v", state)
+	}
+}
+
+func Test_rotateLog(t *testing.T) {
+
+	logPath := filepath.Join(t.TempDir(), "aaaa.log")
+	if err := os.WriteFile(logPath, []byte("small"), 0600); err != nil {
+		t.Fatalf("unable to write the log: %v", err)
+	}
+	rotateLog(logPath)
+	if _, err := os.Stat(logPath + ".1"); !os.IsNotExist(err) {
+		t.Errorf("a small log should not be rotated")
+	}
+
+	if err := os.WriteFile(logPath, make([]byte, MAX_LOG_SIZE+1), 0600); err != nil {
+		t.Fatalf("unable to write the log: %v", err)
+	}
+	rotateLog(logPath)
+	if info, err := os.Stat(logPath); err != nil || info.Size() != 0 {
+		t.Errorf("the log should be truncated: %v", err)
+	} else if info, err := os.Stat(logPath + ".1"); err != nil || info.Size() != MAX_LOG_SIZE+1 {
+		t.Errorf("the log should be moved to the .1 file: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package wasm
+
+import (
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/worker"
+)
+
+// The wasm worker runs the services whose deployment is a WebAssembly module, on devices too small for a container
+// engine. The module gets the same environment variables as the containers of a service, i.e. the user input of the
+// service and the HZN_ platform variables, and is restarted and maintained the same way.
+type WasmWorker struct {
+	worker.BaseWorker // embedded field
+	db                *bolt.DB
+	runtime           *Runtime
+}
+
+func NewWasmWorker(name string, config *config.HorizonConfig, db *bolt.DB) *WasmWorker {
+
+	worker := &WasmWorker{
+		BaseWorker: worker.NewBaseWorker(name, config, nil),
+		db:         db,
+		runtime:    NewRuntime(config.GetWasmRuntimePath(), config.GetWasmStateDir()),
+	}
+
+	glog.Info(wwlog(fmt.Sprintf("Starting Wasm worker")))
+	worker.Start(worker, 0)
+	return worker
+}
+
+func (w *WasmWorker) Messages() chan events.Message {
+	return w.BaseWorker.Manager.Messages
+}
+
+func (w *WasmWorker) NewEvent(incoming events.Message) {
+
+	switch incoming.(type) {
+	case *events.AgreementReachedMessage:
+		msg, _ := incoming.(*events.AgreementReachedMessage)
+
+		fCmd := NewInstallCommand(msg.LaunchContext())
+		w.Commands <- fCmd
+
+	case *events.GovernanceWorkloadCancelationMessage:
+		msg, _ := incoming.(*events.GovernanceWorkloadCancelationMessage)
+
+		switch msg.Event().Id {
+		case events.AGREEMENT_ENDED:
+			cmd := NewUnInstallCommand(msg.AgreementProtocol, msg.AgreementId, msg.Deployment)
+			w.Commands <- cmd
+		}
+
+	case *events.GovernanceMaintenanceMessage:
+		msg, _ := incoming.(*events.GovernanceMaintenanceMessage)
+
+		switch msg.Event().Id {
+		case events.CONTAINER_MAINTAIN:
+			cmd := NewMaintenanceCommand(msg.AgreementProtocol, msg.AgreementId, msg.Deployment)
+			w.Commands <- cmd
+		}
+
+	case *events.NodeShutdownCompleteMessage:
+		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
+		switch msg.Event().Id {
+		case events.UNCONFIGURE_COMPLETE:
+			w.Commands <- worker.NewTerminateCommand("shutdown")
+		}
+
+	default: //nothing
+
+	}
+
+	return
+}
+
+func (w *WasmWorker) CommandHandler(command worker.Command) bool {
+
+	switch command.(type) {
+	case *InstallCommand:
+
+		cmd := command.(*InstallCommand)
+		if lc := w.getLaunchContext(cmd.LaunchContext); lc == nil {
+			glog.Errorf(wwlog(fmt.Sprintf("incoming event was not a known launch context: %T", cmd.LaunchContext)))
+		} else {
+			glog.V(5).Infof(wwlog(fmt.Sprintf("LaunchContext(%T) for agreement: %v", lc, lc.AgreementId)))
+
+			// Check the deployment string to see if it's a Wasm deployment.
+			deploymentConfig := lc.ContainerConfig().Deployment
+			env := map[string]string{}
+			if lc.EnvironmentAdditions != nil {
+				env = *lc.EnvironmentAdditions
+			}
+
+			if wd, err := persistence.GetWasmDeployment(deploymentConfig); err != nil {
+				glog.V(5).Infof(wwlog(fmt.Sprintf("ignoring non-Wasm deployment: %v", err)))
+				return true
+			} else if err := wd.Validate(); err != nil {
+				glog.Errorf(wwlog(fmt.Sprintf("invalid wasm deployment for agreement %v: %v", lc.AgreementId, err)))
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, wd)
+			} else if _, err := persistence.AgreementDeploymentStarted(w.db, lc.AgreementId, lc.AgreementProtocol, wd); err != nil {
+				glog.Errorf(wwlog(fmt.Sprintf("received error updating database deployment state, %v", err)))
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, wd)
+			} else if err := w.runtime.Start(lc.AgreementId, wd, env); err != nil {
+				glog.Errorf(wwlog(fmt.Sprintf("failed to run the wasm module after agreement negotiation: %v", err)))
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, wd)
+			} else {
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_BEGUN, lc.AgreementProtocol, lc.AgreementId, wd)
+			}
+		}
+
+	case *UnInstallCommand:
+
+		cmd := command.(*UnInstallCommand)
+
+		// Make sure it's a Wasm deployment.
+		wdc, ok := cmd.Deployment.(*persistence.WasmDeploymentConfig)
+		if !ok {
+			glog.V(5).Infof(wwlog(fmt.Sprintf("ignoring non-Wasm deployment: %v", cmd.Deployment)))
+			return true
+		}
+
+		glog.V(3).Infof(wwlog(fmt.Sprintf("stopping the module of agreement %v", cmd.CurrentAgreementId)))
+		if err := w.runtime.Stop(cmd.CurrentAgreementId); err != nil {
+			glog.Errorf(wwlog(fmt.Sprintf("failed to stop the wasm module after agreement cancellation: %v", err)))
+		}
+
+		w.Messages() <- events.NewWorkloadMessage(events.WORKLOAD_DESTROYED, cmd.AgreementProtocol, cmd.CurrentAgreementId, wdc)
+
+	case *MaintenanceCommand:
+		cmd := command.(*MaintenanceCommand)
+
+		wdc, ok := cmd.Deployment.(*persistence.WasmDeploymentConfig)
+		if !ok {
+			glog.V(5).Infof(wwlog(fmt.Sprintf("ignoring non-Wasm maintenance command: %v", cmd)))
+			return true
+		}
+
+		glog.V(3).Infof(wwlog(fmt.Sprintf("received maintenance command: %v", cmd)))
+		if err := w.runtime.Maintain(cmd.AgreementId); err != nil {
+			glog.Errorf(wwlog(fmt.Sprintf("%v", err)))
+			// Ask governer to cancel the agreement.
+			w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, cmd.AgreementProtocol, cmd.AgreementId, wdc)
+		}
+
+	default:
+		return false
+	}
+	return true
+
+}
+
+func (w *WasmWorker) getLaunchContext(launchContext interface{}) *events.AgreementLaunchContext {
+	switch launchContext.(type) {
+	case *events.AgreementLaunchContext:
+		lc := launchContext.(*events.AgreementLaunchContext)
+		return lc
+	}
+	return nil
+}
+
+var wwlog = func(v interface{}) string {
+	return fmt.Sprintf("Wasm Worker: %v", v)
+}
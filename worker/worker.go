@@ -5,6 +5,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/metrics"
 	"runtime"
 	"time"
 )
@@ -336,6 +337,7 @@ func (w *BaseWorker) Start(worker Worker, noWorkInterval int) {
 
 		// log worker status
 		workerStatusManager.SetWorkerStatus(w.GetName(), STATUS_STARTED)
+		metrics.WorkerQueue(w.GetName(), func() int { return len(w.Commands) })
 
 		// Allow the worker to initialize itself, or stop it if initialization determines that.
 		if !worker.Initialize() {